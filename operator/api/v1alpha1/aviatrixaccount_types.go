@@ -0,0 +1,136 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixAccountSpec defines the desired state of AviatrixAccount
+type AviatrixAccountSpec struct {
+	// AccountName is the name of the cloud account in Aviatrix Controller
+	AccountName string `json:"accountName"`
+	// CloudType is the cloud provider of the account
+	// +kubebuilder:validation:Enum=aws;azure;gcp;oci
+	CloudType string `json:"cloudType"`
+	// CredentialsSecret is the Secret in the namespace of the resource holding the credentials
+	// of the account; its keys depend on the cloud type. AWS accounts without it use IAM roles.
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// AWS holds the settings of AWS accounts
+	AWS *AwsAccount `json:"aws,omitempty"`
+	// Azure holds the settings of Azure accounts
+	Azure *AzureAccount `json:"azure,omitempty"`
+	// GCP holds the settings of GCP accounts
+	GCP *GcpAccount `json:"gcp,omitempty"`
+	// OCI holds the settings of OCI accounts
+	OCI *OciAccount `json:"oci,omitempty"`
+}
+
+// AwsAccount is an AWS account accessed through IAM roles or, when the credentials Secret holds
+// them, access keys
+type AwsAccount struct {
+	// AccountNumber is the 12-digit AWS account number
+	AccountNumber string `json:"accountNumber"`
+	// RoleArn is the role the Aviatrix Controller assumes (defaults to
+	// arn:aws:iam::<accountNumber>:role/aviatrix-role-app)
+	RoleArn string `json:"roleArn,omitempty"`
+	// EC2RoleArn is the role of the gateway instances (defaults to
+	// arn:aws:iam::<accountNumber>:role/aviatrix-role-ec2)
+	EC2RoleArn string `json:"ec2RoleArn,omitempty"`
+}
+
+// AzureAccount is an Azure subscription accessed through a service principal whose client ID
+// and secret are in the credentials Secret
+type AzureAccount struct {
+	// SubscriptionID is the Azure subscription
+	SubscriptionID string `json:"subscriptionId"`
+	// DirectoryID is the Azure AD tenant of the service principal
+	DirectoryID string `json:"directoryId"`
+}
+
+// GcpAccount is a GCP project accessed through the service account key in the credentials
+// Secret
+type GcpAccount struct {
+	// ProjectID is the GCP project
+	ProjectID string `json:"projectId"`
+}
+
+// OciAccount is an OCI tenancy accessed through the API signing key of a user, held in the
+// credentials Secret
+type OciAccount struct {
+	// TenancyID is the OCID of the tenancy
+	TenancyID string `json:"tenancyId"`
+	// UserID is the OCID of the user
+	UserID string `json:"userId"`
+	// CompartmentID is the OCID of the compartment
+	CompartmentID string `json:"compartmentId"`
+}
+
+// AviatrixAccountStatus defines the observed state of AviatrixAccount
+type AviatrixAccountStatus struct {
+	// Phase represents the current phase of the account lifecycle
+	Phase string `json:"phase"`
+	// State represents the current state of the account
+	State string `json:"state"`
+	// AccountName is the account onboarded to the Aviatrix Controller
+	AccountName string `json:"accountName,omitempty"`
+	// CredentialsHash identifies the settings and credentials last sent to the Aviatrix
+	// Controller, so rotated credentials are sent again
+	CredentialsHash string `json:"credentialsHash,omitempty"`
+	// Audit reports the last audit of the account by the Aviatrix Controller
+	Audit *AccountAuditStatus `json:"audit,omitempty"`
+	// LastUpdated is the timestamp of the last update
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// Conditions represent the latest available observations of the account's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// AccountAuditStatus reports whether the Aviatrix Controller can use the credentials and
+// permissions of an account
+type AccountAuditStatus struct {
+	// Result is Passed or Failed
+	Result string `json:"result"`
+	// Message is the reason the Aviatrix Controller reported for a failed audit
+	Message string `json:"message,omitempty"`
+	// AuditedAt is when the account was last audited
+	AuditedAt *metav1.Time `json:"auditedAt,omitempty"`
+	// FailingSince is when the audit started failing, kept while it keeps failing
+	FailingSince *metav1.Time `json:"failingSince,omitempty"`
+}
+
+// Results of account audits
+const (
+	AccountAuditPassed = "Passed"
+	AccountAuditFailed = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avacct,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="Account",type="string",JSONPath=".spec.accountName"
+//+kubebuilder:printcolumn:name="Cloud",type="string",JSONPath=".spec.cloudType"
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Audit",type="string",JSONPath=".status.audit.result"
+//+kubebuilder:printcolumn:name="Audited",type="date",JSONPath=".status.audit.auditedAt",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixAccount is the Schema for the aviatrixaccounts API. It onboards a cloud account to the
+// Aviatrix Controller, so gateways and VPCs can name it in spec.accountName.
+type AviatrixAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixAccountSpec   `json:"spec,omitempty"`
+	Status AviatrixAccountStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixAccountList contains a list of AviatrixAccount
+type AviatrixAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixAccount{}, &AviatrixAccountList{})
+}
@@ -59,6 +59,13 @@ type AviatrixControllerStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avc,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.version"
+//+kubebuilder:printcolumn:name="PublicIP",type="string",JSONPath=".status.publicIP"
+//+kubebuilder:printcolumn:name="PrivateIP",type="string",JSONPath=".status.privateIP",priority=1
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.region",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixController is the Schema for the aviatrixcontrollers API
 type AviatrixController struct {
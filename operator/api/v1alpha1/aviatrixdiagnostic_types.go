@@ -0,0 +1,98 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixDiagnosticSpec defines the desired state of AviatrixDiagnostic
+type AviatrixDiagnosticSpec struct {
+	// GwName is the name of the gateway the diagnostic runs on
+	GwName string `json:"gwName"`
+	// Action is the diagnostic to run (ping, traceroute, tunnelStatus, packetCapture)
+	Action string `json:"action"`
+	// Target is the host to ping or trace from the gateway
+	Target string `json:"target,omitempty"`
+	// Count is the number of ping packets to send (defaults to 5)
+	Count int `json:"count,omitempty"`
+	// PacketCapture configures the packet capture action
+	PacketCapture *PacketCaptureSpec `json:"packetCapture,omitempty"`
+}
+
+// PacketCaptureSpec defines a packet capture on a gateway
+type PacketCaptureSpec struct {
+	// Host filters captured packets by host
+	Host string `json:"host,omitempty"`
+	// Port filters captured packets by port
+	Port string `json:"port,omitempty"`
+	// DurationSeconds is how long the capture runs before it is stopped (defaults to 60)
+	DurationSeconds int `json:"durationSeconds,omitempty"`
+	// Stop ends a running capture early
+	Stop bool `json:"stop,omitempty"`
+	// Bucket is the bucket the capture file is uploaded to
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the uploaded object key
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// AviatrixDiagnosticStatus defines the observed state of AviatrixDiagnostic
+type AviatrixDiagnosticStatus struct {
+	// Phase represents the current phase of the diagnostic (Pending, Running, Succeeded, Failed)
+	Phase string `json:"phase"`
+	// Message explains a failure
+	Message string `json:"message,omitempty"`
+	// ObservedGeneration is the spec generation the diagnostic ran for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Output is the text output of ping or traceroute
+	Output string `json:"output,omitempty"`
+	// OutputURI is where the full output is stored when the operator has a report store
+	OutputURI string `json:"outputURI,omitempty"`
+	// Tunnels is the tunnel status dump of the gateway
+	Tunnels []TunnelStatus `json:"tunnels,omitempty"`
+	// CaptureURL is where the packet capture file was uploaded
+	CaptureURL string `json:"captureURL,omitempty"`
+	// StartedAt is when the diagnostic started
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// CompletedAt is when the diagnostic finished
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// TunnelStatus describes a tunnel of a gateway
+type TunnelStatus struct {
+	// Peer is the gateway or site at the other end of the tunnel
+	Peer string `json:"peer"`
+	// State is the tunnel state reported by the Aviatrix Controller
+	State string `json:"state"`
+	// Type is the tunnel type, such as peering or site2cloud
+	Type string `json:"type,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avdiag,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="Gateway",type="string",JSONPath=".spec.gwName"
+//+kubebuilder:printcolumn:name="Action",type="string",JSONPath=".spec.action"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Completed",type="date",JSONPath=".status.completedAt"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixDiagnostic is the Schema for the aviatrixdiagnostics API
+type AviatrixDiagnostic struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixDiagnosticSpec   `json:"spec,omitempty"`
+	Status AviatrixDiagnosticStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixDiagnosticList contains a list of AviatrixDiagnostic
+type AviatrixDiagnosticList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixDiagnostic `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixDiagnostic{}, &AviatrixDiagnosticList{})
+}
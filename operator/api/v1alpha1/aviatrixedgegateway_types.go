@@ -52,6 +52,13 @@ type AviatrixEdgeGatewayStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avegw,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="GwSize",type="string",JSONPath=".spec.gwSize"
+//+kubebuilder:printcolumn:name="SiteID",type="string",JSONPath=".spec.siteId"
+//+kubebuilder:printcolumn:name="PublicIP",type="string",JSONPath=".status.publicIP"
+//+kubebuilder:printcolumn:name="PrivateIP",type="string",JSONPath=".status.privateIP",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixEdgeGateway is the Schema for the aviatrixedgegateways API
 type AviatrixEdgeGateway struct {
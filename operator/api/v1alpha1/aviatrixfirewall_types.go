@@ -16,6 +16,51 @@ type AviatrixFirewallSpec struct {
 	Rules []FirewallRule `json:"rules,omitempty"`
 	// Tags for resource tagging
 	Tags map[string]string `json:"tags,omitempty"`
+	// UsageAnalysis periodically pulls rule hit counters and flags unused rules
+	UsageAnalysis *FirewallUsageAnalysisSpec `json:"usageAnalysis,omitempty"`
+	// Tests assert the verdict of the rules for sample connections; rules are not programmed
+	// while a test fails
+	Tests []PolicyTest `json:"tests,omitempty"`
+}
+
+// PolicyTest asserts whether a connection is allowed
+type PolicyTest struct {
+	// Name identifies the test
+	Name string `json:"name"`
+	// Src is the source IP address of the connection, or for microsegmentation the tag or
+	// instance of a policy endpoint
+	Src string `json:"src"`
+	// Dst is the destination IP address of the connection, or for microsegmentation the tag or
+	// instance of a policy endpoint
+	Dst string `json:"dst"`
+	// Port is the destination port; ignored for icmp
+	Port int32 `json:"port,omitempty"`
+	// Protocol is the protocol (tcp, udp, icmp)
+	Protocol string `json:"protocol"`
+	// Expect is the expected verdict (allow, deny)
+	// +kubebuilder:validation:Enum=allow;deny
+	Expect string `json:"expect"`
+}
+
+// PolicyTestResult is the outcome of a policy test
+type PolicyTestResult struct {
+	// Name identifies the test
+	Name string `json:"name"`
+	// Passed is set when the verdict matched the expectation
+	Passed bool `json:"passed"`
+	// Verdict is the action applied to the connection (allow, deny), empty when the test is invalid
+	Verdict string `json:"verdict,omitempty"`
+	// Message explains which rule decided the verdict, or why the test is invalid
+	Message string `json:"message,omitempty"`
+}
+
+// FirewallUsageAnalysisSpec configures the hit-count based unused rule report
+type FirewallUsageAnalysisSpec struct {
+	// Interval between two pulls of the hit counters, as a Go duration. Defaults to 1h.
+	Interval string `json:"interval,omitempty"`
+	// UnusedAfterDays flags rules without hits for this many days. Defaults to 30.
+	// +kubebuilder:validation:Minimum=1
+	UnusedAfterDays int `json:"unusedAfterDays,omitempty"`
 }
 
 // FirewallRule defines a firewall rule
@@ -44,14 +89,50 @@ type AviatrixFirewallStatus struct {
 	State string `json:"state"`
 	// RuleCount is the number of rules
 	RuleCount int `json:"ruleCount,omitempty"`
+	// RuleUsage reports the hit counters of each rule of spec.rules, in the same order
+	RuleUsage []FirewallRuleUsage `json:"ruleUsage,omitempty"`
+	// UnusedRules is the number of rules flagged unused
+	UnusedRules int `json:"unusedRules,omitempty"`
+	// LastAnalyzed is when the hit counters were last pulled
+	LastAnalyzed *metav1.Time `json:"lastAnalyzed,omitempty"`
+	// TestResults reports the outcome of each test of spec.tests, in the same order
+	TestResults []PolicyTestResult `json:"testResults,omitempty"`
+	// DriftDetectedAt is when the firewall policy was first observed out of sync with its spec
+	DriftDetectedAt *metav1.Time `json:"driftDetectedAt,omitempty"`
+	// DriftedFields lists the Aviatrix fields that currently differ from the spec
+	DriftedFields []string `json:"driftedFields,omitempty"`
 	// LastUpdated is the timestamp of the last update
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions represent the latest available observations of the firewall's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// FirewallRuleUsage is the hit count report of a firewall rule
+type FirewallRuleUsage struct {
+	// Rule identifies the rule as "protocol src dst port action"
+	Rule string `json:"rule"`
+	// Description is copied from the rule
+	Description string `json:"description,omitempty"`
+	// HitCount is the number of packets that matched the rule on the gateway
+	HitCount int64 `json:"hitCount"`
+	// LastHit is when the rule last matched traffic, if ever seen
+	LastHit *metav1.Time `json:"lastHit,omitempty"`
+	// TrackedSince is when the analyzer first saw the rule; rules never hit count as unused
+	// from then on
+	TrackedSince metav1.Time `json:"trackedSince"`
+	// Unused is set when the rule has not been hit for spec.usageAnalysis.unusedAfterDays
+	Unused bool `json:"unused,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avfw,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Gateway",type="string",JSONPath=".spec.gwName"
+//+kubebuilder:printcolumn:name="Policy",type="string",JSONPath=".spec.basePolicy"
+//+kubebuilder:printcolumn:name="Rules",type="integer",JSONPath=".status.ruleCount"
+//+kubebuilder:printcolumn:name="Unused",type="integer",JSONPath=".status.unusedRules",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixFirewall is the Schema for the aviatrixfirewalls API
 type AviatrixFirewall struct {
@@ -54,6 +54,45 @@ type AviatrixGatewaySpec struct {
 	PeeringHASubnet string `json:"peeringHASubnet,omitempty"`
 	// PeeringHAZone is the availability zone for peering HA
 	PeeringHAZone string `json:"peeringHAZone,omitempty"`
+	// Schedule stops and starts the gateway on recurring windows to save cloud cost
+	Schedule *GatewaySchedule `json:"schedule,omitempty"`
+	// SoftwareVersion is the gateway software version to upgrade to; empty keeps the current one
+	SoftwareVersion string `json:"softwareVersion,omitempty"`
+	// ImageVersion is the gateway image version to upgrade to; empty keeps the current one
+	ImageVersion string `json:"imageVersion,omitempty"`
+	// DriftPolicy decides what happens to settings changed outside the operator: Detect reports
+	// them in the DriftDetected condition, Correct also pushes the spec back where the Aviatrix
+	// Controller allows it in place. Empty detects, or corrects with the DriftRemediation
+	// feature gate.
+	// +kubebuilder:validation:Enum=Detect;Correct
+	DriftPolicy string `json:"driftPolicy,omitempty"`
+}
+
+// Drift policies of AviatrixGateway
+const (
+	DriftPolicyDetect  = "Detect"
+	DriftPolicyCorrect = "Correct"
+)
+
+// GatewaySchedule defines recurring stop/start windows for a gateway
+type GatewaySchedule struct {
+	// StopCron is the cron expression at which the gateway is stopped
+	StopCron string `json:"stopCron"`
+	// StartCron is the cron expression at which the gateway is started again
+	StartCron string `json:"startCron"`
+	// TimeZone is the IANA time zone the cron expressions are evaluated in (defaults to UTC)
+	TimeZone string `json:"timeZone,omitempty"`
+	// Suspend disables the schedule without removing it
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// GatewayScheduleStatus reports the stop/start schedule of a gateway
+type GatewayScheduleStatus struct {
+	// ScheduledStop is true while the gateway is stopped by its schedule. A gateway stopped by
+	// its schedule is started again when the schedule is removed or suspended.
+	ScheduledStop bool `json:"scheduledStop,omitempty"`
+	// NextScheduledTransition is when the schedule will next stop or start the gateway
+	NextScheduledTransition *metav1.Time `json:"nextScheduledTransition,omitempty"`
 }
 
 // AviatrixGatewayStatus defines the observed state of AviatrixGateway
@@ -74,14 +113,78 @@ type AviatrixGatewayStatus struct {
 	InstanceID string `json:"instanceId,omitempty"`
 	// HAInstanceID is the instance ID of the HA gateway
 	HAInstanceID string `json:"haInstanceId,omitempty"`
+	// GatewayScheduleStatus reports the stop/start schedule of the gateway
+	GatewayScheduleStatus `json:",inline"`
+	// DriftDetectedAt is when the gateway was first observed out of sync with its spec
+	DriftDetectedAt *metav1.Time `json:"driftDetectedAt,omitempty"`
+	// DriftedFields lists the Aviatrix fields that currently differ from the spec
+	DriftedFields []string `json:"driftedFields,omitempty"`
+	// AppliedTags lists the tag keys last applied from the spec
+	AppliedTags []string `json:"appliedTags,omitempty"`
+	// SoftwareVersion is the software version the Aviatrix Controller reports for the gateway
+	SoftwareVersion string `json:"softwareVersion,omitempty"`
+	// ImageVersion is the image version the Aviatrix Controller reports for the gateway
+	ImageVersion string `json:"imageVersion,omitempty"`
+	// Upgrade reports the last upgrade to spec.softwareVersion and spec.imageVersion
+	Upgrade *GatewayUpgradeStatus `json:"upgrade,omitempty"`
 	// LastUpdated is the timestamp of the last update
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions represent the latest available observations of the gateway's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// GatewayUpgradeStatus reports the upgrade of a gateway and its HA gateway to new versions
+type GatewayUpgradeStatus struct {
+	// Phase is Pending while another gateway upgrades, then Upgrading, Completed or Failed
+	Phase string `json:"phase"`
+	// SoftwareVersion is the software version upgraded to
+	SoftwareVersion string `json:"softwareVersion,omitempty"`
+	// ImageVersion is the image version upgraded to
+	ImageVersion string `json:"imageVersion,omitempty"`
+	// Steps lists the gateways in upgrade order, the HA gateway first
+	Steps []GatewayUpgradeStep `json:"steps,omitempty"`
+	// Message explains what the upgrade waits for or why it failed
+	Message string `json:"message,omitempty"`
+	// StartedAt is when the first gateway started upgrading
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// CompletedAt is when the upgrade completed or failed
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// GatewayUpgradeStep reports the upgrade of one gateway of a pair
+type GatewayUpgradeStep struct {
+	// GwName is the name of the gateway
+	GwName string `json:"gwName"`
+	// Phase is Pending, Verifying while the tunnels come back up, Done or Failed
+	Phase string `json:"phase"`
+	// FromSoftwareVersion is the software version before the upgrade
+	FromSoftwareVersion string `json:"fromSoftwareVersion,omitempty"`
+	// FromImageVersion is the image version before the upgrade
+	FromImageVersion string `json:"fromImageVersion,omitempty"`
+	// TunnelsUp is the number of tunnels up before the upgrade, expected up again after it
+	TunnelsUp int32 `json:"tunnelsUp,omitempty"`
+	// StartedAt is when the upgrade of the gateway was requested
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// CompletedAt is when the gateway was verified or failed
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+	// Message explains the outcome
+	Message string `json:"message,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avgw,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="GwSize",type="string",JSONPath=".spec.gwSize"
+//+kubebuilder:printcolumn:name="VpcID",type="string",JSONPath=".spec.vpcId"
+//+kubebuilder:printcolumn:name="PublicIP",type="string",JSONPath=".status.publicIP"
+//+kubebuilder:printcolumn:name="Drift",type="date",JSONPath=".status.driftDetectedAt"
+//+kubebuilder:printcolumn:name="DriftPolicy",type="string",JSONPath=".spec.driftPolicy",priority=1
+//+kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.softwareVersion",priority=1
+//+kubebuilder:printcolumn:name="Upgrade",type="string",JSONPath=".status.upgrade.phase",priority=1
+//+kubebuilder:printcolumn:name="PrivateIP",type="string",JSONPath=".status.privateIP",priority=1
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.vpcRegion",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixGateway is the Schema for the aviatrixgateways API
 type AviatrixGateway struct {
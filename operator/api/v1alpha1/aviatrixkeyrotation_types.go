@@ -0,0 +1,110 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixKeyRotationSpec defines the desired state of AviatrixKeyRotation
+type AviatrixKeyRotationSpec struct {
+	// ConnectionType is the kind of connection whose credentials rotate (site2cloud, bgp)
+	ConnectionType string `json:"connectionType"`
+	// AuthType is the credential rotated (psk, certificate); defaults to psk
+	AuthType string `json:"authType,omitempty"`
+	// GwName is the gateway the connection is configured on
+	GwName string `json:"gwName"`
+	// ConnectionName is the name of the connection on the gateway
+	ConnectionName string `json:"connectionName"`
+	// Peer is the other side of the connection when it is also managed by the Aviatrix Controller
+	Peer *KeyRotationPeer `json:"peer,omitempty"`
+	// Schedule is a cron expression for periodic rotation; without it keys only rotate on demand
+	Schedule string `json:"schedule,omitempty"`
+	// TimeZone is the IANA time zone the schedule is evaluated in (defaults to UTC)
+	TimeZone string `json:"timeZone,omitempty"`
+	// OverlapSeconds is how long the previous key stays valid next to the new one (defaults to 3600)
+	OverlapSeconds int `json:"overlapSeconds,omitempty"`
+	// CertificateValidityDays is how long generated certificates are valid (defaults to 365)
+	CertificateValidityDays int `json:"certificateValidityDays,omitempty"`
+	// SecretName is the Secret the current credentials are stored in (defaults to <name>-credentials)
+	SecretName string `json:"secretName,omitempty"`
+	// HistoryLimit caps the rotations kept in status (defaults to 10)
+	HistoryLimit int `json:"historyLimit,omitempty"`
+}
+
+// KeyRotationPeer identifies the remote side of a connection
+type KeyRotationPeer struct {
+	// GwName is the peer gateway
+	GwName string `json:"gwName"`
+	// ConnectionName is the name of the connection on the peer gateway
+	ConnectionName string `json:"connectionName"`
+}
+
+// AviatrixKeyRotationStatus defines the observed state of AviatrixKeyRotation
+type AviatrixKeyRotationStatus struct {
+	// Phase represents the rotation state (Idle, Overlapping, Failed)
+	Phase string `json:"phase,omitempty"`
+	// Message explains a failure
+	Message string `json:"message,omitempty"`
+	// CurrentKeyID is a fingerprint of the credential in use
+	CurrentKeyID string `json:"currentKeyID,omitempty"`
+	// PreviousKeyID is a fingerprint of the credential still accepted during the overlap
+	PreviousKeyID string `json:"previousKeyID,omitempty"`
+	// LastRotated is when the current credential was installed
+	LastRotated *metav1.Time `json:"lastRotated,omitempty"`
+	// OverlapEnds is when the previous credential is removed from both sides
+	OverlapEnds *metav1.Time `json:"overlapEnds,omitempty"`
+	// NextRotation is the next scheduled rotation
+	NextRotation *metav1.Time `json:"nextRotation,omitempty"`
+	// LastRequest is the last on-demand rotation request that was handled
+	LastRequest string `json:"lastRequest,omitempty"`
+	// History lists the most recent rotations, newest last
+	History []KeyRotationRecord `json:"history,omitempty"`
+}
+
+// KeyRotationRecord describes one rotation
+type KeyRotationRecord struct {
+	// KeyID is the fingerprint of the credential the rotation installed
+	KeyID string `json:"keyID,omitempty"`
+	// Reason is why the rotation ran (Initial, Scheduled, Requested)
+	Reason string `json:"reason"`
+	// Result is the outcome of the rotation (Overlapping, Completed, Failed)
+	Result string `json:"result"`
+	// Message explains a failure
+	Message string `json:"message,omitempty"`
+	// StartedAt is when the new credential was installed
+	StartedAt metav1.Time `json:"startedAt"`
+	// CompletedAt is when the previous credential was removed or the rotation failed
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avkr,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="Gateway",type="string",JSONPath=".spec.gwName"
+//+kubebuilder:printcolumn:name="Connection",type="string",JSONPath=".spec.connectionName"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="KeyID",type="string",JSONPath=".status.currentKeyID"
+//+kubebuilder:printcolumn:name="Rotated",type="date",JSONPath=".status.lastRotated"
+//+kubebuilder:printcolumn:name="Next",type="date",JSONPath=".status.nextRotation",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixKeyRotation is the Schema for the aviatrixkeyrotations API
+type AviatrixKeyRotation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixKeyRotationSpec   `json:"spec,omitempty"`
+	Status AviatrixKeyRotationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixKeyRotationList contains a list of AviatrixKeyRotation
+type AviatrixKeyRotationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixKeyRotation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixKeyRotation{}, &AviatrixKeyRotationList{})
+}
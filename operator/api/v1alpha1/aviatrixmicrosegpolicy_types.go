@@ -24,6 +24,9 @@ type AviatrixMicrosegPolicySpec struct {
 	LogEnabled bool `json:"logEnabled,omitempty"`
 	// Tags for resource tagging
 	Tags map[string]string `json:"tags,omitempty"`
+	// Tests assert the verdict of the policy for sample connections; connections the policy
+	// does not match are denied. The policy is not programmed while a test fails.
+	Tests []PolicyTest `json:"tests,omitempty"`
 }
 
 // PolicyEndpoint defines a policy endpoint
@@ -48,12 +51,21 @@ type AviatrixMicrosegPolicyStatus struct {
 	PolicyID string `json:"policyId,omitempty"`
 	// LastUpdated is the timestamp of the last update
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// TestResults reports the outcome of each test of spec.tests, in the same order
+	TestResults []PolicyTestResult `json:"testResults,omitempty"`
 	// Conditions represent the latest available observations of the microsegmentation policy's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avmsp,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Action",type="string",JSONPath=".spec.action"
+//+kubebuilder:printcolumn:name="Protocol",type="string",JSONPath=".spec.protocol"
+//+kubebuilder:printcolumn:name="Port",type="string",JSONPath=".spec.port"
+//+kubebuilder:printcolumn:name="PolicyID",type="string",JSONPath=".status.policyId",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixMicrosegPolicy is the Schema for the aviatrixmicrosegpolicies API
 type AviatrixMicrosegPolicy struct {
@@ -20,6 +20,9 @@ type AviatrixNetworkDomainSpec struct {
 	CloudType string `json:"cloudType"`
 	// Tags for resource tagging
 	Tags map[string]string `json:"tags,omitempty"`
+	// ConnectedDomains are the names of the network or segmentation security domains this
+	// domain gets a connection policy with; traffic then flows both ways
+	ConnectedDomains []string `json:"connectedDomains,omitempty"`
 }
 
 // AviatrixNetworkDomainStatus defines the observed state of AviatrixNetworkDomain
@@ -34,10 +37,40 @@ type AviatrixNetworkDomainStatus struct {
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions represent the latest available observations of the network domain's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Connectivity is the row of the domain in the connectivity matrix of the Controller: every
+	// known domain and whether traffic may flow between it and this domain
+	Connectivity []DomainConnectivity `json:"connectivity,omitempty"`
+
+	// ConnectivityRefreshed is when the connectivity was last read from the Controller
+	ConnectivityRefreshed *metav1.Time `json:"connectivityRefreshed,omitempty"`
+
+	// ConnectedDomains are the domains the operator created connection policies with for
+	// spec.connectedDomains; only these are removed when dropped from the spec
+	ConnectedDomains []string `json:"connectedDomains,omitempty"`
+}
+
+// DomainConnectivity is whether traffic may flow between two network domains
+type DomainConnectivity struct {
+	// Domain is the name of the other domain
+	Domain string `json:"domain"`
+
+	// Connected is true when a connection policy joins the domains, or for the domain itself
+	Connected bool `json:"connected"`
+
+	// Reason is Self, ConnectionPolicy or Isolated
+	Reason string `json:"reason"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avnd,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="CIDR",type="string",JSONPath=".spec.cidr"
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.region"
+//+kubebuilder:printcolumn:name="DomainID",type="string",JSONPath=".status.domainId",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixNetworkDomain is the Schema for the aviatrixnetworkdomains API
 type AviatrixNetworkDomain struct {
@@ -12,6 +12,9 @@ type AviatrixSegmentationSecurityDomainSpec struct {
 	Type string `json:"type"`
 	// Tags for resource tagging
 	Tags map[string]string `json:"tags,omitempty"`
+	// ConnectedDomains are the names of the network or segmentation security domains this
+	// domain gets a connection policy with; traffic then flows both ways
+	ConnectedDomains []string `json:"connectedDomains,omitempty"`
 }
 
 // AviatrixSegmentationSecurityDomainStatus defines the observed state of AviatrixSegmentationSecurityDomain
@@ -26,10 +29,25 @@ type AviatrixSegmentationSecurityDomainStatus struct {
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions represent the latest available observations of the segmentation security domain's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Connectivity is the row of the domain in the connectivity matrix of the Controller
+	Connectivity []DomainConnectivity `json:"connectivity,omitempty"`
+
+	// ConnectivityRefreshed is when the connectivity was last read from the Controller
+	ConnectivityRefreshed *metav1.Time `json:"connectivityRefreshed,omitempty"`
+
+	// ConnectedDomains are the domains the operator created connection policies with for
+	// spec.connectedDomains; only these are removed when dropped from the spec
+	ConnectedDomains []string `json:"connectedDomains,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avssd,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+//+kubebuilder:printcolumn:name="DomainID",type="string",JSONPath=".status.domainId",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixSegmentationSecurityDomain is the Schema for the aviatrixsegmentationsecuritydomains API
 type AviatrixSegmentationSecurityDomain struct {
@@ -72,6 +72,30 @@ type AviatrixSpokeGatewaySpec struct {
 	BgpLanVpcID string `json:"bgpLanVpcId,omitempty"`
 	// EnableBgpLan enables BGP LAN
 	EnableBgpLan bool `json:"enableBgpLan,omitempty"`
+	// Advertisement customizes the routes the spoke advertises over its transit attachment
+	Advertisement *SpokeAdvertisementSpec `json:"advertisement,omitempty"`
+	// SoftwareVersion is the gateway software version to upgrade to; empty keeps the current one
+	SoftwareVersion string `json:"softwareVersion,omitempty"`
+	// ImageVersion is the gateway image version to upgrade to; empty keeps the current one
+	ImageVersion string `json:"imageVersion,omitempty"`
+	// Schedule stops and starts the spoke gateway on recurring windows to save cloud cost
+	Schedule *GatewaySchedule `json:"schedule,omitempty"`
+}
+
+// SpokeAdvertisementSpec customizes the routes a spoke gateway advertises to its transit.
+// Without it the spoke advertises its whole VPC CIDR.
+type SpokeAdvertisementSpec struct {
+	// IncludedCidrs are advertised in place of the VPC CIDR
+	IncludedCidrs []string `json:"includedCidrs,omitempty"`
+	// AttachedSubnets limits the attachment to these spoke subnet CIDRs, which are advertised
+	// along with IncludedCidrs
+	AttachedSubnets []string `json:"attachedSubnets,omitempty"`
+	// ExcludedCidrs are never advertised: included CIDRs and attached subnets inside them are
+	// dropped. Requires IncludedCidrs or AttachedSubnets.
+	ExcludedCidrs []string `json:"excludedCidrs,omitempty"`
+	// PrependASPath is prepended to the AS path of the BGP routes the spoke advertises
+	// +kubebuilder:validation:MaxItems=25
+	PrependASPath []string `json:"prependASPath,omitempty"`
 }
 
 // AviatrixSpokeGatewayStatus defines the observed state of AviatrixSpokeGateway
@@ -92,14 +116,40 @@ type AviatrixSpokeGatewayStatus struct {
 	InstanceID string `json:"instanceId,omitempty"`
 	// HAInstanceID is the instance ID of the HA spoke gateway
 	HAInstanceID string `json:"haInstanceId,omitempty"`
+	// AttachedTransitGw is the transit gateway the spoke gateway is attached to; empty when it
+	// is not attached
+	AttachedTransitGw string `json:"attachedTransitGw,omitempty"`
 	// LastUpdated is the timestamp of the last update
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// AdvertisedCidrs lists the CIDRs last advertised from spec.advertisement; empty when the
+	// spoke advertises its VPC CIDR
+	AdvertisedCidrs []string `json:"advertisedCidrs,omitempty"`
+	// PrependASPath is the AS path prepending last applied from spec.advertisement
+	PrependASPath []string `json:"prependASPath,omitempty"`
+	// Upgrade reports the last upgrade to spec.softwareVersion and spec.imageVersion
+	Upgrade *GatewayUpgradeStatus `json:"upgrade,omitempty"`
+	// DriftDetectedAt is when the spoke gateway was first observed out of sync with its spec
+	DriftDetectedAt *metav1.Time `json:"driftDetectedAt,omitempty"`
+	// DriftedFields lists the Aviatrix fields that currently differ from the spec
+	DriftedFields []string `json:"driftedFields,omitempty"`
+	// GatewayScheduleStatus reports the stop/start schedule of the spoke gateway
+	GatewayScheduleStatus `json:",inline"`
 	// Conditions represent the latest available observations of the spoke gateway's state
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avsgw,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="GwSize",type="string",JSONPath=".spec.gwSize"
+//+kubebuilder:printcolumn:name="VpcID",type="string",JSONPath=".spec.vpcId"
+//+kubebuilder:printcolumn:name="PublicIP",type="string",JSONPath=".status.publicIP"
+//+kubebuilder:printcolumn:name="Transit",type="string",JSONPath=".spec.transitGw"
+//+kubebuilder:printcolumn:name="Upgrade",type="string",JSONPath=".status.upgrade.phase",priority=1
+//+kubebuilder:printcolumn:name="PrivateIP",type="string",JSONPath=".status.privateIP",priority=1
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.vpcRegion",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixSpokeGateway is the Schema for the aviatrixspokegateways API
 type AviatrixSpokeGateway struct {
@@ -0,0 +1,82 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixTgwAttachmentSpec defines the desired state of AviatrixTgwAttachment
+type AviatrixTgwAttachmentSpec struct {
+	// TgwName is the name of the AWS Transit Gateway in Aviatrix Controller
+	TgwName string `json:"tgwName"`
+	// Region is the region of the AWS Transit Gateway
+	Region string `json:"region"`
+	// VpcAccountName is the cloud account name of the VPC in Aviatrix Controller
+	VpcAccountName string `json:"vpcAccountName"`
+	// VpcID is the ID of the VPC to attach
+	VpcID string `json:"vpcId"`
+	// NetworkDomain is the network domain of the TGW the VPC is attached to
+	NetworkDomain string `json:"networkDomain"`
+	// Subnets are the subnets of the VPC the attachment uses, one per availability zone; empty
+	// lets the Controller pick them
+	Subnets []string `json:"subnets,omitempty"`
+	// RouteTables are the route tables of the VPC that get routes to the TGW; empty means all
+	RouteTables []string `json:"routeTables,omitempty"`
+	// CustomizedRoutes replace the routes programmed in the VPC route tables; empty programs
+	// the CIDRs of the connected network domains
+	CustomizedRoutes []string `json:"customizedRoutes,omitempty"`
+	// CustomizedRouteAdvertisement replaces the CIDRs the VPC advertises to the TGW; empty
+	// advertises the VPC CIDR
+	CustomizedRouteAdvertisement []string `json:"customizedRouteAdvertisement,omitempty"`
+	// DisableLocalRoutePropagation stops the VPC CIDR from propagating to the route table of
+	// its own network domain
+	DisableLocalRoutePropagation bool `json:"disableLocalRoutePropagation,omitempty"`
+}
+
+// AviatrixTgwAttachmentStatus defines the observed state of AviatrixTgwAttachment
+type AviatrixTgwAttachmentStatus struct {
+	// Phase represents the current phase of the attachment lifecycle
+	Phase string `json:"phase"`
+	// State represents the current state of the attachment
+	State string `json:"state"`
+	// AttachedTgwName is the TGW the VPC is attached to on the Aviatrix Controller
+	AttachedTgwName string `json:"attachedTgwName,omitempty"`
+	// AttachedVpcID is the VPC attached on the Aviatrix Controller
+	AttachedVpcID string `json:"attachedVpcId,omitempty"`
+	// LastUpdated is the timestamp of the last update
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// Conditions represent the latest available observations of the attachment's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avtgwa,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="TGW",type="string",JSONPath=".spec.tgwName"
+//+kubebuilder:printcolumn:name="VpcID",type="string",JSONPath=".spec.vpcId"
+//+kubebuilder:printcolumn:name="Domain",type="string",JSONPath=".spec.networkDomain"
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.region",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixTgwAttachment is the Schema for the aviatrixtgwattachments API. It attaches a VPC to
+// an AWS Transit Gateway managed by the Aviatrix Controller.
+type AviatrixTgwAttachment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixTgwAttachmentSpec   `json:"spec,omitempty"`
+	Status AviatrixTgwAttachmentStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixTgwAttachmentList contains a list of AviatrixTgwAttachment
+type AviatrixTgwAttachmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixTgwAttachment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixTgwAttachment{}, &AviatrixTgwAttachmentList{})
+}
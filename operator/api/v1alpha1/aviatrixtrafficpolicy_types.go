@@ -0,0 +1,123 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixTrafficPolicySpec defines the desired state of AviatrixTrafficPolicy
+type AviatrixTrafficPolicySpec struct {
+	// Workloads names the groups of pods and addresses the intent refers to
+	Workloads []TrafficWorkload `json:"workloads"`
+	// Allow lists the permitted flows between workloads; any other traffic to a workload is
+	// denied, except traffic within the same workload
+	Allow []TrafficFlow `json:"allow,omitempty"`
+	// Firewall compiles the intent into rules of an AviatrixFirewall on a gateway; the gateway
+	// must not be managed by another AviatrixFirewall
+	Firewall *TrafficPolicyFirewall `json:"firewall,omitempty"`
+	// Microseg compiles the intent into AviatrixMicrosegPolicies
+	Microseg *TrafficPolicyMicroseg `json:"microseg,omitempty"`
+}
+
+// TrafficWorkload is a named group of pods and addresses
+type TrafficWorkload struct {
+	// Name identifies the workload in flows
+	Name string `json:"name"`
+	// PodSelector selects the pods of the workload in the namespace of the policy; workloads
+	// with a selector get a NetworkPolicy
+	PodSelector map[string]string `json:"podSelector,omitempty"`
+	// CIDRs are the addresses of the workload outside the cluster network, e.g. the pod or VPC
+	// subnets seen by Aviatrix gateways
+	CIDRs []string `json:"cidrs,omitempty"`
+	// Tag is the Aviatrix tag of the workload used by microsegmentation; CIDRs are used as
+	// subnet endpoints without it
+	Tag string `json:"tag,omitempty"`
+}
+
+// TrafficFlow permits traffic from one workload to the ports of another
+type TrafficFlow struct {
+	// From is the workload opening connections
+	From string `json:"from"`
+	// To is the workload accepting connections
+	To string `json:"to"`
+	// Ports are the permitted destination ports; empty permits all ports
+	Ports []TrafficPort `json:"ports,omitempty"`
+}
+
+// TrafficPort is a destination port of a flow
+type TrafficPort struct {
+	// Port is the port number
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+	// Protocol is the protocol (TCP, UDP); defaults to TCP
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// TrafficPolicyFirewall selects the gateway receiving the compiled firewall rules
+type TrafficPolicyFirewall struct {
+	// GwName is the name of the gateway
+	GwName string `json:"gwName"`
+	// LogEnabled enables logging for the compiled rules
+	LogEnabled bool `json:"logEnabled,omitempty"`
+}
+
+// TrafficPolicyMicroseg configures the compiled microsegmentation policies
+type TrafficPolicyMicroseg struct {
+	// LogEnabled enables logging for the compiled policies
+	LogEnabled bool `json:"logEnabled,omitempty"`
+}
+
+// AviatrixTrafficPolicyStatus defines the observed state of AviatrixTrafficPolicy
+type AviatrixTrafficPolicyStatus struct {
+	// Phase represents the compilation state (Compiled, Failed)
+	Phase string `json:"phase,omitempty"`
+	// Message explains a failure
+	Message string `json:"message,omitempty"`
+	// ObservedGeneration is the generation the artifacts were compiled from
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Artifacts lists the resources compiled from the intent
+	Artifacts []TrafficPolicyArtifact `json:"artifacts,omitempty"`
+	// LastCompiled is when the artifacts were last applied
+	LastCompiled *metav1.Time `json:"lastCompiled,omitempty"`
+	// Conditions represent the latest available observations of the traffic policy's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// TrafficPolicyArtifact is a resource compiled from the intent
+type TrafficPolicyArtifact struct {
+	// Kind is the kind of the resource (NetworkPolicy, AviatrixFirewall, AviatrixMicrosegPolicy)
+	Kind string `json:"kind"`
+	// Name is the name of the resource in the namespace of the policy
+	Name string `json:"name"`
+	// Summary describes what the resource enforces
+	Summary string `json:"summary,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avtp,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Compiled",type="date",JSONPath=".status.lastCompiled"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixTrafficPolicy is the Schema for the aviatrixtrafficpolicies API
+type AviatrixTrafficPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixTrafficPolicySpec   `json:"spec,omitempty"`
+	Status AviatrixTrafficPolicyStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixTrafficPolicyList contains a list of AviatrixTrafficPolicy
+type AviatrixTrafficPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixTrafficPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixTrafficPolicy{}, &AviatrixTrafficPolicyList{})
+}
@@ -88,6 +88,8 @@ type AviatrixTransitGatewaySpec struct {
 	EnableMulticastInterfaces bool `json:"enableMulticastInterfaces,omitempty"`
 	// MulticastInterfaces is the list of multicast interfaces
 	MulticastInterfaces []MulticastInterface `json:"multicastInterfaces,omitempty"`
+	// Schedule stops and starts the transit gateway on recurring windows to save cloud cost
+	Schedule *GatewaySchedule `json:"schedule,omitempty"`
 }
 
 // MulticastInterface defines a multicast interface
@@ -116,6 +118,14 @@ type AviatrixTransitGatewayStatus struct {
 	InstanceID string `json:"instanceId,omitempty"`
 	// HAInstanceID is the instance ID of the HA transit gateway
 	HAInstanceID string `json:"haInstanceId,omitempty"`
+	// SoftwareVersion is the software version the transit gateway runs
+	SoftwareVersion string `json:"softwareVersion,omitempty"`
+	// DriftDetectedAt is when the transit gateway was first observed out of sync with its spec
+	DriftDetectedAt *metav1.Time `json:"driftDetectedAt,omitempty"`
+	// DriftedFields lists the Aviatrix fields that currently differ from the spec
+	DriftedFields []string `json:"driftedFields,omitempty"`
+	// GatewayScheduleStatus reports the stop/start schedule of the transit gateway
+	GatewayScheduleStatus `json:",inline"`
 	// LastUpdated is the timestamp of the last update
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions represent the latest available observations of the transit gateway's state
@@ -124,6 +134,14 @@ type AviatrixTransitGatewayStatus struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avtgw,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="GwSize",type="string",JSONPath=".spec.gwSize"
+//+kubebuilder:printcolumn:name="VpcID",type="string",JSONPath=".spec.vpcId"
+//+kubebuilder:printcolumn:name="PublicIP",type="string",JSONPath=".status.publicIP"
+//+kubebuilder:printcolumn:name="PrivateIP",type="string",JSONPath=".status.privateIP",priority=1
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.vpcRegion",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixTransitGateway is the Schema for the aviatrixtransitgateways API
 type AviatrixTransitGateway struct {
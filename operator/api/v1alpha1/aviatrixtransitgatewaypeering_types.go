@@ -0,0 +1,81 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixTransitGatewayPeeringSpec defines the desired state of AviatrixTransitGatewayPeering
+type AviatrixTransitGatewayPeeringSpec struct {
+	// SourceGwName is the name of the first transit gateway in Aviatrix Controller
+	SourceGwName string `json:"sourceGwName"`
+	// DestinationGwName is the name of the second transit gateway in Aviatrix Controller
+	DestinationGwName string `json:"destinationGwName"`
+	// SourceExcludedCidrs are CIDRs the source gateway does not advertise to the destination
+	SourceExcludedCidrs []string `json:"sourceExcludedCidrs,omitempty"`
+	// DestinationExcludedCidrs are CIDRs the destination gateway does not advertise to the
+	// source
+	DestinationExcludedCidrs []string `json:"destinationExcludedCidrs,omitempty"`
+	// SourcePrependAsPath is the AS path the source gateway prepends to the routes it
+	// advertises over the peering, such as its own AS number repeated
+	SourcePrependAsPath []string `json:"sourcePrependAsPath,omitempty"`
+	// DestinationPrependAsPath is the AS path the destination gateway prepends to the routes it
+	// advertises over the peering
+	DestinationPrependAsPath []string `json:"destinationPrependAsPath,omitempty"`
+}
+
+// AviatrixTransitGatewayPeeringStatus defines the observed state of AviatrixTransitGatewayPeering
+type AviatrixTransitGatewayPeeringStatus struct {
+	// Phase represents the current phase of the peering lifecycle
+	Phase string `json:"phase"`
+	// State represents the current state of the peering
+	State string `json:"state"`
+	// PeeredSourceGwName is the source gateway peered on the Aviatrix Controller
+	PeeredSourceGwName string `json:"peeredSourceGwName,omitempty"`
+	// PeeredDestinationGwName is the destination gateway peered on the Aviatrix Controller
+	PeeredDestinationGwName string `json:"peeredDestinationGwName,omitempty"`
+	// Tunnels are the tunnels of the source gateway to the destination gateway and its HA
+	// gateway
+	Tunnels []TunnelStatus `json:"tunnels,omitempty"`
+	// TunnelsUp is the number of tunnels that are up
+	TunnelsUp int32 `json:"tunnelsUp"`
+	// TunnelsTotal is the number of tunnels of the peering
+	TunnelsTotal int32 `json:"tunnelsTotal"`
+	// LastUpdated is the timestamp of the last update
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// Conditions represent the latest available observations of the peering's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avtgp,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Source",type="string",JSONPath=".spec.sourceGwName"
+//+kubebuilder:printcolumn:name="Destination",type="string",JSONPath=".spec.destinationGwName"
+//+kubebuilder:printcolumn:name="TunnelsUp",type="integer",JSONPath=".status.tunnelsUp"
+//+kubebuilder:printcolumn:name="Tunnels",type="integer",JSONPath=".status.tunnelsTotal",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixTransitGatewayPeering is the Schema for the aviatrixtransitgatewaypeerings API. It
+// peers two transit gateways managed by the Aviatrix Controller and reports the health of the
+// tunnels between them.
+type AviatrixTransitGatewayPeering struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixTransitGatewayPeeringSpec   `json:"spec,omitempty"`
+	Status AviatrixTransitGatewayPeeringStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixTransitGatewayPeeringList contains a list of AviatrixTransitGatewayPeering
+type AviatrixTransitGatewayPeeringList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixTransitGatewayPeering `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixTransitGatewayPeering{}, &AviatrixTransitGatewayPeeringList{})
+}
@@ -50,6 +50,12 @@ type AviatrixVpcStatus struct {
 	VpcID string `json:"vpcId,omitempty"`
 	// Subnets is the list of subnets
 	Subnets []SubnetInfo `json:"subnets,omitempty"`
+	// AppliedTags lists the tag keys last applied from the spec
+	AppliedTags []string `json:"appliedTags,omitempty"`
+	// DriftDetectedAt is when the VPC was first observed out of sync with its spec
+	DriftDetectedAt *metav1.Time `json:"driftDetectedAt,omitempty"`
+	// DriftedFields lists the Aviatrix fields that currently differ from the spec
+	DriftedFields []string `json:"driftedFields,omitempty"`
 	// LastUpdated is the timestamp of the last update
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 	// Conditions represent the latest available observations of the VPC's state
@@ -70,6 +76,13 @@ type SubnetInfo struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avvpc,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="VpcID",type="string",JSONPath=".status.vpcId"
+//+kubebuilder:printcolumn:name="CIDR",type="string",JSONPath=".spec.cidr"
+//+kubebuilder:printcolumn:name="Region",type="string",JSONPath=".spec.region"
+//+kubebuilder:printcolumn:name="Cloud",type="string",JSONPath=".spec.cloudType",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // AviatrixVpc is the Schema for the aviatrixvpcs API
 type AviatrixVpc struct {
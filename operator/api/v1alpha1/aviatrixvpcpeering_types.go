@@ -0,0 +1,74 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AviatrixVpcPeeringSpec defines the desired state of AviatrixVpcPeering
+type AviatrixVpcPeeringSpec struct {
+	// Requester is the VPC that requests the peering
+	Requester VpcPeeringSide `json:"requester"`
+	// Accepter is the VPC that accepts the peering
+	Accepter VpcPeeringSide `json:"accepter"`
+}
+
+// VpcPeeringSide is one VPC of a native AWS VPC peering
+type VpcPeeringSide struct {
+	// AccountName is the cloud account name of the VPC in Aviatrix Controller
+	AccountName string `json:"accountName"`
+	// VpcID is the VPC ID
+	VpcID string `json:"vpcId"`
+	// VpcRegion is the region of the VPC
+	VpcRegion string `json:"vpcRegion"`
+	// RouteTables are the route tables that get routes to the peer VPC; empty means all
+	RouteTables []string `json:"routeTables,omitempty"`
+}
+
+// AviatrixVpcPeeringStatus defines the observed state of AviatrixVpcPeering
+type AviatrixVpcPeeringStatus struct {
+	// Phase represents the current phase of the peering lifecycle
+	Phase string `json:"phase"`
+	// State represents the current state of the peering
+	State string `json:"state"`
+	// RequesterVpcID is the requester VPC of the peering created on the Aviatrix Controller
+	RequesterVpcID string `json:"requesterVpcId,omitempty"`
+	// AccepterVpcID is the accepter VPC of the peering created on the Aviatrix Controller
+	AccepterVpcID string `json:"accepterVpcId,omitempty"`
+	// LastUpdated is the timestamp of the last update
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+	// Conditions represent the latest available observations of the peering's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=avpeer,categories=aviatrix;playgrounds
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+//+kubebuilder:printcolumn:name="Requester",type="string",JSONPath=".spec.requester.vpcId"
+//+kubebuilder:printcolumn:name="Accepter",type="string",JSONPath=".spec.accepter.vpcId"
+//+kubebuilder:printcolumn:name="RequesterRegion",type="string",JSONPath=".spec.requester.vpcRegion",priority=1
+//+kubebuilder:printcolumn:name="AccepterRegion",type="string",JSONPath=".spec.accepter.vpcRegion",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AviatrixVpcPeering is the Schema for the aviatrixvpcpeerings API. It peers two AWS VPCs
+// natively through the Aviatrix Controller, without gateways.
+type AviatrixVpcPeering struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AviatrixVpcPeeringSpec   `json:"spec,omitempty"`
+	Status AviatrixVpcPeeringStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AviatrixVpcPeeringList contains a list of AviatrixVpcPeering
+type AviatrixVpcPeeringList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AviatrixVpcPeering `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AviatrixVpcPeering{}, &AviatrixVpcPeeringList{})
+}
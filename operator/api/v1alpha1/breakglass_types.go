@@ -0,0 +1,94 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BreakGlassSpec defines the desired state of BreakGlass
+type BreakGlassSpec struct {
+	// Subject is the user, group or service account receiving elevated access
+	// +kubebuilder:validation:Required
+	Subject string `json:"subject"`
+
+	// SubjectKind is the RBAC kind of the subject
+	// +kubebuilder:validation:Enum=User;Group;ServiceAccount
+	// +kubebuilder:default=User
+	SubjectKind string `json:"subjectKind,omitempty"`
+
+	// Namespace is the managed namespace the access is granted in; it must be a namespace
+	// a K8sPlaygroundsCluster manages
+	// +kubebuilder:validation:Required
+	Namespace string `json:"namespace"`
+
+	// Role is the ClusterRole bound in the namespace for the duration of the grant
+	// +kubebuilder:validation:Enum=view;edit;admin
+	// +kubebuilder:default=admin
+	Role string `json:"role,omitempty"`
+
+	// Duration is how long the access stays in place, e.g. "30m" or "2h"
+	// +kubebuilder:validation:Required
+	Duration metav1.Duration `json:"duration"`
+
+	// Reason explains why elevated access is needed and is recorded in the audit trail
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Reason string `json:"reason"`
+}
+
+// BreakGlassStatus defines the observed state of BreakGlass
+type BreakGlassStatus struct {
+	// Phase represents the current phase of the grant
+	Phase BreakGlassPhase `json:"phase,omitempty"`
+
+	// GrantedAt is when the role binding was created
+	GrantedAt *metav1.Time `json:"grantedAt,omitempty"`
+
+	// ExpiresAt is when the role binding is removed
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// RoleBinding is the name of the role binding created for the grant
+	RoleBinding string `json:"roleBinding,omitempty"`
+
+	// Message provides details about the current phase
+	Message string `json:"message,omitempty"`
+}
+
+// BreakGlassPhase represents the phase of a break-glass grant
+type BreakGlassPhase string
+
+const (
+	BreakGlassPhaseActive  BreakGlassPhase = "Active"
+	BreakGlassPhaseExpired BreakGlassPhase = "Expired"
+	BreakGlassPhaseFailed  BreakGlassPhase = "Failed"
+)
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,categories=playgrounds
+//+kubebuilder:printcolumn:name="Subject",type="string",JSONPath=".spec.subject"
+//+kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".spec.namespace"
+//+kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.role"
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".status.expiresAt"
+
+// BreakGlass is the Schema for the breakglasses API
+type BreakGlass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BreakGlassSpec   `json:"spec,omitempty"`
+	Status BreakGlassStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// BreakGlassList contains a list of BreakGlass
+type BreakGlassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BreakGlass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BreakGlass{}, &BreakGlassList{})
+}
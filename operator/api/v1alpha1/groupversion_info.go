@@ -52,6 +52,20 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&AviatrixMicrosegPolicyList{},
 		&AviatrixEdgeGateway{},
 		&AviatrixEdgeGatewayList{},
+		&AviatrixDiagnostic{},
+		&AviatrixDiagnosticList{},
+		&AviatrixKeyRotation{},
+		&AviatrixKeyRotationList{},
+		&AviatrixTrafficPolicy{},
+		&AviatrixTrafficPolicyList{},
+		&AviatrixVpcPeering{},
+		&AviatrixVpcPeeringList{},
+		&AviatrixTgwAttachment{},
+		&AviatrixTgwAttachmentList{},
+		&AviatrixAccount{},
+		&AviatrixAccountList{},
+		&AviatrixTransitGatewayPeering{},
+		&AviatrixTransitGatewayPeeringList{},
 	)
 	metav1.AddToGroupVersion(scheme, SchemeGroupVersion)
 	return nil
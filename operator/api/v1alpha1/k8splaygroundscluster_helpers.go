@@ -0,0 +1,39 @@
+package v1alpha1
+
+import "sort"
+
+// ManagedNamespaces returns the sorted set of namespaces the cluster's workloads run in,
+// always including the namespace of the cluster itself
+func (c *K8sPlaygroundsCluster) ManagedNamespaces() []string {
+	seen := map[string]bool{c.Namespace: true}
+	add := func(ns string) {
+		if ns != "" {
+			seen[ns] = true
+		}
+	}
+	for _, d := range c.Spec.Deployments {
+		add(d.Namespace)
+	}
+	for _, s := range c.Spec.StatefulSets {
+		add(s.Namespace)
+	}
+	for _, d := range c.Spec.DaemonSets {
+		add(d.Namespace)
+	}
+	for _, rs := range c.Spec.ReplicaSets {
+		add(rs.Namespace)
+	}
+	for _, j := range c.Spec.Jobs {
+		add(j.Namespace)
+	}
+	for _, cj := range c.Spec.CronJobs {
+		add(cj.Namespace)
+	}
+
+	namespaces := make([]string, 0, len(seen))
+	for ns := range seen {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
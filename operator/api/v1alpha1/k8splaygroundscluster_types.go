@@ -35,6 +35,9 @@ type K8sPlaygroundsClusterSpec struct {
 	// ConfigMaps defines the config maps configuration
 	ConfigMaps []ConfigMapSpec `json:"configMaps,omitempty"`
 
+	// Variables are substituted into ConfigMap data templates as {{ .Variables.<name> }}
+	Variables map[string]string `json:"variables,omitempty"`
+
 	// Secrets defines the secrets configuration
 	Secrets []SecretSpec `json:"secrets,omitempty"`
 
@@ -76,6 +79,42 @@ type K8sPlaygroundsClusterSpec struct {
 
 	// Performance defines the performance configuration
 	Performance *PerformanceSpec `json:"performance,omitempty"`
+
+	// Logging defines log collection for managed workloads
+	Logging *LoggingSpec `json:"logging,omitempty"`
+
+	// Access defines how credentials for the cluster namespace are issued to users
+	Access *AccessSpec `json:"access,omitempty"`
+
+	// Hooks defines Jobs run before and after each revision of the cluster is applied
+	Hooks *HooksSpec `json:"hooks,omitempty"`
+
+	// SeedJobs load data into a StatefulSet once all its replicas are Ready and their DNS names
+	// resolve, and again when its data is recreated, e.g. by auto-healing
+	SeedJobs []SeedJobSpec `json:"seedJobs,omitempty"`
+
+	// Labeling defines the labels and annotations added to every managed resource
+	Labeling *LabelingSpec `json:"labeling,omitempty"`
+
+	// MaintenanceWindow defers disruptive changes, such as version upgrades and StatefulSet
+	// restarts, to a recurring window; other changes still apply immediately
+	MaintenanceWindow *MaintenanceWindowSpec `json:"maintenanceWindow,omitempty"`
+
+	// Checks are application-level probes against managed Services. They are evaluated
+	// independent of pod readiness and their outcome is part of the cluster health.
+	Checks []HealthCheckSpec `json:"checks,omitempty"`
+
+	// SoftDelete keeps a deleted cluster in the Trash phase, with its workloads scaled to zero,
+	// for a retention period before its resources and data are destroyed
+	SoftDelete *SoftDeleteSpec `json:"softDelete,omitempty"`
+
+	// PriorityClasses are created for the workloads of the cluster to reference. They are
+	// cluster-scoped and removed together with the cluster.
+	PriorityClasses []PriorityClassSpec `json:"priorityClasses,omitempty"`
+
+	// RuntimeClasses are created for the workloads of the cluster to reference, such as gVisor
+	// or Kata Containers. They are cluster-scoped and removed together with the cluster.
+	RuntimeClasses []RuntimeClassSpec `json:"runtimeClasses,omitempty"`
 }
 
 // K8sPlaygroundsClusterStatus defines the observed state of K8sPlaygroundsCluster
@@ -104,11 +143,51 @@ type K8sPlaygroundsClusterStatus struct {
 	// LastUpdated represents the last time the status was updated
 	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
 
+	// ReconcileErrors counts the reconciles that failed since the last successful one
+	ReconcileErrors int32 `json:"reconcileErrors,omitempty"`
+
+	// LastReconcileError is the error of the last failed reconcile, cleared by a successful one
+	LastReconcileError string `json:"lastReconcileError,omitempty"`
+
 	// Version represents the current version of the cluster
 	Version string `json:"version,omitempty"`
 
 	// Health represents the overall health of the cluster
 	Health ClusterHealth `json:"health,omitempty"`
+
+	// AdmittedAt is when the cluster was admitted to active reconciliation
+	AdmittedAt *metav1.Time `json:"admittedAt,omitempty"`
+
+	// QueuePosition is the position of the cluster in the admission queue while Queued
+	QueuePosition int32 `json:"queuePosition,omitempty"`
+
+	// KubeconfigSecret is the name of the Secret holding the generated access kubeconfig
+	KubeconfigSecret string `json:"kubeconfigSecret,omitempty"`
+
+	// Hooks reports the hook Jobs run for the current revision
+	Hooks []HookStatus `json:"hooks,omitempty"`
+
+	// SeedJobs reports the seeding state of every seed job
+	SeedJobs []SeedJobStatus `json:"seedJobs,omitempty"`
+
+	// Maintenance reports the maintenance window and the changes waiting for it
+	Maintenance *MaintenanceStatus `json:"maintenance,omitempty"`
+
+	// Checks reports the outcome of the health checks in spec.checks
+	Checks []HealthCheckStatus `json:"checks,omitempty"`
+
+	// VolumePlacements reports the claims created for StatefulSets with a volume placement
+	VolumePlacements []VolumePlacementStatus `json:"volumePlacements,omitempty"`
+
+	// StatefulSetIdentities maps every ordinal of the StatefulSets to its pod, claims, volumes
+	// and node, and reports where the identity guarantees do not hold
+	StatefulSetIdentities []StatefulSetIdentityStatus `json:"statefulSetIdentities,omitempty"`
+
+	// Diagnostics reports container terminations, restarts and Warning events of the managed pods
+	Diagnostics *DiagnosticsStatus `json:"diagnostics,omitempty"`
+
+	// Trash reports when a soft-deleted cluster was deleted and when it will be purged
+	Trash *TrashStatus `json:"trash,omitempty"`
 }
 
 // ClusterPhase represents the phase of a cluster
@@ -116,11 +195,13 @@ type ClusterPhase string
 
 const (
 	ClusterPhasePending   ClusterPhase = "Pending"
+	ClusterPhaseQueued    ClusterPhase = "Queued"
 	ClusterPhaseRunning   ClusterPhase = "Running"
 	ClusterPhaseUpdating  ClusterPhase = "Updating"
 	ClusterPhaseScaling   ClusterPhase = "Scaling"
 	ClusterPhaseFailed    ClusterPhase = "Failed"
 	ClusterPhaseDeleting  ClusterPhase = "Deleting"
+	ClusterPhaseTrash     ClusterPhase = "Trash"
 	ClusterPhaseUnknown   ClusterPhase = "Unknown"
 )
 
@@ -147,12 +228,13 @@ type ClusterCondition struct {
 type ClusterConditionType string
 
 const (
-	ClusterConditionReady           ClusterConditionType = "Ready"
-	ClusterConditionHealthy         ClusterConditionType = "Healthy"
-	ClusterConditionScaling         ClusterConditionType = "Scaling"
-	ClusterConditionUpdating        ClusterConditionType = "Updating"
-	ClusterConditionBackupEnabled   ClusterConditionType = "BackupEnabled"
-	ClusterConditionMonitoringReady ClusterConditionType = "MonitoringReady"
+	ClusterConditionReady            ClusterConditionType = "Ready"
+	ClusterConditionHealthy          ClusterConditionType = "Healthy"
+	ClusterConditionScaling          ClusterConditionType = "Scaling"
+	ClusterConditionUpdating         ClusterConditionType = "Updating"
+	ClusterConditionBackupEnabled    ClusterConditionType = "BackupEnabled"
+	ClusterConditionMonitoringReady  ClusterConditionType = "MonitoringReady"
+	ClusterConditionIdentityViolated ClusterConditionType = "IdentityViolated"
 )
 
 // ServiceSpec defines the specification for a service
@@ -161,8 +243,10 @@ type ServiceSpec struct {
 	Namespace   string            `json:"namespace,omitempty"`
 	Labels      map[string]string `json:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"`
-	Selector    map[string]string `json:"selector"`
-	Ports       []ServicePort     `json:"ports"`
+	// Selector and Ports are required unless Mirror is set, in which case they are taken from
+	// the mirrored Service
+	Selector map[string]string `json:"selector,omitempty"`
+	Ports    []ServicePort     `json:"ports,omitempty"`
 	Type        string            `json:"type,omitempty"`
 }
 
@@ -181,8 +265,10 @@ type HeadlessServiceSpec struct {
 	Namespace   string            `json:"namespace,omitempty"`
 	Labels      map[string]string `json:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty"`
-	Selector    map[string]string `json:"selector"`
-	Ports       []ServicePort     `json:"ports"`
+	// Selector and Ports are required unless Mirror is set, in which case they are taken from
+	// the mirrored Service
+	Selector map[string]string `json:"selector,omitempty"`
+	Ports    []ServicePort     `json:"ports,omitempty"`
 	
 	// DNS configuration
 	DNS *DNSSpec `json:"dns,omitempty"`
@@ -192,13 +278,157 @@ type HeadlessServiceSpec struct {
 	
 	// iptables proxy configuration
 	IptablesProxy *IptablesProxySpec `json:"iptablesProxy,omitempty"`
+
+	// Conformance mode disables operator proxying and reports native headless behavior
+	// side by side with an equivalent ClusterIP Service
+	ConformanceMode bool `json:"conformanceMode,omitempty"`
+
+	// Peer list publishing for StatefulSets behind the headless service
+	PeerList *PeerListSpec `json:"peerList,omitempty"`
+
+	// Maximum number of pods the selector may match; beyond it endpoints and iptables rules are
+	// no longer updated. Defaults to the operator-wide limit.
+	MaxMatchedPods int32 `json:"maxMatchedPods,omitempty"`
+
+	// Load test of the data path behind the headless service; changing runID starts a new run
+	LoadTest *LoadTestSpec `json:"loadTest,omitempty"`
+
+	// Scheduling priority and resources of the discovery, DNS test and iptables proxy pods the
+	// operator runs for the service; unset fields use the operator-wide defaults
+	HelperPods *HelperPodsSpec `json:"helperPods,omitempty"`
+
+	// Mirror adopts an existing Service, e.g. one installed by a Helm chart: the managed
+	// headless Service copies its selector and ports and the operator features are layered on
+	// top, while the existing Service is left untouched
+	Mirror *MirrorSpec `json:"mirror,omitempty"`
+
+	// Federation merges the endpoints of the HeadlessServices with the same name in member
+	// clusters into one DNS view with per-cluster records and locality-preferring answers
+	Federation *FederationSpec `json:"federation,omitempty"`
+
+	// ExternalEndpoints publish backends outside the cluster, e.g. a managed database, as
+	// <name>.<service>.<namespace>.svc.<domain>. They are part of DNS answers and discovery
+	// output but only load balanced by the iptables proxy when they set loadBalance.
+	ExternalEndpoints []ExternalEndpointSpec `json:"externalEndpoints,omitempty"`
+
+	// EndpointFilter calls a hook with the pods the selector matches on every reconcile; only
+	// the pods it returns become endpoints, e.g. those that registered with the application
+	EndpointFilter *EndpointFilterSpec `json:"endpointFilter,omitempty"`
+
+	// DemoWorkload runs demo pods matching the selector, so endpoint churn, load balancing and
+	// DNS behavior can be shown without writing a workload
+	DemoWorkload *DemoWorkloadSpec `json:"demoWorkload,omitempty"`
+}
+
+// ExternalEndpointSpec is a backend outside the cluster given by exactly one of IP and Hostname
+type ExternalEndpointSpec struct {
+	Name        string `json:"name"` // DNS label of the record below the service
+	IP          string `json:"ip,omitempty"`
+	Hostname    string `json:"hostname,omitempty"`    // resolved by the operator, e.g. an RDS endpoint
+	LoadBalance bool   `json:"loadBalance,omitempty"` // include in iptables load balancing
+}
+
+// DemoWorkloadSpec configures the demo pods of a headless service. The default image answers
+// HTTP requests on every port of the service with the name of the pod.
+type DemoWorkloadSpec struct {
+	Replicas int32             `json:"replicas"`
+	Image    string            `json:"image,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"` // added to the labels of the selector
+	// FlakinessPercent is the chance that a readiness probe fails, so pods keep dropping out of
+	// and rejoining the endpoints; custom images need sh and od for it
+	FlakinessPercent       int32 `json:"flakinessPercent,omitempty"`
+	ReadinessPeriodSeconds int32 `json:"readinessPeriodSeconds,omitempty"` // defaults to 5
+}
+
+// EndpointFilterSpec configures the hook selecting the endpoints of a headless service
+type EndpointFilterSpec struct {
+	// URL receives the candidate pods as an HTTP POST of an EndpointFilterRequest
+	URL string `json:"url"`
+	// CABundle is the PEM encoded CA verifying the certificate of an https URL; empty uses the
+	// system roots
+	CABundle       string `json:"caBundle,omitempty"`
+	TimeoutSeconds int32  `json:"timeoutSeconds,omitempty"` // defaults to 2, at most 10
+	// FailurePolicy applies when the hook fails: FailOpen publishes every candidate and
+	// FailClosed only those already published. Defaults to FailClosed.
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+}
+
+// FederationSpec lists the member clusters whose endpoints are merged into the DNS view
+type FederationSpec struct {
+	// ClusterName identifies this cluster in the records, e.g. <cluster>.<service>...
+	ClusterName string             `json:"clusterName"`
+	Members     []FederationMember `json:"members"`
+	Domain      string             `json:"domain,omitempty"`   // defaults to clusterset.local
+	Locality    string             `json:"locality,omitempty"` // PreferCluster, None
+	TTL         int32              `json:"ttl,omitempty"`
+	// ConfigMapName receives one zone file per cluster; defaults to <name>-federation
+	ConfigMapName string `json:"configMapName,omitempty"`
+}
+
+// FederationMember is a remote cluster reached through a kubeconfig Secret in the namespace of
+// the headless service
+type FederationMember struct {
+	Name             string `json:"name"`
+	KubeconfigSecret string `json:"kubeconfigSecret"`
+	KubeconfigKey    string `json:"kubeconfigKey,omitempty"` // defaults to kubeconfig
+	// Namespace of the HeadlessService in the member; defaults to the local namespace
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// MirrorSpec references the Service a headless service mirrors
+type MirrorSpec struct {
+	// ServiceName is the Service in the same namespace whose selector and ports are mirrored
+	ServiceName string `json:"serviceName"`
+}
+
+// HelperPodsSpec configures the pods the operator runs on behalf of a headless service
+type HelperPodsSpec struct {
+	PriorityClassName string                `json:"priorityClassName,omitempty"`
+	Resources         *ResourceRequirements `json:"resources,omitempty"`
+}
+
+// LoadTestSpec runs a Job that sends requests to the headless service and measures how they
+// are spread across its endpoints
+type LoadTestSpec struct {
+	RunID           string `json:"runID"`
+	Tool            string `json:"tool,omitempty"` // builtin, fortio, wrk
+	Image           string `json:"image,omitempty"`
+	PortName        string `json:"portName,omitempty"`
+	Path            string `json:"path,omitempty"`
+	Requests        int32  `json:"requests,omitempty"`
+	Concurrency     int32  `json:"concurrency,omitempty"`
+	DurationSeconds int32  `json:"durationSeconds,omitempty"`
+	BackendHeader   string `json:"backendHeader,omitempty"`
+	ReportConfigMap string `json:"reportConfigMap,omitempty"`
+}
+
+// PeerListSpec publishes the ordered per-pod DNS names of a StatefulSet governed by the
+// headless service so bootstrap scripts can discover their initial peers
+type PeerListSpec struct {
+	StatefulSetName string `json:"statefulSetName"`
+	PortName        string `json:"portName,omitempty"`
+	ConfigMapName   string `json:"configMapName,omitempty"`
+	InjectEnv       bool   `json:"injectEnv,omitempty"`
+	EnvPrefix       string `json:"envPrefix,omitempty"`
 }
 
 // DNSSpec defines DNS configuration for headless services
 type DNSSpec struct {
-	ClusterDomain string `json:"clusterDomain,omitempty"`
-	DNSServer     string `json:"dnsServer,omitempty"`
-	TTL           int32  `json:"ttl,omitempty"`
+	ClusterDomain string          `json:"clusterDomain,omitempty"`
+	DNSServer     string          `json:"dnsServer,omitempty"`
+	TTL           int32           `json:"ttl,omitempty"`
+	Testing       *DNSTestingSpec `json:"testing,omitempty"`
+	Transport     string          `json:"transport,omitempty"` // auto, udp, tcp
+	UDPBufferSize int32           `json:"udpBufferSize,omitempty"`
+}
+
+// DNSTestingSpec controls how often DNS resolution is tested and how many consecutive results
+// it takes to change readiness
+type DNSTestingSpec struct {
+	IntervalSeconds   int32 `json:"intervalSeconds,omitempty"`
+	FailureThreshold  int32 `json:"failureThreshold,omitempty"`
+	SuccessThreshold  int32 `json:"successThreshold,omitempty"`
+	MaxBackoffSeconds int32 `json:"maxBackoffSeconds,omitempty"`
 }
 
 // ServiceDiscoverySpec defines service discovery configuration
@@ -211,9 +441,47 @@ type ServiceDiscoverySpec struct {
 
 // IptablesProxySpec defines iptables proxy configuration
 type IptablesProxySpec struct {
-	Enabled                bool   `json:"enabled"`
-	LoadBalancingAlgorithm string `json:"loadBalancingAlgorithm,omitempty"` // random, round-robin, least-connections
-	SessionAffinity        bool   `json:"sessionAffinity,omitempty"`
+	Enabled                bool              `json:"enabled"`
+	Mode                   string            `json:"mode,omitempty"`                   // iptables, ipvs; default iptables
+	Scheduler              string            `json:"scheduler,omitempty"`              // rr, wrr, lc, sh; ipvs mode only, defaults from loadBalancingAlgorithm
+	LoadBalancingAlgorithm string            `json:"loadBalancingAlgorithm,omitempty"` // random, round-robin, least-connections
+	SessionAffinity        bool              `json:"sessionAffinity,omitempty"`
+	NodeSelector           map[string]string `json:"nodeSelector,omitempty"`
+	NodeAffinity           *NodeAffinitySpec `json:"nodeAffinity,omitempty"`
+	Tolerations            []TolerationSpec  `json:"tolerations,omitempty"`
+	IncludeControlPlane    bool              `json:"includeControlPlane,omitempty"`
+	NodeGroups             []ProxyNodeGroup  `json:"nodeGroups,omitempty"`
+	DriftThresholdSeconds  int32             `json:"driftThresholdSeconds,omitempty"`
+	MaxEndpointsPerChain   int32             `json:"maxEndpointsPerChain,omitempty"` // chains split into sub-chains past this many endpoints; default 64
+	Rollout                *ProxyRolloutSpec `json:"rollout,omitempty"`
+	// AlgorithmAdvisor recommends a load balancing algorithm from the observed endpoint
+	// latencies and request spread, and optionally switches to it
+	AlgorithmAdvisor *AlgorithmAdvisorSpec `json:"algorithmAdvisor,omitempty"`
+}
+
+// AlgorithmAdvisorSpec controls the load balancing algorithm recommendation
+type AlgorithmAdvisorSpec struct {
+	// AutoSwitch applies the recommended algorithm in place of loadBalancingAlgorithm; node
+	// groups with an algorithm of their own keep it
+	AutoSwitch bool `json:"autoSwitch,omitempty"`
+	// MinRequests is how many observed requests a recommendation needs; default 1000
+	MinRequests int64 `json:"minRequests,omitempty"`
+}
+
+// ProxyRolloutSpec controls how changed iptables rules reach the nodes
+type ProxyRolloutSpec struct {
+	Strategy            string `json:"strategy,omitempty"` // all, canary
+	CanaryNode          string `json:"canaryNode,omitempty"`
+	ProbeTimeoutSeconds int32  `json:"probeTimeoutSeconds,omitempty"`
+}
+
+// ProxyNodeGroup runs a variant of the iptables proxy on a subset of nodes
+type ProxyNodeGroup struct {
+	Name                   string            `json:"name"`
+	NodeSelector           map[string]string `json:"nodeSelector"`
+	Tolerations            []TolerationSpec  `json:"tolerations,omitempty"`
+	LoadBalancingAlgorithm string            `json:"loadBalancingAlgorithm,omitempty"` // random, round-robin, least-connections
+	Backend                string            `json:"backend,omitempty"`                // legacy, nft
 }
 
 // StatefulSetSpec defines the specification for a stateful set
@@ -237,6 +505,30 @@ type StatefulSetSpec struct {
 	
 	// Pod management policy
 	PodManagementPolicy string `json:"podManagementPolicy,omitempty"`
+
+	// VolumePlacement overrides the storage class of the volume claims per zone or replica.
+	// The claims are created before the StatefulSet needs them, which then adopts them.
+	VolumePlacement *VolumePlacementSpec `json:"volumePlacement,omitempty"`
+}
+
+// VolumePlacementSpec assigns the replicas of a StatefulSet to zones round-robin by ordinal,
+// with per-replica overrides. Zonal storage classes restrict the volumes of a zone to it, and
+// the scheduler places each pod where its volumes are.
+type VolumePlacementSpec struct {
+	Zones       []ZoneStorageSpec    `json:"zones,omitempty"`
+	Replicas    []ReplicaStorageSpec `json:"replicas,omitempty"`
+	TopologyKey string               `json:"topologyKey,omitempty"` // defaults to topology.kubernetes.io/zone
+}
+
+type ZoneStorageSpec struct {
+	Zone             string `json:"zone"`
+	StorageClassName string `json:"storageClassName,omitempty"`
+}
+
+type ReplicaStorageSpec struct {
+	Ordinal          int32  `json:"ordinal"`
+	Zone             string `json:"zone,omitempty"`
+	StorageClassName string `json:"storageClassName,omitempty"`
 }
 
 // PodTemplateSpec defines the pod template
@@ -254,6 +546,9 @@ type PodSpec struct {
 	Tolerations    []TolerationSpec `json:"tolerations,omitempty"`
 	Affinity       *AffinitySpec    `json:"affinity,omitempty"`
 	SecurityContext *SecurityContextSpec `json:"securityContext,omitempty"`
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	RuntimeClassName  string `json:"runtimeClassName,omitempty"`
+	SchedulerName     string `json:"schedulerName,omitempty"` // defaults to default-scheduler
 }
 
 // ContainerSpec defines a container specification
@@ -554,13 +849,21 @@ type DeploymentSpec struct {
 	Strategy    string            `json:"strategy,omitempty"`
 }
 
+
 type ConfigMapSpec struct {
-	Name        string            `json:"name"`
-	Namespace   string            `json:"namespace,omitempty"`
-	Labels      map[string]string `json:"labels,omitempty"`
-	Annotations map[string]string `json:"annotations,omitempty"`
-	Data        map[string]string `json:"data,omitempty"`
-	BinaryData  map[string][]byte `json:"binaryData,omitempty"`
+	Name        string                            `json:"name"`
+	Namespace   string                            `json:"namespace,omitempty"`
+	Labels      map[string]string                 `json:"labels,omitempty"`
+	Annotations map[string]string                 `json:"annotations,omitempty"`
+	Data        map[string]string                 `json:"data,omitempty"`
+	BinaryData  map[string][]byte                 `json:"binaryData,omitempty"`
+	Template    bool                              `json:"template,omitempty"`
+	Validation  map[string]ConfigMapKeyValidation `json:"validation,omitempty"`
+}
+
+type ConfigMapKeyValidation struct {
+	Format string `json:"format"` // json, yaml, properties
+	Schema string `json:"schema,omitempty"`
 }
 
 type SecretSpec struct {
@@ -822,7 +1125,17 @@ type PodSecurityPolicySpec struct {
 }
 
 type RBACSpec struct {
-	Enabled bool `json:"enabled"`
+	Enabled        bool              `json:"enabled"`
+	UsernamePrefix string            `json:"usernamePrefix,omitempty"` // must match the API server --oidc-username-prefix
+	GroupsPrefix   string            `json:"groupsPrefix,omitempty"`   // must match the API server --oidc-groups-prefix
+	Bindings       []RBACBindingSpec `json:"bindings,omitempty"`
+}
+
+type RBACBindingSpec struct {
+	Role       string   `json:"role"` // viewer, editor, admin
+	Groups     []string `json:"groups,omitempty"`
+	Users      []string `json:"users,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"` // defaults to every managed namespace
 }
 
 type SecretsManagementSpec struct {
@@ -842,6 +1155,16 @@ type AutoHealingSpec struct {
 	DeadNodeReplacement bool `json:"deadNodeReplacement,omitempty"`
 	PodRestart        bool `json:"podRestart,omitempty"`
 	ResourceScaling   bool `json:"resourceScaling,omitempty"`
+
+	// DebugContainers attaches an ephemeral debug container to failing managed pods and saves
+	// what it collects in status.diagnostics.debugCaptures
+	DebugContainers *DebugContainersSpec `json:"debugContainers,omitempty"`
+}
+
+// DebugContainersSpec configures the ephemeral debug containers attached to failing pods
+type DebugContainersSpec struct {
+	Enabled bool   `json:"enabled"`
+	Image   string `json:"image,omitempty"` // needs sh, ps, netstat and nslookup; busybox by default
 }
 
 type PerformanceSpec struct {
@@ -851,6 +1174,118 @@ type PerformanceSpec struct {
 	AutoScaling       bool   `json:"autoScaling,omitempty"`
 }
 
+type LoggingSpec struct {
+	Enabled bool          `json:"enabled"`
+	Mode    string        `json:"mode,omitempty"` // sidecar, daemonset
+	Image   string        `json:"image,omitempty"`
+	Output  LogOutputSpec `json:"output"`
+}
+
+type LogOutputSpec struct {
+	Type              string `json:"type"` // loki, s3
+	Endpoint          string `json:"endpoint,omitempty"`
+	Bucket            string `json:"bucket,omitempty"`
+	Region            string `json:"region,omitempty"`
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+}
+
+type AccessSpec struct {
+	Enabled                bool   `json:"enabled"`
+	Role                   string `json:"role,omitempty"` // view, edit, admin
+	Server                 string `json:"server,omitempty"`
+	TokenExpirationSeconds int64  `json:"tokenExpirationSeconds,omitempty"`
+}
+
+type HooksSpec struct {
+	PreApply  []HookSpec `json:"preApply,omitempty"`
+	PostApply []HookSpec `json:"postApply,omitempty"`
+}
+
+type HookSpec struct {
+	Name                  string          `json:"name"`
+	Template              PodTemplateSpec `json:"template"`
+	FailurePolicy         string          `json:"failurePolicy,omitempty"` // abort, retry, ignore
+	Retries               int32           `json:"retries,omitempty"`
+	ActiveDeadlineSeconds *int64          `json:"activeDeadlineSeconds,omitempty"`
+}
+
+// SeedJobSpec is a Job that seeds the data of a StatefulSet of the cluster
+type SeedJobSpec struct {
+	Name                  string          `json:"name"`
+	StatefulSet           string          `json:"statefulSet"` // name of an entry of spec.statefulSets
+	Template              PodTemplateSpec `json:"template"`
+	RerunPolicy           string          `json:"rerunPolicy,omitempty"` // OnDataLoss (default), Never
+	BackoffLimit          *int32          `json:"backoffLimit,omitempty"`
+	ActiveDeadlineSeconds *int64          `json:"activeDeadlineSeconds,omitempty"`
+}
+
+type LabelingSpec struct {
+	PartOf         string            `json:"partOf,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	ConflictPolicy string            `json:"conflictPolicy,omitempty"` // preserve, override
+}
+
+type MaintenanceWindowSpec struct {
+	Schedule string          `json:"schedule"` // cron expression for the window start
+	Duration metav1.Duration `json:"duration"`
+	TimeZone string          `json:"timeZone,omitempty"`
+}
+
+type SoftDeleteSpec struct {
+	Retention metav1.Duration `json:"retention,omitempty"` // time in the Trash phase; defaults to 24h
+}
+
+// HealthCheckSpec probes a managed Service over HTTP or TCP, or runs a command in a helper
+// pod. Exactly one of HTTP, TCP and Command is set.
+type HealthCheckSpec struct {
+	Name             string            `json:"name"`
+	Service          string            `json:"service,omitempty"` // managed Service probed by http and tcp checks
+	Port             int32             `json:"port,omitempty"`    // defaults to the first port of the Service
+	HTTP             *HTTPCheckSpec    `json:"http,omitempty"`
+	TCP              *TCPCheckSpec     `json:"tcp,omitempty"`
+	Command          *CommandCheckSpec `json:"command,omitempty"`
+	IntervalSeconds  int32             `json:"intervalSeconds,omitempty"`
+	TimeoutSeconds   int32             `json:"timeoutSeconds,omitempty"`
+	FailureThreshold int32             `json:"failureThreshold,omitempty"`
+	SuccessThreshold int32             `json:"successThreshold,omitempty"`
+	Severity         string            `json:"severity,omitempty"` // critical, warning
+}
+
+type HTTPCheckSpec struct {
+	Path           string  `json:"path,omitempty"`
+	Scheme         string  `json:"scheme,omitempty"`         // http, https
+	ExpectedStatus []int32 `json:"expectedStatus,omitempty"` // defaults to 200
+}
+
+type TCPCheckSpec struct{}
+
+type CommandCheckSpec struct {
+	Image   string   `json:"image,omitempty"`
+	Command []string `json:"command"`
+}
+
+// PriorityClassSpec defines a PriorityClass managed for the cluster. The scheduler preempts
+// pods of lower priority to place pods of a higher one.
+type PriorityClassSpec struct {
+	Name             string `json:"name"`
+	Value            int32  `json:"value"`
+	GlobalDefault    bool   `json:"globalDefault,omitempty"`    // priority of pods that name no class
+	PreemptionPolicy string `json:"preemptionPolicy,omitempty"` // PreemptLowerPriority, Never
+	Description      string `json:"description,omitempty"`
+}
+
+// RuntimeClassSpec defines a RuntimeClass managed for the cluster, such as gVisor or Kata
+// Containers. Its scheduling constraints are merged into the pods of the class, so they only
+// land on nodes that have the handler installed.
+type RuntimeClassSpec struct {
+	Name         string            `json:"name"`
+	Handler      string            `json:"handler"`            // runtime handler on the nodes, such as runsc or kata
+	Overhead     map[string]string `json:"overhead,omitempty"` // resources a pod uses on top of its containers
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	Tolerations  []TolerationSpec  `json:"tolerations,omitempty"`
+}
+
 // Status types
 type ServiceStatus struct {
 	Name      string `json:"name"`
@@ -861,13 +1296,144 @@ type ServiceStatus struct {
 }
 
 type HeadlessServiceStatus struct {
-	Name      string   `json:"name"`
-	Namespace string   `json:"namespace,omitempty"`
-	Phase     string   `json:"phase,omitempty"`
-	Ready     bool     `json:"ready,omitempty"`
-	Endpoints []string `json:"endpoints,omitempty"`
-	DNS       *DNSTestResult `json:"dns,omitempty"`
-	Message   string   `json:"message,omitempty"`
+	Name              string                   `json:"name"`
+	Namespace         string                   `json:"namespace,omitempty"`
+	Phase             string                   `json:"phase,omitempty"`
+	Ready             bool                     `json:"ready,omitempty"`
+	Endpoints         []string                 `json:"endpoints,omitempty"`
+	MatchedPods       int32                    `json:"matchedPods,omitempty"`
+	DNS               *DNSTestResult           `json:"dns,omitempty"`
+	Message           string                   `json:"message,omitempty"`
+	Conformance       *ConformanceReport       `json:"conformance,omitempty"`
+	RulesDrift        *RulesDriftStatus        `json:"rulesDrift,omitempty"`
+	Canaries          []RulesCanaryStatus      `json:"canaries,omitempty"`
+	PeerList          *PeerListStatus          `json:"peerList,omitempty"`
+	LoadTest          *LoadTestStatus          `json:"loadTest,omitempty"`
+	Mirror            *MirrorStatus            `json:"mirror,omitempty"`
+	Federation        *FederationStatus        `json:"federation,omitempty"`
+	ExternalEndpoints []ExternalEndpointStatus `json:"externalEndpoints,omitempty"`
+	EndpointFilter    *EndpointFilterStatus    `json:"endpointFilter,omitempty"`
+	DemoWorkload      *DemoWorkloadStatus      `json:"demoWorkload,omitempty"`
+	// AlgorithmRecommendation is the load balancing algorithm the observed traffic calls for
+	AlgorithmRecommendation *AlgorithmRecommendationStatus `json:"algorithmRecommendation,omitempty"`
+	Conditions        []metav1.Condition       `json:"conditions,omitempty"`
+}
+
+// ExternalEndpointStatus reports the addresses published for an external endpoint
+type ExternalEndpointStatus struct {
+	Name       string       `json:"name"`
+	Addresses  []string     `json:"addresses,omitempty"`
+	Error      string       `json:"error,omitempty"` // last resolution error; the previous addresses are kept
+	ResolvedAt *metav1.Time `json:"resolvedAt,omitempty"`
+}
+
+// AlgorithmRecommendationStatus reports the load balancing algorithm recommended for the
+// observed traffic and why
+type AlgorithmRecommendationStatus struct {
+	Current     string `json:"current"`
+	Recommended string `json:"recommended,omitempty"`
+	// Applied is true while autoSwitch runs the recommended algorithm
+	Applied   bool   `json:"applied,omitempty"`
+	Rationale string `json:"rationale"`
+	// Source names the observations, e.g. loadTest/<runID>
+	Source   string `json:"source,omitempty"`
+	Requests int64  `json:"requests,omitempty"`
+	// LatencySpreadPercent is the coefficient of variation of the median endpoint latencies
+	LatencySpreadPercent int32 `json:"latencySpreadPercent,omitempty"`
+	// TailRatioPercent is the mean ratio of p99 to p50 latency of the endpoints
+	TailRatioPercent int32 `json:"tailRatioPercent,omitempty"`
+	// SkewPercent is how far the busiest and idlest endpoint are apart, relative to the mean
+	SkewPercent int32       `json:"skewPercent,omitempty"`
+	EvaluatedAt metav1.Time `json:"evaluatedAt"`
+}
+
+// DemoWorkloadStatus reports the Deployment running the demo pods
+type DemoWorkloadStatus struct {
+	Deployment    string `json:"deployment"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+}
+
+// EndpointFilterStatus reports the last call of the endpoint filter hook
+type EndpointFilterStatus struct {
+	Candidates int32       `json:"candidates"`
+	Admitted   int32       `json:"admitted"`
+	Error      string      `json:"error,omitempty"` // the failure policy applied when set
+	FilteredAt metav1.Time `json:"filteredAt,omitempty"`
+}
+
+// FederationStatus reports the last merge of member endpoints into the DNS view
+type FederationStatus struct {
+	ConfigMapName string                   `json:"configMapName"`
+	Endpoints     int32                    `json:"endpoints"`
+	Clusters      []FederationMemberStatus `json:"clusters,omitempty"`
+	SyncedAt      metav1.Time              `json:"syncedAt,omitempty"`
+}
+
+// FederationMemberStatus reports the endpoints contributed by one cluster
+type FederationMemberStatus struct {
+	Name      string `json:"name"`
+	Reachable bool   `json:"reachable"`
+	Endpoints int32  `json:"endpoints"`
+	Message   string `json:"message,omitempty"`
+}
+
+// MirrorStatus reports the last sync from the mirrored Service
+type MirrorStatus struct {
+	ServiceName string `json:"serviceName"`
+	// ResourceVersion of the mirrored Service when it was last copied
+	ResourceVersion string      `json:"resourceVersion,omitempty"`
+	SyncedAt        metav1.Time `json:"syncedAt,omitempty"`
+}
+
+// LoadTestStatus reports the latest load test run
+type LoadTestStatus struct {
+	RunID              string        `json:"runID"`
+	JobName            string        `json:"jobName,omitempty"`
+	Phase              string        `json:"phase,omitempty"` // Running, Succeeded, Failed
+	Message            string        `json:"message,omitempty"`
+	StartedAt          *metav1.Time  `json:"startedAt,omitempty"`
+	CompletedAt        *metav1.Time  `json:"completedAt,omitempty"`
+	Requests           int64         `json:"requests,omitempty"`
+	Errors             int64         `json:"errors,omitempty"`
+	SkewPercent        int32         `json:"skewPercent,omitempty"`
+	AffinityViolations int64         `json:"affinityViolations,omitempty"`
+	Backends           []BackendLoad `json:"backends,omitempty"`
+	ReportConfigMap    string        `json:"reportConfigMap,omitempty"`
+	// ReportURI is where the full result is stored when the operator has a report store
+	ReportURI string `json:"reportURI,omitempty"`
+}
+
+// BackendLoad is the share of load test requests one endpoint served
+type BackendLoad struct {
+	Backend          string `json:"backend"`
+	Requests         int64  `json:"requests"`
+	LatencyP50Micros int64  `json:"latencyP50Micros,omitempty"`
+	LatencyP99Micros int64  `json:"latencyP99Micros,omitempty"`
+}
+
+// PeerListStatus reports the peer list published for a StatefulSet
+type PeerListStatus struct {
+	ConfigMapName string   `json:"configMapName"`
+	Replicas      int32    `json:"replicas"`
+	Peers         []string `json:"peers,omitempty"`
+}
+
+// RulesCanaryStatus reports the verification of new iptables rules of a node group on its
+// canary node
+type RulesCanaryStatus struct {
+	NodeGroup string       `json:"nodeGroup,omitempty"`
+	Node      string       `json:"node"`
+	RulesHash string       `json:"rulesHash"`
+	Phase     string       `json:"phase"` // Probing, Failed
+	Output    string       `json:"output,omitempty"`
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+}
+
+// RulesDriftStatus reports nodes whose applied iptables rules differ from the desired rules
+type RulesDriftStatus struct {
+	DivergentNodes []string     `json:"divergentNodes,omitempty"`
+	DivergingSince *metav1.Time `json:"divergingSince,omitempty"`
 }
 
 type StatefulSetStatus struct {
@@ -879,12 +1445,172 @@ type StatefulSetStatus struct {
 	Message   string `json:"message,omitempty"`
 }
 
+type VolumePlacementStatus struct {
+	StatefulSet      string `json:"statefulSet"`
+	Claim            string `json:"claim"`
+	Ordinal          int32  `json:"ordinal"`
+	Zone             string `json:"zone,omitempty"`
+	StorageClassName string `json:"storageClassName,omitempty"`
+	Phase            string `json:"phase,omitempty"` // Pending, Bound, Lost, Conflict
+	Message          string `json:"message,omitempty"`
+}
+
+// StatefulSetIdentityStatus is the identity of one StatefulSet ordinal. Node is the node the
+// pod runs on, or last ran on while it does not exist.
+type StatefulSetIdentityStatus struct {
+	StatefulSet  string                `json:"statefulSet"`
+	Namespace    string                `json:"namespace,omitempty"`
+	Ordinal      int32                 `json:"ordinal"`
+	Pod          string                `json:"pod"`
+	PodPhase     string                `json:"podPhase,omitempty"` // empty while the pod does not exist
+	Node         string                `json:"node,omitempty"`
+	Zone         string                `json:"zone,omitempty"`
+	PreviousNode string                `json:"previousNode,omitempty"` // node before the last move
+	MovedAt      *metav1.Time          `json:"movedAt,omitempty"`
+	Claims       []IdentityClaimStatus `json:"claims,omitempty"`
+	Violations   []string              `json:"violations,omitempty"`
+}
+
+type IdentityClaimStatus struct {
+	Name   string `json:"name"`
+	Phase  string `json:"phase,omitempty"` // Pending, Bound, Lost; empty while the claim does not exist
+	Volume string `json:"volume,omitempty"`
+	Zone   string `json:"zone,omitempty"` // zone the bound volume is restricted to
+}
+
+type HookStatus struct {
+	Name        string       `json:"name"`
+	Stage       string       `json:"stage"` // preApply, postApply
+	Revision    int64        `json:"revision"`
+	State       string       `json:"state"` // Running, Succeeded, Failed, Ignored
+	Attempts    int32        `json:"attempts,omitempty"`
+	JobName     string       `json:"jobName,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	StartedAt   *metav1.Time `json:"startedAt,omitempty"`
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+// SeedJobStatus is the seeding state of a StatefulSet. DataID fingerprints the claims, or the
+// pods without claims, the last run seeded; a new fingerprint means the data was recreated.
+type SeedJobStatus struct {
+	Name        string       `json:"name"`
+	StatefulSet string       `json:"statefulSet"`
+	State       string       `json:"state"` // Waiting, Running, Succeeded, Failed
+	Runs        int32        `json:"runs,omitempty"`
+	JobName     string       `json:"jobName,omitempty"`
+	Revision    int64        `json:"revision,omitempty"` // cluster generation of the last run
+	DataID      string       `json:"dataID,omitempty"`
+	Message     string       `json:"message,omitempty"`
+	StartedAt   *metav1.Time `json:"startedAt,omitempty"`
+	CompletedAt *metav1.Time `json:"completedAt,omitempty"`
+}
+
+type MaintenanceStatus struct {
+	WindowOpen       bool              `json:"windowOpen"`
+	NextWindow       *metav1.Time      `json:"nextWindow,omitempty"`
+	AppliedRevisions map[string]string `json:"appliedRevisions,omitempty"`
+	Deferred         []DeferredChange  `json:"deferred,omitempty"`
+}
+
+type TrashStatus struct {
+	DeletedAt  metav1.Time `json:"deletedAt"`
+	PurgeAt    metav1.Time `json:"purgeAt"`
+	ScaledDown []string    `json:"scaledDown,omitempty"` // Kind/namespace/name of the workloads stopped
+}
+
+type DeferredChange struct {
+	Kind    string       `json:"kind"` // Version, StatefulSet, ResourceOptimization
+	Name    string       `json:"name,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Since   *metav1.Time `json:"since,omitempty"`
+}
+
+type HealthCheckStatus struct {
+	Name                 string       `json:"name"`
+	Healthy              bool         `json:"healthy"`
+	Success              bool         `json:"success,omitempty"` // outcome of the last probe
+	Message              string       `json:"message,omitempty"`
+	ConsecutiveFailures  int32        `json:"consecutiveFailures,omitempty"`
+	ConsecutiveSuccesses int32        `json:"consecutiveSuccesses,omitempty"`
+	LastCheckedAt        *metav1.Time `json:"lastCheckedAt,omitempty"`
+	NextCheckAt          *metav1.Time `json:"nextCheckAt,omitempty"`
+}
+
+type DiagnosticsStatus struct {
+	CollectedAt metav1.Time      `json:"collectedAt"`
+	Pods        []PodDiagnostics `json:"pods,omitempty"`        // pods with problems first
+	OmittedPods int32            `json:"omittedPods,omitempty"` // healthy pods left out to bound the status size
+
+	// DebugCaptures holds what debug containers collected in failing pods, most recent first.
+	// They are kept after the pod restarts or is replaced.
+	DebugCaptures []DebugCapture `json:"debugCaptures,omitempty"`
+}
+
+type DebugCapture struct {
+	Namespace   string      `json:"namespace"`
+	Pod         string      `json:"pod"`
+	Container   string      `json:"container"` // the ephemeral debug container
+	Target      string      `json:"target"`    // the failing container whose processes it sees
+	Image       string      `json:"image,omitempty"`
+	CompletedAt metav1.Time `json:"completedAt"`
+	Processes   string      `json:"processes,omitempty"`   // ps
+	Connections string      `json:"connections,omitempty"` // netstat
+	DNS         string      `json:"dns,omitempty"`         // resolv.conf and nslookup
+	Error       string      `json:"error,omitempty"`
+}
+
+type PodDiagnostics struct {
+	Namespace  string                 `json:"namespace"`
+	Name       string                 `json:"name"`
+	Phase      string                 `json:"phase,omitempty"`
+	Reason     string                 `json:"reason,omitempty"` // e.g. Evicted
+	Message    string                 `json:"message,omitempty"`
+	Containers []ContainerDiagnostics `json:"containers,omitempty"`
+	Events     []WarningEvent         `json:"events,omitempty"` // most recent first
+}
+
+type ContainerDiagnostics struct {
+	Name            string             `json:"name"`
+	Init            bool               `json:"init,omitempty"`
+	Ready           bool               `json:"ready"`
+	RestartCount    int32              `json:"restartCount,omitempty"`
+	State           string             `json:"state,omitempty"`  // Running, Waiting, Terminated
+	Reason          string             `json:"reason,omitempty"` // e.g. CrashLoopBackOff, ImagePullBackOff
+	LastTermination *TerminationStatus `json:"lastTermination,omitempty"`
+	Analysis        string             `json:"analysis,omitempty"`
+}
+
+type TerminationStatus struct {
+	Reason     string      `json:"reason,omitempty"` // e.g. OOMKilled, Error, Completed
+	ExitCode   int32       `json:"exitCode"`
+	Signal     int32       `json:"signal,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	FinishedAt metav1.Time `json:"finishedAt,omitempty"`
+}
+
+type WarningEvent struct {
+	Reason   string      `json:"reason"`
+	Message  string      `json:"message,omitempty"`
+	Count    int32       `json:"count,omitempty"`
+	LastSeen metav1.Time `json:"lastSeen,omitempty"`
+}
+
 type DNSTestResult struct {
-	ServiceDNS        string            `json:"serviceDNS,omitempty"`
-	ResolvedIPs       []string          `json:"resolvedIPs,omitempty"`
-	IndividualPodDNS  []PodDNSRecord    `json:"individualPodDNS,omitempty"`
-	Success           bool              `json:"success,omitempty"`
-	ErrorMessage      string            `json:"errorMessage,omitempty"`
+	ServiceDNS           string         `json:"serviceDNS,omitempty"`
+	ResolvedIPs          []string       `json:"resolvedIPs,omitempty"`
+	IndividualPodDNS     []PodDNSRecord `json:"individualPodDNS,omitempty"`
+	Success              bool           `json:"success,omitempty"`
+	ErrorMessage         string         `json:"errorMessage,omitempty"`
+	ServerError          bool           `json:"serverError,omitempty"` // the DNS server failed rather than the tested name
+	Healthy              bool           `json:"healthy,omitempty"`
+	ConsecutiveFailures  int32          `json:"consecutiveFailures,omitempty"`
+	ConsecutiveSuccesses int32          `json:"consecutiveSuccesses,omitempty"`
+	LastTestedAt         *metav1.Time   `json:"lastTestedAt,omitempty"`
+	NextTestAt           *metav1.Time   `json:"nextTestAt,omitempty"`
+	Transport            string         `json:"transport,omitempty"`
+	Truncated            bool           `json:"truncated,omitempty"`
+	ResponseBytes        int32          `json:"responseBytes,omitempty"`
+	TruncationEvents     int32          `json:"truncationEvents,omitempty"`
 }
 
 type PodDNSRecord struct {
@@ -893,12 +1619,33 @@ type PodDNSRecord struct {
 	DNSName   string `json:"dnsName,omitempty"`
 }
 
+// ConformanceReport compares native headless Service behavior with a ClusterIP Service
+type ConformanceReport struct {
+	Headless     ServiceBehavior `json:"headless"`
+	ClusterIP    ServiceBehavior `json:"clusterIP"`
+	PodRecords   []PodDNSRecord  `json:"podRecords,omitempty"`
+	Conformant   bool            `json:"conformant"`
+	Explanations []string        `json:"explanations,omitempty"`
+	GeneratedAt  metav1.Time     `json:"generatedAt,omitempty"`
+}
+
+// ServiceBehavior describes how a Service is exposed through DNS
+type ServiceBehavior struct {
+	ServiceName    string   `json:"serviceName"`
+	DNSName        string   `json:"dnsName"`
+	ClusterIP      string   `json:"clusterIP,omitempty"`
+	DNSAnswers     []string `json:"dnsAnswers,omitempty"`
+	LoadBalancedBy string   `json:"loadBalancedBy,omitempty"` // client, kube-proxy
+	Error          string   `json:"error,omitempty"`
+}
+
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
-//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:resource:scope=Namespaced,shortName=kpc,categories=playgrounds
 //+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 //+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas"
 //+kubebuilder:printcolumn:name="Total",type="integer",JSONPath=".status.totalReplicas"
+//+kubebuilder:printcolumn:name="Queue",type="integer",JSONPath=".status.queuePosition",priority=1
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // K8sPlaygroundsCluster is the Schema for the k8splaygroundsclusters API
@@ -921,7 +1668,7 @@ type K8sPlaygroundsClusterList struct {
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
-//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:resource:scope=Namespaced,shortName=hsvc,categories=playgrounds
 //+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 //+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready"
 //+kubebuilder:printcolumn:name="Endpoints",type="integer",JSONPath=".status.endpoints"
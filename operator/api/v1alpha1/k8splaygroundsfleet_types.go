@@ -0,0 +1,98 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// K8sPlaygroundsFleetSpec defines the desired state of K8sPlaygroundsFleet
+type K8sPlaygroundsFleetSpec struct {
+	// Selector selects the K8sPlaygroundsClusters summarized by the fleet; an empty selector
+	// selects every cluster
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Namespaces limits the fleet to clusters in these namespaces; empty means all namespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// MaxFailingComponents caps how many failing components are listed in the status
+	// +kubebuilder:default=20
+	// +kubebuilder:validation:Minimum=0
+	MaxFailingComponents int32 `json:"maxFailingComponents,omitempty"`
+}
+
+// K8sPlaygroundsFleetStatus defines the observed state of K8sPlaygroundsFleet
+type K8sPlaygroundsFleetStatus struct {
+	// ObservedGeneration is the fleet generation the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Clusters is the number of clusters in the fleet
+	Clusters int32 `json:"clusters"`
+
+	// Converged is the number of clusters that are Running and Healthy
+	Converged int32 `json:"converged"`
+
+	// Phases counts the clusters in each phase
+	Phases map[string]int32 `json:"phases,omitempty"`
+
+	// Health counts the clusters in each health state
+	Health map[string]int32 `json:"health,omitempty"`
+
+	// Converging lists the clusters that have not converged, slowest first
+	Converging []FleetClusterProgress `json:"converging,omitempty"`
+
+	// FailingComponentCount is the number of failing components across the fleet
+	FailingComponentCount int32 `json:"failingComponentCount,omitempty"`
+
+	// FailingComponents lists failing components, up to spec.maxFailingComponents
+	FailingComponents []FleetComponentFailure `json:"failingComponents,omitempty"`
+
+	// LastUpdated is when the status was last computed
+	LastUpdated metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// FleetClusterProgress reports a cluster that has not converged yet
+type FleetClusterProgress struct {
+	Namespace string       `json:"namespace"`
+	Name      string       `json:"name"`
+	Phase     string       `json:"phase,omitempty"`
+	Health    string       `json:"health,omitempty"`
+	Since     *metav1.Time `json:"since,omitempty"`
+}
+
+// FleetComponentFailure reports a failing component of a cluster in the fleet
+type FleetComponentFailure struct {
+	Namespace string `json:"namespace"`
+	Cluster   string `json:"cluster"`
+	Kind      string `json:"kind"` // Service, HeadlessService, StatefulSet, Hook
+	Name      string `json:"name"`
+	Message   string `json:"message,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster,shortName=kpf,categories=playgrounds
+//+kubebuilder:printcolumn:name="Clusters",type="integer",JSONPath=".status.clusters"
+//+kubebuilder:printcolumn:name="Converged",type="integer",JSONPath=".status.converged"
+//+kubebuilder:printcolumn:name="Failing",type="integer",JSONPath=".status.failingComponentCount"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// K8sPlaygroundsFleet is the Schema for the k8splaygroundsfleets API
+type K8sPlaygroundsFleet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   K8sPlaygroundsFleetSpec   `json:"spec,omitempty"`
+	Status K8sPlaygroundsFleetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// K8sPlaygroundsFleetList contains a list of K8sPlaygroundsFleet
+type K8sPlaygroundsFleetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []K8sPlaygroundsFleet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&K8sPlaygroundsFleet{}, &K8sPlaygroundsFleetList{})
+}
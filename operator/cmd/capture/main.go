@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/capture"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(k8splaygroundsv1alpha1.AddToScheme(scheme))
+}
+
+// capture turns a hand-built demo namespace into a reusable K8sPlaygroundsCluster manifest
+func main() {
+	var namespace string
+	var name string
+
+	flag.StringVar(&namespace, "namespace", "", "Namespace to capture (required)")
+	flag.StringVar(&name, "name", "", "Name of the generated K8sPlaygroundsCluster (default: the namespace name)")
+	flag.Parse()
+
+	if namespace == "" {
+		fmt.Fprintln(os.Stderr, "--namespace is required")
+		os.Exit(1)
+	}
+	if name == "" {
+		name = namespace
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %v\n", err)
+		os.Exit(1)
+	}
+
+	spec, err := capture.NewCapturer(c).Capture(context.Background(), namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to capture namespace: %v\n", err)
+		os.Exit(1)
+	}
+
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	cluster.APIVersion = "k8s-playgrounds.io/v1alpha1"
+	cluster.Kind = "K8sPlaygroundsCluster"
+	cluster.Name = name
+	cluster.Spec = *spec
+
+	out, err := yaml.Marshal(cluster)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to marshal cluster: %v\n", err)
+		os.Exit(1)
+	}
+	os.Stdout.Write(out)
+}
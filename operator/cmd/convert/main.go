@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/convert"
+)
+
+// convert turns existing Kubernetes manifests into a K8sPlaygroundsCluster manifest, to ease
+// moving hand-written demos under the operator. Manifests and fields that have no equivalent
+// in the cluster spec are listed on stderr for manual review.
+func main() {
+	var name string
+	var namespace string
+	var version string
+	var output string
+	var strict bool
+
+	flag.StringVar(&name, "name", "", "Name of the generated K8sPlaygroundsCluster (required)")
+	flag.StringVar(&namespace, "namespace", "", "Namespace of the generated K8sPlaygroundsCluster")
+	flag.StringVar(&version, "version", "latest", "Kubernetes version of the generated K8sPlaygroundsCluster")
+	flag.StringVar(&output, "output", "", "File to write to (default: stdout)")
+	flag.BoolVar(&strict, "strict", false, "Exit with an error when anything could not be converted")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s --name NAME [flags] [FILE ...]\n\nReads manifests from the files, or stdin when none or - is given.\n\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if name == "" {
+		fmt.Fprintln(os.Stderr, "--name is required")
+		os.Exit(1)
+	}
+
+	var input bytes.Buffer
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, file := range files {
+		if err := read(&input, file); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read manifests: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	result, err := convert.Convert(&input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to convert manifests: %v\n", err)
+		os.Exit(1)
+	}
+	for _, unsupported := range result.Unsupported {
+		fmt.Fprintf(os.Stderr, "unsupported %s\n", unsupported)
+	}
+	if strict && len(result.Unsupported) > 0 {
+		fmt.Fprintf(os.Stderr, "%d manifests or fields could not be converted\n", len(result.Unsupported))
+		os.Exit(1)
+	}
+
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	cluster.APIVersion = "k8s-playgrounds.io/v1alpha1"
+	cluster.Kind = "K8sPlaygroundsCluster"
+	cluster.Name = name
+	cluster.Namespace = namespace
+	cluster.Spec = result.Spec
+	cluster.Spec.Version = version
+
+	out, err := yaml.Marshal(cluster)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to marshal cluster: %v\n", err)
+		os.Exit(1)
+	}
+	if output == "" {
+		os.Stdout.Write(out)
+		return
+	}
+	if err := os.WriteFile(output, out, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write output: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// read appends the manifests of a file, or stdin for -, to input as a separate YAML document
+func read(input *bytes.Buffer, file string) error {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	input.WriteString("\n---\n")
+	input.Write(data)
+	return nil
+}
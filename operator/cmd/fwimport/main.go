@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/fwimport"
+)
+
+// fwimport reads the rules of an existing AWS security group or Azure NSG
+// through the Aviatrix Controller and prints equivalent firewall rules or a
+// proposed AviatrixFirewall, to ease moving native cloud firewalls to
+// declarative Aviatrix policy. Rules that cannot be translated exactly are
+// listed on stderr for manual review.
+func main() {
+	var controllerIP string
+	var username string
+	var password string
+	var cloudType string
+	var accountName string
+	var region string
+	var groupID string
+	var localCIDR string
+	var logEnabled bool
+	var format string
+	var name string
+	var namespace string
+	var gwName string
+	var output string
+
+	flag.StringVar(&controllerIP, "aviatrix-controller-ip", "", "Aviatrix Controller IP address")
+	flag.StringVar(&username, "aviatrix-username", "", "Aviatrix Controller username")
+	flag.StringVar(&password, "aviatrix-password", os.Getenv("AVIATRIX_PASSWORD"), "Aviatrix Controller password (default: $AVIATRIX_PASSWORD)")
+	flag.StringVar(&cloudType, "cloud-type", fwimport.CloudAWS, "Cloud of the security group: aws or azure")
+	flag.StringVar(&accountName, "account-name", "", "Aviatrix access account that owns the security group (required)")
+	flag.StringVar(&region, "region", "", "Region of the security group (required)")
+	flag.StringVar(&groupID, "group-id", "", "AWS security group ID or Azure NSG resource ID (required)")
+	flag.StringVar(&localCIDR, "local-cidr", "", "Address range protected by the group (default: 0.0.0.0/0)")
+	flag.BoolVar(&logEnabled, "log", false, "Enable logging on the generated rules")
+	flag.StringVar(&format, "format", fwimport.FormatFirewall, "Output format: firewall (AviatrixFirewall manifest) or rules (list of rules)")
+	flag.StringVar(&name, "name", "", "Name of the proposed AviatrixFirewall (default: the group ID)")
+	flag.StringVar(&namespace, "namespace", "", "Namespace of the proposed AviatrixFirewall")
+	flag.StringVar(&gwName, "gw-name", "", "Gateway of the proposed AviatrixFirewall")
+	flag.StringVar(&output, "output", "", "File to write to (default: stdout)")
+	flag.Parse()
+
+	if accountName == "" || region == "" || groupID == "" {
+		fmt.Fprintln(os.Stderr, "--account-name, --region and --group-id are required")
+		os.Exit(1)
+	}
+	if name == "" {
+		name = groupID
+	}
+
+	// Interrupting the import abandons requests still waiting on the Controller
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	client, err := aviatrix.NewClient(ctx, controllerIP, username, password, aviatrix.PoolConfig{})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create Aviatrix client: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Logout(context.Background())
+
+	result, err := fwimport.NewImporter(client).Import(ctx, accountName, region, groupID, fwimport.Options{
+		CloudType:  cloudType,
+		LocalCIDR:  localCIDR,
+		LogEnabled: logEnabled,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to import rules: %v\n", err)
+		os.Exit(1)
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s\n", skipped)
+	}
+
+	out := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to create output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := fwimport.Write(out, result, format, name, namespace, gwName); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write output: %v\n", err)
+		os.Exit(1)
+	}
+}
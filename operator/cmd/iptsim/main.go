@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/iptables"
+)
+
+// iptsim renders the rules the proxy would apply for a HeadlessService manifest and a list of
+// endpoints, in iptables-save format or as ipvsadm commands in ipvs mode, without cluster
+// access, so generated rules can be reviewed before they reach any node
+func main() {
+	var servicePath string
+	var endpoints string
+	var output string
+
+	flag.StringVar(&servicePath, "service", "", "Path of the HeadlessService manifest (required)")
+	flag.StringVar(&endpoints, "endpoints", "", "Comma separated endpoint IPs to balance across (required)")
+	flag.StringVar(&output, "output", "", "File to write to (default: stdout)")
+	flag.Parse()
+
+	if servicePath == "" || endpoints == "" {
+		fmt.Fprintln(os.Stderr, "--service and --endpoints are required")
+		os.Exit(1)
+	}
+
+	data, err := os.ReadFile(servicePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to read service: %v\n", err)
+		os.Exit(1)
+	}
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+	if err := yaml.UnmarshalStrict(data, headlessService); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to parse service: %v\n", err)
+		os.Exit(1)
+	}
+	if headlessService.Namespace == "" {
+		headlessService.Namespace = "default"
+	}
+
+	var endpointIPs []string
+	for _, ip := range strings.Split(endpoints, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			endpointIPs = append(endpointIPs, ip)
+		}
+	}
+
+	rules, err := iptables.Render(headlessService, endpointIPs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to render rules: %v\n", err)
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to create output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	if _, err := fmt.Fprint(out, rules); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write output: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apis/conditions"
+	"github.com/k8s-playgrounds/operator/pkg/diagnostics"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(k8splaygroundsv1alpha1.AddToScheme(scheme))
+}
+
+const usage = `Usage:
+  kubectl playgrounds diagnose CLUSTER [-n NAMESPACE] [--live]
+  kubectl playgrounds debug POD [-n NAMESPACE] [-c CONTAINER] [--image IMAGE] [--timeout DURATION]
+
+diagnose shows why the pods of a playground cluster restart or fail: container states, last
+terminations with their exit codes, restart counts, recent Warning events and what debug
+containers captured.
+
+debug attaches an ephemeral debug container to a pod that shares the process namespace of
+CONTAINER, the first container by default, and prints the processes, connections and DNS
+resolution it captured.
+`
+
+// kubectl-playgrounds is a kubectl plugin for inspecting playground clusters. Installed on the
+// PATH it runs as kubectl playgrounds.
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "diagnose" && os.Args[1] != "debug") {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+	command := os.Args[1]
+
+	var namespace, container, image string
+	var live bool
+	var timeout time.Duration
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flags.StringVar(&namespace, "namespace", "", "Namespace of the cluster or pod (default: the namespace of the current context)")
+	flags.StringVar(&namespace, "n", "", "Shorthand for --namespace")
+	if command == "diagnose" {
+		flags.BoolVar(&live, "live", false, "Collect diagnostics now instead of showing those in the cluster status")
+	} else {
+		flags.StringVar(&container, "container", "", "Container whose processes to inspect (default: the first container)")
+		flags.StringVar(&container, "c", "", "Shorthand for --container")
+		flags.StringVar(&image, "image", diagnostics.DefaultDebugImage, "Image of the debug container; needs sh, ps, netstat and nslookup")
+		flags.DurationVar(&timeout, "timeout", 2*time.Minute, "How long to wait for the debug container to finish")
+	}
+	// Accept flags before and after the name, like kubectl does
+	args := os.Args[2:]
+	var name string
+	for len(args) > 0 {
+		if err := flags.Parse(args); err != nil {
+			os.Exit(1)
+		}
+		args = flags.Args()
+		if len(args) > 0 {
+			if name != "" {
+				fmt.Fprint(os.Stderr, usage)
+				os.Exit(1)
+			}
+			name, args = args[0], args[1:]
+		}
+	}
+	if name == "" {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(1)
+	}
+
+	kubeconfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+	if namespace == "" {
+		var err error
+		if namespace, _, err = kubeconfig.Namespace(); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to read namespace: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	config, err := kubeconfig.ClientConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load kubeconfig: %v\n", err)
+		os.Exit(1)
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	key := types.NamespacedName{Name: name, Namespace: namespace}
+	if command == "debug" {
+		if err := debug(ctx, c, key, container, image, timeout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	if err := c.Get(ctx, key, cluster); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to get cluster: %v\n", err)
+		os.Exit(1)
+	}
+
+	now := time.Now()
+	status := cluster.Status.Diagnostics
+	if live {
+		if status, err = diagnostics.NewCollector(c, c).Collect(ctx, cluster, now); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to collect diagnostics: %v\n", err)
+			os.Exit(1)
+		}
+		if cluster.Status.Diagnostics != nil {
+			status.DebugCaptures = cluster.Status.Diagnostics.DebugCaptures
+		}
+	}
+
+	fmt.Printf("Cluster %s/%s is %s (health %s)\n", cluster.Namespace, cluster.Name, cluster.Status.Phase, cluster.Status.Health)
+	printConditions(cluster.Status.Conditions)
+	if err := diagnostics.Print(os.Stdout, status, now); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to print diagnostics: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printConditions lists the cluster conditions, explaining the reasons automation can match on
+func printConditions(clusterConditions []k8splaygroundsv1alpha1.ClusterCondition) {
+	for _, condition := range clusterConditions {
+		line := fmt.Sprintf("  %s=%s %s", condition.Type, condition.Status, condition.Reason)
+		if description := conditions.Describe(condition.Reason); description != "" {
+			line += " (" + description + ")"
+		}
+		if condition.Message != "" {
+			line += ": " + condition.Message
+		}
+		fmt.Println(line)
+	}
+}
+
+// debug attaches a debug container to a pod, waits for it to finish and prints its capture
+func debug(ctx context.Context, c client.Client, key types.NamespacedName, container, image string, timeout time.Duration) error {
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, key, pod); err != nil {
+		return fmt.Errorf("unable to get pod: %w", err)
+	}
+	if container == "" {
+		if len(pod.Spec.Containers) == 0 {
+			return fmt.Errorf("pod %s has no containers", key)
+		}
+		container = pod.Spec.Containers[0].Name
+	}
+	debugContainer, err := diagnostics.Attach(ctx, c, pod, container, image)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Attached debug container %s to %s, waiting for it to finish...\n", debugContainer, key)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := c.Get(ctx, key, pod); err != nil {
+			return fmt.Errorf("unable to get pod: %w", err)
+		}
+		now := time.Now()
+		if capture, done := diagnostics.Capture(pod, debugContainer, now); done {
+			return diagnostics.PrintCapture(os.Stdout, capture, now)
+		}
+		if now.After(deadline) {
+			return fmt.Errorf("debug container %s did not finish within %s", debugContainer, timeout)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"time"
+
+	"github.com/k8s-playgrounds/operator/pkg/loadtest"
+)
+
+// loadtest sends HTTP requests to a headless service and reports how they were spread across
+// its endpoints. Each request uses a new connection so that every request is balanced again.
+// The serving backend is read from a response header and falls back to the address the
+// connection was made to, which identifies the endpoint when clients connect to pod IPs
+// directly. The summary is written to the termination log for the operator to collect.
+func main() {
+	var (
+		url            string
+		backendHeader  string
+		terminationLog string
+		affinity       bool
+		timeout        time.Duration
+		config         loadtest.Config
+	)
+
+	flag.StringVar(&url, "url", "", "URL to send requests to")
+	flag.IntVar(&config.Requests, "requests", loadtest.DefaultRequests, "Total number of requests; 0 sends requests until the duration elapses")
+	flag.IntVar(&config.Concurrency, "concurrency", loadtest.DefaultConcurrency, "Number of concurrent clients")
+	flag.DurationVar(&config.Duration, "duration", 0, "Stop after this long; 0 only stops after the requests")
+	flag.StringVar(&backendHeader, "backend-header", loadtest.DefaultBackendHeader, "Response header identifying the backend that served the request")
+	flag.BoolVar(&affinity, "affinity", false, "Count requests not served by the most used backend as affinity violations")
+	flag.DurationVar(&timeout, "timeout", 5*time.Second, "Timeout of a single request")
+	flag.StringVar(&terminationLog, "termination-log", "/dev/termination-log", "File the result is written to")
+	flag.Parse()
+
+	if url == "" {
+		fmt.Fprintln(os.Stderr, "--url is required")
+		os.Exit(1)
+	}
+
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	do := func(ctx context.Context) (string, error) {
+		var remote string
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				remote = info.Conn.RemoteAddr().String()
+			},
+		}
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, url, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+			return "", err
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return "", fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		if backend := resp.Header.Get(backendHeader); backend != "" {
+			return backend, nil
+		}
+		return remote, nil
+	}
+
+	result := loadtest.Summarize(loadtest.Run(context.Background(), config, do), affinity)
+	data, err := loadtest.Encode(result, loadtest.MaxResultSize)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to encode result: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(loadtest.Report(result))
+
+	if err := os.WriteFile(terminationLog, data, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write result: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,27 +1,58 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"net/http"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g., Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	uberzap "go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
 	"aviatrix-operator/controllers"
+	"aviatrix-operator/pkg/admissionplugins"
+	"aviatrix-operator/pkg/admissionqueue"
 	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/cacheconfig"
 	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/cloudevents"
+	"aviatrix-operator/pkg/clusterdomain"
+	"aviatrix-operator/pkg/features"
+	"aviatrix-operator/pkg/gatewayname"
+	"aviatrix-operator/pkg/ha"
+	"aviatrix-operator/pkg/helperpods"
+	"aviatrix-operator/pkg/inventory"
+	"aviatrix-operator/pkg/migration"
+	"aviatrix-operator/pkg/naming"
 	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/orphans"
+	"aviatrix-operator/pkg/profiling"
+	"aviatrix-operator/pkg/recorder"
+	"aviatrix-operator/pkg/reportstore"
+	"aviatrix-operator/pkg/runtimeconfig"
 	"aviatrix-operator/pkg/security"
+	"aviatrix-operator/pkg/selfcheck"
+	"aviatrix-operator/pkg/upgrade"
+	"aviatrix-operator/pkg/webhook"
+	"aviatrix-operator/pkg/workers"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -42,8 +73,34 @@ func main() {
 	var enableLeaderElection bool
 	var probeAddr string
 	var aviatrixControllerIP string
-	var aviatrixUsername string
-	var aviatrixPassword string
+	var aviatrixCredentialsSecret string
+	var aviatrixCAConfigMap string
+	var aviatrixTLSSecret string
+	var aviatrixInsecureSkipVerify bool
+	var managedTagPrefix string
+	var skipStorageCheck bool
+	var eventSink string
+	var eventSource string
+	cacheSelectors := cacheconfig.Selectors{}
+	reconcileWorkers := workers.Workers{}
+	var profileConfig profiling.Config
+	var profileMemory string
+	var orphanConfig orphans.Config
+	var validationPlugins string
+	var clusterDomain string
+	var kubeletConfig string
+	var aviatrixPool aviatrix.PoolConfig
+	var inventoryTokenFile string
+	var configMap string
+	var selfCheck bool
+	var reportStoreURI string
+	var reportRetention reportstore.RetentionConfig
+	var reportPolicies string
+	var clusterAdmissionsPerMinute int
+	var helperPods helperpods.Config
+	var helperPodRequests string
+	var helperPodLimits string
+	var recordDir string
 	
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -51,8 +108,93 @@ func main() {
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&aviatrixControllerIP, "aviatrix-controller-ip", "", "Aviatrix Controller IP address")
-	flag.StringVar(&aviatrixUsername, "aviatrix-username", "", "Aviatrix Controller username")
-	flag.StringVar(&aviatrixPassword, "aviatrix-password", "", "Aviatrix Controller password")
+	flag.StringVar(&aviatrixCredentialsSecret, "aviatrix-credentials-secret", aviatrix.DefaultCredentialsSecret,
+		"Namespace/name of the Secret holding the username and password of the Aviatrix Controller. Changes of the Secret are applied without a restart.")
+	flag.StringVar(&aviatrixCAConfigMap, "aviatrix-ca-configmap", "",
+		"Namespace/name of a ConfigMap whose key ca.crt holds CA certificates trusted for the Aviatrix Controller in addition to the system roots.")
+	flag.StringVar(&aviatrixTLSSecret, "aviatrix-tls-secret", "",
+		"Namespace/name of a Secret holding CA certificates trusted for the Aviatrix Controller in ca.crt and a client certificate in tls.crt and tls.key.")
+	flag.BoolVar(&aviatrixInsecureSkipVerify, "aviatrix-insecure-skip-verify", false,
+		"Accept any certificate of the Aviatrix Controller. Only for labs: the credentials of the operator can be intercepted.")
+	flag.IntVar(&aviatrixPool.MaxConnsPerHost, "aviatrix-max-conns-per-host", aviatrix.DefaultMaxConnsPerHost,
+		"Maximum number of concurrent connections to the Aviatrix Controller. Requests beyond it wait for a free connection.")
+	flag.IntVar(&aviatrixPool.MaxIdleConnsPerHost, "aviatrix-max-idle-conns-per-host", aviatrix.DefaultMaxIdleConnsPerHost,
+		"Number of idle connections to the Aviatrix Controller kept open between requests.")
+	flag.DurationVar(&aviatrixPool.RequestTimeout, "aviatrix-request-timeout", aviatrix.DefaultRequestTimeout,
+		"Timeout of a single request to the Aviatrix Controller.")
+	flag.IntVar(&aviatrixPool.MaxInFlight, "aviatrix-max-in-flight", aviatrix.DefaultMaxInFlight,
+		"Maximum number of requests to the Aviatrix Controller sent at once. Further requests wait in the order they were made.")
+	flag.IntVar(&aviatrixPool.MaxInFlightPerPool, "aviatrix-max-in-flight-per-kind", aviatrix.DefaultMaxInFlightPerPool,
+		"Maximum number of requests to the Aviatrix Controller sent at once by the reconciles of one kind of resource.")
+	flag.Var(reconcileWorkers, "reconcile-workers",
+		"Number of reconciles of a kind run at once, as Kind=count, e.g. AviatrixGateway=4. The kind * sets the count of every other kind. Can be repeated; the default is 1.")
+	flag.Float64Var(&aviatrixPool.RequestsPerSecond, "aviatrix-requests-per-second", aviatrix.DefaultRequestsPerSecond,
+		"Maximum rate of requests to the Aviatrix Controller across all reconcilers. A negative rate disables the limit.")
+	flag.IntVar(&aviatrixPool.RequestBurst, "aviatrix-request-burst", aviatrix.DefaultRequestBurst,
+		"Number of requests to the Aviatrix Controller that may be sent at once above the rate.")
+	flag.IntVar(&aviatrixPool.MaxRetries, "aviatrix-max-retries", aviatrix.DefaultMaxRetries,
+		"Number of times a request failing with HTTP 429, 5xx or a connection error is retried with exponential backoff. Requests that change something are only retried after HTTP 429, 503 or a refused connection. A negative number disables retries.")
+	flag.IntVar(&aviatrixPool.BreakerThreshold, "aviatrix-breaker-threshold", aviatrix.DefaultBreakerThreshold,
+		"Number of requests in a row failing transiently that pause all requests to the Aviatrix Controller.")
+	flag.DurationVar(&aviatrixPool.BreakerCooldown, "aviatrix-breaker-cooldown", aviatrix.DefaultBreakerCooldown,
+		"How long requests to an unreachable Aviatrix Controller stay paused before one request probes it again.")
+	flag.StringVar(&managedTagPrefix, "managed-tags-prefix", "",
+		"Prefix of cloud tag keys owned by the operator. Tags with this prefix that are not in the spec are removed; other tags added in the cloud are kept.")
+	flag.Var(features.DefaultGate, "feature-gates", features.DefaultGate.Usage())
+	flag.BoolVar(&skipStorageCheck, "skip-storage-version-check", false,
+		"Start even if custom resources are stored at versions this operator cannot decode. Run the migrate command instead where possible.")
+	flag.StringVar(&eventSink, "event-sink", "",
+		"URL receiving lifecycle CloudEvents: an http(s) endpoint or nats://host:port/subject. Empty disables events.")
+	flag.StringVar(&eventSource, "event-source", "/aviatrix-operator", "CloudEvents source attribute of emitted events.")
+	flag.StringVar(&orphanConfig.Instance, "ownership-instance", orphans.DefaultInstance,
+		"Identifies this operator in the ownership tags of the gateways and VPCs it creates. Operators sharing an Aviatrix Controller need distinct values.")
+	flag.StringVar(&orphanConfig.Policy, "orphan-policy", orphans.PolicyReport,
+		"What to do with tagged gateways and VPCs no custom resource tracks: Report, Adopt or Delete.")
+	flag.DurationVar(&orphanConfig.Interval, "orphan-scan-interval", orphans.DefaultInterval,
+		"How often the Aviatrix Controller is scanned for orphaned gateways and VPCs. 0 disables the scan.")
+	flag.StringVar(&validationPlugins, "validation-plugins", "",
+		"File listing customer validation services called by the admission webhook for the custom resources of the operator. Empty disables plugins.")
+	flag.Var(naming.Default, "name-template", naming.Default.Usage())
+	flag.StringVar(&clusterDomain, "cluster-domain", "",
+		"DNS domain of the cluster, used for resources that set no clusterDomain. Empty detects it from --kubelet-config or the search path in /etc/resolv.conf, falling back to cluster.local.")
+	flag.StringVar(&kubeletConfig, "kubelet-config", "",
+		"KubeletConfiguration file to read the cluster domain from, e.g. a mounted kubelet-config ConfigMap. Empty uses /etc/resolv.conf only.")
+	flag.Var(cacheSelectors, "cache-selector",
+		"Only cache objects of a kind matching a label selector, as Kind=selector. Repeat for several kinds. Supported kinds: Pod, Service, ConfigMap, Secret, StatefulSet.")
+	flag.StringVar(&profileConfig.Dir, "profile-dir", "",
+		"Directory the self-profiler stores CPU and heap profiles in when a threshold is exceeded. Empty disables self-profiling.")
+	flag.IntVar(&profileConfig.Keep, "profile-keep", profiling.DefaultKeep, "Number of profile captures kept in the profile directory.")
+	flag.DurationVar(&profileConfig.ReconcileLatency, "profile-reconcile-latency", 0,
+		"Capture profiles when the mean reconcile duration of a controller over 30s exceeds this. 0 disables the trigger.")
+	flag.StringVar(&profileMemory, "profile-memory-threshold", "",
+		"Capture profiles when the live heap exceeds this quantity, e.g. 512Mi. Empty disables the trigger.")
+	flag.StringVar(&inventoryTokenFile, "inventory-token-file", "",
+		"File holding the bearer token that authorizes requests to /inventory and /healthsummary on the metrics address. Empty disables both endpoints.")
+	flag.StringVar(&configMap, "config-map", "",
+		"ConfigMap, as namespace/name, whose settings are applied at runtime without a restart: log level, resync intervals, feature gates, probe rate limits and event sink. Empty disables runtime configuration.")
+	flag.BoolVar(&selfCheck, "self-check", false,
+		"Validate the installation on the live cluster instead of running the operator: CRDs, webhooks, RBAC, the Aviatrix Controller login and cluster DNS. Prints a pass/fail report and exits non-zero when a check fails.")
+	flag.DurationVar(&profileConfig.Cooldown, "profile-cooldown", profiling.DefaultCooldown, "Minimum time between two profile captures.")
+	flag.StringVar(&profileConfig.UploadURL, "profile-upload-url", "",
+		"URL prefix every captured profile is uploaded to with an HTTP PUT, e.g. a bucket accepting writes. Empty keeps profiles local.")
+	flag.StringVar(&reportStoreURI, "report-store", "",
+		"Where reports too large for status are stored: file:///<dir> (e.g. a PVC), s3://<bucket>/<prefix>?region=<region> or gs://<bucket>/<prefix>. Empty keeps truncated reports in status and ConfigMaps only.")
+	flag.DurationVar(&reportRetention.MaxAge, "report-retention", reportstore.DefaultMaxAge,
+		"How long stored reports are kept after they were written.")
+	flag.StringVar(&reportPolicies, "report-retention-policy", "",
+		"Per-subsystem retention overriding --report-retention, e.g. loadtest=168h,diagnostics=72h.")
+	flag.IntVar(&clusterAdmissionsPerMinute, "cluster-admissions-per-minute", 0,
+		"Number of newly created K8sPlaygroundsClusters admitted to reconciliation per minute; clusters beyond it wait in the Queued phase in creation order. 0 admits every cluster immediately.")
+	flag.StringVar(&helperPods.PriorityClassName, "helper-pod-priority-class", "",
+		"Priority class of the discovery pods, DNS test pods and iptables DaemonSets the operator runs. Empty keeps the cluster default priority.")
+	flag.StringVar(&helperPodRequests, "helper-pod-requests", "",
+		"Resource requests of helper containers, e.g. cpu=10m,memory=32Mi. When both requests and limits are empty, cpu=10m,memory=32Mi is requested and cpu=100m,memory=128Mi is the limit.")
+	flag.StringVar(&helperPodLimits, "helper-pod-limits", "",
+		"Resource limits of helper containers, e.g. cpu=100m,memory=128Mi.")
+	flag.IntVar(&helperPods.MaxPerNamespace, "helper-pods-per-namespace", helperpods.DefaultMaxPerNamespace,
+		"Maximum number of running helper pods in a namespace; further helper pods are not created until running ones finish. A negative number disables the cap.")
+	flag.StringVar(&recordDir, "record-dir", "",
+		"Directory every K8sPlaygroundsCluster and HeadlessService reconcile is recorded into, for offline replay with bin/replay. Empty disables recording.")
 	
 	opts := zap.Options{
 		Development: true,
@@ -60,19 +202,153 @@ func main() {
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
+	// Keep the log level adjustable by the configuration ConfigMap
+	logLevel, ok := opts.Level.(uberzap.AtomicLevel)
+	if !ok {
+		logLevel = uberzap.NewAtomicLevelAt(uberzap.InfoLevel)
+		if opts.Development {
+			logLevel = uberzap.NewAtomicLevelAt(uberzap.DebugLevel)
+		}
+		opts.Level = logLevel
+	}
+	flagLogLevel := logLevel.Level()
+
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	setupLog.Info("feature gates", "gates", features.DefaultGate.Status())
+	if templates := naming.Default.String(); templates != "" {
+		setupLog.Info("name templates", "overrides", templates)
+	}
+
+	// Default the cluster domain of resources that set none
+	clusterDomainSource := clusterdomain.SourceFlag
+	if clusterDomain == "" {
+		var err error
+		clusterDomain, clusterDomainSource, err = clusterdomain.Detect(kubeletConfig, clusterdomain.ResolvConfPath)
+		if err != nil {
+			setupLog.Error(err, "unable to detect the cluster domain")
+			os.Exit(1)
+		}
+	}
+	if err := clusterdomain.SetDefault(clusterDomain); err != nil {
+		setupLog.Error(err, "invalid cluster domain")
+		os.Exit(1)
+	}
+	setupLog.Info("cluster domain", "domain", clusterdomain.Default(), "source", clusterDomainSource)
+
+	// Cancelled on SIGTERM, stopping requests to the Aviatrix Controller still in flight
+	ctx := ctrl.SetupSignalHandler()
+
+	// Validate the installation end to end and exit, e.g. for a support ticket
+	if selfCheck {
+		c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client for the self-check")
+			os.Exit(1)
+		}
+		checker := selfcheck.NewChecker(c, scheme, aviatrixv1alpha1.GroupName)
+		checker.ClusterDomain = clusterdomain.Default()
+		if aviatrixControllerIP != "" {
+			checker.Aviatrix = func(ctx context.Context) error {
+				secret, err := aviatrix.ParseSecretName(aviatrixCredentialsSecret)
+				if err != nil {
+					return err
+				}
+				credentials, err := aviatrix.NewSecretProvider(c, secret).Credentials(ctx)
+				if err != nil {
+					return err
+				}
+				tlsOptions, err := aviatrix.ParseTLSOptions(aviatrixCAConfigMap, aviatrixTLSSecret, aviatrixInsecureSkipVerify)
+				if err != nil {
+					return err
+				}
+				if aviatrixPool.TLS, err = tlsOptions.Config(ctx, c); err != nil {
+					return err
+				}
+				aviatrixClient, err := aviatrix.NewClient(ctx, aviatrixControllerIP, credentials.Username, credentials.Password, aviatrixPool)
+				if err != nil {
+					return err
+				}
+				return aviatrixClient.Logout(ctx)
+			}
+		}
+		report := checker.Run(ctx)
+		if err := report.Write(os.Stdout); err != nil {
+			setupLog.Error(err, "unable to write self-check report")
+			os.Exit(1)
+		}
+		if report.Failed() > 0 {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	cfg := ctrl.GetConfigOrDie()
 
-	// Initialize Aviatrix client
-	aviatrixClient, err := aviatrix.NewClient(aviatrixControllerIP, aviatrixUsername, aviatrixPassword)
+	// Initialize Aviatrix client with the credentials of its Secret. The cache of the manager
+	// is not running yet, so the Secret is read directly.
+	credentialsSecret, err := aviatrix.ParseSecretName(aviatrixCredentialsSecret)
+	if err != nil {
+		setupLog.Error(err, "invalid --aviatrix-credentials-secret")
+		os.Exit(1)
+	}
+	credentialsReader, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client for the Aviatrix credentials")
+		os.Exit(1)
+	}
+	credentialsProvider := aviatrix.NewSecretProvider(credentialsReader, credentialsSecret)
+	credentials, err := credentialsProvider.Credentials(ctx)
+	if err != nil {
+		setupLog.Error(err, "unable to read Aviatrix credentials")
+		os.Exit(1)
+	}
+	tlsOptions, err := aviatrix.ParseTLSOptions(aviatrixCAConfigMap, aviatrixTLSSecret, aviatrixInsecureSkipVerify)
+	if err != nil {
+		setupLog.Error(err, "invalid Aviatrix TLS options")
+		os.Exit(1)
+	}
+	if aviatrixPool.TLS, err = tlsOptions.Config(ctx, credentialsReader); err != nil {
+		setupLog.Error(err, "unable to load Aviatrix TLS configuration")
+		os.Exit(1)
+	}
+	if aviatrixInsecureSkipVerify {
+		setupLog.Info("WARNING: the certificate of the Aviatrix Controller is not verified", "flag", "--aviatrix-insecure-skip-verify")
+	}
+	aviatrixClient, err := aviatrix.NewClient(ctx, aviatrixControllerIP, credentials.Username, credentials.Password, aviatrixPool)
 	if err != nil {
 		setupLog.Error(err, "unable to create Aviatrix client")
 		os.Exit(1)
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
-		MetricsBindAddress:     metricsAddr,
-		Port:                   9443,
+	// Refuse to start on objects stored at versions this build cannot decode
+	if !skipStorageCheck {
+		c, err := client.New(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client for the storage version check")
+			os.Exit(1)
+		}
+		if err := migration.NewMigrator(c, scheme, aviatrixv1alpha1.GroupName).Check(context.Background()); err != nil {
+			setupLog.Error(err, "storage version check failed")
+			os.Exit(1)
+		}
+	}
+
+	// Endpoints served next to the metrics endpoint. The metrics server reads the map when the
+	// manager starts, so handlers that need the manager are added to it once it is created.
+	metricsHandlers := map[string]http.Handler{
+		// Expose the feature gate state next to the metrics endpoint
+		"/featuregates": features.DefaultGate.Handler(),
+	}
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:     scheme,
+		Cache:      cacheconfig.Options(cacheSelectors),
+		Controller: reconcileWorkers.Options(aviatrixv1alpha1.GroupName),
+		Metrics: metricsserver.Options{
+			BindAddress:   metricsAddr,
+			ExtraHandlers: metricsHandlers,
+		},
+		WebhookServer:          ctrlwebhook.NewServer(ctrlwebhook.Options{Port: 9443}),
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "aviatrix-operator.k8s.io",
@@ -83,15 +359,97 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Publish lifecycle events to the configured sink. The emitter runs without a sink too, so
+	// the configuration ConfigMap can set one later.
+	var flagSink cloudevents.Sink
+	if eventSink != "" {
+		if flagSink, err = cloudevents.NewSink(eventSink); err != nil {
+			setupLog.Error(err, "invalid event sink")
+			os.Exit(1)
+		}
+	}
+	events := cloudevents.NewEmitter(flagSink, eventSource)
+	if err := mgr.Add(events); err != nil {
+		setupLog.Error(err, "unable to set up event emitter")
+		os.Exit(1)
+	}
+
+	// Capture profiles of the operator itself when it slows down or grows
+	if profileConfig.Dir != "" {
+		if profileMemory != "" {
+			quantity, err := resource.ParseQuantity(profileMemory)
+			if err != nil {
+				setupLog.Error(err, "invalid profile memory threshold")
+				os.Exit(1)
+			}
+			profileConfig.MemoryBytes = uint64(quantity.Value())
+		}
+		if err := mgr.Add(profiling.New(profileConfig)); err != nil {
+			setupLog.Error(err, "unable to set up self-profiler")
+			os.Exit(1)
+		}
+	}
+
+	// Keep large reports outside the API server and prune them once they expire
+	reportStore, err := reportstore.Open(reportStoreURI)
+	if err != nil {
+		setupLog.Error(err, "invalid report store")
+		os.Exit(1)
+	}
+	if reportStore != nil {
+		if reportRetention.Policies, err = reportstore.ParsePolicies(reportPolicies); err != nil {
+			setupLog.Error(err, "invalid report retention policy")
+			os.Exit(1)
+		}
+		if err := mgr.Add(reportstore.NewPruner(reportStore, reportRetention)); err != nil {
+			setupLog.Error(err, "unable to set up report pruner")
+			os.Exit(1)
+		}
+	}
+
+	// Size and prioritize the pods the operator runs for headless services and clusters
+	if helperPods.Resources.Requests, err = helperpods.ParseResourceList(helperPodRequests); err != nil {
+		setupLog.Error(err, "invalid helper pod requests")
+		os.Exit(1)
+	}
+	if helperPods.Resources.Limits, err = helperpods.ParseResourceList(helperPodLimits); err != nil {
+		setupLog.Error(err, "invalid helper pod limits")
+		os.Exit(1)
+	}
+
 	// Initialize managers
 	cloudManager := cloud.NewManager(aviatrixClient)
 	networkManager := network.NewManager(aviatrixClient)
 	securityManager := security.NewManager(aviatrixClient)
 
+	// Gateway and spoke gateway upgrades share one coordinator, so one gateway upgrades at a time
+	upgrades := upgrade.NewCoordinator(mgr.GetClient(), cloudManager)
+
+	// Match tagged gateways and VPCs against custom resources to find those left behind by
+	// crashes or deletions while the operator was down
+	if orphanConfig.Interval > 0 {
+		scanner, err := orphans.NewScanner(mgr.GetClient(), cloudManager, orphanConfig, events)
+		if err != nil {
+			setupLog.Error(err, "invalid orphan scan configuration")
+			os.Exit(1)
+		}
+		if err := mgr.Add(scanner); err != nil {
+			setupLog.Error(err, "unable to set up orphan scanner")
+			os.Exit(1)
+		}
+	}
+
+	// Index gateways by name so conflicting resources are found without listing every gateway
+	if err := gatewayname.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		setupLog.Error(err, "unable to set up gateway name index")
+		os.Exit(1)
+	}
+
 	// Setup controllers
 	if err = (&controllers.AviatrixControllerReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixcontroller"),
 		AviatrixClient: aviatrixClient,
 		CloudManager:   cloudManager,
 		NetworkManager: networkManager,
@@ -102,10 +460,15 @@ func main() {
 	}
 
 	if err = (&controllers.AviatrixGatewayReconciler{
-		Client:         mgr.GetClient(),
-		Scheme:         mgr.GetScheme(),
-		AviatrixClient: aviatrixClient,
-		CloudManager:   cloudManager,
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		Recorder:          mgr.GetEventRecorderFor("aviatrixgateway"),
+		AviatrixClient:    aviatrixClient,
+		CloudManager:      cloudManager,
+		ManagedTagPrefix:  managedTagPrefix,
+		OwnershipInstance: orphanConfig.Instance,
+		Events:            events,
+		Upgrades:          upgrades,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AviatrixGateway")
 		os.Exit(1)
@@ -114,8 +477,11 @@ func main() {
 	if err = (&controllers.AviatrixSpokeGatewayReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixspokegateway"),
 		AviatrixClient: aviatrixClient,
 		CloudManager:   cloudManager,
+		NetworkManager: networkManager,
+		Upgrades:       upgrades,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AviatrixSpokeGateway")
 		os.Exit(1)
@@ -124,18 +490,67 @@ func main() {
 	if err = (&controllers.AviatrixTransitGatewayReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixtransitgateway"),
 		AviatrixClient: aviatrixClient,
 		CloudManager:   cloudManager,
+		NetworkManager: networkManager,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AviatrixTransitGateway")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.AviatrixVpcReconciler{
+	if err = (&controllers.AviatrixAccountReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixaccount"),
 		AviatrixClient: aviatrixClient,
 		CloudManager:   cloudManager,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixAccount")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.AviatrixTransitGatewayPeeringReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixtransitgatewaypeering"),
+		AviatrixClient: aviatrixClient,
+		NetworkManager: networkManager,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixTransitGatewayPeering")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.AviatrixVpcPeeringReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixvpcpeering"),
+		AviatrixClient: aviatrixClient,
+		NetworkManager: networkManager,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixVpcPeering")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.AviatrixTgwAttachmentReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixtgwattachment"),
+		AviatrixClient: aviatrixClient,
+		NetworkManager: networkManager,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixTgwAttachment")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.AviatrixVpcReconciler{
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		Recorder:          mgr.GetEventRecorderFor("aviatrixvpc"),
+		AviatrixClient:    aviatrixClient,
+		CloudManager:      cloudManager,
+		ManagedTagPrefix:  managedTagPrefix,
+		OwnershipInstance: orphanConfig.Instance,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AviatrixVpc")
 		os.Exit(1)
@@ -144,6 +559,7 @@ func main() {
 	if err = (&controllers.AviatrixFirewallReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixfirewall"),
 		AviatrixClient: aviatrixClient,
 		SecurityManager: securityManager,
 	}).SetupWithManager(mgr); err != nil {
@@ -154,6 +570,7 @@ func main() {
 	if err = (&controllers.AviatrixNetworkDomainReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixnetworkdomain"),
 		AviatrixClient: aviatrixClient,
 		NetworkManager: networkManager,
 	}).SetupWithManager(mgr); err != nil {
@@ -164,6 +581,7 @@ func main() {
 	if err = (&controllers.AviatrixSegmentationSecurityDomainReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixsegmentationsecuritydomain"),
 		AviatrixClient: aviatrixClient,
 		SecurityManager: securityManager,
 	}).SetupWithManager(mgr); err != nil {
@@ -174,6 +592,7 @@ func main() {
 	if err = (&controllers.AviatrixMicrosegPolicyReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixmicrosegpolicy"),
 		AviatrixClient: aviatrixClient,
 		SecurityManager: securityManager,
 	}).SetupWithManager(mgr); err != nil {
@@ -184,6 +603,7 @@ func main() {
 	if err = (&controllers.AviatrixEdgeGatewayReconciler{
 		Client:         mgr.GetClient(),
 		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixedgegateway"),
 		AviatrixClient: aviatrixClient,
 		CloudManager:   cloudManager,
 	}).SetupWithManager(mgr); err != nil {
@@ -191,8 +611,207 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err = (&controllers.AviatrixDiagnosticReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixdiagnostic"),
+		AviatrixClient: aviatrixClient,
+		CloudManager:   cloudManager,
+		ReportStore:    reportStore,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixDiagnostic")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.AviatrixKeyRotationReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("aviatrixkeyrotation"),
+		AviatrixClient: aviatrixClient,
+		NetworkManager: networkManager,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixKeyRotation")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.AviatrixTrafficPolicyReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("aviatrixtrafficpolicy"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixTrafficPolicy")
+		os.Exit(1)
+	}
+
+	// Record playground reconciles for offline replay
+	var recordings *recorder.Recorder
+	if recordDir != "" {
+		recordings = recorder.New(recordDir)
+	}
+
+	// Admit newly created playground clusters at a fixed rate, so a burst of creations does not
+	// start every cluster at once
+	var admissionQueue *admissionqueue.Queue
+	if clusterAdmissionsPerMinute > 0 {
+		admissionQueue = admissionqueue.NewQueue(clusterAdmissionsPerMinute)
+	}
+	if err = (&controllers.K8sPlaygroundsClusterReconciler{
+		Client:         mgr.GetClient(),
+		Scheme:         mgr.GetScheme(),
+		Recorder:       mgr.GetEventRecorderFor("k8splaygroundscluster"),
+		AdmissionQueue: admissionQueue,
+		Events:         events,
+		APIReader:      mgr.GetAPIReader(),
+		HelperPods:     helperPods,
+		Recordings:     recordings,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "K8sPlaygroundsCluster")
+		os.Exit(1)
+	}
+	if err = (&controllers.HeadlessServiceReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Recorder:    mgr.GetEventRecorderFor("headlessservice"),
+		Events:      events,
+		ReportStore: reportStore,
+		HelperPods:  helperPods,
+		Recordings:  recordings,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HeadlessService")
+		os.Exit(1)
+	}
+	if err = (&controllers.BreakGlassReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("breakglass"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "BreakGlass")
+		os.Exit(1)
+	}
+	if err = (&controllers.K8sPlaygroundsFleetReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "K8sPlaygroundsFleet")
+		os.Exit(1)
+	}
+
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&webhook.GatewayNameValidator{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GatewayName")
+			os.Exit(1)
+		}
+		if err = (&webhook.SpecValidator{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Spec")
+			os.Exit(1)
+		}
+		if err = (&webhook.K8sPlaygroundsClusterDefaulter{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "K8sPlaygroundsClusterDefaulter")
+			os.Exit(1)
+		}
+		if err = (&webhook.K8sPlaygroundsClusterValidator{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "K8sPlaygroundsCluster")
+			os.Exit(1)
+		}
+		if err = (&webhook.HeadlessServiceDefaulter{}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "HeadlessServiceDefaulter")
+			os.Exit(1)
+		}
+		if err = (&webhook.HeadlessServiceValidator{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "HeadlessServiceValidator")
+			os.Exit(1)
+		}
+		// The plugin webhook is registered for every custom resource, so it is served even
+		// without plugins and then admits everything
+		pluginConfig := &admissionplugins.Config{}
+		if validationPlugins != "" {
+			if pluginConfig, err = admissionplugins.LoadConfig(validationPlugins); err != nil {
+				setupLog.Error(err, "invalid validation plugins")
+				os.Exit(1)
+			}
+		}
+		chain, err := admissionplugins.NewChain(pluginConfig)
+		if err != nil {
+			setupLog.Error(err, "invalid validation plugins")
+			os.Exit(1)
+		}
+		if err = chain.SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ValidationPlugins")
+			os.Exit(1)
+		}
+	}
+
 	//+kubebuilder:scaffold:builder
 
+	// Log in again when the credentials Secret changes, so they rotate without a restart
+	if err := credentialsProvider.SetupWithManager(mgr, aviatrixClient); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "AviatrixCredentials")
+		os.Exit(1)
+	}
+
+	// Apply the settings of the configuration ConfigMap without a restart. Settings the
+	// ConfigMap leaves out fall back to the flags.
+	if configMap != "" {
+		namespace, name, ok := strings.Cut(configMap, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "invalid configuration ConfigMap, want namespace/name", "configMap", configMap)
+			os.Exit(1)
+		}
+		runtimeconfig.Default.Subscribe(func(config runtimeconfig.Config) {
+			level := flagLogLevel
+			if config.LogLevel != "" {
+				level, _ = runtimeconfig.ParseLogLevel(config.LogLevel)
+			}
+			logLevel.SetLevel(level)
+
+			if err := features.DefaultGate.SetRuntime(config.FeatureGates); err != nil {
+				setupLog.Error(err, "unable to apply feature gates")
+			}
+
+			sink := flagSink
+			if config.EventSink != "" {
+				sink, _ = cloudevents.NewSink(config.EventSink)
+			}
+			events.SetSink(sink)
+		})
+		if err = (&controllers.OperatorConfigReconciler{
+			Client:    mgr.GetClient(),
+			Recorder:  mgr.GetEventRecorderFor("operatorconfig"),
+			ConfigMap: types.NamespacedName{Namespace: namespace, Name: name},
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "OperatorConfig")
+			os.Exit(1)
+		}
+	}
+
+	// Every replica serves the read-only endpoints from its own cache, while only the elected
+	// leader reconciles, so monitors keep working while the leader is replaced
+	identity, _ := os.Hostname()
+	roles := ha.NewTracker(mgr.Elected(), identity)
+	roles.WarmCache(mgr.GetCache(), &aviatrixv1alpha1.K8sPlaygroundsCluster{})
+	if err := mgr.Add(roles); err != nil {
+		setupLog.Error(err, "unable to set up role tracker")
+		os.Exit(1)
+	}
+	metricsHandlers["/role"] = roles.Handler()
+	crmetrics.Registry.MustRegister(inventory.NewCollector(mgr.GetClient()))
+
+	// Serve the managed clusters to external monitors that hold no Kubernetes credentials
+	if inventoryTokenFile != "" {
+		data, err := os.ReadFile(inventoryTokenFile)
+		if err != nil {
+			setupLog.Error(err, "unable to read inventory token", "file", inventoryTokenFile)
+			os.Exit(1)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			setupLog.Error(errors.New("inventory token file is empty"), "unable to read inventory token", "file", inventoryTokenFile)
+			os.Exit(1)
+		}
+		metricsHandlers["/inventory"] = roles.ReadOnly(inventory.Handler(mgr.GetClient(), token))
+		metricsHandlers["/healthsummary"] = roles.ReadOnly(inventory.SummaryHandler(mgr.GetClient(), token))
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -203,7 +822,7 @@ func main() {
 	}
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
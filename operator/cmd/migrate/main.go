@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/migration"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(aviatrixv1alpha1.AddToScheme(scheme))
+}
+
+// migrate rewrites every stored object of the operator's API group at the
+// current storage version after a CRD schema bump, reporting objects the
+// operator can no longer decode. Run it before upgrading the operator, which
+// refuses to start while objects are stored at versions it does not know.
+func main() {
+	var dryRun bool
+
+	flag.BoolVar(&dryRun, "dry-run", false, "Only decode stored objects and report the undecodable ones, without rewriting anything")
+	flag.Parse()
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := migration.NewMigrator(c, scheme, aviatrixv1alpha1.GroupName).Migrate(context.Background(), dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to migrate storage: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := report.Write(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write report: %v\n", err)
+		os.Exit(1)
+	}
+	if failed := report.Failed(); failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d object(s) could not be migrated\n", failed)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/controllers"
+	"github.com/k8s-playgrounds/operator/pkg/recorder"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(k8splaygroundsv1alpha1.AddToScheme(scheme))
+}
+
+// replay re-runs a recorded reconcile offline and reports where its writes diverge
+func main() {
+	var path string
+	var output string
+
+	flag.StringVar(&path, "recording", "", "Path of the recording to replay (required)")
+	flag.StringVar(&output, "output-dir", "", "Directory to save the replayed recording into (default: not saved)")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "--recording is required")
+		os.Exit(1)
+	}
+
+	recording, err := recorder.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to load recording: %v\n", err)
+		os.Exit(1)
+	}
+
+	var build func(client.Client) reconcile.Reconciler
+	switch recording.Controller {
+	case "K8sPlaygroundsCluster":
+		build = func(c client.Client) reconcile.Reconciler {
+			return &controllers.K8sPlaygroundsClusterReconciler{Client: c, Scheme: scheme}
+		}
+	case "HeadlessService":
+		build = func(c client.Client) reconcile.Reconciler {
+			return &controllers.HeadlessServiceReconciler{Client: c, Scheme: scheme}
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unsupported controller %q in recording\n", recording.Controller)
+		os.Exit(1)
+	}
+
+	ctx := ctrl.LoggerInto(context.Background(), ctrl.Log.WithName("replay"))
+	replayed, diffs, err := recorder.Replay(ctx, recording, scheme, build)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to replay recording: %v\n", err)
+		os.Exit(1)
+	}
+
+	if output != "" {
+		saved, err := replayed.Save(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to save replayed recording: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Replayed recording saved to %s\n", saved)
+	}
+
+	fmt.Printf("Replayed %s reconcile of %s: %d reads, %d writes recorded; %d writes replayed\n",
+		recording.Controller, recording.Request, len(recording.Inputs), len(recording.Outputs), len(replayed.Outputs))
+	if len(diffs) == 0 {
+		fmt.Println("No differences")
+		return
+	}
+	for _, diff := range diffs {
+		fmt.Println(diff)
+	}
+	os.Exit(2)
+}
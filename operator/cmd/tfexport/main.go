@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/terraform"
+)
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(aviatrixv1alpha1.AddToScheme(scheme))
+}
+
+// tfexport prints Terraform import blocks or commands for every Aviatrix
+// resource managed by the operator, so environments can be handed over to
+// Terraform (or adopted back) without recreating cloud resources.
+func main() {
+	var namespace string
+	var format string
+	var output string
+
+	flag.StringVar(&namespace, "namespace", "", "Only export resources from this namespace (default: all namespaces)")
+	flag.StringVar(&format, "format", terraform.FormatBlocks, "Output format: blocks (Terraform 1.5+ import blocks) or commands (terraform import CLI)")
+	flag.StringVar(&output, "output", "", "File to write to (default: stdout)")
+	flag.Parse()
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to create client: %v\n", err)
+		os.Exit(1)
+	}
+
+	mappings, err := terraform.NewExporter(c).Collect(context.Background(), namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to collect resources: %v\n", err)
+		os.Exit(1)
+	}
+
+	out := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to create output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if err := terraform.Write(out, mappings, format); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to write output: %v\n", err)
+		os.Exit(1)
+	}
+}
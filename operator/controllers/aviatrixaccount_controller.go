@@ -0,0 +1,245 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/accounts"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/verbs"
+)
+
+// AccountFinalizer keeps an AviatrixAccount until its account is offboarded from the Aviatrix
+// Controller
+const AccountFinalizer = "aviatrix.k8s.io/account"
+
+// AccountResyncInterval is how often an account is audited. Audits make the Aviatrix
+// Controller call the cloud provider, so they run less often than lookups of other resources.
+const AccountResyncInterval = 15 * time.Minute
+
+// AccountConditionAudited reports whether the last audit of the account passed
+const AccountConditionAudited = "Audited"
+
+// AviatrixAccountReconciler reconciles a AviatrixAccount object
+type AviatrixAccountReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
+	AviatrixClient *aviatrix.Client
+	CloudManager   *cloud.Manager
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixaccounts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixaccounts/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// Reconcile onboards the cloud account of the spec with the credentials of its Secret, sends
+// the credentials again when they change, audits the account and offboards it with the
+// resource
+func (r *AviatrixAccountReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixAccount instance
+	account := &aviatrixv1alpha1.AviatrixAccount{}
+	if err := r.Get(ctx, req.NamespacedName, account); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixAccount")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixAccount resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, account); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, account, AccountFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, account)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	account.Status.LastUpdated = metav1.Now()
+
+	// Retrying cannot fix a missing Secret or setting; the Secret watch and the next change of
+	// the spec reconcile again
+	var secret *corev1.Secret
+	if name := account.Spec.CredentialsSecret; name != "" {
+		secret = &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: account.Namespace, Name: name}, secret); err != nil {
+			if apierrors.IsNotFound(err) {
+				r.fail(ctx, account, conditions.ReasonCredentialsInvalid, fmt.Errorf("credentials Secret %s not found", name))
+				return ctrl.Result{}, nil
+			}
+			logger.Error(err, "failed to read credentials Secret", "secret", name)
+			return ctrl.Result{}, err
+		}
+	}
+	desired, err := accounts.Build(account, secret)
+	if err != nil {
+		r.fail(ctx, account, conditions.ReasonCredentialsInvalid, err)
+		return ctrl.Result{}, nil
+	}
+	hash := accounts.Hash(desired)
+
+	// Accounts cannot be renamed; an account of another name is offboarded
+	status := &account.Status
+	if status.AccountName != "" && status.AccountName != account.Spec.AccountName {
+		if err := r.CloudManager.DeleteAccount(ctx, status.AccountName); err != nil && !aviatrix.IsNotFound(err) {
+			return r.fail(ctx, account, conditions.ReasonDeleteFailed, fmt.Errorf("failed to offboard renamed account: %w", err))
+		}
+		logger.Info("Offboarded renamed account", "accountName", status.AccountName)
+		recordNormal(r.Recorder, account, EventReasonDeleted, "Offboarded renamed account %s", status.AccountName)
+		status.AccountName, status.CredentialsHash = "", ""
+	}
+
+	// Onboard the account if the Aviatrix Controller does not know it yet, and send the
+	// settings again when they or the credentials changed
+	_, err = r.CloudManager.GetAccount(ctx, account.Spec.AccountName)
+	switch {
+	case aviatrix.IsNotFound(err):
+		status.Phase = conditions.PhaseReconciling
+		status.State = conditions.StateCreating
+		if err := r.CloudManager.CreateAccount(ctx, desired); err != nil {
+			return r.fail(ctx, account, conditions.ReasonCreateFailed, fmt.Errorf("failed to onboard account: %w", err))
+		}
+		logger.Info("Onboarded account", "accountName", account.Spec.AccountName, "cloudType", account.Spec.CloudType)
+		recordNormal(r.Recorder, account, EventReasonCreated, "Onboarded account %s", account.Spec.AccountName)
+	case err != nil:
+		return r.fail(ctx, account, conditions.ReasonControllerError, fmt.Errorf("failed to get account: %w", err))
+	case status.CredentialsHash != hash:
+		// Accounts onboarded before the resource existed are taken over with the spec
+		status.Phase = conditions.PhaseReconciling
+		status.State = conditions.StateUpdating
+		if err := r.CloudManager.UpdateAccount(ctx, desired); err != nil {
+			return r.fail(ctx, account, conditions.ReasonUpdateFailed, fmt.Errorf("failed to update account: %w", err))
+		}
+		logger.Info("Updated account settings and credentials", "accountName", account.Spec.AccountName)
+		recordNormal(r.Recorder, account, EventReasonUpdated, "Updated the settings and credentials of account %s", account.Spec.AccountName)
+	}
+	status.AccountName, status.CredentialsHash = account.Spec.AccountName, hash
+
+	if err := r.audit(ctx, account); err != nil {
+		return r.fail(ctx, account, conditions.ReasonControllerError, err)
+	}
+
+	// An account failing its audit stays onboarded, but gateways cannot be launched with it
+	status.Phase = conditions.PhaseReady
+	status.State = conditions.StateActive
+	conditions.MarkReady(&status.Conditions, account.Generation, conditions.ReasonOnboarded, fmt.Sprintf("Account %s is onboarded", account.Spec.AccountName))
+	if status.Audit.Result == aviatrixv1alpha1.AccountAuditFailed {
+		status.Phase = conditions.PhaseDegraded
+		conditions.Set(&status.Conditions, account.Generation, conditions.TypeDegraded, metav1.ConditionTrue, conditions.ReasonAuditFailed, status.Audit.Message)
+	}
+	if err := r.Status().Update(ctx, account); err != nil {
+		logger.Error(err, "failed to update AviatrixAccount status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixAccount reconciled successfully")
+	return ctrl.Result{RequeueAfter: AccountResyncInterval}, nil
+}
+
+// audit has the Aviatrix Controller check the account and records the outcome. err is only set
+// when the audit could not run.
+func (r *AviatrixAccountReconciler) audit(ctx context.Context, account *aviatrixv1alpha1.AviatrixAccount) error {
+	result, err := r.CloudManager.AuditAccount(ctx, account.Spec.AccountName)
+	if err != nil {
+		return fmt.Errorf("failed to audit account: %w", err)
+	}
+
+	now := metav1.Now()
+	audit := &aviatrixv1alpha1.AccountAuditStatus{Result: aviatrixv1alpha1.AccountAuditPassed, AuditedAt: &now}
+	condition := metav1.Condition{
+		Type:               AccountConditionAudited,
+		Status:             metav1.ConditionTrue,
+		Reason:             conditions.ReasonAuditPassed,
+		Message:            "The Aviatrix Controller can use the account",
+		ObservedGeneration: account.Generation,
+	}
+	if !result.Passed {
+		log.FromContext(ctx).Info("account failed its audit", "accountName", account.Spec.AccountName, "message", result.Message)
+		audit.Result, audit.Message = aviatrixv1alpha1.AccountAuditFailed, result.Message
+		audit.FailingSince = &now
+		if previous := account.Status.Audit; previous != nil && previous.FailingSince != nil {
+			audit.FailingSince = previous.FailingSince
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = conditions.ReasonAuditFailed
+		condition.Message = result.Message
+	}
+	account.Status.Audit = audit
+	meta.SetStatusCondition(&account.Status.Conditions, condition)
+	return nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixAccountReconciler) fail(ctx context.Context, account *aviatrixv1alpha1.AviatrixAccount, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, account, "AviatrixAccount", reason, err, &account.Status.Phase, &account.Status.State, &account.Status.Conditions)
+}
+
+// cleanup offboards the account from the Aviatrix Controller. The Controller refuses while
+// gateways or VPCs still use the account, so the resource is kept until they are gone.
+func (r *AviatrixAccountReconciler) cleanup(ctx context.Context, account *aviatrixv1alpha1.AviatrixAccount) error {
+	// The account that was onboarded may differ from a spec edited since
+	accountName := account.Status.AccountName
+	if accountName == "" {
+		accountName = account.Spec.AccountName
+	}
+	account.Status.Phase = conditions.PhaseDeleting
+	account.Status.State = conditions.StateDeleting
+	if err := r.CloudManager.DeleteAccount(ctx, accountName); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, account, conditions.ReasonDeleteFailed, fmt.Errorf("failed to offboard account: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Offboarded account", "accountName", accountName)
+	recordNormal(r.Recorder, account, EventReasonDeleted, "Offboarded account %s", accountName)
+	return nil
+}
+
+// secretToAccounts maps a Secret to the accounts of its namespace that read their credentials
+// from it
+func (r *AviatrixAccountReconciler) secretToAccounts(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &aviatrixv1alpha1.AviatrixAccountList{}
+	if err := r.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if list.Items[i].Spec.CredentialsSecret == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+func (r *AviatrixAccountReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Accounts offboarded or broken outside the operator are picked up by the periodic resync
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixAccount{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		// Rotated credentials are sent to the Aviatrix Controller right away
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.secretToAccounts)).
+		Complete(aviatrixReconciler("AviatrixAccount", r))
+}
@@ -2,15 +2,16 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/cloud"
 	"aviatrix-operator/pkg/network"
@@ -21,6 +22,7 @@ import (
 type AviatrixControllerReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	CloudManager   *cloud.Manager
 	NetworkManager *network.Manager
@@ -49,33 +51,30 @@ func (r *AviatrixControllerReconciler) Reconcile(ctx context.Context, req ctrl.R
 		return ctrl.Result{}, nil
 	}
 
+	if _, stop, err := handleVerbs(ctx, r.Client, controller); stop {
+		return ctrl.Result{}, err
+	}
+
 	// Update status
-	controller.Status.Phase = "Reconciling"
-	controller.Status.State = "Active"
+	controller.Status.Phase = conditions.PhaseReconciling
+	controller.Status.State = conditions.StateActive
 	controller.Status.LastUpdated = metav1.Now()
 
 	// Set up Aviatrix Controller connection
 	if err := r.setupAviatrixController(ctx, controller); err != nil {
-		logger.Error(err, "failed to setup Aviatrix Controller")
-		controller.Status.Phase = "Failed"
-		controller.Status.State = "Error"
-		r.Status().Update(ctx, controller)
-		return ctrl.Result{}, err
+		return r.fail(ctx, controller, err)
 	}
 
 	// Validate cloud account
 	if err := r.validateCloudAccount(ctx, controller); err != nil {
-		logger.Error(err, "failed to validate cloud account")
-		controller.Status.Phase = "Failed"
-		controller.Status.State = "Error"
-		r.Status().Update(ctx, controller)
-		return ctrl.Result{}, err
+		return r.fail(ctx, controller, err)
 	}
 
 	// Update status to ready
-	controller.Status.Phase = "Ready"
-	controller.Status.State = "Active"
+	controller.Status.Phase = conditions.PhaseReady
+	controller.Status.State = conditions.StateActive
 	controller.Status.Version = controller.Spec.Version
+	conditions.MarkReady(&controller.Status.Conditions, controller.Generation, conditions.ReasonReconciled, "Connected to the Aviatrix Controller and validated the cloud account")
 
 	if err := r.Status().Update(ctx, controller); err != nil {
 		logger.Error(err, "failed to update AviatrixController status")
@@ -86,13 +85,20 @@ func (r *AviatrixControllerReconciler) Reconcile(ctx context.Context, req ctrl.R
 	return ctrl.Result{}, nil
 }
 
+// fail records a failed reconcile in the status, with the reason carried by err, and returns
+// err, so the reconcile is retried with backoff
+func (r *AviatrixControllerReconciler) fail(ctx context.Context, controller *aviatrixv1alpha1.AviatrixController, err error) (ctrl.Result, error) {
+	reason := conditions.ReasonFor(err, conditions.ReasonControllerError)
+	return failReconcile(ctx, r.Client, r.Recorder, controller, "AviatrixController", reason, err, &controller.Status.Phase, &controller.Status.State, &controller.Status.Conditions)
+}
+
 // setupAviatrixController sets up the Aviatrix Controller connection
 func (r *AviatrixControllerReconciler) setupAviatrixController(ctx context.Context, controller *aviatrixv1alpha1.AviatrixController) error {
 	logger := log.FromContext(ctx)
 
 	// Test connection to Aviatrix Controller
-	if err := r.AviatrixClient.Login(); err != nil {
-		return fmt.Errorf("failed to connect to Aviatrix Controller: %w", err)
+	if err := r.AviatrixClient.Login(ctx); err != nil {
+		return conditions.Errorf(conditions.ReasonAviatrixUnreachable, "failed to connect to Aviatrix Controller: %w", err)
 	}
 
 	logger.Info("Successfully connected to Aviatrix Controller", "controllerIP", controller.Spec.ControllerIP)
@@ -104,8 +110,8 @@ func (r *AviatrixControllerReconciler) validateCloudAccount(ctx context.Context,
 	logger := log.FromContext(ctx)
 
 	// Validate cloud account
-	if err := r.CloudManager.ValidateCloudAccount(controller.Spec.AccountName, controller.Spec.CloudType); err != nil {
-		return fmt.Errorf("failed to validate cloud account: %w", err)
+	if err := r.CloudManager.ValidateCloudAccount(ctx, controller.Spec.AccountName, controller.Spec.CloudType); err != nil {
+		return conditions.Errorf(conditions.ReasonCloudAccountInvalid, "failed to validate cloud account: %w", err)
 	}
 
 	logger.Info("Successfully validated cloud account", "accountName", controller.Spec.AccountName, "cloudType", controller.Spec.CloudType)
@@ -116,5 +122,5 @@ func (r *AviatrixControllerReconciler) validateCloudAccount(ctx context.Context,
 func (r *AviatrixControllerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&aviatrixv1alpha1.AviatrixController{}).
-		Complete(r)
+		Complete(aviatrixReconciler("AviatrixController", r))
 }
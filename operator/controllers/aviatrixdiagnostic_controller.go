@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/reportstore"
+)
+
+// Diagnostic actions
+const (
+	DiagnosticActionPing          = "ping"
+	DiagnosticActionTraceroute    = "traceroute"
+	DiagnosticActionTunnelStatus  = "tunnelStatus"
+	DiagnosticActionPacketCapture = "packetCapture"
+)
+
+const (
+	// defaultPingCount is the number of ping packets sent when Count is unset
+	defaultPingCount = 5
+	// defaultCaptureDuration is how long a packet capture runs when DurationSeconds is unset
+	defaultCaptureDuration = 60 * time.Second
+	// maxDiagnosticOutput caps the command output kept in status
+	maxDiagnosticOutput = 32 * 1024
+	// diagnosticReports is the report store subsystem of diagnostic output
+	diagnosticReports = "diagnostics"
+)
+
+// DiagnosticFinalizer keeps an AviatrixDiagnostic until its running packet capture is stopped
+const DiagnosticFinalizer = "aviatrix.k8s.io/diagnostic"
+
+// AviatrixDiagnosticReconciler reconciles a AviatrixDiagnostic object
+type AviatrixDiagnosticReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
+	AviatrixClient *aviatrix.Client
+	CloudManager   *cloud.Manager
+
+	// ReportStore keeps the full output of diagnostics, which status only holds truncated;
+	// nil disables it
+	ReportStore reportstore.Store
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixdiagnostics,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixdiagnostics/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixdiagnostics/finalizers,verbs=update
+
+// Reconcile runs the requested diagnostic once per spec generation. Packet captures keep
+// running until their duration elapses or spec.packetCapture.stop is set, after which the
+// capture file is uploaded to the configured bucket.
+func (r *AviatrixDiagnosticReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixDiagnostic instance
+	diagnostic := &aviatrixv1alpha1.AviatrixDiagnostic{}
+	if err := r.Get(ctx, req.NamespacedName, diagnostic); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixDiagnostic")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixDiagnostic resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	request, stop, err := handleVerbs(ctx, r.Client, diagnostic)
+	if stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, diagnostic, DiagnosticFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, diagnostic)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	// A running capture continues across spec changes so it can be stopped; finished
+	// diagnostics only run again when the spec changes or a resync is forced
+	status := &diagnostic.Status
+	if status.Phase != conditions.PhaseRunning {
+		if status.ObservedGeneration == diagnostic.Generation && !request.ForceResync && (status.Phase == conditions.PhaseSucceeded || status.Phase == conditions.PhaseFailed) {
+			return ctrl.Result{}, nil
+		}
+		now := metav1.Now()
+		*status = aviatrixv1alpha1.AviatrixDiagnosticStatus{
+			Phase:     conditions.PhasePending,
+			StartedAt: &now,
+		}
+	}
+	status.ObservedGeneration = diagnostic.Generation
+
+	var result ctrl.Result
+	switch diagnostic.Spec.Action {
+	case DiagnosticActionPing:
+		err = r.runPing(ctx, diagnostic)
+	case DiagnosticActionTraceroute:
+		err = r.runTraceroute(ctx, diagnostic)
+	case DiagnosticActionTunnelStatus:
+		err = r.runTunnelStatus(ctx, diagnostic)
+	case DiagnosticActionPacketCapture:
+		result, err = r.runPacketCapture(ctx, diagnostic)
+	default:
+		err = fmt.Errorf("unknown diagnostic action %q", diagnostic.Spec.Action)
+	}
+
+	if err != nil {
+		// Diagnostics are one-shot; a failed run is reported instead of retried
+		logger.Error(err, "diagnostic failed", "gateway", diagnostic.Spec.GwName, "action", diagnostic.Spec.Action)
+		now := metav1.Now()
+		status.Phase = conditions.PhaseFailed
+		status.Message = err.Error()
+		recordFailure(r.Recorder, diagnostic, conditions.ReasonFor(err, conditions.ReasonControllerError), err)
+		status.CompletedAt = &now
+	} else if status.Phase != conditions.PhaseRunning {
+		now := metav1.Now()
+		status.Phase = conditions.PhaseSucceeded
+		status.Message = ""
+		status.CompletedAt = &now
+	}
+
+	if err := r.Status().Update(ctx, diagnostic); err != nil {
+		logger.Error(err, "failed to update AviatrixDiagnostic status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixDiagnostic reconciled", "phase", status.Phase)
+	return result, nil
+}
+
+// runPing pings the target from the gateway
+func (r *AviatrixDiagnosticReconciler) runPing(ctx context.Context, diagnostic *aviatrixv1alpha1.AviatrixDiagnostic) error {
+	if diagnostic.Spec.Target == "" {
+		return fmt.Errorf("target is required for %s", DiagnosticActionPing)
+	}
+	count := diagnostic.Spec.Count
+	if count <= 0 {
+		count = defaultPingCount
+	}
+
+	output, err := r.CloudManager.PingFromGateway(ctx, diagnostic.Spec.GwName, diagnostic.Spec.Target, count)
+	if err != nil {
+		return err
+	}
+	return r.recordOutput(ctx, diagnostic, output)
+}
+
+// runTraceroute traces the route to the target from the gateway
+func (r *AviatrixDiagnosticReconciler) runTraceroute(ctx context.Context, diagnostic *aviatrixv1alpha1.AviatrixDiagnostic) error {
+	if diagnostic.Spec.Target == "" {
+		return fmt.Errorf("target is required for %s", DiagnosticActionTraceroute)
+	}
+
+	output, err := r.CloudManager.TracerouteFromGateway(ctx, diagnostic.Spec.GwName, diagnostic.Spec.Target)
+	if err != nil {
+		return err
+	}
+	return r.recordOutput(ctx, diagnostic, output)
+}
+
+// runTunnelStatus records the state of every tunnel of the gateway
+func (r *AviatrixDiagnosticReconciler) runTunnelStatus(ctx context.Context, diagnostic *aviatrixv1alpha1.AviatrixDiagnostic) error {
+	tunnels, err := r.CloudManager.GetTunnelStatus(ctx, diagnostic.Spec.GwName)
+	if err != nil {
+		return err
+	}
+
+	diagnostic.Status.Tunnels = make([]aviatrixv1alpha1.TunnelStatus, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		peer, _ := tunnel["peer_name"].(string)
+		state, _ := tunnel["status"].(string)
+		tunnelType, _ := tunnel["type"].(string)
+		diagnostic.Status.Tunnels = append(diagnostic.Status.Tunnels, aviatrixv1alpha1.TunnelStatus{
+			Peer:  peer,
+			State: state,
+			Type:  tunnelType,
+		})
+	}
+	return nil
+}
+
+// runPacketCapture starts a capture, waits for it to end and uploads the capture file
+func (r *AviatrixDiagnosticReconciler) runPacketCapture(ctx context.Context, diagnostic *aviatrixv1alpha1.AviatrixDiagnostic) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	capture := diagnostic.Spec.PacketCapture
+	if capture == nil || capture.Bucket == "" {
+		return ctrl.Result{}, fmt.Errorf("packetCapture.bucket is required for %s", DiagnosticActionPacketCapture)
+	}
+	duration := defaultCaptureDuration
+	if capture.DurationSeconds > 0 {
+		duration = time.Duration(capture.DurationSeconds) * time.Second
+	}
+
+	status := &diagnostic.Status
+	if status.Phase != conditions.PhaseRunning {
+		if err := r.CloudManager.StartPacketCapture(ctx, diagnostic.Spec.GwName, capture.Host, capture.Port, int(duration.Seconds())); err != nil {
+			return ctrl.Result{}, err
+		}
+		logger.Info("started packet capture", "gateway", diagnostic.Spec.GwName, "duration", duration)
+		status.Phase = conditions.PhaseRunning
+		return ctrl.Result{RequeueAfter: duration}, nil
+	}
+
+	if remaining := time.Until(status.StartedAt.Add(duration)); remaining > 0 && !capture.Stop {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	if err := r.CloudManager.StopPacketCapture(ctx, diagnostic.Spec.GwName); err != nil {
+		return ctrl.Result{}, err
+	}
+	objectKey := fmt.Sprintf("%s%s-%s-%s.pcap", capture.Prefix, diagnostic.Spec.GwName, diagnostic.Name, status.StartedAt.UTC().Format("20060102T150405Z"))
+	url, err := r.CloudManager.UploadPacketCapture(ctx, diagnostic.Spec.GwName, capture.Bucket, objectKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("uploaded packet capture", "gateway", diagnostic.Spec.GwName, "url", url)
+	status.CaptureURL = url
+	status.Phase = conditions.PhaseSucceeded
+	return ctrl.Result{}, nil
+}
+
+// cleanup stops a packet capture still running on the gateway; its capture file is not uploaded
+func (r *AviatrixDiagnosticReconciler) cleanup(ctx context.Context, diagnostic *aviatrixv1alpha1.AviatrixDiagnostic) error {
+	if diagnostic.Spec.Action != DiagnosticActionPacketCapture || diagnostic.Status.Phase != conditions.PhaseRunning {
+		return nil
+	}
+	if err := r.CloudManager.StopPacketCapture(ctx, diagnostic.Spec.GwName); err != nil && !aviatrix.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "failed to stop packet capture", "gateway", diagnostic.Spec.GwName)
+		return err
+	}
+	log.FromContext(ctx).Info("stopped packet capture", "gateway", diagnostic.Spec.GwName)
+	return nil
+}
+
+// recordOutput keeps the output in status, truncated, and stores the full output in the report
+// store
+func (r *AviatrixDiagnosticReconciler) recordOutput(ctx context.Context, diagnostic *aviatrixv1alpha1.AviatrixDiagnostic, output string) error {
+	diagnostic.Status.Output = truncateOutput(output)
+	if r.ReportStore == nil {
+		return nil
+	}
+	key := reportstore.Key(diagnosticReports, diagnostic.Namespace, diagnostic.Name, strconv.FormatInt(diagnostic.Generation, 10), "output.txt")
+	uri, err := r.ReportStore.Put(ctx, key, []byte(output))
+	if err != nil {
+		return err
+	}
+	diagnostic.Status.OutputURI = uri
+	return nil
+}
+
+// truncateOutput keeps diagnostic output small enough to store in status
+func truncateOutput(output string) string {
+	if len(output) <= maxDiagnosticOutput {
+		return output
+	}
+	return output[:maxDiagnosticOutput] + "\n... (truncated)"
+}
+
+func (r *AviatrixDiagnosticReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixDiagnostic{}).
+		Complete(aviatrixReconciler("AviatrixDiagnostic", r))
+}
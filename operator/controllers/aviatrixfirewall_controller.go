@@ -2,21 +2,40 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/metrics"
 	"aviatrix-operator/pkg/security"
 )
 
+// FirewallFinalizer keeps an AviatrixFirewall until its rules are deleted from the gateway
+const FirewallFinalizer = "aviatrix.k8s.io/firewall"
+
+// FirewallConditionUnusedRules is set while rules have not been hit for the unused threshold
+const FirewallConditionUnusedRules = "UnusedRules"
+
+// ConditionPolicyTestFailed is set on firewalls and microsegmentation policies while a test of
+// spec.tests does not hold; the rules are not programmed until it does
+const ConditionPolicyTestFailed = "PolicyTestFailed"
+
 // AviatrixFirewallReconciler reconciles a AviatrixFirewall object
 type AviatrixFirewallReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	SecurityManager *security.Manager
 }
@@ -26,13 +45,241 @@ type AviatrixFirewallReconciler struct {
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixfirewalls/finalizers,verbs=update
 
 func (r *AviatrixFirewallReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement firewall reconciliation logic
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixFirewall instance
+	firewall := &aviatrixv1alpha1.AviatrixFirewall{}
+	if err := r.Get(ctx, req.NamespacedName, firewall); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixFirewall")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixFirewall resource not found. Ignoring since object must be deleted.")
+		metrics.DeleteDriftMetrics("AviatrixFirewall", req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, firewall); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, firewall, FirewallFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, firewall)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	// Only rules that pass their tests are rolled out
+	results, failed := security.RunTests(firewall.Spec.Tests, func(conn security.Connection) (security.Verdict, error) {
+		return security.EvaluateFirewall(&firewall.Spec, conn)
+	})
+	firewall.Status.TestResults = results
+	if !recordPolicyTests(&firewall.Status.Conditions, results, failed) {
+		logger.Info("firewall policy tests failed, not programming rules", "failed", security.FailedTests(results))
+		firewall.Status.Phase = conditions.PhaseFailed
+		firewall.Status.LastUpdated = metav1.Now()
+		conditions.MarkFailed(&firewall.Status.Conditions, firewall.Generation, conditions.ReasonExpectationNotMet, "Rules are not programmed while policy tests fail")
+		recordFailure(r.Recorder, firewall, conditions.ReasonExpectationNotMet, fmt.Errorf("rules are not programmed while policy tests fail: %s", strings.Join(security.FailedTests(results), ", ")))
+		return ctrl.Result{}, r.Status().Update(ctx, firewall)
+	}
+
+	// Program the rules unless the policy of the gateway already matches the spec. The
+	// Controller replaces the whole policy, so drifted rules are programmed again.
+	drifted, found, err := r.reconcileDrift(ctx, firewall)
+	if err != nil {
+		return r.fail(ctx, firewall, conditions.ReasonControllerError, err)
+	}
+	reason := conditions.ReasonReconciled
+	if !found || len(drifted) > 0 {
+		firewall.Status.Phase = conditions.PhaseReconciling
+		if !found {
+			firewall.Status.State = conditions.StateCreating
+		} else {
+			firewall.Status.State = conditions.StateUpdating
+		}
+		if err := r.SecurityManager.CreateFirewall(ctx, security.FirewallPolicyFromSpec(&firewall.Spec)); err != nil {
+			failure := conditions.ReasonCreateFailed
+			if found {
+				failure = conditions.ReasonUpdateFailed
+			}
+			return r.fail(ctx, firewall, failure, fmt.Errorf("failed to program firewall rules: %w", err))
+		}
+		if !found {
+			logger.Info("Programmed firewall", "gwName", firewall.Spec.GwName, "rules", len(firewall.Spec.Rules))
+			recordNormal(r.Recorder, firewall, EventReasonCreated, "Programmed %d firewall rules on gateway %s", len(firewall.Spec.Rules), firewall.Spec.GwName)
+			reason = conditions.ReasonProgrammed
+		} else {
+			logger.Info("Programmed drifted firewall", "gwName", firewall.Spec.GwName, "fields", drifted)
+			recordNormal(r.Recorder, firewall, EventReasonUpdated, "Programmed the firewall rules of gateway %s again, %s differed from the spec", firewall.Spec.GwName, strings.Join(drifted, " and "))
+			reason = conditions.ReasonUpdated
+		}
+	}
+
+	firewall.Status.Phase = conditions.PhaseReady
+	firewall.Status.State = conditions.StateActive
+	firewall.Status.LastUpdated = metav1.Now()
+	firewall.Status.RuleCount = len(firewall.Spec.Rules)
+	conditions.MarkReady(&firewall.Status.Conditions, firewall.Generation, reason, fmt.Sprintf("%d rules are programmed on gateway %s", firewall.Status.RuleCount, firewall.Spec.GwName))
+	if firewall.Spec.UsageAnalysis == nil {
+		firewall.Status.RuleUsage = nil
+		firewall.Status.UnusedRules = 0
+		firewall.Status.LastAnalyzed = nil
+		meta.RemoveStatusCondition(&firewall.Status.Conditions, FirewallConditionUnusedRules)
+		return ctrl.Result{}, r.Status().Update(ctx, firewall)
+	}
+
+	requeueAfter, err := r.analyzeUsage(ctx, firewall)
+	if err != nil {
+		logger.Error(err, "failed to analyze firewall rule usage")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// cleanup deletes the firewall policy of the gateway from the Aviatrix Controller
+func (r *AviatrixFirewallReconciler) cleanup(ctx context.Context, firewall *aviatrixv1alpha1.AviatrixFirewall) error {
+	logger := log.FromContext(ctx)
+	firewall.Status.Phase = conditions.PhaseDeleting
+	firewall.Status.State = conditions.StateDeleting
+	if err := r.SecurityManager.DeleteFirewall(ctx, firewall.Spec.GwName); err != nil && !aviatrix.IsNotFound(err) {
+		logger.Error(err, "failed to delete firewall", "transient", aviatrix.IsTransient(err))
+		firewall.Status.Phase = conditions.PhaseFailed
+		firewall.Status.State = conditions.StateError
+		firewall.Status.LastUpdated = metav1.Now()
+		conditions.MarkFailed(&firewall.Status.Conditions, firewall.Generation, conditions.ReasonDeleteFailed, err.Error())
+		recordFailure(r.Recorder, firewall, conditions.ReasonDeleteFailed, err)
+		updateFailedStatus(ctx, r.Client, firewall)
+		return err
+	}
+	logger.Info("Deleted firewall", "gwName", firewall.Spec.GwName)
+	recordNormal(r.Recorder, firewall, EventReasonDeleted, "Deleted the firewall policy of gateway %s", firewall.Spec.GwName)
+	metrics.DeleteDriftMetrics("AviatrixFirewall", firewall.Namespace, firewall.Name)
+	return nil
+}
+
+// reconcileDrift compares the spec with the firewall policy of the gateway reported by the
+// Aviatrix Controller and returns the fields that differ. found is false when the gateway has no
+// firewall policy yet, which has nothing to drift from.
+func (r *AviatrixFirewallReconciler) reconcileDrift(ctx context.Context, firewall *aviatrixv1alpha1.AviatrixFirewall) (drifted []string, found bool, err error) {
+	policy, err := r.SecurityManager.GetFirewall(ctx, firewall.Spec.GwName)
+	if aviatrix.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get firewall policy: %w", err)
+	}
+
+	desired := make([]string, 0, len(firewall.Spec.Rules))
+	for _, rule := range firewall.Spec.Rules {
+		desired = append(desired, firewallRuleKey(rule.Protocol, rule.SrcIP, rule.DstIP, rule.Port, rule.Action))
+	}
+	observed := make([]string, 0, len(policy.Rules))
+	for _, rule := range policy.Rules {
+		observed = append(observed, firewallRuleKey(rule.Protocol, rule.SrcIP, rule.DstIP, rule.Port, rule.Action))
+	}
+
+	// Rules are compared as a whole, in order, since their order decides which one matches
+	drifted = trackDrift("AviatrixFirewall", firewall, map[string]string{
+		"base_policy": firewall.Spec.BasePolicy,
+		"rules":       strings.Join(desired, ";"),
+	}, map[string]string{
+		"base_policy": policy.BasePolicy,
+		"rules":       strings.Join(observed, ";"),
+	}, &firewall.Status.DriftDetectedAt, &firewall.Status.DriftedFields)
+	setDriftDetected(&firewall.Status.Conditions, firewall.Generation, drifted, "Firewall policy matches its spec")
+	return drifted, true, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixFirewallReconciler) fail(ctx context.Context, firewall *aviatrixv1alpha1.AviatrixFirewall, reason string, err error) (ctrl.Result, error) {
+	firewall.Status.LastUpdated = metav1.Now()
+	return failReconcile(ctx, r.Client, r.Recorder, firewall, "AviatrixFirewall", reason, err, &firewall.Status.Phase, &firewall.Status.State, &firewall.Status.Conditions)
+}
+
+// firewallRuleKey identifies a firewall rule by what it matches and does
+func firewallRuleKey(protocol, srcIP, dstIP, port, action string) string {
+	return strings.ToLower(strings.Join([]string{protocol, srcIP, dstIP, port, action}, ","))
+}
+
+// analyzeUsage pulls the rule hit counters once per interval, reports the usage of every rule
+// in status and flags rules unused for longer than the threshold. It returns the delay until
+// the next pull.
+func (r *AviatrixFirewallReconciler) analyzeUsage(ctx context.Context, firewall *aviatrixv1alpha1.AviatrixFirewall) (time.Duration, error) {
+	logger := log.FromContext(ctx)
+
+	interval, unusedAfter, err := security.UsageSettings(firewall.Spec.UsageAnalysis)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	if last := firewall.Status.LastAnalyzed; last != nil && len(firewall.Status.RuleUsage) == len(firewall.Spec.Rules) {
+		if wait := last.Add(interval).Sub(now); wait > 0 {
+			return wait, r.Status().Update(ctx, firewall)
+		}
+	}
+
+	hits, err := r.SecurityManager.GetFirewallRuleHits(ctx, firewall.Spec.GwName)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rule hit counters: %w", err)
+	}
+	usage, unused := security.AnalyzeUsage(firewall.Spec.Rules, hits, firewall.Status.RuleUsage, now, unusedAfter)
+
+	firewall.Status.RuleUsage = usage
+	firewall.Status.UnusedRules = unused
+	firewall.Status.LastAnalyzed = &metav1.Time{Time: now}
+	firewall.Status.LastUpdated = metav1.Now()
+	if unused > 0 {
+		logger.Info("firewall has unused rules", "unused", unused, "days", int(unusedAfter.Hours()/24))
+		meta.SetStatusCondition(&firewall.Status.Conditions, metav1.Condition{
+			Type:    FirewallConditionUnusedRules,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditions.ReasonNoRecentHits,
+			Message: fmt.Sprintf("%d rules have not been hit for %d days", unused, int(unusedAfter.Hours()/24)),
+		})
+	} else {
+		meta.SetStatusCondition(&firewall.Status.Conditions, metav1.Condition{
+			Type:    FirewallConditionUnusedRules,
+			Status:  metav1.ConditionFalse,
+			Reason:  conditions.ReasonAllRulesHit,
+			Message: "Every rule has been hit recently",
+		})
+	}
+
+	if err := r.Status().Update(ctx, firewall); err != nil {
+		return 0, err
+	}
+	return interval, nil
+}
+
+// recordPolicyTests sets the PolicyTestFailed condition from the test results and reports
+// whether every test passed
+func recordPolicyTests(statusConditions *[]metav1.Condition, results []aviatrixv1alpha1.PolicyTestResult, failed int) bool {
+	if len(results) == 0 {
+		meta.RemoveStatusCondition(statusConditions, ConditionPolicyTestFailed)
+		return true
+	}
+	if failed > 0 {
+		meta.SetStatusCondition(statusConditions, metav1.Condition{
+			Type:    ConditionPolicyTestFailed,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditions.ReasonExpectationNotMet,
+			Message: fmt.Sprintf("%d of %d tests failed: %s", failed, len(results), strings.Join(security.FailedTests(results), ", ")),
+		})
+		return false
+	}
+	meta.SetStatusCondition(statusConditions, metav1.Condition{
+		Type:    ConditionPolicyTestFailed,
+		Status:  metav1.ConditionFalse,
+		Reason:  conditions.ReasonAllTestsPassed,
+		Message: fmt.Sprintf("%d tests passed", len(results)),
+	})
+	return true
 }
 
 func (r *AviatrixFirewallReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&aviatrixv1alpha1.AviatrixFirewall{}).
-		Complete(r)
+		Complete(aviatrixReconciler("AviatrixFirewall", r))
 }
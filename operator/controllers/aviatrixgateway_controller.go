@@ -3,29 +3,64 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/cloudevents"
+	"aviatrix-operator/pkg/features"
+	"aviatrix-operator/pkg/gatewayname"
+	"aviatrix-operator/pkg/metrics"
+	"aviatrix-operator/pkg/orphans"
+	"aviatrix-operator/pkg/runtimeconfig"
+	"aviatrix-operator/pkg/upgrade"
 )
 
+// GatewayFinalizer keeps an AviatrixGateway until its gateways are deleted from the Aviatrix
+// Controller
+const GatewayFinalizer = "aviatrix.k8s.io/gateway"
+
+// GatewayConditionDriftDetected is set while the gateway on the Aviatrix Controller differs
+// from its spec
+const GatewayConditionDriftDetected = ConditionDriftDetected
+
 // AviatrixGatewayReconciler reconciles a AviatrixGateway object
 type AviatrixGatewayReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	CloudManager   *cloud.Manager
+
+	// ManagedTagPrefix marks cloud tags owned by the operator; other tags not in the spec are kept
+	ManagedTagPrefix string
+
+	// OwnershipInstance is set with the owning resource in the ownership tags of created
+	// gateways, so the orphan scanner can match them; empty disables ownership tags
+	OwnershipInstance string
+
+	// Events publishes gateway deletion and drift to external systems; nil disables them
+	Events *cloudevents.Emitter
+
+	// Upgrades lets one gateway upgrade at a time across gateway kinds; nil ignores
+	// spec.softwareVersion and spec.imageVersion
+	Upgrades *upgrade.Coordinator
 }
 
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixgateways,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixgateways/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixgateways/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixspokegateways;aviatrixtransitgateways;aviatrixedgegateways,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -42,53 +77,233 @@ func (r *AviatrixGatewayReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		}
 		// Request object not found, could have been deleted after reconcile request.
 		logger.Info("AviatrixGateway resource not found. Ignoring since object must be deleted.")
+		metrics.DeleteDriftMetrics("AviatrixGateway", req.Namespace, req.Name)
+		if r.Upgrades != nil {
+			r.Upgrades.Release("AviatrixGateway/" + req.Namespace + "/" + req.Name)
+		}
 		return ctrl.Result{}, nil
 	}
 
-	// Update status
-	gateway.Status.Phase = "Reconciling"
-	gateway.Status.State = "Creating"
-	gateway.Status.LastUpdated = metav1.Now()
-
-	// Create gateway
-	if err := r.createGateway(ctx, gateway); err != nil {
-		logger.Error(err, "failed to create gateway")
-		gateway.Status.Phase = "Failed"
-		gateway.Status.State = "Error"
-		r.Status().Update(ctx, gateway)
+	if _, stop, err := handleVerbs(ctx, r.Client, gateway); stop {
 		return ctrl.Result{}, err
 	}
 
-	// Get gateway information
-	gatewayInfo, err := r.CloudManager.GetGateway(gateway.Spec.GwName)
+	// Only the oldest resource naming a gateway may manage it
+	owns, err := gatewayname.Check(ctx, r.Client, gateway, &gateway.Status.Conditions)
 	if err != nil {
-		logger.Error(err, "failed to get gateway information")
-		gateway.Status.Phase = "Failed"
-		gateway.Status.State = "Error"
-		r.Status().Update(ctx, gateway)
+		logger.Error(err, "failed to check gateway name")
 		return ctrl.Result{}, err
 	}
 
+	if done, err := handleFinalizer(ctx, r.Client, gateway, GatewayFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, gateway, owns)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	if !owns {
+		logger.Info("gateway name is claimed by another resource", "gwName", gateway.Spec.GwName)
+		gateway.Status.Phase = conditions.PhaseConflict
+		conditions.MarkFailed(&gateway.Status.Conditions, gateway.Generation, conditions.ReasonGatewayNameConflict, "Gateway "+gateway.Spec.GwName+" is owned by another resource")
+		return ctrl.Result{}, r.Status().Update(ctx, gateway)
+	}
+
+	// Honour the stop/start schedule before touching the gateway
+	requeueAfter, stopped, err := reconcileGatewaySchedule(ctx, r.CloudManager, r.Recorder, gateway, gateway.Spec.GwName, gateway.Spec.Schedule, &gateway.Status.GatewayScheduleStatus, &gateway.Status.Conditions)
+	if err != nil {
+		return r.fail(ctx, gateway, conditions.ReasonControllerError, fmt.Errorf("failed to apply gateway schedule: %w", err))
+	}
+	if stopped {
+		// Health checks are skipped while the gateway is intentionally stopped
+		gateway.Status.Phase = conditions.PhaseStopped
+		gateway.Status.State = conditions.StateStopped
+		gateway.Status.LastUpdated = metav1.Now()
+		return ctrl.Result{RequeueAfter: requeueAfter}, r.Status().Update(ctx, gateway)
+	}
+
+	// Update status
+	gateway.Status.Phase = conditions.PhaseReconciling
+	gateway.Status.State = conditions.StateCreating
+	gateway.Status.LastUpdated = metav1.Now()
+
+	// Create the gateway if the Aviatrix Controller does not know it yet. A gateway created
+	// before the operator stopped is picked up instead of created twice.
+	gatewayInfo, err := r.CloudManager.GetGateway(ctx, gateway.Spec.GwName)
+	if err != nil && !aviatrix.IsNotFound(err) {
+		// The gateway may exist; creating it again would fail or duplicate it
+		return r.fail(ctx, gateway, conditions.ReasonControllerError, fmt.Errorf("failed to get gateway information: %w", err))
+	}
+	if err != nil {
+		if err := r.createGateway(ctx, gateway); err != nil {
+			return r.fail(ctx, gateway, conditions.ReasonCreateFailed, fmt.Errorf("failed to create gateway: %w", err))
+		}
+
+		// Get gateway information
+		if gatewayInfo, err = r.CloudManager.GetGateway(ctx, gateway.Spec.GwName); err != nil {
+			return r.fail(ctx, gateway, conditions.ReasonControllerError, fmt.Errorf("failed to get gateway information: %w", err))
+		}
+	}
+
 	// Update status with gateway information
-	gateway.Status.Phase = "Ready"
-	gateway.Status.State = "Active"
-	if publicIP, ok := gatewayInfo["public_ip"].(string); ok {
-		gateway.Status.PublicIP = publicIP
+	gateway.Status.Phase = conditions.PhaseReady
+	gateway.Status.State = conditions.StateActive
+	gateway.Status.PublicIP = gatewayInfo.PublicIP
+	gateway.Status.PrivateIP = gatewayInfo.PrivateIP
+	gateway.Status.InstanceID = gatewayInfo.InstanceID
+	gateway.Status.SoftwareVersion = gatewayInfo.SoftwareVersion
+	gateway.Status.ImageVersion = gatewayInfo.ImageVersion
+	wasDrifting := gateway.Status.DriftDetectedAt != nil
+	r.trackDrift(gateway, gatewayInfo)
+	if !wasDrifting && gateway.Status.DriftDetectedAt != nil {
+		r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeGatewayDriftDetected, cloudevents.Data{
+			Kind:      "AviatrixGateway",
+			Namespace: gateway.Namespace,
+			Name:      gateway.Name,
+			Message:   "Gateway differs from its spec in the Aviatrix Controller",
+			Details:   map[string]string{"fields": strings.Join(gateway.Status.DriftedFields, ",")},
+		}))
+	}
+
+	// Push drifted fields back to the Aviatrix Controller when the drift policy asks for it
+	if correctDrift(gateway) {
+		if err := r.remediateDrift(ctx, gateway); err != nil {
+			setDriftCondition(gateway, conditions.ReasonRemediationFailed, err.Error())
+			return r.fail(ctx, gateway, conditions.ReasonRemediationFailed, fmt.Errorf("failed to remediate gateway drift: %w", err))
+		}
+	}
+
+	// Converge tags, keeping tags users added in the cloud
+	desiredTags := orphans.WithOwnerTags(gateway.Spec.Tags, r.OwnershipInstance, "AviatrixGateway", gateway)
+	appliedTags, err := r.CloudManager.ReconcileTags(ctx, cloud.TagResourceGateway, gateway.Spec.GwName, desiredTags, gateway.Status.AppliedTags, r.ManagedTagPrefix)
+	if err != nil {
+		return r.fail(ctx, gateway, conditions.ReasonControllerError, fmt.Errorf("failed to reconcile gateway tags: %w", err))
+	}
+	gateway.Status.AppliedTags = appliedTags
+
+	// Upgrade the gateway and its HA gateway to spec.softwareVersion and spec.imageVersion
+	upgradeAfter, err := r.reconcileUpgrade(ctx, gateway)
+	if err != nil {
+		logger.Error(err, "failed to upgrade gateway")
+		updateFailedStatus(ctx, r.Client, gateway)
+		return ctrl.Result{}, err
 	}
-	if privateIP, ok := gatewayInfo["private_ip"].(string); ok {
-		gateway.Status.PrivateIP = privateIP
+	if upgradeAfter > 0 && (requeueAfter == 0 || upgradeAfter < requeueAfter) {
+		requeueAfter = upgradeAfter
 	}
-	if instanceID, ok := gatewayInfo["instance_id"].(string); ok {
-		gateway.Status.InstanceID = instanceID
+	// Changes made in the Aviatrix UI do not trigger a reconcile; resync to notice them
+	if resync := runtimeconfig.Current().GatewayResyncInterval; requeueAfter == 0 || resync < requeueAfter {
+		requeueAfter = resync
 	}
 
+	conditions.MarkReady(&gateway.Status.Conditions, gateway.Generation, conditions.ReasonReconciled, "Gateway is up in the Aviatrix Controller")
+	if gateway.Status.State == conditions.StateUpgrading {
+		// The gateway keeps serving while its pair is upgraded one at a time
+		conditions.Set(&gateway.Status.Conditions, gateway.Generation, conditions.TypeProgressing, metav1.ConditionTrue, conditions.ReasonUpgrading, "Upgrading the gateway and its HA gateway")
+	}
 	if err := r.Status().Update(ctx, gateway); err != nil {
 		logger.Error(err, "failed to update AviatrixGateway status")
 		return ctrl.Result{}, err
 	}
 
 	logger.Info("AviatrixGateway reconciled successfully")
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixGatewayReconciler) fail(ctx context.Context, gateway *aviatrixv1alpha1.AviatrixGateway, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, gateway, "AviatrixGateway", reason, err, &gateway.Status.Phase, &gateway.Status.State, &gateway.Status.Conditions)
+}
+
+// trackDrift compares the spec with the gateway reported by the Aviatrix Controller,
+// recording when drift was first detected and how long it took to converge
+func (r *AviatrixGatewayReconciler) trackDrift(gateway *aviatrixv1alpha1.AviatrixGateway, gatewayInfo *aviatrix.GatewayInfo) {
+	drifted := trackDrift("AviatrixGateway", gateway, map[string]string{
+		"gw_size": gateway.Spec.GwSize,
+		"vpc_id":  gateway.Spec.VpcID,
+		"vpc_reg": gateway.Spec.VpcRegion,
+	}, map[string]string{
+		"gw_size": gatewayInfo.GwSize,
+		"vpc_id":  gatewayInfo.VpcID,
+		"vpc_reg": gatewayInfo.VpcRegion,
+	}, &gateway.Status.DriftDetectedAt, &gateway.Status.DriftedFields)
+
+	if len(drifted) == 0 {
+		setDriftCondition(gateway, conditions.ReasonInSync, "Gateway matches its spec")
+		return
+	}
+	setDriftCondition(gateway, conditions.ReasonDriftDetected, "Fields differ from the spec: "+strings.Join(drifted, ", "))
+}
+
+// remediateDrift applies the spec for drifted fields that can be changed in place. Convergence
+// is observed and recorded by trackDrift on a later reconcile.
+func (r *AviatrixGatewayReconciler) remediateDrift(ctx context.Context, gateway *aviatrixv1alpha1.AviatrixGateway) error {
+	logger := log.FromContext(ctx)
+
+	var corrected, kept []string
+	for _, field := range gateway.Status.DriftedFields {
+		switch field {
+		case "gw_size":
+			logger.Info("resizing drifted gateway", "gwSize", gateway.Spec.GwSize)
+			if err := r.CloudManager.ResizeGateway(ctx, gateway.Spec.GwName, gateway.Spec.GwSize); err != nil {
+				return fmt.Errorf("failed to resize gateway: %w", err)
+			}
+			corrected = append(corrected, field)
+		default:
+			logger.Info("drifted field cannot be remediated in place", "field", field)
+			kept = append(kept, field)
+		}
+	}
+	// Fields that cannot be changed in place keep reporting the drift
+	if len(corrected) > 0 && len(kept) == 0 {
+		setDriftCondition(gateway, conditions.ReasonCorrected, "Pushed the spec back for: "+strings.Join(corrected, ", "))
+		recordNormal(r.Recorder, gateway, EventReasonUpdated, "Pushed the spec back for %s", strings.Join(corrected, ", "))
+	}
+	return nil
+}
+
+// correctDrift reports whether drift of the gateway is pushed back to the Aviatrix Controller.
+// Gateways without a drift policy follow the DriftRemediation feature gate.
+func correctDrift(gateway *aviatrixv1alpha1.AviatrixGateway) bool {
+	switch gateway.Spec.DriftPolicy {
+	case aviatrixv1alpha1.DriftPolicyCorrect:
+		return true
+	case aviatrixv1alpha1.DriftPolicyDetect:
+		return false
+	}
+	return features.Enabled(features.DriftRemediation)
+}
+
+// setDriftCondition sets the DriftDetected condition, which is False only while the gateway is
+// in sync
+func setDriftCondition(gateway *aviatrixv1alpha1.AviatrixGateway, reason, message string) {
+	status := metav1.ConditionTrue
+	if reason == conditions.ReasonInSync {
+		status = metav1.ConditionFalse
+	}
+	conditions.Set(&gateway.Status.Conditions, gateway.Generation, GatewayConditionDriftDetected, status, reason, message)
+}
+
+// reconcileUpgrade advances the upgrade of the gateway pair and returns when to look again
+func (r *AviatrixGatewayReconciler) reconcileUpgrade(ctx context.Context, gateway *aviatrixv1alpha1.AviatrixGateway) (time.Duration, error) {
+	if r.Upgrades == nil {
+		return 0, nil
+	}
+
+	status, requeueAfter, err := r.Upgrades.Advance(ctx, upgrade.Key("AviatrixGateway", gateway), upgrade.Target{
+		GwName:          gateway.Spec.GwName,
+		HAEnabled:       gateway.Spec.HAEnabled,
+		SoftwareVersion: gateway.Spec.SoftwareVersion,
+		ImageVersion:    gateway.Spec.ImageVersion,
+	}, gateway.Status.Upgrade, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	gateway.Status.Upgrade = status
+	if status != nil && status.Phase == upgrade.PhaseUpgrading {
+		gateway.Status.State = conditions.StateUpgrading
+	}
+	return requeueAfter, nil
 }
 
 // createGateway creates the gateway
@@ -97,6 +312,7 @@ func (r *AviatrixGatewayReconciler) createGateway(ctx context.Context, gateway *
 
 	// Create gateway using cloud manager
 	err := r.CloudManager.CreateGateway(
+		ctx,
 		gateway.Spec.GwName,
 		gateway.Spec.CloudType,
 		gateway.Spec.AccountName,
@@ -109,13 +325,48 @@ func (r *AviatrixGatewayReconciler) createGateway(ctx context.Context, gateway *
 		return fmt.Errorf("failed to create gateway: %w", err)
 	}
 
+	// Tag the gateway right away, so it can be matched to this resource if the operator stops
+	// before recording it
+	if tags := orphans.OwnerTags(r.OwnershipInstance, "AviatrixGateway", gateway); len(tags) > 0 {
+		if err := r.CloudManager.AddResourceTags(ctx, cloud.TagResourceGateway, gateway.Spec.GwName, tags); err != nil {
+			return fmt.Errorf("failed to tag created gateway: %w", err)
+		}
+	}
+
 	logger.Info("Successfully created gateway", "gwName", gateway.Spec.GwName)
+	recordNormal(r.Recorder, gateway, EventReasonCreated, "Created gateway %s", gateway.Spec.GwName)
+	return nil
+}
+
+// cleanup deletes the HA gateway and then the gateway from the Aviatrix Controller. A resource
+// that lost the gateway name to another one leaves the gateways to their owner.
+func (r *AviatrixGatewayReconciler) cleanup(ctx context.Context, gateway *aviatrixv1alpha1.AviatrixGateway, owns bool) error {
+	if owns {
+		gateway.Status.Phase = conditions.PhaseDeleting
+		gateway.Status.State = conditions.StateDeleting
+		ha := gateway.Spec.HAEnabled || gateway.Status.HAInstanceID != ""
+		if err := deleteGateways(ctx, r.CloudManager, gateway.Spec.GwName, ha); err != nil {
+			_, err = r.fail(ctx, gateway, conditions.ReasonDeleteFailed, err)
+			return err
+		}
+		recordNormal(r.Recorder, gateway, EventReasonDeleted, "Deleted gateway %s", gateway.Spec.GwName)
+		r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeGatewayDeleted, cloudevents.Data{
+			Kind:      "AviatrixGateway",
+			Namespace: gateway.Namespace,
+			Name:      gateway.Name,
+		}))
+	}
+	metrics.DeleteDriftMetrics("AviatrixGateway", gateway.Namespace, gateway.Name)
 	return nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AviatrixGatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&aviatrixv1alpha1.AviatrixGateway{}).
-		Complete(r)
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixGateway{})
+	// Re-evaluate gateway name conflicts when another resource releases the name
+	for _, obj := range gatewayname.Objects() {
+		builder = builder.Watches(obj, gatewayname.EnqueueClaimants(mgr.GetClient(), &aviatrixv1alpha1.AviatrixGateway{}))
+	}
+	return builder.Complete(aviatrixReconciler("AviatrixGateway", r))
 }
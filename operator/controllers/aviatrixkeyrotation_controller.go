@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/naming"
+	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/rotation"
+	"aviatrix-operator/pkg/schedule"
+)
+
+// Key rotation phases
+const (
+	KeyRotationPhaseIdle        = "Idle"
+	KeyRotationPhaseOverlapping = "Overlapping"
+	KeyRotationPhaseFailed      = conditions.PhaseFailed
+)
+
+// keyRotationRetryInterval is how long a failed rotation waits before it is retried
+const keyRotationRetryInterval = time.Minute
+
+// AviatrixKeyRotationReconciler reconciles a AviatrixKeyRotation object
+type AviatrixKeyRotationReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
+	AviatrixClient *aviatrix.Client
+	NetworkManager *network.Manager
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixkeyrotations,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixkeyrotations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixkeyrotations/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch
+
+// Reconcile rotates the credentials of a connection on its schedule or when the rotate
+// annotation changes. A rotation installs the new key on both sides with the previous key
+// still accepted, stores it in the Secret, and removes the previous key once the overlap ends.
+func (r *AviatrixKeyRotationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixKeyRotation instance
+	keyRotation := &aviatrixv1alpha1.AviatrixKeyRotation{}
+	if err := r.Get(ctx, req.NamespacedName, keyRotation); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixKeyRotation")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixKeyRotation resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, keyRotation); stop {
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	status := &keyRotation.Status
+	cron, location, err := keyRotationSchedule(&keyRotation.Spec)
+	if err == nil {
+		if status.Phase == KeyRotationPhaseOverlapping {
+			if !now.Before(status.OverlapEnds.Time) {
+				err = r.completeRotation(ctx, keyRotation, now)
+			}
+		} else if reason := rotation.Due(keyRotation, cron, location, now); reason != "" {
+			err = r.startRotation(ctx, keyRotation, reason, now)
+		}
+	}
+
+	var result ctrl.Result
+	if err != nil {
+		logger.Error(err, "key rotation failed", "gateway", keyRotation.Spec.GwName, "connection", keyRotation.Spec.ConnectionName)
+		// A rotation that fails to remove the previous key keeps overlapping and retries
+		if status.Phase != KeyRotationPhaseOverlapping {
+			status.Phase = KeyRotationPhaseFailed
+		}
+		status.Message = err.Error()
+		recordFailure(r.Recorder, keyRotation, conditions.ReasonFor(err, conditions.ReasonUpdateFailed), err)
+		result.RequeueAfter = keyRotationRetryInterval
+	}
+
+	status.NextRotation = nil
+	if cron != nil && status.LastRotated != nil {
+		if next, ok := rotation.NextScheduled(cron, status.LastRotated.In(location)); ok {
+			status.NextRotation = &metav1.Time{Time: next}
+		}
+	}
+	if result.RequeueAfter == 0 {
+		switch {
+		case status.Phase == KeyRotationPhaseOverlapping:
+			result.RequeueAfter = time.Until(status.OverlapEnds.Time)
+		case status.NextRotation != nil:
+			result.RequeueAfter = time.Until(status.NextRotation.Time)
+		}
+	}
+
+	if err := r.Status().Update(ctx, keyRotation); err != nil {
+		logger.Error(err, "failed to update AviatrixKeyRotation status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixKeyRotation reconciled", "phase", status.Phase, "keyID", status.CurrentKeyID)
+	return result, nil
+}
+
+// startRotation generates a new credential, stores it and installs it on both sides of the
+// connection next to the credential in use
+func (r *AviatrixKeyRotationReconciler) startRotation(ctx context.Context, keyRotation *aviatrixv1alpha1.AviatrixKeyRotation, reason string, now time.Time) error {
+	logger := log.FromContext(ctx)
+	spec := &keyRotation.Spec
+	status := &keyRotation.Status
+
+	fail := func(err error) error {
+		record := aviatrixv1alpha1.KeyRotationRecord{
+			Reason:      reason,
+			Result:      rotation.ResultFailed,
+			Message:     err.Error(),
+			StartedAt:   metav1.NewTime(now),
+			CompletedAt: &metav1.Time{Time: now},
+		}
+		// Retries of the same failing rotation update one record instead of flooding the history
+		if last := len(status.History) - 1; last >= 0 && status.History[last].Result == rotation.ResultFailed && status.History[last].Reason == reason {
+			record.StartedAt = status.History[last].StartedAt
+			status.History[last] = record
+		} else {
+			status.History = rotation.AppendHistory(status.History, record, spec.HistoryLimit)
+		}
+		return err
+	}
+
+	secretData, err := r.secretData(ctx, keyRotation)
+	if err != nil {
+		return fail(err)
+	}
+	installed := rotation.Installed(secretData, status.CurrentKeyID)
+
+	validity := rotation.DefaultCertificateValidity
+	if spec.CertificateValidityDays > 0 {
+		validity = time.Duration(spec.CertificateValidityDays) * 24 * time.Hour
+	}
+	next, err := rotation.Generate(spec.AuthType, spec.ConnectionName, validity, now)
+	if err != nil {
+		return fail(fmt.Errorf("failed to generate credential: %w", err))
+	}
+
+	// Store the new key before it reaches the gateways so it is never lost
+	if err := r.storeCredentials(ctx, keyRotation, next, installed); err != nil {
+		return fail(err)
+	}
+	var rollback *aviatrix.ConnectionAuth
+	if !installed.IsZero() {
+		auth := rotation.Auth(spec.AuthType, installed, rotation.Credential{})
+		rollback = &auth
+	}
+	if err := r.updateConnection(ctx, spec, rotation.Auth(spec.AuthType, next, installed), rollback); err != nil {
+		if restoreErr := r.storeCredentials(ctx, keyRotation, installed, rotation.Credential{}); restoreErr != nil {
+			logger.Error(restoreErr, "failed to restore credentials Secret")
+		}
+		return fail(err)
+	}
+
+	overlap := rotation.DefaultOverlap
+	if spec.OverlapSeconds > 0 {
+		overlap = time.Duration(spec.OverlapSeconds) * time.Second
+	}
+	overlapEnds := metav1.NewTime(now.Add(overlap))
+
+	record := aviatrixv1alpha1.KeyRotationRecord{
+		KeyID:     next.ID(),
+		Reason:    reason,
+		Result:    rotation.ResultOverlapping,
+		StartedAt: metav1.NewTime(now),
+	}
+	status.Phase = KeyRotationPhaseOverlapping
+	status.Message = ""
+	status.PreviousKeyID = installed.ID()
+	status.CurrentKeyID = next.ID()
+	status.LastRotated = &metav1.Time{Time: now}
+	status.OverlapEnds = &overlapEnds
+	if installed.IsZero() {
+		// Nothing to retire when the connection had no managed key yet
+		record.Result = rotation.ResultCompleted
+		record.CompletedAt = &metav1.Time{Time: now}
+		status.Phase = KeyRotationPhaseIdle
+		status.OverlapEnds = nil
+	}
+	// Handle the current request even when another reason triggered the rotation
+	status.LastRequest = keyRotation.Annotations[rotation.RotateAnnotation]
+	status.History = rotation.AppendHistory(status.History, record, spec.HistoryLimit)
+
+	recordNormal(r.Recorder, keyRotation, EventReasonUpdated, "Rotated credentials of connection %s to key %s: %s", spec.ConnectionName, status.CurrentKeyID, reason)
+	logger.Info("rotated connection credentials", "gateway", spec.GwName, "connection", spec.ConnectionName, "reason", reason, "keyID", status.CurrentKeyID, "phase", status.Phase)
+	return nil
+}
+
+// completeRotation removes the previous credential from both sides once the overlap ended
+func (r *AviatrixKeyRotationReconciler) completeRotation(ctx context.Context, keyRotation *aviatrixv1alpha1.AviatrixKeyRotation, now time.Time) error {
+	spec := &keyRotation.Spec
+	status := &keyRotation.Status
+
+	secretData, err := r.secretData(ctx, keyRotation)
+	if err != nil {
+		return err
+	}
+	current := rotation.FromSecretData(secretData, false)
+	if current.ID() != status.CurrentKeyID {
+		return fmt.Errorf("credentials Secret %s no longer holds key %s", r.secretName(keyRotation), status.CurrentKeyID)
+	}
+
+	if err := r.updateConnection(ctx, spec, rotation.Auth(spec.AuthType, current, rotation.Credential{}), nil); err != nil {
+		return err
+	}
+	if err := r.storeCredentials(ctx, keyRotation, current, rotation.Credential{}); err != nil {
+		return err
+	}
+
+	status.Phase = KeyRotationPhaseIdle
+	status.Message = ""
+	status.PreviousKeyID = ""
+	status.OverlapEnds = nil
+	if last := len(status.History) - 1; last >= 0 && status.History[last].Result == rotation.ResultOverlapping {
+		status.History[last].Result = rotation.ResultCompleted
+		status.History[last].CompletedAt = &metav1.Time{Time: now}
+	}
+
+	log.FromContext(ctx).Info("removed previous connection credentials", "gateway", spec.GwName, "connection", spec.ConnectionName)
+	return nil
+}
+
+// updateConnection applies auth to the gateway and then the peer side. If the peer side fails,
+// rollback is applied to the sides already updated when it is set.
+func (r *AviatrixKeyRotationReconciler) updateConnection(ctx context.Context, spec *aviatrixv1alpha1.AviatrixKeyRotationSpec, auth aviatrix.ConnectionAuth, rollback *aviatrix.ConnectionAuth) error {
+	sides := []aviatrixv1alpha1.KeyRotationPeer{{GwName: spec.GwName, ConnectionName: spec.ConnectionName}}
+	if spec.Peer != nil {
+		sides = append(sides, *spec.Peer)
+	}
+
+	for i, side := range sides {
+		if err := r.NetworkManager.UpdateConnectionAuth(ctx, spec.ConnectionType, side.GwName, side.ConnectionName, auth); err != nil {
+			if rollback != nil {
+				for _, updated := range sides[:i] {
+					// Best effort: the previous key is still accepted as the standby either way
+					_ = r.NetworkManager.UpdateConnectionAuth(ctx, spec.ConnectionType, updated.GwName, updated.ConnectionName, *rollback)
+				}
+			}
+			return fmt.Errorf("failed to update connection %s on gateway %s: %w", side.ConnectionName, side.GwName, err)
+		}
+	}
+	return nil
+}
+
+// secretData returns the data of the credentials Secret, or nil before the first rotation
+func (r *AviatrixKeyRotationReconciler) secretData(ctx context.Context, keyRotation *aviatrixv1alpha1.AviatrixKeyRotation) (map[string][]byte, error) {
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: keyRotation.Namespace, Name: r.secretName(keyRotation)}, secret); err != nil {
+		if client.IgnoreNotFound(err) == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get credentials Secret: %w", err)
+	}
+	return secret.Data, nil
+}
+
+// storeCredentials writes the current and previous credentials to the Secret
+func (r *AviatrixKeyRotationReconciler) storeCredentials(ctx context.Context, keyRotation *aviatrixv1alpha1.AviatrixKeyRotation, current, previous rotation.Credential) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.secretName(keyRotation),
+			Namespace: keyRotation.Namespace,
+		},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = rotation.SecretData(current, previous)
+		return controllerutil.SetControllerReference(keyRotation, secret, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store credentials Secret: %w", err)
+	}
+	return nil
+}
+
+func (r *AviatrixKeyRotationReconciler) secretName(keyRotation *aviatrixv1alpha1.AviatrixKeyRotation) string {
+	if keyRotation.Spec.SecretName != "" {
+		return keyRotation.Spec.SecretName
+	}
+	return naming.Name(naming.RotatedCredentials, keyRotation.Name)
+}
+
+// keyRotationSchedule parses the rotation schedule; the cron is nil for on-demand rotation only
+func keyRotationSchedule(spec *aviatrixv1alpha1.AviatrixKeyRotationSpec) (*schedule.Cron, *time.Location, error) {
+	location := time.UTC
+	if spec.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, time.UTC, fmt.Errorf("invalid time zone %q: %w", spec.TimeZone, err)
+		}
+	}
+	if spec.Schedule == "" {
+		return nil, location, nil
+	}
+	cron, err := schedule.ParseCron(spec.Schedule)
+	if err != nil {
+		return nil, location, fmt.Errorf("invalid rotation schedule: %w", err)
+	}
+	return cron, location, nil
+}
+
+func (r *AviatrixKeyRotationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		// Status updates must not trigger rotations; the rotate annotation does
+		For(&aviatrixv1alpha1.AviatrixKeyRotation{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.AnnotationChangedPredicate{}))).
+		Complete(aviatrixReconciler("AviatrixKeyRotation", r))
+}
@@ -2,21 +2,38 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/security"
+	"aviatrix-operator/pkg/verbs"
 )
 
+// MicrosegPolicyFinalizer keeps an AviatrixMicrosegPolicy until its policy is deleted from the
+// Aviatrix Controller
+const MicrosegPolicyFinalizer = "aviatrix.k8s.io/microseg-policy"
+
+// MicrosegPolicyResyncInterval is how often a policy is compared with the Aviatrix Controller,
+// so changes made outside the operator are reverted
+const MicrosegPolicyResyncInterval = 5 * time.Minute
+
 // AviatrixMicrosegPolicyReconciler reconciles a AviatrixMicrosegPolicy object
 type AviatrixMicrosegPolicyReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	SecurityManager *security.Manager
 }
@@ -26,13 +43,113 @@ type AviatrixMicrosegPolicyReconciler struct {
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixmicrosegpolicies/finalizers,verbs=update
 
 func (r *AviatrixMicrosegPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement microsegmentation policy reconciliation logic
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixMicrosegPolicy instance
+	policy := &aviatrixv1alpha1.AviatrixMicrosegPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixMicrosegPolicy")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixMicrosegPolicy resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, policy); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, policy, MicrosegPolicyFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, policy)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	// Only a policy that passes its tests is rolled out
+	results, failed := security.RunTests(policy.Spec.Tests, func(conn security.Connection) (security.Verdict, error) {
+		return security.EvaluateMicroseg(&policy.Spec, conn)
+	})
+	policy.Status.TestResults = results
+	policy.Status.LastUpdated = metav1.Now()
+	if !recordPolicyTests(&policy.Status.Conditions, results, failed) {
+		logger.Info("microsegmentation policy tests failed, not programming the policy", "failed", security.FailedTests(results))
+		policy.Status.Phase = conditions.PhaseFailed
+		return ctrl.Result{}, r.Status().Update(ctx, policy)
+	}
+
+	// Create the policy unless the Aviatrix Controller knows it, otherwise correct it
+	desired := security.MicrosegPolicyFromSpec(&policy.Spec)
+	reason := conditions.ReasonProgrammed
+	var actual *aviatrix.MicrosegPolicy
+	var err error
+	if policy.Status.PolicyID != "" {
+		actual, err = r.SecurityManager.GetMicrosegPolicy(ctx, policy.Status.PolicyID)
+		if err != nil && !aviatrix.IsNotFound(err) {
+			return r.fail(ctx, policy, conditions.ReasonControllerError, fmt.Errorf("failed to get microsegmentation policy: %w", err))
+		}
+	}
+	switch {
+	case actual == nil:
+		policy.Status.Phase = conditions.PhaseReconciling
+		policy.Status.State = conditions.StateCreating
+		uuid, err := r.SecurityManager.CreateMicrosegPolicy(ctx, desired)
+		if err != nil {
+			return r.fail(ctx, policy, conditions.ReasonCreateFailed, fmt.Errorf("failed to create microsegmentation policy: %w", err))
+		}
+		policy.Status.PolicyID = uuid
+		logger.Info("Successfully created microsegmentation policy", "policyId", uuid)
+		recordNormal(r.Recorder, policy, EventReasonCreated, "Created microsegmentation policy %s", uuid)
+	case security.MicrosegPolicyChanged(desired, *actual):
+		policy.Status.Phase = conditions.PhaseReconciling
+		policy.Status.State = conditions.StateUpdating
+		desired.UUID = policy.Status.PolicyID
+		if err := r.SecurityManager.UpdateMicrosegPolicy(ctx, desired); err != nil {
+			return r.fail(ctx, policy, conditions.ReasonUpdateFailed, fmt.Errorf("failed to update microsegmentation policy: %w", err))
+		}
+		logger.Info("Updated microsegmentation policy", "policyId", policy.Status.PolicyID)
+		recordNormal(r.Recorder, policy, EventReasonUpdated, "Updated microsegmentation policy %s", policy.Status.PolicyID)
+		reason = conditions.ReasonUpdated
+	}
+
+	policy.Status.Phase = conditions.PhaseReady
+	policy.Status.State = conditions.StateActive
+	conditions.MarkReady(&policy.Status.Conditions, policy.Generation, reason, fmt.Sprintf("Policy %s is programmed as %s", policy.Spec.Name, policy.Status.PolicyID))
+	if err := r.Status().Update(ctx, policy); err != nil {
+		logger.Error(err, "failed to update AviatrixMicrosegPolicy status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixMicrosegPolicy reconciled successfully")
+	return ctrl.Result{RequeueAfter: MicrosegPolicyResyncInterval}, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixMicrosegPolicyReconciler) fail(ctx context.Context, policy *aviatrixv1alpha1.AviatrixMicrosegPolicy, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, policy, "AviatrixMicrosegPolicy", reason, err, &policy.Status.Phase, &policy.Status.State, &policy.Status.Conditions)
+}
+
+// cleanup deletes the policy from the Aviatrix Controller
+func (r *AviatrixMicrosegPolicyReconciler) cleanup(ctx context.Context, policy *aviatrixv1alpha1.AviatrixMicrosegPolicy) error {
+	// A policy that was never created has nothing to delete
+	if policy.Status.PolicyID == "" {
+		return nil
+	}
+	policy.Status.Phase = conditions.PhaseDeleting
+	policy.Status.State = conditions.StateDeleting
+	if err := r.SecurityManager.DeleteMicrosegPolicy(ctx, policy.Status.PolicyID); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, policy, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete microsegmentation policy: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Deleted microsegmentation policy", "policyId", policy.Status.PolicyID)
+	recordNormal(r.Recorder, policy, EventReasonDeleted, "Deleted microsegmentation policy %s", policy.Status.PolicyID)
+	return nil
 }
 
 func (r *AviatrixMicrosegPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Changes made on the Aviatrix Controller are picked up by the periodic resync
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&aviatrixv1alpha1.AviatrixMicrosegPolicy{}).
-		Complete(r)
+		For(&aviatrixv1alpha1.AviatrixMicrosegPolicy{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Complete(aviatrixReconciler("AviatrixMicrosegPolicy", r))
 }
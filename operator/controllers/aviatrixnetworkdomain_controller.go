@@ -2,21 +2,40 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/security"
+	"aviatrix-operator/pkg/verbs"
 )
 
+// NetworkDomainFinalizer keeps an AviatrixNetworkDomain until its domain is deleted from the
+// Aviatrix Controller
+const NetworkDomainFinalizer = "aviatrix.k8s.io/network-domain"
+
 // AviatrixNetworkDomainReconciler reconciles a AviatrixNetworkDomain object
 type AviatrixNetworkDomainReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	NetworkManager *network.Manager
 }
@@ -24,15 +43,299 @@ type AviatrixNetworkDomainReconciler struct {
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixnetworkdomains,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixnetworkdomains/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixnetworkdomains/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixsegmentationsecuritydomains,verbs=get;list;watch
 
 func (r *AviatrixNetworkDomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement network domain reconciliation logic
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	domain := &aviatrixv1alpha1.AviatrixNetworkDomain{}
+	if err := r.Get(ctx, req.NamespacedName, domain); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, domain); stop {
+		return ctrl.Result{}, err
+	}
+
+	name := domainName(domain.Spec.Name, domain)
+	if done, err := handleFinalizer(ctx, r.Client, domain, NetworkDomainFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, domain, name)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	// Create the domain if the Aviatrix Controller does not know it yet
+	domain.Status.LastUpdated = metav1.Now()
+	_, err := r.NetworkManager.GetNetworkDomain(ctx, name)
+	if aviatrix.IsNotFound(err) {
+		domain.Status.Phase = conditions.PhaseReconciling
+		domain.Status.State = conditions.StateCreating
+		err = r.NetworkManager.CreateNetworkDomain(ctx, aviatrix.NetworkDomain{
+			Name:        name,
+			Type:        domain.Spec.Type,
+			AccountName: domain.Spec.AccountName,
+			Region:      domain.Spec.Region,
+			CIDR:        domain.Spec.CIDR,
+			CloudType:   domain.Spec.CloudType,
+		})
+		if err != nil {
+			return r.fail(ctx, domain, conditions.ReasonCreateFailed, fmt.Errorf("failed to create network domain: %w", err))
+		}
+		logger.Info("Successfully created network domain", "domain", name)
+		recordNormal(r.Recorder, domain, EventReasonCreated, "Created network domain %s", name)
+	} else if err != nil {
+		return r.fail(ctx, domain, conditions.ReasonControllerError, fmt.Errorf("failed to get network domain: %w", err))
+	}
+	domain.Status.DomainID = name
+
+	connected, err := reconcileDomainConnections(ctx, r.Client, r.AviatrixClient, name, domain.Spec.ConnectedDomains, domain.Status.ConnectedDomains)
+	if err != nil {
+		return r.fail(ctx, domain, conditions.ReasonConnectionPolicyFailed, err)
+	}
+	domain.Status.ConnectedDomains = connected
+	domain.Status.Phase = conditions.PhaseReady
+	domain.Status.State = conditions.StateActive
+	conditions.MarkReady(&domain.Status.Conditions, domain.Generation, conditions.ReasonProgrammed, domainReadyMessage(name, connected))
+
+	known, err := knownDomains(ctx, r.Client)
+	var policies []aviatrix.DomainConnection
+	if err == nil {
+		policies, err = r.AviatrixClient.ListDomainConnectionPolicies(ctx)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to refresh connectivity", "domain", domain.Spec.Name)
+	} else {
+		now := metav1.Now()
+		domain.Status.Connectivity = security.Connectivity(domainName(domain.Spec.Name, domain), known, policies)
+		domain.Status.ConnectivityRefreshed = &now
+	}
+	meta.SetStatusCondition(&domain.Status.Conditions, connectivityCondition(domain.Generation, err))
+	if updateErr := r.Status().Update(ctx, domain); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{RequeueAfter: security.ConnectivityRefreshInterval}, err
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixNetworkDomainReconciler) fail(ctx context.Context, domain *aviatrixv1alpha1.AviatrixNetworkDomain, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, domain, "AviatrixNetworkDomain", reason, err, &domain.Status.Phase, &domain.Status.State, &domain.Status.Conditions)
+}
+
+// cleanup removes the connection policies of the domain, which the Controller requires before a
+// domain can be deleted, then deletes it
+func (r *AviatrixNetworkDomainReconciler) cleanup(ctx context.Context, domain *aviatrixv1alpha1.AviatrixNetworkDomain, name string) error {
+	domain.Status.Phase = conditions.PhaseDeleting
+	domain.Status.State = conditions.StateDeleting
+	if err := disconnectDomain(ctx, r.AviatrixClient, name); err != nil {
+		_, err = r.fail(ctx, domain, conditions.ReasonConnectionPolicyFailed, err)
+		return err
+	}
+	if err := r.NetworkManager.DeleteNetworkDomain(ctx, name); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, domain, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete network domain: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Deleted network domain", "domain", name)
+	recordNormal(r.Recorder, domain, EventReasonDeleted, "Deleted network domain %s", name)
+	return nil
+}
+
+// domainConnectionPolicies lists, creates and deletes connection policies between domains; the
+// Aviatrix client and the security manager both do
+type domainConnectionPolicies interface {
+	ListDomainConnectionPolicies(ctx context.Context) ([]aviatrix.DomainConnection, error)
+	CreateDomainConnectionPolicy(ctx context.Context, domain1, domain2 string) error
+	DeleteDomainConnectionPolicy(ctx context.Context, domain1, domain2 string) error
+}
+
+// reconcileDomainConnections connects a domain to the desired domains and disconnects those it
+// connected before and no longer wants. It returns the connections to record in status.
+func reconcileDomainConnections(ctx context.Context, c client.Reader, policies domainConnectionPolicies, domain string, desired, applied []string) ([]string, error) {
+	logger := log.FromContext(ctx)
+
+	existing, err := policies.ListDomainConnectionPolicies(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connection policies: %w", err)
+	}
+	claimed, err := connectionClaims(ctx, c, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	connect, disconnect := security.PlanDomainConnections(domain, desired, applied, claimed, existing)
+	for _, other := range connect {
+		if err := policies.CreateDomainConnectionPolicy(ctx, domain, other); err != nil {
+			return nil, fmt.Errorf("failed to connect %s to %s: %w", domain, other, err)
+		}
+		logger.Info("Connected network domains", "domain", domain, "other", other)
+	}
+	for _, other := range disconnect {
+		if err := deleteConnectionPolicy(ctx, policies, existing, domain, other); err != nil {
+			return nil, err
+		}
+		logger.Info("Disconnected network domains", "domain", domain, "other", other)
+	}
+
+	var connected []string
+	seen := map[string]bool{}
+	for _, other := range desired {
+		if other != "" && other != domain && !seen[other] {
+			seen[other] = true
+			connected = append(connected, other)
+		}
+	}
+	sort.Strings(connected)
+	return connected, nil
+}
+
+// disconnectDomain deletes every connection policy of a domain
+func disconnectDomain(ctx context.Context, policies domainConnectionPolicies, domain string) error {
+	existing, err := policies.ListDomainConnectionPolicies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list connection policies: %w", err)
+	}
+	for _, policy := range existing {
+		other := policy.Domain2
+		switch domain {
+		case policy.Domain1:
+		case policy.Domain2:
+			other = policy.Domain1
+		default:
+			continue
+		}
+		if err := deleteConnectionPolicy(ctx, policies, existing, domain, other); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteConnectionPolicy deletes the policy between two domains, naming them in the order the
+// Controller reports the policy
+func deleteConnectionPolicy(ctx context.Context, policies domainConnectionPolicies, existing []aviatrix.DomainConnection, domain, other string) error {
+	domain1, domain2 := domain, other
+	for _, policy := range existing {
+		if policy.Domain1 == other && policy.Domain2 == domain {
+			domain1, domain2 = other, domain
+			break
+		}
+	}
+	if err := policies.DeleteDomainConnectionPolicy(ctx, domain1, domain2); err != nil && !aviatrix.IsNotFound(err) {
+		return fmt.Errorf("failed to disconnect %s from %s: %w", domain, other, err)
+	}
+	return nil
+}
+
+// connectionClaims returns the domains whose resource lists domain in spec.connectedDomains
+func connectionClaims(ctx context.Context, c client.Reader, domain string) (map[string]bool, error) {
+	networkDomains := &aviatrixv1alpha1.AviatrixNetworkDomainList{}
+	if err := c.List(ctx, networkDomains); err != nil {
+		return nil, err
+	}
+	securityDomains := &aviatrixv1alpha1.AviatrixSegmentationSecurityDomainList{}
+	if err := c.List(ctx, securityDomains); err != nil {
+		return nil, err
+	}
+	claimed := map[string]bool{}
+	claim := func(name string, connected []string) {
+		for _, other := range connected {
+			if other == domain {
+				claimed[name] = true
+			}
+		}
+	}
+	for i := range networkDomains.Items {
+		d := &networkDomains.Items[i]
+		claim(domainName(d.Spec.Name, d), d.Spec.ConnectedDomains)
+	}
+	for i := range securityDomains.Items {
+		d := &securityDomains.Items[i]
+		claim(domainName(d.Spec.Name, d), d.Spec.ConnectedDomains)
+	}
+	return claimed, nil
+}
+
+// domainReadyMessage describes a programmed domain and its connections
+func domainReadyMessage(name string, connected []string) string {
+	if len(connected) > 0 {
+		return fmt.Sprintf("Domain %s is programmed and connected to %s", name, strings.Join(connected, ", "))
+	}
+	return fmt.Sprintf("Domain %s is programmed without connection policies", name)
+}
+
+// domainName returns the name of a domain on the Controller, which defaults to the object name
+func domainName(name string, obj client.Object) string {
+	if name != "" {
+		return name
+	}
+	return obj.GetName()
+}
+
+// knownDomains returns the names of all network and segmentation security domains in the
+// cluster, so that the connectivity matrix lists domains without any connection policy
+func knownDomains(ctx context.Context, c client.Reader) ([]string, error) {
+	networkDomains := &aviatrixv1alpha1.AviatrixNetworkDomainList{}
+	if err := c.List(ctx, networkDomains); err != nil {
+		return nil, err
+	}
+	securityDomains := &aviatrixv1alpha1.AviatrixSegmentationSecurityDomainList{}
+	if err := c.List(ctx, securityDomains); err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := range networkDomains.Items {
+		names = append(names, domainName(networkDomains.Items[i].Spec.Name, &networkDomains.Items[i]))
+	}
+	for i := range securityDomains.Items {
+		names = append(names, domainName(securityDomains.Items[i].Spec.Name, &securityDomains.Items[i]))
+	}
+	return names, nil
+}
+
+// connectivityCondition reports the outcome of refreshing the connectivity from the Controller;
+// a failed refresh keeps the previous matrix, whose age is in connectivityRefreshed
+func connectivityCondition(generation int64, err error) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               security.ConditionConnectivityRefreshed,
+		Status:             metav1.ConditionTrue,
+		Reason:             conditions.ReasonRefreshed,
+		Message:            "Connectivity read from the Aviatrix Controller",
+		ObservedGeneration: generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = conditions.ReasonRefreshFailed
+		condition.Message = err.Error()
+	}
+	return condition
+}
+
+// domainSetChanged passes the events that add, remove or rename a domain, which changes a
+// column of the connectivity matrix of every other domain
+var domainSetChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		return e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration()
+	},
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// allNetworkDomains maps a change of the domain set to all network domains
+func (r *AviatrixNetworkDomainReconciler) allNetworkDomains(ctx context.Context, _ client.Object) []reconcile.Request {
+	domains := &aviatrixv1alpha1.AviatrixNetworkDomainList{}
+	if err := r.List(ctx, domains); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(domains.Items))
+	for i := range domains.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&domains.Items[i])})
+	}
+	return requests
 }
 
 func (r *AviatrixNetworkDomainReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&aviatrixv1alpha1.AviatrixNetworkDomain{}).
-		Complete(r)
+		For(&aviatrixv1alpha1.AviatrixNetworkDomain{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Watches(&aviatrixv1alpha1.AviatrixNetworkDomain{}, handler.EnqueueRequestsFromMapFunc(r.allNetworkDomains), builder.WithPredicates(domainSetChanged)).
+		Watches(&aviatrixv1alpha1.AviatrixSegmentationSecurityDomain{}, handler.EnqueueRequestsFromMapFunc(r.allNetworkDomains), builder.WithPredicates(domainSetChanged)).
+		Complete(aviatrixReconciler("AviatrixNetworkDomain", r))
 }
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// aviatrixReconciler wraps a reconciler of Aviatrix resources of kind. Its requests to the
+// Aviatrix Controller count towards the request pool of kind, so a flood of resources of one
+// kind cannot hold up the reconciles of the others.
+//
+// A reconcile that failed because the circuit breaker on the Aviatrix Controller is open is
+// requeued when requests resume, instead of being retried with the backoff of failed
+// reconciles; the failure is already reported in the status of the resource.
+func aviatrixReconciler(kind string, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+		result, err := r.Reconcile(aviatrix.WithRequestPool(ctx, kind), req)
+		if err != nil && aviatrix.IsCircuitOpen(err) {
+			retryAfter := aviatrix.RetryAfter(err)
+			log.FromContext(ctx).Info("Aviatrix Controller unreachable, pausing reconciliation", "retryAfter", retryAfter)
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+		return result, err
+	})
+}
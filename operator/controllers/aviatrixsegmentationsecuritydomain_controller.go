@@ -2,21 +2,36 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/security"
+	"aviatrix-operator/pkg/verbs"
 )
 
+// SecurityDomainFinalizer keeps an AviatrixSegmentationSecurityDomain until its domain is
+// deleted from the Aviatrix Controller
+const SecurityDomainFinalizer = "aviatrix.k8s.io/segmentation-security-domain"
+
 // AviatrixSegmentationSecurityDomainReconciler reconciles a AviatrixSegmentationSecurityDomain object
 type AviatrixSegmentationSecurityDomainReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	SecurityManager *security.Manager
 }
@@ -24,15 +39,111 @@ type AviatrixSegmentationSecurityDomainReconciler struct {
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixsegmentationsecuritydomains,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixsegmentationsecuritydomains/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixsegmentationsecuritydomains/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixnetworkdomains,verbs=get;list;watch
 
 func (r *AviatrixSegmentationSecurityDomainReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement segmentation security domain reconciliation logic
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	domain := &aviatrixv1alpha1.AviatrixSegmentationSecurityDomain{}
+	if err := r.Get(ctx, req.NamespacedName, domain); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, domain); stop {
+		return ctrl.Result{}, err
+	}
+
+	name := domainName(domain.Spec.Name, domain)
+	if done, err := handleFinalizer(ctx, r.Client, domain, SecurityDomainFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, domain, name)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	// Create the domain if the Aviatrix Controller does not know it yet
+	domain.Status.LastUpdated = metav1.Now()
+	_, err := r.SecurityManager.GetSegmentationSecurityDomain(ctx, name)
+	if aviatrix.IsNotFound(err) {
+		domain.Status.Phase = conditions.PhaseReconciling
+		domain.Status.State = conditions.StateCreating
+		if err := r.SecurityManager.CreateSegmentationSecurityDomain(ctx, name, domain.Spec.Type); err != nil {
+			return r.fail(ctx, domain, conditions.ReasonCreateFailed, fmt.Errorf("failed to create segmentation security domain: %w", err))
+		}
+		logger.Info("Successfully created segmentation security domain", "domain", name)
+		recordNormal(r.Recorder, domain, EventReasonCreated, "Created segmentation security domain %s", name)
+	} else if err != nil {
+		return r.fail(ctx, domain, conditions.ReasonControllerError, fmt.Errorf("failed to get segmentation security domain: %w", err))
+	}
+	domain.Status.DomainID = name
+
+	connected, err := reconcileDomainConnections(ctx, r.Client, r.SecurityManager, name, domain.Spec.ConnectedDomains, domain.Status.ConnectedDomains)
+	if err != nil {
+		return r.fail(ctx, domain, conditions.ReasonConnectionPolicyFailed, err)
+	}
+	domain.Status.ConnectedDomains = connected
+	domain.Status.Phase = conditions.PhaseReady
+	domain.Status.State = conditions.StateActive
+	conditions.MarkReady(&domain.Status.Conditions, domain.Generation, conditions.ReasonProgrammed, domainReadyMessage(name, connected))
+
+	known, err := knownDomains(ctx, r.Client)
+	var policies []aviatrix.DomainConnection
+	if err == nil {
+		policies, err = r.SecurityManager.ListDomainConnectionPolicies(ctx)
+	}
+	if err != nil {
+		logger.Error(err, "Failed to refresh connectivity", "domain", domain.Spec.Name)
+	} else {
+		now := metav1.Now()
+		domain.Status.Connectivity = security.Connectivity(domainName(domain.Spec.Name, domain), known, policies)
+		domain.Status.ConnectivityRefreshed = &now
+	}
+	meta.SetStatusCondition(&domain.Status.Conditions, connectivityCondition(domain.Generation, err))
+	if updateErr := r.Status().Update(ctx, domain); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{RequeueAfter: security.ConnectivityRefreshInterval}, err
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixSegmentationSecurityDomainReconciler) fail(ctx context.Context, domain *aviatrixv1alpha1.AviatrixSegmentationSecurityDomain, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, domain, "AviatrixSegmentationSecurityDomain", reason, err, &domain.Status.Phase, &domain.Status.State, &domain.Status.Conditions)
+}
+
+// cleanup removes the connection policies of the domain, then deletes it
+func (r *AviatrixSegmentationSecurityDomainReconciler) cleanup(ctx context.Context, domain *aviatrixv1alpha1.AviatrixSegmentationSecurityDomain, name string) error {
+	domain.Status.Phase = conditions.PhaseDeleting
+	domain.Status.State = conditions.StateDeleting
+	if err := disconnectDomain(ctx, r.SecurityManager, name); err != nil {
+		_, err = r.fail(ctx, domain, conditions.ReasonConnectionPolicyFailed, err)
+		return err
+	}
+	if err := r.SecurityManager.DeleteSegmentationSecurityDomain(ctx, name); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, domain, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete segmentation security domain: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Deleted segmentation security domain", "domain", name)
+	recordNormal(r.Recorder, domain, EventReasonDeleted, "Deleted segmentation security domain %s", name)
+	return nil
+}
+
+// allSecurityDomains maps a change of the domain set to all segmentation security domains
+func (r *AviatrixSegmentationSecurityDomainReconciler) allSecurityDomains(ctx context.Context, _ client.Object) []reconcile.Request {
+	domains := &aviatrixv1alpha1.AviatrixSegmentationSecurityDomainList{}
+	if err := r.List(ctx, domains); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(domains.Items))
+	for i := range domains.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&domains.Items[i])})
+	}
+	return requests
 }
 
 func (r *AviatrixSegmentationSecurityDomainReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&aviatrixv1alpha1.AviatrixSegmentationSecurityDomain{}).
-		Complete(r)
+		For(&aviatrixv1alpha1.AviatrixSegmentationSecurityDomain{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Watches(&aviatrixv1alpha1.AviatrixSegmentationSecurityDomain{}, handler.EnqueueRequestsFromMapFunc(r.allSecurityDomains), builder.WithPredicates(domainSetChanged)).
+		Watches(&aviatrixv1alpha1.AviatrixNetworkDomain{}, handler.EnqueueRequestsFromMapFunc(r.allSecurityDomains), builder.WithPredicates(domainSetChanged)).
+		Complete(aviatrixReconciler("AviatrixSegmentationSecurityDomain", r))
 }
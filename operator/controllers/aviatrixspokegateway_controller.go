@@ -2,37 +2,319 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/gatewayname"
+	"aviatrix-operator/pkg/metrics"
+	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/upgrade"
 )
 
+// SpokeGatewayFinalizer keeps an AviatrixSpokeGateway until its gateways are deleted from the
+// Aviatrix Controller
+const SpokeGatewayFinalizer = "aviatrix.k8s.io/spoke-gateway"
+
+// SpokeConditionAdvertisementApplied reports whether spec.advertisement is applied on the spoke
+const SpokeConditionAdvertisementApplied = "AdvertisementApplied"
+
 // AviatrixSpokeGatewayReconciler reconciles a AviatrixSpokeGateway object
 type AviatrixSpokeGatewayReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	CloudManager   *cloud.Manager
+	NetworkManager *network.Manager
+
+	// Upgrades lets one gateway upgrade at a time across gateway kinds; nil ignores
+	// spec.softwareVersion and spec.imageVersion
+	Upgrades *upgrade.Coordinator
 }
 
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixspokegateways,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixspokegateways/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixspokegateways/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixgateways;aviatrixtransitgateways;aviatrixedgegateways,verbs=get;list;watch
 
 func (r *AviatrixSpokeGatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement spoke gateway reconciliation logic
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixSpokeGateway instance
+	spoke := &aviatrixv1alpha1.AviatrixSpokeGateway{}
+	if err := r.Get(ctx, req.NamespacedName, spoke); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixSpokeGateway")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixSpokeGateway resource not found. Ignoring since object must be deleted.")
+		metrics.DeleteDriftMetrics("AviatrixSpokeGateway", req.Namespace, req.Name)
+		if r.Upgrades != nil {
+			r.Upgrades.Release("AviatrixSpokeGateway/" + req.Namespace + "/" + req.Name)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, spoke); stop {
+		return ctrl.Result{}, err
+	}
+
+	// Only the oldest resource naming a gateway may manage it
+	owns, err := gatewayname.Check(ctx, r.Client, spoke, &spoke.Status.Conditions)
+	if err != nil {
+		logger.Error(err, "failed to check gateway name")
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, spoke, SpokeGatewayFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, spoke, owns)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	if !owns {
+		logger.Info("gateway name is claimed by another resource", "gwName", spoke.Spec.GwName)
+		spoke.Status.Phase = conditions.PhaseConflict
+		conditions.MarkFailed(&spoke.Status.Conditions, spoke.Generation, conditions.ReasonGatewayNameConflict, "Gateway "+spoke.Spec.GwName+" is owned by another resource")
+		return ctrl.Result{}, r.Status().Update(ctx, spoke)
+	}
+
+	// Create the spoke gateway if the Aviatrix Controller does not know it yet. The schedule,
+	// attachment, advertisement and upgrade below act on the gateway, so they wait for it.
+	info, err := r.CloudManager.GetGateway(ctx, spoke.Spec.GwName)
+	if aviatrix.IsNotFound(err) {
+		spoke.Status.Phase = conditions.PhaseReconciling
+		spoke.Status.State = conditions.StateCreating
+		if err := r.NetworkManager.CreateSpokeGateway(ctx, spoke.Spec.GwName, spoke.Spec.CloudType, spoke.Spec.AccountName, spoke.Spec.VpcID, spoke.Spec.VpcRegion, spoke.Spec.GwSize, spoke.Spec.Subnet); err != nil {
+			return r.fail(ctx, spoke, conditions.ReasonCreateFailed, fmt.Errorf("failed to create spoke gateway: %w", err))
+		}
+		logger.Info("Successfully created spoke gateway", "gwName", spoke.Spec.GwName)
+		recordNormal(r.Recorder, spoke, EventReasonCreated, "Created spoke gateway %s", spoke.Spec.GwName)
+		info, err = r.CloudManager.GetGateway(ctx, spoke.Spec.GwName)
+	}
+	if err != nil {
+		return r.fail(ctx, spoke, conditions.ReasonControllerError, fmt.Errorf("failed to get spoke gateway: %w", err))
+	}
+	spoke.Status.PublicIP = info.PublicIP
+	spoke.Status.PrivateIP = info.PrivateIP
+	spoke.Status.InstanceID = info.InstanceID
+
+	// Honour the stop/start schedule before touching the spoke gateway
+	scheduleAfter, stopped, err := reconcileGatewaySchedule(ctx, r.CloudManager, r.Recorder, spoke, spoke.Spec.GwName, spoke.Spec.Schedule, &spoke.Status.GatewayScheduleStatus, &spoke.Status.Conditions)
+	if err != nil {
+		logger.Error(err, "failed to apply spoke gateway schedule")
+		spoke.Status.LastUpdated = metav1.Now()
+		conditions.MarkFailed(&spoke.Status.Conditions, spoke.Generation, conditions.ReasonControllerError, err.Error())
+		recordFailure(r.Recorder, spoke, conditions.ReasonControllerError, err)
+		updateFailedStatus(ctx, r.Client, spoke)
+		return ctrl.Result{}, err
+	}
+	if stopped {
+		spoke.Status.Phase = conditions.PhaseStopped
+		spoke.Status.State = conditions.StateStopped
+		spoke.Status.LastUpdated = metav1.Now()
+		return ctrl.Result{RequeueAfter: scheduleAfter}, r.Status().Update(ctx, spoke)
+	}
+
+	if err := r.reconcileAttachment(ctx, spoke); err != nil {
+		return r.fail(ctx, spoke, conditions.ReasonAttachFailed, err)
+	}
+
+	r.trackDrift(spoke, info)
+
+	// Customize the routes advertised over the transit attachment
+	if err := r.reconcileAdvertisement(ctx, spoke); err != nil {
+		logger.Error(err, "failed to reconcile spoke advertisement")
+		spoke.Status.LastUpdated = metav1.Now()
+		reason := conditions.ReasonFor(err, conditions.ReasonControllerError)
+		conditions.MarkFailed(&spoke.Status.Conditions, spoke.Generation, reason, err.Error())
+		recordFailure(r.Recorder, spoke, reason, err)
+		updateFailedStatus(ctx, r.Client, spoke)
+		return ctrl.Result{}, err
+	}
+
+	// Upgrade the spoke and its HA gateway to spec.softwareVersion and spec.imageVersion
+	requeueAfter, err := r.reconcileUpgrade(ctx, spoke)
+	if err != nil {
+		logger.Error(err, "failed to upgrade spoke gateway")
+		spoke.Status.LastUpdated = metav1.Now()
+		updateFailedStatus(ctx, r.Client, spoke)
+		return ctrl.Result{}, err
+	}
+
+	spoke.Status.Phase = conditions.PhaseReady
+	spoke.Status.State = conditions.StateActive
+	spoke.Status.LastUpdated = metav1.Now()
+	conditions.MarkReady(&spoke.Status.Conditions, spoke.Generation, conditions.ReasonReconciled, "Spoke gateway matches its spec")
+	if spoke.Status.Upgrade != nil && spoke.Status.Upgrade.Phase == upgrade.PhaseUpgrading {
+		conditions.Set(&spoke.Status.Conditions, spoke.Generation, conditions.TypeProgressing, metav1.ConditionTrue, conditions.ReasonUpgrading, "Upgrading the spoke gateway and its HA gateway")
+	}
+	if err := r.Status().Update(ctx, spoke); err != nil {
+		logger.Error(err, "failed to update AviatrixSpokeGateway status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixSpokeGateway reconciled successfully")
+	if scheduleAfter > 0 && (requeueAfter == 0 || scheduleAfter < requeueAfter) {
+		requeueAfter = scheduleAfter
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixSpokeGatewayReconciler) fail(ctx context.Context, spoke *aviatrixv1alpha1.AviatrixSpokeGateway, reason string, err error) (ctrl.Result, error) {
+	spoke.Status.LastUpdated = metav1.Now()
+	return failReconcile(ctx, r.Client, r.Recorder, spoke, "AviatrixSpokeGateway", reason, err, &spoke.Status.Phase, &spoke.Status.State, &spoke.Status.Conditions)
+}
+
+// reconcileAttachment attaches the spoke gateway to spec.transitGw, detaching it first from the
+// transit gateway it was attached to before
+func (r *AviatrixSpokeGatewayReconciler) reconcileAttachment(ctx context.Context, spoke *aviatrixv1alpha1.AviatrixSpokeGateway) error {
+	if spoke.Status.AttachedTransitGw == spoke.Spec.TransitGw {
+		return nil
+	}
+	if err := r.detach(ctx, spoke); err != nil {
+		return err
+	}
+	if spoke.Spec.TransitGw == "" {
+		return nil
+	}
+
+	spoke.Status.State = conditions.StateAttaching
+	if err := r.NetworkManager.AttachSpokeToTransit(ctx, spoke.Spec.GwName, spoke.Spec.TransitGw); err != nil {
+		return fmt.Errorf("failed to attach spoke gateway to transit gateway %s: %w", spoke.Spec.TransitGw, err)
+	}
+	spoke.Status.AttachedTransitGw = spoke.Spec.TransitGw
+	log.FromContext(ctx).Info("Attached spoke gateway", "gwName", spoke.Spec.GwName, "transitGw", spoke.Spec.TransitGw)
+	recordNormal(r.Recorder, spoke, EventReasonUpdated, "Attached spoke gateway %s to transit gateway %s", spoke.Spec.GwName, spoke.Spec.TransitGw)
+	return nil
+}
+
+// detach detaches the spoke gateway from the transit gateway it is attached to, if any. An
+// attachment already gone is skipped.
+func (r *AviatrixSpokeGatewayReconciler) detach(ctx context.Context, spoke *aviatrixv1alpha1.AviatrixSpokeGateway) error {
+	transitGw := spoke.Status.AttachedTransitGw
+	if transitGw == "" {
+		return nil
+	}
+	spoke.Status.State = conditions.StateDetaching
+	if err := r.NetworkManager.DetachSpokeFromTransit(ctx, spoke.Spec.GwName, transitGw); err != nil && !aviatrix.IsNotFound(err) {
+		return fmt.Errorf("failed to detach spoke gateway from transit gateway %s: %w", transitGw, err)
+	}
+	spoke.Status.AttachedTransitGw = ""
+	log.FromContext(ctx).Info("Detached spoke gateway", "gwName", spoke.Spec.GwName, "transitGw", transitGw)
+	recordNormal(r.Recorder, spoke, EventReasonUpdated, "Detached spoke gateway %s from transit gateway %s", spoke.Spec.GwName, transitGw)
+	return nil
+}
+
+// trackDrift compares the spec with the spoke gateway reported by the Aviatrix Controller
+func (r *AviatrixSpokeGatewayReconciler) trackDrift(spoke *aviatrixv1alpha1.AviatrixSpokeGateway, info *aviatrix.GatewayInfo) {
+	drifted := trackDrift("AviatrixSpokeGateway", spoke, map[string]string{
+		"gw_size": spoke.Spec.GwSize,
+		"vpc_id":  spoke.Spec.VpcID,
+		"vpc_reg": spoke.Spec.VpcRegion,
+	}, map[string]string{
+		"gw_size": info.GwSize,
+		"vpc_id":  info.VpcID,
+		"vpc_reg": info.VpcRegion,
+	}, &spoke.Status.DriftDetectedAt, &spoke.Status.DriftedFields)
+	setDriftDetected(&spoke.Status.Conditions, spoke.Generation, drifted, "Spoke gateway matches its spec")
+}
+
+// reconcileUpgrade advances the upgrade of the spoke gateway pair and returns when to look again
+func (r *AviatrixSpokeGatewayReconciler) reconcileUpgrade(ctx context.Context, spoke *aviatrixv1alpha1.AviatrixSpokeGateway) (time.Duration, error) {
+	if r.Upgrades == nil {
+		return 0, nil
+	}
+
+	status, requeueAfter, err := r.Upgrades.Advance(ctx, upgrade.Key("AviatrixSpokeGateway", spoke), upgrade.Target{
+		GwName:          spoke.Spec.GwName,
+		HAEnabled:       spoke.Spec.HAEnabled,
+		SoftwareVersion: spoke.Spec.SoftwareVersion,
+		ImageVersion:    spoke.Spec.ImageVersion,
+	}, spoke.Status.Upgrade, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	spoke.Status.Upgrade = status
+	return requeueAfter, nil
+}
+
+// reconcileAdvertisement applies the included and excluded CIDRs, attached subnets and AS path
+// prepending of spec.advertisement, restoring the VPC CIDR advertisement when it is removed
+func (r *AviatrixSpokeGatewayReconciler) reconcileAdvertisement(ctx context.Context, spoke *aviatrixv1alpha1.AviatrixSpokeGateway) error {
+	var desired network.Advertisement
+	if spec := spoke.Spec.Advertisement; spec != nil {
+		var err error
+		desired, err = network.PlanAdvertisement(spec.IncludedCidrs, spec.AttachedSubnets, spec.ExcludedCidrs, spec.PrependASPath)
+		if err != nil {
+			conditions.Set(&spoke.Status.Conditions, spoke.Generation, SpokeConditionAdvertisementApplied, metav1.ConditionFalse, conditions.ReasonInvalidAdvertisement, err.Error())
+			return conditions.Errorf(conditions.ReasonInvalidAdvertisement, "invalid advertisement: %w", err)
+		}
+	}
+
+	applied := network.Advertisement{
+		Cidrs:         spoke.Status.AdvertisedCidrs,
+		PrependASPath: spoke.Status.PrependASPath,
+	}
+	if err := r.NetworkManager.ReconcileSpokeAdvertisement(ctx, spoke.Spec.GwName, desired, applied); err != nil {
+		conditions.Set(&spoke.Status.Conditions, spoke.Generation, SpokeConditionAdvertisementApplied, metav1.ConditionFalse, conditions.ReasonControllerError, err.Error())
+		return err
+	}
+
+	spoke.Status.AdvertisedCidrs = desired.Cidrs
+	spoke.Status.PrependASPath = desired.PrependASPath
+	conditions.Set(&spoke.Status.Conditions, spoke.Generation, SpokeConditionAdvertisementApplied, metav1.ConditionTrue, conditions.ReasonApplied, "Spoke advertisement matches the spec")
+	return nil
+}
+
+// cleanup deletes the HA spoke gateway and then the spoke gateway from the Aviatrix Controller.
+// A resource that lost the gateway name to another one leaves the gateways to their owner.
+func (r *AviatrixSpokeGatewayReconciler) cleanup(ctx context.Context, spoke *aviatrixv1alpha1.AviatrixSpokeGateway, owns bool) error {
+	metrics.DeleteDriftMetrics("AviatrixSpokeGateway", spoke.Namespace, spoke.Name)
+	if !owns {
+		return nil
+	}
+	spoke.Status.Phase = conditions.PhaseDeleting
+	// The Controller refuses to delete a spoke gateway that is still attached
+	err := r.detach(ctx, spoke)
+	if err == nil {
+		spoke.Status.State = conditions.StateDeleting
+		ha := spoke.Spec.HAEnabled || spoke.Status.HAInstanceID != ""
+		err = deleteGateways(ctx, r.CloudManager, spoke.Spec.GwName, ha)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed to delete spoke gateway", "transient", aviatrix.IsTransient(err))
+		spoke.Status.Phase = conditions.PhaseFailed
+		spoke.Status.State = conditions.StateError
+		spoke.Status.LastUpdated = metav1.Now()
+		conditions.MarkFailed(&spoke.Status.Conditions, spoke.Generation, conditions.ReasonDeleteFailed, err.Error())
+		recordFailure(r.Recorder, spoke, conditions.ReasonDeleteFailed, err)
+		updateFailedStatus(ctx, r.Client, spoke)
+		return err
+	}
+	recordNormal(r.Recorder, spoke, EventReasonDeleted, "Deleted spoke gateway %s", spoke.Spec.GwName)
+	return nil
 }
 
 func (r *AviatrixSpokeGatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&aviatrixv1alpha1.AviatrixSpokeGateway{}).
-		Complete(r)
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixSpokeGateway{})
+	// Re-evaluate gateway name conflicts when another resource releases the name
+	for _, obj := range gatewayname.Objects() {
+		builder = builder.Watches(obj, gatewayname.EnqueueClaimants(mgr.GetClient(), &aviatrixv1alpha1.AviatrixSpokeGateway{}))
+	}
+	return builder.Complete(aviatrixReconciler("AviatrixSpokeGateway", r))
 }
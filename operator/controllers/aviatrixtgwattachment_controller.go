@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/verbs"
+)
+
+// TgwAttachmentFinalizer keeps an AviatrixTgwAttachment until its VPC is detached on the
+// Aviatrix Controller
+const TgwAttachmentFinalizer = "aviatrix.k8s.io/tgw-attachment"
+
+// TgwAttachmentResyncInterval is how often an attachment is compared with the Aviatrix
+// Controller, so changes made outside the operator are reverted
+const TgwAttachmentResyncInterval = 5 * time.Minute
+
+// AviatrixTgwAttachmentReconciler reconciles a AviatrixTgwAttachment object
+type AviatrixTgwAttachmentReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
+	AviatrixClient *aviatrix.Client
+	NetworkManager *network.Manager
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtgwattachments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtgwattachments/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtgwattachments/finalizers,verbs=update
+
+// Reconcile attaches the VPC of the spec to the AWS Transit Gateway, keeps its route
+// propagation options in line with the spec and detaches it with the resource
+func (r *AviatrixTgwAttachmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixTgwAttachment instance
+	attachment := &aviatrixv1alpha1.AviatrixTgwAttachment{}
+	if err := r.Get(ctx, req.NamespacedName, attachment); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixTgwAttachment")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixTgwAttachment resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, attachment); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, attachment, TgwAttachmentFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, attachment)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	attachment.Status.LastUpdated = metav1.Now()
+	spec := attachment.Spec
+	desired := aviatrix.TgwAttachment{
+		TgwName:                      spec.TgwName,
+		Region:                       spec.Region,
+		VpcAccountName:               spec.VpcAccountName,
+		VpcID:                        spec.VpcID,
+		NetworkDomain:                spec.NetworkDomain,
+		Subnets:                      spec.Subnets,
+		RouteTables:                  spec.RouteTables,
+		CustomizedRoutes:             spec.CustomizedRoutes,
+		CustomizedRouteAdvertisement: spec.CustomizedRouteAdvertisement,
+		DisableLocalRoutePropagation: spec.DisableLocalRoutePropagation,
+	}
+
+	// Retrying cannot fix an invalid spec; the next change of it is reconciled again
+	if _, err := network.PlanTgwAttachment(desired, desired); err != nil {
+		r.fail(ctx, attachment, conditions.ReasonInvalidSpec, err)
+		return ctrl.Result{}, nil
+	}
+
+	// An attachment to another TGW or of another VPC is detached first
+	status := &attachment.Status
+	if status.AttachedVpcID != "" && (status.AttachedTgwName != spec.TgwName || status.AttachedVpcID != spec.VpcID) {
+		if err := r.NetworkManager.DetachVpcFromTgw(ctx, status.AttachedTgwName, status.AttachedVpcID); err != nil && !aviatrix.IsNotFound(err) {
+			return r.fail(ctx, attachment, conditions.ReasonDetachFailed, fmt.Errorf("failed to detach replaced attachment: %w", err))
+		}
+		logger.Info("Detached replaced VPC", "tgw", status.AttachedTgwName, "vpc", status.AttachedVpcID)
+		recordNormal(r.Recorder, attachment, EventReasonDeleted, "Detached %s from %s, which the spec no longer names", status.AttachedVpcID, status.AttachedTgwName)
+		status.AttachedTgwName, status.AttachedVpcID = "", ""
+	}
+
+	// Attach the VPC if the Aviatrix Controller does not know the attachment yet, otherwise
+	// correct what differs from the spec
+	reason, message := conditions.ReasonAttached, fmt.Sprintf("%s is attached to %s in network domain %s", spec.VpcID, spec.TgwName, spec.NetworkDomain)
+	actual, err := r.NetworkManager.GetTgwAttachment(ctx, spec.TgwName, spec.VpcID)
+	if aviatrix.IsNotFound(err) {
+		attachment.Status.Phase = conditions.PhaseReconciling
+		attachment.Status.State = conditions.StateAttaching
+		if err := r.NetworkManager.AttachVpcToTgw(ctx, desired); err != nil {
+			return r.fail(ctx, attachment, conditions.ReasonAttachFailed, fmt.Errorf("failed to attach VPC: %w", err))
+		}
+		logger.Info("Successfully attached VPC", "tgw", spec.TgwName, "vpc", spec.VpcID)
+		recordNormal(r.Recorder, attachment, EventReasonCreated, "Attached %s to %s", spec.VpcID, spec.TgwName)
+	} else if err != nil {
+		return r.fail(ctx, attachment, conditions.ReasonControllerError, fmt.Errorf("failed to get TGW attachment: %w", err))
+	} else {
+		plan, err := network.PlanTgwAttachment(desired, *actual)
+		if err != nil {
+			r.fail(ctx, attachment, conditions.ReasonInvalidSpec, err)
+			return ctrl.Result{}, nil
+		}
+		if !plan.Empty() {
+			attachment.Status.Phase = conditions.PhaseReconciling
+			attachment.Status.State = conditions.StateUpdating
+			if err := r.NetworkManager.ApplyTgwAttachment(ctx, desired, plan); err != nil {
+				return r.fail(ctx, attachment, conditions.ReasonUpdateFailed, err)
+			}
+			logger.Info("Corrected TGW attachment", "fields", plan.Fields, "reattached", plan.Reattach)
+			recordNormal(r.Recorder, attachment, EventReasonUpdated, "Corrected %s", strings.Join(plan.Fields, ", "))
+			reason = conditions.ReasonCorrected
+			message = fmt.Sprintf("%s; corrected %s", message, strings.Join(plan.Fields, ", "))
+		}
+	}
+	status.AttachedTgwName, status.AttachedVpcID = spec.TgwName, spec.VpcID
+
+	attachment.Status.Phase = conditions.PhaseReady
+	attachment.Status.State = conditions.StateAttached
+	conditions.MarkReady(&attachment.Status.Conditions, attachment.Generation, reason, message)
+	if err := r.Status().Update(ctx, attachment); err != nil {
+		logger.Error(err, "failed to update AviatrixTgwAttachment status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixTgwAttachment reconciled successfully")
+	return ctrl.Result{RequeueAfter: TgwAttachmentResyncInterval}, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixTgwAttachmentReconciler) fail(ctx context.Context, attachment *aviatrixv1alpha1.AviatrixTgwAttachment, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, attachment, "AviatrixTgwAttachment", reason, err, &attachment.Status.Phase, &attachment.Status.State, &attachment.Status.Conditions)
+}
+
+// cleanup detaches the VPC on the Aviatrix Controller
+func (r *AviatrixTgwAttachmentReconciler) cleanup(ctx context.Context, attachment *aviatrixv1alpha1.AviatrixTgwAttachment) error {
+	// The attachment that was made may differ from a spec edited since
+	tgwName, vpcID := attachment.Status.AttachedTgwName, attachment.Status.AttachedVpcID
+	if vpcID == "" {
+		tgwName, vpcID = attachment.Spec.TgwName, attachment.Spec.VpcID
+	}
+	attachment.Status.Phase = conditions.PhaseDeleting
+	attachment.Status.State = conditions.StateDetaching
+	if err := r.NetworkManager.DetachVpcFromTgw(ctx, tgwName, vpcID); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, attachment, conditions.ReasonDetachFailed, fmt.Errorf("failed to detach VPC: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Detached VPC", "tgw", tgwName, "vpc", vpcID)
+	recordNormal(r.Recorder, attachment, EventReasonDeleted, "Detached %s from %s", vpcID, tgwName)
+	return nil
+}
+
+func (r *AviatrixTgwAttachmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Changes made on the Aviatrix Controller are picked up by the periodic resync
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixTgwAttachment{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Complete(aviatrixReconciler("AviatrixTgwAttachment", r))
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/trafficpolicy"
+	"aviatrix-operator/pkg/verbs"
+)
+
+// Traffic policy phases
+const (
+	TrafficPolicyPhaseCompiled = "Compiled"
+	TrafficPolicyPhaseFailed   = conditions.PhaseFailed
+)
+
+// AviatrixTrafficPolicyReconciler reconciles a AviatrixTrafficPolicy object
+type AviatrixTrafficPolicyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtrafficpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtrafficpolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtrafficpolicies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixfirewalls;aviatrixmicrosegpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile compiles the intent of a traffic policy into NetworkPolicies, an AviatrixFirewall
+// and AviatrixMicrosegPolicies owned by it, removes artifacts the intent no longer produces
+// and lists the compiled artifacts in status
+func (r *AviatrixTrafficPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixTrafficPolicy instance
+	policy := &aviatrixv1alpha1.AviatrixTrafficPolicy{}
+	if err := r.Get(ctx, req.NamespacedName, policy); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixTrafficPolicy")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixTrafficPolicy resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, policy); stop {
+		return ctrl.Result{}, err
+	}
+
+	// An invalid intent keeps the previously compiled artifacts, so traffic is not opened or
+	// cut off by a typo
+	compiled, err := trafficpolicy.Compile(policy)
+	if err != nil {
+		logger.Info("invalid traffic policy", "reason", err.Error())
+		policy.Status.Phase = TrafficPolicyPhaseFailed
+		policy.Status.Message = err.Error()
+		conditions.Set(&policy.Status.Conditions, policy.Generation, trafficpolicy.ConditionCompiled, metav1.ConditionFalse, conditions.ReasonInvalidIntent, err.Error())
+		conditions.MarkFailed(&policy.Status.Conditions, policy.Generation, conditions.ReasonInvalidIntent, err.Error())
+		recordFailure(r.Recorder, policy, conditions.ReasonInvalidIntent, err)
+		return ctrl.Result{}, r.Status().Update(ctx, policy)
+	}
+
+	if err := r.applyArtifacts(ctx, policy, compiled); err != nil {
+		logger.Error(err, "failed to apply compiled artifacts")
+		policy.Status.Phase = TrafficPolicyPhaseFailed
+		policy.Status.Message = err.Error()
+		conditions.Set(&policy.Status.Conditions, policy.Generation, trafficpolicy.ConditionCompiled, metav1.ConditionFalse, conditions.ReasonApplyFailed, err.Error())
+		conditions.MarkFailed(&policy.Status.Conditions, policy.Generation, conditions.ReasonApplyFailed, err.Error())
+		recordFailure(r.Recorder, policy, conditions.ReasonApplyFailed, err)
+		if updateErr := r.Status().Update(ctx, policy); updateErr != nil {
+			logger.Error(updateErr, "failed to update status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	policy.Status.Phase = TrafficPolicyPhaseCompiled
+	policy.Status.Message = ""
+	policy.Status.ObservedGeneration = policy.Generation
+	policy.Status.Artifacts = compiled.Artifacts
+	policy.Status.LastCompiled = &now
+	message := fmt.Sprintf("Compiled into %d resources", len(compiled.Artifacts))
+	conditions.Set(&policy.Status.Conditions, policy.Generation, trafficpolicy.ConditionCompiled, metav1.ConditionTrue, conditions.ReasonArtifactsApplied, message)
+	conditions.MarkReady(&policy.Status.Conditions, policy.Generation, conditions.ReasonArtifactsApplied, message)
+	logger.Info("compiled traffic policy", "artifacts", len(compiled.Artifacts))
+	return ctrl.Result{}, r.Status().Update(ctx, policy)
+}
+
+// applyArtifacts creates or updates the compiled resources and deletes those of earlier
+// compilations that are no longer produced
+func (r *AviatrixTrafficPolicyReconciler) applyArtifacts(ctx context.Context, policy *aviatrixv1alpha1.AviatrixTrafficPolicy, compiled *trafficpolicy.Compiled) error {
+	keep := map[string]bool{}
+
+	for i := range compiled.NetworkPolicies {
+		desired := &compiled.NetworkPolicies[i]
+		networkPolicy := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: desired.Namespace}}
+		if err := r.apply(ctx, policy, networkPolicy, func() { networkPolicy.Spec = desired.Spec }); err != nil {
+			return err
+		}
+		keep[trafficpolicy.KindNetworkPolicy+"/"+desired.Name] = true
+	}
+
+	if desired := compiled.Firewall; desired != nil {
+		firewall := &aviatrixv1alpha1.AviatrixFirewall{ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: desired.Namespace}}
+		if err := r.apply(ctx, policy, firewall, func() { firewall.Spec = desired.Spec }); err != nil {
+			return err
+		}
+		keep[trafficpolicy.KindFirewall+"/"+desired.Name] = true
+	}
+
+	for i := range compiled.MicrosegPolicies {
+		desired := &compiled.MicrosegPolicies[i]
+		microseg := &aviatrixv1alpha1.AviatrixMicrosegPolicy{ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: desired.Namespace}}
+		if err := r.apply(ctx, policy, microseg, func() { microseg.Spec = desired.Spec }); err != nil {
+			return err
+		}
+		keep[trafficpolicy.KindMicrosegPolicy+"/"+desired.Name] = true
+	}
+
+	stale := []struct {
+		kind string
+		list client.ObjectList
+	}{
+		{trafficpolicy.KindNetworkPolicy, &networkingv1.NetworkPolicyList{}},
+		{trafficpolicy.KindFirewall, &aviatrixv1alpha1.AviatrixFirewallList{}},
+		{trafficpolicy.KindMicrosegPolicy, &aviatrixv1alpha1.AviatrixMicrosegPolicyList{}},
+	}
+	for _, s := range stale {
+		if err := r.List(ctx, s.list, client.InNamespace(policy.Namespace), client.MatchingLabels(trafficpolicy.Labels(policy))); err != nil {
+			return fmt.Errorf("failed to list %s resources: %w", s.kind, err)
+		}
+		objects, err := meta.ExtractList(s.list)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			obj, ok := object.(client.Object)
+			if !ok || keep[s.kind+"/"+obj.GetName()] || !metav1.IsControlledBy(obj, policy) {
+				continue
+			}
+			if err := r.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete %s %s: %w", s.kind, obj.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// apply creates or updates a compiled resource owned by the policy
+func (r *AviatrixTrafficPolicyReconciler) apply(ctx context.Context, policy *aviatrixv1alpha1.AviatrixTrafficPolicy, obj client.Object, mutate func()) error {
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		for key, value := range trafficpolicy.Labels(policy) {
+			labels[key] = value
+		}
+		obj.SetLabels(labels)
+		mutate()
+		return controllerutil.SetControllerReference(policy, obj, r.Scheme)
+	}); err != nil {
+		return fmt.Errorf("failed to apply %T %s: %w", obj, obj.GetName(), err)
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AviatrixTrafficPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixTrafficPolicy{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		// Hand edits of the artifacts are reverted to the compiled intent
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&aviatrixv1alpha1.AviatrixFirewall{}).
+		Owns(&aviatrixv1alpha1.AviatrixMicrosegPolicy{}).
+		Complete(r)
+}
@@ -2,37 +2,343 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/features"
+	"aviatrix-operator/pkg/gatewayname"
+	"aviatrix-operator/pkg/metrics"
+	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/upgrade"
+	"aviatrix-operator/pkg/verbs"
+)
+
+// TransitGatewayFinalizer keeps an AviatrixTransitGateway until its gateways are deleted from
+// the Aviatrix Controller
+const TransitGatewayFinalizer = "aviatrix.k8s.io/transit-gateway"
+
+// TransitResyncInterval is how often a transit gateway is compared with the Aviatrix Controller
+// to detect drift; changes made in the Aviatrix UI trigger no reconcile
+const TransitResyncInterval = 5 * time.Minute
+
+// Conditions of transit gateways
+const (
+	// TransitConditionHAReady reports whether the HA gateway exists; it is absent without HA
+	TransitConditionHAReady = "HAReady"
+	// TransitConditionFeaturesApplied reports whether BGP, segmentation and FireNet match the spec
+	TransitConditionFeaturesApplied = "FeaturesApplied"
+	// TransitConditionDrifted is set while fields that cannot be toggled differ from the spec
+	TransitConditionDrifted = "Drifted"
 )
 
 // AviatrixTransitGatewayReconciler reconciles a AviatrixTransitGateway object
 type AviatrixTransitGatewayReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	CloudManager   *cloud.Manager
+	NetworkManager *network.Manager
 }
 
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtransitgateways,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtransitgateways/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtransitgateways/finalizers,verbs=update
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixgateways;aviatrixspokegateways;aviatrixedgegateways,verbs=get;list;watch
 
 func (r *AviatrixTransitGatewayReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement transit gateway reconciliation logic
-	return ctrl.Result{}, nil
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixTransitGateway instance
+	transit := &aviatrixv1alpha1.AviatrixTransitGateway{}
+	if err := r.Get(ctx, req.NamespacedName, transit); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixTransitGateway")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixTransitGateway resource not found. Ignoring since object must be deleted.")
+		metrics.DeleteDriftMetrics("AviatrixTransitGateway", req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, transit); stop {
+		return ctrl.Result{}, err
+	}
+
+	// Only the oldest resource naming a gateway may manage it
+	owns, err := gatewayname.Check(ctx, r.Client, transit, &transit.Status.Conditions)
+	if err != nil {
+		logger.Error(err, "failed to check gateway name")
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, transit, TransitGatewayFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, transit, owns)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	if !owns {
+		logger.Info("gateway name is claimed by another resource", "gwName", transit.Spec.GwName)
+		transit.Status.Phase = conditions.PhaseConflict
+		conditions.MarkFailed(&transit.Status.Conditions, transit.Generation, conditions.ReasonGatewayNameConflict, "Gateway "+transit.Spec.GwName+" is owned by another resource")
+		return ctrl.Result{}, r.Status().Update(ctx, transit)
+	}
+
+	transit.Status.LastUpdated = metav1.Now()
+
+	// Honour the stop/start schedule before touching the transit gateway
+	scheduleAfter, stopped, err := reconcileGatewaySchedule(ctx, r.CloudManager, r.Recorder, transit, transit.Spec.GwName, transit.Spec.Schedule, &transit.Status.GatewayScheduleStatus, &transit.Status.Conditions)
+	if err != nil {
+		return r.fail(ctx, transit, conditions.ReasonControllerError, fmt.Errorf("failed to apply transit gateway schedule: %w", err))
+	}
+	if stopped {
+		transit.Status.Phase = conditions.PhaseStopped
+		transit.Status.State = conditions.StateStopped
+		return ctrl.Result{RequeueAfter: scheduleAfter}, r.Status().Update(ctx, transit)
+	}
+
+	// Create the transit gateway if the Aviatrix Controller does not know it yet
+	info, err := r.CloudManager.GetGateway(ctx, transit.Spec.GwName)
+	if aviatrix.IsNotFound(err) {
+		transit.Status.Phase = conditions.PhaseReconciling
+		transit.Status.State = conditions.StateCreating
+		if err := r.NetworkManager.CreateTransitGateway(ctx, transitGatewayConfig(transit)); err != nil {
+			return r.fail(ctx, transit, conditions.ReasonCreateFailed, fmt.Errorf("failed to create transit gateway: %w", err))
+		}
+		logger.Info("Successfully created transit gateway", "gwName", transit.Spec.GwName)
+		recordNormal(r.Recorder, transit, EventReasonCreated, "Created transit gateway %s", transit.Spec.GwName)
+		info, err = r.CloudManager.GetGateway(ctx, transit.Spec.GwName)
+	}
+	if err != nil {
+		return r.fail(ctx, transit, conditions.ReasonControllerError, fmt.Errorf("failed to get transit gateway: %w", err))
+	}
+	transit.Status.PublicIP = info.PublicIP
+	transit.Status.PrivateIP = info.PrivateIP
+	transit.Status.InstanceID = info.InstanceID
+	transit.Status.SoftwareVersion = info.SoftwareVersion
+
+	if err := r.reconcileHA(ctx, transit); err != nil {
+		return r.fail(ctx, transit, conditions.ReasonHAFailed, err)
+	}
+
+	if err := r.reconcileFeatures(ctx, transit, info); err != nil {
+		return r.fail(ctx, transit, conditions.ReasonFeaturesFailed, err)
+	}
+
+	r.trackDrift(transit, info)
+
+	// Resize a drifted transit gateway when the DriftRemediation feature gate allows it
+	if features.Enabled(features.DriftRemediation) && containsString(transit.Status.DriftedFields, "gw_size") {
+		logger.Info("resizing drifted transit gateway", "gwSize", transit.Spec.GwSize)
+		if err := r.CloudManager.ResizeGateway(ctx, transit.Spec.GwName, transit.Spec.GwSize); err != nil {
+			return r.fail(ctx, transit, conditions.ReasonRemediationFailed, fmt.Errorf("failed to resize transit gateway: %w", err))
+		}
+	}
+
+	transit.Status.Phase = conditions.PhaseReady
+	transit.Status.State = conditions.StateActive
+	conditions.MarkReady(&transit.Status.Conditions, transit.Generation, conditions.ReasonReconciled, "Transit gateway is up in the Aviatrix Controller")
+	if err := r.Status().Update(ctx, transit); err != nil {
+		logger.Error(err, "failed to update AviatrixTransitGateway status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixTransitGateway reconciled successfully")
+	if scheduleAfter > 0 && scheduleAfter < TransitResyncInterval {
+		return ctrl.Result{RequeueAfter: scheduleAfter}, nil
+	}
+	return ctrl.Result{RequeueAfter: TransitResyncInterval}, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixTransitGatewayReconciler) fail(ctx context.Context, transit *aviatrixv1alpha1.AviatrixTransitGateway, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, transit, "AviatrixTransitGateway", reason, err, &transit.Status.Phase, &transit.Status.State, &transit.Status.Conditions)
+}
+
+// transitGatewayConfig returns the launch parameters of the transit gateway in the spec
+func transitGatewayConfig(transit *aviatrixv1alpha1.AviatrixTransitGateway) aviatrix.TransitGatewayConfig {
+	return aviatrix.TransitGatewayConfig{
+		GwName:              transit.Spec.GwName,
+		CloudType:           transit.Spec.CloudType,
+		AccountName:         transit.Spec.AccountName,
+		VpcID:               transit.Spec.VpcID,
+		VpcRegion:           transit.Spec.VpcRegion,
+		GwSize:              transit.Spec.GwSize,
+		Subnet:              transit.Spec.Subnet,
+		EnableNat:           transit.Spec.EnableNat,
+		EnableEncryptVolume: transit.Spec.EnableEncryptVolume,
+		VolumeSize:          transit.Spec.VolumeSize,
+		EnableActiveMesh:    transit.Spec.EnableActiveMesh,
+	}
+}
+
+// reconcileHA creates the HA gateway when spec.haEnabled is set and deletes it when it is unset
+func (r *AviatrixTransitGatewayReconciler) reconcileHA(ctx context.Context, transit *aviatrixv1alpha1.AviatrixTransitGateway) error {
+	logger := log.FromContext(ctx)
+	haName := transit.Spec.GwName + upgrade.HASuffix
+
+	haInfo, err := r.CloudManager.GetGateway(ctx, haName)
+	if err != nil && !aviatrix.IsNotFound(err) {
+		return fmt.Errorf("failed to get HA gateway: %w", err)
+	}
+	exists := err == nil
+
+	if !transit.Spec.HAEnabled {
+		if exists {
+			if err := r.CloudManager.DeleteGateway(ctx, haName); err != nil && !aviatrix.IsNotFound(err) {
+				return fmt.Errorf("failed to delete HA gateway: %w", err)
+			}
+			logger.Info("Deleted HA transit gateway", "gwName", haName)
+			recordNormal(r.Recorder, transit, EventReasonDeleted, "Deleted HA transit gateway %s", haName)
+		}
+		transit.Status.HAPublicIP = ""
+		transit.Status.HAPrivateIP = ""
+		transit.Status.HAInstanceID = ""
+		meta.RemoveStatusCondition(&transit.Status.Conditions, TransitConditionHAReady)
+		return nil
+	}
+
+	if !exists {
+		if transit.Spec.HASubnet == "" {
+			err := fmt.Errorf("spec.haSubnet is required when spec.haEnabled is set")
+			r.setHACondition(transit, metav1.ConditionFalse, conditions.ReasonInvalidSpec, err.Error())
+			return err
+		}
+		gwSize := transit.Spec.HAGwSize
+		if gwSize == "" {
+			gwSize = transit.Spec.GwSize
+		}
+		if err := r.NetworkManager.EnableTransitHA(ctx, transit.Spec.GwName, transit.Spec.HASubnet, transit.Spec.HAZone, gwSize); err != nil {
+			r.setHACondition(transit, metav1.ConditionFalse, conditions.ReasonControllerError, err.Error())
+			return fmt.Errorf("failed to enable transit HA: %w", err)
+		}
+		logger.Info("Created HA transit gateway", "gwName", haName)
+		recordNormal(r.Recorder, transit, EventReasonCreated, "Created HA transit gateway %s", haName)
+		if haInfo, err = r.CloudManager.GetGateway(ctx, haName); err != nil {
+			r.setHACondition(transit, metav1.ConditionFalse, conditions.ReasonControllerError, err.Error())
+			return fmt.Errorf("failed to get HA gateway: %w", err)
+		}
+	}
+
+	transit.Status.HAPublicIP = haInfo.PublicIP
+	transit.Status.HAPrivateIP = haInfo.PrivateIP
+	transit.Status.HAInstanceID = haInfo.InstanceID
+	r.setHACondition(transit, metav1.ConditionTrue, conditions.ReasonHAGatewayUp, fmt.Sprintf("HA gateway %s is up", haName))
+	return nil
+}
+
+func (r *AviatrixTransitGatewayReconciler) setHACondition(transit *aviatrixv1alpha1.AviatrixTransitGateway, status metav1.ConditionStatus, reason, message string) {
+	conditions.Set(&transit.Status.Conditions, transit.Generation, TransitConditionHAReady, status, reason, message)
+}
+
+// reconcileFeatures toggles BGP, segmentation and FireNet on the transit gateway to match the
+// spec. Features toggled in the Aviatrix UI are toggled back.
+func (r *AviatrixTransitGatewayReconciler) reconcileFeatures(ctx context.Context, transit *aviatrixv1alpha1.AviatrixTransitGateway, info *aviatrix.GatewayInfo) error {
+	logger := log.FromContext(ctx)
+
+	desired := []struct {
+		feature aviatrix.TransitFeature
+		enabled bool
+	}{
+		{aviatrix.TransitFeatureBgp, transit.Spec.EnableTransitBgp},
+		{aviatrix.TransitFeatureSegmentation, transit.Spec.EnableSegmentation},
+		{aviatrix.TransitFeatureFireNet, transit.Spec.EnableFireNet},
+	}
+	for _, d := range desired {
+		if info.Enabled(d.feature) == d.enabled {
+			continue
+		}
+		if err := r.NetworkManager.SetTransitFeature(ctx, transit.Spec.GwName, d.feature, d.enabled); err != nil {
+			conditions.Set(&transit.Status.Conditions, transit.Generation, TransitConditionFeaturesApplied, metav1.ConditionFalse, conditions.ReasonControllerError, err.Error())
+			return err
+		}
+		logger.Info("Toggled transit gateway feature", "gwName", transit.Spec.GwName, "feature", d.feature, "enabled", d.enabled)
+		recordNormal(r.Recorder, transit, EventReasonUpdated, "Set %s to %t", d.feature, d.enabled)
+	}
+
+	conditions.Set(&transit.Status.Conditions, transit.Generation, TransitConditionFeaturesApplied, metav1.ConditionTrue, conditions.ReasonApplied, "BGP, segmentation and FireNet match the spec")
+	return nil
+}
+
+// trackDrift compares the spec with the transit gateway reported by the Aviatrix Controller,
+// recording when drift was first detected and how long it took to converge
+func (r *AviatrixTransitGatewayReconciler) trackDrift(transit *aviatrixv1alpha1.AviatrixTransitGateway, info *aviatrix.GatewayInfo) {
+	drifted := trackDrift("AviatrixTransitGateway", transit, map[string]string{
+		"gw_size": transit.Spec.GwSize,
+		"vpc_id":  transit.Spec.VpcID,
+		"vpc_reg": transit.Spec.VpcRegion,
+	}, map[string]string{
+		"gw_size": info.GwSize,
+		"vpc_id":  info.VpcID,
+		"vpc_reg": info.VpcRegion,
+	}, &transit.Status.DriftDetectedAt, &transit.Status.DriftedFields)
+
+	condition := metav1.Condition{
+		Type:               TransitConditionDrifted,
+		Status:             metav1.ConditionFalse,
+		Reason:             conditions.ReasonInSync,
+		Message:            "Transit gateway matches its spec",
+		ObservedGeneration: transit.Generation,
+	}
+	if len(drifted) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = conditions.ReasonDriftDetected
+		condition.Message = "Fields differ from the spec: " + strings.Join(drifted, ", ")
+	}
+	meta.SetStatusCondition(&transit.Status.Conditions, condition)
+}
+
+// cleanup deletes the HA gateway and then the transit gateway from the Aviatrix Controller. A
+// resource that lost the gateway name to another one leaves the gateways to their owner.
+func (r *AviatrixTransitGatewayReconciler) cleanup(ctx context.Context, transit *aviatrixv1alpha1.AviatrixTransitGateway, owns bool) error {
+	if owns {
+		transit.Status.Phase = conditions.PhaseDeleting
+		transit.Status.State = conditions.StateDeleting
+		ha := transit.Spec.HAEnabled || transit.Status.HAInstanceID != ""
+		if err := deleteGateways(ctx, r.CloudManager, transit.Spec.GwName, ha); err != nil {
+			_, err = r.fail(ctx, transit, conditions.ReasonDeleteFailed, err)
+			return err
+		}
+		recordNormal(r.Recorder, transit, EventReasonDeleted, "Deleted transit gateway %s", transit.Spec.GwName)
+	}
+	metrics.DeleteDriftMetrics("AviatrixTransitGateway", transit.Namespace, transit.Name)
+	return nil
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 func (r *AviatrixTransitGatewayReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&aviatrixv1alpha1.AviatrixTransitGateway{}).
-		Complete(r)
+	// Status updates do not bump the generation; drift is picked up by the periodic resync
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixTransitGateway{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed)))
+	// Re-evaluate gateway name conflicts when another resource releases the name
+	for _, obj := range gatewayname.Objects() {
+		b = b.Watches(obj, gatewayname.EnqueueClaimants(mgr.GetClient(), &aviatrixv1alpha1.AviatrixTransitGateway{}))
+	}
+	return b.Complete(aviatrixReconciler("AviatrixTransitGateway", r))
 }
@@ -0,0 +1,241 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/upgrade"
+	"aviatrix-operator/pkg/verbs"
+)
+
+// TransitPeeringFinalizer keeps an AviatrixTransitGatewayPeering until its peering is deleted on
+// the Aviatrix Controller
+const TransitPeeringFinalizer = "aviatrix.k8s.io/transit-peering"
+
+// TransitPeeringResyncInterval is how often a peering is compared with the Aviatrix Controller
+// and its tunnels are checked
+const TransitPeeringResyncInterval = 5 * time.Minute
+
+// TransitPeeringTunnelRecheckInterval is how often the tunnels of a peering are checked while
+// some are down
+const TransitPeeringTunnelRecheckInterval = 30 * time.Second
+
+// TransitPeeringConditionTunnelsHealthy reports whether every tunnel of the peering is up
+const TransitPeeringConditionTunnelsHealthy = "TunnelsHealthy"
+
+// AviatrixTransitGatewayPeeringReconciler reconciles a AviatrixTransitGatewayPeering object
+type AviatrixTransitGatewayPeeringReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
+	AviatrixClient *aviatrix.Client
+	NetworkManager *network.Manager
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtransitgatewaypeerings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtransitgatewaypeerings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixtransitgatewaypeerings/finalizers,verbs=update
+
+// Reconcile peers the transit gateways of the spec, keeps their excluded CIDRs and AS path
+// prepending in line with the spec, reports the health of their tunnels and deletes the
+// peering with the resource
+func (r *AviatrixTransitGatewayPeeringReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixTransitGatewayPeering instance
+	peering := &aviatrixv1alpha1.AviatrixTransitGatewayPeering{}
+	if err := r.Get(ctx, req.NamespacedName, peering); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixTransitGatewayPeering")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixTransitGatewayPeering resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, peering); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, peering, TransitPeeringFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, peering)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	peering.Status.LastUpdated = metav1.Now()
+	spec := peering.Spec
+	desired := aviatrix.TransitPeering{
+		SourceGwName:             spec.SourceGwName,
+		DestinationGwName:        spec.DestinationGwName,
+		SourceExcludedCidrs:      spec.SourceExcludedCidrs,
+		DestinationExcludedCidrs: spec.DestinationExcludedCidrs,
+		SourcePrependASPath:      spec.SourcePrependAsPath,
+		DestinationPrependASPath: spec.DestinationPrependAsPath,
+	}
+
+	// Retrying cannot fix an invalid spec; the next change of it is reconciled again
+	if _, err := network.PlanTransitPeering(desired, desired); err != nil {
+		r.fail(ctx, peering, conditions.ReasonInvalidSpec, err)
+		return ctrl.Result{}, nil
+	}
+
+	// A peering of other gateways is deleted first
+	status := &peering.Status
+	if status.PeeredSourceGwName != "" && (status.PeeredSourceGwName != spec.SourceGwName || status.PeeredDestinationGwName != spec.DestinationGwName) {
+		if err := r.NetworkManager.DeleteTransitGatewayPeering(ctx, status.PeeredSourceGwName, status.PeeredDestinationGwName); err != nil && !aviatrix.IsNotFound(err) {
+			return r.fail(ctx, peering, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete replaced peering: %w", err))
+		}
+		logger.Info("Deleted replaced transit peering", "source", status.PeeredSourceGwName, "destination", status.PeeredDestinationGwName)
+		recordNormal(r.Recorder, peering, EventReasonDeleted, "Deleted the replaced peering of %s with %s", status.PeeredSourceGwName, status.PeeredDestinationGwName)
+		status.PeeredSourceGwName, status.PeeredDestinationGwName = "", ""
+	}
+
+	// Peer the gateways if the Aviatrix Controller does not know the peering yet, otherwise
+	// correct what differs from the spec
+	reason, message := conditions.ReasonPeered, fmt.Sprintf("%s is peered with %s", spec.SourceGwName, spec.DestinationGwName)
+	actual, err := r.NetworkManager.GetTransitGatewayPeering(ctx, spec.SourceGwName, spec.DestinationGwName)
+	if aviatrix.IsNotFound(err) {
+		peering.Status.Phase = conditions.PhaseReconciling
+		peering.Status.State = conditions.StateCreating
+		if err := r.NetworkManager.CreateTransitGatewayPeering(ctx, desired); err != nil {
+			return r.fail(ctx, peering, conditions.ReasonCreateFailed, fmt.Errorf("failed to create transit peering: %w", err))
+		}
+		logger.Info("Successfully created transit peering", "source", spec.SourceGwName, "destination", spec.DestinationGwName)
+		recordNormal(r.Recorder, peering, EventReasonCreated, "Peered %s with %s", spec.SourceGwName, spec.DestinationGwName)
+	} else if err != nil {
+		return r.fail(ctx, peering, conditions.ReasonControllerError, fmt.Errorf("failed to get transit peering: %w", err))
+	} else {
+		plan, err := network.PlanTransitPeering(desired, *actual)
+		if err != nil {
+			r.fail(ctx, peering, conditions.ReasonInvalidSpec, err)
+			return ctrl.Result{}, nil
+		}
+		if !plan.Empty() {
+			peering.Status.Phase = conditions.PhaseReconciling
+			peering.Status.State = conditions.StateUpdating
+			if err := r.NetworkManager.ApplyTransitPeering(ctx, desired, plan); err != nil {
+				return r.fail(ctx, peering, conditions.ReasonUpdateFailed, err)
+			}
+			logger.Info("Corrected transit peering", "fields", plan.Fields)
+			recordNormal(r.Recorder, peering, EventReasonUpdated, "Corrected %s", strings.Join(plan.Fields, ", "))
+			reason = conditions.ReasonCorrected
+			message = fmt.Sprintf("%s; corrected %s", message, strings.Join(plan.Fields, ", "))
+		}
+	}
+	status.PeeredSourceGwName, status.PeeredDestinationGwName = spec.SourceGwName, spec.DestinationGwName
+
+	healthy, err := r.updateTunnels(ctx, peering)
+	if err != nil {
+		return r.fail(ctx, peering, conditions.ReasonControllerError, fmt.Errorf("failed to get tunnel status: %w", err))
+	}
+
+	// Tunnels that are down leave the peering in place but degraded
+	peering.Status.Phase = conditions.PhaseReady
+	peering.Status.State = conditions.StateActive
+	conditions.MarkReady(&peering.Status.Conditions, peering.Generation, reason, message)
+	if !healthy {
+		peering.Status.Phase = conditions.PhaseDegraded
+		conditions.Set(&peering.Status.Conditions, peering.Generation, conditions.TypeDegraded, metav1.ConditionTrue, conditions.ReasonTunnelsDown, fmt.Sprintf("%d of %d tunnels up", status.TunnelsUp, status.TunnelsTotal))
+	}
+	if err := r.Status().Update(ctx, peering); err != nil {
+		logger.Error(err, "failed to update AviatrixTransitGatewayPeering status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixTransitGatewayPeering reconciled successfully", "tunnelsUp", status.TunnelsUp, "tunnels", status.TunnelsTotal)
+	if !healthy {
+		return ctrl.Result{RequeueAfter: TransitPeeringTunnelRecheckInterval}, nil
+	}
+	return ctrl.Result{RequeueAfter: TransitPeeringResyncInterval}, nil
+}
+
+// updateTunnels records the tunnels of the source gateway to the destination gateway and its
+// HA gateway, and reports whether there are some and all are up
+func (r *AviatrixTransitGatewayPeeringReconciler) updateTunnels(ctx context.Context, peering *aviatrixv1alpha1.AviatrixTransitGatewayPeering) (bool, error) {
+	tunnels, err := r.NetworkManager.GetTunnelStatus(ctx, peering.Spec.SourceGwName)
+	if err != nil {
+		return false, err
+	}
+
+	status := &peering.Status
+	destination := peering.Spec.DestinationGwName
+	status.Tunnels = nil
+	status.TunnelsUp = 0
+	for _, tunnel := range tunnels {
+		peer, _ := tunnel["peer_name"].(string)
+		if peer != destination && peer != destination+upgrade.HASuffix {
+			continue
+		}
+		state, _ := tunnel["status"].(string)
+		tunnelType, _ := tunnel["type"].(string)
+		status.Tunnels = append(status.Tunnels, aviatrixv1alpha1.TunnelStatus{Peer: peer, State: state, Type: tunnelType})
+		if strings.EqualFold(state, "up") {
+			status.TunnelsUp++
+		}
+	}
+	status.TunnelsTotal = int32(len(status.Tunnels))
+
+	healthy := status.TunnelsTotal > 0 && status.TunnelsUp == status.TunnelsTotal
+	condition := metav1.Condition{
+		Type:               TransitPeeringConditionTunnelsHealthy,
+		Status:             metav1.ConditionTrue,
+		Reason:             conditions.ReasonTunnelsUp,
+		Message:            fmt.Sprintf("%d of %d tunnels up", status.TunnelsUp, status.TunnelsTotal),
+		ObservedGeneration: peering.Generation,
+	}
+	if !healthy {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = conditions.ReasonTunnelsDown
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+	return healthy, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixTransitGatewayPeeringReconciler) fail(ctx context.Context, peering *aviatrixv1alpha1.AviatrixTransitGatewayPeering, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, peering, "AviatrixTransitGatewayPeering", reason, err, &peering.Status.Phase, &peering.Status.State, &peering.Status.Conditions)
+}
+
+// cleanup deletes the peering on the Aviatrix Controller
+func (r *AviatrixTransitGatewayPeeringReconciler) cleanup(ctx context.Context, peering *aviatrixv1alpha1.AviatrixTransitGatewayPeering) error {
+	// The peering that was made may differ from a spec edited since
+	source, destination := peering.Status.PeeredSourceGwName, peering.Status.PeeredDestinationGwName
+	if source == "" {
+		source, destination = peering.Spec.SourceGwName, peering.Spec.DestinationGwName
+	}
+	peering.Status.Phase = conditions.PhaseDeleting
+	peering.Status.State = conditions.StateDeleting
+	if err := r.NetworkManager.DeleteTransitGatewayPeering(ctx, source, destination); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, peering, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete transit peering: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Deleted transit peering", "source", source, "destination", destination)
+	recordNormal(r.Recorder, peering, EventReasonDeleted, "Deleted the peering of %s with %s", source, destination)
+	return nil
+}
+
+func (r *AviatrixTransitGatewayPeeringReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Changes made on the Aviatrix Controller and tunnel state are picked up by the periodic
+	// resync
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixTransitGatewayPeering{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Complete(aviatrixReconciler("AviatrixTransitGatewayPeering", r))
+}
@@ -2,23 +2,40 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
 	"aviatrix-operator/pkg/aviatrix"
 	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/metrics"
+	"aviatrix-operator/pkg/orphans"
 )
 
+// VpcFinalizer keeps an AviatrixVpc until its VPC is deleted from the Aviatrix Controller
+const VpcFinalizer = "aviatrix.k8s.io/vpc"
+
 // AviatrixVpcReconciler reconciles a AviatrixVpc object
 type AviatrixVpcReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
 	AviatrixClient *aviatrix.Client
 	CloudManager   *cloud.Manager
+
+	// ManagedTagPrefix marks cloud tags owned by the operator; other tags not in the spec are kept
+	ManagedTagPrefix string
+
+	// OwnershipInstance is set with the owning resource in the ownership tags of created VPCs,
+	// so the orphan scanner can match them; empty disables ownership tags
+	OwnershipInstance string
 }
 
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixvpcs,verbs=get;list;watch;create;update;patch;delete
@@ -26,13 +43,114 @@ type AviatrixVpcReconciler struct {
 //+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixvpcs/finalizers,verbs=update
 
 func (r *AviatrixVpcReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
-	// TODO: Implement VPC reconciliation logic
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixVpc instance
+	vpc := &aviatrixv1alpha1.AviatrixVpc{}
+	if err := r.Get(ctx, req.NamespacedName, vpc); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixVpc")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixVpc resource not found. Ignoring since object must be deleted.")
+		metrics.DeleteDriftMetrics("AviatrixVpc", req.Namespace, req.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, vpc); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, vpc, VpcFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, vpc)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	vpc.Status.LastUpdated = metav1.Now()
+
+	// Create the VPC if the Aviatrix Controller does not know it yet
+	vpcInfo, err := r.CloudManager.GetVpc(ctx, vpc.Spec.Name)
+	if err != nil && !aviatrix.IsNotFound(err) {
+		// The VPC may exist; creating it again would fail or duplicate it
+		return r.fail(ctx, vpc, conditions.ReasonControllerError, fmt.Errorf("failed to get VPC information: %w", err))
+	}
+	if err != nil {
+		logger.Info("VPC not found, creating", "name", vpc.Spec.Name)
+		if err := r.CloudManager.CreateVpc(ctx, vpc.Spec.Name, vpc.Spec.CloudType, vpc.Spec.AccountName, vpc.Spec.Region, vpc.Spec.CIDR); err != nil {
+			return r.fail(ctx, vpc, conditions.ReasonCreateFailed, fmt.Errorf("failed to create VPC: %w", err))
+		}
+		recordNormal(r.Recorder, vpc, EventReasonCreated, "Created VPC %s with CIDR %s", vpc.Spec.Name, vpc.Spec.CIDR)
+		// Tag the VPC right away, so it can be matched to this resource if the operator stops
+		// before recording it
+		if tags := orphans.OwnerTags(r.OwnershipInstance, "AviatrixVpc", vpc); len(tags) > 0 {
+			if err := r.CloudManager.AddResourceTags(ctx, cloud.TagResourceVpc, vpc.Spec.Name, tags); err != nil {
+				return r.fail(ctx, vpc, conditions.ReasonControllerError, fmt.Errorf("failed to tag created VPC: %w", err))
+			}
+		}
+		if vpcInfo, err = r.CloudManager.GetVpc(ctx, vpc.Spec.Name); err != nil {
+			return r.fail(ctx, vpc, conditions.ReasonControllerError, fmt.Errorf("failed to get VPC information: %w", err))
+		}
+	}
+	if vpcInfo.VpcID != "" {
+		vpc.Status.VpcID = vpcInfo.VpcID
+	}
+
+	// The CIDR, region and account cannot change once the VPC exists, so drift is only reported
+	drifted := trackDrift("AviatrixVpc", vpc, map[string]string{
+		"cidr":         vpc.Spec.CIDR,
+		"region":       vpc.Spec.Region,
+		"account_name": vpc.Spec.AccountName,
+	}, map[string]string{
+		"cidr":         vpcInfo.CIDR,
+		"region":       vpcInfo.Region,
+		"account_name": vpcInfo.AccountName,
+	}, &vpc.Status.DriftDetectedAt, &vpc.Status.DriftedFields)
+	setDriftDetected(&vpc.Status.Conditions, vpc.Generation, drifted, "VPC matches its spec")
+
+	// Converge tags, keeping tags users added in the cloud
+	desiredTags := orphans.WithOwnerTags(vpc.Spec.Tags, r.OwnershipInstance, "AviatrixVpc", vpc)
+	appliedTags, err := r.CloudManager.ReconcileTags(ctx, cloud.TagResourceVpc, vpc.Spec.Name, desiredTags, vpc.Status.AppliedTags, r.ManagedTagPrefix)
+	if err != nil {
+		return r.fail(ctx, vpc, conditions.ReasonControllerError, fmt.Errorf("failed to reconcile VPC tags: %w", err))
+	}
+	vpc.Status.AppliedTags = appliedTags
+
+	vpc.Status.Phase = conditions.PhaseReady
+	vpc.Status.State = conditions.StateActive
+	conditions.MarkReady(&vpc.Status.Conditions, vpc.Generation, conditions.ReasonReconciled, fmt.Sprintf("VPC %s exists in the Aviatrix Controller", vpc.Spec.Name))
+	if err := r.Status().Update(ctx, vpc); err != nil {
+		logger.Error(err, "failed to update AviatrixVpc status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixVpc reconciled successfully")
 	return ctrl.Result{}, nil
 }
 
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixVpcReconciler) fail(ctx context.Context, vpc *aviatrixv1alpha1.AviatrixVpc, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, vpc, "AviatrixVpc", reason, err, &vpc.Status.Phase, &vpc.Status.State, &vpc.Status.Conditions)
+}
+
+// cleanup deletes the VPC from the Aviatrix Controller. The Controller refuses while gateways
+// are still launched in it, so the deletion is retried until they are gone.
+func (r *AviatrixVpcReconciler) cleanup(ctx context.Context, vpc *aviatrixv1alpha1.AviatrixVpc) error {
+	vpc.Status.Phase = conditions.PhaseDeleting
+	vpc.Status.State = conditions.StateDeleting
+	if err := r.CloudManager.DeleteVpc(ctx, vpc.Spec.Name); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, vpc, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete VPC: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Deleted VPC", "name", vpc.Spec.Name)
+	recordNormal(r.Recorder, vpc, EventReasonDeleted, "Deleted VPC %s", vpc.Spec.Name)
+	metrics.DeleteDriftMetrics("AviatrixVpc", vpc.Namespace, vpc.Name)
+	return nil
+}
+
 func (r *AviatrixVpcReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&aviatrixv1alpha1.AviatrixVpc{}).
-		Complete(r)
+		Complete(aviatrixReconciler("AviatrixVpc", r))
 }
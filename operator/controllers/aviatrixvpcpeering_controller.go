@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/network"
+	"aviatrix-operator/pkg/verbs"
+)
+
+// VpcPeeringFinalizer keeps an AviatrixVpcPeering until its peering is deleted from the
+// Aviatrix Controller
+const VpcPeeringFinalizer = "aviatrix.k8s.io/vpc-peering"
+
+// VpcPeeringResyncInterval is how often a peering is checked on the Aviatrix Controller, so a
+// peering deleted outside the operator is created again
+const VpcPeeringResyncInterval = 5 * time.Minute
+
+// AviatrixVpcPeeringReconciler reconciles a AviatrixVpcPeering object
+type AviatrixVpcPeeringReconciler struct {
+	client.Client
+	Scheme         *runtime.Scheme
+	Recorder       record.EventRecorder
+	AviatrixClient *aviatrix.Client
+	NetworkManager *network.Manager
+}
+
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixvpcpeerings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixvpcpeerings/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=aviatrix.k8s.io,resources=aviatrixvpcpeerings/finalizers,verbs=update
+
+// Reconcile creates the native peering between the VPCs of the spec, replaces it when the
+// VPCs change and deletes it with the resource
+func (r *AviatrixVpcPeeringReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Fetch the AviatrixVpcPeering instance
+	peering := &aviatrixv1alpha1.AviatrixVpcPeering{}
+	if err := r.Get(ctx, req.NamespacedName, peering); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "unable to fetch AviatrixVpcPeering")
+			return ctrl.Result{}, err
+		}
+		logger.Info("AviatrixVpcPeering resource not found. Ignoring since object must be deleted.")
+		return ctrl.Result{}, nil
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, peering); stop {
+		return ctrl.Result{}, err
+	}
+
+	if done, err := handleFinalizer(ctx, r.Client, peering, VpcPeeringFinalizer, func(ctx context.Context) error {
+		return r.cleanup(ctx, peering)
+	}); done {
+		return ctrl.Result{}, err
+	}
+
+	peering.Status.LastUpdated = metav1.Now()
+	requester, accepter := peering.Spec.Requester, peering.Spec.Accepter
+
+	// Retrying cannot fix an invalid spec; the next change of it is reconciled again
+	if requester.VpcID == "" || accepter.VpcID == "" || requester.VpcID == accepter.VpcID {
+		err := fmt.Errorf("spec.requester.vpcId and spec.accepter.vpcId must name two different VPCs")
+		r.fail(ctx, peering, conditions.ReasonInvalidSpec, err)
+		return ctrl.Result{}, nil
+	}
+
+	// Peerings cannot be edited; a peering of other VPCs is replaced
+	status := &peering.Status
+	if status.RequesterVpcID != "" && !samePeering(status.RequesterVpcID, status.AccepterVpcID, requester.VpcID, accepter.VpcID) {
+		if err := r.NetworkManager.DeleteVpcPeering(ctx, status.RequesterVpcID, status.AccepterVpcID); err != nil && !aviatrix.IsNotFound(err) {
+			return r.fail(ctx, peering, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete replaced peering: %w", err))
+		}
+		logger.Info("Deleted replaced VPC peering", "requester", status.RequesterVpcID, "accepter", status.AccepterVpcID)
+		recordNormal(r.Recorder, peering, EventReasonDeleted, "Deleted the replaced peering of %s with %s", status.RequesterVpcID, status.AccepterVpcID)
+		status.RequesterVpcID, status.AccepterVpcID = "", ""
+	}
+
+	// Create the peering if the Aviatrix Controller does not know it yet
+	_, err := r.NetworkManager.GetVpcPeering(ctx, requester.VpcID, accepter.VpcID)
+	if aviatrix.IsNotFound(err) {
+		peering.Status.Phase = conditions.PhaseReconciling
+		peering.Status.State = conditions.StateCreating
+		err = r.NetworkManager.CreateVpcPeering(ctx, aviatrix.VpcPeering{
+			RequesterAccountName: requester.AccountName,
+			RequesterVpcID:       requester.VpcID,
+			RequesterRegion:      requester.VpcRegion,
+			RequesterRouteTables: requester.RouteTables,
+			AccepterAccountName:  accepter.AccountName,
+			AccepterVpcID:        accepter.VpcID,
+			AccepterRegion:       accepter.VpcRegion,
+			AccepterRouteTables:  accepter.RouteTables,
+		})
+		if err != nil {
+			return r.fail(ctx, peering, conditions.ReasonCreateFailed, fmt.Errorf("failed to create VPC peering: %w", err))
+		}
+		logger.Info("Successfully created VPC peering", "requester", requester.VpcID, "accepter", accepter.VpcID)
+		recordNormal(r.Recorder, peering, EventReasonCreated, "Peered %s with %s", requester.VpcID, accepter.VpcID)
+	} else if err != nil {
+		return r.fail(ctx, peering, conditions.ReasonControllerError, fmt.Errorf("failed to get VPC peering: %w", err))
+	}
+	status.RequesterVpcID, status.AccepterVpcID = requester.VpcID, accepter.VpcID
+
+	peering.Status.Phase = conditions.PhaseReady
+	peering.Status.State = conditions.StateActive
+	conditions.MarkReady(&peering.Status.Conditions, peering.Generation, conditions.ReasonPeered, fmt.Sprintf("%s is peered with %s", requester.VpcID, accepter.VpcID))
+	if err := r.Status().Update(ctx, peering); err != nil {
+		logger.Error(err, "failed to update AviatrixVpcPeering status")
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("AviatrixVpcPeering reconciled successfully")
+	return ctrl.Result{RequeueAfter: VpcPeeringResyncInterval}, nil
+}
+
+// fail records a failed reconcile in the status and returns err, so the reconcile is retried
+// with backoff
+func (r *AviatrixVpcPeeringReconciler) fail(ctx context.Context, peering *aviatrixv1alpha1.AviatrixVpcPeering, reason string, err error) (ctrl.Result, error) {
+	return failReconcile(ctx, r.Client, r.Recorder, peering, "AviatrixVpcPeering", reason, err, &peering.Status.Phase, &peering.Status.State, &peering.Status.Conditions)
+}
+
+// cleanup deletes the peering from the Aviatrix Controller
+func (r *AviatrixVpcPeeringReconciler) cleanup(ctx context.Context, peering *aviatrixv1alpha1.AviatrixVpcPeering) error {
+	// The peering that was created may differ from a spec edited since
+	requesterVpcID, accepterVpcID := peering.Status.RequesterVpcID, peering.Status.AccepterVpcID
+	if requesterVpcID == "" {
+		requesterVpcID, accepterVpcID = peering.Spec.Requester.VpcID, peering.Spec.Accepter.VpcID
+	}
+	peering.Status.Phase = conditions.PhaseDeleting
+	peering.Status.State = conditions.StateDeleting
+	if err := r.NetworkManager.DeleteVpcPeering(ctx, requesterVpcID, accepterVpcID); err != nil && !aviatrix.IsNotFound(err) {
+		_, err = r.fail(ctx, peering, conditions.ReasonDeleteFailed, fmt.Errorf("failed to delete VPC peering: %w", err))
+		return err
+	}
+	log.FromContext(ctx).Info("Deleted VPC peering", "requester", requesterVpcID, "accepter", accepterVpcID)
+	recordNormal(r.Recorder, peering, EventReasonDeleted, "Deleted the peering of %s with %s", requesterVpcID, accepterVpcID)
+	return nil
+}
+
+// samePeering reports whether two pairs of VPCs name the same peering, which has no direction
+// once created
+func samePeering(requester1, accepter1, requester2, accepter2 string) bool {
+	return (requester1 == requester2 && accepter1 == accepter2) || (requester1 == accepter2 && accepter1 == requester2)
+}
+
+func (r *AviatrixVpcPeeringReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Peerings deleted outside the operator are picked up by the periodic resync
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&aviatrixv1alpha1.AviatrixVpcPeering{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Complete(aviatrixReconciler("AviatrixVpcPeering", r))
+}
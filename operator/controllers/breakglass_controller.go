@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/access"
+)
+
+const (
+	// BreakGlassExpiresAtAnnotation records on the role binding when it will be removed
+	BreakGlassExpiresAtAnnotation = "k8s-playgrounds.io/break-glass-expires-at"
+	// BreakGlassReasonAnnotation records on the role binding why it was granted
+	BreakGlassReasonAnnotation = "k8s-playgrounds.io/break-glass-reason"
+
+	// MaxBreakGlassDuration caps how long a single grant may last
+	MaxBreakGlassDuration = 24 * time.Hour
+)
+
+// BreakGlassReconciler reconciles a BreakGlass object
+type BreakGlassReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=breakglasses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=breakglasses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=k8splaygroundsclusters,verbs=get;list;watch
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile grants the requested role binding, keeps it in place until the grant expires
+// and then removes it
+func (r *BreakGlassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName("BreakGlassReconciler")
+
+	breakGlass := &k8splaygroundsv1alpha1.BreakGlass{}
+	if err := r.Get(ctx, req.NamespacedName, breakGlass); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("BreakGlass not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch BreakGlass")
+		return ctrl.Result{}, err
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, breakGlass); stop {
+		return ctrl.Result{}, err
+	}
+
+	// Expired and rejected grants are final; a new BreakGlass must be created to regain access
+	if breakGlass.Status.Phase == k8splaygroundsv1alpha1.BreakGlassPhaseExpired ||
+		breakGlass.Status.Phase == k8splaygroundsv1alpha1.BreakGlassPhaseFailed {
+		return ctrl.Result{}, nil
+	}
+
+	// The spec is checked on every reconcile, so a grant edited to a role or namespace that is
+	// not allowed loses the access it already had
+	if err := r.validateBreakGlass(ctx, breakGlass); err != nil {
+		log.Error(err, "rejecting break-glass request")
+		r.Recorder.Event(breakGlass, corev1.EventTypeWarning, "Rejected", err.Error())
+		if err := r.deleteRoleBindings(ctx, breakGlass, ""); err != nil {
+			log.Error(err, "failed to delete break-glass role binding")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, r.updateBreakGlassStatus(ctx, breakGlass, k8splaygroundsv1alpha1.BreakGlassPhaseFailed, err.Error())
+	}
+
+	if breakGlass.Status.ExpiresAt == nil {
+		now := metav1.Now()
+		expiresAt := metav1.NewTime(now.Add(breakGlass.Spec.Duration.Duration))
+		breakGlass.Status.GrantedAt = &now
+		breakGlass.Status.ExpiresAt = &expiresAt
+		breakGlass.Status.RoleBinding = breakGlassRoleBindingName(breakGlass)
+	}
+
+	// Revoke the grant once it has expired
+	now := time.Now()
+	if !now.Before(breakGlass.Status.ExpiresAt.Time) {
+		return r.revokeBreakGlass(ctx, breakGlass)
+	}
+
+	created, err := r.ensureRoleBinding(ctx, breakGlass)
+	if err != nil {
+		log.Error(err, "failed to create break-glass role binding")
+		return ctrl.Result{}, err
+	}
+	if created {
+		message := fmt.Sprintf("Granted %s %q role %q in namespace %q until %s: %s",
+			breakGlassSubjectKind(breakGlass), breakGlass.Spec.Subject, breakGlassRole(breakGlass), breakGlass.Spec.Namespace,
+			breakGlass.Status.ExpiresAt.UTC().Format(time.RFC3339), breakGlass.Spec.Reason)
+		log.Info("audit: break-glass access granted",
+			"subject", breakGlass.Spec.Subject, "subjectKind", breakGlassSubjectKind(breakGlass),
+			"namespace", breakGlass.Spec.Namespace, "role", breakGlassRole(breakGlass),
+			"expiresAt", breakGlass.Status.ExpiresAt.Time, "reason", breakGlass.Spec.Reason)
+		r.Recorder.Event(breakGlass, corev1.EventTypeNormal, "Granted", message)
+	}
+
+	if breakGlass.Status.Phase != k8splaygroundsv1alpha1.BreakGlassPhaseActive {
+		if err := r.updateBreakGlassStatus(ctx, breakGlass, k8splaygroundsv1alpha1.BreakGlassPhaseActive, "Elevated access granted"); err != nil {
+			log.Error(err, "failed to update BreakGlass status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: time.Until(breakGlass.Status.ExpiresAt.Time)}, nil
+}
+
+// validateBreakGlass checks the request before anything is granted
+func (r *BreakGlassReconciler) validateBreakGlass(ctx context.Context, breakGlass *k8splaygroundsv1alpha1.BreakGlass) error {
+	duration := breakGlass.Spec.Duration.Duration
+	if duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if duration > MaxBreakGlassDuration {
+		return fmt.Errorf("duration %s exceeds the maximum of %s", duration, MaxBreakGlassDuration)
+	}
+	if breakGlass.Spec.Reason == "" {
+		return fmt.Errorf("a reason is required for break-glass access")
+	}
+	if role := breakGlassRole(breakGlass); !access.AllowedRole(role) {
+		return fmt.Errorf("unsupported break-glass role %q, must be one of view, edit or admin", role)
+	}
+
+	// Only the existence of the namespace matters, so it is cached as metadata
+	namespace := &metav1.PartialObjectMetadata{}
+	namespace.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	if err := r.Get(ctx, types.NamespacedName{Name: breakGlass.Spec.Namespace}, namespace); err != nil {
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("namespace %q does not exist", breakGlass.Spec.Namespace)
+		}
+		return err
+	}
+
+	// Access is only granted in namespaces a playground cluster manages, never in system or
+	// operator namespaces
+	clusters := &k8splaygroundsv1alpha1.K8sPlaygroundsClusterList{}
+	if err := r.List(ctx, clusters); err != nil {
+		return err
+	}
+	for i := range clusters.Items {
+		for _, managed := range clusters.Items[i].ManagedNamespaces() {
+			if managed == breakGlass.Spec.Namespace {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("namespace %q is not managed by a playground cluster", breakGlass.Spec.Namespace)
+}
+
+// ensureRoleBinding creates the role binding for the grant and reports whether it was created
+func (r *BreakGlassReconciler) ensureRoleBinding(ctx context.Context, breakGlass *k8splaygroundsv1alpha1.BreakGlass) (bool, error) {
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      breakGlass.Status.RoleBinding,
+			Namespace: breakGlass.Spec.Namespace,
+		},
+	}
+
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, roleBinding, func() error {
+		roleBinding.Labels = breakGlassLabels(breakGlass)
+		if roleBinding.Annotations == nil {
+			roleBinding.Annotations = make(map[string]string)
+		}
+		roleBinding.Annotations[BreakGlassExpiresAtAnnotation] = breakGlass.Status.ExpiresAt.UTC().Format(time.RFC3339)
+		roleBinding.Annotations[BreakGlassReasonAnnotation] = breakGlass.Spec.Reason
+		roleBinding.RoleRef = rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     breakGlassRole(breakGlass),
+		}
+		subject := rbacv1.Subject{
+			Kind: breakGlassSubjectKind(breakGlass),
+			Name: breakGlass.Spec.Subject,
+		}
+		if subject.Kind == rbacv1.ServiceAccountKind {
+			subject.Namespace = breakGlass.Spec.Namespace
+		} else {
+			subject.APIGroup = rbacv1.GroupName
+		}
+		roleBinding.Subjects = []rbacv1.Subject{subject}
+		return controllerutil.SetControllerReference(breakGlass, roleBinding, r.Scheme)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Remove the binding left in the previous namespace of a grant whose namespace was changed
+	if err := r.deleteRoleBindings(ctx, breakGlass, breakGlass.Spec.Namespace); err != nil {
+		return false, err
+	}
+	return result == controllerutil.OperationResultCreated, nil
+}
+
+// deleteRoleBindings deletes the role bindings of the grant outside the namespace keep, or in
+// every namespace when keep is empty
+func (r *BreakGlassReconciler) deleteRoleBindings(ctx context.Context, breakGlass *k8splaygroundsv1alpha1.BreakGlass, keep string) error {
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings, client.MatchingLabels(breakGlassLabels(breakGlass))); err != nil {
+		return err
+	}
+	for i := range roleBindings.Items {
+		roleBinding := &roleBindings.Items[i]
+		if roleBinding.Namespace == keep || !metav1.IsControlledBy(roleBinding, breakGlass) {
+			continue
+		}
+		if err := r.Delete(ctx, roleBinding); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeBreakGlass removes the role binding of an expired grant
+func (r *BreakGlassReconciler) revokeBreakGlass(ctx context.Context, breakGlass *k8splaygroundsv1alpha1.BreakGlass) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName("BreakGlassReconciler")
+
+	if err := r.deleteRoleBindings(ctx, breakGlass, ""); err != nil {
+		log.Error(err, "failed to delete break-glass role binding")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("audit: break-glass access revoked",
+		"subject", breakGlass.Spec.Subject, "subjectKind", breakGlassSubjectKind(breakGlass),
+		"namespace", breakGlass.Spec.Namespace, "role", breakGlassRole(breakGlass))
+	r.Recorder.Event(breakGlass, corev1.EventTypeNormal, "Revoked",
+		fmt.Sprintf("Revoked %s %q role %q in namespace %q", breakGlassSubjectKind(breakGlass), breakGlass.Spec.Subject, breakGlassRole(breakGlass), breakGlass.Spec.Namespace))
+
+	if err := r.updateBreakGlassStatus(ctx, breakGlass, k8splaygroundsv1alpha1.BreakGlassPhaseExpired, "Elevated access expired and was removed"); err != nil {
+		log.Error(err, "failed to update BreakGlass status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// updateBreakGlassStatus updates the BreakGlass status
+func (r *BreakGlassReconciler) updateBreakGlassStatus(ctx context.Context, breakGlass *k8splaygroundsv1alpha1.BreakGlass, phase k8splaygroundsv1alpha1.BreakGlassPhase, message string) error {
+	breakGlass.Status.Phase = phase
+	breakGlass.Status.Message = message
+	return r.Status().Update(ctx, breakGlass)
+}
+
+// breakGlassRoleBindingName returns the name of the role binding created for a grant
+func breakGlassRoleBindingName(breakGlass *k8splaygroundsv1alpha1.BreakGlass) string {
+	return fmt.Sprintf("break-glass-%s", breakGlass.Name)
+}
+
+// breakGlassLabels returns the labels of the role bindings created for a grant
+func breakGlassLabels(breakGlass *k8splaygroundsv1alpha1.BreakGlass) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "break-glass",
+		"app.kubernetes.io/instance": breakGlass.Name,
+	}
+}
+
+// breakGlassRole returns the ClusterRole of a grant, defaulting to admin
+func breakGlassRole(breakGlass *k8splaygroundsv1alpha1.BreakGlass) string {
+	if breakGlass.Spec.Role == "" {
+		return "admin"
+	}
+	return breakGlass.Spec.Role
+}
+
+// breakGlassSubjectKind returns the RBAC subject kind of a grant, defaulting to User
+func breakGlassSubjectKind(breakGlass *k8splaygroundsv1alpha1.BreakGlass) string {
+	if breakGlass.Spec.SubjectKind == "" {
+		return rbacv1.UserKind
+	}
+	return breakGlass.Spec.SubjectKind
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *BreakGlassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&k8splaygroundsv1alpha1.BreakGlass{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Complete(r)
+}
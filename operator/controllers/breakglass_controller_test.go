@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func newBreakGlassReconciler(t *testing.T, objs ...client.Object) *BreakGlassReconciler {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypes(k8splaygroundsv1alpha1.SchemeGroupVersion,
+		&k8splaygroundsv1alpha1.BreakGlass{}, &k8splaygroundsv1alpha1.BreakGlassList{},
+		&k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}, &k8splaygroundsv1alpha1.K8sPlaygroundsClusterList{})
+	metav1.AddToGroupVersion(scheme, k8splaygroundsv1alpha1.SchemeGroupVersion)
+
+	objs = append(objs,
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "labs"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+		&k8splaygroundsv1alpha1.K8sPlaygroundsCluster{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "labs"}},
+	)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&k8splaygroundsv1alpha1.BreakGlass{}).Build()
+	return &BreakGlassReconciler{Client: c, Scheme: scheme, Recorder: record.NewFakeRecorder(10)}
+}
+
+func breakGlassRequest(namespace, role string) *k8splaygroundsv1alpha1.BreakGlass {
+	return &k8splaygroundsv1alpha1.BreakGlass{
+		ObjectMeta: metav1.ObjectMeta{Name: "incident", UID: "uid-incident"},
+		Spec: k8splaygroundsv1alpha1.BreakGlassSpec{
+			Subject:   "alice",
+			Namespace: namespace,
+			Role:      role,
+			Duration:  metav1.Duration{Duration: time.Hour},
+			Reason:    "database is down",
+		},
+	}
+}
+
+// reconcileBreakGlass reconciles the grant and returns its phase and role binding
+func reconcileBreakGlass(t *testing.T, r *BreakGlassReconciler) (k8splaygroundsv1alpha1.BreakGlassPhase, []rbacv1.RoleBinding) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: "incident"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	breakGlass := &k8splaygroundsv1alpha1.BreakGlass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "incident"}, breakGlass); err != nil {
+		t.Fatal(err)
+	}
+	roleBindings := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, roleBindings); err != nil {
+		t.Fatal(err)
+	}
+	return breakGlass.Status.Phase, roleBindings.Items
+}
+
+func TestBreakGlassGrantsAllowedRoleInManagedNamespace(t *testing.T) {
+	r := newBreakGlassReconciler(t, breakGlassRequest("labs", "edit"))
+
+	phase, roleBindings := reconcileBreakGlass(t, r)
+	if phase != k8splaygroundsv1alpha1.BreakGlassPhaseActive || len(roleBindings) != 1 {
+		t.Fatalf("phase = %s with %d role bindings, want an active grant with one", phase, len(roleBindings))
+	}
+	if ref := roleBindings[0].RoleRef; roleBindings[0].Namespace != "labs" || ref.Kind != "ClusterRole" || ref.Name != "edit" {
+		t.Errorf("role binding %s/%s binds %+v, want ClusterRole edit in labs", roleBindings[0].Namespace, roleBindings[0].Name, ref)
+	}
+}
+
+func TestBreakGlassRejectsRoleAndNamespace(t *testing.T) {
+	cases := map[string]*k8splaygroundsv1alpha1.BreakGlass{
+		"role outside the allowlist": breakGlassRequest("labs", "cluster-admin"),
+		"unmanaged namespace":        breakGlassRequest("kube-system", "view"),
+		"missing namespace":          breakGlassRequest("gone", "view"),
+	}
+	for name, breakGlass := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := newBreakGlassReconciler(t, breakGlass)
+			phase, roleBindings := reconcileBreakGlass(t, r)
+			if phase != k8splaygroundsv1alpha1.BreakGlassPhaseFailed || len(roleBindings) != 0 {
+				t.Errorf("phase = %s with role bindings %+v, want a rejected grant without any", phase, roleBindings)
+			}
+		})
+	}
+}
+
+func TestBreakGlassRevokesGrantEditedOutsideTheAllowlist(t *testing.T) {
+	ctx := context.Background()
+	r := newBreakGlassReconciler(t, breakGlassRequest("labs", "view"))
+	if phase, _ := reconcileBreakGlass(t, r); phase != k8splaygroundsv1alpha1.BreakGlassPhaseActive {
+		t.Fatalf("phase = %s, want Active", phase)
+	}
+
+	breakGlass := &k8splaygroundsv1alpha1.BreakGlass{}
+	if err := r.Get(ctx, types.NamespacedName{Name: "incident"}, breakGlass); err != nil {
+		t.Fatal(err)
+	}
+	breakGlass.Spec.Role = "cluster-admin"
+	if err := r.Update(ctx, breakGlass); err != nil {
+		t.Fatal(err)
+	}
+
+	phase, roleBindings := reconcileBreakGlass(t, r)
+	if phase != k8splaygroundsv1alpha1.BreakGlassPhaseFailed || len(roleBindings) != 0 {
+		t.Errorf("phase = %s with role bindings %+v, want the granted binding revoked", phase, roleBindings)
+	}
+	err := r.Get(ctx, types.NamespacedName{Namespace: "labs", Name: "break-glass-incident"}, &rbacv1.RoleBinding{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("role binding lookup error = %v, want not found", err)
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/drift"
+	"aviatrix-operator/pkg/metrics"
+)
+
+// ConditionDriftDetected is set while a resource on the Aviatrix Controller differs from its spec
+const ConditionDriftDetected = "DriftDetected"
+
+// trackDrift compares the desired fields of obj with those reported by the Aviatrix Controller,
+// recording in detectedAt and fields when drift was first detected and what drifted, and in the
+// metrics how long it took to converge. It returns the fields currently out of sync.
+func trackDrift(kind string, obj client.Object, desired, observed map[string]string, detectedAt **metav1.Time, fields *[]string) []string {
+	now := time.Now()
+	state, converged := drift.Track(drift.State{
+		DetectedAt: *detectedAt,
+		Fields:     *fields,
+	}, drift.Compare(desired, observed), now)
+	if converged > 0 {
+		metrics.RecordDriftConverged(kind, converged)
+	}
+
+	*detectedAt = state.DetectedAt
+	*fields = state.Fields
+	var since time.Time
+	if state.DetectedAt != nil {
+		since = state.DetectedAt.Time
+	}
+	metrics.RecordDriftDetected(kind, obj.GetNamespace(), obj.GetName(), since)
+	return state.Fields
+}
+
+// setDriftDetected sets the DriftDetected condition from the fields out of sync
+func setDriftDetected(statusConditions *[]metav1.Condition, generation int64, drifted []string, inSync string) {
+	if len(drifted) == 0 {
+		conditions.Set(statusConditions, generation, ConditionDriftDetected, metav1.ConditionFalse, conditions.ReasonInSync, inSync)
+		return
+	}
+	conditions.Set(statusConditions, generation, ConditionDriftDetected, metav1.ConditionTrue, conditions.ReasonDriftDetected, "Fields differ from the spec: "+strings.Join(drifted, ", "))
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// Reasons of the Normal events recorded when an external resource changes. Warning events use
+// the condition reason of the failure, so `kubectl describe` and the Ready condition agree.
+const (
+	EventReasonCreated = "Created"
+	EventReasonUpdated = "Updated"
+	EventReasonDeleted = "Deleted"
+)
+
+// recordNormal records a Normal event on obj. Reconcilers built without a recorder, as in
+// tests, record nothing.
+func recordNormal(recorder record.EventRecorder, obj runtime.Object, reason, messageFmt string, args ...interface{}) {
+	if recorder != nil {
+		recorder.Eventf(obj, corev1.EventTypeNormal, reason, messageFmt, args...)
+	}
+}
+
+// recordFailure records a Warning event on obj with the reason reported in its conditions
+func recordFailure(recorder record.EventRecorder, obj runtime.Object, reason string, err error) {
+	if recorder != nil {
+		recorder.Event(obj, corev1.EventTypeWarning, reason, err.Error())
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/metrics"
+	"aviatrix-operator/pkg/upgrade"
+)
+
+// handleFinalizer keeps obj until cleanup has deleted its external resources. A live object
+// gets the finalizer; a deleted one runs cleanup and loses the finalizer once cleanup succeeds.
+// done reports that the reconcile must stop, with err set when it must be retried.
+func handleFinalizer(ctx context.Context, c client.Client, obj client.Object, finalizer string, cleanup func(context.Context) error) (done bool, err error) {
+	logger := log.FromContext(ctx)
+
+	if obj.GetDeletionTimestamp().IsZero() {
+		if controllerutil.ContainsFinalizer(obj, finalizer) {
+			return false, nil
+		}
+		controllerutil.AddFinalizer(obj, finalizer)
+		if err := c.Update(ctx, obj); err != nil {
+			logger.Error(err, "failed to add finalizer")
+			return true, err
+		}
+		return false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, finalizer) {
+		return true, nil
+	}
+	if err := cleanup(ctx); err != nil {
+		return true, err
+	}
+	controllerutil.RemoveFinalizer(obj, finalizer)
+	if err := c.Update(ctx, obj); err != nil {
+		logger.Error(err, "failed to remove finalizer")
+		return true, err
+	}
+	return true, nil
+}
+
+// failReconcile records that reconciling obj of the given kind failed with err: it logs and
+// counts the failure, records a warning event, marks the status Failed through phase, state and
+// conds, and writes the status. err is returned so the reconcile is retried.
+func failReconcile(ctx context.Context, c client.Client, recorder record.EventRecorder, obj client.Object, kind, reason string, err error, phase, state *string, conds *[]metav1.Condition) (ctrl.Result, error) {
+	log.FromContext(ctx).Error(err, "failed to reconcile "+kind, "reason", reason, "transient", aviatrix.IsTransient(err))
+	metrics.RecordReconcileFailure(kind, conditions.Label(reason))
+	recordFailure(recorder, obj, reason, err)
+	*phase = conditions.PhaseFailed
+	*state = conditions.StateError
+	conditions.MarkFailed(conds, obj.GetGeneration(), reason, err.Error())
+	updateFailedStatus(ctx, c, obj)
+	return ctrl.Result{}, err
+}
+
+// updateFailedStatus writes the status of obj after a failed reconcile. The reconcile returns
+// the failure itself, so an error writing the status is logged rather than returned.
+func updateFailedStatus(ctx context.Context, c client.Client, obj client.Object) {
+	if err := c.Status().Update(ctx, obj); err != nil {
+		log.FromContext(ctx).Error(err, "failed to update status after a failed reconcile", "namespace", obj.GetNamespace(), "name", obj.GetName())
+	}
+}
+
+// patchFinalizer adds or removes a finalizer with a merge patch of a copy of obj, so changes
+// made to obj in memory, such as applied defaults, are not written back. obj takes the new
+// finalizers and resource version.
+func patchFinalizer(ctx context.Context, c client.Client, obj client.Object, finalizer string, add bool) error {
+	patched := obj.DeepCopyObject().(client.Object)
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	if add {
+		controllerutil.AddFinalizer(patched, finalizer)
+	} else {
+		controllerutil.RemoveFinalizer(patched, finalizer)
+	}
+	if err := c.Patch(ctx, patched, patch); err != nil {
+		return err
+	}
+	obj.SetFinalizers(patched.GetFinalizers())
+	obj.SetResourceVersion(patched.GetResourceVersion())
+	return nil
+}
+
+// deleteGateways deletes a gateway and, when ha is set, its HA gateway first. Gateways already
+// gone are skipped.
+func deleteGateways(ctx context.Context, m *cloud.Manager, gwName string, ha bool) error {
+	logger := log.FromContext(ctx)
+	if ha {
+		if err := m.DeleteGateway(ctx, gwName+upgrade.HASuffix); err == nil {
+			logger.Info("Deleted HA gateway", "name", gwName+upgrade.HASuffix)
+		} else if !aviatrix.IsNotFound(err) {
+			return fmt.Errorf("failed to delete HA gateway: %w", err)
+		}
+	}
+	if err := m.DeleteGateway(ctx, gwName); err == nil {
+		logger.Info("Deleted gateway", "name", gwName)
+	} else if !aviatrix.IsNotFound(err) {
+		return fmt.Errorf("failed to delete gateway: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/apis/conditions"
+	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/schedule"
+)
+
+// GatewayConditionScheduledStop is set while a gateway is stopped by its schedule
+const GatewayConditionScheduledStop = "ScheduledStop"
+
+// reconcileGatewaySchedule stops the gateway gwName inside the stop window of its schedule and
+// starts it again once the window closes. A gateway stopped by a schedule that was since
+// removed or suspended is started right away. It returns when the schedule next stops or
+// starts the gateway, 0 without a schedule, and whether the gateway is stopped by it; the
+// caller records a stopped gateway and skips the rest of the reconcile.
+func reconcileGatewaySchedule(ctx context.Context, cloudManager *cloud.Manager, recorder record.EventRecorder, obj client.Object, gwName string, spec *aviatrixv1alpha1.GatewaySchedule, status *aviatrixv1alpha1.GatewayScheduleStatus, conds *[]metav1.Condition) (time.Duration, bool, error) {
+	logger := log.FromContext(ctx)
+
+	if spec == nil || spec.Suspend {
+		status.NextScheduledTransition = nil
+		if status.ScheduledStop {
+			if err := cloudManager.StartGateway(ctx, gwName); err != nil {
+				return 0, false, fmt.Errorf("failed to start gateway: %w", err)
+			}
+			logger.Info("Started gateway after its schedule was removed or suspended", "gwName", gwName)
+			recordNormal(recorder, obj, EventReasonUpdated, "Started gateway %s after its schedule was removed or suspended", gwName)
+			status.ScheduledStop = false
+		}
+		meta.RemoveStatusCondition(conds, GatewayConditionScheduledStop)
+		return 0, false, nil
+	}
+
+	window, err := schedule.NewWindow(spec.StopCron, spec.StartCron, spec.TimeZone)
+	if err != nil {
+		return 0, false, err
+	}
+
+	now := time.Now()
+	var requeueAfter time.Duration
+	status.NextScheduledTransition = nil
+	if next, ok := window.NextTransition(now); ok {
+		requeueAfter = next.Sub(now)
+		status.NextScheduledTransition = &metav1.Time{Time: next}
+	}
+
+	if window.Active(now) {
+		if !status.ScheduledStop {
+			if err := cloudManager.StopGateway(ctx, gwName); err != nil {
+				return 0, false, fmt.Errorf("failed to stop gateway: %w", err)
+			}
+			logger.Info("Stopped gateway for scheduled window", "gwName", gwName)
+			recordNormal(recorder, obj, EventReasonUpdated, "Stopped gateway %s for its scheduled window", gwName)
+		}
+
+		status.ScheduledStop = true
+		conditions.Set(conds, obj.GetGeneration(), GatewayConditionScheduledStop, metav1.ConditionTrue, conditions.ReasonInScheduledWindow, "Gateway is stopped by its schedule")
+		// A scheduled stop is intended, so the gateway is not ready but not degraded either
+		conditions.SetReady(conds, obj.GetGeneration(), false, conditions.ReasonInScheduledWindow, "Gateway is stopped by its schedule")
+		return requeueAfter, true, nil
+	}
+
+	if status.ScheduledStop {
+		if err := cloudManager.StartGateway(ctx, gwName); err != nil {
+			return 0, false, fmt.Errorf("failed to start gateway: %w", err)
+		}
+		logger.Info("Started gateway after scheduled window", "gwName", gwName)
+		recordNormal(recorder, obj, EventReasonUpdated, "Started gateway %s after its scheduled window", gwName)
+		status.ScheduledStop = false
+	}
+	conditions.Set(conds, obj.GetGeneration(), GatewayConditionScheduledStop, metav1.ConditionFalse, conditions.ReasonOutsideScheduledWindow, "Gateway is running outside its scheduled stop window")
+
+	return requeueAfter, false, nil
+}
@@ -3,40 +3,106 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apis/conditions"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/cloudevents"
+	"github.com/k8s-playgrounds/operator/pkg/defaults"
+	"github.com/k8s-playgrounds/operator/pkg/demoworkload"
 	"github.com/k8s-playgrounds/operator/pkg/dns"
 	"github.com/k8s-playgrounds/operator/pkg/endpoints"
+	"github.com/k8s-playgrounds/operator/pkg/features"
+	"github.com/k8s-playgrounds/operator/pkg/federation"
+	"github.com/k8s-playgrounds/operator/pkg/helpergc"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
 	"github.com/k8s-playgrounds/operator/pkg/iptables"
+	"github.com/k8s-playgrounds/operator/pkg/loadtest"
 	"github.com/k8s-playgrounds/operator/pkg/metrics"
+	"github.com/k8s-playgrounds/operator/pkg/mirror"
+	"github.com/k8s-playgrounds/operator/pkg/peers"
+	"github.com/k8s-playgrounds/operator/pkg/recorder"
+	"github.com/k8s-playgrounds/operator/pkg/reportstore"
 	"github.com/k8s-playgrounds/operator/pkg/servicediscovery"
+	"github.com/k8s-playgrounds/operator/pkg/serviceports"
+	"github.com/k8s-playgrounds/operator/pkg/runtimeconfig"
+	"github.com/k8s-playgrounds/operator/pkg/throttle"
+	"github.com/k8s-playgrounds/operator/pkg/verbs"
 )
 
 // HeadlessServiceReconciler reconciles a HeadlessService object
 type HeadlessServiceReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
-	Recorder event.Recorder
+	Recorder record.EventRecorder
+
+	// Recordings saves the inputs and outputs of every reconcile for replay; nil disables recording
+	Recordings *recorder.Recorder
+
+	// MaxMatchedPods is the operator-wide limit on the pods a selector may match; 0 uses
+	// endpoints.DefaultMaxMatchedPods
+	MaxMatchedPods int
+
+	// LoadTestImage is the operator image providing the builtin load test client; empty uses
+	// loadtest.DefaultBuiltinImage
+	LoadTestImage string
+
+	// ReportStore keeps full load test results, which otherwise go to the report ConfigMap;
+	// nil disables it
+	ReportStore reportstore.Store
+
+	// HelperPods is the operator-wide priority class, resources and per-namespace cap of the
+	// discovery, DNS test and iptables proxy pods
+	HelperPods helperpods.Config
+
+	// HelperGC selects when completed DNS test pods and stale discovery helpers are removed;
+	// zero values use the helpergc defaults
+	HelperGC helpergc.Config
+
+	// Events publishes iptables rules drift to external systems; nil disables them
+	Events *cloudevents.Emitter
+
+	// EndpointFilter calls the endpoint filter hooks of services that configure one; nil
+	// creates an HTTP client per call
+	EndpointFilter *endpoints.Filter
+
+	// Probes rate limits DNS tests and discovery lookups across all HeadlessServices and pauses
+	// them while the DNS service fails; nil uses a gate with the throttle defaults. Its rate
+	// limits follow the runtime configuration.
+	Probes *throttle.Gate
 }
 
 //+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=headlessservices,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=headlessservices/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=headlessservices/finalizers,verbs=update
-//+kubebuilder:rbac:groups=core,resources=services;endpoints;pods,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services;endpoints;pods;configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets;daemonsets;deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop
 func (r *HeadlessServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -53,16 +119,17 @@ func (r *HeadlessServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, err
 	}
 
-	// Set default values
-	if err := r.setDefaults(headlessService); err != nil {
-		log.Error(err, "failed to set defaults")
+	if _, stop, err := handleVerbs(ctx, r.Client, headlessService); stop {
 		return ctrl.Result{}, err
 	}
 
+	// Fill in what the spec leaves unset in memory only; the defaulting webhook persists the
+	// defaults, so reconciling never changes the spec
+	defaults.HeadlessService(headlessService)
+
 	// Add finalizer if not present
 	if !controllerutil.ContainsFinalizer(headlessService, k8splaygroundsv1alpha1.HeadlessServiceFinalizer) {
-		controllerutil.AddFinalizer(headlessService, k8splaygroundsv1alpha1.HeadlessServiceFinalizer)
-		if err := r.Update(ctx, headlessService); err != nil {
+		if err := patchFinalizer(ctx, r.Client, headlessService, k8splaygroundsv1alpha1.HeadlessServiceFinalizer, true); err != nil {
 			log.Error(err, "failed to add finalizer")
 			return ctrl.Result{}, err
 		}
@@ -81,50 +148,119 @@ func (r *HeadlessServiceReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 func (r *HeadlessServiceReconciler) reconcileHeadlessService(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) (ctrl.Result, error) {
 	log.Info("reconciling HeadlessService", "name", headlessService.Name, "namespace", headlessService.Namespace)
 
-	// 1. Create or update the underlying Kubernetes Service
+	// Probes that are held back below set the condition again
+	meta.RemoveStatusCondition(&headlessService.Status.Conditions, throttle.ConditionProbesThrottled)
+
+	// 1. Copy the selector and ports of a mirrored Service
+	if err := mirror.NewManager(r.Client).Sync(ctx, headlessService); err != nil {
+		log.Error(err, "failed to mirror Service")
+		headlessService.Status.Phase = conditions.PhasePending
+		headlessService.Status.Ready = false
+		headlessService.Status.Message = err.Error()
+		conditions.SetReady(&headlessService.Status.Conditions, headlessService.Generation, false, conditions.ReasonMirrorFailed, err.Error())
+		if err := r.Status().Update(ctx, headlessService); err != nil {
+			log.Error(err, "failed to update status")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// 2. Create or update the underlying Kubernetes Service
 	if err := r.reconcileKubernetesService(ctx, headlessService, log); err != nil {
 		log.Error(err, "failed to reconcile Kubernetes Service")
 		return ctrl.Result{}, err
 	}
 
-	// 2. Create or update endpoints
+	// 3. Run demo pods matching the selector
+	demoRunning, err := r.reconcileDemoWorkload(ctx, headlessService, log)
+	if err != nil {
+		log.Error(err, "failed to reconcile demo workload")
+		return ctrl.Result{}, err
+	}
+
+	// 4. Create or update endpoints
 	if err := r.reconcileEndpoints(ctx, headlessService, log); err != nil {
 		log.Error(err, "failed to reconcile endpoints")
 		return ctrl.Result{}, err
 	}
 
-	// 3. Configure DNS resolution
-	if err := r.reconcileDNS(ctx, headlessService, log); err != nil {
+	// 5. Merge endpoints of member clusters into the federated DNS view
+	if err := r.reconcileFederation(ctx, headlessService, log); err != nil {
+		log.Error(err, "failed to reconcile federation")
+		return ctrl.Result{}, err
+	}
+
+	// 6. Configure DNS resolution
+	requeueAfter := runtimeconfig.Current().HeadlessServiceResyncInterval
+	if headlessService.Spec.Federation != nil && federation.ResyncInterval < requeueAfter {
+		requeueAfter = federation.ResyncInterval
+	}
+	if endpoints.HasExternalHostnames(headlessService) && endpoints.ExternalResolveInterval < requeueAfter {
+		requeueAfter = endpoints.ExternalResolveInterval
+	}
+	dnsWait, err := r.reconcileDNS(ctx, headlessService, log)
+	if err != nil {
 		log.Error(err, "failed to reconcile DNS")
 		return ctrl.Result{}, err
 	}
+	if dnsWait > 0 && dnsWait < requeueAfter {
+		requeueAfter = dnsWait
+	}
 
-	// 4. Configure service discovery
-	if err := r.reconcileServiceDiscovery(ctx, headlessService, log); err != nil {
+	// 7. Configure service discovery
+	discoveryWait, err := r.reconcileServiceDiscovery(ctx, headlessService, log)
+	if err != nil {
 		log.Error(err, "failed to reconcile service discovery")
 		return ctrl.Result{}, err
 	}
+	if discoveryWait > 0 && discoveryWait < requeueAfter {
+		requeueAfter = discoveryWait
+	}
+
+	// 8. Publish StatefulSet peer lists
+	if err := r.reconcilePeerList(ctx, headlessService, log); err != nil {
+		log.Error(err, "failed to reconcile peer list")
+		return ctrl.Result{}, err
+	}
 
-	// 5. Configure iptables proxy mode
+	// 9. Configure iptables proxy mode
 	if err := r.reconcileIptablesProxy(ctx, headlessService, log); err != nil {
 		log.Error(err, "failed to reconcile iptables proxy")
 		return ctrl.Result{}, err
 	}
 
-	// 6. Update status
+	// 10. Compare native headless behavior with a ClusterIP Service
+	if err := r.reconcileConformance(ctx, headlessService, log); err != nil {
+		log.Error(err, "failed to reconcile conformance report")
+		return ctrl.Result{}, err
+	}
+
+	// 11. Run load tests against the data path
+	running, err := r.reconcileLoadTest(ctx, headlessService, log)
+	if err != nil {
+		log.Error(err, "failed to reconcile load test")
+		return ctrl.Result{}, err
+	}
+	if running && loadtest.PollInterval < requeueAfter {
+		requeueAfter = loadtest.PollInterval
+	}
+	if demoRunning && demoworkload.PollInterval < requeueAfter {
+		requeueAfter = demoworkload.PollInterval
+	}
+
+	// 12. Update status
 	if err := r.updateHeadlessServiceStatus(ctx, headlessService, log); err != nil {
 		log.Error(err, "failed to update status")
 		return ctrl.Result{}, err
 	}
 
-	// 7. Update metrics
+	// 13. Update metrics
 	metrics.UpdateHeadlessServiceMetrics(headlessService)
 
 	log.Info("successfully reconciled HeadlessService")
-	return ctrl.Result{RequeueAfter: time.Minute * 2}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
-// reconcileKubernetesService creates or updates the underlying Kubernetes Service
+// reconcileKubernetesService applies the underlying Kubernetes Service
 func (r *HeadlessServiceReconciler) reconcileKubernetesService(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
 	// Create the Kubernetes Service object
 	service := &corev1.Service{
@@ -144,8 +280,9 @@ func (r *HeadlessServiceReconciler) reconcileKubernetesService(ctx context.Conte
 		},
 		Spec: corev1.ServiceSpec{
 			ClusterIP: "None", // This makes it a Headless Service
-			Selector:  headlessService.Spec.Selector,
-			Ports:     convertServicePorts(headlessService.Spec.Ports),
+			// No selector: the operator publishes the EndpointSlices of the Service, so the
+			// endpoint controllers of Kubernetes must leave it alone
+			Ports: serviceports.ToCore(headlessService.Spec.Ports, corev1.ServiceTypeClusterIP),
 		},
 	}
 
@@ -154,26 +291,9 @@ func (r *HeadlessServiceReconciler) reconcileKubernetesService(ctx context.Conte
 		service.Annotations = headlessService.Annotations
 	}
 
-	// Create or update the service
-	if err := r.Create(ctx, service); err != nil {
-		if errors.IsAlreadyExists(err) {
-			// Update existing service
-			existingService := &corev1.Service{}
-			if err := r.Get(ctx, types.NamespacedName{Name: service.Name, Namespace: service.Namespace}, existingService); err != nil {
-				return err
-			}
-			
-			// Update the service spec
-			existingService.Spec = service.Spec
-			existingService.Labels = service.Labels
-			existingService.Annotations = service.Annotations
-			
-			if err := r.Update(ctx, existingService); err != nil {
-				return err
-			}
-		} else {
-			return err
-		}
+	// Apply only the fields above, leaving those of other writers alone
+	if err := apply.Apply(ctx, r.Client, service); err != nil {
+		return err
 	}
 
 	log.Info("successfully reconciled Kubernetes Service", "name", service.Name)
@@ -183,6 +303,11 @@ func (r *HeadlessServiceReconciler) reconcileKubernetesService(ctx context.Conte
 // reconcileEndpoints manages endpoints for the headless service
 func (r *HeadlessServiceReconciler) reconcileEndpoints(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
 	endpointManager := endpoints.NewManager(r.Client)
+
+	// Publish external backends independent of the pods the selector matches
+	if err := endpointManager.ReconcileExternal(ctx, headlessService); err != nil {
+		return fmt.Errorf("failed to publish external endpoints: %w", err)
+	}
 	
 	// Get pods that match the selector
 	pods, err := endpointManager.GetMatchingPods(ctx, headlessService.Namespace, headlessService.Spec.Selector)
@@ -190,100 +315,425 @@ func (r *HeadlessServiceReconciler) reconcileEndpoints(ctx context.Context, head
 		return fmt.Errorf("failed to get matching pods: %w", err)
 	}
 
-	// Create or update endpoints
-	endpoints, err := endpointManager.CreateEndpoints(ctx, headlessService, pods)
+	// Keep the current endpoints rather than growing them to an oversized pod set
+	limit := endpoints.MaxMatchedPods(headlessService, r.MaxMatchedPods)
+	if endpoints.CheckScope(headlessService, len(pods), limit) {
+		log.Info("selector matches too many pods, not updating endpoints", "matched", len(pods), "limit", limit)
+		return nil
+	}
+
+	// Let the hook of the service choose the pods that become endpoints
+	pods, annotations := r.EndpointFilter.Apply(ctx, headlessService, pods, time.Now())
+	if filter := headlessService.Status.EndpointFilter; filter != nil {
+		log.Info("filtered endpoints", "candidates", filter.Candidates, "admitted", filter.Admitted, "error", filter.Error)
+	}
+
+	// Publish the pods in EndpointSlices, replacing the Endpoints object of earlier releases
+	slices, err := endpointManager.ReconcileSlices(ctx, headlessService, pods, annotations)
 	if err != nil {
-		return fmt.Errorf("failed to create endpoints: %w", err)
+		return fmt.Errorf("failed to reconcile endpoint slices: %w", err)
+	}
+	if err := endpointManager.DeleteLegacyEndpoints(ctx, headlessService); err != nil {
+		return fmt.Errorf("failed to delete legacy endpoints: %w", err)
 	}
 
-	// Update status with endpoint information
-	headlessService.Status.Endpoints = make([]string, len(endpoints.Subsets[0].Addresses))
-	for i, address := range endpoints.Subsets[0].Addresses {
-		headlessService.Status.Endpoints[i] = address.IP
+	// Report the addresses cluster DNS answers with
+	headlessService.Status.Endpoints = endpoints.ReadyAddresses(slices)
+
+	log.Info("successfully reconciled endpoints", "count", len(pods), "ready", len(headlessService.Status.Endpoints), "slices", len(slices))
+	return nil
+}
+
+// reconcileFederation publishes the DNS view merged from the endpoints of this and the member
+// clusters, removing it once federation is no longer requested
+func (r *HeadlessServiceReconciler) reconcileFederation(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
+	federationManager := federation.NewManager(r.Client, r.Scheme)
+
+	if headlessService.Spec.Federation == nil {
+		return federationManager.Cleanup(ctx, headlessService)
+	}
+
+	if err := federationManager.Reconcile(ctx, headlessService); err != nil {
+		return err
 	}
 
-	log.Info("successfully reconciled endpoints", "count", len(pods))
+	status := headlessService.Status.Federation
+	log.Info("successfully published federated DNS view", "configMap", status.ConfigMapName, "endpoints", status.Endpoints, "clusters", len(status.Clusters))
 	return nil
 }
 
-// reconcileDNS configures DNS resolution for the headless service
-func (r *HeadlessServiceReconciler) reconcileDNS(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
+// reconcileDNS tests DNS resolution for the headless service when the next test is due and
+// returns how long until the following one
+func (r *HeadlessServiceReconciler) reconcileDNS(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) (time.Duration, error) {
 	if headlessService.Spec.DNS == nil {
-		return nil
+		return 0, nil
+	}
+
+	policy := dns.PolicyFor(headlessService.Spec.DNS)
+	previous := headlessService.Status.DNS
+	now := time.Now()
+	if !policy.Due(previous, now) {
+		return previous.LastTestedAt.Add(policy.NextInterval(previous)).Sub(now), nil
+	}
+	if wait, ok := r.allowProbe(headlessService, throttle.ProbeDNSTest, now, log); !ok {
+		return wait, nil
 	}
 
-	dnsManager := dns.NewManager(r.Client)
+	dnsManager := dns.NewManager(r.Client, r.HelperPods)
 	
 	// Test DNS resolution
 	dnsResult, err := dnsManager.TestDNSResolution(ctx, headlessService)
 	if err != nil {
 		log.Error(err, "DNS resolution test failed")
-		headlessService.Status.DNS = &k8splaygroundsv1alpha1.DNSTestResult{
+		dnsResult = &k8splaygroundsv1alpha1.DNSTestResult{
 			Success:      false,
 			ErrorMessage: err.Error(),
 		}
-	} else {
-		headlessService.Status.DNS = dnsResult
+	} else if dnsResult.Success {
 		log.Info("DNS resolution test successful", "serviceDNS", dnsResult.ServiceDNS, "resolvedIPs", len(dnsResult.ResolvedIPs))
 	}
 
-	return nil
+	// Failures of the DNS service itself count towards pausing all probes that depend on it
+	if r.Probes != nil {
+		r.Probes.Record(dnsResult.ServerError, now)
+		metrics.RecordDNSCircuit(r.Probes.State(now))
+	}
+
+	// Only flip readiness after enough consecutive results, backing off while the service stays broken
+	headlessService.Status.DNS = policy.Record(previous, dnsResult, now)
+	metrics.RecordDNSTest(headlessService.Namespace, headlessService.Name, headlessService.Status.DNS.Healthy, headlessService.Status.DNS.ConsecutiveFailures)
+	if !dnsResult.Success {
+		log.Info("DNS resolution failing", "consecutiveFailures", headlessService.Status.DNS.ConsecutiveFailures, "healthy", headlessService.Status.DNS.Healthy, "nextTestAt", headlessService.Status.DNS.NextTestAt)
+	}
+
+	return policy.NextInterval(headlessService.Status.DNS), nil
 }
 
-// reconcileServiceDiscovery configures service discovery for the headless service
-func (r *HeadlessServiceReconciler) reconcileServiceDiscovery(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
+// reconcileServiceDiscovery configures service discovery for the headless service. It returns
+// how long to wait when the discovery lookups are throttled.
+func (r *HeadlessServiceReconciler) reconcileServiceDiscovery(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) (time.Duration, error) {
 	if headlessService.Spec.ServiceDiscovery == nil {
-		return nil
+		return 0, nil
 	}
 
-	discoveryManager := servicediscovery.NewManager(r.Client)
+	discoveryManager := servicediscovery.NewManager(r.Client, r.HelperPods)
+
+	// Conformance mode relies on plain DNS only, so remove any discovery helpers
+	if headlessService.Spec.ConformanceMode {
+		return 0, discoveryManager.Cleanup(ctx, headlessService)
+	}
 	
 	// Configure service discovery based on type
 	switch headlessService.Spec.ServiceDiscovery.Type {
 	case "dns":
+		if wait, ok := r.allowProbe(headlessService, throttle.ProbeDNSDiscovery, time.Now(), log); !ok {
+			return wait, nil
+		}
 		if err := discoveryManager.ConfigureDNSDiscovery(ctx, headlessService); err != nil {
-			return fmt.Errorf("failed to configure DNS discovery: %w", err)
+			return 0, fmt.Errorf("failed to configure DNS discovery: %w", err)
 		}
 	case "api":
+		if wait, ok := r.allowProbe(headlessService, throttle.ProbeAPIDiscovery, time.Now(), log); !ok {
+			return wait, nil
+		}
 		if err := discoveryManager.ConfigureAPIDiscovery(ctx, headlessService); err != nil {
-			return fmt.Errorf("failed to configure API discovery: %w", err)
+			return 0, fmt.Errorf("failed to configure API discovery: %w", err)
 		}
 	case "custom":
 		if err := discoveryManager.ConfigureCustomDiscovery(ctx, headlessService); err != nil {
-			return fmt.Errorf("failed to configure custom discovery: %w", err)
+			return 0, fmt.Errorf("failed to configure custom discovery: %w", err)
 		}
 	default:
-		return fmt.Errorf("unsupported service discovery type: %s", headlessService.Spec.ServiceDiscovery.Type)
+		return 0, fmt.Errorf("unsupported service discovery type: %s", headlessService.Spec.ServiceDiscovery.Type)
 	}
 
 	log.Info("successfully configured service discovery", "type", headlessService.Spec.ServiceDiscovery.Type)
+	return 0, nil
+}
+
+// allowProbe asks the probe gate whether a probe of the headless service may run now. A probe
+// held back sets the ProbesThrottled condition, and the returned wait is when to ask again.
+func (r *HeadlessServiceReconciler) allowProbe(headlessService *k8splaygroundsv1alpha1.HeadlessService, probe string, now time.Time, log logr.Logger) (time.Duration, bool) {
+	if r.Probes == nil {
+		return 0, true
+	}
+
+	decision := r.Probes.Allow(probe, headlessService.Namespace, now)
+	if decision.Allowed {
+		return 0, true
+	}
+
+	metrics.RecordProbeThrottled(probe, decision.Reason)
+	meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+		Type:    throttle.ConditionProbesThrottled,
+		Status:  metav1.ConditionTrue,
+		Reason:  decision.Reason,
+		Message: decision.Message,
+	})
+	log.Info("probe throttled", "probe", probe, "reason", decision.Reason, "retryAfter", decision.RetryAfter)
+	return decision.RetryAfter, false
+}
+
+// reconcilePeerList publishes the ordered peer list of the StatefulSet governed by the
+// headless service, removing it once the peer list is no longer requested
+func (r *HeadlessServiceReconciler) reconcilePeerList(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
+	peerManager := peers.NewManager(r.Client)
+
+	if headlessService.Spec.PeerList == nil {
+		headlessService.Status.PeerList = nil
+		return peerManager.Cleanup(ctx, headlessService)
+	}
+
+	status, err := peerManager.Reconcile(ctx, headlessService)
+	if err != nil {
+		// The StatefulSet is often created after its headless service; its creation triggers
+		// another reconcile
+		if errors.IsNotFound(err) {
+			log.Info("waiting for statefulset to publish peer list", "statefulset", headlessService.Spec.PeerList.StatefulSetName)
+			headlessService.Status.PeerList = nil
+			return nil
+		}
+		return fmt.Errorf("failed to publish peer list: %w", err)
+	}
+	headlessService.Status.PeerList = status
+
+	log.Info("successfully published peer list", "configMap", status.ConfigMapName, "replicas", status.Replicas)
 	return nil
 }
 
+// statefulSetToHeadlessServices maps a StatefulSet to the headless services publishing its
+// peer list, so the list follows replica changes
+func (r *HeadlessServiceReconciler) statefulSetToHeadlessServices(ctx context.Context, obj client.Object) []reconcile.Request {
+	headlessServices := &k8splaygroundsv1alpha1.HeadlessServiceList{}
+	if err := r.List(ctx, headlessServices, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list HeadlessServices for statefulset", "statefulset", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, headlessService := range headlessServices.Items {
+		if headlessService.Spec.PeerList != nil && headlessService.Spec.PeerList.StatefulSetName == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: headlessService.Name, Namespace: headlessService.Namespace}})
+		}
+	}
+	return requests
+}
+
+// podToHeadlessServices maps a pod to the headless services whose selector matches it, so their
+// EndpointSlices follow pods as they come, go, change IP or turn ready. Updates are mapped with
+// both the old and the new pod, so a pod whose labels no longer match leaves the endpoints of
+// its previous service too.
+func (r *HeadlessServiceReconciler) podToHeadlessServices(ctx context.Context, obj client.Object) []reconcile.Request {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil
+	}
+	headlessServices := &k8splaygroundsv1alpha1.HeadlessServiceList{}
+	if err := r.List(ctx, headlessServices, client.InNamespace(pod.Namespace)); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list HeadlessServices for pod", "pod", pod.Name)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, headlessService := range headlessServices.Items {
+		if endpoints.Selects(headlessService.Spec.Selector, pod) {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: headlessService.Name, Namespace: headlessService.Namespace}})
+		}
+	}
+	return requests
+}
+
+// serviceToHeadlessServices maps a Service to the headless services mirroring it. Edits of
+// the Service are picked up at the latest by the periodic requeue.
+func (r *HeadlessServiceReconciler) serviceToHeadlessServices(ctx context.Context, obj client.Object) []reconcile.Request {
+	headlessServices := &k8splaygroundsv1alpha1.HeadlessServiceList{}
+	if err := r.List(ctx, headlessServices, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "failed to list HeadlessServices for service", "service", obj.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, headlessService := range headlessServices.Items {
+		if headlessService.Spec.Mirror != nil && headlessService.Spec.Mirror.ServiceName == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: headlessService.Name, Namespace: headlessService.Namespace}})
+		}
+	}
+	return requests
+}
+
 // reconcileIptablesProxy configures iptables proxy mode for the headless service
 func (r *HeadlessServiceReconciler) reconcileIptablesProxy(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
 	if headlessService.Spec.IptablesProxy == nil || !headlessService.Spec.IptablesProxy.Enabled {
+		r.clearRulesDrift(headlessService)
 		return nil
 	}
 
-	iptablesManager := iptables.NewManager(r.Client)
-	
+	iptablesManager := iptables.NewManager(r.Client, r.HelperPods)
+
+	// Conformance mode must show kube-proxy-less behavior, so remove any rules programmed earlier
+	if headlessService.Spec.ConformanceMode {
+		r.clearRulesDrift(headlessService)
+		return iptablesManager.CleanupHeadlessService(ctx, headlessService)
+	}
+
+	// Rules are only programmed while the IptablesProxy feature gate is enabled
+	if !features.Enabled(features.IptablesProxy) {
+		log.Info("iptables proxy requested but the IptablesProxy feature gate is disabled")
+		r.clearRulesDrift(headlessService)
+		return iptablesManager.CleanupHeadlessService(ctx, headlessService)
+	}
+
+	// Leave the programmed rules alone while the selector matches too many pods
+	if meta.IsStatusConditionTrue(headlessService.Status.Conditions, endpoints.ConditionSelectorTooBroad) {
+		log.Info("selector matches too many pods, not updating iptables rules", "matched", headlessService.Status.MatchedPods)
+		return nil
+	}
+
+	// Recommend an algorithm from the last load test; with autoSwitch the rules below use it
+	previous := headlessService.Status.AlgorithmRecommendation
+	algorithm := iptables.Advise(headlessService, time.Now())
+	if recommendation := headlessService.Status.AlgorithmRecommendation; recommendation != nil && (previous == nil || previous.Recommended != recommendation.Recommended) {
+		log.Info("load balancing algorithm recommended", "current", recommendation.Current, "recommended", recommendation.Recommended,
+			"applied", recommendation.Applied, "rationale", recommendation.Rationale)
+	}
+	headlessService.Spec.IptablesProxy.LoadBalancingAlgorithm = algorithm
+
 	// Configure iptables rules for the headless service
 	if err := iptablesManager.ConfigureHeadlessService(ctx, headlessService); err != nil {
 		return fmt.Errorf("failed to configure iptables proxy: %w", err)
 	}
 
+	// Flag nodes that have not applied the desired rules, e.g. after a hand edit of the rules
+	// ConfigMap or a missed rollout
+	divergent, err := iptablesManager.CheckRulesDrift(ctx, headlessService)
+	if err != nil {
+		return fmt.Errorf("failed to check iptables rules drift: %w", err)
+	}
+	metrics.RecordIptablesDivergentNodes(headlessService.Namespace, headlessService.Name, len(divergent))
+	threshold := iptables.DriftThreshold(headlessService.Spec.IptablesProxy)
+	wasDegraded := meta.IsStatusConditionTrue(headlessService.Status.Conditions, iptables.ConditionDegraded)
+	wasRejected := wasDegraded && meta.FindStatusCondition(headlessService.Status.Conditions, iptables.ConditionDegraded).Reason == iptables.ReasonCanaryFailed
+	if iptables.TrackCanaries(headlessService) {
+		// The nodes run the previous rules on purpose, so drift is not reported meanwhile
+		condition := meta.FindStatusCondition(headlessService.Status.Conditions, iptables.ConditionDegraded)
+		log.Info("canary rejected the desired iptables rules", "canaries", headlessService.Status.Canaries)
+		if !wasRejected {
+			r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeRulesCanaryFailed, cloudevents.Data{
+				Kind:      "HeadlessService",
+				Namespace: headlessService.Namespace,
+				Name:      headlessService.Name,
+				Reason:    iptables.ReasonCanaryFailed,
+				Message:   condition.Message,
+			}))
+		}
+	} else if iptables.TrackDrift(headlessService, divergent, threshold, time.Now()) {
+		log.Info("iptables rules diverged on nodes", "nodes", divergent, "since", headlessService.Status.RulesDrift.DivergingSince)
+		if !wasDegraded {
+			r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeRulesDriftDetected, cloudevents.Data{
+				Kind:      "HeadlessService",
+				Namespace: headlessService.Namespace,
+				Name:      headlessService.Name,
+				Reason:    iptables.ConditionDegraded,
+				Message:   "Nodes diverged from the desired iptables rules",
+				Details:   map[string]string{"nodes": strings.Join(divergent, ",")},
+			}))
+		}
+	}
+
 	log.Info("successfully configured iptables proxy", "algorithm", headlessService.Spec.IptablesProxy.LoadBalancingAlgorithm)
 	return nil
 }
 
+// clearRulesDrift drops drift, canary, endpoint limit and algorithm reporting while the iptables proxy is not running
+func (r *HeadlessServiceReconciler) clearRulesDrift(headlessService *k8splaygroundsv1alpha1.HeadlessService) {
+	iptables.ClearDrift(headlessService)
+	headlessService.Status.Canaries = nil
+	headlessService.Status.AlgorithmRecommendation = nil
+	meta.RemoveStatusCondition(&headlessService.Status.Conditions, iptables.ConditionEndpointsTruncated)
+	metrics.DeleteIptablesMetrics(headlessService.Namespace, headlessService.Name)
+}
+
+// reconcileConformance maintains the ClusterIP comparison Service and the conformance report
+// while conformance mode is enabled, and removes them otherwise
+func (r *HeadlessServiceReconciler) reconcileConformance(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
+	comparison := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dns.ComparisonServiceName(headlessService),
+			Namespace: headlessService.Namespace,
+		},
+	}
+
+	if !headlessService.Spec.ConformanceMode {
+		headlessService.Status.Conformance = nil
+		if err := r.Delete(ctx, comparison); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete comparison service: %w", err)
+		}
+		return nil
+	}
+
+	comparison.Labels = map[string]string{
+		"app.kubernetes.io/name":     "headless-service-comparison",
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+	comparison.Spec.Type = corev1.ServiceTypeClusterIP
+	comparison.Spec.Selector = headlessService.Spec.Selector
+	comparison.Spec.Ports = serviceports.ToCore(headlessService.Spec.Ports, corev1.ServiceTypeClusterIP)
+	if err := controllerutil.SetControllerReference(headlessService, comparison, r.Scheme); err != nil {
+		return err
+	}
+	// The applied Service holds the cluster IP the report compares against
+	if err := apply.Apply(ctx, r.Client, comparison); err != nil {
+		return fmt.Errorf("failed to reconcile comparison service: %w", err)
+	}
+
+	dnsManager := dns.NewManager(r.Client, r.HelperPods)
+	report := dnsManager.BuildConformanceReport(ctx, headlessService, comparison, headlessService.Status.Endpoints)
+	headlessService.Status.Conformance = report
+
+	log.Info("built conformance report", "conformant", report.Conformant, "headlessAnswers", len(report.Headless.DNSAnswers), "clusterIP", report.ClusterIP.ClusterIP)
+	return nil
+}
+
+// reconcileLoadTest starts a load test Job when the run ID changes and records the measured
+// request distribution once it finishes; it returns true while a run is in progress
+func (r *HeadlessServiceReconciler) reconcileLoadTest(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) (bool, error) {
+	previous := ""
+	if headlessService.Status.LoadTest != nil {
+		previous = headlessService.Status.LoadTest.Phase
+	}
+
+	running, err := loadtest.NewManager(r.Client, r.Scheme, r.LoadTestImage, r.ReportStore).Reconcile(ctx, headlessService)
+	if err != nil {
+		return false, err
+	}
+
+	if status := headlessService.Status.LoadTest; status != nil && status.Phase != previous {
+		log.Info("load test phase changed", "runID", status.RunID, "phase", status.Phase, "message", status.Message)
+	}
+	return running, nil
+}
+
+// reconcileDemoWorkload runs the demo pods of the headless service; it returns true while
+// demo pods run, so that their churn is followed
+func (r *HeadlessServiceReconciler) reconcileDemoWorkload(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) (bool, error) {
+	running, err := demoworkload.NewManager(r.Client, r.Scheme).Reconcile(ctx, headlessService)
+	if err != nil {
+		return false, err
+	}
+
+	if status := headlessService.Status.DemoWorkload; status != nil {
+		log.Info("reconciled demo workload", "deployment", status.Deployment, "replicas", status.Replicas, "ready", status.ReadyReplicas)
+	}
+	return running, nil
+}
+
 // reconcileDelete handles headless service deletion
 func (r *HeadlessServiceReconciler) reconcileDelete(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) (ctrl.Result, error) {
 	log.Info("reconciling HeadlessService deletion", "name", headlessService.Name)
 
 	// Clean up iptables rules
 	if headlessService.Spec.IptablesProxy != nil && headlessService.Spec.IptablesProxy.Enabled {
-		iptablesManager := iptables.NewManager(r.Client)
+		iptablesManager := iptables.NewManager(r.Client, r.HelperPods)
 		if err := iptablesManager.CleanupHeadlessService(ctx, headlessService); err != nil {
 			log.Error(err, "failed to cleanup iptables rules")
 			return ctrl.Result{RequeueAfter: time.Minute}, nil
@@ -292,16 +742,18 @@ func (r *HeadlessServiceReconciler) reconcileDelete(ctx context.Context, headles
 
 	// Clean up service discovery
 	if headlessService.Spec.ServiceDiscovery != nil {
-		discoveryManager := servicediscovery.NewManager(r.Client)
+		discoveryManager := servicediscovery.NewManager(r.Client, r.HelperPods)
 		if err := discoveryManager.Cleanup(ctx, headlessService); err != nil {
 			log.Error(err, "failed to cleanup service discovery")
 			return ctrl.Result{RequeueAfter: time.Minute}, nil
 		}
 	}
 
+	metrics.DeleteIptablesMetrics(headlessService.Namespace, headlessService.Name)
+	metrics.DeleteDNSTestMetrics(headlessService.Namespace, headlessService.Name)
+
 	// Remove finalizer
-	controllerutil.RemoveFinalizer(headlessService, k8splaygroundsv1alpha1.HeadlessServiceFinalizer)
-	if err := r.Update(ctx, headlessService); err != nil {
+	if err := patchFinalizer(ctx, r.Client, headlessService, k8splaygroundsv1alpha1.HeadlessServiceFinalizer, false); err != nil {
 		log.Error(err, "failed to remove finalizer")
 		return ctrl.Result{}, err
 	}
@@ -310,93 +762,87 @@ func (r *HeadlessServiceReconciler) reconcileDelete(ctx context.Context, headles
 	return ctrl.Result{}, nil
 }
 
-// setDefaults sets default values for the headless service
-func (r *HeadlessServiceReconciler) setDefaults(headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
-	// Set default namespace if not specified
-	if headlessService.Namespace == "" {
-		headlessService.Namespace = "default"
-	}
-
-	// Set default labels
-	if headlessService.Labels == nil {
-		headlessService.Labels = make(map[string]string)
-	}
-	headlessService.Labels["app.kubernetes.io/name"] = "headless-service"
-	headlessService.Labels["app.kubernetes.io/instance"] = headlessService.Name
-
-	// Set default DNS configuration
-	if headlessService.Spec.DNS == nil {
-		headlessService.Spec.DNS = &k8splaygroundsv1alpha1.DNSSpec{
-			ClusterDomain: "cluster.local",
-			TTL:           30,
-		}
-	}
-
-	// Set default service discovery configuration
-	if headlessService.Spec.ServiceDiscovery == nil {
-		headlessService.Spec.ServiceDiscovery = &k8splaygroundsv1alpha1.ServiceDiscoverySpec{
-			Type:            "dns",
-			RefreshInterval: 30,
-		}
-	}
-
-	// Set default iptables proxy configuration
-	if headlessService.Spec.IptablesProxy == nil {
-		headlessService.Spec.IptablesProxy = &k8splaygroundsv1alpha1.IptablesProxySpec{
-			Enabled:                true,
-			LoadBalancingAlgorithm: "random",
-			SessionAffinity:        false,
-		}
-	}
-
-	return nil
-}
-
 // updateHeadlessServiceStatus updates the headless service status
 func (r *HeadlessServiceReconciler) updateHeadlessServiceStatus(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, log logr.Logger) error {
 	// Determine phase based on status
-	phase := "Running"
+	phase := conditions.PhaseRunning
+	reason := conditions.ReasonServiceRunning
 	ready := true
 	message := "HeadlessService is running"
 
-	if headlessService.Status.DNS != nil && !headlessService.Status.DNS.Success {
-		phase = "Failed"
+	if condition := meta.FindStatusCondition(headlessService.Status.Conditions, iptables.ConditionDegraded); condition != nil && condition.Status == metav1.ConditionTrue {
+		phase = conditions.PhaseDegraded
+		reason = conditions.ReasonIptablesDiverged
+		message = "iptables rules diverged on some nodes"
+		if condition.Reason == iptables.ReasonCanaryFailed {
+			reason = conditions.ReasonCanaryFailed
+			message = condition.Message
+		}
+	}
+
+	if condition := meta.FindStatusCondition(headlessService.Status.Conditions, iptables.ConditionEndpointsTruncated); condition != nil && condition.Status == metav1.ConditionTrue {
+		phase = conditions.PhaseDegraded
+		reason = conditions.ReasonEndpointsTruncated
+		message = condition.Message
+	}
+
+	if headlessService.Status.DNS != nil && !headlessService.Status.DNS.Healthy {
+		phase = conditions.PhaseFailed
+		reason = conditions.ReasonDNSTestFailed
 		ready = false
 		message = "DNS resolution failed"
 	}
 
 	if len(headlessService.Status.Endpoints) == 0 {
-		phase = "Pending"
+		phase = conditions.PhasePending
+		reason = conditions.ReasonEndpointsEmpty
 		ready = false
 		message = "No endpoints available"
 	}
 
+	// An oversized selector explains missing or stale endpoints, so it takes precedence
+	if condition := meta.FindStatusCondition(headlessService.Status.Conditions, endpoints.ConditionSelectorTooBroad); condition != nil && condition.Status == metav1.ConditionTrue {
+		phase = conditions.PhaseDegraded
+		reason = conditions.ReasonSelectorTooBroad
+		message = condition.Message
+	}
+
 	// Update status
 	headlessService.Status.Phase = phase
 	headlessService.Status.Ready = ready
 	headlessService.Status.Message = message
+	// Degraded is owned by the iptables drift check, so only Ready is set here
+	conditions.SetReady(&headlessService.Status.Conditions, headlessService.Generation, ready, reason, message)
 
 	return r.Status().Update(ctx, headlessService)
 }
 
-// convertServicePorts converts HeadlessService ports to Kubernetes Service ports
-func convertServicePorts(ports []k8splaygroundsv1alpha1.ServicePort) []corev1.ServicePort {
-	servicePorts := make([]corev1.ServicePort, len(ports))
-	for i, port := range ports {
-		servicePorts[i] = corev1.ServicePort{
-			Name:       port.Name,
-			Port:       port.Port,
-			TargetPort: intstr.FromInt(int(port.TargetPort.IntValue())),
-			Protocol:   corev1.Protocol(port.Protocol),
-		}
-	}
-	return servicePorts
-}
-
 // SetupWithManager sets up the controller with the Manager
 func (r *HeadlessServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Serve selector and node lookups from indexes of the informer cache
+	if err := endpoints.SetupIndexes(context.Background(), mgr.GetFieldIndexer()); err != nil {
+		return err
+	}
+	r.Client = r.Recordings.Client(r.Client)
+	if r.Probes == nil {
+		r.Probes = throttle.New(throttle.Config{})
+	}
+	if r.EndpointFilter == nil {
+		r.EndpointFilter = endpoints.NewFilter()
+	}
+	// Remove the DNS test and discovery helpers that reconciles leave behind
+	if err := mgr.Add(helpergc.NewCollector(mgr.GetClient(), r.HelperGC)); err != nil {
+		return err
+	}
+	probes := r.Probes
+	runtimeconfig.Default.Subscribe(func(config runtimeconfig.Config) {
+		probes.SetRateLimits(config.Probes)
+	})
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&k8splaygroundsv1alpha1.HeadlessService{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
-		Complete(r)
+		For(&k8splaygroundsv1alpha1.HeadlessService{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		Owns(&corev1.Service{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.podToHeadlessServices), builder.WithPredicates(endpoints.PodEndpointChanged)).
+		Watches(&appsv1.StatefulSet{}, handler.EnqueueRequestsFromMapFunc(r.statefulSetToHeadlessServices), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.serviceToHeadlessServices), builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r.Recordings.Wrap("HeadlessService", r))
 }
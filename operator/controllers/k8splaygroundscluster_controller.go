@@ -10,24 +10,65 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/access"
+	"github.com/k8s-playgrounds/operator/pkg/admissionqueue"
+	"github.com/k8s-playgrounds/operator/pkg/apis/conditions"
+	"github.com/k8s-playgrounds/operator/pkg/capture"
+	"github.com/k8s-playgrounds/operator/pkg/checks"
+	"github.com/k8s-playgrounds/operator/pkg/cloudevents"
+	"github.com/k8s-playgrounds/operator/pkg/dashboard"
+	"github.com/k8s-playgrounds/operator/pkg/defaults"
+	"github.com/k8s-playgrounds/operator/pkg/diagnostics"
 	"github.com/k8s-playgrounds/operator/pkg/features"
 	"github.com/k8s-playgrounds/operator/pkg/health"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+	"github.com/k8s-playgrounds/operator/pkg/hooks"
+	"github.com/k8s-playgrounds/operator/pkg/identity"
+	"github.com/k8s-playgrounds/operator/pkg/labeling"
+	"github.com/k8s-playgrounds/operator/pkg/logging"
+	"github.com/k8s-playgrounds/operator/pkg/maintenance"
 	"github.com/k8s-playgrounds/operator/pkg/metrics"
+	"github.com/k8s-playgrounds/operator/pkg/rbac"
 	"github.com/k8s-playgrounds/operator/pkg/reconciler"
+	"github.com/k8s-playgrounds/operator/pkg/recorder"
+	"github.com/k8s-playgrounds/operator/pkg/runtimeconfig"
+	"github.com/k8s-playgrounds/operator/pkg/scheduling"
+	"github.com/k8s-playgrounds/operator/pkg/trash"
+	"github.com/k8s-playgrounds/operator/pkg/validation"
+	"github.com/k8s-playgrounds/operator/pkg/verbs"
+	"github.com/k8s-playgrounds/operator/pkg/volumeplacement"
 )
 
 // K8sPlaygroundsClusterReconciler reconciles a K8sPlaygroundsCluster object
 type K8sPlaygroundsClusterReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
-	Recorder event.Recorder
+	Recorder record.EventRecorder
+
+	// Recordings saves the inputs and outputs of every reconcile for replay; nil disables recording
+	Recordings *recorder.Recorder
+
+	// AdmissionQueue rate-limits newly created clusters; nil admits them immediately
+	AdmissionQueue *admissionqueue.Queue
+
+	// Events publishes cluster lifecycle transitions to external systems; nil disables them
+	Events *cloudevents.Emitter
+
+	// HelperPods is the operator-wide priority class, resources and per-namespace cap of the
+	// pods running command checks
+	HelperPods helperpods.Config
+
+	// APIReader reads the Warning events of managed pods without caching every event in the
+	// cluster; nil leaves events out of the status diagnostics
+	APIReader client.Reader
 }
 
 //+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=k8splaygroundsclusters,verbs=get;list;watch;create;update;patch;delete
@@ -39,7 +80,16 @@ type K8sPlaygroundsClusterReconciler struct {
 //+kubebuilder:rbac:groups=batch,resources=jobs;cronjobs,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings;clusterroles;clusterrolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts/token,verbs=create
+//+kubebuilder:rbac:groups=core,resources=pods/ephemeralcontainers,verbs=update
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=clusterroles,verbs=bind
 //+kubebuilder:rbac:groups=policy,resources=podsecuritypolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=scheduling.k8s.io,resources=priorityclasses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=node.k8s.io,resources=runtimeclasses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=list
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop
 func (r *K8sPlaygroundsClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -50,22 +100,31 @@ func (r *K8sPlaygroundsClusterReconciler) Reconcile(ctx context.Context, req ctr
 	if err := r.Get(ctx, req.NamespacedName, cluster); err != nil {
 		if errors.IsNotFound(err) {
 			log.Info("K8sPlaygroundsCluster not found, ignoring")
-			return ctrl.Result{}, nil
+			if r.AdmissionQueue != nil {
+				r.AdmissionQueue.Remove(req.NamespacedName)
+			}
+			return r.reconcileUndelete(ctx, req.NamespacedName, log)
 		}
 		log.Error(err, "unable to fetch K8sPlaygroundsCluster")
 		return ctrl.Result{}, err
 	}
 
-	// Set default values
-	if err := r.setDefaults(cluster); err != nil {
-		log.Error(err, "failed to set defaults")
+	if _, stop, err := handleVerbs(ctx, r.Client, cluster); stop {
 		return ctrl.Result{}, err
 	}
 
+	// Fill the spec from a live namespace when capture is requested
+	if source, ok := cluster.Annotations[capture.CaptureAnnotation]; ok {
+		return r.reconcileCapture(ctx, cluster, source, log)
+	}
+
+	// Fill in what the spec leaves unset in memory only; the defaulting webhook persists the
+	// defaults, so reconciling never changes the spec
+	defaults.Cluster(cluster)
+
 	// Add finalizer if not present
 	if !controllerutil.ContainsFinalizer(cluster, k8splaygroundsv1alpha1.K8sPlaygroundsClusterFinalizer) {
-		controllerutil.AddFinalizer(cluster, k8splaygroundsv1alpha1.K8sPlaygroundsClusterFinalizer)
-		if err := r.Update(ctx, cluster); err != nil {
+		if err := patchFinalizer(ctx, r.Client, cluster, k8splaygroundsv1alpha1.K8sPlaygroundsClusterFinalizer, true); err != nil {
 			log.Error(err, "failed to add finalizer")
 			return ctrl.Result{}, err
 		}
@@ -73,9 +132,62 @@ func (r *K8sPlaygroundsClusterReconciler) Reconcile(ctx context.Context, req ctr
 
 	// Handle deletion
 	if !cluster.DeletionTimestamp.IsZero() {
+		if r.AdmissionQueue != nil {
+			r.AdmissionQueue.Remove(req.NamespacedName)
+		}
 		return r.reconcileDelete(ctx, cluster, log)
 	}
 
+	// Reject malformed resource quantities before anything reaches the API server
+	if err := validation.NormalizeClusterResources(&cluster.Spec); err != nil {
+		log.Error(err, "invalid resource requirements")
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, fmt.Sprintf("Invalid resource requirements: %v", err)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, err
+		}
+		// Wait for the spec to be fixed; the update will trigger a new reconcile
+		return ctrl.Result{}, nil
+	}
+
+	// Render ConfigMap templates and reject broken config before any pod mounts it
+	if err := validation.RenderConfigMaps(cluster); err != nil {
+		log.Error(err, "invalid ConfigMap data")
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, fmt.Sprintf("Invalid ConfigMap data: %v", err)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Reject checks that cannot be probed, such as checks of Services the cluster does not manage
+	if err := checks.Validate(cluster); err != nil {
+		log.Error(err, "invalid health checks")
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, fmt.Sprintf("Invalid health checks: %v", err)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Park the cluster until the admission queue lets it through
+	if r.AdmissionQueue != nil && admissionqueue.Pending(cluster) {
+		admitted, position, retryAfter := r.AdmissionQueue.Admit(req.NamespacedName, cluster.CreationTimestamp.Time, time.Now())
+		if !admitted {
+			log.Info("cluster queued for admission", "position", position, "retryAfter", retryAfter)
+			cluster.Status.QueuePosition = int32(position)
+			if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseQueued, fmt.Sprintf("Waiting for admission, position %d", position)); err != nil {
+				log.Error(err, "failed to update cluster status")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+	}
+	if cluster.Status.AdmittedAt == nil {
+		now := metav1.Now()
+		cluster.Status.AdmittedAt = &now
+		cluster.Status.QueuePosition = 0
+	}
+
 	// Reconcile the cluster
 	return r.reconcileCluster(ctx, cluster, log)
 }
@@ -83,6 +195,7 @@ func (r *K8sPlaygroundsClusterReconciler) Reconcile(ctx context.Context, req ctr
 // reconcileCluster handles the main reconciliation logic
 func (r *K8sPlaygroundsClusterReconciler) reconcileCluster(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, log logr.Logger) (ctrl.Result, error) {
 	log.Info("reconciling K8sPlaygroundsCluster", "name", cluster.Name, "namespace", cluster.Namespace)
+	wasRunning := cluster.Status.Phase == k8splaygroundsv1alpha1.ClusterPhaseRunning
 
 	// Update status to indicate reconciliation is in progress
 	if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseUpdating, "Reconciling cluster"); err != nil {
@@ -90,12 +203,52 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileCluster(ctx context.Context,
 		return ctrl.Result{}, err
 	}
 
+	// Run preApply hooks to completion before the first apply of this revision
+	if cluster.Spec.Hooks == nil {
+		cluster.Status.Hooks = nil
+	} else if len(cluster.Spec.Hooks.PreApply) > 0 {
+		if result, done, err := r.runHooks(ctx, cluster, hooks.StagePreApply, cluster.Spec.Hooks.PreApply, log); !done {
+			return result, err
+		}
+	}
+
+	// Hold back disruptive changes until the maintenance window opens
+	plan, err := maintenance.Gate(cluster, time.Now())
+	if err != nil {
+		log.Error(err, "invalid maintenance window")
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, fmt.Sprintf("Invalid maintenance window: %v", err)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if plan.Deferred {
+		log.Info("deferring disruptive changes to the maintenance window", "deferred", len(cluster.Status.Maintenance.Deferred), "nextWindow", plan.NextWindow)
+	}
+
+	// Inject log forwarding sidecars before the workload reconcilers render pod templates
+	logging.InjectSidecars(cluster)
+
+	// Render children byte for byte the same for the same spec, so exports diff cleanly
+	labeling.Normalize(cluster)
+
+	// Add the standard labels and annotations to every resource the reconcilers render
+	for _, conflict := range labeling.Inject(cluster) {
+		log.Info("spec sets an operator-owned label or annotation", "conflict", conflict.String())
+	}
+
 	// Create reconciler for different resource types
 	reconcilers := []reconciler.Reconciler{
 		reconciler.NewNamespaceReconciler(r.Client, r.Scheme),
+		// Pods naming a priority or runtime class are rejected until it exists
+		scheduling.NewReconciler(r.Client, r.Scheme),
 		reconciler.NewServiceReconciler(r.Client, r.Scheme),
 		reconciler.NewHeadlessServiceReconciler(r.Client, r.Scheme),
+		// Zonal claims must exist before the StatefulSets create their own
+		volumeplacement.NewReconciler(r.Client, r.Scheme),
 		reconciler.NewStatefulSetReconciler(r.Client, r.Scheme),
+		// Seed jobs wait for the StatefulSets they seed to become ready
+		hooks.NewSeedRunner(r.Client, r.Scheme),
 		reconciler.NewDeploymentReconciler(r.Client, r.Scheme),
 		reconciler.NewConfigMapReconciler(r.Client, r.Scheme),
 		reconciler.NewSecretReconciler(r.Client, r.Scheme),
@@ -111,7 +264,7 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileCluster(ctx context.Context,
 
 	// Add monitoring reconciler if enabled
 	if cluster.Spec.Monitoring != nil && cluster.Spec.Monitoring.Enabled {
-		reconcilers = append(reconcilers, reconciler.NewMonitoringReconciler(r.Client, r.Scheme))
+		reconcilers = append(reconcilers, reconciler.NewMonitoringReconciler(r.Client, r.Scheme), dashboard.NewReconciler(r.Client, r.Scheme))
 	}
 
 	// Add security reconciler if enabled
@@ -119,21 +272,43 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileCluster(ctx context.Context,
 		reconcilers = append(reconcilers, reconciler.NewSecurityReconciler(r.Client, r.Scheme))
 	}
 
+	// Render OIDC role templates, or remove them once RBAC is disabled
+	reconcilers = append(reconcilers, rbac.NewReconciler(r.Client, r.Scheme))
+
 	// Add backup reconciler if enabled
 	if cluster.Spec.Backup != nil && cluster.Spec.Backup.Enabled {
 		reconcilers = append(reconcilers, reconciler.NewBackupReconciler(r.Client, r.Scheme))
 	}
 
-	// Add auto-healing reconciler if enabled
+	// Add auto-healing reconciler if enabled and allowed by the AutoHealing feature gate
 	if cluster.Spec.AutoHealing != nil && cluster.Spec.AutoHealing.Enabled {
-		reconcilers = append(reconcilers, reconciler.NewAutoHealingReconciler(r.Client, r.Scheme))
+		if features.Enabled(features.AutoHealing) {
+			reconcilers = append(reconcilers, reconciler.NewAutoHealingReconciler(r.Client, r.Scheme))
+		} else {
+			log.Info("auto-healing requested but the AutoHealing feature gate is disabled")
+		}
 	}
 
-	// Add performance reconciler if enabled
-	if cluster.Spec.Performance != nil && cluster.Spec.Performance.Enabled {
+	// Add performance reconciler if enabled and its resource changes are not deferred
+	if cluster.Spec.Performance != nil && cluster.Spec.Performance.Enabled && !plan.SkipResourceOptimization {
 		reconcilers = append(reconcilers, reconciler.NewPerformanceReconciler(r.Client, r.Scheme))
 	}
 
+	// Add logging reconciler, which also removes the log forwarder once logging is disabled
+	reconcilers = append(reconcilers, logging.NewReconciler(r.Client, r.Scheme))
+
+	// Add access reconciler if enabled
+	if cluster.Spec.Access != nil && cluster.Spec.Access.Enabled {
+		reconcilers = append(reconcilers, access.NewReconciler(r.Client, r.Scheme))
+	}
+
+	// Capture the state of failing pods before auto-healing restarts them
+	var debugCaptures []k8splaygroundsv1alpha1.DebugCapture
+	debugPending := false
+	if debugContainersEnabled(cluster) {
+		debugCaptures, debugPending = r.debugFailingPods(ctx, cluster, log)
+	}
+
 	// Execute all reconcilers
 	var reconcileErrors []error
 	for _, reconciler := range reconcilers {
@@ -143,14 +318,23 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileCluster(ctx context.Context,
 		}
 	}
 
+	// Record why pods restart or fail, especially when the reconcile failed
+	r.collectDiagnostics(ctx, cluster, debugCaptures, log)
+
+	// Map the StatefulSet ordinals to their pods, claims, volumes and nodes
+	r.observeIdentities(ctx, cluster, log)
+
 	// Check if any reconcilers failed
 	if len(reconcileErrors) > 0 {
 		log.Error(fmt.Errorf("reconciliation failed"), "multiple reconcilers failed", "errors", reconcileErrors)
+		cluster.Status.ReconcileErrors++
+		cluster.Status.LastReconcileError = utilerrors.NewAggregate(reconcileErrors).Error()
 		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, "Reconciliation failed"); err != nil {
 			log.Error(err, "failed to update cluster status")
 		}
 		return ctrl.Result{RequeueAfter: time.Minute}, nil
 	}
+	plan.Commit(cluster)
 
 	// Update cluster health
 	clusterHealth, err := r.checkClusterHealth(ctx, cluster)
@@ -159,30 +343,107 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileCluster(ctx context.Context,
 		return ctrl.Result{}, err
 	}
 
+	// Run postApply hooks once every component is ready
+	if clusterHealth == k8splaygroundsv1alpha1.ClusterHealthHealthy && cluster.Spec.Hooks != nil && len(cluster.Spec.Hooks.PostApply) > 0 {
+		if result, done, err := r.runHooks(ctx, cluster, hooks.StagePostApply, cluster.Spec.Hooks.PostApply, log); !done {
+			return result, err
+		}
+	}
+
 	// Update status based on health
 	phase := k8splaygroundsv1alpha1.ClusterPhaseRunning
 	message := "Cluster is running"
-	if clusterHealth != k8splaygroundsv1alpha1.ClusterHealthHealthy {
+	switch clusterHealth {
+	case k8splaygroundsv1alpha1.ClusterHealthHealthy:
+	case k8splaygroundsv1alpha1.ClusterHealthDegraded:
+		// Failing warning checks are reported without failing the cluster
+		message = "Cluster is degraded"
+	default:
 		phase = k8splaygroundsv1alpha1.ClusterPhaseFailed
 		message = "Cluster is unhealthy"
 	}
 
+	cluster.Status.ReconcileErrors = 0
+	cluster.Status.LastReconcileError = ""
 	if err := r.updateClusterStatus(ctx, cluster, phase, message); err != nil {
 		log.Error(err, "failed to update cluster status")
 		return ctrl.Result{}, err
 	}
+	if phase == k8splaygroundsv1alpha1.ClusterPhaseRunning && !wasRunning {
+		r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeClusterReady, cloudevents.Data{
+			Kind:      "K8sPlaygroundsCluster",
+			Namespace: cluster.Namespace,
+			Name:      cluster.Name,
+			Reason:    string(phase),
+			Message:   message,
+			Details:   map[string]string{"version": cluster.Spec.Version},
+		}))
+	}
 
 	// Update metrics
 	metrics.UpdateClusterMetrics(cluster)
 
 	log.Info("successfully reconciled K8sPlaygroundsCluster")
-	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+	requeueAfter := runtimeconfig.Current().ClusterResyncInterval
+	// Come back when the window opens to apply the deferred changes
+	if plan.Deferred && !plan.NextWindow.IsZero() {
+		if untilWindow := time.Until(plan.NextWindow); untilWindow < requeueAfter {
+			requeueAfter = untilWindow
+		}
+	}
+	// Come back when the next health check is due
+	if untilCheck := checks.RequeueAfter(cluster.Status.Checks, time.Now()); untilCheck > 0 && untilCheck < requeueAfter {
+		requeueAfter = untilCheck
+	}
+	// Come back for seed jobs waiting for their StatefulSet or still running
+	if hooks.SeedPending(cluster.Status.SeedJobs) && hooks.PollInterval < requeueAfter {
+		requeueAfter = hooks.PollInterval
+	}
+	// Come back for what the running debug containers collect
+	if debugPending && diagnostics.DebugPollInterval < requeueAfter {
+		requeueAfter = diagnostics.DebugPollInterval
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// runHooks advances the hooks of a stage and reports whether reconciliation may continue.
+// A failed hook with the abort policy fails the cluster until the next revision.
+func (r *K8sPlaygroundsClusterReconciler) runHooks(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, stage string, specs []k8splaygroundsv1alpha1.HookSpec, log logr.Logger) (ctrl.Result, bool, error) {
+	done, err := hooks.NewRunner(r.Client, r.Scheme).Run(ctx, cluster, stage, specs)
+	if hooks.IsAborted(err) {
+		log.Error(err, "hook failed", "stage", stage)
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, fmt.Sprintf("Hook failed: %v", err)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, false, err
+		}
+		// Wait for a new revision; the spec update will trigger a new reconcile
+		return ctrl.Result{}, false, nil
+	}
+	if err != nil {
+		log.Error(err, "failed to run hooks", "stage", stage)
+		return ctrl.Result{}, false, err
+	}
+	if !done {
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseUpdating, fmt.Sprintf("Waiting for %s hooks", stage)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, false, err
+		}
+		return ctrl.Result{RequeueAfter: hooks.PollInterval}, false, nil
+	}
+	return ctrl.Result{}, true, nil
 }
 
 // reconcileDelete handles cluster deletion
 func (r *K8sPlaygroundsClusterReconciler) reconcileDelete(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, log logr.Logger) (ctrl.Result, error) {
 	log.Info("reconciling K8sPlaygroundsCluster deletion", "name", cluster.Name)
 
+	// Keep a soft-deleted cluster in the Trash phase until it is undeleted or its retention expires
+	if trash.Enabled(cluster) {
+		if result, done, err := r.reconcileTrash(ctx, cluster, log); !done {
+			return result, err
+		}
+	}
+
 	// Update status to indicate deletion is in progress
 	if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseDeleting, "Deleting cluster"); err != nil {
 		log.Error(err, "failed to update cluster status")
@@ -196,6 +457,7 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileDelete(ctx context.Context, c
 		reconciler.NewDaemonSetReconciler(r.Client, r.Scheme),
 		reconciler.NewCronJobReconciler(r.Client, r.Scheme),
 		reconciler.NewJobReconciler(r.Client, r.Scheme),
+		hooks.NewSeedRunner(r.Client, r.Scheme),
 		reconciler.NewPersistentVolumeReconciler(r.Client, r.Scheme),
 		reconciler.NewIngressReconciler(r.Client, r.Scheme),
 		reconciler.NewNetworkPolicyReconciler(r.Client, r.Scheme),
@@ -203,11 +465,33 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileDelete(ctx context.Context, c
 		reconciler.NewConfigMapReconciler(r.Client, r.Scheme),
 		reconciler.NewDeploymentReconciler(r.Client, r.Scheme),
 		reconciler.NewStatefulSetReconciler(r.Client, r.Scheme),
+		// Claims placed per zone outlive their StatefulSet, so they are deleted explicitly
+		volumeplacement.NewReconciler(r.Client, r.Scheme),
 		reconciler.NewHeadlessServiceReconciler(r.Client, r.Scheme),
 		reconciler.NewServiceReconciler(r.Client, r.Scheme),
 		reconciler.NewNamespaceReconciler(r.Client, r.Scheme),
+		// Priority and runtime classes are cluster-scoped and outlive the namespace
+		scheduling.NewReconciler(r.Client, r.Scheme),
 	}
 
+	// Clean up log forwarding before the workloads it collects from, even if logging was disabled
+	// since it was last reconciled
+	cleanupReconcilers = append([]reconciler.Reconciler{logging.NewReconciler(r.Client, r.Scheme)}, cleanupReconcilers...)
+
+	// Remove the Grafana dashboard of the cluster
+	if cluster.Spec.Monitoring != nil && cluster.Spec.Monitoring.Enabled {
+		cleanupReconcilers = append([]reconciler.Reconciler{dashboard.NewReconciler(r.Client, r.Scheme)}, cleanupReconcilers...)
+	}
+
+	// Revoke issued credentials before tearing down the namespace
+	if cluster.Spec.Access != nil && cluster.Spec.Access.Enabled {
+		cleanupReconcilers = append([]reconciler.Reconciler{access.NewReconciler(r.Client, r.Scheme)}, cleanupReconcilers...)
+	}
+
+	// Remove OIDC role bindings, which may live outside the cluster namespace and so are not
+	// garbage collected; RBAC may have been disabled since they were rendered
+	cleanupReconcilers = append([]reconciler.Reconciler{rbac.NewReconciler(r.Client, r.Scheme)}, cleanupReconcilers...)
+
 	// Execute cleanup reconcilers
 	var cleanupErrors []error
 	for _, reconciler := range cleanupReconcilers {
@@ -223,43 +507,171 @@ func (r *K8sPlaygroundsClusterReconciler) reconcileDelete(ctx context.Context, c
 		return ctrl.Result{RequeueAfter: time.Minute}, nil
 	}
 
+	metrics.DeleteClusterCheckMetrics(cluster.Namespace, cluster.Name)
+
 	// Remove finalizer
-	controllerutil.RemoveFinalizer(cluster, k8splaygroundsv1alpha1.K8sPlaygroundsClusterFinalizer)
-	if err := r.Update(ctx, cluster); err != nil {
+	if err := patchFinalizer(ctx, r.Client, cluster, k8splaygroundsv1alpha1.K8sPlaygroundsClusterFinalizer, false); err != nil {
 		log.Error(err, "failed to remove finalizer")
 		return ctrl.Result{}, err
 	}
 
+	if trash.Enabled(cluster) {
+		r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeClusterPurged, cloudevents.Data{
+			Kind:      "K8sPlaygroundsCluster",
+			Namespace: cluster.Namespace,
+			Name:      cluster.Name,
+			Reason:    conditions.ReasonRetentionExpired,
+			Message:   fmt.Sprintf("Cluster deleted at %s was purged", cluster.DeletionTimestamp.UTC().Format(time.RFC3339)),
+		}))
+	}
+
 	log.Info("successfully deleted K8sPlaygroundsCluster")
 	return ctrl.Result{}, nil
 }
 
-// setDefaults sets default values for the cluster
-func (r *K8sPlaygroundsClusterReconciler) setDefaults(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
-	// Set default version if not specified
-	if cluster.Spec.Version == "" {
-		cluster.Spec.Version = "latest"
+// reconcileTrash keeps a deleted cluster with its workloads stopped until the retention expires
+// and reports whether the deletion may proceed. An undelete releases the resources of the
+// cluster, saves it and lets the deleted object go; reconcileUndelete then recreates it.
+func (r *K8sPlaygroundsClusterReconciler) reconcileTrash(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, log logr.Logger) (ctrl.Result, bool, error) {
+	m := trash.NewManager(r.Client)
+
+	if trash.UndeleteRequested(cluster) {
+		log.Info("undeleting cluster from the trash")
+		// The saved replicas and the workloads stopped are only known to the deleted cluster, so
+		// they are restored before it goes
+		if cluster.Status.Trash != nil {
+			if err := m.Resume(ctx, cluster.Status.Trash.ScaledDown); err != nil {
+				log.Error(err, "failed to resume cluster workloads")
+				return ctrl.Result{}, false, err
+			}
+		}
+		if err := m.Release(ctx, cluster); err != nil {
+			log.Error(err, "failed to release cluster resources")
+			return ctrl.Result{}, false, err
+		}
+		if err := m.SaveRecord(ctx, cluster); err != nil {
+			log.Error(err, "failed to save cluster for undelete")
+			return ctrl.Result{}, false, err
+		}
+		if err := patchFinalizer(ctx, r.Client, cluster, k8splaygroundsv1alpha1.K8sPlaygroundsClusterFinalizer, false); err != nil {
+			log.Error(err, "failed to remove finalizer")
+			return ctrl.Result{}, false, err
+		}
+		return ctrl.Result{}, false, nil
 	}
 
-	// Set default replicas if not specified
-	if cluster.Spec.Replicas == 0 {
-		cluster.Spec.Replicas = 3
+	purgeAt := trash.PurgeAt(cluster)
+	now := time.Now()
+	if !now.Before(purgeAt) {
+		log.Info("trash retention expired, purging cluster", "deletedAt", cluster.DeletionTimestamp.Time)
+		return ctrl.Result{}, true, nil
 	}
 
-	// Set default namespace if not specified
-	if cluster.Namespace == "" {
-		cluster.Namespace = "default"
+	stopped, err := m.ScaleDown(ctx, cluster)
+	if err != nil {
+		log.Error(err, "failed to stop cluster workloads")
+		return ctrl.Result{}, false, err
+	}
+	trashed := cluster.Status.Trash == nil
+	if trashed {
+		cluster.Status.Trash = &k8splaygroundsv1alpha1.TrashStatus{DeletedAt: *cluster.DeletionTimestamp, PurgeAt: metav1.NewTime(purgeAt)}
+	}
+	cluster.Status.Trash.PurgeAt = metav1.NewTime(purgeAt)
+	cluster.Status.Trash.ScaledDown = trash.Merge(cluster.Status.Trash.ScaledDown, stopped)
+	message := fmt.Sprintf("Deleted; purged at %s unless annotated with %s=true", purgeAt.UTC().Format(time.RFC3339), trash.UndeleteAnnotation)
+	if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseTrash, message); err != nil {
+		log.Error(err, "failed to update cluster status")
+		return ctrl.Result{}, false, err
+	}
+	if trashed {
+		log.Info("cluster moved to the trash", "purgeAt", purgeAt, "stopped", len(stopped))
+		r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeClusterTrashed, cloudevents.Data{
+			Kind:      "K8sPlaygroundsCluster",
+			Namespace: cluster.Namespace,
+			Name:      cluster.Name,
+			Reason:    string(k8splaygroundsv1alpha1.ClusterPhaseTrash),
+			Message:   message,
+			Details:   map[string]string{"purgeAt": purgeAt.UTC().Format(time.RFC3339)},
+		}))
 	}
 
-	// Set default labels
-	if cluster.Labels == nil {
-		cluster.Labels = make(map[string]string)
+	requeueAfter := purgeAt.Sub(now)
+	if requeueAfter > trash.PollInterval {
+		requeueAfter = trash.PollInterval
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, false, nil
+}
+
+// reconcileUndelete recreates a cluster saved by an undelete once the deleted object is gone
+func (r *K8sPlaygroundsClusterReconciler) reconcileUndelete(ctx context.Context, key types.NamespacedName, log logr.Logger) (ctrl.Result, error) {
+	m := trash.NewManager(r.Client)
+	cluster, err := m.Restore(ctx, key)
+	if err != nil {
+		log.Error(err, "failed to read undelete record")
+		return ctrl.Result{}, err
+	}
+	if cluster == nil {
+		log.Info("K8sPlaygroundsCluster not found, ignoring")
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Create(ctx, cluster); err != nil && !errors.IsAlreadyExists(err) {
+		log.Error(err, "failed to recreate undeleted cluster")
+		return ctrl.Result{}, err
+	}
+	if err := m.DeleteRecord(ctx, key); err != nil {
+		log.Error(err, "failed to delete undelete record")
+		return ctrl.Result{}, err
 	}
-	cluster.Labels["app.kubernetes.io/name"] = "k8s-playgrounds-cluster"
-	cluster.Labels["app.kubernetes.io/instance"] = cluster.Name
-	cluster.Labels["app.kubernetes.io/version"] = cluster.Spec.Version
 
-	return nil
+	log.Info("undeleted cluster from the trash")
+	r.Events.Emit(ctx, cloudevents.New(cloudevents.TypeClusterRestored, cloudevents.Data{
+		Kind:      "K8sPlaygroundsCluster",
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Reason:    conditions.ReasonUndeleted,
+		Message:   "Cluster was restored from the trash",
+	}))
+	return ctrl.Result{}, nil
+}
+
+// reconcileCapture replaces the cluster spec with one generated from the source namespace
+// and removes the capture annotation so the capture only happens once
+func (r *K8sPlaygroundsClusterReconciler) reconcileCapture(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, source string, log logr.Logger) (ctrl.Result, error) {
+	log.Info("capturing cluster spec from namespace", "source", source)
+
+	if err := capture.CheckSource(cluster, source); err != nil {
+		log.Error(err, "refusing to capture namespace", "source", source)
+		if err := r.updateClusterStatus(ctx, cluster, k8splaygroundsv1alpha1.ClusterPhaseFailed, fmt.Sprintf("Invalid capture source: %v", err)); err != nil {
+			log.Error(err, "failed to update cluster status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	spec, err := capture.NewCapturer(r.Client).Capture(ctx, source)
+	if err != nil {
+		log.Error(err, "failed to capture namespace", "source", source)
+		return ctrl.Result{}, err
+	}
+
+	// Keep the user-chosen version and replica count
+	if cluster.Spec.Version != "" {
+		spec.Version = cluster.Spec.Version
+	}
+	if cluster.Spec.Replicas != 0 {
+		spec.Replicas = cluster.Spec.Replicas
+	}
+	cluster.Spec = *spec
+	delete(cluster.Annotations, capture.CaptureAnnotation)
+
+	if err := r.Update(ctx, cluster); err != nil {
+		log.Error(err, "failed to update cluster with captured spec")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("captured cluster spec", "source", source)
+	return ctrl.Result{Requeue: true}, nil
 }
 
 // updateClusterStatus updates the cluster status
@@ -293,17 +705,89 @@ func (r *K8sPlaygroundsClusterReconciler) updateClusterStatus(ctx context.Contex
 	return r.Status().Update(ctx, cluster)
 }
 
-// checkClusterHealth checks the overall health of the cluster
+// checkClusterHealth checks the overall health of the cluster: the health of the managed
+// resources combined with the outcome of the health checks in the spec
 func (r *K8sPlaygroundsClusterReconciler) checkClusterHealth(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) (k8splaygroundsv1alpha1.ClusterHealth, error) {
 	// Check if all required resources are healthy
 	healthChecker := health.NewClusterHealthChecker(r.Client)
-	return healthChecker.CheckHealth(ctx, cluster)
+	resourceHealth, err := healthChecker.CheckHealth(ctx, cluster)
+	if err != nil {
+		return resourceHealth, err
+	}
+
+	// Application-level checks are evaluated independent of pod readiness
+	checkHealth, err := checks.NewManager(r.Client, r.HelperPods).Evaluate(ctx, cluster, time.Now())
+	if err != nil {
+		return checkHealth, err
+	}
+	passing := 0
+	for _, status := range cluster.Status.Checks {
+		if status.Healthy {
+			passing++
+		}
+	}
+	metrics.RecordClusterChecks(cluster.Namespace, cluster.Name, passing, len(cluster.Status.Checks)-passing)
+	clusterHealth := checks.Worse(resourceHealth, checkHealth)
+	cluster.Status.Health = clusterHealth
+	return clusterHealth, nil
+}
+
+// collectDiagnostics records the container terminations, restarts and Warning events of the
+// managed pods in the cluster status. Diagnostics are best effort and never fail a reconcile.
+func (r *K8sPlaygroundsClusterReconciler) collectDiagnostics(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, debugCaptures []k8splaygroundsv1alpha1.DebugCapture, log logr.Logger) {
+	status, err := diagnostics.NewCollector(r.Client, r.APIReader).Collect(ctx, cluster, time.Now())
+	if err != nil {
+		log.Error(err, "failed to collect diagnostics")
+		return
+	}
+	status.DebugCaptures = debugCaptures
+	cluster.Status.Diagnostics = status
+}
+
+// observeIdentities records the identity of every StatefulSet ordinal in the cluster status and
+// flags violations in the IdentityViolated condition. Like diagnostics it never fails a reconcile.
+func (r *K8sPlaygroundsClusterReconciler) observeIdentities(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, log logr.Logger) {
+	now := time.Now()
+	statuses, err := identity.NewObserver(r.Client).Observe(ctx, cluster, cluster.Status.StatefulSetIdentities, now)
+	if err != nil {
+		log.Error(err, "failed to observe StatefulSet identities")
+		return
+	}
+	cluster.Status.StatefulSetIdentities = statuses
+	identity.SetCondition(cluster, statuses, now)
+}
+
+// debugFailingPods attaches debug containers to the pods whose containers restarted and returns
+// the captures to keep in the diagnostics, and whether a debug container is still running
+func (r *K8sPlaygroundsClusterReconciler) debugFailingPods(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, log logr.Logger) ([]k8splaygroundsv1alpha1.DebugCapture, bool) {
+	var previous []k8splaygroundsv1alpha1.DebugCapture
+	if cluster.Status.Diagnostics != nil {
+		previous = cluster.Status.Diagnostics.DebugCaptures
+	}
+	debugger := diagnostics.NewDebugger(r.Client, cluster.Spec.AutoHealing.DebugContainers.Image)
+	captures, pending, err := debugger.Debug(ctx, cluster, previous, time.Now())
+	if err != nil {
+		log.Error(err, "failed to debug failing pods")
+	}
+	return captures, pending
+}
+
+// debugContainersEnabled reports whether auto-healing attaches debug containers to failing pods
+func debugContainersEnabled(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) bool {
+	autoHealing := cluster.Spec.AutoHealing
+	if autoHealing == nil || !autoHealing.Enabled || autoHealing.DebugContainers == nil || !autoHealing.DebugContainers.Enabled {
+		return false
+	}
+	return features.Enabled(features.AutoHealing)
 }
 
 // SetupWithManager sets up the controller with the Manager
 func (r *K8sPlaygroundsClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.Client = r.Recordings.Client(r.Client)
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
-		Complete(r)
+		// Annotating a trashed cluster for undelete or setting a reconcile verb does not change
+		// its generation
+		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.NewPredicateFuncs(trash.UndeleteRequested), verbs.Changed)).
+		Complete(r.Recordings.Wrap("K8sPlaygroundsCluster", r))
 }
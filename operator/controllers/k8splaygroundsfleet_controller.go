@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/fleet"
+	"github.com/k8s-playgrounds/operator/pkg/metrics"
+	"github.com/k8s-playgrounds/operator/pkg/runtimeconfig"
+	"github.com/k8s-playgrounds/operator/pkg/verbs"
+)
+
+// K8sPlaygroundsFleetReconciler reconciles a K8sPlaygroundsFleet object
+type K8sPlaygroundsFleetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=k8splaygroundsfleets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=k8splaygroundsfleets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=k8s-playgrounds.io,resources=k8splaygroundsclusters,verbs=get;list;watch
+
+// Reconcile summarizes the clusters selected by a fleet into its status and metrics
+func (r *K8sPlaygroundsFleetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName("K8sPlaygroundsFleetReconciler")
+
+	fleetObj := &k8splaygroundsv1alpha1.K8sPlaygroundsFleet{}
+	if err := r.Get(ctx, req.NamespacedName, fleetObj); err != nil {
+		if errors.IsNotFound(err) {
+			metrics.DeleteFleetMetrics(req.Name)
+			log.Info("K8sPlaygroundsFleet not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch K8sPlaygroundsFleet")
+		return ctrl.Result{}, err
+	}
+
+	if _, stop, err := handleVerbs(ctx, r.Client, fleetObj); stop {
+		return ctrl.Result{}, err
+	}
+
+	clusters, err := r.selectClusters(ctx, fleetObj)
+	if err != nil {
+		log.Error(err, "failed to select fleet clusters")
+		return ctrl.Result{}, err
+	}
+
+	now := time.Now()
+	fleetObj.Status = fleet.Summarize(fleetObj, clusters, now)
+	if err := r.Status().Update(ctx, fleetObj); err != nil {
+		log.Error(err, "failed to update K8sPlaygroundsFleet status")
+		return ctrl.Result{}, err
+	}
+
+	status := &fleetObj.Status
+	metrics.RecordFleet(fleetObj.Name, status.Phases, status.Health, int(status.FailingComponentCount), fleet.SlowestConverging(status, now).Seconds())
+
+	log.Info("summarized fleet", "clusters", status.Clusters, "converged", status.Converged, "failingComponents", status.FailingComponentCount)
+	// Refresh converging times and metrics between cluster changes
+	return ctrl.Result{RequeueAfter: runtimeconfig.Current().FleetResyncInterval}, nil
+}
+
+// selectClusters lists the clusters that belong to a fleet
+func (r *K8sPlaygroundsFleetReconciler) selectClusters(ctx context.Context, fleetObj *k8splaygroundsv1alpha1.K8sPlaygroundsFleet) ([]k8splaygroundsv1alpha1.K8sPlaygroundsCluster, error) {
+	list := &k8splaygroundsv1alpha1.K8sPlaygroundsClusterList{}
+	if err := r.List(ctx, list); err != nil {
+		return nil, err
+	}
+
+	var clusters []k8splaygroundsv1alpha1.K8sPlaygroundsCluster
+	for i := range list.Items {
+		selected, err := fleet.Selects(fleetObj, &list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if selected {
+			clusters = append(clusters, list.Items[i])
+		}
+	}
+	return clusters, nil
+}
+
+// clusterToFleets enqueues every fleet that selects a changed cluster
+func (r *K8sPlaygroundsFleetReconciler) clusterToFleets(ctx context.Context, obj client.Object) []reconcile.Request {
+	cluster, ok := obj.(*k8splaygroundsv1alpha1.K8sPlaygroundsCluster)
+	if !ok {
+		return nil
+	}
+
+	fleets := &k8splaygroundsv1alpha1.K8sPlaygroundsFleetList{}
+	if err := r.List(ctx, fleets); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range fleets.Items {
+		// Fleets that no longer select the cluster still resync on their own interval
+		if selected, err := fleet.Selects(&fleets.Items[i], cluster); err == nil && selected {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&fleets.Items[i])})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager
+func (r *K8sPlaygroundsFleetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&k8splaygroundsv1alpha1.K8sPlaygroundsFleet{}, builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, verbs.Changed))).
+		// Cluster status changes do not bump the generation, so every cluster update is relevant
+		Watches(&k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}, handler.EnqueueRequestsFromMapFunc(r.clusterToFleets)).
+		Complete(r)
+}
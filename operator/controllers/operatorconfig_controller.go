@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"aviatrix-operator/pkg/features"
+	"aviatrix-operator/pkg/runtimeconfig"
+)
+
+// Reasons of the events recorded on the configuration ConfigMap
+const (
+	ReasonConfigApplied  = "ConfigApplied"
+	ReasonConfigRejected = "ConfigRejected"
+)
+
+// OperatorConfigReconciler applies the settings of the operator configuration ConfigMap at
+// runtime. An invalid ConfigMap is rejected as a whole and the settings in effect are kept.
+type OperatorConfigReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// ConfigMap is the namespace and name of the configuration ConfigMap
+	ConfigMap types.NamespacedName
+	// Gate validates the feature gates of the ConfigMap; nil uses the default gate
+	Gate *features.Gate
+	// Store receives the applied configuration; nil uses the default store
+	Store *runtimeconfig.Store
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+// Reconcile validates the configuration ConfigMap and puts its settings into effect. A
+// deleted ConfigMap reverts every setting to its flag or default.
+func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName("OperatorConfigReconciler")
+
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, req.NamespacedName, configMap); err != nil {
+		if errors.IsNotFound(err) {
+			if changed := r.Store.Apply(runtimeconfig.Defaults()); len(changed) > 0 {
+				log.Info("configuration ConfigMap removed, reverted settings", "keys", changed)
+			}
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "unable to fetch configuration ConfigMap")
+		return ctrl.Result{}, err
+	}
+
+	// Retrying cannot fix an invalid ConfigMap; the next change of it is reconciled again
+	config, err := runtimeconfig.Parse(configMap.Data, r.Gate)
+	if err != nil {
+		log.Error(err, "rejected configuration, keeping the settings in effect")
+		r.Recorder.Eventf(configMap, corev1.EventTypeWarning, ReasonConfigRejected, "Kept the settings in effect: %v", err)
+		return ctrl.Result{}, nil
+	}
+
+	changed := r.Store.Apply(config)
+	if len(changed) > 0 {
+		log.Info("applied configuration", "keys", changed, "resourceVersion", configMap.ResourceVersion)
+		r.Recorder.Eventf(configMap, corev1.EventTypeNormal, ReasonConfigApplied, "Applied %s", strings.Join(changed, ", "))
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Only the configuration ConfigMap
+// is watched.
+func (r *OperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Gate == nil {
+		r.Gate = features.DefaultGate
+	}
+	if r.Store == nil {
+		r.Store = runtimeconfig.Default
+	}
+	isConfigMap := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.ConfigMap.Namespace && obj.GetName() == r.ConfigMap.Name
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("operatorconfig").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(isConfigMap)).
+		Complete(r)
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"context"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"aviatrix-operator/pkg/verbs"
+)
+
+// handleVerbs processes the reconcile verb annotations of a fetched resource. stop is set when
+// the reconcile must end here, because reconciliation is paused or the verbs could not be
+// handled; err is then returned by the reconciler.
+func handleVerbs(ctx context.Context, c client.Client, obj client.Object) (request verbs.Request, stop bool, err error) {
+	logger := ctrl.LoggerFrom(ctx)
+
+	request, err = verbs.Handle(ctx, c, obj)
+	if err != nil {
+		logger.Error(err, "failed to handle reconcile verbs")
+		return request, true, err
+	}
+	if request.Paused {
+		logger.Info("reconciliation paused, leaving the resource alone", "annotation", verbs.ReconcilePausedAnnotation)
+		return request, true, nil
+	}
+	if request.ForceResync || request.RefreshStatus {
+		logger.Info("reconciling on request", "forceResync", request.ForceResync, "refreshStatus", request.RefreshStatus)
+	}
+	return request, false, nil
+}
@@ -0,0 +1,288 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+const (
+	// KubeconfigKey is the key of the kubeconfig in the generated Secret
+	KubeconfigKey = "kubeconfig"
+	// ExpiresAtAnnotation records when the token embedded in the kubeconfig expires
+	ExpiresAtAnnotation = "k8s-playgrounds.io/token-expires-at"
+
+	// DefaultRole is the ClusterRole bound when the spec does not name one
+	DefaultRole = "edit"
+	// DefaultServer is the API server address written to the kubeconfig when none is configured
+	DefaultServer = "https://kubernetes.default.svc"
+	// DefaultTokenExpiration is the lifetime of issued tokens when none is configured
+	DefaultTokenExpiration = 24 * time.Hour
+
+	// rootCAConfigMap is published by Kubernetes in every namespace with the cluster CA
+	rootCAConfigMap = "kube-root-ca.crt"
+)
+
+// allowedRoles are the built-in ClusterRoles that may be bound to playground users
+var allowedRoles = map[string]bool{
+	"view":  true,
+	"edit":  true,
+	"admin": true,
+}
+
+// AllowedRole reports whether role is one of the built-in ClusterRoles that may be bound to
+// playground users
+func AllowedRole(role string) bool {
+	return allowedRoles[role]
+}
+
+// Reconciler issues namespaced credentials for a cluster: a ServiceAccount, a RoleBinding to
+// a built-in ClusterRole and a Secret holding a ready-to-use kubeconfig
+type Reconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewReconciler creates a new access reconciler
+func NewReconciler(client client.Client, scheme *runtime.Scheme) *Reconciler {
+	return &Reconciler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Reconcile ensures the access ServiceAccount and RoleBinding exist and that the kubeconfig
+// Secret holds a token that is not close to expiring
+func (r *Reconciler) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	log := logr.FromContextOrDiscard(ctx)
+	access := cluster.Spec.Access
+
+	role := access.Role
+	if role == "" {
+		role = DefaultRole
+	}
+	if !AllowedRole(role) {
+		return fmt.Errorf("unsupported access role %q, must be one of view, edit or admin", role)
+	}
+
+	name := ServiceAccountName(cluster)
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cluster.Namespace, Labels: labels(cluster)},
+	}
+	if err := controllerutil.SetControllerReference(cluster, serviceAccount, r.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, r.client, serviceAccount); err != nil {
+		return fmt.Errorf("failed to reconcile access service account: %w", err)
+	}
+
+	if err := r.reconcileRoleBinding(ctx, cluster, role); err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: SecretName(cluster), Namespace: cluster.Namespace},
+	}
+	err := r.client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, secret)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get kubeconfig secret: %w", err)
+	}
+
+	ttl := tokenExpiration(access)
+	if err == nil && !needsRefresh(secret, ttl, time.Now()) {
+		cluster.Status.KubeconfigSecret = secret.Name
+		return nil
+	}
+
+	kubeconfig, expiresAt, err := r.buildKubeconfig(ctx, cluster, serviceAccount, ttl)
+	if err != nil {
+		return err
+	}
+
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        SecretName(cluster),
+			Namespace:   cluster.Namespace,
+			Labels:      labels(cluster),
+			Annotations: map[string]string{ExpiresAtAnnotation: expiresAt.UTC().Format(time.RFC3339)},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{KubeconfigKey: kubeconfig},
+	}
+	if err := controllerutil.SetControllerReference(cluster, secret, r.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, r.client, secret); err != nil {
+		return fmt.Errorf("failed to reconcile kubeconfig secret: %w", err)
+	}
+
+	cluster.Status.KubeconfigSecret = secret.Name
+	log.Info("issued playground kubeconfig", "secret", secret.Name, "role", role, "expiresAt", expiresAt)
+	return nil
+}
+
+// Cleanup removes the kubeconfig Secret, RoleBinding and ServiceAccount of the cluster
+func (r *Reconciler) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	objects := []client.Object{
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: SecretName(cluster), Namespace: cluster.Namespace}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: ServiceAccountName(cluster), Namespace: cluster.Namespace}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: ServiceAccountName(cluster), Namespace: cluster.Namespace}},
+	}
+	for _, obj := range objects {
+		if err := r.client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete access %T: %w", obj, err)
+		}
+	}
+	return nil
+}
+
+// reconcileRoleBinding binds the access ServiceAccount to the requested ClusterRole in the cluster namespace
+func (r *Reconciler) reconcileRoleBinding(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, role string) error {
+	roleBinding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: ServiceAccountName(cluster), Namespace: cluster.Namespace},
+	}
+
+	// The role reference is immutable, so recreate the binding when the role changes
+	err := r.client.Get(ctx, types.NamespacedName{Name: roleBinding.Name, Namespace: roleBinding.Namespace}, roleBinding)
+	if err == nil && roleBinding.RoleRef.Name != role {
+		if err := r.client.Delete(ctx, roleBinding); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to replace access role binding: %w", err)
+		}
+	} else if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get access role binding: %w", err)
+	}
+
+	roleBinding = &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: ServiceAccountName(cluster), Namespace: cluster.Namespace, Labels: labels(cluster)},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     role,
+		},
+		Subjects: []rbacv1.Subject{
+			{
+				Kind:      rbacv1.ServiceAccountKind,
+				Name:      ServiceAccountName(cluster),
+				Namespace: cluster.Namespace,
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(cluster, roleBinding, r.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, r.client, roleBinding); err != nil {
+		return fmt.Errorf("failed to reconcile access role binding: %w", err)
+	}
+	return nil
+}
+
+// buildKubeconfig requests a bound token for the ServiceAccount and renders a kubeconfig scoped
+// to the cluster namespace
+func (r *Reconciler) buildKubeconfig(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, serviceAccount *corev1.ServiceAccount, ttl time.Duration) ([]byte, time.Time, error) {
+	expirationSeconds := int64(ttl.Seconds())
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	if err := r.client.SubResource("token").Create(ctx, serviceAccount, tokenRequest); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to request service account token: %w", err)
+	}
+
+	caConfigMap := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, types.NamespacedName{Name: rootCAConfigMap, Namespace: cluster.Namespace}, caConfigMap); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to get cluster CA: %w", err)
+	}
+
+	server := cluster.Spec.Access.Server
+	if server == "" {
+		server = DefaultServer
+	}
+
+	kubeconfig, err := Render(cluster.Name, cluster.Namespace, server, []byte(caConfigMap.Data["ca.crt"]), tokenRequest.Status.Token)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return kubeconfig, tokenRequest.Status.ExpirationTimestamp.Time, nil
+}
+
+// Render builds a kubeconfig with a single context that authenticates with the given token
+// and defaults to the given namespace
+func Render(clusterName, namespace, server string, caData []byte, token string) ([]byte, error) {
+	user := fmt.Sprintf("%s-%s", clusterName, namespace)
+	config := clientcmdapi.NewConfig()
+	config.Clusters[clusterName] = &clientcmdapi.Cluster{
+		Server:                   server,
+		CertificateAuthorityData: caData,
+	}
+	config.AuthInfos[user] = &clientcmdapi.AuthInfo{
+		Token: token,
+	}
+	config.Contexts[clusterName] = &clientcmdapi.Context{
+		Cluster:   clusterName,
+		AuthInfo:  user,
+		Namespace: namespace,
+	}
+	config.CurrentContext = clusterName
+
+	data, err := clientcmd.Write(*config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render kubeconfig: %w", err)
+	}
+	return data, nil
+}
+
+// ServiceAccountName returns the name of the access ServiceAccount and RoleBinding
+func ServiceAccountName(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	return naming.Name(naming.Access, cluster.Name)
+}
+
+// SecretName returns the name of the kubeconfig Secret
+func SecretName(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	return naming.Name(naming.Kubeconfig, cluster.Name)
+}
+
+// needsRefresh reports whether the token in the secret expires within a fifth of its lifetime
+func needsRefresh(secret *corev1.Secret, ttl time.Duration, now time.Time) bool {
+	if len(secret.Data[KubeconfigKey]) == 0 {
+		return true
+	}
+	expiresAt, err := time.Parse(time.RFC3339, secret.Annotations[ExpiresAtAnnotation])
+	if err != nil {
+		return true
+	}
+	return now.Add(ttl / 5).After(expiresAt)
+}
+
+// tokenExpiration returns the configured token lifetime or the default
+func tokenExpiration(access *k8splaygroundsv1alpha1.AccessSpec) time.Duration {
+	if access.TokenExpirationSeconds <= 0 {
+		return DefaultTokenExpiration
+	}
+	return time.Duration(access.TokenExpirationSeconds) * time.Second
+}
+
+// labels returns the labels of the access resources
+func labels(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "playground-access",
+		"app.kubernetes.io/instance": cluster.Name,
+	}
+}
@@ -0,0 +1,207 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+func newCluster(role string) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "class", Namespace: "class-a", UID: "cluster-uid"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Access: &k8splaygroundsv1alpha1.AccessSpec{
+				Enabled:                true,
+				Role:                   role,
+				Server:                 "https://playground.example.com:6443",
+				TokenExpirationSeconds: 3600,
+			},
+		},
+	}
+}
+
+// tokenClient is a fake client answering token requests of ServiceAccounts, which the fake
+// client does not implement, and counting them and the deleted RoleBindings
+type tokenClient struct {
+	client.Client
+	tokenRequests int
+	deletes       int
+}
+
+func newClient(t *testing.T) *tokenClient {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypes(k8splaygroundsv1alpha1.SchemeGroupVersion, &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{})
+	rootCA := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: rootCAConfigMap, Namespace: "class-a"},
+		Data:       map[string]string{"ca.crt": "cluster-ca"},
+	}
+
+	c := &tokenClient{}
+	funcs := applytest.Funcs()
+	funcs.SubResourceCreate = func(ctx context.Context, _ client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+		tokenRequest, ok := subResource.(*authenticationv1.TokenRequest)
+		if subResourceName != "token" || !ok {
+			t.Fatalf("unexpected %s subresource create", subResourceName)
+		}
+		c.tokenRequests++
+		tokenRequest.Status.Token = fmt.Sprintf("token-%d", c.tokenRequests)
+		tokenRequest.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(time.Duration(*tokenRequest.Spec.ExpirationSeconds) * time.Second))
+		return nil
+	}
+	funcs.Delete = func(ctx context.Context, inner client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+		if _, ok := obj.(*rbacv1.RoleBinding); ok {
+			c.deletes++
+		}
+		return inner.Delete(ctx, obj, opts...)
+	}
+	c.Client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(rootCA).WithInterceptorFuncs(funcs).Build()
+	return c
+}
+
+func TestReconcileIssuesKubeconfig(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+	r := NewReconciler(c, c.Scheme())
+	cluster := newCluster("view")
+
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if cluster.Status.KubeconfigSecret != "class-kubeconfig" {
+		t.Errorf("status.kubeconfigSecret = %q, want the kubeconfig secret", cluster.Status.KubeconfigSecret)
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: SecretName(cluster), Namespace: "class-a"}, secret); err != nil {
+		t.Fatalf("kubeconfig secret not created: %v", err)
+	}
+	if len(secret.OwnerReferences) != 1 || secret.OwnerReferences[0].UID != cluster.UID {
+		t.Errorf("owner references = %+v, want the cluster", secret.OwnerReferences)
+	}
+	config, err := clientcmd.Load(secret.Data[KubeconfigKey])
+	if err != nil {
+		t.Fatalf("kubeconfig secret holds an invalid kubeconfig: %v", err)
+	}
+	if token := config.AuthInfos["class-class-a"].Token; token != "token-1" {
+		t.Errorf("token = %q, want the requested token", token)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, secret.Annotations[ExpiresAtAnnotation])
+	if err != nil || time.Until(expiresAt) < 59*time.Minute {
+		t.Errorf("%s = %q, want the token expiry an hour ahead", ExpiresAtAnnotation, secret.Annotations[ExpiresAtAnnotation])
+	}
+
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ServiceAccountName(cluster), Namespace: "class-a"}, roleBinding); err != nil {
+		t.Fatalf("role binding not created: %v", err)
+	}
+	if roleBinding.RoleRef.Name != "view" || len(roleBinding.Subjects) != 1 || roleBinding.Subjects[0].Name != ServiceAccountName(cluster) {
+		t.Errorf("role binding = %+v, want the service account bound to view", roleBinding)
+	}
+
+	// A token far from expiring is kept
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if c.tokenRequests != 1 {
+		t.Errorf("%d token requests, want the issued token kept", c.tokenRequests)
+	}
+	if c.deletes != 0 {
+		t.Errorf("%d role bindings deleted, want the unchanged binding kept", c.deletes)
+	}
+}
+
+func TestReconcileRecreatesRoleBindingOnRoleChange(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+	r := NewReconciler(c, c.Scheme())
+	cluster := newCluster("view")
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	// The role reference of a binding cannot be updated
+	cluster.Spec.Access.Role = "admin"
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if c.deletes != 1 {
+		t.Errorf("%d role bindings deleted, want the binding to view replaced", c.deletes)
+	}
+	roleBinding := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, types.NamespacedName{Name: ServiceAccountName(cluster), Namespace: "class-a"}, roleBinding); err != nil {
+		t.Fatalf("role binding not recreated: %v", err)
+	}
+	if roleBinding.RoleRef.Name != "admin" {
+		t.Errorf("role binding refers to %s, want admin", roleBinding.RoleRef.Name)
+	}
+
+	cluster.Spec.Access.Role = "cluster-admin"
+	if err := r.Reconcile(ctx, cluster); err == nil {
+		t.Error("Reconcile() accepted a role other than view, edit and admin")
+	}
+}
+
+func TestNeedsRefresh(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	secret := func(data string, expiresAt string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{ExpiresAtAnnotation: expiresAt}},
+			Data:       map[string][]byte{KubeconfigKey: []byte(data)},
+		}
+	}
+	tests := map[string]struct {
+		secret *corev1.Secret
+		want   bool
+	}{
+		"fresh token":              {secret("kubeconfig", now.Add(23*time.Hour).Format(time.RFC3339)), false},
+		"within a fifth of expiry": {secret("kubeconfig", now.Add(4*time.Hour).Format(time.RFC3339)), true},
+		"expired token":            {secret("kubeconfig", now.Add(-time.Hour).Format(time.RFC3339)), true},
+		"no kubeconfig":            {secret("", now.Add(23*time.Hour).Format(time.RFC3339)), true},
+		"no expiry":                {secret("kubeconfig", ""), true},
+	}
+	for name, tt := range tests {
+		if got := needsRefresh(tt.secret, 24*time.Hour, now); got != tt.want {
+			t.Errorf("%s: needsRefresh() = %t, want %t", name, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	data, err := Render("class", "class-a", DefaultServer, []byte("cluster-ca"), "secret-token")
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		t.Fatalf("Render() wrote an invalid kubeconfig: %v", err)
+	}
+
+	current := config.Contexts[config.CurrentContext]
+	if config.CurrentContext != "class" || current == nil || current.Namespace != "class-a" {
+		t.Fatalf("current context = %q %+v, want class defaulting to class-a", config.CurrentContext, current)
+	}
+	cluster := config.Clusters[current.Cluster]
+	if cluster == nil || cluster.Server != DefaultServer || string(cluster.CertificateAuthorityData) != "cluster-ca" {
+		t.Errorf("cluster = %+v, want the server and CA", cluster)
+	}
+	if user := config.AuthInfos[current.AuthInfo]; user == nil || user.Token != "secret-token" {
+		t.Errorf("user = %+v, want the token", user)
+	}
+}
@@ -0,0 +1,135 @@
+// Package accounts builds the cloud accounts onboarded to the Aviatrix Controller from
+// AviatrixAccount resources and the Secrets holding their credentials.
+package accounts
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// Keys of the credentials Secret of an AviatrixAccount
+const (
+	// SecretKeyAccessKeyID and SecretKeySecretAccessKey are the access keys of AWS accounts
+	// that do not use IAM roles
+	SecretKeyAccessKeyID     = "accessKeyId"
+	SecretKeySecretAccessKey = "secretAccessKey"
+	// SecretKeyClientID and SecretKeyClientSecret are the service principal of Azure accounts
+	SecretKeyClientID     = "clientId"
+	SecretKeyClientSecret = "clientSecret"
+	// SecretKeyCredentials is the service account key file of GCP accounts
+	SecretKeyCredentials = "credentials.json"
+	// SecretKeyPrivateKey is the API signing key of OCI accounts
+	SecretKeyPrivateKey = "privateKey"
+)
+
+// ErrInvalidAccount reports a spec or credentials Secret that lacks settings of the cloud type
+var ErrInvalidAccount = errors.New("invalid account")
+
+// Build returns the account to onboard for an AviatrixAccount. secret is the credentials
+// Secret, nil when spec.credentialsSecret is empty.
+func Build(account *aviatrixv1alpha1.AviatrixAccount, secret *corev1.Secret) (aviatrix.Account, error) {
+	spec := account.Spec
+	built := aviatrix.Account{AccountName: spec.AccountName, CloudType: aviatrix.Value(spec.CloudType)}
+	if spec.AccountName == "" {
+		return aviatrix.Account{}, fmt.Errorf("%w: spec.accountName is required", ErrInvalidAccount)
+	}
+
+	var missing []string
+	require := func(name, value string) string {
+		if value == "" {
+			missing = append(missing, name)
+		}
+		return value
+	}
+	key := func(name string) string {
+		if secret == nil {
+			return require("spec.credentialsSecret", "")
+		}
+		return require(fmt.Sprintf("key %s of Secret %s", name, secret.Name), strings.TrimSpace(string(secret.Data[name])))
+	}
+
+	switch spec.CloudType {
+	case "aws":
+		if spec.AWS == nil {
+			return aviatrix.Account{}, fmt.Errorf("%w: spec.aws is required for cloud type aws", ErrInvalidAccount)
+		}
+		built.AwsAccountNumber = require("spec.aws.accountNumber", spec.AWS.AccountNumber)
+		if secret != nil {
+			built.AwsAccessKey = key(SecretKeyAccessKeyID)
+			built.AwsSecretKey = key(SecretKeySecretAccessKey)
+			break
+		}
+		built.AwsRoleApp = orDefault(spec.AWS.RoleArn, "arn:aws:iam::"+spec.AWS.AccountNumber+":role/aviatrix-role-app")
+		built.AwsRoleEc2 = orDefault(spec.AWS.EC2RoleArn, "arn:aws:iam::"+spec.AWS.AccountNumber+":role/aviatrix-role-ec2")
+	case "azure":
+		if spec.Azure == nil {
+			return aviatrix.Account{}, fmt.Errorf("%w: spec.azure is required for cloud type azure", ErrInvalidAccount)
+		}
+		built.AzureSubscriptionID = require("spec.azure.subscriptionId", spec.Azure.SubscriptionID)
+		built.AzureDirectoryID = require("spec.azure.directoryId", spec.Azure.DirectoryID)
+		built.AzureClientID = key(SecretKeyClientID)
+		built.AzureClientSecret = key(SecretKeyClientSecret)
+	case "gcp":
+		if spec.GCP == nil {
+			return aviatrix.Account{}, fmt.Errorf("%w: spec.gcp is required for cloud type gcp", ErrInvalidAccount)
+		}
+		built.GcpProjectID = require("spec.gcp.projectId", spec.GCP.ProjectID)
+		built.GcpCredentials = key(SecretKeyCredentials)
+	case "oci":
+		if spec.OCI == nil {
+			return aviatrix.Account{}, fmt.Errorf("%w: spec.oci is required for cloud type oci", ErrInvalidAccount)
+		}
+		built.OciTenancyID = require("spec.oci.tenancyId", spec.OCI.TenancyID)
+		built.OciUserID = require("spec.oci.userId", spec.OCI.UserID)
+		built.OciCompartmentID = require("spec.oci.compartmentId", spec.OCI.CompartmentID)
+		built.OciPrivateKey = key(SecretKeyPrivateKey)
+	default:
+		return aviatrix.Account{}, fmt.Errorf("%w: unsupported cloud type %q", ErrInvalidAccount, spec.CloudType)
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return aviatrix.Account{}, fmt.Errorf("%w: missing %s", ErrInvalidAccount, strings.Join(dedupe(missing), ", "))
+	}
+	return built, nil
+}
+
+// Hash identifies the settings and credentials of an account without revealing them, so a
+// change of either is noticed
+func Hash(account aviatrix.Account) string {
+	// The secrets are left out of the JSON form of accounts
+	data, _ := json.Marshal(struct {
+		aviatrix.Account
+		Secrets []string
+	}{account, []string{account.AwsAccessKey, account.AwsSecretKey, account.AzureClientID, account.AzureClientSecret, account.GcpCredentials, account.OciPrivateKey}})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func orDefault(value, fallback string) string {
+	if value != "" {
+		return value
+	}
+	return fallback
+}
+
+// dedupe removes repeated values from a sorted list
+func dedupe(values []string) []string {
+	out := values[:0]
+	for i, v := range values {
+		if i == 0 || v != values[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package accounts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func account(spec aviatrixv1alpha1.AviatrixAccountSpec) *aviatrixv1alpha1.AviatrixAccount {
+	return &aviatrixv1alpha1.AviatrixAccount{ObjectMeta: metav1.ObjectMeta{Name: "acct", Namespace: "default"}, Spec: spec}
+}
+
+func secret(data map[string]string) *corev1.Secret {
+	s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "creds", Namespace: "default"}, Data: map[string][]byte{}}
+	for k, v := range data {
+		s.Data[k] = []byte(v)
+	}
+	return s
+}
+
+func TestBuildAwsDefaultsRoles(t *testing.T) {
+	built, err := Build(account(aviatrixv1alpha1.AviatrixAccountSpec{
+		AccountName: "aws-prod",
+		CloudType:   "aws",
+		AWS:         &aviatrixv1alpha1.AwsAccount{AccountNumber: "123456789012"},
+	}), nil)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if built.AwsRoleApp != "arn:aws:iam::123456789012:role/aviatrix-role-app" || built.AwsRoleEc2 != "arn:aws:iam::123456789012:role/aviatrix-role-ec2" || built.AwsAccessKey != "" {
+		t.Errorf("Build() = %+v, want the default IAM roles", built)
+	}
+
+	// Access keys replace the roles
+	built, err = Build(account(aviatrixv1alpha1.AviatrixAccountSpec{
+		AccountName: "aws-prod",
+		CloudType:   "aws",
+		AWS:         &aviatrixv1alpha1.AwsAccount{AccountNumber: "123456789012"},
+	}), secret(map[string]string{SecretKeyAccessKeyID: "AKIA", SecretKeySecretAccessKey: "s3cr3t"}))
+	if err != nil || built.AwsAccessKey != "AKIA" || built.AwsRoleApp != "" {
+		t.Errorf("Build() = %+v, %v, want access keys", built, err)
+	}
+}
+
+func TestBuildReportsMissingSettings(t *testing.T) {
+	_, err := Build(account(aviatrixv1alpha1.AviatrixAccountSpec{
+		AccountName: "azure-prod",
+		CloudType:   "azure",
+		Azure:       &aviatrixv1alpha1.AzureAccount{SubscriptionID: "sub"},
+	}), secret(map[string]string{SecretKeyClientID: "app"}))
+	if !errors.Is(err, ErrInvalidAccount) {
+		t.Fatalf("Build() error = %v, want ErrInvalidAccount", err)
+	}
+	for _, want := range []string{"spec.azure.directoryId", "key clientSecret of Secret creds"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error = %v, want it to name %s", err, want)
+		}
+	}
+
+	_, err = Build(account(aviatrixv1alpha1.AviatrixAccountSpec{
+		AccountName: "gcp-prod",
+		CloudType:   "gcp",
+		GCP:         &aviatrixv1alpha1.GcpAccount{ProjectID: "proj"},
+	}), nil)
+	if err == nil || !strings.Contains(err.Error(), "spec.credentialsSecret") {
+		t.Errorf("Build() without a Secret error = %v, want spec.credentialsSecret", err)
+	}
+
+	if _, err := Build(account(aviatrixv1alpha1.AviatrixAccountSpec{AccountName: "oci", CloudType: "oci"}), nil); !errors.Is(err, ErrInvalidAccount) {
+		t.Errorf("Build() without spec.oci error = %v, want ErrInvalidAccount", err)
+	}
+}
+
+func TestHashChangesWithCredentials(t *testing.T) {
+	spec := aviatrixv1alpha1.AviatrixAccountSpec{
+		AccountName: "oci-prod",
+		CloudType:   "oci",
+		OCI:         &aviatrixv1alpha1.OciAccount{TenancyID: "t", UserID: "u", CompartmentID: "c"},
+	}
+	first, err := Build(account(spec), secret(map[string]string{SecretKeyPrivateKey: "key-1"}))
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	second, _ := Build(account(spec), secret(map[string]string{SecretKeyPrivateKey: "key-2"}))
+	if Hash(first) == Hash(second) {
+		t.Error("Hash() did not change with the private key")
+	}
+	if again, _ := Build(account(spec), secret(map[string]string{SecretKeyPrivateKey: "key-1"})); Hash(again) != Hash(first) {
+		t.Error("Hash() changed for the same account")
+	}
+	if strings.Contains(Hash(first), "key-1") {
+		t.Error("Hash() reveals the private key")
+	}
+}
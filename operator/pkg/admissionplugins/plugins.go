@@ -0,0 +1,261 @@
+package admissionplugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"sigs.k8s.io/yaml"
+)
+
+// Path is where the API server sends admission requests for the custom resources of the
+// operator to be passed on to the plugins
+const Path = "/validate-plugins"
+
+// Failure policies of a plugin
+const (
+	// FailurePolicyFail denies requests when the plugin cannot be reached or answers garbage
+	FailurePolicyFail = "Fail"
+	// FailurePolicyIgnore admits requests with a warning when the plugin cannot be reached
+	FailurePolicyIgnore = "Ignore"
+)
+
+// DefaultTimeout bounds a call to a plugin without a configured timeout. The API server gives
+// the whole webhook 10 seconds by default, shared by every plugin.
+const DefaultTimeout = 3 * time.Second
+
+// maxResponseBytes bounds the AdmissionReview read from a plugin
+const maxResponseBytes = 1 << 20
+
+//+kubebuilder:webhook:path=/validate-plugins,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io;k8s-playgrounds.io,resources=*,verbs=create;update;delete,versions=v1alpha1,name=vplugins.aviatrix.k8s.io,admissionReviewVersions=v1
+
+// Config lists the validation plugins, read from the file given to --validation-plugins
+type Config struct {
+	Plugins []Plugin `json:"plugins"`
+}
+
+// Plugin is a customer-supplied validation service receiving AdmissionReviews for custom
+// resources of the operator
+type Plugin struct {
+	// Name identifies the plugin in denials and warnings
+	Name string `json:"name"`
+	// URL is the https endpoint receiving admission.k8s.io/v1 AdmissionReviews
+	URL string `json:"url"`
+	// CABundle is the PEM encoded CA verifying the plugin's certificate; empty uses the
+	// system roots
+	CABundle string `json:"caBundle,omitempty"`
+	// CAFile is a file holding CABundle, e.g. a mounted Secret or ConfigMap
+	CAFile string `json:"caFile,omitempty"`
+	// FailurePolicy is Fail or Ignore; defaults to Fail
+	FailurePolicy string `json:"failurePolicy,omitempty"`
+	// TimeoutSeconds bounds a call to the plugin; defaults to 3
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// Rules select the requests sent to the plugin; empty sends every request
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// Rule matches admission requests by API group, resource and operation. An empty list
+// matches everything.
+type Rule struct {
+	Groups     []string `json:"groups,omitempty"`
+	Resources  []string `json:"resources,omitempty"`
+	Operations []string `json:"operations,omitempty"`
+}
+
+// LoadConfig reads and validates a plugin configuration file in YAML or JSON
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation plugins: %w", err)
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse validation plugins: %w", err)
+	}
+	names := map[string]bool{}
+	for i := range config.Plugins {
+		plugin := &config.Plugins[i]
+		if plugin.Name == "" {
+			return nil, fmt.Errorf("validation plugin %d has no name", i)
+		}
+		if names[plugin.Name] {
+			return nil, fmt.Errorf("validation plugin %s is defined twice", plugin.Name)
+		}
+		names[plugin.Name] = true
+		if u, err := url.Parse(plugin.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("validation plugin %s: url must be an https URL", plugin.Name)
+		}
+		switch plugin.FailurePolicy {
+		case "":
+			plugin.FailurePolicy = FailurePolicyFail
+		case FailurePolicyFail, FailurePolicyIgnore:
+		default:
+			return nil, fmt.Errorf("validation plugin %s: failurePolicy must be %s or %s", plugin.Name, FailurePolicyFail, FailurePolicyIgnore)
+		}
+		if plugin.CAFile != "" {
+			if plugin.CABundle != "" {
+				return nil, fmt.Errorf("validation plugin %s: set caBundle or caFile, not both", plugin.Name)
+			}
+			ca, err := os.ReadFile(plugin.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("validation plugin %s: %w", plugin.Name, err)
+			}
+			plugin.CABundle = string(ca)
+		}
+	}
+	return config, nil
+}
+
+// Chain passes admission requests to the plugins matching them in order and denies a request
+// when a plugin denies it. Plugins see the request the API server sent, so they can be
+// written like any validating webhook.
+type Chain struct {
+	plugins []plugin
+}
+
+// plugin is a configured plugin with its HTTP client
+type plugin struct {
+	Plugin
+	client *http.Client
+}
+
+var _ admission.Handler = &Chain{}
+
+// NewChain creates a chain calling the configured plugins
+func NewChain(config *Config) (*Chain, error) {
+	chain := &Chain{}
+	for _, p := range config.Plugins {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if p.CABundle != "" {
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM([]byte(p.CABundle)) {
+				return nil, fmt.Errorf("validation plugin %s: caBundle holds no PEM certificates", p.Name)
+			}
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		}
+		timeout := DefaultTimeout
+		if p.TimeoutSeconds > 0 {
+			timeout = time.Duration(p.TimeoutSeconds) * time.Second
+		}
+		chain.plugins = append(chain.plugins, plugin{Plugin: p, client: &http.Client{Transport: transport, Timeout: timeout}})
+	}
+	return chain, nil
+}
+
+// SetupWithManager registers the chain on the webhook server of the Manager
+func (c *Chain) SetupWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(Path, &webhook.Admission{Handler: c})
+	return nil
+}
+
+// Handle calls every plugin matching the request. The first denial is returned; warnings of
+// all plugins are collected, including those of plugins that failed with the Ignore policy.
+func (c *Chain) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var warnings []string
+	for _, p := range c.plugins {
+		if !p.matches(req.AdmissionRequest) {
+			continue
+		}
+		response, err := p.call(ctx, req.AdmissionRequest)
+		if err != nil {
+			if p.FailurePolicy == FailurePolicyIgnore {
+				warnings = append(warnings, fmt.Sprintf("validation plugin %s was skipped: %v", p.Name, err))
+				continue
+			}
+			return admission.Denied(fmt.Sprintf("validation plugin %s failed: %v", p.Name, err)).WithWarnings(warnings...)
+		}
+		warnings = append(warnings, response.Warnings...)
+		if !response.Allowed {
+			message := "denied"
+			if response.Result != nil && response.Result.Message != "" {
+				message = response.Result.Message
+			}
+			denied := admission.Denied(fmt.Sprintf("validation plugin %s: %s", p.Name, message))
+			if response.Result != nil && response.Result.Code != 0 {
+				denied.Result.Code = response.Result.Code
+			}
+			return denied.WithWarnings(warnings...)
+		}
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// matches reports whether a request is selected by the rules of the plugin
+func (p *plugin) matches(req admissionv1.AdmissionRequest) bool {
+	if len(p.Rules) == 0 {
+		return true
+	}
+	for _, rule := range p.Rules {
+		if contains(rule.Groups, req.Resource.Group) && contains(rule.Resources, req.Resource.Resource) && contains(rule.Operations, string(req.Operation)) {
+			return true
+		}
+	}
+	return false
+}
+
+// call sends the request to the plugin and returns its response
+func (p *plugin) call(ctx context.Context, req admissionv1.AdmissionRequest) (*admissionv1.AdmissionResponse, error) {
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request:  &req,
+	})
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	review := admissionv1.AdmissionReview{}
+	if err := json.Unmarshal(data, &review); err != nil {
+		return nil, fmt.Errorf("invalid AdmissionReview: %w", err)
+	}
+	if review.Response == nil {
+		return nil, fmt.Errorf("AdmissionReview has no response")
+	}
+	if review.Response.UID != req.UID {
+		return nil, fmt.Errorf("AdmissionReview response is for request %s, not %s", review.Response.UID, req.UID)
+	}
+	return review.Response, nil
+}
+
+// contains reports whether values holds value or "*"; an empty list matches everything
+func contains(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == "*" || v == value {
+			return true
+		}
+	}
+	return false
+}
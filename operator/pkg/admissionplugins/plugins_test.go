@@ -0,0 +1,132 @@
+package admissionplugins
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// guardrail denies gateways outside us-east-1
+func guardrail(w http.ResponseWriter, r *http.Request) {
+	review := admissionv1.AdmissionReview{}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	response := &admissionv1.AdmissionResponse{UID: review.Request.UID, Allowed: true}
+	if !strings.Contains(string(review.Request.Object.Raw), `"vpcRegion":"us-east-1"`) {
+		response.Allowed = false
+		response.Result = &metav1.Status{Message: "gateways must be in us-east-1"}
+	}
+	review.Response = response
+	json.NewEncoder(w).Encode(review)
+}
+
+func caOf(server *httptest.Server) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+}
+
+func request(group, resource, object string) admission.Request {
+	return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		UID:       types.UID("uid-1"),
+		Operation: admissionv1.Create,
+		Resource:  metav1.GroupVersionResource{Group: group, Version: "v1alpha1", Resource: resource},
+		Object:    runtime.RawExtension{Raw: []byte(object)},
+	}}
+}
+
+func TestChainDeniesWithPluginMessage(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(guardrail))
+	defer server.Close()
+
+	chain, err := NewChain(&Config{Plugins: []Plugin{{
+		Name:          "regions",
+		URL:           server.URL,
+		CABundle:      caOf(server),
+		FailurePolicy: FailurePolicyFail,
+		Rules:         []Rule{{Groups: []string{"aviatrix.k8s.io"}, Resources: []string{"aviatrixgateways"}}},
+	}}})
+	if err != nil {
+		t.Fatalf("NewChain() error = %v", err)
+	}
+
+	resp := chain.Handle(context.Background(), request("aviatrix.k8s.io", "aviatrixgateways", `{"spec":{"vpcRegion":"eu-west-1"}}`))
+	if resp.Allowed || !strings.Contains(resp.Result.Message, "regions: gateways must be in us-east-1") {
+		t.Errorf("Handle() = %+v, want a denial naming the plugin", resp.AdmissionResponse)
+	}
+	if resp := chain.Handle(context.Background(), request("aviatrix.k8s.io", "aviatrixgateways", `{"spec":{"vpcRegion":"us-east-1"}}`)); !resp.Allowed {
+		t.Errorf("Handle() denied an allowed gateway: %+v", resp.Result)
+	}
+	// Requests outside the rules never reach the plugin
+	if resp := chain.Handle(context.Background(), request("aviatrix.k8s.io", "aviatrixvpcs", `{}`)); !resp.Allowed {
+		t.Errorf("Handle() denied a request outside the rules: %+v", resp.Result)
+	}
+}
+
+func TestChainFailurePolicy(t *testing.T) {
+	// The server certificate is not trusted without the CA bundle
+	server := httptest.NewTLSServer(http.HandlerFunc(guardrail))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		policy  string
+		allowed bool
+	}{
+		{FailurePolicyFail, false},
+		{FailurePolicyIgnore, true},
+	} {
+		chain, err := NewChain(&Config{Plugins: []Plugin{{Name: "untrusted", URL: server.URL, FailurePolicy: tt.policy}}})
+		if err != nil {
+			t.Fatalf("NewChain() error = %v", err)
+		}
+		resp := chain.Handle(context.Background(), request("aviatrix.k8s.io", "aviatrixgateways", `{}`))
+		if resp.Allowed != tt.allowed {
+			t.Errorf("%s: allowed = %t, want %t", tt.policy, resp.Allowed, tt.allowed)
+		}
+		if tt.allowed && len(resp.Warnings) != 1 {
+			t.Errorf("%s: warnings = %v, want the skipped plugin", tt.policy, resp.Warnings)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "plugins.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	config, err := LoadConfig(write("plugins:\n- name: naming\n  url: https://guardrails.example.com/validate\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Plugins[0].FailurePolicy != FailurePolicyFail {
+		t.Errorf("failurePolicy = %q, want Fail by default", config.Plugins[0].FailurePolicy)
+	}
+
+	for _, invalid := range []string{
+		"plugins:\n- name: naming\n  url: http://guardrails.example.com\n",
+		"plugins:\n- name: naming\n  url: https://guardrails.example.com\n  failurePolicy: Retry\n",
+		"plugins:\n- url: https://guardrails.example.com\n",
+		"plugins:\n- name: naming\n  url: https://guardrails.example.com\n  retries: 3\n",
+	} {
+		if _, err := LoadConfig(write(invalid)); err == nil {
+			t.Errorf("LoadConfig() accepted %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package admissionqueue
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// entry is a cluster waiting to be admitted
+type entry struct {
+	key       types.NamespacedName
+	createdAt time.Time
+}
+
+// Queue admits newly created clusters to active reconciliation at a fixed rate.
+// Clusters that arrive faster than the rate are parked in creation order.
+type Queue struct {
+	mu         sync.Mutex
+	perMinute  int
+	tokens     float64
+	lastRefill time.Time
+	waiting    []entry
+}
+
+// NewQueue creates an admission queue that admits up to perMinute clusters per minute
+func NewQueue(perMinute int) *Queue {
+	if perMinute < 1 {
+		perMinute = 1
+	}
+	return &Queue{
+		perMinute: perMinute,
+		tokens:    float64(perMinute),
+	}
+}
+
+// Admit asks for the cluster identified by key to be admitted. It returns whether the
+// cluster was admitted and, if not, its 1-based position in the queue and how long to
+// wait before asking again.
+func (q *Queue) Admit(key types.NamespacedName, createdAt, now time.Time) (bool, int, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.refill(now)
+
+	position := q.position(key)
+	if position == 0 {
+		q.waiting = append(q.waiting, entry{key: key, createdAt: createdAt})
+		sort.SliceStable(q.waiting, func(i, j int) bool {
+			if q.waiting[i].createdAt.Equal(q.waiting[j].createdAt) {
+				return q.waiting[i].key.String() < q.waiting[j].key.String()
+			}
+			return q.waiting[i].createdAt.Before(q.waiting[j].createdAt)
+		})
+		position = q.position(key)
+	}
+
+	// Only clusters at the front of the queue may consume the available tokens
+	if float64(position) <= q.tokens {
+		q.tokens--
+		q.remove(key)
+		return true, 0, 0
+	}
+
+	interval := time.Minute / time.Duration(q.perMinute)
+	missing := float64(position) - q.tokens
+	return false, position, time.Duration(missing * float64(interval))
+}
+
+// Pending reports whether a cluster still has to pass the admission queue. Clusters that were
+// running before the queue was enabled have no admission time but are already admitted.
+func Pending(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) bool {
+	if cluster.Status.AdmittedAt != nil {
+		return false
+	}
+	switch cluster.Status.Phase {
+	case k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterPhaseUpdating, k8splaygroundsv1alpha1.ClusterPhaseScaling:
+		return false
+	}
+	return true
+}
+
+// Remove drops a cluster from the queue, e.g. when it is deleted before admission
+func (q *Queue) Remove(key types.NamespacedName) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.remove(key)
+}
+
+// Len returns the number of clusters waiting for admission
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.waiting)
+}
+
+// refill adds tokens for the time elapsed since the last refill, capped at one minute's worth
+func (q *Queue) refill(now time.Time) {
+	if q.lastRefill.IsZero() {
+		q.lastRefill = now
+		return
+	}
+
+	elapsed := now.Sub(q.lastRefill)
+	if elapsed <= 0 {
+		return
+	}
+	q.tokens += elapsed.Minutes() * float64(q.perMinute)
+	if q.tokens > float64(q.perMinute) {
+		q.tokens = float64(q.perMinute)
+	}
+	q.lastRefill = now
+}
+
+// position returns the 1-based position of key in the queue, or 0 if it is not queued
+func (q *Queue) position(key types.NamespacedName) int {
+	for i, e := range q.waiting {
+		if e.key == key {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// remove deletes key from the waiting list
+func (q *Queue) remove(key types.NamespacedName) {
+	for i, e := range q.waiting {
+		if e.key == key {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package admissionqueue
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestAdmitRate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	q := NewQueue(2)
+
+	keys := []types.NamespacedName{{Namespace: "a", Name: "one"}, {Namespace: "a", Name: "two"}, {Namespace: "a", Name: "three"}}
+	for i, key := range keys[:2] {
+		if admitted, _, _ := q.Admit(key, now.Add(time.Duration(i)*time.Second), now); !admitted {
+			t.Fatalf("%s not admitted within the rate", key)
+		}
+	}
+
+	admitted, position, retryAfter := q.Admit(keys[2], now.Add(2*time.Second), now)
+	if admitted || position != 1 || retryAfter != 30*time.Second {
+		t.Fatalf("Admit() = %v, %d, %v, want queued at 1 for 30s", admitted, position, retryAfter)
+	}
+	if q.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", q.Len())
+	}
+
+	if admitted, _, _ := q.Admit(keys[2], now.Add(2*time.Second), now.Add(30*time.Second)); !admitted {
+		t.Error("queued cluster not admitted after retryAfter")
+	}
+	if q.Len() != 0 {
+		t.Errorf("Len() = %d after admission, want 0", q.Len())
+	}
+}
+
+func TestAdmitInCreationOrder(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	q := NewQueue(1)
+	q.Admit(types.NamespacedName{Name: "first"}, now, now)
+
+	newer := types.NamespacedName{Name: "newer"}
+	older := types.NamespacedName{Name: "older"}
+	q.Admit(newer, now.Add(time.Minute), now)
+	if _, position, _ := q.Admit(older, now.Add(time.Second), now); position != 1 {
+		t.Errorf("older cluster at position %d, want 1", position)
+	}
+	if admitted, _, _ := q.Admit(newer, now.Add(time.Minute), now.Add(time.Minute)); admitted {
+		t.Error("newer cluster admitted ahead of an older one")
+	}
+
+	q.Remove(older)
+	if admitted, _, _ := q.Admit(newer, now.Add(time.Minute), now.Add(time.Minute)); !admitted {
+		t.Error("newer cluster not admitted once the older one was removed")
+	}
+}
+
+func TestPending(t *testing.T) {
+	admittedAt := metav1.Now()
+	cases := map[string]struct {
+		status  k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus
+		pending bool
+	}{
+		"new":                       {k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{}, true},
+		"queued":                    {k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{Phase: k8splaygroundsv1alpha1.ClusterPhaseQueued}, true},
+		"failed before admission":   {k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{Phase: k8splaygroundsv1alpha1.ClusterPhaseFailed}, true},
+		"admitted":                  {k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{Phase: k8splaygroundsv1alpha1.ClusterPhaseFailed, AdmittedAt: &admittedAt}, false},
+		"running before the queue":  {k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{Phase: k8splaygroundsv1alpha1.ClusterPhaseRunning}, false},
+		"updating before the queue": {k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{Phase: k8splaygroundsv1alpha1.ClusterPhaseUpdating}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{Status: tc.status}
+			if got := Pending(cluster); got != tc.pending {
+				t.Errorf("Pending() = %v, want %v", got, tc.pending)
+			}
+		})
+	}
+}
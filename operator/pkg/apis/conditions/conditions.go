@@ -0,0 +1,227 @@
+// Package conditions enumerates the condition types, phases, states and reasons the
+// controllers report in status. Automation should match these values; messages are meant for
+// people and may change.
+package conditions
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TypeReady reports whether a resource matches its spec
+const TypeReady = "Ready"
+
+// Phases of status.phase
+const (
+	PhasePending     = "Pending"
+	PhaseReconciling = "Reconciling"
+	PhaseReady       = "Ready"
+	PhaseRunning     = "Running"
+	PhaseSucceeded   = "Succeeded"
+	PhaseDegraded    = "Degraded"
+	PhaseFailed      = "Failed"
+	PhaseStopped     = "Stopped"
+	PhaseConflict    = "Conflict"
+	PhaseDeleting    = "Deleting"
+)
+
+// States of status.state of Aviatrix resources
+const (
+	StateActive    = "Active"
+	StateCreating  = "Creating"
+	StateUpdating  = "Updating"
+	StateUpgrading = "Upgrading"
+	StateStopped   = "Stopped"
+	StateAttaching = "Attaching"
+	StateAttached  = "Attached"
+	StateDetaching = "Detaching"
+	StateDeleting  = "Deleting"
+	StateError     = "Error"
+)
+
+// Reasons of conditions
+const (
+	// ReasonOther replaces reasons that are not enumerated here in metric labels
+	ReasonOther = "Other"
+
+	ReasonReconciled          = "Reconciled"
+	ReasonApplied             = "Applied"
+	ReasonInvalidSpec         = "InvalidSpec"
+	ReasonAviatrixUnreachable = "AviatrixUnreachable"
+	ReasonCloudAccountInvalid = "CloudAccountInvalid"
+	ReasonControllerError     = "ControllerError"
+	ReasonCreateFailed        = "CreateFailed"
+	ReasonUpdated             = "Updated"
+	ReasonUpdateFailed        = "UpdateFailed"
+	ReasonDeleteFailed        = "DeleteFailed"
+	ReasonApplyFailed         = "ApplyFailed"
+	ReasonRemediationFailed   = "RemediationFailed"
+	ReasonInSync              = "InSync"
+	ReasonDriftDetected       = "DriftDetected"
+	ReasonCorrected           = "Corrected"
+	ReasonUpgrading           = "Upgrading"
+
+	// Gateways
+	ReasonHAGatewayUp            = "HAGatewayUp"
+	ReasonGatewayNameConflict    = "GatewayNameConflict"
+	ReasonHAFailed               = "HAFailed"
+	ReasonFeaturesFailed         = "FeaturesFailed"
+	ReasonInvalidAdvertisement   = "InvalidAdvertisement"
+	ReasonInScheduledWindow      = "InScheduledWindow"
+	ReasonOutsideScheduledWindow = "OutsideScheduledWindow"
+
+	// Accounts
+	ReasonOnboarded          = "Onboarded"
+	ReasonCredentialsInvalid = "CredentialsInvalid"
+	ReasonAuditPassed        = "AuditPassed"
+	ReasonAuditFailed        = "AuditFailed"
+
+	// Peerings and attachments
+	ReasonPeered       = "Peered"
+	ReasonTunnelsUp    = "TunnelsUp"
+	ReasonTunnelsDown  = "TunnelsDown"
+	ReasonAttached     = "Attached"
+	ReasonAttachFailed = "AttachFailed"
+	ReasonDetachFailed = "DetachFailed"
+
+	// Domains and policies
+	ReasonProgrammed             = "Programmed"
+	ReasonConnectionPolicyFailed = "ConnectionPolicyFailed"
+	ReasonRefreshed              = "Refreshed"
+	ReasonRefreshFailed          = "RefreshFailed"
+	ReasonNoRecentHits           = "NoRecentHits"
+	ReasonAllRulesHit            = "AllRulesHit"
+	ReasonExpectationNotMet      = "ExpectationNotMet"
+	ReasonAllTestsPassed         = "AllTestsPassed"
+	ReasonInvalidIntent          = "InvalidIntent"
+	ReasonArtifactsApplied       = "ArtifactsApplied"
+
+	// HeadlessServices
+	ReasonServiceRunning     = "ServiceRunning"
+	ReasonMirrorFailed       = "MirrorFailed"
+	ReasonEndpointsEmpty     = "EndpointsEmpty"
+	ReasonEndpointsTruncated = "EndpointsTruncated"
+	ReasonDNSTestFailed      = "DNSTestFailed"
+	ReasonIptablesDiverged   = "IptablesDiverged"
+	ReasonCanaryFailed       = "CanaryFailed"
+	ReasonSelectorTooBroad   = "SelectorTooBroad"
+	ReasonRetentionExpired   = "RetentionExpired"
+	ReasonUndeleted          = "Undeleted"
+)
+
+// descriptions explains every enumerated reason; kubectl playgrounds prints them next to the
+// reason
+var descriptions = map[string]string{
+	ReasonReconciled:          "the resource matches its spec",
+	ReasonApplied:             "the spec is applied",
+	ReasonInvalidSpec:         "the spec is invalid; the next change of it is reconciled again",
+	ReasonAviatrixUnreachable: "the Aviatrix Controller cannot be reached or rejected the login",
+	ReasonCloudAccountInvalid: "the cloud account is not known to the Aviatrix Controller",
+	ReasonControllerError:     "the Aviatrix Controller returned an error",
+	ReasonCreateFailed:        "creating the resource on the Aviatrix Controller failed",
+	ReasonUpdated:             "the resource on the Aviatrix Controller was updated to its spec",
+	ReasonUpdateFailed:        "updating the resource on the Aviatrix Controller failed",
+	ReasonDeleteFailed:        "deleting the resource from the Aviatrix Controller failed",
+	ReasonApplyFailed:         "applying generated resources failed",
+	ReasonRemediationFailed:   "pushing the spec back over drift failed",
+	ReasonInSync:              "the resource on the Aviatrix Controller matches its spec",
+	ReasonDriftDetected:       "the resource on the Aviatrix Controller differs from its spec",
+	ReasonCorrected:           "drift was corrected",
+	ReasonUpgrading:           "the gateways are being upgraded one at a time",
+
+	ReasonHAGatewayUp:            "the HA gateway is up",
+	ReasonGatewayNameConflict:    "an older resource manages the gateway name; this one is ignored",
+	ReasonHAFailed:               "creating or deleting the HA gateway failed",
+	ReasonFeaturesFailed:         "toggling gateway features failed",
+	ReasonInvalidAdvertisement:   "spec.advertisement is invalid",
+	ReasonInScheduledWindow:      "the gateway is stopped by its schedule",
+	ReasonOutsideScheduledWindow: "the gateway runs outside its scheduled stop",
+
+	ReasonOnboarded:          "the account is onboarded to the Aviatrix Controller",
+	ReasonCredentialsInvalid: "the spec or credentials Secret lacks settings of the cloud type",
+	ReasonAuditPassed:        "the Aviatrix Controller can use the account",
+	ReasonAuditFailed:        "the Aviatrix Controller cannot use the credentials or permissions of the account",
+
+	ReasonPeered:       "the VPCs or transit gateways are peered",
+	ReasonTunnelsUp:    "every tunnel of the peering is up",
+	ReasonTunnelsDown:  "some tunnels of the peering are down or none is reported",
+	ReasonAttached:     "the VPC is attached",
+	ReasonAttachFailed: "attaching the VPC failed",
+	ReasonDetachFailed: "detaching the VPC failed",
+
+	ReasonProgrammed:             "the policies are programmed on the Aviatrix Controller",
+	ReasonConnectionPolicyFailed: "creating or deleting a connection policy failed",
+	ReasonRefreshed:              "connectivity was refreshed from the Aviatrix Controller",
+	ReasonRefreshFailed:          "refreshing connectivity from the Aviatrix Controller failed",
+	ReasonNoRecentHits:           "some rules were not hit for the unused threshold",
+	ReasonAllRulesHit:            "every rule was hit recently",
+	ReasonExpectationNotMet:      "a policy test does not hold; rules are not programmed",
+	ReasonAllTestsPassed:         "every policy test holds",
+	ReasonInvalidIntent:          "the traffic intent cannot be compiled",
+	ReasonArtifactsApplied:       "the compiled policies are applied",
+
+	ReasonServiceRunning:     "the service has healthy endpoints",
+	ReasonMirrorFailed:       "the mirrored Service cannot be copied",
+	ReasonEndpointsEmpty:     "no pod matches the selector or none is ready",
+	ReasonEndpointsTruncated: "endpoints beyond the limit are left out of the iptables rules",
+	ReasonDNSTestFailed:      "the DNS test of the service failed",
+	ReasonIptablesDiverged:   "iptables rules diverged on some nodes",
+	ReasonCanaryFailed:       "the canary nodes rejected the new iptables rules",
+	ReasonSelectorTooBroad:   "the selector matches more pods than allowed",
+	ReasonRetentionExpired:   "the soft-deleted cluster was purged",
+	ReasonUndeleted:          "the soft-deleted cluster was restored",
+}
+
+// failingPhases are the phases that count a resource or component as failing
+var failingPhases = map[string]bool{
+	PhaseFailed:   true,
+	PhaseDegraded: true,
+}
+
+// Describe explains a reason, or returns "" for reasons that are not enumerated
+func Describe(reason string) string {
+	return descriptions[reason]
+}
+
+// Label returns the reason to use as a metric label: the reason itself when it is enumerated,
+// ReasonOther otherwise, so free-form reasons cannot grow the number of series
+func Label(reason string) string {
+	if _, ok := descriptions[reason]; ok {
+		return reason
+	}
+	return ReasonOther
+}
+
+// Failing reports whether a phase counts as failing
+func Failing(phase string) bool {
+	return failingPhases[phase]
+}
+
+// Error is an error with the reason to report for it in status
+type Error struct {
+	Reason string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errorf formats an error with the reason to report for it
+func Errorf(reason, format string, args ...interface{}) error {
+	return &Error{Reason: reason, Err: fmt.Errorf(format, args...)}
+}
+
+// ReasonFor returns the reason of the first Error in the chain of err, or fallback when there
+// is none
+func ReasonFor(err error, fallback string) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Reason
+	}
+	return fallback
+}
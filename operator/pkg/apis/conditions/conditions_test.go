@@ -0,0 +1,45 @@
+package conditions
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestLabel(t *testing.T) {
+	if got := Label(ReasonDNSTestFailed); got != ReasonDNSTestFailed {
+		t.Errorf("Label(%q) = %q, want the reason itself", ReasonDNSTestFailed, got)
+	}
+	if got := Label("connection refused by 10.0.0.1"); got != ReasonOther {
+		t.Errorf("Label of a free-form reason = %q, want %q", got, ReasonOther)
+	}
+}
+
+func TestDescribeCoversReasons(t *testing.T) {
+	for _, reason := range []string{ReasonAviatrixUnreachable, ReasonEndpointsEmpty, ReasonDNSTestFailed, ReasonDeleteFailed} {
+		if Describe(reason) == "" {
+			t.Errorf("%s has no description", reason)
+		}
+	}
+}
+
+func TestReasonFor(t *testing.T) {
+	err := fmt.Errorf("reconcile: %w", Errorf(ReasonAviatrixUnreachable, "login: %w", errors.New("timeout")))
+	if got := ReasonFor(err, ReasonControllerError); got != ReasonAviatrixUnreachable {
+		t.Errorf("ReasonFor(wrapped) = %q, want %q", got, ReasonAviatrixUnreachable)
+	}
+	if err.Error() != "reconcile: login: timeout" {
+		t.Errorf("Error() = %q", err.Error())
+	}
+	if got := ReasonFor(errors.New("boom"), ReasonControllerError); got != ReasonControllerError {
+		t.Errorf("ReasonFor(plain) = %q, want the fallback", got)
+	}
+}
+
+func TestFailing(t *testing.T) {
+	for phase, want := range map[string]bool{PhaseFailed: true, PhaseDegraded: true, PhaseRunning: false, PhasePending: false} {
+		if got := Failing(phase); got != want {
+			t.Errorf("Failing(%q) = %v, want %v", phase, got, want)
+		}
+	}
+}
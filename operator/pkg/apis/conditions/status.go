@@ -0,0 +1,65 @@
+package conditions
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Standard condition types every resource reports next to TypeReady
+const (
+	// TypeProgressing reports whether the operator is changing a resource toward its spec
+	TypeProgressing = "Progressing"
+	// TypeDegraded reports whether the last reconcile of a resource failed
+	TypeDegraded = "Degraded"
+)
+
+// Set sets a condition observed at generation. The last transition time is kept while the
+// status does not change, so it tells how long a resource has been in its state.
+func Set(conditions *[]metav1.Condition, generation int64, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+}
+
+// SetReady sets only the Ready condition, for resources whose Degraded condition is owned by
+// another check
+func SetReady(conditions *[]metav1.Condition, generation int64, ready bool, reason, message string) {
+	Set(conditions, generation, TypeReady, statusOf(ready), reason, message)
+}
+
+// MarkReady reports a resource that matches its spec: Ready, neither progressing nor degraded
+func MarkReady(conditions *[]metav1.Condition, generation int64, reason, message string) {
+	Set(conditions, generation, TypeReady, metav1.ConditionTrue, reason, message)
+	Set(conditions, generation, TypeProgressing, metav1.ConditionFalse, reason, message)
+	Set(conditions, generation, TypeDegraded, metav1.ConditionFalse, reason, message)
+}
+
+// MarkProgressing reports a resource being created or changed toward its spec. Degraded is
+// left as it is until the outcome is known.
+func MarkProgressing(conditions *[]metav1.Condition, generation int64, reason, message string) {
+	Set(conditions, generation, TypeReady, metav1.ConditionFalse, reason, message)
+	Set(conditions, generation, TypeProgressing, metav1.ConditionTrue, reason, message)
+}
+
+// MarkFailed reports a failed reconcile: not Ready, no longer progressing, and Degraded
+func MarkFailed(conditions *[]metav1.Condition, generation int64, reason, message string) {
+	Set(conditions, generation, TypeReady, metav1.ConditionFalse, reason, message)
+	Set(conditions, generation, TypeProgressing, metav1.ConditionFalse, reason, message)
+	Set(conditions, generation, TypeDegraded, metav1.ConditionTrue, reason, message)
+}
+
+// IsReady reports whether the Ready condition is true
+func IsReady(conditions []metav1.Condition) bool {
+	return meta.IsStatusConditionTrue(conditions, TypeReady)
+}
+
+func statusOf(ok bool) metav1.ConditionStatus {
+	if ok {
+		return metav1.ConditionTrue
+	}
+	return metav1.ConditionFalse
+}
@@ -0,0 +1,48 @@
+package conditions
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMarkStandardConditions(t *testing.T) {
+	var conditions []metav1.Condition
+	want := func(step, conditionType string, status metav1.ConditionStatus, reason string) {
+		t.Helper()
+		c := meta.FindStatusCondition(conditions, conditionType)
+		if c == nil || c.Status != status || c.Reason != reason || c.ObservedGeneration != 2 {
+			t.Errorf("%s: %s = %+v, want %s with reason %s", step, conditionType, c, status, reason)
+		}
+	}
+
+	MarkFailed(&conditions, 2, ReasonCreateFailed, "boom")
+	want("failed", TypeReady, metav1.ConditionFalse, ReasonCreateFailed)
+	want("failed", TypeProgressing, metav1.ConditionFalse, ReasonCreateFailed)
+	want("failed", TypeDegraded, metav1.ConditionTrue, ReasonCreateFailed)
+
+	// Degraded stays until the outcome is known
+	MarkProgressing(&conditions, 2, ReasonUpgrading, "upgrading")
+	want("progressing", TypeProgressing, metav1.ConditionTrue, ReasonUpgrading)
+	want("progressing", TypeDegraded, metav1.ConditionTrue, ReasonCreateFailed)
+
+	MarkReady(&conditions, 2, ReasonReconciled, "ok")
+	want("ready", TypeReady, metav1.ConditionTrue, ReasonReconciled)
+	want("ready", TypeProgressing, metav1.ConditionFalse, ReasonReconciled)
+	want("ready", TypeDegraded, metav1.ConditionFalse, ReasonReconciled)
+	if !IsReady(conditions) {
+		t.Error("IsReady() = false after MarkReady")
+	}
+}
+
+func TestSetReadyLeavesOtherConditions(t *testing.T) {
+	conditions := []metav1.Condition{{Type: TypeDegraded, Status: metav1.ConditionTrue, Reason: "RulesDiverged"}}
+	SetReady(&conditions, 1, false, ReasonEndpointsEmpty, "no endpoints")
+	if IsReady(conditions) {
+		t.Error("IsReady() = true after SetReady(false)")
+	}
+	if !meta.IsStatusConditionTrue(conditions, TypeDegraded) || len(conditions) != 2 {
+		t.Errorf("SetReady() changed other conditions: %+v", conditions)
+	}
+}
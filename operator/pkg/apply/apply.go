@@ -0,0 +1,62 @@
+// Package apply writes the child resources of the operator with server-side apply
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// FieldManager is the field manager the operator writes its child resources with. The API
+// server records which fields it set, so fields of other writers, e.g. annotations of a
+// service mesh or replicas of an autoscaler, survive every reconcile.
+const FieldManager = "k8s-playgrounds-operator"
+
+// LegacyFieldManagers are the field managers of the create and update requests earlier
+// releases wrote child resources with. Without a field manager of its own a request is
+// recorded under the name of the binary.
+var LegacyFieldManagers = sets.New("manager")
+
+// Apply writes obj with server-side apply, creating it when it does not exist. obj holds the
+// complete desired state: fields it sets are taken over from other managers, and fields the
+// operator set before but obj leaves out are removed. On success obj holds the stored object.
+func Apply(ctx context.Context, c client.Client, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return fmt.Errorf("failed to apply %s: %w", obj.GetName(), err)
+	}
+	// Apply requests carry the type of the object and must not carry a resource version or
+	// the managed fields read with it
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to apply %s: %w", obj.GetName(), err)
+	}
+	patch := client.RawPatch(types.ApplyPatchType, data)
+	if err := c.Patch(ctx, obj, patch, client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		return err
+	}
+
+	// Fields earlier releases wrote with create and update requests stay owned by their
+	// manager, so the apply cannot remove them. Hand them to FieldManager and apply again to
+	// remove the ones obj leaves out, e.g. the selector of a Service.
+	upgrade, err := csaupgrade.UpgradeManagedFieldsPatch(obj, LegacyFieldManagers, FieldManager)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade managed fields of %s: %w", obj.GetName(), err)
+	}
+	if upgrade == nil {
+		return nil
+	}
+	if err := c.Patch(ctx, obj, client.RawPatch(types.JSONPatchType, upgrade)); err != nil {
+		return fmt.Errorf("failed to upgrade managed fields of %s: %w", obj.GetName(), err)
+	}
+	return c.Patch(ctx, obj, patch, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
@@ -0,0 +1,85 @@
+package apply
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func managedFieldsEntry(manager string, operation metav1.ManagedFieldsOperationType, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  operation,
+		APIVersion: "v1",
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func TestApplyUpgradesLegacyManagedFields(t *testing.T) {
+	// The managed fields the API server stores; the fake client does not track them
+	managedFields := []metav1.ManagedFieldsEntry{
+		managedFieldsEntry("manager", metav1.ManagedFieldsOperationUpdate, `{"f:spec":{"f:selector":{}}}`),
+		managedFieldsEntry(FieldManager, metav1.ManagedFieldsOperationApply, `{"f:spec":{"f:ports":{}}}`),
+	}
+	var patches []types.PatchType
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patches = append(patches, patch.Type())
+			if patch.Type() == types.JSONPatchType {
+				data, err := patch.Data(obj)
+				if err != nil {
+					return err
+				}
+				var ops []struct {
+					Path  string          `json:"path"`
+					Value json.RawMessage `json:"value"`
+				}
+				if err := json.Unmarshal(data, &ops); err != nil {
+					return err
+				}
+				for _, op := range ops {
+					if op.Path == "/metadata/managedFields" {
+						managedFields = nil
+						if err := json.Unmarshal(op.Value, &managedFields); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			obj.SetManagedFields(managedFields)
+			return nil
+		},
+	}).Build()
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"}}
+	if err := Apply(context.Background(), c, service); err != nil {
+		t.Fatal(err)
+	}
+	if want := []types.PatchType{types.ApplyPatchType, types.JSONPatchType, types.ApplyPatchType}; !reflect.DeepEqual(patches, want) {
+		t.Fatalf("patches = %v, want an apply, the upgrade of the managed fields and an apply again", patches)
+	}
+	for _, entry := range managedFields {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply {
+			t.Errorf("managed fields entry of %s %s left after the upgrade", entry.Manager, entry.Operation)
+		}
+	}
+
+	// Once upgraded, an object is applied once
+	patches = nil
+	if err := Apply(context.Background(), c, service); err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 1 {
+		t.Errorf("patches = %v, want a single apply", patches)
+	}
+}
@@ -0,0 +1,33 @@
+// Package applytest lets tests run code that applies objects against the fake client, which
+// does not implement server-side apply
+package applytest
+
+import (
+	"context"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// Funcs intercepts apply patches of a fake client: objects that do not exist are created and
+// existing ones are merged with the applied fields. Unlike the API server it does not remove
+// fields an earlier apply set.
+func Funcs() interceptor.Funcs {
+	return interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			if patch.Type() != types.ApplyPatchType {
+				return c.Patch(ctx, obj, patch, opts...)
+			}
+			existing := obj.DeepCopyObject().(client.Object)
+			if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+				return c.Create(ctx, obj)
+			} else if err != nil {
+				return err
+			}
+			// The fake client merges apply patches like strategic merge patches
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	}
+}
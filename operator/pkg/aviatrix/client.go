@@ -2,80 +2,296 @@ package aviatrix
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Defaults of the connection pool to the Aviatrix Controller
+const (
+	// DefaultMaxConnsPerHost bounds the concurrent connections to the Controller
+	DefaultMaxConnsPerHost = 16
+	// DefaultMaxIdleConnsPerHost is how many connections are kept open between requests. The
+	// default transport keeps 2, so parallel reconciles kept reconnecting.
+	DefaultMaxIdleConnsPerHost = 16
+	// DefaultIdleConnTimeout closes connections unused for this long
+	DefaultIdleConnTimeout = 90 * time.Second
+	// DefaultRequestTimeout bounds a single request, on top of the context of the caller
+	DefaultRequestTimeout = 30 * time.Second
+)
+
+// Renewal of expired sessions
+const (
+	// DefaultLoginAttempts is how often a request whose session expired tries to log in again
+	DefaultLoginAttempts = 3
+	// DefaultLoginBackoff is the wait before the second login attempt; it doubles per attempt
+	DefaultLoginBackoff = 500 * time.Millisecond
 )
 
-// Client represents an Aviatrix API client
+// PoolConfig tunes the connections and requests to the Aviatrix Controller. Zero fields use the
+// defaults.
+type PoolConfig struct {
+	MaxConnsPerHost     int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	RequestTimeout      time.Duration
+
+	// RequestsPerSecond and RequestBurst limit the requests sent to the Controller; a negative
+	// rate disables the limit
+	RequestsPerSecond float64
+	RequestBurst      int
+	// MaxRetries is how often a request that failed transiently is repeated, waiting
+	// RetryBackoff before the first retry and doubling it up to MaxRetryBackoff; a negative
+	// number disables retries
+	MaxRetries      int
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+	// BreakerThreshold requests in a row that fail transiently open the circuit breaker, which
+	// fails requests without sending them for BreakerCooldown
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// MaxInFlight bounds the requests sent to the Controller at once; further requests wait in
+	// order. MaxInFlightPerPool bounds those of one pool, see WithRequestPool, so reconciles of
+	// one kind of resource cannot hold up all others.
+	MaxInFlight        int
+	MaxInFlightPerPool int
+
+	// TLS verifies the certificate of the Controller and holds the client certificate; nil
+	// verifies against the system roots. See TLSOptions.
+	TLS *tls.Config
+}
+
+// Client represents an Aviatrix API client. It is safe for concurrent use: its session is
+// shared by all reconciles and only replaced through its session manager, and requests run in
+// parallel, queued per pool, over a pool of connections. They stop when the context of the
+// caller is done.
 type Client struct {
 	ControllerIP string
-	Username     string
-	Password     string
 	HTTPClient   *http.Client
-	SessionID    string
+
+	sessions sessionManager
+
+	// queue, limiter, retry and breaker keep the requests of all reconciles from overwhelming
+	// the Controller; a Client built without NewClient sends every request once, without limits
+	queue   *requestQueue
+	limiter *rate.Limiter
+	retry   retryPolicy
+	breaker *breaker
+}
+
+// Credentials authenticate with the Aviatrix Controller
+type Credentials struct {
+	Username string
+	Password string
 }
 
-// NewClient creates a new Aviatrix client
-func NewClient(controllerIP, username, password string) (*Client, error) {
+// NewClient creates a new Aviatrix client with its own connection pool and logs in
+func NewClient(ctx context.Context, controllerIP, username, password string, pool PoolConfig) (*Client, error) {
 	client := &Client{
 		ControllerIP: controllerIP,
-		Username:     username,
-		Password:     password,
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		HTTPClient:   newHTTPClient(pool),
 	}
+	client.sessions.credentials = Credentials{Username: username, Password: password}
+	client.applyLimits(pool)
 
 	// Login to get session ID
-	if err := client.Login(); err != nil {
+	if err := client.Login(ctx); err != nil {
 		return nil, fmt.Errorf("failed to login: %w", err)
 	}
 
 	return client, nil
 }
 
-// Login authenticates with the Aviatrix Controller
-func (c *Client) Login() error {
+// newHTTPClient returns an HTTP client with a transport of its own, so the pool can be sized
+// for the Controller without affecting other clients of the process
+func newHTTPClient(pool PoolConfig) *http.Client {
+	if pool.MaxConnsPerHost <= 0 {
+		pool.MaxConnsPerHost = DefaultMaxConnsPerHost
+	}
+	if pool.MaxIdleConnsPerHost <= 0 {
+		pool.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if pool.IdleConnTimeout <= 0 {
+		pool.IdleConnTimeout = DefaultIdleConnTimeout
+	}
+	if pool.RequestTimeout <= 0 {
+		pool.RequestTimeout = DefaultRequestTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = pool.MaxConnsPerHost
+	transport.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = pool.IdleConnTimeout
+	if pool.TLS != nil {
+		transport.TLSClientConfig = pool.TLS.Clone()
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   pool.RequestTimeout,
+	}
+}
+
+// session returns the session ID of the last login
+func (c *Client) session() string {
+	return c.sessions.current()
+}
+
+// Credentials returns the credentials the client logs in with
+func (c *Client) Credentials() Credentials {
+	credentials, _ := c.sessions.snapshot()
+	return credentials
+}
+
+// SetCredentials logs in with new credentials and uses them from then on. When the login
+// fails the credentials and session in effect are kept.
+func (c *Client) SetCredentials(ctx context.Context, credentials Credentials) error {
+	sessionID, err := c.login(ctx, credentials)
+	if err != nil {
+		return err
+	}
+	c.sessions.replace(credentials, sessionID)
+	return nil
+}
+
+// Login authenticates with the Aviatrix Controller. When SetCredentials replaced the
+// credentials meanwhile, their session is kept.
+func (c *Client) Login(ctx context.Context) error {
+	credentials, generation := c.sessions.snapshot()
+	sessionID, err := c.login(ctx, credentials)
+	if err != nil {
+		return err
+	}
+	c.sessions.update(generation, sessionID)
+	return nil
+}
+
+// login authenticates with credentials and returns the ID of the new session
+func (c *Client) login(ctx context.Context, credentials Credentials) (string, error) {
 	loginData := map[string]string{
 		"action":   "login",
-		"username": c.Username,
-		"password": c.Password,
+		"username": credentials.Username,
+		"password": credentials.Password,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", loginData)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", loginData)
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	var result map[string]interface{}
+	var result struct {
+		Return interface{} `json:"return"`
+		Reason interface{} `json:"reason"`
+		CID    string      `json:"CID"`
+	}
 	if err := json.Unmarshal(resp, &result); err != nil {
-		return err
+		return "", &APIError{Op: "log in", Code: ErrorCodeUnknown, Reason: "malformed response: " + err.Error(), err: err}
 	}
 
-	if result["return"] == true {
-		c.SessionID = result["CID"].(string)
-		return nil
+	if result.Return != true {
+		reason := fmt.Sprintf("%v", result.Reason)
+		code := classifyReason(reason)
+		if code == ErrorCodeUnknown || code == ErrorCodeInvalid {
+			code = ErrorCodeUnauthorized
+		}
+		return "", &APIError{Op: "log in", Code: code, Reason: reason}
 	}
 
-	return fmt.Errorf("login failed: %s", result["reason"])
+	return result.CID, nil
 }
 
 // Logout logs out from the Aviatrix Controller
-func (c *Client) Logout() error {
+func (c *Client) Logout(ctx context.Context) error {
 	logoutData := map[string]string{
 		"action": "logout",
-		"CID":    c.SessionID,
+		"CID":    c.session(),
 	}
 
-	_, err := c.makeRequest("POST", "/v1/api", logoutData)
+	// An expired session needs no logout
+	_, err := c.send(ctx, "POST", "/v1/api", logoutData)
 	return err
 }
 
-// makeRequest makes an HTTP request to the Aviatrix Controller
-func (c *Client) makeRequest(method, endpoint string, data interface{}) ([]byte, error) {
+// makeRequest makes an HTTP request to the Aviatrix Controller, retrying transient failures
+// within the rate limit of the client. When the Controller rejects the session of the request
+// as expired, the client logs in again and repeats the request once with the new session.
+func (c *Client) makeRequest(ctx context.Context, method, endpoint string, data interface{}) ([]byte, error) {
+	resp, err := c.send(ctx, method, endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, ok := requestSession(data)
+	if !ok || !sessionExpired(resp) {
+		return resp, nil
+	}
+
+	renewed, err := c.sessions.renew(ctx, sessionID, c.Login)
+	if err != nil {
+		return nil, fmt.Errorf("session expired and login failed: %w", err)
+	}
+	return c.send(ctx, method, endpoint, withSession(data, renewed))
+}
+
+// requestSession returns the session ID a request carries
+func requestSession(data interface{}) (string, bool) {
+	switch data := data.(type) {
+	case map[string]string:
+		sessionID, ok := data["CID"]
+		return sessionID, ok
+	case map[string]interface{}:
+		sessionID, ok := data["CID"].(string)
+		return sessionID, ok
+	}
+	return "", false
+}
+
+// withSession returns a copy of the data of a request with another session ID
+func withSession(data interface{}, sessionID string) interface{} {
+	switch data := data.(type) {
+	case map[string]string:
+		renewed := make(map[string]string, len(data))
+		for key, value := range data {
+			renewed[key] = value
+		}
+		renewed["CID"] = sessionID
+		return renewed
+	case map[string]interface{}:
+		renewed := make(map[string]interface{}, len(data))
+		for key, value := range data {
+			renewed[key] = value
+		}
+		renewed["CID"] = sessionID
+		return renewed
+	}
+	return data
+}
+
+// sessionExpired reports whether the Controller rejected a request because its session is
+// invalid or expired, e.g. "CID is invalid or expired."
+func sessionExpired(resp []byte) bool {
+	var result struct {
+		Return interface{} `json:"return"`
+		Reason string      `json:"reason"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil || result.Return == true {
+		return false
+	}
+	reason := strings.ToLower(result.Reason)
+	return strings.Contains(reason, "cid") && (strings.Contains(reason, "invalid") || strings.Contains(reason, "expired"))
+}
+
+// do sends a single HTTP request to the Aviatrix Controller. The request is abandoned when ctx
+// is done, e.g. when the reconcile of the caller is cancelled on shutdown.
+func (c *Client) do(ctx context.Context, method, endpoint string, data interface{}) ([]byte, error) {
 	url := fmt.Sprintf("https://%s%s", c.ControllerIP, endpoint)
 
 	var body io.Reader
@@ -87,7 +303,7 @@ func (c *Client) makeRequest(method, endpoint string, data interface{}) ([]byte,
 		body = bytes.NewBuffer(jsonData)
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -96,18 +312,36 @@ func (c *Client) makeRequest(method, endpoint string, data interface{}) ([]byte,
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		code := ErrorCodeUnavailable
+		if ctx.Err() != nil {
+			// The caller gave up; retrying with the same context cannot succeed
+			code = ErrorCodeUnknown
+		}
+		return nil, &APIError{Op: "reach the Aviatrix Controller", Code: code, Reason: err.Error(), err: err}
 	}
 	defer resp.Body.Close()
 
-	return io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &APIError{Op: "reach the Aviatrix Controller", Code: ErrorCodeUnavailable, Reason: err.Error(), StatusCode: resp.StatusCode, err: err}
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, &APIError{
+			Op:         method + " " + endpoint,
+			Code:       classifyStatus(resp.StatusCode),
+			Reason:     resp.Status,
+			StatusCode: resp.StatusCode,
+			RetryAfter: retryAfterHeader(resp.Header, time.Now()),
+		}
+	}
+	return respBody, nil
 }
 
 // CreateGateway creates a new gateway
-func (c *Client) CreateGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
+func (c *Client) CreateGateway(ctx context.Context, gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
 	data := map[string]interface{}{
 		"action":     "create_gateway",
-		"CID":        c.SessionID,
+		"CID":        c.session(),
 		"gw_name":    gwName,
 		"cloud_type": cloudType,
 		"account_name": accountName,
@@ -117,78 +351,75 @@ func (c *Client) CreateGateway(gwName, cloudType, accountName, vpcID, vpcRegion,
 		"subnet":     subnet,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
-		return err
-	}
-
-	if result["return"] != true {
-		return fmt.Errorf("failed to create gateway: %s", result["reason"])
-	}
-
-	return nil
+	return decodeResult(resp, "create gateway", nil)
 }
 
 // DeleteGateway deletes a gateway
-func (c *Client) DeleteGateway(gwName string) error {
+func (c *Client) DeleteGateway(ctx context.Context, gwName string) error {
 	data := map[string]string{
 		"action":  "delete_gateway",
-		"CID":     c.SessionID,
+		"CID":     c.session(),
 		"gw_name": gwName,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
-		return err
-	}
-
-	if result["return"] != true {
-		return fmt.Errorf("failed to delete gateway: %s", result["reason"])
-	}
-
-	return nil
+	return decodeResult(resp, "delete gateway", nil)
 }
 
-// GetGateway retrieves gateway information
-func (c *Client) GetGateway(gwName string) (map[string]interface{}, error) {
+// GetGateway retrieves gateway information. A gateway the Controller does not know fails with an
+// APIError for which IsNotFound is true.
+func (c *Client) GetGateway(ctx context.Context, gwName string) (*GatewayInfo, error) {
 	data := map[string]string{
 		"action":  "get_gateway_info",
-		"CID":     c.SessionID,
+		"CID":     c.session(),
 		"gw_name": gwName,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
+	info := &GatewayInfo{}
+	if err := decodeResult(resp, "get gateway", info); err != nil {
 		return nil, err
 	}
+	return info, nil
+}
+
+// ListGateways lists the gateways known to the Controller
+func (c *Client) ListGateways(ctx context.Context) ([]GatewayInfo, error) {
+	data := map[string]string{
+		"action": "list_vpcs_summary",
+		"CID":    c.session(),
+	}
 
-	if result["return"] != true {
-		return nil, fmt.Errorf("failed to get gateway: %s", result["reason"])
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	var gateways []GatewayInfo
+	if err := decodeResult(resp, "list gateways", &gateways); err != nil {
+		return nil, err
+	}
+	return gateways, nil
 }
 
 // CreateVpc creates a new VPC
-func (c *Client) CreateVpc(name, cloudType, accountName, region, cidr string) error {
+func (c *Client) CreateVpc(ctx context.Context, name, cloudType, accountName, region, cidr string) error {
 	data := map[string]string{
 		"action":       "create_vpc",
-		"CID":          c.SessionID,
+		"CID":          c.session(),
 		"name":         name,
 		"cloud_type":   cloudType,
 		"account_name": accountName,
@@ -196,146 +427,1492 @@ func (c *Client) CreateVpc(name, cloudType, accountName, region, cidr string) er
 		"cidr":         cidr,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
-		return err
+	return decodeResult(resp, "create VPC", nil)
+}
+
+// DeleteVpc deletes a VPC
+func (c *Client) DeleteVpc(ctx context.Context, name string) error {
+	data := map[string]string{
+		"action": "delete_vpc",
+		"CID":    c.session(),
+		"name":   name,
 	}
 
-	if result["return"] != true {
-		return fmt.Errorf("failed to create VPC: %s", result["reason"])
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return decodeResult(resp, "delete VPC", nil)
 }
 
-// DeleteVpc deletes a VPC
-func (c *Client) DeleteVpc(name string) error {
+// GetVpc retrieves VPC information. A VPC the Controller does not know fails with an APIError
+// for which IsNotFound is true.
+func (c *Client) GetVpc(ctx context.Context, name string) (*VpcInfo, error) {
 	data := map[string]string{
-		"action": "delete_vpc",
-		"CID":    c.SessionID,
+		"action": "get_vpc_info",
+		"CID":    c.session(),
 		"name":   name,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &VpcInfo{}
+	if err := decodeResult(resp, "get VPC", info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListVpcs lists the VPCs known to the Controller
+func (c *Client) ListVpcs(ctx context.Context) ([]VpcInfo, error) {
+	data := map[string]string{
+		"action": "list_custom_vpcs",
+		"CID":    c.session(),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var vpcs []VpcInfo
+	if err := decodeResult(resp, "list VPCs", &vpcs); err != nil {
+		return nil, err
+	}
+	return vpcs, nil
+}
+
+// CreateFirewall sets the firewall policy of a gateway
+func (c *Client) CreateFirewall(ctx context.Context, policy FirewallPolicy) error {
+	rules := policy.Rules
+	if rules == nil {
+		rules = []FirewallRule{}
+	}
+	data := map[string]interface{}{
+		"action":      "set_firewall",
+		"CID":         c.session(),
+		"gw_name":     policy.GwName,
+		"base_policy": policy.BasePolicy,
+		"rules":       rules,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
+	return decodeResult(resp, "create firewall", nil)
+}
+
+// DeleteFirewall deletes firewall rules
+func (c *Client) DeleteFirewall(ctx context.Context, gwName string) error {
+	data := map[string]string{
+		"action":  "delete_firewall",
+		"CID":     c.session(),
+		"gw_name": gwName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
 		return err
 	}
 
-	if result["return"] != true {
-		return fmt.Errorf("failed to delete VPC: %s", result["reason"])
+	return decodeResult(resp, "delete firewall", nil)
+}
+
+// GetFirewall retrieves the firewall policy of a gateway
+func (c *Client) GetFirewall(ctx context.Context, gwName string) (*FirewallPolicy, error) {
+	data := map[string]string{
+		"action":  "get_firewall",
+		"CID":     c.session(),
+		"gw_name": gwName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	policy := &FirewallPolicy{GwName: gwName}
+	if err := decodeResult(resp, "get firewall", policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
 }
 
-// GetVpc retrieves VPC information
-func (c *Client) GetVpc(name string) (map[string]interface{}, error) {
+// GetFirewallRuleHits retrieves the hit counter and last hit time of each firewall rule on a
+// gateway
+func (c *Client) GetFirewallRuleHits(ctx context.Context, gwName string) ([]map[string]interface{}, error) {
 	data := map[string]string{
-		"action": "get_vpc_info",
-		"CID":    c.SessionID,
-		"name":   name,
+		"action":  "get_firewall_rule_hit_count",
+		"CID":     c.session(),
+		"gw_name": gwName,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
+	var hits []map[string]interface{}
+	if err := decodeResult(resp, "get firewall rule hits", &hits); err != nil {
 		return nil, err
 	}
+	return hits, nil
+}
+
+// ListSecurityGroupRules lists the rules of an AWS security group or Azure network security group
+// from the Controller's cloud inventory
+func (c *Client) ListSecurityGroupRules(ctx context.Context, accountName, cloudType, region, groupID string) ([]map[string]interface{}, error) {
+	data := map[string]string{
+		"action":       "list_cloud_security_group_rules",
+		"CID":          c.session(),
+		"account_name": accountName,
+		"cloud_type":   cloudType,
+		"region":       region,
+		"group_id":     groupID,
+	}
 
-	if result["return"] != true {
-		return nil, fmt.Errorf("failed to get VPC: %s", result["reason"])
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
 	}
 
-	return result, nil
+	var rules []map[string]interface{}
+	if err := decodeResult(resp, "list security group rules", &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
 }
 
-// CreateFirewall creates firewall rules
-func (c *Client) CreateFirewall(gwName, basePolicy string, rules []map[string]interface{}) error {
-	data := map[string]interface{}{
-		"action":      "set_firewall",
-		"CID":         c.SessionID,
-		"gw_name":     gwName,
-		"base_policy": basePolicy,
-		"rules":       rules,
+// StopGateway stops the instance backing a gateway without deleting it
+func (c *Client) StopGateway(ctx context.Context, gwName string) error {
+	data := map[string]string{
+		"action":  "stop_gateway",
+		"CID":     c.session(),
+		"gw_name": gwName,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
-		return err
+	return decodeResult(resp, "stop gateway", nil)
+}
+
+// StartGateway starts a previously stopped gateway instance
+func (c *Client) StartGateway(ctx context.Context, gwName string) error {
+	data := map[string]string{
+		"action":  "start_gateway",
+		"CID":     c.session(),
+		"gw_name": gwName,
 	}
 
-	if result["return"] != true {
-		return fmt.Errorf("failed to create firewall: %s", result["reason"])
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return decodeResult(resp, "start gateway", nil)
 }
 
-// DeleteFirewall deletes firewall rules
-func (c *Client) DeleteFirewall(gwName string) error {
+// ResizeGateway changes the instance size of an existing gateway
+func (c *Client) ResizeGateway(ctx context.Context, gwName, gwSize string) error {
 	data := map[string]string{
-		"action":  "delete_firewall",
-		"CID":     c.SessionID,
+		"action":  "edit_gw_size",
+		"CID":     c.session(),
 		"gw_name": gwName,
+		"gw_size": gwSize,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
+	return decodeResult(resp, "resize gateway", nil)
+}
+
+// UpgradeGateway upgrades a gateway to a software and image version. An empty version keeps
+// the current one. The Controller upgrades the gateway in the background.
+func (c *Client) UpgradeGateway(ctx context.Context, gwName, softwareVersion, imageVersion string) error {
+	data := map[string]string{
+		"action":       "upgrade_selected_gateway",
+		"CID":          c.session(),
+		"gateway_list": gwName,
+	}
+	if softwareVersion != "" {
+		data["software_version"] = softwareVersion
+	}
+	if imageVersion != "" {
+		data["image_version"] = imageVersion
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
 		return err
 	}
 
-	if result["return"] != true {
-		return fmt.Errorf("failed to delete firewall: %s", result["reason"])
+	return decodeResult(resp, "upgrade gateway", nil)
+}
+
+// GetResourceTags retrieves the tags of a gateway or VPC
+func (c *Client) GetResourceTags(ctx context.Context, resourceType, resourceName string) (map[string]string, error) {
+	data := map[string]string{
+		"action":        "list_resource_tags",
+		"CID":           c.session(),
+		"resource_type": resourceType,
+		"resource_name": resourceName,
 	}
 
-	return nil
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var results map[string]Value
+	if err := decodeResult(resp, "get tags", &results); err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(results))
+	for key, value := range results {
+		tags[key] = string(value)
+	}
+	return tags, nil
 }
 
-// GetFirewall retrieves firewall rules
-func (c *Client) GetFirewall(gwName string) (map[string]interface{}, error) {
+// AddResourceTags adds tags to a gateway or VPC, overwriting existing values
+func (c *Client) AddResourceTags(ctx context.Context, resourceType, resourceName string, tags map[string]string) error {
 	data := map[string]string{
-		"action":  "get_firewall",
-		"CID":     c.SessionID,
+		"action":        "add_resource_tags",
+		"CID":           c.session(),
+		"resource_type": resourceType,
+		"resource_name": resourceName,
+		"tag_list":      formatTagList(tags),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "add tags", nil)
+}
+
+// DeleteResourceTags removes tags from a gateway or VPC by key
+func (c *Client) DeleteResourceTags(ctx context.Context, resourceType, resourceName string, keys []string) error {
+	tags := make(map[string]string, len(keys))
+	for _, key := range keys {
+		tags[key] = ""
+	}
+
+	data := map[string]string{
+		"action":        "delete_resource_tags",
+		"CID":           c.session(),
+		"resource_type": resourceType,
+		"resource_name": resourceName,
+		"tag_list":      formatTagList(tags),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete tags", nil)
+}
+
+// PingFromGateway pings a host from a gateway and returns the ping output
+func (c *Client) PingFromGateway(ctx context.Context, gwName, host string, count int) (string, error) {
+	data := map[string]string{
+		"action":    "gateway_diag_ping",
+		"CID":       c.session(),
+		"gw_name":   gwName,
+		"host_name": host,
+		"count":     strconv.Itoa(count),
+	}
+
+	return c.diagnosticOutput(ctx, data, "ping from gateway")
+}
+
+// TracerouteFromGateway traces the route to a host from a gateway and returns the traceroute output
+func (c *Client) TracerouteFromGateway(ctx context.Context, gwName, host string) (string, error) {
+	data := map[string]string{
+		"action":    "gateway_diag_traceroute",
+		"CID":       c.session(),
+		"gw_name":   gwName,
+		"host_name": host,
+	}
+
+	return c.diagnosticOutput(ctx, data, "traceroute from gateway")
+}
+
+// GetTunnelStatus lists the tunnels of a gateway with their state
+func (c *Client) GetTunnelStatus(ctx context.Context, gwName string) ([]map[string]interface{}, error) {
+	data := map[string]string{
+		"action":  "list_gateway_tunnel_status",
+		"CID":     c.session(),
 		"gw_name": gwName,
 	}
 
-	resp, err := c.makeRequest("POST", "/v1/api", data)
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
 	if err != nil {
 		return nil, err
 	}
 
-	var result map[string]interface{}
-	if err := json.Unmarshal(resp, &result); err != nil {
+	var tunnels []map[string]interface{}
+	if err := decodeResult(resp, "get tunnel status", &tunnels); err != nil {
 		return nil, err
 	}
+	return tunnels, nil
+}
+
+// StartPacketCapture starts a packet capture on a gateway, optionally filtered by host and port
+func (c *Client) StartPacketCapture(ctx context.Context, gwName, host, port string, durationSeconds int) error {
+	data := map[string]string{
+		"action":   "start_packet_capture",
+		"CID":      c.session(),
+		"gw_name":  gwName,
+		"host":     host,
+		"port":     port,
+		"duration": strconv.Itoa(durationSeconds),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "start packet capture", nil)
+}
+
+// StopPacketCapture stops the running packet capture on a gateway
+func (c *Client) StopPacketCapture(ctx context.Context, gwName string) error {
+	data := map[string]string{
+		"action":  "stop_packet_capture",
+		"CID":     c.session(),
+		"gw_name": gwName,
+	}
 
-	if result["return"] != true {
-		return nil, fmt.Errorf("failed to get firewall: %s", result["reason"])
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
 	}
 
-	return result, nil
+	return decodeResult(resp, "stop packet capture", nil)
+}
+
+// UploadPacketCapture uploads the last packet capture of a gateway to a bucket and returns its URL
+func (c *Client) UploadPacketCapture(ctx context.Context, gwName, bucket, objectKey string) (string, error) {
+	data := map[string]string{
+		"action":      "upload_packet_capture",
+		"CID":         c.session(),
+		"gw_name":     gwName,
+		"bucket_name": bucket,
+		"object_key":  objectKey,
+	}
+
+	return c.diagnosticOutput(ctx, data, "upload packet capture")
+}
+
+// ConnectionAuth holds the credentials of a Site2Cloud or BGP connection. The standby
+// credential stays accepted next to the primary one while a rotation overlaps; leave it
+// empty to remove it.
+type ConnectionAuth struct {
+	AuthType            string
+	PreSharedKey        string
+	StandbyPreSharedKey string
+	Certificate         string
+	PrivateKey          string
+	StandbyCertificate  string
+}
+
+// connectionAuthActions maps connection types to the API action that edits their credentials
+var connectionAuthActions = map[string]string{
+	"site2cloud": "edit_site2cloud_conn_auth",
+	"bgp":        "edit_transit_external_conn_auth",
+}
+
+// UpdateConnectionAuth replaces the credentials of a Site2Cloud or BGP connection on a gateway
+func (c *Client) UpdateConnectionAuth(ctx context.Context, connectionType, gwName, connectionName string, auth ConnectionAuth) error {
+	action, ok := connectionAuthActions[connectionType]
+	if !ok {
+		return fmt.Errorf("unsupported connection type %q", connectionType)
+	}
+
+	data := map[string]string{
+		"action":          action,
+		"CID":             c.session(),
+		"gw_name":         gwName,
+		"connection_name": connectionName,
+		"auth_type":       auth.AuthType,
+	}
+	if auth.AuthType == "certificate" {
+		data["cert"] = auth.Certificate
+		data["private_key"] = auth.PrivateKey
+		data["standby_cert"] = auth.StandbyCertificate
+	} else {
+		data["pre_shared_key"] = auth.PreSharedKey
+		data["standby_pre_shared_key"] = auth.StandbyPreSharedKey
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update connection credentials", nil)
+}
+
+// UpdateSpokeAdvertisedCidrs sets the CIDRs a spoke gateway advertises to its transit. An
+// empty list restores advertising the VPC CIDR.
+func (c *Client) UpdateSpokeAdvertisedCidrs(ctx context.Context, gwName string, cidrs []string) error {
+	data := map[string]string{
+		"action":                           "edit_aviatrix_spoke_advertised_cidrs",
+		"CID":                              c.session(),
+		"gateway_name":                     gwName,
+		"included_advertised_spoke_routes": strings.Join(cidrs, ","),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update advertised CIDRs", nil)
+}
+
+// UpdatePrependASPath sets the AS path prepended to the BGP routes a gateway advertises. An
+// empty path removes prepending.
+func (c *Client) UpdatePrependASPath(ctx context.Context, gwName string, asPath []string) error {
+	data := map[string]string{
+		"action":              "edit_aviatrix_transit_advanced_config",
+		"subaction":           "prepend_as_path",
+		"CID":                 c.session(),
+		"gateway_name":        gwName,
+		"bgp_prepend_as_path": strings.Join(asPath, " "),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update AS path prepending", nil)
+}
+
+// TransitGatewayConfig is the gateway a CreateTransitGateway request launches
+type TransitGatewayConfig struct {
+	GwName              string
+	CloudType           string
+	AccountName         string
+	VpcID               string
+	VpcRegion           string
+	GwSize              string
+	Subnet              string
+	EnableNat           bool
+	EnableEncryptVolume bool
+	VolumeSize          int
+	EnableActiveMesh    bool
+}
+
+// CreateTransitGateway launches a transit gateway
+func (c *Client) CreateTransitGateway(ctx context.Context, config TransitGatewayConfig) error {
+	data := map[string]interface{}{
+		"action":                "create_transit_gw",
+		"CID":                   c.session(),
+		"gw_name":               config.GwName,
+		"cloud_type":            config.CloudType,
+		"account_name":          config.AccountName,
+		"vpc_id":                config.VpcID,
+		"vpc_reg":               config.VpcRegion,
+		"gw_size":               config.GwSize,
+		"subnet":                config.Subnet,
+		"enable_nat":            config.EnableNat,
+		"enable_encrypt_volume": config.EnableEncryptVolume,
+		"enable_active_mesh":    config.EnableActiveMesh,
+	}
+	if config.VolumeSize > 0 {
+		data["volume_size"] = config.VolumeSize
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "create transit gateway", nil)
+}
+
+// EnableTransitHA launches the HA gateway of a transit gateway in a subnet and zone. The
+// Controller names it after the gateway with the "-hagw" suffix; deleting that gateway
+// disables HA.
+func (c *Client) EnableTransitHA(ctx context.Context, gwName, subnet, zone, gwSize string) error {
+	data := map[string]string{
+		"action":        "enable_transit_ha",
+		"CID":           c.session(),
+		"gw_name":       gwName,
+		"public_subnet": subnet,
+		"new_zone":      zone,
+		"gw_size":       gwSize,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "enable transit HA", nil)
+}
+
+// TransitFeature is a feature of a transit gateway that is toggled after it is launched
+type TransitFeature string
+
+// Transit gateway features
+const (
+	TransitFeatureBgp          TransitFeature = "BGP"
+	TransitFeatureSegmentation TransitFeature = "Segmentation"
+	TransitFeatureFireNet      TransitFeature = "FireNet"
+)
+
+// transitFeatureActions maps transit features to the API actions that enable and disable them
+var transitFeatureActions = map[TransitFeature][2]string{
+	TransitFeatureBgp:          {"enable_transit_bgp", "disable_transit_bgp"},
+	TransitFeatureSegmentation: {"enable_transit_gateway_for_segmentation", "disable_transit_gateway_for_segmentation"},
+	TransitFeatureFireNet:      {"enable_gateway_for_firenet", "disable_gateway_for_firenet"},
+}
+
+// SetTransitFeature enables or disables a feature of a transit gateway
+func (c *Client) SetTransitFeature(ctx context.Context, gwName string, feature TransitFeature, enabled bool) error {
+	actions, ok := transitFeatureActions[feature]
+	if !ok {
+		return fmt.Errorf("unsupported transit feature %q", feature)
+	}
+	action, op := actions[1], "disable "+string(feature)
+	if enabled {
+		action, op = actions[0], "enable "+string(feature)
+	}
+
+	data := map[string]string{
+		"action":  action,
+		"CID":     c.session(),
+		"gw_name": gwName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, op, nil)
+}
+
+// DomainConnection is a connection policy between two network domains. Traffic flows both
+// ways between connected domains; domains without a policy between them are isolated.
+type DomainConnection struct {
+	Domain1 string `json:"domain_name_1"`
+	Domain2 string `json:"domain_name_2"`
+}
+
+// ListDomainConnectionPolicies lists the connection policies between network domains
+func (c *Client) ListDomainConnectionPolicies(ctx context.Context) ([]DomainConnection, error) {
+	data := map[string]string{
+		"action": "list_segmentation_security_domain_connection_policies",
+		"CID":    c.session(),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var policies []DomainConnection
+	if err := decodeResult(resp, "list domain connection policies", &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// CreateDomainConnectionPolicy connects two network domains
+func (c *Client) CreateDomainConnectionPolicy(ctx context.Context, domain1, domain2 string) error {
+	data := map[string]string{
+		"action":        "add_segmentation_security_domain_connection_policy",
+		"CID":           c.session(),
+		"domain_name_1": domain1,
+		"domain_name_2": domain2,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "create domain connection policy", nil)
+}
+
+// DeleteDomainConnectionPolicy removes the connection policy between two network domains
+func (c *Client) DeleteDomainConnectionPolicy(ctx context.Context, domain1, domain2 string) error {
+	data := map[string]string{
+		"action":        "delete_segmentation_security_domain_connection_policy",
+		"CID":           c.session(),
+		"domain_name_1": domain1,
+		"domain_name_2": domain2,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete domain connection policy", nil)
+}
+
+// NetworkDomain is a segmentation domain on the Controller. Segmentation security domains are
+// network domains under their former name, so both share these calls.
+type NetworkDomain struct {
+	Name        string `json:"domain_name"`
+	Type        string `json:"domain_type,omitempty"`
+	AccountName string `json:"account_name,omitempty"`
+	Region      string `json:"region,omitempty"`
+	CIDR        string `json:"cidr,omitempty"`
+	CloudType   string `json:"cloud_type,omitempty"`
+}
+
+// CreateNetworkDomain creates a network domain. Settings left empty are not sent.
+func (c *Client) CreateNetworkDomain(ctx context.Context, domain NetworkDomain) error {
+	data := map[string]string{
+		"action":      "add_segmentation_security_domain",
+		"CID":         c.session(),
+		"domain_name": domain.Name,
+	}
+	settings := map[string]string{
+		"domain_type":  domain.Type,
+		"account_name": domain.AccountName,
+		"region":       domain.Region,
+		"cidr":         domain.CIDR,
+		"cloud_type":   domain.CloudType,
+	}
+	for key, value := range settings {
+		if value != "" {
+			data[key] = value
+		}
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "create network domain", nil)
+}
+
+// DeleteNetworkDomain deletes a network domain
+func (c *Client) DeleteNetworkDomain(ctx context.Context, name string) error {
+	data := map[string]string{
+		"action":      "delete_segmentation_security_domain",
+		"CID":         c.session(),
+		"domain_name": name,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete network domain", nil)
+}
+
+// ListNetworkDomains lists the network domains known to the Controller
+func (c *Client) ListNetworkDomains(ctx context.Context) ([]NetworkDomain, error) {
+	data := map[string]string{
+		"action": "list_segmentation_security_domains",
+		"CID":    c.session(),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var domains []NetworkDomain
+	if err := decodeResult(resp, "list network domains", &domains); err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// GetNetworkDomain retrieves a network domain. A domain the Controller does not know fails
+// with an APIError for which IsNotFound is true.
+func (c *Client) GetNetworkDomain(ctx context.Context, name string) (*NetworkDomain, error) {
+	domains, err := c.ListNetworkDomains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range domains {
+		if domains[i].Name == name {
+			return &domains[i], nil
+		}
+	}
+	return nil, &APIError{Op: "get network domain", Code: ErrorCodeNotFound, Reason: fmt.Sprintf("network domain %s does not exist", name)}
+}
+
+// VpcPeering is a native AWS VPC peering created through the Controller
+type VpcPeering struct {
+	RequesterAccountName string   `json:"account_name1"`
+	RequesterVpcID       string   `json:"vpc_id1"`
+	RequesterRegion      string   `json:"region1"`
+	RequesterRouteTables []string `json:"rtb_list1,omitempty"`
+	AccepterAccountName  string   `json:"account_name2"`
+	AccepterVpcID        string   `json:"vpc_id2"`
+	AccepterRegion       string   `json:"region2"`
+	AccepterRouteTables  []string `json:"rtb_list2,omitempty"`
+}
+
+// CreateAwsPeering peers two AWS VPCs natively. Empty route table lists program routes to
+// the peer VPC in all route tables.
+func (c *Client) CreateAwsPeering(ctx context.Context, peering VpcPeering) error {
+	data := map[string]string{
+		"action":        "create_aws_peering",
+		"CID":           c.session(),
+		"account_name1": peering.RequesterAccountName,
+		"vpc_id1":       peering.RequesterVpcID,
+		"region1":       peering.RequesterRegion,
+		"account_name2": peering.AccepterAccountName,
+		"vpc_id2":       peering.AccepterVpcID,
+		"region2":       peering.AccepterRegion,
+	}
+	if len(peering.RequesterRouteTables) > 0 {
+		data["rtb_list1"] = strings.Join(peering.RequesterRouteTables, ",")
+	}
+	if len(peering.AccepterRouteTables) > 0 {
+		data["rtb_list2"] = strings.Join(peering.AccepterRouteTables, ",")
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "create AWS peering", nil)
+}
+
+// DeleteAwsPeering removes the native peering between two AWS VPCs
+func (c *Client) DeleteAwsPeering(ctx context.Context, requesterVpcID, accepterVpcID string) error {
+	data := map[string]string{
+		"action":  "delete_aws_peering",
+		"CID":     c.session(),
+		"vpc_id1": requesterVpcID,
+		"vpc_id2": accepterVpcID,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete AWS peering", nil)
+}
+
+// ListAwsPeerings lists the native AWS VPC peerings known to the Controller
+func (c *Client) ListAwsPeerings(ctx context.Context) ([]VpcPeering, error) {
+	data := map[string]string{
+		"action": "list_aws_peerings",
+		"CID":    c.session(),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var peerings []VpcPeering
+	if err := decodeResult(resp, "list AWS peerings", &peerings); err != nil {
+		return nil, err
+	}
+	return peerings, nil
+}
+
+// GetAwsPeering retrieves the native peering between two AWS VPCs, whichever requested it. A
+// peering the Controller does not know fails with an APIError for which IsNotFound is true.
+func (c *Client) GetAwsPeering(ctx context.Context, requesterVpcID, accepterVpcID string) (*VpcPeering, error) {
+	peerings, err := c.ListAwsPeerings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range peerings {
+		p := &peerings[i]
+		if (p.RequesterVpcID == requesterVpcID && p.AccepterVpcID == accepterVpcID) ||
+			(p.RequesterVpcID == accepterVpcID && p.AccepterVpcID == requesterVpcID) {
+			return p, nil
+		}
+	}
+	return nil, &APIError{Op: "get AWS peering", Code: ErrorCodeNotFound, Reason: fmt.Sprintf("peering between %s and %s does not exist", requesterVpcID, accepterVpcID)}
+}
+
+// TgwAttachment is a VPC attached to an AWS Transit Gateway through the Controller
+type TgwAttachment struct {
+	TgwName                      string   `json:"tgw_name"`
+	Region                       string   `json:"region"`
+	VpcAccountName               string   `json:"vpc_account_name"`
+	VpcID                        string   `json:"vpc_id"`
+	NetworkDomain                string   `json:"route_domain_name"`
+	Subnets                      []string `json:"subnet_list,omitempty"`
+	RouteTables                  []string `json:"route_table_list,omitempty"`
+	CustomizedRoutes             []string `json:"customized_routes,omitempty"`
+	CustomizedRouteAdvertisement []string `json:"customized_route_advertisement,omitempty"`
+	DisableLocalRoutePropagation bool     `json:"disable_local_route_propagation"`
+}
+
+// AttachVpcToTgw attaches a VPC to a network domain of an AWS Transit Gateway
+func (c *Client) AttachVpcToTgw(ctx context.Context, attachment TgwAttachment) error {
+	data := map[string]interface{}{
+		"action":                          "attach_vpc_to_tgw",
+		"CID":                             c.session(),
+		"tgw_name":                        attachment.TgwName,
+		"region":                          attachment.Region,
+		"vpc_account_name":                attachment.VpcAccountName,
+		"vpc_name":                        attachment.VpcID,
+		"route_domain_name":               attachment.NetworkDomain,
+		"disable_local_route_propagation": attachment.DisableLocalRoutePropagation,
+	}
+	lists := map[string][]string{
+		"subnet_list":                    attachment.Subnets,
+		"route_table_list":               attachment.RouteTables,
+		"customized_routes":              attachment.CustomizedRoutes,
+		"customized_route_advertisement": attachment.CustomizedRouteAdvertisement,
+	}
+	for key, values := range lists {
+		if len(values) > 0 {
+			data[key] = strings.Join(values, ",")
+		}
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "attach VPC to TGW", nil)
+}
+
+// DetachVpcFromTgw detaches a VPC from an AWS Transit Gateway
+func (c *Client) DetachVpcFromTgw(ctx context.Context, tgwName, vpcID string) error {
+	data := map[string]string{
+		"action":   "detach_vpc_from_tgw",
+		"CID":      c.session(),
+		"tgw_name": tgwName,
+		"vpc_name": vpcID,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "detach VPC from TGW", nil)
+}
+
+// AttachSpokeToTransit attaches a spoke gateway to a transit gateway
+func (c *Client) AttachSpokeToTransit(ctx context.Context, spokeGwName, transitGwName string) error {
+	data := map[string]string{
+		"action":     "attach_spoke_to_transit_gw",
+		"CID":        c.session(),
+		"spoke_gw":   spokeGwName,
+		"transit_gw": transitGwName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "attach spoke to transit gateway", nil)
+}
+
+// DetachSpokeFromTransit detaches a spoke gateway from a transit gateway
+func (c *Client) DetachSpokeFromTransit(ctx context.Context, spokeGwName, transitGwName string) error {
+	data := map[string]string{
+		"action":     "detach_spoke_from_transit_gw",
+		"CID":        c.session(),
+		"spoke_gw":   spokeGwName,
+		"transit_gw": transitGwName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "detach spoke from transit gateway", nil)
+}
+
+// GetTgwAttachment retrieves the attachment of a VPC to an AWS Transit Gateway. A VPC that is
+// not attached fails with an APIError for which IsNotFound is true.
+func (c *Client) GetTgwAttachment(ctx context.Context, tgwName, vpcID string) (*TgwAttachment, error) {
+	data := map[string]string{
+		"action":          "get_tgw_attachment_details",
+		"CID":             c.session(),
+		"tgw_name":        tgwName,
+		"attachment_name": vpcID,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &TgwAttachment{}
+	if err := decodeResult(resp, "get TGW attachment", attachment); err != nil {
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// UpdateTgwAttachmentRoutes replaces the customized routes programmed in the route tables of
+// an attached VPC. Empty routes program the CIDRs of the connected network domains again.
+func (c *Client) UpdateTgwAttachmentRoutes(ctx context.Context, tgwName, vpcID string, routes []string) error {
+	data := map[string]string{
+		"action":            "edit_tgw_spoke_vpc_customized_routes",
+		"CID":               c.session(),
+		"tgw_name":          tgwName,
+		"vpc_name":          vpcID,
+		"customized_routes": strings.Join(routes, ","),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update TGW attachment routes", nil)
+}
+
+// UpdateTgwAttachmentAdvertisement replaces the CIDRs an attached VPC advertises to the TGW.
+// Empty CIDRs advertise the VPC CIDR again.
+func (c *Client) UpdateTgwAttachmentAdvertisement(ctx context.Context, tgwName, vpcID string, cidrs []string) error {
+	data := map[string]string{
+		"action":                         "edit_tgw_spoke_vpc_customized_route_advertisement",
+		"CID":                            c.session(),
+		"tgw_name":                       tgwName,
+		"vpc_name":                       vpcID,
+		"customized_route_advertisement": strings.Join(cidrs, ","),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update TGW attachment route advertisement", nil)
+}
+
+// MicrosegEndpoint is the source or destination of a microsegmentation policy
+type MicrosegEndpoint struct {
+	// Type is subnet, tag or instance
+	Type   string `json:"type"`
+	Value  string `json:"value"`
+	Region string `json:"region,omitempty"`
+	VpcID  string `json:"vpc_id,omitempty"`
+}
+
+// MicrosegPolicy is a microsegmentation (distributed cloud firewall) policy. The Controller
+// assigns the UUID when the policy is created.
+type MicrosegPolicy struct {
+	UUID        string           `json:"uuid,omitempty"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Source      MicrosegEndpoint `json:"src"`
+	Destination MicrosegEndpoint `json:"dst"`
+	Action      string           `json:"action"`
+	Port        string           `json:"port_ranges,omitempty"`
+	Protocol    string           `json:"protocol"`
+	Logging     bool             `json:"logging"`
+}
+
+// CreateMicrosegPolicy adds a microsegmentation policy and returns the UUID the Controller
+// assigned to it
+func (c *Client) CreateMicrosegPolicy(ctx context.Context, policy MicrosegPolicy) (string, error) {
+	policy.UUID = ""
+	data := map[string]interface{}{
+		"action": "add_microseg_policy",
+		"CID":    c.session(),
+		"policy": policy,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return "", err
+	}
+
+	var created struct {
+		UUID string `json:"uuid"`
+	}
+	if err := decodeResult(resp, "create microsegmentation policy", &created); err != nil {
+		return "", err
+	}
+	if created.UUID == "" {
+		return "", &APIError{Op: "create microsegmentation policy", Code: ErrorCodeUnknown, Reason: "no policy UUID in response"}
+	}
+	return created.UUID, nil
+}
+
+// UpdateMicrosegPolicy replaces the microsegmentation policy with the UUID of policy
+func (c *Client) UpdateMicrosegPolicy(ctx context.Context, policy MicrosegPolicy) error {
+	data := map[string]interface{}{
+		"action": "update_microseg_policy",
+		"CID":    c.session(),
+		"uuid":   policy.UUID,
+		"policy": policy,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update microsegmentation policy", nil)
+}
+
+// DeleteMicrosegPolicy deletes a microsegmentation policy
+func (c *Client) DeleteMicrosegPolicy(ctx context.Context, uuid string) error {
+	data := map[string]string{
+		"action": "delete_microseg_policy",
+		"CID":    c.session(),
+		"uuid":   uuid,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete microsegmentation policy", nil)
+}
+
+// GetMicrosegPolicy retrieves a microsegmentation policy. A policy the Controller does not know
+// fails with an APIError for which IsNotFound is true.
+func (c *Client) GetMicrosegPolicy(ctx context.Context, uuid string) (*MicrosegPolicy, error) {
+	data := map[string]string{
+		"action": "get_microseg_policy",
+		"CID":    c.session(),
+		"uuid":   uuid,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &MicrosegPolicy{}
+	if err := decodeResult(resp, "get microsegmentation policy", policy); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// Cloud types of accounts, numbered as the Controller numbers them
+var cloudTypeIDs = map[string]string{
+	"aws":   "1",
+	"gcp":   "4",
+	"azure": "8",
+	"oci":   "16",
+}
+
+// CloudTypeID returns the number the Controller uses for a cloud provider name such as aws.
+// Numbers are returned as they are.
+func CloudTypeID(cloudType string) (string, error) {
+	if id, ok := cloudTypeIDs[strings.ToLower(cloudType)]; ok {
+		return id, nil
+	}
+	for _, id := range cloudTypeIDs {
+		if id == cloudType {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported cloud type %q", cloudType)
+}
+
+// Account is a cloud account onboarded to the Controller. Only the fields of its cloud type are
+// sent; secrets are never reported back by the Controller.
+type Account struct {
+	AccountName string `json:"account_name"`
+	CloudType   Value  `json:"cloud_type"`
+
+	// AWS accounts use IAM roles, or access keys when they are set
+	AwsAccountNumber string `json:"account_number,omitempty"`
+	AwsRoleApp       string `json:"aws_role_arn,omitempty"`
+	AwsRoleEc2       string `json:"aws_role_ec2,omitempty"`
+	AwsAccessKey     string `json:"-"`
+	AwsSecretKey     string `json:"-"`
+
+	// Azure accounts use a service principal
+	AzureSubscriptionID string `json:"arm_subscription_id,omitempty"`
+	AzureDirectoryID    string `json:"arm_directory_id,omitempty"`
+	AzureClientID       string `json:"-"`
+	AzureClientSecret   string `json:"-"`
+
+	// GCP accounts use the key of a service account
+	GcpProjectID   string `json:"gcloud_project_name,omitempty"`
+	GcpCredentials string `json:"-"`
+
+	// OCI accounts use the API signing key of a user
+	OciTenancyID     string `json:"oci_tenancy_id,omitempty"`
+	OciUserID        string `json:"oci_user_id,omitempty"`
+	OciCompartmentID string `json:"oci_compartment_id,omitempty"`
+	OciPrivateKey    string `json:"-"`
+}
+
+// accountData returns the request data of an account action. Settings left empty are not sent.
+func (c *Client) accountData(action string, account Account) (map[string]string, error) {
+	cloudType, err := CloudTypeID(string(account.CloudType))
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]string{
+		"action":       action,
+		"CID":          c.session(),
+		"account_name": account.AccountName,
+		"cloud_type":   cloudType,
+	}
+	var settings map[string]string
+	switch cloudType {
+	case cloudTypeIDs["aws"]:
+		settings = map[string]string{
+			"aws_account_number": account.AwsAccountNumber,
+			"aws_access_key":     account.AwsAccessKey,
+			"aws_secret_key":     account.AwsSecretKey,
+		}
+		// Accounts without access keys let the Controller assume IAM roles
+		if account.AwsAccessKey == "" {
+			settings["aws_iam"] = "true"
+			settings["aws_role_arn"] = account.AwsRoleApp
+			settings["aws_role_ec2"] = account.AwsRoleEc2
+		}
+	case cloudTypeIDs["azure"]:
+		settings = map[string]string{
+			"arm_subscription_id":           account.AzureSubscriptionID,
+			"arm_application_endpoint":      account.AzureDirectoryID,
+			"arm_application_client_id":     account.AzureClientID,
+			"arm_application_client_secret": account.AzureClientSecret,
+		}
+	case cloudTypeIDs["gcp"]:
+		settings = map[string]string{
+			"gcloud_project_name":        account.GcpProjectID,
+			"gcloud_project_credentials": account.GcpCredentials,
+		}
+	case cloudTypeIDs["oci"]:
+		settings = map[string]string{
+			"oci_tenancy_id":      account.OciTenancyID,
+			"oci_user_id":         account.OciUserID,
+			"oci_compartment_id":  account.OciCompartmentID,
+			"oci_api_private_key": account.OciPrivateKey,
+		}
+	}
+	for key, value := range settings {
+		if value != "" {
+			data[key] = value
+		}
+	}
+	return data, nil
+}
+
+// CreateAccount onboards a cloud account
+func (c *Client) CreateAccount(ctx context.Context, account Account) error {
+	data, err := c.accountData("setup_account_profile", account)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "create account", nil)
+}
+
+// UpdateAccount replaces the settings and credentials of an onboarded cloud account
+func (c *Client) UpdateAccount(ctx context.Context, account Account) error {
+	data, err := c.accountData("edit_account_profile", account)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update account", nil)
+}
+
+// DeleteAccount offboards a cloud account. The Controller refuses to offboard accounts that
+// gateways or VPCs still use.
+func (c *Client) DeleteAccount(ctx context.Context, accountName string) error {
+	data := map[string]string{
+		"action":       "delete_account_profile",
+		"CID":          c.session(),
+		"account_name": accountName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete account", nil)
+}
+
+// ListAccounts lists the cloud accounts onboarded to the Controller
+func (c *Client) ListAccounts(ctx context.Context) ([]Account, error) {
+	data := map[string]string{
+		"action": "list_accounts",
+		"CID":    c.session(),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts []Account
+	if err := decodeResult(resp, "list accounts", &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// GetAccount retrieves an onboarded cloud account. An account the Controller does not know
+// fails with an APIError for which IsNotFound is true.
+func (c *Client) GetAccount(ctx context.Context, accountName string) (*Account, error) {
+	accounts, err := c.ListAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range accounts {
+		if accounts[i].AccountName == accountName {
+			return &accounts[i], nil
+		}
+	}
+	return nil, &APIError{Op: "get account", Code: ErrorCodeNotFound, Reason: fmt.Sprintf("account %s does not exist", accountName)}
+}
+
+// AccountAudit is the outcome of an audit of the credentials and permissions of an account
+type AccountAudit struct {
+	// Passed is set when the Controller can use the account
+	Passed bool
+	// Message is the reason the Controller reported for a failed audit
+	Message string
+}
+
+// AuditAccount has the Controller check that it can use the credentials and permissions of an
+// account. A failed audit is reported in the result; err is only set when the audit could not
+// run, e.g. because the account does not exist.
+func (c *Client) AuditAccount(ctx context.Context, accountName string) (AccountAudit, error) {
+	data := map[string]string{
+		"action":       "audit_account",
+		"CID":          c.session(),
+		"account_name": accountName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return AccountAudit{}, err
+	}
+
+	err = decodeResult(resp, "audit account", nil)
+	switch CodeOf(err) {
+	case "":
+		return AccountAudit{Passed: true}, nil
+	case ErrorCodeNotFound, ErrorCodeUnavailable:
+		return AccountAudit{}, err
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return AccountAudit{Message: apiErr.Reason}, nil
+	}
+	return AccountAudit{}, err
+}
+// TransitPeering is a peering between two transit gateways through the Controller. Excluded
+// CIDRs are not advertised by a gateway to its peer.
+type TransitPeering struct {
+	SourceGwName             string   `json:"gateway1"`
+	DestinationGwName        string   `json:"gateway2"`
+	SourceExcludedCidrs      []string `json:"src_filter_list,omitempty"`
+	DestinationExcludedCidrs []string `json:"dst_filter_list,omitempty"`
+	SourcePrependASPath      []string `json:"prepend_as_path1,omitempty"`
+	DestinationPrependASPath []string `json:"prepend_as_path2,omitempty"`
+}
+
+// TransitPeeringConnection returns the name of the connection the Controller creates on both
+// gateways of a transit peering
+func TransitPeeringConnection(sourceGwName, destinationGwName string) string {
+	return "peering_" + sourceGwName + "--" + destinationGwName
+}
+
+// CreateTransitPeering peers two transit gateways. AS path prepending is set separately with
+// UpdateConnectionPrependASPath.
+func (c *Client) CreateTransitPeering(ctx context.Context, peering TransitPeering) error {
+	data := map[string]string{
+		"action":   "create_inter_transit_gateway_peering",
+		"CID":      c.session(),
+		"gateway1": peering.SourceGwName,
+		"gateway2": peering.DestinationGwName,
+	}
+	if len(peering.SourceExcludedCidrs) > 0 {
+		data["src_filter_list"] = strings.Join(peering.SourceExcludedCidrs, ",")
+	}
+	if len(peering.DestinationExcludedCidrs) > 0 {
+		data["dst_filter_list"] = strings.Join(peering.DestinationExcludedCidrs, ",")
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "create transit peering", nil)
+}
+
+// UpdateTransitPeeringFilters replaces the excluded CIDRs of both gateways of a transit
+// peering. Empty lists advertise everything.
+func (c *Client) UpdateTransitPeeringFilters(ctx context.Context, peering TransitPeering) error {
+	data := map[string]string{
+		"action":          "edit_inter_transit_gateway_peering",
+		"CID":             c.session(),
+		"gateway1":        peering.SourceGwName,
+		"gateway2":        peering.DestinationGwName,
+		"src_filter_list": strings.Join(peering.SourceExcludedCidrs, ","),
+		"dst_filter_list": strings.Join(peering.DestinationExcludedCidrs, ","),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update transit peering filters", nil)
+}
+
+// UpdateConnectionPrependASPath sets the AS path a gateway prepends to the routes it advertises
+// over one connection. An empty path removes prepending.
+func (c *Client) UpdateConnectionPrependASPath(ctx context.Context, gwName, connectionName string, asPath []string) error {
+	data := map[string]string{
+		"action":                     "edit_transit_connection_as_path_prepend",
+		"CID":                        c.session(),
+		"gateway_name":               gwName,
+		"connection_name":            connectionName,
+		"connection_as_path_prepend": strings.Join(asPath, " "),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "update connection AS path prepending", nil)
+}
+
+// DeleteTransitPeering removes the peering between two transit gateways
+func (c *Client) DeleteTransitPeering(ctx context.Context, sourceGwName, destinationGwName string) error {
+	data := map[string]string{
+		"action":   "delete_inter_transit_gateway_peering",
+		"CID":      c.session(),
+		"gateway1": sourceGwName,
+		"gateway2": destinationGwName,
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return err
+	}
+
+	return decodeResult(resp, "delete transit peering", nil)
+}
+
+// ListTransitPeerings lists the peerings between transit gateways known to the Controller
+func (c *Client) ListTransitPeerings(ctx context.Context) ([]TransitPeering, error) {
+	data := map[string]string{
+		"action": "list_inter_transit_gateway_peering",
+		"CID":    c.session(),
+	}
+
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var peerings []TransitPeering
+	if err := decodeResult(resp, "list transit peerings", &peerings); err != nil {
+		return nil, err
+	}
+	return peerings, nil
+}
+
+// GetTransitPeering retrieves the peering between two transit gateways, whichever requested it,
+// as seen from sourceGwName. A peering the Controller does not know fails with an APIError for
+// which IsNotFound is true.
+func (c *Client) GetTransitPeering(ctx context.Context, sourceGwName, destinationGwName string) (*TransitPeering, error) {
+	peerings, err := c.ListTransitPeerings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range peerings {
+		p := peerings[i]
+		if p.SourceGwName == sourceGwName && p.DestinationGwName == destinationGwName {
+			return &p, nil
+		}
+		if p.SourceGwName == destinationGwName && p.DestinationGwName == sourceGwName {
+			return &TransitPeering{
+				SourceGwName:             p.DestinationGwName,
+				DestinationGwName:        p.SourceGwName,
+				SourceExcludedCidrs:      p.DestinationExcludedCidrs,
+				DestinationExcludedCidrs: p.SourceExcludedCidrs,
+				SourcePrependASPath:      p.DestinationPrependASPath,
+				DestinationPrependASPath: p.SourcePrependASPath,
+			}, nil
+		}
+	}
+	return nil, &APIError{Op: "get transit peering", Code: ErrorCodeNotFound, Reason: fmt.Sprintf("transit peering between %s and %s does not exist", sourceGwName, destinationGwName)}
+}
+
+// diagnosticOutput runs a diagnostic action and returns the text it reports in results
+func (c *Client) diagnosticOutput(ctx context.Context, data map[string]string, description string) (string, error) {
+	resp, err := c.makeRequest(ctx, "POST", "/v1/api", data)
+	if err != nil {
+		return "", err
+	}
+
+	var output string
+	if err := decodeResult(resp, description, &output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// formatTagList encodes tags in the "key1:value1,key2:value2" form the tagging API expects,
+// sorted so requests are deterministic
+func formatTagList(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + ":" + tags[key]
+	}
+	return strings.Join(pairs, ",")
 }
@@ -0,0 +1,274 @@
+package aviatrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testClient returns a client of a TLS test server, logged in with the pool configuration
+func testClient(t *testing.T, handler http.HandlerFunc, pool PoolConfig) *Client {
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	client := &Client{
+		ControllerIP: strings.TrimPrefix(server.URL, "https://"),
+		HTTPClient:   newHTTPClient(pool),
+	}
+	// Trust the certificate of the test server on the pooled transport
+	client.HTTPClient.Transport.(*http.Transport).TLSClientConfig = server.Client().Transport.(*http.Transport).TLSClientConfig
+	return client
+}
+
+func TestRequestStopsWithContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}, PoolConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := client.Login(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Login() error = %v, want the deadline of the context", err)
+	}
+	if elapsed := time.Since(start); elapsed > DefaultRequestTimeout/2 {
+		t.Errorf("Login() returned after %s, want it to stop with the context", elapsed)
+	}
+}
+
+func TestRequestsRunInParallel(t *testing.T) {
+	const parallel = 4
+	var mu sync.Mutex
+	running, peak := 0, 0
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		if running > peak {
+			peak = running
+		}
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		w.Write([]byte(`{"return":true,"CID":"session"}`))
+	}, PoolConfig{MaxConnsPerHost: parallel})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := client.Login(context.Background()); err != nil {
+				t.Errorf("Login() error = %v", err)
+			}
+			client.session()
+		}()
+	}
+	wg.Wait()
+	if peak < 2 || peak > parallel {
+		t.Errorf("%d requests ran at once, want between 2 and %d", peak, parallel)
+	}
+}
+
+func TestNewHTTPClientDefaults(t *testing.T) {
+	transport := newHTTPClient(PoolConfig{}).Transport.(*http.Transport)
+	if transport.MaxConnsPerHost != DefaultMaxConnsPerHost || transport.MaxIdleConnsPerHost != DefaultMaxIdleConnsPerHost {
+		t.Errorf("pool = %d/%d, want the defaults", transport.MaxConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("newHTTPClient() shares the default transport")
+	}
+}
+
+func TestExpiredSessionIsRenewedOnce(t *testing.T) {
+	var mu sync.Mutex
+	logins := 0
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		json.NewDecoder(r.Body).Decode(&data)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case data["action"] == "login":
+			logins++
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte(`{"return":true,"CID":"renewed"}`))
+		case data["CID"] != "renewed":
+			w.Write([]byte(`{"return":false,"reason":"CID is invalid or expired."}`))
+		default:
+			w.Write([]byte(`{"return":true,"results":{"gw_name":"` + data["gw_name"] + `"}}`))
+		}
+	}, PoolConfig{})
+	client.sessions.id = "expired"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.GetGateway(context.Background(), "spoke"); err != nil {
+				t.Errorf("GetGateway() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if logins != 1 {
+		t.Errorf("%d logins, want the expired session renewed once", logins)
+	}
+}
+
+func TestSessionRenewalRetriesLogin(t *testing.T) {
+	var logins int32
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		json.NewDecoder(r.Body).Decode(&data)
+		if data["action"] == "login" {
+			atomic.AddInt32(&logins, 1)
+			w.Write([]byte(`{"return":false,"reason":"Controller is busy"}`))
+			return
+		}
+		w.Write([]byte(`{"return":false,"reason":"CID is invalid or expired."}`))
+	}, PoolConfig{})
+	client.sessions.id = "expired"
+	client.sessions.loginBackoff = time.Millisecond
+
+	_, err := client.GetGateway(context.Background(), "spoke")
+	if err == nil || !strings.Contains(err.Error(), "Controller is busy") {
+		t.Errorf("GetGateway() error = %v, want the failed login", err)
+	}
+	if logins != DefaultLoginAttempts {
+		t.Errorf("%d logins, want %d attempts", logins, DefaultLoginAttempts)
+	}
+}
+
+func TestSetTransitFeature(t *testing.T) {
+	var actions []string
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		json.NewDecoder(r.Body).Decode(&data)
+		actions = append(actions, data["action"])
+		w.Write([]byte(`{"return":true}`))
+	}, PoolConfig{})
+
+	if err := client.SetTransitFeature(context.Background(), "transit", TransitFeatureFireNet, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SetTransitFeature(context.Background(), "transit", TransitFeatureSegmentation, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"enable_gateway_for_firenet", "disable_transit_gateway_for_segmentation"}
+	if len(actions) != 2 || actions[0] != want[0] || actions[1] != want[1] {
+		t.Errorf("actions = %v, want %v", actions, want)
+	}
+	if err := client.SetTransitFeature(context.Background(), "transit", "Multicast", true); err == nil {
+		t.Error("SetTransitFeature() accepted an unsupported feature")
+	}
+}
+
+func TestGetAwsPeering(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"return":true,"results":[{"vpc_id1":"vpc-a","vpc_id2":"vpc-b","region1":"us-east-1","region2":"us-west-2"}]}`))
+	}, PoolConfig{})
+
+	// The peering is found whichever VPC requested it
+	peering, err := client.GetAwsPeering(context.Background(), "vpc-b", "vpc-a")
+	if err != nil || peering.RequesterVpcID != "vpc-a" || peering.AccepterRegion != "us-west-2" {
+		t.Errorf("GetAwsPeering() = %+v, %v", peering, err)
+	}
+	if _, err := client.GetAwsPeering(context.Background(), "vpc-a", "vpc-c"); !IsNotFound(err) {
+		t.Errorf("GetAwsPeering() of a missing peering error = %v, want NotFound", err)
+	}
+}
+
+func TestCreateMicrosegPolicy(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"return":true,"results":{"uuid":"3f2c"}}`))
+	}, PoolConfig{})
+
+	uuid, err := client.CreateMicrosegPolicy(context.Background(), MicrosegPolicy{Name: "web-to-db", Action: "allow", Protocol: "tcp"})
+	if err != nil || uuid != "3f2c" {
+		t.Errorf("CreateMicrosegPolicy() = %q, %v, want the assigned UUID", uuid, err)
+	}
+}
+
+func TestCreateAccountSendsCloudSettings(t *testing.T) {
+	var got map[string]string
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"return":true}`))
+	}, PoolConfig{})
+
+	// AWS accounts without access keys assume IAM roles
+	err := client.CreateAccount(context.Background(), Account{
+		AccountName:      "aws-prod",
+		CloudType:        "aws",
+		AwsAccountNumber: "123456789012",
+		AwsRoleApp:       "arn:aws:iam::123456789012:role/aviatrix-role-app",
+		AwsRoleEc2:       "arn:aws:iam::123456789012:role/aviatrix-role-ec2",
+		AzureClientID:    "ignored",
+	})
+	if err != nil {
+		t.Fatalf("CreateAccount() error = %v", err)
+	}
+	if got["cloud_type"] != "1" || got["aws_iam"] != "true" || got["aws_role_arn"] == "" || got["arm_application_client_id"] != "" {
+		t.Errorf("CreateAccount() sent %v", got)
+	}
+
+	if err := client.CreateAccount(context.Background(), Account{AccountName: "x", CloudType: "alibaba"}); err == nil {
+		t.Error("CreateAccount() accepted an unsupported cloud type")
+	}
+}
+
+func TestAuditAccount(t *testing.T) {
+	var response atomic.Value
+	response.Store(`{"return":false,"reason":"Failed to assume role aviatrix-role-app"}`)
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(response.Load().(string)))
+	}, PoolConfig{})
+
+	// A failed audit is a result, not an error
+	audit, err := client.AuditAccount(context.Background(), "aws-prod")
+	if err != nil || audit.Passed || audit.Message != "Failed to assume role aviatrix-role-app" {
+		t.Errorf("AuditAccount() = %+v, %v", audit, err)
+	}
+
+	response.Store(`{"return":false,"reason":"Account aws-prod does not exist"}`)
+	if _, err := client.AuditAccount(context.Background(), "aws-prod"); !IsNotFound(err) {
+		t.Errorf("AuditAccount() of a missing account error = %v, want NotFound", err)
+	}
+
+	response.Store(`{"return":true}`)
+	if audit, err := client.AuditAccount(context.Background(), "aws-prod"); err != nil || !audit.Passed {
+		t.Errorf("AuditAccount() = %+v, %v, want a passed audit", audit, err)
+	}
+}
+
+func TestGetTransitPeeringFromEitherGateway(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"return":true,"results":[{"gateway1":"transit-east","gateway2":"transit-west","src_filter_list":["10.1.0.0/16"],"prepend_as_path2":["65002"]}]}`))
+	}, PoolConfig{})
+
+	peering, err := client.GetTransitPeering(context.Background(), "transit-west", "transit-east")
+	if err != nil {
+		t.Fatalf("GetTransitPeering() error = %v", err)
+	}
+	if peering.SourceGwName != "transit-west" || len(peering.DestinationExcludedCidrs) != 1 || len(peering.SourcePrependASPath) != 1 {
+		t.Errorf("GetTransitPeering() = %+v, want it seen from transit-west", peering)
+	}
+
+	if _, err := client.GetTransitPeering(context.Background(), "transit-east", "transit-central"); !IsNotFound(err) {
+		t.Errorf("GetTransitPeering() of a missing peering error = %v, want NotFound", err)
+	}
+}
@@ -0,0 +1,112 @@
+package aviatrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Keys of the credentials Secret
+const (
+	SecretKeyUsername = "username"
+	SecretKeyPassword = "password"
+)
+
+// DefaultCredentialsSecret is the Secret the operator reads its credentials from unless
+// configured otherwise
+const DefaultCredentialsSecret = "aviatrix-system/aviatrix-controller-secret"
+
+// ErrInvalidCredentials reports a credentials Secret that lacks a username or password
+var ErrInvalidCredentials = errors.New("invalid Aviatrix credentials")
+
+// ParseSecretName parses the namespace/name of a credentials Secret
+func ParseSecretName(value string) (types.NamespacedName, error) {
+	namespace, name, ok := strings.Cut(value, "/")
+	if !ok || namespace == "" || name == "" {
+		return types.NamespacedName{}, fmt.Errorf("invalid credentials Secret %q, want namespace/name", value)
+	}
+	return types.NamespacedName{Namespace: namespace, Name: name}, nil
+}
+
+// CredentialsFromSecret returns the credentials held by a Secret
+func CredentialsFromSecret(secret *corev1.Secret) (Credentials, error) {
+	credentials := Credentials{
+		Username: strings.TrimSpace(string(secret.Data[SecretKeyUsername])),
+		Password: string(secret.Data[SecretKeyPassword]),
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return Credentials{}, fmt.Errorf("%w: Secret %s/%s needs the keys %s and %s",
+			ErrInvalidCredentials, secret.Namespace, secret.Name, SecretKeyUsername, SecretKeyPassword)
+	}
+	return credentials, nil
+}
+
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+// SecretProvider reads the credentials of the Aviatrix Controller from a Secret and, once set
+// up with a manager, logs a client in again whenever the Secret changes. Credentials can so be
+// rotated without a restart and are kept out of the arguments of the operator pod.
+type SecretProvider struct {
+	reader client.Reader
+	secret types.NamespacedName
+	client *Client
+}
+
+// NewSecretProvider creates a provider reading the Secret through reader. Pass a reader that
+// does not depend on the cache to read the credentials before the manager starts.
+func NewSecretProvider(reader client.Reader, secret types.NamespacedName) *SecretProvider {
+	return &SecretProvider{reader: reader, secret: secret}
+}
+
+// Credentials reads the credentials from the Secret
+func (p *SecretProvider) Credentials(ctx context.Context) (Credentials, error) {
+	secret := &corev1.Secret{}
+	if err := p.reader.Get(ctx, p.secret, secret); err != nil {
+		return Credentials{}, fmt.Errorf("failed to read credentials Secret %s: %w", p.secret, err)
+	}
+	return CredentialsFromSecret(secret)
+}
+
+// Reconcile logs the client in with the credentials of the Secret when they changed. A
+// removed or invalid Secret keeps the credentials in effect; a failed login is retried.
+func (p *SecretProvider) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName("aviatrix-credentials")
+
+	credentials, err := p.Credentials(ctx)
+	if err != nil {
+		if apierrors.IsNotFound(err) || errors.Is(err, ErrInvalidCredentials) {
+			log.Error(err, "keeping the Aviatrix credentials in effect")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+	if credentials == p.client.Credentials() {
+		return ctrl.Result{}, nil
+	}
+	if err := p.client.SetCredentials(ctx, credentials); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to log in with the credentials of Secret %s: %w", p.secret, err)
+	}
+	log.Info("reloaded Aviatrix credentials", "secret", p.secret)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager watches the Secret and applies its changes to c
+func (p *SecretProvider) SetupWithManager(mgr ctrl.Manager, c *Client) error {
+	p.client = c
+	isSecret := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == p.secret.Namespace && obj.GetName() == p.secret.Name
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("aviatrixcredentials").
+		For(&corev1.Secret{}, builder.WithPredicates(isSecret)).
+		Complete(p)
+}
@@ -0,0 +1,101 @@
+package aviatrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func credentialsSecret(username, password string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "aviatrix-system", Name: "aviatrix-controller-secret"},
+		Data: map[string][]byte{
+			SecretKeyUsername: []byte(username),
+			SecretKeyPassword: []byte(password),
+		},
+	}
+}
+
+// loginServer accepts logins with the password "current" and returns the username as session
+func loginServer(w http.ResponseWriter, r *http.Request) {
+	var data map[string]string
+	json.NewDecoder(r.Body).Decode(&data)
+	if data["password"] != "current" {
+		w.Write([]byte(`{"return":false,"reason":"invalid credentials"}`))
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"return": true, "CID": data["username"]})
+}
+
+func TestSecretProviderReloadsCredentials(t *testing.T) {
+	aviatrixClient := testClient(t, loginServer, PoolConfig{})
+	aviatrixClient.sessions.credentials = Credentials{Username: "admin", Password: "current"}
+	if err := aviatrixClient.Login(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := credentialsSecret("operator", "current")
+	key := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+	provider := NewSecretProvider(c, key)
+	provider.client = aviatrixClient
+
+	if _, err := provider.Reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if got := aviatrixClient.Credentials(); got.Username != "operator" || aviatrixClient.session() != "operator" {
+		t.Errorf("credentials = %+v, session = %q, want the Secret logged in", got, aviatrixClient.session())
+	}
+
+	// A rejected password keeps the session in effect and is retried
+	secret.Data[SecretKeyPassword] = []byte("rotated-too-early")
+	if err := c.Update(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err == nil {
+		t.Error("Reconcile() accepted credentials the Controller rejected")
+	}
+	if got := aviatrixClient.Credentials(); got.Password != "current" || aviatrixClient.session() != "operator" {
+		t.Errorf("credentials = %+v, session = %q, want the previous ones kept", got, aviatrixClient.session())
+	}
+
+	// A removed Secret keeps the credentials in effect
+	if err := c.Delete(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Reconcile(context.Background(), reconcile.Request{NamespacedName: key}); err != nil {
+		t.Errorf("Reconcile() error = %v, want the removed Secret ignored", err)
+	}
+	if got := aviatrixClient.Credentials(); got.Username != "operator" {
+		t.Errorf("credentials = %+v, want the previous ones kept", got)
+	}
+}
+
+func TestCredentialsFromSecret(t *testing.T) {
+	if _, err := CredentialsFromSecret(credentialsSecret("admin", "")); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("CredentialsFromSecret() error = %v, want ErrInvalidCredentials", err)
+	}
+	credentials, err := CredentialsFromSecret(credentialsSecret("admin\n", "p@ss "))
+	if err != nil || credentials != (Credentials{Username: "admin", Password: "p@ss "}) {
+		t.Errorf("CredentialsFromSecret() = %+v, %v, want the trimmed username and the exact password", credentials, err)
+	}
+}
+
+func TestParseSecretName(t *testing.T) {
+	if name, err := ParseSecretName(DefaultCredentialsSecret); err != nil || name.Namespace != "aviatrix-system" {
+		t.Errorf("ParseSecretName(%q) = %v, %v", DefaultCredentialsSecret, name, err)
+	}
+	for _, value := range []string{"", "secret", "/secret", "ns/"} {
+		if _, err := ParseSecretName(value); err == nil {
+			t.Errorf("ParseSecretName(%q) succeeded, want an error", value)
+		}
+	}
+}
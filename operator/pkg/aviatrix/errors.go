@@ -0,0 +1,165 @@
+package aviatrix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrorCode classifies why the Aviatrix Controller rejected or failed a request
+type ErrorCode string
+
+const (
+	// ErrorCodeNotFound means the resource does not exist on the Controller
+	ErrorCodeNotFound ErrorCode = "NotFound"
+	// ErrorCodeAlreadyExists means a resource of the same name exists on the Controller
+	ErrorCodeAlreadyExists ErrorCode = "AlreadyExists"
+	// ErrorCodeUnauthorized means the session or the credentials were rejected
+	ErrorCodeUnauthorized ErrorCode = "Unauthorized"
+	// ErrorCodeInvalid means the Controller rejected the parameters of the request
+	ErrorCodeInvalid ErrorCode = "Invalid"
+	// ErrorCodeUnavailable means the Controller could not be reached or is overloaded; the
+	// request may succeed when retried
+	ErrorCodeUnavailable ErrorCode = "Unavailable"
+	// ErrorCodeUnknown is any other failure reported by the Controller
+	ErrorCodeUnknown ErrorCode = "Unknown"
+)
+
+// APIError is a failed request to the Aviatrix Controller
+type APIError struct {
+	// Op is what the request did, e.g. "get gateway"
+	Op string
+	// Code classifies the failure
+	Code ErrorCode
+	// Reason is the reason reported by the Controller, or the transport error
+	Reason string
+	// StatusCode is the HTTP status of the response, 0 when none was received
+	StatusCode int
+	// RetryAfter is how long the Controller asked to wait before retrying, or how long the
+	// circuit breaker holds requests back
+	RetryAfter time.Duration
+
+	err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("failed to %s: %s", e.Op, e.Reason)
+}
+
+// Unwrap returns the transport error, e.g. context.Canceled
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// CodeOf returns the code of an APIError in the chain of err, or "" for other errors
+func CodeOf(err error) ErrorCode {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// IsNotFound reports whether err means the resource does not exist on the Controller
+func IsNotFound(err error) bool {
+	return CodeOf(err) == ErrorCodeNotFound
+}
+
+// IsAlreadyExists reports whether err means a resource of the same name exists on the Controller
+func IsAlreadyExists(err error) bool {
+	return CodeOf(err) == ErrorCodeAlreadyExists
+}
+
+// RetryAfter returns how long to wait before retrying a request that failed with err, 0 when
+// the Controller did not say
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
+
+// IsCircuitOpen reports whether err was returned without a request being sent, because the
+// Controller was found unreachable
+func IsCircuitOpen(err error) bool {
+	return errors.Is(err, ErrCircuitOpen)
+}
+
+// IsTransient reports whether a request that failed with err may succeed when retried unchanged
+func IsTransient(err error) bool {
+	return CodeOf(err) == ErrorCodeUnavailable
+}
+
+// reasonCodes maps phrases of the reasons the Controller reports to error codes, in order
+var reasonCodes = []struct {
+	phrase string
+	code   ErrorCode
+}{
+	{"does not exist", ErrorCodeNotFound},
+	{"not found", ErrorCodeNotFound},
+	{"no such", ErrorCodeNotFound},
+	{"already exist", ErrorCodeAlreadyExists},
+	{"invalid cid", ErrorCodeUnauthorized},
+	{"cid is invalid", ErrorCodeUnauthorized},
+	{"expired", ErrorCodeUnauthorized},
+	{"permission", ErrorCodeUnauthorized},
+	{"invalid", ErrorCodeInvalid},
+	{"missing", ErrorCodeInvalid},
+	{"try again", ErrorCodeUnavailable},
+	{"timed out", ErrorCodeUnavailable},
+	{"busy", ErrorCodeUnavailable},
+}
+
+// classifyReason returns the code of a reason reported by the Controller. The API reports
+// failures as free text, so the code is derived from well-known phrases.
+func classifyReason(reason string) ErrorCode {
+	reason = strings.ToLower(reason)
+	for _, rc := range reasonCodes {
+		if strings.Contains(reason, rc.phrase) {
+			return rc.code
+		}
+	}
+	return ErrorCodeUnknown
+}
+
+// classifyStatus returns the code of an HTTP error status
+func classifyStatus(status int) ErrorCode {
+	switch {
+	case status == http.StatusNotFound:
+		return ErrorCodeNotFound
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrorCodeUnauthorized
+	case status == http.StatusTooManyRequests || status >= http.StatusInternalServerError:
+		return ErrorCodeUnavailable
+	default:
+		return ErrorCodeInvalid
+	}
+}
+
+// decodeResult checks the result of an API call and decodes its results into out, unless out
+// is nil. A result the Controller did not return successfully is an APIError.
+func decodeResult(resp []byte, op string, out interface{}) error {
+	var result struct {
+		Return  interface{}     `json:"return"`
+		Reason  interface{}     `json:"reason"`
+		Results json.RawMessage `json:"results"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return &APIError{Op: op, Code: ErrorCodeUnknown, Reason: "malformed response: " + err.Error(), err: err}
+	}
+	if result.Return != true {
+		reason := fmt.Sprintf("%v", result.Reason)
+		return &APIError{Op: op, Code: classifyReason(reason), Reason: reason}
+	}
+	if out == nil || len(result.Results) == 0 || string(result.Results) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(result.Results, out); err != nil {
+		return &APIError{Op: op, Code: ErrorCodeUnknown, Reason: "malformed results: " + err.Error(), err: err}
+	}
+	return nil
+}
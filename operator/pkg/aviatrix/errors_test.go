@@ -0,0 +1,62 @@
+package aviatrix
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestGetGatewayDistinguishesNotFound(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"return":false,"reason":"Gateway spoke-west does not exist."}`))
+	}, PoolConfig{})
+
+	_, err := client.GetGateway(context.Background(), "spoke-west")
+	if !IsNotFound(err) || IsTransient(err) {
+		t.Errorf("GetGateway() error = %v, want a NotFound APIError", err)
+	}
+	if got := err.Error(); got != "failed to get gateway: Gateway spoke-west does not exist." {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestUnavailableControllerIsTransient(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, PoolConfig{})
+
+	_, err := client.GetVpc(context.Background(), "prod")
+	if !IsTransient(err) || IsNotFound(err) {
+		t.Errorf("GetVpc() error = %v, want a transient APIError", err)
+	}
+}
+
+func TestGetGatewayDecodesTypedInfo(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"return":true,"results":{"gw_name":"spoke-west","cloud_type":1,"vpc_reg":"us-east-1","public_ip":"203.0.113.7"}}`))
+	}, PoolConfig{})
+
+	info, err := client.GetGateway(context.Background(), "spoke-west")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GatewayInfo{GwName: "spoke-west", CloudType: "1", VpcRegion: "us-east-1", PublicIP: "203.0.113.7"}
+	if *info != want {
+		t.Errorf("GetGateway() = %+v, want %+v", *info, want)
+	}
+}
+
+func TestClassifyReason(t *testing.T) {
+	for reason, want := range map[string]ErrorCode{
+		"VPC prod not found":                   ErrorCodeNotFound,
+		"Gateway spoke-west already exists":    ErrorCodeAlreadyExists,
+		"CID is invalid or expired.":           ErrorCodeUnauthorized,
+		"Invalid gw_size t3.huge":              ErrorCodeInvalid,
+		"Controller is busy, please try again": ErrorCodeUnavailable,
+		"Unexpected failure":                   ErrorCodeUnknown,
+	} {
+		if got := classifyReason(reason); got != want {
+			t.Errorf("classifyReason(%q) = %s, want %s", reason, got, want)
+		}
+	}
+}
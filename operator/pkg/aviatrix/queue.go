@@ -0,0 +1,131 @@
+package aviatrix
+
+import (
+	"context"
+	"sync"
+)
+
+// Defaults of the request queue
+const (
+	// DefaultMaxInFlight bounds the requests sent to the Controller at once
+	DefaultMaxInFlight = DefaultMaxConnsPerHost
+	// DefaultMaxInFlightPerPool bounds the requests of one pool sent at once, leaving room for
+	// the other pools
+	DefaultMaxInFlightPerPool = DefaultMaxInFlight / 2
+)
+
+type requestPoolKey struct{}
+
+// WithRequestPool returns a context whose requests count towards pool, e.g. the kind of the
+// resource being reconciled. Requests without a pool share the pool "".
+func WithRequestPool(ctx context.Context, pool string) context.Context {
+	return context.WithValue(ctx, requestPoolKey{}, pool)
+}
+
+// requestPoolOf returns the pool of the requests of ctx
+func requestPoolOf(ctx context.Context) string {
+	pool, _ := ctx.Value(requestPoolKey{}).(string)
+	return pool
+}
+
+// requestQueue bounds the requests in flight, in total and per pool. Requests wait in the order
+// they arrived, except that a request whose pool is full lets later requests of other pools
+// pass. A nil queue lets every request through.
+type requestQueue struct {
+	maxInFlight int
+	maxPerPool  int
+
+	mu       sync.Mutex
+	inFlight int
+	pools    map[string]int
+	waiting  []*queuedRequest
+}
+
+type queuedRequest struct {
+	pool  string
+	ready chan struct{}
+}
+
+func newRequestQueue(maxInFlight, maxPerPool int) *requestQueue {
+	return &requestQueue{maxInFlight: maxInFlight, maxPerPool: maxPerPool, pools: map[string]int{}}
+}
+
+// acquire waits until a request of pool may be sent. The returned func must be called once the
+// response was read.
+func (q *requestQueue) acquire(ctx context.Context, pool string) (func(), error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	request := &queuedRequest{pool: pool, ready: make(chan struct{})}
+	q.mu.Lock()
+	q.waiting = append(q.waiting, request)
+	q.dispatch()
+	q.mu.Unlock()
+
+	select {
+	case <-request.ready:
+		return func() { q.release(pool) }, nil
+	case <-ctx.Done():
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		select {
+		case <-request.ready:
+			// Admitted while giving up; pass the slot on
+			q.releaseLocked(pool)
+		default:
+			q.remove(request)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// stats returns the number of requests in flight and waiting
+func (q *requestQueue) stats() (int, int) {
+	if q == nil {
+		return 0, 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.inFlight, len(q.waiting)
+}
+
+func (q *requestQueue) release(pool string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.releaseLocked(pool)
+}
+
+func (q *requestQueue) releaseLocked(pool string) {
+	q.inFlight--
+	if q.pools[pool]--; q.pools[pool] <= 0 {
+		delete(q.pools, pool)
+	}
+	q.dispatch()
+}
+
+// dispatch admits the waiting requests that fit, oldest first
+func (q *requestQueue) dispatch() {
+	waiting := q.waiting[:0]
+	for _, request := range q.waiting {
+		if q.inFlight < q.maxInFlight && (q.maxPerPool <= 0 || q.pools[request.pool] < q.maxPerPool) {
+			q.inFlight++
+			q.pools[request.pool]++
+			close(request.ready)
+			continue
+		}
+		waiting = append(waiting, request)
+	}
+	for i := len(waiting); i < len(q.waiting); i++ {
+		q.waiting[i] = nil
+	}
+	q.waiting = waiting
+}
+
+func (q *requestQueue) remove(request *queuedRequest) {
+	for i, r := range q.waiting {
+		if r == request {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package aviatrix
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// acquired reports whether acquire admits a request of pool right away
+func acquired(t *testing.T, q *requestQueue, pool string) (func(), bool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	release, err := q.acquire(ctx, pool)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire(%s) error = %v", pool, err)
+	}
+	return release, err == nil
+}
+
+func TestRequestQueueLimitsPools(t *testing.T) {
+	q := newRequestQueue(3, 2)
+
+	releaseGateway, _ := acquired(t, q, "AviatrixGateway")
+	if _, ok := acquired(t, q, "AviatrixGateway"); !ok {
+		t.Fatal("second gateway request held back, want two per pool")
+	}
+	// A full pool leaves room for the other pools
+	if _, ok := acquired(t, q, "AviatrixGateway"); ok {
+		t.Fatal("third gateway request admitted, want at most two per pool")
+	}
+	if _, ok := acquired(t, q, "AviatrixVpc"); !ok {
+		t.Fatal("VPC request held back by the gateway pool")
+	}
+	if _, ok := acquired(t, q, "AviatrixFirewall"); ok {
+		t.Fatal("fourth request admitted, want at most three in flight")
+	}
+	if inFlight, waiting := q.stats(); inFlight != 3 || waiting != 0 {
+		t.Errorf("stats() = %d, %d, want 3 in flight and the abandoned requests gone", inFlight, waiting)
+	}
+
+	// A released slot goes to the oldest waiting request that fits
+	admitted := make(chan string, 2)
+	for _, pool := range []string{"AviatrixGateway", "AviatrixFirewall"} {
+		pool := pool
+		go func() {
+			if _, err := q.acquire(context.Background(), pool); err == nil {
+				admitted <- pool
+			}
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}
+	releaseGateway()
+	select {
+	case pool := <-admitted:
+		if pool != "AviatrixGateway" {
+			t.Errorf("admitted %s, want the oldest waiting request", pool)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no waiting request admitted after a release")
+	}
+	if _, waiting := q.stats(); waiting != 1 {
+		t.Errorf("%d requests waiting, want the firewall request still queued", waiting)
+	}
+}
+
+func TestRequestPoolOf(t *testing.T) {
+	if pool := requestPoolOf(context.Background()); pool != "" {
+		t.Errorf("requestPoolOf() = %q, want the shared pool", pool)
+	}
+	if pool := requestPoolOf(WithRequestPool(context.Background(), "AviatrixVpc")); pool != "AviatrixVpc" {
+		t.Errorf("requestPoolOf() = %q, want AviatrixVpc", pool)
+	}
+}
@@ -0,0 +1,307 @@
+package aviatrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Defaults of the rate limit, retries and circuit breaker of requests to the Aviatrix Controller
+const (
+	// DefaultRequestsPerSecond limits the requests the operator sends to the Controller
+	DefaultRequestsPerSecond = 10
+	// DefaultRequestBurst is how many requests may be sent at once above the rate
+	DefaultRequestBurst = 20
+	// DefaultMaxRetries is how often a request that failed transiently is repeated
+	DefaultMaxRetries = 4
+	// DefaultRetryBackoff is the wait before the first retry; it doubles per retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+	// DefaultMaxRetryBackoff bounds the wait between retries
+	DefaultMaxRetryBackoff = 15 * time.Second
+	// DefaultBreakerThreshold is how many requests in a row must fail transiently to open the
+	// circuit breaker
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerCooldown is how long the circuit breaker holds requests back before a
+	// single request probes the Controller again
+	DefaultBreakerCooldown = 30 * time.Second
+)
+
+// States of the circuit breaker on the Aviatrix Controller
+const (
+	CircuitClosed   = "Closed"
+	CircuitOpen     = "Open"
+	CircuitHalfOpen = "HalfOpen"
+)
+
+// ErrCircuitOpen is the cause of the requests the circuit breaker fails without sending them
+var ErrCircuitOpen = errors.New("the Aviatrix Controller is unreachable; requests are paused")
+
+// retryPolicy repeats requests that failed transiently with exponential backoff. The zero
+// value sends every request once.
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// wait returns the jittered wait before retry number attempt, starting at 1. A Retry-After
+// the Controller asked for is honoured when it is longer.
+func (p retryPolicy) wait(attempt int, err error) time.Duration {
+	backoff := p.backoff
+	for i := 1; i < attempt && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.maxBackoff > 0 && backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+	// Equal jitter: keep half of the backoff and randomize the rest, so reconciles that failed
+	// together do not retry together
+	wait := backoff / 2
+	if half := int64(backoff - wait); half > 0 {
+		wait += time.Duration(rand.Int63n(half + 1))
+	}
+	if after := RetryAfter(err); after > wait {
+		wait = after
+	}
+	return wait
+}
+
+// breaker holds requests back while the Controller keeps failing, so a Controller that is down
+// or overloaded is not hammered by every reconcile. A nil breaker lets every request through.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trialAt  time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, state: CircuitClosed}
+}
+
+// allow decides whether a request may be sent now. While the circuit is open it returns how
+// long until requests resume.
+func (b *breaker) allow(now time.Time) (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.circuitState(now) {
+	case CircuitOpen:
+		return b.openedAt.Add(b.cooldown).Sub(now), false
+	case CircuitHalfOpen:
+		// Only one request probes the Controller until it reports back; a probe abandoned by its
+		// caller is replaced after the cooldown
+		if !b.trialAt.IsZero() && now.Before(b.trialAt.Add(b.cooldown)) {
+			return b.trialAt.Add(b.cooldown).Sub(now), false
+		}
+		b.trialAt = now
+	}
+	return 0, true
+}
+
+// record reports the outcome of a request that was sent. Only transient failures count; a
+// Controller that rejects a request is reachable.
+func (b *breaker) record(transient bool, now time.Time) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !transient {
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.circuitState(now) == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = now
+	}
+}
+
+// State returns the state of the circuit breaker
+func (b *breaker) State(now time.Time) string {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.circuitState(now)
+}
+
+// circuitState moves an open circuit to half-open once the cooldown passed
+func (b *breaker) circuitState(now time.Time) string {
+	if b.state == CircuitOpen && !now.Before(b.openedAt.Add(b.cooldown)) {
+		b.state = CircuitHalfOpen
+		b.trialAt = time.Time{}
+	}
+	return b.state
+}
+
+// applyLimits sets up the request queue, rate limit, retries and circuit breaker of pool. Zero
+// fields use the defaults; a negative rate or number of retries disables them.
+func (c *Client) applyLimits(pool PoolConfig) {
+	if pool.RequestsPerSecond == 0 {
+		pool.RequestsPerSecond = DefaultRequestsPerSecond
+	}
+	if pool.RequestBurst <= 0 {
+		pool.RequestBurst = DefaultRequestBurst
+	}
+	if pool.MaxRetries == 0 {
+		pool.MaxRetries = DefaultMaxRetries
+	}
+	if pool.RetryBackoff <= 0 {
+		pool.RetryBackoff = DefaultRetryBackoff
+	}
+	if pool.MaxRetryBackoff <= 0 {
+		pool.MaxRetryBackoff = DefaultMaxRetryBackoff
+	}
+	if pool.BreakerThreshold <= 0 {
+		pool.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if pool.BreakerCooldown <= 0 {
+		pool.BreakerCooldown = DefaultBreakerCooldown
+	}
+	if pool.MaxInFlight <= 0 {
+		pool.MaxInFlight = DefaultMaxInFlight
+	}
+	if pool.MaxInFlightPerPool <= 0 {
+		pool.MaxInFlightPerPool = DefaultMaxInFlightPerPool
+	}
+
+	c.limiter = nil
+	if pool.RequestsPerSecond > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(pool.RequestsPerSecond), pool.RequestBurst)
+	}
+	c.retry = retryPolicy{backoff: pool.RetryBackoff, maxBackoff: pool.MaxRetryBackoff}
+	if pool.MaxRetries > 0 {
+		c.retry.maxRetries = pool.MaxRetries
+	}
+	c.breaker = newBreaker(pool.BreakerThreshold, pool.BreakerCooldown)
+	c.queue = newRequestQueue(pool.MaxInFlight, pool.MaxInFlightPerPool)
+}
+
+// CircuitState returns the state of the circuit breaker on the Aviatrix Controller
+func (c *Client) CircuitState() string {
+	return c.breaker.State(time.Now())
+}
+
+// send sends a request once the queue admits it and within the rate limit, and repeats it
+// while it fails transiently, see retryable. A request waiting to be retried gives up its place
+// in the queue. While the circuit breaker is open the request fails without being sent.
+func (c *Client) send(ctx context.Context, method, endpoint string, data interface{}) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		if retryAfter, ok := c.breaker.allow(time.Now()); !ok {
+			return nil, &APIError{
+				Op:         "reach the Aviatrix Controller",
+				Code:       ErrorCodeUnavailable,
+				Reason:     fmt.Sprintf("%s; retrying in %s", ErrCircuitOpen, retryAfter.Round(time.Second)),
+				RetryAfter: retryAfter,
+				err:        ErrCircuitOpen,
+			}
+		}
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx); err != nil {
+				return nil, &APIError{Op: "reach the Aviatrix Controller", Code: ErrorCodeUnknown, Reason: err.Error(), err: err}
+			}
+		}
+
+		release, err := c.queue.acquire(ctx, requestPoolOf(ctx))
+		if err != nil {
+			return nil, &APIError{Op: "reach the Aviatrix Controller", Code: ErrorCodeUnknown, Reason: err.Error(), err: err}
+		}
+		resp, err := c.do(ctx, method, endpoint, data)
+		release()
+		if ctx.Err() == nil {
+			// A request abandoned by its caller tells nothing about the Controller
+			c.breaker.record(IsTransient(err), time.Now())
+		}
+		if err == nil || !retryable(err, data) || attempt > c.retry.maxRetries {
+			return resp, err
+		}
+
+		wait := c.retry.wait(attempt, err)
+		ctrl.LoggerFrom(ctx).V(1).Info("Aviatrix request failed transiently, retrying", "endpoint", endpoint, "attempt", attempt, "wait", wait, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryable reports whether a request that failed with err may be sent again. Reads, whose
+// action starts with get_ or list_, change nothing and are repeated after every transient
+// failure, including any HTTP 5xx and timeouts. Other actions are repeated only when the
+// Controller cannot have processed them, see unprocessed.
+func retryable(err error, data interface{}) bool {
+	if read(data) {
+		return IsTransient(err)
+	}
+	return unprocessed(err)
+}
+
+// read reports whether the data of a request names an action that only reads
+func read(data interface{}) bool {
+	var action string
+	switch data := data.(type) {
+	case map[string]string:
+		action = data["action"]
+	case map[string]interface{}:
+		action, _ = data["action"].(string)
+	}
+	return strings.HasPrefix(action, "get_") || strings.HasPrefix(action, "list_")
+}
+
+// unprocessed reports whether err shows that the Controller did not process the request, so
+// repeating it cannot apply an action twice: the connection could not be opened, or the
+// Controller turned the request away with HTTP 429 or 503. A request that timed out or failed
+// otherwise after it was sent may have been processed and is not repeated.
+func unprocessed(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != ErrorCodeUnavailable {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case 0:
+		var opErr *net.OpError
+		return errors.As(err, &opErr) && opErr.Op == "dial"
+	}
+	return false
+}
+
+// retryAfterHeader returns the wait a response asks for in its Retry-After header, in seconds
+// or as an HTTP date
+func retryAfterHeader(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
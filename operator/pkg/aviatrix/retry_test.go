@@ -0,0 +1,231 @@
+package aviatrix
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTransientFailuresAreRetried(t *testing.T) {
+	var requests int32
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{"return":true,"results":{"gw_name":"spoke"}}`))
+		}
+	}, PoolConfig{})
+	client.applyLimits(PoolConfig{RequestsPerSecond: -1, RetryBackoff: time.Millisecond})
+
+	if _, err := client.GetGateway(context.Background(), "spoke"); err != nil {
+		t.Fatalf("GetGateway() error = %v, want success after retries", err)
+	}
+	if requests != 3 {
+		t.Errorf("%d requests, want two retries", requests)
+	}
+	if state := client.CircuitState(); state != CircuitClosed {
+		t.Errorf("CircuitState() = %s, want closed after a success", state)
+	}
+}
+
+func TestRejectedRequestsAreNotRetried(t *testing.T) {
+	var requests int32
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}, PoolConfig{})
+	client.applyLimits(PoolConfig{RequestsPerSecond: -1, RetryBackoff: time.Millisecond})
+
+	if _, err := client.GetGateway(context.Background(), "spoke"); CodeOf(err) != ErrorCodeInvalid {
+		t.Fatalf("GetGateway() error = %v, want it invalid", err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, want a rejected request sent once", requests)
+	}
+}
+
+func TestServerErrorsAreRetriedForReads(t *testing.T) {
+	tests := []struct {
+		status       int
+		read         bool
+		wantRequests int32
+	}{
+		{status: http.StatusInternalServerError, read: true, wantRequests: 2},
+		{status: http.StatusBadGateway, read: true, wantRequests: 2},
+		{status: http.StatusGatewayTimeout, read: true, wantRequests: 2},
+		{status: http.StatusServiceUnavailable, read: true, wantRequests: 2},
+		// A delete that failed with a server error may have been processed
+		{status: http.StatusInternalServerError, wantRequests: 1},
+		{status: http.StatusBadGateway, wantRequests: 1},
+		{status: http.StatusGatewayTimeout, wantRequests: 1},
+		{status: http.StatusServiceUnavailable, wantRequests: 2},
+		{status: http.StatusTooManyRequests, wantRequests: 2},
+	}
+	for _, tt := range tests {
+		var requests int32
+		client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&requests, 1) == 1 {
+				w.WriteHeader(tt.status)
+				return
+			}
+			w.Write([]byte(`{"return":true,"results":{"gw_name":"spoke"}}`))
+		}, PoolConfig{})
+		client.applyLimits(PoolConfig{RequestsPerSecond: -1, RetryBackoff: time.Millisecond})
+
+		var err error
+		if tt.read {
+			_, err = client.GetGateway(context.Background(), "spoke")
+		} else {
+			err = client.DeleteGateway(context.Background(), "spoke")
+		}
+		if tt.wantRequests == 2 && err != nil {
+			t.Errorf("HTTP %d, read %t: error = %v, want success after a retry", tt.status, tt.read, err)
+		}
+		if tt.wantRequests == 1 && !IsTransient(err) {
+			t.Errorf("HTTP %d, read %t: error = %v, want the server error", tt.status, tt.read, err)
+		}
+		if requests != tt.wantRequests {
+			t.Errorf("HTTP %d, read %t: %d requests, want %d", tt.status, tt.read, requests, tt.wantRequests)
+		}
+	}
+}
+
+func TestTimedOutCreateIsNotResent(t *testing.T) {
+	var requests int32
+	release := make(chan struct{})
+	defer close(release)
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-release
+	}, PoolConfig{})
+	client.applyLimits(PoolConfig{RequestsPerSecond: -1, RetryBackoff: time.Millisecond})
+	client.HTTPClient.Timeout = 20 * time.Millisecond
+
+	err := client.CreateGateway(context.Background(), "spoke", "1", "aws", "vpc-1", "us-east-1", "t3.small", "10.0.0.0/24")
+	if !IsTransient(err) {
+		t.Fatalf("CreateGateway() error = %v, want the timeout", err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, want a create that may have been processed sent once", requests)
+	}
+}
+
+func TestRefusedConnectionIsUnprocessed(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {}, PoolConfig{})
+	client.applyLimits(PoolConfig{RequestsPerSecond: -1, MaxRetries: 1, RetryBackoff: time.Millisecond})
+	// Nothing listens on port 1, so no request reaches a Controller
+	client.ControllerIP = "127.0.0.1:1"
+
+	_, err := client.GetGateway(context.Background(), "spoke")
+	if !unprocessed(err) {
+		t.Fatalf("GetGateway() error = %v, want a refused connection", err)
+	}
+}
+
+func TestOpenCircuitPausesRequests(t *testing.T) {
+	var requests int32
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, PoolConfig{})
+	client.applyLimits(PoolConfig{RequestsPerSecond: -1, MaxRetries: 1, RetryBackoff: time.Millisecond, BreakerThreshold: 2, BreakerCooldown: time.Minute})
+
+	if _, err := client.GetGateway(context.Background(), "spoke"); !IsTransient(err) || IsCircuitOpen(err) {
+		t.Fatalf("GetGateway() error = %v, want the unavailable Controller", err)
+	}
+	if state := client.CircuitState(); state != CircuitOpen {
+		t.Fatalf("CircuitState() = %s, want open after two failures in a row", state)
+	}
+
+	_, err := client.GetGateway(context.Background(), "spoke")
+	if !IsCircuitOpen(err) || !IsTransient(err) {
+		t.Fatalf("GetGateway() error = %v, want it held back by the circuit breaker", err)
+	}
+	if after := RetryAfter(err); after <= 0 || after > time.Minute {
+		t.Errorf("RetryAfter() = %s, want the rest of the cooldown", after)
+	}
+	if requests != 2 {
+		t.Errorf("%d requests, want none sent while the circuit is open", requests)
+	}
+}
+
+func TestBreakerProbesOnceAfterCooldown(t *testing.T) {
+	b := newBreaker(2, time.Minute)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Failures in a row open the circuit; a reachable Controller resets the count
+	b.record(true, now)
+	b.record(false, now)
+	b.record(true, now)
+	if state := b.State(now); state != CircuitClosed {
+		t.Fatalf("State() = %s, want closed after failures that were not in a row", state)
+	}
+	b.record(true, now)
+	if wait, ok := b.allow(now.Add(10 * time.Second)); ok || wait != 50*time.Second {
+		t.Fatalf("allow() while open = %s, %t, want held back for the rest of the cooldown", wait, ok)
+	}
+
+	halfOpen := now.Add(time.Minute)
+	if _, ok := b.allow(halfOpen); !ok {
+		t.Fatal("allow() after the cooldown = false, want a probe")
+	}
+	if _, ok := b.allow(halfOpen); ok {
+		t.Error("allow() while probing = true, want a single probe")
+	}
+	b.record(true, halfOpen)
+	if state := b.State(halfOpen); state != CircuitOpen {
+		t.Fatalf("State() after a failed probe = %s, want open again", state)
+	}
+
+	recovered := halfOpen.Add(time.Minute)
+	if _, ok := b.allow(recovered); !ok {
+		t.Fatal("allow() after the cooldown = false, want a probe")
+	}
+	b.record(false, recovered)
+	if _, ok := b.allow(recovered); !ok || b.State(recovered) != CircuitClosed {
+		t.Errorf("State() after a successful probe = %s, want closed", b.State(recovered))
+	}
+}
+
+func TestRetryWait(t *testing.T) {
+	policy := retryPolicy{maxRetries: 5, backoff: time.Second, maxBackoff: 4 * time.Second}
+	for attempt, max := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 5: 4 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if wait := policy.wait(attempt, nil); wait < max/2 || wait > max {
+				t.Fatalf("wait(%d) = %s, want between %s and %s", attempt, wait, max/2, max)
+			}
+		}
+	}
+
+	throttled := &APIError{Code: ErrorCodeUnavailable, RetryAfter: 10 * time.Second}
+	if wait := policy.wait(1, throttled); wait != 10*time.Second {
+		t.Errorf("wait() = %s, want the Retry-After of the Controller", wait)
+	}
+	if wait := policy.wait(1, errors.New("boom")); wait > time.Second {
+		t.Errorf("wait() = %s, want the backoff", wait)
+	}
+}
+
+func TestRetryAfterHeader(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for value, want := range map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"soon":                          0,
+		"Thu, 01 Oct 2026 12:00:30 GMT": 30 * time.Second,
+	} {
+		header := http.Header{}
+		if value != "" {
+			header.Set("Retry-After", value)
+		}
+		if got := retryAfterHeader(header, now); got != want {
+			t.Errorf("retryAfterHeader(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
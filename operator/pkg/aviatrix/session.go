@@ -0,0 +1,92 @@
+package aviatrix
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// sessionManager holds the credentials of a client and the session of its last login. Every
+// reconcile reads the session while others may log in again, so all access goes through it.
+type sessionManager struct {
+	mu          sync.RWMutex
+	credentials Credentials
+	id          string
+	// generation counts the credentials set, so a login that raced with new credentials does
+	// not replace their session with one of the old credentials
+	generation uint64
+
+	// renewMu lets one request at a time renew an expired session; the requests waiting for it
+	// use the renewed session instead of logging in themselves
+	renewMu      sync.Mutex
+	loginBackoff time.Duration
+}
+
+// current returns the session ID of the last login
+func (s *sessionManager) current() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// snapshot returns the credentials in effect and their generation
+func (s *sessionManager) snapshot() (Credentials, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.credentials, s.generation
+}
+
+// replace sets new credentials and the session they logged in with
+func (s *sessionManager) replace(credentials Credentials, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.credentials = credentials
+	s.id = id
+	s.generation++
+}
+
+// update sets the session of a login with the credentials of generation. It reports false
+// when the credentials were replaced meanwhile; their session is kept.
+func (s *sessionManager) update(generation uint64, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return false
+	}
+	s.id = id
+	return true
+}
+
+// renew logs in again after a request found the session expired, retrying with backoff.
+// Requests that find the same session expired wait for the first one to renew it and use the
+// renewed session.
+func (s *sessionManager) renew(ctx context.Context, expired string, login func(context.Context) error) (string, error) {
+	s.renewMu.Lock()
+	defer s.renewMu.Unlock()
+	if current := s.current(); current != expired {
+		return current, nil
+	}
+
+	backoff := s.loginBackoff
+	if backoff <= 0 {
+		backoff = DefaultLoginBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		err := login(ctx)
+		if err == nil {
+			ctrl.LoggerFrom(ctx).Info("Aviatrix session expired, logged in again", "attempt", attempt)
+			return s.current(), nil
+		}
+		if attempt >= DefaultLoginAttempts {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
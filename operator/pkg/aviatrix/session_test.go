@@ -0,0 +1,37 @@
+package aviatrix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestLoginKeepsSessionOfNewCredentials(t *testing.T) {
+	loginStarted, setCredentials := make(chan struct{}), make(chan struct{})
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		json.NewDecoder(r.Body).Decode(&data)
+		if data["username"] == "old" {
+			// New credentials are set while the login with the old ones is in flight
+			close(loginStarted)
+			<-setCredentials
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"return": true, "CID": data["username"]})
+	}, PoolConfig{})
+	client.sessions.credentials = Credentials{Username: "old", Password: "secret"}
+
+	done := make(chan error)
+	go func() { done <- client.Login(context.Background()) }()
+	<-loginStarted
+	if err := client.SetCredentials(context.Background(), Credentials{Username: "new", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	close(setCredentials)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if session := client.session(); session != "new" {
+		t.Errorf("session = %q, want the session of the new credentials kept", session)
+	}
+}
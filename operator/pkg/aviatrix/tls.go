@@ -0,0 +1,110 @@
+package aviatrix
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Keys of the ConfigMap and Secret holding the TLS configuration. They match the keys of
+// kubernetes.io/tls Secrets and of the Secrets cert-manager issues.
+const (
+	TLSKeyCA   = "ca.crt"
+	TLSKeyCert = corev1.TLSCertKey
+	TLSKeyKey  = corev1.TLSPrivateKeyKey
+)
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get
+
+// TLSOptions tells how the client verifies the certificate of the Aviatrix Controller and
+// which certificate it presents. The zero value verifies against the system roots.
+type TLSOptions struct {
+	// CAConfigMap is a ConfigMap whose key ca.crt holds PEM CA certificates trusted in addition
+	// to the system roots, e.g. the CA that signed a self-signed Controller certificate
+	CAConfigMap types.NamespacedName
+	// Secret is a Secret holding trusted CA certificates in ca.crt and, in tls.crt and tls.key,
+	// a client certificate presented to the Controller. Either part may be left out.
+	Secret types.NamespacedName
+	// InsecureSkipVerify accepts any certificate of the Controller. Only meant for labs: the
+	// credentials of the operator can then be intercepted.
+	InsecureSkipVerify bool
+}
+
+// Config reads the ConfigMap and Secret of the options through reader and returns the TLS
+// configuration of the connections to the Controller, or nil when the options are all unset
+func (o TLSOptions) Config(ctx context.Context, reader client.Reader) (*tls.Config, error) {
+	if o == (TLSOptions{}) {
+		return nil, nil
+	}
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+
+	var bundle []byte
+	if o.CAConfigMap.Name != "" {
+		configMap := &corev1.ConfigMap{}
+		if err := reader.Get(ctx, o.CAConfigMap, configMap); err != nil {
+			return nil, fmt.Errorf("failed to read CA ConfigMap %s: %w", o.CAConfigMap, err)
+		}
+		if configMap.Data[TLSKeyCA] == "" {
+			return nil, fmt.Errorf("CA ConfigMap %s has no key %s", o.CAConfigMap, TLSKeyCA)
+		}
+		bundle = append(bundle, configMap.Data[TLSKeyCA]...)
+	}
+	if o.Secret.Name != "" {
+		secret := &corev1.Secret{}
+		if err := reader.Get(ctx, o.Secret, secret); err != nil {
+			return nil, fmt.Errorf("failed to read TLS Secret %s: %w", o.Secret, err)
+		}
+		bundle = append(bundle, secret.Data[TLSKeyCA]...)
+
+		cert, key := secret.Data[TLSKeyCert], secret.Data[TLSKeyKey]
+		if len(cert) > 0 || len(key) > 0 {
+			certificate, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid client certificate in TLS Secret %s: %w", o.Secret, err)
+			}
+			config.Certificates = []tls.Certificate{certificate}
+		}
+	}
+
+	if len(bundle) > 0 {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no PEM certificates in %s", TLSKeyCA)
+		}
+		config.RootCAs = roots
+	}
+	return config, nil
+}
+
+// ParseTLSOptions parses the namespace/name of the CA ConfigMap and of the TLS Secret; either
+// may be empty
+func ParseTLSOptions(caConfigMap, secret string, insecureSkipVerify bool) (TLSOptions, error) {
+	options := TLSOptions{InsecureSkipVerify: insecureSkipVerify}
+	if caConfigMap != "" {
+		namespace, name, ok := strings.Cut(caConfigMap, "/")
+		if !ok || namespace == "" || name == "" {
+			return TLSOptions{}, fmt.Errorf("invalid CA ConfigMap %q, want namespace/name", caConfigMap)
+		}
+		options.CAConfigMap = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	if secret != "" {
+		namespace, name, ok := strings.Cut(secret, "/")
+		if !ok || namespace == "" || name == "" {
+			return TLSOptions{}, fmt.Errorf("invalid TLS Secret %q, want namespace/name", secret)
+		}
+		options.Secret = types.NamespacedName{Namespace: namespace, Name: name}
+	}
+	return options, nil
+}
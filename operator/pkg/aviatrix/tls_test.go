@@ -0,0 +1,123 @@
+package aviatrix
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// clientCertificate returns a self-signed client certificate and its key in PEM
+func clientCertificate(t *testing.T) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "aviatrix-operator"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestTLSOptionsTrustCAAndPresentClientCertificate(t *testing.T) {
+	var clientCerts int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts = len(r.TLS.PeerCertificates)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	cert, key := clientCertificate(t)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "aviatrix-system", Name: "aviatrix-ca"},
+		Data:       map[string]string{TLSKeyCA: string(ca)},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "aviatrix-system", Name: "aviatrix-client-tls"},
+		Data:       map[string][]byte{TLSKeyCert: cert, TLSKeyKey: key},
+	}
+	reader := fake.NewClientBuilder().WithObjects(configMap, secret).Build()
+
+	// The certificate of the test server is not signed by a system root
+	if _, err := newHTTPClient(PoolConfig{}).Get(server.URL); err == nil {
+		t.Fatal("Get() without the CA succeeded, want the certificate rejected")
+	}
+
+	options := TLSOptions{
+		CAConfigMap: types.NamespacedName{Namespace: "aviatrix-system", Name: "aviatrix-ca"},
+		Secret:      types.NamespacedName{Namespace: "aviatrix-system", Name: "aviatrix-client-tls"},
+	}
+	config, err := options.Config(context.Background(), reader)
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+	resp, err := newHTTPClient(PoolConfig{TLS: config}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v, want the server trusted", err)
+	}
+	resp.Body.Close()
+	if clientCerts != 1 {
+		t.Errorf("server saw %d client certificates, want 1", clientCerts)
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "aviatrix-system", Name: "no-ca"},
+		Data:       map[string]string{TLSKeyCA: "not a certificate"},
+	}
+	reader := fake.NewClientBuilder().WithObjects(configMap).Build()
+
+	if config, err := (TLSOptions{}).Config(context.Background(), reader); config != nil || err != nil {
+		t.Errorf("Config() = %v, %v, want nil without options", config, err)
+	}
+	config, err := TLSOptions{InsecureSkipVerify: true}.Config(context.Background(), reader)
+	if err != nil || !config.InsecureSkipVerify {
+		t.Errorf("Config() = %+v, %v, want verification skipped", config, err)
+	}
+	if _, err := (TLSOptions{CAConfigMap: types.NamespacedName{Namespace: "aviatrix-system", Name: "no-ca"}}).Config(context.Background(), reader); err == nil {
+		t.Error("Config() with an invalid CA succeeded, want an error")
+	}
+	if _, err := (TLSOptions{Secret: types.NamespacedName{Namespace: "aviatrix-system", Name: "missing"}}).Config(context.Background(), reader); err == nil {
+		t.Error("Config() with a missing Secret succeeded, want an error")
+	}
+}
+
+func TestParseTLSOptions(t *testing.T) {
+	options, err := ParseTLSOptions("aviatrix-system/aviatrix-ca", "", true)
+	if err != nil || options.CAConfigMap.Name != "aviatrix-ca" || options.Secret.Name != "" || !options.InsecureSkipVerify {
+		t.Errorf("ParseTLSOptions() = %+v, %v", options, err)
+	}
+	if _, err := ParseTLSOptions("", "aviatrix-client-tls", false); err == nil {
+		t.Error("ParseTLSOptions() without a namespace succeeded, want an error")
+	}
+}
@@ -0,0 +1,95 @@
+package aviatrix
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Value is a scalar the Controller reports as a string or a number depending on the action,
+// such as cloud_type, kept as its string form
+type Value string
+
+// UnmarshalJSON accepts strings, numbers and booleans
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = Value(s)
+		return nil
+	}
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch raw := raw.(type) {
+	case nil:
+		*v = ""
+	case float64, bool:
+		*v = Value(fmt.Sprintf("%v", raw))
+	default:
+		return fmt.Errorf("cannot use %s as a scalar value", data)
+	}
+	return nil
+}
+
+// GatewayInfo is a gateway as reported by get_gateway_info and list_vpcs_summary
+type GatewayInfo struct {
+	GwName          string `json:"gw_name"`
+	CloudType       Value  `json:"cloud_type"`
+	AccountName     string `json:"account_name"`
+	VpcID           string `json:"vpc_id"`
+	VpcRegion       string `json:"vpc_reg"`
+	GwSize          string `json:"gw_size"`
+	Subnet          string `json:"subnet"`
+	PublicIP        string `json:"public_ip"`
+	PrivateIP       string `json:"private_ip"`
+	InstanceID      string `json:"instance_id"`
+	SoftwareVersion string `json:"software_version"`
+	ImageVersion    string `json:"image_version"`
+
+	// Features toggled on transit gateways
+	BgpEnabled          bool `json:"enable_transit_bgp"`
+	SegmentationEnabled bool `json:"enable_segmentation"`
+	FireNetEnabled      bool `json:"enable_firenet"`
+}
+
+// Enabled reports whether a transit feature is enabled on the gateway
+func (g *GatewayInfo) Enabled(feature TransitFeature) bool {
+	switch feature {
+	case TransitFeatureBgp:
+		return g.BgpEnabled
+	case TransitFeatureSegmentation:
+		return g.SegmentationEnabled
+	case TransitFeatureFireNet:
+		return g.FireNetEnabled
+	}
+	return false
+}
+
+// VpcInfo is a VPC as reported by get_vpc_info and list_custom_vpcs
+type VpcInfo struct {
+	Name        string `json:"vpc_name"`
+	VpcID       string `json:"vpc_id"`
+	CloudType   Value  `json:"cloud_type"`
+	AccountName string `json:"account_name"`
+	Region      string `json:"region"`
+	CIDR        string `json:"cidr"`
+}
+
+// FirewallPolicy is the stateful firewall of a gateway: a base policy and the rules that
+// override it, in order
+type FirewallPolicy struct {
+	GwName     string         `json:"gw_name"`
+	BasePolicy string         `json:"base_policy"`
+	Rules      []FirewallRule `json:"rules"`
+}
+
+// FirewallRule is a rule of a FirewallPolicy
+type FirewallRule struct {
+	Protocol    string `json:"protocol"`
+	SrcIP       string `json:"s_ip"`
+	DstIP       string `json:"d_ip"`
+	Port        string `json:"port"`
+	Action      string `json:"deny_allow"`
+	LogEnabled  bool   `json:"log_enable"`
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,125 @@
+package cacheconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"aviatrix-operator/pkg/metrics"
+)
+
+// selectable lists the kinds whose informers can be limited with a label selector. Only
+// built-in kinds the operator watches cluster-wide are accepted; custom resources are always
+// cached in full.
+var selectable = map[string]func() client.Object{
+	"Pod":         func() client.Object { return &corev1.Pod{} },
+	"Service":     func() client.Object { return &corev1.Service{} },
+	"ConfigMap":   func() client.Object { return &corev1.ConfigMap{} },
+	"Secret":      func() client.Object { return &corev1.Secret{} },
+	"StatefulSet": func() client.Object { return &appsv1.StatefulSet{} },
+}
+
+// Selectors limits the informers of some kinds to objects matching a label selector. It
+// implements flag.Value so it can be set with a repeated
+// --cache-selector=Kind=selector, e.g. ConfigMap=app.kubernetes.io/managed-by=k8s-playgrounds-operator.
+type Selectors map[string]labels.Selector
+
+// String lists the selectors as Kind=selector pairs
+func (s Selectors) String() string {
+	pairs := make([]string, 0, len(s))
+	for kind, selector := range s {
+		pairs = append(pairs, kind+"="+selector.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses a Kind=selector pair. Setting the same kind again replaces its selector.
+func (s Selectors) Set(value string) error {
+	kind, raw, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("missing label selector for cache selector %s", value)
+	}
+	kind = strings.TrimSpace(kind)
+	if _, ok := selectable[kind]; !ok {
+		return fmt.Errorf("kind %s cannot be limited by a cache selector", kind)
+	}
+	selector, err := labels.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid label selector for kind %s: %w", kind, err)
+	}
+	if selector.Empty() {
+		return fmt.Errorf("empty label selector for kind %s", kind)
+	}
+	s[kind] = selector
+	return nil
+}
+
+// Options returns the informer cache options of the manager: every object is stripped by
+// StripManagedFields and the kinds in selectors only cache matching objects. Reads of objects
+// outside a selector find nothing, so a selector must cover every object the operator reads.
+func Options(selectors Selectors) cache.Options {
+	opts := cache.Options{
+		DefaultTransform: StripManagedFields,
+	}
+	if len(selectors) > 0 {
+		opts.ByObject = make(map[client.Object]cache.ByObject, len(selectors))
+		for kind, selector := range selectors {
+			opts.ByObject[selectable[kind]()] = cache.ByObject{Label: selector}
+		}
+	}
+	return opts
+}
+
+// StripManagedFields is a cache transform that drops the managed fields of objects before
+// they are stored. Managed fields are often the largest part of an object's metadata and the
+// operator never reads them; updates sent from a cached object leave them unchanged on the
+// server. The last-applied-configuration annotation is kept because an update would remove
+// it. The object sizes before and after are recorded in the cache metrics.
+func StripManagedFields(in interface{}) (interface{}, error) {
+	obj, err := meta.Accessor(in)
+	if err != nil {
+		// Tombstones of deleted objects are passed through unchanged
+		return in, nil
+	}
+
+	before := objectSize(in)
+	// Objects without managed fields are left untouched
+	if obj.GetManagedFields() != nil {
+		obj.SetManagedFields(nil)
+	}
+	metrics.RecordCacheTransform(kindOf(in), before, objectSize(in))
+	return in, nil
+}
+
+// objectSize returns the protobuf-encoded size of built-in objects and 0 for other objects
+func objectSize(in interface{}) int {
+	if sized, ok := in.(interface{ Size() int }); ok {
+		return sized.Size()
+	}
+	return 0
+}
+
+// kindOf names the kind of an object. Typed objects from informers have no TypeMeta, so the
+// Go type name is used instead.
+func kindOf(in interface{}) string {
+	if obj, ok := in.(runtime.Object); ok {
+		if kind := obj.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+			return kind
+		}
+	}
+	t := reflect.TypeOf(in)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
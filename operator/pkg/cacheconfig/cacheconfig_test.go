@@ -0,0 +1,70 @@
+package cacheconfig
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+func TestStripManagedFields(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "web-0",
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: "{}"},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationApply, FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:spec":{}}`)}},
+			},
+		},
+	}
+	before := objectSize(pod)
+
+	out, err := StripManagedFields(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stripped := out.(*corev1.Pod)
+	if stripped.ManagedFields != nil {
+		t.Errorf("managed fields = %v, want none", stripped.ManagedFields)
+	}
+	if stripped.Annotations[corev1.LastAppliedConfigAnnotation] == "" {
+		t.Error("last-applied-configuration annotation was removed")
+	}
+	if after := objectSize(stripped); after >= before {
+		t.Errorf("size after = %d, want less than %d", after, before)
+	}
+	if kind := kindOf(stripped); kind != "Pod" {
+		t.Errorf("kindOf() = %q, want Pod", kind)
+	}
+
+	tombstone := toolscache.DeletedFinalStateUnknown{Key: "default/web-0"}
+	if out, err := StripManagedFields(tombstone); err != nil || out != tombstone {
+		t.Errorf("StripManagedFields(tombstone) = %v, %v", out, err)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	selectors := Selectors{}
+	if err := selectors.Set("ConfigMap=app.kubernetes.io/managed-by=k8s-playgrounds-operator"); err != nil {
+		t.Fatal(err)
+	}
+	if got := selectors.String(); got != "ConfigMap=app.kubernetes.io/managed-by=k8s-playgrounds-operator" {
+		t.Errorf("String() = %q", got)
+	}
+	for _, invalid := range []string{"ConfigMap", "AviatrixGateway=app=web", "Pod=", "Pod=app in (web"} {
+		if err := selectors.Set(invalid); err == nil {
+			t.Errorf("Set(%q) succeeded", invalid)
+		}
+	}
+
+	opts := Options(selectors)
+	if opts.DefaultTransform == nil || len(opts.ByObject) != 1 {
+		t.Errorf("Options() = %+v, want the transform and one selector", opts)
+	}
+	for obj, byObject := range opts.ByObject {
+		if _, ok := obj.(*corev1.ConfigMap); !ok || byObject.Label == nil {
+			t.Errorf("ByObject[%T] = %+v", obj, byObject)
+		}
+	}
+}
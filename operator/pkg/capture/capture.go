@@ -0,0 +1,516 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/serviceports"
+)
+
+// CaptureAnnotation on a K8sPlaygroundsCluster asks the operator to fill its spec
+// from the live objects of the namespace named in the annotation value
+const CaptureAnnotation = "k8s-playgrounds.io/capture-from"
+
+// CheckSource rejects capturing a namespace other than the one of the cluster. The operator
+// can read every namespace, so capturing any of them would let whoever may create a cluster copy
+// workloads and ConfigMaps they cannot read themselves.
+func CheckSource(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, source string) error {
+	if source != cluster.Namespace {
+		return fmt.Errorf("can only capture the namespace of the cluster, %s, not %s", cluster.Namespace, source)
+	}
+	return nil
+}
+
+// skippedConfigMaps are cluster-managed ConfigMaps that must not be captured
+var skippedConfigMaps = map[string]bool{
+	"kube-root-ca.crt": true,
+}
+
+// Capturer generates a K8sPlaygroundsClusterSpec from the objects in a live namespace
+type Capturer struct {
+	client client.Client
+}
+
+// NewCapturer creates a new namespace capturer
+func NewCapturer(client client.Client) *Capturer {
+	return &Capturer{
+		client: client,
+	}
+}
+
+// Capture reads Deployments, StatefulSets, DaemonSets, Jobs, Services and ConfigMaps from the
+// namespace and converts them into a spec that reproduces them. Objects owned by another
+// object (e.g. ReplicaSets created by Deployments) are skipped, as are Secrets, whose
+// data must not end up in a reusable playground definition. Node ports allocated to the live
+// Services are dropped, as are their cluster IPs, so the captured Services do not collide with
+// the originals.
+func (c *Capturer) Capture(ctx context.Context, namespace string) (*k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec, error) {
+	log := logr.FromContextOrDiscard(ctx)
+	inNamespace := client.InNamespace(namespace)
+	spec := &k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+		Version:  "latest",
+		Replicas: 1,
+	}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := c.client.List(ctx, deployments, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range deployments.Items {
+		if isOwned(d.ObjectMeta) {
+			continue
+		}
+		spec.Deployments = append(spec.Deployments, FromDeployment(d))
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := c.client.List(ctx, statefulSets, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, s := range statefulSets.Items {
+		if isOwned(s.ObjectMeta) {
+			continue
+		}
+		spec.StatefulSets = append(spec.StatefulSets, FromStatefulSet(s))
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := c.client.List(ctx, daemonSets, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+	}
+	for _, d := range daemonSets.Items {
+		if isOwned(d.ObjectMeta) {
+			continue
+		}
+		spec.DaemonSets = append(spec.DaemonSets, FromDaemonSet(d))
+	}
+
+	jobs := &batchv1.JobList{}
+	if err := c.client.List(ctx, jobs, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, j := range jobs.Items {
+		// Jobs spawned by CronJobs are owned and skipped here
+		if isOwned(j.ObjectMeta) {
+			continue
+		}
+		spec.Jobs = append(spec.Jobs, FromJob(j))
+	}
+
+	services := &corev1.ServiceList{}
+	if err := c.client.List(ctx, services, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	for _, s := range services.Items {
+		if isOwned(s.ObjectMeta) {
+			continue
+		}
+		if IsHeadless(s) {
+			spec.HeadlessServices = append(spec.HeadlessServices, FromHeadlessService(s))
+			continue
+		}
+		service := FromService(s)
+		for i := range service.Ports {
+			service.Ports[i].NodePort = 0
+		}
+		spec.Services = append(spec.Services, service)
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.client.List(ctx, configMaps, inNamespace); err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+	for _, cm := range configMaps.Items {
+		if isOwned(cm.ObjectMeta) || skippedConfigMaps[cm.Name] {
+			continue
+		}
+		spec.ConfigMaps = append(spec.ConfigMaps, FromConfigMap(cm))
+	}
+
+	log.Info("captured namespace",
+		"namespace", namespace,
+		"deployments", len(spec.Deployments),
+		"statefulSets", len(spec.StatefulSets),
+		"services", len(spec.Services)+len(spec.HeadlessServices),
+		"configMaps", len(spec.ConfigMaps))
+
+	return spec, nil
+}
+
+// FromDeployment converts a Deployment to a DeploymentSpec
+func FromDeployment(d appsv1.Deployment) k8splaygroundsv1alpha1.DeploymentSpec {
+	return k8splaygroundsv1alpha1.DeploymentSpec{
+		Name:        d.Name,
+		Labels:      d.Labels,
+		Annotations: cleanAnnotations(d.Annotations),
+		Replicas:    replicasOrOne(d.Spec.Replicas),
+		Selector:    selectorLabels(d.Spec.Selector),
+		Template:    convertPodTemplate(d.Spec.Template),
+		Strategy:    string(d.Spec.Strategy.Type),
+	}
+}
+
+// FromStatefulSet converts a StatefulSet to a StatefulSetSpec
+func FromStatefulSet(s appsv1.StatefulSet) k8splaygroundsv1alpha1.StatefulSetSpec {
+	return k8splaygroundsv1alpha1.StatefulSetSpec{
+		Name:                 s.Name,
+		Labels:               s.Labels,
+		Annotations:          cleanAnnotations(s.Annotations),
+		Replicas:             replicasOrOne(s.Spec.Replicas),
+		Selector:             selectorLabels(s.Spec.Selector),
+		Template:             convertPodTemplate(s.Spec.Template),
+		ServiceName:          s.Spec.ServiceName,
+		VolumeClaimTemplates: convertClaimTemplates(s.Spec.VolumeClaimTemplates),
+		UpdateStrategy:       string(s.Spec.UpdateStrategy.Type),
+		PodManagementPolicy:  string(s.Spec.PodManagementPolicy),
+	}
+}
+
+// FromDaemonSet converts a DaemonSet to a DaemonSetSpec
+func FromDaemonSet(d appsv1.DaemonSet) k8splaygroundsv1alpha1.DaemonSetSpec {
+	return k8splaygroundsv1alpha1.DaemonSetSpec{
+		Name:           d.Name,
+		Labels:         d.Labels,
+		Annotations:    cleanAnnotations(d.Annotations),
+		Selector:       selectorLabels(d.Spec.Selector),
+		Template:       convertPodTemplate(d.Spec.Template),
+		UpdateStrategy: string(d.Spec.UpdateStrategy.Type),
+	}
+}
+
+// FromJob converts a Job to a JobSpec
+func FromJob(j batchv1.Job) k8splaygroundsv1alpha1.JobSpec {
+	return k8splaygroundsv1alpha1.JobSpec{
+		Name:                  j.Name,
+		Labels:                j.Labels,
+		Annotations:           cleanAnnotations(j.Annotations),
+		Template:              convertPodTemplate(j.Spec.Template),
+		Parallelism:           j.Spec.Parallelism,
+		Completions:           j.Spec.Completions,
+		BackoffLimit:          j.Spec.BackoffLimit,
+		ActiveDeadlineSeconds: j.Spec.ActiveDeadlineSeconds,
+	}
+}
+
+// IsHeadless reports whether a Service is headless, i.e. converts to a HeadlessServiceSpec
+func IsHeadless(s corev1.Service) bool {
+	return s.Spec.ClusterIP == corev1.ClusterIPNone
+}
+
+// FromService converts a Service with a cluster IP to a ServiceSpec
+func FromService(s corev1.Service) k8splaygroundsv1alpha1.ServiceSpec {
+	return k8splaygroundsv1alpha1.ServiceSpec{
+		Name:        s.Name,
+		Labels:      s.Labels,
+		Annotations: cleanAnnotations(s.Annotations),
+		Selector:    s.Spec.Selector,
+		Ports:       serviceports.FromCore(s.Spec.Ports),
+		Type:        string(s.Spec.Type),
+	}
+}
+
+// FromHeadlessService converts a headless Service to a HeadlessServiceSpec
+func FromHeadlessService(s corev1.Service) k8splaygroundsv1alpha1.HeadlessServiceSpec {
+	return k8splaygroundsv1alpha1.HeadlessServiceSpec{
+		Name:        s.Name,
+		Labels:      s.Labels,
+		Annotations: cleanAnnotations(s.Annotations),
+		Selector:    s.Spec.Selector,
+		Ports:       serviceports.FromCore(s.Spec.Ports),
+	}
+}
+
+// FromConfigMap converts a ConfigMap to a ConfigMapSpec
+func FromConfigMap(cm corev1.ConfigMap) k8splaygroundsv1alpha1.ConfigMapSpec {
+	return k8splaygroundsv1alpha1.ConfigMapSpec{
+		Name:        cm.Name,
+		Labels:      cm.Labels,
+		Annotations: cleanAnnotations(cm.Annotations),
+		Data:        cm.Data,
+		BinaryData:  cm.BinaryData,
+	}
+}
+
+// isOwned reports whether an object is managed by another object
+func isOwned(meta metav1.ObjectMeta) bool {
+	return len(meta.OwnerReferences) > 0
+}
+
+// cleanAnnotations drops annotations written by kubectl and controllers
+func cleanAnnotations(annotations map[string]string) map[string]string {
+	result := make(map[string]string)
+	for k, v := range annotations {
+		switch k {
+		case corev1.LastAppliedConfigAnnotation, "deployment.kubernetes.io/revision":
+			continue
+		}
+		result[k] = v
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// replicasOrOne dereferences a replica count, defaulting to one like the API server does
+func replicasOrOne(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// selectorLabels returns the match labels of a label selector
+func selectorLabels(selector *metav1.LabelSelector) map[string]string {
+	if selector == nil {
+		return nil
+	}
+	return selector.MatchLabels
+}
+
+// convertPodTemplate converts a Kubernetes pod template to a PodTemplateSpec
+func convertPodTemplate(template corev1.PodTemplateSpec) k8splaygroundsv1alpha1.PodTemplateSpec {
+	spec := k8splaygroundsv1alpha1.PodSpec{
+		RestartPolicy:     string(template.Spec.RestartPolicy),
+		NodeSelector:      template.Spec.NodeSelector,
+		PriorityClassName: template.Spec.PriorityClassName,
+	}
+	if template.Spec.RuntimeClassName != nil {
+		spec.RuntimeClassName = *template.Spec.RuntimeClassName
+	}
+	// The API server fills in the default scheduler, which is left implied
+	if template.Spec.SchedulerName != corev1.DefaultSchedulerName {
+		spec.SchedulerName = template.Spec.SchedulerName
+	}
+
+	for _, c := range template.Spec.Containers {
+		spec.Containers = append(spec.Containers, convertContainer(c))
+	}
+
+	for _, v := range template.Spec.Volumes {
+		spec.Volumes = append(spec.Volumes, k8splaygroundsv1alpha1.VolumeSpec{
+			Name:         v.Name,
+			VolumeSource: convertVolumeSource(v.VolumeSource),
+		})
+	}
+
+	if sc := template.Spec.SecurityContext; sc != nil {
+		spec.SecurityContext = &k8splaygroundsv1alpha1.SecurityContextSpec{
+			RunAsUser:    sc.RunAsUser,
+			RunAsGroup:   sc.RunAsGroup,
+			RunAsNonRoot: sc.RunAsNonRoot,
+			FSGroup:      sc.FSGroup,
+		}
+	}
+
+	// AffinitySpec mirrors the fields of the Kubernetes type, so it is converted through JSON
+	if template.Spec.Affinity != nil {
+		if data, err := json.Marshal(template.Spec.Affinity); err == nil {
+			affinity := &k8splaygroundsv1alpha1.AffinitySpec{}
+			if json.Unmarshal(data, affinity) == nil {
+				spec.Affinity = affinity
+			}
+		}
+	}
+
+	for _, t := range template.Spec.Tolerations {
+		spec.Tolerations = append(spec.Tolerations, k8splaygroundsv1alpha1.TolerationSpec{
+			Key:               t.Key,
+			Operator:          string(t.Operator),
+			Value:             t.Value,
+			Effect:            string(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+
+	return k8splaygroundsv1alpha1.PodTemplateSpec{
+		Metadata: metav1.ObjectMeta{
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: spec,
+	}
+}
+
+// convertContainer converts a Kubernetes container to a ContainerSpec
+func convertContainer(c corev1.Container) k8splaygroundsv1alpha1.ContainerSpec {
+	container := k8splaygroundsv1alpha1.ContainerSpec{
+		Name:            c.Name,
+		Image:           c.Image,
+		ImagePullPolicy: string(c.ImagePullPolicy),
+		Command:         c.Command,
+		Args:            c.Args,
+	}
+
+	for _, p := range c.Ports {
+		container.Ports = append(container.Ports, k8splaygroundsv1alpha1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: p.ContainerPort,
+			Protocol:      string(p.Protocol),
+			HostPort:      p.HostPort,
+		})
+	}
+
+	for _, e := range c.Env {
+		env := k8splaygroundsv1alpha1.EnvVar{Name: e.Name, Value: e.Value}
+		if e.ValueFrom != nil {
+			env.ValueFrom = &k8splaygroundsv1alpha1.EnvVarSource{}
+			if ref := e.ValueFrom.FieldRef; ref != nil {
+				env.ValueFrom.FieldRef = &k8splaygroundsv1alpha1.ObjectFieldSelector{APIVersion: ref.APIVersion, FieldPath: ref.FieldPath}
+			}
+			if ref := e.ValueFrom.ConfigMapKeyRef; ref != nil {
+				env.ValueFrom.ConfigMapKeyRef = &k8splaygroundsv1alpha1.ConfigMapKeySelector{Name: ref.Name, Key: ref.Key}
+			}
+			if ref := e.ValueFrom.SecretKeyRef; ref != nil {
+				env.ValueFrom.SecretKeyRef = &k8splaygroundsv1alpha1.SecretKeySelector{Name: ref.Name, Key: ref.Key}
+			}
+		}
+		container.Env = append(container.Env, env)
+	}
+
+	if len(c.Resources.Limits) > 0 || len(c.Resources.Requests) > 0 {
+		container.Resources = &k8splaygroundsv1alpha1.ResourceRequirements{
+			Limits:   convertResourceList(c.Resources.Limits),
+			Requests: convertResourceList(c.Resources.Requests),
+		}
+	}
+
+	container.LivenessProbe = convertProbe(c.LivenessProbe)
+	container.ReadinessProbe = convertProbe(c.ReadinessProbe)
+
+	for _, m := range c.VolumeMounts {
+		container.VolumeMounts = append(container.VolumeMounts, k8splaygroundsv1alpha1.VolumeMountSpec{
+			Name:      m.Name,
+			MountPath: m.MountPath,
+			ReadOnly:  m.ReadOnly,
+			SubPath:   m.SubPath,
+		})
+	}
+
+	return container
+}
+
+// convertProbe converts the HTTP, TCP and exec probes supported by ProbeSpec
+func convertProbe(probe *corev1.Probe) *k8splaygroundsv1alpha1.ProbeSpec {
+	if probe == nil {
+		return nil
+	}
+	result := &k8splaygroundsv1alpha1.ProbeSpec{
+		InitialDelaySeconds: probe.InitialDelaySeconds,
+		TimeoutSeconds:      probe.TimeoutSeconds,
+		PeriodSeconds:       probe.PeriodSeconds,
+		SuccessThreshold:    probe.SuccessThreshold,
+		FailureThreshold:    probe.FailureThreshold,
+	}
+	switch {
+	case probe.HTTPGet != nil:
+		result.HTTPGet = &k8splaygroundsv1alpha1.HTTPGetAction{
+			Path:   probe.HTTPGet.Path,
+			Port:   probe.HTTPGet.Port,
+			Host:   probe.HTTPGet.Host,
+			Scheme: string(probe.HTTPGet.Scheme),
+		}
+		for _, header := range probe.HTTPGet.HTTPHeaders {
+			result.HTTPGet.HTTPHeaders = append(result.HTTPGet.HTTPHeaders, k8splaygroundsv1alpha1.HTTPHeader{Name: header.Name, Value: header.Value})
+		}
+	case probe.TCPSocket != nil:
+		result.TCPSocket = &k8splaygroundsv1alpha1.TCPSocketAction{Port: probe.TCPSocket.Port, Host: probe.TCPSocket.Host}
+	case probe.Exec != nil:
+		result.Exec = &k8splaygroundsv1alpha1.ExecAction{Command: probe.Exec.Command}
+	}
+	return result
+}
+
+// convertResourceList converts a Kubernetes resource list to string quantities
+func convertResourceList(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(list))
+	for name, quantity := range list {
+		result[string(name)] = quantity.String()
+	}
+	return result
+}
+
+// convertVolumeSource converts the volume sources supported by VolumeSourceSpec
+func convertVolumeSource(source corev1.VolumeSource) k8splaygroundsv1alpha1.VolumeSourceSpec {
+	var result k8splaygroundsv1alpha1.VolumeSourceSpec
+	switch {
+	case source.EmptyDir != nil:
+		result.EmptyDir = &k8splaygroundsv1alpha1.EmptyDirVolumeSource{Medium: string(source.EmptyDir.Medium)}
+		if source.EmptyDir.SizeLimit != nil {
+			result.EmptyDir.SizeLimit = &k8splaygroundsv1alpha1.ResourceQuantity{Value: source.EmptyDir.SizeLimit.String()}
+		}
+	case source.HostPath != nil:
+		result.HostPath = &k8splaygroundsv1alpha1.HostPathVolumeSource{Path: source.HostPath.Path}
+		if source.HostPath.Type != nil {
+			result.HostPath.Type = string(*source.HostPath.Type)
+		}
+	case source.PersistentVolumeClaim != nil:
+		result.PersistentVolumeClaim = &k8splaygroundsv1alpha1.PersistentVolumeClaimVolumeSource{
+			ClaimName: source.PersistentVolumeClaim.ClaimName,
+			ReadOnly:  source.PersistentVolumeClaim.ReadOnly,
+		}
+	case source.ConfigMap != nil:
+		result.ConfigMap = &k8splaygroundsv1alpha1.ConfigMapVolumeSource{
+			Name:        source.ConfigMap.Name,
+			Items:       convertKeyToPaths(source.ConfigMap.Items),
+			DefaultMode: source.ConfigMap.DefaultMode,
+			Optional:    source.ConfigMap.Optional,
+		}
+	case source.Secret != nil:
+		result.Secret = &k8splaygroundsv1alpha1.SecretVolumeSource{
+			SecretName:  source.Secret.SecretName,
+			Items:       convertKeyToPaths(source.Secret.Items),
+			DefaultMode: source.Secret.DefaultMode,
+			Optional:    source.Secret.Optional,
+		}
+	}
+	return result
+}
+
+// convertKeyToPaths converts key-to-path projections
+func convertKeyToPaths(items []corev1.KeyToPath) []k8splaygroundsv1alpha1.KeyToPath {
+	var result []k8splaygroundsv1alpha1.KeyToPath
+	for _, item := range items {
+		result = append(result, k8splaygroundsv1alpha1.KeyToPath{Key: item.Key, Path: item.Path, Mode: item.Mode})
+	}
+	return result
+}
+
+// convertClaimTemplates converts StatefulSet volume claim templates
+func convertClaimTemplates(claims []corev1.PersistentVolumeClaim) []k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate {
+	var result []k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate
+	for _, claim := range claims {
+		accessModes := make([]string, len(claim.Spec.AccessModes))
+		for i, mode := range claim.Spec.AccessModes {
+			accessModes[i] = string(mode)
+		}
+		template := k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate{
+			Metadata: metav1.ObjectMeta{Name: claim.Name, Labels: claim.Labels},
+			Spec: k8splaygroundsv1alpha1.PersistentVolumeClaimSpec{
+				AccessModes: accessModes,
+				Resources: k8splaygroundsv1alpha1.ResourceRequirements{
+					Limits:   convertResourceList(claim.Spec.Resources.Limits),
+					Requests: convertResourceList(claim.Spec.Resources.Requests),
+				},
+				VolumeName: claim.Spec.VolumeName,
+			},
+		}
+		if claim.Spec.StorageClassName != nil {
+			template.Spec.StorageClassName = *claim.Spec.StorageClassName
+		}
+		result = append(result, template)
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package capture
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestCheckSource(t *testing.T) {
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "shop"}}
+	if err := CheckSource(cluster, "team-a"); err != nil {
+		t.Errorf("CheckSource() of the own namespace = %v, want nil", err)
+	}
+	for _, source := range []string{"kube-system", "team-b", ""} {
+		if err := CheckSource(cluster, source); err == nil {
+			t.Errorf("CheckSource(%q) = nil, want an error", source)
+		}
+	}
+}
+
+func TestCaptureDropsAllocatedPorts(t *testing.T) {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "web"},
+		Spec: corev1.ServiceSpec{
+			Type:      corev1.ServiceTypeNodePort,
+			ClusterIP: "10.96.0.10",
+			Selector:  map[string]string{"app": "web"},
+			Ports:     []corev1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}},
+		},
+	}
+	c := fake.NewClientBuilder().WithObjects(service).Build()
+
+	spec, err := NewCapturer(c).Capture(context.Background(), "team-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Services) != 1 {
+		t.Fatalf("captured %d Services, want 1", len(spec.Services))
+	}
+	got := spec.Services[0]
+	if got.Type != string(corev1.ServiceTypeNodePort) || got.Ports[0].Port != 80 {
+		t.Errorf("captured Service = %+v, want a NodePort Service on port 80", got)
+	}
+	if got.Ports[0].NodePort != 0 {
+		t.Errorf("captured node port %d, want it left to the API server", got.Ports[0].NodePort)
+	}
+}
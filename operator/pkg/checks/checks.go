@@ -0,0 +1,242 @@
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Check severities; a failing critical check makes the cluster Unhealthy and a failing warning
+// check makes it Degraded
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+// MinRequeue is the shortest wait before checks are evaluated again, used while a command
+// check runs
+const MinRequeue = 5 * time.Second
+
+// Validate checks the health checks of a cluster. HTTP and TCP checks must name a Service
+// of the cluster; the port, when set, must be one of its ports.
+func Validate(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	var errs field.ErrorList
+	names := make(map[string]bool, len(cluster.Spec.Checks))
+	for i, spec := range cluster.Spec.Checks {
+		path := field.NewPath("spec", "checks").Index(i)
+
+		// The name is part of the helper pod name of command checks
+		for _, msg := range validation.IsDNS1123Label(spec.Name) {
+			errs = append(errs, field.Invalid(path.Child("name"), spec.Name, msg))
+		}
+		if names[spec.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), spec.Name))
+		}
+		names[spec.Name] = true
+
+		probes := 0
+		for _, set := range []bool{spec.HTTP != nil, spec.TCP != nil, spec.Command != nil} {
+			if set {
+				probes++
+			}
+		}
+		if probes != 1 {
+			errs = append(errs, field.Invalid(path, spec.Name, "exactly one of http, tcp and command must be set"))
+		}
+		switch spec.Severity {
+		case "", SeverityCritical, SeverityWarning:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("severity"), spec.Severity, []string{SeverityCritical, SeverityWarning}))
+		}
+
+		if spec.Command != nil {
+			if len(spec.Command.Command) == 0 {
+				errs = append(errs, field.Required(path.Child("command", "command"), "a command is required"))
+			}
+			continue
+		}
+		if spec.HTTP != nil {
+			switch spec.HTTP.Scheme {
+			case "", "http", "https":
+			default:
+				errs = append(errs, field.NotSupported(path.Child("http", "scheme"), spec.HTTP.Scheme, []string{"http", "https"}))
+			}
+		}
+		if _, err := Target(cluster, &spec); err != nil {
+			errs = append(errs, field.Invalid(path.Child("service"), spec.Service, err.Error()))
+		}
+	}
+	return errs.ToAggregate()
+}
+
+// Target returns the host:port an HTTP or TCP check connects to, the cluster DNS name of the
+// Service it names
+func Target(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, spec *k8splaygroundsv1alpha1.HealthCheckSpec) (string, error) {
+	if spec.Service == "" {
+		return "", fmt.Errorf("a Service is required")
+	}
+
+	var namespace string
+	var ports []k8splaygroundsv1alpha1.ServicePort
+	found := false
+	for _, service := range cluster.Spec.Services {
+		if service.Name == spec.Service {
+			namespace, ports, found = service.Namespace, service.Ports, true
+			break
+		}
+	}
+	if !found {
+		for _, service := range cluster.Spec.HeadlessServices {
+			if service.Name == spec.Service {
+				namespace, ports, found = service.Namespace, service.Ports, true
+				break
+			}
+		}
+	}
+	if !found {
+		return "", fmt.Errorf("service %s is not managed by the cluster", spec.Service)
+	}
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+
+	port := spec.Port
+	if port == 0 {
+		if len(ports) == 0 {
+			return "", fmt.Errorf("service %s has no ports", spec.Service)
+		}
+		port = ports[0].Port
+	} else {
+		exposed := false
+		for _, servicePort := range ports {
+			exposed = exposed || servicePort.Port == port
+		}
+		if !exposed {
+			return "", fmt.Errorf("service %s does not expose port %d", spec.Service, port)
+		}
+	}
+	return net.JoinHostPort(fmt.Sprintf("%s.%s.svc", spec.Service, namespace), strconv.Itoa(int(port))), nil
+}
+
+// Probe runs an HTTP or TCP check against target and reports whether it passed, with a
+// message describing the outcome
+func Probe(ctx context.Context, spec *k8splaygroundsv1alpha1.HealthCheckSpec, target string, timeout time.Duration) (bool, string) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if spec.HTTP == nil {
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", target)
+		if err != nil {
+			return false, fmt.Sprintf("connecting to %s failed: %v", target, err)
+		}
+		conn.Close()
+		return true, fmt.Sprintf("connected to %s", target)
+	}
+
+	scheme := spec.HTTP.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	path := spec.HTTP.Path
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("%s://%s%s", scheme, target, path)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err.Error()
+	}
+
+	// Like kubelet probes, checks verify that the application answers, not who it is
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return false, fmt.Sprintf("GET %s failed: %v", url, err)
+	}
+	response.Body.Close()
+
+	expected := spec.HTTP.ExpectedStatus
+	if len(expected) == 0 {
+		expected = []int32{http.StatusOK}
+	}
+	for _, status := range expected {
+		if int(status) == response.StatusCode {
+			return true, fmt.Sprintf("GET %s returned %d", url, response.StatusCode)
+		}
+	}
+	return false, fmt.Sprintf("GET %s returned %d, expected %v", url, response.StatusCode, expected)
+}
+
+// Health returns the cluster health implied by the check statuses. Checks that have not
+// completed a probe yet are ignored.
+func Health(specs []k8splaygroundsv1alpha1.HealthCheckSpec, statuses []k8splaygroundsv1alpha1.HealthCheckStatus) k8splaygroundsv1alpha1.ClusterHealth {
+	severities := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		severities[spec.Name] = spec.Severity
+	}
+
+	health := k8splaygroundsv1alpha1.ClusterHealthHealthy
+	for _, status := range statuses {
+		if status.LastCheckedAt == nil || status.Healthy {
+			continue
+		}
+		if severities[status.Name] == SeverityWarning {
+			health = k8splaygroundsv1alpha1.ClusterHealthDegraded
+			continue
+		}
+		return k8splaygroundsv1alpha1.ClusterHealthUnhealthy
+	}
+	return health
+}
+
+// healthRank orders cluster health from best to worst
+var healthRank = map[k8splaygroundsv1alpha1.ClusterHealth]int{
+	k8splaygroundsv1alpha1.ClusterHealthHealthy:   0,
+	k8splaygroundsv1alpha1.ClusterHealthUnknown:   1,
+	k8splaygroundsv1alpha1.ClusterHealthDegraded:  2,
+	k8splaygroundsv1alpha1.ClusterHealthUnhealthy: 3,
+}
+
+// Worse returns the worse of two cluster health values
+func Worse(a, b k8splaygroundsv1alpha1.ClusterHealth) k8splaygroundsv1alpha1.ClusterHealth {
+	if healthRank[b] > healthRank[a] {
+		return b
+	}
+	return a
+}
+
+// RequeueAfter returns how long to wait until the next check is due, or 0 without checks.
+// Checks that have not completed a probe yet are polled every MinRequeue.
+func RequeueAfter(statuses []k8splaygroundsv1alpha1.HealthCheckStatus, now time.Time) time.Duration {
+	var after time.Duration
+	for _, status := range statuses {
+		wait := MinRequeue
+		if status.NextCheckAt != nil {
+			wait = status.NextCheckAt.Sub(now)
+		}
+		if wait < MinRequeue {
+			wait = MinRequeue
+		}
+		if after == 0 || wait < after {
+			after = wait
+		}
+	}
+	return after
+}
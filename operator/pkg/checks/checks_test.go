@@ -0,0 +1,192 @@
+package checks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+)
+
+func newCluster(checks ...k8splaygroundsv1alpha1.HealthCheckSpec) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "playground", UID: "uid"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Services: []k8splaygroundsv1alpha1.ServiceSpec{
+				{Name: "web", Ports: []k8splaygroundsv1alpha1.ServicePort{{Name: "http", Port: 8080}, {Name: "metrics", Port: 9090}}},
+			},
+			Checks: checks,
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := newCluster(
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "login", Service: "web", HTTP: &k8splaygroundsv1alpha1.HTTPCheckSpec{Path: "/login"}},
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "metrics", Service: "web", Port: 9090, TCP: &k8splaygroundsv1alpha1.TCPCheckSpec{}, Severity: SeverityWarning},
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "migrations", Command: &k8splaygroundsv1alpha1.CommandCheckSpec{Command: []string{"true"}}},
+	)
+	if err := Validate(valid); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	invalid := newCluster(
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "Login", Service: "web", HTTP: &k8splaygroundsv1alpha1.HTTPCheckSpec{}},
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "both", Service: "web", HTTP: &k8splaygroundsv1alpha1.HTTPCheckSpec{}, TCP: &k8splaygroundsv1alpha1.TCPCheckSpec{}},
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "db", Service: "db", TCP: &k8splaygroundsv1alpha1.TCPCheckSpec{}},
+		k8splaygroundsv1alpha1.HealthCheckSpec{Name: "admin", Service: "web", Port: 9443, TCP: &k8splaygroundsv1alpha1.TCPCheckSpec{}},
+	)
+	err := Validate(invalid)
+	if err == nil {
+		t.Fatal("Validate() accepted invalid checks")
+	}
+	for _, want := range []string{"spec.checks[0].name", "spec.checks[1]", "not managed", "does not expose port 9443"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want an error about %s", err, want)
+		}
+	}
+}
+
+func TestTarget(t *testing.T) {
+	cluster := newCluster()
+	target, err := Target(cluster, &k8splaygroundsv1alpha1.HealthCheckSpec{Service: "web"})
+	if err != nil || target != "web.playground.svc:8080" {
+		t.Errorf("Target() = %q, %v, want the first port", target, err)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/login" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	target := strings.TrimPrefix(server.URL, "http://")
+	ctx := context.Background()
+
+	if ok, message := Probe(ctx, &k8splaygroundsv1alpha1.HealthCheckSpec{HTTP: &k8splaygroundsv1alpha1.HTTPCheckSpec{Path: "/login"}}, target, time.Second); !ok {
+		t.Errorf("HTTP probe failed: %s", message)
+	}
+	if ok, message := Probe(ctx, &k8splaygroundsv1alpha1.HealthCheckSpec{HTTP: &k8splaygroundsv1alpha1.HTTPCheckSpec{Path: "/"}}, target, time.Second); ok || !strings.Contains(message, "503") {
+		t.Errorf("HTTP probe = %t, %q, want a failure reporting 503", ok, message)
+	}
+	if ok, message := Probe(ctx, &k8splaygroundsv1alpha1.HealthCheckSpec{TCP: &k8splaygroundsv1alpha1.TCPCheckSpec{}}, target, time.Second); !ok {
+		t.Errorf("TCP probe failed: %s", message)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().String()
+	listener.Close()
+	if ok, _ := Probe(ctx, &k8splaygroundsv1alpha1.HealthCheckSpec{TCP: &k8splaygroundsv1alpha1.TCPCheckSpec{}}, closed, time.Second); ok {
+		t.Error("TCP probe succeeded against a closed port")
+	}
+}
+
+func TestRecordWaitsForThresholds(t *testing.T) {
+	policy := PolicyFor(&k8splaygroundsv1alpha1.HealthCheckSpec{FailureThreshold: 2})
+	now := time.Now()
+
+	status := policy.Record("login", nil, true, "ok", now)
+	if !status.Healthy {
+		t.Fatal("first successful probe is not healthy")
+	}
+	status = policy.Record("login", &status, false, "503", now.Add(30*time.Second))
+	if !status.Healthy || status.ConsecutiveFailures != 1 {
+		t.Errorf("status = %+v, want healthy below the failure threshold", status)
+	}
+	status = policy.Record("login", &status, false, "503", now.Add(time.Minute))
+	if status.Healthy {
+		t.Errorf("status = %+v, want unhealthy at the failure threshold", status)
+	}
+	if policy.Due(&status, now.Add(70*time.Second)) || !policy.Due(&status, now.Add(90*time.Second)) {
+		t.Error("Due() does not follow the interval")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	checked := metav1.Now()
+	specs := []k8splaygroundsv1alpha1.HealthCheckSpec{{Name: "login"}, {Name: "metrics", Severity: SeverityWarning}}
+
+	degraded := []k8splaygroundsv1alpha1.HealthCheckStatus{
+		{Name: "login", Healthy: true, LastCheckedAt: &checked},
+		{Name: "metrics", LastCheckedAt: &checked},
+	}
+	if health := Health(specs, degraded); health != k8splaygroundsv1alpha1.ClusterHealthDegraded {
+		t.Errorf("Health() = %s, want Degraded for a failing warning check", health)
+	}
+	unhealthy := []k8splaygroundsv1alpha1.HealthCheckStatus{{Name: "login", LastCheckedAt: &checked}}
+	if health := Health(specs, unhealthy); health != k8splaygroundsv1alpha1.ClusterHealthUnhealthy {
+		t.Errorf("Health() = %s, want Unhealthy for a failing critical check", health)
+	}
+	if health := Health(specs, []k8splaygroundsv1alpha1.HealthCheckStatus{{Name: "login"}}); health != k8splaygroundsv1alpha1.ClusterHealthHealthy {
+		t.Errorf("Health() = %s, want pending checks ignored", health)
+	}
+
+	if got := Worse(k8splaygroundsv1alpha1.ClusterHealthDegraded, k8splaygroundsv1alpha1.ClusterHealthHealthy); got != k8splaygroundsv1alpha1.ClusterHealthDegraded {
+		t.Errorf("Worse() = %s", got)
+	}
+}
+
+func TestEvaluateCommandCheck(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithInterceptorFuncs(applytest.Funcs()).Build()
+	m := NewManager(c, helperpods.Config{})
+	ctx := context.Background()
+	now := time.Now()
+	cluster := newCluster(k8splaygroundsv1alpha1.HealthCheckSpec{
+		Name:    "migrations",
+		Command: &k8splaygroundsv1alpha1.CommandCheckSpec{Command: []string{"sh", "-c", "exit 1"}},
+	})
+
+	// The first evaluation starts the helper pod and leaves the health unaffected
+	health, err := m.Evaluate(ctx, cluster, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health != k8splaygroundsv1alpha1.ClusterHealthHealthy || cluster.Status.Checks[0].LastCheckedAt != nil {
+		t.Errorf("health = %s, status = %+v, want the check pending", health, cluster.Status.Checks[0])
+	}
+	if after := RequeueAfter(cluster.Status.Checks, now); after != MinRequeue {
+		t.Errorf("RequeueAfter() = %v, want %v while the command runs", after, MinRequeue)
+	}
+
+	key := types.NamespacedName{Name: "shop-check-migrations", Namespace: "playground"}
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, key, pod); err != nil {
+		t.Fatal(err)
+	}
+	if pod.Labels[helperpods.Label] != "true" || *pod.Spec.ActiveDeadlineSeconds != 60 {
+		t.Errorf("pod = %+v, want a helper pod with the command timeout as deadline", pod.ObjectMeta)
+	}
+
+	pod.Status.Phase = corev1.PodFailed
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "check", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1}}}}
+	if err := c.Status().Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	health, err = m.Evaluate(ctx, cluster, now.Add(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health != k8splaygroundsv1alpha1.ClusterHealthUnhealthy || cluster.Status.Checks[0].Message != "command exited with code 1" {
+		t.Errorf("health = %s, status = %+v, want the failed command recorded", health, cluster.Status.Checks[0])
+	}
+	if err := c.Get(ctx, key, pod); err == nil {
+		t.Error("the finished helper pod was not removed")
+	}
+}
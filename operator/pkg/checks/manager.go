@@ -0,0 +1,184 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// DefaultCommandImage runs command checks that do not name an image
+const DefaultCommandImage = "busybox:1.35"
+
+// Manager evaluates the health checks of clusters
+type Manager struct {
+	client     client.Client
+	helperPods helperpods.Config
+}
+
+// NewManager creates a new check manager
+func NewManager(client client.Client, helperPods helperpods.Config) *Manager {
+	return &Manager{
+		client:     client,
+		helperPods: helperPods,
+	}
+}
+
+// Evaluate runs the checks that are due, records their outcome in the cluster status and
+// returns the cluster health they imply. A command check completes on a later evaluation
+// once its helper pod has finished; until then its previous status is kept.
+func (m *Manager) Evaluate(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, now time.Time) (k8splaygroundsv1alpha1.ClusterHealth, error) {
+	previous := make(map[string]k8splaygroundsv1alpha1.HealthCheckStatus, len(cluster.Status.Checks))
+	for _, status := range cluster.Status.Checks {
+		previous[status.Name] = status
+	}
+
+	statuses := make([]k8splaygroundsv1alpha1.HealthCheckStatus, 0, len(cluster.Spec.Checks))
+	for i := range cluster.Spec.Checks {
+		spec := &cluster.Spec.Checks[i]
+		policy := PolicyFor(spec)
+		var last *k8splaygroundsv1alpha1.HealthCheckStatus
+		if status, ok := previous[spec.Name]; ok {
+			last = &status
+		}
+		if !policy.Due(last, now) {
+			statuses = append(statuses, *last)
+			continue
+		}
+
+		var success, done bool
+		var message string
+		if spec.Command != nil {
+			var err error
+			if success, message, done, err = m.runCommand(ctx, cluster, spec, policy); err != nil {
+				return k8splaygroundsv1alpha1.ClusterHealthUnknown, fmt.Errorf("failed to run check %s: %w", spec.Name, err)
+			}
+		} else if target, err := Target(cluster, spec); err != nil {
+			success, message, done = false, err.Error(), true
+		} else {
+			success, message = Probe(ctx, spec, target, policy.Timeout)
+			done = true
+		}
+
+		switch {
+		case done:
+			statuses = append(statuses, policy.Record(spec.Name, last, success, message, now))
+		case last != nil:
+			statuses = append(statuses, *last)
+		default:
+			statuses = append(statuses, k8splaygroundsv1alpha1.HealthCheckStatus{Name: spec.Name, Message: message})
+		}
+	}
+
+	cluster.Status.Checks = statuses
+	return Health(cluster.Spec.Checks, statuses), nil
+}
+
+// PodName returns the name of the helper pod running a command check
+func PodName(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, spec *k8splaygroundsv1alpha1.HealthCheckSpec) string {
+	return naming.Qualified(naming.CheckPod, cluster.Name, spec.Name)
+}
+
+// runCommand advances the helper pod of a command check and reports the outcome once the pod
+// has finished. The pod is created when missing and removed after its outcome is read; the
+// timeout is its active deadline.
+func (m *Manager) runCommand(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, spec *k8splaygroundsv1alpha1.HealthCheckSpec, policy Policy) (bool, string, bool, error) {
+	pod := &corev1.Pod{}
+	err := m.client.Get(ctx, types.NamespacedName{Name: PodName(cluster, spec), Namespace: cluster.Namespace}, pod)
+	if apierrors.IsNotFound(err) {
+		return m.createCommandPod(ctx, cluster, spec, policy)
+	}
+	if err != nil {
+		return false, "", false, err
+	}
+
+	var success bool
+	var message string
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		success, message = true, "command succeeded"
+	case corev1.PodFailed:
+		message = commandFailure(pod)
+	default:
+		return false, "command is running", false, nil
+	}
+	if err := m.client.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+		return false, "", false, err
+	}
+	return success, message, true, nil
+}
+
+// createCommandPod starts the helper pod of a command check, unless the namespace already
+// runs as many helper pods as allowed
+func (m *Manager) createCommandPod(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, spec *k8splaygroundsv1alpha1.HealthCheckSpec, policy Policy) (bool, string, bool, error) {
+	helperPods := m.helperPods.ForCluster()
+	if err := helperPods.Reserve(ctx, m.client, cluster.Namespace); err != nil {
+		if helperpods.IsLimitReached(err) {
+			return false, err.Error(), false, nil
+		}
+		return false, "", false, err
+	}
+
+	image := spec.Command.Image
+	if image == "" {
+		image = DefaultCommandImage
+	}
+	deadline := int64(policy.Timeout / time.Second)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      PodName(cluster, spec),
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":     "health-check",
+				"app.kubernetes.io/instance": cluster.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cluster, k8splaygroundsv1alpha1.SchemeGroupVersion.WithKind("K8sPlaygroundsCluster")),
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:                     "check",
+					Image:                    image,
+					Command:                  spec.Command.Command,
+					TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+				},
+			},
+			RestartPolicy:         corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+		},
+	}
+	helperPods.Apply(&pod.ObjectMeta, &pod.Spec)
+
+	if err := apply.Apply(ctx, m.client, pod); err != nil {
+		return false, "", false, err
+	}
+	return false, "command started", false, nil
+}
+
+// commandFailure describes why the helper pod of a command check failed
+func commandFailure(pod *corev1.Pod) string {
+	for _, container := range pod.Status.ContainerStatuses {
+		if terminated := container.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			if terminated.Message != "" {
+				return fmt.Sprintf("command exited with code %d: %s", terminated.ExitCode, terminated.Message)
+			}
+			return fmt.Sprintf("command exited with code %d", terminated.ExitCode)
+		}
+	}
+	if pod.Status.Reason != "" {
+		return fmt.Sprintf("command failed: %s %s", pod.Status.Reason, pod.Status.Message)
+	}
+	return "command failed"
+}
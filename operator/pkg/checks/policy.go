@@ -0,0 +1,99 @@
+package checks
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Defaults used for check settings a cluster leaves unset
+const (
+	DefaultInterval         = 30 * time.Second
+	DefaultTimeout          = 5 * time.Second
+	DefaultFailureThreshold = 3
+	DefaultSuccessThreshold = 1
+	// DefaultCommandTimeout also covers scheduling the helper pod and pulling its image
+	DefaultCommandTimeout = time.Minute
+)
+
+// Policy is the resolved schedule and thresholds of a check
+type Policy struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int32
+	SuccessThreshold int32
+}
+
+// PolicyFor resolves the schedule and thresholds of a check, filling in defaults
+func PolicyFor(spec *k8splaygroundsv1alpha1.HealthCheckSpec) Policy {
+	policy := Policy{
+		Interval:         DefaultInterval,
+		Timeout:          DefaultTimeout,
+		FailureThreshold: DefaultFailureThreshold,
+		SuccessThreshold: DefaultSuccessThreshold,
+	}
+	if spec.Command != nil {
+		policy.Timeout = DefaultCommandTimeout
+	}
+	if spec.IntervalSeconds > 0 {
+		policy.Interval = time.Duration(spec.IntervalSeconds) * time.Second
+	}
+	if spec.TimeoutSeconds > 0 {
+		policy.Timeout = time.Duration(spec.TimeoutSeconds) * time.Second
+	}
+	if spec.FailureThreshold > 0 {
+		policy.FailureThreshold = spec.FailureThreshold
+	}
+	if spec.SuccessThreshold > 0 {
+		policy.SuccessThreshold = spec.SuccessThreshold
+	}
+	return policy
+}
+
+// Due reports whether the check is due, based on when the previous probe ran
+func (p Policy) Due(previous *k8splaygroundsv1alpha1.HealthCheckStatus, now time.Time) bool {
+	if previous == nil || previous.LastCheckedAt == nil {
+		return true
+	}
+	return !now.Before(previous.LastCheckedAt.Add(p.Interval))
+}
+
+// Record merges the outcome of a probe into the previous status. Healthy only changes once the
+// failure or success threshold is reached; the first probe of a check sets it directly.
+func (p Policy) Record(name string, previous *k8splaygroundsv1alpha1.HealthCheckStatus, success bool, message string, now time.Time) k8splaygroundsv1alpha1.HealthCheckStatus {
+	checked := metav1.NewTime(now)
+	next := metav1.NewTime(now.Add(p.Interval))
+	status := k8splaygroundsv1alpha1.HealthCheckStatus{
+		Name:          name,
+		Success:       success,
+		Message:       message,
+		LastCheckedAt: &checked,
+		NextCheckAt:   &next,
+	}
+
+	if previous == nil || previous.LastCheckedAt == nil {
+		status.Healthy = success
+		if success {
+			status.ConsecutiveSuccesses = 1
+		} else {
+			status.ConsecutiveFailures = 1
+		}
+		return status
+	}
+
+	status.Healthy = previous.Healthy
+	if success {
+		status.ConsecutiveSuccesses = previous.ConsecutiveSuccesses + 1
+		if status.ConsecutiveSuccesses >= p.SuccessThreshold {
+			status.Healthy = true
+		}
+	} else {
+		status.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+		if status.ConsecutiveFailures >= p.FailureThreshold {
+			status.Healthy = false
+		}
+	}
+	return status
+}
@@ -2,6 +2,7 @@ package cloud
 
 import (
 	"aviatrix-operator/pkg/aviatrix"
+	"context"
 	"fmt"
 )
 
@@ -18,58 +19,163 @@ func NewManager(client *aviatrix.Client) *Manager {
 }
 
 // CreateGateway creates a gateway in the cloud
-func (m *Manager) CreateGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
-	return m.client.CreateGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet)
+func (m *Manager) CreateGateway(ctx context.Context, gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
+	return m.client.CreateGateway(ctx, gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet)
 }
 
 // DeleteGateway deletes a gateway from the cloud
-func (m *Manager) DeleteGateway(gwName string) error {
-	return m.client.DeleteGateway(gwName)
+func (m *Manager) DeleteGateway(ctx context.Context, gwName string) error {
+	return m.client.DeleteGateway(ctx, gwName)
 }
 
 // GetGateway retrieves gateway information from the cloud
-func (m *Manager) GetGateway(gwName string) (map[string]interface{}, error) {
-	return m.client.GetGateway(gwName)
+func (m *Manager) GetGateway(ctx context.Context, gwName string) (*aviatrix.GatewayInfo, error) {
+	return m.client.GetGateway(ctx, gwName)
+}
+
+// ListGateways lists the gateways known to the Aviatrix Controller
+func (m *Manager) ListGateways(ctx context.Context) ([]aviatrix.GatewayInfo, error) {
+	return m.client.ListGateways(ctx)
+}
+
+// StopGateway stops a gateway instance in the cloud
+func (m *Manager) StopGateway(ctx context.Context, gwName string) error {
+	return m.client.StopGateway(ctx, gwName)
+}
+
+// StartGateway starts a stopped gateway instance in the cloud
+func (m *Manager) StartGateway(ctx context.Context, gwName string) error {
+	return m.client.StartGateway(ctx, gwName)
+}
+
+// ResizeGateway changes the instance size of a gateway in the cloud
+func (m *Manager) ResizeGateway(ctx context.Context, gwName, gwSize string) error {
+	return m.client.ResizeGateway(ctx, gwName, gwSize)
+}
+
+// UpgradeGateway upgrades a gateway to a software and image version
+func (m *Manager) UpgradeGateway(ctx context.Context, gwName, softwareVersion, imageVersion string) error {
+	return m.client.UpgradeGateway(ctx, gwName, softwareVersion, imageVersion)
+}
+
+// PingFromGateway pings a host from a gateway
+func (m *Manager) PingFromGateway(ctx context.Context, gwName, host string, count int) (string, error) {
+	return m.client.PingFromGateway(ctx, gwName, host, count)
+}
+
+// TracerouteFromGateway traces the route to a host from a gateway
+func (m *Manager) TracerouteFromGateway(ctx context.Context, gwName, host string) (string, error) {
+	return m.client.TracerouteFromGateway(ctx, gwName, host)
+}
+
+// GetTunnelStatus lists the tunnels of a gateway
+func (m *Manager) GetTunnelStatus(ctx context.Context, gwName string) ([]map[string]interface{}, error) {
+	return m.client.GetTunnelStatus(ctx, gwName)
+}
+
+// StartPacketCapture starts a packet capture on a gateway
+func (m *Manager) StartPacketCapture(ctx context.Context, gwName, host, port string, durationSeconds int) error {
+	return m.client.StartPacketCapture(ctx, gwName, host, port, durationSeconds)
+}
+
+// StopPacketCapture stops the packet capture on a gateway
+func (m *Manager) StopPacketCapture(ctx context.Context, gwName string) error {
+	return m.client.StopPacketCapture(ctx, gwName)
+}
+
+// UploadPacketCapture uploads the packet capture of a gateway to a bucket
+func (m *Manager) UploadPacketCapture(ctx context.Context, gwName, bucket, objectKey string) (string, error) {
+	return m.client.UploadPacketCapture(ctx, gwName, bucket, objectKey)
 }
 
 // CreateVpc creates a VPC in the cloud
-func (m *Manager) CreateVpc(name, cloudType, accountName, region, cidr string) error {
-	return m.client.CreateVpc(name, cloudType, accountName, region, cidr)
+func (m *Manager) CreateVpc(ctx context.Context, name, cloudType, accountName, region, cidr string) error {
+	return m.client.CreateVpc(ctx, name, cloudType, accountName, region, cidr)
 }
 
 // DeleteVpc deletes a VPC from the cloud
-func (m *Manager) DeleteVpc(name string) error {
-	return m.client.DeleteVpc(name)
+func (m *Manager) DeleteVpc(ctx context.Context, name string) error {
+	return m.client.DeleteVpc(ctx, name)
 }
 
 // GetVpc retrieves VPC information from the cloud
-func (m *Manager) GetVpc(name string) (map[string]interface{}, error) {
-	return m.client.GetVpc(name)
+func (m *Manager) GetVpc(ctx context.Context, name string) (*aviatrix.VpcInfo, error) {
+	return m.client.GetVpc(ctx, name)
+}
+
+// ListVpcs lists the VPCs known to the Aviatrix Controller
+func (m *Manager) ListVpcs(ctx context.Context) ([]aviatrix.VpcInfo, error) {
+	return m.client.ListVpcs(ctx)
+}
+
+// GetResourceTags retrieves the tags of a gateway or VPC
+func (m *Manager) GetResourceTags(ctx context.Context, resourceType, resourceName string) (map[string]string, error) {
+	return m.client.GetResourceTags(ctx, resourceType, resourceName)
+}
+
+// AddResourceTags adds tags to a gateway or VPC, overwriting existing values
+func (m *Manager) AddResourceTags(ctx context.Context, resourceType, resourceName string, tags map[string]string) error {
+	return m.client.AddResourceTags(ctx, resourceType, resourceName, tags)
+}
+
+// ValidateCloudAccount checks that a cloud account is onboarded to the Aviatrix Controller for
+// the cloud type
+func (m *Manager) ValidateCloudAccount(ctx context.Context, accountName, cloudType string) error {
+	account, err := m.client.GetAccount(ctx, accountName)
+	if err != nil {
+		return err
+	}
+	want, err := aviatrix.CloudTypeID(cloudType)
+	if err != nil {
+		return err
+	}
+	if got := string(account.CloudType); got != want {
+		return fmt.Errorf("account %s is of cloud type %s, not %s", accountName, got, cloudType)
+	}
+	return nil
+}
+
+// CreateAccount onboards a cloud account to the Aviatrix Controller
+func (m *Manager) CreateAccount(ctx context.Context, account aviatrix.Account) error {
+	return m.client.CreateAccount(ctx, account)
+}
+
+// UpdateAccount replaces the settings and credentials of an onboarded cloud account
+func (m *Manager) UpdateAccount(ctx context.Context, account aviatrix.Account) error {
+	return m.client.UpdateAccount(ctx, account)
+}
+
+// DeleteAccount offboards a cloud account from the Aviatrix Controller
+func (m *Manager) DeleteAccount(ctx context.Context, accountName string) error {
+	return m.client.DeleteAccount(ctx, accountName)
+}
+
+// GetAccount retrieves an onboarded cloud account
+func (m *Manager) GetAccount(ctx context.Context, accountName string) (*aviatrix.Account, error) {
+	return m.client.GetAccount(ctx, accountName)
 }
 
-// ValidateCloudAccount validates a cloud account
-func (m *Manager) ValidateCloudAccount(accountName, cloudType string) error {
-	// Implementation for cloud account validation
-	// This would typically involve checking if the account exists and is accessible
-	return fmt.Errorf("cloud account validation not implemented")
+// AuditAccount checks that the Aviatrix Controller can use a cloud account
+func (m *Manager) AuditAccount(ctx context.Context, accountName string) (aviatrix.AccountAudit, error) {
+	return m.client.AuditAccount(ctx, accountName)
 }
 
 // GetCloudRegions retrieves available regions for a cloud account
-func (m *Manager) GetCloudRegions(accountName, cloudType string) ([]string, error) {
+func (m *Manager) GetCloudRegions(ctx context.Context, accountName, cloudType string) ([]string, error) {
 	// Implementation for getting available regions
 	// This would typically involve querying the cloud provider API
 	return nil, fmt.Errorf("get cloud regions not implemented")
 }
 
 // GetCloudVpcs retrieves VPCs for a cloud account
-func (m *Manager) GetCloudVpcs(accountName, cloudType, region string) ([]map[string]interface{}, error) {
+func (m *Manager) GetCloudVpcs(ctx context.Context, accountName, cloudType, region string) ([]map[string]interface{}, error) {
 	// Implementation for getting VPCs
 	// This would typically involve querying the cloud provider API
 	return nil, fmt.Errorf("get cloud VPCs not implemented")
 }
 
 // GetCloudSubnets retrieves subnets for a VPC
-func (m *Manager) GetCloudSubnets(accountName, cloudType, region, vpcID string) ([]map[string]interface{}, error) {
+func (m *Manager) GetCloudSubnets(ctx context.Context, accountName, cloudType, region, vpcID string) ([]map[string]interface{}, error) {
 	// Implementation for getting subnets
 	// This would typically involve querying the cloud provider API
 	return nil, fmt.Errorf("get cloud subnets not implemented")
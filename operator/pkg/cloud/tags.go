@@ -0,0 +1,73 @@
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Resource types understood by the Aviatrix tagging API
+const (
+	TagResourceGateway = "gw"
+	TagResourceVpc     = "vpc"
+)
+
+// PlanTags compares the desired tags with the tags found in the cloud and returns the tags to
+// set and the keys to remove. A tag missing from desired is only removed when the operator owns
+// it: it was applied from the spec before, or its key starts with managedPrefix. Tags users added
+// in the cloud are left alone. An empty managedPrefix matches no keys.
+func PlanTags(desired, actual map[string]string, previouslyApplied []string, managedPrefix string) (map[string]string, []string) {
+	set := make(map[string]string)
+	for key, value := range desired {
+		if current, ok := actual[key]; !ok || current != value {
+			set[key] = value
+		}
+	}
+
+	owned := make(map[string]bool, len(previouslyApplied))
+	for _, key := range previouslyApplied {
+		owned[key] = true
+	}
+
+	var remove []string
+	for key := range actual {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if owned[key] || (managedPrefix != "" && strings.HasPrefix(key, managedPrefix)) {
+			remove = append(remove, key)
+		}
+	}
+	sort.Strings(remove)
+
+	return set, remove
+}
+
+// ReconcileTags converges the tags of a gateway or VPC on the desired tags and returns the keys
+// now applied from the spec, to be passed back as previouslyApplied on the next call
+func (m *Manager) ReconcileTags(ctx context.Context, resourceType, resourceName string, desired map[string]string, previouslyApplied []string, managedPrefix string) ([]string, error) {
+	actual, err := m.client.GetResourceTags(ctx, resourceType, resourceName)
+	if err != nil {
+		return previouslyApplied, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	set, remove := PlanTags(desired, actual, previouslyApplied, managedPrefix)
+	if len(remove) > 0 {
+		if err := m.client.DeleteResourceTags(ctx, resourceType, resourceName, remove); err != nil {
+			return previouslyApplied, fmt.Errorf("failed to remove tags: %w", err)
+		}
+	}
+	if len(set) > 0 {
+		if err := m.client.AddResourceTags(ctx, resourceType, resourceName, set); err != nil {
+			return previouslyApplied, fmt.Errorf("failed to apply tags: %w", err)
+		}
+	}
+
+	applied := make([]string, 0, len(desired))
+	for key := range desired {
+		applied = append(applied, key)
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
@@ -0,0 +1,38 @@
+package cloud
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanTags(t *testing.T) {
+	actual := map[string]string{
+		"env":              "dev",
+		"team":             "network",
+		"owner":            "alice",
+		"k8s-operator-ref": "old",
+		"cost-center":      "1234",
+	}
+	desired := map[string]string{
+		"env":  "prod",
+		"team": "network",
+		"app":  "transit",
+	}
+
+	set, remove := PlanTags(desired, actual, []string{"env", "owner"}, "k8s-operator-")
+
+	if want := map[string]string{"env": "prod", "app": "transit"}; !reflect.DeepEqual(set, want) {
+		t.Errorf("set = %v, want %v", set, want)
+	}
+	// owner was applied from the spec before and the prefixed tag is managed; cost-center was added by a user
+	if want := []string{"k8s-operator-ref", "owner"}; !reflect.DeepEqual(remove, want) {
+		t.Errorf("remove = %v, want %v", remove, want)
+	}
+}
+
+func TestPlanTagsWithoutPrefixKeepsUserTags(t *testing.T) {
+	set, remove := PlanTags(nil, map[string]string{"owner": "alice"}, nil, "")
+	if len(set) != 0 || len(remove) != 0 {
+		t.Errorf("expected no changes, got set %v, remove %v", set, remove)
+	}
+}
@@ -0,0 +1,168 @@
+package cloud
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/schedule"
+)
+
+// gatewaySettings are the settings gateways and transit gateways share
+type gatewaySettings struct {
+	cloudType, gwName, vpcID, vpcRegion, gwSize, subnet string
+	volumeSize                                          int
+	haEnabled                                           bool
+	haSubnet, haZone                                    string
+	peeringHA                                           bool
+	peeringHASubnet                                     string
+	privateOob                                          bool
+	oobManagementSubnet, oobAvailabilityZone            string
+}
+
+// ValidateGateway checks the cloud type, required settings, HA settings and schedule of a
+// gateway, so they are rejected on admission instead of by the Aviatrix Controller
+func ValidateGateway(spec *aviatrixv1alpha1.AviatrixGatewaySpec) field.ErrorList {
+	path := field.NewPath("spec")
+	errs := validateGatewaySettings(gatewaySettings{
+		cloudType: spec.CloudType, gwName: spec.GwName, vpcID: spec.VpcID, vpcRegion: spec.VpcRegion, gwSize: spec.GwSize, subnet: spec.Subnet,
+		volumeSize: spec.VolumeSize,
+		haEnabled:  spec.HAEnabled, haSubnet: spec.HASubnet, haZone: spec.HAZone,
+		peeringHA: spec.EnablePeeringHA, peeringHASubnet: spec.PeeringHASubnet,
+		privateOob: spec.EnablePrivateOob, oobManagementSubnet: spec.OobManagementSubnet, oobAvailabilityZone: spec.OobAvailabilityZone,
+	}, path)
+
+	return append(errs, validateSchedule(spec.Schedule, path.Child("schedule"))...)
+}
+
+// validateSchedule checks the cron expressions and time zone of a gateway schedule
+func validateSchedule(s *aviatrixv1alpha1.GatewaySchedule, path *field.Path) field.ErrorList {
+	if s == nil {
+		return nil
+	}
+	if _, err := schedule.NewWindow(s.StopCron, s.StartCron, s.TimeZone); err != nil {
+		return field.ErrorList{field.Invalid(path, fmt.Sprintf("%s / %s", s.StopCron, s.StartCron), err.Error())}
+	}
+	return nil
+}
+
+// ValidateTransitGateway checks the cloud type, required settings, HA settings, CIDRs and
+// schedule of a transit gateway
+func ValidateTransitGateway(spec *aviatrixv1alpha1.AviatrixTransitGatewaySpec) field.ErrorList {
+	path := field.NewPath("spec")
+	errs := validateGatewaySettings(gatewaySettings{
+		cloudType: spec.CloudType, gwName: spec.GwName, vpcID: spec.VpcID, vpcRegion: spec.VpcRegion, gwSize: spec.GwSize, subnet: spec.Subnet,
+		volumeSize: spec.VolumeSize,
+		haEnabled:  spec.HAEnabled, haSubnet: spec.HASubnet, haZone: spec.HAZone,
+		peeringHA: spec.EnablePeeringHA, peeringHASubnet: spec.PeeringHASubnet,
+		privateOob: spec.EnablePrivateOob, oobManagementSubnet: spec.OobManagementSubnet, oobAvailabilityZone: spec.OobAvailabilityZone,
+	}, path)
+
+	errs = append(errs, validateCidrs(spec.ApprovedLearnedCidrs, path.Child("approvedLearnedCidrs"))...)
+	errs = append(errs, validateCidrs(spec.TransitBgpManualAdvertiseCidrs, path.Child("transitBgpManualAdvertiseCidrs"))...)
+	if _, _, err := net.ParseCIDR(spec.BgpLanCidr); spec.BgpLanCidr != "" && err != nil {
+		errs = append(errs, field.Invalid(path.Child("bgpLanCidr"), spec.BgpLanCidr, "must be a CIDR such as 10.0.0.0/16"))
+	}
+	return append(errs, validateSchedule(spec.Schedule, path.Child("schedule"))...)
+}
+
+// ValidateVpc checks the cloud type, CIDR and subnet layout of a VPC
+func ValidateVpc(spec *aviatrixv1alpha1.AviatrixVpcSpec) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	errs = append(errs, validateCloudType(spec.CloudType, path.Child("cloudType"))...)
+	errs = append(errs, required(path, map[string]string{"accountName": spec.AccountName, "name": spec.Name, "region": spec.Region})...)
+
+	if spec.CIDR == "" {
+		return append(errs, field.Required(path.Child("cidr"), ""))
+	}
+	_, network, err := net.ParseCIDR(spec.CIDR)
+	if err != nil {
+		return append(errs, field.Invalid(path.Child("cidr"), spec.CIDR, "must be a CIDR such as 10.0.0.0/16"))
+	}
+	prefix, bits := network.Mask.Size()
+	if spec.SubnetSize != 0 && (spec.SubnetSize <= prefix || spec.SubnetSize > bits) {
+		errs = append(errs, field.Invalid(path.Child("subnetSize"), spec.SubnetSize, fmt.Sprintf("must be a prefix length longer than /%d and at most /%d", prefix, bits)))
+	}
+	if spec.NumOfSubnetPairs < 0 {
+		errs = append(errs, field.Invalid(path.Child("numOfSubnetPairs"), spec.NumOfSubnetPairs, "must not be negative"))
+	} else if spec.SubnetSize > prefix && spec.SubnetSize <= bits && spec.SubnetSize-prefix < 31 {
+		// Every pair is a public and a private subnet
+		if available := 1 << (spec.SubnetSize - prefix); 2*spec.NumOfSubnetPairs > available {
+			errs = append(errs, field.Invalid(path.Child("numOfSubnetPairs"), spec.NumOfSubnetPairs, fmt.Sprintf("%s holds only %d subnets of size /%d", spec.CIDR, available, spec.SubnetSize)))
+		}
+	}
+	return errs
+}
+
+func validateGatewaySettings(s gatewaySettings, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	errs = append(errs, validateCloudType(s.cloudType, path.Child("cloudType"))...)
+	errs = append(errs, required(path, map[string]string{"gwName": s.gwName, "vpcId": s.vpcID, "vpcRegion": s.vpcRegion, "gwSize": s.gwSize, "subnet": s.subnet})...)
+	if s.volumeSize < 0 {
+		errs = append(errs, field.Invalid(path.Child("volumeSize"), s.volumeSize, "must not be negative"))
+	}
+
+	// GCP places the HA gateway by zone, the other clouds by subnet
+	if s.haEnabled {
+		if strings.EqualFold(s.cloudType, "gcp") || s.cloudType == "4" {
+			if s.haZone == "" {
+				errs = append(errs, field.Required(path.Child("haZone"), "required when haEnabled is set on GCP"))
+			}
+		} else if s.haSubnet == "" {
+			errs = append(errs, field.Required(path.Child("haSubnet"), "required when haEnabled is set"))
+		}
+		if s.haSubnet != "" && s.haSubnet == s.subnet {
+			errs = append(errs, field.Invalid(path.Child("haSubnet"), s.haSubnet, "must differ from subnet, so the HA gateway survives the loss of the primary's zone"))
+		}
+	}
+	if s.peeringHA && s.peeringHASubnet == "" {
+		errs = append(errs, field.Required(path.Child("peeringHASubnet"), "required when enablePeeringHA is set"))
+	}
+	if s.privateOob {
+		errs = append(errs, required(path, map[string]string{"oobManagementSubnet": s.oobManagementSubnet, "oobAvailabilityZone": s.oobAvailabilityZone})...)
+	}
+	return errs
+}
+
+func validateCloudType(cloudType string, path *field.Path) field.ErrorList {
+	if cloudType == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	if _, err := aviatrix.CloudTypeID(cloudType); err != nil {
+		return field.ErrorList{field.NotSupported(path, cloudType, []string{"aws", "azure", "gcp", "oci"})}
+	}
+	return nil
+}
+
+// required reports the empty settings among values, keyed by field name, in a stable order
+func required(path *field.Path, values map[string]string) field.ErrorList {
+	var names []string
+	for name, value := range values {
+		if value == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var errs field.ErrorList
+	for _, name := range names {
+		errs = append(errs, field.Required(path.Child(name), ""))
+	}
+	return errs
+}
+
+func validateCidrs(cidrs []string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			errs = append(errs, field.Invalid(path.Index(i), cidr, "must be a CIDR such as 10.0.0.0/16"))
+		}
+	}
+	return errs
+}
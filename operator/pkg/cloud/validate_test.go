@@ -0,0 +1,72 @@
+package cloud
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func fieldsOf(errs field.ErrorList) string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	return strings.Join(fields, ",")
+}
+
+func TestValidateGateway(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixGatewaySpec{
+		CloudType: "aws", AccountName: "aws-account", GwName: "gw", VpcID: "vpc-1", VpcRegion: "us-west-2", GwSize: "t3.small", Subnet: "10.0.1.0/24",
+		HAEnabled: true, HASubnet: "10.0.2.0/24",
+	}
+	if errs := ValidateGateway(spec); len(errs) > 0 {
+		t.Fatalf("ValidateGateway() = %v, want no errors", errs)
+	}
+
+	spec.CloudType = "vmware"
+	spec.HASubnet = ""
+	spec.GwSize = ""
+	spec.Schedule = &aviatrixv1alpha1.GatewaySchedule{StopCron: "0 20 * * *", StartCron: "every morning"}
+	if got, want := fieldsOf(ValidateGateway(spec)), "spec.cloudType,spec.gwSize,spec.haSubnet,spec.schedule"; got != want {
+		t.Errorf("ValidateGateway() rejected %s, want %s", got, want)
+	}
+
+	// GCP places the HA gateway by zone
+	spec = &aviatrixv1alpha1.AviatrixGatewaySpec{CloudType: "gcp", GwName: "gw", VpcID: "vpc", VpcRegion: "us-west1", GwSize: "n1-standard-1", Subnet: "10.0.1.0/24", HAEnabled: true}
+	if got, want := fieldsOf(ValidateGateway(spec)), "spec.haZone"; got != want {
+		t.Errorf("ValidateGateway() on GCP rejected %s, want %s", got, want)
+	}
+}
+
+func TestValidateTransitGateway(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixTransitGatewaySpec{
+		CloudType: "azure", GwName: "transit", VpcID: "vnet", VpcRegion: "westus", GwSize: "Standard_B2ms", Subnet: "10.0.1.0/24",
+		HAEnabled: true, HASubnet: "10.0.1.0/24",
+		ApprovedLearnedCidrs: []string{"10.1.0.0/16", "10.2.0.0"},
+		BgpLanCidr:           "10.100.0.0/24",
+		Schedule:             &aviatrixv1alpha1.GatewaySchedule{StopCron: "0 20 * * 1-5", StartCron: "0 7 * * 1-5", TimeZone: "Mars/Olympus"},
+	}
+	if got, want := fieldsOf(ValidateTransitGateway(spec)), "spec.haSubnet,spec.approvedLearnedCidrs[1],spec.schedule"; got != want {
+		t.Errorf("ValidateTransitGateway() rejected %s, want %s", got, want)
+	}
+}
+
+func TestValidateVpc(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixVpcSpec{CloudType: "aws", AccountName: "aws-account", Name: "vpc", Region: "us-west-2", CIDR: "10.0.0.0/24", SubnetSize: 26, NumOfSubnetPairs: 2}
+	if errs := ValidateVpc(spec); len(errs) > 0 {
+		t.Fatalf("ValidateVpc() = %v, want no errors", errs)
+	}
+
+	spec.NumOfSubnetPairs = 3
+	if got, want := fieldsOf(ValidateVpc(spec)), "spec.numOfSubnetPairs"; got != want {
+		t.Errorf("ValidateVpc() with too many subnets rejected %s, want %s", got, want)
+	}
+
+	spec.CIDR = "10.0.0.0/33"
+	if got, want := fieldsOf(ValidateVpc(spec)), "spec.cidr"; got != want {
+		t.Errorf("ValidateVpc() with a bad CIDR rejected %s, want %s", got, want)
+	}
+}
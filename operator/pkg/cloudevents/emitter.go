@@ -0,0 +1,103 @@
+package cloudevents
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultBufferSize is how many events wait for delivery before new ones are dropped
+	DefaultBufferSize = 256
+	// DefaultAttempts is how often delivery of an event is tried
+	DefaultAttempts = 3
+	// sendTimeout bounds a single delivery attempt
+	sendTimeout = 10 * time.Second
+)
+
+// Emitter delivers events to a sink in the background, so reconciles never wait for the
+// external system. A nil Emitter drops events, so reconcilers can call it unconditionally.
+type Emitter struct {
+	mu      sync.RWMutex
+	sink    Sink
+	source  string
+	queue   chan Event
+	backoff time.Duration
+}
+
+// NewEmitter creates an emitter sending events from source to sink. Add it to the manager
+// so it runs while the operator does. Events are dropped while the sink is nil.
+func NewEmitter(sink Sink, source string) *Emitter {
+	return &Emitter{
+		sink:    sink,
+		source:  source,
+		queue:   make(chan Event, DefaultBufferSize),
+		backoff: time.Second,
+	}
+}
+
+// Emit queues an event for delivery. Events are dropped when the buffer is full.
+func (e *Emitter) Emit(ctx context.Context, event Event) {
+	if e == nil {
+		return
+	}
+	event.Source = e.source
+	select {
+	case e.queue <- event:
+	default:
+		ctrl.LoggerFrom(ctx).Info("event buffer full, dropping event", "type", event.Type, "subject", event.Subject)
+	}
+}
+
+// SetSink changes the sink events are delivered to; events queued before are delivered to the
+// new sink. A nil sink drops events.
+func (e *Emitter) SetSink(sink Sink) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sink = sink
+}
+
+// Start delivers queued events until ctx is cancelled
+func (e *Emitter) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("cloudevents")
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-e.queue:
+			if err := e.deliver(ctx, event); err != nil {
+				log.Error(err, "failed to deliver event", "type", event.Type, "subject", event.Subject, "id", event.ID)
+			}
+		}
+	}
+}
+
+// deliver sends an event, retrying with exponential backoff
+func (e *Emitter) deliver(ctx context.Context, event Event) error {
+	e.mu.RLock()
+	sink := e.sink
+	e.mu.RUnlock()
+	if sink == nil {
+		return nil
+	}
+
+	backoff := e.backoff
+	var err error
+	for attempt := 1; attempt <= DefaultAttempts; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err = sink.Send(sendCtx, event)
+		cancel()
+		if err == nil || attempt == DefaultAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return err
+}
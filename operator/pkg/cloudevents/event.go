@@ -0,0 +1,91 @@
+package cloudevents
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// SpecVersion is the CloudEvents specification version of the emitted events
+const SpecVersion = "1.0"
+
+// ContentType is the media type of events sent in structured mode
+const ContentType = "application/cloudevents+json"
+
+// Types of the lifecycle events emitted by the operator
+const (
+	// TypeClusterReady is emitted when a playground cluster becomes Running
+	TypeClusterReady = "io.k8s-playgrounds.cluster.ready"
+	// TypeClusterTrashed is emitted when a deleted playground cluster enters the Trash phase
+	TypeClusterTrashed = "io.k8s-playgrounds.cluster.trashed"
+	// TypeClusterRestored is emitted when a playground cluster in the Trash phase is undeleted
+	TypeClusterRestored = "io.k8s-playgrounds.cluster.restored"
+	// TypeClusterPurged is emitted when the resources of a deleted playground cluster have been
+	// destroyed after its retention expired
+	TypeClusterPurged = "io.k8s-playgrounds.cluster.purged"
+	// TypeBackupCompleted is emitted when a playground cluster backup completes
+	TypeBackupCompleted = "io.k8s-playgrounds.cluster.backup.completed"
+	// TypeRulesDriftDetected is emitted when a headless service turns Degraded because nodes
+	// diverged from the desired iptables rules
+	TypeRulesDriftDetected = "io.k8s-playgrounds.headlessservice.drift.detected"
+	// TypeRulesCanaryFailed is emitted when a headless service turns Degraded because the canary
+	// node rejected new iptables rules
+	TypeRulesCanaryFailed = "io.k8s-playgrounds.headlessservice.canary.failed"
+	// TypeGatewayDeleted is emitted when an AviatrixGateway is deleted
+	TypeGatewayDeleted = "io.k8s-playgrounds.gateway.deleted"
+	// TypeGatewayDriftDetected is emitted when a gateway starts drifting from its spec
+	TypeGatewayDriftDetected = "io.k8s-playgrounds.gateway.drift.detected"
+	// TypeOrphanDetected is emitted when a gateway or VPC created by the operator is found that
+	// no custom resource tracks
+	TypeOrphanDetected = "io.k8s-playgrounds.orphan.detected"
+)
+
+// Event is a CloudEvent in the JSON structured format
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// Data describes the object an event is about
+type Data struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Reason is a short machine-readable explanation, e.g. the phase reached
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable explanation
+	Message string `json:"message,omitempty"`
+	// Details carries event specific values, e.g. the drifted fields
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// New creates an event about the object described by data. The subject is the namespaced
+// name of the object and the source is filled in by the Emitter.
+func New(eventType string, data Data) Event {
+	payload, _ := json.Marshal(data)
+	return Event{
+		SpecVersion:     SpecVersion,
+		ID:              newID(),
+		Type:            eventType,
+		Subject:         data.Namespace + "/" + data.Name,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            payload,
+	}
+}
+
+// newID returns a random event ID; sinks use it to drop retried duplicates
+func newID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(id)
+}
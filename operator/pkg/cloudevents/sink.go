@@ -0,0 +1,161 @@
+package cloudevents
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultNATSSubject is the subject events are published on when the NATS URL has no path
+const DefaultNATSSubject = "k8s-playgrounds.events"
+
+// Sink delivers events to an external system
+type Sink interface {
+	Send(ctx context.Context, event Event) error
+}
+
+// NewSink creates the sink for a URL: http and https URLs receive events as HTTP POSTs, and
+// nats://host:port/subject publishes them on a NATS subject
+func NewSink(rawURL string) (Sink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid event sink URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &HTTPSink{URL: rawURL, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case "nats":
+		subject := strings.TrimPrefix(u.Path, "/")
+		if subject == "" {
+			subject = DefaultNATSSubject
+		}
+		host := u.Host
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "4222")
+		}
+		sink := &NATSSink{Address: host, Subject: subject}
+		if u.User != nil {
+			sink.User = u.User.Username()
+			sink.Password, _ = u.User.Password()
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unsupported event sink scheme %q, want http, https or nats", u.Scheme)
+	}
+}
+
+// HTTPSink POSTs events in structured mode
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the event and fails on any non-2xx response
+func (s *HTTPSink) Send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("event sink returned %s", resp.Status)
+	}
+	return nil
+}
+
+// NATSSink publishes events on a NATS subject. It speaks the plain-text NATS client protocol
+// over a short-lived connection per event.
+type NATSSink struct {
+	Address  string
+	Subject  string
+	User     string
+	Password string
+}
+
+// Send publishes the event and waits for the server to confirm it processed the publish
+func (s *NATSSink) Send(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	reader := bufio.NewReader(conn)
+	info, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read NATS server info: %w", err)
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		return fmt.Errorf("unexpected NATS greeting %q", strings.TrimSpace(info))
+	}
+
+	options := map[string]interface{}{"verbose": false, "pedantic": false, "name": "k8s-playgrounds-operator"}
+	if s.User != "" {
+		options["user"] = s.User
+		options["pass"] = s.Password
+	}
+	connect, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+
+	var request bytes.Buffer
+	fmt.Fprintf(&request, "CONNECT %s\r\n", connect)
+	fmt.Fprintf(&request, "PUB %s %d\r\n", s.Subject, len(payload))
+	request.Write(payload)
+	request.WriteString("\r\nPING\r\n")
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return err
+	}
+
+	// The PONG answering our PING follows the processing of CONNECT and PUB
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to read NATS reply: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server rejected the event: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package clusterdomain
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// Fallback is the cluster domain used when none is configured or detected
+	Fallback = "cluster.local"
+
+	// ResolvConfPath is the resolver configuration the kubelet writes into every pod
+	ResolvConfPath = "/etc/resolv.conf"
+)
+
+// Sources of the cluster domain, as logged at startup
+const (
+	SourceFlag          = "flag"
+	SourceKubeletConfig = "kubelet-config"
+	SourceResolvConf    = "resolv.conf"
+	SourceFallback      = "fallback"
+)
+
+var (
+	mu      sync.RWMutex
+	current = Fallback
+)
+
+// Default returns the cluster domain of the running operator. Resources that set their own
+// cluster domain use it instead.
+func Default() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetDefault sets the cluster domain of the running operator
+func SetDefault(domain string) error {
+	domain = strings.TrimSuffix(domain, ".")
+	if errs := validation.IsDNS1123Subdomain(domain); len(errs) > 0 {
+		return fmt.Errorf("invalid cluster domain %q: %s", domain, strings.Join(errs, ", "))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = domain
+	return nil
+}
+
+// Or returns override, e.g. the cluster domain of a resource, or the default when it is empty
+func Or(override string) string {
+	if override != "" {
+		return strings.TrimSuffix(override, ".")
+	}
+	return Default()
+}
+
+// Detect finds the cluster domain from the kubelet configuration file, if given, and then from
+// the search path of resolvConf. It returns the domain with where it was found, and Fallback
+// when neither holds one.
+func Detect(kubeletConfig, resolvConf string) (string, string, error) {
+	if kubeletConfig != "" {
+		data, err := os.ReadFile(kubeletConfig)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to read kubelet config: %w", err)
+		}
+		domain, err := FromKubeletConfig(data)
+		if err != nil {
+			return "", "", err
+		}
+		if domain != "" {
+			return domain, SourceKubeletConfig, nil
+		}
+	}
+
+	// Outside a pod there is no cluster search path, so a missing file is not an error
+	if data, err := os.ReadFile(resolvConf); err == nil {
+		if domain := FromResolvConf(data); domain != "" {
+			return domain, SourceResolvConf, nil
+		}
+	}
+	return Fallback, SourceFallback, nil
+}
+
+// FromKubeletConfig returns the clusterDomain of a KubeletConfiguration
+func FromKubeletConfig(data []byte) (string, error) {
+	config := struct {
+		ClusterDomain string `json:"clusterDomain"`
+	}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return "", fmt.Errorf("failed to parse kubelet config: %w", err)
+	}
+	return strings.TrimSuffix(config.ClusterDomain, "."), nil
+}
+
+// FromResolvConf returns the cluster domain from the search path of a pod, which the kubelet
+// sets to <namespace>.svc.<domain> svc.<domain> <domain>
+func FromResolvConf(data []byte) string {
+	var search []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// The last search line wins, as in the resolver
+		if len(fields) > 1 && fields[0] == "search" {
+			search = fields[1:]
+		}
+	}
+	for _, name := range search {
+		if strings.HasPrefix(name, "svc.") && len(name) > len("svc.") {
+			return strings.TrimSuffix(strings.TrimPrefix(name, "svc."), ".")
+		}
+	}
+	return ""
+}
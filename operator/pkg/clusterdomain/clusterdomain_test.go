@@ -0,0 +1,67 @@
+package clusterdomain
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFromResolvConf(t *testing.T) {
+	for _, tt := range []struct {
+		resolvConf string
+		want       string
+	}{
+		{"nameserver 10.96.0.10\nsearch shop.svc.corp.example svc.corp.example corp.example\noptions ndots:5\n", "corp.example"},
+		{"search default.svc.cluster.local. svc.cluster.local. cluster.local.\n", "cluster.local"},
+		// Outside a pod the search path holds no cluster domain
+		{"nameserver 1.1.1.1\nsearch example.com\n", ""},
+		{"search svc.old.example\nsearch web.svc.new.example svc.new.example\n", "new.example"},
+	} {
+		if got := FromResolvConf([]byte(tt.resolvConf)); got != tt.want {
+			t.Errorf("FromResolvConf(%q) = %q, want %q", tt.resolvConf, got, tt.want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	dir := t.TempDir()
+	resolvConf := filepath.Join(dir, "resolv.conf")
+	kubeletConfig := filepath.Join(dir, "kubelet.yaml")
+	if err := os.WriteFile(resolvConf, []byte("search svc.resolv.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kubeletConfig, []byte("apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nclusterDomain: kubelet.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		kubeletConfig, resolvConf string
+		domain, source            string
+	}{
+		{kubeletConfig, resolvConf, "kubelet.example", SourceKubeletConfig},
+		{"", resolvConf, "resolv.example", SourceResolvConf},
+		{"", filepath.Join(dir, "missing"), Fallback, SourceFallback},
+	} {
+		domain, source, err := Detect(tt.kubeletConfig, tt.resolvConf)
+		if err != nil || domain != tt.domain || source != tt.source {
+			t.Errorf("Detect(%q, %q) = %q, %q, %v, want %q from %s", tt.kubeletConfig, tt.resolvConf, domain, source, err, tt.domain, tt.source)
+		}
+	}
+	if _, _, err := Detect(filepath.Join(dir, "missing"), resolvConf); err == nil {
+		t.Error("Detect() ignored a missing kubelet config")
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	defer SetDefault(Fallback)
+
+	if err := SetDefault("corp.example."); err != nil {
+		t.Fatalf("SetDefault() error = %v", err)
+	}
+	if Or("") != "corp.example" || Or("edge.example") != "edge.example" {
+		t.Errorf("Or() = %q, %q", Or(""), Or("edge.example"))
+	}
+	if err := SetDefault("Corp_Example"); err == nil {
+		t.Error("SetDefault() accepted an invalid domain")
+	}
+}
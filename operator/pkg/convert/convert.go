@@ -0,0 +1,302 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/capture"
+)
+
+// Reasons a manifest or field is reported as unsupported
+const (
+	ReasonField  = "field is not supported"
+	ReasonKind   = "kind is not supported"
+	ReasonSecret = "Secrets are not converted; their data must not end up in a reusable definition"
+)
+
+// Unsupported is a manifest, or a field of one, that has no equivalent in the cluster spec
+type Unsupported struct {
+	Kind string
+	Name string
+	// Field is the path of the field in the manifest, e.g. spec.template.spec.initContainers;
+	// empty when the whole manifest was left out
+	Field  string
+	Reason string
+}
+
+func (u Unsupported) String() string {
+	if u.Field == "" {
+		return fmt.Sprintf("%s %s: %s", u.Kind, u.Name, u.Reason)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", u.Kind, u.Name, u.Field, u.Reason)
+}
+
+// Result holds the converted spec sections and what could not be converted
+type Result struct {
+	Spec        k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec
+	Unsupported []Unsupported
+}
+
+// manifest is the part every Kubernetes manifest shares
+type manifest struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Items []json.RawMessage `json:"items"`
+}
+
+// Convert reads Deployment, StatefulSet, DaemonSet, Job, Service and ConfigMap manifests from
+// multi-document YAML or JSON, including List objects such as kubectl get -o yaml output, and
+// converts them into the sections of a K8sPlaygroundsClusterSpec the same way namespace
+// capture does. Fields the spec cannot express are returned as unsupported instead of being
+// dropped silently.
+func Convert(r io.Reader) (*Result, error) {
+	result := &Result{
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Version:  "latest",
+			Replicas: 1,
+		},
+	}
+	decoder := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return result, nil
+			}
+			return nil, fmt.Errorf("failed to read manifests: %w", err)
+		}
+		raw = bytes.TrimSpace(raw)
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		if err := result.add(raw); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// add converts one manifest into the spec
+func (r *Result) add(raw []byte) error {
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if strings.HasSuffix(m.Kind, "List") {
+		for _, item := range m.Items {
+			if err := r.add(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	spec := &r.Spec
+	var err error
+	switch m.Kind {
+	case "Deployment":
+		var d appsv1.Deployment
+		if err = json.Unmarshal(raw, &d); err == nil {
+			item := capture.FromDeployment(d)
+			item.Namespace = d.Namespace
+			spec.Deployments = append(spec.Deployments, item)
+		}
+	case "StatefulSet":
+		var s appsv1.StatefulSet
+		if err = json.Unmarshal(raw, &s); err == nil {
+			item := capture.FromStatefulSet(s)
+			item.Namespace = s.Namespace
+			spec.StatefulSets = append(spec.StatefulSets, item)
+		}
+	case "DaemonSet":
+		var d appsv1.DaemonSet
+		if err = json.Unmarshal(raw, &d); err == nil {
+			item := capture.FromDaemonSet(d)
+			item.Namespace = d.Namespace
+			spec.DaemonSets = append(spec.DaemonSets, item)
+		}
+	case "Job":
+		var j batchv1.Job
+		if err = json.Unmarshal(raw, &j); err == nil {
+			item := capture.FromJob(j)
+			item.Namespace = j.Namespace
+			spec.Jobs = append(spec.Jobs, item)
+		}
+	case "Service":
+		var s corev1.Service
+		if err = json.Unmarshal(raw, &s); err == nil {
+			if capture.IsHeadless(s) {
+				item := capture.FromHeadlessService(s)
+				item.Namespace = s.Namespace
+				spec.HeadlessServices = append(spec.HeadlessServices, item)
+			} else {
+				item := capture.FromService(s)
+				item.Namespace = s.Namespace
+				spec.Services = append(spec.Services, item)
+			}
+		}
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+		if err = json.Unmarshal(raw, &cm); err == nil {
+			item := capture.FromConfigMap(cm)
+			item.Namespace = cm.Namespace
+			spec.ConfigMaps = append(spec.ConfigMaps, item)
+		}
+	case "Secret":
+		r.Unsupported = append(r.Unsupported, Unsupported{Kind: m.Kind, Name: m.Metadata.Name, Reason: ReasonSecret})
+		return nil
+	default:
+		r.Unsupported = append(r.Unsupported, Unsupported{Kind: m.Kind, Name: m.Metadata.Name, Reason: ReasonKind})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s %s: %w", m.Kind, m.Metadata.Name, err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return fmt.Errorf("failed to read %s %s: %w", m.Kind, m.Metadata.Name, err)
+	}
+	for _, field := range unsupportedFields(m.Kind, fields) {
+		r.Unsupported = append(r.Unsupported, Unsupported{Kind: m.Kind, Name: m.Metadata.Name, Field: field, Reason: ReasonField})
+	}
+	return nil
+}
+
+// unsupportedFields walks a manifest and returns the paths of the set fields that the
+// conversion of its kind does not carry over. A field is reported once, at the outermost
+// level the spec has no place for.
+func unsupportedFields(kind string, fields map[string]interface{}) []string {
+	w := &walker{supported: patterns[kind]}
+	w.walk(fields, nil, "")
+	return w.unsupported
+}
+
+// walker finds the fields of a manifest not matched by the supported patterns
+type walker struct {
+	supported   [][]string
+	unsupported []string
+}
+
+// walk visits node at path; segments holds the map keys along the path with list indexes
+// replaced by "[]", display the path as reported
+func (w *walker) walk(node interface{}, segments []string, display string) {
+	if isEmpty(node) || ignoredValue(segments, node) {
+		return
+	}
+	covered, partial := w.match(segments)
+	if covered {
+		return
+	}
+	if partial || defaultsBelow(segments) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(node))
+			for key := range node {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				child := key
+				if display != "" {
+					child = display + "." + key
+				}
+				w.walk(node[key], append(append([]string(nil), segments...), key), child)
+			}
+			return
+		case []interface{}:
+			for i, element := range node {
+				w.walk(element, append(append([]string(nil), segments...), "[]"), fmt.Sprintf("%s[%d]", display, i))
+			}
+			return
+		}
+	}
+	w.unsupported = append(w.unsupported, display)
+}
+
+// match reports whether a pattern covers segments and its whole subtree, and whether a
+// pattern covers a field below segments
+func (w *walker) match(segments []string) (covered, partial bool) {
+	for _, pattern := range w.supported {
+		n := len(segments)
+		if len(pattern) < n {
+			n = len(pattern)
+		}
+		matches := true
+		for i := 0; i < n; i++ {
+			if pattern[i] != segments[i] {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		if len(pattern) <= len(segments) {
+			return true, false
+		}
+		partial = true
+	}
+	return false, partial
+}
+
+// isEmpty reports whether a value is unset, so leaving it out changes nothing
+func isEmpty(node interface{}) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case string:
+		return node == ""
+	case bool:
+		return !node
+	case float64:
+		return node == 0
+	case map[string]interface{}:
+		return len(node) == 0
+	case []interface{}:
+		return len(node) == 0
+	}
+	return false
+}
+
+// defaultsBelow reports whether a field has defaulted fields below it, which are only reported
+// when they hold other values
+func defaultsBelow(segments []string) bool {
+	prefix := strings.Join(segments, ".") + "."
+	for field := range defaults {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredValue reports whether a field holds the value the API server defaults it to, as in
+// manifests exported with kubectl get, or a value the conversion expresses otherwise
+func ignoredValue(segments []string, node interface{}) bool {
+	values, ok := defaults[strings.Join(segments, ".")]
+	if !ok {
+		return false
+	}
+	value := fmt.Sprintf("%v", node)
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
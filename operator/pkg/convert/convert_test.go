@@ -0,0 +1,146 @@
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const manifests = `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+  labels:
+    app: web
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+spec:
+  replicas: 2
+  revisionHistoryLimit: 10
+  selector:
+    matchLabels:
+      app: web
+  strategy:
+    type: RollingUpdate
+    rollingUpdate:
+      maxSurge: 25%
+      maxUnavailable: 25%
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      dnsPolicy: ClusterFirst
+      serviceAccountName: web
+      initContainers:
+      - name: migrate
+        image: web:1.0
+      containers:
+      - name: web
+        image: web:1.0
+        ports:
+        - containerPort: 8080
+        readinessProbe:
+          httpGet:
+            path: /healthz
+            port: 8080
+        startupProbe:
+          tcpSocket:
+            port: 8080
+        securityContext:
+          runAsNonRoot: true
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web-headless
+spec:
+  clusterIP: None
+  selector:
+    app: web
+  ports:
+  - port: 80
+    targetPort: 8080
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: web-credentials
+stringData:
+  password: hunter2
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: settings
+  data:
+    mode: production
+- apiVersion: networking.k8s.io/v1
+  kind: Ingress
+  metadata:
+    name: web
+`
+
+func TestConvert(t *testing.T) {
+	result, err := Convert(strings.NewReader(manifests))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spec := result.Spec
+	if len(spec.Deployments) != 1 || len(spec.HeadlessServices) != 1 || len(spec.ConfigMaps) != 1 || len(spec.Services) != 0 {
+		t.Fatalf("spec = %+v, want a deployment, a headless service and a config map", spec)
+	}
+	deployment := spec.Deployments[0]
+	if deployment.Name != "web" || deployment.Namespace != "shop" || deployment.Replicas != 2 || deployment.Strategy != "RollingUpdate" {
+		t.Errorf("deployment = %+v", deployment)
+	}
+	if _, ok := deployment.Annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		t.Error("last-applied-configuration was converted")
+	}
+	container := deployment.Template.Spec.Containers[0]
+	if container.ReadinessProbe == nil || container.ReadinessProbe.HTTPGet == nil || container.ReadinessProbe.HTTPGet.Path != "/healthz" {
+		t.Errorf("readiness probe = %+v", container.ReadinessProbe)
+	}
+	if spec.ConfigMaps[0].Data["mode"] != "production" {
+		t.Errorf("config map = %+v", spec.ConfigMaps[0])
+	}
+
+	var got []string
+	for _, u := range result.Unsupported {
+		got = append(got, u.String())
+	}
+	want := []string{
+		"Deployment web: spec.template.spec.containers[0].securityContext: " + ReasonField,
+		"Deployment web: spec.template.spec.containers[0].startupProbe: " + ReasonField,
+		"Deployment web: spec.template.spec.initContainers: " + ReasonField,
+		"Deployment web: spec.template.spec.serviceAccountName: " + ReasonField,
+		"Secret web-credentials: " + ReasonSecret,
+		"Ingress web: " + ReasonKind,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unsupported =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestConvertFlagsChangedDefaults(t *testing.T) {
+	result, err := Convert(strings.NewReader(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"},"spec":{"clusterIP":"10.0.0.12","sessionAffinity":"ClientIP","ports":[{"port":80}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Spec.Services) != 1 {
+		t.Fatalf("services = %+v", result.Spec.Services)
+	}
+	var fields []string
+	for _, u := range result.Unsupported {
+		fields = append(fields, u.Field)
+	}
+	if want := []string{"spec.clusterIP", "spec.sessionAffinity"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("unsupported fields = %v, want %v", fields, want)
+	}
+}
@@ -0,0 +1,191 @@
+package convert
+
+import "strings"
+
+// metadataFields are converted, or written by the API server and meaningless in a spec
+var metadataFields = []string{
+	"apiVersion",
+	"kind",
+	"status",
+	"metadata.name",
+	"metadata.namespace",
+	"metadata.labels",
+	"metadata.annotations",
+	"metadata.uid",
+	"metadata.resourceVersion",
+	"metadata.generation",
+	"metadata.creationTimestamp",
+	"metadata.managedFields",
+	"metadata.selfLink",
+}
+
+// podTemplateFields are the fields of a pod template the conversion carries over, relative to
+// the template
+var podTemplateFields = []string{
+	"metadata.labels",
+	"metadata.annotations",
+	"metadata.creationTimestamp",
+	"spec.restartPolicy",
+	"spec.nodeSelector",
+	"spec.priorityClassName",
+	"spec.runtimeClassName",
+	"spec.schedulerName",
+	"spec.tolerations",
+	"spec.affinity",
+	"spec.securityContext.runAsUser",
+	"spec.securityContext.runAsGroup",
+	"spec.securityContext.runAsNonRoot",
+	"spec.securityContext.fsGroup",
+	"spec.volumes[].name",
+	"spec.volumes[].emptyDir",
+	"spec.volumes[].hostPath",
+	"spec.volumes[].persistentVolumeClaim",
+	"spec.volumes[].configMap",
+	"spec.volumes[].secret",
+	"spec.containers[].name",
+	"spec.containers[].image",
+	"spec.containers[].imagePullPolicy",
+	"spec.containers[].command",
+	"spec.containers[].args",
+	"spec.containers[].ports[].name",
+	"spec.containers[].ports[].containerPort",
+	"spec.containers[].ports[].protocol",
+	"spec.containers[].ports[].hostPort",
+	"spec.containers[].env[].name",
+	"spec.containers[].env[].value",
+	"spec.containers[].env[].valueFrom.fieldRef",
+	"spec.containers[].env[].valueFrom.configMapKeyRef.name",
+	"spec.containers[].env[].valueFrom.configMapKeyRef.key",
+	"spec.containers[].env[].valueFrom.secretKeyRef.name",
+	"spec.containers[].env[].valueFrom.secretKeyRef.key",
+	"spec.containers[].resources.limits",
+	"spec.containers[].resources.requests",
+	"spec.containers[].volumeMounts[].name",
+	"spec.containers[].volumeMounts[].mountPath",
+	"spec.containers[].volumeMounts[].readOnly",
+	"spec.containers[].volumeMounts[].subPath",
+	"spec.containers[].livenessProbe.httpGet",
+	"spec.containers[].livenessProbe.tcpSocket",
+	"spec.containers[].livenessProbe.exec",
+	"spec.containers[].livenessProbe.initialDelaySeconds",
+	"spec.containers[].livenessProbe.timeoutSeconds",
+	"spec.containers[].livenessProbe.periodSeconds",
+	"spec.containers[].livenessProbe.successThreshold",
+	"spec.containers[].livenessProbe.failureThreshold",
+	"spec.containers[].readinessProbe.httpGet",
+	"spec.containers[].readinessProbe.tcpSocket",
+	"spec.containers[].readinessProbe.exec",
+	"spec.containers[].readinessProbe.initialDelaySeconds",
+	"spec.containers[].readinessProbe.timeoutSeconds",
+	"spec.containers[].readinessProbe.periodSeconds",
+	"spec.containers[].readinessProbe.successThreshold",
+	"spec.containers[].readinessProbe.failureThreshold",
+}
+
+// kindFields are the fields each kind converts besides its metadata and pod template
+var kindFields = map[string][]string{
+	"Deployment": {
+		"spec.replicas",
+		"spec.selector.matchLabels",
+		"spec.strategy.type",
+	},
+	"StatefulSet": {
+		"spec.replicas",
+		"spec.selector.matchLabels",
+		"spec.serviceName",
+		"spec.updateStrategy.type",
+		"spec.podManagementPolicy",
+		"spec.volumeClaimTemplates[].metadata.name",
+		"spec.volumeClaimTemplates[].metadata.labels",
+		"spec.volumeClaimTemplates[].metadata.creationTimestamp",
+		"spec.volumeClaimTemplates[].spec.accessModes",
+		"spec.volumeClaimTemplates[].spec.resources.limits",
+		"spec.volumeClaimTemplates[].spec.resources.requests",
+		"spec.volumeClaimTemplates[].spec.storageClassName",
+		"spec.volumeClaimTemplates[].spec.volumeName",
+	},
+	"DaemonSet": {
+		"spec.selector.matchLabels",
+		"spec.updateStrategy.type",
+	},
+	"Job": {
+		"spec.parallelism",
+		"spec.completions",
+		"spec.backoffLimit",
+		"spec.activeDeadlineSeconds",
+	},
+	"Service": {
+		"spec.selector",
+		"spec.type",
+		"spec.ports[].name",
+		"spec.ports[].port",
+		"spec.ports[].targetPort",
+		"spec.ports[].protocol",
+		"spec.ports[].nodePort",
+	},
+	"ConfigMap": {
+		"data",
+		"binaryData",
+	},
+}
+
+// defaultValues are values of unsupported fields that need no conversion: defaults the API
+// server fills in, and the cluster IP None that makes a Service headless
+var defaultValues = map[string][]string{
+	"spec.revisionHistoryLimit":                                {"10"},
+	"spec.progressDeadlineSeconds":                             {"600"},
+	"spec.strategy.rollingUpdate.maxSurge":                     {"25%"},
+	"spec.strategy.rollingUpdate.maxUnavailable":               {"25%"},
+	"spec.updateStrategy.rollingUpdate.partition":              {"0"},
+	"spec.updateStrategy.rollingUpdate.maxUnavailable":         {"1"},
+	"spec.template.spec.dnsPolicy":                             {"ClusterFirst"},
+	"spec.template.spec.terminationGracePeriodSeconds":         {"30"},
+	"spec.template.spec.containers[].terminationMessagePath":   {"/dev/termination-log"},
+	"spec.template.spec.containers[].terminationMessagePolicy": {"File"},
+	"spec.clusterIP":             {"None"},
+	"spec.clusterIPs":            {"[None]"},
+	"spec.sessionAffinity":       {"None"},
+	"spec.internalTrafficPolicy": {"Cluster"},
+	"spec.ipFamilyPolicy":        {"SingleStack"},
+	"spec.ipFamilies":            {"[IPv4]"},
+}
+
+// patterns are the parsed supported fields of each kind; defaults the parsed default values
+var (
+	patterns = map[string][][]string{}
+	defaults = map[string][]string{}
+)
+
+func init() {
+	for kind, fields := range kindFields {
+		for _, field := range metadataFields {
+			patterns[kind] = append(patterns[kind], segments(field))
+		}
+		for _, field := range fields {
+			patterns[kind] = append(patterns[kind], segments(field))
+		}
+		if kind == "Service" || kind == "ConfigMap" {
+			continue
+		}
+		for _, field := range podTemplateFields {
+			patterns[kind] = append(patterns[kind], segments("spec.template."+field))
+		}
+	}
+	for field, values := range defaultValues {
+		defaults[strings.Join(segments(field), ".")] = values
+	}
+}
+
+// segments splits a field path like spec.containers[].name into map keys and "[]" for the
+// elements of a list
+func segments(field string) []string {
+	var result []string
+	for _, part := range strings.Split(field, ".") {
+		if strings.HasSuffix(part, "[]") {
+			result = append(result, strings.TrimSuffix(part, "[]"), "[]")
+			continue
+		}
+		result = append(result, part)
+	}
+	return result
+}
@@ -0,0 +1,247 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Label and LabelValue mark the ConfigMaps the Grafana dashboard sidecar loads dashboards from
+const (
+	Label      = "grafana_dashboard"
+	LabelValue = "1"
+)
+
+// datasource refers to the Prometheus data source picked in the dashboard variable
+var datasource = map[string]string{"type": "prometheus", "uid": "${datasource}"}
+
+// Reconciler provisions the Grafana dashboard of a cluster
+type Reconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewReconciler creates a new dashboard reconciler
+func NewReconciler(client client.Client, scheme *runtime.Scheme) *Reconciler {
+	return &Reconciler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Reconcile applies the ConfigMap holding the dashboard of the cluster
+func (r *Reconciler) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	log := logr.FromContextOrDiscard(ctx)
+
+	model, err := Build(cluster)
+	if err != nil {
+		return err
+	}
+
+	// Labels set by others, e.g. of the Grafana sidecar, are kept by the apply
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName(cluster),
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				Label:                        LabelValue,
+				"app.kubernetes.io/instance": cluster.Name,
+			},
+		},
+		Data: map[string]string{FileName(cluster): string(model)},
+	}
+	if err := controllerutil.SetControllerReference(cluster, configMap, r.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, r.client, configMap); err != nil {
+		return fmt.Errorf("failed to reconcile grafana dashboard: %w", err)
+	}
+
+	log.Info("reconciled grafana dashboard", "configMap", configMap.Name)
+	return nil
+}
+
+// Cleanup removes the dashboard ConfigMap of the cluster
+func (r *Reconciler) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName(cluster), Namespace: cluster.Namespace}}
+	if err := r.client.Delete(ctx, configMap); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete grafana dashboard: %w", err)
+	}
+	return nil
+}
+
+// ConfigMapName returns the name of the ConfigMap holding the dashboard of a cluster
+func ConfigMapName(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	return naming.Name(naming.Dashboard, cluster.Name)
+}
+
+// FileName returns the ConfigMap key of the dashboard. The sidecar writes every key to a file in
+// one directory, so it includes the namespace to keep clusters of the same name apart.
+func FileName(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	return fmt.Sprintf("k8s-playgrounds-%s-%s.json", cluster.Namespace, cluster.Name)
+}
+
+// UID returns the dashboard UID of a cluster, stable across updates and within Grafana's limit of
+// 40 characters
+func UID(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	sum := sha256.Sum256([]byte(cluster.Namespace + "/" + cluster.Name))
+	return "k8s-playgrounds-" + hex.EncodeToString(sum[:])[:16]
+}
+
+type panel struct {
+	ID          int               `json:"id"`
+	Type        string            `json:"type"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	GridPos     gridPos           `json:"gridPos"`
+	Datasource  map[string]string `json:"datasource"`
+	Targets     []target          `json:"targets"`
+	FieldConfig fieldConfig       `json:"fieldConfig"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string   `json:"unit,omitempty"`
+	Min  *float64 `json:"min,omitempty"`
+	Max  *float64 `json:"max,omitempty"`
+}
+
+// Build renders the Grafana dashboard model of a cluster. Its queries are limited to the
+// namespaces the cluster manages and the cluster's own series of the operator metrics.
+func Build(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) ([]byte, error) {
+	namespaces := namespaceMatcher(cluster)
+	checks := fmt.Sprintf(`k8s_playgrounds_cluster_checks{namespace=%q, cluster=%q}`, cluster.Namespace, cluster.Name)
+	passing := fmt.Sprintf(`k8s_playgrounds_cluster_checks{namespace=%q, cluster=%q, state="passing"}`, cluster.Namespace, cluster.Name)
+	zero, one := 0.0, 1.0
+
+	panels := []panel{
+		{
+			Type:        "gauge",
+			Title:       "Scenario progress",
+			Description: "Share of the health checks in spec.checks that pass",
+			GridPos:     gridPos{H: 6, W: 6, X: 0, Y: 0},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum(%s) / sum(%s)`, passing, checks)},
+			},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Unit: "percentunit", Min: &zero, Max: &one}},
+		},
+		{
+			Type:    "stat",
+			Title:   "Pods by phase",
+			GridPos: gridPos{H: 6, W: 9, X: 6, Y: 0},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum by (phase) (kube_pod_status_phase{namespace=~%q} > 0)`, namespaces), LegendFormat: "{{phase}}"},
+			},
+		},
+		{
+			Type:    "timeseries",
+			Title:   "Container restarts",
+			GridPos: gridPos{H: 6, W: 9, X: 15, Y: 0},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum by (namespace, pod) (increase(kube_pod_container_status_restarts_total{namespace=~%q}[15m]))`, namespaces), LegendFormat: "{{namespace}}/{{pod}}"},
+			},
+		},
+		{
+			Type:        "timeseries",
+			Title:       "Service endpoints",
+			Description: "Ready and not ready addresses behind each Service",
+			GridPos:     gridPos{H: 8, W: 12, X: 0, Y: 6},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum by (namespace, endpoint, ready) (kube_endpoint_address{namespace=~%q})`, namespaces), LegendFormat: "{{namespace}}/{{endpoint}} ready={{ready}}"},
+			},
+		},
+		{
+			Type:        "timeseries",
+			Title:       "DNS test results",
+			Description: "1 while DNS resolution of a HeadlessService is healthy, with the failed tests in a row",
+			GridPos:     gridPos{H: 8, W: 12, X: 12, Y: 6},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`k8s_playgrounds_dns_test_healthy{namespace=~%q}`, namespaces), LegendFormat: "{{namespace}}/{{name}} healthy"},
+				{Expr: fmt.Sprintf(`k8s_playgrounds_dns_test_consecutive_failures{namespace=~%q}`, namespaces), LegendFormat: "{{namespace}}/{{name}} failures"},
+			},
+		},
+		{
+			Type:    "timeseries",
+			Title:   "CPU usage",
+			GridPos: gridPos{H: 8, W: 12, X: 0, Y: 14},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum by (namespace, pod) (rate(container_cpu_usage_seconds_total{namespace=~%q, container!=""}[5m]))`, namespaces), LegendFormat: "{{namespace}}/{{pod}}"},
+			},
+		},
+		{
+			Type:    "timeseries",
+			Title:   "Memory usage",
+			GridPos: gridPos{H: 8, W: 12, X: 12, Y: 14},
+			Targets: []target{
+				{Expr: fmt.Sprintf(`sum by (namespace, pod) (container_memory_working_set_bytes{namespace=~%q, container!=""})`, namespaces), LegendFormat: "{{namespace}}/{{pod}}"},
+			},
+			FieldConfig: fieldConfig{Defaults: fieldDefaults{Unit: "bytes"}},
+		},
+	}
+	for i := range panels {
+		panels[i].ID = i + 1
+		panels[i].Datasource = datasource
+		for j := range panels[i].Targets {
+			panels[i].Targets[j].RefID = string(rune('A' + j))
+		}
+	}
+
+	model := map[string]interface{}{
+		"uid":           UID(cluster),
+		"title":         fmt.Sprintf("Playground %s/%s", cluster.Namespace, cluster.Name),
+		"tags":          []string{"k8s-playgrounds"},
+		"editable":      false,
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{
+				{"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus"},
+			},
+		},
+		"panels": panels,
+	}
+	return json.MarshalIndent(model, "", "  ")
+}
+
+// namespaceMatcher returns a regular expression matching exactly the managed namespaces
+func namespaceMatcher(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	namespaces := cluster.ManagedNamespaces()
+	quoted := make([]string, len(namespaces))
+	for i, ns := range namespaces {
+		quoted[i] = regexp.QuoteMeta(ns)
+	}
+	return strings.Join(quoted, "|")
+}
@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func newCluster(name, namespace string) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Deployments: []k8splaygroundsv1alpha1.DeploymentSpec{{Name: "web", Namespace: "shop-web"}},
+		},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	model, err := Build(newCluster("shop", "playground"))
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	var dashboard struct {
+		UID    string `json:"uid"`
+		Title  string `json:"title"`
+		Panels []struct {
+			ID      int    `json:"id"`
+			Title   string `json:"title"`
+			Targets []struct {
+				RefID string `json:"refId"`
+				Expr  string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(model, &dashboard); err != nil {
+		t.Fatalf("dashboard is not valid JSON: %v", err)
+	}
+	if dashboard.Title != "Playground playground/shop" || len(dashboard.UID) > 40 {
+		t.Errorf("title = %q, uid = %q, want the cluster title and a uid of at most 40 characters", dashboard.Title, dashboard.UID)
+	}
+
+	exprs := map[string]string{}
+	for i, panel := range dashboard.Panels {
+		if panel.ID != i+1 || len(panel.Targets) == 0 || panel.Targets[0].RefID != "A" {
+			t.Errorf("panel %q = %+v, want sequential ids and lettered targets", panel.Title, panel)
+			continue
+		}
+		exprs[panel.Title] = panel.Targets[0].Expr
+	}
+	if got := exprs["Pods by phase"]; !strings.Contains(got, `namespace=~"playground|shop-web"`) {
+		t.Errorf("pod query = %s, want it limited to the managed namespaces", got)
+	}
+	if got := exprs["Scenario progress"]; !strings.Contains(got, `cluster="shop", state="passing"`) {
+		t.Errorf("progress query = %s, want the passing checks of the cluster", got)
+	}
+	for _, title := range []string{"Service endpoints", "DNS test results", "CPU usage", "Memory usage"} {
+		if exprs[title] == "" {
+			t.Errorf("dashboard has no %q panel", title)
+		}
+	}
+}
+
+func TestUIDAndFileName(t *testing.T) {
+	a, b := newCluster("shop", "team-a"), newCluster("shop", "team-b")
+	if UID(a) == UID(b) || FileName(a) == FileName(b) {
+		t.Errorf("clusters of the same name in different namespaces share uid %q or file %q", UID(a), FileName(a))
+	}
+	if UID(a) != UID(newCluster("shop", "team-a")) {
+		t.Error("UID() is not stable")
+	}
+}
@@ -0,0 +1,88 @@
+// Package defaults fills in the settings K8sPlaygroundsClusters and HeadlessServices leave
+// unset. The defaulting webhooks persist them on admission. The controllers apply them to the
+// objects they read without writing them back, so objects admitted without the webhooks are
+// reconciled the same way and reconciling never changes a spec.
+package defaults
+
+import (
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/clusterdomain"
+	"github.com/k8s-playgrounds/operator/pkg/serviceports"
+)
+
+// Defaults of a K8sPlaygroundsCluster
+const (
+	ClusterVersion  = "latest"
+	ClusterReplicas = 3
+)
+
+// Defaults of a HeadlessService
+const (
+	DNSTTL                          = 30
+	ServiceDiscoveryType            = "dns"
+	ServiceDiscoveryRefreshInterval = 30
+	LoadBalancingAlgorithm          = "random"
+)
+
+// Cluster fills in the version, replica count, recommended labels and the service ports a
+// cluster leaves unset
+func Cluster(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+	if cluster.Spec.Version == "" {
+		cluster.Spec.Version = ClusterVersion
+	}
+	if cluster.Spec.Replicas == 0 {
+		cluster.Spec.Replicas = ClusterReplicas
+	}
+
+	if cluster.Labels == nil {
+		cluster.Labels = make(map[string]string)
+	}
+	cluster.Labels["app.kubernetes.io/name"] = "k8s-playgrounds-cluster"
+	cluster.Labels["app.kubernetes.io/instance"] = cluster.Name
+	cluster.Labels["app.kubernetes.io/version"] = cluster.Spec.Version
+
+	for i := range cluster.Spec.Services {
+		serviceports.Default(cluster.Spec.Services[i].Ports)
+	}
+	for i := range cluster.Spec.HeadlessServices {
+		serviceports.Default(cluster.Spec.HeadlessServices[i].Ports)
+	}
+}
+
+// HeadlessService fills in the recommended labels, port protocols and target ports, and the
+// DNS, service discovery and iptables proxy settings a headless service leaves unset
+func HeadlessService(headlessService *k8splaygroundsv1alpha1.HeadlessService) {
+	if headlessService.Labels == nil {
+		headlessService.Labels = make(map[string]string)
+	}
+	headlessService.Labels["app.kubernetes.io/name"] = "headless-service"
+	headlessService.Labels["app.kubernetes.io/instance"] = headlessService.Name
+
+	// Default port protocols and target ports as the API server does for Services
+	serviceports.Default(headlessService.Spec.Ports)
+
+	if headlessService.Spec.DNS == nil {
+		headlessService.Spec.DNS = &k8splaygroundsv1alpha1.DNSSpec{
+			ClusterDomain: clusterdomain.Default(),
+			TTL:           DNSTTL,
+		}
+	}
+	if headlessService.Spec.DNS.ClusterDomain == "" {
+		headlessService.Spec.DNS.ClusterDomain = clusterdomain.Default()
+	}
+
+	if headlessService.Spec.ServiceDiscovery == nil {
+		headlessService.Spec.ServiceDiscovery = &k8splaygroundsv1alpha1.ServiceDiscoverySpec{
+			Type:            ServiceDiscoveryType,
+			RefreshInterval: ServiceDiscoveryRefreshInterval,
+		}
+	}
+
+	if headlessService.Spec.IptablesProxy == nil {
+		headlessService.Spec.IptablesProxy = &k8splaygroundsv1alpha1.IptablesProxySpec{
+			Enabled:                true,
+			LoadBalancingAlgorithm: LoadBalancingAlgorithm,
+			SessionAffinity:        false,
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package defaults
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/clusterdomain"
+)
+
+func TestCluster(t *testing.T) {
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+	Cluster(cluster)
+	if cluster.Spec.Version != ClusterVersion || cluster.Spec.Replicas != ClusterReplicas {
+		t.Errorf("Cluster() spec = %+v, want version %s and %d replicas", cluster.Spec, ClusterVersion, ClusterReplicas)
+	}
+	if cluster.Labels["app.kubernetes.io/instance"] != "demo" || cluster.Labels["app.kubernetes.io/version"] != ClusterVersion {
+		t.Errorf("Cluster() labels = %v", cluster.Labels)
+	}
+
+	// Set values are kept
+	cluster = &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{ObjectMeta: metav1.ObjectMeta{Name: "demo"}}
+	cluster.Spec.Version = "1.29"
+	cluster.Spec.Replicas = 1
+	Cluster(cluster)
+	if cluster.Spec.Version != "1.29" || cluster.Spec.Replicas != 1 {
+		t.Errorf("Cluster() overwrote the spec: %+v", cluster.Spec)
+	}
+}
+
+func TestHeadlessService(t *testing.T) {
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{ObjectMeta: metav1.ObjectMeta{Name: "db"}}
+	HeadlessService(headlessService)
+	spec := headlessService.Spec
+	if spec.DNS == nil || spec.DNS.ClusterDomain != clusterdomain.Default() || spec.DNS.TTL != DNSTTL {
+		t.Errorf("HeadlessService() dns = %+v", spec.DNS)
+	}
+	if spec.ServiceDiscovery == nil || spec.ServiceDiscovery.Type != ServiceDiscoveryType {
+		t.Errorf("HeadlessService() serviceDiscovery = %+v", spec.ServiceDiscovery)
+	}
+	if spec.IptablesProxy == nil || !spec.IptablesProxy.Enabled || spec.IptablesProxy.LoadBalancingAlgorithm != LoadBalancingAlgorithm {
+		t.Errorf("HeadlessService() iptablesProxy = %+v", spec.IptablesProxy)
+	}
+
+	// A DNS spec without a cluster domain gets one and keeps its TTL
+	headlessService = &k8splaygroundsv1alpha1.HeadlessService{ObjectMeta: metav1.ObjectMeta{Name: "db"}}
+	headlessService.Spec.DNS = &k8splaygroundsv1alpha1.DNSSpec{TTL: 5}
+	HeadlessService(headlessService)
+	if headlessService.Spec.DNS.ClusterDomain != clusterdomain.Default() || headlessService.Spec.DNS.TTL != 5 {
+		t.Errorf("HeadlessService() dns = %+v, want the cluster domain and TTL 5", headlessService.Spec.DNS)
+	}
+}
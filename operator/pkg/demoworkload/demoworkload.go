@@ -0,0 +1,236 @@
+package demoworkload
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+const (
+	// DefaultImage serves HTTP with busybox httpd
+	DefaultImage = "busybox:1.36"
+	// DefaultReadinessPeriod is how often the readiness of a demo pod is probed
+	DefaultReadinessPeriod = 5 * time.Second
+	// MaxReplicas bounds the demo pods of one headless service
+	MaxReplicas = 100
+
+	// PollInterval is how often a headless service with demo pods is reconciled, so that the
+	// endpoints follow the churn of the pods
+	PollInterval = 10 * time.Second
+
+	// Label marks the demo pods of a headless service
+	Label = "k8s-playgrounds.io/demo-workload"
+
+	containerName = "demo"
+)
+
+// Validate checks the demo workload of a headless service
+func Validate(headlessService *k8splaygroundsv1alpha1.HeadlessService) field.ErrorList {
+	spec := headlessService.Spec.DemoWorkload
+	if spec == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec", "demoWorkload")
+	if spec.Replicas < 0 || spec.Replicas > MaxReplicas {
+		errs = append(errs, field.Invalid(path.Child("replicas"), spec.Replicas, fmt.Sprintf("must be between 0 and %d", MaxReplicas)))
+	}
+	if spec.FlakinessPercent < 0 || spec.FlakinessPercent > 99 {
+		errs = append(errs, field.Invalid(path.Child("flakinessPercent"), spec.FlakinessPercent, "must be between 0 and 99"))
+	}
+	if spec.ReadinessPeriodSeconds < 0 {
+		errs = append(errs, field.Invalid(path.Child("readinessPeriodSeconds"), spec.ReadinessPeriodSeconds, "must not be negative"))
+	}
+	if len(headlessService.Spec.Selector) == 0 && headlessService.Spec.Mirror == nil {
+		errs = append(errs, field.Required(field.NewPath("spec", "selector"), "demo pods need a selector to match"))
+	}
+	for key, value := range spec.Labels {
+		labelPath := path.Child("labels").Key(key)
+		for _, msg := range validation.IsQualifiedName(key) {
+			errs = append(errs, field.Invalid(labelPath, key, msg))
+		}
+		for _, msg := range validation.IsValidLabelValue(value) {
+			errs = append(errs, field.Invalid(labelPath, value, msg))
+		}
+		if selected, ok := headlessService.Spec.Selector[key]; (ok && selected != value) || key == Label {
+			errs = append(errs, field.Invalid(labelPath, value, "must not override a label of the selector"))
+		}
+	}
+	return errs
+}
+
+// DeploymentName returns the name of the Deployment running the demo pods of a service
+func DeploymentName(serviceName string) string {
+	return naming.Name(naming.DemoWorkload, serviceName)
+}
+
+// Manager runs the demo pods of headless services
+type Manager struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewManager creates a new demo workload manager
+func NewManager(client client.Client, scheme *runtime.Scheme) *Manager {
+	return &Manager{client: client, scheme: scheme}
+}
+
+// Reconcile runs a Deployment with the demo pods of a headless service, or removes it once
+// the service no longer asks for demo pods. It returns true while demo pods run. The replicas
+// are recorded in headlessService.Status.DemoWorkload; the caller persists the status.
+func (m *Manager) Reconcile(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) (bool, error) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: DeploymentName(headlessService.Name), Namespace: headlessService.Namespace},
+	}
+	if headlessService.Spec.DemoWorkload == nil {
+		headlessService.Status.DemoWorkload = nil
+		if err := m.client.Get(ctx, client.ObjectKeyFromObject(deployment), deployment); err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+		if !metav1.IsControlledBy(deployment, headlessService) {
+			return false, nil
+		}
+		if err := m.client.Delete(ctx, deployment); err != nil && !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to delete demo workload %s: %w", deployment.Name, err)
+		}
+		return false, nil
+	}
+
+	rendered, err := Render(headlessService)
+	if err != nil {
+		return false, err
+	}
+	replicas := *rendered.Spec.Replicas
+	// The selector only depends on the name of the service, so it never changes once the
+	// Deployment exists
+	if err := controllerutil.SetControllerReference(headlessService, rendered, m.scheme); err != nil {
+		return false, err
+	}
+	if err := apply.Apply(ctx, m.client, rendered); err != nil {
+		return false, fmt.Errorf("failed to reconcile demo workload %s: %w", rendered.Name, err)
+	}
+
+	headlessService.Status.DemoWorkload = &k8splaygroundsv1alpha1.DemoWorkloadStatus{
+		Deployment:    rendered.Name,
+		Replicas:      rendered.Status.Replicas,
+		ReadyReplicas: rendered.Status.ReadyReplicas,
+	}
+	return replicas > 0, nil
+}
+
+// Render returns the Deployment running the demo pods of a headless service. The pods carry
+// the labels of the selector, so they become endpoints like any other matching pod.
+func Render(headlessService *k8splaygroundsv1alpha1.HeadlessService) (*appsv1.Deployment, error) {
+	spec := headlessService.Spec.DemoWorkload
+	if len(headlessService.Spec.Selector) == 0 {
+		return nil, fmt.Errorf("demo workload of %s needs a selector", headlessService.Name)
+	}
+
+	labels := map[string]string{}
+	for key, value := range spec.Labels {
+		labels[key] = value
+	}
+	for key, value := range headlessService.Spec.Selector {
+		labels[key] = value
+	}
+	labels[Label] = headlessService.Name
+
+	container := corev1.Container{
+		Name:  containerName,
+		Image: spec.Image,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("10m"), corev1.ResourceMemory: resource.MustParse("16Mi")},
+			Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("32Mi")},
+		},
+	}
+	var portNumbers []int32
+	for _, port := range headlessService.Spec.Ports {
+		containerPort := corev1.ContainerPort{ContainerPort: port.Port, Protocol: corev1.Protocol(port.Protocol)}
+		switch port.TargetPort.Type {
+		case intstr.String:
+			containerPort.Name = port.TargetPort.StrVal
+		case intstr.Int:
+			if port.TargetPort.IntVal > 0 {
+				containerPort.ContainerPort = port.TargetPort.IntVal
+			}
+		}
+		if containerPort.Protocol == "" {
+			containerPort.Protocol = corev1.ProtocolTCP
+		}
+		container.Ports = append(container.Ports, containerPort)
+		if containerPort.Protocol == corev1.ProtocolTCP {
+			portNumbers = append(portNumbers, containerPort.ContainerPort)
+		}
+	}
+	if container.Image == "" {
+		container.Image = DefaultImage
+		container.Command = []string{"/bin/sh", "-c", serveScript(portNumbers)}
+	}
+
+	period := DefaultReadinessPeriod
+	if spec.ReadinessPeriodSeconds > 0 {
+		period = time.Duration(spec.ReadinessPeriodSeconds) * time.Second
+	}
+	probe := &corev1.Probe{PeriodSeconds: int32(period.Seconds()), SuccessThreshold: 1, FailureThreshold: 1}
+	switch {
+	case spec.FlakinessPercent > 0:
+		// A byte from /dev/urandom scaled to 0-99 fails the probe FlakinessPercent of the time
+		probe.Exec = &corev1.ExecAction{Command: []string{"/bin/sh", "-c",
+			fmt.Sprintf("test $(( $(od -An -N1 -tu1 /dev/urandom) * 100 / 256 )) -ge %d", spec.FlakinessPercent)}}
+	case len(portNumbers) > 0:
+		probe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt(int(portNumbers[0]))}
+	default:
+		probe = nil
+	}
+	container.ReadinessProbe = probe
+
+	replicas := spec.Replicas
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DeploymentName(headlessService.Name),
+			Namespace: headlessService.Namespace,
+			Labels:    map[string]string{Label: headlessService.Name},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{Label: headlessService.Name}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{container}},
+			},
+		},
+	}, nil
+}
+
+// serveScript answers HTTP requests on every port with the name of the pod
+func serveScript(ports []int32) string {
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	var serve []string
+	for _, port := range ports {
+		serve = append(serve, "httpd -p "+strconv.Itoa(int(port))+" -h /www")
+	}
+	script := `mkdir -p /www && echo "$HOSTNAME" > /www/index.html`
+	if len(serve) > 0 {
+		script += " && " + strings.Join(serve, " && ")
+	}
+	return script + " && while true; do sleep 3600; done"
+}
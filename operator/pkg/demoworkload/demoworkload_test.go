@@ -0,0 +1,95 @@
+package demoworkload
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func demoService(spec *k8splaygroundsv1alpha1.DemoWorkloadSpec) *k8splaygroundsv1alpha1.HeadlessService {
+	return &k8splaygroundsv1alpha1.HeadlessService{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+		Spec: k8splaygroundsv1alpha1.HeadlessServiceSpec{
+			Selector: map[string]string{"app": "orders"},
+			Ports: []k8splaygroundsv1alpha1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromInt(8080)},
+				{Name: "metrics", Port: 9090, TargetPort: intstr.FromString("metrics")},
+			},
+			DemoWorkload: spec,
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	deployment, err := Render(demoService(&k8splaygroundsv1alpha1.DemoWorkloadSpec{
+		Replicas: 3,
+		Labels:   map[string]string{"version": "v2"},
+	}))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	if deployment.Name != "orders-demo" || *deployment.Spec.Replicas != 3 {
+		t.Errorf("deployment = %s with %d replicas, want orders-demo with 3", deployment.Name, *deployment.Spec.Replicas)
+	}
+	labels := deployment.Spec.Template.Labels
+	if labels["app"] != "orders" || labels["version"] != "v2" || labels[Label] != "orders" {
+		t.Errorf("pod labels = %v, want the selector, the extra labels and the demo label", labels)
+	}
+	if selector := deployment.Spec.Selector.MatchLabels; len(selector) != 1 || selector[Label] != "orders" {
+		t.Errorf("selector = %v, want only the demo label", selector)
+	}
+
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != DefaultImage || !strings.Contains(container.Command[2], "httpd -p 8080") || !strings.Contains(container.Command[2], "httpd -p 9090") {
+		t.Errorf("container = %s %v, want busybox serving both ports", container.Image, container.Command)
+	}
+	if len(container.Ports) != 2 || container.Ports[0].ContainerPort != 8080 || container.Ports[1].Name != "metrics" {
+		t.Errorf("ports = %+v, want the target ports of the service", container.Ports)
+	}
+	if probe := container.ReadinessProbe; probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != 8080 {
+		t.Errorf("readiness probe = %+v, want a TCP probe of the first port", probe)
+	}
+}
+
+func TestRenderFlakyReadiness(t *testing.T) {
+	deployment, err := Render(demoService(&k8splaygroundsv1alpha1.DemoWorkloadSpec{
+		Replicas:               2,
+		Image:                  "nginx:1.25",
+		FlakinessPercent:       30,
+		ReadinessPeriodSeconds: 2,
+	}))
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "nginx:1.25" || len(container.Command) != 0 {
+		t.Errorf("container = %s %v, want the image with its own command", container.Image, container.Command)
+	}
+	probe := container.ReadinessProbe
+	if probe == nil || probe.Exec == nil || !strings.HasSuffix(probe.Exec.Command[2], "-ge 30") || probe.PeriodSeconds != 2 {
+		t.Errorf("readiness probe = %+v, want a probe failing 30%% of the time every 2s", probe)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errs := Validate(demoService(&k8splaygroundsv1alpha1.DemoWorkloadSpec{Replicas: 5, FlakinessPercent: 20})); len(errs) > 0 {
+		t.Errorf("Validate() = %v", errs)
+	}
+	invalid := demoService(&k8splaygroundsv1alpha1.DemoWorkloadSpec{
+		Replicas:         MaxReplicas + 1,
+		FlakinessPercent: 100,
+		Labels:           map[string]string{"app": "payments"},
+	})
+	if errs := Validate(invalid); len(errs) != 3 {
+		t.Errorf("Validate() = %v, want 3 errors", errs)
+	}
+	invalid.Spec.Selector = nil
+	invalid.Spec.DemoWorkload = &k8splaygroundsv1alpha1.DemoWorkloadSpec{Replicas: 1}
+	if errs := Validate(invalid); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want the missing selector reported", errs)
+	}
+}
@@ -0,0 +1,127 @@
+package diagnostics
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Container states
+const (
+	StateRunning    = "Running"
+	StateWaiting    = "Waiting"
+	StateTerminated = "Terminated"
+)
+
+// maxMessageLength bounds termination and event messages, which may hold whole stack traces
+const maxMessageLength = 256
+
+// Container summarizes the state and last termination of a container and explains why it
+// restarts or does not start
+func Container(status corev1.ContainerStatus, spec *corev1.Container, init bool) k8splaygroundsv1alpha1.ContainerDiagnostics {
+	container := k8splaygroundsv1alpha1.ContainerDiagnostics{
+		Name:         status.Name,
+		Init:         init,
+		Ready:        status.Ready,
+		RestartCount: status.RestartCount,
+	}
+	switch {
+	case status.State.Running != nil:
+		container.State = StateRunning
+	case status.State.Waiting != nil:
+		container.State = StateWaiting
+		container.Reason = status.State.Waiting.Reason
+	case status.State.Terminated != nil:
+		container.State = StateTerminated
+		container.Reason = status.State.Terminated.Reason
+	}
+
+	// A container that terminated and was not restarted yet has no last state
+	terminated := status.LastTerminationState.Terminated
+	if terminated == nil {
+		terminated = status.State.Terminated
+	}
+	if terminated != nil {
+		container.LastTermination = &k8splaygroundsv1alpha1.TerminationStatus{
+			Reason:     terminated.Reason,
+			ExitCode:   terminated.ExitCode,
+			Signal:     terminated.Signal,
+			Message:    truncate(terminated.Message),
+			FinishedAt: terminated.FinishedAt,
+		}
+	}
+
+	container.Analysis = Analyze(container, status.State.Waiting, spec)
+	return container
+}
+
+// Analyze explains in one sentence why a container restarts or does not start, or returns ""
+// when nothing is wrong with it
+func Analyze(container k8splaygroundsv1alpha1.ContainerDiagnostics, waiting *corev1.ContainerStateWaiting, spec *corev1.Container) string {
+	if waiting != nil {
+		switch waiting.Reason {
+		case "ErrImagePull", "ImagePullBackOff", "InvalidImageName":
+			image := ""
+			if spec != nil {
+				image = spec.Image
+			}
+			return fmt.Sprintf("image %s cannot be pulled: %s", image, truncate(waiting.Message))
+		case "CreateContainerConfigError":
+			return fmt.Sprintf("the container cannot be created, often because a referenced ConfigMap or Secret key is missing: %s", truncate(waiting.Message))
+		case "CreateContainerError", "RunContainerError":
+			return fmt.Sprintf("the container runtime cannot start the container: %s", truncate(waiting.Message))
+		}
+	}
+
+	last := container.LastTermination
+	if last == nil || (last.ExitCode == 0 && last.Reason != "OOMKilled") {
+		return ""
+	}
+	var cause string
+	switch {
+	case last.Reason == "OOMKilled":
+		if limit := memoryLimit(spec); limit != "" {
+			cause = fmt.Sprintf("killed for exceeding its memory limit of %s", limit)
+		} else {
+			cause = "killed by the kernel OOM killer while the node ran out of memory; the container has no memory limit"
+		}
+	case last.ExitCode == 137:
+		cause = "killed with SIGKILL, usually after a failed liveness probe or a graceful shutdown that took too long"
+	case last.ExitCode == 143:
+		cause = "terminated with SIGTERM"
+	case last.ExitCode == 139:
+		cause = "crashed with a segmentation fault"
+	case last.ExitCode == 126:
+		cause = "the command is not executable"
+	case last.ExitCode == 127:
+		cause = "the command was not found in the image"
+	default:
+		cause = fmt.Sprintf("exited with code %d; kubectl logs --previous shows the output of the failed run", last.ExitCode)
+	}
+	if container.Reason == "CrashLoopBackOff" {
+		return fmt.Sprintf("crash looping after %d restarts: %s", container.RestartCount, cause)
+	}
+	return cause
+}
+
+// memoryLimit returns the memory limit of a container, or "" when it has none
+func memoryLimit(spec *corev1.Container) string {
+	if spec == nil {
+		return ""
+	}
+	limit, ok := spec.Resources.Limits[corev1.ResourceMemory]
+	if !ok {
+		return ""
+	}
+	return limit.String()
+}
+
+// truncate shortens a message to maxMessageLength bytes
+func truncate(message string) string {
+	if len(message) <= maxMessageLength {
+		return message
+	}
+	return message[:maxMessageLength-3] + "..."
+}
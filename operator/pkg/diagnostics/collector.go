@@ -0,0 +1,200 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/labeling"
+)
+
+const (
+	// MaxPods bounds the pods reported in the cluster status; healthy pods are left out first
+	MaxPods = 50
+	// MaxEventsPerPod bounds the Warning events reported per pod; older events are left out
+	MaxEventsPerPod = 5
+)
+
+// Collector gathers the diagnostics of the managed pods of clusters
+type Collector struct {
+	client client.Reader
+	events client.Reader
+}
+
+// NewCollector creates a new diagnostics collector. Events are read through their own reader,
+// normally the uncached API reader, so the operator does not cache every event in the cluster;
+// a nil reader leaves events out.
+func NewCollector(client client.Reader, events client.Reader) *Collector {
+	return &Collector{
+		client: client,
+		events: events,
+	}
+}
+
+// Collect reports the container states, last terminations and recent Warning events of the
+// pods of a cluster, pods with problems first
+func (c *Collector) Collect(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, now time.Time) (*k8splaygroundsv1alpha1.DiagnosticsStatus, error) {
+	pods, err := managedPods(ctx, c.client, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var diagnostics []k8splaygroundsv1alpha1.PodDiagnostics
+	namespaces := map[string]bool{}
+	for i := range pods {
+		diagnostics = append(diagnostics, Pod(&pods[i]))
+		namespaces[pods[i].Namespace] = true
+	}
+
+	if c.events != nil {
+		events, err := c.warningEvents(ctx, namespaces)
+		if err != nil {
+			return nil, err
+		}
+		for i := range diagnostics {
+			diagnostics[i].Events = events[diagnostics[i].Namespace+"/"+diagnostics[i].Name]
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := Problems(diagnostics[i]), Problems(diagnostics[j])
+		if a != b {
+			return a > b
+		}
+		if diagnostics[i].Namespace != diagnostics[j].Namespace {
+			return diagnostics[i].Namespace < diagnostics[j].Namespace
+		}
+		return diagnostics[i].Name < diagnostics[j].Name
+	})
+	status := &k8splaygroundsv1alpha1.DiagnosticsStatus{CollectedAt: metav1.NewTime(now)}
+	if len(diagnostics) > MaxPods {
+		status.OmittedPods = int32(len(diagnostics) - MaxPods)
+		diagnostics = diagnostics[:MaxPods]
+	}
+	status.Pods = diagnostics
+	return status, nil
+}
+
+// managedPods returns the pods of a cluster
+func managedPods(ctx context.Context, reader client.Reader, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) ([]corev1.Pod, error) {
+	// Pods may live in any namespace a resource spec names, so they are found by label
+	list := &corev1.PodList{}
+	if err := reader.List(ctx, list, client.MatchingLabels{
+		labeling.ManagedByLabel: labeling.ManagedBy,
+		labeling.ClusterLabel:   cluster.Name,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	owner := cluster.Namespace + "/" + cluster.Name
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		// Clusters of the same name in other namespaces label their pods alike
+		if pod.Annotations[labeling.OwnerAnnotation] == owner {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// Pod summarizes a pod and its init and app containers
+func Pod(pod *corev1.Pod) k8splaygroundsv1alpha1.PodDiagnostics {
+	diagnostics := k8splaygroundsv1alpha1.PodDiagnostics{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		Phase:     string(pod.Status.Phase),
+		Reason:    pod.Status.Reason,
+		Message:   truncate(pod.Status.Message),
+	}
+	for _, status := range pod.Status.InitContainerStatuses {
+		diagnostics.Containers = append(diagnostics.Containers, Container(status, containerSpec(pod.Spec.InitContainers, status.Name), true))
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		diagnostics.Containers = append(diagnostics.Containers, Container(status, containerSpec(pod.Spec.Containers, status.Name), false))
+	}
+	return diagnostics
+}
+
+// Problems scores how much is wrong with a pod: every restart, container that is not ready or
+// has an analysis and Warning event adds to it
+func Problems(pod k8splaygroundsv1alpha1.PodDiagnostics) int {
+	problems := len(pod.Events)
+	if pod.Reason != "" || pod.Phase == string(corev1.PodFailed) || pod.Phase == string(corev1.PodPending) {
+		problems++
+	}
+	for _, container := range pod.Containers {
+		problems += int(container.RestartCount)
+		if container.Analysis != "" {
+			problems++
+		}
+		// Terminated containers are never ready; a failed one has an analysis
+		if !container.Ready && container.State != StateTerminated {
+			problems++
+		}
+	}
+	return problems
+}
+
+// warningEvents returns the most recent Warning events of the pods in the namespaces, keyed by
+// namespace/name of the pod
+func (c *Collector) warningEvents(ctx context.Context, namespaces map[string]bool) (map[string][]k8splaygroundsv1alpha1.WarningEvent, error) {
+	events := map[string][]k8splaygroundsv1alpha1.WarningEvent{}
+	for namespace := range namespaces {
+		list := &corev1.EventList{}
+		if err := c.events.List(ctx, list, client.InNamespace(namespace), client.MatchingFields{"type": corev1.EventTypeWarning}); err != nil {
+			return nil, fmt.Errorf("failed to list events in %s: %w", namespace, err)
+		}
+		for _, event := range list.Items {
+			if event.InvolvedObject.Kind != "Pod" {
+				continue
+			}
+			key := namespace + "/" + event.InvolvedObject.Name
+			events[key] = append(events[key], warningEvent(&event))
+		}
+	}
+	for key, list := range events {
+		sort.SliceStable(list, func(i, j int) bool { return list[j].LastSeen.Before(&list[i].LastSeen) })
+		if len(list) > MaxEventsPerPod {
+			list = list[:MaxEventsPerPod]
+		}
+		events[key] = list
+	}
+	return events, nil
+}
+
+// warningEvent summarizes an event, preferring the series of events.k8s.io/v1 recorders
+func warningEvent(event *corev1.Event) k8splaygroundsv1alpha1.WarningEvent {
+	summary := k8splaygroundsv1alpha1.WarningEvent{
+		Reason:   event.Reason,
+		Message:  truncate(event.Message),
+		Count:    event.Count,
+		LastSeen: event.LastTimestamp,
+	}
+	if event.Series != nil {
+		summary.Count = event.Series.Count
+		summary.LastSeen = metav1.NewTime(event.Series.LastObservedTime.Time)
+	}
+	if summary.LastSeen.IsZero() {
+		summary.LastSeen = metav1.NewTime(event.EventTime.Time)
+	}
+	if summary.LastSeen.IsZero() {
+		summary.LastSeen = event.FirstTimestamp
+	}
+	return summary
+}
+
+// containerSpec returns the container of the given name, or nil
+func containerSpec(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+const (
+	// DefaultDebugImage is the debug container image used when the spec does not set one
+	DefaultDebugImage = "busybox:1.36"
+	// DebugContainerPrefix starts the names of the debug containers added to pods
+	DebugContainerPrefix = "playgrounds-debug-"
+	// MaxDebugContainersPerPod bounds the debug containers of a pod. Ephemeral containers cannot
+	// be removed again, so a crash looping pod is only debugged after its first few restarts.
+	MaxDebugContainersPerPod = 3
+	// MaxDebugCaptures bounds the captures kept in the cluster status; older ones are dropped
+	MaxDebugCaptures = 10
+	// DebugPollInterval is how soon to look again while a debug container is running
+	DebugPollInterval = 15 * time.Second
+)
+
+// Sections of the debug container output
+const (
+	sectionProcesses   = "processes"
+	sectionConnections = "connections"
+	sectionDNS         = "dns"
+)
+
+// debugScript collects the processes, connections and DNS resolution of a pod into the
+// termination message. The kubelet keeps at most 4096 bytes of it, so every section is cut short.
+var debugScript = fmt.Sprintf(`{
+echo '## %s'; ps 2>&1 | head -c 1400
+echo '## %s'; netstat -tuanp 2>&1 | head -c 1400
+echo '## %s'; cat /etc/resolv.conf 2>&1 | head -c 300; nslookup kubernetes.default 2>&1 | head -c 800
+} > /dev/termination-log`, sectionProcesses, sectionConnections, sectionDNS)
+
+// Debugger attaches ephemeral debug containers to the failing pods of clusters
+type Debugger struct {
+	client client.Client
+	image  string
+}
+
+// NewDebugger creates a new debugger running the given image, or the default one
+func NewDebugger(client client.Client, image string) *Debugger {
+	if image == "" {
+		image = DefaultDebugImage
+	}
+	return &Debugger{
+		client: client,
+		image:  image,
+	}
+}
+
+// Debug attaches a debug container to every pod of a cluster with a container that restarted
+// since it was last debugged, and collects what finished debug containers captured. The captures
+// are merged into the previous ones, so they outlive the pod. pending reports whether a debug
+// container is still running.
+func (d *Debugger) Debug(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, previous []k8splaygroundsv1alpha1.DebugCapture, now time.Time) (captures []k8splaygroundsv1alpha1.DebugCapture, pending bool, err error) {
+	pods, err := managedPods(ctx, d.client, cluster)
+	if err != nil {
+		return previous, false, err
+	}
+
+	byKey := map[string]k8splaygroundsv1alpha1.DebugCapture{}
+	for _, capture := range previous {
+		byKey[captureKey(capture)] = capture
+	}
+
+	var attachErr error
+	for i := range pods {
+		pod := &pods[i]
+		running := false
+		for _, container := range debugContainers(pod) {
+			capture, done := Capture(pod, container.Name, now)
+			if !done {
+				running = true
+				continue
+			}
+			// Keep the first capture, an image pull error would otherwise be timed anew every time
+			if _, seen := byKey[captureKey(capture)]; !seen {
+				byKey[captureKey(capture)] = capture
+			}
+		}
+		if running {
+			pending = true
+			continue
+		}
+
+		target := debugTarget(pod)
+		if target == "" {
+			continue
+		}
+		if _, err := Attach(ctx, d.client, pod, target, d.image); err != nil {
+			if attachErr == nil {
+				attachErr = err
+			}
+			continue
+		}
+		pending = true
+	}
+
+	for _, capture := range byKey {
+		captures = append(captures, capture)
+	}
+	sort.Slice(captures, func(i, j int) bool {
+		if !captures[i].CompletedAt.Equal(&captures[j].CompletedAt) {
+			return captures[j].CompletedAt.Before(&captures[i].CompletedAt)
+		}
+		return captureKey(captures[i]) < captureKey(captures[j])
+	})
+	if len(captures) > MaxDebugCaptures {
+		captures = captures[:MaxDebugCaptures]
+	}
+	return captures, pending, attachErr
+}
+
+// Attach adds a debug container to a pod that shares the process namespace of the target
+// container, and returns its name
+func Attach(ctx context.Context, c client.Client, pod *corev1.Pod, target, image string) (string, error) {
+	name := fmt.Sprintf("%s%d", DebugContainerPrefix, len(debugContainers(pod)))
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, DebugContainer(name, target, image))
+	if err := c.SubResource("ephemeralcontainers").Update(ctx, pod); err != nil {
+		return "", fmt.Errorf("failed to attach debug container to pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	return name, nil
+}
+
+// DebugContainer returns an ephemeral container that runs the debug script against the target
+// container and exits
+func DebugContainer(name, target, image string) corev1.EphemeralContainer {
+	return corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     name,
+			Image:                    image,
+			Command:                  []string{"sh", "-c", debugScript},
+			TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+		},
+		TargetContainerName: target,
+	}
+}
+
+// Capture returns what a debug container of a pod collected and whether it is done. A debug
+// container whose image cannot be pulled is done with an error.
+func Capture(pod *corev1.Pod, name string, now time.Time) (k8splaygroundsv1alpha1.DebugCapture, bool) {
+	capture := k8splaygroundsv1alpha1.DebugCapture{
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Container: name,
+	}
+	for _, container := range pod.Spec.EphemeralContainers {
+		if container.Name == name {
+			capture.Target = container.TargetContainerName
+			capture.Image = container.Image
+		}
+	}
+
+	for _, status := range pod.Status.EphemeralContainerStatuses {
+		if status.Name != name {
+			continue
+		}
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" && waiting.Reason != "ContainerCreating" && waiting.Reason != "PodInitializing" {
+			capture.CompletedAt = metav1.NewTime(now)
+			capture.Error = truncate(strings.TrimSuffix(waiting.Reason+": "+waiting.Message, ": "))
+			return capture, true
+		}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			return capture, false
+		}
+		capture.CompletedAt = terminated.FinishedAt
+		if capture.CompletedAt.IsZero() {
+			capture.CompletedAt = metav1.NewTime(now)
+		}
+		sections := parseDebugOutput(terminated.Message)
+		capture.Processes = sections[sectionProcesses]
+		capture.Connections = sections[sectionConnections]
+		capture.DNS = sections[sectionDNS]
+		if terminated.ExitCode != 0 {
+			capture.Error = fmt.Sprintf("debug container exited with code %d", terminated.ExitCode)
+		}
+		return capture, true
+	}
+	return capture, false
+}
+
+// parseDebugOutput splits the output of the debug script into its sections
+func parseDebugOutput(output string) map[string]string {
+	sections := map[string]string{}
+	section := ""
+	var lines []string
+	flush := func() {
+		if section != "" {
+			sections[section] = strings.TrimRight(strings.Join(lines, "\n"), "\n")
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(line, "## "); ok {
+			flush()
+			section, lines = name, nil
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return sections
+}
+
+// debugTarget returns the first container of a running pod that restarted more often than it
+// was debugged, or "" when there is none or the pod has all debug containers it may get
+func debugTarget(pod *corev1.Pod) string {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return ""
+	}
+	existing := debugContainers(pod)
+	if len(existing) >= MaxDebugContainersPerPod {
+		return ""
+	}
+	debugged := map[string]int32{}
+	for _, container := range existing {
+		debugged[container.TargetContainerName]++
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.RestartCount > debugged[status.Name] {
+			return status.Name
+		}
+	}
+	return ""
+}
+
+// debugContainers returns the debug containers added to a pod
+func debugContainers(pod *corev1.Pod) []corev1.EphemeralContainer {
+	var containers []corev1.EphemeralContainer
+	for _, container := range pod.Spec.EphemeralContainers {
+		if strings.HasPrefix(container.Name, DebugContainerPrefix) {
+			containers = append(containers, container)
+		}
+	}
+	return containers
+}
+
+// captureKey identifies a capture by its pod and debug container
+func captureKey(capture k8splaygroundsv1alpha1.DebugCapture) string {
+	return capture.Namespace + "/" + capture.Pod + "/" + capture.Container
+}
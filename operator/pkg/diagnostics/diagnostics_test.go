@@ -0,0 +1,289 @@
+package diagnostics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/labeling"
+)
+
+func newPod(name, owner string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "playground",
+			Labels:      map[string]string{labeling.ManagedByLabel: labeling.ManagedBy, labeling.ClusterLabel: "shop"},
+			Annotations: map[string]string{labeling.OwnerAnnotation: owner},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:      "app",
+			Image:     "shop:1.0",
+			Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}},
+		}}},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				Ready: true,
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	spec := &corev1.Container{
+		Image:     "shop:1.0",
+		Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("64Mi")}},
+	}
+	tests := []struct {
+		name      string
+		container k8splaygroundsv1alpha1.ContainerDiagnostics
+		waiting   *corev1.ContainerStateWaiting
+		spec      *corev1.Container
+		want      string
+	}{
+		{
+			name:      "healthy",
+			container: k8splaygroundsv1alpha1.ContainerDiagnostics{Ready: true},
+			spec:      spec,
+		},
+		{
+			name:      "completed",
+			container: k8splaygroundsv1alpha1.ContainerDiagnostics{LastTermination: &k8splaygroundsv1alpha1.TerminationStatus{Reason: "Completed"}},
+			spec:      spec,
+		},
+		{
+			name: "out of memory",
+			container: k8splaygroundsv1alpha1.ContainerDiagnostics{
+				Reason:          "CrashLoopBackOff",
+				RestartCount:    4,
+				LastTermination: &k8splaygroundsv1alpha1.TerminationStatus{Reason: "OOMKilled", ExitCode: 137},
+			},
+			spec: spec,
+			want: "crash looping after 4 restarts: killed for exceeding its memory limit of 64Mi",
+		},
+		{
+			name:      "out of memory without limit",
+			container: k8splaygroundsv1alpha1.ContainerDiagnostics{LastTermination: &k8splaygroundsv1alpha1.TerminationStatus{Reason: "OOMKilled", ExitCode: 137}},
+			spec:      &corev1.Container{},
+			want:      "no memory limit",
+		},
+		{
+			name:      "liveness probe",
+			container: k8splaygroundsv1alpha1.ContainerDiagnostics{LastTermination: &k8splaygroundsv1alpha1.TerminationStatus{Reason: "Error", ExitCode: 137}},
+			spec:      spec,
+			want:      "SIGKILL",
+		},
+		{
+			name:      "application error",
+			container: k8splaygroundsv1alpha1.ContainerDiagnostics{LastTermination: &k8splaygroundsv1alpha1.TerminationStatus{Reason: "Error", ExitCode: 2}},
+			spec:      spec,
+			want:      "exited with code 2",
+		},
+		{
+			name:    "image pull",
+			waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "not found"},
+			spec:    spec,
+			want:    "image shop:1.0 cannot be pulled: not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Analyze(tt.container, tt.waiting, tt.spec)
+			if tt.want == "" && got != "" || !strings.Contains(got, tt.want) {
+				t.Errorf("Analyze() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	healthy := newPod("shop-0", "playground/shop")
+	crashing := newPod("shop-1", "playground/shop")
+	crashing.Status.ContainerStatuses[0] = corev1.ContainerStatus{
+		Name:                 "app",
+		RestartCount:         3,
+		State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+	}
+	foreign := newPod("shop-2", "other/shop")
+
+	var events []client.Object
+	for i, reason := range []string{"BackOff", "Unhealthy", "BackOff", "Unhealthy", "BackOff", "FailedScheduling"} {
+		events = append(events, &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "shop-1." + reason + string(rune('a'+i)), Namespace: "playground"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "shop-1", Namespace: "playground"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         reason,
+			Count:          int32(i + 1),
+			LastTimestamp:  metav1.NewTime(now.Add(-time.Duration(i) * time.Minute)),
+		})
+	}
+	events = append(events, &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: "shop-0.started", Namespace: "playground"},
+		InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "shop-0", Namespace: "playground"},
+		Type:           corev1.EventTypeNormal,
+		Reason:         "Started",
+	})
+
+	c := fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithObjects(append(events, healthy, crashing, foreign)...).
+		WithIndex(&corev1.Event{}, "type", func(obj client.Object) []string { return []string{obj.(*corev1.Event).Type} }).
+		Build()
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "playground"}}
+
+	status, err := NewCollector(c, c).Collect(context.Background(), cluster, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Pods) != 2 || status.Pods[0].Name != "shop-1" || status.Pods[1].Name != "shop-0" {
+		t.Fatalf("pods = %+v, want the crashing pod first and the foreign pod left out", status.Pods)
+	}
+	container := status.Pods[0].Containers[0]
+	if container.State != StateWaiting || container.LastTermination == nil || container.LastTermination.Reason != "OOMKilled" || !strings.Contains(container.Analysis, "64Mi") {
+		t.Errorf("container = %+v, want the OOM kill explained", container)
+	}
+	if events := status.Pods[0].Events; len(events) != MaxEventsPerPod || events[0].Count != 1 || events[len(events)-1].Count != MaxEventsPerPod {
+		t.Errorf("events = %+v, want the %d most recent", events, MaxEventsPerPod)
+	}
+	if len(status.Pods[1].Events) != 0 {
+		t.Errorf("events = %+v, want Normal events left out", status.Pods[1].Events)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	crashing := newPod("shop-1", "playground/shop")
+	crashing.Status.ContainerStatuses[0] = corev1.ContainerStatus{
+		Name:                 "app",
+		RestartCount:         3,
+		State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137, FinishedAt: metav1.NewTime(now.Add(-time.Minute))}},
+	}
+	pod := Pod(crashing)
+	pod.Events = []k8splaygroundsv1alpha1.WarningEvent{{Reason: "BackOff", Message: "Back-off restarting failed container", Count: 7, LastSeen: metav1.NewTime(now)}}
+	status := &k8splaygroundsv1alpha1.DiagnosticsStatus{CollectedAt: metav1.NewTime(now), Pods: []k8splaygroundsv1alpha1.PodDiagnostics{pod}}
+
+	var out strings.Builder
+	if err := Print(&out, status, now); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Waiting: CrashLoopBackOff", "OOMKilled, exit 137, 60s ago", "memory limit of 64Mi", "BackOff (x7, 0s ago)"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Print() = %q, want %q", out.String(), want)
+		}
+	}
+}
+
+func TestDebug(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	healthy := newPod("shop-0", "playground/shop")
+	crashing := newPod("shop-1", "playground/shop")
+	crashing.Status.ContainerStatuses[0].RestartCount = 2
+	// The fake client only updates the status through subresources
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(healthy, crashing).WithInterceptorFuncs(interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResource string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			if subResource == "ephemeralcontainers" {
+				return c.Update(ctx, obj)
+			}
+			return c.SubResource(subResource).Update(ctx, obj, opts...)
+		},
+	}).Build()
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "playground"}}
+	previous := []k8splaygroundsv1alpha1.DebugCapture{{Namespace: "playground", Pod: "shop-9", Container: DebugContainerPrefix + "0", CompletedAt: metav1.NewTime(now.Add(-time.Hour))}}
+
+	debugger := NewDebugger(c, "")
+	captures, pending, err := debugger.Debug(context.Background(), cluster, previous, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pending || len(captures) != 1 || captures[0].Pod != "shop-9" {
+		t.Fatalf("pending = %t, captures = %+v, want a running debug container and the capture of the replaced pod kept", pending, captures)
+	}
+	pod := &corev1.Pod{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(crashing), pod); err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Spec.EphemeralContainers) != 1 || pod.Spec.EphemeralContainers[0].TargetContainerName != "app" || pod.Spec.EphemeralContainers[0].Image != DefaultDebugImage {
+		t.Fatalf("ephemeral containers = %+v, want a debug container targeting app", pod.Spec.EphemeralContainers)
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(healthy), healthy); err != nil || len(healthy.Spec.EphemeralContainers) != 0 {
+		t.Errorf("healthy pod got ephemeral containers %+v", healthy.Spec.EphemeralContainers)
+	}
+
+	// The debug container finishes
+	pod.Status.EphemeralContainerStatuses = []corev1.ContainerStatus{{
+		Name: DebugContainerPrefix + "0",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			FinishedAt: metav1.NewTime(now.Add(time.Minute)),
+			Message:    "## processes\nPID USER COMMAND\n1 root /app\n## connections\ntcp 0 0 0.0.0.0:8080 LISTEN\n## dns\nnameserver 10.96.0.10\n",
+		}},
+	}}
+	if err := c.Status().Update(context.Background(), pod); err != nil {
+		t.Fatal(err)
+	}
+	captures, pending, err = debugger.Debug(context.Background(), cluster, captures, now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(captures) != 2 || captures[0].Pod != "shop-1" {
+		t.Fatalf("captures = %+v, want the new capture first", captures)
+	}
+	if capture := captures[0]; capture.Processes != "PID USER COMMAND\n1 root /app" || !strings.Contains(capture.Connections, ":8080") || capture.DNS != "nameserver 10.96.0.10" || capture.Target != "app" {
+		t.Errorf("capture = %+v, want the sections of the output", capture)
+	}
+	// Both restarts were debugged once the second debug container is attached
+	if !pending {
+		t.Error("pending = false, want a second debug container for the second restart")
+	}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(crashing), pod); err != nil {
+		t.Fatal(err)
+	}
+	if len(pod.Spec.EphemeralContainers) != 2 || pod.Spec.EphemeralContainers[1].Name != DebugContainerPrefix+"1" {
+		t.Errorf("ephemeral containers = %+v, want a second debug container", pod.Spec.EphemeralContainers)
+	}
+	if target := debugTarget(pod); target != "" {
+		t.Errorf("debugTarget() = %q, want no target once every restart was debugged", target)
+	}
+}
+
+func TestCaptureImagePullError(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pod := newPod("shop-1", "playground/shop")
+	pod.Spec.EphemeralContainers = []corev1.EphemeralContainer{DebugContainer(DebugContainerPrefix+"0", "app", "missing:latest")}
+	pod.Status.EphemeralContainerStatuses = []corev1.ContainerStatus{{
+		Name:  DebugContainerPrefix + "0",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+	}}
+	if _, done := Capture(pod, DebugContainerPrefix+"0", now); done {
+		t.Error("Capture() done while the container is created")
+	}
+
+	pod.Status.EphemeralContainerStatuses[0].State.Waiting = &corev1.ContainerStateWaiting{Reason: "ErrImagePull", Message: "not found"}
+	capture, done := Capture(pod, DebugContainerPrefix+"0", now)
+	if !done || capture.Error != "ErrImagePull: not found" || capture.Image != "missing:latest" {
+		t.Errorf("Capture() = %+v, %t, want the pull error", capture, done)
+	}
+
+	var out strings.Builder
+	if err := PrintCapture(&out, capture, now); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "playground/shop-1 playgrounds-debug-0 (target app, 0s ago)") || !strings.Contains(out.String(), "error: ErrImagePull") {
+		t.Errorf("PrintCapture() = %q", out.String())
+	}
+}
@@ -0,0 +1,106 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Print writes the diagnostics of a cluster as a container table followed by the Warning
+// events of its pods, as shown by kubectl playgrounds diagnose
+func Print(w io.Writer, status *k8splaygroundsv1alpha1.DiagnosticsStatus, now time.Time) error {
+	if status == nil {
+		_, err := fmt.Fprintln(w, "No diagnostics collected yet.")
+		return err
+	}
+
+	fmt.Fprintf(w, "Collected %s ago\n\n", age(status.CollectedAt.Time, now))
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "POD\tCONTAINER\tREADY\tSTATE\tRESTARTS\tLAST TERMINATION\tANALYSIS")
+	for _, pod := range status.Pods {
+		if len(pod.Containers) == 0 {
+			fmt.Fprintf(table, "%s/%s\t\t\t%s\t\t\t%s\n", pod.Namespace, pod.Name, pod.Phase, pod.Message)
+		}
+		for _, container := range pod.Containers {
+			name := container.Name
+			if container.Init {
+				name += " (init)"
+			}
+			state := container.State
+			if container.Reason != "" {
+				state += ": " + container.Reason
+			}
+			last := ""
+			if t := container.LastTermination; t != nil {
+				last = fmt.Sprintf("%s, exit %d, %s ago", t.Reason, t.ExitCode, age(t.FinishedAt.Time, now))
+			}
+			fmt.Fprintf(table, "%s/%s\t%s\t%t\t%s\t%d\t%s\t%s\n", pod.Namespace, pod.Name, name, container.Ready, state, container.RestartCount, last, container.Analysis)
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if status.OmittedPods > 0 {
+		fmt.Fprintf(w, "%d healthy pods not shown\n", status.OmittedPods)
+	}
+
+	header := false
+	for _, pod := range status.Pods {
+		for _, event := range pod.Events {
+			if !header {
+				fmt.Fprintln(w, "\nWarning events:")
+				header = true
+			}
+			fmt.Fprintf(w, "  %s/%s: %s (x%d, %s ago): %s\n", pod.Namespace, pod.Name, event.Reason, event.Count, age(event.LastSeen.Time, now), event.Message)
+		}
+	}
+
+	if len(status.DebugCaptures) > 0 {
+		fmt.Fprintln(w, "\nDebug captures:")
+	}
+	for _, capture := range status.DebugCaptures {
+		fmt.Fprintln(w)
+		if err := PrintCapture(w, capture, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrintCapture writes what a debug container collected, as shown by kubectl playgrounds debug
+func PrintCapture(w io.Writer, capture k8splaygroundsv1alpha1.DebugCapture, now time.Time) error {
+	if _, err := fmt.Fprintf(w, "%s/%s %s (target %s, %s ago)\n", capture.Namespace, capture.Pod, capture.Container, capture.Target, age(capture.CompletedAt.Time, now)); err != nil {
+		return err
+	}
+	if capture.Error != "" {
+		fmt.Fprintf(w, "  error: %s\n", capture.Error)
+	}
+	for _, section := range []struct{ title, output string }{
+		{"Processes", capture.Processes},
+		{"Connections", capture.Connections},
+		{"DNS", capture.DNS},
+	} {
+		if section.output == "" {
+			continue
+		}
+		fmt.Fprintf(w, "  %s:\n", section.title)
+		for _, line := range strings.Split(section.output, "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+	return nil
+}
+
+// age formats the time since t like kubectl does
+func age(t, now time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return duration.HumanDuration(now.Sub(t))
+}
@@ -0,0 +1,124 @@
+package dns
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Defaults used for DNS testing settings a HeadlessService leaves unset
+const (
+	DefaultTestInterval     = 2 * time.Minute
+	DefaultFailureThreshold = 3
+	DefaultSuccessThreshold = 1
+	DefaultMaxBackoff       = 15 * time.Minute
+)
+
+// TestPolicy is the resolved DNS test configuration of a HeadlessService
+type TestPolicy struct {
+	Interval         time.Duration
+	FailureThreshold int32
+	SuccessThreshold int32
+	MaxBackoff       time.Duration
+}
+
+// PolicyFor resolves the DNS test configuration of a headless service, filling in defaults
+func PolicyFor(spec *k8splaygroundsv1alpha1.DNSSpec) TestPolicy {
+	policy := TestPolicy{
+		Interval:         DefaultTestInterval,
+		FailureThreshold: DefaultFailureThreshold,
+		SuccessThreshold: DefaultSuccessThreshold,
+		MaxBackoff:       DefaultMaxBackoff,
+	}
+	if spec == nil || spec.Testing == nil {
+		return policy
+	}
+
+	testing := spec.Testing
+	if testing.IntervalSeconds > 0 {
+		policy.Interval = time.Duration(testing.IntervalSeconds) * time.Second
+	}
+	if testing.FailureThreshold > 0 {
+		policy.FailureThreshold = testing.FailureThreshold
+	}
+	if testing.SuccessThreshold > 0 {
+		policy.SuccessThreshold = testing.SuccessThreshold
+	}
+	if testing.MaxBackoffSeconds > 0 {
+		policy.MaxBackoff = time.Duration(testing.MaxBackoffSeconds) * time.Second
+	}
+	if policy.MaxBackoff < policy.Interval {
+		policy.MaxBackoff = policy.Interval
+	}
+	return policy
+}
+
+// NextInterval returns how long to wait after the given result before testing again. While the
+// service is unhealthy the interval doubles for every failure beyond the threshold, up to
+// MaxBackoff.
+func (p TestPolicy) NextInterval(result *k8splaygroundsv1alpha1.DNSTestResult) time.Duration {
+	if result == nil || result.Healthy {
+		return p.Interval
+	}
+
+	interval := p.Interval
+	for extra := result.ConsecutiveFailures - p.FailureThreshold; extra > 0 && interval < p.MaxBackoff; extra-- {
+		interval *= 2
+	}
+	if interval > p.MaxBackoff {
+		interval = p.MaxBackoff
+	}
+	return interval
+}
+
+// Due reports whether the next DNS test is due, based on when the previous one ran
+func (p TestPolicy) Due(previous *k8splaygroundsv1alpha1.DNSTestResult, now time.Time) bool {
+	if previous == nil || previous.LastTestedAt == nil {
+		return true
+	}
+	return !now.Before(previous.LastTestedAt.Add(p.NextInterval(previous)))
+}
+
+// Record merges the outcome of a test into the previous result. Healthy only changes once the
+// failure or success threshold is reached; the first test of a service sets it directly.
+func (p TestPolicy) Record(previous, current *k8splaygroundsv1alpha1.DNSTestResult, now time.Time) *k8splaygroundsv1alpha1.DNSTestResult {
+	result := *current
+	tested := metav1.NewTime(now)
+	result.LastTestedAt = &tested
+
+	if previous == nil || previous.LastTestedAt == nil {
+		result.Healthy = result.Success
+		if result.Success {
+			result.ConsecutiveSuccesses = 1
+		} else {
+			result.ConsecutiveFailures = 1
+		}
+	} else {
+		result.Healthy = previous.Healthy
+		if result.Success {
+			result.ConsecutiveSuccesses = previous.ConsecutiveSuccesses + 1
+			if result.ConsecutiveSuccesses >= p.SuccessThreshold {
+				result.Healthy = true
+			}
+		} else {
+			result.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+			if result.ConsecutiveFailures >= p.FailureThreshold {
+				result.Healthy = false
+			}
+		}
+	}
+
+	// Truncation events accumulate so answers that only fit over TCP stay visible
+	if previous != nil {
+		result.TruncationEvents = previous.TruncationEvents
+	}
+	if result.Truncated {
+		result.TruncationEvents++
+	}
+
+	next := metav1.NewTime(now.Add(p.NextInterval(&result)))
+	result.NextTestAt = &next
+	return &result
+}
@@ -0,0 +1,74 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestRecordWaitsForThresholds(t *testing.T) {
+	policy := PolicyFor(&k8splaygroundsv1alpha1.DNSSpec{
+		Testing: &k8splaygroundsv1alpha1.DNSTestingSpec{FailureThreshold: 2, SuccessThreshold: 2},
+	})
+	now := time.Now()
+
+	result := policy.Record(nil, &k8splaygroundsv1alpha1.DNSTestResult{Success: true}, now)
+	if !result.Healthy {
+		t.Fatal("first successful test should be healthy")
+	}
+
+	result = policy.Record(result, &k8splaygroundsv1alpha1.DNSTestResult{Success: false}, now)
+	if !result.Healthy || result.ConsecutiveFailures != 1 {
+		t.Fatalf("single failure flipped status: healthy=%v failures=%d", result.Healthy, result.ConsecutiveFailures)
+	}
+	result = policy.Record(result, &k8splaygroundsv1alpha1.DNSTestResult{Success: false}, now)
+	if result.Healthy {
+		t.Fatal("expected unhealthy after reaching the failure threshold")
+	}
+
+	result = policy.Record(result, &k8splaygroundsv1alpha1.DNSTestResult{Success: true}, now)
+	if result.Healthy || result.ConsecutiveFailures != 0 {
+		t.Fatalf("single success flipped status: healthy=%v failures=%d", result.Healthy, result.ConsecutiveFailures)
+	}
+	result = policy.Record(result, &k8splaygroundsv1alpha1.DNSTestResult{Success: true}, now)
+	if !result.Healthy {
+		t.Fatal("expected healthy after reaching the success threshold")
+	}
+}
+
+func TestNextIntervalBacksOff(t *testing.T) {
+	policy := PolicyFor(&k8splaygroundsv1alpha1.DNSSpec{
+		Testing: &k8splaygroundsv1alpha1.DNSTestingSpec{IntervalSeconds: 30, FailureThreshold: 2, MaxBackoffSeconds: 300},
+	})
+
+	tests := []struct {
+		failures int32
+		healthy  bool
+		want     time.Duration
+	}{
+		{failures: 1, healthy: true, want: 30 * time.Second},
+		{failures: 2, want: 30 * time.Second},
+		{failures: 3, want: time.Minute},
+		{failures: 5, want: 4 * time.Minute},
+		{failures: 40, want: 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		result := &k8splaygroundsv1alpha1.DNSTestResult{Healthy: tt.healthy, ConsecutiveFailures: tt.failures}
+		if got := policy.NextInterval(result); got != tt.want {
+			t.Errorf("NextInterval(failures=%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestRecordCountsTruncation(t *testing.T) {
+	policy := PolicyFor(&k8splaygroundsv1alpha1.DNSSpec{})
+	now := time.Now()
+
+	result := policy.Record(nil, &k8splaygroundsv1alpha1.DNSTestResult{Success: true, Truncated: true}, now)
+	result = policy.Record(result, &k8splaygroundsv1alpha1.DNSTestResult{Success: true}, now)
+	result = policy.Record(result, &k8splaygroundsv1alpha1.DNSTestResult{Success: true, Truncated: true}, now)
+	if result.TruncationEvents != 2 {
+		t.Errorf("TruncationEvents = %d, want 2", result.TruncationEvents)
+	}
+}
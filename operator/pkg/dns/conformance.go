@@ -0,0 +1,136 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// ComparisonServiceName returns the name of the ClusterIP Service created next to a headless
+// service in conformance mode
+func ComparisonServiceName(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	return naming.Name(naming.ClusterIP, headlessService.Name)
+}
+
+// BuildConformanceReport resolves the headless service and its ClusterIP comparison service and
+// explains how their DNS answers differ. endpoints are the ready pod IPs backing both services.
+func (m *Manager) BuildConformanceReport(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, comparison *corev1.Service, endpoints []string) *k8splaygroundsv1alpha1.ConformanceReport {
+	r := newResolver(headlessService.Spec.DNS)
+	clusterDomain := ClusterDomain(headlessService)
+
+	report := &k8splaygroundsv1alpha1.ConformanceReport{
+		Headless: k8splaygroundsv1alpha1.ServiceBehavior{
+			ServiceName:    headlessService.Name,
+			DNSName:        fmt.Sprintf("%s.%s.svc.%s", headlessService.Name, headlessService.Namespace, clusterDomain),
+			ClusterIP:      corev1.ClusterIPNone,
+			LoadBalancedBy: "client",
+		},
+		ClusterIP: k8splaygroundsv1alpha1.ServiceBehavior{
+			ServiceName:    comparison.Name,
+			DNSName:        fmt.Sprintf("%s.%s.svc.%s", comparison.Name, comparison.Namespace, clusterDomain),
+			ClusterIP:      comparison.Spec.ClusterIP,
+			LoadBalancedBy: "kube-proxy",
+		},
+		GeneratedAt: metav1.Now(),
+	}
+
+	resolve := func(behavior *k8splaygroundsv1alpha1.ServiceBehavior) {
+		lookup, err := r.lookup(ctx, behavior.DNSName)
+		if err != nil {
+			behavior.Error = err.Error()
+			return
+		}
+		sort.Strings(lookup.IPs)
+		behavior.DNSAnswers = lookup.IPs
+	}
+	resolve(&report.Headless)
+	resolve(&report.ClusterIP)
+
+	podRecords, err := m.testIndividualPodDNS(ctx, headlessService, r)
+	if err != nil {
+		report.Explanations = append(report.Explanations, fmt.Sprintf("Per-pod records could not be checked: %v", err))
+	}
+	report.PodRecords = podRecords
+
+	report.Conformant, report.Explanations = explainConformance(report, endpoints, headlessService.Spec.DNS.TTL, report.Explanations)
+	return report
+}
+
+// explainConformance checks the observed DNS answers against native Service semantics and
+// returns whether they conform along with a human-readable explanation of each finding
+func explainConformance(report *k8splaygroundsv1alpha1.ConformanceReport, endpoints []string, ttl int32, explanations []string) (bool, []string) {
+	conformant := true
+	headless := report.Headless
+	clusterIP := report.ClusterIP
+
+	if headless.Error != "" {
+		conformant = false
+		explanations = append(explanations, fmt.Sprintf("Headless lookup of %s failed: %s", headless.DNSName, headless.Error))
+	} else {
+		explanations = append(explanations, fmt.Sprintf(
+			"Headless: %s returned %d A record(s), one per ready pod. There is no virtual IP (clusterIP: None), so no kube-proxy rules exist and the client picks an address itself.",
+			headless.DNSName, len(headless.DNSAnswers)))
+
+		missing, unexpected := diff(endpoints, headless.DNSAnswers)
+		if len(missing) > 0 || len(unexpected) > 0 {
+			conformant = false
+			explanations = append(explanations, fmt.Sprintf(
+				"Headless answers differ from the ready endpoints: missing %v, unexpected %v. Records are cached for up to %ds, so recent pod changes may take that long to appear.",
+				missing, unexpected, ttl))
+		}
+	}
+
+	if clusterIP.Error != "" {
+		conformant = false
+		explanations = append(explanations, fmt.Sprintf("ClusterIP lookup of %s failed: %s", clusterIP.DNSName, clusterIP.Error))
+	} else {
+		explanations = append(explanations, fmt.Sprintf(
+			"ClusterIP: %s returned the single virtual IP %v. Connections to it are load-balanced across the %d endpoint(s) by kube-proxy or the CNI's replacement, not by DNS.",
+			clusterIP.DNSName, clusterIP.DNSAnswers, len(endpoints)))
+
+		if len(clusterIP.DNSAnswers) != 1 || clusterIP.DNSAnswers[0] != clusterIP.ClusterIP {
+			conformant = false
+			explanations = append(explanations, fmt.Sprintf(
+				"ClusterIP answers %v do not match the allocated virtual IP %s.", clusterIP.DNSAnswers, clusterIP.ClusterIP))
+		}
+	}
+
+	explanations = append(explanations, fmt.Sprintf(
+		"Per-pod records (<hostname>.%s) exist only for pods that set spec.hostname and spec.subdomain, which StatefulSets do automatically; %d found.",
+		headless.DNSName, len(report.PodRecords)))
+
+	return conformant, explanations
+}
+
+// diff returns the entries of want missing from got and the entries of got not in want
+func diff(want, got []string) ([]string, []string) {
+	inWant := make(map[string]bool, len(want))
+	for _, w := range want {
+		inWant[w] = true
+	}
+	inGot := make(map[string]bool, len(got))
+	for _, g := range got {
+		inGot[g] = true
+	}
+
+	var missing, unexpected []string
+	for _, w := range want {
+		if !inGot[w] {
+			missing = append(missing, w)
+		}
+	}
+	for _, g := range got {
+		if !inWant[g] {
+			unexpected = append(unexpected, g)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
@@ -0,0 +1,15 @@
+package dns
+
+import (
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/clusterdomain"
+)
+
+// ClusterDomain returns the cluster domain of a headless service: its own clusterDomain if
+// set, otherwise the cluster domain of the operator
+func ClusterDomain(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	if headlessService.Spec.DNS == nil {
+		return clusterdomain.Default()
+	}
+	return clusterdomain.Or(headlessService.Spec.DNS.ClusterDomain)
+}
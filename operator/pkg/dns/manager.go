@@ -3,27 +3,30 @@ package dns
 import (
 	"context"
 	"fmt"
-	"net"
-	"strings"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/endpoints"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
 )
 
 // Manager handles DNS operations for headless services
 type Manager struct {
-	client client.Client
+	client     client.Client
+	helperPods helperpods.Config
 }
 
 // NewManager creates a new DNS manager
-func NewManager(client client.Client) *Manager {
+func NewManager(client client.Client, helperPods helperpods.Config) *Manager {
 	return &Manager{
-		client: client,
+		client:     client,
+		helperPods: helperPods,
 	}
 }
 
@@ -37,65 +40,45 @@ func (m *Manager) TestDNSResolution(ctx context.Context, headlessService *k8spla
 		headlessService.Namespace,
 		headlessService.Spec.DNS.ClusterDomain)
 
-	// Get DNS server
-	dnsServer := headlessService.Spec.DNS.DNSServer
-	if dnsServer == "" {
-		dnsServer = "8.8.8.8" // Default to Google DNS
+	// Test service DNS resolution. Services with many endpoints exceed the UDP payload size,
+	// so truncated answers are retried over TCP unless the spec forbids it.
+	r := newResolver(headlessService.Spec.DNS)
+	lookup, err := r.lookup(ctx, serviceDNS)
+	if lookup.Truncated {
+		log.Info("DNS response truncated over UDP", "serviceDNS", serviceDNS, "bytes", lookup.ResponseBytes, "transport", lookup.Transport)
 	}
-
-	// Test service DNS resolution
-	resolvedIPs, err := m.resolveDNS(serviceDNS, dnsServer)
 	if err != nil {
 		return &k8splaygroundsv1alpha1.DNSTestResult{
-			ServiceDNS:   serviceDNS,
-			ResolvedIPs:  []string{},
-			Success:      false,
-			ErrorMessage: err.Error(),
+			ServiceDNS:    serviceDNS,
+			ResolvedIPs:   []string{},
+			Success:       false,
+			ErrorMessage:  err.Error(),
+			ServerError:   IsServerError(err),
+			Transport:     lookup.Transport,
+			Truncated:     lookup.Truncated,
+			ResponseBytes: int32(lookup.ResponseBytes),
 		}, nil
 	}
 
 	// Test individual pod DNS resolution
-	individualPodDNS, err := m.testIndividualPodDNS(ctx, headlessService, dnsServer)
+	individualPodDNS, err := m.testIndividualPodDNS(ctx, headlessService, r)
 	if err != nil {
 		log.Error(err, "failed to test individual pod DNS")
 	}
 
 	return &k8splaygroundsv1alpha1.DNSTestResult{
 		ServiceDNS:       serviceDNS,
-		ResolvedIPs:      resolvedIPs,
+		ResolvedIPs:      lookup.IPs,
 		IndividualPodDNS: individualPodDNS,
 		Success:          true,
+		Transport:        lookup.Transport,
+		Truncated:        lookup.Truncated,
+		ResponseBytes:    int32(lookup.ResponseBytes),
 	}, nil
 }
 
-// resolveDNS resolves a hostname to IP addresses
-func (m *Manager) resolveDNS(hostname, dnsServer string) ([]string, error) {
-	// Create a custom resolver
-	resolver := &net.Resolver{
-		PreferGo: true,
-		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{}
-			return d.DialContext(ctx, network, dnsServer+":53")
-		},
-	}
-
-	// Resolve the hostname
-	ips, err := resolver.LookupIPAddr(context.Background(), hostname)
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert to string slice
-	result := make([]string, len(ips))
-	for i, ip := range ips {
-		result[i] = ip.IP.String()
-	}
-
-	return result, nil
-}
-
 // testIndividualPodDNS tests DNS resolution for individual pods
-func (m *Manager) testIndividualPodDNS(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, dnsServer string) ([]k8splaygroundsv1alpha1.PodDNSRecord, error) {
+func (m *Manager) testIndividualPodDNS(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, r *resolver) ([]k8splaygroundsv1alpha1.PodDNSRecord, error) {
 	// Get pods that match the selector
 	pods := &corev1.PodList{}
 	selector := client.MatchingLabels(headlessService.Spec.Selector)
@@ -116,28 +99,25 @@ func (m *Manager) testIndividualPodDNS(ctx context.Context, headlessService *k8s
 			headlessService.Spec.DNS.ClusterDomain)
 
 		// Resolve pod DNS
-		resolvedIPs, err := m.resolveDNS(podDNS, dnsServer)
-		if err != nil {
+		if _, err := r.lookup(ctx, podDNS); err != nil {
 			continue // Skip failed resolutions
 		}
 
-		if len(resolvedIPs) > 0 {
-			podDNSRecords = append(podDNSRecords, k8splaygroundsv1alpha1.PodDNSRecord{
-				PodName: pod.Name,
-				PodIP:   pod.Status.PodIP,
-				DNSName: podDNS,
-			})
-		}
+		podDNSRecords = append(podDNSRecords, k8splaygroundsv1alpha1.PodDNSRecord{
+			PodName: pod.Name,
+			PodIP:   pod.Status.PodIP,
+			DNSName: podDNS,
+		})
 	}
 
 	return podDNSRecords, nil
 }
 
-// ConfigureDNSConfigMap creates a ConfigMap with DNS configuration
+// ConfigureDNSConfigMap applies a ConfigMap with DNS configuration
 func (m *Manager) ConfigureDNSConfigMap(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-dns-config", headlessService.Name),
+			Name:      naming.Name(naming.DNSConfig, headlessService.Name),
 			Namespace: headlessService.Namespace,
 			Labels: map[string]string{
 				"app.kubernetes.io/name":     "headless-service-dns",
@@ -164,21 +144,7 @@ func (m *Manager) ConfigureDNSConfigMap(ctx context.Context, headlessService *k8
 		},
 	}
 
-	// Create or update the ConfigMap
-	if err := m.client.Create(ctx, configMap); err != nil {
-		if !strings.Contains(err.Error(), "already exists") {
-			return err
-		}
-		// Update existing ConfigMap
-		existingConfigMap := &corev1.ConfigMap{}
-		if err := m.client.Get(ctx, types.NamespacedName{Name: configMap.Name, Namespace: configMap.Namespace}, existingConfigMap); err != nil {
-			return err
-		}
-		existingConfigMap.Data = configMap.Data
-		return m.client.Update(ctx, existingConfigMap)
-	}
-
-	return nil
+	return apply.Apply(ctx, m.client, configMap)
 }
 
 // ValidateDNSConfiguration validates DNS configuration
@@ -195,35 +161,33 @@ func (m *Manager) ValidateDNSConfiguration(headlessService *k8splaygroundsv1alph
 		return fmt.Errorf("TTL must be non-negative")
 	}
 
+	if err := validateTransport(headlessService.Spec.DNS); err != nil {
+		return err
+	}
+
 	return nil
 }
 
-// GetServiceEndpoints returns the endpoints for a headless service
+// GetServiceEndpoints returns the ready addresses of the EndpointSlices of a headless service
 func (m *Manager) GetServiceEndpoints(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) ([]string, error) {
-	// Get the endpoints for the service
-	endpoints := &corev1.Endpoints{}
-	if err := m.client.Get(ctx, types.NamespacedName{
-		Name:      headlessService.Name,
-		Namespace: headlessService.Namespace,
-	}, endpoints); err != nil {
+	slices, err := endpoints.NewManager(m.client).GetEndpointSlices(ctx, headlessService)
+	if err != nil {
 		return nil, err
 	}
-
-	var endpointIPs []string
-	for _, subset := range endpoints.Subsets {
-		for _, address := range subset.Addresses {
-			endpointIPs = append(endpointIPs, address.IP)
-		}
-	}
-
-	return endpointIPs, nil
+	return endpoints.ReadyAddresses(slices), nil
 }
 
-// CreateDNSTestPod creates a pod for testing DNS resolution
+// CreateDNSTestPod creates a pod for testing DNS resolution, unless the namespace already runs
+// as many helper pods as allowed
 func (m *Manager) CreateDNSTestPod(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	helperPods, err := m.helperPods.For(headlessService)
+	if err != nil {
+		return err
+	}
+
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-dns-test", headlessService.Name),
+			Name:      naming.Name(naming.DNSTest, headlessService.Name),
 			Namespace: headlessService.Namespace,
 			Labels: map[string]string{
 				"app.kubernetes.io/name":     "dns-test",
@@ -250,15 +214,19 @@ func (m *Manager) CreateDNSTestPod(ctx context.Context, headlessService *k8splay
 			RestartPolicy: corev1.RestartPolicyNever,
 		},
 	}
+	helperPods.Apply(&pod.ObjectMeta, &pod.Spec)
 
-	return m.client.Create(ctx, pod)
+	if err := helperPods.Reserve(ctx, m.client, headlessService.Namespace); err != nil {
+		return err
+	}
+	return apply.Apply(ctx, m.client, pod)
 }
 
 // CleanupDNSTestPod cleans up the DNS test pod
 func (m *Manager) CleanupDNSTestPod(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-dns-test", headlessService.Name),
+			Name:      naming.Name(naming.DNSTest, headlessService.Name),
 			Namespace: headlessService.Namespace,
 		},
 	}
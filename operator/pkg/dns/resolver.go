@@ -0,0 +1,287 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Transports used for DNS tests
+const (
+	// TransportAuto queries over UDP and retries over TCP when the answer is truncated
+	TransportAuto = "auto"
+	// TransportUDP only queries over UDP, failing on truncated answers
+	TransportUDP = "udp"
+	// TransportTCP only queries over TCP
+	TransportTCP = "tcp"
+)
+
+const (
+	// DefaultUDPBufferSize is the EDNS0 UDP payload size advertised in queries. It avoids IP
+	// fragmentation on common paths, as recommended by DNS Flag Day 2020.
+	DefaultUDPBufferSize = 1232
+	// minUDPBufferSize is the payload size every DNS server supports without EDNS0
+	minUDPBufferSize = 512
+
+	defaultDNSServer = "8.8.8.8"
+	queryTimeout     = 5 * time.Second
+)
+
+// lookupResult is the outcome of resolving a name
+type lookupResult struct {
+	IPs []string
+	// Transport is the transport of the final answer
+	Transport string
+	// Truncated is set when a UDP answer had the TC bit set
+	Truncated bool
+	// ResponseBytes is the size of the largest response received
+	ResponseBytes int
+}
+
+// serverError is a failure of the DNS server itself, as opposed to an answer saying the name
+// does not exist
+type serverError struct {
+	err error
+}
+
+func (e *serverError) Error() string { return e.err.Error() }
+func (e *serverError) Unwrap() error { return e.err }
+
+// IsServerError reports whether a DNS test failed because the DNS server timed out, could not be
+// reached or answered with SERVFAIL or REFUSED, rather than because of the tested name
+func IsServerError(err error) bool {
+	var target *serverError
+	return errors.As(err, &target)
+}
+
+// resolver sends A and AAAA queries with EDNS0 to a single DNS server
+type resolver struct {
+	server     string
+	transport  string
+	bufferSize int
+}
+
+// newResolver creates a resolver for the DNS settings of a headless service
+func newResolver(spec *k8splaygroundsv1alpha1.DNSSpec) *resolver {
+	r := &resolver{
+		server:     defaultDNSServer,
+		transport:  TransportAuto,
+		bufferSize: DefaultUDPBufferSize,
+	}
+	if spec != nil {
+		if spec.DNSServer != "" {
+			r.server = spec.DNSServer
+		}
+		if spec.Transport != "" {
+			r.transport = spec.Transport
+		}
+		if spec.UDPBufferSize > 0 {
+			r.bufferSize = int(spec.UDPBufferSize)
+		}
+	}
+	if _, _, err := net.SplitHostPort(r.server); err != nil {
+		r.server = net.JoinHostPort(r.server, "53")
+	}
+	return r
+}
+
+// validateTransport checks the transport and UDP buffer size of a DNS spec
+func validateTransport(spec *k8splaygroundsv1alpha1.DNSSpec) error {
+	switch spec.Transport {
+	case "", TransportAuto, TransportUDP, TransportTCP:
+	default:
+		return fmt.Errorf("unsupported DNS transport: %s", spec.Transport)
+	}
+	if spec.UDPBufferSize != 0 && (spec.UDPBufferSize < minUDPBufferSize || spec.UDPBufferSize > 65535) {
+		return fmt.Errorf("UDP buffer size must be between %d and 65535", minUDPBufferSize)
+	}
+	return nil
+}
+
+// lookup resolves the A and AAAA records of a name
+func (r *resolver) lookup(ctx context.Context, name string) (*lookupResult, error) {
+	result := &lookupResult{}
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		ips, err := r.query(ctx, name, qtype, result)
+		if err != nil {
+			return result, err
+		}
+		result.IPs = append(result.IPs, ips...)
+	}
+	if len(result.IPs) == 0 {
+		return result, fmt.Errorf("lookup %s: no addresses", name)
+	}
+	return result, nil
+}
+
+// query sends one question, falling back to TCP when the UDP answer is truncated
+func (r *resolver) query(ctx context.Context, name string, qtype dnsmessage.Type, result *lookupResult) ([]string, error) {
+	id := uint16(rand.Intn(1 << 16))
+	msg, err := buildQuery(id, name, qtype, r.bufferSize)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+
+	if r.transport != TransportTCP {
+		response, size, err := exchangeUDP(ctx, r.server, msg, r.bufferSize)
+		if err != nil {
+			return nil, &serverError{err: err}
+		}
+		if size > result.ResponseBytes {
+			result.ResponseBytes = size
+		}
+		if !response.Header.Truncated {
+			if result.Transport == "" {
+				result.Transport = TransportUDP
+			}
+			return parseAnswers(name, id, response)
+		}
+
+		result.Truncated = true
+		if r.transport == TransportUDP {
+			return nil, fmt.Errorf("lookup %s: response truncated at %d bytes over UDP and TCP fallback is disabled", name, size)
+		}
+	}
+
+	response, size, err := exchangeTCP(ctx, r.server, msg)
+	if err != nil {
+		return nil, &serverError{err: err}
+	}
+	if size > result.ResponseBytes {
+		result.ResponseBytes = size
+	}
+	result.Transport = TransportTCP
+	return parseAnswers(name, id, response)
+}
+
+// buildQuery encodes a recursive query advertising the EDNS0 UDP payload size
+func buildQuery(id uint16, name string, qtype dnsmessage.Type, bufferSize int) ([]byte, error) {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS name %s: %w", name, err)
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(bufferSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// parseAnswers returns the addresses in a response
+func parseAnswers(name string, id uint16, response *dnsmessage.Message) ([]string, error) {
+	if response.Header.ID != id {
+		return nil, fmt.Errorf("lookup %s: response ID mismatch", name)
+	}
+	switch response.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeServerFailure, dnsmessage.RCodeRefused:
+		return nil, &serverError{err: fmt.Errorf("lookup %s: %s", name, response.Header.RCode)}
+	default:
+		return nil, fmt.Errorf("lookup %s: %s", name, response.Header.RCode)
+	}
+
+	var ips []string
+	for _, answer := range response.Answers {
+		switch body := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			ips = append(ips, net.IP(body.A[:]).String())
+		case *dnsmessage.AAAAResource:
+			ips = append(ips, net.IP(body.AAAA[:]).String())
+		}
+	}
+	return ips, nil
+}
+
+// exchangeUDP sends a query over UDP and returns the response and its size
+func exchangeUDP(ctx context.Context, server string, query []byte, bufferSize int) (*dnsmessage.Message, int, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, bufferSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	response := &dnsmessage.Message{}
+	if err := response.Unpack(buf[:n]); err != nil {
+		return nil, n, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	return response, n, nil
+}
+
+// exchangeTCP sends a length-prefixed query over TCP and returns the response and its size
+func exchangeTCP(ctx context.Context, server string, query []byte) (*dnsmessage.Message, int, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	framed := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(framed, uint16(len(query)))
+	copy(framed[2:], query)
+	if _, err := conn.Write(framed); err != nil {
+		return nil, 0, err
+	}
+
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, 0, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return nil, 0, err
+	}
+
+	response := &dnsmessage.Message{}
+	if err := response.Unpack(buf); err != nil {
+		return nil, len(buf), fmt.Errorf("invalid DNS response: %w", err)
+	}
+	return response, len(buf), nil
+}
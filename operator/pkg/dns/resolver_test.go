@@ -0,0 +1,224 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// fakeServer answers A queries with a fixed number of records. UDP answers that do not fit the
+// advertised EDNS0 payload size are truncated, like a real server.
+type fakeServer struct {
+	addr    string
+	records int
+	rcode   dnsmessage.RCode
+}
+
+func startFakeServer(t *testing.T, records int) *fakeServer {
+	t.Helper()
+	return startServer(t, &fakeServer{records: records})
+}
+
+// startServer starts a fake server answering every query with the rcode of s
+func startServer(t *testing.T, s *fakeServer) *fakeServer {
+	t.Helper()
+
+	// UDP and TCP must share a port, so retry until both are free
+	var listener net.Listener
+	var packetConn net.PacketConn
+	for attempt := 0; ; attempt++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pc, err := net.ListenPacket("udp", l.Addr().String())
+		if err == nil {
+			listener, packetConn = l, pc
+			break
+		}
+		l.Close()
+		if attempt == 10 {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		packetConn.Close()
+	})
+
+	s.addr = listener.Addr().String()
+	go s.serveUDP(packetConn)
+	go s.serveTCP(listener)
+	return s
+}
+
+func (s *fakeServer) serveUDP(pc net.PacketConn) {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := &dnsmessage.Message{}
+		if query.Unpack(buf[:n]) != nil {
+			continue
+		}
+		limit := minUDPBufferSize
+		for _, additional := range query.Additionals {
+			if additional.Header.Type == dnsmessage.TypeOPT {
+				limit = int(additional.Header.Class)
+			}
+		}
+		response := s.answer(query, false)
+		if len(response) > limit {
+			response = s.answer(query, true)
+		}
+		pc.WriteTo(response, addr)
+	}
+}
+
+func (s *fakeServer) serveTCP(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err == nil {
+			buf := make([]byte, binary.BigEndian.Uint16(length[:]))
+			query := &dnsmessage.Message{}
+			if _, err := io.ReadFull(conn, buf); err == nil && query.Unpack(buf) == nil {
+				response := s.answer(query, false)
+				binary.BigEndian.PutUint16(length[:], uint16(len(response)))
+				conn.Write(append(length[:], response...))
+			}
+		}
+		conn.Close()
+	}
+}
+
+func (s *fakeServer) answer(query *dnsmessage.Message, truncated bool) []byte {
+	question := query.Questions[0]
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.Header.ID, Response: true, Truncated: truncated, RCode: s.rcode},
+		Questions: query.Questions,
+	}
+	if question.Type == dnsmessage.TypeA && !truncated {
+		for i := 0; i < s.records; i++ {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 30},
+				Body:   &dnsmessage.AResource{A: [4]byte{10, 0, byte(i / 256), byte(i % 256)}},
+			})
+		}
+	}
+	packed, err := response.Pack()
+	if err != nil {
+		panic(err)
+	}
+	return packed
+}
+
+func TestResolverSmallAnswerOverUDP(t *testing.T) {
+	server := startFakeServer(t, 3)
+	r := newResolver(&k8splaygroundsv1alpha1.DNSSpec{DNSServer: server.addr})
+
+	result, err := r.lookup(context.Background(), "web.default.svc.cluster.local")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(result.IPs) != 3 || result.Transport != TransportUDP || result.Truncated {
+		t.Errorf("lookup() = %+v, want 3 addresses over UDP", result)
+	}
+}
+
+func TestResolverFallsBackToTCP(t *testing.T) {
+	server := startFakeServer(t, 300)
+	r := newResolver(&k8splaygroundsv1alpha1.DNSSpec{DNSServer: server.addr})
+
+	result, err := r.lookup(context.Background(), "web.default.svc.cluster.local")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(result.IPs) != 300 || result.Transport != TransportTCP || !result.Truncated {
+		t.Errorf("lookup() = %d addresses over %s (truncated %v), want 300 over TCP after truncation", len(result.IPs), result.Transport, result.Truncated)
+	}
+	if result.ResponseBytes <= DefaultUDPBufferSize {
+		t.Errorf("ResponseBytes = %d, want the size of the TCP answer", result.ResponseBytes)
+	}
+}
+
+func TestResolverLargerBufferAvoidsTruncation(t *testing.T) {
+	server := startFakeServer(t, 300)
+	r := newResolver(&k8splaygroundsv1alpha1.DNSSpec{DNSServer: server.addr, UDPBufferSize: 8192})
+
+	result, err := r.lookup(context.Background(), "web.default.svc.cluster.local")
+	if err != nil {
+		t.Fatalf("lookup() error = %v", err)
+	}
+	if len(result.IPs) != 300 || result.Transport != TransportUDP || result.Truncated {
+		t.Errorf("lookup() = %d addresses over %s (truncated %v), want 300 over UDP", len(result.IPs), result.Transport, result.Truncated)
+	}
+}
+
+func TestResolverUDPOnlyReportsTruncation(t *testing.T) {
+	server := startFakeServer(t, 300)
+	r := newResolver(&k8splaygroundsv1alpha1.DNSSpec{DNSServer: server.addr, Transport: TransportUDP})
+
+	result, err := r.lookup(context.Background(), "web.default.svc.cluster.local")
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("lookup() error = %v, want a truncation error", err)
+	}
+	if !result.Truncated {
+		t.Error("expected the result to be marked truncated")
+	}
+}
+
+func TestResolverClassifiesServerErrors(t *testing.T) {
+	for _, tt := range []struct {
+		rcode dnsmessage.RCode
+		want  bool
+	}{
+		{rcode: dnsmessage.RCodeServerFailure, want: true},
+		{rcode: dnsmessage.RCodeRefused, want: true},
+		{rcode: dnsmessage.RCodeNameError, want: false},
+	} {
+		server := startServer(t, &fakeServer{records: 1, rcode: tt.rcode})
+		r := newResolver(&k8splaygroundsv1alpha1.DNSSpec{DNSServer: server.addr})
+
+		_, err := r.lookup(context.Background(), "web.default.svc.cluster.local")
+		if err == nil || IsServerError(err) != tt.want {
+			t.Errorf("lookup() with %s error = %v, want server error %v", tt.rcode, err, tt.want)
+		}
+	}
+
+	// A server that cannot be reached is a server error too
+	r := newResolver(&k8splaygroundsv1alpha1.DNSSpec{DNSServer: "127.0.0.1:1", Transport: TransportTCP})
+	if _, err := r.lookup(context.Background(), "web.default.svc.cluster.local"); !IsServerError(err) {
+		t.Errorf("lookup() from an unreachable server error = %v, want a server error", err)
+	}
+}
+
+func TestValidateTransport(t *testing.T) {
+	tests := []struct {
+		spec    k8splaygroundsv1alpha1.DNSSpec
+		wantErr bool
+	}{
+		{spec: k8splaygroundsv1alpha1.DNSSpec{}},
+		{spec: k8splaygroundsv1alpha1.DNSSpec{Transport: TransportTCP, UDPBufferSize: 4096}},
+		{spec: k8splaygroundsv1alpha1.DNSSpec{Transport: "quic"}, wantErr: true},
+		{spec: k8splaygroundsv1alpha1.DNSSpec{UDPBufferSize: 256}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := validateTransport(&tt.spec); (err != nil) != tt.wantErr {
+			t.Errorf("validateTransport(%s) error = %v, wantErr %v", fmt.Sprintf("%+v", tt.spec), err, tt.wantErr)
+		}
+	}
+}
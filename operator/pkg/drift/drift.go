@@ -0,0 +1,64 @@
+package drift
+
+import (
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Compare returns the sorted names of fields whose desired value differs from the
+// value reported by the Aviatrix Controller. Empty desired values, and fields the Controller
+// did not report, are not compared.
+func Compare(desired, observed map[string]string) []string {
+	var drifted []string
+	for field, want := range desired {
+		if want == "" {
+			continue
+		}
+		got := observed[field]
+		if got == "" {
+			continue
+		}
+		if got != want {
+			drifted = append(drifted, field)
+		}
+	}
+	sort.Strings(drifted)
+	return drifted
+}
+
+// State records when drift was first detected for a resource
+type State struct {
+	// DetectedAt is when drift was first observed, nil when the resource is in sync
+	DetectedAt *metav1.Time
+	// Fields are the fields currently out of sync
+	Fields []string
+}
+
+// Track updates the drift state from the latest comparison. The first-detection
+// timestamp is kept while drift persists and cleared once the resource converges.
+// When the resource converges, the time it spent out of sync is returned.
+func Track(state State, drifted []string, now time.Time) (State, time.Duration) {
+	if len(drifted) == 0 {
+		var converged time.Duration
+		if state.DetectedAt != nil {
+			converged = now.Sub(state.DetectedAt.Time)
+		}
+		return State{}, converged
+	}
+
+	detectedAt := state.DetectedAt
+	if detectedAt == nil {
+		detectedAt = &metav1.Time{Time: now}
+	}
+	return State{DetectedAt: detectedAt, Fields: drifted}, 0
+}
+
+// Age returns how long the resource has been out of sync
+func (s State) Age(now time.Time) time.Duration {
+	if s.DetectedAt == nil {
+		return 0
+	}
+	return now.Sub(s.DetectedAt.Time)
+}
@@ -0,0 +1,49 @@
+package drift
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	got := Compare(map[string]string{
+		"gw_size": "t3.small",
+		"vpc_id":  "vpc-1",
+		"cidr":    "",
+		"region":  "us-east-1",
+	}, map[string]string{
+		"gw_size": "t3.large",
+		"vpc_id":  "vpc-1",
+		"cidr":    "10.0.0.0/16",
+	})
+	if want := []string{"gw_size"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Compare() = %v, want %v", got, want)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	state, converged := Track(State{}, []string{"gw_size"}, start)
+	if state.DetectedAt == nil || !state.DetectedAt.Time.Equal(start) || converged != 0 {
+		t.Fatalf("Track() = %+v, %v, want drift detected at %v", state, converged, start)
+	}
+
+	// The first detection is kept while the drift persists, even as the fields change
+	state, _ = Track(state, []string{"gw_size", "vpc_id"}, start.Add(time.Minute))
+	if !state.DetectedAt.Time.Equal(start) || len(state.Fields) != 2 {
+		t.Fatalf("Track() = %+v, want drift detected at %v on 2 fields", state, start)
+	}
+	if age := state.Age(start.Add(time.Hour)); age != time.Hour {
+		t.Errorf("Age() = %v, want 1h", age)
+	}
+
+	state, converged = Track(state, nil, start.Add(10*time.Minute))
+	if state.DetectedAt != nil || converged != 10*time.Minute {
+		t.Errorf("Track() = %+v, %v, want in sync after 10m", state, converged)
+	}
+	if age := state.Age(start.Add(time.Hour)); age != 0 {
+		t.Errorf("Age() = %v in sync, want 0", age)
+	}
+}
@@ -0,0 +1,187 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+const (
+	// ExternalManagedBy is the managed-by label of the EndpointSlices publishing external
+	// endpoints. The EndpointSlice controller of Kubernetes leaves slices of other managers
+	// alone, while cluster DNS answers from every slice of the Service.
+	ExternalManagedBy = "k8s-playgrounds-operator"
+
+	// ExternalResolveInterval is how often the hostnames of external endpoints are resolved
+	// again, so that failovers of e.g. a managed database are picked up
+	ExternalResolveInterval = time.Minute
+)
+
+// LookupHost resolves the hostnames of external endpoints
+var LookupHost = net.DefaultResolver.LookupHost
+
+// ValidateExternal checks the external endpoints of a headless service: unique DNS label names
+// and exactly one of a valid IP and hostname
+func ValidateExternal(headlessService *k8splaygroundsv1alpha1.HeadlessService) field.ErrorList {
+	var errs field.ErrorList
+	names := map[string]bool{}
+	for i, external := range headlessService.Spec.ExternalEndpoints {
+		path := field.NewPath("spec", "externalEndpoints").Index(i)
+		for _, msg := range validation.IsDNS1123Label(external.Name) {
+			errs = append(errs, field.Invalid(path.Child("name"), external.Name, msg))
+		}
+		if names[external.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), external.Name))
+		}
+		names[external.Name] = true
+
+		switch {
+		case (external.IP == "") == (external.Hostname == ""):
+			errs = append(errs, field.Invalid(path, external.Name, "exactly one of ip and hostname must be set"))
+		case external.IP != "":
+			if ip := net.ParseIP(external.IP); ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+				errs = append(errs, field.Invalid(path.Child("ip"), external.IP, "must be a routable IPv4 or IPv6 address"))
+			}
+		default:
+			for _, msg := range validation.IsDNS1123Subdomain(external.Hostname) {
+				errs = append(errs, field.Invalid(path.Child("hostname"), external.Hostname, msg))
+			}
+		}
+	}
+	return errs
+}
+
+// HasExternalHostnames reports whether external endpoints need to be resolved periodically
+func HasExternalHostnames(headlessService *k8splaygroundsv1alpha1.HeadlessService) bool {
+	for _, external := range headlessService.Spec.ExternalEndpoints {
+		if external.Hostname != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ExternalIPs returns the published addresses of the external endpoints, sorted; with
+// loadBalanced only those of endpoints that opted into iptables load balancing
+func ExternalIPs(headlessService *k8splaygroundsv1alpha1.HeadlessService, loadBalanced bool) []string {
+	wanted := map[string]bool{}
+	for _, external := range headlessService.Spec.ExternalEndpoints {
+		wanted[external.Name] = !loadBalanced || external.LoadBalance
+	}
+	var ips []string
+	for _, status := range headlessService.Status.ExternalEndpoints {
+		if wanted[status.Name] {
+			ips = append(ips, status.Addresses...)
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// ReconcileExternal resolves the external endpoints of a headless service and publishes them
+// in EndpointSlices of its Service, one per address family, with the endpoint name as
+// hostname. A hostname that fails to resolve keeps its previous addresses.
+func (m *Manager) ReconcileExternal(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	previous := map[string]k8splaygroundsv1alpha1.ExternalEndpointStatus{}
+	for _, status := range headlessService.Status.ExternalEndpoints {
+		previous[status.Name] = status
+	}
+
+	var statuses []k8splaygroundsv1alpha1.ExternalEndpointStatus
+	byFamily := map[discoveryv1.AddressType][]discoveryv1.Endpoint{}
+	for _, external := range headlessService.Spec.ExternalEndpoints {
+		status := resolveExternal(ctx, external, previous[external.Name])
+		statuses = append(statuses, status)
+
+		name := external.Name
+		ready := true
+		for _, address := range status.Addresses {
+			family := discoveryv1.AddressTypeIPv4
+			if net.ParseIP(address).To4() == nil {
+				family = discoveryv1.AddressTypeIPv6
+			}
+			byFamily[family] = append(byFamily[family], discoveryv1.Endpoint{
+				Addresses:  []string{address},
+				Hostname:   &name,
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			})
+		}
+	}
+	headlessService.Status.ExternalEndpoints = statuses
+
+	for _, family := range []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4, discoveryv1.AddressTypeIPv6} {
+		if err := m.applyExternalSlice(ctx, headlessService, family, byFamily[family]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveExternal returns the addresses of an external endpoint
+func resolveExternal(ctx context.Context, external k8splaygroundsv1alpha1.ExternalEndpointSpec, previous k8splaygroundsv1alpha1.ExternalEndpointStatus) k8splaygroundsv1alpha1.ExternalEndpointStatus {
+	now := metav1.Now()
+	if external.IP != "" {
+		return k8splaygroundsv1alpha1.ExternalEndpointStatus{Name: external.Name, Addresses: []string{external.IP}, ResolvedAt: &now}
+	}
+
+	addresses, err := LookupHost(ctx, external.Hostname)
+	if err != nil {
+		return k8splaygroundsv1alpha1.ExternalEndpointStatus{
+			Name:       external.Name,
+			Addresses:  previous.Addresses,
+			Error:      fmt.Sprintf("failed to resolve %s: %v", external.Hostname, err),
+			ResolvedAt: previous.ResolvedAt,
+		}
+	}
+	sort.Strings(addresses)
+	return k8splaygroundsv1alpha1.ExternalEndpointStatus{Name: external.Name, Addresses: addresses, ResolvedAt: &now}
+}
+
+// applyExternalSlice applies the EndpointSlice of an address family, deleting it when the
+// family has no endpoints
+func (m *Manager) applyExternalSlice(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, family discoveryv1.AddressType, endpoints []discoveryv1.Endpoint) error {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ExternalSliceName(headlessService, family),
+			Namespace: headlessService.Namespace,
+		},
+	}
+	if len(endpoints) == 0 {
+		return client.IgnoreNotFound(m.client.Delete(ctx, slice))
+	}
+
+	slice.Labels = map[string]string{
+		discoveryv1.LabelServiceName: headlessService.Name,
+		discoveryv1.LabelManagedBy:   ExternalManagedBy,
+	}
+	slice.AddressType = family
+	slice.Endpoints = endpoints
+	slice.Ports = slicePorts(headlessService)
+	if err := controllerutil.SetControllerReference(headlessService, slice, m.client.Scheme()); err != nil {
+		return fmt.Errorf("failed to set owner of endpoint slice %s: %w", slice.Name, err)
+	}
+	return apply.Apply(ctx, m.client, slice)
+}
+
+// ExternalSliceName returns the name of the EndpointSlice publishing the external endpoints of
+// an address family
+func ExternalSliceName(headlessService *k8splaygroundsv1alpha1.HeadlessService, family discoveryv1.AddressType) string {
+	qualifier := "ipv4"
+	if family == discoveryv1.AddressTypeIPv6 {
+		qualifier = "ipv6"
+	}
+	return naming.Qualified(naming.ExternalEndpoints, headlessService.Name, qualifier)
+}
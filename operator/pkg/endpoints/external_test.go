@@ -0,0 +1,107 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+func externalService(externals ...k8splaygroundsv1alpha1.ExternalEndpointSpec) *k8splaygroundsv1alpha1.HeadlessService {
+	return &k8splaygroundsv1alpha1.HeadlessService{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop", UID: "uid"},
+		Spec: k8splaygroundsv1alpha1.HeadlessServiceSpec{
+			Ports:             []k8splaygroundsv1alpha1.ServicePort{{Name: "pg", Port: 5432}},
+			ExternalEndpoints: externals,
+		},
+	}
+}
+
+func TestValidateExternal(t *testing.T) {
+	valid := externalService(
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "db", Hostname: "orders.abc.us-east-1.rds.amazonaws.com"},
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "cache", IP: "10.20.0.5", LoadBalance: true},
+	)
+	if errs := ValidateExternal(valid); len(errs) > 0 {
+		t.Errorf("ValidateExternal() = %v", errs)
+	}
+
+	invalid := externalService(
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "DB", IP: "10.20.0.5"},
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "both", IP: "10.20.0.5", Hostname: "db.example.com"},
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "local", IP: "127.0.0.1"},
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "local", Hostname: "db_1.example.com"},
+	)
+	if errs := ValidateExternal(invalid); len(errs) != 5 {
+		t.Errorf("ValidateExternal() = %v, want 5 errors", errs)
+	}
+}
+
+func TestReconcileExternal(t *testing.T) {
+	addresses := map[string][]string{"orders.rds.example.com": {"10.30.0.8", "fd00::8"}}
+	defer func(lookup func(context.Context, string) ([]string, error)) { LookupHost = lookup }(LookupHost)
+	LookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if ips, ok := addresses[host]; ok {
+			return ips, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	c := fake.NewClientBuilder().WithScheme(sliceScheme(t)).WithInterceptorFuncs(applytest.Funcs()).Build()
+	m := NewManager(c)
+	headlessService := externalService(
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "db", Hostname: "orders.rds.example.com"},
+		k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "cache", IP: "10.20.0.5", LoadBalance: true},
+	)
+	if err := m.ReconcileExternal(context.Background(), headlessService); err != nil {
+		t.Fatalf("ReconcileExternal() error = %v", err)
+	}
+
+	slice := &discoveryv1.EndpointSlice{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "shop", Name: "orders-external-ipv4"}, slice); err != nil {
+		t.Fatalf("IPv4 slice: %v", err)
+	}
+	if slice.Labels[discoveryv1.LabelServiceName] != "orders" || slice.Labels[discoveryv1.LabelManagedBy] != ExternalManagedBy {
+		t.Errorf("slice labels = %v", slice.Labels)
+	}
+	var records []string
+	for _, endpoint := range slice.Endpoints {
+		records = append(records, *endpoint.Hostname+"="+endpoint.Addresses[0])
+	}
+	if fmt.Sprint(records) != "[db=10.30.0.8 cache=10.20.0.5]" {
+		t.Errorf("IPv4 records = %v", records)
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "shop", Name: "orders-external-ipv6"}, slice); err != nil || slice.AddressType != discoveryv1.AddressTypeIPv6 {
+		t.Errorf("IPv6 slice = %v, %v", slice.AddressType, err)
+	}
+
+	if got := fmt.Sprint(ExternalIPs(headlessService, false)); got != "[10.20.0.5 10.30.0.8 fd00::8]" {
+		t.Errorf("ExternalIPs() = %s", got)
+	}
+	if got := fmt.Sprint(ExternalIPs(headlessService, true)); got != "[10.20.0.5]" {
+		t.Errorf("load balanced ExternalIPs() = %s, want the opted-in endpoint only", got)
+	}
+
+	// A failing lookup keeps the last addresses; dropping the hostname removes the IPv6 slice
+	delete(addresses, "orders.rds.example.com")
+	if err := m.ReconcileExternal(context.Background(), headlessService); err != nil {
+		t.Fatalf("ReconcileExternal() error = %v", err)
+	}
+	if status := headlessService.Status.ExternalEndpoints[0]; status.Error == "" || len(status.Addresses) != 2 {
+		t.Errorf("status after failed lookup = %+v", status)
+	}
+	headlessService.Spec.ExternalEndpoints = headlessService.Spec.ExternalEndpoints[1:]
+	if err := m.ReconcileExternal(context.Background(), headlessService); err != nil {
+		t.Fatalf("ReconcileExternal() error = %v", err)
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "shop", Name: "orders-external-ipv6"}, slice); err == nil {
+		t.Error("IPv6 slice kept without IPv6 endpoints")
+	}
+}
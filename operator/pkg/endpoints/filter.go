@@ -0,0 +1,273 @@
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Failure policies of an endpoint filter
+const (
+	// FilterFailOpen publishes every candidate pod when the hook fails
+	FilterFailOpen = "FailOpen"
+	// FilterFailClosed publishes only the candidates that were already endpoints when the hook
+	// fails, so no pod the hook has not seen is added
+	FilterFailClosed = "FailClosed"
+)
+
+const (
+	// DefaultFilterTimeout bounds a call to a hook without a configured timeout
+	DefaultFilterTimeout = 2 * time.Second
+	// MaxFilterTimeout bounds the configurable timeout; the reconcile waits for the hook
+	MaxFilterTimeout = 10 * time.Second
+
+	// ConditionEndpointFilterFailed is set on a headless service while its hook fails
+	ConditionEndpointFilterFailed = "EndpointFilterFailed"
+
+	// maxFilterResponseBytes bounds the response read from a hook
+	maxFilterResponseBytes = 1 << 20
+)
+
+// FilterRequest is the body posted to an endpoint filter hook
+type FilterRequest struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Namespace  string            `json:"namespace"`
+	Service    string            `json:"service"`
+	Candidates []FilterCandidate `json:"candidates"`
+}
+
+// FilterCandidate is a pod the selector matches
+type FilterCandidate struct {
+	Name        string            `json:"name"`
+	IP          string            `json:"ip"`
+	NodeName    string            `json:"nodeName,omitempty"`
+	Ready       bool              `json:"ready"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// FilterResponse is the answer of an endpoint filter hook
+type FilterResponse struct {
+	// Endpoints names the candidate pods to publish
+	Endpoints []string `json:"endpoints"`
+	// Annotations are set on the EndpointSlices of the pods, e.g. the registry revision the hook used
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ValidateFilter checks the endpoint filter of a headless service
+func ValidateFilter(headlessService *k8splaygroundsv1alpha1.HeadlessService) field.ErrorList {
+	spec := headlessService.Spec.EndpointFilter
+	if spec == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec", "endpointFilter")
+	if u, err := url.Parse(spec.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Child("url"), spec.URL, "must be an http or https URL"))
+	}
+	if spec.CABundle != "" && !x509.NewCertPool().AppendCertsFromPEM([]byte(spec.CABundle)) {
+		errs = append(errs, field.Invalid(path.Child("caBundle"), "", "must hold PEM encoded certificates"))
+	}
+	if spec.TimeoutSeconds < 0 || time.Duration(spec.TimeoutSeconds)*time.Second > MaxFilterTimeout {
+		errs = append(errs, field.Invalid(path.Child("timeoutSeconds"), spec.TimeoutSeconds, fmt.Sprintf("must be at most %d", int(MaxFilterTimeout.Seconds()))))
+	}
+	switch spec.FailurePolicy {
+	case "", FilterFailOpen, FilterFailClosed:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("failurePolicy"), spec.FailurePolicy, []string{FilterFailOpen, FilterFailClosed}))
+	}
+	return errs
+}
+
+// Filter calls the endpoint filter hooks of headless services. HTTP clients are shared by the
+// services using the same CA bundle and timeout. A nil Filter creates a client per call.
+type Filter struct {
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}
+
+// NewFilter creates a new endpoint filter
+func NewFilter() *Filter {
+	return &Filter{clients: map[string]*http.Client{}}
+}
+
+// Apply passes the pods the selector matches through the hook of a headless service and
+// returns the pods to publish and the annotations of their EndpointSlices. When the hook fails
+// its failure policy decides the pods, and the EndpointFilterFailed condition is set. Services
+// without a hook keep every pod.
+func (f *Filter) Apply(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, pods []corev1.Pod, now time.Time) ([]corev1.Pod, map[string]string) {
+	spec := headlessService.Spec.EndpointFilter
+	if spec == nil {
+		headlessService.Status.EndpointFilter = nil
+		meta.RemoveStatusCondition(&headlessService.Status.Conditions, ConditionEndpointFilterFailed)
+		return pods, nil
+	}
+
+	status := &k8splaygroundsv1alpha1.EndpointFilterStatus{Candidates: int32(len(pods)), FilteredAt: metav1.NewTime(now)}
+	headlessService.Status.EndpointFilter = status
+
+	admitted, annotations, err := f.call(ctx, headlessService, pods)
+	if err == nil {
+		status.Admitted = int32(len(admitted))
+		meta.RemoveStatusCondition(&headlessService.Status.Conditions, ConditionEndpointFilterFailed)
+		return admitted, annotations
+	}
+
+	policy := spec.FailurePolicy
+	if policy == "" {
+		policy = FilterFailClosed
+	}
+	admitted = pods
+	if policy == FilterFailClosed {
+		published := map[string]bool{}
+		for _, ip := range headlessService.Status.Endpoints {
+			published[ip] = true
+		}
+		admitted = nil
+		for _, pod := range pods {
+			if published[pod.Status.PodIP] {
+				admitted = append(admitted, pod)
+			}
+		}
+	}
+	status.Admitted = int32(len(admitted))
+	status.Error = err.Error()
+	meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+		Type:    ConditionEndpointFilterFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  policy,
+		Message: fmt.Sprintf("Endpoint filter failed, published %d of %d pods: %v", len(admitted), len(pods), err),
+	})
+	return admitted, nil
+}
+
+// call posts the candidates to the hook and returns the pods it admitted
+func (f *Filter) call(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, pods []corev1.Pod) ([]corev1.Pod, map[string]string, error) {
+	spec := headlessService.Spec.EndpointFilter
+	httpClient, err := f.client(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request := FilterRequest{
+		APIVersion: k8splaygroundsv1alpha1.SchemeGroupVersion.String(),
+		Kind:       "EndpointFilterRequest",
+		Namespace:  headlessService.Namespace,
+		Service:    headlessService.Name,
+		Candidates: []FilterCandidate{},
+	}
+	for _, pod := range pods {
+		request.Candidates = append(request.Candidates, FilterCandidate{
+			Name:        pod.Name,
+			IP:          pod.Status.PodIP,
+			NodeName:    pod.Spec.NodeName,
+			Ready:       podReady(&pod),
+			Labels:      pod.Labels,
+			Annotations: pod.Annotations,
+		})
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, spec.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFilterResponseBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	response := FilterResponse{}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, nil, fmt.Errorf("invalid response: %w", err)
+	}
+	for key := range response.Annotations {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			return nil, nil, fmt.Errorf("invalid annotation %q: %s", key, msgs[0])
+		}
+	}
+
+	// The hook chooses among the candidates; it cannot add pods
+	candidates := make(map[string]corev1.Pod, len(pods))
+	for _, pod := range pods {
+		candidates[pod.Name] = pod
+	}
+	var admitted []corev1.Pod
+	for _, name := range response.Endpoints {
+		pod, ok := candidates[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("pod %s is not a candidate", name)
+		}
+		delete(candidates, name)
+		admitted = append(admitted, pod)
+	}
+	return admitted, response.Annotations, nil
+}
+
+// client returns the HTTP client for the CA bundle and timeout of a hook
+func (f *Filter) client(spec *k8splaygroundsv1alpha1.EndpointFilterSpec) (*http.Client, error) {
+	timeout := DefaultFilterTimeout
+	if spec.TimeoutSeconds > 0 {
+		timeout = time.Duration(spec.TimeoutSeconds) * time.Second
+	}
+	key := fmt.Sprintf("%s/%s", timeout, spec.CABundle)
+	if f != nil {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if c, ok := f.clients[key]; ok {
+			return c, nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if spec.CABundle != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(spec.CABundle)) {
+			return nil, fmt.Errorf("caBundle holds no PEM certificates")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	c := &http.Client{Transport: transport, Timeout: timeout}
+	if f != nil {
+		f.clients[key] = c
+	}
+	return c, nil
+}
+
+// podReady reports whether the Ready condition of a pod is true
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func filteredService(url, failurePolicy string) *k8splaygroundsv1alpha1.HeadlessService {
+	return &k8splaygroundsv1alpha1.HeadlessService{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+		Spec: k8splaygroundsv1alpha1.HeadlessServiceSpec{
+			EndpointFilter: &k8splaygroundsv1alpha1.EndpointFilterSpec{URL: url, FailurePolicy: failurePolicy},
+		},
+	}
+}
+
+func candidatePods() []corev1.Pod {
+	var pods []corev1.Pod
+	for _, pod := range []struct{ name, ip string }{{"orders-0", "10.0.0.1"}, {"orders-1", "10.0.0.2"}, {"orders-2", "10.0.0.3"}} {
+		pods = append(pods, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: pod.name, Namespace: "shop"},
+			Status:     corev1.PodStatus{PodIP: pod.ip},
+		})
+	}
+	return pods
+}
+
+func TestFilterApply(t *testing.T) {
+	var request FilterRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		json.NewEncoder(w).Encode(FilterResponse{Endpoints: []string{"orders-2", "orders-0"}, Annotations: map[string]string{"registry.example.com/revision": "42"}})
+	}))
+	defer server.Close()
+
+	headlessService := filteredService(server.URL, "")
+	pods, annotations := NewFilter().Apply(context.Background(), headlessService, candidatePods(), time.Now())
+	if request.Service != "orders" || len(request.Candidates) != 3 || request.Candidates[1].IP != "10.0.0.2" {
+		t.Errorf("request = %+v, want the three candidates of orders", request)
+	}
+	if len(pods) != 2 || pods[0].Name != "orders-2" || pods[1].Name != "orders-0" || annotations["registry.example.com/revision"] != "42" {
+		t.Errorf("Apply() = %v, %v, want the pods and annotations of the hook", pods, annotations)
+	}
+	if status := headlessService.Status.EndpointFilter; status == nil || status.Candidates != 3 || status.Admitted != 2 || status.Error != "" {
+		t.Errorf("status = %+v, want 2 of 3 admitted", status)
+	}
+}
+
+func TestFilterApplyFailurePolicies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "registry unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	open := filteredService(server.URL, FilterFailOpen)
+	if pods, _ := NewFilter().Apply(context.Background(), open, candidatePods(), time.Now()); len(pods) != 3 {
+		t.Errorf("fail open published %d pods, want every candidate", len(pods))
+	}
+
+	// Failing closed keeps the published pods, without adding new ones
+	closed := filteredService(server.URL, "")
+	closed.Status.Endpoints = []string{"10.0.0.2", "10.0.0.9"}
+	pods, _ := NewFilter().Apply(context.Background(), closed, candidatePods(), time.Now())
+	if len(pods) != 1 || pods[0].Name != "orders-1" {
+		t.Errorf("fail closed published %v, want only the published orders-1", pods)
+	}
+	condition := meta.FindStatusCondition(closed.Status.Conditions, ConditionEndpointFilterFailed)
+	if condition == nil || condition.Reason != FilterFailClosed || closed.Status.EndpointFilter.Error == "" {
+		t.Errorf("conditions = %+v, want the filter failure reported", closed.Status.Conditions)
+	}
+
+	// A hook cannot add pods the selector does not match
+	rogue := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(FilterResponse{Endpoints: []string{"orders-0", "intruder"}})
+	}))
+	defer rogue.Close()
+	if pods, _ := NewFilter().Apply(context.Background(), filteredService(rogue.URL, FilterFailOpen), candidatePods(), time.Now()); len(pods) != 3 {
+		t.Errorf("rogue hook published %v, want the failure policy applied", pods)
+	}
+
+	// Removing the hook clears its status
+	closed.Spec.EndpointFilter = nil
+	if pods, _ := NewFilter().Apply(context.Background(), closed, candidatePods(), time.Now()); len(pods) != 3 || closed.Status.EndpointFilter != nil || len(closed.Status.Conditions) != 0 {
+		t.Errorf("Apply() without a hook = %v, status %+v", pods, closed.Status)
+	}
+}
+
+func TestValidateFilter(t *testing.T) {
+	if errs := ValidateFilter(filteredService("https://registry.shop.svc/filter", FilterFailOpen)); len(errs) > 0 {
+		t.Errorf("ValidateFilter() = %v", errs)
+	}
+	invalid := filteredService("registry.shop.svc", "Ignore")
+	invalid.Spec.EndpointFilter.TimeoutSeconds = 30
+	invalid.Spec.EndpointFilter.CABundle = "not a certificate"
+	if errs := ValidateFilter(invalid); len(errs) != 4 {
+		t.Errorf("ValidateFilter() = %v, want 4 errors", errs)
+	}
+}
@@ -0,0 +1,110 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Cache indexes registered by SetupIndexes
+const (
+	// PodLabelIndex indexes pods by each of their labels as key=value
+	PodLabelIndex = "endpoints.pod.labels"
+	// PodNodeIndex indexes pods by the node they are scheduled on
+	PodNodeIndex = "endpoints.pod.nodeName"
+	// ServiceSelectorIndex indexes Services by a hash of their selector
+	ServiceSelectorIndex = "endpoints.service.selectorHash"
+)
+
+// Index is a field index of the informer cache
+type Index struct {
+	Object  client.Object
+	Field   string
+	Extract client.IndexerFunc
+}
+
+// Indexes lists the cache indexes the endpoint lookups rely on
+var Indexes = []Index{
+	{Object: &corev1.Pod{}, Field: PodLabelIndex, Extract: podLabels},
+	{Object: &corev1.Pod{}, Field: PodNodeIndex, Extract: podNode},
+	{Object: &corev1.Service{}, Field: ServiceSelectorIndex, Extract: serviceSelector},
+}
+
+// SetupIndexes registers Indexes with the cache of a manager. Lists that use them fail on
+// clients without the indexes, including clients that bypass the cache.
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, index := range Indexes {
+		if err := indexer.IndexField(ctx, index.Object, index.Field, index.Extract); err != nil {
+			return fmt.Errorf("failed to register index %s: %w", index.Field, err)
+		}
+	}
+	return nil
+}
+
+// LabelIndexValue returns the PodLabelIndex value of a label
+func LabelIndexValue(key, value string) string {
+	return key + "=" + value
+}
+
+// SelectorHash returns the ServiceSelectorIndex value of a selector; equal selectors have
+// equal hashes regardless of key order
+func SelectorHash(selector map[string]string) string {
+	keys := make([]string, 0, len(selector))
+	for key := range selector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	for _, key := range keys {
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(selector[key]))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// indexedLabel returns the selector label used to look pods up in PodLabelIndex. Any label
+// of the selector narrows the candidates; the smallest key keeps the choice stable.
+func indexedLabel(selector map[string]string) (string, bool) {
+	var first string
+	for key := range selector {
+		if first == "" || key < first {
+			first = key
+		}
+	}
+	if first == "" {
+		return "", false
+	}
+	return LabelIndexValue(first, selector[first]), true
+}
+
+func podLabels(obj client.Object) []string {
+	labels := obj.GetLabels()
+	values := make([]string, 0, len(labels))
+	for key, value := range labels {
+		values = append(values, LabelIndexValue(key, value))
+	}
+	return values
+}
+
+func podNode(obj client.Object) []string {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || pod.Spec.NodeName == "" {
+		return nil
+	}
+	return []string{pod.Spec.NodeName}
+}
+
+func serviceSelector(obj client.Object) []string {
+	service, ok := obj.(*corev1.Service)
+	if !ok || len(service.Spec.Selector) == 0 {
+		return nil
+	}
+	return []string{SelectorHash(service.Spec.Selector)}
+}
@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func indexedClient(objects ...client.Object) client.Client {
+	builder := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objects...)
+	for _, index := range Indexes {
+		builder = builder.WithIndex(index.Object, index.Field, index.Extract)
+	}
+	return builder.Build()
+}
+
+func pod(namespace, name, node string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+}
+
+func TestSelectorHash(t *testing.T) {
+	a := SelectorHash(map[string]string{"app": "web", "tier": "frontend"})
+	b := SelectorHash(map[string]string{"tier": "frontend", "app": "web"})
+	if a != b {
+		t.Errorf("hashes %s and %s differ for the same selector", a, b)
+	}
+	// Keys and values must not run together
+	if SelectorHash(map[string]string{"a": "b=c"}) == SelectorHash(map[string]string{"a=b": "c"}) {
+		t.Error("different selectors share a hash")
+	}
+}
+
+func TestGetMatchingPodsUsesIndexes(t *testing.T) {
+	m := NewManager(indexedClient(
+		pod("shop", "web-0", "node-a", map[string]string{"app": "web", "tier": "frontend"}),
+		pod("shop", "web-1", "node-b", map[string]string{"app": "web", "tier": "frontend"}),
+		pod("shop", "api-0", "node-a", map[string]string{"app": "api", "tier": "frontend"}),
+		pod("blog", "web-0", "node-a", map[string]string{"app": "web", "tier": "frontend"}),
+	))
+	ctx := context.Background()
+
+	pods, err := m.GetMatchingPods(ctx, "shop", map[string]string{"tier": "frontend", "app": "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 2 {
+		t.Errorf("matched %d pods, want web-0 and web-1 in shop", len(pods))
+	}
+
+	onNode, err := m.GetPodsOnNode(ctx, "shop", "node-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onNode) != 2 {
+		t.Errorf("found %d pods on node-a, want web-0 and api-0", len(onNode))
+	}
+}
+
+func TestGetServicesWithSelector(t *testing.T) {
+	service := func(name string, selector map[string]string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+			Spec:       corev1.ServiceSpec{Selector: selector},
+		}
+	}
+	m := NewManager(indexedClient(
+		service("web", map[string]string{"app": "web"}),
+		service("web-headless", map[string]string{"app": "web"}),
+		service("web-canary", map[string]string{"app": "web", "track": "canary"}),
+	))
+
+	services, err := m.GetServicesWithSelector(context.Background(), "shop", map[string]string{"app": "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 {
+		t.Errorf("found %d services, want web and web-headless", len(services))
+	}
+}
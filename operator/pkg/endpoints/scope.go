@@ -0,0 +1,59 @@
+package endpoints
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+const (
+	// DefaultMaxMatchedPods is the operator-wide limit on the pods a HeadlessService selector
+	// may match when neither the service nor the operator sets one
+	DefaultMaxMatchedPods = 500
+
+	// ConditionSelectorTooBroad is set while the selector matches more pods than allowed
+	ConditionSelectorTooBroad = "SelectorTooBroad"
+)
+
+// MaxMatchedPods returns the limit for a headless service: its own limit if set, then the
+// operator-wide limit, then DefaultMaxMatchedPods
+func MaxMatchedPods(headlessService *k8splaygroundsv1alpha1.HeadlessService, operatorLimit int) int {
+	if headlessService.Spec.MaxMatchedPods > 0 {
+		return int(headlessService.Spec.MaxMatchedPods)
+	}
+	if operatorLimit > 0 {
+		return operatorLimit
+	}
+	return DefaultMaxMatchedPods
+}
+
+// CheckScope records how many pods the selector matches and sets the SelectorTooBroad condition
+// when that exceeds limit. It returns whether the limit is exceeded, in which case endpoints and
+// iptables rules should be left as they are rather than grown to the oversized pod set.
+func CheckScope(headlessService *k8splaygroundsv1alpha1.HeadlessService, matched, limit int) bool {
+	status := &headlessService.Status
+	status.MatchedPods = int32(matched)
+
+	if matched <= limit {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ConditionSelectorTooBroad,
+			Status:             metav1.ConditionFalse,
+			Reason:             "WithinLimit",
+			Message:            fmt.Sprintf("Selector matches %d of at most %d pods", matched, limit),
+			ObservedGeneration: headlessService.Generation,
+		})
+		return false
+	}
+
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionSelectorTooBroad,
+		Status:             metav1.ConditionTrue,
+		Reason:             "MaxMatchedPodsExceeded",
+		Message:            fmt.Sprintf("Selector matches %d pods, more than the limit of %d; endpoints and iptables rules are not updated until the selector is narrowed or the limit raised", matched, limit),
+		ObservedGeneration: headlessService.Generation,
+	})
+	return true
+}
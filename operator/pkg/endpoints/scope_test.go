@@ -0,0 +1,41 @@
+package endpoints
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestMaxMatchedPods(t *testing.T) {
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+	if got := MaxMatchedPods(headlessService, 0); got != DefaultMaxMatchedPods {
+		t.Errorf("MaxMatchedPods() = %d, want the default %d", got, DefaultMaxMatchedPods)
+	}
+	if got := MaxMatchedPods(headlessService, 100); got != 100 {
+		t.Errorf("MaxMatchedPods() = %d, want the operator limit 100", got)
+	}
+	headlessService.Spec.MaxMatchedPods = 1000
+	if got := MaxMatchedPods(headlessService, 100); got != 1000 {
+		t.Errorf("MaxMatchedPods() = %d, want the service limit 1000", got)
+	}
+}
+
+func TestCheckScope(t *testing.T) {
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+
+	if !CheckScope(headlessService, 300, 250) {
+		t.Fatal("expected 300 pods to exceed a limit of 250")
+	}
+	if headlessService.Status.MatchedPods != 300 || !meta.IsStatusConditionTrue(headlessService.Status.Conditions, ConditionSelectorTooBroad) {
+		t.Errorf("status = %+v, want 300 matched pods and the condition set", headlessService.Status)
+	}
+
+	if CheckScope(headlessService, 250, 250) {
+		t.Fatal("expected 250 pods to be within a limit of 250")
+	}
+	if meta.IsStatusConditionTrue(headlessService.Status.Conditions, ConditionSelectorTooBroad) {
+		t.Error("expected the condition to clear once within the limit")
+	}
+}
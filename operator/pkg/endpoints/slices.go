@@ -0,0 +1,388 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+const (
+	// MaxEndpointsPerSlice is how many pod endpoints an EndpointSlice holds, the default of the
+	// EndpointSlice controller of Kubernetes
+	MaxEndpointsPerSlice = 100
+
+	// SourceLabel tells the EndpointSlices publishing the pods matching the selector apart from
+	// those publishing external endpoints
+	SourceLabel = "k8s-playgrounds.io/endpoints-source"
+	// SourcePods is the SourceLabel value of the EndpointSlices of pods
+	SourcePods = "pods"
+
+	// legacyEndpointsName is the name label of the Endpoints objects earlier releases wrote
+	legacyEndpointsName = "headless-service-endpoints"
+)
+
+// ReconcileSlices publishes the pods of a headless service in EndpointSlices of its Service,
+// per address family and with at most MaxEndpointsPerSlice endpoints each, and deletes the
+// slices no longer needed. An endpoint stays in the slice holding it and new endpoints fill the
+// slices with room first, so a change of one pod rewrites only the slice holding it.
+// annotations are set on every slice, e.g. the ones returned by an endpoint filter. It returns
+// the slices published.
+func (m *Manager) ReconcileSlices(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, pods []corev1.Pod, annotations map[string]string) ([]discoveryv1.EndpointSlice, error) {
+	zones, err := m.nodeZones(ctx, pods)
+	if err != nil {
+		return nil, err
+	}
+	byFamily := podEndpoints(headlessService, pods, zones)
+
+	existing := &discoveryv1.EndpointSliceList{}
+	if err := m.client.List(ctx, existing, client.InNamespace(headlessService.Namespace), client.MatchingLabels(sliceLabels(headlessService))); err != nil {
+		return nil, fmt.Errorf("failed to list endpoint slices: %w", err)
+	}
+	stale := map[string]*discoveryv1.EndpointSlice{}
+	for i := range existing.Items {
+		stale[existing.Items[i].Name] = &existing.Items[i]
+	}
+
+	var slices []discoveryv1.EndpointSlice
+	for _, family := range []discoveryv1.AddressType{discoveryv1.AddressTypeIPv4, discoveryv1.AddressTypeIPv6} {
+		for _, packed := range packEndpoints(headlessService, family, byFamily[family], existing.Items) {
+			slice, err := podSlice(headlessService, packed.name, family, packed.endpoints, annotations, m.client.Scheme())
+			if err != nil {
+				return nil, err
+			}
+			if err := m.applyPodSlice(ctx, slice, stale[slice.Name]); err != nil {
+				return nil, fmt.Errorf("failed to apply endpoint slice %s: %w", slice.Name, err)
+			}
+			delete(stale, slice.Name)
+			slices = append(slices, *slice)
+		}
+	}
+
+	for _, slice := range stale {
+		if err := client.IgnoreNotFound(m.client.Delete(ctx, slice)); err != nil {
+			return nil, fmt.Errorf("failed to delete endpoint slice %s: %w", slice.Name, err)
+		}
+	}
+	return slices, nil
+}
+
+// packedSlice is the name and the endpoints of a slice of pods
+type packedSlice struct {
+	name      string
+	endpoints []discoveryv1.Endpoint
+}
+
+// packEndpoints spreads the endpoints of an address family over slices of at most
+// MaxEndpointsPerSlice endpoints. An endpoint already published in one of the existing slices
+// stays there, updated; the other endpoints fill the slices with room in name order, then new
+// slices with the lowest free index. Slices left without endpoints are not returned.
+func packEndpoints(headlessService *k8splaygroundsv1alpha1.HeadlessService, family discoveryv1.AddressType, endpoints []discoveryv1.Endpoint, existing []discoveryv1.EndpointSlice) []packedSlice {
+	wanted := map[string]discoveryv1.Endpoint{}
+	for _, endpoint := range endpoints {
+		wanted[endpoint.Addresses[0]] = endpoint
+	}
+
+	var names []string
+	current := map[string][]discoveryv1.Endpoint{}
+	for i := range existing {
+		if existing[i].AddressType == family {
+			names = append(names, existing[i].Name)
+			current[existing[i].Name] = existing[i].Endpoints
+		}
+	}
+	sort.Strings(names)
+
+	placed := map[string]bool{}
+	var packed []packedSlice
+	for _, name := range names {
+		slice := packedSlice{name: name}
+		for _, endpoint := range current[name] {
+			address := endpoint.Addresses[0]
+			if want, ok := wanted[address]; ok && !placed[address] && len(slice.endpoints) < MaxEndpointsPerSlice {
+				placed[address] = true
+				slice.endpoints = append(slice.endpoints, want)
+			}
+		}
+		packed = append(packed, slice)
+	}
+
+	var rest []discoveryv1.Endpoint
+	for _, endpoint := range endpoints {
+		if !placed[endpoint.Addresses[0]] {
+			rest = append(rest, endpoint)
+		}
+	}
+	for i := range packed {
+		n := MaxEndpointsPerSlice - len(packed[i].endpoints)
+		if n > len(rest) {
+			n = len(rest)
+		}
+		packed[i].endpoints = append(packed[i].endpoints, rest[:n]...)
+		rest = rest[n:]
+	}
+	for index := 0; len(rest) > 0; index++ {
+		name := PodSliceName(headlessService, family, index)
+		if _, ok := current[name]; ok {
+			continue
+		}
+		n := len(rest)
+		if n > MaxEndpointsPerSlice {
+			n = MaxEndpointsPerSlice
+		}
+		packed = append(packed, packedSlice{name: name, endpoints: rest[:n]})
+		rest = rest[n:]
+	}
+
+	nonEmpty := packed[:0]
+	for _, slice := range packed {
+		if len(slice.endpoints) > 0 {
+			nonEmpty = append(nonEmpty, slice)
+		}
+	}
+	return nonEmpty
+}
+
+// applyPodSlice applies a slice unless the existing one already matches it
+func (m *Manager) applyPodSlice(ctx context.Context, slice, existing *discoveryv1.EndpointSlice) error {
+	if existing == nil {
+		return apply.Apply(ctx, m.client, slice)
+	}
+	if existing.AddressType == slice.AddressType &&
+		equality.Semantic.DeepEqual(existing.Endpoints, slice.Endpoints) &&
+		equality.Semantic.DeepEqual(existing.Ports, slice.Ports) &&
+		equality.Semantic.DeepEqual(existing.Labels, slice.Labels) &&
+		equality.Semantic.DeepEqual(existing.Annotations, slice.Annotations) &&
+		equality.Semantic.DeepEqual(existing.OwnerReferences, slice.OwnerReferences) {
+		return nil
+	}
+	if existing.AddressType != slice.AddressType {
+		// The address type of a slice is immutable
+		if err := client.IgnoreNotFound(m.client.Delete(ctx, existing)); err != nil {
+			return err
+		}
+	}
+	return apply.Apply(ctx, m.client, slice)
+}
+
+// podSlice builds an EndpointSlice of a headless service, controlled by it
+func podSlice(headlessService *k8splaygroundsv1alpha1.HeadlessService, name string, family discoveryv1.AddressType, endpoints []discoveryv1.Endpoint, annotations map[string]string, scheme *runtime.Scheme) (*discoveryv1.EndpointSlice, error) {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   headlessService.Namespace,
+			Labels:      sliceLabels(headlessService),
+			Annotations: annotations,
+		},
+		AddressType: family,
+		Endpoints:   endpoints,
+		Ports:       slicePorts(headlessService),
+	}
+	if err := controllerutil.SetControllerReference(headlessService, slice, scheme); err != nil {
+		return nil, fmt.Errorf("failed to set owner of endpoint slice %s: %w", name, err)
+	}
+	return slice, nil
+}
+
+// podEndpoints returns the endpoints of the pods that have an IP, per address family and
+// ordered by pod name. Pods that are not ready are published with their conditions, so cluster
+// DNS leaves them out while they stay visible. Every endpoint is hinted to the zone of its node
+// when the headless service asks for topology aware routing and the zones of all nodes are
+// known.
+func podEndpoints(headlessService *k8splaygroundsv1alpha1.HeadlessService, pods []corev1.Pod, zones map[string]string) map[discoveryv1.AddressType][]discoveryv1.Endpoint {
+	pods = append([]corev1.Pod(nil), pods...)
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+	hints := TopologyAware(headlessService)
+
+	byFamily := map[discoveryv1.AddressType][]discoveryv1.Endpoint{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		serving := podReady(pod)
+		terminating := pod.DeletionTimestamp != nil
+		ready := serving && !terminating
+
+		for _, ip := range podIPs(pod) {
+			family := discoveryv1.AddressTypeIPv4
+			if net.ParseIP(ip).To4() == nil {
+				family = discoveryv1.AddressTypeIPv6
+			}
+			endpoint := discoveryv1.Endpoint{
+				Addresses: []string{ip},
+				Conditions: discoveryv1.EndpointConditions{
+					Ready:       &[]bool{ready}[0],
+					Serving:     &[]bool{serving}[0],
+					Terminating: &[]bool{terminating}[0],
+				},
+				TargetRef: &corev1.ObjectReference{
+					Kind:      "Pod",
+					Namespace: pod.Namespace,
+					Name:      pod.Name,
+					UID:       pod.UID,
+				},
+			}
+			if pod.Spec.Hostname != "" && pod.Spec.Subdomain == headlessService.Name {
+				endpoint.Hostname = &[]string{pod.Spec.Hostname}[0]
+			}
+			if pod.Spec.NodeName != "" {
+				endpoint.NodeName = &[]string{pod.Spec.NodeName}[0]
+			}
+			if zone := zones[pod.Spec.NodeName]; zone != "" {
+				endpoint.Zone = &[]string{zone}[0]
+			} else {
+				hints = false
+			}
+			byFamily[family] = append(byFamily[family], endpoint)
+		}
+	}
+
+	if hints {
+		for _, endpoints := range byFamily {
+			for i := range endpoints {
+				endpoints[i].Hints = &discoveryv1.EndpointHints{ForZones: []discoveryv1.ForZone{{Name: *endpoints[i].Zone}}}
+			}
+		}
+	}
+	return byFamily
+}
+
+// nodeZones returns the zones of the nodes running pods, from their topology label. Nodes
+// without the label or that are gone have no zone.
+func (m *Manager) nodeZones(ctx context.Context, pods []corev1.Pod) (map[string]string, error) {
+	zones := map[string]string{}
+	for _, pod := range pods {
+		nodeName := pod.Spec.NodeName
+		if _, ok := zones[nodeName]; ok || nodeName == "" {
+			continue
+		}
+		node := &corev1.Node{}
+		if err := m.client.Get(ctx, types.NamespacedName{Name: nodeName}, node); err != nil && !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+		}
+		zones[nodeName] = node.Labels[corev1.LabelTopologyZone]
+	}
+	return zones, nil
+}
+
+// TopologyAware reports whether a headless service asks for topology aware routing with the
+// annotation of Kubernetes Services
+func TopologyAware(headlessService *k8splaygroundsv1alpha1.HeadlessService) bool {
+	mode := headlessService.Annotations[corev1.AnnotationTopologyMode]
+	if mode == "" {
+		mode = headlessService.Annotations[corev1.DeprecatedAnnotationTopologyAwareHints]
+	}
+	return strings.EqualFold(mode, "auto")
+}
+
+// GetEndpointSlices returns the EndpointSlices of the Service of a headless service, of any
+// manager
+func (m *Manager) GetEndpointSlices(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) ([]discoveryv1.EndpointSlice, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := m.client.List(ctx, slices, client.InNamespace(headlessService.Namespace), client.MatchingLabels{discoveryv1.LabelServiceName: headlessService.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list endpoint slices: %w", err)
+	}
+	return slices.Items, nil
+}
+
+// ReadyAddresses returns the addresses of the ready endpoints of slices, sorted and without
+// duplicates. Endpoints without a ready condition count as ready.
+func ReadyAddresses(slices []discoveryv1.EndpointSlice) []string {
+	seen := map[string]bool{}
+	var addresses []string
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				if !seen[address] {
+					seen[address] = true
+					addresses = append(addresses, address)
+				}
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// DeleteLegacyEndpoints deletes the Endpoints object earlier releases wrote for a headless
+// service. Its Service has no selector any more, so Kubernetes would otherwise mirror the
+// stale addresses into EndpointSlices of its own.
+func (m *Manager) DeleteLegacyEndpoints(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	legacy := &corev1.Endpoints{}
+	err := m.client.Get(ctx, types.NamespacedName{Namespace: headlessService.Namespace, Name: headlessService.Name}, legacy)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get endpoints: %w", err)
+	}
+	if legacy.Labels["app.kubernetes.io/name"] != legacyEndpointsName {
+		return nil
+	}
+	return client.IgnoreNotFound(m.client.Delete(ctx, legacy))
+}
+
+// PodSliceName returns the name of EndpointSlice number index publishing the pods of an
+// address family
+func PodSliceName(headlessService *k8splaygroundsv1alpha1.HeadlessService, family discoveryv1.AddressType, index int) string {
+	qualifier := "ipv4"
+	if family == discoveryv1.AddressTypeIPv6 {
+		qualifier = "ipv6"
+	}
+	return naming.Qualified(naming.PodEndpoints, headlessService.Name, fmt.Sprintf("%s-%d", qualifier, index))
+}
+
+// sliceLabels returns the labels of the EndpointSlices of pods
+func sliceLabels(headlessService *k8splaygroundsv1alpha1.HeadlessService) map[string]string {
+	return map[string]string{
+		discoveryv1.LabelServiceName: headlessService.Name,
+		discoveryv1.LabelManagedBy:   ExternalManagedBy,
+		SourceLabel:                  SourcePods,
+	}
+}
+
+// slicePorts returns the EndpointSlice ports of the ports of a headless service
+func slicePorts(headlessService *k8splaygroundsv1alpha1.HeadlessService) []discoveryv1.EndpointPort {
+	var ports []discoveryv1.EndpointPort
+	for _, servicePort := range headlessService.Spec.Ports {
+		name := servicePort.Name
+		port := servicePort.Port
+		protocol := corev1.Protocol(servicePort.Protocol)
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		ports = append(ports, discoveryv1.EndpointPort{Name: &name, Port: &port, Protocol: &protocol})
+	}
+	return ports
+}
+
+// podIPs returns the IPs of a pod, one per address family on dual-stack clusters
+func podIPs(pod *corev1.Pod) []string {
+	var ips []string
+	for _, podIP := range pod.Status.PodIPs {
+		ips = append(ips, podIP.IP)
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips
+}
@@ -0,0 +1,229 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+func slicePod(name, ip, node string, ready bool) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+		Spec:       corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			PodIP:      ip,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}},
+		},
+	}
+}
+
+// sliceScheme returns a scheme knowing HeadlessServices, the owners of the slices
+func sliceScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypes(k8splaygroundsv1alpha1.SchemeGroupVersion, &k8splaygroundsv1alpha1.HeadlessService{})
+	return scheme
+}
+
+func zoneNode(name, zone string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelTopologyZone: zone}}}
+}
+
+func podSlices(t *testing.T, c client.Client) map[string]discoveryv1.EndpointSlice {
+	t.Helper()
+	list := &discoveryv1.EndpointSliceList{}
+	if err := c.List(context.Background(), list, client.MatchingLabels{SourceLabel: SourcePods}); err != nil {
+		t.Fatal(err)
+	}
+	slices := map[string]discoveryv1.EndpointSlice{}
+	for _, slice := range list.Items {
+		slices[slice.Name] = slice
+	}
+	return slices
+}
+
+func TestReconcileSlicesSplitsFamilies(t *testing.T) {
+	var pods []corev1.Pod
+	for i := 0; i < MaxEndpointsPerSlice+5; i++ {
+		pods = append(pods, *slicePod(fmt.Sprintf("web-%03d", i), fmt.Sprintf("10.0.%d.%d", i/250, i%250+1), "", true))
+	}
+	dualStack := slicePod("web-dual", "10.1.0.1", "", true)
+	dualStack.Status.PodIPs = []corev1.PodIP{{IP: "10.1.0.1"}, {IP: "fd00::1"}}
+	pods = append(pods, *dualStack, *slicePod("web-pending", "", "", false))
+
+	c := fake.NewClientBuilder().WithScheme(sliceScheme(t)).WithInterceptorFuncs(applytest.Funcs()).Build()
+	m := NewManager(c)
+	headlessService := externalService()
+
+	published, err := m.ReconcileSlices(context.Background(), headlessService, pods, map[string]string{"registry/revision": "7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(published) != 3 {
+		t.Fatalf("ReconcileSlices() published %d slices, want 2 IPv4 and 1 IPv6", len(published))
+	}
+
+	slices := podSlices(t, c)
+	first, second, ipv6 := slices["orders-pods-ipv4-0"], slices["orders-pods-ipv4-1"], slices["orders-pods-ipv6-0"]
+	if len(first.Endpoints) != MaxEndpointsPerSlice || len(second.Endpoints) != 6 {
+		t.Errorf("IPv4 slices hold %d and %d endpoints, want %d and 6", len(first.Endpoints), len(second.Endpoints), MaxEndpointsPerSlice)
+	}
+	if len(ipv6.Endpoints) != 1 || ipv6.AddressType != discoveryv1.AddressTypeIPv6 || ipv6.Endpoints[0].Addresses[0] != "fd00::1" {
+		t.Errorf("IPv6 slice = %+v, want the second address of the dual-stack pod", ipv6)
+	}
+	if first.Labels[discoveryv1.LabelServiceName] != "orders" || first.Labels[discoveryv1.LabelManagedBy] != ExternalManagedBy {
+		t.Errorf("labels = %v, want the service name and the operator as manager", first.Labels)
+	}
+	if first.Annotations["registry/revision"] != "7" || *first.Ports[0].Port != 5432 {
+		t.Errorf("slice = %+v, want the filter annotations and the service ports", first)
+	}
+	if owner := metav1.GetControllerOf(&first); owner == nil || owner.Kind != "HeadlessService" || owner.APIVersion != k8splaygroundsv1alpha1.SchemeGroupVersion.String() || owner.UID != headlessService.UID {
+		t.Errorf("controller = %+v, want the headless service", owner)
+	}
+
+	// Shrinking the pods deletes the slices no longer needed and keeps the external ones
+	if err := m.ReconcileExternal(context.Background(), externalService(k8splaygroundsv1alpha1.ExternalEndpointSpec{Name: "cache", IP: "10.20.0.5"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ReconcileSlices(context.Background(), headlessService, pods[:1], nil); err != nil {
+		t.Fatal(err)
+	}
+	if slices := podSlices(t, c); len(slices) != 1 || len(slices["orders-pods-ipv4-0"].Endpoints) != 1 {
+		t.Errorf("slices = %v, want one slice of one endpoint", slices)
+	}
+	all, err := m.GetEndpointSlices(context.Background(), headlessService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addresses := ReadyAddresses(all); !reflect.DeepEqual(addresses, []string{"10.0.0.1", "10.20.0.5"}) {
+		t.Errorf("ReadyAddresses() = %v, want the pod and the external endpoint", addresses)
+	}
+}
+
+func TestReconcileSlicesConditionsAndHints(t *testing.T) {
+	terminating := slicePod("web-2", "10.0.0.3", "node-b", true)
+	terminating.DeletionTimestamp = &metav1.Time{}
+	terminating.Finalizers = []string{"example.com/drain"}
+	pods := []corev1.Pod{*slicePod("web-0", "10.0.0.1", "node-a", true), *slicePod("web-1", "10.0.0.2", "node-b", false), *terminating}
+
+	c := fake.NewClientBuilder().WithScheme(sliceScheme(t)).WithObjects(zoneNode("node-a", "zone-a"), zoneNode("node-b", "zone-b")).WithInterceptorFuncs(applytest.Funcs()).Build()
+	m := NewManager(c)
+	headlessService := externalService()
+	headlessService.Annotations = map[string]string{corev1.AnnotationTopologyMode: "Auto"}
+
+	published, err := m.ReconcileSlices(context.Background(), headlessService, pods, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoints := published[0].Endpoints
+	for i, want := range []struct{ ready, serving, terminating bool }{{true, true, false}, {false, false, false}, {false, true, true}} {
+		conditions := endpoints[i].Conditions
+		if *conditions.Ready != want.ready || *conditions.Serving != want.serving || *conditions.Terminating != want.terminating {
+			t.Errorf("conditions of %s = %+v, want %+v", endpoints[i].TargetRef.Name, conditions, want)
+		}
+		if zone := *endpoints[i].Zone; endpoints[i].Hints == nil || endpoints[i].Hints.ForZones[0].Name != zone {
+			t.Errorf("hints of %s = %+v, want its zone %s", endpoints[i].TargetRef.Name, endpoints[i].Hints, zone)
+		}
+	}
+	if addresses := ReadyAddresses(published); !reflect.DeepEqual(addresses, []string{"10.0.0.1"}) {
+		t.Errorf("ReadyAddresses() = %v, want only the ready pod", addresses)
+	}
+
+	// A pod on a node without zone turns the hints off
+	pods = append(pods, *slicePod("web-3", "10.0.0.4", "node-c", true))
+	published, err = m.ReconcileSlices(context.Background(), headlessService, pods, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, endpoint := range published[0].Endpoints {
+		if endpoint.Hints != nil {
+			t.Errorf("hints of %s = %+v, want none", endpoint.TargetRef.Name, endpoint.Hints)
+		}
+	}
+}
+
+func TestReconcileSlicesKeepsEndpointsInPlace(t *testing.T) {
+	var pods []corev1.Pod
+	for i := 0; i < MaxEndpointsPerSlice+5; i++ {
+		pods = append(pods, *slicePod(fmt.Sprintf("web-%03d", i), fmt.Sprintf("10.0.%d.%d", i/250, i%250+1), "", true))
+	}
+	c := fake.NewClientBuilder().WithScheme(sliceScheme(t)).WithInterceptorFuncs(applytest.Funcs()).Build()
+	m := NewManager(c)
+	headlessService := externalService()
+	if _, err := m.ReconcileSlices(context.Background(), headlessService, pods, nil); err != nil {
+		t.Fatal(err)
+	}
+	before := podSlices(t, c)
+
+	// A pod sorting first joins the slice with room instead of shifting every endpoint
+	pods = append(pods, *slicePod("web-", "10.9.0.1", "", true))
+	if _, err := m.ReconcileSlices(context.Background(), headlessService, pods, nil); err != nil {
+		t.Fatal(err)
+	}
+	after := podSlices(t, c)
+	if after["orders-pods-ipv4-0"].ResourceVersion != before["orders-pods-ipv4-0"].ResourceVersion {
+		t.Error("full slice was rewritten for a pod added elsewhere")
+	}
+	if second := after["orders-pods-ipv4-1"]; len(second.Endpoints) != 6 || second.Endpoints[5].Addresses[0] != "10.9.0.1" {
+		t.Errorf("second slice = %+v, want the new pod appended", second.Endpoints)
+	}
+
+	// A pod removed from the first slice leaves the second one alone
+	if _, err := m.ReconcileSlices(context.Background(), headlessService, pods[1:], nil); err != nil {
+		t.Fatal(err)
+	}
+	removed := podSlices(t, c)
+	if removed["orders-pods-ipv4-1"].ResourceVersion != after["orders-pods-ipv4-1"].ResourceVersion {
+		t.Error("second slice was rewritten for a pod removed from the first")
+	}
+	if n := len(removed["orders-pods-ipv4-0"].Endpoints); n != MaxEndpointsPerSlice-1 {
+		t.Errorf("first slice holds %d endpoints, want %d", n, MaxEndpointsPerSlice-1)
+	}
+}
+
+func TestDeleteLegacyEndpoints(t *testing.T) {
+	legacy := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{
+		Name: "orders", Namespace: "shop",
+		Labels: map[string]string{"app.kubernetes.io/name": legacyEndpointsName},
+	}}
+	foreign := &corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "shop"}}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(legacy, foreign).Build()
+	m := NewManager(c)
+
+	if err := m.DeleteLegacyEndpoints(context.Background(), externalService()); err != nil {
+		t.Fatal(err)
+	}
+	payments := externalService()
+	payments.Name = "payments"
+	if err := m.DeleteLegacyEndpoints(context.Background(), payments); err != nil {
+		t.Fatal(err)
+	}
+
+	list := &corev1.EndpointsList{}
+	if err := c.List(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Name != "payments" {
+		t.Errorf("endpoints = %v, want only the ones the operator did not write", list.Items)
+	}
+}
@@ -0,0 +1,56 @@
+package endpoints
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// PodEndpointChanged passes the pod events that change the endpoints of a headless service:
+// pods that appear or disappear, and updates of their labels, IPs, readiness, node or
+// termination. Status updates that leave all of them alone are dropped.
+var PodEndpointChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		previous, okPrevious := e.ObjectOld.(*corev1.Pod)
+		current, okCurrent := e.ObjectNew.(*corev1.Pod)
+		if !okPrevious || !okCurrent {
+			return false
+		}
+		return !reflect.DeepEqual(endpointFields(previous), endpointFields(current))
+	},
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// podEndpoint holds the fields of a pod that its endpoint is built from
+type podEndpoint struct {
+	labels      map[string]string
+	ips         []string
+	ready       bool
+	terminating bool
+	phase       corev1.PodPhase
+	nodeName    string
+	hostname    string
+	subdomain   string
+}
+
+func endpointFields(pod *corev1.Pod) podEndpoint {
+	return podEndpoint{
+		labels:      pod.Labels,
+		ips:         podIPs(pod),
+		ready:       podReady(pod),
+		terminating: pod.DeletionTimestamp != nil,
+		phase:       pod.Status.Phase,
+		nodeName:    pod.Spec.NodeName,
+		hostname:    pod.Spec.Hostname,
+		subdomain:   pod.Spec.Subdomain,
+	}
+}
+
+// Selects reports whether a selector of a headless service matches a pod. An empty selector
+// matches nothing, as for Services.
+func Selects(selector map[string]string, pod *corev1.Pod) bool {
+	return len(selector) > 0 && labels.SelectorFromSet(selector).Matches(labels.Set(pod.Labels))
+}
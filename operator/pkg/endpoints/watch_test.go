@@ -0,0 +1,52 @@
+package endpoints
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestPodEndpointChanged(t *testing.T) {
+	pod := slicePod("web-0", "10.0.0.1", "node-a", false)
+	pod.Labels = map[string]string{"app": "web"}
+
+	cases := map[string]struct {
+		change func(*corev1.Pod)
+		want   bool
+	}{
+		"unchanged": {func(*corev1.Pod) {}, false},
+		"container state": {func(p *corev1.Pod) {
+			p.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "web", RestartCount: 1}}
+		}, false},
+		"ip":          {func(p *corev1.Pod) { p.Status.PodIP = "10.0.0.9" }, true},
+		"ready":       {func(p *corev1.Pod) { p.Status.Conditions[0].Status = corev1.ConditionTrue }, true},
+		"labels":      {func(p *corev1.Pod) { p.Labels = map[string]string{"app": "api"} }, true},
+		"terminating": {func(p *corev1.Pod) { p.DeletionTimestamp = &metav1.Time{} }, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			current := pod.DeepCopy()
+			tc.change(current)
+			if got := PodEndpointChanged.Update(event.UpdateEvent{ObjectOld: pod, ObjectNew: current}); got != tc.want {
+				t.Errorf("PodEndpointChanged.Update() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+	if !PodEndpointChanged.Create(event.CreateEvent{Object: pod}) || !PodEndpointChanged.Delete(event.DeleteEvent{Object: pod}) {
+		t.Error("PodEndpointChanged drops pods that appear or disappear")
+	}
+}
+
+func TestSelects(t *testing.T) {
+	pod := slicePod("web-0", "10.0.0.1", "", true)
+	pod.Labels = map[string]string{"app": "web", "tier": "front"}
+
+	if !Selects(map[string]string{"app": "web"}, pod) {
+		t.Error("Selects() = false for a matching selector")
+	}
+	if Selects(map[string]string{"app": "api"}, pod) || Selects(nil, pod) {
+		t.Error("Selects() = true for a different or empty selector")
+	}
+}
@@ -0,0 +1,250 @@
+package features
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Feature is the name of a feature gate
+type Feature string
+
+// Stage is the maturity of a feature
+type Stage string
+
+// Feature stages
+const (
+	Alpha      Stage = "Alpha"
+	Beta       Stage = "Beta"
+	GA         Stage = "GA"
+	Deprecated Stage = "Deprecated"
+)
+
+// Features gating risky subsystems
+const (
+	// IptablesProxy programs iptables rules for HeadlessServices with an iptables proxy
+	IptablesProxy Feature = "IptablesProxy"
+	// AutoHealing runs the auto-healing reconciler for clusters that enable it
+	AutoHealing Feature = "AutoHealing"
+	// DriftRemediation pushes the spec back to the Aviatrix Controller when drift is detected
+	DriftRemediation Feature = "DriftRemediation"
+)
+
+// FeatureSpec describes a feature gate
+type FeatureSpec struct {
+	Default bool
+	Stage   Stage
+	// LockToDefault rejects attempts to change the default, used for GA features
+	LockToDefault bool
+}
+
+// defaultFeatures are the gates known to the operator
+var defaultFeatures = map[Feature]FeatureSpec{
+	IptablesProxy:    {Default: true, Stage: Beta},
+	AutoHealing:      {Default: true, Stage: Beta},
+	DriftRemediation: {Default: false, Stage: Alpha},
+}
+
+// DefaultGate holds the feature gates of the running operator
+var DefaultGate = NewGate(defaultFeatures)
+
+// Enabled reports whether a feature is enabled in the default gate
+func Enabled(feature Feature) bool {
+	return DefaultGate.Enabled(feature)
+}
+
+// FeatureStatus is the state of a feature gate as reported by the inspection endpoint
+type FeatureStatus struct {
+	Name    Feature `json:"name"`
+	Stage   Stage   `json:"stage"`
+	Default bool    `json:"default"`
+	Enabled bool    `json:"enabled"`
+}
+
+// Gate tracks which features are enabled. It implements flag.Value so it can be set with
+// --feature-gates=Name=true,Other=false. Runtime overrides set with SetRuntime take precedence
+// over the flag.
+type Gate struct {
+	mu      sync.RWMutex
+	known   map[Feature]FeatureSpec
+	enabled map[Feature]bool
+	runtime map[Feature]bool
+}
+
+// NewGate creates a gate for the given features, each set to its default
+func NewGate(known map[Feature]FeatureSpec) *Gate {
+	g := &Gate{
+		known:   make(map[Feature]FeatureSpec, len(known)),
+		enabled: make(map[Feature]bool),
+	}
+	for name, spec := range known {
+		g.known[name] = spec
+	}
+	return g
+}
+
+// Enabled reports whether a feature is enabled. Unknown features are disabled.
+func (g *Gate) Enabled(feature Feature) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if enabled, ok := g.runtime[feature]; ok {
+		return enabled
+	}
+	if enabled, ok := g.enabled[feature]; ok {
+		return enabled
+	}
+	return g.known[feature].Default
+}
+
+// Set parses a comma-separated list of Name=bool pairs and applies them. Nothing is applied
+// when any pair is invalid.
+func (g *Gate) Set(value string) error {
+	overrides, err := ParseOverrides(value)
+	if err != nil {
+		return err
+	}
+	return g.SetFromMap(overrides)
+}
+
+// ParseOverrides parses a comma-separated list of Name=bool pairs
+func ParseOverrides(value string) (map[Feature]bool, error) {
+	overrides := make(map[Feature]bool)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("missing bool value for feature gate %s", pair)
+		}
+		enabled, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid value %q for feature gate %s", raw, name)
+		}
+		overrides[Feature(strings.TrimSpace(name))] = enabled
+	}
+	return overrides, nil
+}
+
+// SetFromMap applies feature overrides. Nothing is applied when a feature is unknown or locked
+// to its default.
+func (g *Gate) SetFromMap(overrides map[Feature]bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.validate(overrides); err != nil {
+		return err
+	}
+	for name, enabled := range overrides {
+		g.enabled[name] = enabled
+	}
+	return nil
+}
+
+// Validate checks feature overrides without applying them
+func (g *Gate) Validate(overrides map[Feature]bool) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.validate(overrides)
+}
+
+// SetRuntime replaces the runtime overrides, which take precedence over the flag. Features
+// without a runtime override fall back to the flag or their default. Nothing is applied when
+// a feature is unknown or locked to its default.
+func (g *Gate) SetRuntime(overrides map[Feature]bool) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.validate(overrides); err != nil {
+		return err
+	}
+	g.runtime = make(map[Feature]bool, len(overrides))
+	for name, enabled := range overrides {
+		g.runtime[name] = enabled
+	}
+	return nil
+}
+
+func (g *Gate) validate(overrides map[Feature]bool) error {
+	for name, enabled := range overrides {
+		spec, ok := g.known[name]
+		if !ok {
+			return fmt.Errorf("unknown feature gate %s", name)
+		}
+		if spec.LockToDefault && enabled != spec.Default {
+			return fmt.Errorf("feature gate %s is locked to %t", name, spec.Default)
+		}
+	}
+	return nil
+}
+
+// String returns the overridden gates in the form accepted by Set
+func (g *Gate) String() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	pairs := make([]string, 0, len(g.enabled))
+	for name, enabled := range g.enabled {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Type names the flag value type for help output
+func (g *Gate) Type() string {
+	return "mapStringBool"
+}
+
+// Status returns the state of every known feature, sorted by name
+func (g *Gate) Status() []FeatureStatus {
+	g.mu.RLock()
+	names := make([]Feature, 0, len(g.known))
+	for name := range g.known {
+		names = append(names, name)
+	}
+	g.mu.RUnlock()
+
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	statuses := make([]FeatureStatus, 0, len(names))
+	for _, name := range names {
+		spec := g.known[name]
+		statuses = append(statuses, FeatureStatus{
+			Name:    name,
+			Stage:   spec.Stage,
+			Default: spec.Default,
+			Enabled: g.Enabled(name),
+		})
+	}
+	return statuses
+}
+
+// Usage describes the known features for the --feature-gates flag help
+func (g *Gate) Usage() string {
+	lines := []string{"A set of key=value pairs that enable or disable operator features. Options are:"}
+	for _, status := range g.Status() {
+		lines = append(lines, fmt.Sprintf("%s=true|false (%s - default=%t)", status.Name, status.Stage, status.Default))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Handler serves the state of every feature gate as JSON
+func (g *Gate) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(g.Status()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
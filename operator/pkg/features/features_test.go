@@ -0,0 +1,86 @@
+package features
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testGate() *Gate {
+	return NewGate(map[Feature]FeatureSpec{
+		"AlphaThing": {Default: false, Stage: Alpha},
+		"BetaThing":  {Default: true, Stage: Beta},
+		"GAThing":    {Default: true, Stage: GA, LockToDefault: true},
+	})
+}
+
+func TestGateSet(t *testing.T) {
+	gate := testGate()
+	if err := gate.Set("AlphaThing=true, BetaThing=false"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gate.Enabled("AlphaThing") || gate.Enabled("BetaThing") || !gate.Enabled("GAThing") {
+		t.Errorf("unexpected gate state: %s", gate.String())
+	}
+	if got, want := gate.String(), "AlphaThing=true,BetaThing=false"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+func TestGateSetRejectsInvalidValues(t *testing.T) {
+	for _, value := range []string{"Unknown=true", "AlphaThing", "AlphaThing=maybe", "GAThing=false", "AlphaThing=true,Unknown=true"} {
+		gate := testGate()
+		if err := gate.Set(value); err == nil {
+			t.Errorf("Set(%q) expected error", value)
+		}
+		if gate.Enabled("AlphaThing") {
+			t.Errorf("Set(%q) applied overrides despite failing", value)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	gate := testGate()
+	recorder := httptest.NewRecorder()
+	gate.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/featuregates", nil))
+
+	var statuses []FeatureStatus
+	if err := json.Unmarshal(recorder.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(statuses) != 3 || statuses[0].Name != "AlphaThing" || statuses[0].Stage != Alpha || statuses[0].Enabled {
+		t.Errorf("unexpected statuses: %+v", statuses)
+	}
+}
+
+func TestGateSetRuntime(t *testing.T) {
+	gate := testGate()
+	if err := gate.Set("AlphaThing=true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := gate.SetRuntime(map[Feature]bool{"AlphaThing": false, "BetaThing": false}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gate.Enabled("AlphaThing") || gate.Enabled("BetaThing") {
+		t.Errorf("runtime overrides not applied: %+v", gate.Status())
+	}
+	if got, want := gate.String(), "AlphaThing=true"; got != want {
+		t.Errorf("String() = %s, want the flag value %s", got, want)
+	}
+
+	if err := gate.SetRuntime(map[Feature]bool{"GAThing": false}); err == nil {
+		t.Error("SetRuntime() accepted a locked feature")
+	}
+	if gate.Enabled("AlphaThing") {
+		t.Error("failed SetRuntime() replaced the runtime overrides")
+	}
+
+	// Without runtime overrides the flag applies again
+	if err := gate.SetRuntime(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !gate.Enabled("AlphaThing") || !gate.Enabled("BetaThing") {
+		t.Errorf("flag values not restored: %+v", gate.Status())
+	}
+}
@@ -0,0 +1,225 @@
+package federation
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Locality modes of the service answers
+const (
+	// LocalityPreferCluster answers with the endpoints of the asking cluster and falls back to
+	// the other clusters only when it has none
+	LocalityPreferCluster = "PreferCluster"
+	// LocalityNone answers with the endpoints of every cluster
+	LocalityNone = "None"
+)
+
+const (
+	// DefaultDomain is the DNS domain of the federated view, as used by the multi-cluster
+	// services API
+	DefaultDomain = "clusterset.local"
+	// DefaultTTL is the TTL of the federated records in seconds
+	DefaultTTL = 30
+	// ResyncInterval is how often the view is rebuilt; member endpoints are not watched
+	ResyncInterval = 30 * time.Second
+
+	// ConditionDegraded is set while a member cluster cannot be reached and its endpoints are
+	// missing from the view
+	ConditionDegraded = "FederationDegraded"
+	// ZoneKeySuffix is appended to the cluster name to form the ConfigMap key of its zone file
+	ZoneKeySuffix = ".db"
+)
+
+// Endpoint is a ready endpoint of the headless service in one cluster
+type Endpoint struct {
+	Cluster  string
+	Hostname string
+	IP       string
+}
+
+// Record is a resource record of the federated view
+type Record struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// ConfigMapName returns the name of the ConfigMap holding the zone files of a headless service
+func ConfigMapName(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	if spec := headlessService.Spec.Federation; spec != nil && spec.ConfigMapName != "" {
+		return spec.ConfigMapName
+	}
+	return naming.Name(naming.Federation, headlessService.Name)
+}
+
+// Origin returns the name of the service in the federated view,
+// <service>.<namespace>.svc.<domain>., which is also the origin of its zone files
+func Origin(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	domain := DefaultDomain
+	if spec := headlessService.Spec.Federation; spec != nil && spec.Domain != "" {
+		domain = strings.TrimSuffix(spec.Domain, ".")
+	}
+	return fmt.Sprintf("%s.%s.svc.%s.", headlessService.Name, headlessService.Namespace, domain)
+}
+
+// Validate checks the federation settings of a headless service
+func Validate(headlessService *k8splaygroundsv1alpha1.HeadlessService) field.ErrorList {
+	spec := headlessService.Spec.Federation
+	if spec == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	path := field.NewPath("spec", "federation")
+
+	clusters := map[string]bool{}
+	if spec.ClusterName == "" {
+		errs = append(errs, field.Required(path.Child("clusterName"), "the name of this cluster is required"))
+	} else {
+		for _, msg := range validation.IsDNS1123Label(spec.ClusterName) {
+			errs = append(errs, field.Invalid(path.Child("clusterName"), spec.ClusterName, msg))
+		}
+		clusters[spec.ClusterName] = true
+	}
+	if spec.Domain != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(strings.TrimSuffix(spec.Domain, ".")) {
+			errs = append(errs, field.Invalid(path.Child("domain"), spec.Domain, msg))
+		}
+	}
+	switch spec.Locality {
+	case "", LocalityPreferCluster, LocalityNone:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("locality"), spec.Locality, []string{LocalityPreferCluster, LocalityNone}))
+	}
+	if spec.TTL < 0 {
+		errs = append(errs, field.Invalid(path.Child("ttl"), spec.TTL, "must not be negative"))
+	}
+
+	for i, member := range spec.Members {
+		memberPath := path.Child("members").Index(i)
+		if member.Name == "" {
+			errs = append(errs, field.Required(memberPath.Child("name"), "the member cluster name is required"))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(member.Name) {
+				errs = append(errs, field.Invalid(memberPath.Child("name"), member.Name, msg))
+			}
+			if clusters[member.Name] {
+				errs = append(errs, field.Duplicate(memberPath.Child("name"), member.Name))
+			}
+			clusters[member.Name] = true
+		}
+		if member.KubeconfigSecret == "" {
+			errs = append(errs, field.Required(memberPath.Child("kubeconfigSecret"), "the Secret holding the member kubeconfig is required"))
+		}
+	}
+	return errs
+}
+
+// FromSlices returns the ready endpoints of the EndpointSlices of a service in a cluster,
+// sorted by hostname and address. Endpoints without a hostname, e.g. of pods outside a
+// StatefulSet, are named after their pod.
+func FromSlices(cluster string, slices []discoveryv1.EndpointSlice) []Endpoint {
+	seen := map[string]bool{}
+	var result []Endpoint
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			hostname := ""
+			if endpoint.Hostname != nil {
+				hostname = *endpoint.Hostname
+			} else if ref := endpoint.TargetRef; ref != nil && ref.Kind == "Pod" {
+				hostname = ref.Name
+			}
+			for _, address := range endpoint.Addresses {
+				if seen[address] {
+					continue
+				}
+				seen[address] = true
+				result = append(result, Endpoint{Cluster: cluster, Hostname: hostname, IP: address})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hostname != result[j].Hostname {
+			return result[i].Hostname < result[j].Hostname
+		}
+		return result[i].IP < result[j].IP
+	})
+	return result
+}
+
+// Records returns the federated view of a headless service as seen from a cluster:
+//
+//	<service>...             the endpoints chosen by the locality mode
+//	*.<service>...           the same, for any other name below the service
+//	<cluster>.<service>...   the endpoints of one cluster
+//	<host>.<cluster>.<service>...  a single endpoint
+//
+// endpoints holds the endpoints of every cluster; the result is sorted
+func Records(headlessService *k8splaygroundsv1alpha1.HeadlessService, view string, endpoints []Endpoint) []Record {
+	origin := Origin(headlessService)
+	local := false
+	for _, endpoint := range endpoints {
+		if endpoint.Cluster == view {
+			local = true
+			break
+		}
+	}
+	preferCluster := headlessService.Spec.Federation == nil || headlessService.Spec.Federation.Locality != LocalityNone
+
+	var records []Record
+	for _, endpoint := range endpoints {
+		recordType := "A"
+		if ip := net.ParseIP(endpoint.IP); ip != nil && ip.To4() == nil {
+			recordType = "AAAA"
+		}
+		if !preferCluster || !local || endpoint.Cluster == view {
+			records = append(records,
+				Record{Name: origin, Type: recordType, Value: endpoint.IP},
+				Record{Name: "*." + origin, Type: recordType, Value: endpoint.IP},
+			)
+		}
+		clusterName := endpoint.Cluster + "." + origin
+		records = append(records, Record{Name: clusterName, Type: recordType, Value: endpoint.IP})
+		if endpoint.Hostname != "" {
+			records = append(records, Record{Name: endpoint.Hostname + "." + clusterName, Type: recordType, Value: endpoint.IP})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Value < records[j].Value
+	})
+	return records
+}
+
+// ZoneFile renders records as a zone file for the CoreDNS file plugin. The serial is derived
+// from the records, so the file only changes when the view does.
+func ZoneFile(origin string, ttl int32, records []Record) string {
+	h := fnv.New32a()
+	for _, record := range records {
+		fmt.Fprintf(h, "%s %s %s\n", record.Name, record.Type, record.Value)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "$ORIGIN %s\n$TTL %d\n", origin, ttl)
+	fmt.Fprintf(&b, "@ IN SOA ns.dns.%s hostmaster.%s %d 7200 1800 86400 %d\n", origin, origin, h.Sum32(), ttl)
+	fmt.Fprintf(&b, "@ IN NS ns.dns.%s\n", origin)
+	for _, record := range records {
+		fmt.Fprintf(&b, "%s IN %s %s\n", record.Name, record.Type, record.Value)
+	}
+	return b.String()
+}
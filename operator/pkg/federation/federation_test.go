@@ -0,0 +1,194 @@
+package federation
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+const memberKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: east
+  cluster:
+    server: https://east.example.com
+contexts:
+- name: east
+  context:
+    cluster: east
+current-context: east
+`
+
+func federated(locality string, members ...k8splaygroundsv1alpha1.FederationMember) *k8splaygroundsv1alpha1.HeadlessService {
+	return &k8splaygroundsv1alpha1.HeadlessService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "cassandra", UID: "uid"},
+		Spec: k8splaygroundsv1alpha1.HeadlessServiceSpec{
+			Federation: &k8splaygroundsv1alpha1.FederationSpec{ClusterName: "west", Members: members, Locality: locality},
+		},
+	}
+}
+
+func slice(ready map[string]bool) discoveryv1.EndpointSlice {
+	s := discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "cassandra-abc", Labels: map[string]string{discoveryv1.LabelServiceName: "cassandra"}},
+	}
+	for name, isReady := range ready {
+		isReady := isReady
+		hostname := name
+		ip := map[string]string{"cassandra-0": "10.0.0.1", "cassandra-1": "10.0.0.2", "cassandra-2": "10.1.0.1"}[name]
+		s.Endpoints = append(s.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{ip},
+			Hostname:   &hostname,
+			Conditions: discoveryv1.EndpointConditions{Ready: &isReady},
+		})
+	}
+	return s
+}
+
+func names(records []Record, value string) []string {
+	var result []string
+	for _, record := range records {
+		if record.Value == value {
+			result = append(result, record.Name)
+		}
+	}
+	return result
+}
+
+func TestValidate(t *testing.T) {
+	if errs := Validate(federated("", k8splaygroundsv1alpha1.FederationMember{Name: "east", KubeconfigSecret: "east"})); len(errs) != 0 {
+		t.Errorf("Validate() = %v for a valid federation", errs)
+	}
+
+	invalid := federated("Nearest",
+		k8splaygroundsv1alpha1.FederationMember{Name: "west", KubeconfigSecret: "west"},
+		k8splaygroundsv1alpha1.FederationMember{Name: "East"},
+	)
+	errs := Validate(invalid)
+	fields := map[string]bool{}
+	for _, err := range errs {
+		fields[err.Field] = true
+	}
+	for _, want := range []string{"spec.federation.locality", "spec.federation.members[0].name", "spec.federation.members[1].name", "spec.federation.members[1].kubeconfigSecret"} {
+		if !fields[want] {
+			t.Errorf("Validate() did not reject %s: %v", want, errs)
+		}
+	}
+}
+
+func TestFromSlicesSkipsUnready(t *testing.T) {
+	endpoints := FromSlices("west", []discoveryv1.EndpointSlice{slice(map[string]bool{"cassandra-0": true, "cassandra-1": false})})
+	if len(endpoints) != 1 || endpoints[0] != (Endpoint{Cluster: "west", Hostname: "cassandra-0", IP: "10.0.0.1"}) {
+		t.Errorf("FromSlices() = %v, want only the ready endpoint", endpoints)
+	}
+}
+
+func TestRecordsPreferLocalCluster(t *testing.T) {
+	endpoints := []Endpoint{
+		{Cluster: "west", Hostname: "cassandra-0", IP: "10.0.0.1"},
+		{Cluster: "east", Hostname: "cassandra-0", IP: "10.1.0.1"},
+	}
+	headlessService := federated("")
+	origin := "cassandra.db.svc.clusterset.local."
+
+	records := Records(headlessService, "west", endpoints)
+	if got := strings.Join(names(records, "10.0.0.1"), " "); got != "*."+origin+" cassandra-0.west."+origin+" "+origin+" west."+origin {
+		t.Errorf("records of the local endpoint = %s", got)
+	}
+	if got := strings.Join(names(records, "10.1.0.1"), " "); got != "cassandra-0.east."+origin+" east."+origin {
+		t.Errorf("records of the remote endpoint = %s, want only cluster-suffix records", got)
+	}
+
+	// A cluster without endpoints of its own is answered with those of the others
+	if got := names(Records(headlessService, "north", endpoints), "10.1.0.1"); len(got) != 4 {
+		t.Errorf("records of the remote endpoint seen from an empty cluster = %v", got)
+	}
+
+	if got := names(Records(federated(LocalityNone), "west", endpoints), "10.1.0.1"); len(got) != 4 {
+		t.Errorf("records of the remote endpoint without locality = %v", got)
+	}
+}
+
+func TestZoneFileSerialFollowsRecords(t *testing.T) {
+	records := []Record{{Name: "cassandra.db.svc.clusterset.local.", Type: "A", Value: "10.0.0.1"}}
+	zone := ZoneFile("cassandra.db.svc.clusterset.local.", 30, records)
+	if !strings.Contains(zone, "$ORIGIN cassandra.db.svc.clusterset.local.\n") || !strings.Contains(zone, "cassandra.db.svc.clusterset.local. IN A 10.0.0.1\n") {
+		t.Errorf("ZoneFile() =\n%s", zone)
+	}
+	if ZoneFile("cassandra.db.svc.clusterset.local.", 30, records) != zone {
+		t.Error("ZoneFile() changed for the same records")
+	}
+	records[0].Value = "10.0.0.2"
+	if ZoneFile("cassandra.db.svc.clusterset.local.", 30, records) == zone {
+		t.Error("ZoneFile() kept the serial for different records")
+	}
+}
+
+func TestReconcileMergesMembers(t *testing.T) {
+	scheme := clientgoscheme.Scheme
+	headlessService := federated("",
+		k8splaygroundsv1alpha1.FederationMember{Name: "east", KubeconfigSecret: "east-kubeconfig"},
+		k8splaygroundsv1alpha1.FederationMember{Name: "south", KubeconfigSecret: "missing"},
+	)
+	local := slice(map[string]bool{"cassandra-0": true})
+	remote := slice(map[string]bool{"cassandra-2": true})
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "east-kubeconfig"},
+		Data:       map[string][]byte{"kubeconfig": []byte(memberKubeconfig)},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&local, secret).WithInterceptorFuncs(applytest.Funcs()).Build()
+
+	m := NewManager(c, scheme)
+	m.newClient = func(config *rest.Config) (client.Reader, error) {
+		if config.Host != "https://east.example.com" {
+			t.Errorf("connected to %s", config.Host)
+		}
+		return fake.NewClientBuilder().WithScheme(scheme).WithObjects(&remote).Build(), nil
+	}
+	if err := m.Reconcile(context.Background(), headlessService); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	status := headlessService.Status.Federation
+	if status == nil || status.Endpoints != 2 || len(status.Clusters) != 3 {
+		t.Fatalf("status = %+v, want 2 endpoints of 3 clusters", status)
+	}
+	if south := status.Clusters[2]; south.Reachable || south.Message == "" {
+		t.Errorf("unreachable member status = %+v", south)
+	}
+	if !meta.IsStatusConditionTrue(headlessService.Status.Conditions, ConditionDegraded) {
+		t.Error("FederationDegraded not set for an unreachable member")
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "db", Name: "cassandra-federation"}, configMap); err != nil {
+		t.Fatalf("federation ConfigMap: %v", err)
+	}
+	if !strings.Contains(configMap.Data["east.db"], "cassandra-2.east.cassandra.db.svc.clusterset.local. IN A 10.1.0.1") {
+		t.Errorf("zone of east =\n%s", configMap.Data["east.db"])
+	}
+	if !strings.Contains(configMap.Data["west.db"], "cassandra.db.svc.clusterset.local. IN A 10.0.0.1") {
+		t.Errorf("zone of west =\n%s", configMap.Data["west.db"])
+	}
+
+	headlessService.Spec.Federation = nil
+	if err := m.Cleanup(context.Background(), headlessService); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	if headlessService.Status.Federation != nil || meta.FindStatusCondition(headlessService.Status.Conditions, ConditionDegraded) != nil {
+		t.Error("Cleanup() left the federation status")
+	}
+}
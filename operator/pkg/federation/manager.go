@@ -0,0 +1,202 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/access"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+)
+
+// memberTimeout bounds the calls to a member cluster, so an unreachable member does not stall
+// the reconcile
+const memberTimeout = 10 * time.Second
+
+// Manager merges the endpoints of a headless service across member clusters and publishes the
+// federated DNS view
+type Manager struct {
+	client client.Client
+	scheme *runtime.Scheme
+	// newClient connects to a member cluster
+	newClient func(*rest.Config) (client.Reader, error)
+}
+
+// NewManager creates a new federation manager
+func NewManager(client client.Client, scheme *runtime.Scheme) *Manager {
+	m := &Manager{
+		client: client,
+		scheme: scheme,
+	}
+	m.newClient = m.connect
+	return m
+}
+
+// Reconcile collects the ready endpoints of the headless service in this cluster and in every
+// member, writes one zone file per cluster into the federation ConfigMap and records the result
+// in the status. Members that cannot be reached are reported and left out of the view.
+func (m *Manager) Reconcile(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	log := logr.FromContextOrDiscard(ctx)
+	spec := headlessService.Spec.Federation
+
+	localSlices, err := listSlices(ctx, m.client, headlessService.Namespace, headlessService.Name)
+	if err != nil {
+		return err
+	}
+	endpoints := FromSlices(spec.ClusterName, localSlices)
+	clusters := []k8splaygroundsv1alpha1.FederationMemberStatus{{Name: spec.ClusterName, Reachable: true, Endpoints: int32(len(endpoints))}}
+
+	var unreachable []string
+	for _, member := range spec.Members {
+		memberEndpoints, err := m.memberEndpoints(ctx, headlessService, member)
+		if err != nil {
+			log.Info("federation member unreachable", "member", member.Name, "error", err.Error())
+			unreachable = append(unreachable, member.Name)
+			clusters = append(clusters, k8splaygroundsv1alpha1.FederationMemberStatus{Name: member.Name, Message: err.Error()})
+			continue
+		}
+		endpoints = append(endpoints, memberEndpoints...)
+		clusters = append(clusters, k8splaygroundsv1alpha1.FederationMemberStatus{Name: member.Name, Reachable: true, Endpoints: int32(len(memberEndpoints))})
+	}
+
+	ttl := int32(DefaultTTL)
+	if spec.TTL > 0 {
+		ttl = spec.TTL
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName(headlessService),
+			Namespace: headlessService.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/name":     "headless-service-federation",
+				"app.kubernetes.io/instance": headlessService.Name,
+			},
+			OwnerReferences: []metav1.OwnerReference{ownerReference(headlessService)},
+		},
+		Data: map[string]string{},
+	}
+	for _, cluster := range clusters {
+		configMap.Data[cluster.Name+ZoneKeySuffix] = ZoneFile(Origin(headlessService), ttl, Records(headlessService, cluster.Name, endpoints))
+	}
+	// Zones of clusters that left the federation are dropped with the fields they set
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return fmt.Errorf("failed to publish federation zones: %w", err)
+	}
+
+	headlessService.Status.Federation = &k8splaygroundsv1alpha1.FederationStatus{
+		ConfigMapName: configMap.Name,
+		Endpoints:     int32(len(endpoints)),
+		Clusters:      clusters,
+		SyncedAt:      metav1.Now(),
+	}
+	if len(unreachable) > 0 {
+		meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+			Type:               ConditionDegraded,
+			Status:             metav1.ConditionTrue,
+			Reason:             "MemberUnreachable",
+			Message:            fmt.Sprintf("Endpoints of %s are missing from the federated view", strings.Join(unreachable, ", ")),
+			ObservedGeneration: headlessService.Generation,
+		})
+	} else {
+		meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+			Type:               ConditionDegraded,
+			Status:             metav1.ConditionFalse,
+			Reason:             "AllMembersReachable",
+			Message:            fmt.Sprintf("Federated view holds %d endpoints of %d clusters", len(endpoints), len(clusters)),
+			ObservedGeneration: headlessService.Generation,
+		})
+	}
+	return nil
+}
+
+// Cleanup removes the federation ConfigMap and status once federation is no longer requested
+func (m *Manager) Cleanup(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	status := headlessService.Status.Federation
+	if status == nil {
+		return nil
+	}
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: status.ConfigMapName, Namespace: headlessService.Namespace}}
+	if err := m.client.Delete(ctx, configMap); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete federation zones: %w", err)
+	}
+	headlessService.Status.Federation = nil
+	meta.RemoveStatusCondition(&headlessService.Status.Conditions, ConditionDegraded)
+	return nil
+}
+
+// memberEndpoints connects to a member cluster with its kubeconfig Secret and returns the ready
+// endpoints of the headless service with the same name there
+func (m *Manager) memberEndpoints(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, member k8splaygroundsv1alpha1.FederationMember) ([]Endpoint, error) {
+	secret := &corev1.Secret{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: member.KubeconfigSecret, Namespace: headlessService.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig secret %s: %w", member.KubeconfigSecret, err)
+	}
+	key := member.KubeconfigKey
+	if key == "" {
+		key = access.KubeconfigKey
+	}
+	kubeconfig, ok := secret.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig secret %s has no key %s", member.KubeconfigSecret, key)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubeconfig in secret %s: %w", member.KubeconfigSecret, err)
+	}
+	config.Timeout = memberTimeout
+	memberClient, err := m.newClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	namespace := member.Namespace
+	if namespace == "" {
+		namespace = headlessService.Namespace
+	}
+	ctx, cancel := context.WithTimeout(ctx, memberTimeout)
+	defer cancel()
+	slices, err := listSlices(ctx, memberClient, namespace, headlessService.Name)
+	if err != nil {
+		return nil, err
+	}
+	return FromSlices(member.Name, slices), nil
+}
+
+// ownerReference makes the headless service the controller of the federation ConfigMap
+func ownerReference(headlessService *k8splaygroundsv1alpha1.HeadlessService) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: headlessService.APIVersion,
+		Kind:       headlessService.Kind,
+		Name:       headlessService.Name,
+		UID:        headlessService.UID,
+		Controller: &[]bool{true}[0],
+	}
+}
+
+// connect creates a client for a member cluster
+func (m *Manager) connect(config *rest.Config) (client.Reader, error) {
+	return client.New(config, client.Options{Scheme: m.scheme})
+}
+
+// listSlices returns the EndpointSlices of a Service
+func listSlices(ctx context.Context, reader client.Reader, namespace, name string) ([]discoveryv1.EndpointSlice, error) {
+	slices := &discoveryv1.EndpointSliceList{}
+	if err := reader.List(ctx, slices, client.InNamespace(namespace), client.MatchingLabels{discoveryv1.LabelServiceName: name}); err != nil {
+		return nil, fmt.Errorf("failed to list endpoint slices of %s/%s: %w", namespace, name, err)
+	}
+	return slices.Items, nil
+}
@@ -0,0 +1,186 @@
+package fleet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apis/conditions"
+	"github.com/k8s-playgrounds/operator/pkg/hooks"
+)
+
+// DefaultMaxFailingComponents caps the failing components listed when the fleet does not set it
+const DefaultMaxFailingComponents = 20
+
+// Selects reports whether a cluster belongs to the fleet
+func Selects(fleet *k8splaygroundsv1alpha1.K8sPlaygroundsFleet, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) (bool, error) {
+	if len(fleet.Spec.Namespaces) > 0 {
+		found := false
+		for _, namespace := range fleet.Spec.Namespaces {
+			if namespace == cluster.Namespace {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if fleet.Spec.Selector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(fleet.Spec.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid selector: %w", err)
+	}
+	return selector.Matches(labels.Set(cluster.Labels)), nil
+}
+
+// Converged reports whether a cluster is Running and Healthy
+func Converged(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) bool {
+	return cluster.Status.Phase == k8splaygroundsv1alpha1.ClusterPhaseRunning &&
+		cluster.Status.Health == k8splaygroundsv1alpha1.ClusterHealthHealthy
+}
+
+// Summarize aggregates the status of the fleet's clusters. A cluster's converging time carries
+// over from the previous status, so it counts from when the fleet first saw the cluster
+// unconverged; clusters that are still being set up count from their creation.
+func Summarize(fleet *k8splaygroundsv1alpha1.K8sPlaygroundsFleet, clusters []k8splaygroundsv1alpha1.K8sPlaygroundsCluster, now time.Time) k8splaygroundsv1alpha1.K8sPlaygroundsFleetStatus {
+	previous := make(map[types.NamespacedName]*metav1.Time)
+	for _, progress := range fleet.Status.Converging {
+		previous[types.NamespacedName{Namespace: progress.Namespace, Name: progress.Name}] = progress.Since
+	}
+
+	maxFailing := DefaultMaxFailingComponents
+	if fleet.Spec.MaxFailingComponents > 0 {
+		maxFailing = int(fleet.Spec.MaxFailingComponents)
+	}
+
+	status := k8splaygroundsv1alpha1.K8sPlaygroundsFleetStatus{
+		ObservedGeneration: fleet.Generation,
+		Clusters:           int32(len(clusters)),
+		Phases:             make(map[string]int32),
+		Health:             make(map[string]int32),
+		LastUpdated:        metav1.NewTime(now),
+	}
+
+	for i := range clusters {
+		cluster := &clusters[i]
+		status.Phases[phaseOf(cluster)]++
+		status.Health[healthOf(cluster)]++
+
+		if Converged(cluster) {
+			status.Converged++
+		} else {
+			since := previous[types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name}]
+			if since == nil {
+				since = firstSeen(cluster, now)
+			}
+			status.Converging = append(status.Converging, k8splaygroundsv1alpha1.FleetClusterProgress{
+				Namespace: cluster.Namespace,
+				Name:      cluster.Name,
+				Phase:     phaseOf(cluster),
+				Health:    healthOf(cluster),
+				Since:     since,
+			})
+		}
+
+		for _, failure := range failingComponents(cluster) {
+			status.FailingComponentCount++
+			if len(status.FailingComponents) < maxFailing {
+				status.FailingComponents = append(status.FailingComponents, failure)
+			}
+		}
+	}
+
+	sort.SliceStable(status.Converging, func(i, j int) bool {
+		a, b := status.Converging[i], status.Converging[j]
+		if !a.Since.Equal(b.Since) {
+			return a.Since.Before(b.Since)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	return status
+}
+
+// SlowestConverging returns how long the slowest cluster has been converging, or zero
+func SlowestConverging(status *k8splaygroundsv1alpha1.K8sPlaygroundsFleetStatus, now time.Time) time.Duration {
+	if len(status.Converging) == 0 || status.Converging[0].Since == nil {
+		return 0
+	}
+	return now.Sub(status.Converging[0].Since.Time)
+}
+
+// firstSeen returns when an unconverged cluster the fleet has not tracked yet started
+// converging: its creation while it is still being set up, or now
+func firstSeen(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, now time.Time) *metav1.Time {
+	switch cluster.Status.Phase {
+	case "", k8splaygroundsv1alpha1.ClusterPhasePending, k8splaygroundsv1alpha1.ClusterPhaseQueued:
+		if !cluster.CreationTimestamp.IsZero() {
+			created := cluster.CreationTimestamp
+			return &created
+		}
+	}
+	since := metav1.NewTime(now)
+	return &since
+}
+
+// failingComponents returns the components of a cluster whose status reports a failure
+func failingComponents(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) []k8splaygroundsv1alpha1.FleetComponentFailure {
+	var failures []k8splaygroundsv1alpha1.FleetComponentFailure
+	add := func(kind, name, message string) {
+		failures = append(failures, k8splaygroundsv1alpha1.FleetComponentFailure{
+			Namespace: cluster.Namespace,
+			Cluster:   cluster.Name,
+			Kind:      kind,
+			Name:      name,
+			Message:   message,
+		})
+	}
+
+	for _, s := range cluster.Status.ServiceStatuses {
+		if conditions.Failing(s.Phase) {
+			add("Service", s.Name, s.Message)
+		}
+	}
+	for _, s := range cluster.Status.HeadlessServiceStatuses {
+		if conditions.Failing(s.Phase) {
+			add("HeadlessService", s.Name, s.Message)
+		}
+	}
+	for _, s := range cluster.Status.StatefulSetStatuses {
+		if conditions.Failing(s.Phase) {
+			add("StatefulSet", s.Name, s.Message)
+		}
+	}
+	for _, h := range cluster.Status.Hooks {
+		if h.State == hooks.StateFailed {
+			add("Hook", h.Name, h.Message)
+		}
+	}
+	return failures
+}
+
+func phaseOf(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	if cluster.Status.Phase == "" {
+		return string(k8splaygroundsv1alpha1.ClusterPhasePending)
+	}
+	return string(cluster.Status.Phase)
+}
+
+func healthOf(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	if cluster.Status.Health == "" {
+		return string(k8splaygroundsv1alpha1.ClusterHealthUnknown)
+	}
+	return string(cluster.Status.Health)
+}
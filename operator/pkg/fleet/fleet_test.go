@@ -0,0 +1,93 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func newCluster(namespace, name string, phase k8splaygroundsv1alpha1.ClusterPhase, health k8splaygroundsv1alpha1.ClusterHealth) k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	cluster := k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	cluster.Namespace = namespace
+	cluster.Name = name
+	cluster.Labels = map[string]string{"workshop": "k8s-101"}
+	cluster.Status.Phase = phase
+	cluster.Status.Health = health
+	return cluster
+}
+
+func TestSelects(t *testing.T) {
+	cluster := newCluster("team-a", "demo", "", "")
+	tests := []struct {
+		name string
+		spec k8splaygroundsv1alpha1.K8sPlaygroundsFleetSpec
+		want bool
+	}{
+		{name: "everything", want: true},
+		{name: "matching labels", spec: k8splaygroundsv1alpha1.K8sPlaygroundsFleetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"workshop": "k8s-101"}},
+		}, want: true},
+		{name: "other labels", spec: k8splaygroundsv1alpha1.K8sPlaygroundsFleetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"workshop": "helm"}},
+		}},
+		{name: "other namespace", spec: k8splaygroundsv1alpha1.K8sPlaygroundsFleetSpec{Namespaces: []string{"team-b"}}},
+	}
+	for _, tt := range tests {
+		fleet := &k8splaygroundsv1alpha1.K8sPlaygroundsFleet{Spec: tt.spec}
+		got, err := Selects(fleet, &cluster)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Selects() = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	earlier := metav1.NewTime(now.Add(-10 * time.Minute))
+
+	running := newCluster("team-a", "ready", k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterHealthHealthy)
+	updating := newCluster("team-a", "updating", k8splaygroundsv1alpha1.ClusterPhaseUpdating, k8splaygroundsv1alpha1.ClusterHealthDegraded)
+	updating.Status.HeadlessServiceStatuses = []k8splaygroundsv1alpha1.HeadlessServiceStatus{{Name: "db", Phase: "Failed", Message: "DNS resolution failed"}}
+	pending := newCluster("team-b", "new", "", "")
+	pending.CreationTimestamp = metav1.NewTime(now.Add(-2 * time.Minute))
+	pending.Status.Hooks = []k8splaygroundsv1alpha1.HookStatus{{Name: "migrate", State: "Failed"}, {Name: "seed", State: "Succeeded"}}
+
+	fleet := &k8splaygroundsv1alpha1.K8sPlaygroundsFleet{}
+	fleet.Spec.MaxFailingComponents = 1
+	fleet.Status.Converging = []k8splaygroundsv1alpha1.FleetClusterProgress{{Namespace: "team-a", Name: "updating", Since: &earlier}}
+
+	status := Summarize(fleet, []k8splaygroundsv1alpha1.K8sPlaygroundsCluster{running, updating, pending}, now)
+
+	if status.Clusters != 3 || status.Converged != 1 {
+		t.Errorf("clusters = %d, converged = %d, want 3 and 1", status.Clusters, status.Converged)
+	}
+	if status.Phases["Running"] != 1 || status.Phases["Updating"] != 1 || status.Phases["Pending"] != 1 {
+		t.Errorf("phases = %v", status.Phases)
+	}
+	if status.Health["Healthy"] != 1 || status.Health["Degraded"] != 1 || status.Health["Unknown"] != 1 {
+		t.Errorf("health = %v", status.Health)
+	}
+
+	if len(status.Converging) != 2 || status.Converging[0].Name != "updating" || status.Converging[1].Name != "new" {
+		t.Fatalf("converging = %+v, want updating then new", status.Converging)
+	}
+	if !status.Converging[0].Since.Equal(&earlier) {
+		t.Errorf("since = %v, want it carried over from the previous status", status.Converging[0].Since)
+	}
+	if !status.Converging[1].Since.Equal(&pending.CreationTimestamp) {
+		t.Errorf("since = %v, want the creation time of a new cluster", status.Converging[1].Since)
+	}
+	if got := SlowestConverging(&status, now); got != 10*time.Minute {
+		t.Errorf("SlowestConverging() = %s, want 10m", got)
+	}
+
+	if status.FailingComponentCount != 2 || len(status.FailingComponents) != 1 {
+		t.Errorf("failing = %d listed %v, want 2 counted and 1 listed", status.FailingComponentCount, status.FailingComponents)
+	}
+	if f := status.FailingComponents[0]; f.Kind != "HeadlessService" || f.Name != "db" || f.Cluster != "updating" {
+		t.Errorf("failing component = %+v", f)
+	}
+}
@@ -0,0 +1,472 @@
+package fwimport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// Cloud types whose security groups can be imported
+const (
+	// CloudAWS imports AWS security group rules
+	CloudAWS = "aws"
+	// CloudAzure imports Azure network security group rules
+	CloudAzure = "azure"
+)
+
+// Output formats supported by Write
+const (
+	// FormatRules emits the list of firewall rules
+	FormatRules = "rules"
+	// FormatFirewall emits a proposed AviatrixFirewall manifest
+	FormatFirewall = "firewall"
+)
+
+const (
+	// anyCIDR stands for any address
+	anyCIDR = "0.0.0.0/0"
+	// allPorts is the Aviatrix port range matching every port
+	allPorts = "0:65535"
+	// proposedBasePolicy matches the implicit deny of security groups and NSGs
+	proposedBasePolicy = "deny-all"
+)
+
+// Options control how cloud rules are translated
+type Options struct {
+	// CloudType is the cloud the rules come from (aws or azure)
+	CloudType string
+	// LocalCIDR is the address range protected by the group. It is the destination of inbound
+	// rules, the source of outbound rules and the value of the Azure VirtualNetwork tag.
+	// Defaults to 0.0.0.0/0.
+	LocalCIDR string
+	// LogEnabled enables logging on every generated rule
+	LogEnabled bool
+}
+
+// Skipped is a cloud rule that has no Aviatrix equivalent
+type Skipped struct {
+	// Rule is the ID or name of the cloud rule
+	Rule string
+	// Reason explains why the rule was not imported
+	Reason string
+}
+
+func (s Skipped) String() string {
+	return fmt.Sprintf("%s: %s", s.Rule, s.Reason)
+}
+
+// Result holds the translated rules and the cloud rules that were left out
+type Result struct {
+	Rules   []aviatrixv1alpha1.FirewallRule
+	Skipped []Skipped
+}
+
+// Importer reads security group rules from the Controller's cloud inventory
+type Importer struct {
+	client *aviatrix.Client
+}
+
+// NewImporter creates a new firewall rule importer
+func NewImporter(client *aviatrix.Client) *Importer {
+	return &Importer{
+		client: client,
+	}
+}
+
+// Import reads the rules of a security group or NSG and translates them to firewall rules
+func (i *Importer) Import(ctx context.Context, accountName, region, groupID string, opts Options) (*Result, error) {
+	rules, err := i.client.ListSecurityGroupRules(ctx, accountName, opts.CloudType, region, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return Convert(rules, opts)
+}
+
+// Convert translates security group or NSG rules, as returned by the cloud inventory, to
+// firewall rules. Azure rules are ordered by priority so the first match wins as it does in
+// the NSG. Rules the firewall cannot express exactly, such as references to other security
+// groups or service tags, are skipped rather than widened and reported in the result.
+func Convert(rules []map[string]interface{}, opts Options) (*Result, error) {
+	if opts.LocalCIDR == "" {
+		opts.LocalCIDR = anyCIDR
+	}
+
+	var convert func(map[string]interface{}, Options) ([]aviatrixv1alpha1.FirewallRule, error)
+	switch opts.CloudType {
+	case CloudAWS:
+		convert = convertAWS
+	case CloudAzure:
+		convert = convertAzure
+		rules = append([]map[string]interface{}(nil), rules...)
+		sort.SliceStable(rules, func(a, b int) bool {
+			return number(rules[a], "priority") < number(rules[b], "priority")
+		})
+	default:
+		return nil, fmt.Errorf("unsupported cloud type: %s", opts.CloudType)
+	}
+
+	result := &Result{}
+	seen := make(map[aviatrixv1alpha1.FirewallRule]bool)
+	for _, raw := range rules {
+		converted, err := convert(raw, opts)
+		if err != nil {
+			result.Skipped = append(result.Skipped, Skipped{Rule: ruleID(raw), Reason: err.Error()})
+			continue
+		}
+		for _, rule := range converted {
+			if !seen[rule] {
+				seen[rule] = true
+				result.Rules = append(result.Rules, rule)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Write renders the result in the requested format. The firewall format proposes an
+// AviatrixFirewall with a deny-all base policy for the given gateway.
+func Write(w io.Writer, result *Result, format, name, namespace, gwName string) error {
+	var out interface{}
+	switch format {
+	case FormatRules:
+		out = result.Rules
+	case FormatFirewall:
+		out = firewallManifest{
+			APIVersion: aviatrixv1alpha1.SchemeGroupVersion.String(),
+			Kind:       "AviatrixFirewall",
+			Metadata:   manifestMetadata{Name: name, Namespace: namespace},
+			Spec: aviatrixv1alpha1.AviatrixFirewallSpec{
+				GwName:     gwName,
+				BasePolicy: proposedBasePolicy,
+				Rules:      result.Rules,
+			},
+		}
+	default:
+		return fmt.Errorf("unsupported output format: %s", format)
+	}
+
+	data, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// firewallManifest is an AviatrixFirewall without status or server-set metadata
+type firewallManifest struct {
+	APIVersion string                                `json:"apiVersion"`
+	Kind       string                                `json:"kind"`
+	Metadata   manifestMetadata                      `json:"metadata"`
+	Spec       aviatrixv1alpha1.AviatrixFirewallSpec `json:"spec"`
+}
+
+type manifestMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// convertAWS translates a security group rule. The inventory returns one rule per peer, and
+// security groups only allow traffic.
+func convertAWS(raw map[string]interface{}, opts Options) ([]aviatrixv1alpha1.FirewallRule, error) {
+	if id := str(raw, "referenced_group_id"); id != "" {
+		return nil, fmt.Errorf("references security group %s", id)
+	}
+	if id := str(raw, "prefix_list_id"); id != "" {
+		return nil, fmt.Errorf("references prefix list %s", id)
+	}
+	peer := str(raw, "cidr_ipv4")
+	if peer == "" {
+		peer = str(raw, "cidr_ipv6")
+	}
+	if peer == "" {
+		return nil, fmt.Errorf("has no CIDR")
+	}
+
+	protocol, err := awsProtocol(str(raw, "ip_protocol"))
+	if err != nil {
+		return nil, err
+	}
+
+	port := allPorts
+	from, to := number(raw, "from_port"), number(raw, "to_port")
+	switch protocol {
+	case "tcp", "udp":
+		port = portRange(from, to)
+	case "icmp":
+		// ICMP rules carry the type and code in the port fields
+		if from != -1 {
+			return nil, fmt.Errorf("filters ICMP type %d, which firewall rules cannot express", from)
+		}
+	}
+
+	rule := aviatrixv1alpha1.FirewallRule{
+		Protocol:    protocol,
+		Port:        port,
+		Action:      "allow",
+		LogEnabled:  opts.LogEnabled,
+		Description: description(raw),
+	}
+	if boolean(raw, "is_egress") {
+		rule.SrcIP, rule.DstIP = opts.LocalCIDR, peer
+	} else {
+		rule.SrcIP, rule.DstIP = peer, opts.LocalCIDR
+	}
+	return []aviatrixv1alpha1.FirewallRule{rule}, nil
+}
+
+// convertAzure translates an NSG rule, expanding its address prefixes and port ranges
+func convertAzure(raw map[string]interface{}, opts Options) ([]aviatrixv1alpha1.FirewallRule, error) {
+	// NSG rules name both source and destination, so the direction does not change the mapping
+	switch strings.ToLower(str(raw, "direction")) {
+	case "inbound", "outbound":
+	default:
+		return nil, fmt.Errorf("unsupported direction: %s", str(raw, "direction"))
+	}
+
+	protocol, err := azureProtocol(str(raw, "protocol"))
+	if err != nil {
+		return nil, err
+	}
+
+	var action string
+	switch strings.ToLower(str(raw, "access")) {
+	case "allow":
+		action = "allow"
+	case "deny":
+		action = "deny"
+	default:
+		return nil, fmt.Errorf("unsupported access: %s", str(raw, "access"))
+	}
+
+	for _, sourcePort := range values(raw, "source_port_range", "source_port_ranges") {
+		if sourcePort != "*" {
+			return nil, fmt.Errorf("filters source ports, which firewall rules cannot express")
+		}
+	}
+
+	sources, err := azurePrefixes(values(raw, "source_address_prefix", "source_address_prefixes"), opts)
+	if err != nil {
+		return nil, err
+	}
+	destinations, err := azurePrefixes(values(raw, "destination_address_prefix", "destination_address_prefixes"), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ports := []string{allPorts}
+	if protocol == "tcp" || protocol == "udp" {
+		ports = nil
+		for _, r := range values(raw, "destination_port_range", "destination_port_ranges") {
+			port, err := azurePortRange(r)
+			if err != nil {
+				return nil, err
+			}
+			ports = append(ports, port)
+		}
+		if len(ports) == 0 {
+			ports = []string{allPorts}
+		}
+	}
+
+	var rules []aviatrixv1alpha1.FirewallRule
+	for _, src := range sources {
+		for _, dst := range destinations {
+			for _, port := range ports {
+				rules = append(rules, aviatrixv1alpha1.FirewallRule{
+					Protocol:    protocol,
+					SrcIP:       src,
+					DstIP:       dst,
+					Port:        port,
+					Action:      action,
+					LogEnabled:  opts.LogEnabled,
+					Description: description(raw),
+				})
+			}
+		}
+	}
+	return rules, nil
+}
+
+// awsProtocol maps an IP protocol name or number of a security group rule
+func awsProtocol(protocol string) (string, error) {
+	switch strings.ToLower(protocol) {
+	case "-1", "all":
+		return "all", nil
+	case "tcp", "6":
+		return "tcp", nil
+	case "udp", "17":
+		return "udp", nil
+	case "icmp", "1":
+		return "icmp", nil
+	}
+	return "", fmt.Errorf("unsupported protocol: %s", protocol)
+}
+
+// azureProtocol maps the protocol of an NSG rule
+func azureProtocol(protocol string) (string, error) {
+	switch strings.ToLower(protocol) {
+	case "*", "any":
+		return "all", nil
+	case "tcp":
+		return "tcp", nil
+	case "udp":
+		return "udp", nil
+	case "icmp":
+		return "icmp", nil
+	}
+	return "", fmt.Errorf("unsupported protocol: %s", protocol)
+}
+
+// azurePrefixes resolves the address prefixes of an NSG rule to CIDRs. Service tags other than
+// Internet and VirtualNetwork, and application security groups, have no CIDR equivalent.
+func azurePrefixes(prefixes []string, opts Options) ([]string, error) {
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("has no address prefix")
+	}
+
+	var cidrs []string
+	for _, prefix := range prefixes {
+		switch strings.ToLower(prefix) {
+		case "*", "internet":
+			cidrs = append(cidrs, anyCIDR)
+		case "virtualnetwork":
+			cidrs = append(cidrs, opts.LocalCIDR)
+		default:
+			cidr, err := normalizeCIDR(prefix)
+			if err != nil {
+				return nil, fmt.Errorf("references service tag or group %s", prefix)
+			}
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs, nil
+}
+
+// azurePortRange maps an NSG port range such as *, 443 or 8000-8080
+func azurePortRange(r string) (string, error) {
+	if r == "*" {
+		return allPorts, nil
+	}
+	from, to, found := strings.Cut(r, "-")
+	if !found {
+		to = from
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil {
+		return "", fmt.Errorf("invalid port range: %s", r)
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil {
+		return "", fmt.Errorf("invalid port range: %s", r)
+	}
+	return portRange(start, end), nil
+}
+
+// portRange formats a port range the way firewall rules expect
+func portRange(from, to int) string {
+	if from <= 0 && (to <= 0 || to >= 65535) {
+		return allPorts
+	}
+	if from == to {
+		return strconv.Itoa(from)
+	}
+	return fmt.Sprintf("%d:%d", from, to)
+}
+
+// normalizeCIDR accepts a CIDR or a single address, which becomes a host route
+func normalizeCIDR(value string) (string, error) {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network.String(), nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return "", fmt.Errorf("invalid address: %s", value)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+// ruleID returns the ID or name identifying a cloud rule in messages
+func ruleID(raw map[string]interface{}) string {
+	for _, key := range []string{"rule_id", "name"} {
+		if id := str(raw, key); id != "" {
+			return id
+		}
+	}
+	return "unnamed rule"
+}
+
+// description keeps the cloud rule's description, falling back to its ID so imported rules
+// can be traced back
+func description(raw map[string]interface{}) string {
+	if d := str(raw, "description"); d != "" {
+		return d
+	}
+	return "imported from " + ruleID(raw)
+}
+
+func str(raw map[string]interface{}, key string) string {
+	switch v := raw[key].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// number reads a JSON number, which the inventory may also encode as a string. Missing values
+// read as -1, the security group wildcard.
+func number(raw map[string]interface{}, key string) int {
+	switch v := raw[key].(type) {
+	case float64:
+		return int(v)
+	case string:
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+func boolean(raw map[string]interface{}, key string) bool {
+	switch v := raw[key].(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
+
+// values reads a field that NSG rules carry either as a single value or as a list
+func values(raw map[string]interface{}, singleKey, listKey string) []string {
+	var out []string
+	if list, ok := raw[listKey].([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	if len(out) == 0 {
+		if s := str(raw, singleKey); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
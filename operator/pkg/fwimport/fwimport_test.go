@@ -0,0 +1,105 @@
+package fwimport
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func TestConvertAWS(t *testing.T) {
+	rules := []map[string]interface{}{
+		{"rule_id": "sgr-1", "ip_protocol": "tcp", "from_port": float64(443), "to_port": float64(443), "cidr_ipv4": "0.0.0.0/0", "description": "https"},
+		{"rule_id": "sgr-2", "ip_protocol": "udp", "from_port": float64(8000), "to_port": float64(8100), "cidr_ipv4": "10.1.0.0/16"},
+		{"rule_id": "sgr-3", "ip_protocol": "-1", "from_port": float64(-1), "to_port": float64(-1), "cidr_ipv4": "0.0.0.0/0", "is_egress": true},
+		{"rule_id": "sgr-4", "ip_protocol": "tcp", "from_port": float64(22), "to_port": float64(22), "referenced_group_id": "sg-bastion"},
+		{"rule_id": "sgr-5", "ip_protocol": "icmp", "from_port": float64(8), "to_port": float64(0), "cidr_ipv4": "10.0.0.0/8"},
+		{"rule_id": "sgr-6", "ip_protocol": "tcp", "from_port": float64(443), "to_port": float64(443), "cidr_ipv4": "0.0.0.0/0", "description": "https"},
+	}
+
+	result, err := Convert(rules, Options{CloudType: CloudAWS, LocalCIDR: "10.20.0.0/16"})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	want := []aviatrixv1alpha1.FirewallRule{
+		{Protocol: "tcp", SrcIP: "0.0.0.0/0", DstIP: "10.20.0.0/16", Port: "443", Action: "allow", Description: "https"},
+		{Protocol: "udp", SrcIP: "10.1.0.0/16", DstIP: "10.20.0.0/16", Port: "8000:8100", Action: "allow", Description: "imported from sgr-2"},
+		{Protocol: "all", SrcIP: "10.20.0.0/16", DstIP: "0.0.0.0/0", Port: "0:65535", Action: "allow", Description: "imported from sgr-3"},
+	}
+	if !reflect.DeepEqual(result.Rules, want) {
+		t.Errorf("Rules = %+v, want %+v", result.Rules, want)
+	}
+	if len(result.Skipped) != 2 || result.Skipped[0].Rule != "sgr-4" || result.Skipped[1].Rule != "sgr-5" {
+		t.Errorf("Skipped = %v, want sgr-4 and sgr-5", result.Skipped)
+	}
+}
+
+func TestConvertAzure(t *testing.T) {
+	rules := []map[string]interface{}{
+		{"name": "deny-ssh", "priority": float64(200), "direction": "Inbound", "access": "Deny", "protocol": "Tcp",
+			"source_port_range": "*", "source_address_prefix": "Internet", "destination_address_prefix": "*", "destination_port_range": "22"},
+		{"name": "allow-web", "priority": float64(100), "direction": "Inbound", "access": "Allow", "protocol": "Tcp",
+			"source_port_range": "*", "source_address_prefixes": []interface{}{"203.0.113.10", "198.51.100.0/24"},
+			"destination_address_prefix": "VirtualNetwork", "destination_port_ranges": []interface{}{"80", "8080-8090"}},
+		{"name": "allow-lb", "priority": float64(300), "direction": "Inbound", "access": "Allow", "protocol": "*",
+			"source_port_range": "*", "source_address_prefix": "AzureLoadBalancer", "destination_address_prefix": "*", "destination_port_range": "*"},
+	}
+
+	result, err := Convert(rules, Options{CloudType: CloudAzure, LocalCIDR: "10.30.0.0/16", LogEnabled: true})
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	var got []string
+	for _, r := range result.Rules {
+		got = append(got, strings.Join([]string{r.Action, r.Protocol, r.SrcIP, r.DstIP, r.Port}, " "))
+		if !r.LogEnabled {
+			t.Errorf("rule %+v: expected logging to be enabled", r)
+		}
+	}
+	want := []string{
+		"allow tcp 203.0.113.10/32 10.30.0.0/16 80",
+		"allow tcp 203.0.113.10/32 10.30.0.0/16 8080:8090",
+		"allow tcp 198.51.100.0/24 10.30.0.0/16 80",
+		"allow tcp 198.51.100.0/24 10.30.0.0/16 8080:8090",
+		"deny tcp 0.0.0.0/0 0.0.0.0/0 22",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Rules = %v, want %v in priority order", got, want)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Rule != "allow-lb" {
+		t.Errorf("Skipped = %v, want allow-lb", result.Skipped)
+	}
+}
+
+func TestConvertUnsupportedCloud(t *testing.T) {
+	if _, err := Convert(nil, Options{CloudType: "gcp"}); err == nil {
+		t.Error("expected an error for an unsupported cloud type")
+	}
+}
+
+func TestWriteFirewall(t *testing.T) {
+	result := &Result{Rules: []aviatrixv1alpha1.FirewallRule{
+		{Protocol: "tcp", SrcIP: "0.0.0.0/0", DstIP: "10.20.0.0/16", Port: "443", Action: "allow"},
+	}}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, result, FormatFirewall, "web-sg", "networking", "spoke-gw"); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, want := range []string{"kind: AviatrixFirewall", "name: web-sg", "gwName: spoke-gw", "basePolicy: deny-all", "port: \"443\""} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "status") {
+		t.Errorf("output should not contain a status:\n%s", buf.String())
+	}
+
+	if err := Write(&buf, result, "json", "", "", ""); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
@@ -0,0 +1,230 @@
+package gatewayname
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+// Index is the cache index of gateway custom resources by spec.gwName
+const Index = "spec.gwName"
+
+// ConditionConflict is set on gateway custom resources whose gateway name is also claimed by
+// another custom resource
+const ConditionConflict = "GatewayNameConflict"
+
+// owner describes a kind that creates the gateway named in its spec. Gateway names are unique
+// across all gateway types in an Aviatrix Controller.
+type owner struct {
+	kind   string
+	object func() client.Object
+	list   func() client.ObjectList
+}
+
+var owners = []owner{
+	{
+		kind:   "AviatrixGateway",
+		object: func() client.Object { return &aviatrixv1alpha1.AviatrixGateway{} },
+		list:   func() client.ObjectList { return &aviatrixv1alpha1.AviatrixGatewayList{} },
+	},
+	{
+		kind:   "AviatrixSpokeGateway",
+		object: func() client.Object { return &aviatrixv1alpha1.AviatrixSpokeGateway{} },
+		list:   func() client.ObjectList { return &aviatrixv1alpha1.AviatrixSpokeGatewayList{} },
+	},
+	{
+		kind:   "AviatrixTransitGateway",
+		object: func() client.Object { return &aviatrixv1alpha1.AviatrixTransitGateway{} },
+		list:   func() client.ObjectList { return &aviatrixv1alpha1.AviatrixTransitGatewayList{} },
+	},
+	{
+		kind:   "AviatrixEdgeGateway",
+		object: func() client.Object { return &aviatrixv1alpha1.AviatrixEdgeGateway{} },
+		list:   func() client.ObjectList { return &aviatrixv1alpha1.AviatrixEdgeGatewayList{} },
+	},
+}
+
+// Objects returns an empty object of every kind that creates the gateway named in its spec
+func Objects() []client.Object {
+	objects := make([]client.Object, 0, len(owners))
+	for _, owner := range owners {
+		objects = append(objects, owner.object())
+	}
+	return objects
+}
+
+// Claim is a custom resource that names a gateway
+type Claim struct {
+	Kind      string
+	Namespace string
+	Name      string
+	GwName    string
+	Created   metav1.Time
+}
+
+// String identifies the custom resource of the claim as Kind namespace/name
+func (c Claim) String() string {
+	return fmt.Sprintf("%s %s/%s", c.Kind, c.Namespace, c.Name)
+}
+
+// Of returns the claim of a gateway custom resource, or false for other objects
+func Of(obj runtime.Object) (Claim, bool) {
+	var kind, name string
+	switch gateway := obj.(type) {
+	case *aviatrixv1alpha1.AviatrixGateway:
+		kind, name = "AviatrixGateway", gateway.Spec.GwName
+	case *aviatrixv1alpha1.AviatrixSpokeGateway:
+		kind, name = "AviatrixSpokeGateway", gateway.Spec.GwName
+	case *aviatrixv1alpha1.AviatrixTransitGateway:
+		kind, name = "AviatrixTransitGateway", gateway.Spec.GwName
+	case *aviatrixv1alpha1.AviatrixEdgeGateway:
+		kind, name = "AviatrixEdgeGateway", gateway.Spec.GwName
+	default:
+		return Claim{}, false
+	}
+	object := obj.(client.Object)
+	return Claim{
+		Kind:      kind,
+		Namespace: object.GetNamespace(),
+		Name:      object.GetName(),
+		GwName:    name,
+		Created:   object.GetCreationTimestamp(),
+	}, true
+}
+
+// SetupIndexes registers Index for every gateway kind with the cache of a manager. It must be
+// called once, before the gateway controllers are set up.
+func SetupIndexes(ctx context.Context, indexer client.FieldIndexer) error {
+	for _, owner := range owners {
+		err := indexer.IndexField(ctx, owner.object(), Index, func(obj client.Object) []string {
+			if claim, ok := Of(obj); ok && claim.GwName != "" {
+				return []string{claim.GwName}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to index %s by gateway name: %w", owner.kind, err)
+		}
+	}
+	return nil
+}
+
+// Others returns the other custom resources of any gateway kind and namespace that claim the
+// gateway name of claim, oldest first
+func Others(ctx context.Context, reader client.Reader, claim Claim) ([]Claim, error) {
+	if claim.GwName == "" {
+		return nil, nil
+	}
+
+	var others []Claim
+	for _, owner := range owners {
+		list := owner.list()
+		if err := reader.List(ctx, list, client.MatchingFields{Index: claim.GwName}); err != nil {
+			return nil, fmt.Errorf("failed to list %s by gateway name: %w", owner.kind, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			other, ok := Of(item)
+			if !ok || other.Kind == claim.Kind && other.Namespace == claim.Namespace && other.Name == claim.Name {
+				continue
+			}
+			others = append(others, other)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return before(others[i], others[j]) })
+	return others, nil
+}
+
+// before orders claims by creation time, breaking ties by kind, namespace and name so every
+// reconciler agrees on the owner
+func before(a, b Claim) bool {
+	if !a.Created.Equal(&b.Created) {
+		return a.Created.Before(&b.Created)
+	}
+	return a.String() < b.String()
+}
+
+// Check records conflicts over the gateway name of obj in its conditions and reports whether
+// obj owns the gateway. The oldest custom resource owns it; the others must not touch the
+// gateway until the conflict is resolved.
+func Check(ctx context.Context, reader client.Reader, obj client.Object, conditions *[]metav1.Condition) (bool, error) {
+	claim, ok := Of(obj)
+	if !ok {
+		return false, fmt.Errorf("%T does not create a gateway", obj)
+	}
+	others, err := Others(ctx, reader, claim)
+	if err != nil {
+		return false, err
+	}
+	if len(others) == 0 {
+		meta.RemoveStatusCondition(conditions, ConditionConflict)
+		return true, nil
+	}
+
+	names := make([]string, 0, len(others))
+	for _, other := range others {
+		names = append(names, other.String())
+	}
+	if before(claim, others[0]) {
+		meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:    ConditionConflict,
+			Status:  metav1.ConditionTrue,
+			Reason:  "Owner",
+			Message: fmt.Sprintf("Gateway %s is also claimed by %s, which are ignored", claim.GwName, strings.Join(names, ", ")),
+		})
+		return true, nil
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    ConditionConflict,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Duplicate",
+		Message: fmt.Sprintf("Gateway %s is owned by %s; this resource is ignored until its gwName changes", claim.GwName, others[0].String()),
+	})
+	return false, nil
+}
+
+// EnqueueClaimants maps a change of any gateway custom resource to the resources of the kind of
+// obj that claim the same gateway name, so a conflict is re-evaluated when the other resource
+// is renamed or deleted. On updates both the old and the new name are mapped.
+func EnqueueClaimants(reader client.Reader, obj client.Object) handler.EventHandler {
+	target, _ := Of(obj)
+	var kind owner
+	for _, owner := range owners {
+		if owner.kind == target.Kind {
+			kind = owner
+		}
+	}
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, changed client.Object) []reconcile.Request {
+		claim, ok := Of(changed)
+		if !ok || claim.GwName == "" || kind.list == nil {
+			return nil
+		}
+		list := kind.list()
+		if err := reader.List(ctx, list, client.MatchingFields{Index: claim.GwName}); err != nil {
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil
+		}
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			claimant := item.(client.Object)
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(claimant)})
+		}
+		return requests
+	})
+}
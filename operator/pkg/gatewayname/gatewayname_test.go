@@ -0,0 +1,97 @@
+package gatewayname
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func newClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := aviatrixv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...)
+	for _, owner := range owners {
+		builder = builder.WithIndex(owner.object(), Index, func(obj client.Object) []string {
+			claim, _ := Of(obj)
+			return []string{claim.GwName}
+		})
+	}
+	return builder.Build()
+}
+
+func TestCheck(t *testing.T) {
+	created := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	gateway := &aviatrixv1alpha1.AviatrixGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "vpn", Namespace: "network", CreationTimestamp: created},
+		Spec:       aviatrixv1alpha1.AviatrixGatewaySpec{GwName: "gw-east"},
+	}
+	spoke := &aviatrixv1alpha1.AviatrixSpokeGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "spoke", Namespace: "apps", CreationTimestamp: metav1.NewTime(created.Add(time.Hour))},
+		Spec:       aviatrixv1alpha1.AviatrixSpokeGatewaySpec{GwName: "gw-east"},
+	}
+	other := &aviatrixv1alpha1.AviatrixSpokeGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps", CreationTimestamp: created},
+		Spec:       aviatrixv1alpha1.AviatrixSpokeGatewaySpec{GwName: "gw-west"},
+	}
+	c := newClient(t, gateway, spoke, other)
+	ctx := context.Background()
+
+	owns, err := Check(ctx, c, spoke, &spoke.Status.Conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(spoke.Status.Conditions, ConditionConflict)
+	if owns || condition == nil || condition.Reason != "Duplicate" || !strings.Contains(condition.Message, "AviatrixGateway network/vpn") {
+		t.Errorf("Check() = %v, condition %+v, want the newer resource to point at the owner", owns, condition)
+	}
+
+	owns, err = Check(ctx, c, gateway, &gateway.Status.Conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	condition = meta.FindStatusCondition(gateway.Status.Conditions, ConditionConflict)
+	if !owns || condition == nil || condition.Reason != "Owner" || !strings.Contains(condition.Message, "AviatrixSpokeGateway apps/spoke") {
+		t.Errorf("Check() = %v, condition %+v, want the older resource to own the gateway", owns, condition)
+	}
+
+	other.Status.Conditions = []metav1.Condition{{Type: ConditionConflict, Status: metav1.ConditionTrue, Reason: "Duplicate"}}
+	owns, err = Check(ctx, c, other, &other.Status.Conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !owns || meta.FindStatusCondition(other.Status.Conditions, ConditionConflict) != nil {
+		t.Errorf("Check() = %v, conditions %+v, want a resolved conflict cleared", owns, other.Status.Conditions)
+	}
+}
+
+func TestOthersBreaksTiesByName(t *testing.T) {
+	created := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	a := &aviatrixv1alpha1.AviatrixTransitGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "network", CreationTimestamp: created},
+		Spec:       aviatrixv1alpha1.AviatrixTransitGatewaySpec{GwName: "transit"},
+	}
+	b := &aviatrixv1alpha1.AviatrixTransitGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "network", CreationTimestamp: created},
+		Spec:       aviatrixv1alpha1.AviatrixTransitGatewaySpec{GwName: "transit"},
+	}
+	c := newClient(t, a, b)
+
+	owns, err := Check(context.Background(), c, b, &b.Status.Conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owns {
+		t.Error("Check() gave a tie to the later name")
+	}
+}
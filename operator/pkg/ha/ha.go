@@ -0,0 +1,109 @@
+// Package ha tracks which replica of the operator leads. Only the leader reconciles and
+// mutates; every replica serves the read-only endpoints, such as the inventory, the health
+// summary and the metrics, from its own cache, so they stay available while the leader is
+// replaced.
+package ha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"aviatrix-operator/pkg/metrics"
+)
+
+// Roles of a replica
+const (
+	RoleLeader  = "leader"
+	RoleStandby = "standby"
+)
+
+// RoleHeader reports the role of the replica that answered a read-only request
+const RoleHeader = "X-Operator-Role"
+
+// Tracker records the role of this replica. It runs on every replica, warms the cache behind
+// the read-only endpoints and switches to the leader role once this replica is elected.
+type Tracker struct {
+	elected  <-chan struct{}
+	identity string
+	leader   atomic.Bool
+
+	informers cache.Informers
+	warm      []client.Object
+}
+
+// NewTracker creates a tracker for the election channel of the manager, which is closed right
+// away when leader election is disabled. Add it to the manager so it runs on every replica.
+func NewTracker(elected <-chan struct{}, identity string) *Tracker {
+	return &Tracker{elected: elected, identity: identity}
+}
+
+// WarmCache makes the tracker start the informers of objs when the replica starts, so a
+// standby answers its first read-only request from a synced cache instead of waiting for one
+func (t *Tracker) WarmCache(informers cache.Informers, objs ...client.Object) {
+	t.informers = informers
+	t.warm = append(t.warm, objs...)
+}
+
+// NeedLeaderElection runs the tracker on every replica, not only the leader
+func (t *Tracker) NeedLeaderElection() bool {
+	return false
+}
+
+// Start warms the cache, then waits until this replica is elected or ctx is cancelled
+func (t *Tracker) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("ha")
+	metrics.RecordLeader(t.identity, false)
+	for _, obj := range t.warm {
+		if _, err := t.informers.GetInformer(ctx, obj); err != nil {
+			return fmt.Errorf("failed to start informer for %T: %w", obj, err)
+		}
+	}
+	log.Info("serving read-only endpoints", "identity", t.identity, "role", t.Role())
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-t.elected:
+	}
+	t.leader.Store(true)
+	metrics.RecordLeader(t.identity, true)
+	log.Info("elected leader, reconciling", "identity", t.identity)
+	<-ctx.Done()
+	return nil
+}
+
+// Role returns RoleLeader once this replica is elected and RoleStandby before
+func (t *Tracker) Role() string {
+	if t.leader.Load() {
+		return RoleLeader
+	}
+	return RoleStandby
+}
+
+// ReadOnly serves next with the role of the replica in RoleHeader. Only GET and HEAD requests
+// are passed on, so a standby can never be asked to change anything.
+func (t *Tracker) ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set(RoleHeader, t.Role())
+		if req.Method != http.MethodGet && req.Method != http.MethodHead {
+			http.Error(w, "read-only endpoint", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Handler serves the identity and role of this replica as JSON
+func (t *Tracker) Handler() http.Handler {
+	return t.ReadOnly(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"identity": t.identity, "role": t.Role()})
+	}))
+}
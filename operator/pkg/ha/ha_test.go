@@ -0,0 +1,41 @@
+package ha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	elected := make(chan struct{})
+	tracker := NewTracker(elected, "operator-0")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.Start(ctx)
+
+	handler := tracker.ReadOnly(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	serve := func(method string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, "/inventory", nil))
+		return rec
+	}
+
+	// A standby answers reads and refuses writes
+	if rec := serve(http.MethodGet); rec.Code != http.StatusOK || rec.Header().Get(RoleHeader) != RoleStandby {
+		t.Errorf("standby GET = %d %q, want 200 from the standby", rec.Code, rec.Header().Get(RoleHeader))
+	}
+	if rec := serve(http.MethodPost); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("standby POST = %d, want 405", rec.Code)
+	}
+
+	close(elected)
+	deadline := time.Now().Add(time.Second)
+	for tracker.Role() != RoleLeader && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if rec := serve(http.MethodGet); rec.Header().Get(RoleHeader) != RoleLeader {
+		t.Errorf("role after election = %q, want %q", rec.Header().Get(RoleHeader), RoleLeader)
+	}
+}
@@ -0,0 +1,262 @@
+package helpergc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/metrics"
+)
+
+const (
+	// DefaultInterval is how often helper objects are collected
+	DefaultInterval = 5 * time.Minute
+	// DefaultCompletedTTL is how long a helper pod is kept after it completed, so its logs can
+	// still be read
+	DefaultCompletedTTL = 10 * time.Minute
+
+	// TTLAnnotation sets how long after its creation a helper object is removed, as a duration
+	// like "2h"
+	TTLAnnotation = "k8s-playgrounds.io/ttl"
+)
+
+// Labels of the helper objects the collector manages; app.kubernetes.io/instance names the
+// headless service of the object
+const (
+	nameLabel     = "app.kubernetes.io/name"
+	instanceLabel = "app.kubernetes.io/instance"
+	typeKey       = "discovery-type"
+
+	dnsTestName   = "dns-test"
+	discoveryName = "headless-service-discovery"
+)
+
+// Reasons an object is reclaimed
+const (
+	ReasonCompleted = "completed"
+	ReasonExpired   = "expired"
+	ReasonOrphaned  = "orphaned"
+	ReasonStale     = "stale"
+)
+
+// Config selects when helper objects are collected
+type Config struct {
+	// Interval is how often helper objects are collected
+	Interval time.Duration
+	// CompletedTTL is how long a completed helper pod is kept
+	CompletedTTL time.Duration
+}
+
+// Reclaimed is a helper object the collector removed
+type Reclaimed struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+}
+
+//+kubebuilder:rbac:groups=core,resources=pods;configmaps,verbs=list;delete
+
+// Collector periodically removes the helper objects the operator leaves behind: completed or
+// expired DNS test pods, and service discovery pods and ConfigMaps of a discovery type the
+// headless service no longer uses or of a headless service that is gone
+type Collector struct {
+	client client.Client
+	config Config
+}
+
+// NewCollector creates a collector. Add it to the manager so it runs on the leader.
+func NewCollector(c client.Client, config Config) *Collector {
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.CompletedTTL <= 0 {
+		config.CompletedTTL = DefaultCompletedTTL
+	}
+	return &Collector{client: c, config: config}
+}
+
+// NeedLeaderElection collects on the leader only
+func (c *Collector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start collects once and then every interval until ctx is cancelled
+func (c *Collector) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("helpergc")
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		reclaimed, err := c.Collect(ctx, time.Now())
+		if err != nil {
+			log.Error(err, "helper object collection failed")
+		}
+		for _, object := range reclaimed {
+			log.Info("reclaimed helper object", "kind", object.Kind, "namespace", object.Namespace, "name", object.Name, "reason", object.Reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect removes the helper objects that are due at now and returns them. Objects that could
+// not be removed are retried by the next collection.
+func (c *Collector) Collect(ctx context.Context, now time.Time) ([]Reclaimed, error) {
+	services := map[types.NamespacedName]*k8splaygroundsv1alpha1.HeadlessService{}
+	var reclaimed []Reclaimed
+	var errs []error
+
+	pods := &corev1.PodList{}
+	if err := c.client.List(ctx, pods, client.HasLabels{nameLabel, instanceLabel}); err != nil {
+		return nil, fmt.Errorf("failed to list helper pods: %w", err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		name := pod.Labels[nameLabel]
+		if name != dnsTestName && name != discoveryName {
+			continue
+		}
+		reason, err := c.podReason(ctx, services, pod, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		if err := c.client.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete pod %s/%s: %w", pod.Namespace, pod.Name, err))
+			continue
+		}
+		metrics.RecordHelperReclaimed("Pod", reason)
+		reclaimed = append(reclaimed, Reclaimed{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Reason: reason})
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.client.List(ctx, configMaps, client.MatchingLabels{nameLabel: discoveryName}); err != nil {
+		return reclaimed, fmt.Errorf("failed to list discovery ConfigMaps: %w", err)
+	}
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		reason := expired(configMap.Annotations, configMap.CreationTimestamp.Time, now)
+		if reason == "" {
+			var err error
+			reason, err = c.discoveryReason(ctx, services, configMap.Namespace, configMap.Labels[instanceLabel], configMap.Data[typeKey])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if reason == "" {
+			continue
+		}
+		if err := c.client.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("failed to delete ConfigMap %s/%s: %w", configMap.Namespace, configMap.Name, err))
+			continue
+		}
+		metrics.RecordHelperReclaimed("ConfigMap", reason)
+		reclaimed = append(reclaimed, Reclaimed{Kind: "ConfigMap", Namespace: configMap.Namespace, Name: configMap.Name, Reason: reason})
+	}
+
+	if len(errs) > 0 {
+		return reclaimed, fmt.Errorf("%d helper objects not collected, first: %w", len(errs), errs[0])
+	}
+	return reclaimed, nil
+}
+
+// podReason returns why a helper pod is due, or "" when it is kept
+func (c *Collector) podReason(ctx context.Context, services map[types.NamespacedName]*k8splaygroundsv1alpha1.HeadlessService, pod *corev1.Pod, now time.Time) (string, error) {
+	if reason := expired(pod.Annotations, pod.CreationTimestamp.Time, now); reason != "" {
+		return reason, nil
+	}
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		if now.Sub(finishedAt(pod)) >= c.config.CompletedTTL {
+			return ReasonCompleted, nil
+		}
+	}
+
+	if pod.Labels[nameLabel] == discoveryName {
+		return c.discoveryReason(ctx, services, pod.Namespace, pod.Labels[instanceLabel], pod.Labels[typeKey])
+	}
+	service, err := c.service(ctx, services, pod.Namespace, pod.Labels[instanceLabel])
+	if err != nil || service != nil {
+		return "", err
+	}
+	return ReasonOrphaned, nil
+}
+
+// discoveryReason returns why a discovery object of a discovery type is due, or "" when its
+// headless service still uses the type
+func (c *Collector) discoveryReason(ctx context.Context, services map[types.NamespacedName]*k8splaygroundsv1alpha1.HeadlessService, namespace, instance, discoveryType string) (string, error) {
+	service, err := c.service(ctx, services, namespace, instance)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case service == nil:
+		return ReasonOrphaned, nil
+	case service.Spec.ServiceDiscovery == nil || service.Spec.ConformanceMode:
+		return ReasonStale, nil
+	case discoveryType != "" && service.Spec.ServiceDiscovery.Type != discoveryType:
+		return ReasonStale, nil
+	}
+	return "", nil
+}
+
+// service returns the headless service a helper object belongs to, or nil when it is gone.
+// Lookups are cached for one collection.
+func (c *Collector) service(ctx context.Context, services map[types.NamespacedName]*k8splaygroundsv1alpha1.HeadlessService, namespace, name string) (*k8splaygroundsv1alpha1.HeadlessService, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: name}
+	if service, ok := services[key]; ok {
+		return service, nil
+	}
+	service := &k8splaygroundsv1alpha1.HeadlessService{}
+	if err := c.client.Get(ctx, key, service); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get HeadlessService %s: %w", key, err)
+		}
+		service = nil
+	}
+	services[key] = service
+	return service, nil
+}
+
+// expired returns ReasonExpired once the TTL annotation of an object has passed. Invalid TTLs
+// are ignored.
+func expired(annotations map[string]string, created, now time.Time) string {
+	value, ok := annotations[TTLAnnotation]
+	if !ok {
+		return ""
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return ""
+	}
+	if now.Sub(created) >= ttl {
+		return ReasonExpired
+	}
+	return ""
+}
+
+// finishedAt returns when the last container of a completed pod terminated, or its creation
+// when no container reports it
+func finishedAt(pod *corev1.Pod) time.Time {
+	finished := pod.CreationTimestamp.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.After(finished) {
+			finished = terminated.FinishedAt.Time
+		}
+	}
+	return finished
+}
@@ -0,0 +1,137 @@
+package helpergc
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+var now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+func helperMeta(name, helper, instance string, age time.Duration) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:              name,
+		Namespace:         "lab",
+		Labels:            map[string]string{nameLabel: helper, instanceLabel: instance},
+		CreationTimestamp: metav1.NewTime(now.Add(-age)),
+	}
+}
+
+// newClient serves the headless services from a map, since they are not in the client-go scheme
+func newClient(services map[string]*k8splaygroundsv1alpha1.HeadlessService, objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(clientgoscheme.Scheme).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+				service, ok := obj.(*k8splaygroundsv1alpha1.HeadlessService)
+				if !ok {
+					return c.Get(ctx, key, obj, opts...)
+				}
+				found, ok := services[key.Name]
+				if !ok {
+					return apierrors.NewNotFound(schema.GroupResource{Resource: "headlessservices"}, key.Name)
+				}
+				*service = *found
+				return nil
+			},
+		}).
+		Build()
+}
+
+func TestCollect(t *testing.T) {
+	services := map[string]*k8splaygroundsv1alpha1.HeadlessService{
+		"web": {Spec: k8splaygroundsv1alpha1.HeadlessServiceSpec{ServiceDiscovery: &k8splaygroundsv1alpha1.ServiceDiscoverySpec{Type: "api"}}},
+		"db":  {},
+	}
+
+	finished := &corev1.Pod{
+		ObjectMeta: helperMeta("web-dns-test", dnsTestName, "web", 2*time.Hour),
+		Status: corev1.PodStatus{Phase: corev1.PodSucceeded, ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(now.Add(-time.Hour))}},
+		}}},
+	}
+	justFinished := &corev1.Pod{
+		ObjectMeta: helperMeta("db-dns-test", dnsTestName, "db", 2*time.Hour),
+		Status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: []corev1.ContainerStatus{{
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(now.Add(-time.Minute))}},
+		}}},
+	}
+	running := &corev1.Pod{ObjectMeta: helperMeta("gone-dns-test", dnsTestName, "gone", time.Minute), Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+	expiring := &corev1.Pod{ObjectMeta: helperMeta("web-probe", dnsTestName, "web", time.Hour), Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+	expiring.Annotations = map[string]string{TTLAnnotation: "30m"}
+
+	apiPod := &corev1.Pod{ObjectMeta: helperMeta("web-discovery-api", discoveryName, "web", time.Hour)}
+	apiPod.Labels[typeKey] = "api"
+	dnsPod := &corev1.Pod{ObjectMeta: helperMeta("web-discovery-dns", discoveryName, "web", time.Hour)}
+	dnsPod.Labels[typeKey] = "dns"
+	apiConfig := &corev1.ConfigMap{ObjectMeta: helperMeta("web-discovery-config-api", discoveryName, "web", time.Hour), Data: map[string]string{typeKey: "api"}}
+	dnsConfig := &corev1.ConfigMap{ObjectMeta: helperMeta("web-discovery-config-dns", discoveryName, "web", time.Hour), Data: map[string]string{typeKey: "dns"}}
+	dbConfig := &corev1.ConfigMap{ObjectMeta: helperMeta("db-discovery-config-dns", discoveryName, "db", time.Hour), Data: map[string]string{typeKey: "dns"}}
+	unrelated := &corev1.Pod{ObjectMeta: helperMeta("web-0", "web", "web", 48*time.Hour), Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}
+
+	c := newClient(services, finished, justFinished, running, expiring, apiPod, dnsPod, apiConfig, dnsConfig, dbConfig, unrelated)
+	reclaimed, err := NewCollector(c, Config{}).Collect(context.Background(), now)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	got := map[string]string{}
+	for _, object := range reclaimed {
+		got[object.Kind+"/"+object.Name] = object.Reason
+	}
+	want := map[string]string{
+		"Pod/web-dns-test":                   ReasonCompleted,
+		"Pod/gone-dns-test":                  ReasonOrphaned,
+		"Pod/web-probe":                      ReasonExpired,
+		"Pod/web-discovery-dns":              ReasonStale,
+		"ConfigMap/web-discovery-config-dns": ReasonStale,
+		"ConfigMap/db-discovery-config-dns":  ReasonStale,
+	}
+	if len(got) != len(want) {
+		t.Errorf("reclaimed = %v, want %v", got, want)
+	}
+	for name, reason := range want {
+		if got[name] != reason {
+			t.Errorf("%s reclaimed as %q, want %q", name, got[name], reason)
+		}
+	}
+
+	pods := &corev1.PodList{}
+	if err := c.List(context.Background(), pods); err != nil {
+		t.Fatal(err)
+	}
+	var left []string
+	for _, pod := range pods.Items {
+		left = append(left, pod.Name)
+	}
+	sort.Strings(left)
+	if len(left) != 3 || left[0] != "db-dns-test" || left[1] != "web-0" || left[2] != "web-discovery-api" {
+		t.Errorf("pods left = %v, want the recent, unrelated and current discovery pods", left)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	created := now.Add(-time.Hour)
+	if reason := expired(map[string]string{TTLAnnotation: "2h"}, created, now); reason != "" {
+		t.Errorf("expired() = %q before the TTL", reason)
+	}
+	if reason := expired(map[string]string{TTLAnnotation: "1h"}, created, now); reason != ReasonExpired {
+		t.Errorf("expired() = %q, want %q once the TTL passed", reason, ReasonExpired)
+	}
+	if reason := expired(map[string]string{TTLAnnotation: "soon"}, created, now); reason != "" {
+		t.Errorf("expired() = %q for an invalid TTL", reason)
+	}
+}
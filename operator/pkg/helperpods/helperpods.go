@@ -0,0 +1,175 @@
+package helperpods
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Label marks the pods the operator runs on behalf of headless services, so they can be
+// counted against the namespace cap
+const Label = "k8s-playgrounds.io/helper-pod"
+
+// DefaultMaxPerNamespace is the cap on helper pods in a namespace when none is configured
+const DefaultMaxPerNamespace = 50
+
+// DefaultResources returns the requests and limits of helper containers when none are
+// configured: enough for the shell loops and iptables calls they run, and bounded so that
+// they cannot starve workloads
+func DefaultResources() corev1.ResourceRequirements {
+	return corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("10m"),
+			corev1.ResourceMemory: resource.MustParse("32Mi"),
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+	}
+}
+
+// Config is the operator-wide configuration of helper pods
+type Config struct {
+	// PriorityClassName is set on every helper pod; empty keeps the cluster default priority
+	PriorityClassName string
+	// Resources are set on every helper container; empty uses DefaultResources
+	Resources corev1.ResourceRequirements
+	// MaxPerNamespace caps the helper pods in a namespace; 0 uses DefaultMaxPerNamespace and a
+	// negative value disables the cap
+	MaxPerNamespace int
+}
+
+// LimitError is returned when a namespace already runs as many helper pods as allowed
+type LimitError struct {
+	Namespace string
+	Count     int
+	Limit     int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("namespace %s already runs %d helper pods, the limit is %d", e.Namespace, e.Count, e.Limit)
+}
+
+// IsLimitReached reports whether err means the helper pod cap was reached
+func IsLimitReached(err error) bool {
+	var limit *LimitError
+	return errors.As(err, &limit)
+}
+
+// For returns the configuration for the helper pods of a headless service, with the fields
+// the service sets taking precedence
+func (c Config) For(headlessService *k8splaygroundsv1alpha1.HeadlessService) (Config, error) {
+	if len(c.Resources.Requests) == 0 && len(c.Resources.Limits) == 0 {
+		c.Resources = DefaultResources()
+	}
+
+	spec := headlessService.Spec.HelperPods
+	if spec == nil {
+		return c, nil
+	}
+	if spec.PriorityClassName != "" {
+		c.PriorityClassName = spec.PriorityClassName
+	}
+	if spec.Resources != nil {
+		requests, err := resourceList(spec.Resources.Requests)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid helper pod requests: %w", err)
+		}
+		limits, err := resourceList(spec.Resources.Limits)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid helper pod limits: %w", err)
+		}
+		c.Resources = corev1.ResourceRequirements{Requests: requests, Limits: limits}
+	}
+	return c, nil
+}
+
+// ForCluster returns the configuration for the helper pods of a cluster, which cannot
+// override the operator-wide settings
+func (c Config) ForCluster() Config {
+	if len(c.Resources.Requests) == 0 && len(c.Resources.Limits) == 0 {
+		c.Resources = DefaultResources()
+	}
+	return c
+}
+
+// Apply labels a helper pod and sets its priority class and container resources
+func (c Config) Apply(meta *metav1.ObjectMeta, spec *corev1.PodSpec) {
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+	meta.Labels[Label] = "true"
+	spec.PriorityClassName = c.PriorityClassName
+	for i := range spec.Containers {
+		spec.Containers[i].Resources = *c.Resources.DeepCopy()
+	}
+}
+
+// Reserve returns a *LimitError when the namespace already runs MaxPerNamespace helper pods.
+// Call it before creating a new helper pod or DaemonSet; existing ones are never removed.
+func (c Config) Reserve(ctx context.Context, reader client.Reader, namespace string) error {
+	limit := c.MaxPerNamespace
+	if limit == 0 {
+		limit = DefaultMaxPerNamespace
+	}
+	if limit < 0 {
+		return nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := reader.List(ctx, pods, client.InNamespace(namespace), client.MatchingLabels{Label: "true"}); err != nil {
+		return fmt.Errorf("failed to count helper pods: %w", err)
+	}
+	count := 0
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			count++
+		}
+	}
+	if count >= limit {
+		return &LimitError{Namespace: namespace, Count: count, Limit: limit}
+	}
+	return nil
+}
+
+// ParseResourceList parses resources of the form "cpu=10m,memory=32Mi"
+func ParseResourceList(s string) (corev1.ResourceList, error) {
+	list := map[string]string{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid resource %q: want <name>=<quantity>", entry)
+		}
+		list[name] = value
+	}
+	return resourceList(list)
+}
+
+// resourceList parses string quantities into a Kubernetes resource list
+func resourceList(list map[string]string) (corev1.ResourceList, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	result := make(corev1.ResourceList, len(list))
+	for name, value := range list {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		result[corev1.ResourceName(name)] = quantity
+	}
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package helperpods
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestFor(t *testing.T) {
+	operator := Config{PriorityClassName: "helper-low", MaxPerNamespace: 10}
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+
+	config, err := operator.For(headlessService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PriorityClassName != "helper-low" || config.Resources.Limits.Cpu().String() != "100m" {
+		t.Errorf("config = %+v, want the operator priority class and default resources", config)
+	}
+
+	headlessService.Spec.HelperPods = &k8splaygroundsv1alpha1.HelperPodsSpec{
+		PriorityClassName: "helper-high",
+		Resources:         &k8splaygroundsv1alpha1.ResourceRequirements{Requests: map[string]string{"memory": "64Mi"}},
+	}
+	config, err = operator.For(headlessService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.PriorityClassName != "helper-high" || config.Resources.Limits != nil || config.Resources.Requests.Memory().String() != "64Mi" {
+		t.Errorf("config = %+v, want the service overrides", config)
+	}
+	if config.MaxPerNamespace != 10 {
+		t.Errorf("max per namespace = %d, want the operator-wide cap", config.MaxPerNamespace)
+	}
+
+	headlessService.Spec.HelperPods.Resources.Limits = map[string]string{"cpu": "lots"}
+	if _, err := operator.For(headlessService); err == nil {
+		t.Error("For() accepted an invalid quantity")
+	}
+}
+
+func TestApply(t *testing.T) {
+	config := Config{PriorityClassName: "helper-low", Resources: DefaultResources()}
+	meta := metav1.ObjectMeta{Labels: map[string]string{"app": "discovery"}}
+	spec := corev1.PodSpec{Containers: []corev1.Container{{Name: "a"}, {Name: "b"}}}
+
+	config.Apply(&meta, &spec)
+	if meta.Labels[Label] != "true" || meta.Labels["app"] != "discovery" {
+		t.Errorf("labels = %v, want the helper label added", meta.Labels)
+	}
+	if spec.PriorityClassName != "helper-low" {
+		t.Errorf("priority class = %q", spec.PriorityClassName)
+	}
+	for _, container := range spec.Containers {
+		if !container.Resources.Requests.Cpu().Equal(resource.MustParse("10m")) {
+			t.Errorf("container %s resources = %+v", container.Name, container.Resources)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	var objects []client.Object
+	for i, phase := range []corev1.PodPhase{corev1.PodRunning, corev1.PodPending, corev1.PodSucceeded} {
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: fmt.Sprintf("helper-%d", i), Labels: map[string]string{Label: "true"}},
+			Status:     corev1.PodStatus{Phase: phase},
+		})
+	}
+	objects = append(objects, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"}})
+	reader := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objects...).Build()
+	ctx := context.Background()
+
+	// Finished and non-helper pods do not count
+	if err := (Config{MaxPerNamespace: 3}).Reserve(ctx, reader, "shop"); err != nil {
+		t.Errorf("Reserve() = %v with 2 running helper pods and a cap of 3", err)
+	}
+	err := (Config{MaxPerNamespace: 2}).Reserve(ctx, reader, "shop")
+	if !IsLimitReached(err) {
+		t.Errorf("Reserve() = %v, want the limit reached", err)
+	}
+	if err := (Config{MaxPerNamespace: -1}).Reserve(ctx, reader, "shop"); err != nil {
+		t.Errorf("Reserve() = %v with the cap disabled", err)
+	}
+	if err := (Config{MaxPerNamespace: 2}).Reserve(ctx, reader, "blog"); err != nil {
+		t.Errorf("Reserve() = %v in a namespace without helper pods", err)
+	}
+}
+
+func TestParseResourceList(t *testing.T) {
+	list, err := ParseResourceList("cpu=20m, memory=64Mi")
+	if err != nil || list.Cpu().String() != "20m" || list.Memory().String() != "64Mi" {
+		t.Errorf("ParseResourceList() = %v, %v", list, err)
+	}
+	if list, err := ParseResourceList(""); err != nil || list != nil {
+		t.Errorf("ParseResourceList(\"\") = %v, %v, want no resources", list, err)
+	}
+	if _, err := ParseResourceList("cpu"); err == nil {
+		t.Error("ParseResourceList() accepted a resource without a quantity")
+	}
+	if _, err := ParseResourceList("cpu=lots"); err == nil {
+		t.Error("ParseResourceList() accepted an invalid quantity")
+	}
+}
@@ -0,0 +1,294 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Hook stages
+const (
+	StagePreApply  = "preApply"
+	StagePostApply = "postApply"
+)
+
+// Hook failure policies
+const (
+	FailurePolicyAbort  = "abort"
+	FailurePolicyRetry  = "retry"
+	FailurePolicyIgnore = "ignore"
+)
+
+// Hook states reported in status
+const (
+	StateRunning   = "Running"
+	StateSucceeded = "Succeeded"
+	StateFailed    = "Failed"
+	StateIgnored   = "Ignored"
+)
+
+// DefaultRetries is how often a hook with the retry policy is re-run when Retries is unset
+const DefaultRetries = 3
+
+// PollInterval is how often running hooks are checked; Job status changes do not trigger
+// cluster reconciles
+const PollInterval = 10 * time.Second
+
+// Labels identifying hook Jobs
+const (
+	clusterLabel  = "k8s-playgrounds.io/cluster"
+	stageLabel    = "k8s-playgrounds.io/hook-stage"
+	hookLabel     = "k8s-playgrounds.io/hook"
+	revisionLabel = "k8s-playgrounds.io/revision"
+)
+
+// AbortedError is returned when a hook with the abort policy fails
+type AbortedError struct {
+	Stage   string
+	Hook    string
+	Message string
+}
+
+func (e *AbortedError) Error() string {
+	return fmt.Sprintf("%s hook %s failed: %s", e.Stage, e.Hook, e.Message)
+}
+
+// IsAborted reports whether err means a failed hook stopped the rollout
+func IsAborted(err error) bool {
+	var aborted *AbortedError
+	return errors.As(err, &aborted)
+}
+
+// Runner runs the hook Jobs of a cluster revision one at a time
+type Runner struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewRunner creates a new hook runner
+func NewRunner(client client.Client, scheme *runtime.Scheme) *Runner {
+	return &Runner{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Run advances the hooks of a stage for the current revision of the cluster, starting each hook
+// only after the previous one finished. It returns true once every hook has succeeded or been
+// ignored. Progress is recorded in cluster.Status.Hooks; the caller persists the status.
+func (r *Runner) Run(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, stage string, hooks []k8splaygroundsv1alpha1.HookSpec) (bool, error) {
+	revision := cluster.Generation
+	pruneStatus(cluster, revision)
+	if err := r.pruneJobs(ctx, cluster, stage, revision); err != nil {
+		return false, err
+	}
+
+	for _, hook := range hooks {
+		status := hookStatus(cluster, stage, hook.Name, revision)
+		switch status.State {
+		case StateSucceeded, StateIgnored:
+			continue
+		case StateFailed:
+			return false, &AbortedError{Stage: stage, Hook: hook.Name, Message: status.Message}
+		}
+
+		done, err := r.runHook(ctx, cluster, stage, hook, status)
+		if err != nil || !done {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// runHook starts the hook Job if needed and applies the failure policy once it finishes
+func (r *Runner) runHook(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, stage string, hook k8splaygroundsv1alpha1.HookSpec, status *k8splaygroundsv1alpha1.HookStatus) (bool, error) {
+	log := logr.FromContextOrDiscard(ctx).WithValues("stage", stage, "hook", hook.Name)
+
+	if status.JobName == "" {
+		return false, r.startJob(ctx, cluster, stage, hook, status)
+	}
+
+	job := &batchv1.Job{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: status.JobName}, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			// The Job was removed out of band; run the attempt again
+			status.Attempts--
+			return false, r.startJob(ctx, cluster, stage, hook, status)
+		}
+		return false, fmt.Errorf("failed to get hook job %s: %w", status.JobName, err)
+	}
+
+	finished, failed, message := jobResult(job)
+	if !finished {
+		return false, nil
+	}
+
+	now := metav1.Now()
+	if !failed {
+		log.Info("hook succeeded", "job", job.Name)
+		status.State = StateSucceeded
+		status.Message = ""
+		status.CompletedAt = &now
+		return true, nil
+	}
+
+	switch hook.FailurePolicy {
+	case FailurePolicyIgnore:
+		log.Info("hook failed, ignoring", "job", job.Name, "message", message)
+		status.State = StateIgnored
+		status.Message = message
+		status.CompletedAt = &now
+		return true, nil
+	case FailurePolicyRetry:
+		retries := hook.Retries
+		if retries == 0 {
+			retries = DefaultRetries
+		}
+		if status.Attempts <= retries {
+			log.Info("hook failed, retrying", "job", job.Name, "attempt", status.Attempts, "message", message)
+			status.Message = message
+			return false, r.startJob(ctx, cluster, stage, hook, status)
+		}
+		message = fmt.Sprintf("%s (gave up after %d attempts)", message, status.Attempts)
+	}
+
+	log.Info("hook failed, aborting", "job", job.Name, "message", message)
+	status.State = StateFailed
+	status.Message = message
+	status.CompletedAt = &now
+	return false, &AbortedError{Stage: stage, Hook: hook.Name, Message: message}
+}
+
+// startJob creates the Job for the next attempt of a hook
+func (r *Runner) startJob(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, stage string, hook k8splaygroundsv1alpha1.HookSpec, status *k8splaygroundsv1alpha1.HookStatus) error {
+	template, err := buildPodTemplate(hook.Template)
+	if err != nil {
+		return fmt.Errorf("invalid %s hook %s: %w", stage, hook.Name, err)
+	}
+
+	attempt := status.Attempts + 1
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      JobName(cluster.Name, stage, hook.Name, cluster.Generation, attempt),
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterLabel:  cluster.Name,
+				stageLabel:    stage,
+				hookLabel:     hook.Name,
+				revisionLabel: fmt.Sprintf("%d", cluster.Generation),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          &backoffLimit,
+			ActiveDeadlineSeconds: hook.ActiveDeadlineSeconds,
+			Template:              template,
+		},
+	}
+	if err := controllerutil.SetControllerReference(cluster, job, r.scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference on hook job: %w", err)
+	}
+	if err := r.client.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create hook job %s: %w", job.Name, err)
+	}
+
+	logr.FromContextOrDiscard(ctx).Info("started hook", "stage", stage, "hook", hook.Name, "job", job.Name, "attempt", attempt)
+	now := metav1.Now()
+	status.Attempts = attempt
+	status.JobName = job.Name
+	status.State = StateRunning
+	status.StartedAt = &now
+	status.CompletedAt = nil
+	return nil
+}
+
+// pruneJobs deletes hook Jobs of a stage left over from earlier revisions
+func (r *Runner) pruneJobs(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, stage string, revision int64) error {
+	jobs := &batchv1.JobList{}
+	if err := r.client.List(ctx, jobs, client.InNamespace(cluster.Namespace), client.MatchingLabels{
+		clusterLabel: cluster.Name,
+		stageLabel:   stage,
+	}); err != nil {
+		return fmt.Errorf("failed to list hook jobs: %w", err)
+	}
+
+	current := fmt.Sprintf("%d", revision)
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Labels[revisionLabel] == current {
+			continue
+		}
+		if err := r.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete stale hook job %s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// JobName returns the name of the Job for one attempt of a hook. Names that would exceed the
+// label value limit are shortened and suffixed with a hash of the full name.
+func JobName(clusterName, stage, hookName string, revision int64, attempt int32) string {
+	return naming.Qualified(naming.HookJob, clusterName, fmt.Sprintf("%s-%s-%d-%d", strings.ToLower(stage), hookName, revision, attempt))
+}
+
+// jobResult reports whether a Job finished, whether it failed and why
+func jobResult(job *batchv1.Job) (bool, bool, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, false, ""
+		case batchv1.JobFailed:
+			message := condition.Message
+			if message == "" {
+				message = condition.Reason
+			}
+			return true, true, message
+		}
+	}
+	return false, false, ""
+}
+
+// hookStatus returns the status entry of a hook for the given revision, adding it if missing
+func hookStatus(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, stage, name string, revision int64) *k8splaygroundsv1alpha1.HookStatus {
+	for i := range cluster.Status.Hooks {
+		status := &cluster.Status.Hooks[i]
+		if status.Stage == stage && status.Name == name && status.Revision == revision {
+			return status
+		}
+	}
+	cluster.Status.Hooks = append(cluster.Status.Hooks, k8splaygroundsv1alpha1.HookStatus{
+		Name:     name,
+		Stage:    stage,
+		Revision: revision,
+	})
+	return &cluster.Status.Hooks[len(cluster.Status.Hooks)-1]
+}
+
+// pruneStatus drops hook results of earlier revisions
+func pruneStatus(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, revision int64) {
+	kept := cluster.Status.Hooks[:0]
+	for _, status := range cluster.Status.Hooks {
+		if status.Revision == revision {
+			kept = append(kept, status)
+		}
+	}
+	cluster.Status.Hooks = kept
+}
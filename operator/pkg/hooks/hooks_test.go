@@ -0,0 +1,73 @@
+package hooks
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+func TestJobName(t *testing.T) {
+	if got, want := JobName("demo", StagePreApply, "migrate", 4, 2), "demo-preapply-migrate-4-2"; got != want {
+		t.Errorf("JobName() = %s, want %s", got, want)
+	}
+
+	long := JobName(strings.Repeat("cluster", 10), StagePostApply, "seed-data", 12, 1)
+	if len(long) > naming.MaxLength {
+		t.Errorf("JobName() length = %d, want at most %d", len(long), naming.MaxLength)
+	}
+	if other := JobName(strings.Repeat("cluster", 10), StagePostApply, "seed-data", 12, 2); other == long {
+		t.Errorf("truncated names for different attempts collide: %s", long)
+	}
+}
+
+func TestBuildPodTemplate(t *testing.T) {
+	template, err := buildPodTemplate(k8splaygroundsv1alpha1.PodTemplateSpec{
+		Spec: k8splaygroundsv1alpha1.PodSpec{
+			RestartPolicy:     "Always",
+			PriorityClassName: "batch",
+			RuntimeClassName:  "gvisor",
+			Containers: []k8splaygroundsv1alpha1.ContainerSpec{{
+				Name:    "migrate",
+				Image:   "migrate:1.0",
+				Command: []string{"migrate", "up"},
+				Env: []k8splaygroundsv1alpha1.EnvVar{{
+					Name:      "DB_PASSWORD",
+					ValueFrom: &k8splaygroundsv1alpha1.EnvVarSource{SecretKeyRef: &k8splaygroundsv1alpha1.SecretKeySelector{Name: "db", Key: "password"}},
+				}},
+				Resources: &k8splaygroundsv1alpha1.ResourceRequirements{Limits: map[string]string{"memory": "128Mi"}},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("RestartPolicy = %s, want Never", template.Spec.RestartPolicy)
+	}
+	if template.Spec.PriorityClassName != "batch" || template.Spec.RuntimeClassName == nil || *template.Spec.RuntimeClassName != "gvisor" {
+		t.Errorf("classes = %s, %v, want batch and gvisor", template.Spec.PriorityClassName, template.Spec.RuntimeClassName)
+	}
+	container := template.Spec.Containers[0]
+	if ref := container.Env[0].ValueFrom.SecretKeyRef; ref == nil || ref.Name != "db" || ref.Key != "password" {
+		t.Errorf("SecretKeyRef = %+v, want db/password", ref)
+	}
+	if memory := container.Resources.Limits[corev1.ResourceMemory]; memory.String() != "128Mi" {
+		t.Errorf("memory limit = %s, want 128Mi", memory.String())
+	}
+
+	if _, err := buildPodTemplate(k8splaygroundsv1alpha1.PodTemplateSpec{
+		Spec: k8splaygroundsv1alpha1.PodSpec{
+			Containers: []k8splaygroundsv1alpha1.ContainerSpec{{
+				Name:      "bad",
+				Resources: &k8splaygroundsv1alpha1.ResourceRequirements{Requests: map[string]string{"cpu": "lots"}},
+			}},
+		},
+	}); err == nil {
+		t.Error("expected error for invalid quantity")
+	}
+}
@@ -0,0 +1,215 @@
+package hooks
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// buildPodTemplate converts a PodTemplateSpec into the pod template of a hook Job. Hook pods
+// never restart in place; retries are driven by the hook failure policy instead.
+func buildPodTemplate(template k8splaygroundsv1alpha1.PodTemplateSpec) (corev1.PodTemplateSpec, error) {
+	spec := corev1.PodSpec{
+		RestartPolicy:     corev1.RestartPolicyNever,
+		NodeSelector:      template.Spec.NodeSelector,
+		PriorityClassName: template.Spec.PriorityClassName,
+		SchedulerName:     template.Spec.SchedulerName,
+	}
+	if template.Spec.RuntimeClassName != "" {
+		spec.RuntimeClassName = &template.Spec.RuntimeClassName
+	}
+
+	for _, c := range template.Spec.Containers {
+		container, err := buildContainer(c)
+		if err != nil {
+			return corev1.PodTemplateSpec{}, err
+		}
+		spec.Containers = append(spec.Containers, container)
+	}
+
+	for _, v := range template.Spec.Volumes {
+		spec.Volumes = append(spec.Volumes, corev1.Volume{
+			Name:         v.Name,
+			VolumeSource: buildVolumeSource(v.VolumeSource),
+		})
+	}
+
+	for _, t := range template.Spec.Tolerations {
+		spec.Tolerations = append(spec.Tolerations, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+
+	if sc := template.Spec.SecurityContext; sc != nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{
+			RunAsUser:    sc.RunAsUser,
+			RunAsGroup:   sc.RunAsGroup,
+			RunAsNonRoot: sc.RunAsNonRoot,
+			FSGroup:      sc.FSGroup,
+		}
+		for i := range spec.Containers {
+			spec.Containers[i].SecurityContext = &corev1.SecurityContext{
+				ReadOnlyRootFilesystem:   sc.ReadOnlyRootFilesystem,
+				AllowPrivilegeEscalation: sc.AllowPrivilegeEscalation,
+				Privileged:               sc.Privileged,
+			}
+		}
+	}
+
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      template.Metadata.Labels,
+			Annotations: template.Metadata.Annotations,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// buildContainer converts a ContainerSpec into a Kubernetes container
+func buildContainer(c k8splaygroundsv1alpha1.ContainerSpec) (corev1.Container, error) {
+	container := corev1.Container{
+		Name:            c.Name,
+		Image:           c.Image,
+		ImagePullPolicy: corev1.PullPolicy(c.ImagePullPolicy),
+		Command:         c.Command,
+		Args:            c.Args,
+	}
+
+	for _, p := range c.Ports {
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: p.ContainerPort,
+			Protocol:      corev1.Protocol(p.Protocol),
+			HostPort:      p.HostPort,
+		})
+	}
+
+	for _, e := range c.Env {
+		env := corev1.EnvVar{Name: e.Name, Value: e.Value}
+		if e.ValueFrom != nil {
+			env.ValueFrom = &corev1.EnvVarSource{}
+			if ref := e.ValueFrom.FieldRef; ref != nil {
+				env.ValueFrom.FieldRef = &corev1.ObjectFieldSelector{APIVersion: ref.APIVersion, FieldPath: ref.FieldPath}
+			}
+			if ref := e.ValueFrom.ResourceFieldRef; ref != nil {
+				env.ValueFrom.ResourceFieldRef = &corev1.ResourceFieldSelector{ContainerName: ref.ContainerName, Resource: ref.Resource}
+				if ref.Divisor != "" {
+					divisor, err := resource.ParseQuantity(ref.Divisor)
+					if err != nil {
+						return corev1.Container{}, fmt.Errorf("container %s: invalid divisor for %s: %w", c.Name, e.Name, err)
+					}
+					env.ValueFrom.ResourceFieldRef.Divisor = divisor
+				}
+			}
+			if ref := e.ValueFrom.ConfigMapKeyRef; ref != nil {
+				env.ValueFrom.ConfigMapKeyRef = &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  ref.Key,
+				}
+			}
+			if ref := e.ValueFrom.SecretKeyRef; ref != nil {
+				env.ValueFrom.SecretKeyRef = &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Key:                  ref.Key,
+				}
+			}
+		}
+		container.Env = append(container.Env, env)
+	}
+
+	if c.Resources != nil {
+		limits, err := buildResourceList(c.Resources.Limits)
+		if err != nil {
+			return corev1.Container{}, fmt.Errorf("container %s: invalid limits: %w", c.Name, err)
+		}
+		requests, err := buildResourceList(c.Resources.Requests)
+		if err != nil {
+			return corev1.Container{}, fmt.Errorf("container %s: invalid requests: %w", c.Name, err)
+		}
+		container.Resources = corev1.ResourceRequirements{Limits: limits, Requests: requests}
+	}
+
+	for _, m := range c.VolumeMounts {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      m.Name,
+			MountPath: m.MountPath,
+			ReadOnly:  m.ReadOnly,
+			SubPath:   m.SubPath,
+		})
+	}
+
+	return container, nil
+}
+
+// buildResourceList parses string quantities into a Kubernetes resource list
+func buildResourceList(list map[string]string) (corev1.ResourceList, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	result := make(corev1.ResourceList, len(list))
+	for name, value := range list {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		result[corev1.ResourceName(name)] = quantity
+	}
+	return result, nil
+}
+
+// buildVolumeSource converts the volume sources supported by VolumeSourceSpec
+func buildVolumeSource(source k8splaygroundsv1alpha1.VolumeSourceSpec) corev1.VolumeSource {
+	var result corev1.VolumeSource
+	switch {
+	case source.EmptyDir != nil:
+		result.EmptyDir = &corev1.EmptyDirVolumeSource{Medium: corev1.StorageMedium(source.EmptyDir.Medium)}
+		if source.EmptyDir.SizeLimit != nil {
+			if limit, err := resource.ParseQuantity(source.EmptyDir.SizeLimit.Value); err == nil {
+				result.EmptyDir.SizeLimit = &limit
+			}
+		}
+	case source.HostPath != nil:
+		result.HostPath = &corev1.HostPathVolumeSource{Path: source.HostPath.Path}
+		if source.HostPath.Type != "" {
+			hostPathType := corev1.HostPathType(source.HostPath.Type)
+			result.HostPath.Type = &hostPathType
+		}
+	case source.PersistentVolumeClaim != nil:
+		result.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{
+			ClaimName: source.PersistentVolumeClaim.ClaimName,
+			ReadOnly:  source.PersistentVolumeClaim.ReadOnly,
+		}
+	case source.ConfigMap != nil:
+		result.ConfigMap = &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: source.ConfigMap.Name},
+			Items:                buildKeyToPaths(source.ConfigMap.Items),
+			DefaultMode:          source.ConfigMap.DefaultMode,
+			Optional:             source.ConfigMap.Optional,
+		}
+	case source.Secret != nil:
+		result.Secret = &corev1.SecretVolumeSource{
+			SecretName:  source.Secret.SecretName,
+			Items:       buildKeyToPaths(source.Secret.Items),
+			DefaultMode: source.Secret.DefaultMode,
+			Optional:    source.Secret.Optional,
+		}
+	}
+	return result
+}
+
+// buildKeyToPaths converts key-to-path projections
+func buildKeyToPaths(items []k8splaygroundsv1alpha1.KeyToPath) []corev1.KeyToPath {
+	var result []corev1.KeyToPath
+	for _, item := range items {
+		result = append(result, corev1.KeyToPath{Key: item.Key, Path: item.Path, Mode: item.Mode})
+	}
+	return result
+}
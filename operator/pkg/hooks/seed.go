@@ -0,0 +1,343 @@
+package hooks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/clusterdomain"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Seed job rerun policies
+const (
+	RerunOnDataLoss = "OnDataLoss"
+	RerunNever      = "Never"
+)
+
+// StateWaiting reports a seed job waiting for its StatefulSet
+const StateWaiting = "Waiting"
+
+// seedLabel identifies the Jobs of a seed job
+const seedLabel = "k8s-playgrounds.io/seed-job"
+
+// LookupHost resolves the DNS names of the StatefulSet pods before seeding
+var LookupHost = net.DefaultResolver.LookupHost
+
+// ValidateSeedJobs checks that every seed job names a StatefulSet of the cluster that is
+// governed by a headless service
+func ValidateSeedJobs(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) field.ErrorList {
+	var errs field.ErrorList
+	statefulSets := map[string]*k8splaygroundsv1alpha1.StatefulSetSpec{}
+	for i := range cluster.Spec.StatefulSets {
+		statefulSets[cluster.Spec.StatefulSets[i].Name] = &cluster.Spec.StatefulSets[i]
+	}
+	names := map[string]bool{}
+	for i, seed := range cluster.Spec.SeedJobs {
+		path := field.NewPath("spec", "seedJobs").Index(i)
+		if seed.Name == "" {
+			errs = append(errs, field.Required(path.Child("name"), ""))
+		} else if names[seed.Name] {
+			errs = append(errs, field.Duplicate(path.Child("name"), seed.Name))
+		}
+		names[seed.Name] = true
+
+		statefulSet, ok := statefulSets[seed.StatefulSet]
+		switch {
+		case !ok:
+			errs = append(errs, field.NotFound(path.Child("statefulSet"), seed.StatefulSet))
+		case statefulSet.ServiceName == "":
+			errs = append(errs, field.Invalid(path.Child("statefulSet"), seed.StatefulSet, "the StatefulSet needs a serviceName so its pods have DNS names"))
+		}
+		switch seed.RerunPolicy {
+		case "", RerunOnDataLoss, RerunNever:
+		default:
+			errs = append(errs, field.NotSupported(path.Child("rerunPolicy"), seed.RerunPolicy, []string{RerunOnDataLoss, RerunNever}))
+		}
+	}
+	return errs
+}
+
+// SeedRunner runs the seed jobs of a cluster
+type SeedRunner struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewSeedRunner creates a new seed job runner
+func NewSeedRunner(client client.Client, scheme *runtime.Scheme) *SeedRunner {
+	return &SeedRunner{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Reconcile advances every seed job of the cluster: a seed job waits until all replicas of its
+// StatefulSet are Ready and their DNS names resolve, then runs its Job once. It runs again when
+// the claims of the StatefulSet, or its pods without claims, were recreated since the last
+// successful run, and a failed seed job runs again for the next revision of the cluster.
+// Progress is recorded in cluster.Status.SeedJobs; the caller persists the status.
+func (r *SeedRunner) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	if err := ValidateSeedJobs(cluster).ToAggregate(); err != nil {
+		return fmt.Errorf("invalid seed jobs: %w", err)
+	}
+
+	statuses := make([]k8splaygroundsv1alpha1.SeedJobStatus, 0, len(cluster.Spec.SeedJobs))
+	current := map[string]bool{}
+	for _, seed := range cluster.Spec.SeedJobs {
+		status := k8splaygroundsv1alpha1.SeedJobStatus{Name: seed.Name, StatefulSet: seed.StatefulSet}
+		for _, previous := range cluster.Status.SeedJobs {
+			if previous.Name == seed.Name && previous.StatefulSet == seed.StatefulSet {
+				status = previous
+			}
+		}
+		if err := r.runSeed(ctx, cluster, seed, &status); err != nil {
+			return err
+		}
+		statuses = append(statuses, status)
+		if status.JobName != "" {
+			current[status.JobName] = true
+		}
+	}
+	cluster.Status.SeedJobs = statuses
+	return r.pruneJobs(ctx, cluster, current)
+}
+
+// runSeed advances one seed job
+func (r *SeedRunner) runSeed(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, seed k8splaygroundsv1alpha1.SeedJobSpec, status *k8splaygroundsv1alpha1.SeedJobStatus) error {
+	log := logr.FromContextOrDiscard(ctx).WithValues("seedJob", seed.Name, "statefulSet", seed.StatefulSet)
+
+	if status.State == StateRunning {
+		job := &batchv1.Job{}
+		if err := r.client.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: status.JobName}, job); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to get seed job %s: %w", status.JobName, err)
+			}
+			// The Job was removed out of band; seed again once the StatefulSet is ready
+			status.State = StateWaiting
+			status.Runs--
+		} else {
+			finished, failed, message := jobResult(job)
+			if !finished {
+				return nil
+			}
+			now := metav1.Now()
+			status.CompletedAt = &now
+			status.Message = message
+			if failed {
+				log.Info("seed job failed", "job", job.Name, "message", message)
+				status.State = StateFailed
+				return nil
+			}
+			log.Info("seed job succeeded", "job", job.Name)
+			status.State = StateSucceeded
+			return nil
+		}
+	}
+
+	statefulSet := statefulSetSpec(cluster, seed.StatefulSet)
+	namespace := statefulSet.Namespace
+	if namespace == "" {
+		namespace = cluster.Namespace
+	}
+	replicas, message, err := r.statefulSetReady(ctx, namespace, statefulSet)
+	if err != nil {
+		return err
+	}
+	ready := message == ""
+	dataID := ""
+	if ready {
+		dataID, err = r.dataID(ctx, namespace, statefulSet, replicas)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch status.State {
+	case StateSucceeded:
+		// Keep the seeded data while the StatefulSet recovers; judge it once it is ready again
+		if !ready || dataID == status.DataID || seed.RerunPolicy == RerunNever {
+			return nil
+		}
+		log.Info("data of the StatefulSet was recreated, seeding again", "previous", status.DataID, "current", dataID)
+	case StateFailed:
+		if status.Revision == cluster.Generation {
+			return nil
+		}
+	}
+	if !ready {
+		status.State = StateWaiting
+		status.Message = message
+		return nil
+	}
+	return r.startJob(ctx, cluster, seed, status, dataID)
+}
+
+// statefulSetReady returns the replicas of a StatefulSet once all of them are Ready and the DNS
+// names of their pods resolve, or a message saying what is missing
+func (r *SeedRunner) statefulSetReady(ctx context.Context, namespace string, spec *k8splaygroundsv1alpha1.StatefulSetSpec) (int32, string, error) {
+	statefulSet := &appsv1.StatefulSet{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: spec.Name}, statefulSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, fmt.Sprintf("StatefulSet %s does not exist yet", spec.Name), nil
+		}
+		return 0, "", fmt.Errorf("failed to get StatefulSet %s: %w", spec.Name, err)
+	}
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	if replicas == 0 {
+		return 0, fmt.Sprintf("StatefulSet %s is scaled to zero", spec.Name), nil
+	}
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation || statefulSet.Status.ReadyReplicas < replicas {
+		return replicas, fmt.Sprintf("%d/%d replicas of StatefulSet %s are ready", statefulSet.Status.ReadyReplicas, replicas, spec.Name), nil
+	}
+
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		host := fmt.Sprintf("%s-%d.%s.%s.svc.%s", spec.Name, ordinal, spec.ServiceName, namespace, clusterdomain.Default())
+		if addrs, err := LookupHost(ctx, host); err != nil || len(addrs) == 0 {
+			return replicas, fmt.Sprintf("%s does not resolve yet", host), nil
+		}
+	}
+	return replicas, "", nil
+}
+
+// dataID fingerprints the data of a StatefulSet by the UIDs of the claims of every replica, or
+// of its pods when it has no claim templates. Auto-healing that recreates a claim or pod
+// changes the fingerprint.
+func (r *SeedRunner) dataID(ctx context.Context, namespace string, spec *k8splaygroundsv1alpha1.StatefulSetSpec, replicas int32) (string, error) {
+	var uids []string
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		if len(spec.VolumeClaimTemplates) == 0 {
+			pod := &corev1.Pod{}
+			name := fmt.Sprintf("%s-%d", spec.Name, ordinal)
+			if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil && !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to get pod %s: %w", name, err)
+			}
+			uids = append(uids, string(pod.UID))
+			continue
+		}
+		for _, template := range spec.VolumeClaimTemplates {
+			claim := &corev1.PersistentVolumeClaim{}
+			name := fmt.Sprintf("%s-%s-%d", template.Metadata.Name, spec.Name, ordinal)
+			if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, claim); err != nil && !apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("failed to get claim %s: %w", name, err)
+			}
+			uids = append(uids, string(claim.UID))
+		}
+	}
+	sort.Strings(uids)
+	sum := sha256.Sum256([]byte(strings.Join(uids, ",")))
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// startJob creates the Job of the next run of a seed job
+func (r *SeedRunner) startJob(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, seed k8splaygroundsv1alpha1.SeedJobSpec, status *k8splaygroundsv1alpha1.SeedJobStatus, dataID string) error {
+	template, err := buildPodTemplate(seed.Template)
+	if err != nil {
+		return fmt.Errorf("invalid seed job %s: %w", seed.Name, err)
+	}
+
+	run := status.Runs + 1
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      SeedJobName(cluster.Name, seed.Name, run),
+			Namespace: cluster.Namespace,
+			Labels: map[string]string{
+				clusterLabel: cluster.Name,
+				seedLabel:    seed.Name,
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:          seed.BackoffLimit,
+			ActiveDeadlineSeconds: seed.ActiveDeadlineSeconds,
+			Template:              template,
+		},
+	}
+	if err := controllerutil.SetControllerReference(cluster, job, r.scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference on seed job: %w", err)
+	}
+	if err := r.client.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create seed job %s: %w", job.Name, err)
+	}
+
+	logr.FromContextOrDiscard(ctx).Info("started seed job", "seedJob", seed.Name, "job", job.Name, "run", run)
+	now := metav1.Now()
+	status.Runs = run
+	status.JobName = job.Name
+	status.State = StateRunning
+	status.Revision = cluster.Generation
+	status.DataID = dataID
+	status.Message = ""
+	status.StartedAt = &now
+	status.CompletedAt = nil
+	return nil
+}
+
+// Cleanup deletes every seed Job of the cluster
+func (r *SeedRunner) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	return r.pruneJobs(ctx, cluster, nil)
+}
+
+// pruneJobs deletes the seed Jobs of earlier runs and of removed seed jobs
+func (r *SeedRunner) pruneJobs(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, current map[string]bool) error {
+	jobs := &batchv1.JobList{}
+	if err := r.client.List(ctx, jobs, client.InNamespace(cluster.Namespace), client.MatchingLabels{clusterLabel: cluster.Name}, client.HasLabels{seedLabel}); err != nil {
+		return fmt.Errorf("failed to list seed jobs: %w", err)
+	}
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if current[job.Name] {
+			continue
+		}
+		if err := r.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete stale seed job %s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// SeedPending reports whether a seed job is waiting or running, so the cluster is checked
+// again; StatefulSet and Job status changes do not trigger cluster reconciles
+func SeedPending(statuses []k8splaygroundsv1alpha1.SeedJobStatus) bool {
+	for _, status := range statuses {
+		if status.State == StateWaiting || status.State == StateRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// SeedJobName returns the name of the Job for one run of a seed job
+func SeedJobName(clusterName, seedName string, run int32) string {
+	return naming.Qualified(naming.SeedJob, clusterName, fmt.Sprintf("%s-%d", seedName, run))
+}
+
+// statefulSetSpec returns the StatefulSet of the cluster with the given name; seed jobs are
+// validated to name one
+func statefulSetSpec(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, name string) *k8splaygroundsv1alpha1.StatefulSetSpec {
+	for i := range cluster.Spec.StatefulSets {
+		if cluster.Spec.StatefulSets[i].Name == name {
+			return &cluster.Spec.StatefulSets[i]
+		}
+	}
+	return nil
+}
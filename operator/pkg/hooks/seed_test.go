@@ -0,0 +1,214 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func seedCluster(rerunPolicy string) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "labs", Generation: 1},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			StatefulSets: []k8splaygroundsv1alpha1.StatefulSetSpec{{
+				Name:                 "db",
+				Replicas:             2,
+				ServiceName:          "db-headless",
+				VolumeClaimTemplates: []k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate{{Metadata: metav1.ObjectMeta{Name: "data"}}},
+			}},
+			SeedJobs: []k8splaygroundsv1alpha1.SeedJobSpec{{
+				Name:        "load",
+				StatefulSet: "db",
+				RerunPolicy: rerunPolicy,
+				Template: k8splaygroundsv1alpha1.PodTemplateSpec{Spec: k8splaygroundsv1alpha1.PodSpec{
+					Containers: []k8splaygroundsv1alpha1.ContainerSpec{{Name: "seed", Image: "postgres:16", Command: []string{"psql", "-f", "/seed.sql"}}},
+				}},
+			}},
+		},
+	}
+}
+
+func claim(name, uid string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "labs", UID: types.UID(uid)}}
+}
+
+func newSeedRunner(t *testing.T, objs ...client.Object) (*SeedRunner, client.Client) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypes(k8splaygroundsv1alpha1.SchemeGroupVersion, &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+	return NewSeedRunner(c, scheme), c
+}
+
+// resolve makes the DNS names of the given hosts resolve for the duration of the test
+func resolve(t *testing.T, hosts map[string]bool) {
+	previous := LookupHost
+	t.Cleanup(func() { LookupHost = previous })
+	LookupHost = func(ctx context.Context, host string) ([]string, error) {
+		if hosts[host] {
+			return []string{"10.0.0.1"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+}
+
+func completeJob(t *testing.T, c client.Client, name string) {
+	job := &batchv1.Job{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "labs", Name: name}, job); err != nil {
+		t.Fatal(err)
+	}
+	job.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+	if err := c.Status().Update(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSeedRunnerWaitsAndRerunsOnDataLoss(t *testing.T) {
+	ctx := context.Background()
+	replicas := int32(2)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "labs"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+	}
+	runner, c := newSeedRunner(t, statefulSet, claim("data-db-0", "uid-0"), claim("data-db-1", "uid-1"))
+	cluster := seedCluster("")
+	hosts := map[string]bool{"db-0.db-headless.labs.svc.cluster.local": true}
+	resolve(t, hosts)
+
+	if err := runner.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if status := cluster.Status.SeedJobs[0]; status.State != StateWaiting || status.Message != "1/2 replicas of StatefulSet db are ready" {
+		t.Errorf("status = %+v, want waiting for the replicas", status)
+	}
+
+	statefulSet.Status.ReadyReplicas = 2
+	if err := c.Status().Update(ctx, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if status := cluster.Status.SeedJobs[0]; status.State != StateWaiting || status.Message != "db-1.db-headless.labs.svc.cluster.local does not resolve yet" {
+		t.Errorf("status = %+v, want waiting for DNS", status)
+	}
+
+	hosts["db-1.db-headless.labs.svc.cluster.local"] = true
+	if err := runner.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	first := cluster.Status.SeedJobs[0]
+	if first.State != StateRunning || first.JobName != "demo-seed-load-1" || first.DataID == "" {
+		t.Fatalf("status = %+v, want the first run started", first)
+	}
+
+	completeJob(t, c, first.JobName)
+	if err := runner.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if status := cluster.Status.SeedJobs[0]; status.State != StateSucceeded || status.Runs != 1 {
+		t.Errorf("status = %+v, want one successful run", status)
+	}
+
+	// Auto-healing replaced the volume of replica 1
+	if err := c.Delete(ctx, claim("data-db-1", "uid-1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Create(ctx, claim("data-db-1", "uid-1b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	second := cluster.Status.SeedJobs[0]
+	if second.State != StateRunning || second.Runs != 2 || second.DataID == first.DataID {
+		t.Fatalf("status = %+v, want a second run for the new data", second)
+	}
+	jobs := &batchv1.JobList{}
+	if err := c.List(ctx, jobs); err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs.Items) != 1 || jobs.Items[0].Name != "demo-seed-load-2" {
+		t.Errorf("jobs = %v, want only the Job of the second run", jobs.Items)
+	}
+}
+
+func TestSeedRunnerRerunNever(t *testing.T) {
+	replicas := int32(1)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "labs"},
+		Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+		Status:     appsv1.StatefulSetStatus{ReadyReplicas: 1},
+	}
+	runner, _ := newSeedRunner(t, statefulSet, claim("data-db-0", "uid-0b"))
+	resolve(t, map[string]bool{"db-0.db-headless.labs.svc.cluster.local": true})
+	cluster := seedCluster(RerunNever)
+	cluster.Status.SeedJobs = []k8splaygroundsv1alpha1.SeedJobStatus{{Name: "load", StatefulSet: "db", State: StateSucceeded, Runs: 1, DataID: "seeded"}}
+	if err := runner.Reconcile(context.Background(), cluster); err != nil {
+		t.Fatal(err)
+	}
+	if status := cluster.Status.SeedJobs[0]; status.State != StateSucceeded || status.Runs != 1 {
+		t.Errorf("status = %+v, want the seeded data kept", status)
+	}
+}
+
+func TestSeedRunnerCleanup(t *testing.T) {
+	ctx := context.Background()
+	seedJob := func(name, cluster string) *batchv1.Job {
+		return &batchv1.Job{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "labs",
+			Labels: map[string]string{clusterLabel: cluster, seedLabel: "load"},
+		}}
+	}
+	unrelated := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "labs", Labels: map[string]string{clusterLabel: "demo"}}}
+	runner, c := newSeedRunner(t, seedJob(SeedJobName("demo", "load", 1), "demo"), seedJob(SeedJobName("demo", "load", 2), "demo"), seedJob(SeedJobName("other", "load", 1), "other"), unrelated)
+
+	if err := runner.Cleanup(ctx, seedCluster("")); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	jobs := &batchv1.JobList{}
+	if err := c.List(ctx, jobs); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, job := range jobs.Items {
+		names = append(names, job.Name)
+	}
+	if len(names) != 2 || names[0] != "migrate" || names[1] != SeedJobName("other", "load", 1) {
+		t.Errorf("jobs after Cleanup() = %v, want only the Jobs that are not seed jobs of the cluster", names)
+	}
+}
+
+func TestValidateSeedJobs(t *testing.T) {
+	cluster := seedCluster("Always")
+	cluster.Spec.StatefulSets[0].ServiceName = ""
+	cluster.Spec.SeedJobs = append(cluster.Spec.SeedJobs, k8splaygroundsv1alpha1.SeedJobSpec{Name: "load", StatefulSet: "cache"})
+	errs := ValidateSeedJobs(cluster)
+	want := []string{"spec.seedJobs[0].statefulSet", "spec.seedJobs[0].rerunPolicy", "spec.seedJobs[1].name", "spec.seedJobs[1].statefulSet"}
+	if len(errs) != len(want) {
+		t.Fatalf("ValidateSeedJobs() = %v, want errors for %v", errs, want)
+	}
+	for i, field := range want {
+		if errs[i].Field != field {
+			t.Errorf("error %d = %v, want one for %s", i, errs[i], field)
+		}
+	}
+}
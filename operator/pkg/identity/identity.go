@@ -0,0 +1,267 @@
+package identity
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/volumeplacement"
+)
+
+// Reasons of the IdentityViolated condition
+const (
+	ReasonViolated     = "IdentityViolated"
+	ReasonNoViolations = "NoViolations"
+)
+
+// maxConditionViolations bounds the violations listed in the condition message; the statuses
+// of the ordinals list all of them
+const maxConditionViolations = 5
+
+// Observer maps the ordinals of the StatefulSets of a cluster to their pods, claims, volumes
+// and nodes
+type Observer struct {
+	reader client.Reader
+}
+
+// NewObserver creates a new identity observer
+func NewObserver(reader client.Reader) *Observer {
+	return &Observer{reader: reader}
+}
+
+// Observe returns the identity of every ordinal of the StatefulSets of a cluster. previous
+// are the identities of the last reconcile; an ordinal whose pod shows up on another node is
+// recorded as moved.
+func (o *Observer) Observe(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, previous []k8splaygroundsv1alpha1.StatefulSetIdentityStatus, now time.Time) ([]k8splaygroundsv1alpha1.StatefulSetIdentityStatus, error) {
+	last := make(map[string]k8splaygroundsv1alpha1.StatefulSetIdentityStatus, len(previous))
+	for _, status := range previous {
+		last[status.Namespace+"/"+status.Pod] = status
+	}
+
+	var statuses []k8splaygroundsv1alpha1.StatefulSetIdentityStatus
+	for i := range cluster.Spec.StatefulSets {
+		statefulSet := &cluster.Spec.StatefulSets[i]
+		namespace := statefulSet.Namespace
+		if namespace == "" {
+			namespace = cluster.Namespace
+		}
+		topologyKey := volumeplacement.DefaultTopologyKey
+		if statefulSet.VolumePlacement != nil && statefulSet.VolumePlacement.TopologyKey != "" {
+			topologyKey = statefulSet.VolumePlacement.TopologyKey
+		}
+		for ordinal := int32(0); ordinal < statefulSet.Replicas; ordinal++ {
+			status, err := o.observe(ctx, namespace, statefulSet, ordinal, topologyKey)
+			if err != nil {
+				return nil, err
+			}
+			track(&status, last[status.Namespace+"/"+status.Pod], now)
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// observe reads the pod, claims and volumes of one ordinal and checks its identity
+func (o *Observer) observe(ctx context.Context, namespace string, statefulSet *k8splaygroundsv1alpha1.StatefulSetSpec, ordinal int32, topologyKey string) (k8splaygroundsv1alpha1.StatefulSetIdentityStatus, error) {
+	status := k8splaygroundsv1alpha1.StatefulSetIdentityStatus{
+		StatefulSet: statefulSet.Name,
+		Namespace:   namespace,
+		Ordinal:     ordinal,
+		Pod:         fmt.Sprintf("%s-%d", statefulSet.Name, ordinal),
+	}
+
+	pod := &corev1.Pod{}
+	if err := o.reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: status.Pod}, pod); err != nil {
+		if !errors.IsNotFound(err) {
+			return status, fmt.Errorf("failed to get pod %s/%s: %w", namespace, status.Pod, err)
+		}
+		pod = nil
+	}
+	if pod != nil {
+		status.PodPhase = string(pod.Status.Phase)
+		status.Node = pod.Spec.NodeName
+		if owner := metav1.GetControllerOf(pod); owner == nil || owner.Kind != "StatefulSet" || owner.Name != statefulSet.Name {
+			status.Violations = append(status.Violations, fmt.Sprintf("pod %s is not controlled by StatefulSet %s", status.Pod, statefulSet.Name))
+		}
+	}
+	if status.Node != "" {
+		node := &corev1.Node{}
+		if err := o.reader.Get(ctx, types.NamespacedName{Name: status.Node}, node); err != nil {
+			if !errors.IsNotFound(err) {
+				return status, fmt.Errorf("failed to get node %s: %w", status.Node, err)
+			}
+		} else {
+			status.Zone = node.Labels[topologyKey]
+		}
+	}
+
+	for _, template := range statefulSet.VolumeClaimTemplates {
+		claimStatus := k8splaygroundsv1alpha1.IdentityClaimStatus{
+			Name: volumeplacement.ClaimName(template.Metadata.Name, statefulSet.Name, ordinal),
+		}
+		if pod != nil {
+			if mounted := mountedClaim(pod, template.Metadata.Name); mounted != "" && mounted != claimStatus.Name {
+				status.Violations = append(status.Violations, fmt.Sprintf("pod %s mounts claim %s instead of %s", status.Pod, mounted, claimStatus.Name))
+			}
+		}
+
+		claim := &corev1.PersistentVolumeClaim{}
+		if err := o.reader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: claimStatus.Name}, claim); err != nil {
+			if !errors.IsNotFound(err) {
+				return status, fmt.Errorf("failed to get claim %s/%s: %w", namespace, claimStatus.Name, err)
+			}
+			status.Claims = append(status.Claims, claimStatus)
+			continue
+		}
+		claimStatus.Phase = string(claim.Status.Phase)
+		claimStatus.Volume = claim.Spec.VolumeName
+		if claim.Status.Phase == corev1.ClaimLost {
+			status.Violations = append(status.Violations, fmt.Sprintf("claim %s lost its volume %s", claimStatus.Name, claimStatus.Volume))
+		}
+
+		if claimStatus.Volume != "" {
+			volume := &corev1.PersistentVolume{}
+			if err := o.reader.Get(ctx, types.NamespacedName{Name: claimStatus.Volume}, volume); err != nil {
+				if !errors.IsNotFound(err) {
+					return status, fmt.Errorf("failed to get volume %s: %w", claimStatus.Volume, err)
+				}
+			} else {
+				if ref := volume.Spec.ClaimRef; ref != nil && (ref.Namespace != namespace || ref.Name != claimStatus.Name) {
+					status.Violations = append(status.Violations, fmt.Sprintf("volume %s of claim %s is bound to claim %s/%s", volume.Name, claimStatus.Name, ref.Namespace, ref.Name))
+				}
+				zones := volumeZones(volume, topologyKey)
+				claimStatus.Zone = strings.Join(zones, ",")
+				if status.Zone != "" && len(zones) > 0 && !contains(zones, status.Zone) {
+					status.Violations = append(status.Violations, fmt.Sprintf("pod %s runs in zone %s but volume %s of claim %s is in zone %s", status.Pod, status.Zone, volume.Name, claimStatus.Name, claimStatus.Zone))
+				}
+			}
+		}
+		status.Claims = append(status.Claims, claimStatus)
+	}
+	return status, nil
+}
+
+// track carries the node history of an ordinal over from its previous identity. A pod that
+// does not run keeps the node it last ran on; one running on another node has moved.
+func track(status *k8splaygroundsv1alpha1.StatefulSetIdentityStatus, previous k8splaygroundsv1alpha1.StatefulSetIdentityStatus, now time.Time) {
+	status.PreviousNode = previous.PreviousNode
+	status.MovedAt = previous.MovedAt
+	switch {
+	case status.Node == "":
+		status.Node = previous.Node
+		if status.Zone == "" {
+			status.Zone = previous.Zone
+		}
+	case previous.Node != "" && previous.Node != status.Node:
+		status.PreviousNode = previous.Node
+		status.MovedAt = &metav1.Time{Time: now}
+	}
+}
+
+// SetCondition reports the violations of the identities in the IdentityViolated condition of
+// the cluster. The condition is removed from clusters without StatefulSets.
+func SetCondition(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, statuses []k8splaygroundsv1alpha1.StatefulSetIdentityStatus, now time.Time) {
+	conditionType := k8splaygroundsv1alpha1.ClusterConditionIdentityViolated
+	index := -1
+	for i, condition := range cluster.Status.Conditions {
+		if condition.Type == conditionType {
+			index = i
+		}
+	}
+	if len(statuses) == 0 {
+		if index >= 0 {
+			cluster.Status.Conditions = append(cluster.Status.Conditions[:index], cluster.Status.Conditions[index+1:]...)
+		}
+		return
+	}
+
+	var violations []string
+	for _, status := range statuses {
+		violations = append(violations, status.Violations...)
+	}
+	sort.Strings(violations)
+	condition := k8splaygroundsv1alpha1.ClusterCondition{
+		Type:    conditionType,
+		Status:  metav1.ConditionFalse,
+		Reason:  ReasonNoViolations,
+		Message: fmt.Sprintf("%d StatefulSet replicas keep their identity", len(statuses)),
+	}
+	if len(violations) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = ReasonViolated
+		listed := violations
+		if len(listed) > maxConditionViolations {
+			listed = listed[:maxConditionViolations]
+		}
+		condition.Message = strings.Join(listed, "; ")
+		if more := len(violations) - len(listed); more > 0 {
+			condition.Message += fmt.Sprintf("; and %d more", more)
+		}
+	}
+
+	if index < 0 {
+		condition.LastTransitionTime = metav1.NewTime(now)
+		cluster.Status.Conditions = append(cluster.Status.Conditions, condition)
+		return
+	}
+	condition.LastTransitionTime = cluster.Status.Conditions[index].LastTransitionTime
+	if cluster.Status.Conditions[index].Status != condition.Status {
+		condition.LastTransitionTime = metav1.NewTime(now)
+	}
+	cluster.Status.Conditions[index] = condition
+}
+
+// mountedClaim returns the claim a pod mounts as the volume of a claim template, which the
+// StatefulSet controller names after the template
+func mountedClaim(pod *corev1.Pod, template string) string {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == template && volume.PersistentVolumeClaim != nil {
+			return volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	return ""
+}
+
+// volumeZones returns the zones a volume is restricted to by its node affinity, or by its
+// topology label for volumes provisioned before node affinity
+func volumeZones(volume *corev1.PersistentVolume, topologyKey string) []string {
+	var zones []string
+	if affinity := volume.Spec.NodeAffinity; affinity != nil && affinity.Required != nil {
+		for _, term := range affinity.Required.NodeSelectorTerms {
+			for _, expression := range term.MatchExpressions {
+				if expression.Key == topologyKey && expression.Operator == corev1.NodeSelectorOpIn {
+					for _, zone := range expression.Values {
+						if !contains(zones, zone) {
+							zones = append(zones, zone)
+						}
+					}
+				}
+			}
+		}
+	}
+	if len(zones) == 0 {
+		if zone := volume.Labels[topologyKey]; zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
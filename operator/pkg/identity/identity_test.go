@@ -0,0 +1,176 @@
+package identity
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+const zoneKey = "topology.kubernetes.io/zone"
+
+func newCluster() *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "lab", Namespace: "lab"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			StatefulSets: []k8splaygroundsv1alpha1.StatefulSetSpec{{
+				Name:     "db",
+				Replicas: 2,
+				VolumeClaimTemplates: []k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate{{
+					Metadata: metav1.ObjectMeta{Name: "data"},
+				}},
+			}},
+		},
+	}
+}
+
+func newPod(name, node, claim string) *corev1.Pod {
+	controller := true
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "lab",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "db", UID: "uid", Controller: &controller}},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Volumes: []corev1.Volume{{
+				Name:         "data",
+				VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim}},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+}
+
+func newNode(name, zone string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{zoneKey: zone}}}
+}
+
+func newClaim(name, volume string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "lab"},
+		Spec:       corev1.PersistentVolumeClaimSpec{VolumeName: volume},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+}
+
+func newVolume(name, claim, zone string) *corev1.PersistentVolume {
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{Namespace: "lab", Name: claim},
+			NodeAffinity: &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key: zoneKey, Operator: corev1.NodeSelectorOpIn, Values: []string{zone},
+				}}}},
+			}},
+		},
+	}
+}
+
+func newReader(objs ...client.Object) client.Reader {
+	return fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(objs...).Build()
+}
+
+func TestObserveMapsOrdinalsAndFlagsViolations(t *testing.T) {
+	reader := newReader(
+		newNode("node-a", "zone-a"), newNode("node-b", "zone-b"),
+		newPod("db-0", "node-a", "data-db-0"),
+		newClaim("data-db-0", "pv-0"), newVolume("pv-0", "data-db-0", "zone-a"),
+		// db-1 was rescheduled into another zone than its volume
+		newPod("db-1", "node-b", "data-db-1"),
+		newClaim("data-db-1", "pv-1"), newVolume("pv-1", "data-db-1", "zone-a"),
+	)
+	cluster := newCluster()
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	statuses, err := NewObserver(reader).Observe(context.Background(), cluster, nil, now)
+	if err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("statuses = %+v, want one per ordinal", statuses)
+	}
+	first := statuses[0]
+	if first.Pod != "db-0" || first.Node != "node-a" || first.Zone != "zone-a" || len(first.Claims) != 1 ||
+		first.Claims[0].Volume != "pv-0" || first.Claims[0].Zone != "zone-a" || len(first.Violations) != 0 {
+		t.Errorf("ordinal 0 = %+v, want db-0 on node-a with pv-0 in zone-a", first)
+	}
+	if violations := statuses[1].Violations; len(violations) != 1 || !strings.Contains(violations[0], "runs in zone zone-b but volume pv-1") {
+		t.Errorf("ordinal 1 violations = %v, want the zone mismatch", violations)
+	}
+
+	SetCondition(cluster, statuses, now)
+	condition := cluster.Status.Conditions[0]
+	if condition.Type != k8splaygroundsv1alpha1.ClusterConditionIdentityViolated || condition.Status != metav1.ConditionTrue || condition.Reason != ReasonViolated {
+		t.Errorf("condition = %+v, want the identity violated", condition)
+	}
+}
+
+func TestObserveTracksMoves(t *testing.T) {
+	cluster := newCluster()
+	cluster.Spec.StatefulSets[0].Replicas = 1
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	previous := []k8splaygroundsv1alpha1.StatefulSetIdentityStatus{{StatefulSet: "db", Namespace: "lab", Pod: "db-0", Node: "node-a", Zone: "zone-a"}}
+
+	// While the pod is recreated it keeps the node it last ran on
+	statuses, err := NewObserver(newReader()).Observe(context.Background(), cluster, previous, now)
+	if err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if statuses[0].PodPhase != "" || statuses[0].Node != "node-a" || statuses[0].MovedAt != nil {
+		t.Errorf("missing pod = %+v, want the last node kept", statuses[0])
+	}
+
+	reader := newReader(newNode("node-b", "zone-a"), newPod("db-0", "node-b", "data-db-0"))
+	statuses, err = NewObserver(reader).Observe(context.Background(), cluster, statuses, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if statuses[0].Node != "node-b" || statuses[0].PreviousNode != "node-a" || statuses[0].MovedAt == nil {
+		t.Errorf("moved pod = %+v, want a move from node-a to node-b", statuses[0])
+	}
+}
+
+func TestObserveFlagsForeignPodsAndClaims(t *testing.T) {
+	cluster := newCluster()
+	cluster.Spec.StatefulSets[0].Replicas = 1
+	pod := newPod("db-0", "", "data-db-1")
+	pod.OwnerReferences = nil
+	volume := newVolume("pv-0", "data-other", "zone-a")
+	reader := newReader(pod, newClaim("data-db-0", "pv-0"), volume)
+
+	statuses, err := NewObserver(reader).Observe(context.Background(), cluster, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Observe() error = %v", err)
+	}
+	if violations := statuses[0].Violations; len(violations) != 3 {
+		t.Errorf("violations = %v, want the unowned pod, the wrong claim and the foreign volume", violations)
+	}
+}
+
+func TestSetConditionKeepsTransitionTime(t *testing.T) {
+	cluster := newCluster()
+	start := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	statuses := []k8splaygroundsv1alpha1.StatefulSetIdentityStatus{{Pod: "db-0"}}
+
+	SetCondition(cluster, statuses, start)
+	SetCondition(cluster, statuses, start.Add(time.Hour))
+	if len(cluster.Status.Conditions) != 1 || !cluster.Status.Conditions[0].LastTransitionTime.Time.Equal(start) || cluster.Status.Conditions[0].Status != metav1.ConditionFalse {
+		t.Errorf("conditions = %+v, want one unviolated condition since the start", cluster.Status.Conditions)
+	}
+
+	SetCondition(cluster, nil, start.Add(2*time.Hour))
+	if len(cluster.Status.Conditions) != 0 {
+		t.Errorf("conditions = %+v, want the condition removed without StatefulSets", cluster.Status.Conditions)
+	}
+}
@@ -0,0 +1,176 @@
+package inventory
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// StaleAfter is how long a running cluster may go without a reconcile before it is reported
+// as stale. Running clusters are reconciled every five minutes by default.
+const StaleAfter = 15 * time.Minute
+
+// Overall states of the health summary
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// Cluster is the inventory entry of one managed cluster
+type Cluster struct {
+	Namespace          string     `json:"namespace"`
+	Name               string     `json:"name"`
+	Phase              string     `json:"phase"`
+	Health             string     `json:"health"`
+	Version            string     `json:"version,omitempty"`
+	ReadyReplicas      int32      `json:"readyReplicas"`
+	TotalReplicas      int32      `json:"totalReplicas"`
+	LastReconcileTime  *time.Time `json:"lastReconcileTime,omitempty"`
+	ReconcileErrors    int32      `json:"reconcileErrors"`
+	LastReconcileError string     `json:"lastReconcileError,omitempty"`
+	Stale              bool       `json:"stale,omitempty"`
+}
+
+// Inventory lists every managed cluster
+type Inventory struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	Clusters    []Cluster `json:"clusters"`
+}
+
+// HealthSummary condenses the inventory for uptime monitors
+type HealthSummary struct {
+	Status          string         `json:"status"`
+	GeneratedAt     time.Time      `json:"generatedAt"`
+	Clusters        int            `json:"clusters"`
+	Phases          map[string]int `json:"phases"`
+	Health          map[string]int `json:"health"`
+	ReconcileErrors int32          `json:"reconcileErrors"`
+	// Failing lists the clusters, as namespace/name, that failed, are unhealthy, fail to
+	// reconcile or went stale
+	Failing []string `json:"failing,omitempty"`
+}
+
+// Build returns the inventory of the clusters, sorted by namespace and name
+func Build(clusters []k8splaygroundsv1alpha1.K8sPlaygroundsCluster, now time.Time) Inventory {
+	inventory := Inventory{GeneratedAt: now.UTC(), Clusters: []Cluster{}}
+	for i := range clusters {
+		cluster := &clusters[i]
+		entry := Cluster{
+			Namespace:          cluster.Namespace,
+			Name:               cluster.Name,
+			Phase:              string(cluster.Status.Phase),
+			Health:             string(cluster.Status.Health),
+			Version:            cluster.Status.Version,
+			ReadyReplicas:      cluster.Status.ReadyReplicas,
+			TotalReplicas:      cluster.Status.TotalReplicas,
+			ReconcileErrors:    cluster.Status.ReconcileErrors,
+			LastReconcileError: cluster.Status.LastReconcileError,
+		}
+		if entry.Phase == "" {
+			entry.Phase = string(k8splaygroundsv1alpha1.ClusterPhasePending)
+		}
+		if entry.Health == "" {
+			entry.Health = string(k8splaygroundsv1alpha1.ClusterHealthUnknown)
+		}
+		if last := cluster.Status.LastUpdated; !last.IsZero() {
+			t := last.UTC()
+			entry.LastReconcileTime = &t
+			entry.Stale = cluster.Status.Phase == k8splaygroundsv1alpha1.ClusterPhaseRunning && now.Sub(t) > StaleAfter
+		}
+		inventory.Clusters = append(inventory.Clusters, entry)
+	}
+	sort.Slice(inventory.Clusters, func(i, j int) bool {
+		if inventory.Clusters[i].Namespace != inventory.Clusters[j].Namespace {
+			return inventory.Clusters[i].Namespace < inventory.Clusters[j].Namespace
+		}
+		return inventory.Clusters[i].Name < inventory.Clusters[j].Name
+	})
+	return inventory
+}
+
+// Summarize counts the clusters of an inventory by phase and health. The summary is degraded
+// while any cluster is failing.
+func Summarize(inventory Inventory) HealthSummary {
+	summary := HealthSummary{
+		Status:      StatusOK,
+		GeneratedAt: inventory.GeneratedAt,
+		Clusters:    len(inventory.Clusters),
+		Phases:      map[string]int{},
+		Health:      map[string]int{},
+	}
+	for _, cluster := range inventory.Clusters {
+		summary.Phases[cluster.Phase]++
+		summary.Health[cluster.Health]++
+		summary.ReconcileErrors += cluster.ReconcileErrors
+		if cluster.Phase == string(k8splaygroundsv1alpha1.ClusterPhaseFailed) ||
+			cluster.Health == string(k8splaygroundsv1alpha1.ClusterHealthUnhealthy) ||
+			cluster.ReconcileErrors > 0 || cluster.Stale {
+			summary.Failing = append(summary.Failing, cluster.Namespace+"/"+cluster.Name)
+		}
+	}
+	if len(summary.Failing) > 0 {
+		summary.Status = StatusDegraded
+	}
+	return summary
+}
+
+// Handler serves the inventory as JSON. Requests must carry the token as a bearer token.
+func Handler(reader client.Reader, token string) http.Handler {
+	return serve(reader, token, func(w http.ResponseWriter, inventory Inventory) {
+		writeJSON(w, http.StatusOK, inventory)
+	})
+}
+
+// SummaryHandler serves the health summary as JSON, with status 503 while it is degraded so
+// that monitors can alert on the status code alone. Requests must carry the token as a bearer
+// token.
+func SummaryHandler(reader client.Reader, token string) http.Handler {
+	return serve(reader, token, func(w http.ResponseWriter, inventory Inventory) {
+		summary := Summarize(inventory)
+		code := http.StatusOK
+		if summary.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, summary)
+	})
+}
+
+// serve authenticates a GET request and hands the current inventory to write
+func serve(reader client.Reader, token string, write func(http.ResponseWriter, Inventory)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !authorized(req, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="inventory"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		clusters := &k8splaygroundsv1alpha1.K8sPlaygroundsClusterList{}
+		if err := reader.List(req.Context(), clusters); err != nil {
+			http.Error(w, "failed to list clusters: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		write(w, Build(clusters.Items, time.Now()))
+	})
+}
+
+// authorized reports whether a request carries the token. An empty token authorizes nothing.
+func authorized(req *http.Request, token string) bool {
+	presented, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,148 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func newCluster(namespace, name string, phase k8splaygroundsv1alpha1.ClusterPhase, health k8splaygroundsv1alpha1.ClusterHealth, lastUpdated time.Time) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status: k8splaygroundsv1alpha1.K8sPlaygroundsClusterStatus{
+			Phase:       phase,
+			Health:      health,
+			LastUpdated: metav1.NewTime(lastUpdated),
+		},
+	}
+}
+
+// clusterReader lists a fixed set of clusters
+type clusterReader []k8splaygroundsv1alpha1.K8sPlaygroundsCluster
+
+func (r clusterReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return fmt.Errorf("not implemented")
+}
+
+func (r clusterReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	list.(*k8splaygroundsv1alpha1.K8sPlaygroundsClusterList).Items = r
+	return nil
+}
+
+func TestBuildAndSummarize(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	failing := newCluster("team-b", "db", k8splaygroundsv1alpha1.ClusterPhaseFailed, k8splaygroundsv1alpha1.ClusterHealthUnhealthy, now.Add(-time.Minute))
+	failing.Status.ReconcileErrors = 3
+	failing.Status.LastReconcileError = "statefulset db: quota exceeded"
+	clusters := []k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		*failing,
+		*newCluster("team-a", "web", k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterHealthHealthy, now.Add(-time.Minute)),
+		*newCluster("team-a", "old", k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterHealthHealthy, now.Add(-time.Hour)),
+		{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "team-c"}},
+	}
+
+	inventory := Build(clusters, now)
+	if len(inventory.Clusters) != 4 || inventory.Clusters[0].Name != "old" || inventory.Clusters[3].Name != "new" {
+		t.Fatalf("clusters = %+v, want them sorted by namespace and name", inventory.Clusters)
+	}
+	if !inventory.Clusters[0].Stale || inventory.Clusters[1].Stale {
+		t.Errorf("stale = %t, %t, want only the cluster not reconciled for an hour stale", inventory.Clusters[0].Stale, inventory.Clusters[1].Stale)
+	}
+	if pending := inventory.Clusters[3]; pending.Phase != "Pending" || pending.Health != "Unknown" || pending.LastReconcileTime != nil {
+		t.Errorf("new cluster = %+v, want Pending, Unknown and never reconciled", pending)
+	}
+
+	summary := Summarize(inventory)
+	if summary.Status != StatusDegraded || summary.ReconcileErrors != 3 || summary.Phases["Running"] != 2 {
+		t.Errorf("summary = %+v, want degraded with 3 errors and 2 running clusters", summary)
+	}
+	if len(summary.Failing) != 2 || summary.Failing[0] != "team-a/old" || summary.Failing[1] != "team-b/db" {
+		t.Errorf("failing = %v, want the stale and the failed cluster", summary.Failing)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	cluster := newCluster("team-a", "web", k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterHealthHealthy, time.Now())
+	reader := clusterReader{*cluster}
+
+	tests := map[string]struct {
+		handler http.Handler
+		auth    string
+		code    int
+	}{
+		"no token":      {Handler(reader, "secret"), "", http.StatusUnauthorized},
+		"wrong token":   {Handler(reader, "secret"), "Bearer guess", http.StatusUnauthorized},
+		"unset token":   {Handler(reader, ""), "Bearer ", http.StatusUnauthorized},
+		"inventory":     {Handler(reader, "secret"), "Bearer secret", http.StatusOK},
+		"healthy fleet": {SummaryHandler(reader, "secret"), "Bearer secret", http.StatusOK},
+	}
+	for name, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/inventory", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		tt.handler.ServeHTTP(rec, req)
+		if rec.Code != tt.code {
+			t.Errorf("%s: code = %d, want %d", name, rec.Code, tt.code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/inventory", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	Handler(reader, "secret").ServeHTTP(rec, req)
+	var inventory Inventory
+	if err := json.Unmarshal(rec.Body.Bytes(), &inventory); err != nil {
+		t.Fatalf("inventory is not valid JSON: %v", err)
+	}
+	if len(inventory.Clusters) != 1 || inventory.Clusters[0].Name != "web" || inventory.Clusters[0].Phase != "Running" {
+		t.Errorf("inventory = %+v, want the running cluster", inventory)
+	}
+}
+
+func TestCollector(t *testing.T) {
+	now := time.Now()
+	reader := clusterReader{
+		*newCluster("team-a", "web", k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterHealthHealthy, now),
+		*newCluster("team-a", "api", k8splaygroundsv1alpha1.ClusterPhaseRunning, k8splaygroundsv1alpha1.ClusterHealthHealthy, now),
+		*newCluster("team-b", "db", k8splaygroundsv1alpha1.ClusterPhaseFailed, k8splaygroundsv1alpha1.ClusterHealthUnhealthy, now),
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCollector(reader))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	got := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range metric.GetLabel() {
+				key += "/" + label.GetValue()
+			}
+			got[key] = metric.GetGauge().GetValue()
+		}
+	}
+	want := map[string]float64{
+		"k8s_playgrounds_inventory_clusters/Healthy/Running":  2,
+		"k8s_playgrounds_inventory_clusters/Unhealthy/Failed": 1,
+		"k8s_playgrounds_inventory_failing_clusters":          1,
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %v, want %v (all: %v)", key, got[key], value, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package inventory
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// collectTimeout bounds the cache read of a scrape
+const collectTimeout = 5 * time.Second
+
+var (
+	clustersDesc = prometheus.NewDesc(
+		"k8s_playgrounds_inventory_clusters",
+		"Number of managed clusters by phase and health, read from the cache of the answering replica",
+		[]string{"phase", "health"}, nil,
+	)
+	failingDesc = prometheus.NewDesc(
+		"k8s_playgrounds_inventory_failing_clusters",
+		"Number of managed clusters the health summary lists as failing",
+		nil, nil,
+	)
+)
+
+// Collector exports the inventory as metrics. It reads the clusters on every scrape, so a
+// standby replica reports them as well as the leader whose reconciles update other metrics.
+type Collector struct {
+	reader client.Reader
+}
+
+// NewCollector creates a collector reading clusters with reader, normally the cached client.
+// Register it with the metrics registry.
+func NewCollector(reader client.Reader) *Collector {
+	return &Collector{reader: reader}
+}
+
+// Describe sends the descriptors of the inventory metrics
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- clustersDesc
+	ch <- failingDesc
+}
+
+// Collect counts the clusters by phase and health
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	clusters := &k8splaygroundsv1alpha1.K8sPlaygroundsClusterList{}
+	if err := c.reader.List(ctx, clusters); err != nil {
+		ch <- prometheus.NewInvalidMetric(clustersDesc, err)
+		return
+	}
+
+	inventory := Build(clusters.Items, time.Now())
+	counts := map[[2]string]int{}
+	for _, cluster := range inventory.Clusters {
+		counts[[2]string{cluster.Phase, cluster.Health}]++
+	}
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(clustersDesc, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+	ch <- prometheus.MustNewConstMetric(failingDesc, prometheus.GaugeValue, float64(len(Summarize(inventory).Failing)))
+}
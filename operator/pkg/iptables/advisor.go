@@ -0,0 +1,222 @@
+package iptables
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/loadtest"
+)
+
+// Load balancing algorithms of the iptables proxy
+const (
+	AlgorithmRandom           = "random"
+	AlgorithmRoundRobin       = "round-robin"
+	AlgorithmLeastConnections = "least-connections"
+)
+
+const (
+	// DefaultAdvisorMinRequests is how many observed requests a recommendation needs
+	DefaultAdvisorMinRequests = 1000
+
+	// Past these thresholds endpoints differ enough in latency that requests pile up on the
+	// slow ones, which least-connections avoids
+	maxLatencySpreadPercent = 25
+	maxTailRatioPercent     = 500
+	// maxConnectionImbalancePercent bounds the busiest endpoint's open connections relative
+	// to the mean
+	maxConnectionImbalancePercent = 150
+	// maxSkewPercent bounds the request spread of uniform endpoints before round-robin is
+	// recommended to even it out
+	maxSkewPercent = 20
+)
+
+// Observation is the traffic one endpoint served
+type Observation struct {
+	Endpoint   string
+	Requests   int64
+	LatencyP50 time.Duration
+	LatencyP99 time.Duration
+	// ActiveConnections are the open connections of the endpoint; 0 when not observed
+	ActiveConnections int64
+}
+
+// Recommendation is the algorithm the observations call for and why
+type Recommendation struct {
+	// Algorithm is empty when the observations are too few to recommend one
+	Algorithm            string
+	Rationale            string
+	Requests             int64
+	LatencySpreadPercent int32
+	TailRatioPercent     int32
+	SkewPercent          int32
+}
+
+// ObservationsFromLoadTest returns the per-endpoint observations of a finished load test, or
+// nil while no run has succeeded
+func ObservationsFromLoadTest(status *k8splaygroundsv1alpha1.LoadTestStatus) []Observation {
+	if status == nil || status.Phase != loadtest.PhaseSucceeded {
+		return nil
+	}
+	observations := make([]Observation, 0, len(status.Backends))
+	for _, backend := range status.Backends {
+		observations = append(observations, Observation{
+			Endpoint:   backend.Backend,
+			Requests:   backend.Requests,
+			LatencyP50: time.Duration(backend.LatencyP50Micros) * time.Microsecond,
+			LatencyP99: time.Duration(backend.LatencyP99Micros) * time.Microsecond,
+		})
+	}
+	return observations
+}
+
+// Evaluate recommends a load balancing algorithm for the observed traffic. Endpoints that differ
+// in latency, have long latency tails or hold uneven connections call for least-connections;
+// uniform endpoints with an uneven request spread call for round-robin; otherwise the current
+// algorithm is kept. The spread is not judged with session affinity, which pins clients.
+func Evaluate(current string, observations []Observation, minRequests int64, sessionAffinity bool) Recommendation {
+	if current == "" {
+		current = AlgorithmRandom
+	}
+	if minRequests <= 0 {
+		minRequests = DefaultAdvisorMinRequests
+	}
+
+	recommendation := Recommendation{}
+	var requests, connections []float64
+	var p50s []float64
+	var tailRatios []float64
+	for _, observation := range observations {
+		recommendation.Requests += observation.Requests
+		requests = append(requests, float64(observation.Requests))
+		connections = append(connections, float64(observation.ActiveConnections))
+		if observation.LatencyP50 > 0 {
+			p50s = append(p50s, float64(observation.LatencyP50))
+			if observation.LatencyP99 > 0 {
+				tailRatios = append(tailRatios, float64(observation.LatencyP99)/float64(observation.LatencyP50))
+			}
+		}
+	}
+	if len(observations) < 2 {
+		recommendation.Rationale = fmt.Sprintf("%d endpoints observed; at least 2 are needed to compare algorithms", len(observations))
+		return recommendation
+	}
+	if recommendation.Requests < minRequests {
+		recommendation.Rationale = fmt.Sprintf("%d requests observed; at least %d are needed", recommendation.Requests, minRequests)
+		return recommendation
+	}
+
+	mean, stddev := meanStddev(p50s)
+	if mean > 0 {
+		recommendation.LatencySpreadPercent = int32(math.Round(stddev / mean * 100))
+	}
+	if tail, _ := meanStddev(tailRatios); tail > 0 {
+		recommendation.TailRatioPercent = int32(math.Round(tail * 100))
+	}
+	recommendation.SkewPercent = int32(math.Round(spreadPercent(requests)))
+	connectionImbalance := 0.0
+	if mean, _ := meanStddev(connections); mean > 0 {
+		connectionImbalance = maxOf(connections) / mean * 100
+	}
+
+	switch {
+	case recommendation.LatencySpreadPercent > maxLatencySpreadPercent:
+		recommendation.Algorithm = AlgorithmLeastConnections
+		recommendation.Rationale = fmt.Sprintf("median latencies of the endpoints vary by %d%% (over %d%%); least-connections keeps requests off the slow endpoints",
+			recommendation.LatencySpreadPercent, maxLatencySpreadPercent)
+	case recommendation.TailRatioPercent > maxTailRatioPercent:
+		recommendation.Algorithm = AlgorithmLeastConnections
+		recommendation.Rationale = fmt.Sprintf("p99 latency is %.1fx the median (over %.1fx); least-connections avoids queueing behind long requests",
+			float64(recommendation.TailRatioPercent)/100, float64(maxTailRatioPercent)/100)
+	case connectionImbalance > maxConnectionImbalancePercent:
+		recommendation.Algorithm = AlgorithmLeastConnections
+		recommendation.Rationale = fmt.Sprintf("the busiest endpoint holds %.0f%% of the mean open connections (over %d%%); least-connections balances them",
+			connectionImbalance, maxConnectionImbalancePercent)
+	case !sessionAffinity && recommendation.SkewPercent > maxSkewPercent:
+		recommendation.Algorithm = AlgorithmRoundRobin
+		recommendation.Rationale = fmt.Sprintf("endpoints respond alike but requests are spread %d%% unevenly (over %d%%); round-robin spreads them evenly",
+			recommendation.SkewPercent, maxSkewPercent)
+	default:
+		recommendation.Algorithm = current
+		recommendation.Rationale = fmt.Sprintf("endpoints respond alike and requests are spread evenly; %s suits the traffic", current)
+	}
+	return recommendation
+}
+
+// Advise evaluates the observations of the last load test of a headless service, records the
+// recommendation in its status and returns the algorithm the proxy should run: the recommended
+// one with autoSwitch, the configured one otherwise. Without an advisor the status is cleared.
+func Advise(headlessService *k8splaygroundsv1alpha1.HeadlessService, now time.Time) string {
+	proxy := headlessService.Spec.IptablesProxy
+	current := proxy.LoadBalancingAlgorithm
+	if proxy.AlgorithmAdvisor == nil {
+		headlessService.Status.AlgorithmRecommendation = nil
+		return current
+	}
+
+	status := &k8splaygroundsv1alpha1.AlgorithmRecommendationStatus{Current: current, EvaluatedAt: metav1.NewTime(now)}
+	if loadTest := headlessService.Status.LoadTest; loadTest != nil {
+		status.Source = "loadTest/" + loadTest.RunID
+	}
+	recommendation := Evaluate(current, ObservationsFromLoadTest(headlessService.Status.LoadTest), proxy.AlgorithmAdvisor.MinRequests, proxy.SessionAffinity)
+	status.Recommended = recommendation.Algorithm
+	status.Rationale = recommendation.Rationale
+	status.Requests = recommendation.Requests
+	status.LatencySpreadPercent = recommendation.LatencySpreadPercent
+	status.TailRatioPercent = recommendation.TailRatioPercent
+	status.SkewPercent = recommendation.SkewPercent
+
+	// Keep the evaluation time while nothing changed, so the status is not rewritten every reconcile
+	if previous := headlessService.Status.AlgorithmRecommendation; previous != nil && previous.Recommended == status.Recommended &&
+		previous.Source == status.Source && previous.Current == status.Current {
+		status.EvaluatedAt = previous.EvaluatedAt
+	}
+
+	algorithm := current
+	if proxy.AlgorithmAdvisor.AutoSwitch && recommendation.Algorithm != "" {
+		algorithm = recommendation.Algorithm
+		status.Applied = algorithm != current
+	}
+	headlessService.Status.AlgorithmRecommendation = status
+	return algorithm
+}
+
+func meanStddev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// spreadPercent returns the distance between the largest and smallest value relative to the mean
+func spreadPercent(values []float64) float64 {
+	mean, _ := meanStddev(values)
+	if mean == 0 {
+		return 0
+	}
+	low := values[0]
+	for _, v := range values {
+		low = math.Min(low, v)
+	}
+	return (maxOf(values) - low) / mean * 100
+}
+
+func maxOf(values []float64) float64 {
+	high := 0.0
+	for _, v := range values {
+		high = math.Max(high, v)
+	}
+	return high
+}
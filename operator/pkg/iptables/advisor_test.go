@@ -0,0 +1,91 @@
+package iptables
+
+import (
+	"testing"
+	"time"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func observe(requests ...int64) []Observation {
+	var observations []Observation
+	for i, n := range requests {
+		observations = append(observations, Observation{
+			Endpoint:   string(rune('a' + i)),
+			Requests:   n,
+			LatencyP50: 10 * time.Millisecond,
+			LatencyP99: 20 * time.Millisecond,
+		})
+	}
+	return observations
+}
+
+func TestEvaluate(t *testing.T) {
+	if recommendation := Evaluate("random", observe(500, 510, 490), 0, false); recommendation.Algorithm != AlgorithmRandom {
+		t.Errorf("even uniform traffic = %+v, want the current algorithm kept", recommendation)
+	}
+
+	skewed := Evaluate("random", observe(800, 400, 300), 0, false)
+	if skewed.Algorithm != AlgorithmRoundRobin || skewed.SkewPercent != 100 {
+		t.Errorf("skewed traffic = %+v, want round-robin for a 100%% skew", skewed)
+	}
+	if withAffinity := Evaluate("random", observe(800, 400, 300), 0, true); withAffinity.Algorithm != AlgorithmRandom {
+		t.Errorf("skewed traffic with session affinity = %+v, want the current algorithm kept", withAffinity)
+	}
+
+	slow := observe(500, 500, 500)
+	slow[2].LatencyP50 = 40 * time.Millisecond
+	if recommendation := Evaluate("round-robin", slow, 0, false); recommendation.Algorithm != AlgorithmLeastConnections || recommendation.LatencySpreadPercent <= maxLatencySpreadPercent {
+		t.Errorf("one slow endpoint = %+v, want least-connections", recommendation)
+	}
+
+	tail := observe(500, 500, 500)
+	for i := range tail {
+		tail[i].LatencyP99 = 80 * time.Millisecond
+	}
+	if recommendation := Evaluate("random", tail, 0, false); recommendation.Algorithm != AlgorithmLeastConnections || recommendation.TailRatioPercent != 800 {
+		t.Errorf("long latency tail = %+v, want least-connections", recommendation)
+	}
+
+	connections := observe(500, 500, 500)
+	connections[0].ActiveConnections, connections[1].ActiveConnections, connections[2].ActiveConnections = 90, 5, 5
+	if recommendation := Evaluate("random", connections, 0, false); recommendation.Algorithm != AlgorithmLeastConnections {
+		t.Errorf("uneven connections = %+v, want least-connections", recommendation)
+	}
+
+	if recommendation := Evaluate("random", observe(50, 50), 0, false); recommendation.Algorithm != "" || recommendation.Rationale == "" {
+		t.Errorf("too few requests = %+v, want no recommendation with a rationale", recommendation)
+	}
+}
+
+func TestAdvise(t *testing.T) {
+	headlessService := renderService("random")
+	headlessService.Status.LoadTest = &k8splaygroundsv1alpha1.LoadTestStatus{
+		RunID: "7",
+		Phase: "Succeeded",
+		Backends: []k8splaygroundsv1alpha1.BackendLoad{
+			{Backend: "10.0.0.1", Requests: 800, LatencyP50Micros: 1000, LatencyP99Micros: 2000},
+			{Backend: "10.0.0.2", Requests: 300, LatencyP50Micros: 1000, LatencyP99Micros: 2000},
+		},
+	}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	if algorithm := Advise(headlessService, now); algorithm != "random" || headlessService.Status.AlgorithmRecommendation != nil {
+		t.Errorf("Advise() without advisor = %q, %+v", algorithm, headlessService.Status.AlgorithmRecommendation)
+	}
+
+	headlessService.Spec.IptablesProxy.AlgorithmAdvisor = &k8splaygroundsv1alpha1.AlgorithmAdvisorSpec{}
+	algorithm := Advise(headlessService, now)
+	status := headlessService.Status.AlgorithmRecommendation
+	if algorithm != "random" || status == nil || status.Recommended != AlgorithmRoundRobin || status.Applied || status.Source != "loadTest/7" {
+		t.Errorf("Advise() = %q, %+v, want round-robin recommended but not applied", algorithm, status)
+	}
+
+	headlessService.Spec.IptablesProxy.AlgorithmAdvisor.AutoSwitch = true
+	if algorithm := Advise(headlessService, now.Add(time.Minute)); algorithm != AlgorithmRoundRobin || !headlessService.Status.AlgorithmRecommendation.Applied {
+		t.Errorf("Advise() with autoSwitch = %q, %+v, want round-robin applied", algorithm, headlessService.Status.AlgorithmRecommendation)
+	}
+	if evaluated := headlessService.Status.AlgorithmRecommendation.EvaluatedAt; !evaluated.Time.Equal(now) {
+		t.Errorf("evaluatedAt = %v, want it kept while the recommendation is unchanged", evaluated)
+	}
+}
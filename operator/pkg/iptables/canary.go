@@ -0,0 +1,478 @@
+package iptables
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apis/conditions"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/dns"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Rollout strategies of the proxy rules
+const (
+	// RolloutAll applies new rules on every node at once
+	RolloutAll = "all"
+	// RolloutCanary applies new rules on one node and probes the service through it before the
+	// other nodes get them
+	RolloutCanary = "canary"
+)
+
+const (
+	// CanaryResultAnnotation is set by the canary pod to CanaryPassed or CanaryFailed
+	CanaryResultAnnotation = "k8s-playgrounds.io/canary-result"
+	// CanaryOutputAnnotation is set by the canary pod to the outcome of every probe
+	CanaryOutputAnnotation = "k8s-playgrounds.io/canary-output"
+
+	// Results reported by the canary pod
+	CanaryPassed = "passed"
+	CanaryFailed = "failed"
+
+	// Phases of a canary in the headless service status
+	CanaryPhaseProbing = "Probing"
+	CanaryPhaseFailed  = "Failed"
+
+	// ReasonCanaryFailed is the reason of the Degraded condition while the canary rejected the
+	// desired rules
+	ReasonCanaryFailed = conditions.ReasonCanaryFailed
+
+	// DefaultProbeTimeout is how long the canary may take to apply the rules and probe
+	DefaultProbeTimeout = 2 * time.Minute
+
+	// canaryAppName labels the canary objects, which must not match the proxy DaemonSets
+	canaryAppName = "headless-service-iptables-canary"
+
+	// probeTimeoutSeconds bounds a single connection attempt of the canary
+	probeTimeoutSeconds = 5
+)
+
+// canaryRollout reports whether new rules are verified on a canary node first
+func canaryRollout(spec *k8splaygroundsv1alpha1.IptablesProxySpec) bool {
+	return spec.Rollout != nil && spec.Rollout.Strategy == RolloutCanary
+}
+
+// validateRollout rejects unknown rollout strategies
+func validateRollout(spec *k8splaygroundsv1alpha1.IptablesProxySpec) error {
+	if spec.Rollout == nil {
+		return nil
+	}
+	switch spec.Rollout.Strategy {
+	case "", RolloutAll, RolloutCanary:
+		return nil
+	default:
+		return fmt.Errorf("unknown rollout strategy %q", spec.Rollout.Strategy)
+	}
+}
+
+// ProbeTimeout returns how long the canary may take before it counts as failed
+func ProbeTimeout(spec *k8splaygroundsv1alpha1.IptablesProxySpec) time.Duration {
+	if spec == nil || spec.Rollout == nil || spec.Rollout.ProbeTimeoutSeconds <= 0 {
+		return DefaultProbeTimeout
+	}
+	return time.Duration(spec.Rollout.ProbeTimeoutSeconds) * time.Second
+}
+
+// rolloutCanary moves a node group to new rules through a canary node. The node group keeps
+// the rules of its ConfigMap, which are the last verified ones, while a canary pod applies the
+// new rules on one node taken out of the DaemonSet and probes the service. Passing probes
+// promote the rules to the ConfigMap; failing ones put the node back into the DaemonSet, which
+// reapplies the verified rules, and the new rules are not retried until they change again.
+func (m *Manager) rolloutCanary(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, script string) error {
+	log := logr.FromContextOrDiscard(ctx)
+	desired := RulesHash(script)
+
+	configMap := &corev1.ConfigMap{}
+	err := m.client.Get(ctx, types.NamespacedName{Namespace: headlessService.Namespace, Name: configMapName(headlessService, group)}, configMap)
+	if apierrors.IsNotFound(err) {
+		// Nothing runs yet, so there is nothing a canary could protect
+		return m.promote(ctx, headlessService, group, script)
+	}
+	if err != nil {
+		return err
+	}
+	current := configMap.Annotations[RulesHashAnnotation]
+
+	status := canaryStatus(headlessService, group.name)
+	switch {
+	case current == desired:
+		return m.promote(ctx, headlessService, group, script)
+	case status != nil && status.Phase == CanaryPhaseFailed && status.RulesHash == desired:
+		// The canary rejected these rules already; keep the verified ones
+		if err := m.deleteCanary(ctx, headlessService, group); err != nil {
+			return err
+		}
+		return m.createIptablesDaemonSet(ctx, headlessService, group, current, "")
+	}
+
+	node, err := m.canaryNode(ctx, headlessService, group, status)
+	if err != nil {
+		return err
+	}
+	if node == "" {
+		// No node runs the proxy of the group yet
+		return m.promote(ctx, headlessService, group, script)
+	}
+	if status == nil || status.RulesHash != desired || status.Node != node {
+		now := metav1.Now()
+		status = &k8splaygroundsv1alpha1.RulesCanaryStatus{NodeGroup: group.name, Node: node, RulesHash: desired, Phase: CanaryPhaseProbing, StartedAt: &now}
+		setCanaryStatus(headlessService, *status)
+	}
+
+	pod, err := m.ensureCanary(ctx, headlessService, group, script, desired, node)
+	if err != nil {
+		return err
+	}
+	result := ""
+	output := ""
+	if pod != nil {
+		result = pod.Annotations[CanaryResultAnnotation]
+		output = pod.Annotations[CanaryOutputAnnotation]
+	}
+	if result == "" && status.StartedAt != nil && time.Since(status.StartedAt.Time) > ProbeTimeout(headlessService.Spec.IptablesProxy) {
+		result = CanaryFailed
+		output = fmt.Sprintf("no probe result within %s", ProbeTimeout(headlessService.Spec.IptablesProxy))
+	}
+
+	switch result {
+	case CanaryPassed:
+		log.Info("canary verified iptables rules, rolling out to all nodes", "nodeGroup", group.name, "node", node)
+		return m.promote(ctx, headlessService, group, script)
+	case CanaryFailed:
+		log.Info("canary rejected iptables rules, keeping the verified rules", "nodeGroup", group.name, "node", node, "output", output)
+		status.Phase = CanaryPhaseFailed
+		status.Output = output
+		setCanaryStatus(headlessService, *status)
+		if err := m.deleteCanary(ctx, headlessService, group); err != nil {
+			return err
+		}
+		return m.createIptablesDaemonSet(ctx, headlessService, group, current, "")
+	default:
+		// Keep the fleet on the verified rules and off the canary node until the probes finish
+		return m.createIptablesDaemonSet(ctx, headlessService, group, current, node)
+	}
+}
+
+// promote applies rules on every node of a node group and removes its canary
+func (m *Manager) promote(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, script string) error {
+	if err := m.createIptablesConfigMap(ctx, headlessService, group, script); err != nil {
+		return fmt.Errorf("failed to create iptables ConfigMap: %w", err)
+	}
+	if err := m.createIptablesDaemonSet(ctx, headlessService, group, RulesHash(script), ""); err != nil {
+		return fmt.Errorf("failed to create iptables DaemonSet: %w", err)
+	}
+	clearCanaryStatus(headlessService, group.name)
+	return m.deleteCanary(ctx, headlessService, group)
+}
+
+// canaryNode returns the node verifying new rules: the configured one, the node of a canary in
+// progress, or else the first node running the proxy of the group. It is empty when no node
+// runs the proxy.
+func (m *Manager) canaryNode(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, status *k8splaygroundsv1alpha1.RulesCanaryStatus) (string, error) {
+	if node := headlessService.Spec.IptablesProxy.Rollout.CanaryNode; node != "" {
+		return node, nil
+	}
+	if status != nil && status.Phase == CanaryPhaseProbing && status.Node != "" {
+		return status.Node, nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := m.client.List(ctx, pods, client.InNamespace(headlessService.Namespace), client.MatchingLabels(proxyLabels(headlessService, group))); err != nil {
+		return "", fmt.Errorf("failed to list iptables pods: %w", err)
+	}
+	var nodes []string
+	for _, pod := range pods.Items {
+		if pod.Labels[nodeGroupLabel] == group.name && pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil {
+			nodes = append(nodes, pod.Spec.NodeName)
+		}
+	}
+	if len(nodes) == 0 {
+		return "", nil
+	}
+	sort.Strings(nodes)
+	return nodes[0], nil
+}
+
+// ensureCanary creates the ConfigMap and pod applying the rules on the canary node. A pod for
+// other rules or another node is deleted first; the pod is returned once it runs the rules.
+func (m *Manager) ensureCanary(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, script, rulesHash, node string) (*corev1.Pod, error) {
+	name := canaryName(headlessService, group)
+	labels := canaryLabels(headlessService, group)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       headlessService.Namespace,
+			Labels:          labels,
+			Annotations:     map[string]string{RulesHashAnnotation: rulesHash},
+			OwnerReferences: []metav1.OwnerReference{ownerReference(headlessService)},
+		},
+		Data: map[string]string{"rules.sh": script},
+	}
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return nil, fmt.Errorf("failed to create canary ConfigMap: %w", err)
+	}
+
+	pod := &corev1.Pod{}
+	err := m.client.Get(ctx, types.NamespacedName{Namespace: headlessService.Namespace, Name: name}, pod)
+	if err == nil {
+		if pod.Annotations[RulesHashAnnotation] == rulesHash && pod.Spec.NodeName == node {
+			return pod, nil
+		}
+		if err := m.client.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	helperPods, err := m.helperPods.For(headlessService)
+	if err != nil {
+		return nil, err
+	}
+	if err := helperPods.Reserve(ctx, m.client, headlessService.Namespace); err != nil {
+		return nil, err
+	}
+	pod = &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       headlessService.Namespace,
+			Labels:          labels,
+			Annotations:     map[string]string{RulesHashAnnotation: rulesHash},
+			OwnerReferences: []metav1.OwnerReference{ownerReference(headlessService)},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:           node,
+			ServiceAccountName: agentName(headlessService),
+			RestartPolicy:      corev1.RestartPolicyNever,
+			HostNetwork:        true,
+			// The canary node was picked by the operator, so taints must not keep the pod off it
+			Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{
+				{
+					Name:    "iptables-canary",
+					Image:   "alpine:3.18",
+					Command: []string{"/bin/sh"},
+					Args:    []string{"-c", canaryScript(headlessService)},
+					Env: []corev1.EnvVar{
+						{
+							Name:      "POD_NAME",
+							ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+						},
+						{
+							Name:      "POD_NAMESPACE",
+							ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "iptables-rules",
+							MountPath: "/iptables-rules",
+							ReadOnly:  true,
+						},
+					},
+					SecurityContext: &corev1.SecurityContext{
+						Privileged: &[]bool{true}[0],
+						Capabilities: &corev1.Capabilities{
+							Add: []corev1.Capability{"NET_ADMIN"},
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "iptables-rules",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: name},
+						},
+					},
+				},
+			},
+		},
+	}
+	helperPods.Apply(&pod.ObjectMeta, &pod.Spec)
+	// The pod reports the applied rules in annotations of its own, which the apply leaves alone
+	if err := apply.Apply(ctx, m.client, pod); err != nil {
+		return nil, fmt.Errorf("failed to create canary pod: %w", err)
+	}
+	return pod, nil
+}
+
+// canaryScript applies the rules, probes every TCP port of the service through them and
+// reports the result on the canary pod
+func canaryScript(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	var targets []string
+	for _, port := range headlessService.Spec.Ports {
+		if port.Protocol != "" && !strings.EqualFold(port.Protocol, string(corev1.ProtocolTCP)) {
+			continue
+		}
+		targets = append(targets, fmt.Sprintf("%s.%s.svc.%s:%d", headlessService.Name, headlessService.Namespace, dns.ClusterDomain(headlessService), port.Port))
+	}
+
+	return `RESULT=failed; OUTPUT="rules could not be applied"; ` +
+		`if apk add --no-cache ` + proxyPackage(headlessService.Spec.IptablesProxy) + ` curl netcat-openbsd && sh /iptables-rules/rules.sh; then ` +
+		`RESULT=passed; OUTPUT=""; ` +
+		`for TARGET in ` + strings.Join(targets, " ") + `; do ` +
+		fmt.Sprintf(`if nc -z -w %d ${TARGET%%:*} ${TARGET##*:}; then OUTPUT="$OUTPUT$TARGET ok; "; `, probeTimeoutSeconds) +
+		`else OUTPUT="$OUTPUT$TARGET unreachable; "; RESULT=failed; fi; done; fi; ` +
+		`SA=/var/run/secrets/kubernetes.io/serviceaccount && ` +
+		`curl -sSf --cacert $SA/ca.crt -H "Authorization: Bearer $(cat $SA/token)" ` +
+		`-H "Content-Type: application/merge-patch+json" -X PATCH ` +
+		`-d "{\"metadata\":{\"annotations\":{\"` + CanaryResultAnnotation + `\":\"$RESULT\",\"` + CanaryOutputAnnotation + `\":\"$OUTPUT\"}}}" ` +
+		`https://kubernetes.default.svc/api/v1/namespaces/$POD_NAMESPACE/pods/$POD_NAME >/dev/null`
+}
+
+// deleteCanary deletes the canary pod and ConfigMap of a node group
+func (m *Manager) deleteCanary(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup) error {
+	name := canaryName(headlessService, group)
+	for _, obj := range []client.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}},
+	} {
+		if err := m.client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete canary: %w", err)
+		}
+	}
+	return nil
+}
+
+// deleteCanaries deletes the canaries of a headless service, except those of the node groups
+// named in keep
+func (m *Manager) deleteCanaries(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, keep map[string]bool) error {
+	selector := client.MatchingLabels{
+		"app.kubernetes.io/name":     canaryAppName,
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+	namespace := client.InNamespace(headlessService.Namespace)
+
+	pods := &corev1.PodList{}
+	if err := m.client.List(ctx, pods, selector, namespace); err != nil {
+		return err
+	}
+	configMaps := &corev1.ConfigMapList{}
+	if err := m.client.List(ctx, configMaps, selector, namespace); err != nil {
+		return err
+	}
+	var objects []client.Object
+	for i := range pods.Items {
+		objects = append(objects, &pods.Items[i])
+	}
+	for i := range configMaps.Items {
+		objects = append(objects, &configMaps.Items[i])
+	}
+	for _, obj := range objects {
+		if keep[obj.GetLabels()[nodeGroupLabel]] {
+			continue
+		}
+		if err := m.client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	kept := headlessService.Status.Canaries[:0]
+	for _, canary := range headlessService.Status.Canaries {
+		if keep[canary.NodeGroup] {
+			kept = append(kept, canary)
+		}
+	}
+	if len(kept) == 0 {
+		kept = nil
+	}
+	headlessService.Status.Canaries = kept
+	return nil
+}
+
+// canaryName returns the name of the canary pod and ConfigMap of a node group
+func canaryName(headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup) string {
+	if group.name == "" {
+		return naming.Name(naming.IPTablesCanary, headlessService.Name)
+	}
+	return naming.Qualified(naming.IPTablesCanaryGroup, headlessService.Name, group.name)
+}
+
+// canaryLabels returns the labels of the canary objects of a node group
+func canaryLabels(headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup) map[string]string {
+	labels := map[string]string{
+		"app.kubernetes.io/name":     canaryAppName,
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+	if group.name != "" {
+		labels[nodeGroupLabel] = group.name
+	}
+	return labels
+}
+
+// canaryStatus returns the canary of a node group in the status, or nil
+func canaryStatus(headlessService *k8splaygroundsv1alpha1.HeadlessService, group string) *k8splaygroundsv1alpha1.RulesCanaryStatus {
+	for i := range headlessService.Status.Canaries {
+		if headlessService.Status.Canaries[i].NodeGroup == group {
+			canary := headlessService.Status.Canaries[i]
+			return &canary
+		}
+	}
+	return nil
+}
+
+// setCanaryStatus records the canary of a node group in the status
+func setCanaryStatus(headlessService *k8splaygroundsv1alpha1.HeadlessService, canary k8splaygroundsv1alpha1.RulesCanaryStatus) {
+	for i := range headlessService.Status.Canaries {
+		if headlessService.Status.Canaries[i].NodeGroup == canary.NodeGroup {
+			headlessService.Status.Canaries[i] = canary
+			return
+		}
+	}
+	headlessService.Status.Canaries = append(headlessService.Status.Canaries, canary)
+}
+
+// clearCanaryStatus removes the canary of a node group from the status
+func clearCanaryStatus(headlessService *k8splaygroundsv1alpha1.HeadlessService, group string) {
+	var kept []k8splaygroundsv1alpha1.RulesCanaryStatus
+	for _, canary := range headlessService.Status.Canaries {
+		if canary.NodeGroup != group {
+			kept = append(kept, canary)
+		}
+	}
+	headlessService.Status.Canaries = kept
+}
+
+// TrackCanaries sets the Degraded condition with the probe output while a canary rejected the
+// desired rules of a node group. It returns whether a canary failed.
+func TrackCanaries(headlessService *k8splaygroundsv1alpha1.HeadlessService) bool {
+	var failures []string
+	for _, canary := range headlessService.Status.Canaries {
+		if canary.Phase != CanaryPhaseFailed {
+			continue
+		}
+		group := canary.NodeGroup
+		if group == "" {
+			group = "default"
+		}
+		failures = append(failures, fmt.Sprintf("node group %s on node %s: %s", group, canary.Node, strings.TrimSpace(canary.Output)))
+	}
+	if len(failures) == 0 {
+		return false
+	}
+	meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+		Type:               ConditionDegraded,
+		Status:             metav1.ConditionTrue,
+		Reason:             ReasonCanaryFailed,
+		Message:            "Canary rejected the desired iptables rules, nodes keep the previous rules: " + strings.Join(failures, "; "),
+		ObservedGeneration: headlessService.Generation,
+	})
+	return true
+}
@@ -0,0 +1,140 @@
+package iptables
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+)
+
+func endpointPod(name, ip string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", Labels: map[string]string{"app": "web"}},
+		Status:     corev1.PodStatus{PodIP: ip},
+	}
+}
+
+func fleetPod(name, node string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", Labels: proxyLabels(renderService(""), nodeGroup{})},
+		Spec:       corev1.PodSpec{NodeName: node},
+	}
+}
+
+func get(t *testing.T, c client.Client, name string, obj client.Object) bool {
+	t.Helper()
+	err := c.Get(context.Background(), types.NamespacedName{Namespace: "shop", Name: name}, obj)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestCanaryRollout(t *testing.T) {
+	ctx := context.Background()
+	headlessService := renderService("round-robin")
+	headlessService.Spec.Selector = map[string]string{"app": "web"}
+	headlessService.Spec.IptablesProxy.Rollout = &k8splaygroundsv1alpha1.ProxyRolloutSpec{Strategy: RolloutCanary}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(endpointPod("web-0", "10.0.0.1")).WithInterceptorFuncs(applytest.Funcs()).Build()
+	m := NewManager(c, helperpods.Config{MaxPerNamespace: -1})
+
+	// The first rules have nothing to protect and go to every node
+	if err := m.ConfigureHeadlessService(ctx, headlessService); err != nil {
+		t.Fatalf("ConfigureHeadlessService() error = %v", err)
+	}
+	configMap := &corev1.ConfigMap{}
+	get(t, c, "web-iptables-rules", configMap)
+	verified := configMap.Annotations[RulesHashAnnotation]
+	if verified == "" || len(headlessService.Status.Canaries) != 0 {
+		t.Fatalf("first rollout: hash %q, canaries %+v", verified, headlessService.Status.Canaries)
+	}
+
+	// New rules are probed on the first proxy node, which leaves the DaemonSet meanwhile
+	for _, obj := range []client.Object{fleetPod("web-iptables-b", "node-b"), fleetPod("web-iptables-a", "node-a"), endpointPod("web-1", "10.0.0.2")} {
+		if err := c.Create(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.ConfigureHeadlessService(ctx, headlessService); err != nil {
+		t.Fatalf("ConfigureHeadlessService() error = %v", err)
+	}
+	canary := &corev1.Pod{}
+	if !get(t, c, "web-iptables-canary", canary) || canary.Spec.NodeName != "node-a" {
+		t.Fatalf("canary pod = %+v, want one on node-a", canary.Spec)
+	}
+	get(t, c, "web-iptables-rules", configMap)
+	daemonSet := &appsv1.DaemonSet{}
+	get(t, c, "web-iptables", daemonSet)
+	if configMap.Annotations[RulesHashAnnotation] != verified || !strings.Contains(daemonSet.Spec.Template.Spec.Affinity.String(), "Values:[node-a]") {
+		t.Errorf("fleet must keep the verified rules off node-a during the canary")
+	}
+	if len(headlessService.Status.Canaries) != 1 || headlessService.Status.Canaries[0].Phase != CanaryPhaseProbing {
+		t.Errorf("canaries = %+v, want one probing", headlessService.Status.Canaries)
+	}
+
+	// Passing probes roll the rules out to every node
+	canary.Annotations[CanaryResultAnnotation] = CanaryPassed
+	if err := c.Update(ctx, canary); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ConfigureHeadlessService(ctx, headlessService); err != nil {
+		t.Fatalf("ConfigureHeadlessService() error = %v", err)
+	}
+	get(t, c, "web-iptables-rules", configMap)
+	get(t, c, "web-iptables", daemonSet)
+	if configMap.Annotations[RulesHashAnnotation] == verified || strings.Contains(daemonSet.Spec.Template.Spec.Affinity.String(), "node-a") || get(t, c, "web-iptables-canary", &corev1.Pod{}) {
+		t.Errorf("passed canary was not promoted")
+	}
+	verified = configMap.Annotations[RulesHashAnnotation]
+
+	// Failing probes keep the verified rules and mark the service Degraded
+	if err := c.Create(ctx, endpointPod("web-2", "10.0.0.3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.ConfigureHeadlessService(ctx, headlessService); err != nil {
+		t.Fatalf("ConfigureHeadlessService() error = %v", err)
+	}
+	get(t, c, "web-iptables-canary", canary)
+	canary.Annotations[CanaryResultAnnotation] = CanaryFailed
+	canary.Annotations[CanaryOutputAnnotation] = "web.shop.svc.cluster.local:80 unreachable; "
+	if err := c.Update(ctx, canary); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := m.ConfigureHeadlessService(ctx, headlessService); err != nil {
+			t.Fatalf("ConfigureHeadlessService() error = %v", err)
+		}
+	}
+	get(t, c, "web-iptables-rules", configMap)
+	if configMap.Annotations[RulesHashAnnotation] != verified || get(t, c, "web-iptables-canary", &corev1.Pod{}) {
+		t.Errorf("failed canary was not rolled back or was retried")
+	}
+	if !TrackCanaries(headlessService) {
+		t.Fatal("TrackCanaries() = false after a failed canary")
+	}
+	condition := meta.FindStatusCondition(headlessService.Status.Conditions, ConditionDegraded)
+	if condition.Reason != ReasonCanaryFailed || !strings.Contains(condition.Message, "node-a: web.shop.svc.cluster.local:80 unreachable") {
+		t.Errorf("Degraded condition = %+v", condition)
+	}
+
+	// Leaving the canary strategy removes the canary reporting
+	headlessService.Spec.IptablesProxy.Rollout = nil
+	if err := m.ConfigureHeadlessService(ctx, headlessService); err != nil {
+		t.Fatalf("ConfigureHeadlessService() error = %v", err)
+	}
+	if len(headlessService.Status.Canaries) != 0 {
+		t.Errorf("canaries = %+v after leaving the canary strategy", headlessService.Status.Canaries)
+	}
+}
@@ -0,0 +1,119 @@
+package iptables
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apis/conditions"
+)
+
+const (
+	// RulesHashAnnotation records the hash of the desired rules on the rules ConfigMap and the
+	// proxy pod template
+	RulesHashAnnotation = "k8s-playgrounds.io/rules-hash"
+	// AppliedRulesHashAnnotation is set by each proxy pod to the hash of the rules it applied
+	AppliedRulesHashAnnotation = "k8s-playgrounds.io/applied-rules-hash"
+
+	// ConditionDegraded is the standard Degraded condition, set on headless services while
+	// nodes run rules other than the desired ones for longer than the drift threshold
+	ConditionDegraded = conditions.TypeDegraded
+
+	// DefaultDriftThreshold is how long nodes may diverge before the service is Degraded
+	DefaultDriftThreshold = 5 * time.Minute
+)
+
+// RulesHash returns the hash of a rules script as reported by the proxy pods
+func RulesHash(script string) string {
+	sum := sha256.Sum256([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// DivergentNodes returns the sorted names of nodes whose proxy pod has not reported applying
+// the rules its DaemonSet currently wants
+func DivergentNodes(daemonSets []appsv1.DaemonSet, pods []corev1.Pod) []string {
+	desired := make(map[string]string, len(daemonSets))
+	for _, daemonSet := range daemonSets {
+		desired[daemonSet.Labels[nodeGroupLabel]] = daemonSet.Spec.Template.Annotations[RulesHashAnnotation]
+	}
+
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		want, ok := desired[pod.Labels[nodeGroupLabel]]
+		if !ok || want == "" || pod.Annotations[AppliedRulesHashAnnotation] == want {
+			continue
+		}
+		if !seen[pod.Spec.NodeName] {
+			seen[pod.Spec.NodeName] = true
+			nodes = append(nodes, pod.Spec.NodeName)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// TrackDrift records the divergent nodes on the headless service status and sets the Degraded
+// condition once nodes have diverged for longer than threshold. It returns whether the service
+// is degraded.
+func TrackDrift(headlessService *k8splaygroundsv1alpha1.HeadlessService, divergent []string, threshold time.Duration, now time.Time) bool {
+	status := &headlessService.Status
+	if len(divergent) == 0 {
+		status.RulesDrift = nil
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               ConditionDegraded,
+			Status:             metav1.ConditionFalse,
+			Reason:             "RulesInSync",
+			Message:            "All nodes applied the desired iptables rules",
+			ObservedGeneration: headlessService.Generation,
+		})
+		return false
+	}
+
+	since := metav1.NewTime(now)
+	if status.RulesDrift != nil && status.RulesDrift.DivergingSince != nil {
+		since = *status.RulesDrift.DivergingSince
+	}
+	status.RulesDrift = &k8splaygroundsv1alpha1.RulesDriftStatus{
+		DivergentNodes: divergent,
+		DivergingSince: &since,
+	}
+
+	// Nodes briefly diverge during every rollout, so only report lasting drift
+	if now.Sub(since.Time) < threshold {
+		return meta.IsStatusConditionTrue(status.Conditions, ConditionDegraded)
+	}
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               ConditionDegraded,
+		Status:             metav1.ConditionTrue,
+		Reason:             "RulesDiverged",
+		Message:            fmt.Sprintf("%d node(s) have not applied the desired iptables rules since %s", len(divergent), since.UTC().Format(time.RFC3339)),
+		ObservedGeneration: headlessService.Generation,
+	})
+	return true
+}
+
+// ClearDrift removes drift status and the Degraded condition when the proxy is not running
+func ClearDrift(headlessService *k8splaygroundsv1alpha1.HeadlessService) {
+	headlessService.Status.RulesDrift = nil
+	meta.RemoveStatusCondition(&headlessService.Status.Conditions, ConditionDegraded)
+}
+
+// DriftThreshold returns how long nodes may diverge before the service is Degraded
+func DriftThreshold(spec *k8splaygroundsv1alpha1.IptablesProxySpec) time.Duration {
+	if spec == nil || spec.DriftThresholdSeconds <= 0 {
+		return DefaultDriftThreshold
+	}
+	return time.Duration(spec.DriftThresholdSeconds) * time.Second
+}
@@ -0,0 +1,78 @@
+package iptables
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func proxyPod(node, group, applied string) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{nodeGroupLabel: group},
+			Annotations: map[string]string{AppliedRulesHashAnnotation: applied},
+		},
+		Spec: corev1.PodSpec{NodeName: node},
+	}
+}
+
+func TestDivergentNodes(t *testing.T) {
+	daemonSets := []appsv1.DaemonSet{{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{nodeGroupLabel: "arm"}},
+		Spec: appsv1.DaemonSetSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{RulesHashAnnotation: "new"}},
+		}},
+	}}
+	pods := []corev1.Pod{
+		proxyPod("node-c", "arm", "old"),
+		proxyPod("node-a", "arm", "new"),
+		proxyPod("node-b", "arm", ""),
+		proxyPod("", "arm", "old"),
+		proxyPod("node-d", "gpu", "old"),
+	}
+
+	if got, want := DivergentNodes(daemonSets, pods), []string{"node-b", "node-c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DivergentNodes() = %v, want %v", got, want)
+	}
+}
+
+func TestTrackDrift(t *testing.T) {
+	hs := &k8splaygroundsv1alpha1.HeadlessService{}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if TrackDrift(hs, []string{"node-a"}, time.Minute, start) {
+		t.Fatal("expected no degradation before the threshold")
+	}
+	if hs.Status.RulesDrift == nil || !hs.Status.RulesDrift.DivergingSince.Time.Equal(start) {
+		t.Fatalf("expected drift since %v, got %+v", start, hs.Status.RulesDrift)
+	}
+
+	if !TrackDrift(hs, []string{"node-a", "node-b"}, time.Minute, start.Add(2*time.Minute)) {
+		t.Fatal("expected degradation after the threshold")
+	}
+	if !hs.Status.RulesDrift.DivergingSince.Time.Equal(start) {
+		t.Errorf("DivergingSince moved to %v", hs.Status.RulesDrift.DivergingSince)
+	}
+	if !meta.IsStatusConditionTrue(hs.Status.Conditions, ConditionDegraded) {
+		t.Error("expected Degraded condition to be true")
+	}
+
+	if TrackDrift(hs, nil, time.Minute, start.Add(3*time.Minute)) {
+		t.Fatal("expected recovery once nodes are in sync")
+	}
+	if hs.Status.RulesDrift != nil || !meta.IsStatusConditionFalse(hs.Status.Conditions, ConditionDegraded) {
+		t.Errorf("expected drift cleared, got %+v", hs.Status)
+	}
+
+	ClearDrift(hs)
+	if meta.FindStatusCondition(hs.Status.Conditions, ConditionDegraded) != nil {
+		t.Error("expected Degraded condition removed")
+	}
+}
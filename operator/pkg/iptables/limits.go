@@ -0,0 +1,57 @@
+package iptables
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+const (
+	// DefaultMaxEndpointsPerChain is how many endpoints a load balancing chain holds before it
+	// is split into sub-chains
+	DefaultMaxEndpointsPerChain = 64
+
+	// MaxEndpoints is the hard limit of endpoints programmed per port. Endpoints past it get no
+	// traffic from the proxy and the service reports EndpointsTruncated.
+	MaxEndpoints = 4096
+
+	// ConditionEndpointsTruncated is set while a service has more endpoints than MaxEndpoints
+	ConditionEndpointsTruncated = "EndpointsTruncated"
+)
+
+// MaxEndpointsPerChain returns how many endpoints a load balancing chain of the proxy holds
+func MaxEndpointsPerChain(spec *k8splaygroundsv1alpha1.IptablesProxySpec) int {
+	if spec == nil || spec.MaxEndpointsPerChain < 2 {
+		return DefaultMaxEndpointsPerChain
+	}
+	return int(spec.MaxEndpointsPerChain)
+}
+
+// validateMaxEndpointsPerChain rejects chain sizes that cannot be split into sub-chains
+func validateMaxEndpointsPerChain(spec *k8splaygroundsv1alpha1.IptablesProxySpec) error {
+	if spec.MaxEndpointsPerChain < 0 || spec.MaxEndpointsPerChain == 1 {
+		return fmt.Errorf("maxEndpointsPerChain must be at least 2, got %d", spec.MaxEndpointsPerChain)
+	}
+	return nil
+}
+
+// TrackEndpointLimit sets the EndpointsTruncated condition while the service has more than
+// MaxEndpoints endpoints and clears it otherwise. It returns whether endpoints were left out.
+func TrackEndpointLimit(headlessService *k8splaygroundsv1alpha1.HeadlessService, endpoints int) bool {
+	conditions := &headlessService.Status.Conditions
+	if endpoints <= MaxEndpoints {
+		meta.RemoveStatusCondition(conditions, ConditionEndpointsTruncated)
+		return false
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               ConditionEndpointsTruncated,
+		Status:             metav1.ConditionTrue,
+		Reason:             "TooManyEndpoints",
+		Message:            fmt.Sprintf("%d endpoints exceed the iptables proxy limit of %d; %d endpoints receive no proxied traffic", endpoints, MaxEndpoints, endpoints-MaxEndpoints),
+		ObservedGeneration: headlessService.Generation,
+	})
+	return true
+}
@@ -3,25 +3,36 @@ package iptables
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/endpoints"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+	"github.com/k8s-playgrounds/operator/pkg/ipvs"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
 )
 
 // Manager handles iptables operations for headless services
 type Manager struct {
-	client client.Client
+	client     client.Client
+	helperPods helperpods.Config
 }
 
 // NewManager creates a new iptables manager
-func NewManager(client client.Client) *Manager {
+func NewManager(client client.Client, helperPods helperpods.Config) *Manager {
 	return &Manager{
-		client: client,
+		client:     client,
+		helperPods: helperPods,
 	}
 }
 
@@ -35,7 +46,7 @@ func (m *Manager) ConfigureHeadlessService(ctx context.Context, headlessService
 	}
 
 	// Get the service endpoints
-	endpointIPs, err := m.getServiceEndpoints(ctx, headlessService)
+	endpointIPs, weights, err := m.getServiceEndpoints(ctx, headlessService)
 	if err != nil {
 		return fmt.Errorf("failed to get service endpoints: %w", err)
 	}
@@ -45,300 +56,400 @@ func (m *Manager) ConfigureHeadlessService(ctx context.Context, headlessService
 		return nil
 	}
 
-	// Generate iptables rules
-	rules := m.generateIptablesRules(headlessService, endpointIPs)
+	// Run one variant of the proxy per node group, each with its own rules and DaemonSet
+	if err := validateNodeGroups(headlessService.Spec.IptablesProxy.NodeGroups); err != nil {
+		return err
+	}
+	if err := validateMaxEndpointsPerChain(headlessService.Spec.IptablesProxy); err != nil {
+		return err
+	}
+	if err := validateRollout(headlessService.Spec.IptablesProxy); err != nil {
+		return err
+	}
+	if err := ipvs.Validate(headlessService.Spec.IptablesProxy); err != nil {
+		return err
+	}
+	groups := nodeGroups(headlessService.Spec.IptablesProxy)
+
+	// Endpoints past the hard limit are left out of the rules; warn on the status. IPVS
+	// programs every endpoint.
+	if ipvs.Enabled(headlessService.Spec.IptablesProxy) {
+		meta.RemoveStatusCondition(&headlessService.Status.Conditions, ConditionEndpointsTruncated)
+	} else if TrackEndpointLimit(headlessService, len(endpointIPs)) {
+		log.Info("service has more endpoints than the iptables proxy programs", "endpoints", len(endpointIPs), "limit", MaxEndpoints)
+	}
 
-	// Create a ConfigMap with the iptables rules
-	if err := m.createIptablesConfigMap(ctx, headlessService, rules); err != nil {
-		return fmt.Errorf("failed to create iptables ConfigMap: %w", err)
+	// Allow the proxy pods to report the rules they applied
+	if err := m.createAgentRBAC(ctx, headlessService); err != nil {
+		return fmt.Errorf("failed to create iptables agent RBAC: %w", err)
 	}
 
-	// Create a DaemonSet to apply the iptables rules
-	if err := m.createIptablesDaemonSet(ctx, headlessService); err != nil {
-		return fmt.Errorf("failed to create iptables DaemonSet: %w", err)
+	for _, group := range groups {
+		// Generate the iptables rules or IPVS virtual servers
+		script := proxyScript(headlessService, group, endpointIPs, weights)
+
+		// Verify changed rules on a canary node before the other nodes get them
+		if canaryRollout(headlessService.Spec.IptablesProxy) {
+			if err := m.rolloutCanary(ctx, headlessService, group, script); err != nil {
+				return fmt.Errorf("failed to roll out iptables rules through a canary: %w", err)
+			}
+			continue
+		}
+
+		// Create a ConfigMap with the iptables rules
+		if err := m.createIptablesConfigMap(ctx, headlessService, group, script); err != nil {
+			return fmt.Errorf("failed to create iptables ConfigMap: %w", err)
+		}
+
+		// Create a DaemonSet to apply the iptables rules; a new rules hash rolls the pods
+		if err := m.createIptablesDaemonSet(ctx, headlessService, group, RulesHash(script), ""); err != nil {
+			return fmt.Errorf("failed to create iptables DaemonSet: %w", err)
+		}
+	}
+
+	// Remove the proxies of node groups dropped from the spec
+	if err := m.pruneNodeGroups(ctx, headlessService, groups); err != nil {
+		return fmt.Errorf("failed to prune iptables node groups: %w", err)
 	}
 
 	log.Info("successfully configured iptables proxy", 
 		"service", headlessService.Name,
 		"endpoints", len(endpointIPs),
-		"algorithm", headlessService.Spec.IptablesProxy.LoadBalancingAlgorithm)
+		"mode", proxyMode(headlessService.Spec.IptablesProxy),
+		"algorithm", headlessService.Spec.IptablesProxy.LoadBalancingAlgorithm,
+		"nodeGroups", len(groups))
 
 	return nil
 }
 
-// getServiceEndpoints returns the IP addresses of service endpoints
-func (m *Manager) getServiceEndpoints(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) ([]string, error) {
+// getServiceEndpoints returns the IP addresses of service endpoints and the IPVS weights of
+// the pods among them
+func (m *Manager) getServiceEndpoints(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) ([]string, map[string]int, error) {
 	// Get pods that match the selector
 	pods := &corev1.PodList{}
 	selector := client.MatchingLabels(headlessService.Spec.Selector)
 	namespace := client.InNamespace(headlessService.Namespace)
 	
 	if err := m.client.List(ctx, pods, selector, namespace); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var endpointIPs []string
-	for _, pod := range pods.Items {
+	weights := make(map[string]int, len(pods.Items))
+	for i, pod := range pods.Items {
 		if pod.Status.PodIP != "" {
 			endpointIPs = append(endpointIPs, pod.Status.PodIP)
+			weights[pod.Status.PodIP] = ipvs.Weight(&pods.Items[i])
 		}
 	}
 
-	return endpointIPs, nil
-}
-
-// generateIptablesRules generates iptables rules for the headless service
-func (m *Manager) generateIptablesRules(headlessService *k8splaygroundsv1alpha1.HeadlessService, endpointIPs []string) []string {
-	var rules []string
-	
-	// Service DNS name
-	serviceDNS := fmt.Sprintf("%s.%s.svc.cluster.local", headlessService.Name, headlessService.Namespace)
-	
-	// Generate rules for each port
-	for _, port := range headlessService.Spec.Ports {
-		// PREROUTING rule to capture traffic
-		rule := fmt.Sprintf("iptables -t nat -A PREROUTING -d %s -p %s --dport %d -j DNAT --to-destination %s:%d",
-			serviceDNS,
-			strings.ToLower(port.Protocol),
-			port.Port,
-			endpointIPs[0], // Use first endpoint for now
-			port.TargetPort.IntValue())
-		rules = append(rules, rule)
-		
-		// OUTPUT rule for local traffic
-		rule = fmt.Sprintf("iptables -t nat -A OUTPUT -d %s -p %s --dport %d -j DNAT --to-destination %s:%d",
-			serviceDNS,
-			strings.ToLower(port.Protocol),
-			port.Port,
-			endpointIPs[0], // Use first endpoint for now
-			port.TargetPort.IntValue())
-		rules = append(rules, rule)
-		
-		// Load balancing rules based on algorithm
-		switch headlessService.Spec.IptablesProxy.LoadBalancingAlgorithm {
-		case "round-robin":
-			rules = append(rules, m.generateRoundRobinRules(serviceDNS, port, endpointIPs)...)
-		case "least-connections":
-			rules = append(rules, m.generateLeastConnectionsRules(serviceDNS, port, endpointIPs)...)
-		case "random":
-		default:
-			rules = append(rules, m.generateRandomRules(serviceDNS, port, endpointIPs)...)
-		}
-	}
-
-	return rules
-}
+	// External backends are only balanced when they opt in
+	endpointIPs = append(endpointIPs, endpoints.ExternalIPs(headlessService, true)...)
 
-// generateRoundRobinRules generates round-robin load balancing rules
-func (m *Manager) generateRoundRobinRules(serviceDNS string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string {
-	var rules []string
-	
-	// Create a chain for round-robin
-	chainName := fmt.Sprintf("ROUND_ROBIN_%s_%d", strings.ToUpper(serviceDNS), port.Port)
-	rules = append(rules, fmt.Sprintf("iptables -t nat -N %s", chainName))
-	
-	// Add rules for each endpoint
-	for i, endpointIP := range endpointIPs {
-		rule := fmt.Sprintf("iptables -t nat -A %s -m statistic --mode nth --every %d --packet 0 -j DNAT --to-destination %s:%d",
-			chainName,
-			len(endpointIPs),
-			endpointIP,
-			port.TargetPort.IntValue())
-		rules = append(rules, rule)
-	}
-	
-	// Default rule
-	rules = append(rules, fmt.Sprintf("iptables -t nat -A %s -j DNAT --to-destination %s:%d",
-		chainName,
-		endpointIPs[0],
-		port.TargetPort.IntValue()))
-	
-	return rules
+	return endpointIPs, weights, nil
 }
 
-// generateLeastConnectionsRules generates least-connections load balancing rules
-func (m *Manager) generateLeastConnectionsRules(serviceDNS string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string {
-	var rules []string
-	
-	// Create a chain for least connections
-	chainName := fmt.Sprintf("LEAST_CONN_%s_%d", strings.ToUpper(serviceDNS), port.Port)
-	rules = append(rules, fmt.Sprintf("iptables -t nat -N %s", chainName))
-	
-	// Add rules for each endpoint with connection tracking
-	for _, endpointIP := range endpointIPs {
-		rule := fmt.Sprintf("iptables -t nat -A %s -m conntrack --ctstate NEW -j DNAT --to-destination %s:%d",
-			chainName,
-			endpointIP,
-			port.TargetPort.IntValue())
-		rules = append(rules, rule)
-	}
-	
-	return rules
-}
-
-// generateRandomRules generates random load balancing rules
-func (m *Manager) generateRandomRules(serviceDNS string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string {
-	var rules []string
-	
-	// Create a chain for random selection
-	chainName := fmt.Sprintf("RANDOM_%s_%d", strings.ToUpper(serviceDNS), port.Port)
-	rules = append(rules, fmt.Sprintf("iptables -t nat -N %s", chainName))
-	
-	// Add rules for each endpoint with random probability
-	for i, endpointIP := range endpointIPs {
-		probability := 1.0 / float64(len(endpointIPs))
-		rule := fmt.Sprintf("iptables -t nat -A %s -m random --probability %.3f -j DNAT --to-destination %s:%d",
-			chainName,
-			probability,
-			endpointIP,
-			port.TargetPort.IntValue())
-		rules = append(rules, rule)
-	}
-	
-	return rules
-}
-
-// createIptablesConfigMap creates a ConfigMap with iptables rules
-func (m *Manager) createIptablesConfigMap(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, rules []string) error {
+// createIptablesConfigMap applies the ConfigMap with the iptables rules of a node group
+func (m *Manager) createIptablesConfigMap(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, script string) error {
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-iptables-rules", headlessService.Name),
-			Namespace: headlessService.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/name":     "headless-service-iptables",
-				"app.kubernetes.io/instance": headlessService.Name,
-			},
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: headlessService.APIVersion,
-					Kind:       headlessService.Kind,
-					Name:       headlessService.Name,
-					UID:        headlessService.UID,
-					Controller: &[]bool{true}[0],
-				},
-			},
+			Name:            configMapName(headlessService, group),
+			Namespace:       headlessService.Namespace,
+			Labels:          proxyLabels(headlessService, group),
+			Annotations:     map[string]string{RulesHashAnnotation: RulesHash(script)},
+			OwnerReferences: []metav1.OwnerReference{ownerReference(headlessService)},
 		},
 		Data: map[string]string{
-			"rules.sh": strings.Join(rules, "\n"),
+			"rules.sh":  script,
 			"service":   headlessService.Name,
 			"namespace": headlessService.Namespace,
 		},
 	}
 
-	return m.client.Create(ctx, configMap)
+	existing := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(configMap), existing); err == nil {
+		if applied := existing.Annotations[RulesHashAnnotation]; applied != "" && RulesHash(existing.Data["rules.sh"]) != applied {
+			logr.FromContextOrDiscard(ctx).Info("iptables rules ConfigMap was edited by hand, restoring the desired rules", "configMap", configMap.Name)
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	return apply.Apply(ctx, m.client, configMap)
 }
 
-// createIptablesDaemonSet creates a DaemonSet to apply iptables rules
-func (m *Manager) createIptablesDaemonSet(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+// createIptablesDaemonSet applies the DaemonSet applying the iptables rules on the nodes of a
+// node group. A non-empty excludeNode keeps the proxy off that node, which runs a canary
+// instead.
+func (m *Manager) createIptablesDaemonSet(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, rulesHash string, excludeNode string) error {
+	spec := headlessService.Spec.IptablesProxy
+	labels := proxyLabels(headlessService, group)
+	helperPods, err := m.helperPods.For(headlessService)
+	if err != nil {
+		return err
+	}
 	daemonSet := &appsv1.DaemonSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-iptables", headlessService.Name),
-			Namespace: headlessService.Namespace,
-			Labels: map[string]string{
-				"app.kubernetes.io/name":     "headless-service-iptables",
-				"app.kubernetes.io/instance": headlessService.Name,
-			},
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: headlessService.APIVersion,
-					Kind:       headlessService.Kind,
-					Name:       headlessService.Name,
-					UID:        headlessService.UID,
-					Controller: &[]bool{true}[0],
-				},
-			},
+			Name:            daemonSetName(headlessService, group),
+			Namespace:       headlessService.Namespace,
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{ownerReference(headlessService)},
 		},
 		Spec: appsv1.DaemonSetSpec{
-			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app.kubernetes.io/name":     "headless-service-iptables",
-					"app.kubernetes.io/instance": headlessService.Name,
-				},
-			},
-			Template: corev1.PodTemplateSpec{
-				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app.kubernetes.io/name":     "headless-service-iptables",
-						"app.kubernetes.io/instance": headlessService.Name,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+
+	// The selector is immutable once the DaemonSet exists
+	existing := &appsv1.DaemonSet{}
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(daemonSet), existing); apierrors.IsNotFound(err) {
+		if err := helperPods.Reserve(ctx, m.client, headlessService.Namespace); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else {
+		daemonSet.Spec.Selector = existing.Spec.Selector
+	}
+
+	daemonSet.Spec.Template = corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      labels,
+			Annotations: map[string]string{RulesHashAnnotation: rulesHash},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: agentName(headlessService),
+			Containers: []corev1.Container{
+				{
+					Name:    "iptables-manager",
+					Image:   "alpine:3.18",
+					Command: []string{"/bin/sh"},
+					Args: []string{
+						"-c",
+						"apk add --no-cache " + proxyPackage(spec) + " curl && sh /iptables-rules/rules.sh && " + reportAppliedScript + " && sleep infinity",
 					},
-				},
-				Spec: corev1.PodSpec{
-					Containers: []corev1.Container{
+					Env: []corev1.EnvVar{
 						{
-							Name:  "iptables-manager",
-							Image: "alpine:3.18",
-							Command: []string{"/bin/sh"},
-							Args: []string{
-								"-c",
-								"apk add --no-cache iptables && /iptables-rules/rules.sh && sleep infinity",
-							},
-							VolumeMounts: []corev1.VolumeMount{
-								{
-									Name:      "iptables-rules",
-									MountPath: "/iptables-rules",
-									ReadOnly:  true,
-								},
-							},
-							SecurityContext: &corev1.SecurityContext{
-								Privileged: &[]bool{true}[0],
-								Capabilities: &corev1.Capabilities{
-									Add: []corev1.Capability{"NET_ADMIN"},
-								},
-							},
+							Name:      "POD_NAME",
+							ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
 						},
-					},
-					Volumes: []corev1.Volume{
 						{
-							Name: "iptables-rules",
-							VolumeSource: corev1.VolumeSource{
-								ConfigMap: &corev1.ConfigMapVolumeSource{
-									LocalObjectReference: corev1.LocalObjectReference{
-										Name: fmt.Sprintf("%s-iptables-rules", headlessService.Name),
-									},
-								},
-							},
+							Name:      "POD_NAMESPACE",
+							ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
 						},
 					},
-					HostNetwork: true,
-					Tolerations: []corev1.Toleration{
+					VolumeMounts: []corev1.VolumeMount{
 						{
-							Effect: corev1.TaintEffectNoSchedule,
+							Name:      "iptables-rules",
+							MountPath: "/iptables-rules",
+							ReadOnly:  true,
+						},
+					},
+					SecurityContext: &corev1.SecurityContext{
+						Privileged: &[]bool{true}[0],
+						Capabilities: &corev1.Capabilities{
+							Add: []corev1.Capability{"NET_ADMIN"},
 						},
 					},
 				},
 			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "iptables-rules",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: configMapName(headlessService, group),
+							},
+						},
+					},
+				},
+			},
+			HostNetwork:  true,
+			NodeSelector: group.nodeSelector,
+			Affinity:     withoutNode(buildAffinity(spec.NodeAffinity, spec.IncludeControlPlane), excludeNode),
+			Tolerations:  buildTolerations(group.tolerations, spec.IncludeControlPlane),
 		},
 	}
+	helperPods.Apply(&daemonSet.Spec.Template.ObjectMeta, &daemonSet.Spec.Template.Spec)
+	return apply.Apply(ctx, m.client, daemonSet)
+}
+
+// reportAppliedScript annotates the proxy pod with the hash of the rules it applied, so the
+// operator can tell which nodes run the desired rules
+const reportAppliedScript = `HASH=$(sha256sum /iptables-rules/rules.sh | cut -d' ' -f1) && ` +
+	`SA=/var/run/secrets/kubernetes.io/serviceaccount && ` +
+	`curl -sSf --cacert $SA/ca.crt -H "Authorization: Bearer $(cat $SA/token)" ` +
+	`-H "Content-Type: application/merge-patch+json" -X PATCH ` +
+	`-d "{\"metadata\":{\"annotations\":{\"` + AppliedRulesHashAnnotation + `\":\"$HASH\"}}}" ` +
+	`https://kubernetes.default.svc/api/v1/namespaces/$POD_NAMESPACE/pods/$POD_NAME >/dev/null`
+
+// createAgentRBAC creates the service account the proxy pods use to report applied rules
+func (m *Manager) createAgentRBAC(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	name := agentName(headlessService)
+	labels := proxyLabels(headlessService, nodeGroup{})
+
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, m.client, serviceAccount, func() error {
+		serviceAccount.Labels = labels
+		serviceAccount.OwnerReferences = []metav1.OwnerReference{ownerReference(headlessService)}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, m.client, role, func() error {
+		role.Labels = labels
+		role.OwnerReferences = []metav1.OwnerReference{ownerReference(headlessService)}
+		role.Rules = []rbacv1.PolicyRule{{
+			APIGroups: []string{""},
+			Resources: []string{"pods"},
+			Verbs:     []string{"get", "patch"},
+		}}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	roleBinding := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, m.client, roleBinding, func() error {
+		roleBinding.Labels = labels
+		roleBinding.OwnerReferences = []metav1.OwnerReference{ownerReference(headlessService)}
+		roleBinding.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name}
+		roleBinding.Subjects = []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: headlessService.Namespace}}
+		return nil
+	})
+	return err
+}
+
+// CheckRulesDrift returns the nodes whose proxy pod has not applied the desired rules
+func (m *Manager) CheckRulesDrift(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) ([]string, error) {
+	selector := client.MatchingLabels{
+		"app.kubernetes.io/name":     "headless-service-iptables",
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+	namespace := client.InNamespace(headlessService.Namespace)
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := m.client.List(ctx, daemonSets, selector, namespace); err != nil {
+		return nil, fmt.Errorf("failed to list iptables DaemonSets: %w", err)
+	}
+	pods := &corev1.PodList{}
+	if err := m.client.List(ctx, pods, selector, namespace); err != nil {
+		return nil, fmt.Errorf("failed to list iptables pods: %w", err)
+	}
+	return DivergentNodes(daemonSets.Items, pods.Items), nil
+}
+
+// agentName returns the name of the service account and RBAC objects of the proxy pods
+func agentName(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	return naming.Name(naming.IPTablesAgent, headlessService.Name)
+}
+
+// pruneNodeGroups deletes the DaemonSets and ConfigMaps of node groups no longer in the spec
+func (m *Manager) pruneNodeGroups(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, groups []nodeGroup) error {
+	keepDaemonSets := make(map[string]bool, len(groups))
+	keepConfigMaps := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		keepDaemonSets[daemonSetName(headlessService, group)] = true
+		keepConfigMaps[configMapName(headlessService, group)] = true
+	}
+	if err := m.deleteProxies(ctx, headlessService, keepDaemonSets, keepConfigMaps); err != nil {
+		return err
+	}
+
+	// Canaries only run for the kept node groups while the canary strategy is used
+	keepCanaries := make(map[string]bool, len(groups))
+	if canaryRollout(headlessService.Spec.IptablesProxy) {
+		for _, group := range groups {
+			keepCanaries[group.name] = true
+		}
+	}
+	return m.deleteCanaries(ctx, headlessService, keepCanaries)
+}
 
-	return m.client.Create(ctx, daemonSet)
+// deleteProxies deletes the proxy DaemonSets and ConfigMaps of a headless service, except the
+// ones named in keep
+func (m *Manager) deleteProxies(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, keepDaemonSets, keepConfigMaps map[string]bool) error {
+	selector := client.MatchingLabels{
+		"app.kubernetes.io/name":     "headless-service-iptables",
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+	namespace := client.InNamespace(headlessService.Namespace)
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := m.client.List(ctx, daemonSets, selector, namespace); err != nil {
+		return err
+	}
+	for i := range daemonSets.Items {
+		if keepDaemonSets[daemonSets.Items[i].Name] {
+			continue
+		}
+		if err := m.client.Delete(ctx, &daemonSets.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	configMaps := &corev1.ConfigMapList{}
+	if err := m.client.List(ctx, configMaps, selector, namespace); err != nil {
+		return err
+	}
+	for i := range configMaps.Items {
+		if keepConfigMaps[configMaps.Items[i].Name] {
+			continue
+		}
+		if err := m.client.Delete(ctx, &configMaps.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// proxyLabels returns the labels of the proxy objects of a node group
+func proxyLabels(headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup) map[string]string {
+	labels := map[string]string{
+		"app.kubernetes.io/name":     "headless-service-iptables",
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+	if group.name != "" {
+		labels[nodeGroupLabel] = group.name
+	}
+	return labels
+}
+
+// ownerReference makes the headless service the controller of a proxy object
+func ownerReference(headlessService *k8splaygroundsv1alpha1.HeadlessService) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: headlessService.APIVersion,
+		Kind:       headlessService.Kind,
+		Name:       headlessService.Name,
+		UID:        headlessService.UID,
+		Controller: &[]bool{true}[0],
+	}
 }
 
 // CleanupHeadlessService removes iptables rules for a headless service
 func (m *Manager) CleanupHeadlessService(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
 	log := logr.FromContextOrDiscard(ctx)
 	
-	// Delete the DaemonSet
-	daemonSet := &appsv1.DaemonSet{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-iptables", headlessService.Name),
-			Namespace: headlessService.Namespace,
-		},
+	// Delete the DaemonSets and ConfigMaps of every node group
+	if err := m.deleteProxies(ctx, headlessService, nil, nil); err != nil {
+		log.Error(err, "failed to delete iptables proxies")
 	}
-	
-	if err := m.client.Delete(ctx, daemonSet); err != nil {
-		log.Error(err, "failed to delete iptables DaemonSet")
+	if err := m.deleteCanaries(ctx, headlessService, nil); err != nil {
+		log.Error(err, "failed to delete iptables canaries")
 	}
 
-	// Delete the ConfigMap
-	configMap := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-iptables-rules", headlessService.Name),
-			Namespace: headlessService.Namespace,
-		},
-	}
-	
-	if err := m.client.Delete(ctx, configMap); err != nil {
-		log.Error(err, "failed to delete iptables ConfigMap")
+	// Delete the agent service account and its permissions
+	name := agentName(headlessService)
+	for _, obj := range []client.Object{
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: headlessService.Namespace}},
+	} {
+		if err := m.client.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			log.Error(err, "failed to delete iptables agent RBAC", "name", name)
+		}
 	}
 
 	log.Info("cleaned up iptables rules", "service", headlessService.Name)
@@ -0,0 +1,226 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// nodeGroupLabel identifies the node group a proxy DaemonSet and its pods belong to
+const nodeGroupLabel = "k8s-playgrounds.io/node-group"
+
+// Control plane node role labels; the proxy stays off these nodes unless IncludeControlPlane is set
+var controlPlaneRoleLabels = []string{
+	"node-role.kubernetes.io/control-plane",
+	"node-role.kubernetes.io/master",
+}
+
+// nodeGroup is a set of nodes running one variant of the proxy
+type nodeGroup struct {
+	// name is empty for the single default group used when no node groups are configured
+	name         string
+	nodeSelector map[string]string
+	tolerations  []k8splaygroundsv1alpha1.TolerationSpec
+	algorithm    string
+	backend      string
+}
+
+// nodeGroups returns the node groups to run the proxy on. Group selectors and tolerations are
+// added to the ones set for the whole proxy; groups should select disjoint sets of nodes.
+func nodeGroups(spec *k8splaygroundsv1alpha1.IptablesProxySpec) []nodeGroup {
+	if len(spec.NodeGroups) == 0 {
+		return []nodeGroup{{
+			nodeSelector: spec.NodeSelector,
+			tolerations:  spec.Tolerations,
+			algorithm:    spec.LoadBalancingAlgorithm,
+		}}
+	}
+
+	groups := make([]nodeGroup, 0, len(spec.NodeGroups))
+	for _, g := range spec.NodeGroups {
+		selector := make(map[string]string, len(spec.NodeSelector)+len(g.NodeSelector))
+		for key, value := range spec.NodeSelector {
+			selector[key] = value
+		}
+		for key, value := range g.NodeSelector {
+			selector[key] = value
+		}
+
+		algorithm := g.LoadBalancingAlgorithm
+		if algorithm == "" {
+			algorithm = spec.LoadBalancingAlgorithm
+		}
+
+		groups = append(groups, nodeGroup{
+			name:         g.Name,
+			nodeSelector: selector,
+			tolerations:  append(append([]k8splaygroundsv1alpha1.TolerationSpec{}, spec.Tolerations...), g.Tolerations...),
+			algorithm:    algorithm,
+			backend:      g.Backend,
+		})
+	}
+	return groups
+}
+
+// validateNodeGroups checks that node group names are unique DNS labels, that every group
+// selects nodes and that backends are known
+func validateNodeGroups(groups []k8splaygroundsv1alpha1.ProxyNodeGroup) error {
+	seen := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		if errs := validation.IsDNS1123Label(g.Name); len(errs) > 0 {
+			return fmt.Errorf("invalid node group name %q: %s", g.Name, strings.Join(errs, ", "))
+		}
+		if seen[g.Name] {
+			return fmt.Errorf("duplicate node group %s", g.Name)
+		}
+		seen[g.Name] = true
+		if len(g.NodeSelector) == 0 {
+			return fmt.Errorf("node group %s must set a nodeSelector", g.Name)
+		}
+		switch g.Backend {
+		case "", "legacy", "nft":
+		default:
+			return fmt.Errorf("node group %s has unknown backend %q", g.Name, g.Backend)
+		}
+	}
+	return nil
+}
+
+// daemonSetName returns the name of the proxy DaemonSet of a node group
+func daemonSetName(headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup) string {
+	if group.name == "" {
+		return naming.Name(naming.IPTables, headlessService.Name)
+	}
+	return naming.Qualified(naming.IPTablesGroup, headlessService.Name, group.name)
+}
+
+// configMapName returns the name of the rules ConfigMap of a node group
+func configMapName(headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup) string {
+	if group.name == "" {
+		return naming.Name(naming.IPTablesRules, headlessService.Name)
+	}
+	return naming.Qualified(naming.IPTablesRulesGroup, headlessService.Name, group.name)
+}
+
+// buildAffinity restricts the proxy to Linux nodes and, unless includeControlPlane is set, to
+// worker nodes. The restrictions are added to every required term of the user's node affinity.
+func buildAffinity(nodeAffinity *k8splaygroundsv1alpha1.NodeAffinitySpec, includeControlPlane bool) *corev1.Affinity {
+	restrictions := []corev1.NodeSelectorRequirement{{
+		Key:      corev1.LabelOSStable,
+		Operator: corev1.NodeSelectorOpIn,
+		Values:   []string{"linux"},
+	}}
+	if !includeControlPlane {
+		for _, label := range controlPlaneRoleLabels {
+			restrictions = append(restrictions, corev1.NodeSelectorRequirement{
+				Key:      label,
+				Operator: corev1.NodeSelectorOpDoesNotExist,
+			})
+		}
+	}
+
+	affinity := &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{},
+	}
+	var requiredTerms []k8splaygroundsv1alpha1.NodeSelectorTerm
+	if nodeAffinity != nil {
+		if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+			requiredTerms = nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+		}
+		for _, term := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			affinity.PreferredDuringSchedulingIgnoredDuringExecution = append(affinity.PreferredDuringSchedulingIgnoredDuringExecution, corev1.PreferredSchedulingTerm{
+				Weight:     term.Weight,
+				Preference: buildNodeSelectorTerm(term.Preference, nil),
+			})
+		}
+	}
+
+	if len(requiredTerms) == 0 {
+		requiredTerms = []k8splaygroundsv1alpha1.NodeSelectorTerm{{}}
+	}
+	for _, term := range requiredTerms {
+		affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms = append(
+			affinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms,
+			buildNodeSelectorTerm(term, restrictions))
+	}
+
+	return &corev1.Affinity{NodeAffinity: affinity}
+}
+
+// withoutNode keeps pods with the affinity off the named node; an empty node changes nothing
+func withoutNode(affinity *corev1.Affinity, node string) *corev1.Affinity {
+	if node == "" {
+		return affinity
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	for i := range terms {
+		terms[i].MatchFields = append(terms[i].MatchFields, corev1.NodeSelectorRequirement{
+			Key:      "metadata.name",
+			Operator: corev1.NodeSelectorOpNotIn,
+			Values:   []string{node},
+		})
+	}
+	return affinity
+}
+
+// buildNodeSelectorTerm converts a node selector term, appending extra match expressions
+func buildNodeSelectorTerm(term k8splaygroundsv1alpha1.NodeSelectorTerm, extra []corev1.NodeSelectorRequirement) corev1.NodeSelectorTerm {
+	var result corev1.NodeSelectorTerm
+	for _, requirement := range term.MatchExpressions {
+		result.MatchExpressions = append(result.MatchExpressions, buildNodeSelectorRequirement(requirement))
+	}
+	for _, requirement := range term.MatchFields {
+		result.MatchFields = append(result.MatchFields, buildNodeSelectorRequirement(requirement))
+	}
+	result.MatchExpressions = append(result.MatchExpressions, extra...)
+	return result
+}
+
+// buildNodeSelectorRequirement converts a node selector requirement
+func buildNodeSelectorRequirement(requirement k8splaygroundsv1alpha1.NodeSelectorRequirement) corev1.NodeSelectorRequirement {
+	return corev1.NodeSelectorRequirement{
+		Key:      requirement.Key,
+		Operator: corev1.NodeSelectorOperator(requirement.Operator),
+		Values:   requirement.Values,
+	}
+}
+
+// buildTolerations converts the tolerations of a node group, tolerating the control plane
+// taints when the proxy should also run there
+func buildTolerations(tolerations []k8splaygroundsv1alpha1.TolerationSpec, includeControlPlane bool) []corev1.Toleration {
+	var result []corev1.Toleration
+	for _, t := range tolerations {
+		result = append(result, corev1.Toleration{
+			Key:               t.Key,
+			Operator:          corev1.TolerationOperator(t.Operator),
+			Value:             t.Value,
+			Effect:            corev1.TaintEffect(t.Effect),
+			TolerationSeconds: t.TolerationSeconds,
+		})
+	}
+	if includeControlPlane {
+		for _, label := range controlPlaneRoleLabels {
+			result = append(result, corev1.Toleration{
+				Key:      label,
+				Operator: corev1.TolerationOpExists,
+				Effect:   corev1.TaintEffectNoSchedule,
+			})
+		}
+	}
+	return result
+}
+
+// ruleScript renders the rules of a node group as a shell script, routing the iptables
+// commands to the requested backend
+func ruleScript(rules []string, backend string) string {
+	script := strings.Join(rules, "\n")
+	if backend == "" {
+		return script
+	}
+	return fmt.Sprintf("iptables() { command iptables-%s \"$@\"; }\n", backend) + script
+}
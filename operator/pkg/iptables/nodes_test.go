@@ -0,0 +1,102 @@
+package iptables
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestNodeGroups(t *testing.T) {
+	spec := &k8splaygroundsv1alpha1.IptablesProxySpec{
+		LoadBalancingAlgorithm: "random",
+		NodeSelector:           map[string]string{"pool": "general"},
+		Tolerations:            []k8splaygroundsv1alpha1.TolerationSpec{{Key: "dedicated", Operator: "Exists"}},
+	}
+
+	groups := nodeGroups(spec)
+	if len(groups) != 1 || groups[0].name != "" || groups[0].algorithm != "random" {
+		t.Fatalf("expected a single default group, got %+v", groups)
+	}
+
+	spec.NodeGroups = []k8splaygroundsv1alpha1.ProxyNodeGroup{
+		{Name: "arm", NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"}, Backend: "nft"},
+		{Name: "gpu", NodeSelector: map[string]string{"pool": "gpu"}, LoadBalancingAlgorithm: "round-robin",
+			Tolerations: []k8splaygroundsv1alpha1.TolerationSpec{{Key: "nvidia.com/gpu", Operator: "Exists"}}},
+	}
+	groups = nodeGroups(spec)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+	if want := map[string]string{"pool": "general", "kubernetes.io/arch": "arm64"}; !reflect.DeepEqual(groups[0].nodeSelector, want) {
+		t.Errorf("arm selector = %v, want %v", groups[0].nodeSelector, want)
+	}
+	if groups[0].algorithm != "random" || groups[0].backend != "nft" {
+		t.Errorf("arm group = %+v, want inherited algorithm and nft backend", groups[0])
+	}
+	if groups[1].nodeSelector["pool"] != "gpu" || groups[1].algorithm != "round-robin" || len(groups[1].tolerations) != 2 {
+		t.Errorf("gpu group = %+v, want group overrides", groups[1])
+	}
+}
+
+func TestValidateNodeGroups(t *testing.T) {
+	valid := k8splaygroundsv1alpha1.ProxyNodeGroup{Name: "arm", NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"}}
+	if err := validateNodeGroups([]k8splaygroundsv1alpha1.ProxyNodeGroup{valid}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	invalid := map[string][]k8splaygroundsv1alpha1.ProxyNodeGroup{
+		"bad name":        {{Name: "Arm_Nodes", NodeSelector: valid.NodeSelector}},
+		"duplicate":       {valid, valid},
+		"no selector":     {{Name: "all"}},
+		"unknown backend": {{Name: "arm", NodeSelector: valid.NodeSelector, Backend: "ebpf"}},
+	}
+	for name, groups := range invalid {
+		if err := validateNodeGroups(groups); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestBuildAffinity(t *testing.T) {
+	affinity := buildAffinity(&k8splaygroundsv1alpha1.NodeAffinitySpec{
+		RequiredDuringSchedulingIgnoredDuringExecution: &k8splaygroundsv1alpha1.NodeSelectorSpec{
+			NodeSelectorTerms: []k8splaygroundsv1alpha1.NodeSelectorTerm{
+				{MatchExpressions: []k8splaygroundsv1alpha1.NodeSelectorRequirement{{Key: "zone", Operator: "In", Values: []string{"a"}}}},
+				{MatchExpressions: []k8splaygroundsv1alpha1.NodeSelectorRequirement{{Key: "zone", Operator: "In", Values: []string{"b"}}}},
+			},
+		},
+	}, false)
+
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 2 {
+		t.Fatalf("expected 2 terms, got %d", len(terms))
+	}
+	for _, term := range terms {
+		// zone, linux only and both control plane role labels absent
+		if len(term.MatchExpressions) != 4 || term.MatchExpressions[1].Key != corev1.LabelOSStable {
+			t.Errorf("term missing restrictions: %+v", term.MatchExpressions)
+		}
+	}
+
+	affinity = buildAffinity(nil, true)
+	terms = affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchExpressions) != 1 {
+		t.Errorf("expected only the OS restriction with control plane included, got %+v", terms)
+	}
+	if tolerations := buildTolerations(nil, true); len(tolerations) != len(controlPlaneRoleLabels) {
+		t.Errorf("expected control plane tolerations, got %+v", tolerations)
+	}
+}
+
+func TestRuleScript(t *testing.T) {
+	rules := []string{"iptables -t nat -N A", "iptables -t nat -A A -j ACCEPT"}
+	if got, want := ruleScript(rules, ""), "iptables -t nat -N A\niptables -t nat -A A -j ACCEPT"; got != want {
+		t.Errorf("ruleScript() = %q, want %q", got, want)
+	}
+	if got, want := ruleScript(rules[:1], "legacy"), "iptables() { command iptables-legacy \"$@\"; }\niptables -t nat -N A"; got != want {
+		t.Errorf("ruleScript() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,320 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/dns"
+	"github.com/k8s-playgrounds/operator/pkg/ipvs"
+)
+
+// builtinChains lists the chains of each table, in the order iptables-save prints them
+var builtinChains = map[string][]string{
+	"filter": {"INPUT", "FORWARD", "OUTPUT"},
+	"nat":    {"PREROUTING", "INPUT", "OUTPUT", "POSTROUTING"},
+	"mangle": {"PREROUTING", "INPUT", "FORWARD", "OUTPUT", "POSTROUTING"},
+	"raw":    {"PREROUTING", "OUTPUT"},
+}
+
+// GenerateRules returns the iptables commands that balance the ports of the headless service
+// across endpointIPs with the given algorithm. endpointIPs must not be empty; endpoints past
+// MaxEndpoints are left out.
+func GenerateRules(headlessService *k8splaygroundsv1alpha1.HeadlessService, endpointIPs []string, algorithm string) []string {
+	var rules []string
+
+	// Service DNS name
+	serviceDNS := fmt.Sprintf("%s.%s.svc.%s", headlessService.Name, headlessService.Namespace, dns.ClusterDomain(headlessService))
+	if len(endpointIPs) > MaxEndpoints {
+		endpointIPs = endpointIPs[:MaxEndpoints]
+	}
+	perChain := MaxEndpointsPerChain(headlessService.Spec.IptablesProxy)
+
+	// Generate rules for each port
+	for _, port := range headlessService.Spec.Ports {
+		// PREROUTING rule to capture traffic
+		rule := fmt.Sprintf("iptables -t nat -A PREROUTING -d %s -p %s --dport %d -j DNAT --to-destination %s:%d",
+			serviceDNS,
+			strings.ToLower(port.Protocol),
+			port.Port,
+			endpointIPs[0], // Use first endpoint for now
+			port.TargetPort.IntValue())
+		rules = append(rules, rule)
+
+		// OUTPUT rule for local traffic
+		rule = fmt.Sprintf("iptables -t nat -A OUTPUT -d %s -p %s --dport %d -j DNAT --to-destination %s:%d",
+			serviceDNS,
+			strings.ToLower(port.Protocol),
+			port.Port,
+			endpointIPs[0], // Use first endpoint for now
+			port.TargetPort.IntValue())
+		rules = append(rules, rule)
+
+		// Load balancing rules based on algorithm
+		switch algorithm {
+		case "round-robin":
+			chainName := fmt.Sprintf("ROUND_ROBIN_%s_%d", strings.ToUpper(serviceDNS), port.Port)
+			rules = append(rules, chunkedRules(chainName, port, endpointIPs, perChain, roundRobinDispatch, generateRoundRobinRules)...)
+		case "least-connections":
+			chainName := fmt.Sprintf("LEAST_CONN_%s_%d", strings.ToUpper(serviceDNS), port.Port)
+			rules = append(rules, chunkedRules(chainName, port, endpointIPs, perChain, randomDispatch, generateLeastConnectionsRules)...)
+		case "random":
+		default:
+			chainName := fmt.Sprintf("RANDOM_%s_%d", strings.ToUpper(serviceDNS), port.Port)
+			rules = append(rules, chunkedRules(chainName, port, endpointIPs, perChain, randomDispatch, generateRandomRules)...)
+		}
+	}
+
+	return rules
+}
+
+// chainRules generates the rules of a chain balancing across endpointIPs
+type chainRules func(chainName string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string
+
+// dispatchMatch returns the match of the rule jumping to chunk i of n, given the number of
+// endpoints in that chunk and in the chunks from i on. The last chunk takes what is left.
+type dispatchMatch func(i, n, size, remaining int) string
+
+// chunkedRules generates the chain balancing across endpointIPs. Past perChain endpoints the
+// chain dispatches to sub-chains of at most perChain endpoints each, nesting further when
+// there are more than perChain sub-chains, so a packet traverses O(perChain) rules per level
+// instead of one rule per endpoint.
+func chunkedRules(chainName string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string, perChain int, dispatch dispatchMatch, leaf chainRules) []string {
+	if len(endpointIPs) <= perChain {
+		return leaf(chainName, port, endpointIPs)
+	}
+
+	// Each sub-chain holds up to perChain^depth endpoints, so this chain has at most perChain
+	// jumps; the endpoints are spread evenly so the sub-chains weigh about the same
+	capacity := perChain
+	for (len(endpointIPs)+capacity-1)/capacity > perChain {
+		capacity *= perChain
+	}
+	n := (len(endpointIPs) + capacity - 1) / capacity
+
+	rules := []string{fmt.Sprintf("iptables -t nat -N %s", chainName)}
+	var subChains []string
+	var chunks [][]string
+	for i, rest := 0, endpointIPs; i < n; i++ {
+		size := len(rest) / (n - i)
+		subChain := fmt.Sprintf("%s_%d", chainName, i)
+		rule := fmt.Sprintf("iptables -t nat -A %s -j %s", chainName, subChain)
+		if match := dispatch(i, n, size, len(rest)); match != "" {
+			rule = fmt.Sprintf("iptables -t nat -A %s %s -j %s", chainName, match, subChain)
+		}
+		rules = append(rules, rule)
+		subChains = append(subChains, subChain)
+		chunks = append(chunks, rest[:size])
+		rest = rest[size:]
+	}
+	for i, subChain := range subChains {
+		rules = append(rules, chunkedRules(subChain, port, chunks[i], perChain, dispatch, leaf)...)
+	}
+	return rules
+}
+
+// roundRobinDispatch cycles through the sub-chains packet by packet
+func roundRobinDispatch(i, n, size, remaining int) string {
+	if i == n-1 {
+		return ""
+	}
+	return fmt.Sprintf("-m statistic --mode nth --every %d --packet 0", n-i)
+}
+
+// randomDispatch picks a sub-chain with a probability matching its share of the endpoints
+func randomDispatch(i, n, size, remaining int) string {
+	if i == n-1 {
+		return ""
+	}
+	return fmt.Sprintf("-m statistic --mode random --probability %.5f", float64(size)/float64(remaining))
+}
+
+// generateRoundRobinRules generates round-robin load balancing rules
+func generateRoundRobinRules(chainName string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string {
+	var rules []string
+
+	// Create a chain for round-robin
+	rules = append(rules, fmt.Sprintf("iptables -t nat -N %s", chainName))
+
+	// Add rules for each endpoint
+	for _, endpointIP := range endpointIPs {
+		rule := fmt.Sprintf("iptables -t nat -A %s -m statistic --mode nth --every %d --packet 0 -j DNAT --to-destination %s:%d",
+			chainName,
+			len(endpointIPs),
+			endpointIP,
+			port.TargetPort.IntValue())
+		rules = append(rules, rule)
+	}
+
+	// Default rule
+	rules = append(rules, fmt.Sprintf("iptables -t nat -A %s -j DNAT --to-destination %s:%d",
+		chainName,
+		endpointIPs[0],
+		port.TargetPort.IntValue()))
+
+	return rules
+}
+
+// generateLeastConnectionsRules generates least-connections load balancing rules
+func generateLeastConnectionsRules(chainName string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string {
+	var rules []string
+
+	// Create a chain for least connections
+	rules = append(rules, fmt.Sprintf("iptables -t nat -N %s", chainName))
+
+	// Add rules for each endpoint with connection tracking
+	for _, endpointIP := range endpointIPs {
+		rule := fmt.Sprintf("iptables -t nat -A %s -m conntrack --ctstate NEW -j DNAT --to-destination %s:%d",
+			chainName,
+			endpointIP,
+			port.TargetPort.IntValue())
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// generateRandomRules generates random load balancing rules
+func generateRandomRules(chainName string, port k8splaygroundsv1alpha1.ServicePort, endpointIPs []string) []string {
+	var rules []string
+
+	// Create a chain for random selection
+	rules = append(rules, fmt.Sprintf("iptables -t nat -N %s", chainName))
+
+	// Add rules for each endpoint with random probability
+	for _, endpointIP := range endpointIPs {
+		probability := 1.0 / float64(len(endpointIPs))
+		rule := fmt.Sprintf("iptables -t nat -A %s -m random --probability %.3f -j DNAT --to-destination %s:%d",
+			chainName,
+			probability,
+			endpointIP,
+			port.TargetPort.IntValue())
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// proxyScript returns the script programming the proxy of a node group: IPVS virtual servers
+// in ipvs mode, iptables rules otherwise. weights holds the IPVS weights of the endpoints.
+func proxyScript(headlessService *k8splaygroundsv1alpha1.HeadlessService, group nodeGroup, endpointIPs []string, weights map[string]int) string {
+	spec := headlessService.Spec.IptablesProxy
+	if ipvs.Enabled(spec) {
+		return strings.Join(ipvs.GenerateRules(headlessService, endpointIPs, weights, ipvs.Scheduler(spec, group.algorithm)), "\n")
+	}
+	return ruleScript(GenerateRules(headlessService, endpointIPs, group.algorithm), group.backend)
+}
+
+// proxyMode returns the mode the proxy runs in
+func proxyMode(spec *k8splaygroundsv1alpha1.IptablesProxySpec) string {
+	if ipvs.Enabled(spec) {
+		return ipvs.ModeIPVS
+	}
+	return ipvs.ModeIptables
+}
+
+// proxyPackage returns the Alpine package providing the tool the rules script runs
+func proxyPackage(spec *k8splaygroundsv1alpha1.IptablesProxySpec) string {
+	if ipvs.Enabled(spec) {
+		return "ipvsadm"
+	}
+	return "iptables"
+}
+
+// Render returns the rules the proxy would apply for the headless service and the given
+// endpoints, one rule set per node group: in iptables-save format, or as ipvsadm commands in
+// ipvs mode. It needs no cluster access, so generated rules can be reviewed and tested offline.
+func Render(headlessService *k8splaygroundsv1alpha1.HeadlessService, endpointIPs []string) (string, error) {
+	spec := headlessService.Spec.IptablesProxy
+	if spec == nil || !spec.Enabled {
+		return "", fmt.Errorf("iptables proxy is not enabled")
+	}
+	if len(endpointIPs) == 0 {
+		return "", fmt.Errorf("at least one endpoint is required")
+	}
+	if err := validateNodeGroups(spec.NodeGroups); err != nil {
+		return "", err
+	}
+	if err := validateMaxEndpointsPerChain(spec); err != nil {
+		return "", err
+	}
+	if err := ipvs.Validate(spec); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, group := range nodeGroups(spec) {
+		fmt.Fprintf(&b, "# ConfigMap %s/%s", headlessService.Namespace, configMapName(headlessService, group))
+		if ipvs.Enabled(spec) {
+			fmt.Fprintf(&b, " (ipvs %s)\n", ipvs.Scheduler(spec, group.algorithm))
+			b.WriteString(proxyScript(headlessService, group, endpointIPs, nil) + "\n")
+			continue
+		}
+		rules, err := SaveFormat(GenerateRules(headlessService, endpointIPs, group.algorithm))
+		if err != nil {
+			return "", err
+		}
+		if group.backend != "" {
+			fmt.Fprintf(&b, " (iptables-%s)", group.backend)
+		}
+		b.WriteString("\n")
+		b.WriteString(rules)
+	}
+	return b.String(), nil
+}
+
+// SaveFormat converts iptables commands into iptables-save format. Only chain creation (-N)
+// and append (-A) commands are supported, which is all the generated rules use.
+func SaveFormat(rules []string) (string, error) {
+	var tables []string
+	chains := make(map[string][]string)
+	lines := make(map[string][]string)
+
+	for _, rule := range rules {
+		fields := strings.Fields(rule)
+		if len(fields) == 0 || fields[0] != "iptables" {
+			return "", fmt.Errorf("not an iptables command: %q", rule)
+		}
+		fields = fields[1:]
+
+		table := "filter"
+		if len(fields) >= 2 && fields[0] == "-t" {
+			table, fields = fields[1], fields[2:]
+		}
+		if _, ok := builtinChains[table]; !ok {
+			return "", fmt.Errorf("unknown table %q in %q", table, rule)
+		}
+		if _, seen := chains[table]; !seen {
+			tables = append(tables, table)
+			chains[table] = nil
+		}
+
+		if len(fields) < 2 {
+			return "", fmt.Errorf("incomplete iptables command: %q", rule)
+		}
+		switch fields[0] {
+		case "-N":
+			chains[table] = append(chains[table], fields[1])
+		case "-A":
+			lines[table] = append(lines[table], strings.Join(fields, " "))
+		default:
+			return "", fmt.Errorf("unsupported iptables command %s in %q", fields[0], rule)
+		}
+	}
+
+	var b strings.Builder
+	for _, table := range tables {
+		fmt.Fprintf(&b, "*%s\n", table)
+		for _, chain := range builtinChains[table] {
+			fmt.Fprintf(&b, ":%s ACCEPT [0:0]\n", chain)
+		}
+		for _, chain := range chains[table] {
+			fmt.Fprintf(&b, ":%s - [0:0]\n", chain)
+		}
+		for _, line := range lines[table] {
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("COMMIT\n")
+	}
+	return b.String(), nil
+}
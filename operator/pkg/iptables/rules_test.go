@@ -0,0 +1,218 @@
+package iptables
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func renderService(algorithm string) *k8splaygroundsv1alpha1.HeadlessService {
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+	headlessService.Name = "web"
+	headlessService.Namespace = "shop"
+	headlessService.Spec.Ports = []k8splaygroundsv1alpha1.ServicePort{{Port: 80, TargetPort: intstr.FromInt(8080), Protocol: "TCP"}}
+	headlessService.Spec.IptablesProxy = &k8splaygroundsv1alpha1.IptablesProxySpec{Enabled: true, LoadBalancingAlgorithm: algorithm}
+	return headlessService
+}
+
+func TestSaveFormat(t *testing.T) {
+	rules := []string{
+		"iptables -t nat -A PREROUTING -d web -p tcp --dport 80 -j DNAT --to-destination 10.0.0.1:8080",
+		"iptables -t nat -N LB",
+		"iptables -t nat -A LB -j DNAT --to-destination 10.0.0.1:8080",
+	}
+	got, err := SaveFormat(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `*nat
+:PREROUTING ACCEPT [0:0]
+:INPUT ACCEPT [0:0]
+:OUTPUT ACCEPT [0:0]
+:POSTROUTING ACCEPT [0:0]
+:LB - [0:0]
+-A PREROUTING -d web -p tcp --dport 80 -j DNAT --to-destination 10.0.0.1:8080
+-A LB -j DNAT --to-destination 10.0.0.1:8080
+COMMIT
+`
+	if got != want {
+		t.Errorf("SaveFormat() =\n%s\nwant\n%s", got, want)
+	}
+
+	for _, rule := range []string{"ip6tables -A INPUT -j ACCEPT", "iptables -t nat -D LB 1", "iptables -t broute -N LB"} {
+		if _, err := SaveFormat([]string{rule}); err == nil {
+			t.Errorf("SaveFormat(%q) succeeded, want an error", rule)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	headlessService := renderService("round-robin")
+	got, err := Render(headlessService, []string{"10.0.0.1", "10.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# ConfigMap shop/web-iptables-rules\n*nat\n",
+		":ROUND_ROBIN_WEB.SHOP.SVC.CLUSTER.LOCAL_80 - [0:0]\n",
+		"--every 2 --packet 0 -j DNAT --to-destination 10.0.0.2:8080\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() =\n%s\nmissing %q", got, want)
+		}
+	}
+
+	// Node groups render one rule set each with their own algorithm
+	headlessService.Spec.IptablesProxy.NodeGroups = []k8splaygroundsv1alpha1.ProxyNodeGroup{
+		{Name: "edge", NodeSelector: map[string]string{"tier": "edge"}, Backend: "nft"},
+		{Name: "core", NodeSelector: map[string]string{"tier": "core"}, LoadBalancingAlgorithm: "least-connections"},
+	}
+	got, err = Render(headlessService, []string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(got, "COMMIT\n") != 2 || !strings.Contains(got, "# ConfigMap shop/web-iptables-rules-edge (iptables-nft)\n") || !strings.Contains(got, ":LEAST_CONN_") {
+		t.Errorf("Render() =\n%s\nwant a rule set per node group", got)
+	}
+
+	if _, err := Render(headlessService, nil); err == nil {
+		t.Error("Render() succeeded without endpoints")
+	}
+	headlessService.Spec.IptablesProxy.Enabled = false
+	if _, err := Render(headlessService, []string{"10.0.0.1"}); err == nil {
+		t.Error("Render() succeeded with the proxy disabled")
+	}
+}
+
+func TestRenderIPVS(t *testing.T) {
+	headlessService := renderService("round-robin")
+	headlessService.Spec.IptablesProxy.Mode = "ipvs"
+	headlessService.Spec.IptablesProxy.NodeGroups = []k8splaygroundsv1alpha1.ProxyNodeGroup{
+		{Name: "edge", NodeSelector: map[string]string{"tier": "edge"}},
+		{Name: "core", NodeSelector: map[string]string{"tier": "core"}, LoadBalancingAlgorithm: "least-connections"},
+	}
+
+	// Every endpoint past the iptables limit is a real server of the virtual server
+	got, err := Render(headlessService, endpointIPs(MaxEndpoints+10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# ConfigMap shop/web-iptables-rules-edge (ipvs rr)\nipvsadm -A -t web.shop.svc.cluster.local:80 -s rr 2>/dev/null",
+		"# ConfigMap shop/web-iptables-rules-core (ipvs lc)\n",
+		"ipvsadm -A -t web.shop.svc.cluster.local:80 -s lc 2>/dev/null || ipvsadm -E -t web.shop.svc.cluster.local:80 -s lc\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render() missing %q", want)
+		}
+	}
+	if servers := strings.Count(got, "ipvsadm -a "); servers != 2*(MaxEndpoints+10) {
+		t.Errorf("Render() programs %d real servers, want %d per node group", servers, MaxEndpoints+10)
+	}
+	if strings.Contains(got, "COMMIT") {
+		t.Error("Render() rendered iptables rules in ipvs mode")
+	}
+
+	headlessService.Spec.IptablesProxy.Scheduler = "dh"
+	if _, err := Render(headlessService, []string{"10.0.0.1"}); err == nil {
+		t.Error("Render() succeeded with an unknown scheduler")
+	}
+}
+
+func endpointIPs(n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		ips[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	return ips
+}
+
+func TestGenerateRulesChunksLongChains(t *testing.T) {
+	headlessService := renderService("random")
+	const chain = "RANDOM_WEB.SHOP.SVC.CLUSTER.LOCAL_80"
+
+	// Below the threshold every endpoint stays in the service chain
+	rules := GenerateRules(headlessService, endpointIPs(DefaultMaxEndpointsPerChain), "")
+	if n := countRules(rules, "-A "+chain+" "); n != DefaultMaxEndpointsPerChain {
+		t.Errorf("chain has %d rules, want %d", n, DefaultMaxEndpointsPerChain)
+	}
+
+	// 500 endpoints dispatch to 8 sub-chains of 62 or 63 endpoints
+	rules = GenerateRules(headlessService, endpointIPs(500), "")
+	if n := countRules(rules, "-A "+chain+" "); n != 8 {
+		t.Errorf("dispatch chain has %d rules, want 8", n)
+	}
+	dnat := 0
+	for i := 0; i < 8; i++ {
+		n := countRules(rules, fmt.Sprintf("-A %s_%d ", chain, i))
+		if n < 62 || n > 63 {
+			t.Errorf("sub-chain %d has %d rules", i, n)
+		}
+		dnat += n
+	}
+	if dnat != 500 {
+		t.Errorf("sub-chains hold %d endpoints, want 500", dnat)
+	}
+	if !containsRule(rules, "iptables -t nat -A "+chain+" -m statistic --mode random --probability 0.12400 -j "+chain+"_0") ||
+		!containsRule(rules, "iptables -t nat -A "+chain+" -j "+chain+"_7") {
+		t.Errorf("dispatch rules are not weighted by sub-chain size:\n%s", strings.Join(rules, "\n"))
+	}
+	if _, err := SaveFormat(rules); err != nil {
+		t.Errorf("SaveFormat() error = %v", err)
+	}
+
+	// Small chains nest into a tree instead of a long dispatch chain
+	headlessService.Spec.IptablesProxy.MaxEndpointsPerChain = 4
+	rules = GenerateRules(headlessService, endpointIPs(20), "round-robin")
+	rr := "ROUND_ROBIN_WEB.SHOP.SVC.CLUSTER.LOCAL_80"
+	if countRules(rules, "-A "+rr+" ") != 2 || countRules(rules, "-A "+rr+"_0 ") != 3 || countRules(rules, "-N "+rr+"_0_2") != 1 {
+		t.Errorf("rules do not form a tree:\n%s", strings.Join(rules, "\n"))
+	}
+	if !containsRule(rules, "iptables -t nat -A "+rr+" -m statistic --mode nth --every 2 --packet 0 -j "+rr+"_0") {
+		t.Errorf("round-robin dispatch missing:\n%s", strings.Join(rules, "\n"))
+	}
+}
+
+func TestEndpointLimit(t *testing.T) {
+	headlessService := renderService("random")
+	rules := GenerateRules(headlessService, endpointIPs(MaxEndpoints+10), "")
+	if n := countRules(rules, "DNAT --to-destination 10.0.16.9:8080"); n != 0 {
+		t.Error("endpoints past the limit were programmed")
+	}
+
+	if !TrackEndpointLimit(headlessService, MaxEndpoints+10) || !meta.IsStatusConditionTrue(headlessService.Status.Conditions, ConditionEndpointsTruncated) {
+		t.Errorf("conditions = %+v, want EndpointsTruncated", headlessService.Status.Conditions)
+	}
+	if TrackEndpointLimit(headlessService, MaxEndpoints) || meta.FindStatusCondition(headlessService.Status.Conditions, ConditionEndpointsTruncated) != nil {
+		t.Errorf("conditions = %+v, want EndpointsTruncated cleared", headlessService.Status.Conditions)
+	}
+
+	headlessService.Spec.IptablesProxy.MaxEndpointsPerChain = 1
+	if _, err := Render(headlessService, endpointIPs(2)); err == nil {
+		t.Error("Render() accepted a chain of one endpoint")
+	}
+}
+
+func countRules(rules []string, substr string) int {
+	n := 0
+	for _, rule := range rules {
+		if strings.Contains(rule, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func containsRule(rules []string, want string) bool {
+	for _, rule := range rules {
+		if rule == want {
+			return true
+		}
+	}
+	return false
+}
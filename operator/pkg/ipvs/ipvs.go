@@ -0,0 +1,181 @@
+// Package ipvs programs the headless service proxy as IPVS virtual servers. A virtual server
+// looks up its real servers in a hash table, so unlike iptables chains its cost does not grow
+// with the number of endpoints.
+package ipvs
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/dns"
+)
+
+// Proxy modes
+const (
+	ModeIptables = "iptables"
+	ModeIPVS     = "ipvs"
+)
+
+// Schedulers of the virtual servers
+const (
+	SchedulerRoundRobin         = "rr"
+	SchedulerWeightedRoundRobin = "wrr"
+	SchedulerLeastConnection    = "lc"
+	SchedulerSourceHashing      = "sh"
+)
+
+const (
+	// WeightAnnotation sets the weight of a pod among the real servers, 0 to 65535. A pod of
+	// weight 0 gets no new connections.
+	WeightAnnotation = "k8s-playgrounds.io/ipvs-weight"
+
+	// DefaultWeight is the weight of pods without a valid WeightAnnotation and of external
+	// endpoints
+	DefaultWeight = 1
+
+	maxWeight = 65535
+
+	// persistenceTimeoutSeconds keeps clients on their real server with session affinity,
+	// matching the default timeout of Services
+	persistenceTimeoutSeconds = 10800
+)
+
+// Enabled reports whether the proxy programs IPVS virtual servers instead of iptables rules
+func Enabled(spec *k8splaygroundsv1alpha1.IptablesProxySpec) bool {
+	return spec != nil && spec.Mode == ModeIPVS
+}
+
+// Validate rejects unknown modes and schedulers, and settings that only apply to the other mode
+func Validate(spec *k8splaygroundsv1alpha1.IptablesProxySpec) error {
+	switch spec.Mode {
+	case "", ModeIptables:
+		if spec.Scheduler != "" {
+			return fmt.Errorf("scheduler %q requires mode %s", spec.Scheduler, ModeIPVS)
+		}
+		return nil
+	case ModeIPVS:
+	default:
+		return fmt.Errorf("unknown proxy mode %q", spec.Mode)
+	}
+
+	switch spec.Scheduler {
+	case "", SchedulerRoundRobin, SchedulerWeightedRoundRobin, SchedulerLeastConnection, SchedulerSourceHashing:
+	default:
+		return fmt.Errorf("unknown IPVS scheduler %q", spec.Scheduler)
+	}
+	for _, group := range spec.NodeGroups {
+		if group.Backend != "" {
+			return fmt.Errorf("node group %s sets iptables backend %q, which mode %s does not use", group.Name, group.Backend, ModeIPVS)
+		}
+	}
+	return nil
+}
+
+// Scheduler returns the scheduler of the virtual servers of a node group. Without a scheduler
+// in the spec it follows the load balancing algorithm of the group, so node group algorithms
+// and the algorithm advisor keep working: least-connections maps to lc, the others to rr.
+func Scheduler(spec *k8splaygroundsv1alpha1.IptablesProxySpec, algorithm string) string {
+	if spec.Scheduler != "" {
+		return spec.Scheduler
+	}
+	if algorithm == "least-connections" {
+		return SchedulerLeastConnection
+	}
+	return SchedulerRoundRobin
+}
+
+// Weight returns the weight of a pod among the real servers
+func Weight(pod *corev1.Pod) int {
+	weight, err := strconv.Atoi(pod.Annotations[WeightAnnotation])
+	if err != nil || weight < 0 || weight > maxWeight {
+		return DefaultWeight
+	}
+	return weight
+}
+
+// GenerateRules returns the shell commands that program a virtual server per port of the
+// headless service and address family, with the endpointIPs of the family as masqueraded real
+// servers. weights holds the weight of endpoints by IP; endpoints missing from it get
+// DefaultWeight. The IPv4 virtual servers are addressed by the service name, which ipvsadm
+// resolves to IPv4 only; the IPv6 ones by the first IPv6 address of the name. The commands
+// edit the virtual servers in place rather than recreating them: missing servers are added,
+// existing ones get the scheduler and weights, and real servers no longer listed are deleted,
+// so a rerun keeps the connections of the real servers that stay. The virtual servers of a
+// family without endpoints are deleted.
+func GenerateRules(headlessService *k8splaygroundsv1alpha1.HeadlessService, endpointIPs []string, weights map[string]int, scheduler string) []string {
+	serviceDNS := fmt.Sprintf("%s.%s.svc.%s", headlessService.Name, headlessService.Namespace, dns.ClusterDomain(headlessService))
+	var persistence string
+	if headlessService.Spec.IptablesProxy != nil && headlessService.Spec.IptablesProxy.SessionAffinity {
+		persistence = fmt.Sprintf(" -p %d", persistenceTimeoutSeconds)
+	}
+
+	var ipv4, ipv6 []string
+	for _, endpointIP := range endpointIPs {
+		if net.ParseIP(endpointIP).To4() != nil {
+			ipv4 = append(ipv4, endpointIP)
+		} else {
+			ipv6 = append(ipv6, endpointIP)
+		}
+	}
+
+	rules := virtualServers(headlessService, func(port int32) string {
+		return net.JoinHostPort(serviceDNS, strconv.Itoa(int(port)))
+	}, ipv4, weights, scheduler+persistence)
+
+	rules = append(rules,
+		fmt.Sprintf("vip6=$(getent ahostsv6 %s | awk 'NR == 1 {print $1}')", serviceDNS),
+		`if [ -n "$vip6" ]; then`)
+	for _, rule := range virtualServers(headlessService, func(port int32) string {
+		return fmt.Sprintf(`"[$vip6]:%d"`, port)
+	}, ipv6, weights, scheduler+persistence) {
+		rules = append(rules, "  "+rule)
+	}
+	return append(rules, "fi")
+}
+
+// virtualServers returns the commands programming the virtual servers of one address family,
+// one per port at the address returned by address, with endpointIPs as real servers. options
+// are the scheduler and persistence flags of the virtual servers.
+func virtualServers(headlessService *k8splaygroundsv1alpha1.HeadlessService, address func(port int32) string, endpointIPs []string, weights map[string]int, options string) []string {
+	var rules []string
+	for _, port := range headlessService.Spec.Ports {
+		virtualServer := fmt.Sprintf("%s %s", protocolFlag(port.Protocol), address(port.Port))
+		if len(endpointIPs) == 0 {
+			rules = append(rules, fmt.Sprintf("ipvsadm -D %s 2>/dev/null || true", virtualServer))
+			continue
+		}
+
+		// -A and -a fail for servers that exist; -E and -e edit them instead
+		rules = append(rules, fmt.Sprintf("ipvsadm -A %s -s %s 2>/dev/null || ipvsadm -E %s -s %s", virtualServer, options, virtualServer, options))
+		var keep strings.Builder
+		for _, endpointIP := range endpointIPs {
+			weight, ok := weights[endpointIP]
+			if !ok {
+				weight = DefaultWeight
+			}
+			realServer := fmt.Sprintf("-r %s -m -w %d", net.JoinHostPort(endpointIP, strconv.Itoa(port.TargetPort.IntValue())), weight)
+			rules = append(rules, fmt.Sprintf("ipvsadm -a %s %s 2>/dev/null || ipvsadm -e %s %s", virtualServer, realServer, virtualServer, realServer))
+			keep.WriteString(" -e " + net.JoinHostPort(endpointIP, strconv.Itoa(port.TargetPort.IntValue())))
+		}
+		rules = append(rules, fmt.Sprintf(`ipvsadm -Ln %s | awk '$1 == "->" && $2 != "RemoteAddress:Port" {print $2}' | grep -vxF%s | while read -r real; do ipvsadm -d %s -r "$real"; done`,
+			virtualServer, keep.String(), virtualServer))
+	}
+	return rules
+}
+
+// protocolFlag returns the ipvsadm flag selecting a virtual server of the protocol
+func protocolFlag(protocol string) string {
+	switch strings.ToUpper(protocol) {
+	case "UDP":
+		return "-u"
+	case "SCTP":
+		return "--sctp-service"
+	default:
+		return "-t"
+	}
+}
@@ -0,0 +1,111 @@
+package ipvs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func ipvsService(spec k8splaygroundsv1alpha1.IptablesProxySpec) *k8splaygroundsv1alpha1.HeadlessService {
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+	headlessService.Name = "web"
+	headlessService.Namespace = "shop"
+	headlessService.Spec.Ports = []k8splaygroundsv1alpha1.ServicePort{
+		{Port: 80, TargetPort: intstr.FromInt(8080), Protocol: "TCP"},
+		{Port: 53, TargetPort: intstr.FromInt(5353), Protocol: "UDP"},
+	}
+	spec.Enabled = true
+	spec.Mode = ModeIPVS
+	headlessService.Spec.IptablesProxy = &spec
+	return headlessService
+}
+
+func TestGenerateRules(t *testing.T) {
+	headlessService := ipvsService(k8splaygroundsv1alpha1.IptablesProxySpec{SessionAffinity: true})
+
+	got := GenerateRules(headlessService, []string{"10.0.0.1", "fd00::2"}, map[string]int{"10.0.0.1": 3}, SchedulerWeightedRoundRobin)
+	want := []string{
+		"ipvsadm -A -t web.shop.svc.cluster.local:80 -s wrr -p 10800 2>/dev/null || ipvsadm -E -t web.shop.svc.cluster.local:80 -s wrr -p 10800",
+		"ipvsadm -a -t web.shop.svc.cluster.local:80 -r 10.0.0.1:8080 -m -w 3 2>/dev/null || ipvsadm -e -t web.shop.svc.cluster.local:80 -r 10.0.0.1:8080 -m -w 3",
+		`ipvsadm -Ln -t web.shop.svc.cluster.local:80 | awk '$1 == "->" && $2 != "RemoteAddress:Port" {print $2}' | grep -vxF -e 10.0.0.1:8080 | while read -r real; do ipvsadm -d -t web.shop.svc.cluster.local:80 -r "$real"; done`,
+		"ipvsadm -A -u web.shop.svc.cluster.local:53 -s wrr -p 10800 2>/dev/null || ipvsadm -E -u web.shop.svc.cluster.local:53 -s wrr -p 10800",
+		"ipvsadm -a -u web.shop.svc.cluster.local:53 -r 10.0.0.1:5353 -m -w 3 2>/dev/null || ipvsadm -e -u web.shop.svc.cluster.local:53 -r 10.0.0.1:5353 -m -w 3",
+		`ipvsadm -Ln -u web.shop.svc.cluster.local:53 | awk '$1 == "->" && $2 != "RemoteAddress:Port" {print $2}' | grep -vxF -e 10.0.0.1:5353 | while read -r real; do ipvsadm -d -u web.shop.svc.cluster.local:53 -r "$real"; done`,
+		"vip6=$(getent ahostsv6 web.shop.svc.cluster.local | awk 'NR == 1 {print $1}')",
+		`if [ -n "$vip6" ]; then`,
+		`  ipvsadm -A -t "[$vip6]:80" -s wrr -p 10800 2>/dev/null || ipvsadm -E -t "[$vip6]:80" -s wrr -p 10800`,
+		`  ipvsadm -a -t "[$vip6]:80" -r [fd00::2]:8080 -m -w 1 2>/dev/null || ipvsadm -e -t "[$vip6]:80" -r [fd00::2]:8080 -m -w 1`,
+		`  ipvsadm -Ln -t "[$vip6]:80" | awk '$1 == "->" && $2 != "RemoteAddress:Port" {print $2}' | grep -vxF -e [fd00::2]:8080 | while read -r real; do ipvsadm -d -t "[$vip6]:80" -r "$real"; done`,
+		`  ipvsadm -A -u "[$vip6]:53" -s wrr -p 10800 2>/dev/null || ipvsadm -E -u "[$vip6]:53" -s wrr -p 10800`,
+		`  ipvsadm -a -u "[$vip6]:53" -r [fd00::2]:5353 -m -w 1 2>/dev/null || ipvsadm -e -u "[$vip6]:53" -r [fd00::2]:5353 -m -w 1`,
+		`  ipvsadm -Ln -u "[$vip6]:53" | awk '$1 == "->" && $2 != "RemoteAddress:Port" {print $2}' | grep -vxF -e [fd00::2]:5353 | while read -r real; do ipvsadm -d -u "[$vip6]:53" -r "$real"; done`,
+		"fi",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GenerateRules() =\n%v\nwant\n%v", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Without IPv6 endpoints the IPv6 virtual servers are deleted
+	got = GenerateRules(headlessService, []string{"10.0.0.1"}, nil, SchedulerRoundRobin)
+	for _, want := range []string{`  ipvsadm -D -t "[$vip6]:80" 2>/dev/null || true`, `  ipvsadm -D -u "[$vip6]:53" 2>/dev/null || true`} {
+		if !strings.Contains(strings.Join(got, "\n"), want) {
+			t.Errorf("GenerateRules() = %v, missing %q", got, want)
+		}
+	}
+	for _, rule := range got {
+		if strings.Contains(rule, "web.shop.svc.cluster.local") && strings.Contains(rule, "ipvsadm -D") {
+			t.Errorf("GenerateRules() tears down an IPv4 virtual server with endpoints: %s", rule)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	spec := &k8splaygroundsv1alpha1.IptablesProxySpec{Mode: ModeIPVS}
+	for algorithm, want := range map[string]string{"least-connections": SchedulerLeastConnection, "round-robin": SchedulerRoundRobin, "random": SchedulerRoundRobin} {
+		if got := Scheduler(spec, algorithm); got != want {
+			t.Errorf("Scheduler(%s) = %s, want %s", algorithm, got, want)
+		}
+	}
+	spec.Scheduler = SchedulerSourceHashing
+	if got := Scheduler(spec, "least-connections"); got != SchedulerSourceHashing {
+		t.Errorf("Scheduler() = %s, want the scheduler of the spec", got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := map[string]struct {
+		spec  k8splaygroundsv1alpha1.IptablesProxySpec
+		valid bool
+	}{
+		"iptables":                {k8splaygroundsv1alpha1.IptablesProxySpec{}, true},
+		"ipvs":                    {k8splaygroundsv1alpha1.IptablesProxySpec{Mode: ModeIPVS, Scheduler: SchedulerLeastConnection}, true},
+		"unknown mode":            {k8splaygroundsv1alpha1.IptablesProxySpec{Mode: "nftables"}, false},
+		"unknown scheduler":       {k8splaygroundsv1alpha1.IptablesProxySpec{Mode: ModeIPVS, Scheduler: "dh"}, false},
+		"scheduler with iptables": {k8splaygroundsv1alpha1.IptablesProxySpec{Mode: ModeIptables, Scheduler: SchedulerRoundRobin}, false},
+		"iptables backend": {k8splaygroundsv1alpha1.IptablesProxySpec{Mode: ModeIPVS, NodeGroups: []k8splaygroundsv1alpha1.ProxyNodeGroup{
+			{Name: "edge", NodeSelector: map[string]string{"tier": "edge"}, Backend: "nft"},
+		}}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if err := Validate(&tc.spec); (err == nil) != tc.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestWeight(t *testing.T) {
+	for value, want := range map[string]int{"": DefaultWeight, "5": 5, "0": 0, "-1": DefaultWeight, "70000": DefaultWeight, "heavy": DefaultWeight} {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{WeightAnnotation: value}}}
+		if got := Weight(pod); got != want {
+			t.Errorf("Weight(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package labeling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Recommended labels; values set in a resource spec are kept
+const (
+	NameLabel     = "app.kubernetes.io/name"
+	InstanceLabel = "app.kubernetes.io/instance"
+	VersionLabel  = "app.kubernetes.io/version"
+	PartOfLabel   = "app.kubernetes.io/part-of"
+)
+
+// Operator-owned labels and annotations; they are always set and cannot be changed from a spec
+const (
+	ManagedByLabel  = "app.kubernetes.io/managed-by"
+	ClusterLabel    = "k8s-playgrounds.io/cluster"
+	RevisionLabel   = "k8s-playgrounds.io/revision"
+	OwnerAnnotation = "k8s-playgrounds.io/owner"
+	// SpecHashAnnotation is the hash of the resource's own spec, for change detection
+	SpecHashAnnotation = "k8s-playgrounds.io/spec-hash"
+)
+
+const (
+	// ManagedBy is the managed-by label value of every managed resource
+	ManagedBy = "k8s-playgrounds-operator"
+	// DefaultPartOf is the part-of label value when the cluster does not set one
+	DefaultPartOf = "k8s-playgrounds"
+)
+
+// Conflict policies for common labels and annotations that a resource spec also sets
+const (
+	// PolicyPreserve keeps the value from the resource spec
+	PolicyPreserve = "preserve"
+	// PolicyOverride replaces it with the cluster-wide value
+	PolicyOverride = "override"
+)
+
+// Conflict records a resource spec setting an operator-owned key to another value
+type Conflict struct {
+	Kind  string
+	Name  string
+	Key   string
+	Value string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s %s sets operator-owned key %s=%q", c.Kind, c.Name, c.Key, c.Value)
+}
+
+// injector adds the standard labels and annotations of a cluster to its resource specs
+type injector struct {
+	cluster   *k8splaygroundsv1alpha1.K8sPlaygroundsCluster
+	policy    string
+	revision  string
+	conflicts []Conflict
+}
+
+// Inject adds the standard labels and annotations to every resource and pod template in the
+// cluster spec before the reconcilers render them. Recommended labels only fill gaps and
+// common labels follow the conflict policy, so labels set in resource specs are not
+// clobbered; operator-owned keys always win and every override of one is returned as a
+// conflict. Pod templates carry no revision label, so spec changes do not restart every
+// workload, and owned labels that would change a workload's selector are left alone. Every
+// resource is annotated with the hash of its own spec; call Normalize first so the hash does
+// not depend on list order.
+func Inject(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) []Conflict {
+	in := &injector{
+		cluster:  cluster,
+		policy:   PolicyPreserve,
+		revision: Revision(cluster),
+	}
+	if cluster.Spec.Labeling != nil && cluster.Spec.Labeling.ConflictPolicy != "" {
+		in.policy = cluster.Spec.Labeling.ConflictPolicy
+	}
+
+	spec := &cluster.Spec
+	for i := range spec.Services {
+		s := &spec.Services[i]
+		in.object("Service", s.Name, &s.Labels, &s.Annotations)
+		in.specHash("Service", s.Name, s, &s.Annotations, &s.Labels)
+	}
+	for i := range spec.HeadlessServices {
+		s := &spec.HeadlessServices[i]
+		in.object("HeadlessService", s.Name, &s.Labels, &s.Annotations)
+		in.specHash("HeadlessService", s.Name, s, &s.Annotations, &s.Labels)
+	}
+	for i := range spec.StatefulSets {
+		s := &spec.StatefulSets[i]
+		in.object("StatefulSet", s.Name, &s.Labels, &s.Annotations)
+		in.template("StatefulSet", s.Name, &s.Template, s.Selector)
+		in.specHash("StatefulSet", s.Name, s, &s.Annotations, &s.Labels)
+	}
+	for i := range spec.Deployments {
+		d := &spec.Deployments[i]
+		in.object("Deployment", d.Name, &d.Labels, &d.Annotations)
+		in.template("Deployment", d.Name, &d.Template, d.Selector)
+		in.specHash("Deployment", d.Name, d, &d.Annotations, &d.Labels)
+	}
+	for i := range spec.ConfigMaps {
+		c := &spec.ConfigMaps[i]
+		in.object("ConfigMap", c.Name, &c.Labels, &c.Annotations)
+		in.specHash("ConfigMap", c.Name, c, &c.Annotations, &c.Labels)
+	}
+	for i := range spec.Secrets {
+		s := &spec.Secrets[i]
+		in.object("Secret", s.Name, &s.Labels, &s.Annotations)
+		in.specHash("Secret", s.Name, s, &s.Annotations, &s.Labels)
+	}
+	for i := range spec.NetworkPolicies {
+		p := &spec.NetworkPolicies[i]
+		in.object("NetworkPolicy", p.Name, &p.Labels, &p.Annotations)
+		in.specHash("NetworkPolicy", p.Name, p, &p.Annotations, &p.Labels)
+	}
+	for i := range spec.Ingresses {
+		g := &spec.Ingresses[i]
+		in.object("Ingress", g.Name, &g.Labels, &g.Annotations)
+		in.specHash("Ingress", g.Name, g, &g.Annotations, &g.Labels)
+	}
+	for i := range spec.PersistentVolumes {
+		v := &spec.PersistentVolumes[i]
+		in.object("PersistentVolume", v.Name, &v.Labels, &v.Annotations)
+		in.specHash("PersistentVolume", v.Name, v, &v.Annotations, &v.Labels)
+	}
+	for i := range spec.Jobs {
+		j := &spec.Jobs[i]
+		in.object("Job", j.Name, &j.Labels, &j.Annotations)
+		in.template("Job", j.Name, &j.Template, nil)
+		in.specHash("Job", j.Name, j, &j.Annotations, &j.Labels)
+	}
+	for i := range spec.CronJobs {
+		c := &spec.CronJobs[i]
+		in.object("CronJob", c.Name, &c.Labels, &c.Annotations)
+		in.object("CronJob", c.Name, &c.JobTemplate.Labels, &c.JobTemplate.Annotations)
+		in.template("CronJob", c.Name, &c.JobTemplate.Template, nil)
+		in.specHash("CronJob", c.Name, c, &c.Annotations, &c.Labels, &c.JobTemplate.Labels)
+	}
+	for i := range spec.DaemonSets {
+		d := &spec.DaemonSets[i]
+		in.object("DaemonSet", d.Name, &d.Labels, &d.Annotations)
+		in.template("DaemonSet", d.Name, &d.Template, d.Selector)
+		in.specHash("DaemonSet", d.Name, d, &d.Annotations, &d.Labels)
+	}
+	for i := range spec.ReplicaSets {
+		r := &spec.ReplicaSets[i]
+		in.object("ReplicaSet", r.Name, &r.Labels, &r.Annotations)
+		in.template("ReplicaSet", r.Name, &r.Template, r.Selector)
+		in.specHash("ReplicaSet", r.Name, r, &r.Annotations, &r.Labels)
+	}
+	for i := range spec.HorizontalPodAutoscalers {
+		h := &spec.HorizontalPodAutoscalers[i]
+		in.object("HorizontalPodAutoscaler", h.Name, &h.Labels, &h.Annotations)
+		in.specHash("HorizontalPodAutoscaler", h.Name, h, &h.Annotations, &h.Labels)
+	}
+	return in.conflicts
+}
+
+// Revision returns a short hash of the cluster spec, used as the revision label of resources
+func Revision(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	// The spec only holds JSON-serializable fields, so marshaling cannot fail
+	data, _ := json.Marshal(cluster.Spec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:10]
+}
+
+// object labels and annotates the metadata of a resource
+func (in *injector) object(kind, name string, labels, annotations *map[string]string) {
+	in.labels(kind, name, labels, nil)
+	in.owned(kind, name, labels, RevisionLabel, in.revision, nil)
+	in.annotations(kind, name, annotations)
+}
+
+// template labels and annotates a pod template, leaving keys of the workload's selector alone
+func (in *injector) template(kind, name string, template *k8splaygroundsv1alpha1.PodTemplateSpec, selector map[string]string) {
+	in.labels(kind, name, &template.Metadata.Labels, selector)
+	in.annotations(kind, name, &template.Metadata.Annotations)
+}
+
+// labels adds the recommended, common and owned labels, except the revision label
+func (in *injector) labels(kind, name string, labels *map[string]string, selector map[string]string) {
+	if *labels == nil {
+		*labels = make(map[string]string)
+	}
+
+	partOf := DefaultPartOf
+	if l := in.cluster.Spec.Labeling; l != nil && l.PartOf != "" {
+		partOf = l.PartOf
+	}
+	for key, value := range map[string]string{
+		NameLabel:     name,
+		InstanceLabel: in.cluster.Name,
+		VersionLabel:  in.cluster.Spec.Version,
+		PartOfLabel:   partOf,
+	} {
+		if _, ok := (*labels)[key]; !ok && value != "" {
+			(*labels)[key] = value
+		}
+	}
+
+	if l := in.cluster.Spec.Labeling; l != nil {
+		in.common(*labels, l.Labels, selector)
+	}
+
+	in.owned(kind, name, labels, ManagedByLabel, ManagedBy, selector)
+	in.owned(kind, name, labels, ClusterLabel, in.cluster.Name, selector)
+}
+
+// annotations adds the common and owned annotations
+func (in *injector) annotations(kind, name string, annotations *map[string]string) {
+	if *annotations == nil {
+		*annotations = make(map[string]string)
+	}
+	if l := in.cluster.Spec.Labeling; l != nil {
+		in.common(*annotations, l.Annotations, nil)
+	}
+	in.owned(kind, name, annotations, OwnerAnnotation, in.cluster.Namespace+"/"+in.cluster.Name, nil)
+}
+
+// common adds cluster-wide keys, resolving keys the resource already sets by the conflict
+// policy. Selector keys are never overridden.
+func (in *injector) common(target, values, selector map[string]string) {
+	for key, value := range values {
+		if _, ok := target[key]; ok {
+			if _, selected := selector[key]; selected || in.policy != PolicyOverride {
+				continue
+			}
+		}
+		target[key] = value
+	}
+}
+
+// owned enforces an operator-owned key, recording a conflict when the spec set another value.
+// Keys matched by the selector are not changed because that would orphan the workload's pods.
+func (in *injector) owned(kind, name string, target *map[string]string, key, value string, selector map[string]string) {
+	current, ok := (*target)[key]
+	if ok && current != value {
+		in.conflicts = append(in.conflicts, Conflict{Kind: kind, Name: name, Key: key, Value: current})
+		if _, selected := selector[key]; selected {
+			return
+		}
+	}
+	(*target)[key] = value
+}
@@ -0,0 +1,175 @@
+package labeling
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func newCluster() *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "playground"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Version: "1.2.0",
+			Deployments: []k8splaygroundsv1alpha1.DeploymentSpec{{
+				Name:     "web",
+				Labels:   map[string]string{NameLabel: "frontend", "team": "web"},
+				Selector: map[string]string{"app": "web", ManagedByLabel: "helm"},
+				Template: k8splaygroundsv1alpha1.PodTemplateSpec{
+					Metadata: metav1.ObjectMeta{Labels: map[string]string{"app": "web", ManagedByLabel: "helm"}},
+				},
+			}},
+			ConfigMaps: []k8splaygroundsv1alpha1.ConfigMapSpec{{Name: "settings"}},
+		},
+	}
+}
+
+func TestInjectStandardLabels(t *testing.T) {
+	cluster := newCluster()
+	conflicts := Inject(cluster)
+
+	deployment := cluster.Spec.Deployments[0]
+	if deployment.Labels[NameLabel] != "frontend" {
+		t.Errorf("name label = %q, want the spec value to be kept", deployment.Labels[NameLabel])
+	}
+	for key, want := range map[string]string{
+		InstanceLabel:  "demo",
+		VersionLabel:   "1.2.0",
+		PartOfLabel:    DefaultPartOf,
+		ManagedByLabel: ManagedBy,
+		ClusterLabel:   "demo",
+		RevisionLabel:  Revision(newCluster()),
+	} {
+		if got := deployment.Labels[key]; got != want {
+			t.Errorf("label %s = %q, want %q", key, got, want)
+		}
+	}
+	if deployment.Annotations[OwnerAnnotation] != "playground/demo" {
+		t.Errorf("owner annotation = %q", deployment.Annotations[OwnerAnnotation])
+	}
+
+	template := deployment.Template.Metadata
+	if template.Labels[ManagedByLabel] != "helm" {
+		t.Errorf("managed-by label on the pod template = %q, want the selector value to be kept", template.Labels[ManagedByLabel])
+	}
+	if _, ok := template.Labels[RevisionLabel]; ok {
+		t.Error("pod templates must not carry the revision label")
+	}
+	if template.Labels[ClusterLabel] != "demo" || template.Labels[NameLabel] != "web" {
+		t.Errorf("pod template labels = %v", template.Labels)
+	}
+
+	if cluster.Spec.ConfigMaps[0].Labels[NameLabel] != "settings" {
+		t.Errorf("config map labels = %v", cluster.Spec.ConfigMaps[0].Labels)
+	}
+
+	if len(conflicts) != 1 || conflicts[0].Kind != "Deployment" || conflicts[0].Key != ManagedByLabel || conflicts[0].Value != "helm" {
+		t.Errorf("conflicts = %v, want the managed-by label of the web pod template", conflicts)
+	}
+}
+
+func TestInjectConflictPolicy(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{policy: "", want: "web"},
+		{policy: PolicyPreserve, want: "web"},
+		{policy: PolicyOverride, want: "platform"},
+	}
+	for _, tt := range tests {
+		cluster := newCluster()
+		cluster.Spec.Labeling = &k8splaygroundsv1alpha1.LabelingSpec{
+			PartOf:         "shop",
+			Labels:         map[string]string{"team": "platform", "app": "shop", "cost-center": "42"},
+			ConflictPolicy: tt.policy,
+		}
+		Inject(cluster)
+
+		deployment := cluster.Spec.Deployments[0]
+		if got := deployment.Labels["team"]; got != tt.want {
+			t.Errorf("policy %q: team label = %q, want %q", tt.policy, got, tt.want)
+		}
+		if deployment.Labels["cost-center"] != "42" || deployment.Labels[PartOfLabel] != "shop" {
+			t.Errorf("policy %q: labels = %v, want common labels added", tt.policy, deployment.Labels)
+		}
+		if got := deployment.Template.Metadata.Labels["app"]; got != "web" {
+			t.Errorf("policy %q: selector label app = %q, want it untouched", tt.policy, got)
+		}
+	}
+}
+
+func TestSpecHashOnlyFollowsTheResource(t *testing.T) {
+	cluster := newCluster()
+	Normalize(cluster)
+	Inject(cluster)
+	hash := cluster.Spec.Deployments[0].Annotations[SpecHashAnnotation]
+	if len(hash) != 16 {
+		t.Fatalf("spec hash = %q, want 16 hex digits", hash)
+	}
+
+	// Another resource changes the revision of the cluster but not the hash of the deployment
+	other := newCluster()
+	other.Spec.ConfigMaps[0].Data = map[string]string{"mode": "debug"}
+	Normalize(other)
+	Inject(other)
+	if other.Spec.Deployments[0].Labels[RevisionLabel] == cluster.Spec.Deployments[0].Labels[RevisionLabel] {
+		t.Error("revision label did not change with the spec")
+	}
+	if got := other.Spec.Deployments[0].Annotations[SpecHashAnnotation]; got != hash {
+		t.Errorf("spec hash = %q after an unrelated change, want %q", got, hash)
+	}
+	if other.Spec.ConfigMaps[0].Annotations[SpecHashAnnotation] == cluster.Spec.ConfigMaps[0].Annotations[SpecHashAnnotation] {
+		t.Error("config map spec hash did not change with its data")
+	}
+
+	changed := newCluster()
+	changed.Spec.Deployments[0].Replicas = 3
+	Normalize(changed)
+	Inject(changed)
+	if changed.Spec.Deployments[0].Annotations[SpecHashAnnotation] == hash {
+		t.Error("spec hash did not change with the deployment")
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	withContainer := func(ports []k8splaygroundsv1alpha1.ContainerPort, mounts []k8splaygroundsv1alpha1.VolumeMountSpec) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+		cluster := newCluster()
+		deployment := &cluster.Spec.Deployments[0]
+		deployment.Annotations = map[string]string{"kubectl.kubernetes.io/last-applied-configuration": "{}", "team": "web"}
+		deployment.Template.Spec.Containers = []k8splaygroundsv1alpha1.ContainerSpec{{
+			Name:         "web",
+			Image:        "nginx",
+			Ports:        ports,
+			VolumeMounts: mounts,
+		}}
+		return cluster
+	}
+	http := k8splaygroundsv1alpha1.ContainerPort{Name: "http", ContainerPort: 8080}
+	metrics := k8splaygroundsv1alpha1.ContainerPort{Name: "metrics", ContainerPort: 9090}
+	data := k8splaygroundsv1alpha1.VolumeMountSpec{Name: "data", MountPath: "/data"}
+	cache := k8splaygroundsv1alpha1.VolumeMountSpec{Name: "cache", MountPath: "/data/cache"}
+
+	a := withContainer([]k8splaygroundsv1alpha1.ContainerPort{metrics, http}, []k8splaygroundsv1alpha1.VolumeMountSpec{cache, data})
+	b := withContainer([]k8splaygroundsv1alpha1.ContainerPort{http, metrics}, []k8splaygroundsv1alpha1.VolumeMountSpec{data, cache})
+	for _, cluster := range []*k8splaygroundsv1alpha1.K8sPlaygroundsCluster{a, b} {
+		Normalize(cluster)
+		Inject(cluster)
+	}
+
+	container := a.Spec.Deployments[0].Template.Spec.Containers[0]
+	if container.Ports[0].Name != "http" || container.VolumeMounts[0].Name != "data" {
+		t.Errorf("container = %+v, want ports and mounts sorted", container)
+	}
+	if _, ok := a.Spec.Deployments[0].Annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		t.Error("volatile annotation was kept")
+	}
+	if a.Spec.Deployments[0].Annotations["team"] != "web" {
+		t.Error("regular annotation was dropped")
+	}
+	if Revision(a) != Revision(b) || SpecHash(a.Spec.Deployments[0]) != SpecHash(b.Spec.Deployments[0]) {
+		t.Error("equivalent specs render differently")
+	}
+}
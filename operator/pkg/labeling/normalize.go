@@ -0,0 +1,150 @@
+package labeling
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// VolatileAnnotations are written by tools and controllers on every apply or rollout. They are
+// dropped from resource specs so exported children only differ when their spec does.
+var VolatileAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"deprecated.daemonset.template.generation",
+}
+
+// Normalize puts the resource specs of a cluster in a canonical form, so that the same spec
+// renders the same children byte for byte: volatile annotations are removed and lists whose
+// order has no meaning are sorted. Containers and environment variables keep their order;
+// the first container is the default one and variables may reference earlier ones.
+func Normalize(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+	spec := &cluster.Spec
+	for i := range spec.Services {
+		s := &spec.Services[i]
+		dropVolatile(s.Annotations)
+		sort.SliceStable(s.Ports, func(a, b int) bool {
+			return portKey(s.Ports[a].Port, s.Ports[a].Protocol) < portKey(s.Ports[b].Port, s.Ports[b].Protocol)
+		})
+	}
+	for i := range spec.HeadlessServices {
+		dropVolatile(spec.HeadlessServices[i].Annotations)
+	}
+	for i := range spec.StatefulSets {
+		dropVolatile(spec.StatefulSets[i].Annotations)
+		normalizeTemplate(&spec.StatefulSets[i].Template)
+	}
+	for i := range spec.Deployments {
+		dropVolatile(spec.Deployments[i].Annotations)
+		normalizeTemplate(&spec.Deployments[i].Template)
+	}
+	for i := range spec.ConfigMaps {
+		dropVolatile(spec.ConfigMaps[i].Annotations)
+	}
+	for i := range spec.Secrets {
+		dropVolatile(spec.Secrets[i].Annotations)
+	}
+	for i := range spec.NetworkPolicies {
+		dropVolatile(spec.NetworkPolicies[i].Annotations)
+	}
+	for i := range spec.Ingresses {
+		dropVolatile(spec.Ingresses[i].Annotations)
+	}
+	for i := range spec.PersistentVolumes {
+		dropVolatile(spec.PersistentVolumes[i].Annotations)
+	}
+	for i := range spec.Jobs {
+		dropVolatile(spec.Jobs[i].Annotations)
+		normalizeTemplate(&spec.Jobs[i].Template)
+	}
+	for i := range spec.CronJobs {
+		dropVolatile(spec.CronJobs[i].Annotations)
+		dropVolatile(spec.CronJobs[i].JobTemplate.Annotations)
+		normalizeTemplate(&spec.CronJobs[i].JobTemplate.Template)
+	}
+	for i := range spec.DaemonSets {
+		dropVolatile(spec.DaemonSets[i].Annotations)
+		normalizeTemplate(&spec.DaemonSets[i].Template)
+	}
+	for i := range spec.ReplicaSets {
+		dropVolatile(spec.ReplicaSets[i].Annotations)
+		normalizeTemplate(&spec.ReplicaSets[i].Template)
+	}
+	for i := range spec.HorizontalPodAutoscalers {
+		dropVolatile(spec.HorizontalPodAutoscalers[i].Annotations)
+	}
+}
+
+// SpecHash returns a short hash of the canonical JSON form of a resource spec. JSON objects are
+// written with sorted keys, so the hash is stable across operator restarts.
+func SpecHash(resource interface{}) string {
+	// Resource specs only hold JSON-serializable fields, so marshaling cannot fail
+	data, _ := json.Marshal(resource)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// specHash annotates a resource with the hash of its spec. The revision labels change with
+// every other resource of the cluster and the annotation cannot be part of its own hash, so
+// both are left out; the hash only changes when the resource does.
+func (in *injector) specHash(kind, name string, resource interface{}, annotations *map[string]string, labels ...*map[string]string) {
+	revisions := make([]string, len(labels))
+	for i, l := range labels {
+		revisions[i] = (*l)[RevisionLabel]
+		delete(*l, RevisionLabel)
+	}
+	previous, hasPrevious := (*annotations)[SpecHashAnnotation]
+	delete(*annotations, SpecHashAnnotation)
+
+	hash := SpecHash(resource)
+
+	for i, l := range labels {
+		(*l)[RevisionLabel] = revisions[i]
+	}
+	if hasPrevious {
+		(*annotations)[SpecHashAnnotation] = previous
+	}
+	in.owned(kind, name, annotations, SpecHashAnnotation, hash, nil)
+}
+
+// normalizeTemplate drops the volatile annotations of a pod template and sorts its volumes,
+// tolerations and the ports and mounts of its containers
+func normalizeTemplate(template *k8splaygroundsv1alpha1.PodTemplateSpec) {
+	dropVolatile(template.Metadata.Annotations)
+	pod := &template.Spec
+	sort.SliceStable(pod.Volumes, func(a, b int) bool {
+		return pod.Volumes[a].Name < pod.Volumes[b].Name
+	})
+	sort.SliceStable(pod.Tolerations, func(a, b int) bool {
+		return tolerationKey(pod.Tolerations[a]) < tolerationKey(pod.Tolerations[b])
+	})
+	for i := range pod.Containers {
+		c := &pod.Containers[i]
+		sort.SliceStable(c.Ports, func(a, b int) bool {
+			return portKey(c.Ports[a].ContainerPort, c.Ports[a].Protocol) < portKey(c.Ports[b].ContainerPort, c.Ports[b].Protocol)
+		})
+		// Parents sort before the paths mounted below them
+		sort.SliceStable(c.VolumeMounts, func(a, b int) bool {
+			return c.VolumeMounts[a].MountPath < c.VolumeMounts[b].MountPath
+		})
+	}
+}
+
+func dropVolatile(annotations map[string]string) {
+	for _, key := range VolatileAnnotations {
+		delete(annotations, key)
+	}
+}
+
+// portKey orders ports numerically, then by protocol
+func portKey(port int32, protocol string) string {
+	return fmt.Sprintf("%05d/%s", port, protocol)
+}
+
+func tolerationKey(t k8splaygroundsv1alpha1.TolerationSpec) string {
+	return t.Key + "\x00" + t.Operator + "\x00" + t.Value + "\x00" + t.Effect
+}
@@ -0,0 +1,214 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Load test tools
+const (
+	ToolBuiltin = "builtin"
+	ToolFortio  = "fortio"
+	ToolWrk     = "wrk"
+)
+
+// Defaults applied to unset load test fields
+const (
+	DefaultRequests      = 1000
+	DefaultConcurrency   = 4
+	DefaultWrkDuration   = 30 * time.Second
+	DefaultBackendHeader = "X-Served-By"
+)
+
+// MaxResultSize is the size limit of a container termination message, which carries the
+// result of the builtin client back to the operator
+const MaxResultSize = 4096
+
+// Sample is the outcome of one request
+type Sample struct {
+	// Backend identifies the endpoint that served the request; empty when the request failed
+	Backend string
+	Latency time.Duration
+	Failed  bool
+}
+
+// BackendResult summarizes the requests one backend served
+type BackendResult struct {
+	Backend    string `json:"n"`
+	Requests   int64  `json:"r"`
+	P50Micros  int64  `json:"p50"`
+	P99Micros  int64  `json:"p99"`
+	MeanMicros int64  `json:"avg"`
+}
+
+// Result is the summary the builtin client reports through its termination message. Field
+// names are kept short so that results for many backends fit in MaxResultSize.
+type Result struct {
+	Requests           int64           `json:"r"`
+	Errors             int64           `json:"e"`
+	AffinityViolations int64           `json:"a,omitempty"`
+	Backends           []BackendResult `json:"b,omitempty"`
+	// Truncated counts the least loaded backends dropped to fit MaxResultSize
+	Truncated int `json:"t,omitempty"`
+}
+
+// Config controls a run of the builtin client
+type Config struct {
+	// Requests is the total number of requests; 0 sends requests until Duration elapses
+	Requests    int
+	Concurrency int
+	Duration    time.Duration
+}
+
+// Run sends requests with Concurrency workers until Requests were sent or Duration elapsed,
+// calling do for each one. do returns the backend that served the request.
+func Run(ctx context.Context, config Config, do func(context.Context) (string, error)) []Sample {
+	if config.Concurrency <= 0 {
+		config.Concurrency = DefaultConcurrency
+	}
+	if config.Requests <= 0 && config.Duration <= 0 {
+		config.Requests = DefaultRequests
+	}
+	if config.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Duration)
+		defer cancel()
+	}
+
+	var (
+		sent    int64
+		mu      sync.Mutex
+		samples []Sample
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < config.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if config.Requests > 0 && atomic.AddInt64(&sent, 1) > int64(config.Requests) {
+					return
+				}
+				start := time.Now()
+				backend, err := do(ctx)
+				if err != nil && ctx.Err() != nil {
+					// The run ended while the request was in flight
+					return
+				}
+				sample := Sample{Backend: backend, Latency: time.Since(start), Failed: err != nil}
+				mu.Lock()
+				samples = append(samples, sample)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return samples
+}
+
+// Summarize groups samples by backend, most loaded first. With affinity, all requests of the
+// client are expected on one backend and requests served elsewhere count as violations.
+func Summarize(samples []Sample, affinity bool) Result {
+	latencies := make(map[string][]time.Duration)
+	result := Result{Requests: int64(len(samples))}
+	for _, sample := range samples {
+		if sample.Failed {
+			result.Errors++
+			continue
+		}
+		latencies[sample.Backend] = append(latencies[sample.Backend], sample.Latency)
+	}
+
+	for backend, values := range latencies {
+		sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+		var total time.Duration
+		for _, value := range values {
+			total += value
+		}
+		result.Backends = append(result.Backends, BackendResult{
+			Backend:    backend,
+			Requests:   int64(len(values)),
+			P50Micros:  percentile(values, 50).Microseconds(),
+			P99Micros:  percentile(values, 99).Microseconds(),
+			MeanMicros: (total / time.Duration(len(values))).Microseconds(),
+		})
+	}
+	sort.Slice(result.Backends, func(i, j int) bool {
+		if result.Backends[i].Requests != result.Backends[j].Requests {
+			return result.Backends[i].Requests > result.Backends[j].Requests
+		}
+		return result.Backends[i].Backend < result.Backends[j].Backend
+	})
+
+	if affinity && len(result.Backends) > 0 {
+		result.AffinityViolations = result.Requests - result.Errors - result.Backends[0].Requests
+	}
+	return result
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Skew returns how far the most and least loaded of endpoints backends deviate from an even
+// share of the successful requests, as a percentage of that share. Endpoints that served no
+// request count as the least loaded. With session affinity an uneven spread is expected.
+func Skew(result Result, endpoints int) int32 {
+	if endpoints < len(result.Backends) {
+		endpoints = len(result.Backends)
+	}
+	served := result.Requests - result.Errors
+	if endpoints == 0 || served == 0 {
+		return 0
+	}
+	share := float64(served) / float64(endpoints)
+
+	var least int64
+	if len(result.Backends) == endpoints {
+		least = result.Backends[len(result.Backends)-1].Requests
+	}
+	most := result.Backends[0].Requests
+	deviation := math.Max(float64(most)-share, share-float64(least))
+	return int32(math.Round(deviation / share * 100))
+}
+
+// Encode marshals the result, dropping the least loaded backends until it fits in maxSize
+func Encode(result Result, maxSize int) ([]byte, error) {
+	for {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) <= maxSize {
+			return data, nil
+		}
+		if len(result.Backends) == 0 {
+			return nil, fmt.Errorf("result of %d bytes exceeds %d bytes", len(data), maxSize)
+		}
+		result.Backends = result.Backends[:len(result.Backends)-1]
+		result.Truncated++
+	}
+}
+
+// Decode parses a result reported by the builtin client
+func Decode(data string) (Result, error) {
+	var result Result
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		return Result{}, fmt.Errorf("invalid load test result: %w", err)
+	}
+	return result, nil
+}
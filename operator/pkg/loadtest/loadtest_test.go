@@ -0,0 +1,137 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+func TestRunSendsTheRequestedNumberOfRequests(t *testing.T) {
+	var calls int64
+	samples := Run(context.Background(), Config{Requests: 50, Concurrency: 8}, func(context.Context) (string, error) {
+		n := atomic.AddInt64(&calls, 1)
+		if n%10 == 0 {
+			return "", errors.New("connection refused")
+		}
+		return fmt.Sprintf("10.0.0.%d", n%3), nil
+	})
+	if len(samples) != 50 || calls != 50 {
+		t.Fatalf("samples = %d, calls = %d, want 50", len(samples), calls)
+	}
+	if result := Summarize(samples, false); result.Errors != 5 {
+		t.Errorf("errors = %d, want 5", result.Errors)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var samples []Sample
+	for i := 1; i <= 100; i++ {
+		samples = append(samples, Sample{Backend: "a", Latency: time.Duration(i) * time.Millisecond})
+	}
+	for i := 0; i < 10; i++ {
+		samples = append(samples, Sample{Backend: "b", Latency: time.Millisecond})
+	}
+	samples = append(samples, Sample{Failed: true})
+
+	result := Summarize(samples, true)
+	if result.Requests != 111 || result.Errors != 1 {
+		t.Errorf("requests = %d, errors = %d, want 111 and 1", result.Requests, result.Errors)
+	}
+	if len(result.Backends) != 2 || result.Backends[0].Backend != "a" {
+		t.Fatalf("backends = %+v, want a before b", result.Backends)
+	}
+	a := result.Backends[0]
+	if a.Requests != 100 || a.P50Micros != 50000 || a.P99Micros != 99000 || a.MeanMicros != 50500 {
+		t.Errorf("backend a = %+v", a)
+	}
+	if result.AffinityViolations != 10 {
+		t.Errorf("affinity violations = %d, want the 10 requests served by b", result.AffinityViolations)
+	}
+	if Summarize(samples, false).AffinityViolations != 0 {
+		t.Error("affinity violations counted without session affinity")
+	}
+}
+
+func TestSkew(t *testing.T) {
+	tests := []struct {
+		name      string
+		backends  []int64
+		endpoints int
+		want      int32
+	}{
+		{name: "even", backends: []int64{25, 25, 25, 25}, endpoints: 4, want: 0},
+		{name: "uneven", backends: []int64{40, 30, 20, 10}, endpoints: 4, want: 60},
+		{name: "idle endpoint", backends: []int64{50, 50}, endpoints: 4, want: 100},
+		{name: "unknown endpoints", backends: []int64{60, 40}, endpoints: 0, want: 20},
+	}
+	for _, tt := range tests {
+		result := Result{}
+		for i, requests := range tt.backends {
+			result.Requests += requests
+			result.Backends = append(result.Backends, BackendResult{Backend: fmt.Sprint(i), Requests: requests})
+		}
+		if got := Skew(result, tt.endpoints); got != tt.want {
+			t.Errorf("%s: Skew() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEncodeTruncatesToFit(t *testing.T) {
+	result := Result{Requests: 500}
+	for i := 0; i < 100; i++ {
+		result.Backends = append(result.Backends, BackendResult{Backend: fmt.Sprintf("10.244.%d.%d:8080", i/250, i%250), Requests: 5})
+	}
+
+	data, err := Encode(result, MaxResultSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > MaxResultSize {
+		t.Fatalf("encoded %d bytes, want at most %d", len(data), MaxResultSize)
+	}
+	decoded, err := Decode(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Truncated == 0 || len(decoded.Backends)+decoded.Truncated != 100 || decoded.Requests != 500 {
+		t.Errorf("decoded %d backends with %d truncated, want 100 in total", len(decoded.Backends), decoded.Truncated)
+	}
+}
+
+func TestTargetURL(t *testing.T) {
+	headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+	headlessService.Name = "web"
+	headlessService.Namespace = "shop"
+	headlessService.Spec.Ports = []k8splaygroundsv1alpha1.ServicePort{{Name: "metrics", Port: 9090}, {Name: "http", Port: 8080}}
+	headlessService.Spec.LoadTest = &k8splaygroundsv1alpha1.LoadTestSpec{RunID: "1", PortName: "http", Path: "healthz"}
+
+	got, err := TargetURL(headlessService)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://web.shop.svc.cluster.local:8080/healthz"; got != want {
+		t.Errorf("TargetURL() = %s, want %s", got, want)
+	}
+
+	headlessService.Spec.LoadTest.PortName = "grpc"
+	if _, err := TargetURL(headlessService); err == nil {
+		t.Error("TargetURL() succeeded for an unknown port")
+	}
+}
+
+func TestJobName(t *testing.T) {
+	name := JobName(strings.Repeat("a", 70), "2026-10-18")
+	if len(name) > naming.MaxLength || !strings.HasSuffix(name, "-loadtest-"+runHash("2026-10-18")) {
+		t.Errorf("JobName() = %s", name)
+	}
+	if JobName("web", "1") == JobName("web", "2") {
+		t.Error("different runs share a job name")
+	}
+}
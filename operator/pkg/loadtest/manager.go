@@ -0,0 +1,505 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-logr/logr"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/dns"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+	"github.com/k8s-playgrounds/operator/pkg/reportstore"
+)
+
+// Run phases reported in status
+const (
+	PhaseRunning   = "Running"
+	PhaseSucceeded = "Succeeded"
+	PhaseFailed    = "Failed"
+)
+
+// Default images of the load test tools; the builtin client ships in the operator image
+const (
+	DefaultBuiltinImage = "aviatrix-operator:latest"
+	DefaultFortioImage  = "fortio/fortio:latest"
+	DefaultWrkImage     = "williamyeh/wrk:latest"
+)
+
+// PollInterval is how often a running load test is checked; Job status changes do not
+// trigger headless service reconciles
+const PollInterval = 10 * time.Second
+
+// Keys of the report ConfigMap
+const (
+	ReportRunIDKey   = "runID"
+	ReportResultKey  = "result.json"
+	ReportSummaryKey = "summary"
+	// ReportURIKey replaces ReportResultKey when the result is kept in the report store
+	ReportURIKey = "resultURI"
+)
+
+// reportSubsystem is the report store subsystem of load test results
+const reportSubsystem = "loadtest"
+
+// Labels identifying load test Jobs
+const (
+	serviceLabel = "k8s-playgrounds.io/headless-service"
+	runLabel     = "k8s-playgrounds.io/load-test-run"
+)
+
+// containerName is the name of the load test container whose termination message is read
+const containerName = "loadtest"
+
+// Manager runs load test Jobs against headless services
+type Manager struct {
+	client client.Client
+	scheme *runtime.Scheme
+	image  string
+	store  reportstore.Store
+}
+
+// NewManager creates a new load test manager. image is the operator image providing the
+// builtin client; empty uses DefaultBuiltinImage. Full results are kept in store when set,
+// and in the report ConfigMap otherwise.
+func NewManager(client client.Client, scheme *runtime.Scheme, image string, store reportstore.Store) *Manager {
+	if image == "" {
+		image = DefaultBuiltinImage
+	}
+	return &Manager{
+		client: client,
+		scheme: scheme,
+		image:  image,
+		store:  store,
+	}
+}
+
+// Reconcile starts a Job whenever the run ID of the load test changes and records its result
+// once it finishes. It returns true while a run is in progress. Progress is recorded in
+// headlessService.Status.LoadTest; the caller persists the status.
+func (m *Manager) Reconcile(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) (bool, error) {
+	spec := headlessService.Spec.LoadTest
+	if spec == nil || spec.RunID == "" {
+		headlessService.Status.LoadTest = nil
+		return false, m.pruneJobs(ctx, headlessService, "")
+	}
+
+	status := headlessService.Status.LoadTest
+	if status == nil || status.RunID != spec.RunID {
+		if err := m.pruneJobs(ctx, headlessService, spec.RunID); err != nil {
+			return false, err
+		}
+		if err := m.startJob(ctx, headlessService); err != nil {
+			return false, err
+		}
+		return headlessService.Status.LoadTest.Phase == PhaseRunning, nil
+	}
+	if status.Phase != PhaseRunning {
+		return false, nil
+	}
+
+	job := &batchv1.Job{}
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: headlessService.Namespace, Name: status.JobName}, job); err != nil {
+		if apierrors.IsNotFound(err) {
+			finish(status, PhaseFailed, fmt.Sprintf("load test job %s was deleted", status.JobName))
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get load test job %s: %w", status.JobName, err)
+	}
+
+	finished, failed, message := jobResult(job)
+	if !finished {
+		return true, nil
+	}
+	if failed {
+		finish(status, PhaseFailed, message)
+		return false, nil
+	}
+	if tool(spec) != ToolBuiltin {
+		// fortio and wrk only report aggregate numbers in their output
+		finish(status, PhaseSucceeded, fmt.Sprintf("%s results are in the logs of job %s", tool(spec), job.Name))
+		return false, nil
+	}
+	return false, m.recordResult(ctx, headlessService, job)
+}
+
+// recordResult reads the result of a finished builtin run and publishes it
+func (m *Manager) recordResult(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, job *batchv1.Job) error {
+	status := headlessService.Status.LoadTest
+	message, found, err := m.terminationMessage(ctx, job)
+	if err != nil {
+		return err
+	}
+	if !found {
+		finish(status, PhaseFailed, fmt.Sprintf("no completed pod found for load test job %s", job.Name))
+		return nil
+	}
+	result, err := Decode(message)
+	if err != nil {
+		finish(status, PhaseFailed, err.Error())
+		return nil
+	}
+
+	status.Requests = result.Requests
+	status.Errors = result.Errors
+	status.AffinityViolations = result.AffinityViolations
+	status.SkewPercent = Skew(result, len(headlessService.Status.Endpoints))
+	status.Backends = make([]k8splaygroundsv1alpha1.BackendLoad, len(result.Backends))
+	for i, backend := range result.Backends {
+		status.Backends[i] = k8splaygroundsv1alpha1.BackendLoad{
+			Backend:          backend.Backend,
+			Requests:         backend.Requests,
+			LatencyP50Micros: backend.P50Micros,
+			LatencyP99Micros: backend.P99Micros,
+		}
+	}
+
+	if err := m.writeReport(ctx, headlessService, result); err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("%d requests, %d errors across %d backends, %d%% skew", result.Requests, result.Errors, len(result.Backends)+result.Truncated, status.SkewPercent)
+	if affinity(headlessService) {
+		summary += fmt.Sprintf(", %d affinity violations", result.AffinityViolations)
+	}
+	finish(status, PhaseSucceeded, summary)
+	logr.FromContextOrDiscard(ctx).Info("load test finished", "job", job.Name, "requests", result.Requests, "errors", result.Errors, "skewPercent", status.SkewPercent)
+	return nil
+}
+
+// terminationMessage returns the result the builtin client wrote when it exited
+func (m *Manager) terminationMessage(ctx context.Context, job *batchv1.Job) (string, bool, error) {
+	pods := &corev1.PodList{}
+	if err := m.client.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return "", false, fmt.Errorf("failed to list pods of load test job %s: %w", job.Name, err)
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Status.ContainerStatuses {
+			if container.Name != containerName || container.State.Terminated == nil || container.State.Terminated.ExitCode != 0 {
+				continue
+			}
+			return container.State.Terminated.Message, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// writeReport stores a readable summary in the report ConfigMap, along with the full result or,
+// with a report store, the URI of the result in the store
+func (m *Manager) writeReport(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, result Result) error {
+	status := headlessService.Status.LoadTest
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	summary := Report(result)
+	reportData := map[string]string{
+		ReportRunIDKey:   status.RunID,
+		ReportResultKey:  string(data),
+		ReportSummaryKey: summary,
+	}
+	if m.store != nil {
+		prefix := reportstore.Key(reportSubsystem, headlessService.Namespace, headlessService.Name, status.RunID)
+		uri, err := m.store.Put(ctx, prefix+"/"+ReportResultKey, data)
+		if err != nil {
+			return fmt.Errorf("failed to write load test report: %w", err)
+		}
+		if _, err := m.store.Put(ctx, prefix+"/summary.txt", []byte(summary)); err != nil {
+			return fmt.Errorf("failed to write load test report: %w", err)
+		}
+		delete(reportData, ReportResultKey)
+		reportData[ReportURIKey] = uri
+		status.ReportURI = uri
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ReportName(headlessService),
+			Namespace: headlessService.Namespace,
+			Labels:    map[string]string{serviceLabel: headlessService.Name},
+		},
+		Data: reportData,
+	}
+	if err := controllerutil.SetControllerReference(headlessService, configMap, m.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return fmt.Errorf("failed to write load test report: %w", err)
+	}
+	status.ReportConfigMap = configMap.Name
+	return nil
+}
+
+// startJob creates the Job for the current run ID
+func (m *Manager) startJob(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	spec := headlessService.Spec.LoadTest
+	container, err := m.container(headlessService)
+	if err != nil {
+		headlessService.Status.LoadTest = &k8splaygroundsv1alpha1.LoadTestStatus{RunID: spec.RunID}
+		finish(headlessService.Status.LoadTest, PhaseFailed, err.Error())
+		return nil
+	}
+
+	backoffLimit := int32(0)
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      JobName(headlessService.Name, spec.RunID),
+			Namespace: headlessService.Namespace,
+			Labels: map[string]string{
+				serviceLabel: headlessService.Name,
+				runLabel:     runHash(spec.RunID),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{serviceLabel: headlessService.Name},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{container},
+				},
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(headlessService, job, m.scheme); err != nil {
+		return fmt.Errorf("failed to set owner reference on load test job: %w", err)
+	}
+	if err := m.client.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create load test job %s: %w", job.Name, err)
+	}
+
+	logr.FromContextOrDiscard(ctx).Info("started load test", "runID", spec.RunID, "job", job.Name, "tool", tool(spec))
+	now := metav1.Now()
+	headlessService.Status.LoadTest = &k8splaygroundsv1alpha1.LoadTestStatus{
+		RunID:     spec.RunID,
+		JobName:   job.Name,
+		Phase:     PhaseRunning,
+		StartedAt: &now,
+	}
+	return nil
+}
+
+// container builds the load test container for the configured tool
+func (m *Manager) container(headlessService *k8splaygroundsv1alpha1.HeadlessService) (corev1.Container, error) {
+	spec := headlessService.Spec.LoadTest
+	target, err := TargetURL(headlessService)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	requests := spec.Requests
+	if requests == 0 && spec.DurationSeconds == 0 {
+		requests = DefaultRequests
+	}
+	concurrency := spec.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	container := corev1.Container{
+		Name:                     containerName,
+		Image:                    spec.Image,
+		TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+	}
+	switch tool(spec) {
+	case ToolBuiltin:
+		header := spec.BackendHeader
+		if header == "" {
+			header = DefaultBackendHeader
+		}
+		container.Command = []string{"/loadtest"}
+		container.Args = []string{
+			"--url=" + target,
+			fmt.Sprintf("--requests=%d", requests),
+			fmt.Sprintf("--concurrency=%d", concurrency),
+			fmt.Sprintf("--duration=%ds", spec.DurationSeconds),
+			"--backend-header=" + header,
+			fmt.Sprintf("--affinity=%t", affinity(headlessService)),
+		}
+		if container.Image == "" {
+			container.Image = m.image
+		}
+	case ToolFortio:
+		container.Args = []string{"load", "-qps", "0", "-c", strconv.Itoa(int(concurrency))}
+		if spec.DurationSeconds > 0 {
+			container.Args = append(container.Args, "-t", fmt.Sprintf("%ds", spec.DurationSeconds))
+		} else {
+			container.Args = append(container.Args, "-n", strconv.Itoa(int(requests)))
+		}
+		container.Args = append(container.Args, target)
+		if container.Image == "" {
+			container.Image = DefaultFortioImage
+		}
+	case ToolWrk:
+		// wrk only runs for a duration and needs at least as many connections as threads
+		duration := time.Duration(spec.DurationSeconds) * time.Second
+		if duration == 0 {
+			duration = DefaultWrkDuration
+		}
+		threads := concurrency
+		if threads > 4 {
+			threads = 4
+		}
+		container.Args = []string{
+			"-t", strconv.Itoa(int(threads)),
+			"-c", strconv.Itoa(int(concurrency)),
+			"-d", fmt.Sprintf("%ds", int(duration.Seconds())),
+			"--latency", target,
+		}
+		if container.Image == "" {
+			container.Image = DefaultWrkImage
+		}
+	default:
+		return corev1.Container{}, fmt.Errorf("unsupported load test tool %q", spec.Tool)
+	}
+	return container, nil
+}
+
+// pruneJobs deletes load test Jobs of the headless service other than those of keepRunID
+func (m *Manager) pruneJobs(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, keepRunID string) error {
+	jobs := &batchv1.JobList{}
+	if err := m.client.List(ctx, jobs, client.InNamespace(headlessService.Namespace), client.MatchingLabels{
+		serviceLabel: headlessService.Name,
+	}); err != nil {
+		return fmt.Errorf("failed to list load test jobs: %w", err)
+	}
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if keepRunID != "" && job.Labels[runLabel] == runHash(keepRunID) {
+			continue
+		}
+		if err := m.client.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete stale load test job %s: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// TargetURL returns the URL the load test sends requests to, using the named port or the
+// first port of the headless service
+func TargetURL(headlessService *k8splaygroundsv1alpha1.HeadlessService) (string, error) {
+	spec := headlessService.Spec.LoadTest
+	if len(headlessService.Spec.Ports) == 0 {
+		return "", fmt.Errorf("headless service has no ports to load test")
+	}
+	port := headlessService.Spec.Ports[0]
+	if spec.PortName != "" {
+		found := false
+		for _, p := range headlessService.Spec.Ports {
+			if p.Name == spec.PortName {
+				port, found = p, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("headless service has no port named %q", spec.PortName)
+		}
+	}
+
+	clusterDomain := dns.ClusterDomain(headlessService)
+	path := spec.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return fmt.Sprintf("http://%s.%s.svc.%s:%d%s", headlessService.Name, headlessService.Namespace, clusterDomain, port.Port, path), nil
+}
+
+// ReportName returns the name of the report ConfigMap of the headless service
+func ReportName(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	if name := headlessService.Spec.LoadTest.ReportConfigMap; name != "" {
+		return name
+	}
+	return naming.Name(naming.LoadTestReport, headlessService.Name)
+}
+
+// JobName returns the name of the Job of a run. Run IDs are free-form, so they are hashed.
+func JobName(serviceName, runID string) string {
+	return naming.Qualified(naming.LoadTestJob, serviceName, runHash(runID))
+}
+
+// Report renders the result as a table with one row per backend
+func Report(result Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "requests: %d\nerrors: %d\naffinity violations: %d\n\n", result.Requests, result.Errors, result.AffinityViolations)
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tREQUESTS\tSHARE\tP50\tP99\tMEAN")
+	for _, backend := range result.Backends {
+		share := 0.0
+		if served := result.Requests - result.Errors; served > 0 {
+			share = float64(backend.Requests) / float64(served) * 100
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\t%s\t%s\n", backend.Backend, backend.Requests, share,
+			micros(backend.P50Micros), micros(backend.P99Micros), micros(backend.MeanMicros))
+	}
+	w.Flush()
+	if result.Truncated > 0 {
+		fmt.Fprintf(&b, "\n%d less loaded backends omitted\n", result.Truncated)
+	}
+	return b.String()
+}
+
+func micros(value int64) time.Duration {
+	return time.Duration(value) * time.Microsecond
+}
+
+func runHash(runID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(runID))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+func tool(spec *k8splaygroundsv1alpha1.LoadTestSpec) string {
+	if spec.Tool == "" {
+		return ToolBuiltin
+	}
+	return spec.Tool
+}
+
+// affinity reports whether the iptables proxy pins clients to one endpoint
+func affinity(headlessService *k8splaygroundsv1alpha1.HeadlessService) bool {
+	return headlessService.Spec.IptablesProxy != nil && headlessService.Spec.IptablesProxy.Enabled && headlessService.Spec.IptablesProxy.SessionAffinity
+}
+
+func finish(status *k8splaygroundsv1alpha1.LoadTestStatus, phase, message string) {
+	now := metav1.Now()
+	status.Phase = phase
+	status.Message = message
+	status.CompletedAt = &now
+}
+
+// jobResult reports whether a Job finished, whether it failed and why
+func jobResult(job *batchv1.Job) (bool, bool, string) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, false, ""
+		case batchv1.JobFailed:
+			message := condition.Message
+			if message == "" {
+				message = condition.Reason
+			}
+			return true, true, message
+		}
+	}
+	return false, false, ""
+}
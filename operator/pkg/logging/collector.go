@@ -0,0 +1,331 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Collection modes
+const (
+	// ModeSidecar injects a log-forwarding container into every managed pod that ships the files
+	// written to LogDir; unlike ModeDaemonSet it does not collect stdout and stderr
+	ModeSidecar = "sidecar"
+	// ModeDaemonSet runs one Fluent Bit pod per node tailing container logs
+	ModeDaemonSet = "daemonset"
+)
+
+// Output types
+const (
+	OutputLoki = "loki"
+	OutputS3   = "s3"
+)
+
+// DefaultImage is the Fluent Bit image used when the spec does not set one
+const DefaultImage = "fluent/fluent-bit:2.2.0"
+
+// configKey is the key of the Fluent Bit configuration in the generated ConfigMap
+const configKey = "fluent-bit.conf"
+
+// Reconciler creates the log forwarding configuration and, in DaemonSet mode, the
+// Fluent Bit DaemonSet for a cluster
+type Reconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewReconciler creates a new logging reconciler
+func NewReconciler(client client.Client, scheme *runtime.Scheme) *Reconciler {
+	return &Reconciler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Reconcile ensures the log forwarding resources match the cluster logging spec, removing them
+// when logging is disabled
+func (r *Reconciler) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	log := logr.FromContextOrDiscard(ctx)
+	logging := cluster.Spec.Logging
+	if logging == nil || !logging.Enabled {
+		return r.Cleanup(ctx, cluster)
+	}
+
+	if err := Validate(logging); err != nil {
+		return err
+	}
+
+	config, err := BuildConfig(cluster)
+	if err != nil {
+		return err
+	}
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName(cluster),
+			Namespace: cluster.Namespace,
+			Labels:    labels(cluster),
+		},
+		Data: map[string]string{configKey: config},
+	}
+	if err := controllerutil.SetControllerReference(cluster, configMap, r.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, r.client, configMap); err != nil {
+		return fmt.Errorf("failed to reconcile log forwarder config: %w", err)
+	}
+
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ConfigMapName(cluster),
+			Namespace: cluster.Namespace,
+		},
+	}
+	if mode(logging) != ModeDaemonSet {
+		// Remove a DaemonSet left over from a previous mode
+		if err := r.client.Delete(ctx, daemonSet); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete log forwarder daemonset: %w", err)
+		}
+		return nil
+	}
+
+	daemonSet.Labels = labels(cluster)
+	daemonSet.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels(cluster)}
+	daemonSet.Spec.Template = daemonSetPodTemplate(cluster)
+	if err := controllerutil.SetControllerReference(cluster, daemonSet, r.scheme); err != nil {
+		return err
+	}
+	if err := apply.Apply(ctx, r.client, daemonSet); err != nil {
+		return fmt.Errorf("failed to reconcile log forwarder daemonset: %w", err)
+	}
+
+	log.Info("reconciled log forwarder", "mode", ModeDaemonSet, "generation", daemonSet.Generation)
+	return nil
+}
+
+// Validate checks that the logging spec names a supported mode and a complete output
+func Validate(logging *k8splaygroundsv1alpha1.LoggingSpec) error {
+	switch mode(logging) {
+	case ModeSidecar, ModeDaemonSet:
+	default:
+		return fmt.Errorf("unsupported logging mode %q, must be %q or %q", logging.Mode, ModeSidecar, ModeDaemonSet)
+	}
+
+	output := logging.Output
+	switch output.Type {
+	case OutputLoki:
+		if output.Endpoint == "" {
+			return fmt.Errorf("logging output endpoint is required for loki")
+		}
+		if _, err := url.Parse(output.Endpoint); err != nil {
+			return fmt.Errorf("invalid loki endpoint %q: %w", output.Endpoint, err)
+		}
+	case OutputS3:
+		if output.Bucket == "" || output.Region == "" {
+			return fmt.Errorf("logging output bucket and region are required for s3")
+		}
+	default:
+		return fmt.Errorf("unsupported logging output type %q, must be %q or %q", output.Type, OutputLoki, OutputS3)
+	}
+	return nil
+}
+
+// ConfigMapName returns the name shared by the log forwarder ConfigMap and DaemonSet
+func ConfigMapName(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) string {
+	return naming.Name(naming.LogForwarder, cluster.Name)
+}
+
+// BuildConfig renders the Fluent Bit configuration for the cluster logging spec
+func BuildConfig(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) (string, error) {
+	logging := cluster.Spec.Logging
+	var b strings.Builder
+
+	b.WriteString("[SERVICE]\n    Flush        5\n    Log_Level    info\n\n")
+
+	if mode(logging) == ModeDaemonSet {
+		// Only tail the containers of namespaces the cluster manages
+		for _, ns := range cluster.ManagedNamespaces() {
+			fmt.Fprintf(&b, "[INPUT]\n    Name              tail\n    Path              /var/log/containers/*_%s_*.log\n    multiline.parser  docker, cri\n    Tag               kube.%s.*\n\n", ns, ns)
+		}
+	} else {
+		fmt.Fprintf(&b, "[INPUT]\n    Name              tail\n    Path              %s/*.log\n    Tag               playground.*\n\n", LogDir)
+	}
+
+	fmt.Fprintf(&b, "[FILTER]\n    Name    record_modifier\n    Match   *\n    Record  cluster %s\n", cluster.Name)
+	if mode(logging) == ModeSidecar {
+		b.WriteString("    Record  pod ${POD_NAME}\n    Record  namespace ${POD_NAMESPACE}\n")
+	}
+	b.WriteString("\n")
+
+	output := logging.Output
+	switch output.Type {
+	case OutputLoki:
+		endpoint, err := url.Parse(output.Endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid loki endpoint %q: %w", output.Endpoint, err)
+		}
+		port := endpoint.Port()
+		if port == "" {
+			port = "3100"
+		}
+		uri := endpoint.Path
+		if uri == "" || uri == "/" {
+			uri = "/loki/api/v1/push"
+		}
+		fmt.Fprintf(&b, "[OUTPUT]\n    Name         loki\n    Match        *\n    Host         %s\n    Port         %s\n    Uri          %s\n    labels       job=k8s-playgrounds, cluster=%s\n", endpoint.Hostname(), port, uri, cluster.Name)
+		if endpoint.Scheme == "https" {
+			b.WriteString("    tls          on\n")
+		}
+		if output.CredentialsSecret != "" {
+			b.WriteString("    http_user    ${LOKI_USERNAME}\n    http_passwd  ${LOKI_PASSWORD}\n")
+		}
+	case OutputS3:
+		fmt.Fprintf(&b, "[OUTPUT]\n    Name             s3\n    Match            *\n    bucket           %s\n    region           %s\n    total_file_size  50M\n    upload_timeout   1m\n    compression      gzip\n    s3_key_format    /%s/%s/%%Y/%%m/%%d/%%H%%M%%S-$UUID.gz\n", output.Bucket, output.Region, cluster.Namespace, cluster.Name)
+		if output.Endpoint != "" {
+			fmt.Fprintf(&b, "    endpoint         %s\n", output.Endpoint)
+		}
+	default:
+		return "", fmt.Errorf("unsupported logging output type %q", output.Type)
+	}
+
+	return b.String(), nil
+}
+
+// daemonSetPodTemplate builds the Fluent Bit pod template used in DaemonSet mode
+func daemonSetPodTemplate(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels(cluster),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  SidecarName,
+					Image: image(cluster.Spec.Logging),
+					Args:  []string{"--config", fmt.Sprintf("%s/%s", configDir, configKey)},
+					Env:   credentialEnv(cluster.Spec.Logging.Output),
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: "varlog", MountPath: "/var/log", ReadOnly: true},
+						{Name: configVolumeName, MountPath: configDir, ReadOnly: true},
+					},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("50m"),
+							corev1.ResourceMemory: resource.MustParse("64Mi"),
+						},
+						Limits: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse("200m"),
+							corev1.ResourceMemory: resource.MustParse("128Mi"),
+						},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: "varlog",
+					VolumeSource: corev1.VolumeSource{
+						HostPath: &corev1.HostPathVolumeSource{Path: "/var/log"},
+					},
+				},
+				{
+					Name: configVolumeName,
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: ConfigMapName(cluster)},
+						},
+					},
+				},
+			},
+			// Collect logs from every node, including tainted ones
+			Tolerations: []corev1.Toleration{
+				{Operator: corev1.TolerationOpExists},
+			},
+		},
+	}
+}
+
+// credentialEnv exposes the output credentials to Fluent Bit as environment variables
+func credentialEnv(output k8splaygroundsv1alpha1.LogOutputSpec) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	for name, key := range credentialKeys(output) {
+		env = append(env, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: output.CredentialsSecret},
+					Key:                  key,
+				},
+			},
+		})
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+	return env
+}
+
+// credentialKeys maps environment variable names to keys of the credentials secret
+func credentialKeys(output k8splaygroundsv1alpha1.LogOutputSpec) map[string]string {
+	if output.CredentialsSecret == "" {
+		return nil
+	}
+	switch output.Type {
+	case OutputLoki:
+		return map[string]string{"LOKI_USERNAME": "username", "LOKI_PASSWORD": "password"}
+	case OutputS3:
+		return map[string]string{"AWS_ACCESS_KEY_ID": "accessKeyId", "AWS_SECRET_ACCESS_KEY": "secretAccessKey"}
+	}
+	return nil
+}
+
+// labels returns the labels of the log forwarder resources
+func labels(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "log-forwarder",
+		"app.kubernetes.io/instance": cluster.Name,
+	}
+}
+
+// mode returns the configured collection mode, defaulting to sidecar
+func mode(logging *k8splaygroundsv1alpha1.LoggingSpec) string {
+	if logging.Mode == "" {
+		return ModeSidecar
+	}
+	return logging.Mode
+}
+
+// image returns the configured Fluent Bit image or the default
+func image(logging *k8splaygroundsv1alpha1.LoggingSpec) string {
+	if logging.Image == "" {
+		return DefaultImage
+	}
+	return logging.Image
+}
+
+// Cleanup removes the log forwarder DaemonSet and ConfigMap of the cluster
+func (r *Reconciler) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	objects := []client.Object{
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName(cluster), Namespace: cluster.Namespace}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName(cluster), Namespace: cluster.Namespace}},
+	}
+	for _, obj := range objects {
+		if err := r.client.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete log forwarder %T: %w", obj, err)
+		}
+	}
+	return nil
+}
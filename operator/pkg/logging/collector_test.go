@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+func loggingCluster(mode string) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "labs", UID: "uid-demo"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Logging: &k8splaygroundsv1alpha1.LoggingSpec{
+				Enabled: true,
+				Mode:    mode,
+				Output:  k8splaygroundsv1alpha1.LogOutputSpec{Type: OutputLoki, Endpoint: "http://loki.monitoring:3100"},
+			},
+		},
+	}
+}
+
+func newTestReconciler(t *testing.T) (*Reconciler, client.Client) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	scheme.AddKnownTypes(k8splaygroundsv1alpha1.SchemeGroupVersion, &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{})
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(applytest.Funcs()).Build()
+	return NewReconciler(c, scheme), c
+}
+
+// exists reports whether the log forwarder object of the given type exists
+func exists(t *testing.T, c client.Client, obj client.Object) bool {
+	t.Helper()
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "labs", Name: "demo-log-forwarder"}, obj)
+	if err != nil && !apierrors.IsNotFound(err) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+func TestReconcileRemovesForwarderWhenDisabled(t *testing.T) {
+	ctx := context.Background()
+	r, c := newTestReconciler(t)
+	cluster := loggingCluster(ModeDaemonSet)
+
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !exists(t, c, &corev1.ConfigMap{}) || !exists(t, c, &appsv1.DaemonSet{}) {
+		t.Fatal("daemonset mode did not create the forwarder ConfigMap and DaemonSet")
+	}
+
+	cluster.Spec.Logging.Enabled = false
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if exists(t, c, &corev1.ConfigMap{}) || exists(t, c, &appsv1.DaemonSet{}) {
+		t.Error("disabling logging left the forwarder behind")
+	}
+
+	// Removing the logging spec altogether is the same as disabling it
+	cluster.Spec.Logging = nil
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Errorf("Reconcile() without a logging spec error = %v", err)
+	}
+}
+
+func TestReconcileRemovesDaemonSetInSidecarMode(t *testing.T) {
+	ctx := context.Background()
+	r, c := newTestReconciler(t)
+	cluster := loggingCluster(ModeDaemonSet)
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	cluster.Spec.Logging.Mode = ModeSidecar
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !exists(t, c, &corev1.ConfigMap{}) || exists(t, c, &appsv1.DaemonSet{}) {
+		t.Error("sidecar mode wants the forwarder ConfigMap and no DaemonSet")
+	}
+}
+
+func TestBuildConfig(t *testing.T) {
+	config, err := BuildConfig(loggingCluster(ModeSidecar))
+	if err != nil {
+		t.Fatalf("BuildConfig() error = %v", err)
+	}
+	if !strings.Contains(config, "Path              "+LogDir+"/*.log") || strings.Contains(config, "/var/log/containers") {
+		t.Errorf("sidecar config = %s, want only the files of %s tailed", config, LogDir)
+	}
+
+	config, err = BuildConfig(loggingCluster(ModeDaemonSet))
+	if err != nil {
+		t.Fatalf("BuildConfig() error = %v", err)
+	}
+	if !strings.Contains(config, "/var/log/containers/*_labs_*.log") {
+		t.Errorf("daemonset config = %s, want the container logs of the cluster namespace tailed", config)
+	}
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+const (
+	// SidecarName is the name of the injected log forwarding container
+	SidecarName = "log-forwarder"
+	// LogDir is the shared directory workloads write log files to in sidecar mode
+	LogDir = "/var/log/playground"
+
+	logVolumeName    = "playground-logs"
+	configVolumeName = "log-forwarder-config"
+	configDir        = "/fluent-bit/etc/playground"
+)
+
+// InjectSidecars adds a log forwarding container to the long-running workloads of the cluster
+// when sidecar mode is enabled. Every container of an injected pod gets LogDir mounted from a
+// shared emptyDir that the sidecar tails. Only files written to LogDir are shipped: the sidecar
+// cannot read the stdout and stderr of the other containers, which need DaemonSet mode. Jobs and CronJobs are skipped because a sidecar that
+// never exits would keep their pods from completing, and workloads outside the cluster namespace
+// are skipped because they cannot mount the forwarder ConfigMap. Injection is idempotent.
+func InjectSidecars(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+	logging := cluster.Spec.Logging
+	if logging == nil || !logging.Enabled || mode(logging) != ModeSidecar {
+		return
+	}
+
+	inject := func(namespace string, template *k8splaygroundsv1alpha1.PodTemplateSpec) {
+		if namespace != "" && namespace != cluster.Namespace {
+			return
+		}
+		injectPodTemplate(cluster, template)
+	}
+
+	for i := range cluster.Spec.Deployments {
+		inject(cluster.Spec.Deployments[i].Namespace, &cluster.Spec.Deployments[i].Template)
+	}
+	for i := range cluster.Spec.StatefulSets {
+		inject(cluster.Spec.StatefulSets[i].Namespace, &cluster.Spec.StatefulSets[i].Template)
+	}
+	for i := range cluster.Spec.DaemonSets {
+		inject(cluster.Spec.DaemonSets[i].Namespace, &cluster.Spec.DaemonSets[i].Template)
+	}
+	for i := range cluster.Spec.ReplicaSets {
+		inject(cluster.Spec.ReplicaSets[i].Namespace, &cluster.Spec.ReplicaSets[i].Template)
+	}
+}
+
+// injectPodTemplate adds the shared log volume, the config volume and the sidecar to a pod template
+func injectPodTemplate(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, template *k8splaygroundsv1alpha1.PodTemplateSpec) {
+	podSpec := &template.Spec
+	for _, c := range podSpec.Containers {
+		if c.Name == SidecarName {
+			return
+		}
+	}
+
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].VolumeMounts = append(podSpec.Containers[i].VolumeMounts, k8splaygroundsv1alpha1.VolumeMountSpec{
+			Name:      logVolumeName,
+			MountPath: LogDir,
+		})
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes,
+		k8splaygroundsv1alpha1.VolumeSpec{
+			Name: logVolumeName,
+			VolumeSource: k8splaygroundsv1alpha1.VolumeSourceSpec{
+				EmptyDir: &k8splaygroundsv1alpha1.EmptyDirVolumeSource{},
+			},
+		},
+		k8splaygroundsv1alpha1.VolumeSpec{
+			Name: configVolumeName,
+			VolumeSource: k8splaygroundsv1alpha1.VolumeSourceSpec{
+				ConfigMap: &k8splaygroundsv1alpha1.ConfigMapVolumeSource{Name: ConfigMapName(cluster)},
+			},
+		},
+	)
+
+	env := []k8splaygroundsv1alpha1.EnvVar{
+		{
+			Name: "POD_NAME",
+			ValueFrom: &k8splaygroundsv1alpha1.EnvVarSource{
+				FieldRef: &k8splaygroundsv1alpha1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		},
+		{
+			Name: "POD_NAMESPACE",
+			ValueFrom: &k8splaygroundsv1alpha1.EnvVarSource{
+				FieldRef: &k8splaygroundsv1alpha1.ObjectFieldSelector{FieldPath: "metadata.namespace"},
+			},
+		},
+	}
+	for _, e := range credentialEnv(cluster.Spec.Logging.Output) {
+		env = append(env, k8splaygroundsv1alpha1.EnvVar{
+			Name: e.Name,
+			ValueFrom: &k8splaygroundsv1alpha1.EnvVarSource{
+				SecretKeyRef: &k8splaygroundsv1alpha1.SecretKeySelector{
+					Name: e.ValueFrom.SecretKeyRef.Name,
+					Key:  e.ValueFrom.SecretKeyRef.Key,
+				},
+			},
+		})
+	}
+
+	podSpec.Containers = append(podSpec.Containers, k8splaygroundsv1alpha1.ContainerSpec{
+		Name:  SidecarName,
+		Image: image(cluster.Spec.Logging),
+		Args:  []string{"--config", configDir + "/" + configKey},
+		Env:   env,
+		Resources: &k8splaygroundsv1alpha1.ResourceRequirements{
+			Requests: map[string]string{"cpu": "10m", "memory": "32Mi"},
+			Limits:   map[string]string{"cpu": "100m", "memory": "64Mi"},
+		},
+		VolumeMounts: []k8splaygroundsv1alpha1.VolumeMountSpec{
+			{Name: logVolumeName, MountPath: LogDir, ReadOnly: true},
+			{Name: configVolumeName, MountPath: configDir, ReadOnly: true},
+		},
+	})
+}
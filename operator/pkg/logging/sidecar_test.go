@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"testing"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestInjectSidecars(t *testing.T) {
+	cluster := loggingCluster(ModeSidecar)
+	cluster.Spec.Deployments = []k8splaygroundsv1alpha1.DeploymentSpec{
+		{Name: "web", Template: k8splaygroundsv1alpha1.PodTemplateSpec{Spec: k8splaygroundsv1alpha1.PodSpec{
+			Containers: []k8splaygroundsv1alpha1.ContainerSpec{{Name: "web", Image: "nginx"}},
+		}}},
+		{Name: "api", Namespace: "other", Template: k8splaygroundsv1alpha1.PodTemplateSpec{Spec: k8splaygroundsv1alpha1.PodSpec{
+			Containers: []k8splaygroundsv1alpha1.ContainerSpec{{Name: "api", Image: "api"}},
+		}}},
+	}
+
+	InjectSidecars(cluster)
+	InjectSidecars(cluster)
+
+	web := cluster.Spec.Deployments[0].Template.Spec
+	if len(web.Containers) != 2 || web.Containers[1].Name != SidecarName || len(web.Volumes) != 2 {
+		t.Fatalf("web pod = %+v, want one sidecar and its two volumes", web)
+	}
+	if mounts := web.Containers[0].VolumeMounts; len(mounts) != 1 || mounts[0].MountPath != LogDir {
+		t.Errorf("web container mounts = %+v, want only %s", mounts, LogDir)
+	}
+	if api := cluster.Spec.Deployments[1].Template.Spec; len(api.Containers) != 1 {
+		t.Errorf("workload outside the cluster namespace got a sidecar: %+v", api.Containers)
+	}
+}
+
+func TestInjectSidecarsSkipsDaemonSetMode(t *testing.T) {
+	cluster := loggingCluster(ModeDaemonSet)
+	cluster.Spec.Deployments = []k8splaygroundsv1alpha1.DeploymentSpec{{Name: "web"}}
+	InjectSidecars(cluster)
+	if containers := cluster.Spec.Deployments[0].Template.Spec.Containers; len(containers) != 0 {
+		t.Errorf("daemonset mode injected %+v", containers)
+	}
+}
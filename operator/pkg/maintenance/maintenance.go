@@ -0,0 +1,182 @@
+package maintenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/schedule"
+)
+
+// Kinds of disruptive changes held back outside the maintenance window
+const (
+	KindVersion              = "Version"
+	KindStatefulSet          = "StatefulSet"
+	KindResourceOptimization = "ResourceOptimization"
+)
+
+// versionKey is the applied revision key of the cluster version
+const versionKey = "version"
+
+// Window is a recurring maintenance window opening on a cron schedule for a fixed duration
+type Window struct {
+	Start    *schedule.Cron
+	Duration time.Duration
+	Location *time.Location
+}
+
+// NewWindow parses a maintenance window spec
+func NewWindow(spec *k8splaygroundsv1alpha1.MaintenanceWindowSpec) (*Window, error) {
+	start, err := schedule.ParseCron(spec.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maintenance schedule: %w", err)
+	}
+	if spec.Duration.Duration <= 0 {
+		return nil, fmt.Errorf("maintenance window duration must be positive")
+	}
+
+	location := time.UTC
+	if spec.TimeZone != "" {
+		if location, err = time.LoadLocation(spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", spec.TimeZone, err)
+		}
+	}
+
+	return &Window{Start: start, Duration: spec.Duration.Duration, Location: location}, nil
+}
+
+// Open reports whether now falls inside the window
+func (w *Window) Open(now time.Time) bool {
+	lastStart, ok := w.Start.Previous(now.In(w.Location))
+	return ok && now.Before(lastStart.Add(w.Duration))
+}
+
+// Next returns when the window next opens after now
+func (w *Window) Next(now time.Time) (time.Time, bool) {
+	return w.Start.Next(now.In(w.Location))
+}
+
+// Plan is the outcome of gating a cluster spec. Commit records the revisions it applied once
+// the reconcilers succeeded.
+type Plan struct {
+	// SkipResourceOptimization is set when resource optimization must wait for the window
+	SkipResourceOptimization bool
+	// Deferred is set when any change waits for the window
+	Deferred bool
+	// NextWindow is when the window next opens; zero without a window or while it is open
+	NextWindow time.Time
+
+	applied map[string]string
+}
+
+// Commit records the revisions applied by this reconcile, so later changes are compared with
+// them. Deferred items keep their previously applied revision.
+func (p *Plan) Commit(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+	if cluster.Status.Maintenance != nil {
+		cluster.Status.Maintenance.AppliedRevisions = p.applied
+	}
+}
+
+// Gate holds back disruptive changes to the cluster spec while the maintenance window is
+// closed and reports them in status. It changes the in-memory spec only: a version upgrade is
+// reverted to the applied version, and a StatefulSet whose pod template changed is left out so
+// its pods are not restarted. Resource optimization applies only run inside the window.
+// Changes to resources the operator has not applied yet are never deferred.
+func Gate(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, now time.Time) (*Plan, error) {
+	plan := &Plan{applied: make(map[string]string)}
+	spec := cluster.Spec.MaintenanceWindow
+	if spec == nil {
+		cluster.Status.Maintenance = nil
+		return plan, nil
+	}
+
+	window, err := NewWindow(spec)
+	if err != nil {
+		return nil, err
+	}
+	open := window.Open(now)
+
+	previous := &k8splaygroundsv1alpha1.MaintenanceStatus{}
+	if cluster.Status.Maintenance != nil {
+		previous = cluster.Status.Maintenance
+	}
+	status := &k8splaygroundsv1alpha1.MaintenanceStatus{WindowOpen: open}
+	if !open {
+		if next, ok := window.Next(now); ok {
+			plan.NextWindow = next
+			nextWindow := metav1.NewTime(next)
+			status.NextWindow = &nextWindow
+		}
+	}
+
+	deferredSince := make(map[string]*metav1.Time)
+	for _, change := range previous.Deferred {
+		deferredSince[change.Kind+"/"+change.Name] = change.Since
+	}
+	deferChange := func(kind, name, message string) {
+		since := deferredSince[kind+"/"+name]
+		if since == nil {
+			t := metav1.NewTime(now)
+			since = &t
+		}
+		status.Deferred = append(status.Deferred, k8splaygroundsv1alpha1.DeferredChange{
+			Kind:    kind,
+			Name:    name,
+			Message: message,
+			Since:   since,
+		})
+	}
+
+	// hold reports whether a changed item must wait, recording the revision to keep applied
+	hold := func(key, desired string) bool {
+		applied, ok := previous.AppliedRevisions[key]
+		if open || !ok || applied == desired {
+			plan.applied[key] = desired
+			return false
+		}
+		plan.applied[key] = applied
+		return true
+	}
+
+	if applied := previous.AppliedRevisions[versionKey]; hold(versionKey, cluster.Spec.Version) {
+		deferChange(KindVersion, "", fmt.Sprintf("Upgrade from %s to %s", applied, cluster.Spec.Version))
+		cluster.Spec.Version = applied
+	}
+
+	statefulSets := cluster.Spec.StatefulSets[:0:0]
+	for _, statefulSet := range cluster.Spec.StatefulSets {
+		if hold(statefulSetKey(statefulSet.Name), templateHash(statefulSet.Template)) {
+			deferChange(KindStatefulSet, statefulSet.Name, "Pod template changed; applying it restarts the pods")
+			continue
+		}
+		statefulSets = append(statefulSets, statefulSet)
+	}
+	cluster.Spec.StatefulSets = statefulSets
+
+	if performance := cluster.Spec.Performance; performance != nil && performance.Enabled && performance.ResourceOptimization && !open {
+		plan.SkipResourceOptimization = true
+		deferChange(KindResourceOptimization, "", "Resource optimization applies wait for the window")
+	}
+
+	plan.Deferred = len(status.Deferred) > 0
+	status.AppliedRevisions = previous.AppliedRevisions
+	cluster.Status.Maintenance = status
+	return plan, nil
+}
+
+func statefulSetKey(name string) string {
+	return "statefulset/" + name
+}
+
+// templateHash returns a short hash of a pod template
+func templateHash(template k8splaygroundsv1alpha1.PodTemplateSpec) string {
+	// Pod templates only hold JSON-serializable fields, so marshaling cannot fail
+	data, _ := json.Marshal(template)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:10]
+}
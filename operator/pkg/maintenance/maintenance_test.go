@@ -0,0 +1,159 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// Saturdays 02:00-04:00 UTC
+var weekendWindow = &k8splaygroundsv1alpha1.MaintenanceWindowSpec{
+	Schedule: "0 2 * * 6",
+	Duration: metav1.Duration{Duration: 2 * time.Hour},
+}
+
+func newCluster(version, image string) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	cluster.Spec.Version = version
+	cluster.Spec.MaintenanceWindow = weekendWindow
+	cluster.Spec.StatefulSets = []k8splaygroundsv1alpha1.StatefulSetSpec{{Name: "db"}}
+	cluster.Spec.StatefulSets[0].Template.Metadata.Labels = map[string]string{"image": image}
+	return cluster
+}
+
+func TestWindowOpen(t *testing.T) {
+	window, err := NewWindow(weekendWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{time.Date(2026, 1, 3, 1, 59, 0, 0, time.UTC), false},
+		{time.Date(2026, 1, 3, 2, 0, 0, 0, time.UTC), true},
+		{time.Date(2026, 1, 3, 3, 59, 0, 0, time.UTC), true},
+		{time.Date(2026, 1, 3, 4, 0, 0, 0, time.UTC), false},
+		{time.Date(2026, 1, 7, 3, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := window.Open(tt.now); got != tt.want {
+			t.Errorf("Open(%s) = %v, want %v", tt.now, got, tt.want)
+		}
+	}
+
+	next, ok := window.Next(time.Date(2026, 1, 7, 3, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 1, 10, 2, 0, 0, 0, time.UTC); !ok || !next.Equal(want) {
+		t.Errorf("Next() = %s, want %s", next, want)
+	}
+}
+
+func TestNewWindowRejectsInvalidSpecs(t *testing.T) {
+	for _, spec := range []*k8splaygroundsv1alpha1.MaintenanceWindowSpec{
+		{Schedule: "not a cron", Duration: metav1.Duration{Duration: time.Hour}},
+		{Schedule: "0 2 * * 6"},
+		{Schedule: "0 2 * * 6", Duration: metav1.Duration{Duration: time.Hour}, TimeZone: "Nowhere/City"},
+	} {
+		if _, err := NewWindow(spec); err == nil {
+			t.Errorf("NewWindow(%+v) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestGateDefersDisruptiveChangesOutsideTheWindow(t *testing.T) {
+	closed := time.Date(2026, 1, 7, 12, 0, 0, 0, time.UTC)
+	open := time.Date(2026, 1, 10, 3, 0, 0, 0, time.UTC)
+
+	// First apply: nothing has been applied yet, so nothing is deferred
+	cluster := newCluster("1.28", "postgres:15")
+	plan, err := Gate(cluster, closed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Deferred {
+		t.Fatalf("deferred = %+v on first apply", cluster.Status.Maintenance.Deferred)
+	}
+	plan.Commit(cluster)
+	status := cluster.Status
+
+	// Upgrade and template change while the window is closed
+	cluster = newCluster("1.29", "postgres:16")
+	cluster.Spec.StatefulSets = append(cluster.Spec.StatefulSets, k8splaygroundsv1alpha1.StatefulSetSpec{Name: "cache"})
+	cluster.Status = status
+	plan, err = Gate(cluster, closed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Deferred || len(cluster.Status.Maintenance.Deferred) != 2 {
+		t.Fatalf("deferred = %+v, want the version and db", cluster.Status.Maintenance.Deferred)
+	}
+	if cluster.Spec.Version != "1.28" {
+		t.Errorf("version = %s, want the applied 1.28", cluster.Spec.Version)
+	}
+	if len(cluster.Spec.StatefulSets) != 1 || cluster.Spec.StatefulSets[0].Name != "cache" {
+		t.Errorf("statefulsets = %+v, want only the new cache", cluster.Spec.StatefulSets)
+	}
+	want := metav1.NewTime(time.Date(2026, 1, 10, 2, 0, 0, 0, time.UTC))
+	if next := cluster.Status.Maintenance.NextWindow; next == nil || !next.Equal(&want) {
+		t.Errorf("next window = %v, want %v", next, want)
+	}
+	plan.Commit(cluster)
+	since := cluster.Status.Maintenance.Deferred[0].Since
+	status = cluster.Status
+
+	// Still closed: the deferred changes keep when they were first held back
+	cluster = newCluster("1.29", "postgres:16")
+	cluster.Status = status
+	if _, err := Gate(cluster, closed.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := cluster.Status.Maintenance.Deferred[0].Since; !got.Equal(since) {
+		t.Errorf("since = %v, want %v carried over", got, since)
+	}
+	status = cluster.Status
+
+	// The window opens: everything applies
+	cluster = newCluster("1.29", "postgres:16")
+	cluster.Status = status
+	plan, err = Gate(cluster, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Deferred || cluster.Spec.Version != "1.29" || len(cluster.Spec.StatefulSets) != 1 {
+		t.Errorf("deferred = %+v, spec = %+v, want everything applied", cluster.Status.Maintenance.Deferred, cluster.Spec)
+	}
+	plan.Commit(cluster)
+	if got := cluster.Status.Maintenance.AppliedRevisions[versionKey]; got != "1.29" {
+		t.Errorf("applied version = %s, want 1.29", got)
+	}
+}
+
+func TestGateDefersResourceOptimization(t *testing.T) {
+	cluster := newCluster("1.28", "postgres:15")
+	cluster.Spec.Performance = &k8splaygroundsv1alpha1.PerformanceSpec{Enabled: true, ResourceOptimization: true}
+
+	plan, err := Gate(cluster, time.Date(2026, 1, 7, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.SkipResourceOptimization || cluster.Status.Maintenance.Deferred[0].Kind != KindResourceOptimization {
+		t.Errorf("plan = %+v, deferred = %+v, want resource optimization deferred", plan, cluster.Status.Maintenance.Deferred)
+	}
+}
+
+func TestGateWithoutWindowClearsStatus(t *testing.T) {
+	cluster := newCluster("1.29", "postgres:16")
+	cluster.Spec.MaintenanceWindow = nil
+	cluster.Status.Maintenance = &k8splaygroundsv1alpha1.MaintenanceStatus{WindowOpen: true}
+
+	plan, err := Gate(cluster, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Deferred || cluster.Status.Maintenance != nil {
+		t.Errorf("maintenance = %+v, want it cleared", cluster.Status.Maintenance)
+	}
+}
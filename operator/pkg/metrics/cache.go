@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// cacheTransformedObjects counts the objects passed through the cache transform
+	cacheTransformedObjects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aviatrix_operator_cache_transformed_objects_total",
+			Help: "Objects added to or updated in the informer cache after the transform stripped them",
+		},
+		[]string{"kind"},
+	)
+
+	// cacheObjectBytes sums the encoded size of cached objects before and after the transform
+	cacheObjectBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aviatrix_operator_cache_object_bytes_total",
+			Help: "Encoded size of objects entering the informer cache, before and after stripping managed fields and annotations",
+		},
+		[]string{"kind", "stage"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(cacheTransformedObjects, cacheObjectBytes)
+}
+
+// RecordCacheTransform counts an object entering the cache with its size before and after
+// the transform. Sizes are skipped when they are unknown.
+func RecordCacheTransform(kind string, before, after int) {
+	cacheTransformedObjects.WithLabelValues(kind).Inc()
+	if before > 0 {
+		cacheObjectBytes.WithLabelValues(kind, "before").Add(float64(before))
+		cacheObjectBytes.WithLabelValues(kind, "after").Add(float64(after))
+	}
+}
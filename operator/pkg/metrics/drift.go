@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// driftAges reports how long each custom resource has been out of sync
+	driftAges = newDriftAgeCollector()
+
+	// driftConvergenceSeconds records how long drifted resources took to converge
+	driftConvergenceSeconds = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "aviatrix_operator_drift_convergence_seconds",
+			Help:    "Time between first drift detection and convergence for custom resources",
+			Buckets: []float64{30, 60, 300, 900, 1800, 3600, 7200, 21600, 86400},
+		},
+		[]string{"kind"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(driftAges, driftConvergenceSeconds)
+}
+
+// driftKey identifies a custom resource in the drift age collector
+type driftKey struct {
+	kind, namespace, name string
+}
+
+// driftAgeCollector reports the drift age of custom resources from when drift was first
+// detected. The age is computed at scrape time, so it keeps growing between reconciles of a
+// resource that stays out of sync.
+type driftAgeCollector struct {
+	desc *prometheus.Desc
+	now  func() time.Time
+
+	mu         sync.Mutex
+	detectedAt map[driftKey]time.Time
+}
+
+func newDriftAgeCollector() *driftAgeCollector {
+	return &driftAgeCollector{
+		desc: prometheus.NewDesc(
+			"aviatrix_operator_drift_age_seconds",
+			"Seconds since spec-vs-reality drift was first detected for a custom resource, 0 when in sync",
+			[]string{"kind", "namespace", "name"}, nil,
+		),
+		now:        time.Now,
+		detectedAt: map[driftKey]time.Time{},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *driftAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *driftAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, detectedAt := range c.detectedAt {
+		var age float64
+		if !detectedAt.IsZero() {
+			age = now.Sub(detectedAt).Seconds()
+		}
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, age, key.kind, key.namespace, key.name)
+	}
+}
+
+func (c *driftAgeCollector) set(key driftKey, detectedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.detectedAt[key] = detectedAt
+}
+
+func (c *driftAgeCollector) delete(key driftKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.detectedAt, key)
+}
+
+// RecordDriftDetected records when drift was first detected for a custom resource; the zero
+// time marks a resource in sync, reported with a drift age of 0
+func RecordDriftDetected(kind, namespace, name string, detectedAt time.Time) {
+	driftAges.set(driftKey{kind, namespace, name}, detectedAt)
+}
+
+// RecordDriftConverged observes the convergence time of a resource that is back in sync
+func RecordDriftConverged(kind string, outOfSync time.Duration) {
+	driftConvergenceSeconds.WithLabelValues(kind).Observe(outOfSync.Seconds())
+}
+
+// DeleteDriftMetrics removes the drift series of a deleted custom resource
+func DeleteDriftMetrics(kind, namespace, name string) {
+	driftAges.delete(driftKey{kind, namespace, name})
+}
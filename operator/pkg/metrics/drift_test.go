@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// collectDriftAges scrapes the collector and returns the drift age by resource name
+func collectDriftAges(t *testing.T, c *driftAgeCollector) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 10)
+	c.Collect(ch)
+	close(ch)
+
+	ages := map[string]float64{}
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		for _, label := range m.GetLabel() {
+			if label.GetName() == "name" {
+				ages[label.GetValue()] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	return ages
+}
+
+func TestDriftAgeGrowsBetweenScrapes(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newDriftAgeCollector()
+	c.now = func() time.Time { return now }
+
+	c.set(driftKey{"AviatrixVpc", "networking", "hub"}, now.Add(-time.Minute))
+	c.set(driftKey{"AviatrixFirewall", "networking", "edge"}, time.Time{})
+
+	ages := collectDriftAges(t, c)
+	if ages["hub"] != 60 || ages["edge"] != 0 {
+		t.Fatalf("drift ages = %v, want hub 60 and edge 0", ages)
+	}
+
+	// No reconcile in between: the age still follows the clock
+	now = now.Add(time.Hour)
+	if ages := collectDriftAges(t, c); ages["hub"] != 3660 {
+		t.Errorf("drift age of hub = %v after an hour, want 3660", ages["hub"])
+	}
+
+	c.delete(driftKey{"AviatrixVpc", "networking", "hub"})
+	if _, ok := collectDriftAges(t, c)["hub"]; ok {
+		t.Error("deleted resource is still reported")
+	}
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// fleetClusters counts the clusters of a fleet by phase
+	fleetClusters = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_fleet_clusters",
+			Help: "Number of clusters in a K8sPlaygroundsFleet by phase",
+		},
+		[]string{"fleet", "phase"},
+	)
+
+	// fleetClustersByHealth counts the clusters of a fleet by health
+	fleetClustersByHealth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_fleet_clusters_by_health",
+			Help: "Number of clusters in a K8sPlaygroundsFleet by health",
+		},
+		[]string{"fleet", "health"},
+	)
+
+	// fleetFailingComponents reports the failing components across a fleet
+	fleetFailingComponents = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_fleet_failing_components",
+			Help: "Number of failing components across the clusters of a K8sPlaygroundsFleet",
+		},
+		[]string{"fleet"},
+	)
+
+	// fleetSlowestConverging reports how long the slowest cluster of a fleet has been converging
+	fleetSlowestConverging = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_fleet_slowest_converging_seconds",
+			Help: "Seconds the slowest unconverged cluster of a K8sPlaygroundsFleet has been converging",
+		},
+		[]string{"fleet"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(fleetClusters, fleetClustersByHealth, fleetFailingComponents, fleetSlowestConverging)
+}
+
+// RecordFleet replaces the series of a fleet with its current counts
+func RecordFleet(fleet string, phases, health map[string]int32, failingComponents int, slowestSeconds float64) {
+	DeleteFleetMetrics(fleet)
+	for phase, count := range phases {
+		fleetClusters.WithLabelValues(fleet, phase).Set(float64(count))
+	}
+	for state, count := range health {
+		fleetClustersByHealth.WithLabelValues(fleet, state).Set(float64(count))
+	}
+	fleetFailingComponents.WithLabelValues(fleet).Set(float64(failingComponents))
+	fleetSlowestConverging.WithLabelValues(fleet).Set(slowestSeconds)
+}
+
+// DeleteFleetMetrics removes the series of a fleet
+func DeleteFleetMetrics(fleet string) {
+	labels := prometheus.Labels{"fleet": fleet}
+	fleetClusters.DeletePartialMatch(labels)
+	fleetClustersByHealth.DeletePartialMatch(labels)
+	fleetFailingComponents.DeletePartialMatch(labels)
+	fleetSlowestConverging.DeletePartialMatch(labels)
+}
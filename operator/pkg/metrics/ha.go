@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// operatorLeader reports whether a replica leads
+	operatorLeader = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aviatrix_operator_leader",
+			Help: "1 while this replica is the elected leader that reconciles, 0 while it is a read-only standby",
+		},
+		[]string{"identity"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(operatorLeader)
+}
+
+// RecordLeader sets whether the replica with identity leads
+func RecordLeader(identity string, leader bool) {
+	value := 0.0
+	if leader {
+		value = 1
+	}
+	operatorLeader.WithLabelValues(identity).Set(value)
+}
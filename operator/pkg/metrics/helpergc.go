@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// helpersReclaimed counts the helper objects removed by the garbage collector
+	helpersReclaimed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8s_playgrounds_helper_objects_reclaimed_total",
+			Help: "Number of DNS test and service discovery helper objects removed by the garbage collector, by kind and reason (completed, expired, orphaned, stale)",
+		},
+		[]string{"kind", "reason"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(helpersReclaimed)
+}
+
+// RecordHelperReclaimed counts a helper object removed by the garbage collector
+func RecordHelperReclaimed(kind, reason string) {
+	helpersReclaimed.WithLabelValues(kind, reason).Inc()
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// iptablesDivergentNodes reports how many nodes run iptables rules other than the desired ones
+var iptablesDivergentNodes = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "k8s_playgrounds_iptables_divergent_nodes",
+		Help: "Number of nodes whose applied iptables rules differ from the desired rules of a HeadlessService",
+	},
+	[]string{"namespace", "name"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(iptablesDivergentNodes)
+}
+
+// RecordIptablesDivergentNodes sets the divergent node gauge for a headless service
+func RecordIptablesDivergentNodes(namespace, name string, count int) {
+	iptablesDivergentNodes.WithLabelValues(namespace, name).Set(float64(count))
+}
+
+// DeleteIptablesMetrics removes the iptables series of a headless service
+func DeleteIptablesMetrics(namespace, name string) {
+	iptablesDivergentNodes.DeleteLabelValues(namespace, name)
+}
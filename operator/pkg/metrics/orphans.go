@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// orphanedResources counts the resources found by the last orphan scan
+	orphanedResources = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "aviatrix_operator_orphaned_resources",
+			Help: "Gateways and VPCs tagged by the operator that no custom resource tracks, by resource type (gw, vpc), as of the last orphan scan",
+		},
+		[]string{"resource_type"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphanedResources)
+}
+
+// RecordOrphans sets the number of orphans of a resource type found by a scan
+func RecordOrphans(resourceType string, count int) {
+	orphanedResources.WithLabelValues(resourceType).Set(float64(count))
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// dnsTestHealthy reports whether the DNS test of a headless service passes
+	dnsTestHealthy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_dns_test_healthy",
+			Help: "Whether DNS resolution of a HeadlessService is healthy (1) or failing (0)",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// dnsTestConsecutiveFailures reports the failed DNS tests in a row of a headless service
+	dnsTestConsecutiveFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_dns_test_consecutive_failures",
+			Help: "Number of consecutive failed DNS tests of a HeadlessService",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	// clusterChecks counts the health checks of a cluster by state
+	clusterChecks = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_cluster_checks",
+			Help: "Number of health checks of a K8sPlaygroundsCluster by state",
+		},
+		[]string{"namespace", "cluster", "state"},
+	)
+
+	// probesThrottled counts the DNS tests and discovery lookups held back by the probe gate
+	probesThrottled = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "k8s_playgrounds_probes_throttled_total",
+			Help: "Number of HeadlessService probes held back by rate limits or the DNS circuit breaker",
+		},
+		[]string{"probe", "reason"},
+	)
+
+	// dnsCircuitState reports the state of the circuit breaker on the DNS service
+	dnsCircuitState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_dns_circuit_state",
+			Help: "State of the circuit breaker on the DNS service; only the current state is reported, as 1",
+		},
+		[]string{"state"},
+	)
+
+	// dnsErrorRatio reports the share of recent DNS tests failing with server errors
+	dnsErrorRatio = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "k8s_playgrounds_dns_error_ratio",
+			Help: "Share of the DNS tests within the circuit breaker window that failed with server errors",
+		},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(dnsTestHealthy, dnsTestConsecutiveFailures, clusterChecks, probesThrottled, dnsCircuitState, dnsErrorRatio)
+}
+
+// RecordDNSTest sets the DNS test gauges of a headless service
+func RecordDNSTest(namespace, name string, healthy bool, consecutiveFailures int32) {
+	value := 0.0
+	if healthy {
+		value = 1
+	}
+	dnsTestHealthy.WithLabelValues(namespace, name).Set(value)
+	dnsTestConsecutiveFailures.WithLabelValues(namespace, name).Set(float64(consecutiveFailures))
+}
+
+// DeleteDNSTestMetrics removes the DNS test series of a headless service
+func DeleteDNSTestMetrics(namespace, name string) {
+	dnsTestHealthy.DeleteLabelValues(namespace, name)
+	dnsTestConsecutiveFailures.DeleteLabelValues(namespace, name)
+}
+
+// RecordClusterChecks sets the number of passing and failing health checks of a cluster
+func RecordClusterChecks(namespace, cluster string, passing, failing int) {
+	clusterChecks.WithLabelValues(namespace, cluster, "passing").Set(float64(passing))
+	clusterChecks.WithLabelValues(namespace, cluster, "failing").Set(float64(failing))
+}
+
+// DeleteClusterCheckMetrics removes the health check series of a cluster
+func DeleteClusterCheckMetrics(namespace, cluster string) {
+	clusterChecks.DeleteLabelValues(namespace, cluster, "passing")
+	clusterChecks.DeleteLabelValues(namespace, cluster, "failing")
+}
+
+// RecordProbeThrottled counts a probe held back by the probe gate
+func RecordProbeThrottled(probe, reason string) {
+	probesThrottled.WithLabelValues(probe, reason).Inc()
+}
+
+// RecordDNSCircuit sets the state of the circuit breaker on the DNS service and its error ratio
+func RecordDNSCircuit(state string, errorRatio float64) {
+	dnsCircuitState.Reset()
+	dnsCircuitState.WithLabelValues(state).Set(1)
+	dnsErrorRatio.Set(errorRatio)
+}
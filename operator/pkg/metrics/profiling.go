@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// profileCaptures counts the profiles the self-profiler captured
+	profileCaptures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "aviatrix_operator_profile_captures_total",
+			Help: "Profiles captured by the self-profiler by trigger (reconcile_latency, memory) and result (stored, uploaded, failed)",
+		},
+		[]string{"trigger", "result"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(profileCaptures)
+}
+
+// RecordProfileCapture counts a profile capture by what triggered it and how it ended
+func RecordProfileCapture(trigger, result string) {
+	profileCaptures.WithLabelValues(trigger, result).Inc()
+}
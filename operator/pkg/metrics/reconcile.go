@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// reconcileFailures counts failed reconciles by the reason reported in the Ready condition
+var reconcileFailures = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "aviatrix_operator_reconcile_failures_total",
+		Help: "Number of failed reconciles of Aviatrix custom resources, by kind and the reason of their Ready condition",
+	},
+	[]string{"kind", "reason"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileFailures)
+}
+
+// RecordReconcileFailure counts a failed reconcile. reason must be an enumerated reason, see
+// conditions.Label, so the number of series stays bounded.
+func RecordReconcileFailure(kind, reason string) {
+	reconcileFailures.WithLabelValues(kind, reason).Inc()
+}
@@ -0,0 +1,300 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// crdGVK identifies CustomResourceDefinitions, read as unstructured objects
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// Resource describes how a custom resource of the migrated group is stored
+type Resource struct {
+	// CRD is the name of the CustomResourceDefinition
+	CRD string
+	// Kind is the kind of the custom resource
+	Kind string
+	// ListKind is the kind of lists of the custom resource
+	ListKind string
+	// Plural is the resource name of the custom resource in API paths and RBAC rules
+	Plural string
+	// ServedVersions are the versions the API server serves
+	ServedVersions []string
+	// StorageVersion is the version new writes are stored at
+	StorageVersion string
+	// StoredVersions are the versions objects may still be stored at
+	StoredVersions []string
+}
+
+// NeedsMigration reports whether objects may be stored at a version other than the storage version
+func (r Resource) NeedsMigration() bool {
+	return len(r.StoredVersions) != 1 || r.StoredVersions[0] != r.StorageVersion
+}
+
+// ObjectError is a stored object that could not be decoded or rewritten
+type ObjectError struct {
+	Namespace string
+	Name      string
+	Err       error
+}
+
+// ResourceResult is the outcome of migrating the objects of one resource
+type ResourceResult struct {
+	Resource
+	// Migrated is the number of objects rewritten at the storage version
+	Migrated int
+	// Failed lists objects that were left untouched
+	Failed []ObjectError
+}
+
+// Report is the outcome of a migration run
+type Report struct {
+	DryRun    bool
+	Resources []ResourceResult
+}
+
+// Failed returns the number of objects that could not be migrated
+func (r *Report) Failed() int {
+	failed := 0
+	for _, resource := range r.Resources {
+		failed += len(resource.Failed)
+	}
+	return failed
+}
+
+// Write prints the report as a table followed by every object that was not migrated
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "RESOURCE\tSTORAGE VERSION\tSTORED VERSIONS\tMIGRATED\tFAILED")
+	for _, resource := range r.Resources {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\n", resource.CRD, resource.StorageVersion, strings.Join(resource.StoredVersions, ","), resource.Migrated, len(resource.Failed))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, resource := range r.Resources {
+		for _, failure := range resource.Failed {
+			name := failure.Name
+			if failure.Namespace != "" {
+				name = failure.Namespace + "/" + name
+			}
+			fmt.Fprintf(w, "failed %s %s: %v\n", resource.Kind, name, failure.Err)
+		}
+	}
+	if r.DryRun {
+		fmt.Fprintln(w, "dry run: no objects were rewritten")
+	}
+	return nil
+}
+
+// Migrator rewrites the stored objects of an API group at their current storage version
+type Migrator struct {
+	client client.Client
+	scheme *runtime.Scheme
+	group  string
+}
+
+// NewMigrator creates a new storage migrator for an API group. The scheme decides which
+// objects the operator can decode.
+func NewMigrator(client client.Client, scheme *runtime.Scheme, group string) *Migrator {
+	return &Migrator{
+		client: client,
+		scheme: scheme,
+		group:  group,
+	}
+}
+
+// Resources returns the custom resources of the group sorted by CRD name
+func (m *Migrator) Resources(ctx context.Context) ([]Resource, error) {
+	crds := &unstructured.UnstructuredList{}
+	crds.SetGroupVersionKind(crdGVK.GroupVersion().WithKind(crdGVK.Kind + "List"))
+	if err := m.client.List(ctx, crds); err != nil {
+		return nil, fmt.Errorf("failed to list CustomResourceDefinitions: %w", err)
+	}
+
+	var resources []Resource
+	for i := range crds.Items {
+		group, _, _ := unstructured.NestedString(crds.Items[i].Object, "spec", "group")
+		if group != m.group {
+			continue
+		}
+		resource, err := parseCRD(&crds.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, resource)
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].CRD < resources[j].CRD })
+	return resources, nil
+}
+
+// parseCRD reads the storage layout of a CustomResourceDefinition
+func parseCRD(crd *unstructured.Unstructured) (Resource, error) {
+	resource := Resource{CRD: crd.GetName()}
+	resource.Kind, _, _ = unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	resource.ListKind, _, _ = unstructured.NestedString(crd.Object, "spec", "names", "listKind")
+	if resource.ListKind == "" {
+		resource.ListKind = resource.Kind + "List"
+	}
+	resource.Plural, _, _ = unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	resource.StoredVersions, _, _ = unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := version["name"].(string)
+		if served, _ := version["served"].(bool); served {
+			resource.ServedVersions = append(resource.ServedVersions, name)
+		}
+		if storage, _ := version["storage"].(bool); storage {
+			resource.StorageVersion = name
+		}
+	}
+	if resource.StorageVersion == "" {
+		return Resource{}, fmt.Errorf("CustomResourceDefinition %s has no storage version", resource.CRD)
+	}
+	return resource, nil
+}
+
+// IncompatibleVersions returns the stored versions of a resource the scheme cannot decode
+func IncompatibleVersions(scheme *runtime.Scheme, group string, resource Resource) []string {
+	var incompatible []string
+	for _, version := range resource.StoredVersions {
+		if !scheme.Recognizes(schema.GroupVersionKind{Group: group, Version: version, Kind: resource.Kind}) {
+			incompatible = append(incompatible, version)
+		}
+	}
+	return incompatible
+}
+
+// Check returns an error naming every resource with objects stored at a version the operator
+// cannot decode, so the operator can refuse to start until they are migrated
+func (m *Migrator) Check(ctx context.Context) error {
+	resources, err := m.Resources(ctx)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	for _, resource := range resources {
+		if incompatible := IncompatibleVersions(m.scheme, m.group, resource); len(incompatible) > 0 {
+			problems = append(problems, fmt.Sprintf("%s stores %s", resource.CRD, strings.Join(incompatible, ",")))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("incompatible storage versions (%s); run the storage migration first", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Migrate reads every object of the group at its storage version, checks that the operator can
+// decode it and writes it back unchanged so the API server re-encodes it at the storage
+// version. Once every object of a resource is rewritten, its stored versions are reset to the
+// storage version. Undecodable objects are reported and left untouched; a dry run only decodes.
+func (m *Migrator) Migrate(ctx context.Context, dryRun bool) (*Report, error) {
+	resources, err := m.Resources(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{DryRun: dryRun}
+	for _, resource := range resources {
+		result, err := m.migrateResource(ctx, resource, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		report.Resources = append(report.Resources, *result)
+	}
+	return report, nil
+}
+
+// migrateResource rewrites the objects of one resource
+func (m *Migrator) migrateResource(ctx context.Context, resource Resource, dryRun bool) (*ResourceResult, error) {
+	result := &ResourceResult{Resource: resource}
+	gv := schema.GroupVersion{Group: m.group, Version: resource.StorageVersion}
+
+	objects := &unstructured.UnstructuredList{}
+	objects.SetGroupVersionKind(gv.WithKind(resource.ListKind))
+	if err := m.client.List(ctx, objects); err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", resource.CRD, err)
+	}
+
+	for i := range objects.Items {
+		object := &objects.Items[i]
+		if err := Decode(m.scheme, object); err != nil {
+			result.Failed = append(result.Failed, ObjectError{Namespace: object.GetNamespace(), Name: object.GetName(), Err: err})
+			continue
+		}
+		if !dryRun {
+			if err := m.rewrite(ctx, object); err != nil {
+				result.Failed = append(result.Failed, ObjectError{Namespace: object.GetNamespace(), Name: object.GetName(), Err: err})
+				continue
+			}
+		}
+		result.Migrated++
+	}
+
+	// Older versions can only be dropped from the CRD once nothing is stored at them
+	if !dryRun && len(result.Failed) == 0 && resource.NeedsMigration() {
+		if err := m.resetStoredVersions(ctx, resource); err != nil {
+			return nil, err
+		}
+		result.StoredVersions = []string{resource.StorageVersion}
+	}
+	return result, nil
+}
+
+// Decode checks that the operator can decode an object, including that it carries no fields
+// unknown to the operator's types
+func Decode(scheme *runtime.Scheme, object *unstructured.Unstructured) error {
+	typed, err := scheme.New(object.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructuredWithValidation(object.Object, typed, true)
+}
+
+// rewrite writes an object back unchanged, refetching it when it changed in the meantime
+func (m *Migrator) rewrite(ctx context.Context, object *unstructured.Unstructured) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := m.client.Update(ctx, object)
+		if err == nil || !apierrors.IsConflict(err) {
+			return err
+		}
+		if getErr := m.client.Get(ctx, client.ObjectKeyFromObject(object), object); getErr != nil {
+			return getErr
+		}
+		return err
+	})
+}
+
+// resetStoredVersions records that objects of a resource are only stored at the storage version
+func (m *Migrator) resetStoredVersions(ctx context.Context, resource Resource) error {
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(crdGVK)
+	if err := m.client.Get(ctx, client.ObjectKey{Name: resource.CRD}, crd); err != nil {
+		return fmt.Errorf("failed to get CustomResourceDefinition %s: %w", resource.CRD, err)
+	}
+	if err := unstructured.SetNestedStringSlice(crd.Object, []string{resource.StorageVersion}, "status", "storedVersions"); err != nil {
+		return err
+	}
+	if err := m.client.Status().Update(ctx, crd); err != nil {
+		return fmt.Errorf("failed to update stored versions of %s: %w", resource.CRD, err)
+	}
+	return nil
+}
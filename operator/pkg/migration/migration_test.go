@@ -0,0 +1,116 @@
+package migration
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestParseCRD(t *testing.T) {
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "aviatrixgateways.aviatrix.k8s.io"},
+		"spec": map[string]interface{}{
+			"group": "aviatrix.k8s.io",
+			"names": map[string]interface{}{"kind": "AviatrixGateway", "plural": "aviatrixgateways"},
+			"versions": []interface{}{
+				map[string]interface{}{"name": "v1alpha1", "served": false, "storage": false},
+				map[string]interface{}{"name": "v1beta1", "served": true, "storage": true},
+			},
+		},
+		"status": map[string]interface{}{"storedVersions": []interface{}{"v1alpha1", "v1beta1"}},
+	}}
+
+	resource, err := parseCRD(crd)
+	if err != nil {
+		t.Fatalf("parseCRD() error = %v", err)
+	}
+	want := Resource{
+		CRD:            "aviatrixgateways.aviatrix.k8s.io",
+		Kind:           "AviatrixGateway",
+		ListKind:       "AviatrixGatewayList",
+		Plural:         "aviatrixgateways",
+		ServedVersions: []string{"v1beta1"},
+		StorageVersion: "v1beta1",
+		StoredVersions: []string{"v1alpha1", "v1beta1"},
+	}
+	if !reflect.DeepEqual(resource, want) {
+		t.Errorf("parseCRD() = %+v, want %+v", resource, want)
+	}
+	if !resource.NeedsMigration() {
+		t.Error("expected a resource with two stored versions to need migration")
+	}
+
+	resource.StoredVersions = []string{"v1beta1"}
+	if resource.NeedsMigration() {
+		t.Error("expected a resource stored only at its storage version to be migrated")
+	}
+
+	unstructured.RemoveNestedField(crd.Object, "spec", "versions")
+	if _, err := parseCRD(crd); err == nil {
+		t.Error("expected an error for a CRD without a storage version")
+	}
+}
+
+func TestIncompatibleVersions(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	resource := Resource{Kind: "ConfigMap", StoredVersions: []string{"v1", "v1beta1"}}
+	if got, want := IncompatibleVersions(scheme, "", resource), []string{"v1beta1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("IncompatibleVersions() = %v, want %v", got, want)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	object := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "config"},
+		"data":       map[string]interface{}{"key": "value"},
+	}}
+	if err := Decode(scheme, object); err != nil {
+		t.Errorf("Decode() error = %v", err)
+	}
+
+	object.Object["legacyField"] = "value"
+	if err := Decode(scheme, object); err == nil || !strings.Contains(err.Error(), "legacyField") {
+		t.Errorf("Decode() error = %v, want unknown field legacyField", err)
+	}
+
+	object.SetAPIVersion("v1beta1")
+	if err := Decode(scheme, object); err == nil {
+		t.Error("expected an error for a version unknown to the scheme")
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	report := &Report{Resources: []ResourceResult{{
+		Resource: Resource{CRD: "aviatrixvpcs.aviatrix.k8s.io", Kind: "AviatrixVpc", StorageVersion: "v1alpha1", StoredVersions: []string{"v1alpha1"}},
+		Migrated: 2,
+		Failed:   []ObjectError{{Namespace: "default", Name: "broken", Err: errors.New("bad field")}},
+	}}}
+	if report.Failed() != 1 {
+		t.Errorf("Failed() = %d, want 1", report.Failed())
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "failed AviatrixVpc default/broken: bad field") {
+		t.Errorf("report does not list the failed object:\n%s", out.String())
+	}
+}
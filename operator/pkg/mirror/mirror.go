@@ -0,0 +1,125 @@
+package mirror
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/serviceports"
+)
+
+// ConditionSynced reports whether the selector and ports were copied from the mirrored Service
+const ConditionSynced = "MirrorSynced"
+
+// Validate checks the mirror settings of a headless service
+func Validate(headlessService *k8splaygroundsv1alpha1.HeadlessService) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if headlessService.Spec.Mirror == nil {
+		if len(headlessService.Spec.Ports) == 0 {
+			errs = append(errs, field.Required(specPath.Child("ports"), "ports are required unless a Service is mirrored"))
+		}
+		return errs
+	}
+
+	mirrorPath := specPath.Child("mirror", "serviceName")
+	switch headlessService.Spec.Mirror.ServiceName {
+	case "":
+		errs = append(errs, field.Required(mirrorPath, "the Service to mirror is required"))
+	case headlessService.Name:
+		// The managed copy is named after the headless service and would replace the source
+		errs = append(errs, field.Invalid(mirrorPath, headlessService.Spec.Mirror.ServiceName, "must differ from the HeadlessService name"))
+	}
+	if len(headlessService.Spec.Selector) > 0 {
+		errs = append(errs, field.Forbidden(specPath.Child("selector"), "the selector is copied from the mirrored Service"))
+	}
+	if len(headlessService.Spec.Ports) > 0 {
+		errs = append(errs, field.Forbidden(specPath.Child("ports"), "ports are copied from the mirrored Service"))
+	}
+	return errs
+}
+
+// Apply copies the selector and ports of source into the spec of the headless service. The
+// copy is made on every reconcile and never written back to the HeadlessService object.
+func Apply(headlessService *k8splaygroundsv1alpha1.HeadlessService, source *corev1.Service) error {
+	if len(source.Spec.Selector) == 0 {
+		return fmt.Errorf("service %s has no selector to mirror", source.Name)
+	}
+
+	selector := make(map[string]string, len(source.Spec.Selector))
+	for key, value := range source.Spec.Selector {
+		selector[key] = value
+	}
+	headlessService.Spec.Selector = selector
+	headlessService.Spec.Ports = serviceports.FromCore(source.Spec.Ports)
+	serviceports.Default(headlessService.Spec.Ports)
+	// Node ports belong to the source Service; the managed copy is headless
+	for i := range headlessService.Spec.Ports {
+		headlessService.Spec.Ports[i].NodePort = 0
+	}
+
+	headlessService.Status.Mirror = &k8splaygroundsv1alpha1.MirrorStatus{
+		ServiceName:     source.Name,
+		ResourceVersion: source.ResourceVersion,
+		SyncedAt:        metav1.Now(),
+	}
+	return nil
+}
+
+// Manager keeps headless services in sync with the Services they mirror
+type Manager struct {
+	client client.Client
+}
+
+// NewManager creates a new mirror manager
+func NewManager(client client.Client) *Manager {
+	return &Manager{
+		client: client,
+	}
+}
+
+// Sync copies the selector and ports of the mirrored Service into the headless service and
+// records the outcome in the MirrorSynced condition. It does nothing when no Service is
+// mirrored.
+func (m *Manager) Sync(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	spec := headlessService.Spec.Mirror
+	if spec == nil {
+		headlessService.Status.Mirror = nil
+		meta.RemoveStatusCondition(&headlessService.Status.Conditions, ConditionSynced)
+		return nil
+	}
+
+	source := &corev1.Service{}
+	err := m.client.Get(ctx, types.NamespacedName{Name: spec.ServiceName, Namespace: headlessService.Namespace}, source)
+	if err == nil {
+		err = Apply(headlessService, source)
+	} else if apierrors.IsNotFound(err) {
+		err = fmt.Errorf("service %s not found", spec.ServiceName)
+	}
+	if err != nil {
+		meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+			Type:    ConditionSynced,
+			Status:  metav1.ConditionFalse,
+			Reason:  "SourceUnavailable",
+			Message: err.Error(),
+		})
+		return fmt.Errorf("failed to mirror service: %w", err)
+	}
+
+	meta.SetStatusCondition(&headlessService.Status.Conditions, metav1.Condition{
+		Type:    ConditionSynced,
+		Status:  metav1.ConditionTrue,
+		Reason:  "Synced",
+		Message: fmt.Sprintf("Selector and ports mirror service %s", spec.ServiceName),
+	})
+	return nil
+}
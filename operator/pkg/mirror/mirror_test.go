@@ -0,0 +1,84 @@
+package mirror
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func helmService() *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web", ResourceVersion: "42"},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeNodePort,
+			Selector: map[string]string{"app.kubernetes.io/name": "web"},
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, TargetPort: intstr.FromString("http"), Protocol: corev1.ProtocolTCP, NodePort: 30080},
+			},
+		},
+	}
+}
+
+func mirroring(serviceName string) *k8splaygroundsv1alpha1.HeadlessService {
+	return &k8splaygroundsv1alpha1.HeadlessService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-headless"},
+		Spec:       k8splaygroundsv1alpha1.HeadlessServiceSpec{Mirror: &k8splaygroundsv1alpha1.MirrorSpec{ServiceName: serviceName}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if errs := Validate(mirroring("web")); len(errs) != 0 {
+		t.Errorf("Validate() = %v for a valid mirror", errs)
+	}
+	if errs := Validate(mirroring("web-headless")); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want the self reference rejected", errs)
+	}
+
+	conflicting := mirroring("web")
+	conflicting.Spec.Selector = map[string]string{"app": "web"}
+	conflicting.Spec.Ports = []k8splaygroundsv1alpha1.ServicePort{{Port: 80}}
+	if errs := Validate(conflicting); len(errs) != 2 {
+		t.Errorf("Validate() = %v, want selector and ports forbidden", errs)
+	}
+
+	if errs := Validate(&k8splaygroundsv1alpha1.HeadlessService{}); len(errs) != 1 {
+		t.Errorf("Validate() = %v, want ports required without a mirror", errs)
+	}
+}
+
+func TestSync(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(helmService()).Build()
+	m := NewManager(c)
+	ctx := context.Background()
+
+	headlessService := mirroring("web")
+	if err := m.Sync(ctx, headlessService); err != nil {
+		t.Fatal(err)
+	}
+	if headlessService.Spec.Selector["app.kubernetes.io/name"] != "web" {
+		t.Errorf("selector = %v", headlessService.Spec.Selector)
+	}
+	port := headlessService.Spec.Ports[0]
+	if port.Port != 80 || port.TargetPort != intstr.FromString("http") || port.NodePort != 0 {
+		t.Errorf("port = %+v, want port 80 to the named target without the node port", port)
+	}
+	if headlessService.Status.Mirror.ResourceVersion != "42" || !meta.IsStatusConditionTrue(headlessService.Status.Conditions, ConditionSynced) {
+		t.Errorf("status = %+v", headlessService.Status)
+	}
+
+	missing := mirroring("api")
+	if err := m.Sync(ctx, missing); err == nil {
+		t.Error("Sync() succeeded for a missing Service")
+	}
+	if condition := meta.FindStatusCondition(missing.Status.Conditions, ConditionSynced); condition == nil || condition.Status != metav1.ConditionFalse {
+		t.Errorf("conditions = %v, want MirrorSynced false", missing.Status.Conditions)
+	}
+}
@@ -0,0 +1,237 @@
+package naming
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// MaxLength is the length of a DNS label. Child names are kept within it so that they are
+// valid for every kind, including Services, and usable as label values such as job-name.
+const MaxLength = validation.DNS1123LabelMaxLength
+
+// Placeholders of a name template: the name of the parent and, for kinds naming one of
+// several children of a parent, the qualifier telling them apart
+const (
+	ParentPlaceholder    = "{name}"
+	QualifierPlaceholder = "{qualifier}"
+)
+
+// Kinds of derived child names
+const (
+	IPTables            = "iptables"
+	IPTablesGroup       = "iptables-group"
+	IPTablesRules       = "iptables-rules"
+	IPTablesRulesGroup  = "iptables-rules-group"
+	IPTablesAgent       = "iptables-agent"
+	IPTablesCanary      = "iptables-canary"
+	IPTablesCanaryGroup = "iptables-canary-group"
+	DNSConfig           = "dns-config"
+	DNSTest             = "dns-test"
+	ClusterIP           = "clusterip"
+	DiscoveryConfig     = "discovery-config"
+	DiscoveryPod        = "discovery-pod"
+	Peers               = "peers"
+	Federation          = "federation"
+	LoadTestReport      = "loadtest-report"
+	LoadTestJob         = "loadtest-job"
+	HookJob             = "hook-job"
+	SeedJob             = "seed-job"
+	CheckPod            = "check-pod"
+	Access              = "access"
+	Kubeconfig          = "kubeconfig"
+	LogForwarder        = "log-forwarder"
+	OIDCBinding         = "oidc-binding"
+	TrafficPolicy       = "traffic-networkpolicy"
+	TrafficMicroseg     = "traffic-microseg"
+	RotatedCredentials  = "rotated-credentials"
+	UndeleteRecord      = "undelete-record"
+	ExternalEndpoints   = "external-endpoints"
+	PodEndpoints        = "pod-endpoints"
+	Dashboard           = "dashboard"
+	DemoWorkload        = "demo-workload"
+)
+
+// defaultTemplates are the names children had before templates were configurable; changing
+// one renames the children of existing parents
+var defaultTemplates = map[string]string{
+	IPTables:            "{name}-iptables",
+	IPTablesGroup:       "{name}-iptables-{qualifier}",
+	IPTablesRules:       "{name}-iptables-rules",
+	IPTablesRulesGroup:  "{name}-iptables-rules-{qualifier}",
+	IPTablesAgent:       "{name}-iptables-agent",
+	IPTablesCanary:      "{name}-iptables-canary",
+	IPTablesCanaryGroup: "{name}-iptables-canary-{qualifier}",
+	DNSConfig:           "{name}-dns-config",
+	DNSTest:             "{name}-dns-test",
+	ClusterIP:           "{name}-clusterip",
+	DiscoveryConfig:     "{name}-{qualifier}-discovery",
+	DiscoveryPod:        "{name}-discovery-{qualifier}",
+	Peers:               "{name}-peers",
+	Federation:          "{name}-federation",
+	LoadTestReport:      "{name}-loadtest",
+	LoadTestJob:         "{name}-loadtest-{qualifier}",
+	HookJob:             "{name}-{qualifier}",
+	SeedJob:             "{name}-seed-{qualifier}",
+	CheckPod:            "{name}-check-{qualifier}",
+	Access:              "{name}-access",
+	Kubeconfig:          "{name}-kubeconfig",
+	LogForwarder:        "{name}-log-forwarder",
+	OIDCBinding:         "{name}-oidc-{qualifier}",
+	TrafficPolicy:       "{name}-{qualifier}",
+	TrafficMicroseg:     "{name}-{qualifier}",
+	RotatedCredentials:  "{name}-credentials",
+	UndeleteRecord:      "{name}-undelete",
+	ExternalEndpoints:   "{name}-external-{qualifier}",
+	PodEndpoints:        "{name}-pods-{qualifier}",
+	Dashboard:           "{name}-dashboard",
+	DemoWorkload:        "{name}-demo",
+}
+
+// Default holds the name templates of the running operator
+var Default = NewTemplates()
+
+// Name returns the name of the child of a parent with the default templates
+func Name(kind, parent string) string {
+	return Default.Name(kind, parent, "")
+}
+
+// Qualified returns the name of one of several children of a parent with the default templates
+func Qualified(kind, parent, qualifier string) string {
+	return Default.Name(kind, parent, qualifier)
+}
+
+// Templates renders the names of child resources. It implements flag.Value so it can be set
+// with a repeated --name-template=kind=template, e.g. iptables-rules={name}-ipt.
+type Templates struct {
+	mu        sync.RWMutex
+	overrides map[string]string
+}
+
+// NewTemplates creates templates rendering the default names
+func NewTemplates() *Templates {
+	return &Templates{overrides: map[string]string{}}
+}
+
+// template returns the template of a kind. Kinds without a template are appended to the name
+// of the parent.
+func (t *Templates) template(kind string) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if template, ok := t.overrides[kind]; ok {
+		return template
+	}
+	if template, ok := defaultTemplates[kind]; ok {
+		return template
+	}
+	return ParentPlaceholder + "-" + kind
+}
+
+// Name renders the name of a child. Names longer than MaxLength keep the rest of the template
+// and shorten the parent name, which is suffixed with a hash of the full name so that parents
+// sharing a long prefix keep distinct children. When the rest alone does not fit, the whole
+// name is truncated.
+func (t *Templates) Name(kind, parent, qualifier string) string {
+	template := t.template(kind)
+	name := render(template, parent, qualifier)
+	if len(name) <= MaxLength {
+		return name
+	}
+
+	suffix := "-" + hash(name)
+	if room := MaxLength - (len(name) - len(parent)) - len(suffix); room > 0 {
+		if short := strings.TrimRight(parent[:room], "-."); short != "" {
+			return render(template, short+suffix, qualifier)
+		}
+	}
+	return Truncate(name)
+}
+
+// Set parses a kind=template pair. Setting the same kind again replaces its template.
+func (t *Templates) Set(value string) error {
+	kind, template, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("missing template for name template %s", value)
+	}
+	kind, template = strings.TrimSpace(kind), strings.TrimSpace(template)
+	if err := Validate(kind, template); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.overrides[kind] = template
+	return nil
+}
+
+// String returns the overridden templates as kind=template pairs
+func (t *Templates) String() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	pairs := make([]string, 0, len(t.overrides))
+	for kind, template := range t.overrides {
+		pairs = append(pairs, kind+"="+template)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Usage returns the help text of the flag, listing the kinds and their default templates
+func (t *Templates) Usage() string {
+	kinds := make([]string, 0, len(defaultTemplates))
+	for kind, template := range defaultTemplates {
+		kinds = append(kinds, kind+"="+template)
+	}
+	sort.Strings(kinds)
+	return "A kind=template pair renaming the children of a kind; may be repeated. " +
+		"Templates contain {name} once, and {qualifier} once for kinds that have it. Kinds and defaults:\n" +
+		strings.Join(kinds, "\n")
+}
+
+// Validate checks a template for a kind: it must contain the name of the parent, contain the
+// qualifier exactly when the default template does, so that siblings cannot collide, and
+// render a valid DNS label.
+func Validate(kind, template string) error {
+	defaultTemplate, ok := defaultTemplates[kind]
+	if !ok {
+		return fmt.Errorf("unknown name template kind %s", kind)
+	}
+	if strings.Count(template, ParentPlaceholder) != 1 {
+		return fmt.Errorf("name template of %s must contain %s once", kind, ParentPlaceholder)
+	}
+	qualified := strings.Contains(defaultTemplate, QualifierPlaceholder)
+	if count := strings.Count(template, QualifierPlaceholder); qualified && count != 1 {
+		return fmt.Errorf("name template of %s must contain %s once", kind, QualifierPlaceholder)
+	} else if !qualified && count != 0 {
+		return fmt.Errorf("name template of %s has no %s", kind, QualifierPlaceholder)
+	}
+	if errs := validation.IsDNS1123Label(render(template, "a", "b")); len(errs) > 0 {
+		return fmt.Errorf("name template of %s does not render a DNS label: %s", kind, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Truncate shortens a name longer than MaxLength to fit, suffixed with a hash of the full name
+func Truncate(name string) string {
+	if len(name) <= MaxLength {
+		return name
+	}
+	suffix := "-" + hash(name)
+	return strings.TrimRight(name[:MaxLength-len(suffix)], "-.") + suffix
+}
+
+func render(template, parent, qualifier string) string {
+	return strings.NewReplacer(ParentPlaceholder, parent, QualifierPlaceholder, qualifier).Replace(template)
+}
+
+func hash(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
@@ -0,0 +1,60 @@
+package naming
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNameKeepsShortNames(t *testing.T) {
+	templates := NewTemplates()
+	if got := templates.Name(IPTablesRules, "web", ""); got != "web-iptables-rules" {
+		t.Errorf("Name() = %s", got)
+	}
+	if got := templates.Name(IPTablesRulesGroup, "web", "arm64"); got != "web-iptables-rules-arm64" {
+		t.Errorf("Name() = %s", got)
+	}
+}
+
+func TestNameShortensParent(t *testing.T) {
+	templates := NewTemplates()
+	parent := strings.Repeat("orders-service-", 5)
+	name := templates.Name(IPTablesRulesGroup, parent+"east", "arm64")
+	if len(name) > MaxLength || !strings.HasSuffix(name, "-iptables-rules-arm64") {
+		t.Errorf("Name() = %s, want at most %d characters keeping the kind and qualifier", name, MaxLength)
+	}
+	if other := templates.Name(IPTablesRulesGroup, parent+"west", "arm64"); other == name {
+		t.Errorf("parents sharing a long prefix collide: %s", name)
+	}
+
+	// A qualifier too long for the rest of the template truncates the whole name
+	long := templates.Name(HookJob, "demo", strings.Repeat("seed", 20))
+	if len(long) > MaxLength || !strings.HasPrefix(long, "demo-seed") {
+		t.Errorf("Name() = %s", long)
+	}
+}
+
+func TestSetOverridesTemplate(t *testing.T) {
+	templates := NewTemplates()
+	if err := templates.Set("iptables-rules={name}-ipt"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := templates.Name(IPTablesRules, "web", ""); got != "web-ipt" {
+		t.Errorf("Name() = %s", got)
+	}
+	if got := templates.String(); got != "iptables-rules={name}-ipt" {
+		t.Errorf("String() = %s", got)
+	}
+
+	for _, value := range []string{
+		"iptables-rules",
+		"unknown={name}-x",
+		"iptables-rules=rules",
+		"iptables-rules-group={name}-rules",
+		"iptables-rules={name}-{qualifier}",
+		"peers={name}_Peers",
+	} {
+		if err := templates.Set(value); err == nil {
+			t.Errorf("Set(%q) succeeded", value)
+		}
+	}
+}
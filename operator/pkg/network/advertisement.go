@@ -0,0 +1,109 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+)
+
+// Advertisement is the route advertisement of a spoke gateway. The zero value advertises the
+// VPC CIDR without AS path prepending.
+type Advertisement struct {
+	Cidrs         []string
+	PrependASPath []string
+}
+
+// PlanAdvertisement validates a customized spoke advertisement and returns the CIDRs to
+// advertise: the included CIDRs and attached subnets, in canonical form and sorted, without
+// those inside an excluded CIDR.
+func PlanAdvertisement(included, attachedSubnets, excluded, prependASPath []string) (Advertisement, error) {
+	if len(excluded) > 0 && len(included) == 0 && len(attachedSubnets) == 0 {
+		return Advertisement{}, fmt.Errorf("excluded CIDRs require included CIDRs or attached subnets")
+	}
+
+	candidates, err := parseCidrs(append(append([]string{}, included...), attachedSubnets...))
+	if err != nil {
+		return Advertisement{}, err
+	}
+	exclusions, err := parseCidrs(excluded)
+	if err != nil {
+		return Advertisement{}, err
+	}
+
+	seen := make(map[string]bool, len(candidates))
+	var cidrs []string
+	for _, candidate := range candidates {
+		key := candidate.String()
+		if seen[key] || covered(candidate, exclusions) {
+			continue
+		}
+		seen[key] = true
+		cidrs = append(cidrs, key)
+	}
+	if len(candidates) > 0 && len(cidrs) == 0 {
+		return Advertisement{}, fmt.Errorf("all advertised CIDRs are excluded")
+	}
+	sort.Strings(cidrs)
+
+	for _, as := range prependASPath {
+		if number, err := strconv.ParseUint(as, 10, 32); err != nil || number == 0 {
+			return Advertisement{}, fmt.Errorf("invalid AS number %q in AS path", as)
+		}
+	}
+
+	return Advertisement{Cidrs: cidrs, PrependASPath: prependASPath}, nil
+}
+
+// ReconcileSpokeAdvertisement applies the parts of the desired advertisement that differ from
+// the one applied before
+func (m *Manager) ReconcileSpokeAdvertisement(ctx context.Context, gwName string, desired, applied Advertisement) error {
+	if !equal(desired.Cidrs, applied.Cidrs) {
+		if err := m.client.UpdateSpokeAdvertisedCidrs(ctx, gwName, desired.Cidrs); err != nil {
+			return fmt.Errorf("failed to advertise CIDRs: %w", err)
+		}
+	}
+	if !equal(desired.PrependASPath, applied.PrependASPath) {
+		if err := m.client.UpdatePrependASPath(ctx, gwName, desired.PrependASPath); err != nil {
+			return fmt.Errorf("failed to prepend AS path: %w", err)
+		}
+	}
+	return nil
+}
+
+func parseCidrs(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// covered reports whether network lies inside one of the exclusions
+func covered(network *net.IPNet, exclusions []*net.IPNet) bool {
+	ones, bits := network.Mask.Size()
+	for _, exclusion := range exclusions {
+		exclusionOnes, exclusionBits := exclusion.Mask.Size()
+		if bits == exclusionBits && exclusionOnes <= ones && exclusion.Contains(network.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanAdvertisement(t *testing.T) {
+	advertisement, err := PlanAdvertisement(
+		[]string{"10.20.0.0/16", "192.168.5.7/24"},
+		[]string{"10.10.1.0/24", "10.10.2.0/24", "10.20.0.0/16"},
+		[]string{"10.10.2.0/23"},
+		[]string{"65001", "65001"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Host bits are cleared, duplicates dropped and 10.10.2.0/24 lies inside the exclusion
+	want := []string{"10.10.1.0/24", "10.20.0.0/16", "192.168.5.0/24"}
+	if !reflect.DeepEqual(advertisement.Cidrs, want) {
+		t.Errorf("cidrs = %v, want %v", advertisement.Cidrs, want)
+	}
+	if !reflect.DeepEqual(advertisement.PrependASPath, []string{"65001", "65001"}) {
+		t.Errorf("AS path = %v", advertisement.PrependASPath)
+	}
+}
+
+func TestPlanAdvertisementKeepsWiderCidrs(t *testing.T) {
+	// An exclusion only drops CIDRs it fully contains
+	advertisement, err := PlanAdvertisement([]string{"10.0.0.0/8"}, nil, []string{"10.1.0.0/16"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(advertisement.Cidrs, []string{"10.0.0.0/8"}) {
+		t.Errorf("cidrs = %v", advertisement.Cidrs)
+	}
+}
+
+func TestPlanAdvertisementErrors(t *testing.T) {
+	tests := map[string][4][]string{
+		"exclusion without base": {nil, nil, {"10.0.0.0/8"}, nil},
+		"everything excluded":    {{"10.1.0.0/16"}, nil, {"10.0.0.0/8"}, nil},
+		"invalid CIDR":           {{"10.1.0.0"}, nil, nil, nil},
+		"invalid AS number":      {nil, nil, nil, {"AS65001"}},
+		"zero AS number":         {nil, nil, nil, {"0"}},
+	}
+	for name, args := range tests {
+		if _, err := PlanAdvertisement(args[0], args[1], args[2], args[3]); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPlanAdvertisementDefault(t *testing.T) {
+	advertisement, err := PlanAdvertisement(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(advertisement.Cidrs) != 0 || len(advertisement.PrependASPath) != 0 {
+		t.Errorf("advertisement = %+v, want the VPC CIDR default", advertisement)
+	}
+}
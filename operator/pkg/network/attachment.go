@@ -0,0 +1,104 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// AttachmentPlan is what must change for a VPC attached to an AWS Transit Gateway to match its
+// spec
+type AttachmentPlan struct {
+	// Reattach is set when a setting the Controller cannot edit in place differs; the VPC is
+	// detached and attached again
+	Reattach bool
+	// Routes is set when the customized routes differ
+	Routes bool
+	// Advertisement is set when the customized route advertisement differs
+	Advertisement bool
+	// Fields names the settings that differ, sorted
+	Fields []string
+}
+
+// Empty reports whether the attachment matches its spec
+func (p AttachmentPlan) Empty() bool {
+	return len(p.Fields) == 0
+}
+
+// PlanTgwAttachment validates the CIDRs of a desired attachment and compares it with the
+// attachment reported by the Controller. Lists are compared regardless of their order.
+func PlanTgwAttachment(desired, actual aviatrix.TgwAttachment) (AttachmentPlan, error) {
+	if _, err := parseCidrs(desired.CustomizedRoutes); err != nil {
+		return AttachmentPlan{}, fmt.Errorf("invalid customized route: %w", err)
+	}
+	if _, err := parseCidrs(desired.CustomizedRouteAdvertisement); err != nil {
+		return AttachmentPlan{}, fmt.Errorf("invalid customized route advertisement: %w", err)
+	}
+
+	var plan AttachmentPlan
+	reattach := []struct {
+		field   string
+		changed bool
+	}{
+		{"networkDomain", desired.NetworkDomain != actual.NetworkDomain},
+		// The Controller picks subnets and route tables when none are given
+		{"subnets", len(desired.Subnets) > 0 && !sameSet(desired.Subnets, actual.Subnets)},
+		{"routeTables", len(desired.RouteTables) > 0 && !sameSet(desired.RouteTables, actual.RouteTables)},
+		{"disableLocalRoutePropagation", desired.DisableLocalRoutePropagation != actual.DisableLocalRoutePropagation},
+	}
+	for _, r := range reattach {
+		if r.changed {
+			plan.Reattach = true
+			plan.Fields = append(plan.Fields, r.field)
+		}
+	}
+	if !sameSet(desired.CustomizedRoutes, actual.CustomizedRoutes) {
+		plan.Routes = true
+		plan.Fields = append(plan.Fields, "customizedRoutes")
+	}
+	if !sameSet(desired.CustomizedRouteAdvertisement, actual.CustomizedRouteAdvertisement) {
+		plan.Advertisement = true
+		plan.Fields = append(plan.Fields, "customizedRouteAdvertisement")
+	}
+	sort.Strings(plan.Fields)
+	return plan, nil
+}
+
+// ApplyTgwAttachment carries out a plan for an attached VPC. Reattaching applies every setting,
+// so routes and advertisement are only edited for attachments that are kept.
+func (m *Manager) ApplyTgwAttachment(ctx context.Context, desired aviatrix.TgwAttachment, plan AttachmentPlan) error {
+	if plan.Reattach {
+		if err := m.client.DetachVpcFromTgw(ctx, desired.TgwName, desired.VpcID); err != nil && !aviatrix.IsNotFound(err) {
+			return fmt.Errorf("failed to detach VPC for reattaching: %w", err)
+		}
+		if err := m.client.AttachVpcToTgw(ctx, desired); err != nil {
+			return fmt.Errorf("failed to reattach VPC: %w", err)
+		}
+		return nil
+	}
+	if plan.Routes {
+		if err := m.client.UpdateTgwAttachmentRoutes(ctx, desired.TgwName, desired.VpcID, desired.CustomizedRoutes); err != nil {
+			return fmt.Errorf("failed to update customized routes: %w", err)
+		}
+	}
+	if plan.Advertisement {
+		if err := m.client.UpdateTgwAttachmentAdvertisement(ctx, desired.TgwName, desired.VpcID, desired.CustomizedRouteAdvertisement); err != nil {
+			return fmt.Errorf("failed to update customized route advertisement: %w", err)
+		}
+	}
+	return nil
+}
+
+// sameSet reports whether two lists hold the same values in any order
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return equal(a, b)
+}
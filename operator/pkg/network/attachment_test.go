@@ -0,0 +1,50 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+func TestPlanTgwAttachment(t *testing.T) {
+	actual := aviatrix.TgwAttachment{
+		TgwName:          "tgw-east",
+		VpcID:            "vpc-1",
+		NetworkDomain:    "prod",
+		Subnets:          []string{"subnet-b", "subnet-a"},
+		RouteTables:      []string{"rtb-1"},
+		CustomizedRoutes: []string{"10.0.0.0/8"},
+	}
+
+	// Order does not matter and empty subnets and route tables leave the choice to the Controller
+	desired := actual
+	desired.Subnets = []string{"subnet-a", "subnet-b"}
+	desired.RouteTables = nil
+	plan, err := PlanTgwAttachment(desired, actual)
+	if err != nil || !plan.Empty() {
+		t.Errorf("PlanTgwAttachment() = %+v, %v, want an empty plan", plan, err)
+	}
+
+	desired.CustomizedRoutes = []string{"10.0.0.0/8", "172.16.0.0/12"}
+	desired.CustomizedRouteAdvertisement = []string{"10.1.0.0/16"}
+	plan, err = PlanTgwAttachment(desired, actual)
+	want := AttachmentPlan{Routes: true, Advertisement: true, Fields: []string{"customizedRouteAdvertisement", "customizedRoutes"}}
+	if err != nil || !reflect.DeepEqual(plan, want) {
+		t.Errorf("PlanTgwAttachment() = %+v, %v, want %+v", plan, err, want)
+	}
+
+	desired.NetworkDomain = "dev"
+	desired.DisableLocalRoutePropagation = true
+	plan, _ = PlanTgwAttachment(desired, actual)
+	if !plan.Reattach || !reflect.DeepEqual(plan.Fields, []string{"customizedRouteAdvertisement", "customizedRoutes", "disableLocalRoutePropagation", "networkDomain"}) {
+		t.Errorf("PlanTgwAttachment() = %+v, want a reattach", plan)
+	}
+}
+
+func TestPlanTgwAttachmentInvalidCidr(t *testing.T) {
+	desired := aviatrix.TgwAttachment{CustomizedRoutes: []string{"10.0.0.0/33"}}
+	if _, err := PlanTgwAttachment(desired, aviatrix.TgwAttachment{}); err == nil {
+		t.Error("PlanTgwAttachment() accepted an invalid customized route")
+	}
+}
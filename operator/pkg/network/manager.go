@@ -2,6 +2,7 @@ package network
 
 import (
 	"aviatrix-operator/pkg/aviatrix"
+	"context"
 	"fmt"
 )
 
@@ -18,88 +19,137 @@ func NewManager(client *aviatrix.Client) *Manager {
 }
 
 // CreateTransitGateway creates a transit gateway
-func (m *Manager) CreateTransitGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
-	return m.client.CreateGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet)
+func (m *Manager) CreateTransitGateway(ctx context.Context, config aviatrix.TransitGatewayConfig) error {
+	return m.client.CreateTransitGateway(ctx, config)
+}
+
+// EnableTransitHA creates the HA gateway of a transit gateway
+func (m *Manager) EnableTransitHA(ctx context.Context, gwName, subnet, zone, gwSize string) error {
+	return m.client.EnableTransitHA(ctx, gwName, subnet, zone, gwSize)
+}
+
+// SetTransitFeature enables or disables BGP, segmentation or FireNet on a transit gateway
+func (m *Manager) SetTransitFeature(ctx context.Context, gwName string, feature aviatrix.TransitFeature, enabled bool) error {
+	return m.client.SetTransitFeature(ctx, gwName, feature, enabled)
 }
 
 // CreateSpokeGateway creates a spoke gateway
-func (m *Manager) CreateSpokeGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
-	return m.client.CreateGateway(gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet)
+func (m *Manager) CreateSpokeGateway(ctx context.Context, gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet string) error {
+	return m.client.CreateGateway(ctx, gwName, cloudType, accountName, vpcID, vpcRegion, gwSize, subnet)
 }
 
 // AttachSpokeToTransit attaches a spoke gateway to a transit gateway
-func (m *Manager) AttachSpokeToTransit(spokeGwName, transitGwName string) error {
-	// Implementation for attaching spoke to transit
-	// This would typically involve calling the Aviatrix API to create the attachment
-	return fmt.Errorf("attach spoke to transit not implemented")
+func (m *Manager) AttachSpokeToTransit(ctx context.Context, spokeGwName, transitGwName string) error {
+	return m.client.AttachSpokeToTransit(ctx, spokeGwName, transitGwName)
 }
 
 // DetachSpokeFromTransit detaches a spoke gateway from a transit gateway
-func (m *Manager) DetachSpokeFromTransit(spokeGwName, transitGwName string) error {
-	// Implementation for detaching spoke from transit
-	// This would typically involve calling the Aviatrix API to delete the attachment
-	return fmt.Errorf("detach spoke from transit not implemented")
+func (m *Manager) DetachSpokeFromTransit(ctx context.Context, spokeGwName, transitGwName string) error {
+	return m.client.DetachSpokeFromTransit(ctx, spokeGwName, transitGwName)
 }
 
 // CreateNetworkDomain creates a network domain
-func (m *Manager) CreateNetworkDomain(name, domainType, accountName, region, cidr, cloudType string) error {
-	// Implementation for creating network domain
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("create network domain not implemented")
+func (m *Manager) CreateNetworkDomain(ctx context.Context, domain aviatrix.NetworkDomain) error {
+	return m.client.CreateNetworkDomain(ctx, domain)
 }
 
 // DeleteNetworkDomain deletes a network domain
-func (m *Manager) DeleteNetworkDomain(name string) error {
-	// Implementation for deleting network domain
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("delete network domain not implemented")
+func (m *Manager) DeleteNetworkDomain(ctx context.Context, name string) error {
+	return m.client.DeleteNetworkDomain(ctx, name)
 }
 
-// GetNetworkDomain retrieves network domain information
-func (m *Manager) GetNetworkDomain(name string) (map[string]interface{}, error) {
-	// Implementation for getting network domain
-	// This would typically involve calling the Aviatrix API
-	return nil, fmt.Errorf("get network domain not implemented")
+// GetNetworkDomain retrieves a network domain
+func (m *Manager) GetNetworkDomain(ctx context.Context, name string) (*aviatrix.NetworkDomain, error) {
+	return m.client.GetNetworkDomain(ctx, name)
 }
 
-// CreateTransitGatewayPeering creates a transit gateway peering
-func (m *Manager) CreateTransitGatewayPeering(sourceGwName, destinationGwName string) error {
-	// Implementation for creating transit gateway peering
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("create transit gateway peering not implemented")
+// CreateTransitGatewayPeering peers two transit gateways and sets the AS path each prepends
+// over the peering
+func (m *Manager) CreateTransitGatewayPeering(ctx context.Context, peering aviatrix.TransitPeering) error {
+	if err := m.client.CreateTransitPeering(ctx, peering); err != nil {
+		return err
+	}
+	connection := aviatrix.TransitPeeringConnection(peering.SourceGwName, peering.DestinationGwName)
+	if len(peering.SourcePrependASPath) > 0 {
+		if err := m.client.UpdateConnectionPrependASPath(ctx, peering.SourceGwName, connection, peering.SourcePrependASPath); err != nil {
+			return fmt.Errorf("failed to set AS path prepending of %s: %w", peering.SourceGwName, err)
+		}
+	}
+	if len(peering.DestinationPrependASPath) > 0 {
+		if err := m.client.UpdateConnectionPrependASPath(ctx, peering.DestinationGwName, connection, peering.DestinationPrependASPath); err != nil {
+			return fmt.Errorf("failed to set AS path prepending of %s: %w", peering.DestinationGwName, err)
+		}
+	}
+	return nil
 }
 
 // DeleteTransitGatewayPeering deletes a transit gateway peering
-func (m *Manager) DeleteTransitGatewayPeering(sourceGwName, destinationGwName string) error {
-	// Implementation for deleting transit gateway peering
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("delete transit gateway peering not implemented")
+func (m *Manager) DeleteTransitGatewayPeering(ctx context.Context, sourceGwName, destinationGwName string) error {
+	return m.client.DeleteTransitPeering(ctx, sourceGwName, destinationGwName)
 }
 
-// GetTransitGatewayPeering retrieves transit gateway peering information
-func (m *Manager) GetTransitGatewayPeering(sourceGwName, destinationGwName string) (map[string]interface{}, error) {
-	// Implementation for getting transit gateway peering
-	// This would typically involve calling the Aviatrix API
-	return nil, fmt.Errorf("get transit gateway peering not implemented")
+// GetTransitGatewayPeering retrieves a transit gateway peering as seen from sourceGwName
+func (m *Manager) GetTransitGatewayPeering(ctx context.Context, sourceGwName, destinationGwName string) (*aviatrix.TransitPeering, error) {
+	return m.client.GetTransitPeering(ctx, sourceGwName, destinationGwName)
+}
+
+// GetTunnelStatus lists the tunnels of a gateway
+func (m *Manager) GetTunnelStatus(ctx context.Context, gwName string) ([]map[string]interface{}, error) {
+	return m.client.GetTunnelStatus(ctx, gwName)
 }
 
 // CreateTransitGatewayRouteTable creates a transit gateway route table
-func (m *Manager) CreateTransitGatewayRouteTable(gwName, routeTableName string) error {
+func (m *Manager) CreateTransitGatewayRouteTable(ctx context.Context, gwName, routeTableName string) error {
 	// Implementation for creating transit gateway route table
 	// This would typically involve calling the Aviatrix API
 	return fmt.Errorf("create transit gateway route table not implemented")
 }
 
 // DeleteTransitGatewayRouteTable deletes a transit gateway route table
-func (m *Manager) DeleteTransitGatewayRouteTable(gwName, routeTableName string) error {
+func (m *Manager) DeleteTransitGatewayRouteTable(ctx context.Context, gwName, routeTableName string) error {
 	// Implementation for deleting transit gateway route table
 	// This would typically involve calling the Aviatrix API
 	return fmt.Errorf("delete transit gateway route table not implemented")
 }
 
 // GetTransitGatewayRouteTable retrieves transit gateway route table information
-func (m *Manager) GetTransitGatewayRouteTable(gwName, routeTableName string) (map[string]interface{}, error) {
+func (m *Manager) GetTransitGatewayRouteTable(ctx context.Context, gwName, routeTableName string) (map[string]interface{}, error) {
 	// Implementation for getting transit gateway route table
 	// This would typically involve calling the Aviatrix API
 	return nil, fmt.Errorf("get transit gateway route table not implemented")
 }
+
+// CreateVpcPeering peers two AWS VPCs natively
+func (m *Manager) CreateVpcPeering(ctx context.Context, peering aviatrix.VpcPeering) error {
+	return m.client.CreateAwsPeering(ctx, peering)
+}
+
+// DeleteVpcPeering removes the native peering between two AWS VPCs
+func (m *Manager) DeleteVpcPeering(ctx context.Context, requesterVpcID, accepterVpcID string) error {
+	return m.client.DeleteAwsPeering(ctx, requesterVpcID, accepterVpcID)
+}
+
+// GetVpcPeering retrieves the native peering between two AWS VPCs
+func (m *Manager) GetVpcPeering(ctx context.Context, requesterVpcID, accepterVpcID string) (*aviatrix.VpcPeering, error) {
+	return m.client.GetAwsPeering(ctx, requesterVpcID, accepterVpcID)
+}
+
+// AttachVpcToTgw attaches a VPC to a network domain of an AWS Transit Gateway
+func (m *Manager) AttachVpcToTgw(ctx context.Context, attachment aviatrix.TgwAttachment) error {
+	return m.client.AttachVpcToTgw(ctx, attachment)
+}
+
+// DetachVpcFromTgw detaches a VPC from an AWS Transit Gateway
+func (m *Manager) DetachVpcFromTgw(ctx context.Context, tgwName, vpcID string) error {
+	return m.client.DetachVpcFromTgw(ctx, tgwName, vpcID)
+}
+
+// GetTgwAttachment retrieves the attachment of a VPC to an AWS Transit Gateway
+func (m *Manager) GetTgwAttachment(ctx context.Context, tgwName, vpcID string) (*aviatrix.TgwAttachment, error) {
+	return m.client.GetTgwAttachment(ctx, tgwName, vpcID)
+}
+
+// UpdateConnectionAuth replaces the credentials of a Site2Cloud or BGP connection on a gateway
+func (m *Manager) UpdateConnectionAuth(ctx context.Context, connectionType, gwName, connectionName string, auth aviatrix.ConnectionAuth) error {
+	return m.client.UpdateConnectionAuth(ctx, connectionType, gwName, connectionName, auth)
+}
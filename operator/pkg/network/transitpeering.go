@@ -0,0 +1,92 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// PeeringPlan is what must change for a transit gateway peering to match its spec
+type PeeringPlan struct {
+	// Filters is set when the excluded CIDRs of either gateway differ
+	Filters bool
+	// SourceASPath is set when the AS path prepended by the source gateway differs
+	SourceASPath bool
+	// DestinationASPath is set when the AS path prepended by the destination gateway differs
+	DestinationASPath bool
+	// Fields names the settings that differ, sorted
+	Fields []string
+}
+
+// Empty reports whether the peering matches its spec
+func (p PeeringPlan) Empty() bool {
+	return len(p.Fields) == 0
+}
+
+// PlanTransitPeering validates the excluded CIDRs and AS paths of a desired peering and compares
+// it with the peering reported by the Controller. Excluded CIDRs are compared regardless of
+// their order; AS paths are not, as the order is the path.
+func PlanTransitPeering(desired, actual aviatrix.TransitPeering) (PeeringPlan, error) {
+	if desired.SourceGwName == "" || desired.DestinationGwName == "" {
+		return PeeringPlan{}, fmt.Errorf("source and destination gateways are required")
+	}
+	if desired.SourceGwName == desired.DestinationGwName {
+		return PeeringPlan{}, fmt.Errorf("transit gateway %s cannot peer with itself", desired.SourceGwName)
+	}
+	if _, err := parseCidrs(desired.SourceExcludedCidrs); err != nil {
+		return PeeringPlan{}, fmt.Errorf("invalid source excluded CIDR: %w", err)
+	}
+	if _, err := parseCidrs(desired.DestinationExcludedCidrs); err != nil {
+		return PeeringPlan{}, fmt.Errorf("invalid destination excluded CIDR: %w", err)
+	}
+	for _, as := range append(append([]string{}, desired.SourcePrependASPath...), desired.DestinationPrependASPath...) {
+		if number, err := strconv.ParseUint(as, 10, 32); err != nil || number == 0 {
+			return PeeringPlan{}, fmt.Errorf("invalid AS number %q in AS path", as)
+		}
+	}
+
+	var plan PeeringPlan
+	if !sameSet(desired.SourceExcludedCidrs, actual.SourceExcludedCidrs) {
+		plan.Filters = true
+		plan.Fields = append(plan.Fields, "sourceExcludedCidrs")
+	}
+	if !sameSet(desired.DestinationExcludedCidrs, actual.DestinationExcludedCidrs) {
+		plan.Filters = true
+		plan.Fields = append(plan.Fields, "destinationExcludedCidrs")
+	}
+	if !equal(desired.SourcePrependASPath, actual.SourcePrependASPath) {
+		plan.SourceASPath = true
+		plan.Fields = append(plan.Fields, "sourcePrependAsPath")
+	}
+	if !equal(desired.DestinationPrependASPath, actual.DestinationPrependASPath) {
+		plan.DestinationASPath = true
+		plan.Fields = append(plan.Fields, "destinationPrependAsPath")
+	}
+	sort.Strings(plan.Fields)
+	return plan, nil
+}
+
+// ApplyTransitPeering carries out a plan for an existing peering
+func (m *Manager) ApplyTransitPeering(ctx context.Context, desired aviatrix.TransitPeering, plan PeeringPlan) error {
+	if plan.Filters {
+		if err := m.client.UpdateTransitPeeringFilters(ctx, desired); err != nil {
+			return fmt.Errorf("failed to update excluded CIDRs: %w", err)
+		}
+	}
+	if plan.SourceASPath {
+		connection := aviatrix.TransitPeeringConnection(desired.SourceGwName, desired.DestinationGwName)
+		if err := m.client.UpdateConnectionPrependASPath(ctx, desired.SourceGwName, connection, desired.SourcePrependASPath); err != nil {
+			return fmt.Errorf("failed to update AS path prepending of %s: %w", desired.SourceGwName, err)
+		}
+	}
+	if plan.DestinationASPath {
+		connection := aviatrix.TransitPeeringConnection(desired.SourceGwName, desired.DestinationGwName)
+		if err := m.client.UpdateConnectionPrependASPath(ctx, desired.DestinationGwName, connection, desired.DestinationPrependASPath); err != nil {
+			return fmt.Errorf("failed to update AS path prepending of %s: %w", desired.DestinationGwName, err)
+		}
+	}
+	return nil
+}
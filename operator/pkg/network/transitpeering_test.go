@@ -0,0 +1,45 @@
+package network
+
+import (
+	"reflect"
+	"testing"
+
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+func TestPlanTransitPeering(t *testing.T) {
+	actual := aviatrix.TransitPeering{
+		SourceGwName:        "transit-east",
+		DestinationGwName:   "transit-west",
+		SourceExcludedCidrs: []string{"10.2.0.0/16", "10.1.0.0/16"},
+		SourcePrependASPath: []string{"65001", "65001"},
+	}
+
+	desired := actual
+	desired.SourceExcludedCidrs = []string{"10.1.0.0/16", "10.2.0.0/16"}
+	plan, err := PlanTransitPeering(desired, actual)
+	if err != nil || !plan.Empty() {
+		t.Errorf("PlanTransitPeering() = %+v, %v, want an empty plan", plan, err)
+	}
+
+	desired.DestinationExcludedCidrs = []string{"172.16.0.0/12"}
+	desired.SourcePrependASPath = nil
+	desired.DestinationPrependASPath = []string{"65002"}
+	plan, err = PlanTransitPeering(desired, actual)
+	want := PeeringPlan{Filters: true, SourceASPath: true, DestinationASPath: true, Fields: []string{"destinationExcludedCidrs", "destinationPrependAsPath", "sourcePrependAsPath"}}
+	if err != nil || !reflect.DeepEqual(plan, want) {
+		t.Errorf("PlanTransitPeering() = %+v, %v, want %+v", plan, err, want)
+	}
+}
+
+func TestPlanTransitPeeringInvalid(t *testing.T) {
+	for name, desired := range map[string]aviatrix.TransitPeering{
+		"self":      {SourceGwName: "transit-east", DestinationGwName: "transit-east"},
+		"cidr":      {SourceGwName: "transit-east", DestinationGwName: "transit-west", DestinationExcludedCidrs: []string{"10.0.0.0/33"}},
+		"as number": {SourceGwName: "transit-east", DestinationGwName: "transit-west", SourcePrependASPath: []string{"0"}},
+	} {
+		if _, err := PlanTransitPeering(desired, aviatrix.TransitPeering{}); err == nil {
+			t.Errorf("PlanTransitPeering() accepted an invalid %s", name)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package orphans
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/cloudevents"
+	"aviatrix-operator/pkg/metrics"
+)
+
+// Ownership tags set on the gateways and VPCs the operator creates
+const (
+	// TagInstance identifies the operator that created the resource, so operators sharing an
+	// Aviatrix Controller leave each other's resources alone
+	TagInstance = "aviatrix-operator-instance"
+	// TagOwner names the custom resource the resource was created for, as kind/namespace/name
+	TagOwner = "aviatrix-operator-owner"
+)
+
+// Policies for resources no custom resource tracks
+const (
+	// PolicyReport logs, counts and emits an event for orphans and leaves them in place
+	PolicyReport = "Report"
+	// PolicyAdopt recreates the custom resource named by the ownership tag from the resource
+	PolicyAdopt = "Adopt"
+	// PolicyDelete deletes orphans from the Aviatrix Controller
+	PolicyDelete = "Delete"
+)
+
+// Actions taken on an orphan
+const (
+	ActionReported = "Reported"
+	ActionAdopted  = "Adopted"
+	ActionDeleted  = "Deleted"
+	ActionFailed   = "Failed"
+)
+
+// AdoptedAnnotation marks custom resources recreated by the scanner, with the time of adoption
+const AdoptedAnnotation = "aviatrix.k8s.io/adopted-at"
+
+const (
+	// DefaultInstance is the instance tag of an operator without a configured one
+	DefaultInstance = "aviatrix-operator"
+	// DefaultInterval is how often the Aviatrix Controller is scanned for orphans
+	DefaultInterval = 10 * time.Minute
+)
+
+const (
+	kindGateway = "AviatrixGateway"
+	kindVpc     = "AviatrixVpc"
+)
+
+// Cloud is the part of the Aviatrix Controller API the scanner uses; *cloud.Manager
+// implements it
+type Cloud interface {
+	ListGateways(ctx context.Context) ([]aviatrix.GatewayInfo, error)
+	ListVpcs(ctx context.Context) ([]aviatrix.VpcInfo, error)
+	GetGateway(ctx context.Context, gwName string) (*aviatrix.GatewayInfo, error)
+	GetVpc(ctx context.Context, name string) (*aviatrix.VpcInfo, error)
+	GetResourceTags(ctx context.Context, resourceType, resourceName string) (map[string]string, error)
+	DeleteGateway(ctx context.Context, gwName string) error
+	DeleteVpc(ctx context.Context, name string) error
+}
+
+// Owner is the custom resource named by the ownership tag of a resource
+type Owner struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// String formats the owner as the value of TagOwner
+func (o Owner) String() string {
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
+// ParseOwner parses the value of TagOwner
+func ParseOwner(value string) (Owner, bool) {
+	parts := strings.Split(value, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Owner{}, false
+	}
+	return Owner{Kind: parts[0], Namespace: parts[1], Name: parts[2]}, true
+}
+
+// OwnerTags returns the ownership tags of a resource created for obj, or nil when instance is
+// empty and ownership tagging is disabled
+func OwnerTags(instance, kind string, obj metav1.Object) map[string]string {
+	if instance == "" {
+		return nil
+	}
+	return map[string]string{
+		TagInstance: instance,
+		TagOwner:    Owner{Kind: kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}.String(),
+	}
+}
+
+// WithOwnerTags returns the spec tags of obj with its ownership tags added, so that
+// reconciling tags keeps the ownership tags in place
+func WithOwnerTags(tags map[string]string, instance, kind string, obj metav1.Object) map[string]string {
+	owner := OwnerTags(instance, kind, obj)
+	if len(owner) == 0 {
+		return tags
+	}
+	merged := make(map[string]string, len(tags)+len(owner))
+	for key, value := range tags {
+		merged[key] = value
+	}
+	for key, value := range owner {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Orphan is a resource carrying the ownership tags of this operator that no custom resource
+// tracks, e.g. because the operator stopped between creating it and recording it or its custom
+// resource was deleted while the operator was down
+type Orphan struct {
+	// ResourceType is the tagging resource type, cloud.TagResourceGateway or cloud.TagResourceVpc
+	ResourceType string
+	// Name is the name of the resource in the Aviatrix Controller
+	Name string
+	// Owner is the custom resource named by the ownership tag
+	Owner Owner
+	// Reason explains why the resource is an orphan
+	Reason string
+	// Action is what the policy did about it
+	Action string
+	// Error explains a failed action
+	Error string
+}
+
+// Config selects how orphans are found and handled
+type Config struct {
+	// Instance is the value of TagInstance this operator sets; resources of other instances
+	// are ignored
+	Instance string
+	// Policy is PolicyReport, PolicyAdopt or PolicyDelete
+	Policy string
+	// Interval is how often the Aviatrix Controller is scanned
+	Interval time.Duration
+}
+
+// Scanner periodically matches the gateways and VPCs tagged by this operator against custom
+// resources and reports, adopts or deletes those that no resource tracks
+type Scanner struct {
+	client client.Client
+	cloud  Cloud
+	config Config
+	events *cloudevents.Emitter
+
+	// reported holds the orphans an event was emitted for, so a lingering orphan is announced
+	// once
+	reported map[string]bool
+}
+
+// NewScanner creates a scanner. Add it to the manager so it runs on the leader.
+func NewScanner(c client.Client, cloud Cloud, config Config, events *cloudevents.Emitter) (*Scanner, error) {
+	if config.Instance == "" {
+		config.Instance = DefaultInstance
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	switch config.Policy {
+	case "":
+		config.Policy = PolicyReport
+	case PolicyReport, PolicyAdopt, PolicyDelete:
+	default:
+		return nil, fmt.Errorf("unknown orphan policy %q, must be %s, %s or %s", config.Policy, PolicyReport, PolicyAdopt, PolicyDelete)
+	}
+	return &Scanner{client: c, cloud: cloud, config: config, events: events, reported: map[string]bool{}}, nil
+}
+
+// NeedLeaderElection scans on the leader only, so orphans are not adopted or deleted twice
+func (s *Scanner) NeedLeaderElection() bool {
+	return true
+}
+
+// Start scans once and then every interval until ctx is cancelled
+func (s *Scanner) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("orphans")
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+	for {
+		orphans, err := s.Scan(ctx)
+		if err != nil {
+			log.Error(err, "orphan scan failed")
+		}
+		for _, orphan := range orphans {
+			log.Info("orphaned Aviatrix resource", "type", orphan.ResourceType, "name", orphan.Name,
+				"owner", orphan.Owner.String(), "reason", orphan.Reason, "action", orphan.Action, "error", orphan.Error)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan lists the gateways and VPCs of the Aviatrix Controller, matches those tagged by this
+// operator against custom resources and applies the policy to the unmatched ones. Resources
+// without ownership tags or tagged by another instance are never touched.
+func (s *Scanner) Scan(ctx context.Context) ([]Orphan, error) {
+	var orphans []Orphan
+	for _, resourceType := range []string{cloud.TagResourceGateway, cloud.TagResourceVpc} {
+		found, err := s.scan(ctx, resourceType)
+		if err != nil {
+			return orphans, err
+		}
+		metrics.RecordOrphans(resourceType, len(found))
+		orphans = append(orphans, found...)
+	}
+
+	current := make(map[string]bool, len(orphans))
+	for _, orphan := range orphans {
+		key := orphan.ResourceType + "/" + orphan.Name
+		current[key] = true
+		if s.reported[key] {
+			continue
+		}
+		s.events.Emit(ctx, cloudevents.New(cloudevents.TypeOrphanDetected, cloudevents.Data{
+			Kind:      orphan.Owner.Kind,
+			Namespace: orphan.Owner.Namespace,
+			Name:      orphan.Owner.Name,
+			Reason:    orphan.Action,
+			Message:   orphan.Reason,
+			Details:   map[string]string{"resourceType": orphan.ResourceType, "resource": orphan.Name},
+		}))
+	}
+	s.reported = current
+	return orphans, nil
+}
+
+// scan finds and handles the orphans of one resource type
+func (s *Scanner) scan(ctx context.Context, resourceType string) ([]Orphan, error) {
+	names, err := s.list(ctx, resourceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []Orphan
+	for _, name := range names {
+		tags, err := s.cloud.GetResourceTags(ctx, resourceType, name)
+		if err != nil {
+			return orphans, fmt.Errorf("failed to get tags of %s %s: %w", resourceType, name, err)
+		}
+		if tags[TagInstance] != s.config.Instance {
+			continue
+		}
+		owner, ok := ParseOwner(tags[TagOwner])
+		if !ok || owner.Kind != kindOf(resourceType) {
+			orphans = append(orphans, s.handle(ctx, Orphan{ResourceType: resourceType, Name: name, Owner: owner, Reason: fmt.Sprintf("invalid owner tag %q", tags[TagOwner])}, nil))
+			continue
+		}
+
+		tracked, exists, err := s.tracks(ctx, resourceType, owner, name)
+		if err != nil {
+			return orphans, err
+		}
+		if tracked {
+			continue
+		}
+		orphan := Orphan{ResourceType: resourceType, Name: name, Owner: owner, Reason: owner.Kind + " no longer exists"}
+		if exists {
+			orphan.Reason = owner.Kind + " names another resource"
+		}
+		orphans = append(orphans, s.handle(ctx, orphan, tags))
+	}
+	return orphans, nil
+}
+
+// list returns the sorted names of the resources of a type
+func (s *Scanner) list(ctx context.Context, resourceType string) ([]string, error) {
+	var names []string
+	if resourceType == cloud.TagResourceVpc {
+		vpcs, err := s.cloud.ListVpcs(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s resources: %w", resourceType, err)
+		}
+		for _, vpc := range vpcs {
+			if vpc.Name != "" {
+				names = append(names, vpc.Name)
+			}
+		}
+	} else {
+		gateways, err := s.cloud.ListGateways(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s resources: %w", resourceType, err)
+		}
+		for _, gateway := range gateways {
+			if gateway.GwName != "" {
+				names = append(names, gateway.GwName)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// tracks reports whether the owner exists and still names the resource
+func (s *Scanner) tracks(ctx context.Context, resourceType string, owner Owner, name string) (bool, bool, error) {
+	key := types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}
+	var specName string
+	switch resourceType {
+	case cloud.TagResourceGateway:
+		gateway := &aviatrixv1alpha1.AviatrixGateway{}
+		if err := s.client.Get(ctx, key, gateway); err != nil {
+			return false, false, client.IgnoreNotFound(err)
+		}
+		specName = gateway.Spec.GwName
+	default:
+		vpc := &aviatrixv1alpha1.AviatrixVpc{}
+		if err := s.client.Get(ctx, key, vpc); err != nil {
+			return false, false, client.IgnoreNotFound(err)
+		}
+		specName = vpc.Spec.Name
+	}
+	return specName == name, true, nil
+}
+
+// handle applies the policy to an orphan. Tags are nil when the owner cannot be parsed; such
+// orphans cannot be adopted and are reported.
+func (s *Scanner) handle(ctx context.Context, orphan Orphan, tags map[string]string) Orphan {
+	var err error
+	switch {
+	case s.config.Policy == PolicyDelete:
+		orphan.Action = ActionDeleted
+		if orphan.ResourceType == cloud.TagResourceGateway {
+			err = s.cloud.DeleteGateway(ctx, orphan.Name)
+		} else {
+			err = s.cloud.DeleteVpc(ctx, orphan.Name)
+		}
+	case s.config.Policy == PolicyAdopt && tags != nil:
+		orphan.Action = ActionAdopted
+		err = s.adopt(ctx, orphan, tags)
+	default:
+		orphan.Action = ActionReported
+	}
+	if err != nil {
+		orphan.Action = ActionFailed
+		orphan.Error = err.Error()
+	}
+	return orphan
+}
+
+// adopt recreates the owner of an orphan from the resource. An owner that exists and names
+// another resource is left alone.
+func (s *Scanner) adopt(ctx context.Context, orphan Orphan, tags map[string]string) error {
+	specTags := map[string]string{}
+	for key, value := range tags {
+		if key != TagInstance && key != TagOwner {
+			specTags[key] = value
+		}
+	}
+	meta := metav1.ObjectMeta{
+		Namespace:   orphan.Owner.Namespace,
+		Name:        orphan.Owner.Name,
+		Annotations: map[string]string{AdoptedAnnotation: time.Now().UTC().Format(time.RFC3339)},
+	}
+
+	var obj client.Object
+	if orphan.ResourceType == cloud.TagResourceGateway {
+		info, err := s.cloud.GetGateway(ctx, orphan.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get gateway: %w", err)
+		}
+		obj = &aviatrixv1alpha1.AviatrixGateway{
+			ObjectMeta: meta,
+			Spec: aviatrixv1alpha1.AviatrixGatewaySpec{
+				GwName:      orphan.Name,
+				CloudType:   string(info.CloudType),
+				AccountName: info.AccountName,
+				VpcID:       info.VpcID,
+				VpcRegion:   info.VpcRegion,
+				GwSize:      info.GwSize,
+				Subnet:      info.Subnet,
+				Tags:        specTags,
+			},
+		}
+	} else {
+		info, err := s.cloud.GetVpc(ctx, orphan.Name)
+		if err != nil {
+			return fmt.Errorf("failed to get VPC: %w", err)
+		}
+		obj = &aviatrixv1alpha1.AviatrixVpc{
+			ObjectMeta: meta,
+			Spec: aviatrixv1alpha1.AviatrixVpcSpec{
+				Name:        orphan.Name,
+				CloudType:   string(info.CloudType),
+				AccountName: info.AccountName,
+				Region:      info.Region,
+				CIDR:        info.CIDR,
+				Tags:        specTags,
+			},
+		}
+	}
+
+	if err := s.client.Create(ctx, obj); err != nil {
+		if errors.IsAlreadyExists(err) {
+			return fmt.Errorf("%s already exists and names another resource", orphan.Owner.Kind)
+		}
+		return fmt.Errorf("failed to create %s: %w", orphan.Owner.Kind, err)
+	}
+	return nil
+}
+
+// kindOf returns the kind of custom resource owning resources of a type
+func kindOf(resourceType string) string {
+	if resourceType == cloud.TagResourceVpc {
+		return kindVpc
+	}
+	return kindGateway
+}
@@ -0,0 +1,192 @@
+package orphans
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/cloud"
+)
+
+// fakeCloud serves gateways and VPCs by name with their tags
+type fakeCloud struct {
+	gateways map[string]map[string]string
+	vpcs     map[string]map[string]string
+	deleted  []string
+}
+
+func (f *fakeCloud) ListGateways(ctx context.Context) ([]aviatrix.GatewayInfo, error) {
+	var items []aviatrix.GatewayInfo
+	for name := range f.gateways {
+		items = append(items, aviatrix.GatewayInfo{GwName: name})
+	}
+	return items, nil
+}
+
+func (f *fakeCloud) ListVpcs(ctx context.Context) ([]aviatrix.VpcInfo, error) {
+	var items []aviatrix.VpcInfo
+	for name := range f.vpcs {
+		items = append(items, aviatrix.VpcInfo{Name: name})
+	}
+	return items, nil
+}
+
+func (f *fakeCloud) GetGateway(ctx context.Context, gwName string) (*aviatrix.GatewayInfo, error) {
+	return &aviatrix.GatewayInfo{GwName: gwName, CloudType: "1", AccountName: "aws-prod", VpcID: "vpc-1", VpcRegion: "us-east-1", GwSize: "t3.small"}, nil
+}
+
+func (f *fakeCloud) GetVpc(ctx context.Context, name string) (*aviatrix.VpcInfo, error) {
+	return &aviatrix.VpcInfo{Name: name, CIDR: "10.0.0.0/16"}, nil
+}
+
+func (f *fakeCloud) GetResourceTags(ctx context.Context, resourceType, resourceName string) (map[string]string, error) {
+	if resourceType == cloud.TagResourceVpc {
+		return f.vpcs[resourceName], nil
+	}
+	return f.gateways[resourceName], nil
+}
+
+func (f *fakeCloud) DeleteGateway(ctx context.Context, gwName string) error {
+	f.deleted = append(f.deleted, gwName)
+	return nil
+}
+
+func (f *fakeCloud) DeleteVpc(ctx context.Context, name string) error {
+	return fmt.Errorf("VPC %s has gateways", name)
+}
+
+func gateway(namespace, name, gwName string) *aviatrixv1alpha1.AviatrixGateway {
+	return &aviatrixv1alpha1.AviatrixGateway{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec:       aviatrixv1alpha1.AviatrixGatewaySpec{GwName: gwName},
+	}
+}
+
+func owned(kind, namespace, name string, extra map[string]string) map[string]string {
+	tags := OwnerTags(DefaultInstance, kind, &metav1.ObjectMeta{Namespace: namespace, Name: name})
+	for key, value := range extra {
+		tags[key] = value
+	}
+	return tags
+}
+
+func newScanner(t *testing.T, policy string, cloud Cloud, objects ...client.Object) (*Scanner, client.Client) {
+	scheme := runtime.NewScheme()
+	if err := aviatrixv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	scanner, err := NewScanner(c, cloud, Config{Policy: policy}, nil)
+	if err != nil {
+		t.Fatalf("NewScanner() error = %v", err)
+	}
+	return scanner, c
+}
+
+func testCloud() *fakeCloud {
+	return &fakeCloud{
+		gateways: map[string]map[string]string{
+			// Tracked by its resource
+			"spoke-east": owned(kindGateway, "net", "east", nil),
+			// Created before the operator crashed; the resource was deleted since
+			"spoke-west": owned(kindGateway, "net", "west", map[string]string{"team": "net"}),
+			// The resource was renamed to another gateway
+			"spoke-old": owned(kindGateway, "net", "east", nil),
+			// Not created by this operator
+			"manual":  {"team": "ops"},
+			"foreign": {TagInstance: "other", TagOwner: "AviatrixGateway/net/west"},
+		},
+		vpcs: map[string]map[string]string{
+			"shared": owned(kindVpc, "net", "shared", nil),
+		},
+	}
+}
+
+func TestScanReportsUntrackedResources(t *testing.T) {
+	scanner, _ := newScanner(t, "", testCloud(), gateway("net", "east", "spoke-east"))
+
+	orphans, err := scanner.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	found := map[string]Orphan{}
+	for _, orphan := range orphans {
+		found[orphan.Name] = orphan
+		if orphan.Action != ActionReported {
+			t.Errorf("%s action = %s, want %s", orphan.Name, orphan.Action, ActionReported)
+		}
+	}
+	if len(found) != 3 {
+		t.Fatalf("Scan() found %v, want spoke-old, spoke-west and shared", orphans)
+	}
+	if found["spoke-old"].Reason != "AviatrixGateway names another resource" {
+		t.Errorf("spoke-old reason = %s", found["spoke-old"].Reason)
+	}
+	if found["spoke-west"].Owner != (Owner{Kind: kindGateway, Namespace: "net", Name: "west"}) {
+		t.Errorf("spoke-west owner = %+v", found["spoke-west"].Owner)
+	}
+}
+
+func TestScanAdoptsOrphans(t *testing.T) {
+	scanner, c := newScanner(t, PolicyAdopt, testCloud(), gateway("net", "east", "spoke-east"))
+
+	orphans, err := scanner.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	actions := map[string]string{}
+	for _, orphan := range orphans {
+		actions[orphan.Name] = orphan.Action
+	}
+	if actions["spoke-west"] != ActionAdopted || actions["shared"] != ActionAdopted || actions["spoke-old"] != ActionFailed {
+		t.Errorf("actions = %v, want spoke-west and shared adopted and spoke-old failed", actions)
+	}
+
+	adopted := &aviatrixv1alpha1.AviatrixGateway{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "net", Name: "west"}, adopted); err != nil {
+		t.Fatalf("adopted AviatrixGateway: %v", err)
+	}
+	if adopted.Spec.GwName != "spoke-west" || adopted.Spec.CloudType != "1" || adopted.Spec.VpcRegion != "us-east-1" {
+		t.Errorf("adopted spec = %+v", adopted.Spec)
+	}
+	if len(adopted.Spec.Tags) != 1 || adopted.Spec.Tags["team"] != "net" || adopted.Annotations[AdoptedAnnotation] == "" {
+		t.Errorf("adopted tags = %v, annotations = %v, want the user tags only", adopted.Spec.Tags, adopted.Annotations)
+	}
+
+	// The adopted resources track their resources on the next scan
+	if orphans, _ := scanner.Scan(context.Background()); len(orphans) != 1 || orphans[0].Name != "spoke-old" {
+		t.Errorf("second Scan() = %v, want only spoke-old", orphans)
+	}
+}
+
+func TestScanDeletesOrphans(t *testing.T) {
+	cloud := testCloud()
+	scanner, _ := newScanner(t, PolicyDelete, cloud, gateway("net", "east", "spoke-east"))
+
+	orphans, err := scanner.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if fmt.Sprint(cloud.deleted) != "[spoke-old spoke-west]" {
+		t.Errorf("deleted %v, want spoke-old and spoke-west only", cloud.deleted)
+	}
+	for _, orphan := range orphans {
+		if orphan.Name == "shared" && (orphan.Action != ActionFailed || orphan.Error == "") {
+			t.Errorf("failed deletion = %+v", orphan)
+		}
+	}
+}
+
+func TestNewScannerRejectsUnknownPolicy(t *testing.T) {
+	if _, err := NewScanner(nil, &fakeCloud{}, Config{Policy: "Ignore"}, nil); err == nil {
+		t.Error("NewScanner() accepted an unknown policy")
+	}
+}
@@ -0,0 +1,270 @@
+package peers
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/dns"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Keys of the peer list ConfigMap
+const (
+	// PeersKey holds the comma separated host:port of every peer in ordinal order
+	PeersKey = "peers"
+	// HostsKey holds the comma separated DNS names of every peer in ordinal order
+	HostsKey = "hosts"
+	// ReplicasKey holds the number of peers
+	ReplicasKey = "replicas"
+)
+
+// envVars maps the injected environment variables, without prefix, to ConfigMap keys
+var envVars = []struct {
+	name string
+	key  string
+}{
+	{"PEERS", PeersKey},
+	{"PEER_HOSTS", HostsKey},
+	{"PEER_COUNT", ReplicasKey},
+}
+
+// Peer is a StatefulSet pod addressed through the headless service
+type Peer struct {
+	Ordinal int32
+	Host    string
+	Port    int32
+}
+
+// Address returns host:port, or only the host when no port is known
+func (p Peer) Address() string {
+	if p.Port == 0 {
+		return p.Host
+	}
+	return fmt.Sprintf("%s:%d", p.Host, p.Port)
+}
+
+// Manager publishes StatefulSet peer lists for headless services
+type Manager struct {
+	client client.Client
+}
+
+// NewManager creates a new peer list manager
+func NewManager(client client.Client) *Manager {
+	return &Manager{
+		client: client,
+	}
+}
+
+// ConfigMapName returns the name of the peer list ConfigMap of a headless service
+func ConfigMapName(headlessService *k8splaygroundsv1alpha1.HeadlessService) string {
+	if spec := headlessService.Spec.PeerList; spec != nil && spec.ConfigMapName != "" {
+		return spec.ConfigMapName
+	}
+	return naming.Name(naming.Peers, headlessService.Name)
+}
+
+// List returns the peers of a StatefulSet with the given number of replicas, ordered by
+// ordinal. Names follow <statefulset>-<ordinal>.<service>.<namespace>.svc.<cluster domain>.
+func List(headlessService *k8splaygroundsv1alpha1.HeadlessService, statefulSetName string, replicas int32, port int32) []Peer {
+	clusterDomain := dns.ClusterDomain(headlessService)
+
+	peers := make([]Peer, 0, replicas)
+	for ordinal := int32(0); ordinal < replicas; ordinal++ {
+		peers = append(peers, Peer{
+			Ordinal: ordinal,
+			Host:    fmt.Sprintf("%s-%d.%s.%s.svc.%s", statefulSetName, ordinal, headlessService.Name, headlessService.Namespace, clusterDomain),
+			Port:    port,
+		})
+	}
+	return peers
+}
+
+// PeerPort returns the pod port peers listen on: the target port of the named service port,
+// or of the first port when no name is given. Named target ports fall back to the service port.
+func PeerPort(ports []k8splaygroundsv1alpha1.ServicePort, name string) (int32, error) {
+	for _, port := range ports {
+		if name != "" && port.Name != name {
+			continue
+		}
+		if target := port.TargetPort.IntValue(); target > 0 {
+			return int32(target), nil
+		}
+		return port.Port, nil
+	}
+	if name != "" {
+		return 0, fmt.Errorf("service has no port named %s", name)
+	}
+	return 0, nil
+}
+
+// ConfigMapData renders the peer list ConfigMap contents
+func ConfigMapData(peers []Peer) map[string]string {
+	addresses := make([]string, 0, len(peers))
+	hosts := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		addresses = append(addresses, peer.Address())
+		hosts = append(hosts, peer.Host)
+	}
+	return map[string]string{
+		PeersKey:    strings.Join(addresses, ","),
+		HostsKey:    strings.Join(hosts, ","),
+		ReplicasKey: strconv.Itoa(len(peers)),
+	}
+}
+
+// InjectEnv adds environment variables referencing the peer list ConfigMap to every container
+// of a pod template. Existing variables are left alone so the template only changes once. It
+// returns whether the template changed.
+func InjectEnv(template *corev1.PodTemplateSpec, configMapName, prefix string) bool {
+	changed := false
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		for _, v := range envVars {
+			name := prefix + v.name
+			if hasEnv(container.Env, name) {
+				continue
+			}
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name: name,
+				ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: configMapName},
+						Key:                  v.key,
+					},
+				},
+			})
+			changed = true
+		}
+	}
+	return changed
+}
+
+// hasEnv reports whether an environment variable is already set
+func hasEnv(env []corev1.EnvVar, name string) bool {
+	for _, e := range env {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Reconcile publishes the peer list of the StatefulSet named in spec.peerList and, when
+// requested, injects it into the StatefulSet's containers. Environment variables are resolved
+// when a container starts, so running pods keep the list they started with while the
+// ConfigMap always reflects the current replica count.
+func (m *Manager) Reconcile(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) (*k8splaygroundsv1alpha1.PeerListStatus, error) {
+	log := logr.FromContextOrDiscard(ctx)
+	spec := headlessService.Spec.PeerList
+
+	statefulSet := &appsv1.StatefulSet{}
+	if err := m.client.Get(ctx, types.NamespacedName{Name: spec.StatefulSetName, Namespace: headlessService.Namespace}, statefulSet); err != nil {
+		return nil, fmt.Errorf("failed to get statefulset %s: %w", spec.StatefulSetName, err)
+	}
+
+	// Per-pod DNS records only exist under the governing service of the StatefulSet
+	if statefulSet.Spec.ServiceName != headlessService.Name {
+		return nil, fmt.Errorf("statefulset %s is governed by service %q, not %s", statefulSet.Name, statefulSet.Spec.ServiceName, headlessService.Name)
+	}
+
+	port, err := PeerPort(headlessService.Spec.Ports, spec.PortName)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+	peers := List(headlessService, statefulSet.Name, replicas, port)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            ConfigMapName(headlessService),
+			Namespace:       headlessService.Namespace,
+			Labels:          peerListLabels(headlessService),
+			OwnerReferences: []metav1.OwnerReference{ownerReference(headlessService)},
+		},
+		Data: ConfigMapData(peers),
+	}
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return nil, fmt.Errorf("failed to reconcile peer list ConfigMap: %w", err)
+	}
+
+	// Drop the ConfigMap published under a previous name
+	if err := m.deleteConfigMaps(ctx, headlessService, configMap.Name); err != nil {
+		return nil, err
+	}
+
+	if spec.InjectEnv && InjectEnv(&statefulSet.Spec.Template, configMap.Name, spec.EnvPrefix) {
+		if err := m.client.Update(ctx, statefulSet); err != nil {
+			return nil, fmt.Errorf("failed to inject peer list into statefulset %s: %w", statefulSet.Name, err)
+		}
+		log.Info("injected peer list environment", "statefulset", statefulSet.Name, "configMap", configMap.Name)
+	}
+
+	status := &k8splaygroundsv1alpha1.PeerListStatus{
+		ConfigMapName: configMap.Name,
+		Replicas:      replicas,
+	}
+	for _, peer := range peers {
+		status.Peers = append(status.Peers, peer.Address())
+	}
+
+	log.Info("published peer list", "statefulset", statefulSet.Name, "replicas", replicas)
+	return status, nil
+}
+
+// Cleanup removes the peer list ConfigMaps of a headless service. Injected environment
+// variables are left on the StatefulSet so running workloads are not restarted.
+func (m *Manager) Cleanup(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService) error {
+	return m.deleteConfigMaps(ctx, headlessService, "")
+}
+
+// deleteConfigMaps deletes the peer list ConfigMaps of a headless service except keep
+func (m *Manager) deleteConfigMaps(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, keep string) error {
+	configMaps := &corev1.ConfigMapList{}
+	if err := m.client.List(ctx, configMaps, client.InNamespace(headlessService.Namespace), client.MatchingLabels(peerListLabels(headlessService))); err != nil {
+		return fmt.Errorf("failed to list peer list ConfigMaps: %w", err)
+	}
+	for i := range configMaps.Items {
+		if configMaps.Items[i].Name == keep {
+			continue
+		}
+		if err := m.client.Delete(ctx, &configMaps.Items[i]); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete peer list ConfigMap %s: %w", configMaps.Items[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// peerListLabels returns the labels identifying the peer list ConfigMaps of a headless service
+func peerListLabels(headlessService *k8splaygroundsv1alpha1.HeadlessService) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "headless-service-peers",
+		"app.kubernetes.io/instance": headlessService.Name,
+	}
+}
+
+// ownerReference makes the headless service the controller of the peer list ConfigMap
+func ownerReference(headlessService *k8splaygroundsv1alpha1.HeadlessService) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		APIVersion: headlessService.APIVersion,
+		Kind:       headlessService.Kind,
+		Name:       headlessService.Name,
+		UID:        headlessService.UID,
+		Controller: &[]bool{true}[0],
+	}
+}
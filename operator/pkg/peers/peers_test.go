@@ -0,0 +1,87 @@
+package peers
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestListAndConfigMapData(t *testing.T) {
+	hs := &k8splaygroundsv1alpha1.HeadlessService{
+		ObjectMeta: metav1.ObjectMeta{Name: "etcd", Namespace: "db"},
+	}
+
+	data := ConfigMapData(List(hs, "etcd", 3, 2380))
+	if want := "etcd-0.etcd.db.svc.cluster.local:2380,etcd-1.etcd.db.svc.cluster.local:2380,etcd-2.etcd.db.svc.cluster.local:2380"; data[PeersKey] != want {
+		t.Errorf("peers = %q, want %q", data[PeersKey], want)
+	}
+	if data[ReplicasKey] != "3" {
+		t.Errorf("replicas = %q, want 3", data[ReplicasKey])
+	}
+
+	hs.Spec.DNS = &k8splaygroundsv1alpha1.DNSSpec{ClusterDomain: "example.internal"}
+	data = ConfigMapData(List(hs, "etcd", 1, 0))
+	if want := "etcd-0.etcd.db.svc.example.internal"; data[PeersKey] != want || data[HostsKey] != want {
+		t.Errorf("data = %v, want host %q without port", data, want)
+	}
+
+	data = ConfigMapData(List(hs, "etcd", 0, 2380))
+	if data[PeersKey] != "" || data[ReplicasKey] != "0" {
+		t.Errorf("data = %v, want an empty peer list", data)
+	}
+}
+
+func TestPeerPort(t *testing.T) {
+	ports := []k8splaygroundsv1alpha1.ServicePort{
+		{Name: "client", Port: 2379, TargetPort: intstr.FromInt(12379)},
+		{Name: "peer", Port: 2380, TargetPort: intstr.FromString("peer")},
+	}
+
+	tests := []struct {
+		name    string
+		want    int32
+		wantErr bool
+	}{
+		{name: "", want: 12379},
+		{name: "client", want: 12379},
+		{name: "peer", want: 2380},
+		{name: "metrics", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := PeerPort(ports, tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("PeerPort(%q) = %d, %v, want %d (error %v)", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestInjectEnv(t *testing.T) {
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{
+			{Name: "etcd", Env: []corev1.EnvVar{{Name: "ETCD_PEER_COUNT", Value: "5"}}},
+			{Name: "sidecar"},
+		}},
+	}
+
+	if !InjectEnv(template, "etcd-peers", "ETCD_") {
+		t.Fatal("expected the template to change")
+	}
+	if got := len(template.Spec.Containers[0].Env); got != 3 {
+		t.Errorf("etcd container has %d env vars, want 3", got)
+	}
+	if template.Spec.Containers[0].Env[0].Value != "5" {
+		t.Error("existing env var was overwritten")
+	}
+	env := template.Spec.Containers[1].Env
+	if len(env) != 3 || env[0].Name != "ETCD_PEERS" || env[0].ValueFrom.ConfigMapKeyRef.Name != "etcd-peers" {
+		t.Errorf("sidecar env = %+v, want references to etcd-peers", env)
+	}
+
+	if InjectEnv(template, "etcd-peers", "ETCD_") {
+		t.Error("expected a second injection to leave the template unchanged")
+	}
+}
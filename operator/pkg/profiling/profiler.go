@@ -0,0 +1,323 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrl "sigs.k8s.io/controller-runtime"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"aviatrix-operator/pkg/metrics"
+)
+
+// Triggers of a capture
+const (
+	TriggerReconcileLatency = "reconcile_latency"
+	TriggerMemory           = "memory"
+)
+
+const (
+	// DefaultKeep is how many captures are kept in the profile directory
+	DefaultKeep = 5
+	// DefaultInterval is how often latency and memory are compared with the thresholds
+	DefaultInterval = 30 * time.Second
+	// DefaultCooldown is the minimum time between two captures, so a sustained incident does
+	// not replace every earlier profile or keep the CPU profiler running
+	DefaultCooldown = 10 * time.Minute
+	// DefaultCPUDuration is how long the CPU profile of a capture records
+	DefaultCPUDuration = 30 * time.Second
+
+	// reconcileTimeMetric is the controller-runtime histogram of reconcile durations
+	reconcileTimeMetric = "controller_runtime_reconcile_time_seconds"
+	// uploadTimeout bounds the upload of a single profile
+	uploadTimeout = time.Minute
+)
+
+// Config selects when profiles are captured and where they go
+type Config struct {
+	// Dir stores the captured profiles, typically an emptyDir or PVC mount
+	Dir string
+	// Keep is how many captures are kept in Dir; older ones are deleted
+	Keep int
+	// ReconcileLatency captures when the mean reconcile duration of a controller over the last
+	// interval exceeds it; 0 disables the trigger
+	ReconcileLatency time.Duration
+	// MemoryBytes captures when the live heap exceeds it; 0 disables the trigger
+	MemoryBytes uint64
+	// Interval is how often the triggers are evaluated
+	Interval time.Duration
+	// Cooldown is the minimum time between two captures
+	Cooldown time.Duration
+	// CPUDuration is how long the CPU profile records
+	CPUDuration time.Duration
+	// UploadURL receives every profile as an HTTP PUT to UploadURL/<file>, e.g. a bucket
+	// accepting writes or a presigned prefix; empty keeps the profiles local only
+	UploadURL string
+}
+
+// Profiler captures CPU and heap profiles of the operator when reconciles slow down or memory
+// grows, so performance incidents can be diagnosed after the fact
+type Profiler struct {
+	config     Config
+	gatherer   prometheus.Gatherer
+	heapBytes  func() uint64
+	httpClient *http.Client
+
+	// reconcileTotals holds the sum and count of the reconcile histogram per controller at the
+	// previous evaluation; nil before the first one
+	reconcileTotals map[string][2]float64
+	lastCapture     time.Time
+}
+
+// New creates a profiler. Add it to the manager so it runs while the operator does.
+func New(config Config) *Profiler {
+	if config.Keep <= 0 {
+		config.Keep = DefaultKeep
+	}
+	if config.Interval <= 0 {
+		config.Interval = DefaultInterval
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = DefaultCooldown
+	}
+	if config.CPUDuration <= 0 {
+		config.CPUDuration = DefaultCPUDuration
+	}
+	return &Profiler{
+		config:     config,
+		gatherer:   crmetrics.Registry,
+		heapBytes:  heapBytes,
+		httpClient: &http.Client{Timeout: uploadTimeout},
+	}
+}
+
+// NeedLeaderElection lets every replica profile itself, not only the leader
+func (p *Profiler) NeedLeaderElection() bool {
+	return false
+}
+
+// Start evaluates the triggers every interval and captures profiles until ctx is cancelled
+func (p *Profiler) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("profiling")
+	if err := os.MkdirAll(p.config.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	// The first evaluation only records the histogram totals to compare against
+	p.Evaluate()
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			trigger, reason := p.Evaluate()
+			if trigger == "" || now.Sub(p.lastCapture) < p.config.Cooldown {
+				continue
+			}
+			p.lastCapture = now
+			log.Info("capturing profiles", "trigger", trigger, "reason", reason)
+			files, err := p.Capture(ctx, trigger, now)
+			if err != nil {
+				log.Error(err, "failed to capture profiles", "trigger", trigger)
+				metrics.RecordProfileCapture(trigger, "failed")
+				continue
+			}
+			if p.config.UploadURL == "" {
+				metrics.RecordProfileCapture(trigger, "stored")
+				continue
+			}
+			if err := p.Upload(ctx, files); err != nil {
+				log.Error(err, "failed to upload profiles", "trigger", trigger)
+				metrics.RecordProfileCapture(trigger, "failed")
+				continue
+			}
+			metrics.RecordProfileCapture(trigger, "uploaded")
+		}
+	}
+}
+
+// Evaluate compares reconcile latency and memory with the thresholds and returns the trigger
+// that fired with a reason, or "" when none did. Memory is checked first because a growing
+// heap also slows reconciles down.
+func (p *Profiler) Evaluate() (string, string) {
+	trigger, reason := p.evaluateLatency()
+	if p.config.MemoryBytes > 0 {
+		if heap := p.heapBytes(); heap > p.config.MemoryBytes {
+			return TriggerMemory, fmt.Sprintf("live heap of %d bytes exceeds %d", heap, p.config.MemoryBytes)
+		}
+	}
+	return trigger, reason
+}
+
+// evaluateLatency compares the mean reconcile duration of every controller since the previous
+// evaluation with the threshold and reports the slowest controller above it
+func (p *Profiler) evaluateLatency() (string, string) {
+	if p.config.ReconcileLatency <= 0 {
+		return "", ""
+	}
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return "", ""
+	}
+	totals := map[string][2]float64{}
+	for _, family := range families {
+		if family.GetName() != reconcileTimeMetric {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			controller := ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "controller" {
+					controller = label.GetValue()
+				}
+			}
+			histogram := metric.GetHistogram()
+			totals[controller] = [2]float64{histogram.GetSampleSum(), float64(histogram.GetSampleCount())}
+		}
+	}
+
+	// Reconciles before the first evaluation may predate the interval
+	if p.reconcileTotals == nil {
+		p.reconcileTotals = totals
+		return "", ""
+	}
+	trigger, reason, slowest := "", "", 0.0
+	for controller, total := range totals {
+		// A controller without previous totals made all its reconciles in this interval
+		previous := p.reconcileTotals[controller]
+		count := total[1] - previous[1]
+		if count <= 0 {
+			continue
+		}
+		mean := (total[0] - previous[0]) / count
+		if mean > p.config.ReconcileLatency.Seconds() && mean > slowest {
+			slowest = mean
+			trigger = TriggerReconcileLatency
+			reason = fmt.Sprintf("reconciles of controller %s took %.2fs on average", controller, mean)
+		}
+	}
+	p.reconcileTotals = totals
+	return trigger, reason
+}
+
+// Capture writes a heap profile and a CPU profile recorded over the CPU duration into the
+// profile directory, deletes captures beyond the kept number and returns the written files.
+// The CPU profile is skipped when another one is running, e.g. from /debug/pprof.
+func (p *Profiler) Capture(ctx context.Context, trigger string, now time.Time) ([]string, error) {
+	prefix := filepath.Join(p.config.Dir, now.UTC().Format("20060102T150405Z")+"-"+trigger)
+	var files []string
+
+	heapFile := prefix + ".heap.pprof"
+	if err := writeProfile(heapFile, func(f *os.File) error { return pprof.Lookup("heap").WriteTo(f, 0) }); err != nil {
+		return nil, err
+	}
+	files = append(files, heapFile)
+
+	cpuFile := prefix + ".cpu.pprof"
+	err := writeProfile(cpuFile, func(f *os.File) error {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+		select {
+		case <-ctx.Done():
+		case <-time.After(p.config.CPUDuration):
+		}
+		return nil
+	})
+	if err != nil {
+		ctrl.Log.WithName("profiling").Info("skipping CPU profile", "reason", err.Error())
+		os.Remove(cpuFile)
+	} else {
+		files = append(files, cpuFile)
+	}
+
+	return files, p.prune()
+}
+
+// Upload PUTs the files to the upload URL
+func (p *Profiler) Upload(ctx context.Context, files []string) error {
+	base := strings.TrimSuffix(p.config.UploadURL, "/")
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, base+"/"+filepath.Base(file), bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", filepath.Base(file), err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("failed to upload %s: unexpected status %s", filepath.Base(file), resp.Status)
+		}
+	}
+	return nil
+}
+
+// prune deletes all but the newest captures. Files of a capture share the name up to the first
+// dot, and names start with the capture time, so they sort by age.
+func (p *Profiler) prune() error {
+	entries, err := os.ReadDir(p.config.Dir)
+	if err != nil {
+		return err
+	}
+	captures := map[string][]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".pprof") {
+			continue
+		}
+		capture := strings.SplitN(entry.Name(), ".", 2)[0]
+		captures[capture] = append(captures[capture], entry.Name())
+	}
+	names := make([]string, 0, len(captures))
+	for capture := range captures {
+		names = append(names, capture)
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names)-p.config.Keep; i++ {
+		for _, file := range captures[names[i]] {
+			if err := os.Remove(filepath.Join(p.config.Dir, file)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeProfile creates a file and writes a profile into it
+func writeProfile(path string, write func(*os.File) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// heapBytes returns the bytes of live heap objects
+func heapBytes() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
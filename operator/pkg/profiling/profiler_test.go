@@ -0,0 +1,103 @@
+package profiling
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestEvaluate(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	histogram := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: reconcileTimeMetric}, []string{"controller"})
+	registry.MustRegister(histogram)
+
+	var heap uint64 = 100
+	p := New(Config{ReconcileLatency: time.Second, MemoryBytes: 1000})
+	p.gatherer = registry
+	p.heapBytes = func() uint64 { return heap }
+
+	histogram.WithLabelValues("gateway").Observe(5)
+	if trigger, _ := p.Evaluate(); trigger != "" {
+		t.Errorf("Evaluate() = %q on the first evaluation, want only the totals recorded", trigger)
+	}
+
+	histogram.WithLabelValues("gateway").Observe(0.1)
+	histogram.WithLabelValues("gateway").Observe(0.3)
+	if trigger, reason := p.Evaluate(); trigger != "" {
+		t.Errorf("Evaluate() = %q (%s) for fast reconciles", trigger, reason)
+	}
+
+	histogram.WithLabelValues("gateway").Observe(0.5)
+	histogram.WithLabelValues("cluster").Observe(3)
+	histogram.WithLabelValues("cluster").Observe(1)
+	if trigger, reason := p.Evaluate(); trigger != TriggerReconcileLatency || !strings.Contains(reason, "controller cluster took 2.00s") {
+		t.Errorf("Evaluate() = %q (%s), want the slow controller", trigger, reason)
+	}
+
+	heap = 2000
+	if trigger, _ := p.Evaluate(); trigger != TriggerMemory {
+		t.Errorf("Evaluate() = %q, want the memory trigger", trigger)
+	}
+}
+
+func TestCaptureKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	p := New(Config{Dir: dir, Keep: 2, CPUDuration: 10 * time.Millisecond})
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var files []string
+	for i := 0; i < 3; i++ {
+		var err error
+		if files, err = p.Capture(context.Background(), TriggerMemory, start.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(files) != 2 || !strings.HasSuffix(files[0], "20240501T120200Z-memory.heap.pprof") {
+		t.Errorf("Capture() = %v, want a heap and a CPU profile", files)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 4 || strings.HasPrefix(names[0], "20240501T120000Z") {
+		t.Errorf("profile directory = %v, want the two newest captures", names)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	var mu sync.Mutex
+	uploaded := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploaded[r.Method+" "+r.URL.Path] = string(body)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "20240501T120000Z-memory.heap.pprof")
+	if err := os.WriteFile(file, []byte("profile"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p := New(Config{UploadURL: server.URL + "/profiles/operator-0/"})
+	if err := p.Upload(context.Background(), []string{file}); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded["PUT /profiles/operator-0/20240501T120000Z-memory.heap.pprof"] != "profile" {
+		t.Errorf("uploaded = %v", uploaded)
+	}
+}
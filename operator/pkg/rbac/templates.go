@@ -0,0 +1,214 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+// Role templates that can be bound to OIDC identities
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// roleTemplates maps each role template to the built-in ClusterRole it binds
+var roleTemplates = map[string]string{
+	RoleViewer: "view",
+	RoleEditor: "edit",
+	RoleAdmin:  "admin",
+}
+
+// Labels identifying the role bindings rendered for a cluster
+const (
+	managedByLabel        = "k8s-playgrounds.io/oidc-rbac"
+	clusterLabel          = "k8s-playgrounds.io/cluster"
+	clusterNamespaceLabel = "k8s-playgrounds.io/cluster-namespace"
+)
+
+// Reconciler renders RBACSpec role templates into RoleBindings for OIDC users and groups
+// in every managed namespace, and prunes bindings that are no longer rendered
+type Reconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewReconciler creates a new OIDC RBAC reconciler
+func NewReconciler(client client.Client, scheme *runtime.Scheme) *Reconciler {
+	return &Reconciler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Enabled reports whether the cluster renders OIDC role templates
+func Enabled(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) bool {
+	security := cluster.Spec.Security
+	return security != nil && security.Enabled && security.RBAC != nil && security.RBAC.Enabled
+}
+
+// Reconcile applies the rendered role bindings and removes stale ones, or every binding of the
+// cluster once OIDC RBAC is disabled
+func (r *Reconciler) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	log := logr.FromContextOrDiscard(ctx)
+	if !Enabled(cluster) {
+		return r.Cleanup(ctx, cluster)
+	}
+
+	desired, err := Render(cluster)
+	if err != nil {
+		return err
+	}
+
+	rendered := make(map[string]bool, len(desired))
+	for i := range desired {
+		binding := &desired[i]
+		rendered[binding.Namespace+"/"+binding.Name] = true
+
+		if err := apply.Apply(ctx, r.client, binding); err != nil {
+			return fmt.Errorf("failed to reconcile role binding %s/%s: %w", binding.Namespace, binding.Name, err)
+		}
+	}
+
+	current, err := r.list(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	for i := range current {
+		binding := &current[i]
+		if rendered[binding.Namespace+"/"+binding.Name] {
+			continue
+		}
+		if err := r.client.Delete(ctx, binding); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to prune role binding %s/%s: %w", binding.Namespace, binding.Name, err)
+		}
+		log.Info("pruned OIDC role binding", "namespace", binding.Namespace, "name", binding.Name)
+	}
+
+	return nil
+}
+
+// Cleanup removes every role binding rendered for the cluster
+func (r *Reconciler) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	current, err := r.list(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	for i := range current {
+		if err := r.client.Delete(ctx, &current[i]); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete role binding %s/%s: %w", current[i].Namespace, current[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// list returns the role bindings previously rendered for the cluster across all namespaces.
+// Bindings outside the cluster namespace cannot carry an owner reference, so they are tracked by label.
+func (r *Reconciler) list(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) ([]rbacv1.RoleBinding, error) {
+	bindings := &rbacv1.RoleBindingList{}
+	if err := r.client.List(ctx, bindings, client.MatchingLabels(labels(cluster))); err != nil {
+		return nil, fmt.Errorf("failed to list OIDC role bindings: %w", err)
+	}
+	return bindings.Items, nil
+}
+
+// Render builds one RoleBinding per role template and namespace, aggregating the users and
+// groups of every binding that targets it. Bindings may only target managed namespaces.
+func Render(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) ([]rbacv1.RoleBinding, error) {
+	rbac := cluster.Spec.Security.RBAC
+	managed := cluster.ManagedNamespaces()
+	isManaged := make(map[string]bool, len(managed))
+	for _, ns := range managed {
+		isManaged[ns] = true
+	}
+
+	// subjects[namespace][role] holds the deduplicated subjects for each binding to render
+	subjects := make(map[string]map[string]map[rbacv1.Subject]bool)
+	for i, binding := range rbac.Bindings {
+		if _, ok := roleTemplates[binding.Role]; !ok {
+			return nil, fmt.Errorf("spec.security.rbac.bindings[%d]: unsupported role %q, must be one of viewer, editor or admin", i, binding.Role)
+		}
+		if len(binding.Users) == 0 && len(binding.Groups) == 0 {
+			return nil, fmt.Errorf("spec.security.rbac.bindings[%d]: at least one user or group is required", i)
+		}
+
+		namespaces := binding.Namespaces
+		if len(namespaces) == 0 {
+			namespaces = managed
+		}
+		for _, ns := range namespaces {
+			if !isManaged[ns] {
+				return nil, fmt.Errorf("spec.security.rbac.bindings[%d]: namespace %q is not managed by the cluster", i, ns)
+			}
+			if subjects[ns] == nil {
+				subjects[ns] = make(map[string]map[rbacv1.Subject]bool)
+			}
+			if subjects[ns][binding.Role] == nil {
+				subjects[ns][binding.Role] = make(map[rbacv1.Subject]bool)
+			}
+			for _, user := range binding.Users {
+				subjects[ns][binding.Role][rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: rbac.UsernamePrefix + user}] = true
+			}
+			for _, group := range binding.Groups {
+				subjects[ns][binding.Role][rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: rbac.GroupsPrefix + group}] = true
+			}
+		}
+	}
+
+	var bindings []rbacv1.RoleBinding
+	for ns, roles := range subjects {
+		for role, set := range roles {
+			binding := rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      naming.Qualified(naming.OIDCBinding, cluster.Name, role),
+					Namespace: ns,
+					Labels:    labels(cluster),
+				},
+				RoleRef: rbacv1.RoleRef{
+					APIGroup: rbacv1.GroupName,
+					Kind:     "ClusterRole",
+					Name:     roleTemplates[role],
+				},
+			}
+			for subject := range set {
+				binding.Subjects = append(binding.Subjects, subject)
+			}
+			sort.Slice(binding.Subjects, func(i, j int) bool {
+				if binding.Subjects[i].Kind != binding.Subjects[j].Kind {
+					return binding.Subjects[i].Kind < binding.Subjects[j].Kind
+				}
+				return binding.Subjects[i].Name < binding.Subjects[j].Name
+			})
+			bindings = append(bindings, binding)
+		}
+	}
+
+	sort.Slice(bindings, func(i, j int) bool {
+		if bindings[i].Namespace != bindings[j].Namespace {
+			return bindings[i].Namespace < bindings[j].Namespace
+		}
+		return bindings[i].Name < bindings[j].Name
+	})
+	return bindings, nil
+}
+
+// labels returns the labels identifying role bindings rendered for the cluster
+func labels(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) map[string]string {
+	return map[string]string{
+		managedByLabel:        "true",
+		clusterLabel:          cluster.Name,
+		clusterNamespaceLabel: cluster.Namespace,
+	}
+}
@@ -0,0 +1,115 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+func newCluster(bindings ...k8splaygroundsv1alpha1.RBACBindingSpec) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "class", Namespace: "class-a"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Deployments: []k8splaygroundsv1alpha1.DeploymentSpec{{Name: "web", Namespace: "class-b"}},
+			Security: &k8splaygroundsv1alpha1.SecuritySpec{
+				Enabled: true,
+				RBAC: &k8splaygroundsv1alpha1.RBACSpec{
+					Enabled:      true,
+					GroupsPrefix: "oidc:",
+					Bindings:     bindings,
+				},
+			},
+		},
+	}
+}
+
+func TestRenderAggregatesSubjectsPerNamespaceAndRole(t *testing.T) {
+	cluster := newCluster(
+		k8splaygroundsv1alpha1.RBACBindingSpec{Role: RoleViewer, Groups: []string{"students"}},
+		k8splaygroundsv1alpha1.RBACBindingSpec{Role: RoleViewer, Users: []string{"alice"}, Namespaces: []string{"class-a"}},
+		k8splaygroundsv1alpha1.RBACBindingSpec{Role: RoleAdmin, Groups: []string{"teachers"}, Namespaces: []string{"class-b"}},
+	)
+
+	bindings, err := Render(cluster)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(bindings) != 3 {
+		t.Fatalf("expected 3 bindings, got %d", len(bindings))
+	}
+
+	viewerA := bindings[0]
+	if viewerA.Namespace != "class-a" || viewerA.Name != "class-oidc-viewer" || viewerA.RoleRef.Name != "view" {
+		t.Errorf("unexpected binding %s/%s -> %s", viewerA.Namespace, viewerA.Name, viewerA.RoleRef.Name)
+	}
+	if len(viewerA.Subjects) != 2 || viewerA.Subjects[0].Name != "oidc:students" || viewerA.Subjects[1].Name != "alice" {
+		t.Errorf("unexpected subjects %+v", viewerA.Subjects)
+	}
+
+	if bindings[1].Namespace != "class-b" || bindings[1].RoleRef.Name != "admin" {
+		t.Errorf("unexpected binding %s/%s -> %s", bindings[1].Namespace, bindings[1].Name, bindings[1].RoleRef.Name)
+	}
+}
+
+func TestRenderRejectsInvalidBindings(t *testing.T) {
+	tests := map[string]k8splaygroundsv1alpha1.RBACBindingSpec{
+		"unknown role":        {Role: "owner", Groups: []string{"students"}},
+		"no subjects":         {Role: RoleEditor},
+		"unmanaged namespace": {Role: RoleEditor, Users: []string{"bob"}, Namespaces: []string{"kube-system"}},
+	}
+	for name, binding := range tests {
+		if _, err := Render(newCluster(binding)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestReconcileRemovesBindingsWhenDisabled(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(applytest.Funcs()).Build()
+	r := NewReconciler(c, scheme)
+
+	count := func() int {
+		bindings := &rbacv1.RoleBindingList{}
+		if err := c.List(ctx, bindings); err != nil {
+			t.Fatal(err)
+		}
+		return len(bindings.Items)
+	}
+
+	for name, disable := range map[string]func(*k8splaygroundsv1alpha1.K8sPlaygroundsCluster){
+		"rbac disabled": func(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+			cluster.Spec.Security.RBAC.Enabled = false
+		},
+		"security disabled": func(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) { cluster.Spec.Security.Enabled = false },
+		"security removed":  func(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) { cluster.Spec.Security = nil },
+	} {
+		cluster := newCluster(k8splaygroundsv1alpha1.RBACBindingSpec{Role: RoleViewer, Groups: []string{"students"}})
+		if err := r.Reconcile(ctx, cluster); err != nil {
+			t.Fatalf("%s: Reconcile() error = %v", name, err)
+		}
+		if got := count(); got != 2 {
+			t.Fatalf("%s: rendered %d role bindings, want one per managed namespace", name, got)
+		}
+
+		disable(cluster)
+		if err := r.Reconcile(ctx, cluster); err != nil {
+			t.Fatalf("%s: Reconcile() error = %v", name, err)
+		}
+		if got := count(); got != 0 {
+			t.Errorf("%s: %d role bindings left, want them pruned", name, got)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package recorder
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sessionKey is the context key of the recording in progress
+type sessionKey struct{}
+
+// session guards a recording that may be written from several goroutines of one reconcile
+type session struct {
+	mu        sync.Mutex
+	recording *Recording
+}
+
+// withSession returns a context that records client calls into recording
+func withSession(ctx context.Context, recording *Recording) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{recording: recording})
+}
+
+// record appends an interaction to the recording in ctx, if any
+func record(ctx context.Context, input bool, interaction Interaction) {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if input {
+		s.recording.Inputs = append(s.recording.Inputs, interaction)
+	} else {
+		s.recording.Outputs = append(s.recording.Outputs, interaction)
+	}
+}
+
+// recordingClient records reads and writes made with a context carrying a recording session.
+// Calls made outside a recorded reconcile pass straight through.
+type recordingClient struct {
+	client.Client
+}
+
+// NewClient wraps c so that calls made during a recorded reconcile are captured
+func NewClient(c client.Client) client.Client {
+	return &recordingClient{Client: c}
+}
+
+func (c *recordingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	err := c.Client.Get(ctx, key, obj, opts...)
+	record(ctx, true, newInteraction(c.Client, VerbGet, "", obj, key, err))
+	return err
+}
+
+func (c *recordingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	err := c.Client.List(ctx, list, opts...)
+	record(ctx, true, newInteraction(c.Client, VerbList, "", list, types.NamespacedName{}, err))
+	return err
+}
+
+func (c *recordingClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	err := c.Client.Create(ctx, obj, opts...)
+	record(ctx, false, newInteraction(c.Client, VerbCreate, "", obj, client.ObjectKeyFromObject(obj), err))
+	return err
+}
+
+func (c *recordingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	err := c.Client.Update(ctx, obj, opts...)
+	record(ctx, false, newInteraction(c.Client, VerbUpdate, "", obj, client.ObjectKeyFromObject(obj), err))
+	return err
+}
+
+func (c *recordingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	err := c.Client.Patch(ctx, obj, patch, opts...)
+	record(ctx, false, newInteraction(c.Client, VerbPatch, "", obj, client.ObjectKeyFromObject(obj), err))
+	return err
+}
+
+func (c *recordingClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	err := c.Client.Delete(ctx, obj, opts...)
+	record(ctx, false, newInteraction(c.Client, VerbDelete, "", obj, client.ObjectKeyFromObject(obj), err))
+	return err
+}
+
+func (c *recordingClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	err := c.Client.DeleteAllOf(ctx, obj, opts...)
+	record(ctx, false, newInteraction(c.Client, VerbDeleteAllOf, "", obj, types.NamespacedName{Namespace: obj.GetNamespace()}, err))
+	return err
+}
+
+func (c *recordingClient) Status() client.SubResourceWriter {
+	return &recordingSubResourceClient{SubResourceClient: c.Client.SubResource("status"), client: c.Client, subResource: "status"}
+}
+
+func (c *recordingClient) SubResource(subResource string) client.SubResourceClient {
+	return &recordingSubResourceClient{SubResourceClient: c.Client.SubResource(subResource), client: c.Client, subResource: subResource}
+}
+
+// recordingSubResourceClient records writes to a subresource
+type recordingSubResourceClient struct {
+	client.SubResourceClient
+	client      client.Client
+	subResource string
+}
+
+func (c *recordingSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	err := c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+	record(ctx, false, newInteraction(c.client, VerbCreate, c.subResource, subResource, client.ObjectKeyFromObject(obj), err))
+	return err
+}
+
+func (c *recordingSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	err := c.SubResourceClient.Update(ctx, obj, opts...)
+	record(ctx, false, newInteraction(c.client, VerbUpdate, c.subResource, obj, client.ObjectKeyFromObject(obj), err))
+	return err
+}
+
+func (c *recordingSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	err := c.SubResourceClient.Patch(ctx, obj, patch, opts...)
+	record(ctx, false, newInteraction(c.client, VerbPatch, c.subResource, obj, client.ObjectKeyFromObject(obj), err))
+	return err
+}
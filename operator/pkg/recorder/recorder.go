@@ -0,0 +1,61 @@
+package recorder
+
+import (
+	"context"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Recorder saves a compressed artifact of every reconcile it wraps. A nil Recorder disables
+// recording, so reconcilers can call it unconditionally.
+type Recorder struct {
+	dir string
+}
+
+// New creates a recorder writing artifacts into dir
+func New(dir string) *Recorder {
+	return &Recorder{
+		dir: dir,
+	}
+}
+
+// Client wraps c so its calls are captured into the recording of the current reconcile
+func (r *Recorder) Client(c client.Client) client.Client {
+	if r == nil {
+		return c
+	}
+	return NewClient(c)
+}
+
+// Wrap returns a reconciler that records each call to inner under the given controller name
+func (r *Recorder) Wrap(controller string, inner reconcile.Reconciler) reconcile.Reconciler {
+	if r == nil {
+		return inner
+	}
+	return reconcile.Func(func(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+		log := ctrl.LoggerFrom(ctx)
+
+		recording := &Recording{
+			Controller: controller,
+			Request:    req.NamespacedName,
+			StartedAt:  time.Now(),
+		}
+		result, err := inner.Reconcile(withSession(ctx, recording), req)
+
+		recording.Duration = time.Since(recording.StartedAt)
+		recording.Result = result
+		if err != nil {
+			recording.Error = err.Error()
+		}
+		if path, saveErr := recording.Save(r.dir); saveErr != nil {
+			log.Error(saveErr, "failed to save reconcile recording")
+		} else {
+			log.V(1).Info("saved reconcile recording", "path", path)
+		}
+
+		return result, err
+	})
+}
@@ -0,0 +1,136 @@
+package recorder
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// copyReconciler copies the data of the requested ConfigMap into a ConfigMap named <name>-copy
+type copyReconciler struct {
+	client client.Client
+	// skipWrite leaves the copy out, to make a replay diverge
+	skipWrite bool
+}
+
+func (r *copyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	source := &corev1.ConfigMap{}
+	if err := r.client.Get(ctx, req.NamespacedName, source); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if r.skipWrite {
+		return ctrl.Result{}, nil
+	}
+	copied := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: req.Name + "-copy", Namespace: req.Namespace},
+		Data:       source.Data,
+	}
+	return ctrl.Result{RequeueAfter: time.Minute}, r.client.Create(ctx, copied)
+}
+
+func TestSaveLoad(t *testing.T) {
+	recording := &Recording{
+		Controller: "HeadlessService",
+		Request:    types.NamespacedName{Namespace: "shop", Name: "orders"},
+		StartedAt:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Duration:   time.Second,
+		Inputs: []Interaction{{
+			Verb:   VerbGet,
+			Key:    types.NamespacedName{Namespace: "shop", Name: "orders"},
+			Object: []byte(`{"metadata":{"name":"orders"}}`),
+		}},
+		Outputs: []Interaction{{Verb: VerbUpdate, SubResource: "status", Error: "conflict"}},
+		Result:  ctrl.Result{Requeue: true},
+		Error:   "conflict",
+	}
+
+	dir := filepath.Join(t.TempDir(), "recordings")
+	path, err := recording.Save(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "headlessservice-shop-orders-20240501T120000.000000000.json.gz"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, recording) {
+		t.Errorf("Load() = %+v, want %+v", loaded, recording)
+	}
+
+	if err := os.WriteFile(path, []byte("not gzip"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load() accepted a file that is not a recording")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	source := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "orders", Namespace: "shop"},
+		Data:       map[string]string{"replicas": "3"},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(source).Build()
+
+	// Record a reconcile the way the operator does
+	dir := t.TempDir()
+	recordings := New(dir)
+	recorded := recordings.Wrap("ConfigMapCopy", &copyReconciler{client: recordings.Client(c)})
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(source)}
+	if _, err := recorded.Reconcile(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "configmapcopy-shop-orders-*.json.gz"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("recordings = %v, %v, want one", files, err)
+	}
+	recording, err := Load(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording.Inputs) != 1 || len(recording.Outputs) != 1 || recording.Outputs[0].Verb != VerbCreate {
+		t.Fatalf("recording = %+v, want the read of the source and the create of the copy", recording)
+	}
+
+	// The replay sees the source as it was read, and the same reconciler writes the same copy
+	replayed, diffs, err := Replay(context.Background(), recording, clientgoscheme.Scheme, func(c client.Client) reconcile.Reconciler {
+		return &copyReconciler{client: c}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Errorf("diffs = %v, want none", diffs)
+	}
+	if len(replayed.Outputs) != 1 || replayed.Outputs[0].Key.Name != "orders-copy" {
+		t.Errorf("replayed writes = %+v, want the create of the copy", replayed.Outputs)
+	}
+
+	// A reconciler that changed its behavior is reported
+	_, diffs, err = Replay(context.Background(), recording, clientgoscheme.Scheme, func(c client.Client) reconcile.Reconciler {
+		return &copyReconciler{client: c, skipWrite: true}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || !strings.Contains(diffs[0], "recorded create ConfigMap shop/orders-copy, not replayed") || !strings.HasPrefix(diffs[1], "result:") {
+		t.Errorf("diffs = %v, want the missing create and the changed result", diffs)
+	}
+}
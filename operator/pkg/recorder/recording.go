@@ -0,0 +1,134 @@
+package recorder
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Interaction verbs
+const (
+	VerbGet         = "get"
+	VerbList        = "list"
+	VerbCreate      = "create"
+	VerbUpdate      = "update"
+	VerbPatch       = "patch"
+	VerbDelete      = "delete"
+	VerbDeleteAllOf = "deleteallof"
+)
+
+// Recording captures the inputs and outputs of a single reconcile
+type Recording struct {
+	// Controller is the name of the recorded reconciler
+	Controller string `json:"controller"`
+	// Request is the object the reconcile was triggered for
+	Request types.NamespacedName `json:"request"`
+	// StartedAt is when the reconcile started
+	StartedAt time.Time `json:"startedAt"`
+	// Duration is how long the reconcile took
+	Duration time.Duration `json:"duration"`
+	// Inputs are the objects read from the API server, in call order
+	Inputs []Interaction `json:"inputs,omitempty"`
+	// Outputs are the writes sent to the API server, in call order
+	Outputs []Interaction `json:"outputs,omitempty"`
+	// Result is the result returned by the reconciler
+	Result ctrl.Result `json:"result"`
+	// Error is the error returned by the reconciler, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Interaction is a single API call made by a reconciler
+type Interaction struct {
+	// Verb is the API verb of the call
+	Verb string `json:"verb"`
+	// SubResource is the subresource written, e.g. status
+	SubResource string `json:"subResource,omitempty"`
+	// GroupVersionKind identifies the type of the object
+	GroupVersionKind schema.GroupVersionKind `json:"gvk"`
+	// Key is the namespace and name of the object; empty for lists
+	Key types.NamespacedName `json:"key"`
+	// Object is the object returned by a read or sent by a write
+	Object json.RawMessage `json:"object,omitempty"`
+	// Error is the error returned by the API server, if any
+	Error string `json:"error,omitempty"`
+}
+
+// newInteraction serializes obj into an interaction
+func newInteraction(c client.Client, verb, subResource string, obj runtime.Object, key types.NamespacedName, err error) Interaction {
+	interaction := Interaction{
+		Verb:        verb,
+		SubResource: subResource,
+		Key:         key,
+	}
+	if gvk, gvkErr := c.GroupVersionKindFor(obj); gvkErr == nil {
+		interaction.GroupVersionKind = gvk
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+	if data, marshalErr := json.Marshal(obj); marshalErr == nil {
+		interaction.Object = data
+	}
+	return interaction
+}
+
+// FileName returns the name the recording is saved under
+func (r *Recording) FileName() string {
+	name := fmt.Sprintf("%s-%s-%s-%s.json.gz",
+		strings.ToLower(r.Controller), r.Request.Namespace, r.Request.Name, r.StartedAt.UTC().Format("20060102T150405.000000000"))
+	return strings.ReplaceAll(name, "/", "_")
+}
+
+// Save writes the recording as gzip-compressed JSON into dir and returns the file path
+func (r *Recording) Save(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return "", fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	path := filepath.Join(dir, r.FileName())
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create recording: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if err := json.NewEncoder(gz).Encode(r); err != nil {
+		return "", fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("failed to write recording: %w", err)
+	}
+	return path, nil
+}
+
+// Load reads a recording written by Save
+func Load(path string) (*Recording, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	defer gz.Close()
+
+	recording := &Recording{}
+	if err := json.NewDecoder(gz).Decode(recording); err != nil {
+		return nil, fmt.Errorf("failed to decode recording: %w", err)
+	}
+	return recording, nil
+}
@@ -0,0 +1,169 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/k8s-playgrounds/operator/pkg/endpoints"
+)
+
+// Replay runs a reconciler built by build against a fake client seeded with the objects the
+// recorded reconcile read, and returns the new recording along with the differences between
+// the recorded and replayed writes. Only the first observed version of each object is seeded,
+// since later reads may already reflect the reconciler's own writes.
+func Replay(ctx context.Context, recording *Recording, scheme *runtime.Scheme, build func(client.Client) reconcile.Reconciler) (*Recording, []string, error) {
+	seed, err := seedObjects(recording, scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	statusKinds := make(map[schema.GroupVersionKind]bool)
+	for _, out := range recording.Outputs {
+		if out.SubResource == "status" {
+			statusKinds[out.GroupVersionKind] = true
+		}
+	}
+	var withStatus []client.Object
+	for _, obj := range seed {
+		if statusKinds[obj.GetObjectKind().GroupVersionKind()] {
+			withStatus = append(withStatus, obj)
+		}
+	}
+
+	builder := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(seed...).
+		WithStatusSubresource(withStatus...)
+	// Reconcilers list through the same cache indexes as in the operator
+	for _, index := range endpoints.Indexes {
+		builder = builder.WithIndex(index.Object, index.Field, index.Extract)
+	}
+	fakeClient := builder.Build()
+
+	replayed := &Recording{
+		Controller: recording.Controller,
+		Request:    recording.Request,
+		StartedAt:  time.Now(),
+	}
+	reconciler := build(NewClient(fakeClient))
+	result, reconcileErr := reconciler.Reconcile(withSession(ctx, replayed), ctrl.Request{NamespacedName: recording.Request})
+	replayed.Duration = time.Since(replayed.StartedAt)
+	replayed.Result = result
+	if reconcileErr != nil {
+		replayed.Error = reconcileErr.Error()
+	}
+
+	return replayed, Compare(recording, replayed), nil
+}
+
+// Compare lists the differences between the writes, results and errors of two recordings
+func Compare(recorded, replayed *Recording) []string {
+	var diffs []string
+
+	for i := 0; i < len(recorded.Outputs) || i < len(replayed.Outputs); i++ {
+		switch {
+		case i >= len(replayed.Outputs):
+			diffs = append(diffs, fmt.Sprintf("write %d: recorded %s, not replayed", i, describe(recorded.Outputs[i])))
+		case i >= len(recorded.Outputs):
+			diffs = append(diffs, fmt.Sprintf("write %d: replayed %s, not recorded", i, describe(replayed.Outputs[i])))
+		case describe(recorded.Outputs[i]) != describe(replayed.Outputs[i]):
+			diffs = append(diffs, fmt.Sprintf("write %d: recorded %s, replayed %s", i, describe(recorded.Outputs[i]), describe(replayed.Outputs[i])))
+		}
+	}
+
+	if recorded.Result != replayed.Result {
+		diffs = append(diffs, fmt.Sprintf("result: recorded %+v, replayed %+v", recorded.Result, replayed.Result))
+	}
+	if recorded.Error != replayed.Error {
+		diffs = append(diffs, fmt.Sprintf("error: recorded %q, replayed %q", recorded.Error, replayed.Error))
+	}
+	return diffs
+}
+
+// describe summarizes an interaction for comparison
+func describe(interaction Interaction) string {
+	verb := interaction.Verb
+	if interaction.SubResource != "" {
+		verb = fmt.Sprintf("%s %s", verb, interaction.SubResource)
+	}
+	s := fmt.Sprintf("%s %s %s", verb, interaction.GroupVersionKind.Kind, interaction.Key)
+	if interaction.Error != "" {
+		s += " (failed)"
+	}
+	return s
+}
+
+// seedObjects decodes the first observed version of every object read during the recording
+func seedObjects(recording *Recording, scheme *runtime.Scheme) ([]client.Object, error) {
+	seen := make(map[string]bool)
+	var objects []client.Object
+
+	add := func(gvk schema.GroupVersionKind, obj client.Object) {
+		key := fmt.Sprintf("%s/%s", gvk, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()})
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		objects = append(objects, obj)
+	}
+
+	for _, in := range recording.Inputs {
+		if in.Error != "" || len(in.Object) == 0 {
+			continue
+		}
+
+		switch in.Verb {
+		case VerbGet:
+			obj, err := decode(scheme, in.GroupVersionKind, in.Object)
+			if err != nil {
+				return nil, err
+			}
+			clientObj, ok := obj.(client.Object)
+			if !ok {
+				return nil, fmt.Errorf("recorded %s is not a client object", in.GroupVersionKind.Kind)
+			}
+			add(in.GroupVersionKind, clientObj)
+		case VerbList:
+			list, err := decode(scheme, in.GroupVersionKind, in.Object)
+			if err != nil {
+				return nil, err
+			}
+			items, err := meta.ExtractList(list)
+			if err != nil {
+				return nil, fmt.Errorf("failed to extract %s items: %w", in.GroupVersionKind.Kind, err)
+			}
+			itemGVK := in.GroupVersionKind.GroupVersion().WithKind(strings.TrimSuffix(in.GroupVersionKind.Kind, "List"))
+			for _, item := range items {
+				if obj, ok := item.(client.Object); ok {
+					add(itemGVK, obj)
+				}
+			}
+		}
+	}
+	return objects, nil
+}
+
+// decode unmarshals raw JSON into a new object of the given kind
+func decode(scheme *runtime.Scheme, gvk schema.GroupVersionKind, data json.RawMessage) (runtime.Object, error) {
+	obj, err := scheme.New(gvk)
+	if err != nil {
+		return nil, fmt.Errorf("unknown kind %s in recording: %w", gvk, err)
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, fmt.Errorf("failed to decode recorded %s: %w", gvk.Kind, err)
+	}
+	return obj, nil
+}
@@ -0,0 +1,120 @@
+package reportstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix marks reports being written; they are renamed into place once complete
+const tempPrefix = ".tmp-"
+
+// FileStore keeps reports as files below a directory, typically a mounted PVC
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store writing below dir
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: filepath.Clean(dir)}
+}
+
+// Put writes the report to a temporary file and renames it into place, so readers never see a
+// partial report
+func (s *FileStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return "", fmt.Errorf("failed to store report %s: %w", key, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), tempPrefix+"*")
+	if err != nil {
+		return "", fmt.Errorf("failed to store report %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to store report %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to store report %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return "", fmt.Errorf("failed to store report %s: %w", key, err)
+	}
+	return "file://" + filepath.ToSlash(file), nil
+}
+
+// Get reads the report file
+func (s *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	file, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// List walks the directory for reports whose key starts with prefix
+func (s *FileStore) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(s.dir, func(file string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && file == s.dir {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	return objects, nil
+}
+
+// Delete removes the report file
+func (s *FileStore) Delete(ctx context.Context, key string) error {
+	file, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete report %s: %w", key, err)
+	}
+	return nil
+}
+
+// path returns the file of key, refusing keys that leave the directory
+func (s *FileStore) path(key string) (string, error) {
+	file := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(file, s.dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid report key %q", key)
+	}
+	return file, nil
+}
@@ -0,0 +1,123 @@
+package reportstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const (
+	// DefaultRetentionInterval is how often expired reports are pruned
+	DefaultRetentionInterval = time.Hour
+	// DefaultMaxAge is how long reports are kept unless a policy of their subsystem says
+	// otherwise
+	DefaultMaxAge = 30 * 24 * time.Hour
+)
+
+// RetentionConfig selects how long reports are kept
+type RetentionConfig struct {
+	// Interval is how often expired reports are pruned
+	Interval time.Duration
+	// MaxAge is how long a report is kept after it was last written
+	MaxAge time.Duration
+	// Policies overrides MaxAge for the reports of a subsystem, keyed by the first segment of
+	// their keys, e.g. "loadtest"
+	Policies map[string]time.Duration
+}
+
+// ParsePolicies parses retention policies of the form "loadtest=168h,diagnostics=72h"
+func ParsePolicies(s string) (map[string]time.Duration, error) {
+	policies := map[string]time.Duration{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, value, ok := strings.Cut(entry, "=")
+		if !ok || prefix == "" {
+			return nil, fmt.Errorf("invalid retention policy %q: want <subsystem>=<duration>", entry)
+		}
+		maxAge, err := time.ParseDuration(value)
+		if err != nil || maxAge <= 0 {
+			return nil, fmt.Errorf("invalid retention policy %q: want a positive duration", entry)
+		}
+		policies[prefix] = maxAge
+	}
+	return policies, nil
+}
+
+// Pruner periodically deletes the reports that outlived their retention
+type Pruner struct {
+	store  Store
+	config RetentionConfig
+}
+
+// NewPruner creates a pruner of store. Add it to the manager so it runs on the leader.
+func NewPruner(store Store, config RetentionConfig) *Pruner {
+	if config.Interval <= 0 {
+		config.Interval = DefaultRetentionInterval
+	}
+	if config.MaxAge <= 0 {
+		config.MaxAge = DefaultMaxAge
+	}
+	return &Pruner{store: store, config: config}
+}
+
+// NeedLeaderElection prunes on the leader only
+func (p *Pruner) NeedLeaderElection() bool {
+	return true
+}
+
+// Start prunes once and then every interval until ctx is cancelled
+func (p *Pruner) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("reportstore")
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+	for {
+		pruned, err := p.Prune(ctx, time.Now())
+		if err != nil {
+			log.Error(err, "report pruning failed")
+		}
+		for _, object := range pruned {
+			log.Info("pruned expired report", "key", object.Key, "lastModified", object.LastModified)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Prune deletes the reports that expired at now and returns them. Reports that could not be
+// deleted are retried by the next pruning.
+func (p *Pruner) Prune(ctx context.Context, now time.Time) ([]Object, error) {
+	objects, err := p.store.List(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var pruned []Object
+	var errs []error
+	for _, object := range objects {
+		maxAge, ok := p.config.Policies[TopLevel(object.Key)]
+		if !ok {
+			maxAge = p.config.MaxAge
+		}
+		if now.Sub(object.LastModified) < maxAge {
+			continue
+		}
+		if err := p.store.Delete(ctx, object.Key); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		pruned = append(pruned, object)
+	}
+	if len(errs) > 0 {
+		return pruned, fmt.Errorf("%d reports not pruned, first: %w", len(errs), errs[0])
+	}
+	return pruned, nil
+}
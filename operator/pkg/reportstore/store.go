@@ -0,0 +1,123 @@
+package reportstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned by Get for a key the store does not hold
+var ErrNotFound = errors.New("report not found")
+
+// Store keeps reports too large for custom resource status or ConfigMaps, such as load test
+// results and diagnostic output. Keys are slash-separated paths relative to the store; build
+// them with Key.
+type Store interface {
+	// Put writes data under key, replacing any previous report, and returns the URI of the
+	// report to record in status
+	Put(ctx context.Context, key string, data []byte) (string, error)
+	// Get reads the report under key
+	Get(ctx context.Context, key string) ([]byte, error)
+	// List returns the reports whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Delete removes the report under key; a missing report is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// Object is a report held by a store
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Environment variables holding the credentials of bucket stores
+const (
+	AccessKeyIDEnv     = "AWS_ACCESS_KEY_ID"
+	SecretAccessKeyEnv = "AWS_SECRET_ACCESS_KEY"
+	SessionTokenEnv    = "AWS_SESSION_TOKEN"
+	// GCS HMAC keys; the AWS variables are used when unset
+	GCSAccessKeyIDEnv     = "GCS_HMAC_ACCESS_KEY_ID"
+	GCSSecretAccessKeyEnv = "GCS_HMAC_SECRET"
+)
+
+// gcsEndpoint is the S3-compatible XML API of Google Cloud Storage
+const gcsEndpoint = "https://storage.googleapis.com"
+
+// Open returns the store of a URI:
+//
+//	file:///var/lib/reports            a directory, e.g. a mounted PVC
+//	s3://bucket/prefix?region=eu-west-1 an S3 bucket; endpoint= selects an S3-compatible service
+//	gs://bucket/prefix                 a Google Cloud Storage bucket, through its XML API
+//
+// An empty URI returns a nil store: reports are then only kept where they fit, in status or
+// ConfigMaps.
+func Open(uri string) (Store, error) {
+	if uri == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid report store %q: %w", uri, err)
+	}
+	switch u.Scheme {
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid report store %q: a directory is required", uri)
+		}
+		return NewFileStore(u.Path), nil
+	case "s3":
+		config := S3Config{
+			Bucket:          u.Host,
+			Prefix:          u.Path,
+			Region:          u.Query().Get("region"),
+			Endpoint:        u.Query().Get("endpoint"),
+			AccessKeyID:     os.Getenv(AccessKeyIDEnv),
+			SecretAccessKey: os.Getenv(SecretAccessKeyEnv),
+			SessionToken:    os.Getenv(SessionTokenEnv),
+		}
+		return NewS3Store(config)
+	case "gs":
+		config := S3Config{
+			Scheme:          "gs",
+			Bucket:          u.Host,
+			Prefix:          u.Path,
+			Region:          "auto",
+			Endpoint:        gcsEndpoint,
+			AccessKeyID:     os.Getenv(GCSAccessKeyIDEnv),
+			SecretAccessKey: os.Getenv(GCSSecretAccessKeyEnv),
+		}
+		if config.AccessKeyID == "" {
+			config.AccessKeyID = os.Getenv(AccessKeyIDEnv)
+			config.SecretAccessKey = os.Getenv(SecretAccessKeyEnv)
+		}
+		return NewS3Store(config)
+	default:
+		return nil, fmt.Errorf("invalid report store %q: unsupported scheme %q", uri, u.Scheme)
+	}
+}
+
+// Key joins parts into a report key. Parts are names of objects and runs, so slashes and
+// leading dots are replaced to keep every part a single path segment.
+func Key(parts ...string) string {
+	segments := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.ReplaceAll(part, "/", "_")
+		part = strings.TrimLeft(part, ".")
+		if part == "" {
+			part = "_"
+		}
+		segments = append(segments, part)
+	}
+	return path.Join(segments...)
+}
+
+// TopLevel returns the first segment of a key, the subsystem that wrote the report
+func TopLevel(key string) string {
+	return strings.SplitN(key, "/", 2)[0]
+}
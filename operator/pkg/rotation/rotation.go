@@ -0,0 +1,250 @@
+package rotation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+	"aviatrix-operator/pkg/schedule"
+)
+
+// Credential types
+const (
+	AuthTypePSK         = "psk"
+	AuthTypeCertificate = "certificate"
+)
+
+// Rotation reasons
+const (
+	ReasonInitial   = "Initial"
+	ReasonScheduled = "Scheduled"
+	ReasonRequested = "Requested"
+)
+
+// Rotation results
+const (
+	ResultOverlapping = "Overlapping"
+	ResultCompleted   = "Completed"
+	ResultFailed      = "Failed"
+)
+
+// RotateAnnotation requests a rotation; setting it to a new value rotates the keys once
+const RotateAnnotation = "aviatrix.k8s.io/rotate"
+
+// Secret data keys; the previous credential is stored under the same keys with PreviousPrefix
+const (
+	KeyPreSharedKey = "psk"
+	KeyCertificate  = "tls.crt"
+	KeyPrivateKey   = "tls.key"
+	PreviousPrefix  = "previous-"
+)
+
+const (
+	// DefaultOverlap is how long the previous key stays valid when OverlapSeconds is unset
+	DefaultOverlap = time.Hour
+	// DefaultCertificateValidity is how long certificates are valid when CertificateValidityDays is unset
+	DefaultCertificateValidity = 365 * 24 * time.Hour
+	// DefaultHistoryLimit caps the history when HistoryLimit is unset
+	DefaultHistoryLimit = 10
+
+	// pskLength is the number of characters in a generated pre-shared key
+	pskLength = 48
+	// pskAlphabet avoids characters that network devices reject in pre-shared keys
+	pskAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	// maxScheduleSearch bounds how far ahead the next scheduled rotation is searched
+	maxScheduleSearch = 400 * 24 * time.Hour
+)
+
+// Credential is a pre-shared key or a certificate with its private key
+type Credential struct {
+	PreSharedKey string
+	Certificate  string
+	PrivateKey   string
+}
+
+// IsZero reports whether the credential is empty
+func (c Credential) IsZero() bool {
+	return c.PreSharedKey == "" && c.Certificate == ""
+}
+
+// ID returns a fingerprint that identifies the credential without revealing it
+func (c Credential) ID() string {
+	if c.IsZero() {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(c.PreSharedKey + c.Certificate))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Generate creates a new credential of the given type
+func Generate(authType, commonName string, validity time.Duration, now time.Time) (Credential, error) {
+	switch authType {
+	case "", AuthTypePSK:
+		psk, err := generatePSK()
+		return Credential{PreSharedKey: psk}, err
+	case AuthTypeCertificate:
+		return generateCertificate(commonName, validity, now)
+	default:
+		return Credential{}, fmt.Errorf("unsupported auth type %q", authType)
+	}
+}
+
+func generatePSK() (string, error) {
+	max := big.NewInt(int64(len(pskAlphabet)))
+	key := make([]byte, pskLength)
+	for i := range key {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		key[i] = pskAlphabet[n.Int64()]
+	}
+	return string(key), nil
+}
+
+// generateCertificate creates a self-signed ECDSA certificate for the connection
+func generateCertificate(commonName string, validity time.Duration, now time.Time) (Credential, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return Credential{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return Credential{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return Credential{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		Certificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})),
+	}, nil
+}
+
+// FromSecretData reads the current or previous credential from Secret data
+func FromSecretData(data map[string][]byte, previous bool) Credential {
+	prefix := ""
+	if previous {
+		prefix = PreviousPrefix
+	}
+	return Credential{
+		PreSharedKey: string(data[prefix+KeyPreSharedKey]),
+		Certificate:  string(data[prefix+KeyCertificate]),
+		PrivateKey:   string(data[prefix+KeyPrivateKey]),
+	}
+}
+
+// SecretData encodes the current credential and, while the keys overlap, the previous one
+func SecretData(current, previous Credential) map[string][]byte {
+	data := make(map[string][]byte)
+	add := func(prefix string, c Credential) {
+		for key, value := range map[string]string{
+			KeyPreSharedKey: c.PreSharedKey,
+			KeyCertificate:  c.Certificate,
+			KeyPrivateKey:   c.PrivateKey,
+		} {
+			if value != "" {
+				data[prefix+key] = []byte(value)
+			}
+		}
+	}
+	add("", current)
+	add(PreviousPrefix, previous)
+	return data
+}
+
+// Auth builds the connection credentials with standby accepted next to current
+func Auth(authType string, current, standby Credential) aviatrix.ConnectionAuth {
+	if authType == "" {
+		authType = AuthTypePSK
+	}
+	return aviatrix.ConnectionAuth{
+		AuthType:            authType,
+		PreSharedKey:        current.PreSharedKey,
+		StandbyPreSharedKey: standby.PreSharedKey,
+		Certificate:         current.Certificate,
+		PrivateKey:          current.PrivateKey,
+		StandbyCertificate:  standby.Certificate,
+	}
+}
+
+// NextScheduled returns the first scheduled rotation after t. Cron.Next only looks about a
+// week ahead, so the search advances a week at a time to support monthly schedules.
+func NextScheduled(cron *schedule.Cron, t time.Time) (time.Time, bool) {
+	for from := t; from.Before(t.Add(maxScheduleSearch)); from = from.Add(7 * 24 * time.Hour) {
+		if next, ok := cron.Next(from); ok {
+			return next, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Due returns why the keys should rotate now, or an empty string. Keys rotate when none were
+// issued yet, when the rotate annotation changed, and when a scheduled time passed since the
+// last rotation. cron may be nil for on-demand rotation only.
+func Due(rotation *aviatrixv1alpha1.AviatrixKeyRotation, cron *schedule.Cron, location *time.Location, now time.Time) string {
+	status := &rotation.Status
+	if status.CurrentKeyID == "" || status.LastRotated == nil {
+		return ReasonInitial
+	}
+	if request := rotation.Annotations[RotateAnnotation]; request != "" && request != status.LastRequest {
+		return ReasonRequested
+	}
+	if cron != nil {
+		if next, ok := NextScheduled(cron, status.LastRotated.In(location)); ok && !next.After(now) {
+			return ReasonScheduled
+		}
+	}
+	return ""
+}
+
+// AppendHistory adds a record and drops the oldest ones beyond limit
+func AppendHistory(history []aviatrixv1alpha1.KeyRotationRecord, record aviatrixv1alpha1.KeyRotationRecord, limit int) []aviatrixv1alpha1.KeyRotationRecord {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	history = append(history, record)
+	if len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return history
+}
+
+// Installed returns the credential the gateways use, given the Secret data and the key ID
+// recorded in status. A rotation interrupted after storing its new key leaves the installed
+// credential under the previous keys.
+func Installed(data map[string][]byte, currentKeyID string) Credential {
+	current := FromSecretData(data, false)
+	if currentKeyID == "" || current.ID() == currentKeyID {
+		return current
+	}
+	if previous := FromSecretData(data, true); previous.ID() == currentKeyID {
+		return previous
+	}
+	return current
+}
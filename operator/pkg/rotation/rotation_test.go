@@ -0,0 +1,127 @@
+package rotation
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/schedule"
+)
+
+func TestGenerate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	first, err := Generate(AuthTypePSK, "s2c-branch", 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := Generate("", "s2c-branch", 0, now)
+	if len(first.PreSharedKey) != pskLength || first.PreSharedKey == second.PreSharedKey {
+		t.Errorf("PSKs = %q and %q, want two different %d character keys", first.PreSharedKey, second.PreSharedKey, pskLength)
+	}
+	if first.ID() == "" || first.ID() == second.ID() {
+		t.Errorf("IDs = %q and %q, want two different fingerprints", first.ID(), second.ID())
+	}
+
+	cert, err := Generate(AuthTypeCertificate, "s2c-branch", 30*24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode([]byte(cert.Certificate))
+	if block == nil || cert.PrivateKey == "" {
+		t.Fatalf("certificate = %q, want a PEM certificate and key", cert.Certificate)
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Subject.CommonName != "s2c-branch" || !parsed.NotAfter.Equal(now.Add(30*24*time.Hour)) {
+		t.Errorf("certificate subject %q expires %s", parsed.Subject.CommonName, parsed.NotAfter)
+	}
+
+	if _, err := Generate("token", "s2c-branch", 0, now); err == nil {
+		t.Error("Generate() succeeded for an unknown auth type")
+	}
+}
+
+func TestSecretDataRoundTrip(t *testing.T) {
+	current := Credential{PreSharedKey: "new"}
+	previous := Credential{PreSharedKey: "old"}
+
+	data := SecretData(current, previous)
+	if got := FromSecretData(data, false); got != current {
+		t.Errorf("current = %+v, want %+v", got, current)
+	}
+	if got := FromSecretData(data, true); got != previous {
+		t.Errorf("previous = %+v, want %+v", got, previous)
+	}
+	if _, ok := SecretData(current, Credential{})[PreviousPrefix+KeyPreSharedKey]; ok {
+		t.Error("previous key kept after the overlap")
+	}
+}
+
+func TestInstalled(t *testing.T) {
+	installed := Credential{PreSharedKey: "old"}
+	stored := Credential{PreSharedKey: "new"}
+
+	// An interrupted rotation stored the new key, but the gateways still use the old one
+	data := SecretData(stored, installed)
+	if got := Installed(data, installed.ID()); got != installed {
+		t.Errorf("Installed() = %+v, want the previous key %+v", got, installed)
+	}
+	if got := Installed(data, stored.ID()); got != stored {
+		t.Errorf("Installed() = %+v, want the current key %+v", got, stored)
+	}
+	// A key seeded by the user before the first rotation is kept valid during it
+	if got := Installed(SecretData(installed, Credential{}), ""); got != installed {
+		t.Errorf("Installed() = %+v, want the seeded key %+v", got, installed)
+	}
+}
+
+func TestDue(t *testing.T) {
+	monthly, err := schedule.ParseCron("0 3 1 * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastRotated := metav1.NewTime(time.Date(2026, 3, 1, 3, 0, 0, 0, time.UTC))
+	rotated := aviatrixv1alpha1.AviatrixKeyRotationStatus{CurrentKeyID: "abc", LastRotated: &lastRotated, LastRequest: "1"}
+
+	tests := []struct {
+		name       string
+		status     aviatrixv1alpha1.AviatrixKeyRotationStatus
+		annotation string
+		cron       *schedule.Cron
+		now        time.Time
+		want       string
+	}{
+		{name: "never rotated", now: lastRotated.Time, want: ReasonInitial},
+		{name: "not due", status: rotated, cron: monthly, now: time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{name: "scheduled", status: rotated, cron: monthly, now: time.Date(2026, 4, 1, 3, 0, 0, 0, time.UTC), want: ReasonScheduled},
+		{name: "on demand only", status: rotated, now: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "handled request", status: rotated, annotation: "1", now: lastRotated.Time},
+		{name: "new request", status: rotated, annotation: "2", now: lastRotated.Time, want: ReasonRequested},
+	}
+	for _, tt := range tests {
+		keyRotation := &aviatrixv1alpha1.AviatrixKeyRotation{Status: tt.status}
+		if tt.annotation != "" {
+			keyRotation.Annotations = map[string]string{RotateAnnotation: tt.annotation}
+		}
+		if got := Due(keyRotation, tt.cron, time.UTC, tt.now); got != tt.want {
+			t.Errorf("%s: Due() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAppendHistory(t *testing.T) {
+	var history []aviatrixv1alpha1.KeyRotationRecord
+	for _, id := range []string{"a", "b", "c"} {
+		history = AppendHistory(history, aviatrixv1alpha1.KeyRotationRecord{KeyID: id}, 2)
+	}
+	if len(history) != 2 || history[0].KeyID != "b" || history[1].KeyID != "c" {
+		t.Errorf("history = %+v, want the two newest records", history)
+	}
+}
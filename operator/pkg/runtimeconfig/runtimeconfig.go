@@ -0,0 +1,269 @@
+package runtimeconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	"aviatrix-operator/pkg/cloudevents"
+	"aviatrix-operator/pkg/features"
+	"aviatrix-operator/pkg/throttle"
+)
+
+// Keys of the configuration ConfigMap
+const (
+	KeyLogLevel                      = "logLevel"
+	KeyClusterResyncInterval         = "clusterResyncInterval"
+	KeyHeadlessServiceResyncInterval = "headlessServiceResyncInterval"
+	KeyFleetResyncInterval           = "fleetResyncInterval"
+	KeyGatewayResyncInterval         = "gatewayResyncInterval"
+	KeyFeatureGates                  = "featureGates"
+	KeyProbeRate                     = "probeRate"
+	KeyProbeBurst                    = "probeBurst"
+	KeyNamespaceProbeRate            = "namespaceProbeRate"
+	KeyNamespaceProbeBurst           = "namespaceProbeBurst"
+	KeyEventSink                     = "eventSink"
+)
+
+// Defaults for settings the ConfigMap leaves unset
+const (
+	DefaultClusterResyncInterval         = 5 * time.Minute
+	DefaultHeadlessServiceResyncInterval = 2 * time.Minute
+	DefaultFleetResyncInterval           = 30 * time.Second
+	DefaultGatewayResyncInterval         = 5 * time.Minute
+)
+
+// Bounds of the resync intervals. Shorter intervals overload the API server with thousands of
+// objects; longer ones let drift go unnoticed for too long.
+const (
+	MinResyncInterval = 10 * time.Second
+	MaxResyncInterval = 24 * time.Hour
+)
+
+// Config holds the operator settings that can change without a restart
+type Config struct {
+	// LogLevel is debug, info, warn, error or a positive verbosity; empty keeps the level of
+	// the --zap-log-level flag
+	LogLevel string
+	// Resync intervals of running resources
+	ClusterResyncInterval         time.Duration
+	HeadlessServiceResyncInterval time.Duration
+	FleetResyncInterval           time.Duration
+	GatewayResyncInterval         time.Duration
+	// FeatureGates override the --feature-gates flag
+	FeatureGates map[features.Feature]bool
+	// Probes rate limits DNS tests and discovery lookups; its circuit breaker settings are not
+	// configurable at runtime. Zero fields use the throttle defaults.
+	Probes throttle.Config
+	// EventSink overrides the --event-sink flag; empty keeps the flag
+	EventSink string
+}
+
+// Defaults returns the configuration in effect without a ConfigMap
+func Defaults() Config {
+	return Config{
+		ClusterResyncInterval:         DefaultClusterResyncInterval,
+		HeadlessServiceResyncInterval: DefaultHeadlessServiceResyncInterval,
+		FleetResyncInterval:           DefaultFleetResyncInterval,
+		GatewayResyncInterval:         DefaultGatewayResyncInterval,
+	}
+}
+
+// Parse validates the data of the configuration ConfigMap. Keys it leaves out take their
+// defaults. Unknown keys are rejected so that typos do not go unnoticed.
+func Parse(data map[string]string, gate *features.Gate) (Config, error) {
+	config := Defaults()
+	var errs field.ErrorList
+	for key, raw := range data {
+		path := field.NewPath("data", key)
+		value := strings.TrimSpace(raw)
+		switch key {
+		case KeyLogLevel:
+			if _, err := ParseLogLevel(value); err != nil {
+				errs = append(errs, field.Invalid(path, raw, err.Error()))
+			}
+			config.LogLevel = value
+		case KeyClusterResyncInterval:
+			errs = append(errs, parseInterval(path, value, &config.ClusterResyncInterval)...)
+		case KeyHeadlessServiceResyncInterval:
+			errs = append(errs, parseInterval(path, value, &config.HeadlessServiceResyncInterval)...)
+		case KeyFleetResyncInterval:
+			errs = append(errs, parseInterval(path, value, &config.FleetResyncInterval)...)
+		case KeyGatewayResyncInterval:
+			errs = append(errs, parseInterval(path, value, &config.GatewayResyncInterval)...)
+		case KeyFeatureGates:
+			overrides, err := features.ParseOverrides(value)
+			if err == nil {
+				err = gate.Validate(overrides)
+			}
+			if err != nil {
+				errs = append(errs, field.Invalid(path, raw, err.Error()))
+			}
+			config.FeatureGates = overrides
+		case KeyProbeRate:
+			errs = append(errs, parseRate(path, value, &config.Probes.Rate)...)
+		case KeyNamespaceProbeRate:
+			errs = append(errs, parseRate(path, value, &config.Probes.NamespaceRate)...)
+		case KeyProbeBurst:
+			errs = append(errs, parseBurst(path, value, &config.Probes.Burst)...)
+		case KeyNamespaceProbeBurst:
+			errs = append(errs, parseBurst(path, value, &config.Probes.NamespaceBurst)...)
+		case KeyEventSink:
+			if value != "" {
+				if _, err := cloudevents.NewSink(value); err != nil {
+					errs = append(errs, field.Invalid(path, raw, err.Error()))
+				}
+			}
+			config.EventSink = value
+		default:
+			errs = append(errs, field.NotSupported(path, raw, keys()))
+		}
+	}
+	if len(errs) > 0 {
+		return Config{}, errs.ToAggregate()
+	}
+	return config, nil
+}
+
+// ParseLogLevel parses a log level name or a verbosity, where verbosity 2 logs V(2) messages
+func ParseLogLevel(value string) (zapcore.Level, error) {
+	switch value {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "warn":
+		return zapcore.WarnLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	verbosity, err := strconv.Atoi(value)
+	if err != nil || verbosity <= 0 || verbosity > 127 {
+		return 0, fmt.Errorf("must be debug, info, warn, error or a verbosity between 1 and 127")
+	}
+	return zapcore.Level(-verbosity), nil
+}
+
+func parseInterval(path *field.Path, value string, interval *time.Duration) field.ErrorList {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, value, "must be a duration such as 5m")}
+	}
+	if parsed < MinResyncInterval || parsed > MaxResyncInterval {
+		return field.ErrorList{field.Invalid(path, value, fmt.Sprintf("must be between %s and %s", MinResyncInterval, MaxResyncInterval))}
+	}
+	*interval = parsed
+	return nil
+}
+
+func parseRate(path *field.Path, value string, rate *float64) field.ErrorList {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed <= 0 {
+		return field.ErrorList{field.Invalid(path, value, "must be a positive number of probes per second")}
+	}
+	*rate = parsed
+	return nil
+}
+
+func parseBurst(path *field.Path, value string, burst *int) field.ErrorList {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return field.ErrorList{field.Invalid(path, value, "must be a positive number of probes")}
+	}
+	*burst = parsed
+	return nil
+}
+
+// keys returns the supported keys, sorted
+func keys() []string {
+	supported := []string{
+		KeyLogLevel, KeyClusterResyncInterval, KeyHeadlessServiceResyncInterval, KeyFleetResyncInterval,
+		KeyGatewayResyncInterval, KeyFeatureGates, KeyProbeRate, KeyProbeBurst, KeyNamespaceProbeRate, KeyNamespaceProbeBurst, KeyEventSink,
+	}
+	sort.Strings(supported)
+	return supported
+}
+
+// Changed returns the keys whose settings differ between two configurations, sorted
+func Changed(previous, next Config) []string {
+	var changed []string
+	add := func(key string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, key)
+		}
+	}
+	add(KeyLogLevel, previous.LogLevel, next.LogLevel)
+	add(KeyClusterResyncInterval, previous.ClusterResyncInterval, next.ClusterResyncInterval)
+	add(KeyHeadlessServiceResyncInterval, previous.HeadlessServiceResyncInterval, next.HeadlessServiceResyncInterval)
+	add(KeyFleetResyncInterval, previous.FleetResyncInterval, next.FleetResyncInterval)
+	add(KeyGatewayResyncInterval, previous.GatewayResyncInterval, next.GatewayResyncInterval)
+	if len(previous.FeatureGates) > 0 || len(next.FeatureGates) > 0 {
+		add(KeyFeatureGates, previous.FeatureGates, next.FeatureGates)
+	}
+	add(KeyProbeRate, previous.Probes.Rate, next.Probes.Rate)
+	add(KeyProbeBurst, previous.Probes.Burst, next.Probes.Burst)
+	add(KeyNamespaceProbeRate, previous.Probes.NamespaceRate, next.Probes.NamespaceRate)
+	add(KeyNamespaceProbeBurst, previous.Probes.NamespaceBurst, next.Probes.NamespaceBurst)
+	add(KeyEventSink, previous.EventSink, next.EventSink)
+	sort.Strings(changed)
+	return changed
+}
+
+// Store holds the configuration in effect and notifies subscribers of changes
+type Store struct {
+	mu          sync.RWMutex
+	current     Config
+	subscribers []func(Config)
+}
+
+// Default holds the configuration of the running operator
+var Default = NewStore()
+
+// Current returns the configuration in effect in the default store
+func Current() Config {
+	return Default.Current()
+}
+
+// NewStore creates a store holding the defaults
+func NewStore() *Store {
+	return &Store{current: Defaults()}
+}
+
+// Current returns the configuration in effect
+func (s *Store) Current() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Subscribe registers a function called with every configuration that changes settings.
+// Settings read with Current need no subscription.
+func (s *Store) Subscribe(fn func(Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Apply puts a validated configuration into effect and returns the keys it changed.
+// Subscribers are only called when a setting changed.
+func (s *Store) Apply(config Config) []string {
+	s.mu.Lock()
+	changed := Changed(s.current, config)
+	s.current = config
+	subscribers := append([]func(Config){}, s.subscribers...)
+	s.mu.Unlock()
+
+	if len(changed) > 0 {
+		for _, fn := range subscribers {
+			fn(config)
+		}
+	}
+	return changed
+}
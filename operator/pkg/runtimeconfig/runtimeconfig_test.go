@@ -0,0 +1,100 @@
+package runtimeconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+
+	"aviatrix-operator/pkg/features"
+)
+
+func testGate() *features.Gate {
+	return features.NewGate(map[features.Feature]features.FeatureSpec{
+		"AlphaThing": {Default: false, Stage: features.Alpha},
+		"GAThing":    {Default: true, Stage: features.GA, LockToDefault: true},
+	})
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse(map[string]string{
+		KeyLogLevel:              "debug",
+		KeyClusterResyncInterval: "10m",
+		KeyFeatureGates:          "AlphaThing=true",
+		KeyProbeRate:             "5.5",
+		KeyNamespaceProbeBurst:   "3",
+		KeyEventSink:             "nats://nats:4222/events",
+	}, testGate())
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if config.LogLevel != "debug" || config.ClusterResyncInterval != 10*time.Minute || !config.FeatureGates["AlphaThing"] ||
+		config.Probes.Rate != 5.5 || config.Probes.NamespaceBurst != 3 || config.EventSink != "nats://nats:4222/events" {
+		t.Errorf("Parse() = %+v, want the settings of the ConfigMap", config)
+	}
+	if config.HeadlessServiceResyncInterval != DefaultHeadlessServiceResyncInterval || config.FleetResyncInterval != DefaultFleetResyncInterval ||
+		config.GatewayResyncInterval != DefaultGatewayResyncInterval {
+		t.Errorf("Parse() = %+v, want defaults for the keys left out", config)
+	}
+}
+
+func TestParseRejectsInvalidSettings(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown key":        {"logLevl": "debug"},
+		"unknown level":      {KeyLogLevel: "verbose"},
+		"interval too short": {KeyClusterResyncInterval: "1s"},
+		"interval too long":  {KeyGatewayResyncInterval: "48h"},
+		"not a duration":     {KeyFleetResyncInterval: "often"},
+		"unknown feature":    {KeyFeatureGates: "Unknown=true"},
+		"locked feature":     {KeyFeatureGates: "GAThing=false"},
+		"negative rate":      {KeyProbeRate: "-1"},
+		"zero burst":         {KeyProbeBurst: "0"},
+		"unsupported sink":   {KeyEventSink: "ftp://events"},
+	}
+	for name, data := range tests {
+		if config, err := Parse(data, testGate()); err == nil {
+			t.Errorf("%s: Parse() = %+v, want an error", name, config)
+		}
+	}
+
+	_, err := Parse(map[string]string{KeyProbeRate: "fast", KeyProbeBurst: "many"}, testGate())
+	if err == nil || !strings.Contains(err.Error(), KeyProbeRate) || !strings.Contains(err.Error(), KeyProbeBurst) {
+		t.Errorf("Parse() error = %v, want both invalid keys reported", err)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	if level, err := ParseLogLevel("3"); err != nil || level != zapcore.Level(-3) {
+		t.Errorf("ParseLogLevel(3) = %v, %v, want verbosity 3", level, err)
+	}
+	if level, err := ParseLogLevel("error"); err != nil || level != zapcore.ErrorLevel {
+		t.Errorf("ParseLogLevel(error) = %v, %v, want the error level", level, err)
+	}
+}
+
+func TestStoreApply(t *testing.T) {
+	store := NewStore()
+	var applied []Config
+	store.Subscribe(func(config Config) { applied = append(applied, config) })
+
+	config := Defaults()
+	config.ClusterResyncInterval = time.Minute
+	config.FeatureGates = map[features.Feature]bool{"AlphaThing": true}
+	if changed := store.Apply(config); !reflect.DeepEqual(changed, []string{KeyClusterResyncInterval, KeyFeatureGates}) {
+		t.Errorf("Apply() = %v, want the changed keys", changed)
+	}
+	if store.Current().ClusterResyncInterval != time.Minute || len(applied) != 1 {
+		t.Errorf("current = %+v, %d notifications, want the applied configuration", store.Current(), len(applied))
+	}
+
+	// Applying the same configuration again notifies nobody
+	if changed := store.Apply(config); len(changed) != 0 || len(applied) != 1 {
+		t.Errorf("Apply() = %v, %d notifications, want no change", changed, len(applied))
+	}
+
+	if changed := store.Apply(Defaults()); !reflect.DeepEqual(changed, []string{KeyClusterResyncInterval, KeyFeatureGates}) || len(applied) != 2 {
+		t.Errorf("Apply(Defaults()) = %v, want the settings reverted", changed)
+	}
+}
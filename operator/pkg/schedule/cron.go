@@ -0,0 +1,200 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLookback bounds how far back Previous searches for a matching minute
+const maxLookback = 8 * 24 * time.Hour
+
+// Cron is a parsed five-field cron expression (minute hour day-of-month month day-of-week)
+type Cron struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	// anyDay is set when the day-of-month or the day-of-week field starts with *; otherwise a
+	// day matching either field matches, as in standard cron
+	anyDay bool
+}
+
+// ParseCron parses a standard five-field cron expression
+func ParseCron(expr string) (*Cron, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expr, len(fields))
+	}
+
+	minutes, err := parseField(fields[0], 0, 59)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	hours, err := parseField(fields[1], 0, 23)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	days, err := parseField(fields[2], 1, 31)
+	if err != nil {
+		return nil, fmt.Errorf("invalid day-of-month field: %w", err)
+	}
+	months, err := parseField(fields[3], 1, 12)
+	if err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+	weekdays, err := parseField(fields[4], 0, 7)
+	if err != nil {
+		return nil, fmt.Errorf("invalid day-of-week field: %w", err)
+	}
+	// Both 0 and 7 mean Sunday
+	if weekdays[7] {
+		weekdays[0] = true
+	}
+
+	return &Cron{
+		minutes:  minutes,
+		hours:    hours,
+		days:     days,
+		months:   months,
+		weekdays: weekdays,
+		anyDay:   strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseField parses a single cron field supporting *, lists, ranges and steps
+func parseField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			s, err := strconv.Atoi(part[idx+1:])
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			step = s
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = v, v
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value %q out of range [%d-%d]", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			values[v] = true
+		}
+	}
+	return values, nil
+}
+
+// Matches reports whether t falls on a minute selected by the expression
+func (c *Cron) Matches(t time.Time) bool {
+	return c.minutes[t.Minute()] &&
+		c.hours[t.Hour()] &&
+		c.months[int(t.Month())] &&
+		c.matchesDay(t)
+}
+
+// matchesDay reports whether the day of t is selected by the day-of-month and day-of-week
+// fields: both must match when either is *, otherwise one of them
+func (c *Cron) matchesDay(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	if c.anyDay {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Previous returns the most recent time at or before t matched by the expression
+func (c *Cron) Previous(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for earliest := t.Add(-maxLookback); !t.Before(earliest); t = t.Add(-time.Minute) {
+		if c.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Next returns the first time strictly after t matched by the expression
+func (c *Cron) Next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for latest := t.Add(maxLookback); !t.After(latest); t = t.Add(time.Minute) {
+		if c.Matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Window is a recurring stop/start window described by two cron expressions
+type Window struct {
+	Stop     *Cron
+	Start    *Cron
+	Location *time.Location
+}
+
+// NewWindow parses the stop and start expressions evaluated in the given IANA time zone
+func NewWindow(stopCron, startCron, timeZone string) (*Window, error) {
+	stop, err := ParseCron(stopCron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stop schedule: %w", err)
+	}
+	start, err := ParseCron(startCron)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start schedule: %w", err)
+	}
+
+	location := time.UTC
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", timeZone, err)
+		}
+	}
+
+	return &Window{Stop: stop, Start: start, Location: location}, nil
+}
+
+// Active reports whether now falls inside the window, i.e. the last stop fired after the last start
+func (w *Window) Active(now time.Time) bool {
+	now = now.In(w.Location)
+	lastStop, ok := w.Stop.Previous(now)
+	if !ok {
+		return false
+	}
+	lastStart, ok := w.Start.Previous(now)
+	if !ok {
+		return true
+	}
+	return lastStop.After(lastStart)
+}
+
+// NextTransition returns the next time the window opens or closes after now
+func (w *Window) NextTransition(now time.Time) (time.Time, bool) {
+	now = now.In(w.Location)
+	if w.Active(now) {
+		return w.Start.Next(now)
+	}
+	return w.Stop.Next(now)
+}
@@ -0,0 +1,84 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronRejectsInvalidExpressions(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestCronMatchesDays(t *testing.T) {
+	tests := []struct {
+		expr  string
+		day   time.Time
+		match bool
+	}{
+		// With both day fields restricted either one selects the day
+		{"0 0 1 * 1", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true},  // Thursday the 1st
+		{"0 0 1 * 1", time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), true},  // Monday the 5th
+		{"0 0 1 * 1", time.Date(2024, 2, 6, 0, 0, 0, 0, time.UTC), false}, // Tuesday the 6th
+		// A * in either field leaves the other one to decide
+		{"0 0 * * 1", time.Date(2024, 2, 6, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 1 * *", time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 */2 * 1", time.Date(2024, 2, 6, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 */2 * 1", time.Date(2024, 2, 5, 0, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cron.Matches(tt.day); got != tt.match {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.day.Format("Mon Jan 2"), got, tt.match)
+		}
+	}
+}
+
+func TestWindowActive(t *testing.T) {
+	// Stop at 20:00 and start at 07:00 on weekdays
+	window, err := NewWindow("0 20 * * 1-5", "0 7 * * 1-5", "UTC")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		active bool
+	}{
+		{"weekday daytime", time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC), false},
+		{"weekday night", time.Date(2024, 1, 10, 23, 0, 0, 0, time.UTC), true},
+		{"exactly at stop", time.Date(2024, 1, 10, 20, 0, 0, 0, time.UTC), true},
+		{"exactly at start", time.Date(2024, 1, 11, 7, 0, 0, 0, time.UTC), false},
+		{"weekend stays stopped", time.Date(2024, 1, 13, 12, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		if got := window.Active(tt.now); got != tt.active {
+			t.Errorf("%s: Active() = %v, want %v", tt.name, got, tt.active)
+		}
+	}
+}
+
+func TestWindowNextTransition(t *testing.T) {
+	window, err := NewWindow("0 20 * * *", "0 7 * * *", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	now := time.Date(2024, 1, 10, 23, 30, 0, 0, time.UTC)
+	next, ok := window.NextTransition(now)
+	if !ok {
+		t.Fatal("expected a next transition")
+	}
+	if want := time.Date(2024, 1, 11, 7, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("NextTransition() = %v, want %v", next, want)
+	}
+}
@@ -0,0 +1,340 @@
+package scheduling
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+)
+
+// Labels identifying the classes created for a cluster
+const (
+	managedByLabel        = "k8s-playgrounds.io/scheduling"
+	clusterLabel          = "k8s-playgrounds.io/cluster"
+	clusterNamespaceLabel = "k8s-playgrounds.io/cluster-namespace"
+)
+
+// MaxPriority is the highest value of a user-defined PriorityClass; higher ones are reserved
+// for the system classes
+const MaxPriority = 1000000000
+
+// reservedPrefix starts the names of the PriorityClasses of the system
+const reservedPrefix = "system-"
+
+// Reconciler creates the PriorityClasses and RuntimeClasses declared in the cluster spec and
+// removes the ones that are no longer declared
+type Reconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewReconciler creates a new scheduling class reconciler
+func NewReconciler(client client.Client, scheme *runtime.Scheme) *Reconciler {
+	return &Reconciler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Reconcile applies the declared classes and prunes stale ones. Classes that exist without
+// belonging to the cluster, such as the ones of another cluster, are left alone and reported.
+func (r *Reconciler) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	if err := Validate(cluster).ToAggregate(); err != nil {
+		return fmt.Errorf("invalid scheduling classes: %w", err)
+	}
+	priorityClasses, runtimeClasses, err := Render(cluster)
+	if err != nil {
+		return err
+	}
+
+	rendered := make(map[string]bool, len(priorityClasses))
+	for i := range priorityClasses {
+		rendered[priorityClasses[i].Name] = true
+		if err := r.applyPriorityClass(ctx, cluster, &priorityClasses[i]); err != nil {
+			return err
+		}
+	}
+	if err := r.prunePriorityClasses(ctx, cluster, rendered); err != nil {
+		return err
+	}
+
+	rendered = make(map[string]bool, len(runtimeClasses))
+	for i := range runtimeClasses {
+		rendered[runtimeClasses[i].Name] = true
+		if err := r.applyRuntimeClass(ctx, cluster, &runtimeClasses[i]); err != nil {
+			return err
+		}
+	}
+	return r.pruneRuntimeClasses(ctx, cluster, rendered)
+}
+
+// Cleanup removes every class created for the cluster. Pods that run with them keep running;
+// only new pods naming them are rejected.
+func (r *Reconciler) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	if err := r.prunePriorityClasses(ctx, cluster, nil); err != nil {
+		return err
+	}
+	return r.pruneRuntimeClasses(ctx, cluster, nil)
+}
+
+// applyPriorityClass applies a PriorityClass. Its value and preemption policy are immutable,
+// so a class that changes them is replaced; running pods keep their priority.
+func (r *Reconciler) applyPriorityClass(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, desired *schedulingv1.PriorityClass) error {
+	log := logr.FromContextOrDiscard(ctx)
+
+	existing := &schedulingv1.PriorityClass{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: desired.Name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get priority class %s: %w", desired.Name, err)
+	}
+	replace := false
+	if err == nil {
+		if !ownedBy(existing.Labels, cluster) {
+			return fmt.Errorf("priority class %s exists and is not managed by the cluster", desired.Name)
+		}
+		replace = existing.Value != desired.Value || !equality.Semantic.DeepEqual(existing.PreemptionPolicy, desired.PreemptionPolicy)
+	}
+	if replace {
+		if err := r.client.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to replace priority class %s: %w", desired.Name, err)
+		}
+	}
+
+	if err := apply.Apply(ctx, r.client, desired); err != nil {
+		return fmt.Errorf("failed to apply priority class %s: %w", desired.Name, err)
+	}
+	if replace {
+		log.Info("replaced priority class", "name", desired.Name, "value", desired.Value)
+	}
+	return nil
+}
+
+// applyRuntimeClass applies a RuntimeClass. Its handler is immutable, so a class that changes
+// it is replaced.
+func (r *Reconciler) applyRuntimeClass(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, desired *nodev1.RuntimeClass) error {
+	log := logr.FromContextOrDiscard(ctx)
+
+	existing := &nodev1.RuntimeClass{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: desired.Name}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("failed to get runtime class %s: %w", desired.Name, err)
+	}
+	replace := false
+	if err == nil {
+		if !ownedBy(existing.Labels, cluster) {
+			return fmt.Errorf("runtime class %s exists and is not managed by the cluster", desired.Name)
+		}
+		replace = existing.Handler != desired.Handler
+	}
+	if replace {
+		if err := r.client.Delete(ctx, existing); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to replace runtime class %s: %w", desired.Name, err)
+		}
+	}
+
+	if err := apply.Apply(ctx, r.client, desired); err != nil {
+		return fmt.Errorf("failed to apply runtime class %s: %w", desired.Name, err)
+	}
+	if replace {
+		log.Info("replaced runtime class", "name", desired.Name, "handler", desired.Handler)
+	}
+	return nil
+}
+
+// prunePriorityClasses deletes the PriorityClasses of the cluster that are not rendered.
+// Cluster-scoped classes cannot carry an owner reference, so they are tracked by label.
+func (r *Reconciler) prunePriorityClasses(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, rendered map[string]bool) error {
+	log := logr.FromContextOrDiscard(ctx)
+
+	classes := &schedulingv1.PriorityClassList{}
+	if err := r.client.List(ctx, classes, client.MatchingLabels(labels(cluster))); err != nil {
+		return fmt.Errorf("failed to list priority classes: %w", err)
+	}
+	for i := range classes.Items {
+		class := &classes.Items[i]
+		if rendered[class.Name] {
+			continue
+		}
+		if err := r.client.Delete(ctx, class); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete priority class %s: %w", class.Name, err)
+		}
+		log.Info("deleted priority class", "name", class.Name)
+	}
+	return nil
+}
+
+// pruneRuntimeClasses deletes the RuntimeClasses of the cluster that are not rendered
+func (r *Reconciler) pruneRuntimeClasses(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, rendered map[string]bool) error {
+	log := logr.FromContextOrDiscard(ctx)
+
+	classes := &nodev1.RuntimeClassList{}
+	if err := r.client.List(ctx, classes, client.MatchingLabels(labels(cluster))); err != nil {
+		return fmt.Errorf("failed to list runtime classes: %w", err)
+	}
+	for i := range classes.Items {
+		class := &classes.Items[i]
+		if rendered[class.Name] {
+			continue
+		}
+		if err := r.client.Delete(ctx, class); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete runtime class %s: %w", class.Name, err)
+		}
+		log.Info("deleted runtime class", "name", class.Name)
+	}
+	return nil
+}
+
+// Render builds the PriorityClasses and RuntimeClasses declared in the cluster spec, sorted
+// by name
+func Render(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) ([]schedulingv1.PriorityClass, []nodev1.RuntimeClass, error) {
+	var priorityClasses []schedulingv1.PriorityClass
+	for _, spec := range cluster.Spec.PriorityClasses {
+		class := schedulingv1.PriorityClass{
+			ObjectMeta:    metav1.ObjectMeta{Name: spec.Name, Labels: labels(cluster)},
+			Value:         spec.Value,
+			GlobalDefault: spec.GlobalDefault,
+			Description:   spec.Description,
+		}
+		policy := corev1.PreemptLowerPriority
+		if spec.PreemptionPolicy != "" {
+			policy = corev1.PreemptionPolicy(spec.PreemptionPolicy)
+		}
+		class.PreemptionPolicy = &policy
+		priorityClasses = append(priorityClasses, class)
+	}
+	sort.Slice(priorityClasses, func(i, j int) bool { return priorityClasses[i].Name < priorityClasses[j].Name })
+
+	var runtimeClasses []nodev1.RuntimeClass
+	for _, spec := range cluster.Spec.RuntimeClasses {
+		class := nodev1.RuntimeClass{
+			ObjectMeta: metav1.ObjectMeta{Name: spec.Name, Labels: labels(cluster)},
+			Handler:    spec.Handler,
+		}
+		if len(spec.Overhead) > 0 {
+			podFixed := make(corev1.ResourceList, len(spec.Overhead))
+			for name, value := range spec.Overhead {
+				quantity, err := resource.ParseQuantity(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("runtime class %s: invalid overhead %s: %w", spec.Name, name, err)
+				}
+				podFixed[corev1.ResourceName(name)] = quantity
+			}
+			class.Overhead = &nodev1.Overhead{PodFixed: podFixed}
+		}
+		if len(spec.NodeSelector) > 0 || len(spec.Tolerations) > 0 {
+			class.Scheduling = &nodev1.Scheduling{NodeSelector: spec.NodeSelector}
+			for _, t := range spec.Tolerations {
+				class.Scheduling.Tolerations = append(class.Scheduling.Tolerations, corev1.Toleration{
+					Key:               t.Key,
+					Operator:          corev1.TolerationOperator(t.Operator),
+					Value:             t.Value,
+					Effect:            corev1.TaintEffect(t.Effect),
+					TolerationSeconds: t.TolerationSeconds,
+				})
+			}
+		}
+		runtimeClasses = append(runtimeClasses, class)
+	}
+	sort.Slice(runtimeClasses, func(i, j int) bool { return runtimeClasses[i].Name < runtimeClasses[j].Name })
+
+	return priorityClasses, runtimeClasses, nil
+}
+
+// Validate checks the declared classes: unique valid names, priorities below the system
+// classes and at most one global default
+func Validate(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) field.ErrorList {
+	var errs field.ErrorList
+
+	path := field.NewPath("spec", "priorityClasses")
+	seen := map[string]bool{}
+	globalDefault := ""
+	for i, spec := range cluster.Spec.PriorityClasses {
+		classPath := path.Index(i)
+		for _, msg := range validation.IsDNS1123Subdomain(spec.Name) {
+			errs = append(errs, field.Invalid(classPath.Child("name"), spec.Name, msg))
+		}
+		if strings.HasPrefix(spec.Name, reservedPrefix) {
+			errs = append(errs, field.Invalid(classPath.Child("name"), spec.Name, "the system- prefix is reserved for the system priority classes"))
+		}
+		if seen[spec.Name] {
+			errs = append(errs, field.Duplicate(classPath.Child("name"), spec.Name))
+		}
+		seen[spec.Name] = true
+		if spec.Value > MaxPriority {
+			errs = append(errs, field.Invalid(classPath.Child("value"), spec.Value, fmt.Sprintf("must be at most %d", MaxPriority)))
+		}
+		switch corev1.PreemptionPolicy(spec.PreemptionPolicy) {
+		case "", corev1.PreemptLowerPriority, corev1.PreemptNever:
+		default:
+			errs = append(errs, field.NotSupported(classPath.Child("preemptionPolicy"), spec.PreemptionPolicy, []string{string(corev1.PreemptLowerPriority), string(corev1.PreemptNever)}))
+		}
+		if spec.GlobalDefault {
+			if globalDefault != "" {
+				errs = append(errs, field.Invalid(classPath.Child("globalDefault"), true, fmt.Sprintf("%s is already the global default", globalDefault)))
+			}
+			globalDefault = spec.Name
+		}
+	}
+
+	path = field.NewPath("spec", "runtimeClasses")
+	seen = map[string]bool{}
+	for i, spec := range cluster.Spec.RuntimeClasses {
+		classPath := path.Index(i)
+		for _, msg := range validation.IsDNS1123Subdomain(spec.Name) {
+			errs = append(errs, field.Invalid(classPath.Child("name"), spec.Name, msg))
+		}
+		if seen[spec.Name] {
+			errs = append(errs, field.Duplicate(classPath.Child("name"), spec.Name))
+		}
+		seen[spec.Name] = true
+		if spec.Handler == "" {
+			errs = append(errs, field.Required(classPath.Child("handler"), "the runtime handler configured on the nodes"))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(spec.Handler) {
+				errs = append(errs, field.Invalid(classPath.Child("handler"), spec.Handler, msg))
+			}
+		}
+		for name, value := range spec.Overhead {
+			if _, err := resource.ParseQuantity(value); err != nil {
+				errs = append(errs, field.Invalid(classPath.Child("overhead").Key(name), value, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// ownedBy reports whether a class carries the labels of the cluster
+func ownedBy(classLabels map[string]string, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) bool {
+	for key, value := range labels(cluster) {
+		if classLabels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// labels returns the labels identifying classes created for the cluster
+func labels(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) map[string]string {
+	return map[string]string{
+		managedByLabel:        "true",
+		clusterLabel:          cluster.Name,
+		clusterNamespaceLabel: cluster.Namespace,
+	}
+}
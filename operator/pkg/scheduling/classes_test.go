@@ -0,0 +1,140 @@
+package scheduling
+
+import (
+	"context"
+	"testing"
+
+	nodev1 "k8s.io/api/node/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply/applytest"
+)
+
+func newCluster(name string) *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "lab"},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			PriorityClasses: []k8splaygroundsv1alpha1.PriorityClassSpec{
+				{Name: "critical", Value: 1000},
+				{Name: "batch", Value: 10, PreemptionPolicy: "Never"},
+			},
+			RuntimeClasses: []k8splaygroundsv1alpha1.RuntimeClassSpec{{
+				Name:         "gvisor",
+				Handler:      "runsc",
+				Overhead:     map[string]string{"memory": "64Mi"},
+				NodeSelector: map[string]string{"runtime": "gvisor"},
+			}},
+		},
+	}
+}
+
+func newClient(t *testing.T, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithInterceptorFuncs(applytest.Funcs()).Build()
+}
+
+func TestReconcileCreatesReplacesAndPrunesClasses(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t)
+	r := NewReconciler(c, c.Scheme())
+	cluster := newCluster("scheduling")
+
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	runtimeClass := &nodev1.RuntimeClass{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "gvisor"}, runtimeClass); err != nil {
+		t.Fatalf("runtime class not created: %v", err)
+	}
+	if runtimeClass.Handler != "runsc" || runtimeClass.Overhead.PodFixed.Memory().String() != "64Mi" || runtimeClass.Scheduling.NodeSelector["runtime"] != "gvisor" {
+		t.Errorf("runtime class = %+v, want the declared handler, overhead and node selector", runtimeClass)
+	}
+
+	// The value of a priority class is immutable, so it is replaced
+	cluster.Spec.PriorityClasses = cluster.Spec.PriorityClasses[:1]
+	cluster.Spec.PriorityClasses[0].Value = 2000
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	classes := &schedulingv1.PriorityClassList{}
+	if err := c.List(ctx, classes); err != nil {
+		t.Fatal(err)
+	}
+	if len(classes.Items) != 1 || classes.Items[0].Name != "critical" || classes.Items[0].Value != 2000 {
+		t.Errorf("priority classes = %+v, want only critical with value 2000", classes.Items)
+	}
+
+	if err := r.Cleanup(ctx, cluster); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+	runtimeClasses := &nodev1.RuntimeClassList{}
+	if err := c.List(ctx, runtimeClasses); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.List(ctx, classes); err != nil {
+		t.Fatal(err)
+	}
+	if len(runtimeClasses.Items) != 0 || len(classes.Items) != 0 {
+		t.Errorf("classes left after cleanup: %d runtime, %d priority", len(runtimeClasses.Items), len(classes.Items))
+	}
+}
+
+func TestReconcileLeavesClassesOfOthersAlone(t *testing.T) {
+	ctx := context.Background()
+	// Another cluster created the class first
+	other := &schedulingv1.PriorityClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "critical", Labels: labels(newCluster("other"))},
+		Value:      5,
+	}
+	c := newClient(t, other)
+	r := NewReconciler(c, c.Scheme())
+
+	if err := r.Reconcile(ctx, newCluster("scheduling")); err == nil {
+		t.Fatal("Reconcile() succeeded, want the class of the other cluster reported")
+	}
+	existing := &schedulingv1.PriorityClass{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "critical"}, existing); err != nil || existing.Value != 5 {
+		t.Errorf("class of the other cluster = %+v, %v, want it unchanged", existing, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(*k8splaygroundsv1alpha1.K8sPlaygroundsCluster){
+		"reserved name": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) { c.Spec.PriorityClasses[0].Name = "system-lab" },
+		"value too high": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+			c.Spec.PriorityClasses[0].Value = MaxPriority + 1
+		},
+		"duplicate name": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) { c.Spec.PriorityClasses[1].Name = "critical" },
+		"unknown preemption": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+			c.Spec.PriorityClasses[0].PreemptionPolicy = "Always"
+		},
+		"two global defaults": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+			c.Spec.PriorityClasses[0].GlobalDefault = true
+			c.Spec.PriorityClasses[1].GlobalDefault = true
+		},
+		"missing handler": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) { c.Spec.RuntimeClasses[0].Handler = "" },
+		"invalid overhead": func(c *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) {
+			c.Spec.RuntimeClasses[0].Overhead["cpu"] = "lots"
+		},
+	}
+	if errs := Validate(newCluster("scheduling")); len(errs) != 0 {
+		t.Fatalf("Validate() = %v for a valid cluster", errs)
+	}
+	for name, mutate := range tests {
+		cluster := newCluster("scheduling")
+		mutate(cluster)
+		if errs := Validate(cluster); len(errs) == 0 {
+			t.Errorf("%s: Validate() accepted the cluster", name)
+		}
+	}
+}
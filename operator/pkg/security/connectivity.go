@@ -0,0 +1,111 @@
+package security
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// ConnectivityRefreshInterval is how often the connectivity of a domain is read from the
+// Controller; connection policies changed in the Aviatrix UI trigger no reconcile
+const ConnectivityRefreshInterval = 5 * time.Minute
+
+// ConditionConnectivityRefreshed reports whether the connectivity in status is current
+const ConditionConnectivityRefreshed = "ConnectivityRefreshed"
+
+// Reasons two domains are connected or isolated
+const (
+	ConnectivitySelf             = "Self"
+	ConnectivityConnectionPolicy = "ConnectionPolicy"
+	ConnectivityIsolated         = "Isolated"
+)
+
+// ListDomainConnectionPolicies lists the connection policies between network domains
+func (m *Manager) ListDomainConnectionPolicies(ctx context.Context) ([]aviatrix.DomainConnection, error) {
+	return m.client.ListDomainConnectionPolicies(ctx)
+}
+
+// CreateDomainConnectionPolicy connects two network domains
+func (m *Manager) CreateDomainConnectionPolicy(ctx context.Context, domain1, domain2 string) error {
+	return m.client.CreateDomainConnectionPolicy(ctx, domain1, domain2)
+}
+
+// DeleteDomainConnectionPolicy removes the connection policy between two network domains
+func (m *Manager) DeleteDomainConnectionPolicy(ctx context.Context, domain1, domain2 string) error {
+	return m.client.DeleteDomainConnectionPolicy(ctx, domain1, domain2)
+}
+
+// PlanDomainConnections returns the domains a domain must be connected to and disconnected
+// from, sorted. desired are the domains of its spec and applied those its resource connected
+// before. A connection that is no longer desired is kept while the other domain's resource
+// asks for it (claimed), as connection policies join domains both ways.
+func PlanDomainConnections(domain string, desired, applied []string, claimed map[string]bool, policies []aviatrix.DomainConnection) (connect, disconnect []string) {
+	existing := map[string]bool{}
+	for _, policy := range policies {
+		switch domain {
+		case policy.Domain1:
+			existing[policy.Domain2] = true
+		case policy.Domain2:
+			existing[policy.Domain1] = true
+		}
+	}
+	wanted := map[string]bool{}
+	for _, name := range desired {
+		if name == "" || name == domain || wanted[name] {
+			continue
+		}
+		wanted[name] = true
+		if !existing[name] {
+			connect = append(connect, name)
+		}
+	}
+	for _, name := range applied {
+		if !wanted[name] && existing[name] && !claimed[name] {
+			disconnect = append(disconnect, name)
+			// A domain listed twice is disconnected once
+			existing[name] = false
+		}
+	}
+	sort.Strings(connect)
+	sort.Strings(disconnect)
+	return connect, disconnect
+}
+
+// Connectivity returns the row of a domain in the connectivity matrix of the known domains and
+// those named by a connection policy, sorted by domain. Connection policies join two domains
+// both ways and are not transitive: a domain connected to two others does not connect them.
+func Connectivity(domain string, known []string, policies []aviatrix.DomainConnection) []aviatrixv1alpha1.DomainConnectivity {
+	domains := map[string]bool{domain: true}
+	for _, name := range known {
+		domains[name] = true
+	}
+	connected := map[string]bool{}
+	for _, policy := range policies {
+		domains[policy.Domain1] = true
+		domains[policy.Domain2] = true
+		switch domain {
+		case policy.Domain1:
+			connected[policy.Domain2] = true
+		case policy.Domain2:
+			connected[policy.Domain1] = true
+		}
+	}
+	delete(domains, "")
+
+	row := make([]aviatrixv1alpha1.DomainConnectivity, 0, len(domains))
+	for name := range domains {
+		entry := aviatrixv1alpha1.DomainConnectivity{Domain: name, Reason: ConnectivityIsolated}
+		switch {
+		case name == domain:
+			entry.Connected, entry.Reason = true, ConnectivitySelf
+		case connected[name]:
+			entry.Connected, entry.Reason = true, ConnectivityConnectionPolicy
+		}
+		row = append(row, entry)
+	}
+	sort.Slice(row, func(i, j int) bool { return row[i].Domain < row[j].Domain })
+	return row
+}
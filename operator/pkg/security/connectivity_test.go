@@ -0,0 +1,58 @@
+package security
+
+import (
+	"reflect"
+	"testing"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+func TestConnectivity(t *testing.T) {
+	policies := []aviatrix.DomainConnection{
+		{Domain1: "prod", Domain2: "shared"},
+		{Domain1: "dev", Domain2: "shared"},
+	}
+	got := Connectivity("prod", []string{"dev", "prod", "pci"}, policies)
+	want := []aviatrixv1alpha1.DomainConnectivity{
+		// Connection policies are not transitive: prod reaches shared, but not dev through it
+		{Domain: "dev", Connected: false, Reason: ConnectivityIsolated},
+		{Domain: "pci", Connected: false, Reason: ConnectivityIsolated},
+		{Domain: "prod", Connected: true, Reason: ConnectivitySelf},
+		{Domain: "shared", Connected: true, Reason: ConnectivityConnectionPolicy},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Connectivity() = %+v, want %+v", got, want)
+	}
+
+	// Policies apply both ways
+	if row := Connectivity("shared", nil, policies); len(row) != 3 || !row[0].Connected || !row[1].Connected {
+		t.Errorf("Connectivity(shared) = %+v, want dev and prod connected", row)
+	}
+}
+
+func TestPlanDomainConnections(t *testing.T) {
+	policies := []aviatrix.DomainConnection{
+		{Domain1: "shared", Domain2: "prod"},
+		{Domain1: "prod", Domain2: "dev"},
+		{Domain1: "prod", Domain2: "pci"},
+	}
+	// shared is connected either way round, dev is dropped, pci is dropped but still claimed by
+	// the pci domain, and the domain itself is ignored
+	connect, disconnect := PlanDomainConnections("prod",
+		[]string{"shared", "egress", "prod", "egress"},
+		[]string{"shared", "dev", "pci"},
+		map[string]bool{"pci": true},
+		policies)
+	if !reflect.DeepEqual(connect, []string{"egress"}) {
+		t.Errorf("connect = %v, want [egress]", connect)
+	}
+	if !reflect.DeepEqual(disconnect, []string{"dev"}) {
+		t.Errorf("disconnect = %v, want [dev]", disconnect)
+	}
+
+	// Connections made outside the operator are left alone
+	if _, disconnect := PlanDomainConnections("prod", nil, nil, nil, policies); len(disconnect) != 0 {
+		t.Errorf("disconnect = %v, want none without applied connections", disconnect)
+	}
+}
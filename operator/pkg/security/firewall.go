@@ -0,0 +1,31 @@
+package security
+
+import (
+	"strings"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// FirewallPolicyFromSpec returns the Controller firewall policy of an AviatrixFirewall spec.
+// The rules keep their order, which decides the rule a connection matches. Actions and
+// protocols are sent in lower case, as the Controller reports them.
+func FirewallPolicyFromSpec(spec *aviatrixv1alpha1.AviatrixFirewallSpec) aviatrix.FirewallPolicy {
+	rules := make([]aviatrix.FirewallRule, 0, len(spec.Rules))
+	for _, rule := range spec.Rules {
+		rules = append(rules, aviatrix.FirewallRule{
+			Protocol:    strings.ToLower(rule.Protocol),
+			SrcIP:       rule.SrcIP,
+			DstIP:       rule.DstIP,
+			Port:        rule.Port,
+			Action:      strings.ToLower(rule.Action),
+			LogEnabled:  rule.LogEnabled,
+			Description: rule.Description,
+		})
+	}
+	return aviatrix.FirewallPolicy{
+		GwName:     spec.GwName,
+		BasePolicy: spec.BasePolicy,
+		Rules:      rules,
+	}
+}
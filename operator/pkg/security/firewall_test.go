@@ -0,0 +1,28 @@
+package security
+
+import (
+	"testing"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func TestFirewallPolicyFromSpec(t *testing.T) {
+	policy := FirewallPolicyFromSpec(&aviatrixv1alpha1.AviatrixFirewallSpec{
+		GwName:     "spoke-1",
+		BasePolicy: "deny-all",
+		Rules: []aviatrixv1alpha1.FirewallRule{
+			{Protocol: "TCP", SrcIP: "10.0.0.0/16", DstIP: "10.1.0.0/16", Port: "443", Action: "Allow", LogEnabled: true},
+			{Protocol: "all", SrcIP: "0.0.0.0/0", DstIP: "10.1.0.0/16", Port: "0:65535", Action: "deny"},
+		},
+	})
+	if policy.GwName != "spoke-1" || policy.BasePolicy != "deny-all" || len(policy.Rules) != 2 {
+		t.Fatalf("FirewallPolicyFromSpec() = %+v, want the gateway, base policy and both rules", policy)
+	}
+	first := policy.Rules[0]
+	if first.Protocol != "tcp" || first.Action != "allow" || !first.LogEnabled {
+		t.Errorf("first rule = %+v, want lower case protocol and action", first)
+	}
+	if policy.Rules[1].Action != "deny" {
+		t.Errorf("rules = %+v, want the order of the spec", policy.Rules)
+	}
+}
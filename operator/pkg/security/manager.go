@@ -2,6 +2,7 @@ package security
 
 import (
 	"aviatrix-operator/pkg/aviatrix"
+	"context"
 	"fmt"
 )
 
@@ -17,100 +18,87 @@ func NewManager(client *aviatrix.Client) *Manager {
 	}
 }
 
-// CreateFirewall creates firewall rules
-func (m *Manager) CreateFirewall(gwName, basePolicy string, rules []map[string]interface{}) error {
-	return m.client.CreateFirewall(gwName, basePolicy, rules)
+// CreateFirewall sets the firewall policy of a gateway
+func (m *Manager) CreateFirewall(ctx context.Context, policy aviatrix.FirewallPolicy) error {
+	return m.client.CreateFirewall(ctx, policy)
 }
 
 // DeleteFirewall deletes firewall rules
-func (m *Manager) DeleteFirewall(gwName string) error {
-	return m.client.DeleteFirewall(gwName)
+func (m *Manager) DeleteFirewall(ctx context.Context, gwName string) error {
+	return m.client.DeleteFirewall(ctx, gwName)
 }
 
-// GetFirewall retrieves firewall rules
-func (m *Manager) GetFirewall(gwName string) (map[string]interface{}, error) {
-	return m.client.GetFirewall(gwName)
+// GetFirewall retrieves the firewall policy of a gateway
+func (m *Manager) GetFirewall(ctx context.Context, gwName string) (*aviatrix.FirewallPolicy, error) {
+	return m.client.GetFirewall(ctx, gwName)
 }
 
 // CreateSegmentationSecurityDomain creates a segmentation security domain
-func (m *Manager) CreateSegmentationSecurityDomain(name, domainType string) error {
-	// Implementation for creating segmentation security domain
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("create segmentation security domain not implemented")
+func (m *Manager) CreateSegmentationSecurityDomain(ctx context.Context, name, domainType string) error {
+	return m.client.CreateNetworkDomain(ctx, aviatrix.NetworkDomain{Name: name, Type: domainType})
 }
 
 // DeleteSegmentationSecurityDomain deletes a segmentation security domain
-func (m *Manager) DeleteSegmentationSecurityDomain(name string) error {
-	// Implementation for deleting segmentation security domain
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("delete segmentation security domain not implemented")
+func (m *Manager) DeleteSegmentationSecurityDomain(ctx context.Context, name string) error {
+	return m.client.DeleteNetworkDomain(ctx, name)
 }
 
-// GetSegmentationSecurityDomain retrieves segmentation security domain information
-func (m *Manager) GetSegmentationSecurityDomain(name string) (map[string]interface{}, error) {
-	// Implementation for getting segmentation security domain
-	// This would typically involve calling the Aviatrix API
-	return nil, fmt.Errorf("get segmentation security domain not implemented")
+// GetSegmentationSecurityDomain retrieves a segmentation security domain
+func (m *Manager) GetSegmentationSecurityDomain(ctx context.Context, name string) (*aviatrix.NetworkDomain, error) {
+	return m.client.GetNetworkDomain(ctx, name)
 }
 
-// CreateMicrosegPolicy creates a microsegmentation policy
-func (m *Manager) CreateMicrosegPolicy(name, description, source, destination, action, port, protocol string) error {
-	// Implementation for creating microsegmentation policy
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("create microsegmentation policy not implemented")
+// CreateMicrosegPolicy creates a microsegmentation policy and returns its UUID
+func (m *Manager) CreateMicrosegPolicy(ctx context.Context, policy aviatrix.MicrosegPolicy) (string, error) {
+	return m.client.CreateMicrosegPolicy(ctx, policy)
+}
+
+// UpdateMicrosegPolicy replaces a microsegmentation policy, identified by its UUID
+func (m *Manager) UpdateMicrosegPolicy(ctx context.Context, policy aviatrix.MicrosegPolicy) error {
+	return m.client.UpdateMicrosegPolicy(ctx, policy)
 }
 
 // DeleteMicrosegPolicy deletes a microsegmentation policy
-func (m *Manager) DeleteMicrosegPolicy(name string) error {
-	// Implementation for deleting microsegmentation policy
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("delete microsegmentation policy not implemented")
+func (m *Manager) DeleteMicrosegPolicy(ctx context.Context, uuid string) error {
+	return m.client.DeleteMicrosegPolicy(ctx, uuid)
 }
 
-// GetMicrosegPolicy retrieves microsegmentation policy information
-func (m *Manager) GetMicrosegPolicy(name string) (map[string]interface{}, error) {
-	// Implementation for getting microsegmentation policy
-	// This would typically involve calling the Aviatrix API
-	return nil, fmt.Errorf("get microsegmentation policy not implemented")
+// GetMicrosegPolicy retrieves a microsegmentation policy
+func (m *Manager) GetMicrosegPolicy(ctx context.Context, uuid string) (*aviatrix.MicrosegPolicy, error) {
+	return m.client.GetMicrosegPolicy(ctx, uuid)
 }
 
 // CreateNetworkDomain creates a network domain for segmentation
-func (m *Manager) CreateNetworkDomain(name, domainType, accountName, region, cidr, cloudType string) error {
-	// Implementation for creating network domain
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("create network domain not implemented")
+func (m *Manager) CreateNetworkDomain(ctx context.Context, domain aviatrix.NetworkDomain) error {
+	return m.client.CreateNetworkDomain(ctx, domain)
 }
 
 // DeleteNetworkDomain deletes a network domain
-func (m *Manager) DeleteNetworkDomain(name string) error {
-	// Implementation for deleting network domain
-	// This would typically involve calling the Aviatrix API
-	return fmt.Errorf("delete network domain not implemented")
+func (m *Manager) DeleteNetworkDomain(ctx context.Context, name string) error {
+	return m.client.DeleteNetworkDomain(ctx, name)
 }
 
-// GetNetworkDomain retrieves network domain information
-func (m *Manager) GetNetworkDomain(name string) (map[string]interface{}, error) {
-	// Implementation for getting network domain
-	// This would typically involve calling the Aviatrix API
-	return nil, fmt.Errorf("get network domain not implemented")
+// GetNetworkDomain retrieves a network domain
+func (m *Manager) GetNetworkDomain(ctx context.Context, name string) (*aviatrix.NetworkDomain, error) {
+	return m.client.GetNetworkDomain(ctx, name)
 }
 
 // CreateSecurityGroup creates a security group
-func (m *Manager) CreateSecurityGroup(name, description string, rules []map[string]interface{}) error {
+func (m *Manager) CreateSecurityGroup(ctx context.Context, name, description string, rules []map[string]interface{}) error {
 	// Implementation for creating security group
 	// This would typically involve calling the Aviatrix API
 	return fmt.Errorf("create security group not implemented")
 }
 
 // DeleteSecurityGroup deletes a security group
-func (m *Manager) DeleteSecurityGroup(name string) error {
+func (m *Manager) DeleteSecurityGroup(ctx context.Context, name string) error {
 	// Implementation for deleting security group
 	// This would typically involve calling the Aviatrix API
 	return fmt.Errorf("delete security group not implemented")
 }
 
 // GetSecurityGroup retrieves security group information
-func (m *Manager) GetSecurityGroup(name string) (map[string]interface{}, error) {
+func (m *Manager) GetSecurityGroup(ctx context.Context, name string) (map[string]interface{}, error) {
 	// Implementation for getting security group
 	// This would typically involve calling the Aviatrix API
 	return nil, fmt.Errorf("get security group not implemented")
@@ -0,0 +1,59 @@
+package security
+
+import (
+	"strings"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// MicrosegPolicyFromSpec returns the Controller policy of an AviatrixMicrosegPolicy spec.
+// Actions and protocols are sent in lower case, as the Controller reports them.
+func MicrosegPolicyFromSpec(spec *aviatrixv1alpha1.AviatrixMicrosegPolicySpec) aviatrix.MicrosegPolicy {
+	return aviatrix.MicrosegPolicy{
+		Name:        spec.Name,
+		Description: spec.Description,
+		Source:      microsegEndpoint(spec.Source),
+		Destination: microsegEndpoint(spec.Destination),
+		Action:      strings.ToLower(spec.Action),
+		Port:        strings.TrimSpace(spec.Port),
+		Protocol:    strings.ToLower(spec.Protocol),
+		Logging:     spec.LogEnabled,
+	}
+}
+
+// MicrosegPolicyChanged reports whether the policy on the Controller differs from the desired
+// one. The UUID is not compared, and names of actions, protocols and endpoint types are
+// compared regardless of case.
+func MicrosegPolicyChanged(desired, actual aviatrix.MicrosegPolicy) bool {
+	return desired.Name != actual.Name ||
+		desired.Description != actual.Description ||
+		!sameEndpoint(desired.Source, actual.Source) ||
+		!sameEndpoint(desired.Destination, actual.Destination) ||
+		!strings.EqualFold(desired.Action, actual.Action) ||
+		normalizePort(desired.Port) != normalizePort(actual.Port) ||
+		!strings.EqualFold(desired.Protocol, actual.Protocol) ||
+		desired.Logging != actual.Logging
+}
+
+func microsegEndpoint(endpoint aviatrixv1alpha1.PolicyEndpoint) aviatrix.MicrosegEndpoint {
+	return aviatrix.MicrosegEndpoint{
+		Type:   strings.ToLower(endpoint.Type),
+		Value:  endpoint.Value,
+		Region: endpoint.Region,
+		VpcID:  endpoint.VpcID,
+	}
+}
+
+func sameEndpoint(a, b aviatrix.MicrosegEndpoint) bool {
+	return strings.EqualFold(a.Type, b.Type) && a.Value == b.Value && a.Region == b.Region && a.VpcID == b.VpcID
+}
+
+// normalizePort treats an empty port and "all" alike, as both match every port
+func normalizePort(port string) string {
+	port = strings.TrimSpace(port)
+	if strings.EqualFold(port, "all") {
+		return ""
+	}
+	return port
+}
@@ -0,0 +1,43 @@
+package security
+
+import (
+	"testing"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+func TestMicrosegPolicyChanged(t *testing.T) {
+	desired := MicrosegPolicyFromSpec(&aviatrixv1alpha1.AviatrixMicrosegPolicySpec{
+		Name:        "web-to-db",
+		Source:      aviatrixv1alpha1.PolicyEndpoint{Type: "Tag", Value: "web"},
+		Destination: aviatrixv1alpha1.PolicyEndpoint{Type: "subnet", Value: "10.1.0.0/24"},
+		Action:      "ALLOW",
+		Port:        "all",
+		Protocol:    "TCP",
+	})
+	if desired.Action != "allow" || desired.Protocol != "tcp" || desired.Source.Type != "tag" {
+		t.Errorf("MicrosegPolicyFromSpec() = %+v, want lower case action, protocol and type", desired)
+	}
+
+	actual := desired
+	actual.UUID = "3f2c"
+	actual.Port = ""
+	actual.Action = "Allow"
+	if MicrosegPolicyChanged(desired, actual) {
+		t.Error("MicrosegPolicyChanged() = true for a policy differing only in UUID, case and all ports")
+	}
+
+	for name, change := range map[string]func(p *aviatrix.MicrosegPolicy){
+		"port":        func(p *aviatrix.MicrosegPolicy) { p.Port = "5432" },
+		"action":      func(p *aviatrix.MicrosegPolicy) { p.Action = "deny" },
+		"destination": func(p *aviatrix.MicrosegPolicy) { p.Destination.Value = "10.2.0.0/24" },
+		"logging":     func(p *aviatrix.MicrosegPolicy) { p.Logging = true },
+	} {
+		changed := actual
+		change(&changed)
+		if !MicrosegPolicyChanged(desired, changed) {
+			t.Errorf("MicrosegPolicyChanged() = false with a different %s", name)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+// Verdicts of a connection
+const (
+	VerdictAllow = "allow"
+	VerdictDeny  = "deny"
+)
+
+// Base policies of a firewall
+const (
+	BasePolicyAllowAll = "allow-all"
+	BasePolicyDenyAll  = "deny-all"
+)
+
+// Connection is a connection a policy test asks about
+type Connection struct {
+	Src      string
+	Dst      string
+	Port     int32
+	Protocol string
+}
+
+// Verdict is the action applied to a connection and why
+type Verdict struct {
+	Action string
+	Reason string
+}
+
+// EvaluateFirewall applies the rules of a firewall to a connection the way the gateway does:
+// the first matching rule decides, and the base policy applies when none matches
+func EvaluateFirewall(spec *aviatrixv1alpha1.AviatrixFirewallSpec, conn Connection) (Verdict, error) {
+	src, dst := net.ParseIP(conn.Src), net.ParseIP(conn.Dst)
+	if src == nil || dst == nil {
+		return Verdict{}, fmt.Errorf("source and destination must be IP addresses")
+	}
+	for i, rule := range spec.Rules {
+		srcMatch, err := matchAddress(rule.SrcIP, src)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("rule %d: %w", i, err)
+		}
+		dstMatch, err := matchAddress(rule.DstIP, dst)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("rule %d: %w", i, err)
+		}
+		portMatch, err := matchPort(rule.Port, conn)
+		if err != nil {
+			return Verdict{}, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if srcMatch && dstMatch && portMatch && matchProtocol(rule.Protocol, conn.Protocol) {
+			return Verdict{
+				Action: strings.ToLower(rule.Action),
+				Reason: fmt.Sprintf("rule %d (%s) matched", i, RuleKey(rule.Protocol, rule.SrcIP, rule.DstIP, rule.Port, rule.Action)),
+			}, nil
+		}
+	}
+
+	switch strings.ToLower(spec.BasePolicy) {
+	case BasePolicyAllowAll:
+		return Verdict{Action: VerdictAllow, Reason: "no rule matched, base policy allow-all"}, nil
+	case BasePolicyDenyAll:
+		return Verdict{Action: VerdictDeny, Reason: "no rule matched, base policy deny-all"}, nil
+	default:
+		return Verdict{}, fmt.Errorf("unknown base policy %q", spec.BasePolicy)
+	}
+}
+
+// EvaluateMicroseg applies a microsegmentation policy to a connection. Subnet endpoints match
+// addresses within them, tag and instance endpoints match their value. Connections the policy
+// does not match are denied, as microsegmentation denies traffic no policy allows.
+func EvaluateMicroseg(spec *aviatrixv1alpha1.AviatrixMicrosegPolicySpec, conn Connection) (Verdict, error) {
+	srcMatch, err := matchEndpoint(spec.Source, conn.Src)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("source: %w", err)
+	}
+	dstMatch, err := matchEndpoint(spec.Destination, conn.Dst)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("destination: %w", err)
+	}
+	portMatch, err := matchPort(spec.Port, conn)
+	if err != nil {
+		return Verdict{}, err
+	}
+	if srcMatch && dstMatch && portMatch && matchProtocol(spec.Protocol, conn.Protocol) {
+		return Verdict{Action: strings.ToLower(spec.Action), Reason: fmt.Sprintf("policy %s matched", spec.Name)}, nil
+	}
+	return Verdict{Action: VerdictDeny, Reason: fmt.Sprintf("policy %s did not match, denied by default", spec.Name)}, nil
+}
+
+// RunTests evaluates every test and reports its result and the number of failed tests. Invalid
+// tests count as failed.
+func RunTests(tests []aviatrixv1alpha1.PolicyTest, evaluate func(Connection) (Verdict, error)) ([]aviatrixv1alpha1.PolicyTestResult, int) {
+	results := make([]aviatrixv1alpha1.PolicyTestResult, 0, len(tests))
+	failed := 0
+	for _, test := range tests {
+		result := aviatrixv1alpha1.PolicyTestResult{Name: test.Name}
+		var verdict Verdict
+		err := validateTest(test)
+		if err == nil {
+			verdict, err = evaluate(Connection{Src: test.Src, Dst: test.Dst, Port: test.Port, Protocol: strings.ToLower(test.Protocol)})
+		}
+		switch {
+		case err != nil:
+			result.Message = fmt.Sprintf("invalid test: %v", err)
+		case verdict.Action == strings.ToLower(test.Expect):
+			result.Passed, result.Verdict, result.Message = true, verdict.Action, verdict.Reason
+		default:
+			result.Verdict = verdict.Action
+			result.Message = fmt.Sprintf("expected %s, got %s: %s", strings.ToLower(test.Expect), verdict.Action, verdict.Reason)
+		}
+		if !result.Passed {
+			failed++
+		}
+		results = append(results, result)
+	}
+	return results, failed
+}
+
+// validateTest checks the protocol, port and expectation of a test
+func validateTest(test aviatrixv1alpha1.PolicyTest) error {
+	switch strings.ToLower(test.Protocol) {
+	case "tcp", "udp":
+		if test.Port < 1 || test.Port > 65535 {
+			return fmt.Errorf("port %d is out of range", test.Port)
+		}
+	case "icmp":
+	default:
+		return fmt.Errorf("protocol must be tcp, udp or icmp, got %q", test.Protocol)
+	}
+	if expect := strings.ToLower(test.Expect); expect != VerdictAllow && expect != VerdictDeny {
+		return fmt.Errorf("expect must be allow or deny, got %q", test.Expect)
+	}
+	return nil
+}
+
+// FailedTests names the failed tests of a report
+func FailedTests(results []aviatrixv1alpha1.PolicyTestResult) []string {
+	var names []string
+	for _, result := range results {
+		if !result.Passed {
+			names = append(names, result.Name)
+		}
+	}
+	return names
+}
+
+// matchAddress reports whether a rule address, a CIDR, an IP or empty for any, contains ip
+func matchAddress(address string, ip net.IP) (bool, error) {
+	address = strings.TrimSpace(address)
+	if address == "" || strings.EqualFold(address, "any") {
+		return true, nil
+	}
+	if strings.Contains(address, "/") {
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			return false, fmt.Errorf("invalid address %q", address)
+		}
+		return network.Contains(ip), nil
+	}
+	ruleIP := net.ParseIP(address)
+	if ruleIP == nil {
+		return false, fmt.Errorf("invalid address %q", address)
+	}
+	return ruleIP.Equal(ip), nil
+}
+
+// matchEndpoint reports whether a microsegmentation endpoint matches a test source or
+// destination
+func matchEndpoint(endpoint aviatrixv1alpha1.PolicyEndpoint, value string) (bool, error) {
+	if strings.EqualFold(endpoint.Type, "subnet") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return value == endpoint.Value, nil
+		}
+		return matchAddress(endpoint.Value, ip)
+	}
+	return value == endpoint.Value, nil
+}
+
+// matchPort reports whether a rule port, a port, a low:high range, a comma separated list of
+// those or empty for all, contains the destination port. icmp has no ports.
+func matchPort(ports string, conn Connection) (bool, error) {
+	ports = strings.TrimSpace(ports)
+	if ports == "" || strings.EqualFold(ports, "all") || strings.EqualFold(conn.Protocol, "icmp") {
+		return true, nil
+	}
+	for _, part := range strings.Split(ports, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(part), ":")
+		if !isRange {
+			high = low
+		}
+		from, err := strconv.Atoi(low)
+		if err != nil {
+			return false, fmt.Errorf("invalid port %q", ports)
+		}
+		to, err := strconv.Atoi(high)
+		if err != nil {
+			return false, fmt.Errorf("invalid port %q", ports)
+		}
+		if int(conn.Port) >= from && int(conn.Port) <= to {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchProtocol reports whether a rule protocol matches a connection protocol
+func matchProtocol(ruleProtocol, protocol string) bool {
+	return ruleProtocol == "" || strings.EqualFold(ruleProtocol, "all") || strings.EqualFold(ruleProtocol, protocol)
+}
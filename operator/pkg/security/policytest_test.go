@@ -0,0 +1,87 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func TestEvaluateFirewall(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixFirewallSpec{
+		BasePolicy: "deny-all",
+		Rules: []aviatrixv1alpha1.FirewallRule{
+			{Protocol: "tcp", SrcIP: "10.0.0.0/8", DstIP: "10.1.0.0/16", Port: "22", Action: "deny"},
+			{Protocol: "tcp", SrcIP: "10.0.0.0/8", DstIP: "10.1.0.0/16", Port: "443,8000:8080", Action: "allow"},
+			{Protocol: "all", SrcIP: "10.2.0.5", DstIP: "10.1.0.0/16", Port: "0:65535", Action: "allow"},
+		},
+	}
+	tests := []struct {
+		conn Connection
+		want string
+		rule string
+	}{
+		{Connection{Src: "10.3.0.1", Dst: "10.1.2.3", Port: 22, Protocol: "tcp"}, VerdictDeny, "rule 0"},
+		{Connection{Src: "10.3.0.1", Dst: "10.1.2.3", Port: 8080, Protocol: "tcp"}, VerdictAllow, "rule 1"},
+		{Connection{Src: "10.3.0.1", Dst: "10.1.2.3", Port: 8081, Protocol: "tcp"}, VerdictDeny, "base policy"},
+		{Connection{Src: "10.2.0.5", Dst: "10.1.2.3", Protocol: "icmp"}, VerdictAllow, "rule 2"},
+		{Connection{Src: "192.168.0.1", Dst: "10.1.2.3", Port: 443, Protocol: "tcp"}, VerdictDeny, "base policy"},
+	}
+	for _, tt := range tests {
+		verdict, err := EvaluateFirewall(spec, tt.conn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verdict.Action != tt.want || !strings.Contains(verdict.Reason, tt.rule) {
+			t.Errorf("EvaluateFirewall(%+v) = %+v, want %s by %s", tt.conn, verdict, tt.want, tt.rule)
+		}
+	}
+
+	if _, err := EvaluateFirewall(spec, Connection{Src: "web", Dst: "10.1.2.3", Port: 443, Protocol: "tcp"}); err == nil {
+		t.Error("EvaluateFirewall() accepted a source that is not an IP")
+	}
+}
+
+func TestEvaluateMicroseg(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixMicrosegPolicySpec{
+		Name:        "web-to-db",
+		Source:      aviatrixv1alpha1.PolicyEndpoint{Type: "tag", Value: "web"},
+		Destination: aviatrixv1alpha1.PolicyEndpoint{Type: "subnet", Value: "10.5.0.0/24"},
+		Action:      "allow",
+		Port:        "5432",
+		Protocol:    "tcp",
+	}
+	verdict, err := EvaluateMicroseg(spec, Connection{Src: "web", Dst: "10.5.0.10", Port: 5432, Protocol: "tcp"})
+	if err != nil || verdict.Action != VerdictAllow {
+		t.Errorf("EvaluateMicroseg() = %+v, %v, want allow", verdict, err)
+	}
+	verdict, err = EvaluateMicroseg(spec, Connection{Src: "batch", Dst: "10.5.0.10", Port: 5432, Protocol: "tcp"})
+	if err != nil || verdict.Action != VerdictDeny {
+		t.Errorf("EvaluateMicroseg() = %+v, %v, want an unmatched connection denied", verdict, err)
+	}
+}
+
+func TestRunTests(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixFirewallSpec{
+		BasePolicy: "allow-all",
+		Rules:      []aviatrixv1alpha1.FirewallRule{{Protocol: "tcp", SrcIP: "0.0.0.0/0", DstIP: "10.1.0.0/16", Port: "22", Action: "deny"}},
+	}
+	tests := []aviatrixv1alpha1.PolicyTest{
+		{Name: "ssh blocked", Src: "10.0.0.1", Dst: "10.1.0.1", Port: 22, Protocol: "tcp", Expect: "deny"},
+		{Name: "https blocked", Src: "10.0.0.1", Dst: "10.1.0.1", Port: 443, Protocol: "TCP", Expect: "deny"},
+		{Name: "no port", Src: "10.0.0.1", Dst: "10.1.0.1", Protocol: "udp", Expect: "allow"},
+	}
+	results, failed := RunTests(tests, func(conn Connection) (Verdict, error) { return EvaluateFirewall(spec, conn) })
+	if failed != 2 || !results[0].Passed {
+		t.Fatalf("RunTests() = %+v, %d failed", results, failed)
+	}
+	if results[1].Verdict != VerdictAllow || !strings.Contains(results[1].Message, "expected deny, got allow") {
+		t.Errorf("result = %+v, want the unexpected verdict explained", results[1])
+	}
+	if results[2].Verdict != "" || !strings.Contains(results[2].Message, "invalid test") {
+		t.Errorf("result = %+v, want the test reported invalid", results[2])
+	}
+	if names := FailedTests(results); len(names) != 2 || names[0] != "https blocked" {
+		t.Errorf("FailedTests() = %v", names)
+	}
+}
@@ -0,0 +1,148 @@
+package security
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+// Defaults of the firewall usage analysis
+const (
+	DefaultUsageInterval   = time.Hour
+	DefaultUnusedAfterDays = 30
+)
+
+// RuleHits are the counters the gateway keeps for a firewall rule
+type RuleHits struct {
+	HitCount int64
+	// LastHit is zero when the Controller does not report it
+	LastHit time.Time
+}
+
+// UsageSettings returns the pull interval and unused threshold of a usage analysis spec,
+// applying the defaults
+func UsageSettings(spec *aviatrixv1alpha1.FirewallUsageAnalysisSpec) (time.Duration, time.Duration, error) {
+	interval := DefaultUsageInterval
+	if spec.Interval != "" {
+		var err error
+		if interval, err = time.ParseDuration(spec.Interval); err != nil || interval <= 0 {
+			return 0, 0, fmt.Errorf("invalid usage analysis interval %q", spec.Interval)
+		}
+	}
+	days := spec.UnusedAfterDays
+	if days <= 0 {
+		days = DefaultUnusedAfterDays
+	}
+	return interval, time.Duration(days) * 24 * time.Hour, nil
+}
+
+// RuleKey identifies a firewall rule by the fields the gateway matches on
+func RuleKey(protocol, srcIP, dstIP, port, action string) string {
+	return strings.ToLower(strings.Join([]string{protocol, srcIP, dstIP, port, action}, " "))
+}
+
+// ParseRuleHits indexes the hit counters returned by the Aviatrix Controller by RuleKey
+func ParseRuleHits(hits []map[string]interface{}) map[string]RuleHits {
+	parsed := make(map[string]RuleHits, len(hits))
+	for _, hit := range hits {
+		key := RuleKey(stringField(hit, "protocol"), stringField(hit, "s_ip"), stringField(hit, "d_ip"), stringField(hit, "port"), stringField(hit, "action"))
+		counters := RuleHits{HitCount: int64Field(hit, "hit_count")}
+		if seconds := int64Field(hit, "last_hit"); seconds > 0 {
+			counters.LastHit = time.Unix(seconds, 0).UTC()
+		}
+		// The same rule may be reported once per gateway instance, e.g. with HA
+		existing := parsed[key]
+		existing.HitCount += counters.HitCount
+		if counters.LastHit.After(existing.LastHit) {
+			existing.LastHit = counters.LastHit
+		}
+		parsed[key] = existing
+	}
+	return parsed
+}
+
+// AnalyzeUsage reports the usage of each rule and the number of rules not hit within
+// unusedAfter. previous is the report of the last analysis; rules keep their tracking start
+// and last hit across analyses, and a grown counter counts as a hit now when the Controller
+// does not report the time. A counter that went down was reset on the gateway.
+func AnalyzeUsage(rules []aviatrixv1alpha1.FirewallRule, hits map[string]RuleHits, previous []aviatrixv1alpha1.FirewallRuleUsage, now time.Time, unusedAfter time.Duration) ([]aviatrixv1alpha1.FirewallRuleUsage, int) {
+	before := make(map[string]aviatrixv1alpha1.FirewallRuleUsage, len(previous))
+	for _, usage := range previous {
+		before[usage.Rule] = usage
+	}
+
+	report := make([]aviatrixv1alpha1.FirewallRuleUsage, 0, len(rules))
+	unused := 0
+	for _, rule := range rules {
+		key := RuleKey(rule.Protocol, rule.SrcIP, rule.DstIP, rule.Port, rule.Action)
+		counters := hits[key]
+		usage := aviatrixv1alpha1.FirewallRuleUsage{
+			Rule:         key,
+			Description:  rule.Description,
+			HitCount:     counters.HitCount,
+			TrackedSince: metav1.NewTime(now),
+		}
+
+		last, seen := before[key]
+		if seen {
+			usage.TrackedSince = last.TrackedSince
+			usage.LastHit = last.LastHit
+		}
+		switch {
+		case !counters.LastHit.IsZero():
+			usage.LastHit = &metav1.Time{Time: counters.LastHit}
+		case counters.HitCount > 0 && (!seen || counters.HitCount != last.HitCount):
+			hitAt := metav1.NewTime(now)
+			usage.LastHit = &hitAt
+		}
+
+		reference := usage.TrackedSince.Time
+		if usage.LastHit != nil {
+			reference = usage.LastHit.Time
+		}
+		if now.Sub(reference) >= unusedAfter {
+			usage.Unused = true
+			unused++
+		}
+		report = append(report, usage)
+	}
+	return report, unused
+}
+
+// GetFirewallRuleHits retrieves the hit counters of the firewall rules on a gateway
+func (m *Manager) GetFirewallRuleHits(ctx context.Context, gwName string) (map[string]RuleHits, error) {
+	hits, err := m.client.GetFirewallRuleHits(ctx, gwName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRuleHits(hits), nil
+}
+
+func stringField(values map[string]interface{}, key string) string {
+	switch value := values[key].(type) {
+	case string:
+		return value
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+func int64Field(values map[string]interface{}, key string) int64 {
+	switch value := values[key].(type) {
+	case float64:
+		return int64(value)
+	case string:
+		number, _ := strconv.ParseInt(value, 10, 64)
+		return number
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,74 @@
+package security
+
+import (
+	"testing"
+	"time"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+var usageRules = []aviatrixv1alpha1.FirewallRule{
+	{Protocol: "tcp", SrcIP: "10.0.0.0/8", DstIP: "10.1.0.0/16", Port: "443", Action: "allow", Description: "https"},
+	{Protocol: "udp", SrcIP: "10.0.0.0/8", DstIP: "10.1.0.0/16", Port: "53", Action: "allow", Description: "dns"},
+	{Protocol: "tcp", SrcIP: "0.0.0.0/0", DstIP: "10.1.0.0/16", Port: "22", Action: "deny", Description: "ssh"},
+}
+
+func TestParseRuleHits(t *testing.T) {
+	hits := ParseRuleHits([]map[string]interface{}{
+		{"protocol": "TCP", "s_ip": "10.0.0.0/8", "d_ip": "10.1.0.0/16", "port": float64(443), "action": "allow", "hit_count": float64(120), "last_hit": float64(1700000000)},
+		{"protocol": "tcp", "s_ip": "10.0.0.0/8", "d_ip": "10.1.0.0/16", "port": "443", "action": "allow", "hit_count": "30", "last_hit": float64(1700000100)},
+	})
+
+	got := hits[RuleKey("tcp", "10.0.0.0/8", "10.1.0.0/16", "443", "allow")]
+	if got.HitCount != 150 || got.LastHit.Unix() != 1700000100 {
+		t.Errorf("hits = %+v, want the counters of both instances summed", got)
+	}
+}
+
+func TestAnalyzeUsage(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	unusedAfter := 30 * 24 * time.Hour
+	httpsKey := RuleKey("tcp", "10.0.0.0/8", "10.1.0.0/16", "443", "allow")
+	dnsKey := RuleKey("udp", "10.0.0.0/8", "10.1.0.0/16", "53", "allow")
+
+	// First analysis: nothing is unused yet, counters without a time count as a hit now
+	report, unused := AnalyzeUsage(usageRules, map[string]RuleHits{
+		httpsKey: {HitCount: 10},
+	}, nil, start, unusedAfter)
+	if unused != 0 {
+		t.Errorf("unused = %d on the first analysis", unused)
+	}
+	if report[0].LastHit == nil || !report[0].LastHit.Time.Equal(start) {
+		t.Errorf("https last hit = %v, want %v", report[0].LastHit, start)
+	}
+
+	// 40 days later the https counter grew, DNS reports a recent hit and ssh never matched
+	later := start.Add(40 * 24 * time.Hour)
+	report, unused = AnalyzeUsage(usageRules, map[string]RuleHits{
+		httpsKey: {HitCount: 25},
+		dnsKey:   {HitCount: 3, LastHit: later.Add(-time.Hour)},
+	}, report, later, unusedAfter)
+	if unused != 1 || !report[2].Unused || report[0].Unused || report[1].Unused {
+		t.Errorf("report = %+v, want only ssh unused", report)
+	}
+	if !report[2].TrackedSince.Time.Equal(start) {
+		t.Errorf("ssh tracked since %v, want the first analysis", report[2].TrackedSince)
+	}
+
+	// The counters were reset and https did not match again for 31 days
+	reset := later.Add(31 * 24 * time.Hour)
+	report, _ = AnalyzeUsage(usageRules, nil, report, reset, unusedAfter)
+	if !report[0].Unused || !report[0].LastHit.Time.Equal(later) {
+		t.Errorf("https usage = %+v, want unused with the last hit kept", report[0])
+	}
+}
+
+func TestUsageSettings(t *testing.T) {
+	interval, unusedAfter, err := UsageSettings(&aviatrixv1alpha1.FirewallUsageAnalysisSpec{})
+	if err != nil || interval != time.Hour || unusedAfter != 30*24*time.Hour {
+		t.Errorf("defaults = %v, %v, %v", interval, unusedAfter, err)
+	}
+	if _, _, err := UsageSettings(&aviatrixv1alpha1.FirewallUsageAnalysisSpec{Interval: "daily"}); err == nil {
+		t.Error("UsageSettings() accepted an invalid interval")
+	}
+}
@@ -0,0 +1,98 @@
+package security
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+// ruleProtocols and ruleActions are the protocols and actions a firewall rule may use
+var (
+	ruleProtocols = []string{"tcp", "udp", "icmp", "all"}
+	ruleActions   = []string{VerdictAllow, VerdictDeny}
+)
+
+// ValidateFirewall checks the base policy, rule addresses, ports, protocols and actions, and
+// the usage analysis settings of a firewall, so they are rejected on admission instead of by
+// the gateway
+func ValidateFirewall(spec *aviatrixv1alpha1.AviatrixFirewallSpec) field.ErrorList {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if spec.GwName == "" {
+		errs = append(errs, field.Required(path.Child("gwName"), ""))
+	}
+	switch strings.ToLower(spec.BasePolicy) {
+	case BasePolicyAllowAll, BasePolicyDenyAll:
+	default:
+		errs = append(errs, field.NotSupported(path.Child("basePolicy"), spec.BasePolicy, []string{BasePolicyAllowAll, BasePolicyDenyAll}))
+	}
+
+	for i, rule := range spec.Rules {
+		rulePath := path.Child("rules").Index(i)
+		if rule.Protocol != "" && !oneOf(rule.Protocol, ruleProtocols) {
+			errs = append(errs, field.NotSupported(rulePath.Child("protocol"), rule.Protocol, ruleProtocols))
+		}
+		if !oneOf(rule.Action, ruleActions) {
+			errs = append(errs, field.NotSupported(rulePath.Child("action"), rule.Action, ruleActions))
+		}
+		if _, err := matchAddress(rule.SrcIP, net.IPv4zero); err != nil {
+			errs = append(errs, field.Invalid(rulePath.Child("srcIp"), rule.SrcIP, "must be an IP address, a CIDR or empty for any"))
+		}
+		if _, err := matchAddress(rule.DstIP, net.IPv4zero); err != nil {
+			errs = append(errs, field.Invalid(rulePath.Child("dstIp"), rule.DstIP, "must be an IP address, a CIDR or empty for any"))
+		}
+		if err := validatePorts(rule.Port); err != nil {
+			errs = append(errs, field.Invalid(rulePath.Child("port"), rule.Port, err.Error()))
+		}
+	}
+
+	if spec.UsageAnalysis != nil {
+		if _, _, err := UsageSettings(spec.UsageAnalysis); err != nil {
+			errs = append(errs, field.Invalid(path.Child("usageAnalysis", "interval"), spec.UsageAnalysis.Interval, "must be a positive duration such as 1h"))
+		}
+	}
+	return errs
+}
+
+// validatePorts checks a rule port: a port, a low:high range, a comma separated list of those,
+// or empty or all for every port
+func validatePorts(ports string) error {
+	ports = strings.TrimSpace(ports)
+	if ports == "" || strings.EqualFold(ports, "all") {
+		return nil
+	}
+	for _, part := range strings.Split(ports, ",") {
+		low, high, isRange := strings.Cut(strings.TrimSpace(part), ":")
+		if !isRange {
+			high = low
+		}
+		from, err := strconv.Atoi(low)
+		if err != nil {
+			return fmt.Errorf("%q is not a port or port range", part)
+		}
+		to, err := strconv.Atoi(high)
+		if err != nil {
+			return fmt.Errorf("%q is not a port or port range", part)
+		}
+		if from < 0 || to > 65535 || from > to {
+			return fmt.Errorf("port range %q must lie within 0-65535 and start at its low end", part)
+		}
+	}
+	return nil
+}
+
+// oneOf reports whether value is one of values, ignoring case
+func oneOf(value string, values []string) bool {
+	for _, v := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+func TestValidateFirewall(t *testing.T) {
+	spec := &aviatrixv1alpha1.AviatrixFirewallSpec{
+		GwName:     "spoke-gw",
+		BasePolicy: "deny-all",
+		Rules: []aviatrixv1alpha1.FirewallRule{
+			{Protocol: "tcp", SrcIP: "10.0.0.0/8", DstIP: "10.1.0.5", Port: "443,8000:8080", Action: "allow"},
+			{Protocol: "all", Port: "0:65535", Action: "Deny"},
+		},
+		UsageAnalysis: &aviatrixv1alpha1.FirewallUsageAnalysisSpec{Interval: "30m"},
+	}
+	if errs := ValidateFirewall(spec); len(errs) > 0 {
+		t.Fatalf("ValidateFirewall() = %v, want no errors", errs)
+	}
+
+	spec.BasePolicy = "allow"
+	spec.Rules = append(spec.Rules, aviatrixv1alpha1.FirewallRule{Protocol: "gre", SrcIP: "10.0.0.0/33", DstIP: "10.1.0.5", Port: "80:22", Action: "drop"})
+	spec.UsageAnalysis.Interval = "daily"
+	errs := ValidateFirewall(spec)
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+	want := "spec.basePolicy,spec.rules[2].protocol,spec.rules[2].action,spec.rules[2].srcIp,spec.rules[2].port,spec.usageAnalysis.interval"
+	if got := strings.Join(fields, ","); got != want {
+		t.Errorf("ValidateFirewall() rejected %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,410 @@
+package selfcheck
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"aviatrix-operator/pkg/migration"
+)
+
+// Outcomes of a check
+const (
+	StatusPass = "PASS"
+	StatusFail = "FAIL"
+	StatusSkip = "SKIP"
+)
+
+// DefaultTimeout bounds every single check
+const DefaultTimeout = 10 * time.Second
+
+// Result is the outcome of one check
+type Result struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the outcome of a self-check run
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed returns the number of failed checks
+func (r *Report) Failed() int {
+	failed := 0
+	for _, result := range r.Results {
+		if result.Status == StatusFail {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write prints the report as a table followed by a summary line
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tMESSAGE")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Name, result.Status, result.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d checks, %d failed\n", len(r.Results), r.Failed())
+	return err
+}
+
+func (r *Report) add(name string, err error) {
+	if err != nil {
+		r.Results = append(r.Results, Result{Name: name, Status: StatusFail, Message: err.Error()})
+		return
+	}
+	r.Results = append(r.Results, Result{Name: name, Status: StatusPass})
+}
+
+func (r *Report) skip(name, reason string) {
+	r.Results = append(r.Results, Result{Name: name, Status: StatusSkip, Message: reason})
+}
+
+// Rule is a permission the operator needs
+type Rule struct {
+	Group    string
+	Resource string
+	Verbs    []string
+}
+
+// BaseRules are the permissions the operator needs besides those on its own resources
+var BaseRules = []Rule{
+	{Group: "", Resource: "events", Verbs: []string{"create", "patch"}},
+	{Group: "", Resource: "secrets", Verbs: []string{"get", "list", "watch"}},
+	{Group: "", Resource: "configmaps", Verbs: []string{"get", "list", "watch"}},
+	{Group: "coordination.k8s.io", Resource: "leases", Verbs: []string{"get", "create", "update"}},
+}
+
+// ResourceRules returns the permissions the operator needs on a custom resource of its group
+func ResourceRules(group string, resource migration.Resource) []Rule {
+	return []Rule{
+		{Group: group, Resource: resource.Plural, Verbs: []string{"get", "list", "watch", "create", "update", "patch", "delete"}},
+		{Group: group, Resource: resource.Plural + "/status", Verbs: []string{"get", "update", "patch"}},
+		{Group: group, Resource: resource.Plural + "/finalizers", Verbs: []string{"update"}},
+	}
+}
+
+// Resolver looks up host names
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations;mutatingwebhookconfigurations,verbs=get;list
+
+// Checker validates an installation of the operator against a live cluster: its CRDs, its
+// webhooks, its permissions, the Aviatrix Controller and the cluster DNS
+type Checker struct {
+	client client.Client
+	scheme *runtime.Scheme
+	group  string
+
+	// Aviatrix logs in to the Aviatrix Controller; nil skips the check
+	Aviatrix func(ctx context.Context) error
+	// ClusterDomain is the domain the DNS check resolves the API server Service in; empty
+	// skips the check
+	ClusterDomain string
+	// Resolver resolves the DNS check; nil uses the resolver of the process
+	Resolver Resolver
+	// Dial connects to webhook servers; nil uses a TLS dialer
+	Dial func(ctx context.Context, addr string, config *tls.Config) error
+	// Timeout bounds every single check; 0 uses DefaultTimeout
+	Timeout time.Duration
+}
+
+// NewChecker creates a new self-checker for the resources of an API group. The scheme decides
+// the kinds and versions the CRDs must provide.
+func NewChecker(client client.Client, scheme *runtime.Scheme, group string) *Checker {
+	return &Checker{
+		client: client,
+		scheme: scheme,
+		group:  group,
+	}
+}
+
+// Run performs every check and reports all of them, also when earlier checks fail
+func (c *Checker) Run(ctx context.Context) *Report {
+	report := &Report{}
+	resources := c.checkCRDs(ctx, report)
+	c.checkWebhooks(ctx, report)
+	c.checkRBAC(ctx, report, resources)
+	if c.Aviatrix == nil {
+		report.skip("aviatrix/login", "no Aviatrix Controller configured")
+	} else {
+		report.add("aviatrix/login", c.withTimeout(ctx, c.Aviatrix))
+	}
+	if c.ClusterDomain == "" {
+		report.skip("dns/kubernetes", "no cluster domain configured")
+	} else {
+		report.add("dns/kubernetes", c.withTimeout(ctx, c.checkDNS))
+	}
+	return report
+}
+
+func (c *Checker) withTimeout(ctx context.Context, check func(context.Context) error) error {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return check(ctx)
+}
+
+// checkCRDs reports whether every kind of the group in the scheme has a CRD that serves the
+// version of the operator and stores no version the operator cannot decode. It returns the
+// installed resources of the group.
+func (c *Checker) checkCRDs(ctx context.Context, report *Report) []migration.Resource {
+	var resources []migration.Resource
+	err := c.withTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		resources, err = migration.NewMigrator(c.client, c.scheme, c.group).Resources(ctx)
+		return err
+	})
+	if err != nil {
+		report.add("crd", err)
+		return nil
+	}
+
+	installed := make(map[string]migration.Resource, len(resources))
+	for _, resource := range resources {
+		installed[resource.Kind] = resource
+	}
+	for _, kind := range c.kinds() {
+		resource, ok := installed[kind.Kind]
+		if !ok {
+			report.add("crd/"+kind.Kind, fmt.Errorf("no CustomResourceDefinition installed"))
+			continue
+		}
+		if !contains(resource.ServedVersions, kind.Version) {
+			report.add("crd/"+kind.Kind, fmt.Errorf("%s serves %s, the operator needs %s", resource.CRD, strings.Join(resource.ServedVersions, ","), kind.Version))
+			continue
+		}
+		if incompatible := migration.IncompatibleVersions(c.scheme, c.group, resource); len(incompatible) > 0 {
+			report.add("crd/"+kind.Kind, fmt.Errorf("%s stores %s; run the storage migration", resource.CRD, strings.Join(incompatible, ",")))
+			continue
+		}
+		report.add("crd/"+kind.Kind, nil)
+	}
+	return resources
+}
+
+// resourceKind is a kind of the group and the version the operator reads it at
+type resourceKind struct {
+	Kind    string
+	Version string
+}
+
+// kinds returns the kinds of the group in the scheme, sorted by name. Kinds without a list
+// kind, like the option types of the group, are not resources.
+func (c *Checker) kinds() []resourceKind {
+	known := c.scheme.AllKnownTypes()
+	var kinds []resourceKind
+	for gvk := range known {
+		if gvk.Group != c.group || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		if _, ok := known[gvk.GroupVersion().WithKind(gvk.Kind+"List")]; !ok {
+			continue
+		}
+		kinds = append(kinds, resourceKind{Kind: gvk.Kind, Version: gvk.Version})
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Kind < kinds[j].Kind })
+	return kinds
+}
+
+// checkWebhooks reports whether the server of every webhook configuration intercepting the
+// group accepts TLS connections with its CA bundle
+func (c *Checker) checkWebhooks(ctx context.Context, report *Report) {
+	type webhook struct {
+		name   string
+		config admissionregistrationv1.WebhookClientConfig
+	}
+	var webhooks []webhook
+	err := c.withTimeout(ctx, func(ctx context.Context) error {
+		validating := &admissionregistrationv1.ValidatingWebhookConfigurationList{}
+		if err := c.client.List(ctx, validating); err != nil {
+			return fmt.Errorf("failed to list validating webhook configurations: %w", err)
+		}
+		for _, configuration := range validating.Items {
+			for _, hook := range configuration.Webhooks {
+				if c.intercepts(hook.Rules) {
+					webhooks = append(webhooks, webhook{configuration.Name + "/" + hook.Name, hook.ClientConfig})
+				}
+			}
+		}
+		mutating := &admissionregistrationv1.MutatingWebhookConfigurationList{}
+		if err := c.client.List(ctx, mutating); err != nil {
+			return fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+		}
+		for _, configuration := range mutating.Items {
+			for _, hook := range configuration.Webhooks {
+				if c.intercepts(hook.Rules) {
+					webhooks = append(webhooks, webhook{configuration.Name + "/" + hook.Name, hook.ClientConfig})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		report.add("webhook", err)
+		return
+	}
+	if len(webhooks) == 0 {
+		report.skip("webhook", "no webhook configuration intercepts "+c.group)
+		return
+	}
+	for _, hook := range webhooks {
+		hook := hook
+		report.add("webhook/"+hook.name, c.withTimeout(ctx, func(ctx context.Context) error {
+			return c.dialWebhook(ctx, hook.config)
+		}))
+	}
+}
+
+// intercepts reports whether webhook rules cover resources of the group
+func (c *Checker) intercepts(rules []admissionregistrationv1.RuleWithOperations) bool {
+	for _, rule := range rules {
+		if contains(rule.APIGroups, c.group) || contains(rule.APIGroups, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// dialWebhook opens a TLS connection to the server of a webhook, verified with its CA bundle
+func (c *Checker) dialWebhook(ctx context.Context, config admissionregistrationv1.WebhookClientConfig) error {
+	if len(config.CABundle) == 0 {
+		return fmt.Errorf("no caBundle configured")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(config.CABundle) {
+		return fmt.Errorf("caBundle holds no PEM certificates")
+	}
+
+	var host, port string
+	switch {
+	case config.Service != nil:
+		host = fmt.Sprintf("%s.%s.svc", config.Service.Name, config.Service.Namespace)
+		port = "443"
+		if config.Service.Port != nil {
+			port = strconv.Itoa(int(*config.Service.Port))
+		}
+	case config.URL != nil:
+		u, err := url.Parse(*config.URL)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		host, port = u.Hostname(), u.Port()
+		if port == "" {
+			port = "443"
+		}
+	default:
+		return fmt.Errorf("neither service nor url configured")
+	}
+
+	tlsConfig := &tls.Config{RootCAs: pool, ServerName: host, MinVersion: tls.VersionTLS12}
+	addr := net.JoinHostPort(host, port)
+	if c.Dial != nil {
+		return c.Dial(ctx, addr, tlsConfig)
+	}
+	dialer := &tls.Dialer{Config: tlsConfig}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to reach %s: %w", addr, err)
+	}
+	return conn.Close()
+}
+
+// checkRBAC asks the API server whether the operator may perform every verb it needs
+func (c *Checker) checkRBAC(ctx context.Context, report *Report, resources []migration.Resource) {
+	rules := append([]Rule{}, BaseRules...)
+	for _, resource := range resources {
+		rules = append(rules, ResourceRules(c.group, resource)...)
+	}
+	for _, rule := range rules {
+		rule := rule
+		name := "rbac/" + rule.Resource
+		if rule.Group != "" {
+			name = "rbac/" + rule.Resource + "." + rule.Group
+		}
+		report.add(name, c.withTimeout(ctx, func(ctx context.Context) error {
+			var denied []string
+			for _, verb := range rule.Verbs {
+				review := &authorizationv1.SelfSubjectAccessReview{
+					Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+						ResourceAttributes: resourceAttributes(rule, verb),
+					},
+				}
+				if err := c.client.Create(ctx, review); err != nil {
+					return fmt.Errorf("failed to review access: %w", err)
+				}
+				if !review.Status.Allowed {
+					denied = append(denied, verb)
+				}
+			}
+			if len(denied) > 0 {
+				return fmt.Errorf("denied %s", strings.Join(denied, ", "))
+			}
+			return nil
+		}))
+	}
+}
+
+// resourceAttributes splits the subresource off the resource of a rule
+func resourceAttributes(rule Rule, verb string) *authorizationv1.ResourceAttributes {
+	resource, subresource, _ := strings.Cut(rule.Resource, "/")
+	return &authorizationv1.ResourceAttributes{
+		Group:       rule.Group,
+		Resource:    resource,
+		Subresource: subresource,
+		Verb:        verb,
+	}
+}
+
+// checkDNS resolves the API server Service through the cluster DNS
+func (c *Checker) checkDNS(ctx context.Context) error {
+	resolver := c.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	host := "kubernetes.default.svc." + c.ClusterDomain
+	addrs, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return fmt.Errorf("unable to resolve %s: %w", host, err)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("%s resolved to no address", host)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
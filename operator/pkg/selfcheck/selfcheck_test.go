@@ -0,0 +1,200 @@
+package selfcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const testGroup = "widgets.example.com"
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+// testScheme knows the kinds Widget and Gadget of the test group at v1
+func testScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	version := schema.GroupVersion{Group: testGroup, Version: "v1"}
+	for _, kind := range []string{"Widget", "Gadget"} {
+		scheme.AddKnownTypeWithName(version.WithKind(kind), &corev1.ConfigMap{})
+		scheme.AddKnownTypeWithName(version.WithKind(kind+"List"), &corev1.ConfigMapList{})
+	}
+	return scheme
+}
+
+func newCRD(kind string, served, stored []string) *unstructured.Unstructured {
+	plural := strings.ToLower(kind) + "s"
+	var versions []interface{}
+	for _, version := range served {
+		versions = append(versions, map[string]interface{}{"name": version, "served": true, "storage": version == served[len(served)-1]})
+	}
+	var storedVersions []interface{}
+	for _, version := range stored {
+		storedVersions = append(storedVersions, version)
+	}
+	crd := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group":    testGroup,
+			"names":    map[string]interface{}{"kind": kind, "plural": plural},
+			"versions": versions,
+		},
+		"status": map[string]interface{}{"storedVersions": storedVersions},
+	}}
+	crd.SetGroupVersionKind(crdGVK)
+	crd.SetName(plural + "." + testGroup)
+	return crd
+}
+
+type resolver map[string][]string
+
+func (r resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func newTestClient(scheme *runtime.Scheme, allowed func(*authorizationv1.ResourceAttributes) bool, objs ...client.Object) client.Client {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for gvk := range scheme.AllKnownTypes() {
+		mapper.Add(gvk, meta.RESTScopeRoot)
+	}
+	mapper.Add(crdGVK, meta.RESTScopeRoot)
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithRESTMapper(mapper).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if review, ok := obj.(*authorizationv1.SelfSubjectAccessReview); ok {
+					review.Status.Allowed = allowed(review.Spec.ResourceAttributes)
+					return nil
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+}
+
+func resultsByName(report *Report) map[string]Result {
+	results := map[string]Result{}
+	for _, result := range report.Results {
+		results[result.Name] = result
+	}
+	return results
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	webhooks := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:         "vwidget.example.com",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{URL: &server.URL, CABundle: caBundle},
+			Rules: []admissionregistrationv1.RuleWithOperations{{
+				Rule: admissionregistrationv1.Rule{APIGroups: []string{testGroup}},
+			}},
+		}},
+	}
+	scheme := testScheme(t)
+	// Widget is installed and allowed everything; Gadget still stores a version the operator
+	// cannot decode and may not be deleted
+	c := newTestClient(scheme, func(attributes *authorizationv1.ResourceAttributes) bool {
+		return attributes.Resource != "gadgets" || attributes.Verb != "delete"
+	}, newCRD("Widget", []string{"v1"}, []string{"v1"}), newCRD("Gadget", []string{"v1"}, []string{"v1beta1", "v1"}), webhooks)
+
+	checker := NewChecker(c, scheme, testGroup)
+	checker.ClusterDomain = "cluster.local"
+	checker.Resolver = resolver{"kubernetes.default.svc.cluster.local": {"10.96.0.1"}}
+	checker.Aviatrix = func(ctx context.Context) error { return errors.New("login failed: invalid credentials") }
+	report := checker.Run(context.Background())
+
+	results := resultsByName(report)
+	want := map[string]string{
+		"crd/Widget":                          StatusPass,
+		"crd/Gadget":                          StatusFail,
+		"webhook/widgets/vwidget.example.com": StatusPass,
+		"rbac/widgets." + testGroup:           StatusPass,
+		"rbac/gadgets." + testGroup:           StatusFail,
+		"rbac/gadgets/status." + testGroup:    StatusPass,
+		"rbac/leases.coordination.k8s.io":     StatusPass,
+		"aviatrix/login":                      StatusFail,
+		"dns/kubernetes":                      StatusPass,
+	}
+	for name, status := range want {
+		if results[name].Status != status {
+			t.Errorf("%s = %+v, want %s", name, results[name], status)
+		}
+	}
+	if msg := results["crd/Gadget"].Message; !strings.Contains(msg, "v1beta1") {
+		t.Errorf("crd/Gadget message = %q, want the undecodable version", msg)
+	}
+	if msg := results["rbac/gadgets."+testGroup].Message; msg != "denied delete" {
+		t.Errorf("rbac/gadgets message = %q, want the denied verb", msg)
+	}
+	if report.Failed() != 3 {
+		t.Errorf("Failed() = %d, want 3", report.Failed())
+	}
+}
+
+func TestRunReportsMissingInstallation(t *testing.T) {
+	scheme := testScheme(t)
+	c := newTestClient(scheme, func(*authorizationv1.ResourceAttributes) bool { return true }, newCRD("Widget", []string{"v1alpha1"}, []string{"v1alpha1"}))
+	checker := NewChecker(c, scheme, testGroup)
+	checker.ClusterDomain = "cluster.local"
+	checker.Resolver = resolver{}
+	results := resultsByName(checker.Run(context.Background()))
+
+	if result := results["crd/Gadget"]; result.Status != StatusFail || !strings.Contains(result.Message, "no CustomResourceDefinition") {
+		t.Errorf("crd/Gadget = %+v, want the missing CRD reported", result)
+	}
+	if result := results["crd/Widget"]; result.Status != StatusFail || !strings.Contains(result.Message, "needs v1") {
+		t.Errorf("crd/Widget = %+v, want the missing version reported", result)
+	}
+	if results["webhook"].Status != StatusSkip || results["aviatrix/login"].Status != StatusSkip {
+		t.Errorf("results = %+v, want webhooks and Aviatrix skipped", results)
+	}
+	if results["dns/kubernetes"].Status != StatusFail {
+		t.Errorf("dns/kubernetes = %+v, want a failed lookup", results["dns/kubernetes"])
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	report := &Report{}
+	report.add("crd/Widget", nil)
+	report.add("dns/kubernetes", errors.New("unable to resolve kubernetes.default.svc.cluster.local"))
+	report.skip("aviatrix/login", "no Aviatrix Controller configured")
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"CHECK", "crd/Widget", "FAIL", "unable to resolve", "3 checks, 1 failed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -7,21 +7,30 @@ import (
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/apply"
+	"github.com/k8s-playgrounds/operator/pkg/dns"
+	"github.com/k8s-playgrounds/operator/pkg/endpoints"
+	"github.com/k8s-playgrounds/operator/pkg/helperpods"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
 )
 
 // Manager handles service discovery operations for headless services
 type Manager struct {
-	client client.Client
+	client     client.Client
+	helperPods helperpods.Config
 }
 
 // NewManager creates a new service discovery manager
-func NewManager(client client.Client) *Manager {
+func NewManager(client client.Client, helperPods helperpods.Config) *Manager {
 	return &Manager{
-		client: client,
+		client:     client,
+		helperPods: helperPods,
 	}
 }
 
@@ -32,7 +41,7 @@ func (m *Manager) ConfigureDNSDiscovery(ctx context.Context, headlessService *k8
 	// Create a ConfigMap with DNS discovery configuration
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-dns-discovery", headlessService.Name),
+			Name:      naming.Qualified(naming.DiscoveryConfig, headlessService.Name, "dns"),
 			Namespace: headlessService.Namespace,
 			Labels: map[string]string{
 				"app.kubernetes.io/name":     "headless-service-discovery",
@@ -58,8 +67,8 @@ func (m *Manager) ConfigureDNSDiscovery(ctx context.Context, headlessService *k8
 		},
 	}
 
-	if err := m.client.Create(ctx, configMap); err != nil {
-		return fmt.Errorf("failed to create DNS discovery ConfigMap: %w", err)
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return fmt.Errorf("failed to apply DNS discovery ConfigMap: %w", err)
 	}
 
 	// Create a service discovery pod
@@ -78,7 +87,7 @@ func (m *Manager) ConfigureAPIDiscovery(ctx context.Context, headlessService *k8
 	// Create a ConfigMap with API discovery configuration
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-api-discovery", headlessService.Name),
+			Name:      naming.Qualified(naming.DiscoveryConfig, headlessService.Name, "api"),
 			Namespace: headlessService.Namespace,
 			Labels: map[string]string{
 				"app.kubernetes.io/name":     "headless-service-discovery",
@@ -99,12 +108,12 @@ func (m *Manager) ConfigureAPIDiscovery(ctx context.Context, headlessService *k8
 			"service-name":     headlessService.Name,
 			"namespace":        headlessService.Namespace,
 			"refresh-interval": fmt.Sprintf("%d", headlessService.Spec.ServiceDiscovery.RefreshInterval),
-			"api-endpoint":     fmt.Sprintf("https://kubernetes.default.svc.cluster.local/api/v1/namespaces/%s/endpoints/%s", headlessService.Namespace, headlessService.Name),
+			"api-endpoint":     fmt.Sprintf("https://kubernetes.default.svc.%s/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices?labelSelector=%s%%3D%s", dns.ClusterDomain(headlessService), headlessService.Namespace, discoveryv1.LabelServiceName, headlessService.Name),
 		},
 	}
 
-	if err := m.client.Create(ctx, configMap); err != nil {
-		return fmt.Errorf("failed to create API discovery ConfigMap: %w", err)
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return fmt.Errorf("failed to apply API discovery ConfigMap: %w", err)
 	}
 
 	// Create a service discovery pod
@@ -123,7 +132,7 @@ func (m *Manager) ConfigureCustomDiscovery(ctx context.Context, headlessService
 	// Create a ConfigMap with custom discovery configuration
 	configMap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-custom-discovery", headlessService.Name),
+			Name:      naming.Qualified(naming.DiscoveryConfig, headlessService.Name, "custom"),
 			Namespace: headlessService.Namespace,
 			Labels: map[string]string{
 				"app.kubernetes.io/name":     "headless-service-discovery",
@@ -153,8 +162,8 @@ func (m *Manager) ConfigureCustomDiscovery(ctx context.Context, headlessService
 		configMap.Data[fmt.Sprintf("custom-%s", key)] = value
 	}
 
-	if err := m.client.Create(ctx, configMap); err != nil {
-		return fmt.Errorf("failed to create custom discovery ConfigMap: %w", err)
+	if err := apply.Apply(ctx, m.client, configMap); err != nil {
+		return fmt.Errorf("failed to apply custom discovery ConfigMap: %w", err)
 	}
 
 	// Create a service discovery pod
@@ -166,11 +175,16 @@ func (m *Manager) ConfigureCustomDiscovery(ctx context.Context, headlessService
 	return nil
 }
 
-// createServiceDiscoveryPod creates a pod for service discovery
+// createServiceDiscoveryPod creates a pod for service discovery unless it already runs
 func (m *Manager) createServiceDiscoveryPod(ctx context.Context, headlessService *k8splaygroundsv1alpha1.HeadlessService, discoveryType string) error {
+	helperPods, err := m.helperPods.For(headlessService)
+	if err != nil {
+		return err
+	}
+
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-discovery-%s", headlessService.Name, discoveryType),
+			Name:      naming.Qualified(naming.DiscoveryPod, headlessService.Name, discoveryType),
 			Namespace: headlessService.Namespace,
 			Labels: map[string]string{
 				"app.kubernetes.io/name":     "headless-service-discovery",
@@ -222,16 +236,6 @@ func (m *Manager) createServiceDiscoveryPod(ctx context.Context, headlessService
 							ReadOnly:  true,
 						},
 					},
-					Resources: corev1.ResourceRequirements{
-						Requests: corev1.ResourceList{
-							corev1.ResourceCPU:    resource.MustParse("10m"),
-							corev1.ResourceMemory: resource.MustParse("32Mi"),
-						},
-						Limits: corev1.ResourceList{
-							corev1.ResourceCPU:    resource.MustParse("100m"),
-							corev1.ResourceMemory: resource.MustParse("128Mi"),
-						},
-					},
 				},
 			},
 			Volumes: []corev1.Volume{
@@ -240,7 +244,7 @@ func (m *Manager) createServiceDiscoveryPod(ctx context.Context, headlessService
 					VolumeSource: corev1.VolumeSource{
 						ConfigMap: &corev1.ConfigMapVolumeSource{
 							LocalObjectReference: corev1.LocalObjectReference{
-								Name: fmt.Sprintf("%s-%s-discovery", headlessService.Name, discoveryType),
+								Name: naming.Qualified(naming.DiscoveryConfig, headlessService.Name, discoveryType),
 							},
 						},
 					},
@@ -249,8 +253,17 @@ func (m *Manager) createServiceDiscoveryPod(ctx context.Context, headlessService
 			RestartPolicy: corev1.RestartPolicyAlways,
 		},
 	}
+	helperPods.Apply(&pod.ObjectMeta, &pod.Spec)
 
-	return m.client.Create(ctx, pod)
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err := helperPods.Reserve(ctx, m.client, headlessService.Namespace); err != nil {
+		return err
+	}
+	return apply.Apply(ctx, m.client, pod)
 }
 
 // getDiscoveryScript returns the appropriate discovery script based on type
@@ -278,7 +291,7 @@ func (m *Manager) getDiscoveryScript(discoveryType string, headlessService *k8sp
 			
 			while true; do
 				echo "Performing API discovery..."
-				curl -k -H "Authorization: Bearer $(cat /var/run/secrets/kubernetes.io/serviceaccount/token)" $API_ENDPOINT | jq '.subsets[].addresses[].ip'
+				curl -k -H "Authorization: Bearer $(cat /var/run/secrets/kubernetes.io/serviceaccount/token)" "$API_ENDPOINT" | jq '.items[].endpoints[] | select(.conditions.ready != false) | .addresses[]'
 				sleep $REFRESH_INTERVAL
 			done
 		`
@@ -385,12 +398,16 @@ func (m *Manager) GetDiscoveredEndpoints(ctx context.Context, headlessService *k
 		return nil, err
 	}
 
+	// External backends are discovered like pods
+	external := endpoints.ExternalIPs(headlessService, false)
+
 	var endpoints []string
 	for _, pod := range pods.Items {
 		if pod.Status.PodIP != "" {
 			endpoints = append(endpoints, pod.Status.PodIP)
 		}
 	}
+	endpoints = append(endpoints, external...)
 
 	return endpoints, nil
 }
@@ -0,0 +1,75 @@
+package serviceports
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// DefaultProtocol is used for ports that do not set a protocol, as for Kubernetes Services
+const DefaultProtocol = corev1.ProtocolTCP
+
+// Default fills in what ports leave unset the way the API server does for Services: the
+// protocol defaults to TCP and the target port to the service port. Protocols are upper-cased
+// so that "tcp" maps to a valid corev1.Protocol.
+func Default(ports []k8splaygroundsv1alpha1.ServicePort) {
+	for i := range ports {
+		port := &ports[i]
+		port.Protocol = strings.ToUpper(port.Protocol)
+		if port.Protocol == "" {
+			port.Protocol = string(DefaultProtocol)
+		}
+		if isUnset(port.TargetPort) {
+			port.TargetPort = intstr.FromInt32(port.Port)
+		}
+	}
+}
+
+// ToCore converts ports to the ports of a Kubernetes Service of the given type, applying the
+// defaults without changing ports. Named target ports are kept so that they resolve against
+// the container ports of each pod. Node ports are only kept for NodePort and LoadBalancer
+// Services, since the API server rejects them on any other type.
+func ToCore(ports []k8splaygroundsv1alpha1.ServicePort, serviceType corev1.ServiceType) []corev1.ServicePort {
+	defaulted := append([]k8splaygroundsv1alpha1.ServicePort(nil), ports...)
+	Default(defaulted)
+
+	servicePorts := make([]corev1.ServicePort, len(defaulted))
+	for i, port := range defaulted {
+		servicePorts[i] = corev1.ServicePort{
+			Name:       port.Name,
+			Port:       port.Port,
+			TargetPort: port.TargetPort,
+			Protocol:   corev1.Protocol(port.Protocol),
+		}
+		if serviceType == corev1.ServiceTypeNodePort || serviceType == corev1.ServiceTypeLoadBalancer {
+			servicePorts[i].NodePort = port.NodePort
+		}
+	}
+	return servicePorts
+}
+
+// FromCore converts the ports of a Kubernetes Service to ServicePorts
+func FromCore(ports []corev1.ServicePort) []k8splaygroundsv1alpha1.ServicePort {
+	result := make([]k8splaygroundsv1alpha1.ServicePort, len(ports))
+	for i, p := range ports {
+		result[i] = k8splaygroundsv1alpha1.ServicePort{
+			Name:       p.Name,
+			Port:       p.Port,
+			TargetPort: p.TargetPort,
+			Protocol:   string(p.Protocol),
+			NodePort:   p.NodePort,
+		}
+	}
+	return result
+}
+
+// isUnset reports whether a target port was left empty
+func isUnset(targetPort intstr.IntOrString) bool {
+	if targetPort.Type == intstr.String {
+		return targetPort.StrVal == ""
+	}
+	return targetPort.IntVal == 0
+}
@@ -0,0 +1,68 @@
+package serviceports
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestDefault(t *testing.T) {
+	ports := []k8splaygroundsv1alpha1.ServicePort{
+		{Name: "http", Port: 80},
+		{Name: "dns", Port: 53, Protocol: "udp", TargetPort: intstr.FromInt32(5353)},
+		{Name: "grpc", Port: 9090, Protocol: "TCP", TargetPort: intstr.FromString("grpc")},
+		{Name: "empty-name", Port: 8443, TargetPort: intstr.FromString("")},
+	}
+	Default(ports)
+
+	want := []k8splaygroundsv1alpha1.ServicePort{
+		{Name: "http", Port: 80, Protocol: "TCP", TargetPort: intstr.FromInt32(80)},
+		{Name: "dns", Port: 53, Protocol: "UDP", TargetPort: intstr.FromInt32(5353)},
+		{Name: "grpc", Port: 9090, Protocol: "TCP", TargetPort: intstr.FromString("grpc")},
+		{Name: "empty-name", Port: 8443, Protocol: "TCP", TargetPort: intstr.FromInt32(8443)},
+	}
+	if !reflect.DeepEqual(ports, want) {
+		t.Errorf("Default() =\n%+v\nwant\n%+v", ports, want)
+	}
+}
+
+func TestToCore(t *testing.T) {
+	ports := []k8splaygroundsv1alpha1.ServicePort{
+		{Name: "http", Port: 80, NodePort: 30080},
+		{Name: "metrics", Port: 9100, TargetPort: intstr.FromString("metrics")},
+	}
+
+	headless := ToCore(ports, corev1.ServiceTypeClusterIP)
+	if headless[0].Protocol != corev1.ProtocolTCP || headless[0].TargetPort != intstr.FromInt32(80) {
+		t.Errorf("port = %+v, want TCP to target port 80", headless[0])
+	}
+	if headless[0].NodePort != 0 {
+		t.Errorf("node port = %d kept on a ClusterIP service", headless[0].NodePort)
+	}
+	if headless[1].TargetPort != intstr.FromString("metrics") {
+		t.Errorf("target port = %+v, want the named port kept", headless[1].TargetPort)
+	}
+	if ports[0].Protocol != "" {
+		t.Error("ToCore() changed the ports it converted")
+	}
+
+	for _, serviceType := range []corev1.ServiceType{corev1.ServiceTypeNodePort, corev1.ServiceTypeLoadBalancer} {
+		if got := ToCore(ports, serviceType); got[0].NodePort != 30080 {
+			t.Errorf("%s: node port = %d, want 30080", serviceType, got[0].NodePort)
+		}
+	}
+}
+
+func TestFromCoreRoundTrip(t *testing.T) {
+	ports := []corev1.ServicePort{
+		{Name: "http", Port: 80, TargetPort: intstr.FromString("web"), Protocol: corev1.ProtocolTCP, NodePort: 30080},
+		{Name: "sctp", Port: 3868, TargetPort: intstr.FromInt32(3868), Protocol: corev1.ProtocolSCTP},
+	}
+	if got := ToCore(FromCore(ports), corev1.ServiceTypeNodePort); !reflect.DeepEqual(got, ports) {
+		t.Errorf("round trip =\n%+v\nwant\n%+v", got, ports)
+	}
+}
@@ -0,0 +1,248 @@
+package terraform
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+)
+
+// Output formats supported by the exporter
+const (
+	// FormatBlocks emits Terraform 1.5+ import blocks
+	FormatBlocks = "blocks"
+	// FormatCommands emits terraform import CLI commands
+	FormatCommands = "commands"
+)
+
+// invalidNameChars matches characters that are not allowed in Terraform resource names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// ImportMapping maps a managed custom resource to a Terraform resource address and import ID
+type ImportMapping struct {
+	// Kind is the Kubernetes kind of the source custom resource
+	Kind string
+	// Namespace is the namespace of the source custom resource
+	Namespace string
+	// Name is the name of the source custom resource
+	Name string
+	// ResourceType is the Aviatrix Terraform provider resource type
+	ResourceType string
+	// ResourceName is the Terraform resource name derived from the custom resource
+	ResourceName string
+	// ImportID is the ID Terraform uses to import the resource
+	ImportID string
+}
+
+// Address returns the Terraform resource address of the mapping
+func (m ImportMapping) Address() string {
+	return fmt.Sprintf("%s.%s", m.ResourceType, m.ResourceName)
+}
+
+// Exporter collects import mappings for every Aviatrix resource managed by the operator
+type Exporter struct {
+	client client.Client
+}
+
+// NewExporter creates a new Terraform exporter
+func NewExporter(client client.Client) *Exporter {
+	return &Exporter{
+		client: client,
+	}
+}
+
+// Collect lists managed Aviatrix resources and returns their import mappings sorted by address.
+// An empty namespace collects resources from all namespaces.
+func (e *Exporter) Collect(ctx context.Context, namespace string) ([]ImportMapping, error) {
+	var mappings []ImportMapping
+	opts := []client.ListOption{client.InNamespace(namespace)}
+
+	accounts := &aviatrixv1alpha1.AviatrixAccountList{}
+	if err := e.client.List(ctx, accounts, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixAccounts: %w", err)
+	}
+	for _, a := range accounts.Items {
+		// Accounts are imported by their name once onboarded
+		if a.Status.AccountName == "" {
+			continue
+		}
+		mappings = append(mappings, newMapping("AviatrixAccount", a.Namespace, a.Name, "aviatrix_account", a.Status.AccountName))
+	}
+
+	gateways := &aviatrixv1alpha1.AviatrixGatewayList{}
+	if err := e.client.List(ctx, gateways, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixGateways: %w", err)
+	}
+	for _, gw := range gateways.Items {
+		mappings = append(mappings, newMapping("AviatrixGateway", gw.Namespace, gw.Name, "aviatrix_gateway", gw.Spec.GwName))
+	}
+
+	spokes := &aviatrixv1alpha1.AviatrixSpokeGatewayList{}
+	if err := e.client.List(ctx, spokes, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixSpokeGateways: %w", err)
+	}
+	for _, gw := range spokes.Items {
+		mappings = append(mappings, newMapping("AviatrixSpokeGateway", gw.Namespace, gw.Name, "aviatrix_spoke_gateway", gw.Spec.GwName))
+	}
+
+	transits := &aviatrixv1alpha1.AviatrixTransitGatewayList{}
+	if err := e.client.List(ctx, transits, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixTransitGateways: %w", err)
+	}
+	for _, gw := range transits.Items {
+		mappings = append(mappings, newMapping("AviatrixTransitGateway", gw.Namespace, gw.Name, "aviatrix_transit_gateway", gw.Spec.GwName))
+	}
+
+	edges := &aviatrixv1alpha1.AviatrixEdgeGatewayList{}
+	if err := e.client.List(ctx, edges, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixEdgeGateways: %w", err)
+	}
+	for _, gw := range edges.Items {
+		mappings = append(mappings, newMapping("AviatrixEdgeGateway", gw.Namespace, gw.Name, "aviatrix_edge_spoke", gw.Spec.GwName))
+	}
+
+	vpcs := &aviatrixv1alpha1.AviatrixVpcList{}
+	if err := e.client.List(ctx, vpcs, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixVpcs: %w", err)
+	}
+	for _, vpc := range vpcs.Items {
+		// VPCs are imported by their cloud ID, which is only known once created
+		if vpc.Status.VpcID == "" {
+			continue
+		}
+		mappings = append(mappings, newMapping("AviatrixVpc", vpc.Namespace, vpc.Name, "aviatrix_vpc", vpc.Status.VpcID))
+	}
+
+	peerings := &aviatrixv1alpha1.AviatrixVpcPeeringList{}
+	if err := e.client.List(ctx, peerings, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixVpcPeerings: %w", err)
+	}
+	for _, p := range peerings.Items {
+		// Peerings are imported by the VPCs peered on the controller
+		if p.Status.RequesterVpcID == "" {
+			continue
+		}
+		mappings = append(mappings, newMapping("AviatrixVpcPeering", p.Namespace, p.Name, "aviatrix_aws_peer", p.Status.RequesterVpcID+"~"+p.Status.AccepterVpcID))
+	}
+
+	attachments := &aviatrixv1alpha1.AviatrixTgwAttachmentList{}
+	if err := e.client.List(ctx, attachments, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixTgwAttachments: %w", err)
+	}
+	for _, a := range attachments.Items {
+		// Attachments are imported by the TGW and VPC attached on the controller
+		if a.Status.AttachedVpcID == "" {
+			continue
+		}
+		mappings = append(mappings, newMapping("AviatrixTgwAttachment", a.Namespace, a.Name, "aviatrix_aws_tgw_vpc_attachment", a.Status.AttachedTgwName+"~~"+a.Spec.NetworkDomain+"~~"+a.Status.AttachedVpcID))
+	}
+
+	transitPeerings := &aviatrixv1alpha1.AviatrixTransitGatewayPeeringList{}
+	if err := e.client.List(ctx, transitPeerings, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixTransitGatewayPeerings: %w", err)
+	}
+	for _, p := range transitPeerings.Items {
+		// Peerings are imported by the gateways peered on the controller
+		if p.Status.PeeredSourceGwName == "" {
+			continue
+		}
+		mappings = append(mappings, newMapping("AviatrixTransitGatewayPeering", p.Namespace, p.Name, "aviatrix_transit_gateway_peering", p.Status.PeeredSourceGwName+"~"+p.Status.PeeredDestinationGwName))
+	}
+
+	firewalls := &aviatrixv1alpha1.AviatrixFirewallList{}
+	if err := e.client.List(ctx, firewalls, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixFirewalls: %w", err)
+	}
+	for _, fw := range firewalls.Items {
+		mappings = append(mappings, newMapping("AviatrixFirewall", fw.Namespace, fw.Name, "aviatrix_firewall", fw.Spec.GwName))
+	}
+
+	domains := &aviatrixv1alpha1.AviatrixNetworkDomainList{}
+	if err := e.client.List(ctx, domains, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixNetworkDomains: %w", err)
+	}
+	for _, d := range domains.Items {
+		mappings = append(mappings, newMapping("AviatrixNetworkDomain", d.Namespace, d.Name, "aviatrix_segmentation_network_domain", d.Spec.Name))
+	}
+
+	securityDomains := &aviatrixv1alpha1.AviatrixSegmentationSecurityDomainList{}
+	if err := e.client.List(ctx, securityDomains, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixSegmentationSecurityDomains: %w", err)
+	}
+	for _, d := range securityDomains.Items {
+		mappings = append(mappings, newMapping("AviatrixSegmentationSecurityDomain", d.Namespace, d.Name, "aviatrix_segmentation_security_domain", d.Spec.Name))
+	}
+
+	policies := &aviatrixv1alpha1.AviatrixMicrosegPolicyList{}
+	if err := e.client.List(ctx, policies, opts...); err != nil {
+		return nil, fmt.Errorf("failed to list AviatrixMicrosegPolicies: %w", err)
+	}
+	for _, p := range policies.Items {
+		// Policies are imported by their controller-assigned UUID
+		if p.Status.PolicyID == "" {
+			continue
+		}
+		mappings = append(mappings, newMapping("AviatrixMicrosegPolicy", p.Namespace, p.Name, "aviatrix_microseg_policy_list", p.Status.PolicyID))
+	}
+
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].Address() < mappings[j].Address()
+	})
+
+	return mappings, nil
+}
+
+// Write renders the mappings in the requested format
+func Write(w io.Writer, mappings []ImportMapping, format string) error {
+	for _, m := range mappings {
+		var err error
+		switch format {
+		case FormatBlocks:
+			_, err = fmt.Fprintf(w, "# %s %s/%s\nimport {\n  to = %s\n  id = %q\n}\n\n", m.Kind, m.Namespace, m.Name, m.Address(), m.ImportID)
+		case FormatCommands:
+			_, err = fmt.Fprintf(w, "terraform import %s %q\n", m.Address(), m.ImportID)
+		default:
+			return fmt.Errorf("unsupported output format: %s", format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newMapping builds an import mapping with a Terraform-safe resource name
+func newMapping(kind, namespace, name, resourceType, importID string) ImportMapping {
+	return ImportMapping{
+		Kind:         kind,
+		Namespace:    namespace,
+		Name:         name,
+		ResourceType: resourceType,
+		ResourceName: resourceName(namespace, name),
+		ImportID:     importID,
+	}
+}
+
+// resourceName derives the Terraform resource name of a custom resource from its namespace and
+// name. Neither contains an underscore, so namespace_name is unique; when characters had to be
+// replaced a short hash of the original keeps a-b/c and a/b-c apart. Terraform names cannot start
+// with a digit, so those get a leading underscore.
+func resourceName(namespace, name string) string {
+	raw := fmt.Sprintf("%s_%s", namespace, name)
+	safe := invalidNameChars.ReplaceAllString(raw, "_")
+	if safe != raw {
+		sum := sha256.Sum256([]byte(namespace + "/" + name))
+		safe += "_" + hex.EncodeToString(sum[:])[:8]
+	}
+	if safe[0] >= '0' && safe[0] <= '9' {
+		safe = "_" + safe
+	}
+	return safe
+}
@@ -0,0 +1,39 @@
+package terraform
+
+import (
+	"regexp"
+	"testing"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
+func TestResourceName(t *testing.T) {
+	cases := map[string]struct {
+		namespace, name string
+		want            string
+	}{
+		"plain":         {"networking", "hub", "networking_hub"},
+		"dashes":        {"a-b", "c", "a_b_c_"},
+		"leading digit": {"1net", "hub", "_1net_hub"},
+	}
+	for desc, tc := range cases {
+		t.Run(desc, func(t *testing.T) {
+			got := resourceName(tc.namespace, tc.name)
+			if len(got) < len(tc.want) || got[:len(tc.want)] != tc.want {
+				t.Errorf("resourceName(%s, %s) = %s, want prefix %s", tc.namespace, tc.name, got, tc.want)
+			}
+			if !validName.MatchString(got) {
+				t.Errorf("resourceName(%s, %s) = %s, not a valid Terraform name", tc.namespace, tc.name, got)
+			}
+		})
+	}
+}
+
+func TestResourceNameCollisions(t *testing.T) {
+	if a, b := resourceName("a-b", "c"), resourceName("a", "b-c"); a == b {
+		t.Errorf("a-b/c and a/b-c both map to %s", a)
+	}
+	if a, b := resourceName("a", "b.c"), resourceName("a", "b-c"); a == b {
+		t.Errorf("a/b.c and a/b-c both map to %s", a)
+	}
+}
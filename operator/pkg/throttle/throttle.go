@@ -0,0 +1,291 @@
+package throttle
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Probes the gate limits
+const (
+	// ProbeDNSTest is a DNS resolution test of a HeadlessService
+	ProbeDNSTest = "dns-test"
+	// ProbeDNSDiscovery configures DNS based service discovery
+	ProbeDNSDiscovery = "dns-discovery"
+	// ProbeAPIDiscovery configures API based service discovery
+	ProbeAPIDiscovery = "api-discovery"
+)
+
+// Reasons a probe is throttled
+const (
+	ReasonRateLimited          = "RateLimited"
+	ReasonNamespaceRateLimited = "NamespaceRateLimited"
+	ReasonCircuitOpen          = "CircuitOpen"
+)
+
+// ConditionProbesThrottled is set on a HeadlessService while its probes are held back
+const ConditionProbesThrottled = "ProbesThrottled"
+
+// States of the circuit breaker on the DNS service
+const (
+	CircuitClosed   = "Closed"
+	CircuitOpen     = "Open"
+	CircuitHalfOpen = "HalfOpen"
+)
+
+// Defaults for settings a Config leaves unset
+const (
+	DefaultRate           = 20
+	DefaultBurst          = 40
+	DefaultNamespaceRate  = 2
+	DefaultNamespaceBurst = 10
+	DefaultErrorRatio     = 0.5
+	DefaultMinRequests    = 20
+	DefaultWindow         = time.Minute
+	DefaultOpenDuration   = 30 * time.Second
+)
+
+// Config sets the rate limits and the circuit breaker of a Gate. Zero fields use the defaults.
+type Config struct {
+	// Rate and Burst limit the probes per second across the operator
+	Rate  float64
+	Burst int
+	// NamespaceRate and NamespaceBurst limit the probes per second of one namespace
+	NamespaceRate  float64
+	NamespaceBurst int
+	// ErrorRatio is the share of DNS tests failing with server errors within Window that opens
+	// the circuit, once at least MinRequests tests ran
+	ErrorRatio  float64
+	MinRequests int
+	Window      time.Duration
+	// OpenDuration is how long the circuit stays open before a single test may probe the DNS
+	// service again
+	OpenDuration time.Duration
+}
+
+// withDefaults fills in the unset settings
+func (c Config) withDefaults() Config {
+	if c.Rate <= 0 {
+		c.Rate = DefaultRate
+	}
+	if c.Burst <= 0 {
+		c.Burst = DefaultBurst
+	}
+	if c.NamespaceRate <= 0 {
+		c.NamespaceRate = DefaultNamespaceRate
+	}
+	if c.NamespaceBurst <= 0 {
+		c.NamespaceBurst = DefaultNamespaceBurst
+	}
+	if c.ErrorRatio <= 0 || c.ErrorRatio > 1 {
+		c.ErrorRatio = DefaultErrorRatio
+	}
+	if c.MinRequests <= 0 {
+		c.MinRequests = DefaultMinRequests
+	}
+	if c.Window <= 0 {
+		c.Window = DefaultWindow
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = DefaultOpenDuration
+	}
+	return c
+}
+
+// Decision is the outcome of asking the gate for a probe
+type Decision struct {
+	Allowed bool
+	// Reason and Message explain why a probe is throttled
+	Reason  string
+	Message string
+	// RetryAfter is how long to wait before asking again
+	RetryAfter time.Duration
+}
+
+// outcome is the result of one DNS test within the breaker window
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// Gate rate limits the probes of all HeadlessServices, globally and per namespace, and stops
+// probes that depend on the DNS service while its error rate is elevated
+type Gate struct {
+	config Config
+
+	mu         sync.Mutex
+	global     *rate.Limiter
+	namespaces map[string]*rate.Limiter
+
+	state    string
+	outcomes []outcome
+	openedAt time.Time
+	trialAt  time.Time
+}
+
+// New creates a new gate
+func New(config Config) *Gate {
+	config = config.withDefaults()
+	return &Gate{
+		config:     config,
+		global:     rate.NewLimiter(rate.Limit(config.Rate), config.Burst),
+		namespaces: map[string]*rate.Limiter{},
+		state:      CircuitClosed,
+	}
+}
+
+// SetRateLimits changes the global and namespace rate limits to those of config; its circuit
+// breaker settings are ignored. Zero fields use the defaults.
+func (g *Gate) SetRateLimits(config Config) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	config = config.withDefaults()
+	g.config.Rate, g.config.Burst = config.Rate, config.Burst
+	g.config.NamespaceRate, g.config.NamespaceBurst = config.NamespaceRate, config.NamespaceBurst
+	g.global.SetLimit(rate.Limit(config.Rate))
+	g.global.SetBurst(config.Burst)
+	for _, limiter := range g.namespaces {
+		limiter.SetLimit(rate.Limit(config.NamespaceRate))
+		limiter.SetBurst(config.NamespaceBurst)
+	}
+}
+
+// Allow decides whether a probe of a HeadlessService in namespace may run now. An allowed probe
+// uses up its tokens; DNS tests must report their outcome with Record.
+func (g *Gate) Allow(probe, namespace string, now time.Time) Decision {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if dependsOnDNS(probe) {
+		switch g.circuitState(now) {
+		case CircuitOpen:
+			retry := g.openedAt.Add(g.config.OpenDuration).Sub(now)
+			return Decision{
+				Reason:     ReasonCircuitOpen,
+				Message:    fmt.Sprintf("DNS service error rate is elevated; probing paused for %s", retry.Round(time.Second)),
+				RetryAfter: retry,
+			}
+		case CircuitHalfOpen:
+			// Only one DNS test probes the service until it reports back
+			if probe != ProbeDNSTest || now.Before(g.trialAt.Add(g.config.OpenDuration)) {
+				return Decision{
+					Reason:     ReasonCircuitOpen,
+					Message:    "DNS service is being probed again after elevated error rates",
+					RetryAfter: g.config.OpenDuration,
+				}
+			}
+		}
+	}
+
+	limiter, ok := g.namespaces[namespace]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(g.config.NamespaceRate), g.config.NamespaceBurst)
+		g.namespaces[namespace] = limiter
+	}
+	reservation := limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return Decision{
+			Reason:     ReasonNamespaceRateLimited,
+			Message:    fmt.Sprintf("Probes of namespace %s exceed %g per second", namespace, g.config.NamespaceRate),
+			RetryAfter: delay,
+		}
+	}
+	globalReservation := g.global.ReserveN(now, 1)
+	if delay := globalReservation.DelayFrom(now); delay > 0 {
+		globalReservation.CancelAt(now)
+		reservation.CancelAt(now)
+		return Decision{
+			Reason:     ReasonRateLimited,
+			Message:    fmt.Sprintf("Probes exceed the operator-wide limit of %g per second", g.config.Rate),
+			RetryAfter: delay,
+		}
+	}
+
+	if probe == ProbeDNSTest && g.state == CircuitHalfOpen {
+		g.trialAt = now
+	}
+	return Decision{Allowed: true}
+}
+
+// Record reports the outcome of a DNS test. Only failures of the DNS service itself count
+// towards opening the circuit; a name that does not resolve is an answer.
+func (g *Gate) Record(serverError bool, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch g.circuitState(now) {
+	case CircuitHalfOpen:
+		if serverError {
+			g.open(now)
+		} else {
+			g.state = CircuitClosed
+			g.outcomes = nil
+		}
+		return
+	case CircuitOpen:
+		return
+	}
+
+	g.outcomes = append(g.outcomes, outcome{at: now, failed: serverError})
+	g.prune(now)
+	if len(g.outcomes) >= g.config.MinRequests && g.errorRatio() >= g.config.ErrorRatio {
+		g.open(now)
+	}
+}
+
+// State returns the state of the circuit and the error ratio of the DNS tests within the window
+func (g *Gate) State(now time.Time) (string, float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state := g.circuitState(now)
+	g.prune(now)
+	return state, g.errorRatio()
+}
+
+// circuitState moves an open circuit to half-open once OpenDuration passed
+func (g *Gate) circuitState(now time.Time) string {
+	if g.state == CircuitOpen && !now.Before(g.openedAt.Add(g.config.OpenDuration)) {
+		g.state = CircuitHalfOpen
+		g.trialAt = time.Time{}
+	}
+	return g.state
+}
+
+func (g *Gate) open(now time.Time) {
+	g.state = CircuitOpen
+	g.openedAt = now
+	g.outcomes = nil
+}
+
+// prune drops the outcomes older than the window
+func (g *Gate) prune(now time.Time) {
+	cutoff := now.Add(-g.config.Window)
+	i := 0
+	for i < len(g.outcomes) && g.outcomes[i].at.Before(cutoff) {
+		i++
+	}
+	g.outcomes = g.outcomes[i:]
+}
+
+func (g *Gate) errorRatio() float64 {
+	if len(g.outcomes) == 0 {
+		return 0
+	}
+	failed := 0
+	for _, o := range g.outcomes {
+		if o.failed {
+			failed++
+		}
+	}
+	return float64(failed) / float64(len(g.outcomes))
+}
+
+// dependsOnDNS reports whether a probe queries the cluster DNS service
+func dependsOnDNS(probe string) bool {
+	return probe == ProbeDNSTest || probe == ProbeDNSDiscovery
+}
@@ -0,0 +1,116 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllowLimitsNamespacesSeparately(t *testing.T) {
+	gate := New(Config{Rate: 100, Burst: 100, NamespaceRate: 1, NamespaceBurst: 2})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if decision := gate.Allow(ProbeDNSTest, "team-a", now); !decision.Allowed {
+			t.Fatalf("probe %d of team-a = %+v, want allowed within the burst", i, decision)
+		}
+	}
+	decision := gate.Allow(ProbeAPIDiscovery, "team-a", now)
+	if decision.Allowed || decision.Reason != ReasonNamespaceRateLimited || decision.RetryAfter <= 0 || decision.RetryAfter > time.Second {
+		t.Errorf("third probe of team-a = %+v, want it held back for up to a second", decision)
+	}
+	if decision := gate.Allow(ProbeDNSTest, "team-b", now); !decision.Allowed {
+		t.Errorf("probe of team-b = %+v, want other namespaces unaffected", decision)
+	}
+	if decision := gate.Allow(ProbeDNSTest, "team-a", now.Add(time.Second)); !decision.Allowed {
+		t.Errorf("probe of team-a a second later = %+v, want allowed", decision)
+	}
+}
+
+func TestAllowLimitsAllNamespaces(t *testing.T) {
+	gate := New(Config{Rate: 1, Burst: 3, NamespaceRate: 10, NamespaceBurst: 10})
+	now := time.Now()
+
+	for i, namespace := range []string{"a", "b", "c"} {
+		if decision := gate.Allow(ProbeDNSTest, namespace, now); !decision.Allowed {
+			t.Fatalf("probe %d = %+v, want allowed within the burst", i, decision)
+		}
+	}
+	if decision := gate.Allow(ProbeDNSTest, "d", now); decision.Allowed || decision.Reason != ReasonRateLimited {
+		t.Errorf("fourth probe = %+v, want the operator-wide limit", decision)
+	}
+	// The rejected probe gives its namespace token back
+	if decision := gate.Allow(ProbeDNSTest, "d", now.Add(time.Second)); !decision.Allowed {
+		t.Errorf("probe a second later = %+v, want allowed", decision)
+	}
+}
+
+func TestSetRateLimits(t *testing.T) {
+	gate := New(Config{Rate: 100, Burst: 100, NamespaceRate: 1, NamespaceBurst: 1})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	if decision := gate.Allow(ProbeDNSTest, "team-a", now); !decision.Allowed {
+		t.Fatalf("first probe = %+v, want allowed", decision)
+	}
+	if decision := gate.Allow(ProbeDNSTest, "team-a", now.Add(100*time.Millisecond)); decision.Allowed {
+		t.Fatalf("second probe = %+v, want held back at one per second", decision)
+	}
+
+	// Existing namespace limiters pick up the new limits
+	gate.SetRateLimits(Config{Rate: 100, Burst: 100, NamespaceRate: 10, NamespaceBurst: 1})
+	if decision := gate.Allow(ProbeDNSTest, "team-a", now.Add(200*time.Millisecond)); !decision.Allowed {
+		t.Errorf("probe after raising the limit = %+v, want allowed at ten per second", decision)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	gate := New(Config{Rate: 1000, Burst: 1000, NamespaceRate: 1000, NamespaceBurst: 1000, ErrorRatio: 0.5, MinRequests: 4, OpenDuration: time.Minute})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Names that do not resolve are answers and never open the circuit
+	for i := 0; i < 10; i++ {
+		gate.Record(false, now)
+	}
+	for i := 0; i < 9; i++ {
+		gate.Record(true, now)
+	}
+	if state, ratio := gate.State(now); state != CircuitClosed || ratio < 0.47 {
+		t.Fatalf("State() = %s, %.2f, want closed just below the error ratio", state, ratio)
+	}
+
+	// Old outcomes leave the window, so recent server errors open the circuit
+	later := now.Add(2 * DefaultWindow)
+	for i := 0; i < 4; i++ {
+		gate.Record(i%2 == 0, later)
+	}
+	if state, _ := gate.State(later); state != CircuitOpen {
+		t.Fatalf("State() = %s, want open at an error ratio of 0.5", state)
+	}
+	if decision := gate.Allow(ProbeDNSDiscovery, "a", later); decision.Allowed || decision.Reason != ReasonCircuitOpen || decision.RetryAfter != time.Minute {
+		t.Errorf("DNS discovery while open = %+v, want it paused for a minute", decision)
+	}
+	if decision := gate.Allow(ProbeAPIDiscovery, "a", later); !decision.Allowed {
+		t.Errorf("API discovery while open = %+v, want probes not using DNS allowed", decision)
+	}
+
+	// After OpenDuration a single DNS test probes the service
+	halfOpen := later.Add(time.Minute)
+	if decision := gate.Allow(ProbeDNSTest, "a", halfOpen); !decision.Allowed {
+		t.Fatalf("trial DNS test = %+v, want allowed", decision)
+	}
+	if decision := gate.Allow(ProbeDNSTest, "b", halfOpen); decision.Allowed {
+		t.Errorf("second DNS test while half-open = %+v, want held back", decision)
+	}
+	gate.Record(true, halfOpen)
+	if state, _ := gate.State(halfOpen); state != CircuitOpen {
+		t.Fatalf("State() after a failed trial = %s, want open again", state)
+	}
+
+	recovered := halfOpen.Add(time.Minute)
+	if decision := gate.Allow(ProbeDNSTest, "a", recovered); !decision.Allowed {
+		t.Fatalf("trial DNS test = %+v, want allowed", decision)
+	}
+	gate.Record(false, recovered)
+	if state, ratio := gate.State(recovered); state != CircuitClosed || ratio != 0 {
+		t.Errorf("State() after a good trial = %s, %.2f, want closed with a fresh window", state, ratio)
+	}
+}
@@ -0,0 +1,342 @@
+package trafficpolicy
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/naming"
+)
+
+const (
+	// PolicyLabel marks the resources compiled from a traffic policy with its name
+	PolicyLabel = "aviatrix.k8s.io/traffic-policy"
+
+	// ConditionCompiled is true while the artifacts match the intent
+	ConditionCompiled = "Compiled"
+
+	// Artifact kinds
+	KindNetworkPolicy  = "NetworkPolicy"
+	KindFirewall       = "AviatrixFirewall"
+	KindMicrosegPolicy = "AviatrixMicrosegPolicy"
+
+	// allPorts is the port range of firewall rules that match every port
+	allPorts = "0:65535"
+)
+
+// Compiled holds the resources a traffic policy compiles into. Their names are set, their
+// namespace is the namespace of the policy.
+type Compiled struct {
+	NetworkPolicies  []networkingv1.NetworkPolicy
+	Firewall         *aviatrixv1alpha1.AviatrixFirewall
+	MicrosegPolicies []aviatrixv1alpha1.AviatrixMicrosegPolicy
+	Artifacts        []aviatrixv1alpha1.TrafficPolicyArtifact
+}
+
+// Validate checks that workloads are uniquely named, flows refer to them and every workload
+// in a flow can be expressed by each enabled target
+func Validate(spec *aviatrixv1alpha1.AviatrixTrafficPolicySpec) error {
+	workloads := map[string]*aviatrixv1alpha1.TrafficWorkload{}
+	for i := range spec.Workloads {
+		workload := &spec.Workloads[i]
+		if msgs := validation.IsDNS1123Label(workload.Name); len(msgs) > 0 {
+			return fmt.Errorf("workload %q: %s", workload.Name, strings.Join(msgs, ", "))
+		}
+		if workloads[workload.Name] != nil {
+			return fmt.Errorf("workload %s is defined twice", workload.Name)
+		}
+		if len(workload.PodSelector) == 0 && len(workload.CIDRs) == 0 && workload.Tag == "" {
+			return fmt.Errorf("workload %s needs a podSelector, cidrs or a tag", workload.Name)
+		}
+		for _, cidr := range workload.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("workload %s: invalid CIDR %q", workload.Name, cidr)
+			}
+		}
+		workloads[workload.Name] = workload
+	}
+	if spec.Firewall != nil && spec.Firewall.GwName == "" {
+		return fmt.Errorf("firewall.gwName is required")
+	}
+
+	for i, flow := range spec.Allow {
+		for _, name := range []string{flow.From, flow.To} {
+			workload := workloads[name]
+			if workload == nil {
+				return fmt.Errorf("allow[%d]: unknown workload %q", i, name)
+			}
+			if spec.Firewall != nil && len(workload.CIDRs) == 0 {
+				return fmt.Errorf("allow[%d]: workload %s needs cidrs for firewall rules", i, name)
+			}
+			if spec.Microseg != nil && len(workload.CIDRs) == 0 && workload.Tag == "" {
+				return fmt.Errorf("allow[%d]: workload %s needs a tag or cidrs for microsegmentation", i, name)
+			}
+		}
+		if flow.From == flow.To {
+			return fmt.Errorf("allow[%d]: traffic within workload %s is always allowed", i, flow.From)
+		}
+		if len(workloads[flow.To].PodSelector) > 0 && len(workloads[flow.From].PodSelector) == 0 && len(workloads[flow.From].CIDRs) == 0 {
+			return fmt.Errorf("allow[%d]: workload %s needs a podSelector or cidrs to be admitted by the NetworkPolicy of %s", i, flow.From, flow.To)
+		}
+		for _, port := range flow.Ports {
+			if port.Port < 1 || port.Port > 65535 {
+				return fmt.Errorf("allow[%d]: port %d is out of range", i, port.Port)
+			}
+			if protocol := protocolOf(port); protocol != corev1.ProtocolTCP && protocol != corev1.ProtocolUDP {
+				return fmt.Errorf("allow[%d]: protocol must be TCP or UDP, got %q", i, port.Protocol)
+			}
+		}
+	}
+	return nil
+}
+
+// Compile translates a traffic policy into NetworkPolicies for workloads with a pod selector,
+// and into firewall rules and microsegmentation policies when requested, so that every target
+// allows the same flows and denies the rest
+func Compile(policy *aviatrixv1alpha1.AviatrixTrafficPolicy) (*Compiled, error) {
+	spec := &policy.Spec
+	if err := Validate(spec); err != nil {
+		return nil, err
+	}
+	workloads := map[string]*aviatrixv1alpha1.TrafficWorkload{}
+	for i := range spec.Workloads {
+		workloads[spec.Workloads[i].Name] = &spec.Workloads[i]
+	}
+
+	compiled := &Compiled{}
+	for i := range spec.Workloads {
+		workload := &spec.Workloads[i]
+		if len(workload.PodSelector) == 0 {
+			continue
+		}
+		networkPolicy, summary := compileNetworkPolicy(policy, workload, workloads)
+		compiled.NetworkPolicies = append(compiled.NetworkPolicies, networkPolicy)
+		compiled.Artifacts = append(compiled.Artifacts, aviatrixv1alpha1.TrafficPolicyArtifact{Kind: KindNetworkPolicy, Name: networkPolicy.Name, Summary: summary})
+	}
+
+	if spec.Firewall != nil {
+		compiled.Firewall = compileFirewall(policy, workloads)
+		allowed := 0
+		for _, rule := range compiled.Firewall.Spec.Rules {
+			if rule.Action == "allow" {
+				allowed++
+			}
+		}
+		compiled.Artifacts = append(compiled.Artifacts, aviatrixv1alpha1.TrafficPolicyArtifact{
+			Kind:    KindFirewall,
+			Name:    compiled.Firewall.Name,
+			Summary: fmt.Sprintf("%d allow and %d deny rules on gateway %s", allowed, len(compiled.Firewall.Spec.Rules)-allowed, spec.Firewall.GwName),
+		})
+	}
+
+	if spec.Microseg != nil {
+		compiled.MicrosegPolicies = compileMicroseg(policy, workloads)
+		for _, microseg := range compiled.MicrosegPolicies {
+			compiled.Artifacts = append(compiled.Artifacts, aviatrixv1alpha1.TrafficPolicyArtifact{
+				Kind:    KindMicrosegPolicy,
+				Name:    microseg.Name,
+				Summary: microseg.Spec.Description,
+			})
+		}
+	}
+	return compiled, nil
+}
+
+// Labels returns the labels of the resources compiled from a traffic policy
+func Labels(policy *aviatrixv1alpha1.AviatrixTrafficPolicy) map[string]string {
+	return map[string]string{PolicyLabel: policy.Name}
+}
+
+// compileNetworkPolicy isolates the pods of a workload for ingress, admitting its own pods and
+// the flows that end at it
+func compileNetworkPolicy(policy *aviatrixv1alpha1.AviatrixTrafficPolicy, workload *aviatrixv1alpha1.TrafficWorkload, workloads map[string]*aviatrixv1alpha1.TrafficWorkload) (networkingv1.NetworkPolicy, string) {
+	rules := []networkingv1.NetworkPolicyIngressRule{{
+		From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: workload.PodSelector}}},
+	}}
+	var allowed []string
+	for _, flow := range policy.Spec.Allow {
+		if flow.To != workload.Name {
+			continue
+		}
+		source := workloads[flow.From]
+		var peers []networkingv1.NetworkPolicyPeer
+		if len(source.PodSelector) > 0 {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: source.PodSelector}})
+		}
+		for _, cidr := range source.CIDRs {
+			peers = append(peers, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		rule := networkingv1.NetworkPolicyIngressRule{From: peers}
+		for _, port := range flow.Ports {
+			protocol := protocolOf(port)
+			number := intstr.FromInt32(port.Port)
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &number})
+		}
+		rules = append(rules, rule)
+		allowed = append(allowed, fmt.Sprintf("%s on %s", flow.From, describePorts(flow.Ports)))
+	}
+
+	summary := fmt.Sprintf("ingress to %s only from itself", workload.Name)
+	if len(allowed) > 0 {
+		summary = fmt.Sprintf("ingress to %s from itself and %s", workload.Name, strings.Join(allowed, ", "))
+	}
+	return networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      naming.Qualified(naming.TrafficPolicy, policy.Name, workload.Name),
+			Namespace: policy.Namespace,
+			Labels:    Labels(policy),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: workload.PodSelector},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     rules,
+		},
+	}, summary
+}
+
+// compileFirewall allows the flows between the CIDRs of their workloads and then denies all
+// other traffic between workloads. The base policy allows traffic unrelated to the workloads.
+func compileFirewall(policy *aviatrixv1alpha1.AviatrixTrafficPolicy, workloads map[string]*aviatrixv1alpha1.TrafficWorkload) *aviatrixv1alpha1.AviatrixFirewall {
+	logEnabled := policy.Spec.Firewall.LogEnabled
+	var rules []aviatrixv1alpha1.FirewallRule
+	for _, flow := range policy.Spec.Allow {
+		for _, src := range workloads[flow.From].CIDRs {
+			for _, dst := range workloads[flow.To].CIDRs {
+				description := fmt.Sprintf("%s: %s to %s", policy.Name, flow.From, flow.To)
+				if len(flow.Ports) == 0 {
+					rules = append(rules, aviatrixv1alpha1.FirewallRule{Protocol: "all", SrcIP: src, DstIP: dst, Port: allPorts, Action: "allow", LogEnabled: logEnabled, Description: description})
+				}
+				for _, port := range flow.Ports {
+					rules = append(rules, aviatrixv1alpha1.FirewallRule{
+						Protocol:    strings.ToLower(string(protocolOf(port))),
+						SrcIP:       src,
+						DstIP:       dst,
+						Port:        strconv.Itoa(int(port.Port)),
+						Action:      "allow",
+						LogEnabled:  logEnabled,
+						Description: description,
+					})
+				}
+			}
+		}
+	}
+	for _, from := range policy.Spec.Workloads {
+		for _, to := range policy.Spec.Workloads {
+			if from.Name == to.Name {
+				continue
+			}
+			for _, src := range from.CIDRs {
+				for _, dst := range to.CIDRs {
+					rules = append(rules, aviatrixv1alpha1.FirewallRule{
+						Protocol:    "all",
+						SrcIP:       src,
+						DstIP:       dst,
+						Port:        allPorts,
+						Action:      "deny",
+						LogEnabled:  logEnabled,
+						Description: fmt.Sprintf("%s: deny %s to %s", policy.Name, from.Name, to.Name),
+					})
+				}
+			}
+		}
+	}
+
+	return &aviatrixv1alpha1.AviatrixFirewall{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			Labels:    Labels(policy),
+		},
+		Spec: aviatrixv1alpha1.AviatrixFirewallSpec{
+			GwName:     policy.Spec.Firewall.GwName,
+			BasePolicy: "allow-all",
+			Rules:      rules,
+		},
+	}
+}
+
+// compileMicroseg creates one allow policy per flow, port and pair of endpoints.
+// Microsegmentation denies what no policy allows, so no deny policies are needed.
+func compileMicroseg(policy *aviatrixv1alpha1.AviatrixTrafficPolicy, workloads map[string]*aviatrixv1alpha1.TrafficWorkload) []aviatrixv1alpha1.AviatrixMicrosegPolicy {
+	var result []aviatrixv1alpha1.AviatrixMicrosegPolicy
+	for _, flow := range policy.Spec.Allow {
+		ports := flow.Ports
+		if len(ports) == 0 {
+			ports = []aviatrixv1alpha1.TrafficPort{{}}
+		}
+		for _, src := range endpointsOf(workloads[flow.From]) {
+			for _, dst := range endpointsOf(workloads[flow.To]) {
+				for _, port := range ports {
+					name := naming.Qualified(naming.TrafficMicroseg, policy.Name, fmt.Sprintf("%s-%s-%d", flow.From, flow.To, len(result)))
+					protocol, portRange := "all", allPorts
+					if port.Port != 0 {
+						protocol, portRange = strings.ToLower(string(protocolOf(port))), strconv.Itoa(int(port.Port))
+					}
+					result = append(result, aviatrixv1alpha1.AviatrixMicrosegPolicy{
+						ObjectMeta: metav1.ObjectMeta{
+							Name:      name,
+							Namespace: policy.Namespace,
+							Labels:    Labels(policy),
+						},
+						Spec: aviatrixv1alpha1.AviatrixMicrosegPolicySpec{
+							Name:        name,
+							Description: fmt.Sprintf("allow %s (%s) to %s (%s) on %s", flow.From, src.Value, flow.To, dst.Value, describePorts([]aviatrixv1alpha1.TrafficPort{port})),
+							Source:      src,
+							Destination: dst,
+							Action:      "allow",
+							Port:        portRange,
+							Protocol:    protocol,
+							LogEnabled:  policy.Spec.Microseg.LogEnabled,
+						},
+					})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// endpointsOf returns the microsegmentation endpoints of a workload: its tag, or each CIDR
+func endpointsOf(workload *aviatrixv1alpha1.TrafficWorkload) []aviatrixv1alpha1.PolicyEndpoint {
+	if workload.Tag != "" {
+		return []aviatrixv1alpha1.PolicyEndpoint{{Type: "tag", Value: workload.Tag}}
+	}
+	endpoints := make([]aviatrixv1alpha1.PolicyEndpoint, 0, len(workload.CIDRs))
+	for _, cidr := range workload.CIDRs {
+		endpoints = append(endpoints, aviatrixv1alpha1.PolicyEndpoint{Type: "subnet", Value: cidr})
+	}
+	return endpoints
+}
+
+// protocolOf returns the protocol of a port, TCP when unset
+func protocolOf(port aviatrixv1alpha1.TrafficPort) corev1.Protocol {
+	if port.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return corev1.Protocol(strings.ToUpper(port.Protocol))
+}
+
+// describePorts renders ports as TCP/8080, UDP/53 or all ports
+func describePorts(ports []aviatrixv1alpha1.TrafficPort) string {
+	var parts []string
+	for _, port := range ports {
+		if port.Port == 0 {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s/%d", protocolOf(port), port.Port))
+	}
+	if len(parts) == 0 {
+		return "all ports"
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,145 @@
+package trafficpolicy
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/security"
+)
+
+// frontendToBackend is "frontend can reach backend:8080; nothing else"
+func frontendToBackend() *aviatrixv1alpha1.AviatrixTrafficPolicy {
+	return &aviatrixv1alpha1.AviatrixTrafficPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+		Spec: aviatrixv1alpha1.AviatrixTrafficPolicySpec{
+			Workloads: []aviatrixv1alpha1.TrafficWorkload{
+				{Name: "frontend", PodSelector: map[string]string{"app": "frontend"}, CIDRs: []string{"10.1.0.0/24"}, Tag: "frontend"},
+				{Name: "backend", PodSelector: map[string]string{"app": "backend"}, CIDRs: []string{"10.2.0.0/24"}, Tag: "backend"},
+			},
+			Allow: []aviatrixv1alpha1.TrafficFlow{
+				{From: "frontend", To: "backend", Ports: []aviatrixv1alpha1.TrafficPort{{Port: 8080}}},
+			},
+			Firewall: &aviatrixv1alpha1.TrafficPolicyFirewall{GwName: "spoke-east"},
+			Microseg: &aviatrixv1alpha1.TrafficPolicyMicroseg{},
+		},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*aviatrixv1alpha1.AviatrixTrafficPolicySpec)
+		err    string
+	}{
+		{"valid", func(*aviatrixv1alpha1.AviatrixTrafficPolicySpec) {}, ""},
+		{"unknown workload", func(s *aviatrixv1alpha1.AviatrixTrafficPolicySpec) { s.Allow[0].To = "db" }, `unknown workload "db"`},
+		{"duplicate workload", func(s *aviatrixv1alpha1.AviatrixTrafficPolicySpec) { s.Workloads[1].Name = "frontend" }, "defined twice"},
+		{"firewall without cidrs", func(s *aviatrixv1alpha1.AviatrixTrafficPolicySpec) { s.Workloads[0].CIDRs = nil }, "needs cidrs for firewall rules"},
+		{"invalid protocol", func(s *aviatrixv1alpha1.AviatrixTrafficPolicySpec) { s.Allow[0].Ports[0].Protocol = "sctp" }, "protocol must be TCP or UDP"},
+		{"tag only source of a pod workload", func(s *aviatrixv1alpha1.AviatrixTrafficPolicySpec) {
+			s.Firewall = nil
+			s.Workloads[0].PodSelector, s.Workloads[0].CIDRs = nil, nil
+		}, "needs a podSelector or cidrs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := frontendToBackend()
+			tt.modify(&policy.Spec)
+			err := Validate(&policy.Spec)
+			if tt.err == "" && err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestCompileNetworkPolicies(t *testing.T) {
+	compiled, err := Compile(frontendToBackend())
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	if len(compiled.NetworkPolicies) != 2 {
+		t.Fatalf("compiled %d NetworkPolicies, want one per workload", len(compiled.NetworkPolicies))
+	}
+
+	frontend, backend := compiled.NetworkPolicies[0], compiled.NetworkPolicies[1]
+	if frontend.Name != "web-frontend" || len(frontend.Spec.Ingress) != 1 {
+		t.Errorf("frontend NetworkPolicy %s admits %d rules, want only its own pods", frontend.Name, len(frontend.Spec.Ingress))
+	}
+	if len(backend.Spec.Ingress) != 2 {
+		t.Fatalf("backend NetworkPolicy has %d rules, want its own pods and frontend", len(backend.Spec.Ingress))
+	}
+	rule := backend.Spec.Ingress[1]
+	if rule.From[0].PodSelector.MatchLabels["app"] != "frontend" || rule.From[1].IPBlock.CIDR != "10.1.0.0/24" {
+		t.Errorf("backend admits %+v, want the frontend pods and CIDR", rule.From)
+	}
+	if len(rule.Ports) != 1 || rule.Ports[0].Port.IntValue() != 8080 || *rule.Ports[0].Protocol != "TCP" {
+		t.Errorf("backend admits ports %+v, want TCP/8080", rule.Ports)
+	}
+	if backend.Labels[PolicyLabel] != "web" {
+		t.Errorf("labels = %v", backend.Labels)
+	}
+}
+
+func TestCompiledRulesAgree(t *testing.T) {
+	compiled, err := Compile(frontendToBackend())
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		conn   security.Connection
+		expect string
+	}{
+		{"frontend to backend on the allowed port", security.Connection{Src: "10.1.0.5", Dst: "10.2.0.7", Port: 8080, Protocol: "tcp"}, security.VerdictAllow},
+		{"frontend to backend on another port", security.Connection{Src: "10.1.0.5", Dst: "10.2.0.7", Port: 22, Protocol: "tcp"}, security.VerdictDeny},
+		{"backend to frontend", security.Connection{Src: "10.2.0.7", Dst: "10.1.0.5", Port: 8080, Protocol: "tcp"}, security.VerdictDeny},
+	}
+	for _, tt := range tests {
+		verdict, err := security.EvaluateFirewall(&compiled.Firewall.Spec, tt.conn)
+		if err != nil {
+			t.Fatalf("%s: EvaluateFirewall() error = %v", tt.name, err)
+		}
+		if verdict.Action != tt.expect {
+			t.Errorf("%s: firewall verdict = %s (%s), want %s", tt.name, verdict.Action, verdict.Reason, tt.expect)
+		}
+	}
+
+	// Traffic unrelated to the workloads passes the gateway
+	verdict, _ := security.EvaluateFirewall(&compiled.Firewall.Spec, security.Connection{Src: "10.9.0.1", Dst: "10.2.0.7", Port: 443, Protocol: "tcp"})
+	if verdict.Action != security.VerdictAllow {
+		t.Errorf("unrelated traffic verdict = %s, want allow", verdict.Action)
+	}
+
+	if len(compiled.MicrosegPolicies) != 1 {
+		t.Fatalf("compiled %d microsegmentation policies, want 1", len(compiled.MicrosegPolicies))
+	}
+	microseg := &compiled.MicrosegPolicies[0].Spec
+	for _, tt := range []struct {
+		conn   security.Connection
+		expect string
+	}{
+		{security.Connection{Src: "frontend", Dst: "backend", Port: 8080, Protocol: "tcp"}, security.VerdictAllow},
+		{security.Connection{Src: "backend", Dst: "frontend", Port: 8080, Protocol: "tcp"}, security.VerdictDeny},
+	} {
+		verdict, err := security.EvaluateMicroseg(microseg, tt.conn)
+		if err != nil || verdict.Action != tt.expect {
+			t.Errorf("microseg verdict for %+v = %s, %v, want %s", tt.conn, verdict.Action, err, tt.expect)
+		}
+	}
+
+	kinds := map[string]int{}
+	for _, artifact := range compiled.Artifacts {
+		kinds[artifact.Kind]++
+	}
+	if kinds[KindNetworkPolicy] != 2 || kinds[KindFirewall] != 1 || kinds[KindMicrosegPolicy] != 1 {
+		t.Errorf("artifacts = %+v", compiled.Artifacts)
+	}
+}
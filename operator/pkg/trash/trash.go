@@ -0,0 +1,427 @@
+package trash
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/labeling"
+	"github.com/k8s-playgrounds/operator/pkg/naming"
+)
+
+const (
+	// UndeleteAnnotation set to "true" on a cluster in the Trash phase restores it
+	UndeleteAnnotation = "k8s-playgrounds.io/undelete"
+	// TrashedNodeLabel is required by the pods of trashed DaemonSets. No node has it, so the
+	// DaemonSets run nowhere while they are kept.
+	TrashedNodeLabel = "k8s-playgrounds.io/trashed"
+	// ReplicasAnnotation saves on a workload scaled to zero the replicas it had, which Resume
+	// restores
+	ReplicasAnnotation = "k8s-playgrounds.io/trashed-replicas"
+	// DefaultRetention is how long a deleted cluster stays in the Trash phase when the spec
+	// sets no retention
+	DefaultRetention = 24 * time.Hour
+	// PollInterval bounds the time between two reconciles of a trashed cluster
+	PollInterval = 10 * time.Minute
+
+	// recordKey is the key of the saved cluster in the undelete record
+	recordKey = "cluster.json"
+)
+
+// childKinds are the kinds of the resources a cluster creates, released to survive an undelete
+var childKinds = []schema.GroupVersionKind{
+	{Version: "v1", Kind: "Service"},
+	{Version: "v1", Kind: "ConfigMap"},
+	{Version: "v1", Kind: "Secret"},
+	{Version: "v1", Kind: "ServiceAccount"},
+	{Version: "v1", Kind: "PersistentVolumeClaim"},
+	{Group: "apps", Version: "v1", Kind: "Deployment"},
+	{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+	{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+	{Group: "apps", Version: "v1", Kind: "ReplicaSet"},
+	{Group: "batch", Version: "v1", Kind: "Job"},
+	{Group: "batch", Version: "v1", Kind: "CronJob"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"},
+	{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"},
+	{Group: "autoscaling", Version: "v2", Kind: "HorizontalPodAutoscaler"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "RoleBinding"},
+}
+
+// Enabled reports whether deleting the cluster moves it to the Trash phase first
+func Enabled(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) bool {
+	return cluster.Spec.SoftDelete != nil
+}
+
+// Retention returns how long a deleted cluster is kept
+func Retention(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) time.Duration {
+	if cluster.Spec.SoftDelete == nil || cluster.Spec.SoftDelete.Retention.Duration <= 0 {
+		return DefaultRetention
+	}
+	return cluster.Spec.SoftDelete.Retention.Duration
+}
+
+// PurgeAt returns when a deleted cluster leaves the Trash phase and is destroyed
+func PurgeAt(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) time.Time {
+	return cluster.DeletionTimestamp.Add(Retention(cluster))
+}
+
+// UndeleteRequested reports whether the cluster carries the undelete annotation
+func UndeleteRequested(cluster client.Object) bool {
+	return cluster.GetAnnotations()[UndeleteAnnotation] == "true"
+}
+
+// RecordName returns the name of the ConfigMap saving a cluster being undeleted
+func RecordName(cluster string) string {
+	return naming.Name(naming.UndeleteRecord, cluster)
+}
+
+// Manager stops the workloads of trashed clusters and carries clusters across an undelete
+type Manager struct {
+	client client.Client
+}
+
+// NewManager creates a trash manager
+func NewManager(c client.Client) *Manager {
+	return &Manager{client: c}
+}
+
+// selector matches the resources labeled as belonging to the cluster
+func selector(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, namespace string) []client.ListOption {
+	return []client.ListOption{client.InNamespace(namespace), client.MatchingLabels{labeling.ClusterLabel: cluster.Name}}
+}
+
+// ScaleDown stops every workload of the cluster without deleting it: Deployments, StatefulSets
+// and ReplicaSets are scaled to zero with their replicas saved, Jobs and CronJobs suspended and
+// DaemonSets restricted to nodes that do not exist. It returns the workloads it changed, sorted,
+// which Resume starts again.
+func (m *Manager) ScaleDown(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) ([]string, error) {
+	var stopped []string
+	zero := int32(0)
+	suspend := true
+	for _, namespace := range cluster.ManagedNamespaces() {
+		deployments := &appsv1.DeploymentList{}
+		if err := m.client.List(ctx, deployments, selector(cluster, namespace)...); err != nil {
+			return nil, err
+		}
+		for i := range deployments.Items {
+			d := &deployments.Items[i]
+			if d.Spec.Replicas == nil || *d.Spec.Replicas != 0 {
+				saveReplicas(d, d.Spec.Replicas)
+				d.Spec.Replicas = &zero
+				if err := m.update(ctx, d, "Deployment", &stopped); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		statefulSets := &appsv1.StatefulSetList{}
+		if err := m.client.List(ctx, statefulSets, selector(cluster, namespace)...); err != nil {
+			return nil, err
+		}
+		for i := range statefulSets.Items {
+			s := &statefulSets.Items[i]
+			if s.Spec.Replicas == nil || *s.Spec.Replicas != 0 {
+				saveReplicas(s, s.Spec.Replicas)
+				s.Spec.Replicas = &zero
+				if err := m.update(ctx, s, "StatefulSet", &stopped); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		replicaSets := &appsv1.ReplicaSetList{}
+		if err := m.client.List(ctx, replicaSets, selector(cluster, namespace)...); err != nil {
+			return nil, err
+		}
+		for i := range replicaSets.Items {
+			rs := &replicaSets.Items[i]
+			// ReplicaSets of a Deployment follow the Deployment
+			if owner := metav1.GetControllerOf(rs); owner != nil && owner.Kind == "Deployment" {
+				continue
+			}
+			if rs.Spec.Replicas == nil || *rs.Spec.Replicas != 0 {
+				saveReplicas(rs, rs.Spec.Replicas)
+				rs.Spec.Replicas = &zero
+				if err := m.update(ctx, rs, "ReplicaSet", &stopped); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		daemonSets := &appsv1.DaemonSetList{}
+		if err := m.client.List(ctx, daemonSets, selector(cluster, namespace)...); err != nil {
+			return nil, err
+		}
+		for i := range daemonSets.Items {
+			ds := &daemonSets.Items[i]
+			if _, ok := ds.Spec.Template.Spec.NodeSelector[TrashedNodeLabel]; !ok {
+				if ds.Spec.Template.Spec.NodeSelector == nil {
+					ds.Spec.Template.Spec.NodeSelector = map[string]string{}
+				}
+				ds.Spec.Template.Spec.NodeSelector[TrashedNodeLabel] = "true"
+				if err := m.update(ctx, ds, "DaemonSet", &stopped); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		jobs := &batchv1.JobList{}
+		if err := m.client.List(ctx, jobs, selector(cluster, namespace)...); err != nil {
+			return nil, err
+		}
+		for i := range jobs.Items {
+			j := &jobs.Items[i]
+			if j.Status.CompletionTime == nil && (j.Spec.Suspend == nil || !*j.Spec.Suspend) {
+				j.Spec.Suspend = &suspend
+				if err := m.update(ctx, j, "Job", &stopped); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		cronJobs := &batchv1.CronJobList{}
+		if err := m.client.List(ctx, cronJobs, selector(cluster, namespace)...); err != nil {
+			return nil, err
+		}
+		for i := range cronJobs.Items {
+			cj := &cronJobs.Items[i]
+			if cj.Spec.Suspend == nil || !*cj.Spec.Suspend {
+				cj.Spec.Suspend = &suspend
+				if err := m.update(ctx, cj, "CronJob", &stopped); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	sort.Strings(stopped)
+	return stopped, nil
+}
+
+// Resume starts the workloads ScaleDown stopped again, given as the Kind/namespace/name it
+// returned: Deployments, StatefulSets and ReplicaSets get their saved replicas back, Jobs and
+// CronJobs are resumed and DaemonSets may run on every node again. Workloads deleted since are
+// skipped.
+func (m *Manager) Resume(ctx context.Context, stopped []string) error {
+	resume := false
+	for _, workload := range stopped {
+		parts := strings.SplitN(workload, "/", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		kind, key := parts[0], types.NamespacedName{Namespace: parts[1], Name: parts[2]}
+
+		var obj client.Object
+		switch kind {
+		case "Deployment":
+			obj = &appsv1.Deployment{}
+		case "StatefulSet":
+			obj = &appsv1.StatefulSet{}
+		case "ReplicaSet":
+			obj = &appsv1.ReplicaSet{}
+		case "DaemonSet":
+			obj = &appsv1.DaemonSet{}
+		case "Job":
+			obj = &batchv1.Job{}
+		case "CronJob":
+			obj = &batchv1.CronJob{}
+		default:
+			continue
+		}
+		if err := m.client.Get(ctx, key, obj); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get %s %s: %w", kind, key, err)
+		}
+
+		switch w := obj.(type) {
+		case *appsv1.Deployment:
+			w.Spec.Replicas = savedReplicas(w, w.Spec.Replicas)
+		case *appsv1.StatefulSet:
+			w.Spec.Replicas = savedReplicas(w, w.Spec.Replicas)
+		case *appsv1.ReplicaSet:
+			w.Spec.Replicas = savedReplicas(w, w.Spec.Replicas)
+		case *appsv1.DaemonSet:
+			delete(w.Spec.Template.Spec.NodeSelector, TrashedNodeLabel)
+		case *batchv1.Job:
+			w.Spec.Suspend = &resume
+		case *batchv1.CronJob:
+			w.Spec.Suspend = &resume
+		}
+		if err := m.client.Update(ctx, obj); err != nil {
+			return fmt.Errorf("failed to resume %s %s: %w", kind, key, err)
+		}
+	}
+	return nil
+}
+
+// saveReplicas records the replicas of a workload before it is scaled to zero; unset replicas
+// default to 1
+func saveReplicas(obj metav1.Object, replicas *int32) {
+	saved := int32(1)
+	if replicas != nil {
+		saved = *replicas
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[ReplicasAnnotation] = strconv.Itoa(int(saved))
+	obj.SetAnnotations(annotations)
+}
+
+// savedReplicas returns the replicas saved by saveReplicas and removes the record, or replicas
+// when none was saved
+func savedReplicas(obj metav1.Object, replicas *int32) *int32 {
+	annotations := obj.GetAnnotations()
+	value, ok := annotations[ReplicasAnnotation]
+	if !ok {
+		return replicas
+	}
+	delete(annotations, ReplicasAnnotation)
+	obj.SetAnnotations(annotations)
+	saved, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || saved < 0 {
+		return replicas
+	}
+	restored := int32(saved)
+	return &restored
+}
+
+// Merge adds newly stopped workloads to those stopped before, keeping them sorted
+func Merge(stopped, more []string) []string {
+	seen := map[string]bool{}
+	for _, workload := range stopped {
+		seen[workload] = true
+	}
+	for _, workload := range more {
+		if !seen[workload] {
+			seen[workload] = true
+			stopped = append(stopped, workload)
+		}
+	}
+	sort.Strings(stopped)
+	return stopped
+}
+
+// update writes a stopped workload and records it
+func (m *Manager) update(ctx context.Context, obj client.Object, kind string, stopped *[]string) error {
+	if err := m.client.Update(ctx, obj); err != nil {
+		return fmt.Errorf("failed to stop %s %s/%s: %w", kind, obj.GetNamespace(), obj.GetName(), err)
+	}
+	*stopped = append(*stopped, kind+"/"+obj.GetNamespace()+"/"+obj.GetName())
+	return nil
+}
+
+// Release removes the owner references to the cluster from its resources, so the garbage
+// collector keeps them when the deleted cluster goes away and the restored cluster adopts them
+func (m *Manager) Release(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	for _, namespace := range cluster.ManagedNamespaces() {
+		for _, gvk := range childKinds {
+			list := &metav1.PartialObjectMetadataList{}
+			list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+			if err := m.client.List(ctx, list, selector(cluster, namespace)...); err != nil {
+				return fmt.Errorf("failed to list %s: %w", gvk.Kind, err)
+			}
+			for i := range list.Items {
+				obj := &list.Items[i]
+				refs := obj.GetOwnerReferences()
+				kept := refs[:0:0]
+				for _, ref := range refs {
+					if ref.UID != cluster.UID {
+						kept = append(kept, ref)
+					}
+				}
+				if len(kept) == len(refs) {
+					continue
+				}
+				obj.SetGroupVersionKind(gvk)
+				patch := client.MergeFrom(obj.DeepCopy())
+				obj.SetOwnerReferences(kept)
+				if err := m.client.Patch(ctx, obj, patch); err != nil {
+					return fmt.Errorf("failed to release %s %s/%s: %w", gvk.Kind, obj.Namespace, obj.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SaveRecord saves the metadata and spec of a cluster being undeleted in a ConfigMap next to
+// it, from which Restore recreates the cluster once the deleted one is gone
+func (m *Manager) SaveRecord(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	annotations := map[string]string{}
+	for key, value := range cluster.Annotations {
+		if key != UndeleteAnnotation {
+			annotations[key] = value
+		}
+	}
+	saved := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		TypeMeta: metav1.TypeMeta{APIVersion: k8splaygroundsv1alpha1.SchemeGroupVersion.String(), Kind: "K8sPlaygroundsCluster"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   cluster.Namespace,
+			Name:        cluster.Name,
+			Labels:      cluster.Labels,
+			Annotations: annotations,
+		},
+		Spec: cluster.Spec,
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	record := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: cluster.Namespace,
+			Name:      RecordName(cluster.Name),
+			Labels:    map[string]string{labeling.ClusterLabel: cluster.Name, labeling.ManagedByLabel: labeling.ManagedBy},
+		},
+		Data: map[string]string{recordKey: string(data)},
+	}
+	if err := m.client.Create(ctx, record); err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to save undelete record: %w", err)
+	}
+	return nil
+}
+
+// Restore returns the cluster saved for an undelete, or nil without a record. Once the
+// restored cluster is created, DeleteRecord removes the record.
+func (m *Manager) Restore(ctx context.Context, key types.NamespacedName) (*k8splaygroundsv1alpha1.K8sPlaygroundsCluster, error) {
+	record := &corev1.ConfigMap{}
+	if err := m.client.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: RecordName(key.Name)}, record); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	if err := json.Unmarshal([]byte(record.Data[recordKey]), cluster); err != nil {
+		return nil, fmt.Errorf("invalid undelete record %s: %w", record.Name, err)
+	}
+	if cluster.Name != key.Name || cluster.Namespace != key.Namespace {
+		return nil, fmt.Errorf("undelete record %s is for cluster %s/%s", record.Name, cluster.Namespace, cluster.Name)
+	}
+	return cluster, nil
+}
+
+// DeleteRecord removes the undelete record of a cluster
+func (m *Manager) DeleteRecord(ctx context.Context, key types.NamespacedName) error {
+	record := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: RecordName(key.Name)}}
+	return client.IgnoreNotFound(m.client.Delete(ctx, record))
+}
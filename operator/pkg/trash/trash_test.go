@@ -0,0 +1,204 @@
+package trash
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/labeling"
+)
+
+func newCluster() *k8splaygroundsv1alpha1.K8sPlaygroundsCluster {
+	deleted := metav1.NewTime(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC))
+	return &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "shop", Namespace: "playground", UID: "uid",
+			DeletionTimestamp: &deleted,
+			Annotations:       map[string]string{UndeleteAnnotation: "true", "team": "web"},
+		},
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{
+			Version:    "1.2.0",
+			SoftDelete: &k8splaygroundsv1alpha1.SoftDeleteSpec{Retention: metav1.Duration{Duration: time.Hour}},
+		},
+	}
+}
+
+// meta returns the metadata of a child of the cluster owned by it
+func meta(name string) metav1.ObjectMeta {
+	controller := true
+	return metav1.ObjectMeta{
+		Namespace: "playground",
+		Name:      name,
+		Labels:    map[string]string{labeling.ClusterLabel: "shop"},
+		OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: "k8s-playgrounds.io/v1alpha1", Kind: "K8sPlaygroundsCluster", Name: "shop", UID: "uid", Controller: &controller},
+			{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other"},
+		},
+	}
+}
+
+func TestRetention(t *testing.T) {
+	cluster := newCluster()
+	if got := PurgeAt(cluster); !got.Equal(cluster.DeletionTimestamp.Add(time.Hour)) {
+		t.Errorf("PurgeAt() = %s, want an hour after the deletion", got)
+	}
+	cluster.Spec.SoftDelete.Retention = metav1.Duration{}
+	if got := Retention(cluster); got != DefaultRetention {
+		t.Errorf("Retention() = %s, want the default", got)
+	}
+}
+
+func TestScaleDown(t *testing.T) {
+	three := int32(3)
+	unrelated := meta("unrelated")
+	unrelated.Labels = nil
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: meta("web"), Spec: appsv1.DeploymentSpec{Replicas: &three}},
+		&appsv1.StatefulSet{ObjectMeta: meta("db"), Spec: appsv1.StatefulSetSpec{Replicas: &three}},
+		&appsv1.DaemonSet{ObjectMeta: meta("agent")},
+		&batchv1.CronJob{ObjectMeta: meta("report")},
+		&appsv1.Deployment{ObjectMeta: unrelated, Spec: appsv1.DeploymentSpec{Replicas: &three}},
+	).Build()
+	m := NewManager(c)
+
+	stopped, err := m.ScaleDown(context.Background(), newCluster())
+	if err != nil {
+		t.Fatalf("ScaleDown() error = %v", err)
+	}
+	want := "[CronJob/playground/report DaemonSet/playground/agent Deployment/playground/web StatefulSet/playground/db]"
+	if fmt.Sprint(stopped) != want {
+		t.Errorf("ScaleDown() = %v, want %s", stopped, want)
+	}
+
+	deployment := &appsv1.Deployment{}
+	c.Get(context.Background(), types.NamespacedName{Namespace: "playground", Name: "web"}, deployment)
+	if *deployment.Spec.Replicas != 0 {
+		t.Errorf("replicas = %d, want 0", *deployment.Spec.Replicas)
+	}
+	daemonSet := &appsv1.DaemonSet{}
+	c.Get(context.Background(), types.NamespacedName{Namespace: "playground", Name: "agent"}, daemonSet)
+	if daemonSet.Spec.Template.Spec.NodeSelector[TrashedNodeLabel] != "true" {
+		t.Errorf("DaemonSet node selector = %v, want no node", daemonSet.Spec.Template.Spec.NodeSelector)
+	}
+	c.Get(context.Background(), types.NamespacedName{Namespace: "playground", Name: "unrelated"}, deployment)
+	if *deployment.Spec.Replicas != 3 {
+		t.Error("ScaleDown() stopped a workload of another cluster")
+	}
+
+	// Stopped workloads are left alone on the next reconcile
+	if stopped, _ := m.ScaleDown(context.Background(), newCluster()); len(stopped) != 0 {
+		t.Errorf("second ScaleDown() = %v, want nothing", stopped)
+	}
+}
+
+func TestScaleDownResume(t *testing.T) {
+	ctx := context.Background()
+	three, suspended := int32(3), true
+	agent := &appsv1.DaemonSet{ObjectMeta: meta("agent")}
+	agent.Spec.Template.Spec.NodeSelector = map[string]string{"disk": "ssd"}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: meta("web"), Spec: appsv1.DeploymentSpec{Replicas: &three}},
+		&appsv1.StatefulSet{ObjectMeta: meta("db")},
+		agent,
+		&batchv1.Job{ObjectMeta: meta("migrate")},
+		&batchv1.CronJob{ObjectMeta: meta("report")},
+		&batchv1.CronJob{ObjectMeta: meta("paused"), Spec: batchv1.CronJobSpec{Suspend: &suspended}},
+	).Build()
+	m := NewManager(c)
+
+	stopped, err := m.ScaleDown(ctx, newCluster())
+	if err != nil {
+		t.Fatalf("ScaleDown() error = %v", err)
+	}
+	// A workload deleted while the cluster is in the trash is skipped
+	if err := m.Resume(ctx, append(stopped, "Deployment/playground/gone")); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+
+	get := func(name string, obj client.Object) {
+		t.Helper()
+		if err := c.Get(ctx, types.NamespacedName{Namespace: "playground", Name: name}, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deployment := &appsv1.Deployment{}
+	get("web", deployment)
+	if *deployment.Spec.Replicas != 3 || deployment.Annotations[ReplicasAnnotation] != "" {
+		t.Errorf("Deployment replicas = %d with annotations %v, want 3 restored", *deployment.Spec.Replicas, deployment.Annotations)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	get("db", statefulSet)
+	if *statefulSet.Spec.Replicas != 1 {
+		t.Errorf("StatefulSet replicas = %d, want the default of 1 restored", *statefulSet.Spec.Replicas)
+	}
+	daemonSet := &appsv1.DaemonSet{}
+	get("agent", daemonSet)
+	if fmt.Sprint(daemonSet.Spec.Template.Spec.NodeSelector) != "map[disk:ssd]" {
+		t.Errorf("DaemonSet node selector = %v, want the original selector", daemonSet.Spec.Template.Spec.NodeSelector)
+	}
+	job := &batchv1.Job{}
+	get("migrate", job)
+	cronJob := &batchv1.CronJob{}
+	get("report", cronJob)
+	if *job.Spec.Suspend || *cronJob.Spec.Suspend {
+		t.Error("Resume() left the Job or CronJob suspended")
+	}
+	get("paused", cronJob)
+	if !*cronJob.Spec.Suspend {
+		t.Error("Resume() resumed a CronJob suspended before the cluster was deleted")
+	}
+}
+
+func TestUndeleteRoundTrip(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&corev1.Service{ObjectMeta: meta("web")},
+		&corev1.ConfigMap{ObjectMeta: meta("settings")},
+	).Build()
+	m := NewManager(c)
+	cluster := newCluster()
+	key := client.ObjectKeyFromObject(cluster)
+
+	if err := m.Release(context.Background(), cluster); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	service := &corev1.Service{}
+	c.Get(context.Background(), types.NamespacedName{Namespace: "playground", Name: "web"}, service)
+	if len(service.OwnerReferences) != 1 || service.OwnerReferences[0].UID != "other" {
+		t.Errorf("owner references = %v, want only the other owner", service.OwnerReferences)
+	}
+
+	if restored, err := m.Restore(context.Background(), key); restored != nil || err != nil {
+		t.Fatalf("Restore() without a record = %v, %v", restored, err)
+	}
+	if err := m.SaveRecord(context.Background(), cluster); err != nil {
+		t.Fatalf("SaveRecord() error = %v", err)
+	}
+	restored, err := m.Restore(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if restored.Spec.Version != "1.2.0" || restored.DeletionTimestamp != nil || restored.UID != "" {
+		t.Errorf("restored cluster = %+v, want the spec without the deleted object's identity", restored.ObjectMeta)
+	}
+	if UndeleteRequested(restored) || restored.Annotations["team"] != "web" {
+		t.Errorf("restored annotations = %v, want the undelete annotation dropped", restored.Annotations)
+	}
+
+	if err := m.DeleteRecord(context.Background(), key); err != nil {
+		t.Fatalf("DeleteRecord() error = %v", err)
+	}
+	if restored, _ := m.Restore(context.Background(), key); restored != nil {
+		t.Error("Restore() found a deleted record")
+	}
+}
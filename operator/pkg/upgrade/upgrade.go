@@ -0,0 +1,317 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// Upgrade phases
+const (
+	PhasePending   = "Pending"
+	PhaseUpgrading = "Upgrading"
+	PhaseCompleted = "Completed"
+	PhaseFailed    = "Failed"
+)
+
+// Phases of the upgrade of one gateway
+const (
+	StepPending   = "Pending"
+	StepVerifying = "Verifying"
+	StepDone      = "Done"
+	StepFailed    = "Failed"
+)
+
+const (
+	// VerifyTimeout is how long an upgraded gateway may take to report the new versions and
+	// bring its tunnels back up before the upgrade fails
+	VerifyTimeout = 15 * time.Minute
+	// PollInterval is how soon to look again while an upgrade waits or verifies
+	PollInterval = 30 * time.Second
+	// HASuffix is appended to the gateway name by the Aviatrix Controller to name the HA gateway
+	HASuffix = "-hagw"
+)
+
+// Cloud is the part of the Aviatrix Controller API upgrades use; *cloud.Manager implements it
+type Cloud interface {
+	GetGateway(ctx context.Context, gwName string) (*aviatrix.GatewayInfo, error)
+	GetTunnelStatus(ctx context.Context, gwName string) ([]map[string]interface{}, error)
+	UpgradeGateway(ctx context.Context, gwName, softwareVersion, imageVersion string) error
+}
+
+// Target is the gateway pair to upgrade and the versions to upgrade it to. Empty versions are
+// left as they are.
+type Target struct {
+	GwName          string
+	HAEnabled       bool
+	SoftwareVersion string
+	ImageVersion    string
+}
+
+// IsZero reports whether the target requests no upgrade
+func (t Target) IsZero() bool {
+	return t.SoftwareVersion == "" && t.ImageVersion == ""
+}
+
+// Key identifies the resource of an upgrade as kind/namespace/name
+func Key(kind string, obj metav1.Object) string {
+	return kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// Coordinator upgrades one gateway at a time across all gateway resources. A gateway waits in
+// Pending while another one upgrades.
+type Coordinator struct {
+	reader client.Reader
+	cloud  Cloud
+
+	mu     sync.Mutex
+	holder string
+}
+
+// NewCoordinator creates a new upgrade coordinator. The reader finds upgrades in progress that
+// a previous run of the operator started; nil only considers upgrades of this run.
+func NewCoordinator(reader client.Reader, cloud Cloud) *Coordinator {
+	return &Coordinator{
+		reader: reader,
+		cloud:  cloud,
+	}
+}
+
+// Advance moves the upgrade of a gateway pair forward and returns its new status and when to
+// look again; zero when nothing is left to do. The HA gateway is upgraded first while the
+// primary carries the traffic, and every gateway must have its tunnels back up before the next
+// one starts. Errors talking to the Aviatrix Controller leave the status as it was.
+func (c *Coordinator) Advance(ctx context.Context, key string, target Target, status *aviatrixv1alpha1.GatewayUpgradeStatus, now time.Time) (*aviatrixv1alpha1.GatewayUpgradeStatus, time.Duration, error) {
+	if target.IsZero() {
+		c.Release(key)
+		return status, 0, nil
+	}
+	if status == nil || status.SoftwareVersion != target.SoftwareVersion || status.ImageVersion != target.ImageVersion {
+		status = plan(target)
+	} else {
+		copied := *status
+		copied.Steps = append([]aviatrixv1alpha1.GatewayUpgradeStep(nil), status.Steps...)
+		status = &copied
+	}
+
+	switch status.Phase {
+	case PhaseCompleted, PhaseFailed:
+		c.Release(key)
+		return status, 0, nil
+	case PhasePending:
+		holder, err := c.Acquire(ctx, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if holder != key {
+			status.Message = fmt.Sprintf("Waiting for %s to finish upgrading", holder)
+			return status, PollInterval, nil
+		}
+		status.Phase = PhaseUpgrading
+		status.StartedAt = &metav1.Time{Time: now}
+		status.Message = ""
+	}
+
+	for i := range status.Steps {
+		step := &status.Steps[i]
+		if step.Phase == StepDone {
+			continue
+		}
+		done, err := c.advanceStep(ctx, target, status, i, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		if step.Phase == StepFailed {
+			status.Phase = PhaseFailed
+			status.Message = fmt.Sprintf("Upgrade of gateway %s failed: %s", step.GwName, step.Message)
+			status.CompletedAt = &metav1.Time{Time: now}
+			c.Release(key)
+			return status, 0, nil
+		}
+		if !done {
+			return status, PollInterval, nil
+		}
+	}
+
+	status.Phase = PhaseCompleted
+	status.Message = fmt.Sprintf("Upgraded %d gateway(s)", len(status.Steps))
+	status.CompletedAt = &metav1.Time{Time: now}
+	c.Release(key)
+	return status, 0, nil
+}
+
+// advanceStep upgrades or verifies the gateway of step i and reports whether it is done
+func (c *Coordinator) advanceStep(ctx context.Context, target Target, status *aviatrixv1alpha1.GatewayUpgradeStatus, i int, now time.Time) (bool, error) {
+	step := &status.Steps[i]
+
+	info, err := c.cloud.GetGateway(ctx, step.GwName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get gateway %s: %w", step.GwName, err)
+	}
+	tunnels, err := c.cloud.GetTunnelStatus(ctx, step.GwName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get tunnel status of gateway %s: %w", step.GwName, err)
+	}
+	up, total := countTunnels(tunnels)
+
+	switch step.Phase {
+	case StepPending:
+		if atTarget(info, target) {
+			step.Phase = StepDone
+			step.CompletedAt = &metav1.Time{Time: now}
+			step.Message = "Already at the target versions"
+			return true, nil
+		}
+
+		// Traffic fails over to the peer while the gateway upgrades, so its tunnels must be up
+		if peer := peerOf(status, i); peer != "" {
+			peerTunnels, err := c.cloud.GetTunnelStatus(ctx, peer)
+			if err != nil {
+				return false, fmt.Errorf("failed to get tunnel status of gateway %s: %w", peer, err)
+			}
+			if peerUp, peerTotal := countTunnels(peerTunnels); peerUp < peerTotal {
+				step.Message = fmt.Sprintf("Waiting for the tunnels of %s to come up: %d of %d up", peer, peerUp, peerTotal)
+				return false, nil
+			}
+		}
+
+		step.FromSoftwareVersion = info.SoftwareVersion
+		step.FromImageVersion = info.ImageVersion
+		step.TunnelsUp = int32(up)
+		step.StartedAt = &metav1.Time{Time: now}
+		if err := c.cloud.UpgradeGateway(ctx, step.GwName, target.SoftwareVersion, target.ImageVersion); err != nil {
+			step.Phase = StepFailed
+			step.CompletedAt = &metav1.Time{Time: now}
+			step.Message = err.Error()
+			return false, nil
+		}
+		step.Phase = StepVerifying
+		step.Message = fmt.Sprintf("Upgrade requested with %d of %d tunnels up", up, total)
+		return false, nil
+
+	case StepVerifying:
+		if atTarget(info, target) && int32(up) >= step.TunnelsUp {
+			step.Phase = StepDone
+			step.CompletedAt = &metav1.Time{Time: now}
+			step.Message = fmt.Sprintf("Upgraded with %d of %d tunnels up", up, total)
+			return true, nil
+		}
+		step.Message = fmt.Sprintf("Verifying: software %s, image %s, %d of %d tunnels up, %d expected",
+			info.SoftwareVersion, info.ImageVersion, up, total, step.TunnelsUp)
+		if step.StartedAt != nil && now.Sub(step.StartedAt.Time) > VerifyTimeout {
+			step.Phase = StepFailed
+			step.CompletedAt = &metav1.Time{Time: now}
+			step.Message = fmt.Sprintf("Not verified within %s: %s", VerifyTimeout, step.Message)
+		}
+		return false, nil
+	}
+	return step.Phase == StepDone, nil
+}
+
+// Acquire lets the resource of key upgrade unless another one does, and returns the key of the
+// resource holding the upgrade
+func (c *Coordinator) Acquire(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.holder != "" {
+		return c.holder, nil
+	}
+	if c.reader != nil {
+		holder, err := c.upgrading(ctx, key)
+		if err != nil {
+			return "", err
+		}
+		if holder != "" {
+			return holder, nil
+		}
+	}
+	c.holder = key
+	return key, nil
+}
+
+// Release ends the upgrade of the resource of key, if it holds it
+func (c *Coordinator) Release(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.holder == key {
+		c.holder = ""
+	}
+}
+
+// upgrading returns the key of another gateway resource whose status says it is upgrading
+func (c *Coordinator) upgrading(ctx context.Context, key string) (string, error) {
+	gateways := &aviatrixv1alpha1.AviatrixGatewayList{}
+	if err := c.reader.List(ctx, gateways); err != nil {
+		return "", fmt.Errorf("failed to list gateways: %w", err)
+	}
+	for i := range gateways.Items {
+		gateway := &gateways.Items[i]
+		if other := Key("AviatrixGateway", gateway); other != key && upgradingStatus(gateway.Status.Upgrade) {
+			return other, nil
+		}
+	}
+
+	spokes := &aviatrixv1alpha1.AviatrixSpokeGatewayList{}
+	if err := c.reader.List(ctx, spokes); err != nil {
+		return "", fmt.Errorf("failed to list spoke gateways: %w", err)
+	}
+	for i := range spokes.Items {
+		spoke := &spokes.Items[i]
+		if other := Key("AviatrixSpokeGateway", spoke); other != key && upgradingStatus(spoke.Status.Upgrade) {
+			return other, nil
+		}
+	}
+	return "", nil
+}
+
+// plan returns a pending upgrade of the target, the HA gateway first
+func plan(target Target) *aviatrixv1alpha1.GatewayUpgradeStatus {
+	status := &aviatrixv1alpha1.GatewayUpgradeStatus{
+		Phase:           PhasePending,
+		SoftwareVersion: target.SoftwareVersion,
+		ImageVersion:    target.ImageVersion,
+	}
+	if target.HAEnabled {
+		status.Steps = append(status.Steps, aviatrixv1alpha1.GatewayUpgradeStep{GwName: target.GwName + HASuffix, Phase: StepPending})
+	}
+	status.Steps = append(status.Steps, aviatrixv1alpha1.GatewayUpgradeStep{GwName: target.GwName, Phase: StepPending})
+	return status
+}
+
+// peerOf returns the other gateway of the pair of step i, or "" without HA
+func peerOf(status *aviatrixv1alpha1.GatewayUpgradeStatus, i int) string {
+	if len(status.Steps) != 2 {
+		return ""
+	}
+	return status.Steps[1-i].GwName
+}
+
+// atTarget reports whether the gateway runs the target versions
+func atTarget(info *aviatrix.GatewayInfo, target Target) bool {
+	return (target.SoftwareVersion == "" || info.SoftwareVersion == target.SoftwareVersion) &&
+		(target.ImageVersion == "" || info.ImageVersion == target.ImageVersion)
+}
+
+// countTunnels returns how many of the tunnels are up
+func countTunnels(tunnels []map[string]interface{}) (up, total int) {
+	for _, tunnel := range tunnels {
+		if state, _ := tunnel["status"].(string); strings.EqualFold(state, "up") {
+			up++
+		}
+	}
+	return up, len(tunnels)
+}
+
+func upgradingStatus(status *aviatrixv1alpha1.GatewayUpgradeStatus) bool {
+	return status != nil && status.Phase == PhaseUpgrading
+}
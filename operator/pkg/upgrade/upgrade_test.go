@@ -0,0 +1,196 @@
+package upgrade
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/aviatrix"
+)
+
+// fakeCloud serves gateways with their versions and tunnels. An upgraded gateway reports no
+// versions once while it restarts, and the new ones after that.
+type fakeCloud struct {
+	versions map[string][2]string
+	tunnels  map[string][]string
+	pending  map[string][2]string
+	upgraded []string
+	fail     error
+}
+
+func newFakeCloud() *fakeCloud {
+	return &fakeCloud{
+		versions: map[string][2]string{"gw": {"7.0", "img-1"}, "gw-hagw": {"7.0", "img-1"}},
+		tunnels:  map[string][]string{"gw": {"up", "up"}, "gw-hagw": {"up", "up"}},
+		pending:  map[string][2]string{},
+	}
+}
+
+func (f *fakeCloud) GetGateway(ctx context.Context, gwName string) (*aviatrix.GatewayInfo, error) {
+	if versions, ok := f.pending[gwName]; ok {
+		f.versions[gwName] = versions
+		delete(f.pending, gwName)
+		return &aviatrix.GatewayInfo{GwName: gwName}, nil
+	}
+	versions, ok := f.versions[gwName]
+	if !ok {
+		return nil, fmt.Errorf("gateway %s not found", gwName)
+	}
+	return &aviatrix.GatewayInfo{GwName: gwName, SoftwareVersion: versions[0], ImageVersion: versions[1]}, nil
+}
+
+func (f *fakeCloud) GetTunnelStatus(ctx context.Context, gwName string) ([]map[string]interface{}, error) {
+	var tunnels []map[string]interface{}
+	for i, state := range f.tunnels[gwName] {
+		tunnels = append(tunnels, map[string]interface{}{"peer_name": fmt.Sprintf("peer-%d", i), "status": state})
+	}
+	return tunnels, nil
+}
+
+func (f *fakeCloud) UpgradeGateway(ctx context.Context, gwName, softwareVersion, imageVersion string) error {
+	if f.fail != nil {
+		return f.fail
+	}
+	f.upgraded = append(f.upgraded, gwName)
+	f.pending[gwName] = [2]string{softwareVersion, imageVersion}
+	return nil
+}
+
+func TestAdvanceUpgradesHAGatewayFirst(t *testing.T) {
+	cloud := newFakeCloud()
+	coordinator := NewCoordinator(nil, cloud)
+	target := Target{GwName: "gw", HAEnabled: true, SoftwareVersion: "7.1", ImageVersion: "img-2"}
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var status *aviatrixv1alpha1.GatewayUpgradeStatus
+	for i := 0; i < 10; i++ {
+		var err error
+		var requeue time.Duration
+		status, requeue, err = coordinator.Advance(context.Background(), "AviatrixGateway/ns/gw", target, status, now)
+		if err != nil {
+			t.Fatalf("Advance() error = %v", err)
+		}
+		if requeue == 0 {
+			break
+		}
+		now = now.Add(requeue)
+	}
+
+	if status.Phase != PhaseCompleted {
+		t.Fatalf("phase = %s (%s), want %s", status.Phase, status.Message, PhaseCompleted)
+	}
+	if len(cloud.upgraded) != 2 || cloud.upgraded[0] != "gw-hagw" || cloud.upgraded[1] != "gw" {
+		t.Errorf("upgraded = %v, want the HA gateway first", cloud.upgraded)
+	}
+	for _, step := range status.Steps {
+		if step.Phase != StepDone || step.FromSoftwareVersion != "7.0" || step.TunnelsUp != 2 {
+			t.Errorf("step = %+v, want done from 7.0 with 2 tunnels up", step)
+		}
+	}
+}
+
+func TestAdvanceWaitsForPeerTunnels(t *testing.T) {
+	cloud := newFakeCloud()
+	cloud.tunnels["gw"] = []string{"up", "down"}
+	coordinator := NewCoordinator(nil, cloud)
+	target := Target{GwName: "gw", HAEnabled: true, SoftwareVersion: "7.1"}
+
+	status, requeue, err := coordinator.Advance(context.Background(), "AviatrixGateway/ns/gw", target, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if status.Phase != PhaseUpgrading || status.Steps[0].Phase != StepPending || requeue != PollInterval {
+		t.Errorf("status = %+v, requeue = %s, want the HA gateway held", status, requeue)
+	}
+	if len(cloud.upgraded) != 0 {
+		t.Errorf("upgraded = %v while the primary has a tunnel down", cloud.upgraded)
+	}
+}
+
+func TestAdvanceFailsWhenTunnelsStayDown(t *testing.T) {
+	cloud := newFakeCloud()
+	coordinator := NewCoordinator(nil, cloud)
+	key := "AviatrixGateway/ns/gw"
+	target := Target{GwName: "gw", SoftwareVersion: "7.1"}
+	now := time.Now()
+
+	status, _, err := coordinator.Advance(context.Background(), key, target, nil, now)
+	if err != nil || status.Steps[0].Phase != StepVerifying {
+		t.Fatalf("Advance() = %+v, %v, want the gateway verifying", status, err)
+	}
+
+	cloud.tunnels["gw"] = []string{"up", "down"}
+	status, requeue, err := coordinator.Advance(context.Background(), key, target, status, now.Add(VerifyTimeout+time.Minute))
+	if err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if status.Phase != PhaseFailed || requeue != 0 {
+		t.Errorf("status = %+v, requeue = %s, want the upgrade failed", status, requeue)
+	}
+
+	// The failure releases the upgrade to the next gateway
+	if holder, _ := coordinator.Acquire(context.Background(), "AviatrixSpokeGateway/ns/spoke"); holder != "AviatrixSpokeGateway/ns/spoke" {
+		t.Errorf("holder = %s after the failed upgrade, want the next gateway", holder)
+	}
+}
+
+func TestAdvanceFailsWhenControllerRejectsUpgrade(t *testing.T) {
+	cloud := newFakeCloud()
+	cloud.fail = fmt.Errorf("version 9.9 not available")
+	coordinator := NewCoordinator(nil, cloud)
+
+	status, _, err := coordinator.Advance(context.Background(), "AviatrixGateway/ns/gw", Target{GwName: "gw", SoftwareVersion: "9.9"}, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if status.Phase != PhaseFailed || status.Steps[0].Message != "version 9.9 not available" {
+		t.Errorf("status = %+v, want the upgrade failed with the controller's reason", status)
+	}
+}
+
+func TestAdvanceUpgradesOneGatewayAtATime(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := aviatrixv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	// A previous run of the operator left a spoke upgrading
+	spoke := &aviatrixv1alpha1.AviatrixSpokeGateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "spoke", Namespace: "ns"},
+		Status: aviatrixv1alpha1.AviatrixSpokeGatewayStatus{
+			Upgrade: &aviatrixv1alpha1.GatewayUpgradeStatus{Phase: PhaseUpgrading},
+		},
+	}
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(spoke).WithStatusSubresource(spoke).Build()
+	cloud := newFakeCloud()
+	coordinator := NewCoordinator(reader, cloud)
+
+	status, requeue, err := coordinator.Advance(context.Background(), "AviatrixGateway/ns/gw", Target{GwName: "gw", SoftwareVersion: "7.1"}, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if status.Phase != PhasePending || requeue != PollInterval || status.Message != "Waiting for AviatrixSpokeGateway/ns/spoke to finish upgrading" {
+		t.Errorf("status = %+v, requeue = %s, want the gateway waiting for the spoke", status, requeue)
+	}
+	if len(cloud.upgraded) != 0 {
+		t.Errorf("upgraded = %v while another gateway upgrades", cloud.upgraded)
+	}
+}
+
+func TestAdvanceSkipsGatewaysAtTarget(t *testing.T) {
+	cloud := newFakeCloud()
+	coordinator := NewCoordinator(nil, cloud)
+
+	status, requeue, err := coordinator.Advance(context.Background(), "AviatrixGateway/ns/gw", Target{GwName: "gw", HAEnabled: true, SoftwareVersion: "7.0"}, nil, time.Now())
+	if err != nil {
+		t.Fatalf("Advance() error = %v", err)
+	}
+	if status.Phase != PhaseCompleted || requeue != 0 || len(cloud.upgraded) != 0 {
+		t.Errorf("status = %+v, upgraded = %v, want completed without upgrades", status, cloud.upgraded)
+	}
+}
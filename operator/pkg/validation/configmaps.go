@@ -0,0 +1,201 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/yaml"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// ConfigMap data formats that can be validated
+const (
+	FormatJSON       = "json"
+	FormatYAML       = "yaml"
+	FormatProperties = "properties"
+)
+
+// templateData is the data ConfigMap templates are rendered with
+type templateData struct {
+	Cluster   string
+	Namespace string
+	Variables map[string]string
+}
+
+// RenderConfigMaps expands the data templates of every templated ConfigMap in the cluster spec
+// and then checks each key that declares a format. Rendered values replace the templates in the
+// spec. All problems are returned together so users can fix them in a single pass.
+func RenderConfigMaps(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	data := templateData{
+		Cluster:   cluster.Name,
+		Namespace: cluster.Namespace,
+		Variables: cluster.Spec.Variables,
+	}
+
+	for i := range cluster.Spec.ConfigMaps {
+		configMap := &cluster.Spec.ConfigMaps[i]
+		path := specPath.Child("configMaps").Key(configMap.Name)
+
+		if configMap.Template {
+			errs = append(errs, renderData(configMap.Data, data, path.Child("data"))...)
+		}
+		errs = append(errs, ValidateConfigMapData(configMap, path)...)
+	}
+
+	return errs.ToAggregate()
+}
+
+// renderData renders each value of data as a template. Referencing an undefined variable is an
+// error rather than an empty string, so typos surface before pods start.
+func renderData(values map[string]string, data templateData, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, key := range sortedKeys(values) {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(values[key])
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Key(key), field.OmitValueType{}, fmt.Sprintf("invalid template: %v", err)))
+			continue
+		}
+		var out bytes.Buffer
+		if err := tmpl.Execute(&out, data); err != nil {
+			errs = append(errs, field.Invalid(path.Key(key), field.OmitValueType{}, fmt.Sprintf("failed to render template: %v", err)))
+			continue
+		}
+		values[key] = out.String()
+	}
+	return errs
+}
+
+// ValidateConfigMapData checks every data key that declares a validation against its format
+// and, for JSON and YAML, its optional JSON schema
+func ValidateConfigMapData(configMap *k8splaygroundsv1alpha1.ConfigMapSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for _, key := range sortedKeys(configMap.Validation) {
+		rule := configMap.Validation[key]
+		rulePath := path.Child("validation").Key(key)
+		keyPath := path.Child("data").Key(key)
+
+		value, ok := configMap.Data[key]
+		if !ok {
+			errs = append(errs, field.NotFound(keyPath, key))
+			continue
+		}
+
+		var doc interface{}
+		switch rule.Format {
+		case FormatJSON:
+			if err := json.Unmarshal([]byte(value), &doc); err != nil {
+				errs = append(errs, field.Invalid(keyPath, field.OmitValueType{}, fmt.Sprintf("invalid JSON: %v", err)))
+				continue
+			}
+		case FormatYAML:
+			if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+				errs = append(errs, field.Invalid(keyPath, field.OmitValueType{}, fmt.Sprintf("invalid YAML: %v", err)))
+				continue
+			}
+		case FormatProperties:
+			if rule.Schema != "" {
+				errs = append(errs, field.Invalid(rulePath.Child("schema"), field.OmitValueType{}, "schemas are only supported for json and yaml"))
+			}
+			errs = append(errs, validateProperties(value, keyPath)...)
+			continue
+		default:
+			errs = append(errs, field.NotSupported(rulePath.Child("format"), rule.Format, []string{FormatJSON, FormatYAML, FormatProperties}))
+			continue
+		}
+
+		if rule.Schema != "" {
+			errs = append(errs, validateSchema(doc, rule.Schema, keyPath, rulePath.Child("schema"))...)
+		}
+	}
+	return errs
+}
+
+// validateSchema checks doc against a JSON schema
+func validateSchema(doc interface{}, schemaJSON string, keyPath, schemaPath *field.Path) field.ErrorList {
+	schema, err := ParseSchema([]byte(schemaJSON))
+	if err != nil {
+		return field.ErrorList{field.Invalid(schemaPath, field.OmitValueType{}, fmt.Sprintf("invalid JSON schema: %v", err))}
+	}
+
+	var errs field.ErrorList
+	for _, problem := range schema.Validate(doc) {
+		errs = append(errs, field.Invalid(keyPath, field.OmitValueType{}, fmt.Sprintf("does not match schema: %s", problem)))
+	}
+	return errs
+}
+
+// validateProperties checks Java properties syntax: every entry needs a key, keys must be unique
+// and the last line must not end in a continuation
+func validateProperties(value string, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	seen := make(map[string]int)
+
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	lineNumber := 0
+	var logical strings.Builder
+	start := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimLeft(scanner.Text(), " \t\f")
+
+		if logical.Len() == 0 {
+			if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+				continue
+			}
+			start = lineNumber
+		}
+
+		// An odd number of trailing backslashes continues the entry on the next line
+		trailing := len(line) - len(strings.TrimRight(line, `\`))
+		if trailing%2 == 1 {
+			logical.WriteString(line[:len(line)-1])
+			continue
+		}
+		logical.WriteString(line)
+
+		key := propertyKey(logical.String())
+		logical.Reset()
+		if key == "" {
+			errs = append(errs, field.Invalid(path, field.OmitValueType{}, fmt.Sprintf("line %d: entry has no key", start)))
+			continue
+		}
+		if previous, ok := seen[key]; ok {
+			errs = append(errs, field.Invalid(path, field.OmitValueType{}, fmt.Sprintf("line %d: duplicate key %q, first set on line %d", start, key, previous)))
+			continue
+		}
+		seen[key] = start
+	}
+	if logical.Len() > 0 {
+		errs = append(errs, field.Invalid(path, field.OmitValueType{}, fmt.Sprintf("line %d: continuation at end of input", start)))
+	}
+	return errs
+}
+
+// propertyKey returns the key of a properties entry, which ends at the first unescaped '=', ':'
+// or whitespace
+func propertyKey(entry string) string {
+	var key strings.Builder
+	for i := 0; i < len(entry); i++ {
+		c := entry[i]
+		if c == '\\' && i+1 < len(entry) {
+			i++
+			key.WriteByte(entry[i])
+			continue
+		}
+		if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+			break
+		}
+		key.WriteByte(c)
+	}
+	return key.String()
+}
@@ -0,0 +1,87 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestRenderConfigMapsSubstitutesVariables(t *testing.T) {
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	cluster.Name = "demo"
+	cluster.Namespace = "playground"
+	cluster.Spec.Variables = map[string]string{"replicas": "3"}
+	cluster.Spec.ConfigMaps = []k8splaygroundsv1alpha1.ConfigMapSpec{{
+		Name:     "app",
+		Template: true,
+		Data: map[string]string{
+			"config.json": `{"cluster": "{{ .Cluster }}.{{ .Namespace }}", "replicas": {{ .Variables.replicas }}}`,
+		},
+		Validation: map[string]k8splaygroundsv1alpha1.ConfigMapKeyValidation{
+			"config.json": {Format: FormatJSON, Schema: `{"type": "object", "required": ["replicas"], "properties": {"replicas": {"type": "integer", "minimum": 1}}}`},
+		},
+	}}
+
+	if err := RenderConfigMaps(cluster); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := cluster.Spec.ConfigMaps[0].Data["config.json"], `{"cluster": "demo.playground", "replicas": 3}`; got != want {
+		t.Errorf("rendered data = %s, want %s", got, want)
+	}
+}
+
+func TestRenderConfigMapsRejectsUndefinedVariables(t *testing.T) {
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}
+	cluster.Spec.ConfigMaps = []k8splaygroundsv1alpha1.ConfigMapSpec{{
+		Name:     "app",
+		Template: true,
+		Data:     map[string]string{"url": "http://{{ .Variables.host }}"},
+	}}
+
+	err := RenderConfigMaps(cluster)
+	if err == nil || !strings.Contains(err.Error(), "spec.configMaps[app].data[url]") {
+		t.Errorf("expected render error for undefined variable, got %v", err)
+	}
+}
+
+func TestValidateConfigMapData(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		schema string
+		value  string
+		valid  bool
+	}{
+		{"valid json", FormatJSON, "", `{"a": 1}`, true},
+		{"broken json", FormatJSON, "", `{"a": 1`, false},
+		{"valid yaml", FormatYAML, "", "a: 1\nb: [x, y]\n", true},
+		{"broken yaml", FormatYAML, "", "a: [1\n", false},
+		{"yaml matches schema", FormatYAML, `{"properties": {"level": {"enum": ["debug", "info"]}}}`, "level: info\n", true},
+		{"yaml violates enum", FormatYAML, `{"properties": {"level": {"enum": ["debug", "info"]}}}`, "level: trace\n", false},
+		{"wrong type", FormatJSON, `{"type": "array", "items": {"type": "string"}}`, `["a", 1]`, false},
+		{"additional property", FormatJSON, `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, false},
+		{"pattern", FormatJSON, `{"type": "string", "pattern": "^v[0-9]+$"}`, `"v1"`, true},
+		{"invalid schema", FormatJSON, `{"type": "text"}`, `"v1"`, false},
+		{"valid properties", FormatProperties, "", "# comment\nkey=value\nother : value \\\n  continued\n", true},
+		{"duplicate property", FormatProperties, "", "key=1\nkey=2\n", false},
+		{"property without key", FormatProperties, "", "=value\n", false},
+		{"dangling continuation", FormatProperties, "", "key=value\\\n", false},
+		{"properties with schema", FormatProperties, `{}`, "key=value\n", false},
+		{"unknown format", "toml", "", "a = 1", false},
+	}
+
+	for _, tt := range tests {
+		configMap := &k8splaygroundsv1alpha1.ConfigMapSpec{
+			Name: "app",
+			Data: map[string]string{"data": tt.value},
+			Validation: map[string]k8splaygroundsv1alpha1.ConfigMapKeyValidation{
+				"data": {Format: tt.format, Schema: tt.schema},
+			},
+		}
+		errs := ValidateConfigMapData(configMap, nil)
+		if valid := len(errs) == 0; valid != tt.valid {
+			t.Errorf("%s: valid = %v, want %v (errors: %v)", tt.name, valid, tt.valid, errs)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// standardResources are the unqualified resource names accepted in limits and requests
+var standardResources = map[string]bool{
+	"cpu":               true,
+	"memory":            true,
+	"storage":           true,
+	"ephemeral-storage": true,
+	"pods":              true,
+}
+
+// NormalizeClusterResources validates every resource quantity in the cluster spec and rewrites
+// valid quantities in their canonical form. All problems are returned together so users can fix
+// them in a single pass.
+func NormalizeClusterResources(spec *k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec) error {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	for i := range spec.StatefulSets {
+		path := specPath.Child("statefulSets").Key(spec.StatefulSets[i].Name)
+		errs = append(errs, normalizePodTemplate(&spec.StatefulSets[i].Template, path.Child("template"))...)
+		for j := range spec.StatefulSets[i].VolumeClaimTemplates {
+			claim := &spec.StatefulSets[i].VolumeClaimTemplates[j]
+			claimPath := path.Child("volumeClaimTemplates").Index(j).Child("spec", "resources")
+			errs = append(errs, NormalizeResourceRequirements(&claim.Spec.Resources, claimPath)...)
+		}
+	}
+	for i := range spec.Deployments {
+		path := specPath.Child("deployments").Key(spec.Deployments[i].Name).Child("template")
+		errs = append(errs, normalizePodTemplate(&spec.Deployments[i].Template, path)...)
+	}
+	for i := range spec.Jobs {
+		path := specPath.Child("jobs").Key(spec.Jobs[i].Name).Child("template")
+		errs = append(errs, normalizePodTemplate(&spec.Jobs[i].Template, path)...)
+	}
+	for i := range spec.CronJobs {
+		path := specPath.Child("cronJobs").Key(spec.CronJobs[i].Name).Child("jobTemplate", "template")
+		errs = append(errs, normalizePodTemplate(&spec.CronJobs[i].JobTemplate.Template, path)...)
+	}
+	for i := range spec.DaemonSets {
+		path := specPath.Child("daemonSets").Key(spec.DaemonSets[i].Name).Child("template")
+		errs = append(errs, normalizePodTemplate(&spec.DaemonSets[i].Template, path)...)
+	}
+	for i := range spec.ReplicaSets {
+		path := specPath.Child("replicaSets").Key(spec.ReplicaSets[i].Name).Child("template")
+		errs = append(errs, normalizePodTemplate(&spec.ReplicaSets[i].Template, path)...)
+	}
+
+	return errs.ToAggregate()
+}
+
+// normalizePodTemplate normalizes the resources of every container in a pod template
+func normalizePodTemplate(template *k8splaygroundsv1alpha1.PodTemplateSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for i := range template.Spec.Containers {
+		container := &template.Spec.Containers[i]
+		if container.Resources == nil {
+			continue
+		}
+		containerPath := path.Child("spec", "containers").Key(container.Name).Child("resources")
+		errs = append(errs, NormalizeResourceRequirements(container.Resources, containerPath)...)
+	}
+	return errs
+}
+
+// NormalizeResourceRequirements parses the limits and requests of a single requirements block,
+// rewrites them in canonical form and checks that no request exceeds its limit
+func NormalizeResourceRequirements(req *k8splaygroundsv1alpha1.ResourceRequirements, path *field.Path) field.ErrorList {
+	limits, errs := normalizeResourceList(req.Limits, path.Child("limits"))
+	requests, requestErrs := normalizeResourceList(req.Requests, path.Child("requests"))
+	errs = append(errs, requestErrs...)
+
+	for _, name := range sortedKeys(requests) {
+		limit, ok := limits[name]
+		if !ok {
+			continue
+		}
+		request := requests[name]
+		if request.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(path.Child("requests").Key(name), req.Requests[name],
+				fmt.Sprintf("must be less than or equal to the %s limit of %s", name, limit.String())))
+		}
+	}
+
+	if len(errs) == 0 {
+		for name, q := range limits {
+			req.Limits[name] = q.String()
+		}
+		for name, q := range requests {
+			req.Requests[name] = q.String()
+		}
+	}
+	return errs
+}
+
+// normalizeResourceList parses each quantity in a limits or requests map
+func normalizeResourceList(list map[string]string, path *field.Path) (map[string]resource.Quantity, field.ErrorList) {
+	var errs field.ErrorList
+	parsed := make(map[string]resource.Quantity, len(list))
+
+	for _, name := range sortedKeys(list) {
+		value := list[name]
+		if !isValidResourceName(name) {
+			errs = append(errs, field.NotSupported(path.Key(name), name, []string{"cpu", "memory", "storage", "ephemeral-storage", "hugepages-<size>", "<domain>/<name>"}))
+			continue
+		}
+
+		q, err := resource.ParseQuantity(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, field.Invalid(path.Key(name), value, quantityHint(name)))
+			continue
+		}
+		if q.Sign() < 0 {
+			errs = append(errs, field.Invalid(path.Key(name), value, "must not be negative"))
+			continue
+		}
+		parsed[name] = q
+	}
+	return parsed, errs
+}
+
+// isValidResourceName reports whether name is a standard, hugepages or domain-qualified resource
+func isValidResourceName(name string) bool {
+	if standardResources[name] {
+		return true
+	}
+	if strings.HasPrefix(name, "hugepages-") {
+		_, err := resource.ParseQuantity(strings.TrimPrefix(name, "hugepages-"))
+		return err == nil
+	}
+	return strings.Contains(name, "/")
+}
+
+// quantityHint returns an actionable message for an unparsable quantity
+func quantityHint(name string) string {
+	switch name {
+	case "cpu":
+		return "must be a quantity such as \"500m\", \"1\" or \"2.5\" (no spaces)"
+	case "memory", "storage", "ephemeral-storage":
+		return "must be a quantity such as \"128Mi\", \"1Gi\" or \"500M\" (suffixes are case-sensitive, no spaces)"
+	default:
+		return "must be a valid Kubernetes quantity such as \"1\", \"100m\" or \"1Gi\""
+	}
+}
+
+// sortedKeys returns the keys of a map in sorted order so errors are reported deterministically
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
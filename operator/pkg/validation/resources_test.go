@@ -0,0 +1,94 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestNormalizeResourceRequirements(t *testing.T) {
+	cases := map[string]struct {
+		limits, requests map[string]string
+		wantErr          string
+		wantLimits       map[string]string
+		wantRequests     map[string]string
+	}{
+		"canonical form": {
+			limits:       map[string]string{"cpu": "1000m", "memory": " 1024Mi "},
+			requests:     map[string]string{"cpu": "0.5", "hugepages-2Mi": "4Mi", "example.com/gpu": "1"},
+			wantLimits:   map[string]string{"cpu": "1", "memory": "1Gi"},
+			wantRequests: map[string]string{"cpu": "500m", "hugepages-2Mi": "4Mi", "example.com/gpu": "1"},
+		},
+		"unparsable":         {limits: map[string]string{"memory": "1 GB"}, wantErr: "spec.resources.limits[memory]"},
+		"negative":           {requests: map[string]string{"cpu": "-1"}, wantErr: "must not be negative"},
+		"unknown resource":   {requests: map[string]string{"gpu": "1"}, wantErr: "spec.resources.requests[gpu]"},
+		"bad hugepages":      {requests: map[string]string{"hugepages-big": "1"}, wantErr: "hugepages-big"},
+		"request over limit": {limits: map[string]string{"cpu": "500m"}, requests: map[string]string{"cpu": "1"}, wantErr: "less than or equal to the cpu limit"},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := &k8splaygroundsv1alpha1.ResourceRequirements{Limits: tc.limits, Requests: tc.requests}
+			errs := NormalizeResourceRequirements(req, field.NewPath("spec", "resources"))
+			if tc.wantErr != "" {
+				if err := errs.ToAggregate(); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("NormalizeResourceRequirements() = %v, want an error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if len(errs) > 0 {
+				t.Fatalf("NormalizeResourceRequirements() = %v", errs)
+			}
+			for name, want := range tc.wantLimits {
+				if got := req.Limits[name]; got != want {
+					t.Errorf("limit %s = %q, want %q", name, got, want)
+				}
+			}
+			for name, want := range tc.wantRequests {
+				if got := req.Requests[name]; got != want {
+					t.Errorf("request %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeClusterResourcesReportsEveryProblem(t *testing.T) {
+	spec := &k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{}
+	spec.Deployments = []k8splaygroundsv1alpha1.DeploymentSpec{{Name: "web"}}
+	spec.Deployments[0].Template.Spec.Containers = []k8splaygroundsv1alpha1.ContainerSpec{{
+		Name:      "app",
+		Resources: &k8splaygroundsv1alpha1.ResourceRequirements{Limits: map[string]string{"cpu": "lots"}},
+	}}
+	spec.StatefulSets = []k8splaygroundsv1alpha1.StatefulSetSpec{{Name: "db"}}
+	spec.StatefulSets[0].VolumeClaimTemplates = []k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate{{}}
+	spec.StatefulSets[0].VolumeClaimTemplates[0].Spec.Resources.Requests = map[string]string{"storage": "10gi"}
+
+	err := NormalizeClusterResources(spec)
+	if err == nil {
+		t.Fatal("NormalizeClusterResources() = nil, want errors")
+	}
+	for _, path := range []string{
+		"spec.deployments[web].template.spec.containers[app].resources.limits[cpu]",
+		"spec.statefulSets[db].volumeClaimTemplates[0].spec.resources.requests[storage]",
+	} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("NormalizeClusterResources() = %v, want an error for %s", err, path)
+		}
+	}
+}
+
+func TestNormalizeClusterResourcesKeepsInvalidBlocks(t *testing.T) {
+	req := &k8splaygroundsv1alpha1.ResourceRequirements{
+		Limits:   map[string]string{"cpu": "2000m"},
+		Requests: map[string]string{"cpu": "3"},
+	}
+	if errs := NormalizeResourceRequirements(req, field.NewPath("resources")); len(errs) == 0 {
+		t.Fatal("NormalizeResourceRequirements() accepted a request over its limit")
+	}
+	if req.Limits["cpu"] != "2000m" || req.Requests["cpu"] != "3" {
+		t.Errorf("invalid requirements were rewritten to %v / %v", req.Limits, req.Requests)
+	}
+}
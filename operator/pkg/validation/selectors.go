@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// RequiredSelectorLabelsAnnotation lists, comma-separated on a namespace, the label keys every
+// HeadlessService selector in that namespace must constrain, such as "team,app"
+const RequiredSelectorLabelsAnnotation = "k8s-playgrounds.io/required-selector-labels"
+
+// RequiredSelectorLabels returns the sorted label keys a namespace requires in selectors
+func RequiredSelectorLabels(namespace *corev1.Namespace) []string {
+	if namespace == nil {
+		return nil
+	}
+	var keys []string
+	for _, key := range strings.Split(namespace.Annotations[RequiredSelectorLabelsAnnotation], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateSelectorScope rejects HeadlessService selectors that are unbounded: empty selectors,
+// which match every pod in the namespace, and selectors missing a label key the namespace
+// requires. namespace may be nil when it cannot be read, in which case no keys are required.
+func ValidateSelectorScope(headlessService *k8splaygroundsv1alpha1.HeadlessService, namespace *corev1.Namespace) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+	selectorPath := specPath.Child("selector")
+
+	if len(headlessService.Spec.Selector) == 0 {
+		errs = append(errs, field.Required(selectorPath, "an empty selector matches every pod in the namespace"))
+	}
+	for _, key := range RequiredSelectorLabels(namespace) {
+		if headlessService.Spec.Selector[key] == "" {
+			errs = append(errs, field.Required(selectorPath.Key(key), fmt.Sprintf("namespace %s requires selectors to constrain label %s", namespace.Name, key)))
+		}
+	}
+
+	if headlessService.Spec.MaxMatchedPods < 0 {
+		errs = append(errs, field.Invalid(specPath.Child("maxMatchedPods"), headlessService.Spec.MaxMatchedPods, "must not be negative"))
+	}
+
+	return errs
+}
@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func TestValidateSelectorScope(t *testing.T) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:        "shop",
+		Annotations: map[string]string{RequiredSelectorLabelsAnnotation: "team, app"},
+	}}
+
+	tests := []struct {
+		name      string
+		selector  map[string]string
+		maxPods   int32
+		namespace *corev1.Namespace
+		wantErr   string
+	}{
+		{name: "scoped", selector: map[string]string{"app": "web", "team": "checkout"}, namespace: namespace},
+		{name: "no requirements", selector: map[string]string{"app": "web"}},
+		{name: "empty selector", selector: nil, wantErr: "spec.selector"},
+		{name: "missing required key", selector: map[string]string{"app": "web"}, namespace: namespace, wantErr: "spec.selector[team]"},
+		{name: "negative limit", selector: map[string]string{"app": "web"}, maxPods: -1, wantErr: "spec.maxMatchedPods"},
+	}
+	for _, tt := range tests {
+		headlessService := &k8splaygroundsv1alpha1.HeadlessService{}
+		headlessService.Spec.Selector = tt.selector
+		headlessService.Spec.MaxMatchedPods = tt.maxPods
+
+		err := ValidateSelectorScope(headlessService, tt.namespace).ToAggregate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: error = %v, want it to mention %s", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package verbs
+
+import (
+	"context"
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Annotations every controller understands on the resources it reconciles. They give
+// ad-hoc control over a resource without editing its spec.
+const (
+	// ReconcilePausedAnnotation set to "true" stops the controller from changing the resource
+	// or anything it manages until the annotation is removed. Deletion is still processed, so
+	// finalizers never block it.
+	ReconcilePausedAnnotation = "k8s-playgrounds.io/reconcile-paused"
+	// ForceResyncAnnotation, with any value, requests an immediate reconcile that also redoes
+	// work the controller would otherwise skip, such as rerunning a completed diagnostic. The
+	// annotation is removed once handled.
+	ForceResyncAnnotation = "k8s-playgrounds.io/force-resync"
+	// RefreshStatusAnnotation, with any value, requests an immediate reconcile so the status
+	// reflects the current state, without redoing completed work. The annotation is removed
+	// once handled.
+	RefreshStatusAnnotation = "k8s-playgrounds.io/refresh-status"
+)
+
+// Request holds the verbs set on a resource
+type Request struct {
+	// Paused is set when reconciliation is paused and the resource is not being deleted
+	Paused bool
+	// ForceResync is set when completed work must be redone
+	ForceResync bool
+	// RefreshStatus is set when a status refresh was requested
+	RefreshStatus bool
+}
+
+// Parse reads the verbs set on a resource
+func Parse(obj client.Object) Request {
+	annotations := obj.GetAnnotations()
+	_, forceResync := annotations[ForceResyncAnnotation]
+	_, refreshStatus := annotations[RefreshStatusAnnotation]
+	return Request{
+		Paused:        annotations[ReconcilePausedAnnotation] == "true" && obj.GetDeletionTimestamp().IsZero(),
+		ForceResync:   forceResync,
+		RefreshStatus: refreshStatus,
+	}
+}
+
+// Handle reads the verbs set on a resource fetched by a controller and removes the one-shot
+// ones, so setting them again triggers another reconcile. The resource is patched in place,
+// and later updates of it or its status do not conflict. While reconciliation is paused the
+// one-shot verbs are kept and take effect when it resumes.
+func Handle(ctx context.Context, c client.Client, obj client.Object) (Request, error) {
+	request := Parse(obj)
+	if request.Paused || (!request.ForceResync && !request.RefreshStatus) {
+		return request, nil
+	}
+
+	original := obj.DeepCopyObject().(client.Object)
+	annotations := obj.GetAnnotations()
+	delete(annotations, ForceResyncAnnotation)
+	delete(annotations, RefreshStatusAnnotation)
+	obj.SetAnnotations(annotations)
+	if err := c.Patch(ctx, obj, client.MergeFrom(original)); err != nil {
+		return request, fmt.Errorf("failed to remove handled annotations: %w", err)
+	}
+	return request, nil
+}
+
+// Changed passes updates that pause or resume reconciliation or set a one-shot verb.
+// Controllers that only watch generation changes add it so annotating a resource triggers a
+// reconcile. Removing a handled one-shot verb does not pass.
+var Changed = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld == nil || e.ObjectNew == nil {
+			return false
+		}
+		previous, current := e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations()
+		if previous[ReconcilePausedAnnotation] != current[ReconcilePausedAnnotation] {
+			return true
+		}
+		for _, key := range []string{ForceResyncAnnotation, RefreshStatusAnnotation} {
+			before, hadBefore := previous[key]
+			if after, hasAfter := current[key]; hasAfter && (!hadBefore || before != after) {
+				return true
+			}
+		}
+		return false
+	},
+}
@@ -0,0 +1,87 @@
+package verbs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func object(annotations map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Annotations: annotations}}
+}
+
+func TestHandle(t *testing.T) {
+	ctx := context.Background()
+	obj := object(map[string]string{ForceResyncAnnotation: "2026-10-18T12:00:00Z", RefreshStatusAnnotation: "", "team": "web"})
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(obj).Build()
+
+	request, err := Handle(ctx, c, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != (Request{ForceResync: true, RefreshStatus: true}) {
+		t.Errorf("Handle() = %+v", request)
+	}
+	stored := &corev1.ConfigMap{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "web"}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Annotations) != 1 || stored.Annotations["team"] != "web" {
+		t.Errorf("annotations = %v, want only the handled verbs removed", stored.Annotations)
+	}
+	if obj.ResourceVersion != stored.ResourceVersion {
+		t.Errorf("resource version = %q, want the patched %q", obj.ResourceVersion, stored.ResourceVersion)
+	}
+}
+
+func TestHandlePaused(t *testing.T) {
+	ctx := context.Background()
+	obj := object(map[string]string{ReconcilePausedAnnotation: "true", ForceResyncAnnotation: "1"})
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(obj).Build()
+
+	request, err := Handle(ctx, c, obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request != (Request{Paused: true, ForceResync: true}) {
+		t.Errorf("Handle() = %+v", request)
+	}
+	if _, ok := obj.Annotations[ForceResyncAnnotation]; !ok {
+		t.Error("force-resync was removed while paused")
+	}
+
+	// Deleting a paused resource is not held up
+	now := metav1.NewTime(time.Now())
+	obj.DeletionTimestamp = &now
+	if Parse(obj).Paused {
+		t.Error("Parse() reports a deleted resource as paused")
+	}
+}
+
+func TestChanged(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		before, after map[string]string
+		want          bool
+	}{
+		{"pause", nil, map[string]string{ReconcilePausedAnnotation: "true"}, true},
+		{"resume", map[string]string{ReconcilePausedAnnotation: "true"}, nil, true},
+		{"force resync", nil, map[string]string{ForceResyncAnnotation: ""}, true},
+		{"force resync again", map[string]string{ForceResyncAnnotation: "1"}, map[string]string{ForceResyncAnnotation: "2"}, true},
+		{"handled", map[string]string{RefreshStatusAnnotation: "1"}, nil, false},
+		{"other annotation", nil, map[string]string{"team": "web"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Changed.Update(event.UpdateEvent{ObjectOld: object(tc.before), ObjectNew: object(tc.after)}); got != tc.want {
+				t.Errorf("Changed.Update() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
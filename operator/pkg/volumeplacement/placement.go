@@ -0,0 +1,145 @@
+package volumeplacement
+
+import (
+	"fmt"
+
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// DefaultTopologyKey is the node label zonal storage classes restrict volumes by, unless the
+// placement names the key of a CSI driver
+const DefaultTopologyKey = "topology.kubernetes.io/zone"
+
+// ZoneLabel records on a claim the zone it was placed in
+const ZoneLabel = "k8s-playgrounds.io/zone"
+
+// PlacementLabel records on a claim the name of the cluster whose volume placement created it
+const PlacementLabel = "k8s-playgrounds.io/volume-placement"
+
+// Placement is the zone and storage class of the volume claims of one replica. Empty fields
+// keep the zone unconstrained and the storage class of the claim template.
+type Placement struct {
+	Ordinal          int32
+	Zone             string
+	StorageClassName string
+}
+
+// Validate checks the volume placement of a StatefulSet
+func Validate(statefulSet *k8splaygroundsv1alpha1.StatefulSetSpec, path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	spec := statefulSet.VolumePlacement
+	if spec == nil {
+		return nil
+	}
+	path = path.Child("volumePlacement")
+
+	zones := make(map[string]bool, len(spec.Zones))
+	for i, zone := range spec.Zones {
+		zonePath := path.Child("zones").Index(i).Child("zone")
+		switch {
+		case zone.Zone == "":
+			errs = append(errs, field.Required(zonePath, "a zone is required"))
+		case zones[zone.Zone]:
+			errs = append(errs, field.Duplicate(zonePath, zone.Zone))
+		}
+		zones[zone.Zone] = true
+	}
+
+	ordinals := make(map[int32]bool, len(spec.Replicas))
+	for i, replica := range spec.Replicas {
+		ordinalPath := path.Child("replicas").Index(i).Child("ordinal")
+		switch {
+		case replica.Ordinal < 0:
+			errs = append(errs, field.Invalid(ordinalPath, replica.Ordinal, "must not be negative"))
+		case ordinals[replica.Ordinal]:
+			errs = append(errs, field.Duplicate(ordinalPath, replica.Ordinal))
+		}
+		ordinals[replica.Ordinal] = true
+	}
+
+	if len(statefulSet.VolumeClaimTemplates) == 0 {
+		errs = append(errs, field.Invalid(path, statefulSet.Name, "the StatefulSet has no volume claim templates"))
+	}
+	return errs
+}
+
+// Plan returns the placement of every replica of a StatefulSet. Replicas are assigned to the
+// zones round-robin by ordinal; a replica override picks the zone, and with it the storage
+// class of that zone, or the storage class directly.
+func Plan(statefulSet *k8splaygroundsv1alpha1.StatefulSetSpec) []Placement {
+	spec := statefulSet.VolumePlacement
+	if spec == nil {
+		return nil
+	}
+
+	zoneClasses := make(map[string]string, len(spec.Zones))
+	for _, zone := range spec.Zones {
+		zoneClasses[zone.Zone] = zone.StorageClassName
+	}
+	overrides := make(map[int32]k8splaygroundsv1alpha1.ReplicaStorageSpec, len(spec.Replicas))
+	for _, replica := range spec.Replicas {
+		overrides[replica.Ordinal] = replica
+	}
+
+	placements := make([]Placement, 0, statefulSet.Replicas)
+	for ordinal := int32(0); ordinal < statefulSet.Replicas; ordinal++ {
+		placement := Placement{Ordinal: ordinal}
+		if len(spec.Zones) > 0 {
+			zone := spec.Zones[int(ordinal)%len(spec.Zones)]
+			placement.Zone, placement.StorageClassName = zone.Zone, zone.StorageClassName
+		}
+		if override, ok := overrides[ordinal]; ok {
+			if override.Zone != "" {
+				placement.Zone, placement.StorageClassName = override.Zone, zoneClasses[override.Zone]
+			}
+			if override.StorageClassName != "" {
+				placement.StorageClassName = override.StorageClassName
+			}
+		}
+		placements = append(placements, placement)
+	}
+	return placements
+}
+
+// ClaimName returns the name of the claim the StatefulSet controller looks for, for the given
+// claim template and ordinal
+func ClaimName(template, statefulSet string, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", template, statefulSet, ordinal)
+}
+
+// ZoneWarning explains why volumes of a storage class may not land in zone, or returns "" when
+// the class restricts its volumes to the zone. With WaitForFirstConsumer the volume follows
+// the pod, which may be scheduled in any zone; with Immediate binding the volume is
+// provisioned in any zone and the pod follows it.
+func ZoneWarning(class *storagev1.StorageClass, topologyKey, zone string) string {
+	if pinsZone(class, topologyKey, zone) {
+		return ""
+	}
+	if class.VolumeBindingMode != nil && *class.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer {
+		return fmt.Sprintf("storage class %s waits for the first consumer but does not restrict %s to %s; the volume is provisioned wherever the pod is scheduled", class.Name, topologyKey, zone)
+	}
+	return fmt.Sprintf("storage class %s binds immediately and does not restrict %s to %s; the volume may be provisioned in any zone", class.Name, topologyKey, zone)
+}
+
+// pinsZone reports whether every allowed topology of a storage class restricts topologyKey to
+// exactly zone
+func pinsZone(class *storagev1.StorageClass, topologyKey, zone string) bool {
+	if len(class.AllowedTopologies) == 0 {
+		return false
+	}
+	for _, term := range class.AllowedTopologies {
+		pinned := false
+		for _, requirement := range term.MatchLabelExpressions {
+			if requirement.Key == topologyKey {
+				pinned = len(requirement.Values) == 1 && requirement.Values[0] == zone
+			}
+		}
+		if !pinned {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package volumeplacement
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+func newStatefulSet() k8splaygroundsv1alpha1.StatefulSetSpec {
+	return k8splaygroundsv1alpha1.StatefulSetSpec{
+		Name:     "db",
+		Replicas: 4,
+		VolumeClaimTemplates: []k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate{{
+			Metadata: metav1.ObjectMeta{Name: "data"},
+			Spec: k8splaygroundsv1alpha1.PersistentVolumeClaimSpec{
+				AccessModes:      []string{"ReadWriteOnce"},
+				Resources:        k8splaygroundsv1alpha1.ResourceRequirements{Requests: map[string]string{"storage": "10Gi"}},
+				StorageClassName: "standard",
+			},
+		}},
+		VolumePlacement: &k8splaygroundsv1alpha1.VolumePlacementSpec{
+			Zones: []k8splaygroundsv1alpha1.ZoneStorageSpec{
+				{Zone: "us-east-1a", StorageClassName: "gp3-us-east-1a"},
+				{Zone: "us-east-1b", StorageClassName: "gp3-us-east-1b"},
+			},
+			Replicas: []k8splaygroundsv1alpha1.ReplicaStorageSpec{
+				{Ordinal: 2, Zone: "us-east-1b"},
+				{Ordinal: 3, StorageClassName: "io2-us-east-1b"},
+			},
+		},
+	}
+}
+
+func TestPlan(t *testing.T) {
+	statefulSet := newStatefulSet()
+	want := []Placement{
+		{Ordinal: 0, Zone: "us-east-1a", StorageClassName: "gp3-us-east-1a"},
+		{Ordinal: 1, Zone: "us-east-1b", StorageClassName: "gp3-us-east-1b"},
+		{Ordinal: 2, Zone: "us-east-1b", StorageClassName: "gp3-us-east-1b"},
+		{Ordinal: 3, Zone: "us-east-1b", StorageClassName: "io2-us-east-1b"},
+	}
+	got := Plan(&statefulSet)
+	if len(got) != len(want) {
+		t.Fatalf("Plan() = %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("placement %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestZoneWarning(t *testing.T) {
+	waitForFirstConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+	zonal := &storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: "gp3-us-east-1a"},
+		VolumeBindingMode: &waitForFirstConsumer,
+		AllowedTopologies: []corev1.TopologySelectorTerm{{
+			MatchLabelExpressions: []corev1.TopologySelectorLabelRequirement{{Key: DefaultTopologyKey, Values: []string{"us-east-1a"}}},
+		}},
+	}
+	if warning := ZoneWarning(zonal, DefaultTopologyKey, "us-east-1a"); warning != "" {
+		t.Errorf("ZoneWarning() = %q for a zonal class", warning)
+	}
+	if warning := ZoneWarning(zonal, DefaultTopologyKey, "us-east-1b"); warning == "" {
+		t.Error("ZoneWarning() accepted a class pinned to another zone")
+	}
+
+	regional := &storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}, VolumeBindingMode: &waitForFirstConsumer}
+	if warning := ZoneWarning(regional, DefaultTopologyKey, "us-east-1a"); !strings.Contains(warning, "first consumer") {
+		t.Errorf("ZoneWarning() = %q, want the WaitForFirstConsumer explanation", warning)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	conflicting := "standard"
+	existing := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-db-1", Namespace: "playground"},
+		Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &conflicting},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(existing).Build()
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "playground"},
+		Spec:       k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{StatefulSets: []k8splaygroundsv1alpha1.StatefulSetSpec{newStatefulSet()}},
+	}
+	ctx := context.Background()
+
+	if err := NewReconciler(c, clientgoscheme.Scheme).Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	claim := &corev1.PersistentVolumeClaim{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "data-db-0", Namespace: "playground"}, claim); err != nil {
+		t.Fatal(err)
+	}
+	if *claim.Spec.StorageClassName != "gp3-us-east-1a" || claim.Labels[ZoneLabel] != "us-east-1a" {
+		t.Errorf("claim = %+v, want the zonal storage class", claim)
+	}
+	if storage := claim.Spec.Resources.Requests[corev1.ResourceStorage]; storage.String() != "10Gi" {
+		t.Errorf("storage request = %s", storage.String())
+	}
+
+	statuses := cluster.Status.VolumePlacements
+	if len(statuses) != 4 || statuses[1].Phase != PhaseConflict {
+		t.Errorf("statuses = %+v, want the existing claim reported as a conflict", statuses)
+	}
+	if !strings.Contains(statuses[0].Message, "does not exist") {
+		t.Errorf("status = %+v, want the missing storage class reported", statuses[0])
+	}
+}
+
+func TestReconcileRejectsInvalidPlacement(t *testing.T) {
+	statefulSet := newStatefulSet()
+	statefulSet.VolumePlacement.Zones = append(statefulSet.VolumePlacement.Zones, k8splaygroundsv1alpha1.ZoneStorageSpec{Zone: "us-east-1a"})
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		Spec: k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{StatefulSets: []k8splaygroundsv1alpha1.StatefulSetSpec{statefulSet}},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build()
+	if err := NewReconciler(c, clientgoscheme.Scheme).Reconcile(context.Background(), cluster); err == nil {
+		t.Error("Reconcile() accepted a duplicate zone")
+	}
+}
+
+func TestCleanupDeletesOnlyPlacedClaims(t *testing.T) {
+	conflicting := "standard"
+	existing := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-db-1", Namespace: "playground"},
+		Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &conflicting},
+	}
+	otherCluster := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data-cache-0", Namespace: "playground", Labels: map[string]string{PlacementLabel: "other"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(existing, otherCluster).Build()
+	cluster := &k8splaygroundsv1alpha1.K8sPlaygroundsCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "playground"},
+		Spec:       k8splaygroundsv1alpha1.K8sPlaygroundsClusterSpec{StatefulSets: []k8splaygroundsv1alpha1.StatefulSetSpec{newStatefulSet()}},
+	}
+	ctx := context.Background()
+	r := NewReconciler(c, clientgoscheme.Scheme)
+
+	if err := r.Reconcile(ctx, cluster); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Cleanup(ctx, cluster); err != nil {
+		t.Fatalf("Cleanup() error = %v", err)
+	}
+
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, claims); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, claim := range claims.Items {
+		names = append(names, claim.Name)
+	}
+	if strings.Join(names, ",") != "data-cache-0,data-db-1" {
+		t.Errorf("claims after Cleanup() = %v, want only the claims the placement did not create", names)
+	}
+}
@@ -0,0 +1,209 @@
+package volumeplacement
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// PhaseConflict reports a claim that exists with another storage class than planned
+const PhaseConflict = "Conflict"
+
+// Reconciler creates the volume claims of StatefulSet replicas with a zone or storage class
+// override. It must run before the StatefulSets are scaled: the StatefulSet controller only
+// creates claims that do not exist yet and uses existing claims of the same name as they are.
+type Reconciler struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+// NewReconciler creates a new volume placement reconciler
+func NewReconciler(client client.Client, scheme *runtime.Scheme) *Reconciler {
+	return &Reconciler{
+		client: client,
+		scheme: scheme,
+	}
+}
+
+// Reconcile creates the missing claims of every StatefulSet with a volume placement and
+// reports all planned claims in the cluster status. Like the claims the StatefulSet controller
+// creates, they are kept when the StatefulSet scales down or is deleted; Cleanup deletes them
+// with the cluster.
+func (r *Reconciler) Reconcile(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	var errs field.ErrorList
+	for i := range cluster.Spec.StatefulSets {
+		statefulSet := &cluster.Spec.StatefulSets[i]
+		errs = append(errs, Validate(statefulSet, field.NewPath("spec", "statefulSets").Key(statefulSet.Name))...)
+	}
+	if err := errs.ToAggregate(); err != nil {
+		return fmt.Errorf("invalid volume placement: %w", err)
+	}
+
+	var statuses []k8splaygroundsv1alpha1.VolumePlacementStatus
+	for i := range cluster.Spec.StatefulSets {
+		statefulSet := &cluster.Spec.StatefulSets[i]
+		namespace := statefulSet.Namespace
+		if namespace == "" {
+			namespace = cluster.Namespace
+		}
+		for _, placement := range Plan(statefulSet) {
+			for _, template := range statefulSet.VolumeClaimTemplates {
+				status, err := r.ensureClaim(ctx, cluster, namespace, statefulSet, template, placement)
+				if err != nil {
+					return err
+				}
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	cluster.Status.VolumePlacements = statuses
+	return nil
+}
+
+// ensureClaim creates the claim of one replica and claim template when it is missing and
+// reports its placement
+func (r *Reconciler) ensureClaim(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, namespace string, statefulSet *k8splaygroundsv1alpha1.StatefulSetSpec, template k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate, placement Placement) (k8splaygroundsv1alpha1.VolumePlacementStatus, error) {
+	log := logr.FromContextOrDiscard(ctx)
+	storageClassName := placement.StorageClassName
+	if storageClassName == "" {
+		storageClassName = template.Spec.StorageClassName
+	}
+	status := k8splaygroundsv1alpha1.VolumePlacementStatus{
+		StatefulSet:      statefulSet.Name,
+		Claim:            ClaimName(template.Metadata.Name, statefulSet.Name, placement.Ordinal),
+		Ordinal:          placement.Ordinal,
+		Zone:             placement.Zone,
+		StorageClassName: storageClassName,
+	}
+
+	if placement.Zone != "" && storageClassName != "" {
+		class := &storagev1.StorageClass{}
+		if err := r.client.Get(ctx, types.NamespacedName{Name: storageClassName}, class); err != nil {
+			if !errors.IsNotFound(err) {
+				return status, fmt.Errorf("failed to get storage class %s: %w", storageClassName, err)
+			}
+			status.Message = fmt.Sprintf("storage class %s does not exist", storageClassName)
+		} else {
+			topologyKey := statefulSet.VolumePlacement.TopologyKey
+			if topologyKey == "" {
+				topologyKey = DefaultTopologyKey
+			}
+			status.Message = ZoneWarning(class, topologyKey, placement.Zone)
+		}
+	}
+
+	existing := &corev1.PersistentVolumeClaim{}
+	err := r.client.Get(ctx, types.NamespacedName{Name: status.Claim, Namespace: namespace}, existing)
+	if err == nil {
+		status.Phase = string(existing.Status.Phase)
+		// The storage class of a claim is immutable
+		if existingClass := existing.Spec.StorageClassName; existingClass != nil && *existingClass != storageClassName && storageClassName != "" {
+			status.Phase = PhaseConflict
+			status.Message = fmt.Sprintf("claim exists with storage class %s; delete the claim to move the replica", *existingClass)
+		}
+		return status, nil
+	}
+	if !errors.IsNotFound(err) {
+		return status, fmt.Errorf("failed to get claim %s: %w", status.Claim, err)
+	}
+
+	claim, err := buildClaim(cluster, status.Claim, namespace, template, placement.Zone, storageClassName)
+	if err != nil {
+		return status, err
+	}
+	if err := r.client.Create(ctx, claim); err != nil {
+		return status, fmt.Errorf("failed to create claim %s: %w", status.Claim, err)
+	}
+	log.Info("created volume claim", "claim", status.Claim, "zone", placement.Zone, "storageClass", storageClassName)
+	status.Phase = string(corev1.ClaimPending)
+	return status, nil
+}
+
+// buildClaim renders the claim of a replica from its claim template
+func buildClaim(cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster, name, namespace string, template k8splaygroundsv1alpha1.PersistentVolumeClaimTemplate, zone, storageClassName string) (*corev1.PersistentVolumeClaim, error) {
+	labels := make(map[string]string, len(template.Metadata.Labels)+2)
+	for key, value := range template.Metadata.Labels {
+		labels[key] = value
+	}
+	labels[PlacementLabel] = cluster.Name
+	if zone != "" {
+		labels[ZoneLabel] = zone
+	}
+
+	requests, err := buildResourceList(template.Spec.Resources.Requests)
+	if err != nil {
+		return nil, fmt.Errorf("invalid requests of claim %s: %w", name, err)
+	}
+	limits, err := buildResourceList(template.Spec.Resources.Limits)
+	if err != nil {
+		return nil, fmt.Errorf("invalid limits of claim %s: %w", name, err)
+	}
+
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: template.Metadata.Annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources:  corev1.VolumeResourceRequirements{Requests: requests, Limits: limits},
+			VolumeName: template.Spec.VolumeName,
+		},
+	}
+	for _, mode := range template.Spec.AccessModes {
+		claim.Spec.AccessModes = append(claim.Spec.AccessModes, corev1.PersistentVolumeAccessMode(mode))
+	}
+	if storageClassName != "" {
+		claim.Spec.StorageClassName = &storageClassName
+	}
+	return claim, nil
+}
+
+// Cleanup deletes the claims the volume placement of the cluster created. Claims the StatefulSet
+// controller created and claims that existed before are left alone.
+func (r *Reconciler) Cleanup(ctx context.Context, cluster *k8splaygroundsv1alpha1.K8sPlaygroundsCluster) error {
+	log := logr.FromContextOrDiscard(ctx)
+	for _, namespace := range cluster.ManagedNamespaces() {
+		claims := &corev1.PersistentVolumeClaimList{}
+		if err := r.client.List(ctx, claims, client.InNamespace(namespace), client.MatchingLabels{PlacementLabel: cluster.Name}); err != nil {
+			return fmt.Errorf("failed to list placed claims in %s: %w", namespace, err)
+		}
+		for i := range claims.Items {
+			claim := &claims.Items[i]
+			if err := r.client.Delete(ctx, claim); err != nil && !errors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete claim %s: %w", claim.Name, err)
+			}
+			log.Info("deleted volume claim", "claim", claim.Name, "namespace", namespace)
+		}
+	}
+	return nil
+}
+
+// buildResourceList parses string quantities into a Kubernetes resource list
+func buildResourceList(list map[string]string) (corev1.ResourceList, error) {
+	if len(list) == 0 {
+		return nil, nil
+	}
+	result := make(corev1.ResourceList, len(list))
+	for name, value := range list {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q for %s: %w", value, name, err)
+		}
+		result[corev1.ResourceName(name)] = quantity
+	}
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/cloud"
+	"aviatrix-operator/pkg/security"
+)
+
+//+kubebuilder:webhook:path=/validate-aviatrix-k8s-io-v1alpha1-aviatrixvpc,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io,resources=aviatrixvpcs,verbs=create;update,versions=v1alpha1,name=vaviatrixvpc.aviatrix.k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-aviatrix-k8s-io-v1alpha1-aviatrixfirewall,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io,resources=aviatrixfirewalls,verbs=create;update,versions=v1alpha1,name=vaviatrixfirewall.aviatrix.k8s.io,admissionReviewVersions=v1
+
+// SpecValidator rejects AviatrixVpcs and AviatrixFirewalls with malformed specs. Gateways and
+// transit gateways are checked the same way by GatewayNameValidator, which serves their webhook.
+type SpecValidator struct{}
+
+var _ admission.CustomValidator = &SpecValidator{}
+
+// SetupWithManager registers the validating webhooks of VPCs and firewalls with the Manager
+func (v *SpecValidator) SetupWithManager(mgr ctrl.Manager) error {
+	for _, obj := range []client.Object{&aviatrixv1alpha1.AviatrixVpc{}, &aviatrixv1alpha1.AviatrixFirewall{}} {
+		if err := ctrl.NewWebhookManagedBy(mgr).For(obj).WithValidator(v).Complete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateCreate validates the spec of a new VPC or firewall
+func (v *SpecValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validateSpec(obj)
+}
+
+// ValidateUpdate validates a changed spec of a VPC or firewall
+func (v *SpecValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if !specChanged(oldObj, newObj) {
+		return nil, nil
+	}
+	return nil, validateSpec(newObj)
+}
+
+// ValidateDelete allows every deletion
+func (v *SpecValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateSpec rejects a malformed spec of a gateway, transit gateway, VPC or firewall with
+// every problem found; other kinds are admitted
+func validateSpec(obj runtime.Object) error {
+	var kind, name string
+	var errs field.ErrorList
+	switch o := obj.(type) {
+	case *aviatrixv1alpha1.AviatrixGateway:
+		kind, name, errs = "AviatrixGateway", o.Name, cloud.ValidateGateway(&o.Spec)
+	case *aviatrixv1alpha1.AviatrixTransitGateway:
+		kind, name, errs = "AviatrixTransitGateway", o.Name, cloud.ValidateTransitGateway(&o.Spec)
+	case *aviatrixv1alpha1.AviatrixVpc:
+		kind, name, errs = "AviatrixVpc", o.Name, cloud.ValidateVpc(&o.Spec)
+	case *aviatrixv1alpha1.AviatrixFirewall:
+		kind, name, errs = "AviatrixFirewall", o.Name, security.ValidateFirewall(&o.Spec)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.NewInvalid(aviatrixv1alpha1.Kind(kind), name, errs)
+}
+
+// specChanged reports whether an update changes the spec. Updates that leave it alone, such as
+// the removal of a finalizer, are admitted, so resources created before their spec was
+// validated can still be deleted.
+func specChanged(oldObj, newObj runtime.Object) bool {
+	return !equality.Semantic.DeepEqual(specOf(oldObj), specOf(newObj))
+}
+
+func specOf(obj runtime.Object) interface{} {
+	switch o := obj.(type) {
+	case *aviatrixv1alpha1.AviatrixGateway:
+		return o.Spec
+	case *aviatrixv1alpha1.AviatrixTransitGateway:
+		return o.Spec
+	case *aviatrixv1alpha1.AviatrixVpc:
+		return o.Spec
+	case *aviatrixv1alpha1.AviatrixFirewall:
+		return o.Spec
+	}
+	return nil
+}
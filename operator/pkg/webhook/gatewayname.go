@@ -0,0 +1,88 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	aviatrixv1alpha1 "aviatrix-operator/api/v1alpha1"
+	"aviatrix-operator/pkg/gatewayname"
+)
+
+//+kubebuilder:webhook:path=/validate-aviatrix-k8s-io-v1alpha1-aviatrixgateway,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io,resources=aviatrixgateways,verbs=create;update,versions=v1alpha1,name=vaviatrixgateway.aviatrix.k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-aviatrix-k8s-io-v1alpha1-aviatrixspokegateway,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io,resources=aviatrixspokegateways,verbs=create;update,versions=v1alpha1,name=vaviatrixspokegateway.aviatrix.k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-aviatrix-k8s-io-v1alpha1-aviatrixtransitgateway,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io,resources=aviatrixtransitgateways,verbs=create;update,versions=v1alpha1,name=vaviatrixtransitgateway.aviatrix.k8s.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-aviatrix-k8s-io-v1alpha1-aviatrixedgegateway,mutating=false,failurePolicy=fail,sideEffects=None,groups=aviatrix.k8s.io,resources=aviatrixedgegateways,verbs=create;update,versions=v1alpha1,name=vaviatrixedgegateway.aviatrix.k8s.io,admissionReviewVersions=v1
+
+// GatewayNameValidator rejects gateway custom resources whose gwName is already claimed by
+// another custom resource of any gateway kind. The check reads the cache, so two resources
+// created at the same time may both be admitted; the gateway controllers then mark the newer
+// one with a conflict condition and leave the gateway to the older one. As a kind is served by
+// one validating webhook, it also rejects malformed specs of gateways and transit gateways.
+type GatewayNameValidator struct {
+	// Client must read from a cache indexed with gatewayname.SetupIndexes
+	Client client.Client
+}
+
+var _ admission.CustomValidator = &GatewayNameValidator{}
+
+// SetupWithManager registers the validating webhook of every gateway kind with the Manager
+func (v *GatewayNameValidator) SetupWithManager(mgr ctrl.Manager) error {
+	for _, obj := range gatewayname.Objects() {
+		if err := ctrl.NewWebhookManagedBy(mgr).For(obj).WithValidator(v).Complete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateCreate validates the spec and gateway name of a new gateway custom resource
+func (v *GatewayNameValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if err := validateSpec(obj); err != nil {
+		return nil, err
+	}
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate validates the changed spec and gateway name of a gateway custom resource
+func (v *GatewayNameValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if specChanged(oldObj, newObj) {
+		if err := validateSpec(newObj); err != nil {
+			return nil, err
+		}
+	}
+	oldClaim, _ := gatewayname.Of(oldObj)
+	newClaim, _ := gatewayname.Of(newObj)
+	// Status and metadata updates of a resource that already lost a conflict must not be blocked
+	if oldClaim.GwName == newClaim.GwName {
+		return nil, nil
+	}
+	return v.validate(ctx, newObj)
+}
+
+// ValidateDelete allows every deletion
+func (v *GatewayNameValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *GatewayNameValidator) validate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	claim, ok := gatewayname.Of(obj)
+	if !ok {
+		return nil, fmt.Errorf("expected a gateway, got %T", obj)
+	}
+	others, err := gatewayname.Others(ctx, v.Client, claim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up gateway name: %w", err)
+	}
+	if len(others) == 0 {
+		return nil, nil
+	}
+	errs := field.ErrorList{field.Invalid(field.NewPath("spec", "gwName"), claim.GwName, fmt.Sprintf("already used by %s", others[0]))}
+	return nil, errors.NewInvalid(aviatrixv1alpha1.Kind(claim.Kind), claim.Name, errs)
+}
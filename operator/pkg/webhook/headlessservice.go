@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/defaults"
+	"github.com/k8s-playgrounds/operator/pkg/demoworkload"
+	"github.com/k8s-playgrounds/operator/pkg/endpoints"
+	"github.com/k8s-playgrounds/operator/pkg/federation"
+	"github.com/k8s-playgrounds/operator/pkg/mirror"
+	"github.com/k8s-playgrounds/operator/pkg/validation"
+)
+
+//+kubebuilder:webhook:path=/mutate-k8s-playgrounds-io-v1alpha1-headlessservice,mutating=true,failurePolicy=fail,sideEffects=None,groups=k8s-playgrounds.io,resources=headlessservices,verbs=create;update,versions=v1alpha1,name=mheadlessservice.k8s-playgrounds.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-k8s-playgrounds-io-v1alpha1-headlessservice,mutating=false,failurePolicy=fail,sideEffects=None,groups=k8s-playgrounds.io,resources=headlessservices,verbs=create;update,versions=v1alpha1,name=vheadlessservice.k8s-playgrounds.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// HeadlessServiceDefaulter fills in the recommended labels, ports and the DNS, service discovery
+// and iptables proxy settings a HeadlessService leaves unset, so they are stored with the spec
+type HeadlessServiceDefaulter struct{}
+
+var _ admission.CustomDefaulter = &HeadlessServiceDefaulter{}
+
+// SetupWithManager registers the defaulting webhook with the Manager
+func (d *HeadlessServiceDefaulter) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&k8splaygroundsv1alpha1.HeadlessService{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default defaults a HeadlessService
+func (d *HeadlessServiceDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	headlessService, ok := obj.(*k8splaygroundsv1alpha1.HeadlessService)
+	if !ok {
+		return fmt.Errorf("expected a HeadlessService, got %T", obj)
+	}
+	defaults.HeadlessService(headlessService)
+	return nil
+}
+
+// HeadlessServiceValidator rejects HeadlessServices whose selector is unbounded or misses a
+// label the namespace requires or whose mirror settings are inconsistent, and warns when the
+// selector already matches more pods than the service may use
+type HeadlessServiceValidator struct {
+	Client client.Client
+
+	// MaxMatchedPods is the operator-wide limit on matched pods; 0 uses the default
+	MaxMatchedPods int
+}
+
+var _ admission.CustomValidator = &HeadlessServiceValidator{}
+
+// SetupWithManager registers the validating webhook with the Manager
+func (v *HeadlessServiceValidator) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&k8splaygroundsv1alpha1.HeadlessService{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates a new HeadlessService
+func (v *HeadlessServiceValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate validates a changed HeadlessService
+func (v *HeadlessServiceValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, newObj)
+}
+
+// ValidateDelete allows every deletion
+func (v *HeadlessServiceValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *HeadlessServiceValidator) validate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	headlessService, ok := obj.(*k8splaygroundsv1alpha1.HeadlessService)
+	if !ok {
+		return nil, fmt.Errorf("expected a HeadlessService, got %T", obj)
+	}
+
+	// Only the annotations of the namespace are read, so it is cached as metadata
+	namespaceMetadata := &metav1.PartialObjectMetadata{}
+	namespaceMetadata.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Namespace"))
+	var namespace *corev1.Namespace
+	if err := v.Client.Get(ctx, types.NamespacedName{Name: headlessService.Namespace}, namespaceMetadata); err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get namespace: %w", err)
+		}
+	} else {
+		namespace = &corev1.Namespace{ObjectMeta: namespaceMetadata.ObjectMeta}
+	}
+
+	if errs := mirror.Validate(headlessService); len(errs) > 0 {
+		return nil, errors.NewInvalid(k8splaygroundsv1alpha1.Kind("HeadlessService"), headlessService.Name, errs)
+	}
+	if errs := federation.Validate(headlessService); len(errs) > 0 {
+		return nil, errors.NewInvalid(k8splaygroundsv1alpha1.Kind("HeadlessService"), headlessService.Name, errs)
+	}
+	if errs := endpoints.ValidateExternal(headlessService); len(errs) > 0 {
+		return nil, errors.NewInvalid(k8splaygroundsv1alpha1.Kind("HeadlessService"), headlessService.Name, errs)
+	}
+	if errs := endpoints.ValidateFilter(headlessService); len(errs) > 0 {
+		return nil, errors.NewInvalid(k8splaygroundsv1alpha1.Kind("HeadlessService"), headlessService.Name, errs)
+	}
+	if errs := demoworkload.Validate(headlessService); len(errs) > 0 {
+		return nil, errors.NewInvalid(k8splaygroundsv1alpha1.Kind("HeadlessService"), headlessService.Name, errs)
+	}
+
+	// A mirrored Service brings its own selector, which is checked like a selector in the spec
+	if headlessService.Spec.Mirror != nil {
+		source := &corev1.Service{}
+		if err := v.Client.Get(ctx, types.NamespacedName{Name: headlessService.Spec.Mirror.ServiceName, Namespace: headlessService.Namespace}, source); err != nil {
+			if !errors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get mirrored service: %w", err)
+			}
+			return admission.Warnings{fmt.Sprintf("service %s does not exist yet; the headless service stays pending until it does", headlessService.Spec.Mirror.ServiceName)}, nil
+		}
+		// Apply replaces the selector and ports, so a shallow copy keeps the admitted object intact
+		mirrored := *headlessService
+		headlessService = &mirrored
+		if err := mirror.Apply(headlessService, source); err != nil {
+			return nil, errors.NewBadRequest(err.Error())
+		}
+	}
+
+	if errs := validation.ValidateSelectorScope(headlessService, namespace); len(errs) > 0 {
+		return nil, errors.NewInvalid(k8splaygroundsv1alpha1.Kind("HeadlessService"), headlessService.Name, errs)
+	}
+
+	// The pod count changes after admission, so exceeding the limit is only a warning here and
+	// is enforced by the controller
+	pods := &corev1.PodList{}
+	if err := v.Client.List(ctx, pods, client.InNamespace(headlessService.Namespace), client.MatchingLabels(headlessService.Spec.Selector)); err != nil {
+		return admission.Warnings{fmt.Sprintf("could not count matched pods: %v", err)}, nil
+	}
+	limit := endpoints.MaxMatchedPods(headlessService, v.MaxMatchedPods)
+	if len(pods.Items) > limit {
+		return admission.Warnings{fmt.Sprintf("selector matches %d pods, more than the limit of %d; endpoints and iptables rules will not be updated", len(pods.Items), limit)}, nil
+	}
+	return nil, nil
+}
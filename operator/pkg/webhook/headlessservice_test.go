@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+)
+
+// recordingServer records the paths webhooks are registered at
+type recordingServer struct {
+	ctrlwebhook.Server
+	paths []string
+}
+
+func (s *recordingServer) Register(path string, hook http.Handler) {
+	s.paths = append(s.paths, path)
+	s.Server.Register(path, hook)
+}
+
+func TestHeadlessServiceWebhooksRegister(t *testing.T) {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypes(k8splaygroundsv1alpha1.SchemeGroupVersion, &k8splaygroundsv1alpha1.HeadlessService{})
+	metav1.AddToGroupVersion(scheme, k8splaygroundsv1alpha1.SchemeGroupVersion)
+
+	server := &recordingServer{Server: ctrlwebhook.NewServer(ctrlwebhook.Options{})}
+	mgr, err := ctrl.NewManager(&rest.Config{Host: "https://127.0.0.1:6443"}, ctrl.Options{
+		Scheme:        scheme,
+		Metrics:       metricsserver.Options{BindAddress: "0"},
+		WebhookServer: server,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&HeadlessServiceDefaulter{}).SetupWithManager(mgr); err != nil {
+		t.Fatalf("defaulter SetupWithManager() error = %v", err)
+	}
+	if err := (&HeadlessServiceValidator{Client: mgr.GetClient()}).SetupWithManager(mgr); err != nil {
+		t.Fatalf("validator SetupWithManager() error = %v", err)
+	}
+
+	// Both webhooks are served at the paths the webhook configuration generated from the
+	// kubebuilder markers calls
+	sort.Strings(server.paths)
+	gv := strings.ReplaceAll(k8splaygroundsv1alpha1.SchemeGroupVersion.Group, ".", "-") + "-" + k8splaygroundsv1alpha1.SchemeGroupVersion.Version
+	want := []string{
+		"/mutate-" + gv + "-headlessservice",
+		"/validate-" + gv + "-headlessservice",
+	}
+	if len(server.paths) != len(want) || server.paths[0] != want[0] || server.paths[1] != want[1] {
+		t.Errorf("registered paths = %v, want %v", server.paths, want)
+	}
+}
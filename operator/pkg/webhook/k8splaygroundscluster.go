@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	k8splaygroundsv1alpha1 "github.com/k8s-playgrounds/operator/api/v1alpha1"
+	"github.com/k8s-playgrounds/operator/pkg/defaults"
+	"github.com/k8s-playgrounds/operator/pkg/validation"
+)
+
+//+kubebuilder:webhook:path=/mutate-k8s-playgrounds-io-v1alpha1-k8splaygroundscluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=k8s-playgrounds.io,resources=k8splaygroundsclusters,verbs=create;update,versions=v1alpha1,name=mk8splaygroundscluster.k8s-playgrounds.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-k8s-playgrounds-io-v1alpha1-k8splaygroundscluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=k8s-playgrounds.io,resources=k8splaygroundsclusters,verbs=create;update,versions=v1alpha1,name=vk8splaygroundscluster.k8s-playgrounds.io,admissionReviewVersions=v1
+
+// K8sPlaygroundsClusterDefaulter fills in the version, replica count, recommended labels and
+// service ports a cluster leaves unset, so they are stored with the spec
+type K8sPlaygroundsClusterDefaulter struct{}
+
+var _ admission.CustomDefaulter = &K8sPlaygroundsClusterDefaulter{}
+
+// SetupWithManager registers the defaulting webhook with the Manager
+func (d *K8sPlaygroundsClusterDefaulter) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}).
+		WithDefaulter(d).
+		Complete()
+}
+
+// Default defaults a K8sPlaygroundsCluster
+func (d *K8sPlaygroundsClusterDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	cluster, ok := obj.(*k8splaygroundsv1alpha1.K8sPlaygroundsCluster)
+	if !ok {
+		return fmt.Errorf("expected a K8sPlaygroundsCluster, got %T", obj)
+	}
+	defaults.Cluster(cluster)
+	return nil
+}
+
+// K8sPlaygroundsClusterValidator rejects clusters with resource quantities that do not parse,
+// name unknown resources or request more than their limit, instead of leaving them to fail at
+// reconcile time
+type K8sPlaygroundsClusterValidator struct{}
+
+var _ admission.CustomValidator = &K8sPlaygroundsClusterValidator{}
+
+// SetupWithManager registers the validating webhook with the Manager
+func (v *K8sPlaygroundsClusterValidator) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&k8splaygroundsv1alpha1.K8sPlaygroundsCluster{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates a new K8sPlaygroundsCluster
+func (v *K8sPlaygroundsClusterValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(obj)
+}
+
+// ValidateUpdate validates a changed K8sPlaygroundsCluster
+func (v *K8sPlaygroundsClusterValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(newObj)
+}
+
+// ValidateDelete allows every deletion
+func (v *K8sPlaygroundsClusterValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *K8sPlaygroundsClusterValidator) validate(obj runtime.Object) error {
+	cluster, ok := obj.(*k8splaygroundsv1alpha1.K8sPlaygroundsCluster)
+	if !ok {
+		return fmt.Errorf("expected a K8sPlaygroundsCluster, got %T", obj)
+	}
+	// Normalizing rewrites the quantities of the decoded object only; a validating webhook
+	// cannot change what is stored, so only the errors matter
+	if err := validation.NormalizeClusterResources(&cluster.Spec); err != nil {
+		return errors.NewBadRequest(fmt.Sprintf("invalid resource requirements: %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package workers
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/config"
+)
+
+// AllKinds sets the workers of every controller without a count of its own
+const AllKinds = "*"
+
+// Workers sets how many reconciles of a kind run at once, so kinds with many resources get
+// more workers without letting them crowd out the others. It implements flag.Value so it can
+// be set with a repeated --reconcile-workers=Kind=count, e.g. AviatrixGateway=4; the kind *
+// sets the count of every other controller. Controllers run one worker by default.
+type Workers map[string]int
+
+// String lists the counts as Kind=count pairs
+func (w Workers) String() string {
+	pairs := make([]string, 0, len(w))
+	for kind, count := range w {
+		pairs = append(pairs, kind+"="+strconv.Itoa(count))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set parses a Kind=count pair. Setting the same kind again replaces its count.
+func (w Workers) Set(value string) error {
+	kind, raw, ok := strings.Cut(value, "=")
+	kind = strings.TrimSpace(kind)
+	if !ok || kind == "" {
+		return fmt.Errorf("invalid reconcile workers %q, want Kind=count", value)
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || count < 1 {
+		return fmt.Errorf("invalid number of reconcile workers for kind %s: %q", kind, raw)
+	}
+	w[kind] = count
+	return nil
+}
+
+// Options returns the controller options of the manager for the kinds of group
+func (w Workers) Options(group string) config.Controller {
+	options := config.Controller{MaxConcurrentReconciles: w[AllKinds]}
+	for kind, count := range w {
+		if kind == AllKinds {
+			continue
+		}
+		if options.GroupKindConcurrency == nil {
+			options.GroupKindConcurrency = map[string]int{}
+		}
+		options.GroupKindConcurrency[kind+"."+group] = count
+	}
+	return options
+}
@@ -0,0 +1,28 @@
+package workers
+
+import "testing"
+
+func TestWorkers(t *testing.T) {
+	workers := Workers{}
+	for _, value := range []string{"AviatrixGateway=4", "*=2", "AviatrixVpc = 3"} {
+		if err := workers.Set(value); err != nil {
+			t.Fatalf("Set(%q) error = %v", value, err)
+		}
+	}
+	if got := workers.String(); got != "*=2,AviatrixGateway=4,AviatrixVpc=3" {
+		t.Errorf("String() = %q", got)
+	}
+	for _, invalid := range []string{"AviatrixGateway", "=2", "AviatrixGateway=0", "AviatrixGateway=many"} {
+		if err := workers.Set(invalid); err == nil {
+			t.Errorf("Set(%q) succeeded", invalid)
+		}
+	}
+
+	options := workers.Options("aviatrix.k8s.io")
+	if options.MaxConcurrentReconciles != 2 {
+		t.Errorf("MaxConcurrentReconciles = %d, want the count of *", options.MaxConcurrentReconciles)
+	}
+	if len(options.GroupKindConcurrency) != 2 || options.GroupKindConcurrency["AviatrixGateway.aviatrix.k8s.io"] != 4 {
+		t.Errorf("GroupKindConcurrency = %v", options.GroupKindConcurrency)
+	}
+}